@@ -1,8 +1,12 @@
 package acme
 
 import (
+	"context"
 	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"time"
 
@@ -14,14 +18,36 @@ import (
 // Account is a subset of the internal account type containing only those
 // attributes required for responses in the ACME protocol.
 type Account struct {
-	ID                     string           `json:"-"`
-	Key                    *jose.JSONWebKey `json:"-"`
-	Contact                []string         `json:"contact,omitempty"`
-	Status                 Status           `json:"status"`
-	OrdersURL              string           `json:"orders"`
-	ExternalAccountBinding interface{}      `json:"externalAccountBinding,omitempty"`
-	LocationPrefix         string           `json:"-"`
-	ProvisionerName        string           `json:"-"`
+	ID                     string                    `json:"-"`
+	Key                    *jose.JSONWebKey          `json:"-"`
+	Contact                []string                  `json:"contact,omitempty"`
+	Status                 Status                    `json:"status"`
+	OrdersURL              string                    `json:"orders"`
+	ExternalAccountBinding interface{}               `json:"externalAccountBinding,omitempty"`
+	LocationPrefix         string                    `json:"-"`
+	ProvisionerName        string                    `json:"-"`
+	ClientCertificate      *AccountClientCertificate `json:"-"`
+}
+
+// AccountClientCertificate is the identity of the mTLS client certificate an
+// account was created with. Provisioners that require client certificates
+// only accept requests of the account with a certificate for the same
+// subject.
+type AccountClientCertificate struct {
+	Subject     string    `json:"subject"`
+	Fingerprint string    `json:"fingerprint"`
+	BoundAt     time.Time `json:"boundAt"`
+}
+
+// NewAccountClientCertificate returns the identity of the given client
+// certificate.
+func NewAccountClientCertificate(crt *x509.Certificate) *AccountClientCertificate {
+	sum := sha256.Sum256(crt.Raw)
+	return &AccountClientCertificate{
+		Subject:     crt.Subject.String(),
+		Fingerprint: hex.EncodeToString(sum[:]),
+		BoundAt:     clock.Now(),
+	}
 }
 
 // GetLocation returns the URL location of the given account.
@@ -46,6 +72,52 @@ func (a *Account) IsValid() bool {
 	return a.Status == StatusValid
 }
 
+// Deactivate deactivates the account and invalidates its pending orders and
+// authorizations, as described in RFC 8555 section 7.3.6. Orders or
+// authorizations modified concurrently are left untouched.
+func (a *Account) Deactivate(ctx context.Context, db DB) error {
+	a.Status = StatusDeactivated
+	if err := db.UpdateAccount(ctx, a); err != nil {
+		return WrapErrorISE(err, "error updating account")
+	}
+
+	oids, err := db.GetOrdersByAccountID(ctx, a.ID)
+	if err != nil {
+		return WrapErrorISE(err, "error loading orders for account %s", a.ID)
+	}
+	for _, oid := range oids {
+		o, err := db.GetOrder(ctx, oid)
+		if err != nil {
+			return WrapErrorISE(err, "error loading order %s", oid)
+		}
+		if o.Status != StatusPending && o.Status != StatusReady {
+			continue
+		}
+		from := o.Status
+		o.Status = StatusInvalid
+		o.Error = NewError(ErrorUnauthorizedType, "account %s has been deactivated", a.ID)
+		if err := db.UpdateOrderStatus(ctx, o, from); err != nil && !IsErrConflict(err) {
+			return WrapErrorISE(err, "error updating order %s", oid)
+		}
+	}
+
+	azs, err := db.GetAuthorizationsByAccountID(ctx, a.ID)
+	if err != nil {
+		return WrapErrorISE(err, "error loading authorizations for account %s", a.ID)
+	}
+	for _, az := range azs {
+		if az.Status != StatusPending {
+			continue
+		}
+		az.Status = StatusInvalid
+		az.Error = NewError(ErrorUnauthorizedType, "account %s has been deactivated", a.ID)
+		if err := db.UpdateAuthorization(ctx, az); err != nil {
+			return WrapErrorISE(err, "error updating authorization %s", az.ID)
+		}
+	}
+	return nil
+}
+
 // KeyToID converts a JWK to a thumbprint.
 func KeyToID(jwk *jose.JSONWebKey) (string, error) {
 	kid, err := jwk.Thumbprint(crypto.SHA256)
@@ -1,6 +1,7 @@
 package acme
 
 import (
+	"context"
 	"crypto"
 	"encoding/base64"
 	"testing"
@@ -99,6 +100,58 @@ func TestAccount_IsValid(t *testing.T) {
 	}
 }
 
+func TestAccount_Deactivate(t *testing.T) {
+	orders := map[string]*Order{
+		"pending":  {ID: "pending", Status: StatusPending},
+		"ready":    {ID: "ready", Status: StatusReady},
+		"valid":    {ID: "valid", Status: StatusValid},
+		"conflict": {ID: "conflict", Status: StatusPending},
+	}
+	var updatedOrders, updatedAuthzs []string
+	db := &MockDB{
+		MockUpdateAccount: func(ctx context.Context, acc *Account) error {
+			assert.Equals(t, StatusDeactivated, acc.Status)
+			return nil
+		},
+		MockGetOrdersByAccountID: func(ctx context.Context, accountID string) ([]string, error) {
+			assert.Equals(t, "accID", accountID)
+			return []string{"pending", "ready", "valid", "conflict"}, nil
+		},
+		MockGetOrder: func(ctx context.Context, id string) (*Order, error) {
+			return orders[id], nil
+		},
+		MockUpdateOrderStatus: func(ctx context.Context, o *Order, from Status) error {
+			assert.Equals(t, StatusInvalid, o.Status)
+			assert.Equals(t, "urn:ietf:params:acme:error:unauthorized", o.Error.Type)
+			if o.ID == "conflict" {
+				return errors.Wrap(ErrConflict, "force")
+			}
+			updatedOrders = append(updatedOrders, o.ID+"/"+string(from))
+			return nil
+		},
+		MockGetAuthorizationsByAccountID: func(ctx context.Context, accountID string) ([]*Authorization, error) {
+			return []*Authorization{{ID: "az1", Status: StatusPending}, {ID: "az2", Status: StatusValid}}, nil
+		},
+		MockUpdateAuthorization: func(ctx context.Context, az *Authorization) error {
+			assert.Equals(t, StatusInvalid, az.Status)
+			updatedAuthzs = append(updatedAuthzs, az.ID)
+			return nil
+		},
+	}
+
+	acc := &Account{ID: "accID", Status: StatusValid}
+	assert.FatalError(t, acc.Deactivate(context.Background(), db))
+	assert.Equals(t, StatusDeactivated, acc.Status)
+	assert.Equals(t, []string{"pending/pending", "ready/ready"}, updatedOrders)
+	assert.Equals(t, []string{"az1"}, updatedAuthzs)
+
+	// Errors updating the account are returned.
+	db.MockUpdateAccount = func(ctx context.Context, acc *Account) error {
+		return errors.New("force")
+	}
+	assert.Error(t, acc.Deactivate(context.Background(), db))
+}
+
 func TestExternalAccountKey_BindTo(t *testing.T) {
 	boundAt := time.Now()
 	tests := []struct {
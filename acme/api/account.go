@@ -4,11 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/go-chi/chi"
+	"go.step.sm/crypto/jose"
 
 	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/api/pagination"
 	"github.com/smallstep/certificates/api/render"
 	"github.com/smallstep/certificates/logging"
 )
@@ -131,6 +136,12 @@ func NewAccount(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		limit := prov.GetRateLimitOptions().GetNewAccountsPerIP()
+		if err := acme.ConsumeRateLimit(ctx, db, acme.RateLimitKey(acme.NewAccountRateLimit, prov.GetID(), remoteIP(r)), limit); err != nil {
+			render.Error(w, err)
+			return
+		}
+
 		acc = &acme.Account{
 			Key:             jwk,
 			Contact:         nar.Contact,
@@ -138,6 +149,10 @@ func NewAccount(w http.ResponseWriter, r *http.Request) {
 			LocationPrefix:  getAccountLocationPath(ctx, linker, ""),
 			ProvisionerName: prov.GetName(),
 		}
+		// Bind the account to the identity of the client certificate.
+		if crt, ok := clientCertificateFromContext(ctx); ok {
+			acc.ClientCertificate = acme.NewAccountClientCertificate(crt)
+		}
 		if err := db.CreateAccount(ctx, acc); err != nil {
 			render.Error(w, acme.WrapErrorISE(err, "error creating account"))
 			return
@@ -154,6 +169,7 @@ func NewAccount(w http.ResponseWriter, r *http.Request) {
 			}
 			acc.ExternalAccountBinding = nar.ExternalAccountBinding
 		}
+		acme.MeterFromContext(ctx).AccountCreated(ctx, acc)
 	} else {
 		// Account exists
 		httpStatus = http.StatusOK
@@ -195,13 +211,16 @@ func GetOrUpdateAccount(w http.ResponseWriter, r *http.Request) {
 			render.Error(w, err)
 			return
 		}
-		if len(uar.Status) > 0 || len(uar.Contact) > 0 {
-			if len(uar.Status) > 0 {
-				acc.Status = uar.Status
-			} else if len(uar.Contact) > 0 {
-				acc.Contact = uar.Contact
+		switch {
+		case len(uar.Status) > 0:
+			// Deactivating an account also invalidates its pending orders
+			// and authorizations.
+			if err := acc.Deactivate(ctx, db); err != nil {
+				render.Error(w, err)
+				return
 			}
-
+		case len(uar.Contact) > 0:
+			acc.Contact = uar.Contact
 			if err := db.UpdateAccount(ctx, acc); err != nil {
 				render.Error(w, acme.WrapErrorISE(err, "error updating account"))
 				return
@@ -215,6 +234,148 @@ func GetOrUpdateAccount(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, acc)
 }
 
+// KeyChangeRequest represents the payload of the inner JWS of a key-change
+// request.
+type KeyChangeRequest struct {
+	Account string           `json:"account"`
+	OldKey  *jose.JSONWebKey `json:"oldKey"`
+}
+
+// KeyChange is the handler resource for rolling over the key of an ACME
+// account, as described in RFC 8555 section 7.3.5. The outer JWS is signed by
+// the current key of the account, and its payload is a JWS signed by the new
+// key.
+func KeyChange(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	db := acme.MustDatabaseFromContext(ctx)
+	linker := acme.MustLinkerFromContext(ctx)
+
+	acc, err := accountFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	outer, err := jwsFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	payload, err := payloadFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	inner, err := jose.ParseJWS(string(payload.value))
+	if err != nil {
+		render.Error(w, acme.WrapError(acme.ErrorMalformedType, err, "failed to parse inner JWS"))
+		return
+	}
+	if len(inner.Signatures) != 1 {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType, "inner JWS must contain one signature"))
+		return
+	}
+	sig := inner.Signatures[0]
+	uh := sig.Unprotected
+	if len(uh.KeyID) > 0 || uh.JSONWebKey != nil || len(uh.Algorithm) > 0 || len(uh.Nonce) > 0 || len(uh.ExtraHeaders) > 0 {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType, "inner JWS unprotected header must not be used"))
+		return
+	}
+	hdr := sig.Protected
+	if err := validateJWSAlgorithm(hdr); err != nil {
+		render.Error(w, err)
+		return
+	}
+	if hdr.JSONWebKey == nil || len(hdr.KeyID) > 0 {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType, "inner JWS must have a jwk and no kid in the protected header"))
+		return
+	}
+	if !hdr.JSONWebKey.Valid() || !hdr.JSONWebKey.IsPublic() {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType, "invalid jwk in inner JWS protected header"))
+		return
+	}
+	if innerURL, ok := hdr.ExtraHeaders["url"].(string); !ok || innerURL != outer.Signatures[0].Protected.ExtraHeaders["url"] {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType, "url header in inner JWS does not match outer JWS"))
+		return
+	}
+	b, err := inner.Verify(hdr.JSONWebKey)
+	if err != nil {
+		render.Error(w, acme.WrapError(acme.ErrorMalformedType, err, "error verifying inner JWS"))
+		return
+	}
+
+	var kcr KeyChangeRequest
+	if err := json.Unmarshal(b, &kcr); err != nil {
+		render.Error(w, acme.WrapError(acme.ErrorMalformedType, err,
+			"failed to unmarshal key-change request payload"))
+		return
+	}
+	if kcr.Account != outer.Signatures[0].Protected.KeyID {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType, "key-change account does not match outer JWS kid"))
+		return
+	}
+	if kcr.OldKey == nil {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType, "key-change request is missing the old key"))
+		return
+	}
+	oldKid, err := acme.KeyToID(kcr.OldKey)
+	if err != nil {
+		render.Error(w, acme.WrapError(acme.ErrorMalformedType, err, "invalid old key in key-change request"))
+		return
+	}
+	accKid, err := acme.KeyToID(acc.Key)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	if oldKid != accKid {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType, "old key in key-change request does not match the account key"))
+		return
+	}
+
+	newKey := hdr.JSONWebKey
+	if newKey.KeyID, err = acme.KeyToID(newKey); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	// The new key cannot be bound to an account, including this one.
+	existing, err := db.GetAccountByKeyID(ctx, newKey.KeyID)
+	switch {
+	case err == nil:
+		w.Header().Set("Location", getAccountLocationPath(ctx, linker, existing.ID))
+		render.Error(w, errKeyInUse())
+		return
+	case !acme.IsErrNotFound(err):
+		render.Error(w, acme.WrapErrorISE(err, "error loading account by key"))
+		return
+	}
+
+	acc.Key = newKey
+	if err := db.UpdateAccountKey(ctx, acc); err != nil {
+		if acme.IsErrConflict(err) {
+			render.Error(w, errKeyInUse())
+			return
+		}
+		render.Error(w, acme.WrapErrorISE(err, "error updating account key"))
+		return
+	}
+
+	linker.LinkAccount(ctx, acc)
+
+	w.Header().Set("Location", getAccountLocationPath(ctx, linker, acc.ID))
+	render.JSON(w, acc)
+}
+
+// errKeyInUse returns the error used when the new key of a key-change request
+// is already bound to an account. RFC 8555 section 7.3.5 requires a 409
+// Conflict status.
+func errKeyInUse() *acme.Error {
+	acmeErr := acme.NewError(acme.ErrorMalformedType, "new key is already in use by an account")
+	acmeErr.Status = http.StatusConflict
+	return acmeErr
+}
+
 func logOrdersByAccount(w http.ResponseWriter, oids []string) {
 	if rl, ok := w.(logging.ResponseLogger); ok {
 		m := map[string]interface{}{
@@ -241,14 +402,43 @@ func GetOrdersByAccountID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	opts, err := pagination.Parse(r, nil, nil)
+	if err != nil {
+		render.Error(w, acme.WrapError(acme.ErrorMalformedType, err, "error parsing cursor and limit"))
+		return
+	}
+
 	orders, err := db.GetOrdersByAccountID(ctx, acc.ID)
 	if err != nil {
 		render.Error(w, err)
 		return
 	}
 
+	// Per RFC 8555 §7.1.2.1, the list is paginated using the Link header.
+	orders, next, err := pagination.Paginate(orders, opts, func(id string) string { return id })
+	if err != nil {
+		render.Error(w, acme.WrapError(acme.ErrorMalformedType, err, "error paginating orders"))
+		return
+	}
+	if next != "" {
+		q := url.Values{"cursor": {next}}
+		if opts.Limit > 0 {
+			q.Set("limit", strconv.Itoa(opts.Limit))
+		}
+		w.Header().Add("Link", link(linker.GetLink(ctx, acme.OrdersByAccountLinkType, acc.ID)+"?"+q.Encode(), "next"))
+	}
+
 	linker.LinkOrdersByAccountID(ctx, orders)
 
 	render.JSON(w, orders)
 	logOrdersByAccount(w, orders)
 }
+
+// remoteIP returns the IP address of the client without the port.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
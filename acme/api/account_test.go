@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -47,7 +48,38 @@ func (*fakeProvisioner) IsChallengeEnabled(context.Context, provisioner.ACMEChal
 func (*fakeProvisioner) IsAttestationFormatEnabled(context.Context, provisioner.ACMEAttestationFormat) bool {
 	return true
 }
-func (*fakeProvisioner) GetAttestationRoots() (*x509.CertPool, bool)   { return nil, false }
+func (*fakeProvisioner) GetAttestationRoots() (*x509.CertPool, bool)    { return nil, false }
+func (*fakeProvisioner) GetDNS01Options() *provisioner.ACMEDNS01Options { return nil }
+func (*fakeProvisioner) GetHTTP01Options() *provisioner.ACMEHTTP01Options {
+	return nil
+}
+func (*fakeProvisioner) GetCAAOptions() *provisioner.ACMECAAOptions { return nil }
+func (*fakeProvisioner) GetValidationOptions() *provisioner.ACMEValidationOptions {
+	return nil
+}
+func (*fakeProvisioner) GetApprovalOptions() *provisioner.ACMEApprovalOptions {
+	return nil
+}
+func (*fakeProvisioner) GetAutoRenewalOptions() *provisioner.ACMEAutoRenewalOptions {
+	return nil
+}
+func (*fakeProvisioner) GetRateLimitOptions() *provisioner.ACMERateLimitOptions {
+	return nil
+}
+func (*fakeProvisioner) GetChallengeDelegation(string) *provisioner.ACMEChallengeDelegation {
+	return nil
+}
+func (*fakeProvisioner) GetEmailReplyOptions() *provisioner.ACMEEmailReplyOptions {
+	return nil
+}
+func (*fakeProvisioner) GetOIDCOptions() *provisioner.ACMEOIDCOptions {
+	return nil
+}
+func (*fakeProvisioner) RequireClientCertificate() bool                    { return false }
+func (*fakeProvisioner) VerifyClientCertificate([]*x509.Certificate) error { return nil }
+func (*fakeProvisioner) AuthorizeSSHSign(context.Context, string) ([]provisioner.SignOption, error) {
+	return nil, nil
+}
 func (*fakeProvisioner) AuthorizeRevoke(context.Context, string) error { return nil }
 func (*fakeProvisioner) GetID() string                                 { return "" }
 func (*fakeProvisioner) GetName() string                               { return "" }
@@ -299,8 +331,10 @@ func TestHandler_GetOrdersByAccountID(t *testing.T) {
 	type test struct {
 		db         acme.DB
 		ctx        context.Context
+		query      string
 		statusCode int
 		err        *acme.Error
+		link       string
 	}
 	var tests = map[string]func(t *testing.T) test{
 		"fail/no-account": func(t *testing.T) test {
@@ -359,12 +393,58 @@ func TestHandler_GetOrdersByAccountID(t *testing.T) {
 				statusCode: 200,
 			}
 		},
+		"ok/paginated": func(t *testing.T) test {
+			acc := &acme.Account{ID: accID}
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+			ctx = acme.NewProvisionerContext(ctx, prov)
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			return test{
+				db: &acme.MockDB{
+					MockGetOrdersByAccountID: func(ctx context.Context, id string) ([]string, error) {
+						return []string{"foo", "bar", "baz"}, nil
+					},
+				},
+				ctx:        ctx,
+				query:      "?limit=2",
+				statusCode: 200,
+				link:       fmt.Sprintf(`<%s/acme/%s/account/%s/orders?cursor=baz&limit=2>;rel="next"`, baseURL.String(), provName, accID),
+			}
+		},
+		"fail/cursor": func(t *testing.T) test {
+			acc := &acme.Account{ID: accID}
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+			ctx = acme.NewProvisionerContext(ctx, prov)
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			return test{
+				db: &acme.MockDB{
+					MockGetOrdersByAccountID: func(ctx context.Context, id string) ([]string, error) {
+						return oids, nil
+					},
+				},
+				ctx:        ctx,
+				query:      "?cursor=missing",
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "error paginating orders"),
+			}
+		},
+		"fail/limit": func(t *testing.T) test {
+			acc := &acme.Account{ID: accID}
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			return test{
+				db:         &acme.MockDB{},
+				ctx:        ctx,
+				query:      "?limit=foo",
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "error parsing cursor and limit"),
+			}
+		},
 	}
 	for name, run := range tests {
 		tc := run(t)
 		t.Run(name, func(t *testing.T) {
 			ctx := acme.NewContext(tc.ctx, tc.db, nil, acme.NewLinker("test.ca.smallstep.com", "acme"), nil)
-			req := httptest.NewRequest("GET", u, http.NoBody)
+			req := httptest.NewRequest("GET", u+tc.query, http.NoBody)
 			req = req.WithContext(ctx)
 			w := httptest.NewRecorder()
 			GetOrdersByAccountID(w, req)
@@ -389,6 +469,7 @@ func TestHandler_GetOrdersByAccountID(t *testing.T) {
 				assert.FatalError(t, err)
 				assert.Equals(t, bytes.TrimSpace(body), expB)
 				assert.Equals(t, res.Header["Content-Type"], []string{"application/json"})
+				assert.Equals(t, res.Header.Get("Link"), tc.link)
 			}
 		})
 	}
@@ -635,6 +716,36 @@ func TestHandler_NewAccount(t *testing.T) {
 				err:        acme.NewError(acme.ErrorServerInternalType, "error updating external account binding key"),
 			}
 		},
+		"fail/rate-limited": func(t *testing.T) test {
+			nar := &NewAccountRequest{
+				Contact: []string{"foo", "bar"},
+			}
+			b, err := json.Marshal(nar)
+			assert.FatalError(t, err)
+			jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+			assert.FatalError(t, err)
+			limited := &provisioner.ACME{
+				Type: "ACME",
+				Name: "limited",
+				RateLimits: &provisioner.ACMERateLimitOptions{
+					NewAccountsPerIP: &provisioner.ACMERateLimit{Limit: 1, Window: provisioner.Duration{Duration: time.Hour}},
+				},
+			}
+			ctx := context.WithValue(context.Background(), payloadContextKey, &payloadInfo{value: b})
+			ctx = context.WithValue(ctx, jwkContextKey, jwk)
+			ctx = acme.NewProvisionerContext(ctx, limited)
+			return test{
+				db: &acme.MockDB{
+					MockGetRateLimitEvents: func(ctx context.Context, key string) ([]time.Time, error) {
+						assert.Equals(t, key, "newAccount:"+limited.GetID()+":192.0.2.1")
+						return []time.Time{time.Now()}, nil
+					},
+				},
+				ctx:        ctx,
+				statusCode: 429,
+				err:        acme.NewDetailedError(acme.ErrorRateLimitedType, "1 requests in 1h0m0s"),
+			}
+		},
 		"ok/new-account": func(t *testing.T) test {
 			nar := &NewAccountRequest{
 				Contact: []string{"foo", "bar"},
@@ -666,6 +777,41 @@ func TestHandler_NewAccount(t *testing.T) {
 				statusCode: 201,
 			}
 		},
+		"ok/new-account-client-certificate": func(t *testing.T) test {
+			nar := &NewAccountRequest{
+				Contact: []string{"foo", "bar"},
+			}
+			b, err := json.Marshal(nar)
+			assert.FatalError(t, err)
+			jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+			assert.FatalError(t, err)
+			crt := &x509.Certificate{Raw: []byte("client"), Subject: pkix.Name{CommonName: "client.internal"}}
+			ctx := context.WithValue(context.Background(), payloadContextKey, &payloadInfo{value: b})
+			ctx = context.WithValue(ctx, jwkContextKey, jwk)
+			ctx = context.WithValue(ctx, clientCertificateContextKey, crt)
+			ctx = acme.NewProvisionerContext(ctx, prov)
+			return test{
+				db: &acme.MockDB{
+					MockCreateAccount: func(ctx context.Context, acc *acme.Account) error {
+						acc.ID = "accountID"
+						if assert.NotNil(t, acc.ClientCertificate) {
+							assert.Equals(t, acc.ClientCertificate.Subject, "CN=client.internal")
+							assert.Equals(t, acc.ClientCertificate.Fingerprint, "948fe603f61dc036b5c596dc09fe3ce3f3d30dc90f024c85f3c82db2ccab679d")
+						}
+						return nil
+					},
+				},
+				acc: &acme.Account{
+					ID:        "accountID",
+					Key:       jwk,
+					Status:    acme.StatusValid,
+					Contact:   []string{"foo", "bar"},
+					OrdersURL: fmt.Sprintf("%s/acme/%s/account/accountID/orders", baseURL.String(), escProvName),
+				},
+				ctx:        ctx,
+				statusCode: 201,
+			}
+		},
 		"ok/return-existing": func(t *testing.T) test {
 			nar := &NewAccountRequest{
 				OnlyReturnExisting: true,
@@ -952,6 +1098,14 @@ func TestHandler_GetOrUpdateAccount(t *testing.T) {
 						assert.Equals(t, upd.ID, acc.ID)
 						return nil
 					},
+					MockGetOrdersByAccountID: func(ctx context.Context, accountID string) ([]string, error) {
+						assert.Equals(t, accountID, acc.ID)
+						return []string{}, nil
+					},
+					MockGetAuthorizationsByAccountID: func(ctx context.Context, accountID string) ([]*acme.Authorization, error) {
+						assert.Equals(t, accountID, acc.ID)
+						return []*acme.Authorization{}, nil
+					},
 				},
 				ctx:        ctx,
 				statusCode: 200,
@@ -1038,3 +1192,217 @@ func TestHandler_GetOrUpdateAccount(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_KeyChange(t *testing.T) {
+	accID := "accountID"
+	prov := newProv()
+	escProvName := url.PathEscape(prov.GetName())
+	baseURL := &url.URL{Scheme: "https", Host: "test.ca.smallstep.com"}
+	kid := fmt.Sprintf("%s/acme/%s/account/%s", baseURL.String(), escProvName, accID)
+	keyChangeURL := fmt.Sprintf("%s/acme/%s/key-change", baseURL.String(), escProvName)
+
+	oldJWK, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	oldPub := oldJWK.Public()
+	newJWK, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	otherJWK, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+
+	sign := func(t *testing.T, key *jose.JSONWebKey, u string, v interface{}) []byte {
+		t.Helper()
+		payload, err := json.Marshal(v)
+		assert.FatalError(t, err)
+		so := new(jose.SignerOptions)
+		so.WithHeader("url", u)
+		so.EmbedJWK = true
+		signer, err := jose.NewSigner(jose.SigningKey{
+			Algorithm: jose.SignatureAlgorithm(key.Algorithm),
+			Key:       key.Key,
+		}, so)
+		assert.FatalError(t, err)
+		jws, err := signer.Sign(payload)
+		assert.FatalError(t, err)
+		return []byte(jws.FullSerialize())
+	}
+	outer := func(t *testing.T) *jose.JSONWebSignature {
+		t.Helper()
+		so := new(jose.SignerOptions)
+		so.WithHeader("url", keyChangeURL)
+		so.WithHeader("kid", kid)
+		signer, err := jose.NewSigner(jose.SigningKey{
+			Algorithm: jose.SignatureAlgorithm(oldJWK.Algorithm),
+			Key:       oldJWK.Key,
+		}, so)
+		assert.FatalError(t, err)
+		jws, err := signer.Sign([]byte("{}"))
+		assert.FatalError(t, err)
+		raw, err := jws.CompactSerialize()
+		assert.FatalError(t, err)
+		parsed, err := jose.ParseJWS(raw)
+		assert.FatalError(t, err)
+		return parsed
+	}
+	newContext := func(t *testing.T, payload []byte) context.Context {
+		acc := &acme.Account{ID: accID, Key: &oldPub, Status: acme.StatusValid}
+		ctx := acme.NewProvisionerContext(context.Background(), prov)
+		ctx = context.WithValue(ctx, accContextKey, acc)
+		ctx = context.WithValue(ctx, jwsContextKey, outer(t))
+		return context.WithValue(ctx, payloadContextKey, &payloadInfo{value: payload})
+	}
+
+	type test struct {
+		db         acme.DB
+		ctx        context.Context
+		statusCode int
+		location   string
+		err        *acme.Error
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/no-account": func(t *testing.T) test {
+			return test{
+				db:         &acme.MockDB{},
+				ctx:        context.Background(),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorAccountDoesNotExistType, "account does not exist"),
+			}
+		},
+		"fail/parse-inner": func(t *testing.T) test {
+			return test{
+				db:         &acme.MockDB{},
+				ctx:        newContext(t, []byte("{}")),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "failed to parse inner JWS"),
+			}
+		},
+		"fail/url": func(t *testing.T) test {
+			payload := sign(t, newJWK, "https://example.com", &KeyChangeRequest{Account: kid, OldKey: &oldPub})
+			return test{
+				db:         &acme.MockDB{},
+				ctx:        newContext(t, payload),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "url header in inner JWS does not match outer JWS"),
+			}
+		},
+		"fail/account": func(t *testing.T) test {
+			payload := sign(t, newJWK, keyChangeURL, &KeyChangeRequest{Account: kid + "foo", OldKey: &oldPub})
+			return test{
+				db:         &acme.MockDB{},
+				ctx:        newContext(t, payload),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "key-change account does not match outer JWS kid"),
+			}
+		},
+		"fail/old-key": func(t *testing.T) test {
+			otherPub := otherJWK.Public()
+			payload := sign(t, newJWK, keyChangeURL, &KeyChangeRequest{Account: kid, OldKey: &otherPub})
+			return test{
+				db:         &acme.MockDB{},
+				ctx:        newContext(t, payload),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "old key in key-change request does not match the account key"),
+			}
+		},
+		"fail/key-in-use": func(t *testing.T) test {
+			payload := sign(t, newJWK, keyChangeURL, &KeyChangeRequest{Account: kid, OldKey: &oldPub})
+			return test{
+				db: &acme.MockDB{
+					MockGetAccountByKeyID: func(ctx context.Context, kid string) (*acme.Account, error) {
+						return &acme.Account{ID: "otherID"}, nil
+					},
+				},
+				ctx:        newContext(t, payload),
+				statusCode: 409,
+				location:   fmt.Sprintf("%s/acme/%s/account/otherID", baseURL.String(), escProvName),
+				err:        acme.NewError(acme.ErrorMalformedType, "new key is already in use by an account"),
+			}
+		},
+		"fail/db.UpdateAccountKey-conflict": func(t *testing.T) test {
+			payload := sign(t, newJWK, keyChangeURL, &KeyChangeRequest{Account: kid, OldKey: &oldPub})
+			return test{
+				db: &acme.MockDB{
+					MockGetAccountByKeyID: func(ctx context.Context, kid string) (*acme.Account, error) {
+						return nil, acme.ErrNotFound
+					},
+					MockUpdateAccountKey: func(ctx context.Context, acc *acme.Account) error {
+						return errors.Wrap(acme.ErrConflict, "force")
+					},
+				},
+				ctx:        newContext(t, payload),
+				statusCode: 409,
+				err:        acme.NewError(acme.ErrorMalformedType, "new key is already in use by an account"),
+			}
+		},
+		"fail/db.UpdateAccountKey-error": func(t *testing.T) test {
+			payload := sign(t, newJWK, keyChangeURL, &KeyChangeRequest{Account: kid, OldKey: &oldPub})
+			return test{
+				db: &acme.MockDB{
+					MockGetAccountByKeyID: func(ctx context.Context, kid string) (*acme.Account, error) {
+						return nil, acme.ErrNotFound
+					},
+					MockUpdateAccountKey: func(ctx context.Context, acc *acme.Account) error {
+						return errors.New("force")
+					},
+				},
+				ctx:        newContext(t, payload),
+				statusCode: 500,
+				err:        acme.NewErrorISE("force"),
+			}
+		},
+		"ok": func(t *testing.T) test {
+			payload := sign(t, newJWK, keyChangeURL, &KeyChangeRequest{Account: kid, OldKey: &oldPub})
+			newKid, err := acme.KeyToID(newJWK)
+			assert.FatalError(t, err)
+			return test{
+				db: &acme.MockDB{
+					MockGetAccountByKeyID: func(ctx context.Context, kid string) (*acme.Account, error) {
+						assert.Equals(t, kid, newKid)
+						return nil, acme.ErrNotFound
+					},
+					MockUpdateAccountKey: func(ctx context.Context, acc *acme.Account) error {
+						assert.Equals(t, acc.ID, accID)
+						assert.Equals(t, acc.Key.KeyID, newKid)
+						assert.True(t, acc.Key.IsPublic())
+						return nil
+					},
+				},
+				ctx:        newContext(t, payload),
+				statusCode: 200,
+				location:   kid,
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			ctx := acme.NewContext(tc.ctx, tc.db, nil, acme.NewLinker("test.ca.smallstep.com", "acme"), nil)
+			req := httptest.NewRequest("POST", "/foo/bar", http.NoBody)
+			req = req.WithContext(ctx)
+			w := httptest.NewRecorder()
+			KeyChange(w, req)
+			res := w.Result()
+
+			assert.Equals(t, res.StatusCode, tc.statusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if tc.location != "" {
+				assert.Equals(t, res.Header["Location"], []string{tc.location})
+			}
+			if res.StatusCode >= 400 && assert.NotNil(t, tc.err) {
+				var ae acme.Error
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &ae))
+				assert.Equals(t, ae.Type, tc.err.Type)
+				assert.Equals(t, ae.Detail, tc.err.Detail)
+				assert.Equals(t, res.Header["Content-Type"], []string{"application/problem+json"})
+			} else {
+				var acc acme.Account
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &acc))
+				assert.Equals(t, acc.Status, acme.StatusValid)
+				assert.Equals(t, res.Header["Content-Type"], []string{"application/json"})
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/api/render"
+)
+
+// NewAuthzRequest represents the body for a NewAuthz request.
+type NewAuthzRequest struct {
+	Identifier acme.Identifier `json:"identifier"`
+}
+
+// Validate validates a new-authz request body.
+func (n *NewAuthzRequest) Validate() error {
+	// RFC 8555 section 7.4.1: pre-authorization cannot be used to authorize
+	// the issuance of certificates with wildcard domain names.
+	if n.Identifier.Type == acme.DNS && strings.HasPrefix(n.Identifier.Value, "*.") {
+		return acme.NewError(acme.ErrorRejectedIdentifierType, "wildcard identifiers cannot be pre-authorized")
+	}
+	nor := &NewOrderRequest{Identifiers: []acme.Identifier{n.Identifier}}
+	return nor.Validate()
+}
+
+// NewAuthz ACME api for creating a pre-authorization, as defined in RFC 8555
+// section 7.4.1. If the provisioner reuses authorizations, a valid
+// authorization for the identifier is returned instead of a new one.
+func NewAuthz(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ca := mustAuthority(ctx)
+	db := acme.MustDatabaseFromContext(ctx)
+	linker := acme.MustLinkerFromContext(ctx)
+
+	acc, err := accountFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	prov, err := provisionerFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	payload, err := payloadFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	acmeProv, err := acmeProvisionerFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	orderOpts := acmeProv.GetOrderOptions()
+	if !orderOpts.IsPreAuthorizationEnabled() {
+		render.Error(w, acme.NewError(acme.ErrorNotImplementedType, "pre-authorization is not enabled"))
+		return
+	}
+
+	var nar NewAuthzRequest
+	if err := json.Unmarshal(payload.value, &nar); err != nil {
+		render.Error(w, acme.WrapError(acme.ErrorMalformedType, err,
+			"failed to unmarshal new-authz request payload"))
+		return
+	}
+	if err := nar.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	acmePolicy, err := accountPolicyEngine(ctx, acmeProv, prov, acc)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	if err := authorizeIdentifier(ctx, ca, prov, acmePolicy, nar.Identifier); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	status := http.StatusCreated
+	var az *acme.Authorization
+	if reuse := orderOpts.GetAuthorizationReuse(); reuse > 0 {
+		azs, err := db.GetAuthorizationsByAccountID(ctx, acc.ID)
+		if err != nil {
+			render.Error(w, acme.WrapErrorISE(err, "error retrieving authorizations"))
+			return
+		}
+		if az, err = findReusableAuthorization(ctx, db, azs, nar.Identifier, reuse); err != nil {
+			render.Error(w, err)
+			return
+		}
+	}
+	if az != nil {
+		status = http.StatusOK
+	} else {
+		az = &acme.Authorization{
+			AccountID:  acc.ID,
+			Identifier: nar.Identifier,
+			ExpiresAt:  clock.Now().Add(orderOpts.GetAuthorizationLifetime()),
+			Status:     acme.StatusPending,
+		}
+		if err := newAuthorization(ctx, az); err != nil {
+			render.Error(w, err)
+			return
+		}
+	}
+
+	linker.LinkAuthorization(ctx, az)
+
+	w.Header().Set("Location", linker.GetLink(ctx, acme.AuthzLinkType, az.ID))
+	render.JSONStatus(w, az, status)
+}
+
+// findReusableAuthorization returns the first valid authorization in the
+// list for the given identifier that was validated within the reuse window.
+// It returns nil if there is none.
+func findReusableAuthorization(ctx context.Context, db acme.DB, azs []*acme.Authorization, identifier acme.Identifier, reuse time.Duration) (*acme.Authorization, error) {
+	if reuse <= 0 {
+		return nil, nil
+	}
+
+	now := clock.Now()
+	value, isWildcard := trimIfWildcard(identifier.Value)
+	for _, az := range azs {
+		if az.Status != acme.StatusValid || az.Wildcard != isWildcard ||
+			az.Identifier.Type != identifier.Type || az.Identifier.Value != value {
+			continue
+		}
+		// The list of authorizations does not include the challenges.
+		az, err := db.GetAuthorization(ctx, az.ID)
+		if err != nil {
+			return nil, acme.WrapErrorISE(err, "error retrieving authorization")
+		}
+		if validatedAt, ok := authorizationValidatedAt(az); ok && now.Sub(validatedAt) <= reuse {
+			return az, nil
+		}
+	}
+	return nil, nil
+}
+
+// authorizationValidatedAt returns the time the last valid challenge of the
+// authorization was validated.
+func authorizationValidatedAt(az *acme.Authorization) (time.Time, bool) {
+	var validatedAt time.Time
+	for _, ch := range az.Challenges {
+		if ch.Status != acme.StatusValid {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, ch.ValidatedAt); err == nil && t.After(validatedAt) {
+			validatedAt = t
+		}
+	}
+	return validatedAt, !validatedAt.IsZero()
+}
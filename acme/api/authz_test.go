@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func TestHandler_NewAuthz(t *testing.T) {
+	prov := newACMEProv(t)
+	prov.Orders = &provisioner.ACMEOrderOptions{
+		PreAuthorization:   true,
+		AuthorizationReuse: &provisioner.Duration{Duration: 8 * time.Hour},
+	}
+	escProvName := url.PathEscape(prov.GetName())
+	baseURL := &url.URL{Scheme: "https", Host: "test.ca.smallstep.com"}
+	acc := &acme.Account{ID: "accID"}
+
+	newContext := func(p acme.Provisioner, nar *NewAuthzRequest) context.Context {
+		b, err := json.Marshal(nar)
+		assert.FatalError(t, err)
+		ctx := acme.NewProvisionerContext(context.Background(), p)
+		ctx = context.WithValue(ctx, accContextKey, acc)
+		return context.WithValue(ctx, payloadContextKey, &payloadInfo{value: b})
+	}
+
+	type test struct {
+		db         acme.DB
+		ctx        context.Context
+		statusCode int
+		authzID    string
+		err        *acme.Error
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/disabled": func(t *testing.T) test {
+			return test{
+				db:         &acme.MockDB{},
+				ctx:        newContext(newACMEProv(t), &NewAuthzRequest{Identifier: acme.Identifier{Type: "dns", Value: "zap.internal"}}),
+				statusCode: 501,
+				err:        acme.NewError(acme.ErrorNotImplementedType, "pre-authorization is not enabled"),
+			}
+		},
+		"fail/wildcard": func(t *testing.T) test {
+			return test{
+				db:         &acme.MockDB{},
+				ctx:        newContext(prov, &NewAuthzRequest{Identifier: acme.Identifier{Type: "dns", Value: "*.zap.internal"}}),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorRejectedIdentifierType, "wildcard identifiers cannot be pre-authorized"),
+			}
+		},
+		"fail/identifier": func(t *testing.T) test {
+			return test{
+				db:         &acme.MockDB{},
+				ctx:        newContext(prov, &NewAuthzRequest{Identifier: acme.Identifier{Type: "ip", Value: "zap.internal"}}),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "invalid IP address: zap.internal"),
+			}
+		},
+		"fail/db.GetAuthorizationsByAccountID": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetAuthorizationsByAccountID: func(ctx context.Context, accountID string) ([]*acme.Authorization, error) {
+						return nil, acme.NewErrorISE("force")
+					},
+				},
+				ctx:        newContext(prov, &NewAuthzRequest{Identifier: acme.Identifier{Type: "dns", Value: "zap.internal"}}),
+				statusCode: 500,
+				err:        acme.NewErrorISE("error retrieving authorizations: force"),
+			}
+		},
+		"ok/new": func(t *testing.T) test {
+			var chCount int
+			return test{
+				db: &acme.MockDB{
+					MockGetAuthorizationsByAccountID: func(ctx context.Context, accountID string) ([]*acme.Authorization, error) {
+						return []*acme.Authorization{}, nil
+					},
+					MockCreateChallenge: func(ctx context.Context, ch *acme.Challenge) error {
+						chCount++
+						ch.ID = fmt.Sprintf("ch%d", chCount)
+						assert.Equals(t, "accID", ch.AccountID)
+						assert.Equals(t, "zap.internal", ch.Value)
+						return nil
+					},
+					MockCreateAuthorization: func(ctx context.Context, az *acme.Authorization) error {
+						az.ID = "newID"
+						assert.Equals(t, "accID", az.AccountID)
+						assert.Equals(t, acme.StatusPending, az.Status)
+						assert.Equals(t, acme.Identifier{Type: "dns", Value: "zap.internal"}, az.Identifier)
+						assert.Len(t, 3, az.Challenges)
+						return nil
+					},
+				},
+				ctx:        newContext(prov, &NewAuthzRequest{Identifier: acme.Identifier{Type: "dns", Value: "zap.internal"}}),
+				statusCode: 201,
+				authzID:    "newID",
+			}
+		},
+		"ok/reuse": func(t *testing.T) test {
+			validatedAt := clock.Now().Add(-time.Hour).Format(time.RFC3339)
+			return test{
+				db: &acme.MockDB{
+					MockGetAuthorizationsByAccountID: func(ctx context.Context, accountID string) ([]*acme.Authorization, error) {
+						return []*acme.Authorization{
+							{ID: "validID", Identifier: acme.Identifier{Type: "dns", Value: "zap.internal"}, Status: acme.StatusValid},
+						}, nil
+					},
+					MockGetAuthorization: func(ctx context.Context, id string) (*acme.Authorization, error) {
+						return &acme.Authorization{
+							ID:         id,
+							Identifier: acme.Identifier{Type: "dns", Value: "zap.internal"},
+							Status:     acme.StatusValid,
+							Challenges: []*acme.Challenge{{ID: "ch1", Status: acme.StatusValid, ValidatedAt: validatedAt}},
+						}, nil
+					},
+				},
+				ctx:        newContext(prov, &NewAuthzRequest{Identifier: acme.Identifier{Type: "dns", Value: "zap.internal"}}),
+				statusCode: 200,
+				authzID:    "validID",
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			mockMustAuthority(t, &mockCA{})
+			ctx := newBaseContext(tc.ctx, tc.db, acme.NewLinker("test.ca.smallstep.com", "acme"))
+			req := httptest.NewRequest("POST", "/foo", http.NoBody).WithContext(ctx)
+			w := httptest.NewRecorder()
+			NewAuthz(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 && assert.NotNil(t, tc.err) {
+				var ae acme.Error
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &ae))
+				assert.Equals(t, tc.err.Type, ae.Type)
+				assert.Equals(t, tc.err.Detail, ae.Detail)
+				return
+			}
+
+			az := new(acme.Authorization)
+			assert.FatalError(t, json.Unmarshal(body, az))
+			assert.Equals(t, acme.Identifier{Type: "dns", Value: "zap.internal"}, az.Identifier)
+			assert.Equals(t, []string{fmt.Sprintf("%s/acme/%s/authz/%s", baseURL.String(), escProvName, tc.authzID)}, res.Header["Location"])
+		})
+	}
+}
+
+func Test_findReusableAuthorization(t *testing.T) {
+	now := clock.Now()
+	identifier := acme.Identifier{Type: "dns", Value: "zap.internal"}
+	db := &acme.MockDB{
+		MockGetAuthorization: func(ctx context.Context, id string) (*acme.Authorization, error) {
+			var validatedAt string
+			switch id {
+			case "recent", "wildcard":
+				validatedAt = now.Add(-time.Minute).Format(time.RFC3339)
+			case "old":
+				validatedAt = now.Add(-2 * time.Hour).Format(time.RFC3339)
+			}
+			return &acme.Authorization{
+				ID:         id,
+				Identifier: identifier,
+				Status:     acme.StatusValid,
+				Challenges: []*acme.Challenge{{Status: acme.StatusValid, ValidatedAt: validatedAt}},
+			}, nil
+		},
+	}
+	azs := []*acme.Authorization{
+		{ID: "pending", Identifier: identifier, Status: acme.StatusPending},
+		{ID: "old", Identifier: identifier, Status: acme.StatusValid},
+		{ID: "other", Identifier: acme.Identifier{Type: "dns", Value: "other.internal"}, Status: acme.StatusValid},
+		{ID: "wildcard", Identifier: identifier, Status: acme.StatusValid, Wildcard: true},
+		{ID: "recent", Identifier: identifier, Status: acme.StatusValid},
+	}
+
+	tests := []struct {
+		name       string
+		identifier acme.Identifier
+		reuse      time.Duration
+		want       string
+	}{
+		{"ok", identifier, time.Hour, "recent"},
+		{"ok/window", identifier, 3 * time.Hour, "old"},
+		{"ok/wildcard", acme.Identifier{Type: "dns", Value: "*.zap.internal"}, time.Hour, "wildcard"},
+		{"ok/disabled", identifier, 0, ""},
+		{"ok/none", acme.Identifier{Type: "dns", Value: "none.internal"}, time.Hour, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findReusableAuthorization(context.Background(), db, azs, tt.identifier, tt.reuse)
+			assert.FatalError(t, err)
+			if tt.want == "" {
+				assert.Nil(t, got)
+			} else if assert.NotNil(t, got) {
+				assert.Equals(t, tt.want, got.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// DiagnoseRequest represents the body for a diagnose request.
+type DiagnoseRequest struct {
+	Type       acme.ChallengeType `json:"type"`
+	Identifier acme.Identifier    `json:"identifier"`
+	Token      string             `json:"token"`
+}
+
+// Validate validates a diagnose request body.
+func (d *DiagnoseRequest) Validate() error {
+	return acme.ValidateDiagnostic(d.Type, d.Identifier, d.Token)
+}
+
+// Diagnose is the ACME resource that validates a challenge of the given type
+// for an identifier using the key of the account, without creating an order.
+// It returns the operations performed and their results, and it's meant to
+// debug failed challenges.
+func Diagnose(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	acc, err := accountFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	prov, err := provisionerFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	payload, err := payloadFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	var dr DiagnoseRequest
+	if err := json.Unmarshal(payload.value, &dr); err != nil {
+		render.Error(w, acme.WrapError(acme.ErrorMalformedType, err,
+			"failed to unmarshal diagnose request payload"))
+		return
+	}
+	if err := dr.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+	if !prov.IsChallengeEnabled(ctx, provisioner.ACMEChallenge(dr.Type)) {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType,
+			"challenge type %s is not enabled in the provisioner", dr.Type))
+		return
+	}
+
+	d, err := acme.Diagnose(ctx, dr.Type, dr.Identifier, dr.Token, acc.Key)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, d)
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func TestDiagnose(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	keyAuth, err := acme.KeyAuthorization("token", jwk)
+	assert.FatalError(t, err)
+	acc := &acme.Account{ID: "accountID", Key: jwk}
+
+	client := &mockClient{
+		get: func(url string) (*http.Response, error) {
+			assert.Equals(t, "http://example.com/.well-known/acme-challenge/token", url)
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewBufferString(keyAuth)),
+			}, nil
+		},
+	}
+	payload := func(t *testing.T, v interface{}) *payloadInfo {
+		b, err := json.Marshal(v)
+		assert.FatalError(t, err)
+		return &payloadInfo{value: b}
+	}
+
+	type test struct {
+		ctx        context.Context
+		statusCode int
+		err        *acme.Error
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/no-account": func(t *testing.T) test {
+			return test{
+				ctx:        acme.NewProvisionerContext(context.Background(), newProv()),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorAccountDoesNotExistType, "account does not exist"),
+			}
+		},
+		"fail/unmarshal": func(t *testing.T) test {
+			ctx := acme.NewProvisionerContext(context.Background(), newProv())
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			ctx = context.WithValue(ctx, payloadContextKey, &payloadInfo{value: []byte("{")})
+			return test{
+				ctx:        ctx,
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "failed to unmarshal diagnose request payload"),
+			}
+		},
+		"fail/wildcard": func(t *testing.T) test {
+			ctx := acme.NewProvisionerContext(context.Background(), newProv())
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			ctx = context.WithValue(ctx, payloadContextKey, payload(t, &DiagnoseRequest{
+				Type:       acme.HTTP01,
+				Identifier: acme.Identifier{Type: acme.DNS, Value: "*.example.com"},
+				Token:      "token",
+			}))
+			return test{
+				ctx:        ctx,
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "http-01 challenges cannot be used with wildcard identifiers"),
+			}
+		},
+		"fail/not-enabled": func(t *testing.T) test {
+			prov := &provisioner.ACME{
+				Type:       "ACME",
+				Name:       "acme",
+				Challenges: []provisioner.ACMEChallenge{provisioner.DNS_01},
+			}
+			assert.FatalError(t, prov.Init(provisioner.Config{Claims: globalProvisionerClaims}))
+			ctx := acme.NewProvisionerContext(context.Background(), prov)
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			ctx = context.WithValue(ctx, payloadContextKey, payload(t, &DiagnoseRequest{
+				Type:       acme.HTTP01,
+				Identifier: acme.Identifier{Type: acme.DNS, Value: "example.com"},
+				Token:      "token",
+			}))
+			return test{
+				ctx:        ctx,
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "challenge type http-01 is not enabled in the provisioner"),
+			}
+		},
+		"ok": func(t *testing.T) test {
+			ctx := acme.NewProvisionerContext(context.Background(), newProv())
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			ctx = context.WithValue(ctx, payloadContextKey, payload(t, &DiagnoseRequest{
+				Type:       acme.HTTP01,
+				Identifier: acme.Identifier{Type: acme.DNS, Value: "example.com"},
+				Token:      "token",
+			}))
+			return test{
+				ctx:        ctx,
+				statusCode: 200,
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			ctx := acme.NewClientContext(tc.ctx, client)
+			req := httptest.NewRequest("POST", "https://test.ca.smallstep.com/acme/acme/diagnose", http.NoBody)
+			req = req.WithContext(ctx)
+			w := httptest.NewRecorder()
+			Diagnose(w, req)
+			res := w.Result()
+
+			assert.Equals(t, res.StatusCode, tc.statusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 && assert.NotNil(t, tc.err) {
+				var ae acme.Error
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &ae))
+				assert.Equals(t, ae.Type, tc.err.Type)
+				assert.Equals(t, ae.Detail, tc.err.Detail)
+			} else {
+				var d acme.Diagnostic
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &d))
+				assert.Equals(t, acme.StatusValid, d.Status)
+				assert.Equals(t, keyAuth, d.KeyAuthorization)
+				assert.Len(t, 2, d.Steps)
+			}
+		})
+	}
+}
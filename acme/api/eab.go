@@ -72,7 +72,22 @@ func validateExternalAccountBinding(ctx context.Context, nar *NewAccountRequest)
 		return nil, acme.NewError(acme.ErrorUnauthorizedType, "external account binding key with id '%s' was already bound to account '%s' on %s", keyID, externalAccountKey.AccountID, externalAccountKey.BoundAt)
 	}
 
-	payload, err := eabJWS.Verify(externalAccountKey.HmacKey)
+	// The binding can be signed with the stored key or with the HMAC key of
+	// an External Account Binding credential of the provisioner.
+	hmacKeys, err := acmeProv.GetExternalAccountKeys(keyID, externalAccountKey.HmacKey)
+	if err != nil {
+		return nil, acme.WrapErrorISE(err, "error retrieving external account binding credentials")
+	}
+	if len(hmacKeys) == 0 {
+		return nil, acme.NewError(acme.ErrorUnauthorizedType, "external account binding key with id '%s' is not valid at this time", keyID)
+	}
+
+	var payload []byte
+	for _, hmacKey := range hmacKeys {
+		if payload, err = eabJWS.Verify(hmacKey); err == nil {
+			break
+		}
+	}
 	if err != nil {
 		return nil, acme.WrapErrorISE(err, "error verifying externalAccountBinding signature")
 	}
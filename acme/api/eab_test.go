@@ -14,6 +14,7 @@ import (
 
 	"github.com/smallstep/assert"
 	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/authority/provisioner"
 )
 
 func Test_keysAreEqual(t *testing.T) {
@@ -886,6 +887,79 @@ func TestHandler_validateExternalAccountBinding(t *testing.T) {
 	}
 }
 
+func TestHandler_validateExternalAccountBinding_credentials(t *testing.T) {
+	stored, rotated := []byte{1, 3, 3, 7}, []byte{7, 3, 3, 1}
+	now := time.Now()
+	tests := map[string]struct {
+		hmacKey []byte
+		creds   []*provisioner.Credential
+		err     *acme.Error
+	}{
+		"ok/stored": {stored, []*provisioner.Credential{
+			{Type: provisioner.CredentialEABKey, KeyID: "eakID", HmacKey: rotated},
+		}, nil},
+		"ok/rotated": {rotated, []*provisioner.Credential{
+			{Type: provisioner.CredentialEABKey, KeyID: "eakID", HmacKey: rotated},
+		}, nil},
+		"fail/not-started": {rotated, []*provisioner.Credential{
+			{Type: provisioner.CredentialEABKey, KeyID: "eakID", HmacKey: rotated, NotBefore: now.Add(time.Hour)},
+		}, acme.NewErrorISE("error verifying externalAccountBinding signature")},
+		"fail/retired": {stored, []*provisioner.Credential{
+			{Type: provisioner.CredentialEABKey, KeyID: "eakID", HmacKey: stored, NotAfter: now},
+		}, acme.NewError(acme.ErrorUnauthorizedType, "external account binding key with id 'eakID' is not valid at this time")},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			prov := newACMEProv(t)
+			prov.RequireEAB = true
+			assert.FatalError(t, prov.Init(provisioner.Config{
+				Claims: globalProvisionerClaims,
+				GetCredentialsFunc: func(name string) ([]*provisioner.Credential, error) {
+					return tc.creds, nil
+				},
+			}))
+
+			jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+			assert.FatalError(t, err)
+			u := "https://test.ca.smallstep.com/acme/account/new-account"
+			rawEABJWS, err := createRawEABJWS(jwk, tc.hmacKey, "eakID", u)
+			assert.FatalError(t, err)
+			eab := &ExternalAccountBinding{}
+			assert.FatalError(t, json.Unmarshal(rawEABJWS, &eab))
+			so := new(jose.SignerOptions)
+			so.WithHeader("url", u)
+			signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jwk.Key}, so)
+			assert.FatalError(t, err)
+			jws, err := signer.Sign([]byte("{}"))
+			assert.FatalError(t, err)
+			raw, err := jws.CompactSerialize()
+			assert.FatalError(t, err)
+			jws, err = jose.ParseJWS(raw)
+			assert.FatalError(t, err)
+
+			ctx := context.WithValue(context.Background(), jwkContextKey, jwk)
+			ctx = context.WithValue(ctx, jwsContextKey, jws)
+			ctx = acme.NewProvisionerContext(ctx, prov)
+			ctx = acme.NewDatabaseContext(ctx, &acme.MockDB{
+				MockGetExternalAccountKey: func(ctx context.Context, provisionerName, keyID string) (*acme.ExternalAccountKey, error) {
+					return &acme.ExternalAccountKey{ID: "eakID", ProvisionerID: prov.GetID(), HmacKey: stored}, nil
+				},
+			})
+			got, err := validateExternalAccountBinding(ctx, &NewAccountRequest{ExternalAccountBinding: eab})
+			if tc.err != nil {
+				var ae *acme.Error
+				if assert.True(t, errors.As(err, &ae)) {
+					assert.Equals(t, tc.err.Type, ae.Type)
+					assert.HasPrefix(t, ae.Err.Error(), tc.err.Err.Error())
+				}
+				return
+			}
+			assert.FatalError(t, err)
+			assert.Equals(t, "eakID", got.ID)
+		})
+	}
+}
+
 func Test_validateEABJWS(t *testing.T) {
 	acmeProv := newACMEProv(t)
 	escProvName := url.PathEscape(acmeProv.GetName())
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/api/render"
+)
+
+// maxEmailReplySize is the maximum size of the replies of the email-reply-00
+// challenges.
+const maxEmailReplySize = 1 << 20
+
+// EmailReply is the resource used by the service receiving the replies to the
+// challenge emails of the email-reply-00 challenges, defined in RFC 8823, to
+// post them to the CA. The body is the reply in the Internet Message Format,
+// and the request is authenticated with the secret of the provisioner as a
+// bearer token.
+func EmailReply(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	db := acme.MustDatabaseFromContext(ctx)
+
+	prov, err := provisionerFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	opts := prov.GetEmailReplyOptions()
+	if opts == nil || !isEmailReplyEnabled(ctx, prov) {
+		render.Error(w, acme.NewError(acme.ErrorNotImplementedType, "email-reply-00 challenges are not enabled"))
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(opts.Secret)) != 1 {
+		render.Error(w, acme.NewError(acme.ErrorUnauthorizedType, "invalid email-reply credentials"))
+		return
+	}
+
+	if _, err := acme.ValidateEmailReply(ctx, db, http.MaxBytesReader(w, r.Body, maxEmailReplySize)); err != nil {
+		render.Error(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -6,10 +6,13 @@ import (
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi"
+	"golang.org/x/crypto/ssh"
 
 	"github.com/smallstep/certificates/acme"
 	"github.com/smallstep/certificates/api"
@@ -136,13 +139,13 @@ func route(r api.Router, middleware func(next nextHTTP) nextHTTP) {
 		return commonMiddleware(addNonce(addDirLink(verifyContentType(parseJWS(validateJWS(next))))))
 	}
 	extractPayloadByJWK := func(next nextHTTP) nextHTTP {
-		return validatingMiddleware(extractJWK(verifyAndExtractJWSPayload(next)))
+		return validatingMiddleware(extractJWK(verifyClientCertificate(verifyAndExtractJWSPayload(next))))
 	}
 	extractPayloadByKid := func(next nextHTTP) nextHTTP {
-		return validatingMiddleware(lookupJWK(verifyAndExtractJWSPayload(next)))
+		return validatingMiddleware(lookupJWK(verifyClientCertificate(verifyAndExtractJWSPayload(next))))
 	}
 	extractPayloadByKidOrJWK := func(next nextHTTP) nextHTTP {
-		return validatingMiddleware(extractOrLookupJWK(verifyAndExtractJWSPayload(next)))
+		return validatingMiddleware(extractOrLookupJWK(verifyClientCertificate(verifyAndExtractJWSPayload(next))))
 	}
 
 	getPath := acme.GetUnescapedPathSuffix
@@ -161,12 +164,14 @@ func route(r api.Router, middleware func(next nextHTTP) nextHTTP) {
 		extractPayloadByJWK(NewAccount))
 	r.MethodFunc("POST", getPath(acme.AccountLinkType, "{provisionerID}", "{accID}"),
 		extractPayloadByKid(GetOrUpdateAccount))
-	r.MethodFunc("POST", getPath(acme.KeyChangeLinkType, "{provisionerID}", "{accID}"),
-		extractPayloadByKid(NotImplemented))
+	r.MethodFunc("POST", getPath(acme.KeyChangeLinkType, "{provisionerID}"),
+		extractPayloadByKid(KeyChange))
 	r.MethodFunc("POST", getPath(acme.NewOrderLinkType, "{provisionerID}"),
 		extractPayloadByKid(NewOrder))
+	r.MethodFunc("POST", getPath(acme.NewAuthzLinkType, "{provisionerID}"),
+		extractPayloadByKid(NewAuthz))
 	r.MethodFunc("POST", getPath(acme.OrderLinkType, "{provisionerID}", "{ordID}"),
-		extractPayloadByKid(isPostAsGet(GetOrder)))
+		extractPayloadByKid(GetOrUpdateOrder))
 	r.MethodFunc("POST", getPath(acme.OrdersByAccountLinkType, "{provisionerID}", "{accID}"),
 		extractPayloadByKid(isPostAsGet(GetOrdersByAccountID)))
 	r.MethodFunc("POST", getPath(acme.FinalizeLinkType, "{provisionerID}", "{ordID}"),
@@ -177,8 +182,24 @@ func route(r api.Router, middleware func(next nextHTTP) nextHTTP) {
 		extractPayloadByKid(GetChallenge))
 	r.MethodFunc("POST", getPath(acme.CertificateLinkType, "{provisionerID}", "{certID}"),
 		extractPayloadByKid(isPostAsGet(GetCertificate)))
+	r.MethodFunc("POST", getPath(acme.CertificateLinkType, "{provisionerID}", "{certID}")+"/{chain}",
+		extractPayloadByKid(isPostAsGet(GetCertificate)))
+	r.MethodFunc("POST", getPath(acme.StarCertificateLinkType, "{provisionerID}", "{ordID}"),
+		extractPayloadByKid(isPostAsGet(GetStarCertificate)))
 	r.MethodFunc("POST", getPath(acme.RevokeCertLinkType, "{provisionerID}"),
 		extractPayloadByKidOrJWK(RevokeCert))
+	r.MethodFunc("POST", getPath(acme.DiagnoseLinkType, "{provisionerID}"),
+		extractPayloadByKid(Diagnose))
+
+	// Replies of the email-reply-00 challenges, posted by the service
+	// receiving them.
+	r.MethodFunc("POST", getPath(acme.EmailReplyLinkType, "{provisionerID}"),
+		commonMiddleware(EmailReply))
+
+	// Certificates of the auto-renewal orders that allow unauthenticated
+	// GET requests, RFC 8739 section 3.4.
+	r.MethodFunc("GET", getPath(acme.StarCertificateLinkType, "{provisionerID}", "{ordID}"),
+		commonMiddleware(GetStarCertificate))
 }
 
 // GetNonce just sets the right header since a Nonce is added to each response
@@ -192,10 +213,20 @@ func GetNonce(w http.ResponseWriter, r *http.Request) {
 }
 
 type Meta struct {
-	TermsOfService          string   `json:"termsOfService,omitempty"`
-	Website                 string   `json:"website,omitempty"`
-	CaaIdentities           []string `json:"caaIdentities,omitempty"`
-	ExternalAccountRequired bool     `json:"externalAccountRequired,omitempty"`
+	TermsOfService          string           `json:"termsOfService,omitempty"`
+	Website                 string           `json:"website,omitempty"`
+	CaaIdentities           []string         `json:"caaIdentities,omitempty"`
+	ExternalAccountRequired bool             `json:"externalAccountRequired,omitempty"`
+	AutoRenewal             *MetaAutoRenewal `json:"auto-renewal,omitempty"`
+}
+
+// MetaAutoRenewal is the auto-renewal object of the directory metadata, it
+// advertises the support of STAR orders, RFC 8739 section 3.1.3. The
+// durations are in seconds.
+type MetaAutoRenewal struct {
+	MinLifetime         int64 `json:"min-lifetime"`
+	MaxDuration         int64 `json:"max-duration"`
+	AllowCertificateGet bool  `json:"allow-certificate-get,omitempty"`
 }
 
 // Directory represents an ACME directory for configuring clients.
@@ -203,6 +234,7 @@ type Directory struct {
 	NewNonce   string `json:"newNonce"`
 	NewAccount string `json:"newAccount"`
 	NewOrder   string `json:"newOrder"`
+	NewAuthz   string `json:"newAuthz,omitempty"`
 	RevokeCert string `json:"revokeCert"`
 	KeyChange  string `json:"keyChange"`
 	Meta       *Meta  `json:"meta,omitempty"`
@@ -229,10 +261,18 @@ func GetDirectory(w http.ResponseWriter, r *http.Request) {
 
 	linker := acme.MustLinkerFromContext(ctx)
 
+	// The newAuthz endpoint is only advertised if pre-authorization is
+	// enabled.
+	var newAuthz string
+	if acmeProv.GetOrderOptions().IsPreAuthorizationEnabled() {
+		newAuthz = linker.GetLink(ctx, acme.NewAuthzLinkType)
+	}
+
 	render.JSON(w, &Directory{
 		NewNonce:   linker.GetLink(ctx, acme.NewNonceLinkType),
 		NewAccount: linker.GetLink(ctx, acme.NewAccountLinkType),
 		NewOrder:   linker.GetLink(ctx, acme.NewOrderLinkType),
+		NewAuthz:   newAuthz,
 		RevokeCert: linker.GetLink(ctx, acme.RevokeCertLinkType),
 		KeyChange:  linker.GetLink(ctx, acme.KeyChangeLinkType),
 		Meta:       createMetaObject(acmeProv),
@@ -244,12 +284,20 @@ func GetDirectory(w http.ResponseWriter, r *http.Request) {
 // It returns nil if none of the properties are set.
 func createMetaObject(p *provisioner.ACME) *Meta {
 	if shouldAddMetaObject(p) {
-		return &Meta{
+		m := &Meta{
 			TermsOfService:          p.TermsOfService,
 			Website:                 p.Website,
 			CaaIdentities:           p.CaaIdentities,
 			ExternalAccountRequired: p.RequireEAB,
 		}
+		if opts := p.GetAutoRenewalOptions(); opts.IsEnabled() {
+			m.AutoRenewal = &MetaAutoRenewal{
+				MinLifetime:         int64(opts.GetMinLifetime().Seconds()),
+				MaxDuration:         int64(opts.GetMaxDuration().Seconds()),
+				AllowCertificateGet: opts.IsCertificateGetAllowed(),
+			}
+		}
+		return m
 	}
 	return nil
 }
@@ -266,6 +314,8 @@ func shouldAddMetaObject(p *provisioner.ACME) bool {
 		return true
 	case p.RequireEAB:
 		return true
+	case p.GetAutoRenewalOptions().IsEnabled():
+		return true
 	default:
 		return false
 	}
@@ -350,19 +400,45 @@ func GetChallenge(w http.ResponseWriter, r *http.Request) {
 		render.Error(w, err)
 		return
 	}
-	if err = ch.Validate(ctx, db, jwk, payload.value); err != nil {
+	// Challenges are validated in the background if the provisioner has
+	// validation options.
+	var opts *provisioner.ACMEValidationOptions
+	if prov, ok := acme.ProvisionerFromContext(ctx); ok {
+		opts = prov.GetValidationOptions()
+		// Validations are refused while the hostname has too many failures.
+		limit := prov.GetRateLimitOptions().GetFailedValidationsPerHostname()
+		if limit != nil && ch.Status == acme.StatusPending {
+			key := acme.RateLimitKey(acme.FailedValidationRateLimit, prov.GetID(), acc.ID, ch.Value)
+			if err := acme.CheckRateLimit(ctx, db, key, limit); err != nil {
+				render.Error(w, err)
+				return
+			}
+		}
+	}
+	var retryAfter time.Duration
+	if opts != nil {
+		retryAfter, err = ch.ValidateInBackground(ctx, db, jwk, payload.value, opts)
+	} else {
+		err = ch.Validate(ctx, db, jwk, payload.value)
+	}
+	if err != nil {
 		render.Error(w, acme.WrapErrorISE(err, "error validating challenge"))
 		return
 	}
 
 	linker.LinkChallenge(ctx, ch, azID)
 
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
 	w.Header().Add("Link", link(linker.GetLink(ctx, acme.AuthzLinkType, azID), "up"))
 	w.Header().Set("Location", linker.GetLink(ctx, acme.ChallengeLinkType, azID, ch.ID))
 	render.JSON(w, ch)
 }
 
-// GetCertificate ACME api for retrieving a Certificate.
+// GetCertificate ACME api for retrieving a Certificate. Only the account that
+// created the order can download the certificate. If there are alternate
+// chains, they are advertised using Link headers with the alternate relation.
 func GetCertificate(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	db := acme.MustDatabaseFromContext(ctx)
@@ -385,6 +461,90 @@ func GetCertificate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// SSH user certificates of ssh-principal orders are served in the
+	// authorized keys format, they don't have chains.
+	if cert.SSH != nil {
+		api.LogSSHCertificate(w, cert.SSH)
+		w.Header().Set("Content-Type", "application/x-ssh-certificate")
+		w.Write(ssh.MarshalAuthorizedKey(cert.SSH))
+		return
+	}
+
+	// The default chain is served at the certificate URL, and the alternate
+	// chains at the certificate URL followed by their index.
+	chains := append([][]*x509.Certificate{cert.Intermediates}, mustAuthority(ctx).GetAlternateChains(cert.Leaf)...)
+	var index int
+	if s := chi.URLParam(r, "chain"); s != "" {
+		if index, err = strconv.Atoi(s); err != nil || index < 1 || index >= len(chains) {
+			render.Error(w, acme.NewError(acme.ErrorMalformedType,
+				"certificate '%s' does not have the chain '%s'", certID, s))
+			return
+		}
+	}
+	if len(chains) > 1 {
+		certURL := acme.MustLinkerFromContext(ctx).GetLink(ctx, acme.CertificateLinkType, certID)
+		for i := range chains {
+			switch {
+			case i == index:
+			case i == 0:
+				w.Header().Add("Link", link(certURL, "alternate"))
+			default:
+				w.Header().Add("Link", link(certURL+"/"+strconv.Itoa(i), "alternate"))
+			}
+		}
+	}
+
+	var certBytes []byte
+	for _, c := range append([]*x509.Certificate{cert.Leaf}, chains[index]...) {
+		certBytes = append(certBytes, pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: c.Raw,
+		})...)
+	}
+
+	api.LogCertificate(w, cert.Leaf)
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	w.Write(certBytes)
+}
+
+// GetStarCertificate returns the latest certificate of an auto-renewal order,
+// RFC 8739 section 3.3. It's available to the account of the order with
+// POST-as-GET requests, and with unauthenticated GET requests if the order
+// allows them.
+func GetStarCertificate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	db := acme.MustDatabaseFromContext(ctx)
+
+	prov, err := provisionerFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	var o *acme.Order
+	if r.Method == http.MethodGet {
+		o, err = db.GetOrder(ctx, chi.URLParam(r, "ordID"))
+		if err != nil {
+			render.Error(w, acme.WrapErrorISE(err, "error retrieving order"))
+			return
+		}
+		if prov.GetID() != o.ProvisionerID || o.AutoRenewal == nil || !o.AutoRenewal.AllowCertificateGet ||
+			!prov.GetAutoRenewalOptions().IsCertificateGetAllowed() {
+			render.Error(w, acme.NewError(acme.ErrorUnauthorizedType,
+				"order '%s' does not allow unauthenticated requests", o.ID))
+			return
+		}
+	} else if o, err = getAccountOrder(ctx, db, prov, chi.URLParam(r, "ordID")); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	cert, err := o.GetStarCertificate(ctx, db)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
 	var certBytes []byte
 	for _, c := range append([]*x509.Certificate{cert.Leaf}, cert.Intermediates...) {
 		certBytes = append(certBytes, pem.EncodeToMemory(&pem.Block{
@@ -394,6 +554,8 @@ func GetCertificate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	api.LogCertificate(w, cert.Leaf)
+	w.Header().Set("Cert-Not-Before", cert.Leaf.NotBefore.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cert-Not-After", cert.Leaf.NotAfter.UTC().Format(http.TimeFormat))
 	w.Header().Set("Content-Type", "application/pem-certificate-chain")
 	w.Write(certBytes)
 }
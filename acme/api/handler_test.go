@@ -142,6 +142,26 @@ func TestHandler_GetDirectory(t *testing.T) {
 				statusCode: 200,
 			}
 		},
+		"ok/pre-authorization": func(t *testing.T) test {
+			prov := newACMEProv(t)
+			prov.Orders = &provisioner.ACMEOrderOptions{PreAuthorization: true}
+			provName := url.PathEscape(prov.GetName())
+			baseURL := &url.URL{Scheme: "https", Host: "test.ca.smallstep.com"}
+			ctx := acme.NewProvisionerContext(context.Background(), prov)
+			expDir := Directory{
+				NewNonce:   fmt.Sprintf("%s/acme/%s/new-nonce", baseURL.String(), provName),
+				NewAccount: fmt.Sprintf("%s/acme/%s/new-account", baseURL.String(), provName),
+				NewOrder:   fmt.Sprintf("%s/acme/%s/new-order", baseURL.String(), provName),
+				NewAuthz:   fmt.Sprintf("%s/acme/%s/new-authz", baseURL.String(), provName),
+				RevokeCert: fmt.Sprintf("%s/acme/%s/revoke-cert", baseURL.String(), provName),
+				KeyChange:  fmt.Sprintf("%s/acme/%s/key-change", baseURL.String(), provName),
+			}
+			return test{
+				ctx:        ctx,
+				dir:        expDir,
+				statusCode: 200,
+			}
+		},
 		"ok/full-meta": func(t *testing.T) test {
 			prov := newACMEProv(t)
 			prov.TermsOfService = "https://terms.ca.local/"
@@ -410,11 +430,50 @@ func TestHandler_GetCertificate(t *testing.T) {
 	u := fmt.Sprintf("%s/acme/%s/certificate/%s",
 		baseURL.String(), provName, certID)
 
+	altBytes := append(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: leaf.Raw,
+	}), pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: root.Raw,
+	})...)
+	altCA := &mockCA{
+		MockGetAlternateChains: func(crt *x509.Certificate) [][]*x509.Certificate {
+			assert.Equals(t, leaf, crt)
+			return [][]*x509.Certificate{{root}}
+		},
+	}
+	altCtx := func(chain string) context.Context {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("certID", certID)
+		if chain != "" {
+			chiCtx.URLParams.Add("chain", chain)
+		}
+		ctx := acme.NewProvisionerContext(context.Background(), prov)
+		ctx = acme.NewLinkerContext(ctx, acme.NewLinker("test.ca.smallstep.com", "acme"))
+		ctx = context.WithValue(ctx, accContextKey, &acme.Account{ID: "accID"})
+		return context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+	}
+	altDB := &acme.MockDB{
+		MockGetCertificate: func(ctx context.Context, id string) (*acme.Certificate, error) {
+			return &acme.Certificate{
+				AccountID:     "accID",
+				OrderID:       "ordID",
+				Leaf:          leaf,
+				Intermediates: []*x509.Certificate{inter, root},
+				ID:            id,
+			}, nil
+		},
+	}
+
 	type test struct {
 		db         acme.DB
+		ca         acme.CertificateAuthority
 		ctx        context.Context
 		statusCode int
 		err        *acme.Error
+		expected   []byte
+		links      []string
 	}
 	var tests = map[string]func(t *testing.T) test{
 		"fail/no-account": func(t *testing.T) test {
@@ -482,12 +541,46 @@ func TestHandler_GetCertificate(t *testing.T) {
 				},
 				ctx:        ctx,
 				statusCode: 200,
+				expected:   certBytes,
+			}
+		},
+		"ok/with-alternate": func(t *testing.T) test {
+			return test{
+				db:         altDB,
+				ca:         altCA,
+				ctx:        altCtx(""),
+				statusCode: 200,
+				expected:   certBytes,
+				links:      []string{fmt.Sprintf("<%s/1>;rel=\"alternate\"", u)},
+			}
+		},
+		"ok/alternate": func(t *testing.T) test {
+			return test{
+				db:         altDB,
+				ca:         altCA,
+				ctx:        altCtx("1"),
+				statusCode: 200,
+				expected:   altBytes,
+				links:      []string{fmt.Sprintf("<%s>;rel=\"alternate\"", u)},
+			}
+		},
+		"fail/alternate-not-found": func(t *testing.T) test {
+			return test{
+				db:         altDB,
+				ca:         altCA,
+				ctx:        altCtx("2"),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "certificate 'certID' does not have the chain '2'"),
 			}
 		},
 	}
 	for name, run := range tests {
 		tc := run(t)
 		t.Run(name, func(t *testing.T) {
+			if tc.ca == nil {
+				tc.ca = &mockCA{}
+			}
+			mockMustAuthority(t, tc.ca)
 			ctx := acme.NewDatabaseContext(tc.ctx, tc.db)
 			req := httptest.NewRequest("GET", u, http.NoBody)
 			req = req.WithContext(ctx)
@@ -509,9 +602,121 @@ func TestHandler_GetCertificate(t *testing.T) {
 				assert.HasPrefix(t, ae.Detail, tc.err.Detail)
 				assert.Equals(t, ae.Subproblems, tc.err.Subproblems)
 				assert.Equals(t, res.Header["Content-Type"], []string{"application/problem+json"})
+			} else {
+				assert.Equals(t, bytes.TrimSpace(body), bytes.TrimSpace(tc.expected))
+				assert.Equals(t, res.Header["Content-Type"], []string{"application/pem-certificate-chain"})
+				assert.Equals(t, res.Header["Link"], tc.links)
+			}
+		})
+	}
+}
+
+func TestHandler_GetStarCertificate(t *testing.T) {
+	leaf, err := pemutil.ReadCertificate("../../authority/testdata/certs/foo.crt")
+	assert.FatalError(t, err)
+	inter, err := pemutil.ReadCertificate("../../authority/testdata/certs/intermediate_ca.crt")
+	assert.FatalError(t, err)
+	certBytes := append(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: leaf.Raw,
+	}), pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: inter.Raw,
+	})...)
+
+	prov := newACMEProv(t)
+	prov.Orders = &provisioner.ACMEOrderOptions{AutoRenewal: &provisioner.ACMEAutoRenewalOptions{Enabled: true, AllowCertificateGet: true}}
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add("ordID", "ordID")
+	u := fmt.Sprintf("https://test.ca.smallstep.com/acme/%s/star-certificate/ordID", url.PathEscape(prov.GetName()))
+
+	now := clock.Now()
+	newDB := func(allowGet bool, status acme.Status) *acme.MockDB {
+		return &acme.MockDB{
+			MockGetOrder: func(ctx context.Context, id string) (*acme.Order, error) {
+				return &acme.Order{
+					ID:            id,
+					AccountID:     "accID",
+					ProvisionerID: prov.GetID(),
+					Status:        status,
+					CertificateID: "certID",
+					AutoRenewal:   &acme.AutoRenewal{StartDate: now, EndDate: now.Add(24 * time.Hour), Lifetime: 3600, AllowCertificateGet: allowGet},
+				}, nil
+			},
+			MockGetCertificate: func(ctx context.Context, id string) (*acme.Certificate, error) {
+				assert.Equals(t, id, "certID")
+				return &acme.Certificate{ID: id, Leaf: leaf, Intermediates: []*x509.Certificate{inter}}, nil
+			},
+		}
+	}
+
+	type test struct {
+		db         acme.DB
+		method     string
+		statusCode int
+		err        *acme.Error
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/get-not-allowed": func(t *testing.T) test {
+			return test{
+				db:         newDB(false, acme.StatusValid),
+				method:     "GET",
+				statusCode: 401,
+				err:        acme.NewError(acme.ErrorUnauthorizedType, "order 'ordID' does not allow unauthenticated requests"),
+			}
+		},
+		"fail/canceled": func(t *testing.T) test {
+			return test{
+				db:         newDB(true, acme.StatusCanceled),
+				method:     "GET",
+				statusCode: 403,
+				err:        acme.NewError(acme.ErrorAutoRenewalCanceledType, "auto-renewal of order ordID has been canceled"),
+			}
+		},
+		"fail/post-no-account": func(t *testing.T) test {
+			return test{
+				db:         newDB(true, acme.StatusValid),
+				method:     "POST",
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorAccountDoesNotExistType, "account does not exist"),
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				db:         newDB(true, acme.StatusValid),
+				method:     "GET",
+				statusCode: 200,
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			ctx := acme.NewProvisionerContext(context.Background(), prov)
+			ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+			ctx = acme.NewDatabaseContext(ctx, tc.db)
+			req := httptest.NewRequest(tc.method, u, http.NoBody)
+			req = req.WithContext(ctx)
+			w := httptest.NewRecorder()
+			GetStarCertificate(w, req)
+			res := w.Result()
+
+			assert.Equals(t, res.StatusCode, tc.statusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 && assert.NotNil(t, tc.err) {
+				var ae acme.Error
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &ae))
+				assert.Equals(t, ae.Type, tc.err.Type)
+				assert.Equals(t, ae.Detail, tc.err.Detail)
+				assert.Equals(t, res.Header["Content-Type"], []string{"application/problem+json"})
 			} else {
 				assert.Equals(t, bytes.TrimSpace(body), bytes.TrimSpace(certBytes))
 				assert.Equals(t, res.Header["Content-Type"], []string{"application/pem-certificate-chain"})
+				assert.Equals(t, res.Header["Cert-Not-After"], []string{leaf.NotAfter.UTC().Format(http.TimeFormat)})
 			}
 		})
 	}
@@ -732,6 +937,10 @@ func TestHandler_GetChallenge(t *testing.T) {
 					AccountID:       "accID",
 					URL:             u,
 					Error:           acme.NewError(acme.ErrorConnectionType, "force"),
+					ValidationRecord: []*acme.ValidationRecord{{
+						URL:  "http:///.well-known/acme-challenge/",
+						Port: "80",
+					}},
 				},
 				vc: &mockClient{
 					get: func(string) (*http.Response, error) {
@@ -779,6 +988,70 @@ func TestHandler_GetChallenge(t *testing.T) {
 	}
 }
 
+func TestHandler_GetChallenge_background(t *testing.T) {
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add("chID", "chID")
+	chiCtx.URLParams.Add("authzID", "authzID")
+	prov := &provisioner.ACME{
+		Type: "ACME",
+		Name: "acme",
+		Validation: &provisioner.ACMEValidationOptions{
+			Attempts: 1,
+			Interval: &provisioner.Duration{Duration: 1500 * time.Millisecond},
+		},
+	}
+	assert.FatalError(t, prov.Init(provisioner.Config{Claims: globalProvisionerClaims}))
+
+	_jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	_pub := _jwk.Public()
+	ctx := acme.NewProvisionerContext(context.Background(), prov)
+	ctx = context.WithValue(ctx, accContextKey, &acme.Account{ID: "accID"})
+	ctx = context.WithValue(ctx, payloadContextKey, &payloadInfo{isEmptyJSON: true})
+	ctx = context.WithValue(ctx, jwkContextKey, &_pub)
+	ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+
+	updates := make(chan acme.Status, 10)
+	db := &acme.MockDB{
+		MockGetChallenge: func(ctx context.Context, chID, azID string) (*acme.Challenge, error) {
+			return &acme.Challenge{
+				ID:        "chID",
+				Status:    acme.StatusPending,
+				Type:      acme.HTTP01,
+				AccountID: "accID",
+			}, nil
+		},
+		MockUpdateChallenge: func(ctx context.Context, ch *acme.Challenge) error {
+			updates <- ch.Status
+			return nil
+		},
+	}
+	vc := &mockClient{
+		get: func(string) (*http.Response, error) {
+			return nil, errors.New("force")
+		},
+	}
+
+	u := "https://test.ca.smallstep.com/acme/acme/challenge/authzID/chID"
+	req := httptest.NewRequest("GET", u, http.NoBody)
+	req = req.WithContext(acme.NewContext(ctx, db, vc, acme.NewLinker("test.ca.smallstep.com", "acme"), nil))
+	w := httptest.NewRecorder()
+	GetChallenge(w, req)
+	res := w.Result()
+
+	assert.Equals(t, 200, res.StatusCode)
+	assert.Equals(t, []string{"2"}, res.Header["Retry-After"])
+	var ch acme.Challenge
+	assert.FatalError(t, json.NewDecoder(res.Body).Decode(&ch))
+	res.Body.Close()
+	assert.Equals(t, acme.StatusProcessing, ch.Status)
+
+	// The challenge is invalid after the only attempt.
+	assert.Equals(t, acme.StatusProcessing, <-updates)
+	assert.Equals(t, acme.StatusProcessing, <-updates)
+	assert.Equals(t, acme.StatusInvalid, <-updates)
+}
+
 func Test_createMetaObject(t *testing.T) {
 	tests := []struct {
 		name string
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"crypto/rsa"
+	"crypto/x509"
 	"errors"
 	"io"
 	"net/http"
@@ -156,27 +157,8 @@ func validateJWS(next nextHTTP) nextHTTP {
 			return
 		}
 		hdr := sig.Protected
-		switch hdr.Algorithm {
-		case jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS384, jose.PS512:
-			if hdr.JSONWebKey != nil {
-				switch k := hdr.JSONWebKey.Key.(type) {
-				case *rsa.PublicKey:
-					if k.Size() < keyutil.MinRSAKeyBytes {
-						render.Error(w, acme.NewError(acme.ErrorMalformedType,
-							"rsa keys must be at least %d bits (%d bytes) in size",
-							8*keyutil.MinRSAKeyBytes, keyutil.MinRSAKeyBytes))
-						return
-					}
-				default:
-					render.Error(w, acme.NewError(acme.ErrorMalformedType,
-						"jws key type and algorithm do not match"))
-					return
-				}
-			}
-		case jose.ES256, jose.ES384, jose.ES512, jose.EdDSA:
-			// we good
-		default:
-			render.Error(w, acme.NewError(acme.ErrorBadSignatureAlgorithmType, "unsuitable algorithm: %s", hdr.Algorithm))
+		if err := validateJWSAlgorithm(hdr); err != nil {
+			render.Error(w, err)
 			return
 		}
 
@@ -211,6 +193,32 @@ func validateJWS(next nextHTTP) nextHTTP {
 	}
 }
 
+// validateJWSAlgorithm checks that the algorithm of the protected header is
+// suitable for ACME and that it matches the type of the JWK, if present.
+func validateJWSAlgorithm(hdr jose.Header) error {
+	switch hdr.Algorithm {
+	case jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS384, jose.PS512:
+		if hdr.JSONWebKey != nil {
+			switch k := hdr.JSONWebKey.Key.(type) {
+			case *rsa.PublicKey:
+				if k.Size() < keyutil.MinRSAKeyBytes {
+					return acme.NewError(acme.ErrorMalformedType,
+						"rsa keys must be at least %d bits (%d bytes) in size",
+						8*keyutil.MinRSAKeyBytes, keyutil.MinRSAKeyBytes)
+				}
+			default:
+				return acme.NewError(acme.ErrorMalformedType,
+					"jws key type and algorithm do not match")
+			}
+		}
+	case jose.ES256, jose.ES384, jose.ES512, jose.EdDSA:
+		// we good
+	default:
+		return acme.NewError(acme.ErrorBadSignatureAlgorithmType, "unsuitable algorithm: %s", hdr.Algorithm)
+	}
+	return nil
+}
+
 // extractJWK is a middleware that extracts the JWK from the JWS and saves it
 // in the context. Make sure to parse and validate the JWS before running this
 // middleware.
@@ -403,6 +411,50 @@ func canExtractJWKFrom(jws *jose.JSONWebSignature) bool {
 	return jws.Signatures[0].Protected.JSONWebKey != nil
 }
 
+// verifyClientCertificate is a middleware that verifies the mTLS client
+// certificate of the request if the provisioner requires one, and saves it in
+// the context. If the request is for an account, the certificate must have the
+// same subject as the certificate the account was created with. Make sure to
+// extract or look up the JWK before running this middleware.
+func verifyClientCertificate(next nextHTTP) nextHTTP {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		prov, err := provisionerFromContext(ctx)
+		if err != nil {
+			render.Error(w, err)
+			return
+		}
+		if !prov.RequireClientCertificate() {
+			next(w, r)
+			return
+		}
+
+		var chain []*x509.Certificate
+		if r.TLS != nil {
+			chain = r.TLS.PeerCertificates
+		}
+		if err := prov.VerifyClientCertificate(chain); err != nil {
+			render.Error(w, acme.NewDetailedError(acme.ErrorUnauthorizedType, "%s", err))
+			return
+		}
+		crt := chain[0]
+
+		if acc, err := accountFromContext(ctx); err == nil {
+			switch {
+			case acc.ClientCertificate == nil:
+				render.Error(w, acme.NewDetailedError(acme.ErrorUnauthorizedType, "account is not bound to a client certificate"))
+				return
+			case acc.ClientCertificate.Subject != crt.Subject.String():
+				render.Error(w, acme.NewDetailedError(acme.ErrorUnauthorizedType, "client certificate does not match the account"))
+				return
+			}
+		}
+
+		ctx = context.WithValue(ctx, clientCertificateContextKey, crt)
+		next(w, r.WithContext(ctx))
+	}
+}
+
 // verifyAndExtractJWSPayload extracts the JWK from the JWS and saves it in the context.
 // Make sure to parse and validate the JWS before running this middleware.
 func verifyAndExtractJWSPayload(next nextHTTP) nextHTTP {
@@ -465,6 +517,8 @@ const (
 	jwkContextKey = ContextKey("jwk")
 	// payloadContextKey payload key
 	payloadContextKey = ContextKey("payload")
+	// clientCertificateContextKey client certificate key
+	clientCertificateContextKey = ContextKey("clientCertificate")
 )
 
 // accountFromContext searches the context for an ACME account. Returns the
@@ -520,6 +574,13 @@ func acmeProvisionerFromContext(ctx context.Context) (*provisioner.ACME, error)
 	return ap, nil
 }
 
+// clientCertificateFromContext returns the verified client certificate of the
+// request, if the provisioner requires one.
+func clientCertificateFromContext(ctx context.Context) (*x509.Certificate, bool) {
+	crt, ok := ctx.Value(clientCertificateContextKey).(*x509.Certificate)
+	return crt, ok && crt != nil
+}
+
 // payloadFromContext searches the context for a payload. Returns the payload
 // or an error.
 func payloadFromContext(ctx context.Context) (*payloadInfo, error) {
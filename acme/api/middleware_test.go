@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"context"
 	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -1695,3 +1698,129 @@ func TestHandler_checkPrerequisites(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_verifyClientCertificate(t *testing.T) {
+	u := "https://test.ca.smallstep.com/acme/acme/new-order"
+	crt := &x509.Certificate{Subject: pkix.Name{CommonName: "client.internal"}}
+	bound := &acme.AccountClientCertificate{Subject: "CN=client.internal"}
+	requireProv := func(err error) *acme.MockProvisioner {
+		return &acme.MockProvisioner{
+			MrequireClientCert: func() bool { return true },
+			MverifyClientCert: func(chain []*x509.Certificate) error {
+				if err == nil && len(chain) == 0 {
+					return errors.New("client certificate is required")
+				}
+				return err
+			},
+		}
+	}
+	type test struct {
+		ctx        context.Context
+		tls        *tls.ConnectionState
+		err        *acme.Error
+		statusCode int
+		wantCert   bool
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/no-provisioner": func(t *testing.T) test {
+			return test{
+				ctx:        context.Background(),
+				statusCode: 500,
+				err:        acme.NewErrorISE("provisioner expected in request context"),
+			}
+		},
+		"fail/no-tls": func(t *testing.T) test {
+			return test{
+				ctx:        acme.NewProvisionerContext(context.Background(), requireProv(nil)),
+				statusCode: 401,
+				err:        acme.NewDetailedError(acme.ErrorUnauthorizedType, "client certificate is required"),
+			}
+		},
+		"fail/verify": func(t *testing.T) test {
+			return test{
+				ctx:        acme.NewProvisionerContext(context.Background(), requireProv(errors.New("force"))),
+				tls:        &tls.ConnectionState{PeerCertificates: []*x509.Certificate{crt}},
+				statusCode: 401,
+				err:        acme.NewDetailedError(acme.ErrorUnauthorizedType, "force"),
+			}
+		},
+		"fail/account-not-bound": func(t *testing.T) test {
+			ctx := acme.NewProvisionerContext(context.Background(), requireProv(nil))
+			return test{
+				ctx:        context.WithValue(ctx, accContextKey, &acme.Account{ID: "accID"}),
+				tls:        &tls.ConnectionState{PeerCertificates: []*x509.Certificate{crt}},
+				statusCode: 401,
+				err:        acme.NewDetailedError(acme.ErrorUnauthorizedType, "account is not bound to a client certificate"),
+			}
+		},
+		"fail/account-mismatch": func(t *testing.T) test {
+			ctx := acme.NewProvisionerContext(context.Background(), requireProv(nil))
+			acc := &acme.Account{ID: "accID", ClientCertificate: &acme.AccountClientCertificate{Subject: "CN=other.internal"}}
+			return test{
+				ctx:        context.WithValue(ctx, accContextKey, acc),
+				tls:        &tls.ConnectionState{PeerCertificates: []*x509.Certificate{crt}},
+				statusCode: 401,
+				err:        acme.NewDetailedError(acme.ErrorUnauthorizedType, "client certificate does not match the account"),
+			}
+		},
+		"ok/not-required": func(t *testing.T) test {
+			return test{
+				ctx:        acme.NewProvisionerContext(context.Background(), &acme.MockProvisioner{}),
+				statusCode: 200,
+			}
+		},
+		"ok/new-account": func(t *testing.T) test {
+			return test{
+				ctx:        acme.NewProvisionerContext(context.Background(), requireProv(nil)),
+				tls:        &tls.ConnectionState{PeerCertificates: []*x509.Certificate{crt}},
+				statusCode: 200,
+				wantCert:   true,
+			}
+		},
+		"ok/account": func(t *testing.T) test {
+			ctx := acme.NewProvisionerContext(context.Background(), requireProv(nil))
+			acc := &acme.Account{ID: "accID", ClientCertificate: bound}
+			return test{
+				ctx:        context.WithValue(ctx, accContextKey, acc),
+				tls:        &tls.ConnectionState{PeerCertificates: []*x509.Certificate{crt}},
+				statusCode: 200,
+				wantCert:   true,
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", u, http.NoBody)
+			req = req.WithContext(tc.ctx)
+			req.TLS = tc.tls
+			w := httptest.NewRecorder()
+			next := func(w http.ResponseWriter, r *http.Request) {
+				got, ok := clientCertificateFromContext(r.Context())
+				assert.Equals(t, ok, tc.wantCert)
+				if tc.wantCert {
+					assert.Equals(t, got, crt)
+				}
+				w.Write(testBody)
+			}
+			verifyClientCertificate(next)(w, req)
+			res := w.Result()
+
+			assert.Equals(t, res.StatusCode, tc.statusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 && assert.NotNil(t, tc.err) {
+				var ae acme.Error
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &ae))
+				assert.Equals(t, ae.Type, tc.err.Type)
+				assert.Equals(t, ae.Detail, tc.err.Detail)
+				assert.Equals(t, res.Header["Content-Type"], []string{"application/problem+json"})
+			} else {
+				assert.Equals(t, bytes.TrimSpace(body), testBody)
+			}
+		})
+	}
+}
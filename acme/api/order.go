@@ -5,8 +5,10 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"math"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,6 +21,7 @@ import (
 	"github.com/smallstep/certificates/api/render"
 	"github.com/smallstep/certificates/authority/policy"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/smime"
 )
 
 // NewOrderRequest represents the body for a NewOrder request.
@@ -26,6 +29,7 @@ type NewOrderRequest struct {
 	Identifiers []acme.Identifier `json:"identifiers"`
 	NotBefore   time.Time         `json:"notBefore,omitempty"`
 	NotAfter    time.Time         `json:"notAfter,omitempty"`
+	AutoRenewal *acme.AutoRenewal `json:"auto-renewal,omitempty"`
 }
 
 // Validate validates a new-order request body.
@@ -33,6 +37,7 @@ func (n *NewOrderRequest) Validate() error {
 	if len(n.Identifiers) == 0 {
 		return acme.NewError(acme.ErrorMalformedType, "identifiers list cannot be empty")
 	}
+	var emails, principals, permanentIdentifiers int
 	for _, id := range n.Identifiers {
 		switch id.Type {
 		case acme.IP:
@@ -48,6 +53,17 @@ func (n *NewOrderRequest) Validate() error {
 			if id.Value == "" {
 				return acme.NewError(acme.ErrorMalformedType, "permanent identifier cannot be empty")
 			}
+			permanentIdentifiers++
+		case acme.Email:
+			if err := smime.ValidateEmail(id.Value); err != nil {
+				return acme.NewError(acme.ErrorMalformedType, "invalid email address: %s", id.Value)
+			}
+			emails++
+		case acme.SSHPrincipal:
+			if id.Value == "" || strings.ContainsAny(id.Value, ", \t\r\n") {
+				return acme.NewError(acme.ErrorMalformedType, "invalid ssh principal: %s", id.Value)
+			}
+			principals++
 		default:
 			return acme.NewError(acme.ErrorMalformedType, "identifier type unsupported: %s", id.Type)
 		}
@@ -55,9 +71,96 @@ func (n *NewOrderRequest) Validate() error {
 		// TODO(hs): add some validations for DNS domains?
 		// TODO(hs): combine the errors from this with allow/deny policy, like example error in https://datatracker.ietf.org/doc/html/rfc8555#section-6.7.1
 	}
+	// S/MIME certificates only contain email addresses.
+	if emails > 0 && emails != len(n.Identifiers) {
+		return acme.NewError(acme.ErrorMalformedType, "email identifiers cannot be combined with other identifier types")
+	}
+	// SSH user certificates only contain principals, a permanent identifier
+	// can bind the key of the certificate to an attested device.
+	if principals > 0 && principals+permanentIdentifiers != len(n.Identifiers) {
+		return acme.NewError(acme.ErrorMalformedType, "ssh-principal identifiers can only be combined with permanent-identifier identifiers")
+	}
+	if n.AutoRenewal != nil {
+		return n.validateAutoRenewal()
+	}
 	return nil
 }
 
+// validateAutoRenewal validates the auto-renewal object of a STAR order, RFC
+// 8739 section 3.1.1. The validity of the certificates is defined by the
+// auto-renewal object.
+func (n *NewOrderRequest) validateAutoRenewal() error {
+	a := n.AutoRenewal
+	switch {
+	case !n.NotBefore.IsZero() || !n.NotAfter.IsZero():
+		return acme.NewError(acme.ErrorMalformedType, "notBefore and notAfter cannot be combined with auto-renewal")
+	case n.isSSH():
+		return acme.NewError(acme.ErrorMalformedType, "ssh-principal identifiers cannot be combined with auto-renewal")
+	case a.EndDate.IsZero():
+		return acme.NewError(acme.ErrorMalformedType, "auto-renewal end-date is required")
+	case a.Lifetime <= 0:
+		return acme.NewError(acme.ErrorMalformedType, "auto-renewal lifetime must be a positive number of seconds")
+	case a.LifetimeAdjust < 0:
+		return acme.NewError(acme.ErrorMalformedType, "auto-renewal lifetime-adjust cannot be negative")
+	case !a.StartDate.IsZero() && !a.EndDate.After(a.StartDate):
+		return acme.NewError(acme.ErrorMalformedType, "auto-renewal end-date must be after start-date")
+	default:
+		return nil
+	}
+}
+
+// authorizeAutoRenewal checks the auto-renewal object of a STAR order with
+// the options of the provisioner. The start date defaults to the current
+// time.
+func authorizeAutoRenewal(opts *provisioner.ACMEAutoRenewalOptions, a *acme.AutoRenewal, now time.Time) error {
+	if !opts.IsEnabled() {
+		return acme.NewError(acme.ErrorMalformedType, "auto-renewal orders are not enabled")
+	}
+	if a.StartDate.IsZero() {
+		a.StartDate = now
+	}
+	switch {
+	case !a.EndDate.After(now):
+		return acme.NewError(acme.ErrorMalformedType, "auto-renewal end-date must be in the future")
+	case !a.EndDate.After(a.StartDate):
+		return acme.NewError(acme.ErrorMalformedType, "auto-renewal end-date must be after start-date")
+	case a.GetLifetime() < opts.GetMinLifetime():
+		return acme.NewError(acme.ErrorMalformedType, "auto-renewal lifetime cannot be less than %d seconds",
+			int64(opts.GetMinLifetime().Seconds()))
+	case a.EndDate.Sub(a.StartDate) > opts.GetMaxDuration():
+		return acme.NewError(acme.ErrorMalformedType, "auto-renewal duration cannot be greater than %d seconds",
+			int64(opts.GetMaxDuration().Seconds()))
+	case a.AllowCertificateGet && !opts.IsCertificateGetAllowed():
+		return acme.NewError(acme.ErrorMalformedType, "auto-renewal allow-certificate-get is not allowed")
+	default:
+		return nil
+	}
+}
+
+// isSSH returns true if the order requests an SSH user certificate.
+func (n *NewOrderRequest) isSSH() bool {
+	for _, id := range n.Identifiers {
+		if id.Type == acme.SSHPrincipal {
+			return true
+		}
+	}
+	return false
+}
+
+// orderRetryAfter is the number of seconds a client should wait before fetching
+// an order that is being processed.
+const orderRetryAfter = "1"
+
+// processingRetryAfter returns the number of seconds a client should wait
+// before fetching a processing order. Orders waiting for an approval are
+// polled less often.
+func processingRetryAfter(prov acme.Provisioner) string {
+	if approval := prov.GetApprovalOptions(); approval.IsRequired() {
+		return strconv.Itoa(int(math.Ceil(approval.GetRetryAfter().Seconds())))
+	}
+	return orderRetryAfter
+}
+
 // FinalizeRequest captures the body for a Finalize order request.
 type FinalizeRequest struct {
 	CSR string `json:"csr"`
@@ -87,7 +190,7 @@ func (f *FinalizeRequest) Validate() error {
 	return nil
 }
 
-var defaultOrderExpiry = time.Hour * 24
+var defaultOrderExpiry = provisioner.DefaultACMEOrderLifetime
 var defaultOrderBackdate = time.Minute
 
 // NewOrder ACME api for creating a new order.
@@ -134,57 +237,75 @@ func NewOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var eak *acme.ExternalAccountKey
-	if acmeProv.RequireEAB {
-		if eak, err = db.GetExternalAccountKeyByAccountID(ctx, prov.GetID(), acc.ID); err != nil {
-			render.Error(w, acme.WrapErrorISE(err, "error retrieving external account binding key"))
-			return
-		}
+	if max := acmeProv.GetMaxOrderIdentifiers(); max > 0 && len(nor.Identifiers) > max {
+		render.Error(w, acme.NewError(acme.ErrorRejectedIdentifierType,
+			"order has %d identifiers, the maximum allowed is %d", len(nor.Identifiers), max))
+		return
 	}
 
-	acmePolicy, err := newACMEPolicyEngine(eak)
+	acmePolicy, err := accountPolicyEngine(ctx, acmeProv, prov, acc)
 	if err != nil {
-		render.Error(w, acme.WrapErrorISE(err, "error creating ACME policy engine"))
+		render.Error(w, err)
 		return
 	}
 
 	for _, identifier := range nor.Identifiers {
-		// evaluate the ACME account level policy
-		if err = isIdentifierAllowed(acmePolicy, identifier); err != nil {
-			render.Error(w, acme.WrapError(acme.ErrorRejectedIdentifierType, err, "not authorized"))
-			return
-		}
-		// evaluate the provisioner level policy
-		orderIdentifier := provisioner.ACMEIdentifier{Type: provisioner.ACMEIdentifierType(identifier.Type), Value: identifier.Value}
-		if err = prov.AuthorizeOrderIdentifier(ctx, orderIdentifier); err != nil {
-			render.Error(w, acme.WrapError(acme.ErrorRejectedIdentifierType, err, "not authorized"))
+		if err = authorizeIdentifier(ctx, ca, prov, acmePolicy, identifier); err != nil {
+			render.Error(w, err)
 			return
 		}
-		// evaluate the authority level policy
-		if err = ca.AreSANsAllowed(ctx, []string{identifier.Value}); err != nil {
-			render.Error(w, acme.WrapError(acme.ErrorRejectedIdentifierType, err, "not authorized"))
+	}
+
+	limit := acmeProv.GetRateLimitOptions().GetNewOrdersPerAccount()
+	if err := acme.ConsumeRateLimit(ctx, db, acme.RateLimitKey(acme.NewOrderRateLimit, prov.GetID(), acc.ID), limit); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	now := clock.Now()
+	if nor.AutoRenewal != nil {
+		if err := authorizeAutoRenewal(acmeProv.GetAutoRenewalOptions(), nor.AutoRenewal, now); err != nil {
+			render.Error(w, err)
 			return
 		}
 	}
 
-	now := clock.Now()
+	orderOpts := acmeProv.GetOrderOptions()
 	// New order.
 	o := &acme.Order{
 		AccountID:        acc.ID,
 		ProvisionerID:    prov.GetID(),
 		Status:           acme.StatusPending,
 		Identifiers:      nor.Identifiers,
-		ExpiresAt:        now.Add(defaultOrderExpiry),
+		ExpiresAt:        now.Add(orderOpts.GetOrderLifetime()),
 		AuthorizationIDs: make([]string, len(nor.Identifiers)),
 		NotBefore:        nor.NotBefore,
 		NotAfter:         nor.NotAfter,
+		AutoRenewal:      nor.AutoRenewal,
+	}
+
+	var reusable []*acme.Authorization
+	if orderOpts.GetAuthorizationReuse() > 0 {
+		if reusable, err = db.GetAuthorizationsByAccountID(ctx, acc.ID); err != nil {
+			render.Error(w, acme.WrapErrorISE(err, "error retrieving authorizations"))
+			return
+		}
 	}
 
 	for i, identifier := range o.Identifiers {
-		az := &acme.Authorization{
+		az, err := findReusableAuthorization(ctx, db, reusable, identifier, orderOpts.GetAuthorizationReuse())
+		if err != nil {
+			render.Error(w, err)
+			return
+		}
+		if az != nil {
+			o.AuthorizationIDs[i] = az.ID
+			continue
+		}
+		az = &acme.Authorization{
 			AccountID:  acc.ID,
 			Identifier: identifier,
-			ExpiresAt:  o.ExpiresAt,
+			ExpiresAt:  now.Add(orderOpts.GetAuthorizationLifetime()),
 			Status:     acme.StatusPending,
 		}
 		if err := newAuthorization(ctx, az); err != nil {
@@ -194,22 +315,34 @@ func NewOrder(w http.ResponseWriter, r *http.Request) {
 		o.AuthorizationIDs[i] = az.ID
 	}
 
-	if o.NotBefore.IsZero() {
-		o.NotBefore = now
-	}
-	if o.NotAfter.IsZero() {
-		o.NotAfter = o.NotBefore.Add(prov.DefaultTLSCertDuration())
-	}
-	// If request NotBefore was empty then backdate the order.NotBefore (now)
-	// to avoid timing issues.
-	if nor.NotBefore.IsZero() {
-		o.NotBefore = o.NotBefore.Add(-defaultOrderBackdate)
+	switch {
+	case nor.AutoRenewal != nil:
+		// The validity of the first certificate, the certificates are
+		// valid from the time they are signed.
+		o.NotBefore, o.NotAfter = nor.AutoRenewal.Window(now)
+	default:
+		if o.NotBefore.IsZero() {
+			o.NotBefore = now
+		}
+		if o.NotAfter.IsZero() {
+			if nor.isSSH() {
+				o.NotAfter = o.NotBefore.Add(acmeProv.DefaultUserSSHCertDuration())
+			} else {
+				o.NotAfter = o.NotBefore.Add(prov.DefaultTLSCertDuration())
+			}
+		}
+		// If request NotBefore was empty then backdate the order.NotBefore (now)
+		// to avoid timing issues.
+		if nor.NotBefore.IsZero() {
+			o.NotBefore = o.NotBefore.Add(-defaultOrderBackdate)
+		}
 	}
 
 	if err := db.CreateOrder(ctx, o); err != nil {
 		render.Error(w, acme.WrapErrorISE(err, "error creating order"))
 		return
 	}
+	acme.MeterFromContext(ctx).OrderChanged(ctx, o, nil)
 
 	linker.LinkOrder(ctx, o)
 
@@ -217,6 +350,63 @@ func NewOrder(w http.ResponseWriter, r *http.Request) {
 	render.JSONStatus(w, o, http.StatusCreated)
 }
 
+// accountPolicyEngine returns the policy of the external account binding key
+// of the account, it returns nil if the provisioner does not require EAB.
+func accountPolicyEngine(ctx context.Context, acmeProv *provisioner.ACME, prov acme.Provisioner, acc *acme.Account) (policy.X509Policy, error) {
+	var eak *acme.ExternalAccountKey
+	if acmeProv.RequireEAB {
+		var err error
+		db := acme.MustDatabaseFromContext(ctx)
+		if eak, err = db.GetExternalAccountKeyByAccountID(ctx, prov.GetID(), acc.ID); err != nil {
+			return nil, acme.WrapErrorISE(err, "error retrieving external account binding key")
+		}
+	}
+
+	acmePolicy, err := newACMEPolicyEngine(eak)
+	if err != nil {
+		return nil, acme.WrapErrorISE(err, "error creating ACME policy engine")
+	}
+	return acmePolicy, nil
+}
+
+// authorizeIdentifier evaluates the ACME account, provisioner and authority
+// policies for the given identifier.
+func authorizeIdentifier(ctx context.Context, ca acme.CertificateAuthority, prov acme.Provisioner, acmePolicy policy.X509Policy, identifier acme.Identifier) error {
+	// email identifiers can only be validated with email-reply-00
+	if identifier.Type == acme.Email && !isEmailReplyEnabled(ctx, prov) {
+		return acme.NewError(acme.ErrorUnsupportedIdentifierType,
+			"email identifiers require the email-reply-00 challenge")
+	}
+	// ssh-principal identifiers can only be validated with oidc-01, the X.509
+	// policies do not apply to them, the SSH policies are evaluated by the
+	// provisioner and when the certificate is signed
+	if identifier.Type == acme.SSHPrincipal {
+		if prov.GetOIDCOptions() == nil || !prov.IsChallengeEnabled(ctx, provisioner.OIDC_01) {
+			return acme.NewError(acme.ErrorUnsupportedIdentifierType,
+				"ssh-principal identifiers require the oidc-01 challenge")
+		}
+		orderIdentifier := provisioner.ACMEIdentifier{Type: provisioner.SSHPrincipal, Value: identifier.Value}
+		if err := prov.AuthorizeOrderIdentifier(ctx, orderIdentifier); err != nil {
+			return acme.WrapError(acme.ErrorRejectedIdentifierType, err, "not authorized")
+		}
+		return nil
+	}
+	// evaluate the ACME account level policy
+	if err := isIdentifierAllowed(acmePolicy, identifier); err != nil {
+		return acme.WrapError(acme.ErrorRejectedIdentifierType, err, "not authorized")
+	}
+	// evaluate the provisioner level policy
+	orderIdentifier := provisioner.ACMEIdentifier{Type: provisioner.ACMEIdentifierType(identifier.Type), Value: identifier.Value}
+	if err := prov.AuthorizeOrderIdentifier(ctx, orderIdentifier); err != nil {
+		return acme.WrapError(acme.ErrorRejectedIdentifierType, err, "not authorized")
+	}
+	// evaluate the authority level policy
+	if err := ca.AreSANsAllowed(ctx, []string{identifier.Value}); err != nil {
+		return acme.WrapError(acme.ErrorRejectedIdentifierType, err, "not authorized")
+	}
+	return nil
+}
+
 func isIdentifierAllowed(acmePolicy policy.X509Policy, identifier acme.Identifier) error {
 	if acmePolicy == nil {
 		return nil
@@ -248,8 +438,11 @@ func newAuthorization(ctx context.Context, az *acme.Authorization) error {
 
 	chTypes := challengeTypes(az)
 
-	var err error
-	az.Token, err = randutil.Alphanumeric(32)
+	acmeProv, err := acmeProvisionerFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	az.Token, err = randutil.Alphanumeric(acmeProv.GetOrderOptions().GetTokenLength())
 	if err != nil {
 		return acme.WrapErrorISE(err, "error generating random alphanumeric ID")
 	}
@@ -269,9 +462,25 @@ func newAuthorization(ctx context.Context, az *acme.Authorization) error {
 			Token:     az.Token,
 			Status:    acme.StatusPending,
 		}
+		// The token of the email-reply-00 challenges has two parts, the
+		// first one is only sent in the challenge email.
+		if typ == acme.EMAILREPLY00 {
+			if !isEmailReplyEnabled(ctx, prov) {
+				continue
+			}
+			ch.From = prov.GetEmailReplyOptions().From
+			if ch.TokenPart1, err = randutil.Alphanumeric(acmeProv.GetOrderOptions().GetTokenLength()); err != nil {
+				return acme.WrapErrorISE(err, "error generating random alphanumeric ID")
+			}
+		}
 		if err := db.CreateChallenge(ctx, ch); err != nil {
 			return acme.WrapErrorISE(err, "error creating challenge")
 		}
+		if typ == acme.EMAILREPLY00 {
+			if err := acme.SendEmailChallenge(ctx, ch); err != nil {
+				return err
+			}
+		}
 		az.Challenges = append(az.Challenges, ch)
 	}
 	if err = db.CreateAuthorization(ctx, az); err != nil {
@@ -286,34 +495,107 @@ func GetOrder(w http.ResponseWriter, r *http.Request) {
 	db := acme.MustDatabaseFromContext(ctx)
 	linker := acme.MustLinkerFromContext(ctx)
 
-	acc, err := accountFromContext(ctx)
+	prov, err := provisionerFromContext(ctx)
 	if err != nil {
 		render.Error(w, err)
 		return
 	}
-	prov, err := provisionerFromContext(ctx)
+	o, err := getAccountOrder(ctx, db, prov, chi.URLParam(r, "ordID"))
 	if err != nil {
 		render.Error(w, err)
 		return
 	}
+	if err = o.UpdateStatus(ctx, db); err != nil {
+		render.Error(w, acme.WrapErrorISE(err, "error updating order status"))
+		return
+	}
 
-	o, err := db.GetOrder(ctx, chi.URLParam(r, "ordID"))
+	linker.LinkOrder(ctx, o)
+
+	if o.Status == acme.StatusProcessing {
+		w.Header().Set("Retry-After", processingRetryAfter(prov))
+	}
+	w.Header().Set("Location", linker.GetLink(ctx, acme.OrderLinkType, o.ID))
+	render.JSON(w, o)
+}
+
+// getAccountOrder returns the order with the given id if it is owned by the
+// account and the provisioner in the context.
+func getAccountOrder(ctx context.Context, db acme.DB, prov acme.Provisioner, id string) (*acme.Order, error) {
+	acc, err := accountFromContext(ctx)
 	if err != nil {
-		render.Error(w, acme.WrapErrorISE(err, "error retrieving order"))
-		return
+		return nil, err
+	}
+	o, err := db.GetOrder(ctx, id)
+	if err != nil {
+		return nil, acme.WrapErrorISE(err, "error retrieving order")
 	}
 	if acc.ID != o.AccountID {
-		render.Error(w, acme.NewError(acme.ErrorUnauthorizedType,
-			"account '%s' does not own order '%s'", acc.ID, o.ID))
-		return
+		return nil, acme.NewError(acme.ErrorUnauthorizedType,
+			"account '%s' does not own order '%s'", acc.ID, o.ID)
 	}
 	if prov.GetID() != o.ProvisionerID {
-		render.Error(w, acme.NewError(acme.ErrorUnauthorizedType,
-			"provisioner '%s' does not own order '%s'", prov.GetID(), o.ID))
+		return nil, acme.NewError(acme.ErrorUnauthorizedType,
+			"provisioner '%s' does not own order '%s'", prov.GetID(), o.ID)
+	}
+	return o, nil
+}
+
+// UpdateOrderRequest represents the body of a request to cancel an
+// auto-renewal order, RFC 8739 section 3.1.2.
+type UpdateOrderRequest struct {
+	Status acme.Status `json:"status"`
+}
+
+// Validate validates an update-order request body.
+func (u *UpdateOrderRequest) Validate() error {
+	if u.Status != acme.StatusCanceled {
+		return acme.NewError(acme.ErrorAutoRenewalCancellationInvalidType,
+			"cannot update order status to '%s', only 'canceled'", u.Status)
+	}
+	return nil
+}
+
+// GetOrUpdateOrder returns an order with a POST-as-GET request, or cancels an
+// auto-renewal order with a request with the canceled status.
+func GetOrUpdateOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	db := acme.MustDatabaseFromContext(ctx)
+	linker := acme.MustLinkerFromContext(ctx)
+
+	payload, err := payloadFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
 		return
 	}
-	if err = o.UpdateStatus(ctx, db); err != nil {
-		render.Error(w, acme.WrapErrorISE(err, "error updating order status"))
+	if payload.isPostAsGet {
+		GetOrder(w, r)
+		return
+	}
+
+	var uor UpdateOrderRequest
+	if err := json.Unmarshal(payload.value, &uor); err != nil {
+		render.Error(w, acme.WrapError(acme.ErrorMalformedType, err,
+			"failed to unmarshal update-order request payload"))
+		return
+	}
+	if err := uor.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	prov, err := provisionerFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	o, err := getAccountOrder(ctx, db, prov, chi.URLParam(r, "ordID"))
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	if err := o.Cancel(ctx, db); err != nil {
+		render.Error(w, err)
 		return
 	}
 
@@ -379,6 +661,9 @@ func FinalizeOrder(w http.ResponseWriter, r *http.Request) {
 
 	linker.LinkOrder(ctx, o)
 
+	if o.Status == acme.StatusProcessing {
+		w.Header().Set("Retry-After", processingRetryAfter(prov))
+	}
 	w.Header().Set("Location", linker.GetLink(ctx, acme.OrderLinkType, o.ID))
 	render.JSON(w, o)
 }
@@ -399,9 +684,19 @@ func challengeTypes(az *acme.Authorization) []acme.ChallengeType {
 		}
 	case acme.PermanentIdentifier:
 		chTypes = []acme.ChallengeType{acme.DEVICEATTEST01}
+	case acme.Email:
+		chTypes = []acme.ChallengeType{acme.EMAILREPLY00}
+	case acme.SSHPrincipal:
+		chTypes = []acme.ChallengeType{acme.OIDC01}
 	default:
 		chTypes = []acme.ChallengeType{}
 	}
 
 	return chTypes
 }
+
+// isEmailReplyEnabled returns true if the provisioner can validate email
+// identifiers with email-reply-00 challenges.
+func isEmailReplyEnabled(ctx context.Context, prov acme.Provisioner) bool {
+	return prov.GetEmailReplyOptions() != nil && prov.IsChallengeEnabled(ctx, provisioner.EMAIL_REPLY_00)
+}
@@ -50,6 +50,48 @@ func TestNewOrderRequest_Validate(t *testing.T) {
 				err: acme.NewError(acme.ErrorMalformedType, "identifier type unsupported: foo"),
 			}
 		},
+		"fail/bad-identifier/bad-email": func(t *testing.T) test {
+			return test{
+				nor: &NewOrderRequest{
+					Identifiers: []acme.Identifier{
+						{Type: "email", Value: "Jane <jane@example.com>"},
+					},
+				},
+				err: acme.NewError(acme.ErrorMalformedType, "invalid email address: Jane <jane@example.com>"),
+			}
+		},
+		"fail/bad-identifier/mixed-email": func(t *testing.T) test {
+			return test{
+				nor: &NewOrderRequest{
+					Identifiers: []acme.Identifier{
+						{Type: "email", Value: "jane@example.com"},
+						{Type: "dns", Value: "example.com"},
+					},
+				},
+				err: acme.NewError(acme.ErrorMalformedType, "email identifiers cannot be combined with other identifier types"),
+			}
+		},
+		"fail/bad-identifier/bad-ssh-principal": func(t *testing.T) test {
+			return test{
+				nor: &NewOrderRequest{
+					Identifiers: []acme.Identifier{
+						{Type: "ssh-principal", Value: "jane,root"},
+					},
+				},
+				err: acme.NewError(acme.ErrorMalformedType, "invalid ssh principal: jane,root"),
+			}
+		},
+		"fail/bad-identifier/mixed-ssh-principal": func(t *testing.T) test {
+			return test{
+				nor: &NewOrderRequest{
+					Identifiers: []acme.Identifier{
+						{Type: "ssh-principal", Value: "jane"},
+						{Type: "dns", Value: "example.com"},
+					},
+				},
+				err: acme.NewError(acme.ErrorMalformedType, "ssh-principal identifiers can only be combined with permanent-identifier identifiers"),
+			}
+		},
 		"fail/bad-identifier/bad-dns": func(t *testing.T) test {
 			return test{
 				nor: &NewOrderRequest{
@@ -112,6 +154,37 @@ func TestNewOrderRequest_Validate(t *testing.T) {
 				naf: naf,
 			}
 		},
+		"ok/email": func(t *testing.T) test {
+			nbf := time.Now().UTC().Add(time.Minute)
+			naf := time.Now().UTC().Add(5 * time.Minute)
+			return test{
+				nor: &NewOrderRequest{
+					Identifiers: []acme.Identifier{
+						{Type: "email", Value: "jane@example.com"},
+					},
+					NotAfter:  naf,
+					NotBefore: nbf,
+				},
+				nbf: nbf,
+				naf: naf,
+			}
+		},
+		"ok/ssh-principal": func(t *testing.T) test {
+			nbf := time.Now().UTC().Add(time.Minute)
+			naf := time.Now().UTC().Add(5 * time.Minute)
+			return test{
+				nor: &NewOrderRequest{
+					Identifiers: []acme.Identifier{
+						{Type: "ssh-principal", Value: "jane"},
+						{Type: "permanent-identifier", Value: "device-serial"},
+					},
+					NotAfter:  naf,
+					NotBefore: nbf,
+				},
+				nbf: nbf,
+				naf: naf,
+			}
+		},
 		"ok/ipv4": func(t *testing.T) test {
 			nbf := time.Now().UTC().Add(time.Minute)
 			naf := time.Now().UTC().Add(5 * time.Minute)
@@ -501,6 +574,201 @@ func TestHandler_GetOrder(t *testing.T) {
 	}
 }
 
+func TestNewOrderRequest_Validate_autoRenewal(t *testing.T) {
+	now := time.Now().UTC()
+	dns := []acme.Identifier{{Type: "dns", Value: "example.com"}}
+	tests := []struct {
+		name    string
+		nor     *NewOrderRequest
+		wantErr string
+	}{
+		{"ok", &NewOrderRequest{Identifiers: dns, AutoRenewal: &acme.AutoRenewal{EndDate: now.Add(time.Hour), Lifetime: 3600}}, ""},
+		{"ok/start-date", &NewOrderRequest{Identifiers: dns, AutoRenewal: &acme.AutoRenewal{StartDate: now, EndDate: now.Add(time.Hour), Lifetime: 3600, LifetimeAdjust: 60}}, ""},
+		{"fail/not-after", &NewOrderRequest{Identifiers: dns, NotAfter: now, AutoRenewal: &acme.AutoRenewal{EndDate: now.Add(time.Hour), Lifetime: 3600}}, "notBefore and notAfter cannot be combined with auto-renewal"},
+		{"fail/ssh", &NewOrderRequest{Identifiers: []acme.Identifier{{Type: "ssh-principal", Value: "jane"}}, AutoRenewal: &acme.AutoRenewal{EndDate: now.Add(time.Hour), Lifetime: 3600}}, "ssh-principal identifiers cannot be combined with auto-renewal"},
+		{"fail/end-date", &NewOrderRequest{Identifiers: dns, AutoRenewal: &acme.AutoRenewal{Lifetime: 3600}}, "auto-renewal end-date is required"},
+		{"fail/lifetime", &NewOrderRequest{Identifiers: dns, AutoRenewal: &acme.AutoRenewal{EndDate: now.Add(time.Hour)}}, "auto-renewal lifetime must be a positive number of seconds"},
+		{"fail/lifetime-adjust", &NewOrderRequest{Identifiers: dns, AutoRenewal: &acme.AutoRenewal{EndDate: now.Add(time.Hour), Lifetime: 3600, LifetimeAdjust: -1}}, "auto-renewal lifetime-adjust cannot be negative"},
+		{"fail/start-date", &NewOrderRequest{Identifiers: dns, AutoRenewal: &acme.AutoRenewal{StartDate: now.Add(time.Hour), EndDate: now, Lifetime: 3600}}, "auto-renewal end-date must be after start-date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.nor.Validate()
+			if tt.wantErr == "" {
+				assert.FatalError(t, err)
+				return
+			}
+			var ae *acme.Error
+			if assert.True(t, errors.As(err, &ae)) {
+				assert.Equals(t, ae.Type, "urn:ietf:params:acme:error:malformed")
+				assert.Equals(t, ae.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_authorizeAutoRenewal(t *testing.T) {
+	now := time.Now().UTC()
+	day := 24 * time.Hour
+	opts := &provisioner.ACMEAutoRenewalOptions{Enabled: true, MaxDuration: &provisioner.Duration{Duration: 30 * day}}
+	tests := []struct {
+		name      string
+		opts      *provisioner.ACMEAutoRenewalOptions
+		ar        *acme.AutoRenewal
+		wantStart time.Time
+		wantErr   string
+	}{
+		{"ok", opts, &acme.AutoRenewal{EndDate: now.Add(day), Lifetime: 3600}, now, ""},
+		{"ok/start-date", opts, &acme.AutoRenewal{StartDate: now.Add(day), EndDate: now.Add(2 * day), Lifetime: 3600}, now.Add(day), ""},
+		{"ok/allow-certificate-get", &provisioner.ACMEAutoRenewalOptions{Enabled: true, AllowCertificateGet: true}, &acme.AutoRenewal{EndDate: now.Add(day), Lifetime: 3600, AllowCertificateGet: true}, now, ""},
+		{"fail/disabled", nil, &acme.AutoRenewal{EndDate: now.Add(day), Lifetime: 3600}, time.Time{}, "auto-renewal orders are not enabled"},
+		{"fail/end-date", opts, &acme.AutoRenewal{EndDate: now, Lifetime: 3600}, now, "auto-renewal end-date must be in the future"},
+		{"fail/start-date", opts, &acme.AutoRenewal{StartDate: now.Add(2 * day), EndDate: now.Add(day), Lifetime: 3600}, now.Add(2 * day), "auto-renewal end-date must be after start-date"},
+		{"fail/min-lifetime", opts, &acme.AutoRenewal{EndDate: now.Add(day), Lifetime: 60}, now, "auto-renewal lifetime cannot be less than 3600 seconds"},
+		{"fail/max-duration", opts, &acme.AutoRenewal{EndDate: now.Add(31 * day), Lifetime: 3600}, now, "auto-renewal duration cannot be greater than 2592000 seconds"},
+		{"fail/allow-certificate-get", opts, &acme.AutoRenewal{EndDate: now.Add(day), Lifetime: 3600, AllowCertificateGet: true}, now, "auto-renewal allow-certificate-get is not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authorizeAutoRenewal(tt.opts, tt.ar, now)
+			assert.Equals(t, tt.ar.StartDate, tt.wantStart)
+			if tt.wantErr == "" {
+				assert.FatalError(t, err)
+				return
+			}
+			var ae *acme.Error
+			if assert.True(t, errors.As(err, &ae)) {
+				assert.Equals(t, ae.Type, "urn:ietf:params:acme:error:malformed")
+				assert.Equals(t, ae.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandler_GetOrUpdateOrder(t *testing.T) {
+	prov := newProv()
+	escProvName := url.PathEscape(prov.GetName())
+	baseURL := &url.URL{Scheme: "https", Host: "test.ca.smallstep.com"}
+
+	now := clock.Now()
+	newOrder := func(status acme.Status) *acme.Order {
+		return &acme.Order{
+			ID:               "orderID",
+			AccountID:        "accountID",
+			ProvisionerID:    fmt.Sprintf("acme/%s", prov.GetName()),
+			ExpiresAt:        now.Add(time.Hour),
+			Status:           status,
+			AuthorizationIDs: []string{"foo"},
+			NotBefore:        now,
+			NotAfter:         now.Add(time.Hour),
+			Identifiers:      []acme.Identifier{{Type: "dns", Value: "example.com"}},
+			CertificateID:    "certID",
+			AutoRenewal:      &acme.AutoRenewal{StartDate: now, EndDate: now.Add(24 * time.Hour), Lifetime: 3600},
+		}
+	}
+
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add("ordID", "orderID")
+	u := fmt.Sprintf("%s/acme/%s/order/orderID", baseURL.String(), escProvName)
+
+	type test struct {
+		db         acme.DB
+		payload    *payloadInfo
+		statusCode int
+		err        *acme.Error
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/unmarshal": func(t *testing.T) test {
+			return test{
+				db:         &acme.MockDB{},
+				payload:    &payloadInfo{value: []byte("foo")},
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "failed to unmarshal update-order request payload: invalid character 'o' in literal false (expecting 'a')"),
+			}
+		},
+		"fail/status": func(t *testing.T) test {
+			return test{
+				db:         &acme.MockDB{},
+				payload:    &payloadInfo{value: []byte(`{"status":"deactivated"}`)},
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorAutoRenewalCancellationInvalidType, "cannot update order status to 'deactivated', only 'canceled'"),
+			}
+		},
+		"fail/not-valid": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetOrder: func(ctx context.Context, id string) (*acme.Order, error) {
+						return newOrder(acme.StatusReady), nil
+					},
+				},
+				payload:    &payloadInfo{value: []byte(`{"status":"canceled"}`)},
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorAutoRenewalCancellationInvalidType, "order orderID is not valid"),
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetOrder: func(ctx context.Context, id string) (*acme.Order, error) {
+						return newOrder(acme.StatusValid), nil
+					},
+					MockUpdateOrderStatus: func(ctx context.Context, o *acme.Order, from acme.Status) error {
+						assert.Equals(t, o.Status, acme.StatusCanceled)
+						assert.Equals(t, from, acme.StatusValid)
+						return nil
+					},
+					MockGetStarOrder: func(ctx context.Context, orderID string) (*acme.StarOrder, error) {
+						return &acme.StarOrder{OrderID: orderID, Status: acme.StarOrderActive}, nil
+					},
+					MockUpdateStarOrder: func(ctx context.Context, s *acme.StarOrder, from acme.StarOrderStatus) error {
+						assert.Equals(t, s.Status, acme.StarOrderCanceled)
+						return nil
+					},
+				},
+				payload:    &payloadInfo{value: []byte(`{"status":"canceled"}`)},
+				statusCode: 200,
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			ctx := acme.NewProvisionerContext(context.Background(), prov)
+			ctx = context.WithValue(ctx, accContextKey, &acme.Account{ID: "accountID"})
+			ctx = context.WithValue(ctx, payloadContextKey, tc.payload)
+			ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+			ctx = newBaseContext(ctx, tc.db, acme.NewLinker("test.ca.smallstep.com", "acme"))
+			req := httptest.NewRequest("POST", u, http.NoBody)
+			req = req.WithContext(ctx)
+			w := httptest.NewRecorder()
+			GetOrUpdateOrder(w, req)
+			res := w.Result()
+
+			assert.Equals(t, res.StatusCode, tc.statusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 && assert.NotNil(t, tc.err) {
+				var ae acme.Error
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &ae))
+				assert.Equals(t, ae.Type, tc.err.Type)
+				assert.Equals(t, ae.Detail, tc.err.Detail)
+				assert.Equals(t, res.Header["Content-Type"], []string{"application/problem+json"})
+			} else {
+				var o acme.Order
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &o))
+				assert.Equals(t, o.Status, acme.StatusCanceled)
+				assert.Equals(t, o.StarCertificateURL, fmt.Sprintf("%s/acme/%s/star-certificate/orderID", baseURL.String(), escProvName))
+				assert.Equals(t, o.CertificateURL, "")
+				assert.Equals(t, res.Header["Location"], []string{u})
+				assert.Equals(t, res.Header["Content-Type"], []string{"application/json"})
+			}
+		})
+	}
+}
+
 func TestHandler_newAuthorization(t *testing.T) {
 	defaultProvisioner := newProv()
 	type test struct {
@@ -715,6 +983,33 @@ func TestHandler_newAuthorization(t *testing.T) {
 				az: az,
 			}
 		},
+		"ok/token-length": func(t *testing.T) test {
+			az := &acme.Authorization{
+				AccountID: "accID",
+				Identifier: acme.Identifier{
+					Type:  "dns",
+					Value: "zap.internal",
+				},
+				Status:    acme.StatusPending,
+				ExpiresAt: clock.Now(),
+			}
+			tokenProv := newProv()
+			tokenProv.(*provisioner.ACME).Orders = &provisioner.ACMEOrderOptions{TokenLength: 48}
+			return test{
+				prov: tokenProv,
+				db: &acme.MockDB{
+					MockCreateChallenge: func(ctx context.Context, ch *acme.Challenge) error {
+						assert.Len(t, 48, ch.Token)
+						return nil
+					},
+					MockCreateAuthorization: func(ctx context.Context, _az *acme.Authorization) error {
+						assert.Len(t, 48, _az.Token)
+						return nil
+					},
+				},
+				az: az,
+			}
+		},
 		"ok/permanent-identifier-enabled": func(t *testing.T) test {
 			var ch1 *acme.Challenge
 			az := &acme.Authorization{
@@ -918,6 +1213,34 @@ func TestHandler_NewOrder(t *testing.T) {
 				err: acme.NewErrorISE("error retrieving external account binding key: force"),
 			}
 		},
+		"fail/max-order-identifiers": func(t *testing.T) test {
+			maxIdentifiers := 1
+			acmeProv := &provisioner.ACME{
+				Type:   "ACME",
+				Name:   "test@acme-<test>provisioner.com",
+				Claims: &provisioner.Claims{MaxOrderIdentifiers: &maxIdentifiers},
+			}
+			assert.FatalError(t, acmeProv.Init(provisioner.Config{Claims: globalProvisionerClaims}))
+			acc := &acme.Account{ID: "accID"}
+			fr := &NewOrderRequest{
+				Identifiers: []acme.Identifier{
+					{Type: "dns", Value: "zap.internal"},
+					{Type: "dns", Value: "zar.internal"},
+				},
+			}
+			b, err := json.Marshal(fr)
+			assert.FatalError(t, err)
+			ctx := acme.NewProvisionerContext(context.Background(), acmeProv)
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			ctx = context.WithValue(ctx, payloadContextKey, &payloadInfo{value: b})
+			return test{
+				ctx:        ctx,
+				statusCode: 400,
+				ca:         &mockCA{},
+				db:         &acme.MockDB{},
+				err:        acme.NewError(acme.ErrorRejectedIdentifierType, "order has 2 identifiers, the maximum allowed is 1"),
+			}
+		},
 		"fail/db.GetExternalAccountKeyByAccountID-error": func(t *testing.T) test {
 			acmeProv := newACMEProv(t)
 			acmeProv.RequireEAB = true
@@ -1822,6 +2145,70 @@ func TestHandler_NewOrder(t *testing.T) {
 				},
 			}
 		},
+		"ok/reuse-authz": func(t *testing.T) test {
+			acmeProv := newACMEProv(t)
+			acmeProv.Orders = &provisioner.ACMEOrderOptions{
+				AuthorizationReuse: &provisioner.Duration{Duration: time.Hour},
+			}
+			acc := &acme.Account{ID: "accID"}
+			nor := &NewOrderRequest{
+				Identifiers: []acme.Identifier{
+					{Type: "dns", Value: "zap.internal"},
+					{Type: "dns", Value: "*.zap.internal"},
+				},
+			}
+			b, err := json.Marshal(nor)
+			assert.FatalError(t, err)
+			ctx := acme.NewProvisionerContext(context.Background(), acmeProv)
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			ctx = context.WithValue(ctx, payloadContextKey, &payloadInfo{value: b})
+			validatedAt := clock.Now().Add(-time.Minute).Format(time.RFC3339)
+			return test{
+				ctx:        ctx,
+				statusCode: 201,
+				nor:        nor,
+				ca:         &mockCA{},
+				db: &acme.MockDB{
+					MockGetAuthorizationsByAccountID: func(ctx context.Context, accountID string) ([]*acme.Authorization, error) {
+						assert.Equals(t, "accID", accountID)
+						return []*acme.Authorization{
+							{ID: "pending", Identifier: acme.Identifier{Type: "dns", Value: "zap.internal"}, Status: acme.StatusPending},
+							{ID: "valid", Identifier: acme.Identifier{Type: "dns", Value: "zap.internal"}, Status: acme.StatusValid},
+						}, nil
+					},
+					MockGetAuthorization: func(ctx context.Context, id string) (*acme.Authorization, error) {
+						assert.Equals(t, "valid", id)
+						return &acme.Authorization{
+							ID:         id,
+							Identifier: acme.Identifier{Type: "dns", Value: "zap.internal"},
+							Status:     acme.StatusValid,
+							Challenges: []*acme.Challenge{{Status: acme.StatusValid, ValidatedAt: validatedAt}},
+						}, nil
+					},
+					MockCreateChallenge: func(ctx context.Context, ch *acme.Challenge) error {
+						ch.ID = "dns"
+						assert.Equals(t, acme.DNS01, ch.Type)
+						return nil
+					},
+					MockCreateAuthorization: func(ctx context.Context, az *acme.Authorization) error {
+						az.ID = "wildcard"
+						assert.True(t, az.Wildcard)
+						return nil
+					},
+					MockCreateOrder: func(ctx context.Context, o *acme.Order) error {
+						o.ID = "ordID"
+						assert.Equals(t, []string{"valid", "wildcard"}, o.AuthorizationIDs)
+						return nil
+					},
+				},
+				vr: func(t *testing.T, o *acme.Order) {
+					assert.Equals(t, []string{
+						fmt.Sprintf("%s/acme/%s/authz/valid", baseURL.String(), escProvName),
+						fmt.Sprintf("%s/acme/%s/authz/wildcard", baseURL.String(), escProvName),
+					}, o.AuthorizationURLs)
+				},
+			}
+		},
 	}
 	for name, run := range tests {
 		tc := run(t)
@@ -2237,3 +2624,23 @@ func TestTrimIfWildcard(t *testing.T) {
 		})
 	}
 }
+
+func Test_processingRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		approval *provisioner.ACMEApprovalOptions
+		want     string
+	}{
+		{"no approval", nil, "1"},
+		{"not required", &provisioner.ACMEApprovalOptions{RetryAfter: &provisioner.Duration{Duration: time.Hour}}, "1"},
+		{"default", &provisioner.ACMEApprovalOptions{Required: true}, "60"},
+		{"custom", &provisioner.ACMEApprovalOptions{Required: true, RetryAfter: &provisioner.Duration{Duration: 1500 * time.Millisecond}}, "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prov := newACMEProv(t)
+			prov.Orders = &provisioner.ACMEOrderOptions{Approval: tt.approval}
+			assert.Equals(t, tt.want, processingRetryAfter(prov))
+		})
+	}
+}
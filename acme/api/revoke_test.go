@@ -25,6 +25,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/ocsp"
+	"golang.org/x/crypto/ssh"
 
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/keyutil"
@@ -276,12 +277,17 @@ func jwsFinal(_ crypto.Hash, sig []byte, phead, payload string) ([]byte, error)
 }
 
 type mockCA struct {
-	MockIsRevoked      func(sn string) (bool, error)
-	MockRevoke         func(ctx context.Context, opts *authority.RevokeOptions) error
-	MockAreSANsallowed func(ctx context.Context, sans []string) error
+	MockIsRevoked          func(sn string) (bool, error)
+	MockRevoke             func(ctx context.Context, opts *authority.RevokeOptions) error
+	MockAreSANsallowed     func(ctx context.Context, sans []string) error
+	MockGetAlternateChains func(crt *x509.Certificate) [][]*x509.Certificate
 }
 
-func (m *mockCA) Sign(*x509.CertificateRequest, provisioner.SignOptions, ...provisioner.SignOption) ([]*x509.Certificate, error) {
+func (m *mockCA) SignWithContext(context.Context, *x509.CertificateRequest, provisioner.SignOptions, ...provisioner.SignOption) ([]*x509.Certificate, error) {
+	return nil, nil
+}
+
+func (m *mockCA) SignSSH(context.Context, ssh.PublicKey, provisioner.SignSSHOptions, ...provisioner.SignOption) (*ssh.Certificate, error) {
 	return nil, nil
 }
 
@@ -310,6 +316,13 @@ func (m *mockCA) LoadProvisionerByName(string) (provisioner.Interface, error) {
 	return nil, nil
 }
 
+func (m *mockCA) GetAlternateChains(crt *x509.Certificate) [][]*x509.Certificate {
+	if m.MockGetAlternateChains != nil {
+		return m.MockGetAlternateChains(crt)
+	}
+	return nil
+}
+
 func Test_validateReasonCode(t *testing.T) {
 	tests := []struct {
 		name       string
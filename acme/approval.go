@@ -0,0 +1,261 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/webhook"
+)
+
+// ApprovalStatus is the status of the approval of a deferred order.
+type ApprovalStatus string
+
+const (
+	// ApprovalPending is the status of the orders waiting for a decision.
+	ApprovalPending ApprovalStatus = "pending"
+	// ApprovalApproved is the status of the approved orders.
+	ApprovalApproved ApprovalStatus = "approved"
+	// ApprovalRejected is the status of the rejected orders.
+	ApprovalRejected ApprovalStatus = "rejected"
+)
+
+// DeferredOrder is the finalization of an order that requires an approval.
+// It keeps the CSR sent by the client until the order is approved or
+// rejected.
+type DeferredOrder struct {
+	OrderID       string         `json:"orderID"`
+	AccountID     string         `json:"accountID"`
+	ProvisionerID string         `json:"provisionerID"`
+	Identifiers   []Identifier   `json:"identifiers"`
+	CSR           []byte         `json:"csr"`
+	Status        ApprovalStatus `json:"status"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	DecidedAt     time.Time      `json:"decidedAt,omitempty"`
+	DecidedBy     string         `json:"decidedBy,omitempty"`
+	Reason        string         `json:"reason,omitempty"`
+}
+
+// Types of the events sent to the approval webhook.
+const (
+	ApprovalRequiredEvent = "order.approvalRequired"
+	OrderReadyEvent       = "order.ready"
+	OrderRejectedEvent    = "order.rejected"
+)
+
+// ApprovalEvent is the body sent to the approval webhook.
+type ApprovalEvent struct {
+	Type          string       `json:"type"`
+	Timestamp     time.Time    `json:"timestamp"`
+	Nonce         string       `json:"nonce"`
+	OrderID       string       `json:"orderID"`
+	AccountID     string       `json:"accountID"`
+	ProvisionerID string       `json:"provisionerID"`
+	Identifiers   []Identifier `json:"identifiers"`
+	SerialNumber  string       `json:"serialNumber,omitempty"`
+	DecidedBy     string       `json:"decidedBy,omitempty"`
+	Reason        string       `json:"reason,omitempty"`
+}
+
+// approvalWebhookTimeout is the timeout of the requests to the approval
+// webhook.
+var approvalWebhookTimeout = 10 * time.Second
+
+// deferFinalize stores the CSR of a processing order until the order is
+// approved or rejected.
+func (o *Order) deferFinalize(ctx context.Context, db DB, csr []byte, approval *provisioner.ACMEApprovalOptions) error {
+	d := &DeferredOrder{
+		OrderID:       o.ID,
+		AccountID:     o.AccountID,
+		ProvisionerID: o.ProvisionerID,
+		Identifiers:   o.Identifiers,
+		CSR:           csr,
+		Status:        ApprovalPending,
+		CreatedAt:     clock.Now(),
+	}
+	if err := db.CreateDeferredOrder(ctx, d); err != nil {
+		o.release(ctx, db)
+		return WrapErrorISE(err, "error creating deferred order %s", o.ID)
+	}
+	notifyApproval(approval.GetWebhook(), d, ApprovalRequiredEvent, "")
+	return nil
+}
+
+// Approve issues the certificate of an order waiting for an approval. If the
+// certificate cannot be signed the order becomes invalid.
+func (o *Order) Approve(ctx context.Context, db DB, auth CertificateAuthority, p Provisioner, by string) error {
+	d, err := o.decide(ctx, db, p, ApprovalApproved, by, "")
+	if err != nil {
+		return err
+	}
+
+	cert, err := o.signDeferred(ctx, db, d, auth, p)
+	if err != nil {
+		var acmeErr *Error
+		if !errors.As(err, &acmeErr) {
+			acmeErr = WrapErrorISE(err, "error signing certificate for order %s", o.ID)
+		}
+		o.Status = StatusInvalid
+		o.Error = acmeErr
+		if err := db.UpdateOrder(ctx, o); err != nil {
+			return WrapErrorISE(err, "error updating order %s", o.ID)
+		}
+		MeterFromContext(ctx).OrderChanged(ctx, o, nil)
+		d.Status = ApprovalRejected
+		d.Reason = acmeErr.Error()
+		if err := db.UpdateDeferredOrder(ctx, d, ApprovalApproved); err != nil {
+			return WrapErrorISE(err, "error updating deferred order %s", o.ID)
+		}
+		notifyApproval(p.GetApprovalOptions().GetWebhook(), d, OrderRejectedEvent, "")
+		return acmeErr
+	}
+
+	notifyApproval(p.GetApprovalOptions().GetWebhook(), d, OrderReadyEvent, cert.SerialNumber())
+	return nil
+}
+
+// Reject rejects an order waiting for an approval, the order becomes
+// invalid.
+func (o *Order) Reject(ctx context.Context, db DB, p Provisioner, by, reason string) error {
+	d, err := o.decide(ctx, db, p, ApprovalRejected, by, reason)
+	if err != nil {
+		return err
+	}
+
+	o.Status = StatusInvalid
+	if reason == "" {
+		o.Error = NewDetailedError(ErrorUnauthorizedType, "order %s has been rejected", o.ID)
+	} else {
+		o.Error = NewDetailedError(ErrorUnauthorizedType, "order %s has been rejected: %s", o.ID, reason)
+	}
+	if err := db.UpdateOrder(ctx, o); err != nil {
+		return WrapErrorISE(err, "error updating order %s", o.ID)
+	}
+	MeterFromContext(ctx).OrderChanged(ctx, o, nil)
+
+	notifyApproval(p.GetApprovalOptions().GetWebhook(), d, OrderRejectedEvent, "")
+	return nil
+}
+
+// decide records the decision on the deferred order. Only one of the
+// concurrent decisions succeeds.
+func (o *Order) decide(ctx context.Context, db DB, p Provisioner, status ApprovalStatus, by, reason string) (*DeferredOrder, error) {
+	if o.ProvisionerID != p.GetID() {
+		return nil, NewError(ErrorMalformedType, "order %s does not belong to provisioner %s", o.ID, p.GetName())
+	}
+	if o.Status != StatusProcessing {
+		return nil, NewError(ErrorMalformedType, "order %s is not waiting for an approval", o.ID)
+	}
+	d, err := db.GetDeferredOrder(ctx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	if d.Status != ApprovalPending {
+		return nil, NewError(ErrorMalformedType, "order %s has already been %s", o.ID, d.Status)
+	}
+
+	d.Status = status
+	d.DecidedAt = clock.Now()
+	d.DecidedBy = by
+	d.Reason = reason
+	if err := db.UpdateDeferredOrder(ctx, d, ApprovalPending); err != nil {
+		if IsErrConflict(err) {
+			return nil, NewError(ErrorMalformedType, "order %s has already been decided", o.ID)
+		}
+		return nil, WrapErrorISE(err, "error updating deferred order %s", o.ID)
+	}
+	return d, nil
+}
+
+// signDeferred signs the certificate of an approved order. The CSR and the
+// sign options are validated again, the provisioner might have changed while
+// the order was waiting for the approval.
+func (o *Order) signDeferred(ctx context.Context, db DB, d *DeferredOrder, auth CertificateAuthority, p Provisioner) (*Certificate, error) {
+	csr, err := x509.ParseCertificateRequest(d.CSR)
+	if err != nil {
+		return nil, WrapErrorISE(err, "error parsing csr of order %s", o.ID)
+	}
+	ctx = provisioner.NewContextWithMethod(ctx, provisioner.SignMethod)
+	csr, signOps, err := o.signOptions(ctx, db, csr, p)
+	if err != nil {
+		return nil, err
+	}
+	return o.sign(ctx, db, csr, auth, signOps)
+}
+
+// notifyApproval sends the event to the approval webhook in the background.
+// Errors are logged, the webhook does not affect the order.
+func notifyApproval(wh *provisioner.ACMEApprovalWebhook, d *DeferredOrder, typ, serialNumber string) {
+	if wh == nil {
+		return
+	}
+	nonce, err := webhook.NewNonce()
+	if err != nil {
+		log.Printf("error sending %s event of order %s: %v", typ, d.OrderID, err)
+		return
+	}
+	e := &ApprovalEvent{
+		Type:          typ,
+		Timestamp:     clock.Now().UTC(),
+		Nonce:         nonce,
+		OrderID:       d.OrderID,
+		AccountID:     d.AccountID,
+		ProvisionerID: d.ProvisionerID,
+		Identifiers:   d.Identifiers,
+		SerialNumber:  serialNumber,
+		DecidedBy:     d.DecidedBy,
+		Reason:        d.Reason,
+	}
+	go func() {
+		if err := postApprovalEvent(wh, e); err != nil {
+			log.Printf("error sending %s event of order %s: %v", typ, d.OrderID, err)
+		}
+	}()
+}
+
+func postApprovalEvent(wh *provisioner.ACMEApprovalWebhook, e *ApprovalEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling event")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), approvalWebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if wh.Secret != "" {
+		secret, err := base64.StdEncoding.DecodeString(wh.Secret)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(webhook.SignatureHeader, webhook.Sign(secret, body))
+	}
+	if wh.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+wh.BearerToken)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook server responded with %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package acme
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/keyutil"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/webhook"
+)
+
+func newApprovalCSR(t *testing.T, names ...string) *x509.CertificateRequest {
+	t.Helper()
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: names[0]},
+		DNSNames: names,
+	}, signer)
+	assert.FatalError(t, err)
+	csr, err := x509.ParseCertificateRequest(der)
+	assert.FatalError(t, err)
+	return csr
+}
+
+func newApprovalProvisioner(approval *provisioner.ACMEApprovalOptions) *MockProvisioner {
+	return &MockProvisioner{
+		MgetID:   func() string { return "provID" },
+		MgetName: func() string { return "acme" },
+		MauthorizeSign: func(ctx context.Context, token string) ([]provisioner.SignOption, error) {
+			return nil, nil
+		},
+		MgetOptions:         func() *provisioner.Options { return nil },
+		MgetApprovalOptions: func() *provisioner.ACMEApprovalOptions { return approval },
+	}
+}
+
+func TestOrder_Finalize_approval(t *testing.T) {
+	now := clock.Now()
+	o := &Order{
+		ID:               "oID",
+		AccountID:        "accID",
+		ProvisionerID:    "provID",
+		Status:           StatusReady,
+		ExpiresAt:        now.Add(5 * time.Minute),
+		AuthorizationIDs: []string{"a"},
+		Identifiers:      []Identifier{{Type: "dns", Value: "foo.internal"}},
+	}
+	csr := newApprovalCSR(t, "foo.internal")
+
+	var deferred *DeferredOrder
+	db := &MockDB{
+		MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+			return &Authorization{ID: id, Status: StatusValid}, nil
+		},
+		MockUpdateOrderStatus: func(ctx context.Context, updo *Order, from Status) error {
+			assert.Equals(t, from, StatusReady)
+			assert.Equals(t, updo.Status, StatusProcessing)
+			return nil
+		},
+		MockCreateDeferredOrder: func(ctx context.Context, d *DeferredOrder) error {
+			deferred = d
+			return nil
+		},
+		MockCreateCertificate: func(ctx context.Context, cert *Certificate) error {
+			t.Error("certificate created before the approval")
+			return nil
+		},
+	}
+	ca := &mockSignAuth{err: errors.New("force")}
+	prov := newApprovalProvisioner(&provisioner.ACMEApprovalOptions{Required: true})
+
+	assert.FatalError(t, o.Finalize(context.Background(), db, csr, ca, prov))
+	assert.Equals(t, o.Status, StatusProcessing)
+	if assert.NotNil(t, deferred) {
+		assert.Equals(t, deferred.OrderID, o.ID)
+		assert.Equals(t, deferred.AccountID, o.AccountID)
+		assert.Equals(t, deferred.ProvisionerID, o.ProvisionerID)
+		assert.Equals(t, deferred.Identifiers, o.Identifiers)
+		assert.Equals(t, deferred.CSR, csr.Raw)
+		assert.Equals(t, deferred.Status, ApprovalPending)
+	}
+
+	// The order is released if the deferred order cannot be stored.
+	o.Status = StatusReady
+	db.MockCreateDeferredOrder = func(ctx context.Context, d *DeferredOrder) error {
+		return errors.New("force")
+	}
+	db.MockUpdateOrderStatus = func(ctx context.Context, updo *Order, from Status) error {
+		return nil
+	}
+	err := o.Finalize(context.Background(), db, csr, ca, prov)
+	assert.Error(t, err)
+	assert.Equals(t, o.Status, StatusReady)
+}
+
+func TestOrder_Approve(t *testing.T) {
+	csr := newApprovalCSR(t, "foo.internal")
+	leaf := &x509.Certificate{SerialNumber: big.NewInt(1234)}
+	intermediate := &x509.Certificate{Subject: pkix.Name{CommonName: "intermediate"}}
+
+	type test struct {
+		o      *Order
+		db     *MockDB
+		ca     CertificateAuthority
+		err    *Error
+		status Status
+	}
+	tests := map[string]func(t *testing.T) test{
+		"fail/provisioner": func(t *testing.T) test {
+			o := &Order{ID: "oID", ProvisionerID: "otherID", Status: StatusProcessing}
+			return test{
+				o:   o,
+				db:  &MockDB{},
+				err: NewError(ErrorMalformedType, "order oID does not belong to provisioner acme"),
+			}
+		},
+		"fail/not-processing": func(t *testing.T) test {
+			o := &Order{ID: "oID", ProvisionerID: "provID", Status: StatusReady}
+			return test{
+				o:   o,
+				db:  &MockDB{},
+				err: NewError(ErrorMalformedType, "order oID is not waiting for an approval"),
+			}
+		},
+		"fail/decided": func(t *testing.T) test {
+			o := &Order{ID: "oID", ProvisionerID: "provID", Status: StatusProcessing}
+			return test{
+				o: o,
+				db: &MockDB{
+					MockGetDeferredOrder: func(ctx context.Context, orderID string) (*DeferredOrder, error) {
+						return &DeferredOrder{OrderID: orderID, Status: ApprovalRejected}, nil
+					},
+				},
+				err: NewError(ErrorMalformedType, "order oID has already been rejected"),
+			}
+		},
+		"fail/conflict": func(t *testing.T) test {
+			o := &Order{ID: "oID", ProvisionerID: "provID", Status: StatusProcessing}
+			return test{
+				o: o,
+				db: &MockDB{
+					MockGetDeferredOrder: func(ctx context.Context, orderID string) (*DeferredOrder, error) {
+						return &DeferredOrder{OrderID: orderID, Status: ApprovalPending}, nil
+					},
+					MockUpdateDeferredOrder: func(ctx context.Context, d *DeferredOrder, from ApprovalStatus) error {
+						return errors.Wrap(ErrConflict, "force")
+					},
+				},
+				err: NewError(ErrorMalformedType, "order oID has already been decided"),
+			}
+		},
+		"fail/sign": func(t *testing.T) test {
+			o := &Order{
+				ID: "oID", ProvisionerID: "provID", Status: StatusProcessing,
+				AuthorizationIDs: []string{"a"},
+				Identifiers:      []Identifier{{Type: "dns", Value: "foo.internal"}},
+			}
+			var updates []ApprovalStatus
+			return test{
+				o: o,
+				db: &MockDB{
+					MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+						return &Authorization{ID: id, Status: StatusValid}, nil
+					},
+					MockGetDeferredOrder: func(ctx context.Context, orderID string) (*DeferredOrder, error) {
+						return &DeferredOrder{OrderID: orderID, CSR: csr.Raw, Status: ApprovalPending}, nil
+					},
+					MockUpdateDeferredOrder: func(ctx context.Context, d *DeferredOrder, from ApprovalStatus) error {
+						updates = append(updates, from, d.Status)
+						if len(updates) == 4 {
+							assert.Equals(t, updates, []ApprovalStatus{ApprovalPending, ApprovalApproved, ApprovalApproved, ApprovalRejected})
+							assert.Equals(t, d.Reason, "error signing certificate for order oID: force")
+						}
+						return nil
+					},
+					MockUpdateOrder: func(ctx context.Context, updo *Order) error {
+						assert.Equals(t, updo.Status, StatusInvalid)
+						return nil
+					},
+				},
+				ca:     &mockSignAuth{err: errors.New("force")},
+				err:    NewErrorISE("error signing certificate for order oID: force"),
+				status: StatusInvalid,
+			}
+		},
+		"ok": func(t *testing.T) test {
+			o := &Order{
+				ID: "oID", AccountID: "accID", ProvisionerID: "provID", Status: StatusProcessing,
+				AuthorizationIDs: []string{"a"},
+				Identifiers:      []Identifier{{Type: "dns", Value: "foo.internal"}},
+			}
+			return test{
+				o: o,
+				db: &MockDB{
+					MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+						return &Authorization{ID: id, Status: StatusValid}, nil
+					},
+					MockGetDeferredOrder: func(ctx context.Context, orderID string) (*DeferredOrder, error) {
+						return &DeferredOrder{OrderID: orderID, CSR: csr.Raw, Status: ApprovalPending}, nil
+					},
+					MockUpdateDeferredOrder: func(ctx context.Context, d *DeferredOrder, from ApprovalStatus) error {
+						assert.Equals(t, from, ApprovalPending)
+						assert.Equals(t, d.Status, ApprovalApproved)
+						assert.Equals(t, d.DecidedBy, "admin@example.com")
+						return nil
+					},
+					MockCreateCertificate: func(ctx context.Context, cert *Certificate) error {
+						assert.Equals(t, cert.Leaf, leaf)
+						cert.ID = "certID"
+						return nil
+					},
+					MockUpdateOrder: func(ctx context.Context, updo *Order) error {
+						assert.Equals(t, updo.Status, StatusValid)
+						assert.Equals(t, updo.CertificateID, "certID")
+						return nil
+					},
+				},
+				ca: &mockSignAuth{
+					sign: func(_csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+						assert.Equals(t, _csr.Raw, csr.Raw)
+						return []*x509.Certificate{leaf, intermediate}, nil
+					},
+				},
+				status: StatusValid,
+			}
+		},
+	}
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc := run(t)
+			prov := newApprovalProvisioner(&provisioner.ACMEApprovalOptions{Required: true})
+			err := tc.o.Approve(context.Background(), tc.db, tc.ca, prov, "admin@example.com")
+			if tc.err != nil {
+				var k *Error
+				if assert.True(t, errors.As(err, &k)) {
+					assert.Equals(t, k.Type, tc.err.Type)
+					assert.Equals(t, k.Detail, tc.err.Detail)
+				}
+			} else {
+				assert.FatalError(t, err)
+			}
+			if tc.status != "" {
+				assert.Equals(t, tc.o.Status, tc.status)
+			}
+		})
+	}
+}
+
+func TestOrder_Reject(t *testing.T) {
+	o := &Order{ID: "oID", ProvisionerID: "provID", Status: StatusProcessing}
+	db := &MockDB{
+		MockGetDeferredOrder: func(ctx context.Context, orderID string) (*DeferredOrder, error) {
+			return &DeferredOrder{OrderID: orderID, Status: ApprovalPending}, nil
+		},
+		MockUpdateDeferredOrder: func(ctx context.Context, d *DeferredOrder, from ApprovalStatus) error {
+			assert.Equals(t, from, ApprovalPending)
+			assert.Equals(t, d.Status, ApprovalRejected)
+			assert.Equals(t, d.DecidedBy, "admin@example.com")
+			assert.Equals(t, d.Reason, "not allowed")
+			return nil
+		},
+		MockUpdateOrder: func(ctx context.Context, updo *Order) error {
+			assert.Equals(t, updo.Status, StatusInvalid)
+			return nil
+		},
+	}
+	prov := newApprovalProvisioner(&provisioner.ACMEApprovalOptions{Required: true})
+	assert.FatalError(t, o.Reject(context.Background(), db, prov, "admin@example.com", "not allowed"))
+	assert.Equals(t, o.Status, StatusInvalid)
+	if assert.NotNil(t, o.Error) {
+		assert.Equals(t, o.Error.Type, "urn:ietf:params:acme:error:unauthorized")
+		assert.Equals(t, o.Error.Detail, "The client lacks sufficient authorization: order oID has been rejected: not allowed")
+	}
+}
+
+func Test_notifyApproval(t *testing.T) {
+	secret := []byte("the-secret")
+	events := make(chan *ApprovalEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.FatalError(t, err)
+		assert.Equals(t, r.Header.Get(webhook.SignatureHeader), webhook.Sign(secret, body))
+		assert.Equals(t, r.Header.Get("Authorization"), "Bearer the-token")
+		var e ApprovalEvent
+		assert.FatalError(t, json.Unmarshal(body, &e))
+		events <- &e
+	}))
+	defer srv.Close()
+
+	notifyApproval(&provisioner.ACMEApprovalWebhook{
+		URL:         srv.URL,
+		Secret:      base64.StdEncoding.EncodeToString(secret),
+		BearerToken: "the-token",
+	}, &DeferredOrder{
+		OrderID:       "oID",
+		AccountID:     "accID",
+		ProvisionerID: "provID",
+		Identifiers:   []Identifier{{Type: "dns", Value: "foo.internal"}},
+	}, OrderReadyEvent, "1234")
+
+	select {
+	case e := <-events:
+		assert.Equals(t, e.Type, OrderReadyEvent)
+		assert.Equals(t, e.OrderID, "oID")
+		assert.Equals(t, e.AccountID, "accID")
+		assert.Equals(t, e.ProvisionerID, "provID")
+		assert.Equals(t, e.SerialNumber, "1234")
+		assert.Equals(t, e.Identifiers, []Identifier{{Type: "dns", Value: "foo.internal"}})
+		assert.NotEquals(t, e.Nonce, "")
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+
+	// Without a webhook nothing is sent.
+	notifyApproval(nil, &DeferredOrder{OrderID: "oID"}, OrderReadyEvent, "")
+}
@@ -0,0 +1,212 @@
+package acme
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// typeCAA is the DNS type of the CAA records.
+const typeCAA dnsmessage.Type = 257
+
+// caaFlagCritical is the issuer critical flag of a CAA record.
+const caaFlagCritical = 128
+
+// CAARecord is a Certification Authority Authorization record as defined in
+// RFC 8659.
+type CAARecord struct {
+	Flags uint8
+	Tag   string
+	Value string
+}
+
+// caaClient is implemented by the clients that can look up the CAA records of
+// a DNS name.
+type caaClient interface {
+	lookupCAA(name string) ([]*CAARecord, error)
+}
+
+func (c *client) lookupCAA(name string) ([]*CAARecord, error) {
+	answers, err := dnsExchange(systemNameservers(), name, typeCAA)
+	if err != nil {
+		return nil, err
+	}
+	var records []*CAARecord
+	for _, a := range answers {
+		if a.Header.Type != typeCAA {
+			continue
+		}
+		body, ok := a.Body.(*dnsmessage.UnknownResource)
+		if !ok {
+			continue
+		}
+		r, err := parseCAARecord(body.Data)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// parseCAARecord parses the data of a CAA resource record.
+func parseCAARecord(data []byte) (*CAARecord, error) {
+	if len(data) < 2 || len(data) < 2+int(data[1]) || data[1] == 0 {
+		return nil, errors.New("error parsing CAA record: malformed data")
+	}
+	n := int(data[1])
+	return &CAARecord{
+		Flags: data[0],
+		Tag:   string(data[2 : 2+n]),
+		Value: string(data[2+n:]),
+	}, nil
+}
+
+// checkCAA verifies that the CAA records of the challenge identifier allow the
+// CA to issue certificates for it. The records are only checked if they are
+// enabled in the provisioner, and never for IP addresses. It returns the error
+// to store in the challenge, and if the challenge must be marked as invalid.
+func (ch *Challenge) checkCAA(ctx context.Context, db DB) (*Error, bool) {
+	prov, ok := ProvisionerFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	opts := prov.GetCAAOptions()
+	if opts == nil || net.ParseIP(ch.Value) != nil {
+		return nil, false
+	}
+
+	domain, wildcard := trimWildcard(ch.Value)
+	if !wildcard && ch.Type == DNS01 && ch.AuthorizationID != "" {
+		az, err := db.GetAuthorization(ctx, ch.AuthorizationID)
+		if err != nil {
+			return WrapErrorISE(err, "error retrieving authorization %s", ch.AuthorizationID), false
+		}
+		wildcard = az.Wildcard
+	}
+
+	vc := MustClientFromContext(ctx)
+	cc, ok := vc.(caaClient)
+	if !ok {
+		if opts.SoftFail {
+			return nil, false
+		}
+		return NewError(ErrorDNSType, "error looking up CAA records for %s: client does not support CAA lookups", domain), false
+	}
+	name, records, err := lookupRelevantCAA(cc, domain)
+	if err != nil {
+		if opts.SoftFail {
+			return nil, false
+		}
+		return WrapError(ErrorDNSType, err, "error looking up CAA records for %s", domain), false
+	}
+	if err := evaluateCAA(records, opts.IssuerDomainNames, wildcard, ch.Type); err != nil {
+		return NewError(ErrorCaaType, "CAA records for %s forbid the issuance for %s: %s", name, ch.Value, err), true
+	}
+	return nil, false
+}
+
+// lookupRelevantCAA returns the relevant CAA records of a domain, the records of
+// the domain or, if it has none, the ones of its closest ancestor. It returns
+// the name where the records were found.
+func lookupRelevantCAA(c caaClient, domain string) (string, []*CAARecord, error) {
+	name := strings.TrimSuffix(strings.ToLower(domain), ".")
+	for name != "" {
+		records, err := c.lookupCAA(name)
+		if err != nil {
+			return name, nil, err
+		}
+		if len(records) > 0 {
+			return name, records, nil
+		}
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[i+1:]
+	}
+	return domain, nil, nil
+}
+
+// evaluateCAA returns an error if the given CAA records do not allow any of the
+// issuer domain names to issue a certificate using the challenge type. The
+// issuewild records are used for wildcard names if there are any.
+func evaluateCAA(records []*CAARecord, issuers []string, wildcard bool, typ ChallengeType) error {
+	var issue, issueWild []*CAARecord
+	for _, r := range records {
+		switch strings.ToLower(r.Tag) {
+		case "issue":
+			issue = append(issue, r)
+		case "issuewild":
+			issueWild = append(issueWild, r)
+		case "iodef", "contactemail", "contactphone", "issuemail", "issuevmc":
+		default:
+			if r.Flags&caaFlagCritical != 0 {
+				return errors.Errorf("unknown critical property %q", r.Tag)
+			}
+		}
+	}
+
+	relevant := issue
+	if wildcard && len(issueWild) > 0 {
+		relevant = issueWild
+	}
+	// Records without issue properties do not restrict the issuance.
+	if len(relevant) == 0 {
+		return nil
+	}
+	for _, r := range relevant {
+		issuer, params := parseCAAIssueValue(r.Value)
+		if issuer == "" || !containsIssuer(issuers, issuer) {
+			continue
+		}
+		if methods, ok := params["validationmethods"]; ok && !containsMethod(methods, typ) {
+			continue
+		}
+		return nil
+	}
+	return errors.Errorf("issuers %q are not authorized", issuers)
+}
+
+// parseCAAIssueValue returns the issuer domain name and the parameters of the
+// value of an issue or issuewild property.
+func parseCAAIssueValue(value string) (string, map[string]string) {
+	parts := strings.Split(value, ";")
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		if kv := strings.SplitN(strings.TrimSpace(p), "=", 2); len(kv) == 2 {
+			params[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+		}
+	}
+	return strings.TrimSpace(parts[0]), params
+}
+
+func containsIssuer(issuers []string, issuer string) bool {
+	issuer = strings.TrimSuffix(issuer, ".")
+	for _, s := range issuers {
+		if strings.EqualFold(strings.TrimSuffix(s, "."), issuer) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsMethod(methods string, typ ChallengeType) bool {
+	for _, m := range strings.Split(methods, ",") {
+		if strings.EqualFold(strings.TrimSpace(m), string(typ)) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimWildcard removes the wildcard label of a name, and reports if it had.
+func trimWildcard(value string) (string, bool) {
+	if strings.HasPrefix(value, "*.") {
+		return strings.TrimPrefix(value, "*."), true
+	}
+	return value, false
+}
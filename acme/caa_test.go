@@ -0,0 +1,262 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/jose"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+type mockCAAClient struct {
+	mockClient
+	records map[string][]*CAARecord
+	err     error
+}
+
+func (m *mockCAAClient) lookupCAA(name string) ([]*CAARecord, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.records[name], nil
+}
+
+func Test_parseCAARecord(t *testing.T) {
+	r, err := parseCAARecord(append([]byte{128, 5}, []byte("issueca.example.net")...))
+	require.NoError(t, err)
+	assert.Equal(t, &CAARecord{Flags: 128, Tag: "issue", Value: "ca.example.net"}, r)
+
+	_, err = parseCAARecord([]byte{0})
+	assert.Error(t, err)
+	_, err = parseCAARecord([]byte{0, 0, 'a'})
+	assert.Error(t, err)
+	_, err = parseCAARecord([]byte{0, 5, 'i', 's'})
+	assert.Error(t, err)
+}
+
+func Test_evaluateCAA(t *testing.T) {
+	issuers := []string{"ca.example.net"}
+	tests := []struct {
+		name     string
+		records  []*CAARecord
+		wildcard bool
+		typ      ChallengeType
+		wantErr  bool
+	}{
+		{"no records", nil, false, HTTP01, false},
+		{"iodef only", []*CAARecord{{Tag: "iodef", Value: "mailto:security@example.com"}}, false, HTTP01, false},
+		{"issue", []*CAARecord{{Tag: "issue", Value: "other.example.org"}, {Tag: "issue", Value: "ca.example.net"}}, false, HTTP01, false},
+		{"issue case insensitive", []*CAARecord{{Tag: "Issue", Value: "CA.example.net."}}, false, HTTP01, false},
+		{"issue parameters", []*CAARecord{{Tag: "issue", Value: "ca.example.net; accounturi=https://ca.example.net/acme/account/1"}}, false, HTTP01, false},
+		{"issue other", []*CAARecord{{Tag: "issue", Value: "other.example.org"}}, false, HTTP01, true},
+		{"issue empty", []*CAARecord{{Tag: "issue", Value: ";"}}, false, HTTP01, true},
+		{"validation methods", []*CAARecord{{Tag: "issue", Value: "ca.example.net; validationmethods=dns-01,tls-alpn-01"}}, false, TLSALPN01, false},
+		{"validation methods other", []*CAARecord{{Tag: "issue", Value: "ca.example.net; validationmethods=dns-01"}}, false, HTTP01, true},
+		{"wildcard issue", []*CAARecord{{Tag: "issue", Value: "ca.example.net"}}, true, DNS01, false},
+		{"wildcard issuewild", []*CAARecord{{Tag: "issue", Value: "ca.example.net"}, {Tag: "issuewild", Value: ";"}}, true, DNS01, true},
+		{"wildcard issuewild ok", []*CAARecord{{Tag: "issue", Value: ";"}, {Tag: "issuewild", Value: "ca.example.net"}}, true, DNS01, false},
+		{"non wildcard issuewild", []*CAARecord{{Tag: "issue", Value: ";"}, {Tag: "issuewild", Value: "ca.example.net"}}, false, DNS01, true},
+		{"unknown", []*CAARecord{{Tag: "unknown", Value: "foo"}, {Tag: "issue", Value: "ca.example.net"}}, false, HTTP01, false},
+		{"unknown critical", []*CAARecord{{Flags: 128, Tag: "unknown", Value: "foo"}, {Tag: "issue", Value: "ca.example.net"}}, false, HTTP01, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := evaluateCAA(tt.records, issuers, tt.wildcard, tt.typ)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_lookupRelevantCAA(t *testing.T) {
+	records := []*CAARecord{{Tag: "issue", Value: "ca.example.net"}}
+	c := &mockCAAClient{records: map[string][]*CAARecord{
+		"example.com": records,
+	}}
+
+	name, got, err := lookupRelevantCAA(c, "www.sub.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", name)
+	assert.Equal(t, records, got)
+
+	_, got, err = lookupRelevantCAA(c, "example.org")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	_, _, err = lookupRelevantCAA(&mockCAAClient{err: errors.New("force")}, "example.com")
+	assert.Error(t, err)
+}
+
+func TestChallenge_Validate_caa(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	require.NoError(t, err)
+
+	newContext := func(opts *provisioner.ACMECAAOptions, c Client) context.Context {
+		ctx := NewProvisionerContext(context.Background(), &MockProvisioner{
+			MgetCAAOptions: func() *provisioner.ACMECAAOptions { return opts },
+		})
+		return NewClientContext(ctx, c)
+	}
+	forbidden := map[string][]*CAARecord{
+		"example.com": {{Tag: "issue", Value: "other.example.org"}},
+	}
+
+	t.Run("forbidden", func(t *testing.T) {
+		ch := &Challenge{Type: HTTP01, Value: "www.example.com", Token: "token", Status: StatusPending}
+		ctx := newContext(&provisioner.ACMECAAOptions{IssuerDomainNames: []string{"ca.example.net"}}, &mockCAAClient{
+			mockClient: mockClient{get: func(string) (*http.Response, error) {
+				t.Error("unexpected http-01 request")
+				return nil, errors.New("force")
+			}},
+			records: forbidden,
+		})
+		db := &MockDB{MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+			return nil
+		}}
+		require.NoError(t, ch.Validate(ctx, db, jwk, nil))
+		assert.Equal(t, StatusInvalid, ch.Status)
+		if assert.NotNil(t, ch.Error) {
+			assert.Equal(t, NewError(ErrorCaaType, "").Type, ch.Error.Type)
+		}
+	})
+
+	t.Run("wildcard authorization", func(t *testing.T) {
+		ch := &Challenge{Type: DNS01, Value: "example.com", AuthorizationID: "azID", Token: "token", Status: StatusPending}
+		ctx := newContext(&provisioner.ACMECAAOptions{IssuerDomainNames: []string{"ca.example.net"}}, &mockCAAClient{
+			records: map[string][]*CAARecord{
+				"example.com": {{Tag: "issue", Value: "ca.example.net"}, {Tag: "issuewild", Value: ";"}},
+			},
+		})
+		db := &MockDB{
+			MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+				assert.Equal(t, "azID", id)
+				return &Authorization{ID: id, Wildcard: true}, nil
+			},
+			MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+				return nil
+			},
+		}
+		require.NoError(t, ch.Validate(ctx, db, jwk, nil))
+		assert.Equal(t, StatusInvalid, ch.Status)
+		if assert.NotNil(t, ch.Error) {
+			assert.Equal(t, NewError(ErrorCaaType, "").Type, ch.Error.Type)
+		}
+	})
+
+	t.Run("lookup error", func(t *testing.T) {
+		ch := &Challenge{Type: TLSALPN01, Value: "example.com", Token: "token", Status: StatusPending}
+		ctx := newContext(&provisioner.ACMECAAOptions{IssuerDomainNames: []string{"ca.example.net"}}, &mockCAAClient{
+			err: errors.New("force"),
+		})
+		db := &MockDB{MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+			return nil
+		}}
+		require.NoError(t, ch.Validate(ctx, db, jwk, nil))
+		assert.Equal(t, StatusPending, ch.Status)
+		if assert.NotNil(t, ch.Error) {
+			assert.Equal(t, NewError(ErrorDNSType, "").Type, ch.Error.Type)
+		}
+	})
+
+	t.Run("soft fail", func(t *testing.T) {
+		ch := &Challenge{Type: HTTP01, Value: "example.com", Token: "token", Status: StatusPending}
+		var called bool
+		ctx := newContext(&provisioner.ACMECAAOptions{IssuerDomainNames: []string{"ca.example.net"}, SoftFail: true}, &mockCAAClient{
+			mockClient: mockClient{get: func(string) (*http.Response, error) {
+				called = true
+				return nil, errors.New("force")
+			}},
+			err: errors.New("force"),
+		})
+		db := &MockDB{MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+			return nil
+		}}
+		require.NoError(t, ch.Validate(ctx, db, jwk, nil))
+		assert.True(t, called)
+		if assert.NotNil(t, ch.Error) {
+			assert.Equal(t, NewError(ErrorConnectionType, "").Type, ch.Error.Type)
+		}
+	})
+
+	t.Run("ip address", func(t *testing.T) {
+		ch := &Challenge{Type: HTTP01, Value: "127.0.0.1", Token: "token", Status: StatusPending}
+		var called bool
+		ctx := newContext(&provisioner.ACMECAAOptions{IssuerDomainNames: []string{"ca.example.net"}}, &mockCAAClient{
+			mockClient: mockClient{get: func(string) (*http.Response, error) {
+				called = true
+				return nil, errors.New("force")
+			}},
+			err: errors.New("unexpected CAA lookup"),
+		})
+		db := &MockDB{MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+			return nil
+		}}
+		require.NoError(t, ch.Validate(ctx, db, jwk, nil))
+		assert.True(t, called)
+	})
+}
+
+func Test_dnsExchange(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	go func() {
+		b := make([]byte, 512)
+		n, addr, err := pc.ReadFrom(b)
+		if err != nil {
+			return
+		}
+		var query dnsmessage.Message
+		if err := query.Unpack(b[:n]); err != nil {
+			return
+		}
+		q := query.Questions[0]
+		resp := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+			Questions: query.Questions,
+			Answers: []dnsmessage.Resource{
+				{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: typeCAA, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &dnsmessage.UnknownResource{Type: typeCAA, Data: append([]byte{0, 5}, []byte("issueca.example.net")...)},
+				},
+			},
+		}
+		if b, err = resp.Pack(); err == nil {
+			pc.WriteTo(b, addr) //nolint:errcheck // test server
+		}
+	}()
+
+	answers, err := dnsExchange([]string{pc.LocalAddr().String()}, "example.com", typeCAA)
+	require.NoError(t, err)
+	require.Len(t, answers, 1)
+	body, ok := answers[0].Body.(*dnsmessage.UnknownResource)
+	require.True(t, ok)
+	r, err := parseCAARecord(body.Data)
+	require.NoError(t, err)
+	assert.Equal(t, &CAARecord{Tag: "issue", Value: "ca.example.net"}, r)
+}
+
+func Test_systemNameservers(t *testing.T) {
+	tmp := resolvConf
+	t.Cleanup(func() { resolvConf = tmp })
+
+	resolvConf = filepath.Join(t.TempDir(), "resolv.conf")
+	require.NoError(t, os.WriteFile(resolvConf, []byte("# comment\nsearch example.com\nnameserver 10.0.0.1\nnameserver fe80::1%eth0\nnameserver bad\n"), 0600))
+	assert.Equal(t, []string{"10.0.0.1:53", "[fe80::1%eth0]:53"}, systemNameservers())
+
+	resolvConf = filepath.Join(t.TempDir(), "missing.conf")
+	assert.Equal(t, []string{"127.0.0.1:53"}, systemNameservers())
+}
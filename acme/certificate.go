@@ -2,13 +2,27 @@ package acme
 
 import (
 	"crypto/x509"
+	"strconv"
+
+	"golang.org/x/crypto/ssh"
 )
 
-// Certificate options with which to create and store a cert object.
+// Certificate options with which to create and store a cert object. The
+// certificates of the orders of ssh-principal identifiers only have the SSH
+// certificate.
 type Certificate struct {
 	ID            string
 	AccountID     string
 	OrderID       string
 	Leaf          *x509.Certificate
 	Intermediates []*x509.Certificate
+	SSH           *ssh.Certificate
+}
+
+// SerialNumber returns the serial number of the certificate.
+func (c *Certificate) SerialNumber() string {
+	if c.SSH != nil {
+		return strconv.FormatUint(c.SSH.Serial, 10)
+	}
+	return c.Leaf.SerialNumber.String()
 }
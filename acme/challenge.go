@@ -36,6 +36,7 @@ import (
 	"go.step.sm/crypto/pemutil"
 	"go.step.sm/crypto/x509util"
 
+	"github.com/smallstep/certificates/attestation"
 	"github.com/smallstep/certificates/authority/provisioner"
 )
 
@@ -50,6 +51,12 @@ const (
 	TLSALPN01 ChallengeType = "tls-alpn-01"
 	// DEVICEATTEST01 is the device-attest-01 ACME challenge type
 	DEVICEATTEST01 ChallengeType = "device-attest-01"
+	// EMAILREPLY00 is the email-reply-00 ACME challenge type defined in RFC
+	// 8823
+	EMAILREPLY00 ChallengeType = "email-reply-00"
+	// OIDC01 is the oidc-01 ACME challenge type used to validate the
+	// ssh-principal identifiers
+	OIDC01 ChallengeType = "oidc-01"
 )
 
 var (
@@ -66,16 +73,41 @@ var (
 
 // Challenge represents an ACME response Challenge type.
 type Challenge struct {
-	ID              string        `json:"-"`
-	AccountID       string        `json:"-"`
-	AuthorizationID string        `json:"-"`
-	Value           string        `json:"-"`
-	Type            ChallengeType `json:"type"`
-	Status          Status        `json:"status"`
-	Token           string        `json:"token"`
-	ValidatedAt     string        `json:"validated,omitempty"`
-	URL             string        `json:"url"`
-	Error           *Error        `json:"error,omitempty"`
+	ID               string              `json:"-"`
+	AccountID        string              `json:"-"`
+	AuthorizationID  string              `json:"-"`
+	Value            string              `json:"-"`
+	Type             ChallengeType       `json:"type"`
+	Status           Status              `json:"status"`
+	Token            string              `json:"token"`
+	From             string              `json:"from,omitempty"`
+	TokenPart1       string              `json:"-"`
+	ValidatedAt      string              `json:"validated,omitempty"`
+	URL              string              `json:"url"`
+	Error            *Error              `json:"error,omitempty"`
+	ValidationRecord []*ValidationRecord `json:"validationRecord,omitempty"`
+}
+
+// ValidationRecord is what the server observed during the last validation
+// attempt of a challenge. RFC 8555 allows challenge objects to include
+// additional fields, the names are the same ones used by other ACME servers.
+type ValidationRecord struct {
+	// URL is the url of the last http-01 request, after following redirects.
+	URL string `json:"url,omitempty"`
+	// Hostname is the name used to connect to the server, or the name used in
+	// the TXT lookup for dns-01 challenges.
+	Hostname string `json:"hostname"`
+	// Port is the port used to connect to the server.
+	Port string `json:"port,omitempty"`
+	// AddressesResolved are the IP addresses the hostname resolved to.
+	AddressesResolved []string `json:"addressesResolved,omitempty"`
+	// AddressUsed is the IP address used to connect to the server.
+	AddressUsed string `json:"addressUsed,omitempty"`
+	// CNAMEChain are the CNAME records followed from the hostname in dns-01
+	// challenges.
+	CNAMEChain []string `json:"cnameChain,omitempty"`
+	// TXTRecords are the TXT records found in dns-01 challenges.
+	TXTRecords []string `json:"txtRecords,omitempty"`
 }
 
 // ToLog enables response logging.
@@ -95,6 +127,35 @@ func (ch *Challenge) Validate(ctx context.Context, db DB, jwk *jose.JSONWebKey,
 	if ch.Status != StatusPending {
 		return nil
 	}
+	if err := ch.validate(ctx, db, jwk, payload); err != nil {
+		return err
+	}
+	if ch.Error != nil {
+		ch.recordFailedValidation(ctx, db)
+	}
+	return nil
+}
+
+// validate performs the validation of the challenge, it is also used by the
+// validations in the background, where the challenge is processing. Each
+// attempt is reported to the meter in the context.
+func (ch *Challenge) validate(ctx context.Context, db DB, jwk *jose.JSONWebKey, payload []byte) (err error) {
+	start := time.Now()
+	defer func() {
+		MeterFromContext(ctx).ChallengeValidated(ctx, ch, time.Since(start), err)
+	}()
+
+	switch ch.Type {
+	case HTTP01, DNS01, TLSALPN01:
+		if caaErr, invalid := ch.checkCAA(ctx, db); caaErr != nil {
+			return storeError(ctx, db, ch, invalid, caaErr)
+		}
+		if p, ok := ProvisionerFromContext(ctx); ok {
+			if d := p.GetChallengeDelegation(ch.Value); d != nil {
+				return delegatedValidate(ctx, ch, db, jwk, p, d)
+			}
+		}
+	}
 	switch ch.Type {
 	case HTTP01:
 		return http01Validate(ctx, ch, db, jwk)
@@ -104,6 +165,10 @@ func (ch *Challenge) Validate(ctx context.Context, db DB, jwk *jose.JSONWebKey,
 		return tlsalpn01Validate(ctx, ch, db, jwk)
 	case DEVICEATTEST01:
 		return deviceAttest01Validate(ctx, ch, db, jwk, payload)
+	case EMAILREPLY00:
+		return emailReply00Validate(ctx, ch, db)
+	case OIDC01:
+		return oidc01Validate(ctx, ch, db, jwk, payload)
 	default:
 		return NewErrorISE("unexpected challenge type '%s'", ch.Type)
 	}
@@ -118,13 +183,30 @@ func http01Validate(ctx context.Context, ch *Challenge, db DB, jwk *jose.JSONWeb
 		u.Host += ":" + strconv.Itoa(InsecurePortHTTP01)
 	}
 
+	rec := &ValidationRecord{
+		URL:      u.String(),
+		Hostname: ch.Value,
+		Port:     urlPort(u),
+	}
+	ch.ValidationRecord = []*ValidationRecord{rec}
+
 	vc := MustClientFromContext(ctx)
-	resp, err := vc.Get(u.String())
+	if prov, ok := ProvisionerFromContext(ctx); ok {
+		if hc, ok := vc.(http01Client); ok && prov.GetHTTP01Options() != nil {
+			vc = hc.withHTTP01Options(prov.GetHTTP01Options())
+		}
+	}
+	resp, err := httpGet(vc, u.String(), rec)
 	if err != nil {
 		return storeError(ctx, db, ch, false, WrapError(ErrorConnectionType, err,
 			"error doing http GET for url %s", u))
 	}
 	defer resp.Body.Close()
+	if resp.Request != nil && resp.Request.URL != nil {
+		rec.URL = resp.Request.URL.String()
+		rec.Hostname = resp.Request.URL.Hostname()
+		rec.Port = urlPort(resp.Request.URL)
+	}
 	if resp.StatusCode >= 400 {
 		return storeError(ctx, db, ch, false, NewError(ErrorConnectionType,
 			"error doing http GET for url %s with status code %d", u, resp.StatusCode))
@@ -168,6 +250,18 @@ func http01ChallengeHost(value string) string {
 	return value
 }
 
+// urlPort returns the port of the given url, or the default port of its
+// scheme.
+func urlPort(u *url.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+	if u.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
 func tlsAlert(err error) uint8 {
 	var opErr *net.OpError
 	if errors.As(err, &opErr) {
@@ -190,14 +284,18 @@ func tlsalpn01Validate(ctx context.Context, ch *Challenge, db DB, jwk *jose.JSON
 		InsecureSkipVerify: true, //nolint:gosec // we expect a self-signed challenge certificate
 	}
 
-	var hostPort string
-
 	// Allow to change TLS port for testing purposes.
-	if port := InsecurePortTLSALPN01; port == 0 {
-		hostPort = net.JoinHostPort(ch.Value, "443")
-	} else {
-		hostPort = net.JoinHostPort(ch.Value, strconv.Itoa(port))
+	port := "443"
+	if InsecurePortTLSALPN01 != 0 {
+		port = strconv.Itoa(InsecurePortTLSALPN01)
+	}
+	hostPort := net.JoinHostPort(ch.Value, port)
+
+	rec := &ValidationRecord{
+		Hostname: ch.Value,
+		Port:     port,
 	}
+	ch.ValidationRecord = []*ValidationRecord{rec}
 
 	vc := MustClientFromContext(ctx)
 	conn, err := vc.TLSDial("tcp", hostPort, config)
@@ -214,6 +312,7 @@ func tlsalpn01Validate(ctx context.Context, ch *Challenge, db DB, jwk *jose.JSON
 			"error doing TLS dial for %s", hostPort))
 	}
 	defer conn.Close()
+	rec.AddressUsed = addrIP(conn.RemoteAddr())
 
 	cs := conn.ConnectionState()
 	certs := cs.PeerCertificates
@@ -306,8 +405,37 @@ func dns01Validate(ctx context.Context, ch *Challenge, db DB, jwk *jose.JSONWebK
 	// Instead perform txt lookup for _acme-challenge.example.com
 	domain := strings.TrimPrefix(ch.Value, "*.")
 
+	rec := &ValidationRecord{
+		Hostname: "_acme-challenge." + domain,
+	}
+	ch.ValidationRecord = []*ValidationRecord{rec}
+
 	vc := MustClientFromContext(ctx)
-	txtRecords, err := vc.LookupTxt("_acme-challenge." + domain)
+
+	// Follow the delegation of the challenge to a validation zone.
+	name := rec.Hostname
+	var opts *provisioner.ACMEDNS01Options
+	if prov, ok := ProvisionerFromContext(ctx); ok {
+		opts = prov.GetDNS01Options()
+	}
+	if rc, ok := vc.(resolversClient); ok && len(opts.GetResolvers()) > 0 {
+		vc = rc.withResolvers(opts)
+	}
+	if opts.FollowsCNAME(domain) {
+		target, chain, err := followCNAME(vc, name, opts.GetMaxCNAMEHops())
+		rec.CNAMEChain = chain
+		if err != nil {
+			return storeError(ctx, db, ch, false, WrapError(ErrorDNSType, err,
+				"error following CNAME records for %s", name))
+		}
+		name = target
+	}
+	if zone := opts.GetDelegationZone(domain); zone != "" && name != zone && !strings.HasSuffix(name, "."+zone) {
+		return storeError(ctx, db, ch, false, NewError(ErrorRejectedIdentifierType,
+			"%s must be delegated to the zone %s, but it resolves to %s", rec.Hostname, zone, name))
+	}
+
+	txtRecords, err := vc.LookupTxt(name)
 	if err != nil {
 		return storeError(ctx, db, ch, false, WrapError(ErrorDNSType, err,
 			"error looking up TXT records for domain %s", domain))
@@ -317,6 +445,7 @@ func dns01Validate(ctx context.Context, ch *Challenge, db DB, jwk *jose.JSONWebK
 	if err != nil {
 		return err
 	}
+	rec.TXTRecords = txtRecords
 	h := sha256.Sum256([]byte(expectedKeyAuth))
 	expected := base64.RawURLEncoding.EncodeToString(h[:])
 	var found bool
@@ -342,6 +471,42 @@ func dns01Validate(ctx context.Context, ch *Challenge, db DB, jwk *jose.JSONWebK
 	return nil
 }
 
+// followCNAME follows the CNAME records of the given name, up to the given
+// number of hops. It returns the last name and the names followed.
+func followCNAME(vc Client, name string, maxHops int) (string, []string, error) {
+	cc, ok := vc.(cnameClient)
+	if !ok {
+		return "", nil, errors.New("client does not support CNAME lookups")
+	}
+
+	var chain []string
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	seen := map[string]bool{name: true}
+	for {
+		target, err := cc.lookupCNAME(name)
+		if err != nil {
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+				return name, chain, nil
+			}
+			return "", chain, err
+		}
+		target = strings.TrimSuffix(strings.ToLower(target), ".")
+		if target == "" || target == name {
+			return name, chain, nil
+		}
+		if seen[target] {
+			return "", chain, fmt.Errorf("CNAME loop detected at %s", target)
+		}
+		if len(chain) >= maxHops {
+			return "", chain, fmt.Errorf("too many CNAME records, the maximum is %d", maxHops)
+		}
+		seen[target] = true
+		chain = append(chain, target)
+		name = target
+	}
+}
+
 type payloadType struct {
 	AttObj string `json:"attObj"`
 	Error  string `json:"error"`
@@ -658,21 +823,14 @@ func doTPMAttestationFormat(_ context.Context, prov Provisioner, ch *Challenge,
 		return nil, NewDetailedError(ErrorBadAttestationStatementType, "invalid alg %d in attestation statement", alg)
 	}
 
-	// set the hash algorithm to use to SHA256
-	hash := crypto.SHA256
-
 	// recreate the generated key certification parameter values and verify
 	// the attested key using the public key of the AK.
-	certificationParameters := &attest.CertificationParameters{
+	certificationParameters := attest.CertificationParameters{
 		Public:            pubArea,  // the public key that was attested
 		CreateAttestation: certInfo, // the attested properties of the key
 		CreateSignature:   sig,      // signature over the attested properties
 	}
-	verifyOpts := attest.VerifyOpts{
-		Public: akCert.PublicKey, // public key of the AK that attested the key
-		Hash:   hash,
-	}
-	if err = certificationParameters.Verify(verifyOpts); err != nil {
+	if _, err = attestation.VerifyKeyCertification(akCert.PublicKey, certificationParameters); err != nil {
 		return nil, WrapDetailedError(ErrorBadAttestationStatementType, err, "invalid certification parameters")
 	}
 
@@ -801,22 +959,7 @@ func validateAKCertificateExtendedKeyUsage(c *x509.Certificate) error {
 	return nil
 }
 
-// Apple Enterprise Attestation Root CA from
-// https://www.apple.com/certificateauthority/private/
-const appleEnterpriseAttestationRootCA = `-----BEGIN CERTIFICATE-----
-MIICJDCCAamgAwIBAgIUQsDCuyxyfFxeq/bxpm8frF15hzcwCgYIKoZIzj0EAwMw
-UTEtMCsGA1UEAwwkQXBwbGUgRW50ZXJwcmlzZSBBdHRlc3RhdGlvbiBSb290IENB
-MRMwEQYDVQQKDApBcHBsZSBJbmMuMQswCQYDVQQGEwJVUzAeFw0yMjAyMTYxOTAx
-MjRaFw00NzAyMjAwMDAwMDBaMFExLTArBgNVBAMMJEFwcGxlIEVudGVycHJpc2Ug
-QXR0ZXN0YXRpb24gUm9vdCBDQTETMBEGA1UECgwKQXBwbGUgSW5jLjELMAkGA1UE
-BhMCVVMwdjAQBgcqhkjOPQIBBgUrgQQAIgNiAAT6Jigq+Ps9Q4CoT8t8q+UnOe2p
-oT9nRaUfGhBTbgvqSGXPjVkbYlIWYO+1zPk2Sz9hQ5ozzmLrPmTBgEWRcHjA2/y7
-7GEicps9wn2tj+G89l3INNDKETdxSPPIZpPj8VmjQjBAMA8GA1UdEwEB/wQFMAMB
-Af8wHQYDVR0OBBYEFPNqTQGd8muBpV5du+UIbVbi+d66MA4GA1UdDwEB/wQEAwIB
-BjAKBggqhkjOPQQDAwNpADBmAjEA1xpWmTLSpr1VH4f8Ypk8f3jMUKYz4QPG8mL5
-8m9sX/b2+eXpTv2pH4RZgJjucnbcAjEA4ZSB6S45FlPuS/u4pTnzoz632rA+xW/T
-ZwFEh9bhKjJ+5VQ9/Do1os0u3LEkgN/r
------END CERTIFICATE-----`
+const appleEnterpriseAttestationRootCA = attestation.AppleEnterpriseAttestationRootCA
 
 var (
 	oidAppleSerialNumber                    = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 8, 9, 1}
@@ -907,27 +1050,7 @@ func doAppleAttestationFormat(_ context.Context, prov Provisioner, _ *Challenge,
 	return data, nil
 }
 
-// Yubico PIV Root CA Serial 263751
-// https://developers.yubico.com/PIV/Introduction/piv-attestation-ca.pem
-const yubicoPIVRootCA = `-----BEGIN CERTIFICATE-----
-MIIDFzCCAf+gAwIBAgIDBAZHMA0GCSqGSIb3DQEBCwUAMCsxKTAnBgNVBAMMIFl1
-YmljbyBQSVYgUm9vdCBDQSBTZXJpYWwgMjYzNzUxMCAXDTE2MDMxNDAwMDAwMFoY
-DzIwNTIwNDE3MDAwMDAwWjArMSkwJwYDVQQDDCBZdWJpY28gUElWIFJvb3QgQ0Eg
-U2VyaWFsIDI2Mzc1MTCCASIwDQYJKoZIhvcNAQEBBQADggEPADCCAQoCggEBAMN2
-cMTNR6YCdcTFRxuPy31PabRn5m6pJ+nSE0HRWpoaM8fc8wHC+Tmb98jmNvhWNE2E
-ilU85uYKfEFP9d6Q2GmytqBnxZsAa3KqZiCCx2LwQ4iYEOb1llgotVr/whEpdVOq
-joU0P5e1j1y7OfwOvky/+AXIN/9Xp0VFlYRk2tQ9GcdYKDmqU+db9iKwpAzid4oH
-BVLIhmD3pvkWaRA2H3DA9t7H/HNq5v3OiO1jyLZeKqZoMbPObrxqDg+9fOdShzgf
-wCqgT3XVmTeiwvBSTctyi9mHQfYd2DwkaqxRnLbNVyK9zl+DzjSGp9IhVPiVtGet
-X02dxhQnGS7K6BO0Qe8CAwEAAaNCMEAwHQYDVR0OBBYEFMpfyvLEojGc6SJf8ez0
-1d8Cv4O/MA8GA1UdEwQIMAYBAf8CAQEwDgYDVR0PAQH/BAQDAgEGMA0GCSqGSIb3
-DQEBCwUAA4IBAQBc7Ih8Bc1fkC+FyN1fhjWioBCMr3vjneh7MLbA6kSoyWF70N3s
-XhbXvT4eRh0hvxqvMZNjPU/VlRn6gLVtoEikDLrYFXN6Hh6Wmyy1GTnspnOvMvz2
-lLKuym9KYdYLDgnj3BeAvzIhVzzYSeU77/Cupofj093OuAswW0jYvXsGTyix6B3d
-bW5yWvyS9zNXaqGaUmP3U9/b6DlHdDogMLu3VLpBB9bm5bjaKWWJYgWltCVgUbFq
-Fqyi4+JE014cSgR57Jcu3dZiehB6UtAPgad9L5cNvua/IWRmm+ANy3O2LH++Pyl8
-SREzU8onbBsjMg9QDiSf5oJLKvd/Ren+zGY7
------END CERTIFICATE-----`
+const yubicoPIVRootCA = attestation.YubicoPIVRootCA
 
 // Serial number of the YubiKey, encoded as an integer.
 // https://developers.yubico.com/PIV/Introduction/PIV_attestation.html
@@ -2796,6 +2796,164 @@ func Test_http01ChallengeHost(t *testing.T) {
 	}
 }
 
+func TestChallenge_Validate_validationRecord(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	require.NoError(t, err)
+	keyAuth, err := KeyAuthorization("token", jwk)
+	require.NoError(t, err)
+	db := &MockDB{
+		MockUpdateChallenge: func(ctx context.Context, ch *Challenge) error {
+			return nil
+		},
+	}
+
+	t.Run("http-01", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/.well-known/acme-challenge/token" {
+				http.Redirect(w, r, "/redirected", http.StatusFound)
+				return
+			}
+			w.Write([]byte(keyAuth))
+		}))
+		defer srv.Close()
+		_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+		require.NoError(t, err)
+		InsecurePortHTTP01, err = strconv.Atoi(port)
+		require.NoError(t, err)
+		defer func() { InsecurePortHTTP01 = 0 }()
+
+		ch := &Challenge{Type: HTTP01, Status: StatusPending, Token: "token", Value: "127.0.0.1"}
+		ctx := NewClientContext(context.Background(), NewClient())
+		require.NoError(t, ch.Validate(ctx, db, jwk, nil))
+		assert.Equal(t, StatusValid, ch.Status)
+		assert.Equal(t, []*ValidationRecord{{
+			URL:         "http://127.0.0.1:" + port + "/redirected",
+			Hostname:    "127.0.0.1",
+			Port:        port,
+			AddressUsed: "127.0.0.1",
+		}}, ch.ValidationRecord)
+	})
+
+	t.Run("dns-01", func(t *testing.T) {
+		h := sha256.Sum256([]byte(keyAuth))
+		records := []string{"foo", base64.RawURLEncoding.EncodeToString(h[:])}
+		ch := &Challenge{Type: DNS01, Status: StatusPending, Token: "token", Value: "*.example.com"}
+		ctx := NewClientContext(context.Background(), &mockClient{
+			lookupTxt: func(name string) ([]string, error) {
+				return records, nil
+			},
+		})
+		require.NoError(t, ch.Validate(ctx, db, jwk, nil))
+		assert.Equal(t, StatusValid, ch.Status)
+		assert.Equal(t, []*ValidationRecord{{
+			Hostname:   "_acme-challenge.example.com",
+			TXTRecords: records,
+		}}, ch.ValidationRecord)
+	})
+
+	t.Run("tls-alpn-01 connection error", func(t *testing.T) {
+		ch := &Challenge{Type: TLSALPN01, Status: StatusPending, Token: "token", Value: "example.com"}
+		ctx := NewClientContext(context.Background(), &mockClient{
+			tlsDial: func(network, addr string, config *tls.Config) (*tls.Conn, error) {
+				return nil, errors.New("force")
+			},
+		})
+		require.NoError(t, ch.Validate(ctx, db, jwk, nil))
+		assert.Equal(t, StatusPending, ch.Status)
+		assert.Equal(t, []*ValidationRecord{{
+			Hostname: "example.com",
+			Port:     "443",
+		}}, ch.ValidationRecord)
+	})
+}
+
+type mockCNAMEClient struct {
+	mockClient
+	cnames map[string]string
+}
+
+func (m *mockCNAMEClient) lookupCNAME(name string) (string, error) {
+	if target, ok := m.cnames[name]; ok {
+		return target, nil
+	}
+	return "", &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func TestDNS01Validate_cname(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	require.NoError(t, err)
+	keyAuth, err := KeyAuthorization("token", jwk)
+	require.NoError(t, err)
+	h := sha256.Sum256([]byte(keyAuth))
+	txt := base64.RawURLEncoding.EncodeToString(h[:])
+
+	newClient := func(cnames map[string]string) *mockCNAMEClient {
+		return &mockCNAMEClient{
+			mockClient: mockClient{
+				lookupTxt: func(name string) ([]string, error) {
+					if name == "www.validation.example.net" {
+						return []string{txt}, nil
+					}
+					return nil, nil
+				},
+			},
+			cnames: cnames,
+		}
+	}
+	db := &MockDB{
+		MockUpdateChallenge: func(ctx context.Context, ch *Challenge) error {
+			return nil
+		},
+	}
+	delegated := map[string]string{
+		"_acme-challenge.www.example.com":       "_acme-challenge.www.other.example.org.",
+		"_acme-challenge.www.other.example.org": "www.validation.example.net.",
+	}
+
+	tests := []struct {
+		name       string
+		opts       *provisioner.ACMEDNS01Options
+		cnames     map[string]string
+		wantStatus Status
+		wantChain  []string
+		wantErr    string
+	}{
+		{"ok follow", &provisioner.ACMEDNS01Options{FollowCNAME: true}, delegated, StatusValid,
+			[]string{"_acme-challenge.www.other.example.org", "www.validation.example.net"}, ""},
+		{"ok delegation", &provisioner.ACMEDNS01Options{Delegations: []provisioner.ACMEDNS01Delegation{{Domain: "example.com", Zone: "validation.example.net"}}}, delegated, StatusValid,
+			[]string{"_acme-challenge.www.other.example.org", "www.validation.example.net"}, ""},
+		{"fail no follow", nil, delegated, StatusPending, nil, "keyAuthorization does not match"},
+		{"fail hops", &provisioner.ACMEDNS01Options{FollowCNAME: true, MaxCNAMEHops: 1}, delegated, StatusPending,
+			[]string{"_acme-challenge.www.other.example.org"}, "too many CNAME records, the maximum is 1"},
+		{"fail loop", &provisioner.ACMEDNS01Options{FollowCNAME: true}, map[string]string{
+			"_acme-challenge.www.example.com": "foo.example.net",
+			"foo.example.net":                 "_acme-challenge.www.example.com",
+		}, StatusPending, []string{"foo.example.net"}, "CNAME loop detected at _acme-challenge.www.example.com"},
+		{"fail not delegated", &provisioner.ACMEDNS01Options{Delegations: []provisioner.ACMEDNS01Delegation{{Domain: "www.example.com", Zone: "acme.example.net"}}}, delegated, StatusPending,
+			[]string{"_acme-challenge.www.other.example.org", "www.validation.example.net"}, "must be delegated to the zone acme.example.net"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			ctx := NewClientContext(context.Background(), newClient(tt.cnames))
+			ctx = NewProvisionerContext(ctx, &MockProvisioner{
+				MgetDNS01Options: func() *provisioner.ACMEDNS01Options { return opts },
+			})
+			ch := &Challenge{Type: DNS01, Status: StatusPending, Token: "token", Value: "www.example.com"}
+			require.NoError(t, ch.Validate(ctx, db, jwk, nil))
+			assert.Equal(t, tt.wantStatus, ch.Status)
+			require.Len(t, ch.ValidationRecord, 1)
+			assert.Equal(t, "_acme-challenge.www.example.com", ch.ValidationRecord[0].Hostname)
+			assert.Equal(t, tt.wantChain, ch.ValidationRecord[0].CNAMEChain)
+			if tt.wantErr == "" {
+				assert.Nil(t, ch.Error)
+			} else if assert.NotNil(t, ch.Error) {
+				assert.Contains(t, ch.Error.Err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
 func Test_doAppleAttestationFormat(t *testing.T) {
 	ctx := context.Background()
 	ca, err := minica.New()
@@ -5,7 +5,11 @@ import (
 	"crypto/tls"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"sync"
 	"time"
+
+	"github.com/smallstep/certificates/authority/provisioner"
 )
 
 // Client is the interface used to verify ACME challenges.
@@ -21,6 +25,51 @@ type Client interface {
 	TLSDial(network, addr string, config *tls.Config) (*tls.Conn, error)
 }
 
+// recordingClient is implemented by the clients that can report the addresses
+// resolved and used by HTTP requests.
+type recordingClient interface {
+	getWithRecord(url string, rec *ValidationRecord) (*http.Response, error)
+}
+
+// cnameClient is implemented by the clients that can look up the CNAME
+// records of a DNS name.
+type cnameClient interface {
+	lookupCNAME(name string) (string, error)
+}
+
+// resolversClient is implemented by the clients that can look up the DNS
+// records using the resolvers of the dns-01 options instead of the system
+// resolver.
+type resolversClient interface {
+	withResolvers(opts *provisioner.ACMEDNS01Options) Client
+}
+
+// httpGet issues an HTTP GET to the specified URL, adding the resolved and
+// used addresses to the given validation record if the client supports it.
+func httpGet(c Client, url string, rec *ValidationRecord) (*http.Response, error) {
+	if rc, ok := c.(recordingClient); ok {
+		return rc.getWithRecord(url, rec)
+	}
+	return c.Get(url)
+}
+
+// addrIP returns the IP address of the given network address, or an empty
+// string if it does not have one.
+func addrIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP.String()
+	case nil:
+		return ""
+	default:
+		host, _, err := net.SplitHostPort(a.String())
+		if err != nil || net.ParseIP(host) == nil {
+			return ""
+		}
+		return host
+	}
+}
+
 type clientKey struct{}
 
 // NewClientContext adds the given client to the context.
@@ -71,10 +120,65 @@ func (c *client) Get(url string) (*http.Response, error) {
 	return c.http.Get(url)
 }
 
+func (c *client) getWithRecord(url string, rec *ValidationRecord) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	// The callbacks and the request can run in different goroutines.
+	var mu sync.Mutex
+	var resolved []string
+	var used string
+	trace := &httptrace.ClientTrace{
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			resolved = resolved[:0]
+			for _, a := range info.Addrs {
+				resolved = append(resolved, a.IP.String())
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			used = addrIP(info.Conn.RemoteAddr())
+		},
+	}
+	resp, err := c.http.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+
+	mu.Lock()
+	if len(resolved) > 0 {
+		rec.AddressesResolved = append([]string(nil), resolved...)
+	}
+	rec.AddressUsed = used
+	mu.Unlock()
+	return resp, err
+}
+
 func (c *client) LookupTxt(name string) ([]string, error) {
 	return net.LookupTXT(name)
 }
 
+func (c *client) lookupCNAME(name string) (string, error) {
+	return net.LookupCNAME(name)
+}
+
+func (c *client) withResolvers(opts *provisioner.ACMEDNS01Options) Client {
+	return &resolverClient{
+		Client:    c,
+		resolvers: newDNSResolvers(opts),
+		quorum:    opts.GetQuorum(),
+	}
+}
+
+func (c *client) withHTTP01Options(opts *provisioner.ACMEHTTP01Options) Client {
+	return &client{
+		http:   newHTTP01Client(opts),
+		dialer: c.dialer,
+	}
+}
+
 func (c *client) TLSDial(network, addr string, config *tls.Config) (*tls.Conn, error) {
 	return tls.DialWithDialer(c.dialer, network, addr, config)
 }
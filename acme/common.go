@@ -5,6 +5,8 @@ import (
 	"crypto/x509"
 	"time"
 
+	"golang.org/x/crypto/ssh"
+
 	"github.com/smallstep/certificates/authority"
 	"github.com/smallstep/certificates/authority/provisioner"
 )
@@ -21,11 +23,13 @@ var clock Clock
 
 // CertificateAuthority is the interface implemented by a CA authority.
 type CertificateAuthority interface {
-	Sign(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
+	SignWithContext(ctx context.Context, cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
+	SignSSH(ctx context.Context, key ssh.PublicKey, opts provisioner.SignSSHOptions, signOpts ...provisioner.SignOption) (*ssh.Certificate, error)
 	AreSANsAllowed(ctx context.Context, sans []string) error
 	IsRevoked(sn string) (bool, error)
 	Revoke(context.Context, *authority.RevokeOptions) error
 	LoadProvisionerByName(string) (provisioner.Interface, error)
+	GetAlternateChains(crt *x509.Certificate) [][]*x509.Certificate
 }
 
 // NewContext adds the given acme components to the context.
@@ -70,10 +74,23 @@ func PrerequisitesCheckerFromContext(ctx context.Context) (PrerequisitesChecker,
 type Provisioner interface {
 	AuthorizeOrderIdentifier(ctx context.Context, identifier provisioner.ACMEIdentifier) error
 	AuthorizeSign(ctx context.Context, token string) ([]provisioner.SignOption, error)
+	AuthorizeSSHSign(ctx context.Context, token string) ([]provisioner.SignOption, error)
 	AuthorizeRevoke(ctx context.Context, token string) error
 	IsChallengeEnabled(ctx context.Context, challenge provisioner.ACMEChallenge) bool
 	IsAttestationFormatEnabled(ctx context.Context, format provisioner.ACMEAttestationFormat) bool
 	GetAttestationRoots() (*x509.CertPool, bool)
+	GetDNS01Options() *provisioner.ACMEDNS01Options
+	GetHTTP01Options() *provisioner.ACMEHTTP01Options
+	GetCAAOptions() *provisioner.ACMECAAOptions
+	GetValidationOptions() *provisioner.ACMEValidationOptions
+	GetApprovalOptions() *provisioner.ACMEApprovalOptions
+	GetAutoRenewalOptions() *provisioner.ACMEAutoRenewalOptions
+	GetRateLimitOptions() *provisioner.ACMERateLimitOptions
+	GetChallengeDelegation(value string) *provisioner.ACMEChallengeDelegation
+	GetEmailReplyOptions() *provisioner.ACMEEmailReplyOptions
+	GetOIDCOptions() *provisioner.ACMEOIDCOptions
+	RequireClientCertificate() bool
+	VerifyClientCertificate(chain []*x509.Certificate) error
 	GetID() string
 	GetName() string
 	DefaultTLSCertDuration() time.Duration
@@ -111,10 +128,23 @@ type MockProvisioner struct {
 	MgetName                  func() string
 	MauthorizeOrderIdentifier func(ctx context.Context, identifier provisioner.ACMEIdentifier) error
 	MauthorizeSign            func(ctx context.Context, ott string) ([]provisioner.SignOption, error)
+	MauthorizeSSHSign         func(ctx context.Context, ott string) ([]provisioner.SignOption, error)
 	MauthorizeRevoke          func(ctx context.Context, token string) error
 	MisChallengeEnabled       func(ctx context.Context, challenge provisioner.ACMEChallenge) bool
 	MisAttFormatEnabled       func(ctx context.Context, format provisioner.ACMEAttestationFormat) bool
 	MgetAttestationRoots      func() (*x509.CertPool, bool)
+	MgetDNS01Options          func() *provisioner.ACMEDNS01Options
+	MgetHTTP01Options         func() *provisioner.ACMEHTTP01Options
+	MgetCAAOptions            func() *provisioner.ACMECAAOptions
+	MgetValidationOptions     func() *provisioner.ACMEValidationOptions
+	MgetApprovalOptions       func() *provisioner.ACMEApprovalOptions
+	MgetAutoRenewalOptions    func() *provisioner.ACMEAutoRenewalOptions
+	MgetRateLimitOptions      func() *provisioner.ACMERateLimitOptions
+	MgetChallengeDelegation   func(value string) *provisioner.ACMEChallengeDelegation
+	MgetEmailReplyOptions     func() *provisioner.ACMEEmailReplyOptions
+	MgetOIDCOptions           func() *provisioner.ACMEOIDCOptions
+	MrequireClientCert        func() bool
+	MverifyClientCert         func(chain []*x509.Certificate) error
 	MdefaultTLSCertDuration   func() time.Duration
 	MgetOptions               func() *provisioner.Options
 }
@@ -143,6 +173,14 @@ func (m *MockProvisioner) AuthorizeSign(ctx context.Context, ott string) ([]prov
 	return m.Mret1.([]provisioner.SignOption), m.Merr
 }
 
+// AuthorizeSSHSign mock
+func (m *MockProvisioner) AuthorizeSSHSign(ctx context.Context, ott string) ([]provisioner.SignOption, error) {
+	if m.MauthorizeSSHSign != nil {
+		return m.MauthorizeSSHSign(ctx, ott)
+	}
+	return m.Mret1.([]provisioner.SignOption), m.Merr
+}
+
 // AuthorizeRevoke mock
 func (m *MockProvisioner) AuthorizeRevoke(ctx context.Context, token string) error {
 	if m.MauthorizeRevoke != nil {
@@ -174,6 +212,102 @@ func (m *MockProvisioner) GetAttestationRoots() (*x509.CertPool, bool) {
 	return m.Mret1.(*x509.CertPool), m.Mret1 != nil
 }
 
+// GetDNS01Options mock
+func (m *MockProvisioner) GetDNS01Options() *provisioner.ACMEDNS01Options {
+	if m.MgetDNS01Options != nil {
+		return m.MgetDNS01Options()
+	}
+	return nil
+}
+
+// GetHTTP01Options mock
+func (m *MockProvisioner) GetHTTP01Options() *provisioner.ACMEHTTP01Options {
+	if m.MgetHTTP01Options != nil {
+		return m.MgetHTTP01Options()
+	}
+	return nil
+}
+
+// GetCAAOptions mock
+func (m *MockProvisioner) GetCAAOptions() *provisioner.ACMECAAOptions {
+	if m.MgetCAAOptions != nil {
+		return m.MgetCAAOptions()
+	}
+	return nil
+}
+
+// GetValidationOptions mock
+func (m *MockProvisioner) GetValidationOptions() *provisioner.ACMEValidationOptions {
+	if m.MgetValidationOptions != nil {
+		return m.MgetValidationOptions()
+	}
+	return nil
+}
+
+// GetApprovalOptions mock
+func (m *MockProvisioner) GetApprovalOptions() *provisioner.ACMEApprovalOptions {
+	if m.MgetApprovalOptions != nil {
+		return m.MgetApprovalOptions()
+	}
+	return nil
+}
+
+// GetAutoRenewalOptions mock
+func (m *MockProvisioner) GetAutoRenewalOptions() *provisioner.ACMEAutoRenewalOptions {
+	if m.MgetAutoRenewalOptions != nil {
+		return m.MgetAutoRenewalOptions()
+	}
+	return nil
+}
+
+// GetRateLimitOptions mock
+func (m *MockProvisioner) GetRateLimitOptions() *provisioner.ACMERateLimitOptions {
+	if m.MgetRateLimitOptions != nil {
+		return m.MgetRateLimitOptions()
+	}
+	return nil
+}
+
+// GetChallengeDelegation mock
+func (m *MockProvisioner) GetChallengeDelegation(value string) *provisioner.ACMEChallengeDelegation {
+	if m.MgetChallengeDelegation != nil {
+		return m.MgetChallengeDelegation(value)
+	}
+	return nil
+}
+
+// GetEmailReplyOptions mock
+func (m *MockProvisioner) GetEmailReplyOptions() *provisioner.ACMEEmailReplyOptions {
+	if m.MgetEmailReplyOptions != nil {
+		return m.MgetEmailReplyOptions()
+	}
+	return nil
+}
+
+// GetOIDCOptions mock
+func (m *MockProvisioner) GetOIDCOptions() *provisioner.ACMEOIDCOptions {
+	if m.MgetOIDCOptions != nil {
+		return m.MgetOIDCOptions()
+	}
+	return nil
+}
+
+// RequireClientCertificate mock
+func (m *MockProvisioner) RequireClientCertificate() bool {
+	if m.MrequireClientCert != nil {
+		return m.MrequireClientCert()
+	}
+	return false
+}
+
+// VerifyClientCertificate mock
+func (m *MockProvisioner) VerifyClientCertificate(chain []*x509.Certificate) error {
+	if m.MverifyClientCert != nil {
+		return m.MverifyClientCert(chain)
+	}
+	return m.Merr
+}
+
 // DefaultTLSCertDuration mock
 func (m *MockProvisioner) DefaultTLSCertDuration() time.Duration {
 	if m.MdefaultTLSCertDuration != nil {
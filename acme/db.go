@@ -2,6 +2,7 @@ package acme
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -18,12 +19,24 @@ func IsErrNotFound(err error) bool {
 	return errors.Is(err, ErrNotFound)
 }
 
+// ErrConflict is an error that should be used by the acme.DB interface to
+// indicate that an entity has been modified by a concurrent request.
+var ErrConflict = errors.New("changed since last read")
+
+// IsErrConflict returns true if the error is a "conflict" error. Returns false
+// otherwise.
+func IsErrConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
 // DB is the DB interface expected by the step-ca ACME API.
 type DB interface {
 	CreateAccount(ctx context.Context, acc *Account) error
 	GetAccount(ctx context.Context, id string) (*Account, error)
 	GetAccountByKeyID(ctx context.Context, kid string) (*Account, error)
+	GetAccountsByProvisioner(ctx context.Context, provisionerName string) ([]*Account, error)
 	UpdateAccount(ctx context.Context, acc *Account) error
+	UpdateAccountKey(ctx context.Context, acc *Account) error
 
 	CreateExternalAccountKey(ctx context.Context, provisionerID, reference string) (*ExternalAccountKey, error)
 	GetExternalAccountKey(ctx context.Context, provisionerID, keyID string) (*ExternalAccountKey, error)
@@ -44,6 +57,7 @@ type DB interface {
 	CreateCertificate(ctx context.Context, cert *Certificate) error
 	GetCertificate(ctx context.Context, id string) (*Certificate, error)
 	GetCertificateBySerial(ctx context.Context, serial string) (*Certificate, error)
+	GetCertificatesByAccountID(ctx context.Context, accountID string) ([]*Certificate, error)
 
 	CreateChallenge(ctx context.Context, ch *Challenge) error
 	GetChallenge(ctx context.Context, id, authzID string) (*Challenge, error)
@@ -52,7 +66,22 @@ type DB interface {
 	CreateOrder(ctx context.Context, o *Order) error
 	GetOrder(ctx context.Context, id string) (*Order, error)
 	GetOrdersByAccountID(ctx context.Context, accountID string) ([]string, error)
+	GetAllOrdersByAccountID(ctx context.Context, accountID string) ([]*Order, error)
 	UpdateOrder(ctx context.Context, o *Order) error
+	UpdateOrderStatus(ctx context.Context, o *Order, from Status) error
+
+	CreateDeferredOrder(ctx context.Context, d *DeferredOrder) error
+	GetDeferredOrder(ctx context.Context, orderID string) (*DeferredOrder, error)
+	GetDeferredOrders(ctx context.Context, provisionerID string) ([]*DeferredOrder, error)
+	UpdateDeferredOrder(ctx context.Context, d *DeferredOrder, from ApprovalStatus) error
+
+	CreateStarOrder(ctx context.Context, s *StarOrder) error
+	GetStarOrder(ctx context.Context, orderID string) (*StarOrder, error)
+	GetStarOrders(ctx context.Context, before time.Time) ([]*StarOrder, error)
+	UpdateStarOrder(ctx context.Context, s *StarOrder, from StarOrderStatus) error
+
+	GetRateLimitEvents(ctx context.Context, key string) ([]time.Time, error)
+	UpdateRateLimitEvents(ctx context.Context, key string, events, old []time.Time, expiresAt time.Time) error
 }
 
 type dbKey struct{}
@@ -81,10 +110,12 @@ func MustDatabaseFromContext(ctx context.Context) DB {
 // MockDB is an implementation of the DB interface that should only be used as
 // a mock in tests.
 type MockDB struct {
-	MockCreateAccount     func(ctx context.Context, acc *Account) error
-	MockGetAccount        func(ctx context.Context, id string) (*Account, error)
-	MockGetAccountByKeyID func(ctx context.Context, kid string) (*Account, error)
-	MockUpdateAccount     func(ctx context.Context, acc *Account) error
+	MockCreateAccount            func(ctx context.Context, acc *Account) error
+	MockGetAccount               func(ctx context.Context, id string) (*Account, error)
+	MockGetAccountByKeyID        func(ctx context.Context, kid string) (*Account, error)
+	MockGetAccountsByProvisioner func(ctx context.Context, provisionerName string) ([]*Account, error)
+	MockUpdateAccount            func(ctx context.Context, acc *Account) error
+	MockUpdateAccountKey         func(ctx context.Context, acc *Account) error
 
 	MockCreateExternalAccountKey         func(ctx context.Context, provisionerID, reference string) (*ExternalAccountKey, error)
 	MockGetExternalAccountKey            func(ctx context.Context, provisionerID, keyID string) (*ExternalAccountKey, error)
@@ -102,18 +133,34 @@ type MockDB struct {
 	MockUpdateAuthorization          func(ctx context.Context, az *Authorization) error
 	MockGetAuthorizationsByAccountID func(ctx context.Context, accountID string) ([]*Authorization, error)
 
-	MockCreateCertificate      func(ctx context.Context, cert *Certificate) error
-	MockGetCertificate         func(ctx context.Context, id string) (*Certificate, error)
-	MockGetCertificateBySerial func(ctx context.Context, serial string) (*Certificate, error)
+	MockCreateCertificate          func(ctx context.Context, cert *Certificate) error
+	MockGetCertificate             func(ctx context.Context, id string) (*Certificate, error)
+	MockGetCertificateBySerial     func(ctx context.Context, serial string) (*Certificate, error)
+	MockGetCertificatesByAccountID func(ctx context.Context, accountID string) ([]*Certificate, error)
 
 	MockCreateChallenge func(ctx context.Context, ch *Challenge) error
 	MockGetChallenge    func(ctx context.Context, id, authzID string) (*Challenge, error)
 	MockUpdateChallenge func(ctx context.Context, ch *Challenge) error
 
-	MockCreateOrder          func(ctx context.Context, o *Order) error
-	MockGetOrder             func(ctx context.Context, id string) (*Order, error)
-	MockGetOrdersByAccountID func(ctx context.Context, accountID string) ([]string, error)
-	MockUpdateOrder          func(ctx context.Context, o *Order) error
+	MockCreateOrder             func(ctx context.Context, o *Order) error
+	MockGetOrder                func(ctx context.Context, id string) (*Order, error)
+	MockGetOrdersByAccountID    func(ctx context.Context, accountID string) ([]string, error)
+	MockGetAllOrdersByAccountID func(ctx context.Context, accountID string) ([]*Order, error)
+	MockUpdateOrder             func(ctx context.Context, o *Order) error
+	MockUpdateOrderStatus       func(ctx context.Context, o *Order, from Status) error
+
+	MockCreateDeferredOrder func(ctx context.Context, d *DeferredOrder) error
+	MockGetDeferredOrder    func(ctx context.Context, orderID string) (*DeferredOrder, error)
+	MockGetDeferredOrders   func(ctx context.Context, provisionerID string) ([]*DeferredOrder, error)
+	MockUpdateDeferredOrder func(ctx context.Context, d *DeferredOrder, from ApprovalStatus) error
+
+	MockCreateStarOrder func(ctx context.Context, s *StarOrder) error
+	MockGetStarOrder    func(ctx context.Context, orderID string) (*StarOrder, error)
+	MockGetStarOrders   func(ctx context.Context, before time.Time) ([]*StarOrder, error)
+	MockUpdateStarOrder func(ctx context.Context, s *StarOrder, from StarOrderStatus) error
+
+	MockGetRateLimitEvents    func(ctx context.Context, key string) ([]time.Time, error)
+	MockUpdateRateLimitEvents func(ctx context.Context, key string, events, old []time.Time, expiresAt time.Time) error
 
 	MockRet1  interface{}
 	MockError error
@@ -149,6 +196,16 @@ func (m *MockDB) GetAccountByKeyID(ctx context.Context, kid string) (*Account, e
 	return m.MockRet1.(*Account), m.MockError
 }
 
+// GetAccountsByProvisioner mock
+func (m *MockDB) GetAccountsByProvisioner(ctx context.Context, provisionerName string) ([]*Account, error) {
+	if m.MockGetAccountsByProvisioner != nil {
+		return m.MockGetAccountsByProvisioner(ctx, provisionerName)
+	} else if m.MockError != nil {
+		return nil, m.MockError
+	}
+	return m.MockRet1.([]*Account), m.MockError
+}
+
 // UpdateAccount mock
 func (m *MockDB) UpdateAccount(ctx context.Context, acc *Account) error {
 	if m.MockUpdateAccount != nil {
@@ -159,6 +216,16 @@ func (m *MockDB) UpdateAccount(ctx context.Context, acc *Account) error {
 	return m.MockError
 }
 
+// UpdateAccountKey mock
+func (m *MockDB) UpdateAccountKey(ctx context.Context, acc *Account) error {
+	if m.MockUpdateAccountKey != nil {
+		return m.MockUpdateAccountKey(ctx, acc)
+	} else if m.MockError != nil {
+		return m.MockError
+	}
+	return m.MockError
+}
+
 // CreateExternalAccountKey mock
 func (m *MockDB) CreateExternalAccountKey(ctx context.Context, provisionerID, reference string) (*ExternalAccountKey, error) {
 	if m.MockCreateExternalAccountKey != nil {
@@ -319,6 +386,16 @@ func (m *MockDB) GetCertificateBySerial(ctx context.Context, serial string) (*Ce
 	return m.MockRet1.(*Certificate), m.MockError
 }
 
+// GetCertificatesByAccountID mock
+func (m *MockDB) GetCertificatesByAccountID(ctx context.Context, accountID string) ([]*Certificate, error) {
+	if m.MockGetCertificatesByAccountID != nil {
+		return m.MockGetCertificatesByAccountID(ctx, accountID)
+	} else if m.MockError != nil {
+		return nil, m.MockError
+	}
+	return m.MockRet1.([]*Certificate), m.MockError
+}
+
 // CreateChallenge mock
 func (m *MockDB) CreateChallenge(ctx context.Context, ch *Challenge) error {
 	if m.MockCreateChallenge != nil {
@@ -379,6 +456,16 @@ func (m *MockDB) UpdateOrder(ctx context.Context, o *Order) error {
 	return m.MockError
 }
 
+// UpdateOrderStatus mock
+func (m *MockDB) UpdateOrderStatus(ctx context.Context, o *Order, from Status) error {
+	if m.MockUpdateOrderStatus != nil {
+		return m.MockUpdateOrderStatus(ctx, o, from)
+	} else if m.MockError != nil {
+		return m.MockError
+	}
+	return m.MockError
+}
+
 // GetOrdersByAccountID mock
 func (m *MockDB) GetOrdersByAccountID(ctx context.Context, accID string) ([]string, error) {
 	if m.MockGetOrdersByAccountID != nil {
@@ -388,3 +475,113 @@ func (m *MockDB) GetOrdersByAccountID(ctx context.Context, accID string) ([]stri
 	}
 	return m.MockRet1.([]string), m.MockError
 }
+
+// GetAllOrdersByAccountID mock
+func (m *MockDB) GetAllOrdersByAccountID(ctx context.Context, accountID string) ([]*Order, error) {
+	if m.MockGetAllOrdersByAccountID != nil {
+		return m.MockGetAllOrdersByAccountID(ctx, accountID)
+	} else if m.MockError != nil {
+		return nil, m.MockError
+	}
+	return m.MockRet1.([]*Order), m.MockError
+}
+
+// CreateDeferredOrder mock
+func (m *MockDB) CreateDeferredOrder(ctx context.Context, d *DeferredOrder) error {
+	if m.MockCreateDeferredOrder != nil {
+		return m.MockCreateDeferredOrder(ctx, d)
+	} else if m.MockError != nil {
+		return m.MockError
+	}
+	return m.MockError
+}
+
+// GetDeferredOrder mock
+func (m *MockDB) GetDeferredOrder(ctx context.Context, orderID string) (*DeferredOrder, error) {
+	if m.MockGetDeferredOrder != nil {
+		return m.MockGetDeferredOrder(ctx, orderID)
+	} else if m.MockError != nil {
+		return nil, m.MockError
+	}
+	return m.MockRet1.(*DeferredOrder), m.MockError
+}
+
+// GetDeferredOrders mock
+func (m *MockDB) GetDeferredOrders(ctx context.Context, provisionerID string) ([]*DeferredOrder, error) {
+	if m.MockGetDeferredOrders != nil {
+		return m.MockGetDeferredOrders(ctx, provisionerID)
+	} else if m.MockError != nil {
+		return nil, m.MockError
+	}
+	return m.MockRet1.([]*DeferredOrder), m.MockError
+}
+
+// UpdateDeferredOrder mock
+func (m *MockDB) UpdateDeferredOrder(ctx context.Context, d *DeferredOrder, from ApprovalStatus) error {
+	if m.MockUpdateDeferredOrder != nil {
+		return m.MockUpdateDeferredOrder(ctx, d, from)
+	} else if m.MockError != nil {
+		return m.MockError
+	}
+	return m.MockError
+}
+
+// CreateStarOrder mock
+func (m *MockDB) CreateStarOrder(ctx context.Context, s *StarOrder) error {
+	if m.MockCreateStarOrder != nil {
+		return m.MockCreateStarOrder(ctx, s)
+	} else if m.MockError != nil {
+		return m.MockError
+	}
+	return m.MockError
+}
+
+// GetStarOrder mock
+func (m *MockDB) GetStarOrder(ctx context.Context, orderID string) (*StarOrder, error) {
+	if m.MockGetStarOrder != nil {
+		return m.MockGetStarOrder(ctx, orderID)
+	} else if m.MockError != nil {
+		return nil, m.MockError
+	}
+	return m.MockRet1.(*StarOrder), m.MockError
+}
+
+// GetStarOrders mock
+func (m *MockDB) GetStarOrders(ctx context.Context, before time.Time) ([]*StarOrder, error) {
+	if m.MockGetStarOrders != nil {
+		return m.MockGetStarOrders(ctx, before)
+	} else if m.MockError != nil {
+		return nil, m.MockError
+	}
+	return m.MockRet1.([]*StarOrder), m.MockError
+}
+
+// UpdateStarOrder mock
+func (m *MockDB) UpdateStarOrder(ctx context.Context, s *StarOrder, from StarOrderStatus) error {
+	if m.MockUpdateStarOrder != nil {
+		return m.MockUpdateStarOrder(ctx, s, from)
+	} else if m.MockError != nil {
+		return m.MockError
+	}
+	return m.MockError
+}
+
+// GetRateLimitEvents mock
+func (m *MockDB) GetRateLimitEvents(ctx context.Context, key string) ([]time.Time, error) {
+	if m.MockGetRateLimitEvents != nil {
+		return m.MockGetRateLimitEvents(ctx, key)
+	} else if m.MockError != nil {
+		return nil, m.MockError
+	}
+	return m.MockRet1.([]time.Time), m.MockError
+}
+
+// UpdateRateLimitEvents mock
+func (m *MockDB) UpdateRateLimitEvents(ctx context.Context, key string, events, old []time.Time, expiresAt time.Time) error {
+	if m.MockUpdateRateLimitEvents != nil {
+		return m.MockUpdateRateLimitEvents(ctx, key, events, old, expiresAt)
+	} else if m.MockError != nil {
+		return m.MockError
+	}
+	return m.MockError
+}
@@ -21,6 +21,9 @@ type dbAccount struct {
 	ProvisionerName string           `json:"provisionerName"`
 	CreatedAt       time.Time        `json:"createdAt"`
 	DeactivatedAt   time.Time        `json:"deactivatedAt"`
+	// ClientCertificate is set if the account was created with a client
+	// certificate. Older accounts do not have it.
+	ClientCertificate *acme.AccountClientCertificate `json:"clientCertificate,omitempty"`
 }
 
 func (dba *dbAccount) clone() *dbAccount {
@@ -64,15 +67,47 @@ func (db *DB) GetAccount(ctx context.Context, id string) (*acme.Account, error)
 	}
 
 	return &acme.Account{
-		Status:          dbacc.Status,
-		Contact:         dbacc.Contact,
-		Key:             dbacc.Key,
-		ID:              dbacc.ID,
-		LocationPrefix:  dbacc.LocationPrefix,
-		ProvisionerName: dbacc.ProvisionerName,
+		Status:            dbacc.Status,
+		Contact:           dbacc.Contact,
+		Key:               dbacc.Key,
+		ID:                dbacc.ID,
+		LocationPrefix:    dbacc.LocationPrefix,
+		ProvisionerName:   dbacc.ProvisionerName,
+		ClientCertificate: dbacc.ClientCertificate,
 	}, nil
 }
 
+// GetAccountsByProvisioner retrieves the ACME accounts of a provisioner.
+func (db *DB) GetAccountsByProvisioner(_ context.Context, provisionerName string) ([]*acme.Account, error) {
+	entries, err := db.db.List(accountTable)
+	switch {
+	case nosqlDB.IsErrNotFound(err):
+		return []*acme.Account{}, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "error listing accounts")
+	}
+	accounts := []*acme.Account{}
+	for _, entry := range entries {
+		dbacc := new(dbAccount)
+		if err := json.Unmarshal(entry.Value, dbacc); err != nil {
+			return nil, errors.Wrapf(err, "error unmarshaling account %s into dbAccount", entry.Key)
+		}
+		if dbacc.ProvisionerName != provisionerName {
+			continue
+		}
+		accounts = append(accounts, &acme.Account{
+			Status:            dbacc.Status,
+			Contact:           dbacc.Contact,
+			Key:               dbacc.Key,
+			ID:                dbacc.ID,
+			LocationPrefix:    dbacc.LocationPrefix,
+			ProvisionerName:   dbacc.ProvisionerName,
+			ClientCertificate: dbacc.ClientCertificate,
+		})
+	}
+	return accounts, nil
+}
+
 // GetAccountByKeyID retrieves an ACME account by KeyID (thumbprint of the Account Key -- JWK).
 func (db *DB) GetAccountByKeyID(ctx context.Context, kid string) (*acme.Account, error) {
 	id, err := db.getAccountIDByKeyID(ctx, kid)
@@ -91,13 +126,14 @@ func (db *DB) CreateAccount(ctx context.Context, acc *acme.Account) error {
 	}
 
 	dba := &dbAccount{
-		ID:              acc.ID,
-		Key:             acc.Key,
-		Contact:         acc.Contact,
-		Status:          acc.Status,
-		CreatedAt:       clock.Now(),
-		LocationPrefix:  acc.LocationPrefix,
-		ProvisionerName: acc.ProvisionerName,
+		ID:                acc.ID,
+		Key:               acc.Key,
+		Contact:           acc.Contact,
+		Status:            acc.Status,
+		CreatedAt:         clock.Now(),
+		LocationPrefix:    acc.LocationPrefix,
+		ProvisionerName:   acc.ProvisionerName,
+		ClientCertificate: acc.ClientCertificate,
 	}
 
 	kid, err := acme.KeyToID(dba.Key)
@@ -140,3 +176,42 @@ func (db *DB) UpdateAccount(ctx context.Context, acc *acme.Account) error {
 
 	return db.save(ctx, old.ID, nu, old, "account", accountTable)
 }
+
+// UpdateAccountKey implements the AcmeDB.UpdateAccountKey interface. It
+// replaces the key of the account and the key-account index. It returns an
+// acme.ErrConflict error if the new key is already bound to an account.
+func (db *DB) UpdateAccountKey(ctx context.Context, acc *acme.Account) error {
+	old, err := db.getDBAccount(ctx, acc.ID)
+	if err != nil {
+		return err
+	}
+	oldKid, err := acme.KeyToID(old.Key)
+	if err != nil {
+		return err
+	}
+	kid, err := acme.KeyToID(acc.Key)
+	if err != nil {
+		return err
+	}
+	kidB := []byte(kid)
+
+	// Set the new jwkID -> acme account ID index
+	_, swapped, err := db.db.CmpAndSwap(accountByKeyIDTable, kidB, nil, []byte(acc.ID))
+	switch {
+	case err != nil:
+		return errors.Wrap(err, "error storing keyID to accountID index")
+	case !swapped:
+		return errors.Wrapf(acme.ErrConflict, "key-id %s is already bound to an account", kid)
+	}
+
+	nu := old.clone()
+	nu.Key = acc.Key
+	if err := db.save(ctx, old.ID, nu, old, "account", accountTable); err != nil {
+		db.db.Del(accountByKeyIDTable, kidB)
+		return err
+	}
+	if err := db.db.Del(accountByKeyIDTable, []byte(oldKid)); err != nil {
+		return errors.Wrap(err, "error deleting keyID to accountID index")
+	}
+	return nil
+}
@@ -246,6 +246,33 @@ func TestDB_GetAccount(t *testing.T) {
 				dbacc: dbacc,
 			}
 		},
+		"ok/client-certificate": func(t *testing.T) test {
+			jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+			assert.FatalError(t, err)
+			dbacc := &dbAccount{
+				ID:              accID,
+				Status:          acme.StatusValid,
+				CreatedAt:       clock.Now(),
+				Key:             jwk,
+				LocationPrefix:  locationPrefix,
+				ProvisionerName: provisionerName,
+				ClientCertificate: &acme.AccountClientCertificate{
+					Subject:     "CN=client.internal",
+					Fingerprint: "b1b6b3df3cb7e5f9",
+					BoundAt:     time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC),
+				},
+			}
+			b, err := json.Marshal(dbacc)
+			assert.FatalError(t, err)
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return b, nil
+					},
+				},
+				dbacc: dbacc,
+			}
+		},
 	}
 	for name, run := range tests {
 		tc := run(t)
@@ -273,6 +300,7 @@ func TestDB_GetAccount(t *testing.T) {
 				assert.Equals(t, acc.LocationPrefix, tc.dbacc.LocationPrefix)
 				assert.Equals(t, acc.ProvisionerName, tc.dbacc.ProvisionerName)
 				assert.Equals(t, acc.Key.KeyID, tc.dbacc.Key.KeyID)
+				assert.Equals(t, acc.ClientCertificate, tc.dbacc.ClientCertificate)
 			}
 		})
 	}
@@ -713,3 +741,161 @@ func TestDB_UpdateAccount(t *testing.T) {
 		})
 	}
 }
+
+func TestDB_UpdateAccountKey(t *testing.T) {
+	accID := "accID"
+	oldJWK, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	newJWK, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	oldKid, err := acme.KeyToID(oldJWK)
+	assert.FatalError(t, err)
+	newKid, err := acme.KeyToID(newJWK)
+	assert.FatalError(t, err)
+	dbacc := &dbAccount{
+		ID:     accID,
+		Status: acme.StatusValid,
+		Key:    oldJWK,
+	}
+	b, err := json.Marshal(dbacc)
+	assert.FatalError(t, err)
+	acc := &acme.Account{ID: accID, Status: acme.StatusValid, Key: newJWK}
+
+	type test struct {
+		db  nosql.DB
+		err error
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/db.Get-error": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return nil, errors.New("force")
+					},
+				},
+				err: errors.New("error loading account accID: force"),
+			}
+		},
+		"fail/key-in-use": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return b, nil
+					},
+					MCmpAndSwap: func(bucket, key, old, nu []byte) ([]byte, bool, error) {
+						assert.Equals(t, bucket, accountByKeyIDTable)
+						assert.Equals(t, string(key), newKid)
+						return []byte("otherID"), false, nil
+					},
+				},
+				err: acme.ErrConflict,
+			}
+		},
+		"fail/save-error": func(t *testing.T) test {
+			var deleted bool
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return b, nil
+					},
+					MCmpAndSwap: func(bucket, key, old, nu []byte) ([]byte, bool, error) {
+						if string(bucket) == string(accountByKeyIDTable) {
+							return nu, true, nil
+						}
+						return nil, false, errors.New("force")
+					},
+					MDel: func(bucket, key []byte) error {
+						assert.False(t, deleted)
+						assert.Equals(t, bucket, accountByKeyIDTable)
+						assert.Equals(t, string(key), newKid)
+						deleted = true
+						return nil
+					},
+				},
+				err: errors.New("error saving acme account: force"),
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return b, nil
+					},
+					MCmpAndSwap: func(bucket, key, old, nu []byte) ([]byte, bool, error) {
+						switch string(bucket) {
+						case string(accountByKeyIDTable):
+							assert.Equals(t, string(key), newKid)
+							assert.Nil(t, old)
+							assert.Equals(t, string(nu), accID)
+						case string(accountTable):
+							assert.Equals(t, old, b)
+							dbNew := new(dbAccount)
+							assert.FatalError(t, json.Unmarshal(nu, dbNew))
+							assert.Equals(t, dbNew.Key.KeyID, newJWK.KeyID)
+							assert.Equals(t, dbNew.Status, acme.StatusValid)
+						}
+						return nu, true, nil
+					},
+					MDel: func(bucket, key []byte) error {
+						assert.Equals(t, bucket, accountByKeyIDTable)
+						assert.Equals(t, string(key), oldKid)
+						return nil
+					},
+				},
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			d := DB{db: tc.db}
+			if err := d.UpdateAccountKey(context.Background(), acc); err != nil {
+				if assert.NotNil(t, tc.err) {
+					if errors.Is(tc.err, acme.ErrConflict) {
+						assert.True(t, acme.IsErrConflict(err))
+					} else {
+						assert.HasPrefix(t, err.Error(), tc.err.Error())
+					}
+				}
+			} else {
+				assert.Nil(t, tc.err)
+			}
+		})
+	}
+}
+
+func TestDB_GetAccountsByProvisioner(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	entry := func(id, provisionerName string) *nosqldb.Entry {
+		b, err := json.Marshal(&dbAccount{ID: id, Key: jwk, Status: acme.StatusValid, ProvisionerName: provisionerName})
+		assert.FatalError(t, err)
+		return &nosqldb.Entry{Bucket: accountTable, Key: []byte(id), Value: b}
+	}
+
+	t.Run("fail/db.List-error", func(t *testing.T) {
+		d := DB{db: &db.MockNoSQLDB{
+			MList: func(bucket []byte) ([]*nosqldb.Entry, error) {
+				return nil, errors.New("force")
+			},
+		}}
+		_, err := d.GetAccountsByProvisioner(context.Background(), "provName")
+		assert.HasPrefix(t, err.Error(), "error listing accounts: force")
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		d := DB{db: &db.MockNoSQLDB{
+			MList: func(bucket []byte) ([]*nosqldb.Entry, error) {
+				assert.Equals(t, accountTable, bucket)
+				return []*nosqldb.Entry{entry("acc1", "provName"), entry("acc2", "other"), entry("acc3", "provName")}, nil
+			},
+		}}
+		accs, err := d.GetAccountsByProvisioner(context.Background(), "provName")
+		assert.FatalError(t, err)
+		if assert.Len(t, 2, accs) {
+			assert.Equals(t, "acc1", accs[0].ID)
+			assert.Equals(t, "acc3", accs[1].ID)
+			assert.Equals(t, "provName", accs[1].ProvisionerName)
+		}
+	})
+}
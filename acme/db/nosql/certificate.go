@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/acme"
 	"github.com/smallstep/nosql"
+	"golang.org/x/crypto/ssh"
 )
 
 type dbCert struct {
@@ -19,6 +20,7 @@ type dbCert struct {
 	OrderID       string    `json:"orderID"`
 	Leaf          []byte    `json:"leaf"`
 	Intermediates []byte    `json:"intermediates"`
+	SSH           []byte    `json:"ssh,omitempty"`
 }
 
 type dbSerial struct {
@@ -34,6 +36,18 @@ func (db *DB) CreateCertificate(ctx context.Context, cert *acme.Certificate) err
 		return err
 	}
 
+	// SSH certificates are stored in the authorized keys format, and they are
+	// not indexed by serial number.
+	if cert.SSH != nil {
+		return db.save(ctx, cert.ID, &dbCert{
+			ID:        cert.ID,
+			AccountID: cert.AccountID,
+			OrderID:   cert.OrderID,
+			SSH:       ssh.MarshalAuthorizedKey(cert.SSH),
+			CreatedAt: time.Now().UTC(),
+		}, nil, "certificate", certTable)
+	}
+
 	leaf := pem.EncodeToMemory(&pem.Block{
 		Type:  "CERTIFICATE",
 		Bytes: cert.Leaf.Raw,
@@ -80,10 +94,54 @@ func (db *DB) GetCertificate(_ context.Context, id string) (*acme.Certificate, e
 	if err := json.Unmarshal(b, dbC); err != nil {
 		return nil, errors.Wrapf(err, "error unmarshaling certificate %s", id)
 	}
+	return dbC.toCertificate()
+}
+
+// GetCertificatesByAccountID retrieves the certificates issued to an ACME
+// account.
+func (db *DB) GetCertificatesByAccountID(_ context.Context, accountID string) ([]*acme.Certificate, error) {
+	entries, err := db.db.List(certTable)
+	switch {
+	case nosql.IsErrNotFound(err):
+		return []*acme.Certificate{}, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "error listing certificates")
+	}
+	certs := []*acme.Certificate{}
+	for _, entry := range entries {
+		dbC := new(dbCert)
+		if err := json.Unmarshal(entry.Value, dbC); err != nil {
+			return nil, errors.Wrapf(err, "error unmarshaling certificate %s", entry.Key)
+		}
+		if dbC.AccountID != accountID {
+			continue
+		}
+		cert, err := dbC.toCertificate()
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+func (dbC *dbCert) toCertificate() (*acme.Certificate, error) {
+	if len(dbC.SSH) > 0 {
+		sshCert, err := parseSSHCertificate(dbC.SSH)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing ssh certificate for ACME certificate with ID %s", dbC.ID)
+		}
+		return &acme.Certificate{
+			ID:        dbC.ID,
+			AccountID: dbC.AccountID,
+			OrderID:   dbC.OrderID,
+			SSH:       sshCert,
+		}, nil
+	}
 
 	certs, err := parseBundle(append(dbC.Leaf, dbC.Intermediates...))
 	if err != nil {
-		return nil, errors.Wrapf(err, "error parsing certificate chain for ACME certificate with ID %s", id)
+		return nil, errors.Wrapf(err, "error parsing certificate chain for ACME certificate with ID %s", dbC.ID)
 	}
 
 	return &acme.Certificate{
@@ -139,3 +197,15 @@ func parseBundle(b []byte) ([]*x509.Certificate, error) {
 	}
 	return bundle, nil
 }
+
+func parseSSHCertificate(b []byte) (*ssh.Certificate, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing ssh certificate")
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.New("error parsing ssh certificate: data is not a certificate")
+	}
+	return cert, nil
+}
@@ -16,7 +16,9 @@ import (
 	"github.com/smallstep/certificates/db"
 	"github.com/smallstep/nosql"
 	nosqldb "github.com/smallstep/nosql/database"
+	"go.step.sm/crypto/minica"
 	"go.step.sm/crypto/pemutil"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestDB_CreateCertificate(t *testing.T) {
@@ -275,6 +277,40 @@ func TestDB_GetCertificate(t *testing.T) {
 	}
 }
 
+func TestDB_Certificate_ssh(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	sshCert, err := ca.SignSSH(&ssh.Certificate{
+		Key:             ca.SSHUserSigner.PublicKey(),
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"jane"},
+	})
+	assert.FatalError(t, err)
+
+	var stored []byte
+	d := DB{db: &db.MockNoSQLDB{
+		MCmpAndSwap: func(bucket, key, old, nu []byte) ([]byte, bool, error) {
+			// SSH certificates are not indexed by serial number.
+			assert.Equals(t, bucket, certTable)
+			stored = nu
+			return nu, true, nil
+		},
+		MGet: func(bucket, key []byte) ([]byte, error) {
+			assert.Equals(t, bucket, certTable)
+			return stored, nil
+		},
+	}}
+
+	cert := &acme.Certificate{AccountID: "accountID", OrderID: "orderID", SSH: sshCert}
+	assert.FatalError(t, d.CreateCertificate(context.Background(), cert))
+	got, err := d.GetCertificate(context.Background(), cert.ID)
+	assert.FatalError(t, err)
+	assert.Equals(t, cert.ID, got.ID)
+	assert.Equals(t, "accountID", got.AccountID)
+	assert.Nil(t, got.Leaf)
+	assert.Equals(t, sshCert.Marshal(), got.SSH.Marshal())
+}
+
 func Test_parseBundle(t *testing.T) {
 	leaf, err := pemutil.ReadCertificate("../../../authority/testdata/certs/foo.crt")
 	assert.FatalError(t, err)
@@ -468,3 +504,43 @@ func TestDB_GetCertificateBySerial(t *testing.T) {
 		})
 	}
 }
+
+func TestDB_GetCertificatesByAccountID(t *testing.T) {
+	leaf, err := pemutil.ReadCertificate("../../../authority/testdata/certs/foo.crt")
+	assert.FatalError(t, err)
+	entry := func(id, accountID string) *nosqldb.Entry {
+		b, err := json.Marshal(&dbCert{
+			ID:        id,
+			AccountID: accountID,
+			OrderID:   "orderID",
+			Leaf:      pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}),
+		})
+		assert.FatalError(t, err)
+		return &nosqldb.Entry{Bucket: certTable, Key: []byte(id), Value: b}
+	}
+
+	t.Run("fail/db.List-error", func(t *testing.T) {
+		d := DB{db: &db.MockNoSQLDB{
+			MList: func(bucket []byte) ([]*nosqldb.Entry, error) {
+				return nil, errors.New("force")
+			},
+		}}
+		_, err := d.GetCertificatesByAccountID(context.Background(), "accID")
+		assert.HasPrefix(t, err.Error(), "error listing certificates: force")
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		d := DB{db: &db.MockNoSQLDB{
+			MList: func(bucket []byte) ([]*nosqldb.Entry, error) {
+				assert.Equals(t, certTable, bucket)
+				return []*nosqldb.Entry{entry("c1", "accID"), entry("c2", "other")}, nil
+			},
+		}}
+		certs, err := d.GetCertificatesByAccountID(context.Background(), "accID")
+		assert.FatalError(t, err)
+		if assert.Len(t, 1, certs) {
+			assert.Equals(t, "c1", certs[0].ID)
+			assert.Equals(t, leaf.Raw, certs[0].Leaf.Raw)
+		}
+	})
+}
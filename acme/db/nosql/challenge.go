@@ -18,10 +18,14 @@ type dbChallenge struct {
 	Type        acme.ChallengeType `json:"type"`
 	Status      acme.Status        `json:"status"`
 	Token       string             `json:"token"`
+	TokenPart1  string             `json:"tokenPart1,omitempty"`
+	From        string             `json:"from,omitempty"`
 	Value       string             `json:"value"`
 	ValidatedAt string             `json:"validatedAt"`
 	CreatedAt   time.Time          `json:"createdAt"`
 	Error       *acme.Error        `json:"error"` // TODO(hs): a bit dangerous; should become db-specific type
+
+	ValidationRecord []*acme.ValidationRecord `json:"validationRecord,omitempty"`
 }
 
 func (dbc *dbChallenge) clone() *dbChallenge {
@@ -54,13 +58,15 @@ func (db *DB) CreateChallenge(ctx context.Context, ch *acme.Challenge) error {
 	}
 
 	dbch := &dbChallenge{
-		ID:        ch.ID,
-		AccountID: ch.AccountID,
-		Value:     ch.Value,
-		Status:    acme.StatusPending,
-		Token:     ch.Token,
-		CreatedAt: clock.Now(),
-		Type:      ch.Type,
+		ID:         ch.ID,
+		AccountID:  ch.AccountID,
+		Value:      ch.Value,
+		Status:     acme.StatusPending,
+		Token:      ch.Token,
+		TokenPart1: ch.TokenPart1,
+		From:       ch.From,
+		CreatedAt:  clock.Now(),
+		Type:       ch.Type,
 	}
 
 	return db.save(ctx, ch.ID, dbch, nil, "challenge", challengeTable)
@@ -82,8 +88,12 @@ func (db *DB) GetChallenge(ctx context.Context, id, authzID string) (*acme.Chall
 		Value:       dbch.Value,
 		Status:      dbch.Status,
 		Token:       dbch.Token,
+		TokenPart1:  dbch.TokenPart1,
+		From:        dbch.From,
 		Error:       dbch.Error,
 		ValidatedAt: dbch.ValidatedAt,
+
+		ValidationRecord: dbch.ValidationRecord,
 	}
 	return ch, nil
 }
@@ -101,6 +111,7 @@ func (db *DB) UpdateChallenge(ctx context.Context, ch *acme.Challenge) error {
 	nu.Status = ch.Status
 	nu.Error = ch.Error
 	nu.ValidatedAt = ch.ValidatedAt
+	nu.ValidationRecord = ch.ValidationRecord
 
 	return db.save(ctx, old.ID, nu, old, "challenge", challengeTable)
 }
@@ -114,6 +114,8 @@ func TestDB_getDBChallenge(t *testing.T) {
 				assert.Equals(t, ch.Type, tc.dbc.Type)
 				assert.Equals(t, ch.Status, tc.dbc.Status)
 				assert.Equals(t, ch.Token, tc.dbc.Token)
+				assert.Equals(t, ch.TokenPart1, tc.dbc.TokenPart1)
+				assert.Equals(t, ch.From, tc.dbc.From)
 				assert.Equals(t, ch.Value, tc.dbc.Value)
 				assert.Equals(t, ch.ValidatedAt, tc.dbc.ValidatedAt)
 				assert.Equals(t, ch.Error.Error(), tc.dbc.Error.Error())
@@ -280,6 +282,30 @@ func TestDB_GetChallenge(t *testing.T) {
 				dbc: dbc,
 			}
 		},
+		"ok/email-reply-00": func(t *testing.T) test {
+			dbc := &dbChallenge{
+				ID:         chID,
+				AccountID:  "accountID",
+				Type:       "email-reply-00",
+				Status:     acme.StatusPending,
+				Token:      "token",
+				TokenPart1: "part1",
+				From:       "acme@ca.smallstep.com",
+				Value:      "jane@smallstep.com",
+				CreatedAt:  clock.Now(),
+				Error:      acme.NewErrorISE("The server experienced an internal error"),
+			}
+			b, err := json.Marshal(dbc)
+			assert.FatalError(t, err)
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return b, nil
+					},
+				},
+				dbc: dbc,
+			}
+		},
 	}
 	for name, run := range tests {
 		tc := run(t)
@@ -400,6 +426,10 @@ func TestDB_UpdateChallenge(t *testing.T) {
 				Status:      acme.StatusValid,
 				ValidatedAt: "foobar",
 				Error:       acme.NewError(acme.ErrorMalformedType, "malformed"),
+				ValidationRecord: []*acme.ValidationRecord{{
+					Hostname:   "_acme-challenge.zap.internal",
+					TXTRecords: []string{"foo"},
+				}},
 			}
 			return test{
 				ch: updCh,
@@ -429,6 +459,7 @@ func TestDB_UpdateChallenge(t *testing.T) {
 						assert.Equals(t, dbNew.Status, acme.StatusValid)
 						assert.Equals(t, dbNew.ValidatedAt, "foobar")
 						assert.Equals(t, dbNew.Error.Error(), acme.NewError(acme.ErrorMalformedType, "The request message was malformed").Error())
+						assert.Equals(t, dbNew.ValidationRecord, updCh.ValidationRecord)
 						return nu, true, nil
 					},
 				},
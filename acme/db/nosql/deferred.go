@@ -0,0 +1,136 @@
+package nosql
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/nosql"
+)
+
+type dbDeferredOrder struct {
+	OrderID       string              `json:"orderID"`
+	AccountID     string              `json:"accountID"`
+	ProvisionerID string              `json:"provisionerID"`
+	Identifiers   []acme.Identifier   `json:"identifiers"`
+	CSR           []byte              `json:"csr"`
+	Status        acme.ApprovalStatus `json:"status"`
+	CreatedAt     time.Time           `json:"createdAt"`
+	DecidedAt     time.Time           `json:"decidedAt,omitempty"`
+	DecidedBy     string              `json:"decidedBy,omitempty"`
+	Reason        string              `json:"reason,omitempty"`
+}
+
+func (d *dbDeferredOrder) clone() *dbDeferredOrder {
+	u := *d
+	return &u
+}
+
+func (d *dbDeferredOrder) toDeferredOrder() *acme.DeferredOrder {
+	return &acme.DeferredOrder{
+		OrderID:       d.OrderID,
+		AccountID:     d.AccountID,
+		ProvisionerID: d.ProvisionerID,
+		Identifiers:   d.Identifiers,
+		CSR:           d.CSR,
+		Status:        d.Status,
+		CreatedAt:     d.CreatedAt,
+		DecidedAt:     d.DecidedAt,
+		DecidedBy:     d.DecidedBy,
+		Reason:        d.Reason,
+	}
+}
+
+func unmarshalDeferredOrder(data []byte, orderID string) (*dbDeferredOrder, error) {
+	d := new(dbDeferredOrder)
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshaling deferred order %s into dbDeferredOrder", orderID)
+	}
+	return d, nil
+}
+
+// getDBDeferredOrder retrieves and unmarshals a deferred order from the
+// database.
+func (db *DB) getDBDeferredOrder(_ context.Context, orderID string) (*dbDeferredOrder, error) {
+	data, err := db.db.Get(deferredOrderTable, []byte(orderID))
+	if nosql.IsErrNotFound(err) {
+		return nil, acme.NewError(acme.ErrorMalformedType, "deferred order %s not found", orderID)
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "error loading deferred order %s", orderID)
+	}
+	return unmarshalDeferredOrder(data, orderID)
+}
+
+// CreateDeferredOrder stores the finalization of an order that requires an
+// approval. A previous deferred order of the same order is replaced.
+func (db *DB) CreateDeferredOrder(ctx context.Context, d *acme.DeferredOrder) error {
+	dbd := &dbDeferredOrder{
+		OrderID:       d.OrderID,
+		AccountID:     d.AccountID,
+		ProvisionerID: d.ProvisionerID,
+		Identifiers:   d.Identifiers,
+		CSR:           d.CSR,
+		Status:        d.Status,
+		CreatedAt:     clock.Now(),
+	}
+	b, err := json.Marshal(dbd)
+	if err != nil {
+		return errors.Wrapf(err, "error marshaling deferred order %s", d.OrderID)
+	}
+	if err := db.db.Set(deferredOrderTable, []byte(d.OrderID), b); err != nil {
+		return errors.Wrapf(err, "error saving acme deferred order %s", d.OrderID)
+	}
+	d.CreatedAt = dbd.CreatedAt
+	return nil
+}
+
+// GetDeferredOrder retrieves the deferred order of an order.
+func (db *DB) GetDeferredOrder(ctx context.Context, orderID string) (*acme.DeferredOrder, error) {
+	dbd, err := db.getDBDeferredOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return dbd.toDeferredOrder(), nil
+}
+
+// GetDeferredOrders retrieves the deferred orders of a provisioner that are
+// waiting for an approval.
+func (db *DB) GetDeferredOrders(_ context.Context, provisionerID string) ([]*acme.DeferredOrder, error) {
+	entries, err := db.db.List(deferredOrderTable)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing deferred orders")
+	}
+	orders := []*acme.DeferredOrder{}
+	for _, entry := range entries {
+		dbd, err := unmarshalDeferredOrder(entry.Value, string(entry.Key))
+		if err != nil {
+			return nil, err
+		}
+		if dbd.ProvisionerID == provisionerID && dbd.Status == acme.ApprovalPending {
+			orders = append(orders, dbd.toDeferredOrder())
+		}
+	}
+	return orders, nil
+}
+
+// UpdateDeferredOrder saves the decision on a deferred order. It returns
+// acme.ErrConflict if the stored deferred order does not have the given
+// status.
+func (db *DB) UpdateDeferredOrder(ctx context.Context, d *acme.DeferredOrder, from acme.ApprovalStatus) error {
+	old, err := db.getDBDeferredOrder(ctx, d.OrderID)
+	if err != nil {
+		return err
+	}
+	if old.Status != from {
+		return errors.Wrapf(acme.ErrConflict, "error saving acme deferred order; deferred order %s has status %s", d.OrderID, old.Status)
+	}
+
+	nu := old.clone()
+	nu.Status = d.Status
+	nu.DecidedAt = d.DecidedAt
+	nu.DecidedBy = d.DecidedBy
+	nu.Reason = d.Reason
+	return db.save(ctx, old.OrderID, nu, old, "deferred order", deferredOrderTable)
+}
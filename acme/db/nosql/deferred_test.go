@@ -0,0 +1,72 @@
+package nosql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/nosql"
+)
+
+func TestDB_DeferredOrders(t *testing.T) {
+	bdb, err := nosql.New(nosql.BBoltDriver, filepath.Join(t.TempDir(), "acme.db"))
+	assert.FatalError(t, err)
+	t.Cleanup(func() { bdb.Close() })
+	db, err := New(bdb)
+	assert.FatalError(t, err)
+	ctx := context.Background()
+
+	_, err = db.GetDeferredOrder(ctx, "missing")
+	var acmeErr *acme.Error
+	if assert.True(t, errors.As(err, &acmeErr)) {
+		assert.Equals(t, acmeErr.Type, "urn:ietf:params:acme:error:malformed")
+	}
+
+	identifiers := []acme.Identifier{{Type: acme.DNS, Value: "foo.internal"}}
+	for _, d := range []*acme.DeferredOrder{
+		{OrderID: "o1", AccountID: "accID", ProvisionerID: "provID", Identifiers: identifiers, CSR: []byte("csr1"), Status: acme.ApprovalPending},
+		{OrderID: "o2", AccountID: "accID", ProvisionerID: "provID", Identifiers: identifiers, CSR: []byte("csr2"), Status: acme.ApprovalPending},
+		{OrderID: "o3", AccountID: "accID", ProvisionerID: "otherID", Identifiers: identifiers, CSR: []byte("csr3"), Status: acme.ApprovalPending},
+	} {
+		assert.FatalError(t, db.CreateDeferredOrder(ctx, d))
+		assert.False(t, d.CreatedAt.IsZero())
+	}
+
+	d, err := db.GetDeferredOrder(ctx, "o1")
+	assert.FatalError(t, err)
+	assert.Equals(t, d.AccountID, "accID")
+	assert.Equals(t, d.Identifiers, identifiers)
+	assert.Equals(t, d.CSR, []byte("csr1"))
+
+	orders, err := db.GetDeferredOrders(ctx, "provID")
+	assert.FatalError(t, err)
+	assert.Len(t, 2, orders)
+
+	d.Status = acme.ApprovalApproved
+	d.DecidedAt = clock.Now()
+	d.DecidedBy = "admin@example.com"
+	assert.FatalError(t, db.UpdateDeferredOrder(ctx, d, acme.ApprovalPending))
+	err = db.UpdateDeferredOrder(ctx, d, acme.ApprovalPending)
+	assert.True(t, acme.IsErrConflict(err))
+
+	d, err = db.GetDeferredOrder(ctx, "o1")
+	assert.FatalError(t, err)
+	assert.Equals(t, d.Status, acme.ApprovalApproved)
+	assert.Equals(t, d.DecidedBy, "admin@example.com")
+
+	orders, err = db.GetDeferredOrders(ctx, "provID")
+	assert.FatalError(t, err)
+	if assert.Len(t, 1, orders) {
+		assert.Equals(t, orders[0].OrderID, "o2")
+	}
+
+	// A new finalization replaces the previous one.
+	assert.FatalError(t, db.CreateDeferredOrder(ctx, &acme.DeferredOrder{OrderID: "o1", ProvisionerID: "provID", CSR: []byte("csr4"), Status: acme.ApprovalPending}))
+	d, err = db.GetDeferredOrder(ctx, "o1")
+	assert.FatalError(t, err)
+	assert.Equals(t, d.Status, acme.ApprovalPending)
+	assert.Equals(t, d.CSR, []byte("csr4"))
+}
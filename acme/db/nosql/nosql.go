@@ -3,11 +3,14 @@ package nosql
 import (
 	"context"
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	nosqlDB "github.com/smallstep/nosql"
 	"go.step.sm/crypto/randutil"
+
+	"github.com/smallstep/certificates/acme"
 )
 
 var (
@@ -23,11 +26,19 @@ var (
 	externalAccountKeyTable                   = []byte("acme_external_account_keys")
 	externalAccountKeyIDsByReferenceTable     = []byte("acme_external_account_keyID_reference_index")
 	externalAccountKeyIDsByProvisionerIDTable = []byte("acme_external_account_keyID_provisionerID_index")
+	deferredOrderTable                        = []byte("acme_deferred_orders")
+	starOrderTable                            = []byte("acme_star_orders")
+	rateLimitTable                            = []byte("acme_rate_limits")
 )
 
 // DB is a struct that implements the AcmeDB interface.
 type DB struct {
 	db nosqlDB.DB
+
+	// sweepMu guards sweptAt, the last time the expired rate limits were
+	// deleted.
+	sweepMu sync.Mutex
+	sweptAt time.Time
 }
 
 // New configures and returns a new ACME DB backend implemented using a nosql DB.
@@ -36,6 +47,7 @@ func New(db nosqlDB.DB) (*DB, error) {
 		challengeTable, nonceTable, orderTable, ordersByAccountIDTable,
 		certTable, certBySerialTable, externalAccountKeyTable,
 		externalAccountKeyIDsByReferenceTable, externalAccountKeyIDsByProvisionerIDTable,
+		deferredOrderTable, starOrderTable, rateLimitTable,
 	}
 	for _, b := range tables {
 		if err := db.CreateTable(b); err != nil {
@@ -43,7 +55,7 @@ func New(db nosqlDB.DB) (*DB, error) {
 				string(b))
 		}
 	}
-	return &DB{db}, nil
+	return &DB{db: db}, nil
 }
 
 // save writes the new data to the database, overwriting the old data if it
@@ -76,7 +88,7 @@ func (db *DB) save(_ context.Context, id string, nu, old interface{}, typ string
 	case err != nil:
 		return errors.Wrapf(err, "error saving acme %s", typ)
 	case !swapped:
-		return errors.Errorf("error saving acme %s; changed since last read", typ)
+		return errors.Wrapf(acme.ErrConflict, "error saving acme %s", typ)
 	default:
 		return nil
 	}
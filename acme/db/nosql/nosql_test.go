@@ -95,7 +95,7 @@ func TestDB_save(t *testing.T) {
 					return nil, false, nil
 				},
 			},
-			err: errors.New("error saving acme challenge; changed since last read"),
+			err: errors.New("error saving acme challenge: changed since last read"),
 		},
 		"ok": {
 			nu:  "new",
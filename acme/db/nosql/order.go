@@ -27,6 +27,7 @@ type dbOrder struct {
 	ExpiresAt        time.Time         `json:"expiresAt,omitempty"`
 	CertificateID    string            `json:"certificate,omitempty"`
 	Error            *acme.Error       `json:"error,omitempty"`
+	AutoRenewal      *acme.AutoRenewal `json:"autoRenewal,omitempty"`
 }
 
 func (a *dbOrder) clone() *dbOrder {
@@ -34,6 +35,23 @@ func (a *dbOrder) clone() *dbOrder {
 	return &b
 }
 
+func (a *dbOrder) toOrder() *acme.Order {
+	return &acme.Order{
+		ID:               a.ID,
+		AccountID:        a.AccountID,
+		ProvisionerID:    a.ProvisionerID,
+		CertificateID:    a.CertificateID,
+		Status:           a.Status,
+		ExpiresAt:        a.ExpiresAt,
+		Identifiers:      a.Identifiers,
+		NotBefore:        a.NotBefore,
+		NotAfter:         a.NotAfter,
+		AuthorizationIDs: a.AuthorizationIDs,
+		Error:            a.Error,
+		AutoRenewal:      a.AutoRenewal,
+	}
+}
+
 // getDBOrder retrieves and unmarshals an ACME Order type from the database.
 func (db *DB) getDBOrder(_ context.Context, id string) (*dbOrder, error) {
 	b, err := db.db.Get(orderTable, []byte(id))
@@ -56,21 +74,31 @@ func (db *DB) GetOrder(ctx context.Context, id string) (*acme.Order, error) {
 		return nil, err
 	}
 
-	o := &acme.Order{
-		ID:               dbo.ID,
-		AccountID:        dbo.AccountID,
-		ProvisionerID:    dbo.ProvisionerID,
-		CertificateID:    dbo.CertificateID,
-		Status:           dbo.Status,
-		ExpiresAt:        dbo.ExpiresAt,
-		Identifiers:      dbo.Identifiers,
-		NotBefore:        dbo.NotBefore,
-		NotAfter:         dbo.NotAfter,
-		AuthorizationIDs: dbo.AuthorizationIDs,
-		Error:            dbo.Error,
-	}
+	return dbo.toOrder(), nil
+}
 
-	return o, nil
+// GetAllOrdersByAccountID retrieves all the orders of an ACME account, in any
+// status. Unlike GetOrdersByAccountID, the status of the orders is not
+// updated.
+func (db *DB) GetAllOrdersByAccountID(_ context.Context, accountID string) ([]*acme.Order, error) {
+	entries, err := db.db.List(orderTable)
+	switch {
+	case nosql.IsErrNotFound(err):
+		return []*acme.Order{}, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "error listing orders")
+	}
+	orders := []*acme.Order{}
+	for _, entry := range entries {
+		dbo := new(dbOrder)
+		if err := json.Unmarshal(entry.Value, dbo); err != nil {
+			return nil, errors.Wrapf(err, "error unmarshaling order %s into dbOrder", entry.Key)
+		}
+		if dbo.AccountID == accountID {
+			orders = append(orders, dbo.toOrder())
+		}
+	}
+	return orders, nil
 }
 
 // CreateOrder creates ACME Order resources and saves them to the DB.
@@ -93,6 +121,7 @@ func (db *DB) CreateOrder(ctx context.Context, o *acme.Order) error {
 		NotBefore:        o.NotBefore,
 		NotAfter:         o.NotAfter,
 		AuthorizationIDs: o.AuthorizationIDs,
+		AutoRenewal:      o.AutoRenewal,
 	}
 	if err := db.save(ctx, o.ID, dbo, nil, "order", orderTable); err != nil {
 		return err
@@ -120,6 +149,26 @@ func (db *DB) UpdateOrder(ctx context.Context, o *acme.Order) error {
 	return db.save(ctx, old.ID, nu, old, "order", orderTable)
 }
 
+// UpdateOrderStatus saves the status, error and certificate of an order only if
+// the stored order has the given status. It returns an acme.ErrConflict error if
+// the order has a different status or it is modified concurrently.
+func (db *DB) UpdateOrderStatus(ctx context.Context, o *acme.Order, from acme.Status) error {
+	old, err := db.getDBOrder(ctx, o.ID)
+	if err != nil {
+		return err
+	}
+	if old.Status != from {
+		return errors.Wrapf(acme.ErrConflict, "error saving acme order; order %s has status %s", o.ID, old.Status)
+	}
+
+	nu := old.clone()
+
+	nu.Status = o.Status
+	nu.Error = o.Error
+	nu.CertificateID = o.CertificateID
+	return db.save(ctx, old.ID, nu, old, "order", orderTable)
+}
+
 func (db *DB) updateAddOrderIDs(ctx context.Context, accID string, addOids ...string) ([]string, error) {
 	ordersByAccountMux.Lock()
 	defer ordersByAccountMux.Unlock()
@@ -379,6 +379,110 @@ func TestDB_UpdateOrder(t *testing.T) {
 	}
 }
 
+func TestDB_UpdateOrderStatus(t *testing.T) {
+	orderID := "orderID"
+	now := clock.Now()
+	dbo := &dbOrder{
+		ID:               orderID,
+		AccountID:        "accID",
+		ProvisionerID:    "provID",
+		Status:           acme.StatusReady,
+		ExpiresAt:        now,
+		CreatedAt:        now,
+		AuthorizationIDs: []string{"foo", "bar"},
+	}
+	b, err := json.Marshal(dbo)
+	assert.FatalError(t, err)
+	type test struct {
+		db       nosql.DB
+		from     acme.Status
+		conflict bool
+		err      error
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/db.Get-error": func(t *testing.T) test {
+			return test{
+				from: acme.StatusReady,
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return nil, errors.New("force")
+					},
+				},
+				err: errors.New("error loading order orderID: force"),
+			}
+		},
+		"fail/status-changed": func(t *testing.T) test {
+			return test{
+				from: acme.StatusPending,
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return b, nil
+					},
+					MCmpAndSwap: func(bucket, key, old, nu []byte) ([]byte, bool, error) {
+						t.Error("unexpected call to CmpAndSwap")
+						return nil, false, errors.New("force")
+					},
+				},
+				conflict: true,
+				err:      errors.New("error saving acme order; order orderID has status ready"),
+			}
+		},
+		"fail/changed-since-last-read": func(t *testing.T) test {
+			return test{
+				from: acme.StatusReady,
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return b, nil
+					},
+					MCmpAndSwap: func(bucket, key, old, nu []byte) ([]byte, bool, error) {
+						return nil, false, nil
+					},
+				},
+				conflict: true,
+				err:      errors.New("error saving acme order: changed since last read"),
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				from: acme.StatusReady,
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						assert.Equals(t, bucket, orderTable)
+						assert.Equals(t, string(key), orderID)
+						return b, nil
+					},
+					MCmpAndSwap: func(bucket, key, old, nu []byte) ([]byte, bool, error) {
+						assert.Equals(t, bucket, orderTable)
+						assert.Equals(t, old, b)
+
+						dbNew := new(dbOrder)
+						assert.FatalError(t, json.Unmarshal(nu, dbNew))
+						assert.Equals(t, dbNew.ID, dbo.ID)
+						assert.Equals(t, dbNew.Status, acme.StatusProcessing)
+						assert.Equals(t, dbNew.AuthorizationIDs, dbo.AuthorizationIDs)
+						return nu, true, nil
+					},
+				},
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			d := DB{db: tc.db}
+			o := &acme.Order{ID: orderID, Status: acme.StatusProcessing}
+			if err := d.UpdateOrderStatus(context.Background(), o, tc.from); err != nil {
+				if assert.NotNil(t, tc.err) {
+					assert.HasPrefix(t, err.Error(), tc.err.Error())
+					assert.Equals(t, tc.conflict, acme.IsErrConflict(err))
+				}
+			} else {
+				assert.Nil(t, tc.err)
+			}
+		})
+	}
+}
+
 func TestDB_CreateOrder(t *testing.T) {
 	now := clock.Now()
 	nbf := now.Add(5 * time.Minute)
@@ -1023,3 +1127,40 @@ func TestDB_updateAddOrderIDs(t *testing.T) {
 		})
 	}
 }
+
+func TestDB_GetAllOrdersByAccountID(t *testing.T) {
+	entry := func(id, accountID string, status acme.Status) *database.Entry {
+		b, err := json.Marshal(&dbOrder{ID: id, AccountID: accountID, Status: status})
+		assert.FatalError(t, err)
+		return &database.Entry{Bucket: orderTable, Key: []byte(id), Value: b}
+	}
+
+	t.Run("fail/db.List-error", func(t *testing.T) {
+		d := DB{db: &db.MockNoSQLDB{
+			MList: func(bucket []byte) ([]*database.Entry, error) {
+				return nil, errors.New("force")
+			},
+		}}
+		_, err := d.GetAllOrdersByAccountID(context.Background(), "accID")
+		assert.HasPrefix(t, err.Error(), "error listing orders: force")
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		d := DB{db: &db.MockNoSQLDB{
+			MList: func(bucket []byte) ([]*database.Entry, error) {
+				assert.Equals(t, orderTable, bucket)
+				return []*database.Entry{
+					entry("o1", "accID", acme.StatusValid),
+					entry("o2", "other", acme.StatusPending),
+					entry("o3", "accID", acme.StatusInvalid),
+				}, nil
+			},
+		}}
+		orders, err := d.GetAllOrdersByAccountID(context.Background(), "accID")
+		assert.FatalError(t, err)
+		if assert.Len(t, 2, orders) {
+			assert.Equals(t, "o1", orders[0].ID)
+			assert.Equals(t, acme.StatusInvalid, orders[1].Status)
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package nosql
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/nosql"
+
+	"github.com/smallstep/certificates/acme"
+)
+
+// rateLimitSweepInterval is the minimum time between two deletions of the
+// expired rate limits.
+const rateLimitSweepInterval = 10 * time.Minute
+
+type dbRateLimit struct {
+	Key       string      `json:"key"`
+	Events    []time.Time `json:"events"`
+	ExpiresAt time.Time   `json:"expiresAt"`
+}
+
+// GetRateLimitEvents retrieves the times of the requests counted by a rate
+// limit. It returns nil if there are no requests.
+func (db *DB) GetRateLimitEvents(_ context.Context, key string) ([]time.Time, error) {
+	data, err := db.db.Get(rateLimitTable, []byte(key))
+	switch {
+	case nosql.IsErrNotFound(err):
+		return nil, nil
+	case err != nil:
+		return nil, errors.Wrapf(err, "error loading rate limit %s", key)
+	}
+	return parseRateLimitEvents(key, data)
+}
+
+// UpdateRateLimitEvents replaces the times of the requests counted by a rate
+// limit, the rate limit is deleted after expiresAt. It returns
+// acme.ErrConflict if the stored times are not the old ones.
+//
+// The expired rate limits are deleted by the updates, at most once every
+// rateLimitSweepInterval.
+func (db *DB) UpdateRateLimitEvents(_ context.Context, key string, events, old []time.Time, expiresAt time.Time) error {
+	data, err := db.db.Get(rateLimitTable, []byte(key))
+	if err != nil && !nosql.IsErrNotFound(err) {
+		return errors.Wrapf(err, "error loading rate limit %s", key)
+	}
+	stored, err := parseRateLimitEvents(key, data)
+	if err != nil {
+		return err
+	}
+	if !equalTimes(stored, old) {
+		return errors.Wrapf(acme.ErrConflict, "error saving acme rate limit %s", key)
+	}
+
+	var nu []byte
+	if len(events) > 0 {
+		if nu, err = json.Marshal(&dbRateLimit{Key: key, Events: events, ExpiresAt: expiresAt}); err != nil {
+			return errors.Wrapf(err, "error marshaling acme type: rate limit, value: %v", events)
+		}
+	}
+	if len(data) > 0 || len(nu) > 0 {
+		_, swapped, err := db.db.CmpAndSwap(rateLimitTable, []byte(key), data, nu)
+		switch {
+		case err != nil:
+			return errors.Wrap(err, "error saving acme rate limit")
+		case !swapped:
+			return errors.Wrapf(acme.ErrConflict, "error saving acme rate limit %s", key)
+		}
+	}
+
+	if err := db.sweepRateLimits(clock.Now()); err != nil {
+		log.Printf("error deleting expired acme rate limits: %v", err)
+	}
+	return nil
+}
+
+// sweepRateLimits deletes the rate limits that expired before now, and the
+// ones without events. It does nothing if the last sweep was less than
+// rateLimitSweepInterval ago.
+//
+// The entries cannot be deleted atomically, a rate limit updated while it's
+// deleted loses the request of the update. Only the expired rate limits are
+// deleted, so at most one request per rate limit and sweep is not counted.
+func (db *DB) sweepRateLimits(now time.Time) error {
+	db.sweepMu.Lock()
+	if now.Sub(db.sweptAt) < rateLimitSweepInterval {
+		db.sweepMu.Unlock()
+		return nil
+	}
+	db.sweptAt = now
+	db.sweepMu.Unlock()
+
+	entries, err := db.db.List(rateLimitTable)
+	if err != nil {
+		return errors.Wrap(err, "error listing rate limits")
+	}
+	for _, e := range entries {
+		if len(e.Value) > 0 {
+			dbrl := new(dbRateLimit)
+			if err := json.Unmarshal(e.Value, dbrl); err != nil || now.Before(dbrl.ExpiresAt) {
+				continue
+			}
+		}
+		if err := db.db.Del(rateLimitTable, e.Key); err != nil {
+			return errors.Wrapf(err, "error deleting rate limit %s", e.Key)
+		}
+	}
+	return nil
+}
+
+// parseRateLimitEvents returns the events of a stored rate limit.
+func parseRateLimitEvents(key string, data []byte) ([]time.Time, error) {
+	if len(data) == 0 {
+		// The events have been removed.
+		return nil, nil
+	}
+	dbrl := new(dbRateLimit)
+	if err := json.Unmarshal(data, dbrl); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshaling rate limit %s into dbRateLimit", key)
+	}
+	return dbrl.Events, nil
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
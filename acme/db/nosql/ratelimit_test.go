@@ -0,0 +1,85 @@
+package nosql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/nosql"
+)
+
+func TestDB_RateLimitEvents(t *testing.T) {
+	bdb, err := nosql.New(nosql.BBoltDriver, filepath.Join(t.TempDir(), "acme.db"))
+	assert.FatalError(t, err)
+	t.Cleanup(func() { bdb.Close() })
+	db, err := New(bdb)
+	assert.FatalError(t, err)
+	ctx := context.Background()
+
+	events, err := db.GetRateLimitEvents(ctx, "newOrder:provID:accID")
+	assert.FatalError(t, err)
+	assert.Len(t, 0, events)
+
+	now := clock.Now()
+	expiresAt := now.Add(time.Hour)
+	first := []time.Time{now}
+	assert.FatalError(t, db.UpdateRateLimitEvents(ctx, "newOrder:provID:accID", first, nil, expiresAt))
+	err = db.UpdateRateLimitEvents(ctx, "newOrder:provID:accID", first, nil, expiresAt)
+	assert.True(t, acme.IsErrConflict(err))
+
+	second := []time.Time{now, now.Add(time.Second)}
+	assert.FatalError(t, db.UpdateRateLimitEvents(ctx, "newOrder:provID:accID", second, first, expiresAt))
+	err = db.UpdateRateLimitEvents(ctx, "newOrder:provID:accID", second, first, expiresAt)
+	assert.True(t, acme.IsErrConflict(err))
+
+	events, err = db.GetRateLimitEvents(ctx, "newOrder:provID:accID")
+	assert.FatalError(t, err)
+	if assert.Len(t, 2, events) {
+		assert.True(t, events[1].Equal(second[1]))
+	}
+
+	// The rate limit can be removed and created again.
+	assert.FatalError(t, db.UpdateRateLimitEvents(ctx, "newOrder:provID:accID", nil, events, expiresAt))
+	events, err = db.GetRateLimitEvents(ctx, "newOrder:provID:accID")
+	assert.FatalError(t, err)
+	assert.Len(t, 0, events)
+	assert.FatalError(t, db.UpdateRateLimitEvents(ctx, "newOrder:provID:accID", first, nil, expiresAt))
+}
+
+func TestDB_RateLimitEvents_sweep(t *testing.T) {
+	bdb, err := nosql.New(nosql.BBoltDriver, filepath.Join(t.TempDir(), "acme.db"))
+	assert.FatalError(t, err)
+	t.Cleanup(func() { bdb.Close() })
+	db, err := New(bdb)
+	assert.FatalError(t, err)
+	ctx := context.Background()
+
+	now := clock.Now()
+	events := []time.Time{now}
+	expired := []time.Time{now.Add(-2 * time.Hour)}
+	assert.FatalError(t, db.UpdateRateLimitEvents(ctx, "newOrder:provID:accID", events, nil, now.Add(time.Hour)))
+	assert.FatalError(t, db.UpdateRateLimitEvents(ctx, "newOrder:provID:expired", expired, nil, expired[0].Add(time.Hour)))
+	assert.FatalError(t, db.UpdateRateLimitEvents(ctx, "newOrder:provID:removed", events, nil, now.Add(time.Hour)))
+	assert.FatalError(t, db.UpdateRateLimitEvents(ctx, "newOrder:provID:removed", nil, events, now.Add(time.Hour)))
+
+	// The sweep runs at most once every rateLimitSweepInterval.
+	entries, err := bdb.List(rateLimitTable)
+	assert.FatalError(t, err)
+	assert.Len(t, 3, entries)
+
+	// The expired rate limits and the ones without events are deleted.
+	db.sweptAt = now.Add(-rateLimitSweepInterval)
+	assert.FatalError(t, db.UpdateRateLimitEvents(ctx, "newOrder:provID:other", events, nil, now.Add(time.Hour)))
+	entries, err = bdb.List(rateLimitTable)
+	assert.FatalError(t, err)
+	if assert.Len(t, 2, entries) {
+		assert.Equals(t, "newOrder:provID:accID", string(entries[0].Key))
+		assert.Equals(t, "newOrder:provID:other", string(entries[1].Key))
+	}
+	events, err = db.GetRateLimitEvents(ctx, "newOrder:provID:expired")
+	assert.FatalError(t, err)
+	assert.Len(t, 0, events)
+}
@@ -0,0 +1,128 @@
+package nosql
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/nosql"
+)
+
+type dbStarOrder struct {
+	OrderID       string               `json:"orderID"`
+	AccountID     string               `json:"accountID"`
+	ProvisionerID string               `json:"provisionerID"`
+	CSR           []byte               `json:"csr"`
+	Status        acme.StarOrderStatus `json:"status"`
+	NextRenewalAt time.Time            `json:"nextRenewalAt,omitempty"`
+	CreatedAt     time.Time            `json:"createdAt"`
+}
+
+func (s *dbStarOrder) clone() *dbStarOrder {
+	u := *s
+	return &u
+}
+
+func (s *dbStarOrder) toStarOrder() *acme.StarOrder {
+	return &acme.StarOrder{
+		OrderID:       s.OrderID,
+		AccountID:     s.AccountID,
+		ProvisionerID: s.ProvisionerID,
+		CSR:           s.CSR,
+		Status:        s.Status,
+		NextRenewalAt: s.NextRenewalAt,
+		CreatedAt:     s.CreatedAt,
+	}
+}
+
+func unmarshalStarOrder(data []byte, orderID string) (*dbStarOrder, error) {
+	s := new(dbStarOrder)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshaling star order %s into dbStarOrder", orderID)
+	}
+	return s, nil
+}
+
+// getDBStarOrder retrieves and unmarshals the auto-renewal of an order from
+// the database.
+func (db *DB) getDBStarOrder(_ context.Context, orderID string) (*dbStarOrder, error) {
+	data, err := db.db.Get(starOrderTable, []byte(orderID))
+	if nosql.IsErrNotFound(err) {
+		return nil, acme.NewError(acme.ErrorMalformedType, "star order %s not found", orderID)
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "error loading star order %s", orderID)
+	}
+	return unmarshalStarOrder(data, orderID)
+}
+
+// CreateStarOrder stores the auto-renewal of a finalized order. A previous
+// auto-renewal of the same order is replaced.
+func (db *DB) CreateStarOrder(ctx context.Context, s *acme.StarOrder) error {
+	dbs := &dbStarOrder{
+		OrderID:       s.OrderID,
+		AccountID:     s.AccountID,
+		ProvisionerID: s.ProvisionerID,
+		CSR:           s.CSR,
+		Status:        s.Status,
+		NextRenewalAt: s.NextRenewalAt,
+		CreatedAt:     clock.Now(),
+	}
+	b, err := json.Marshal(dbs)
+	if err != nil {
+		return errors.Wrapf(err, "error marshaling star order %s", s.OrderID)
+	}
+	if err := db.db.Set(starOrderTable, []byte(s.OrderID), b); err != nil {
+		return errors.Wrapf(err, "error saving acme star order %s", s.OrderID)
+	}
+	s.CreatedAt = dbs.CreatedAt
+	return nil
+}
+
+// GetStarOrder retrieves the auto-renewal of an order.
+func (db *DB) GetStarOrder(ctx context.Context, orderID string) (*acme.StarOrder, error) {
+	dbs, err := db.getDBStarOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return dbs.toStarOrder(), nil
+}
+
+// GetStarOrders retrieves the active auto-renewals that must be renewed
+// before the given time.
+func (db *DB) GetStarOrders(_ context.Context, before time.Time) ([]*acme.StarOrder, error) {
+	entries, err := db.db.List(starOrderTable)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing star orders")
+	}
+	orders := []*acme.StarOrder{}
+	for _, entry := range entries {
+		dbs, err := unmarshalStarOrder(entry.Value, string(entry.Key))
+		if err != nil {
+			return nil, err
+		}
+		if dbs.Status == acme.StarOrderActive && !dbs.NextRenewalAt.After(before) {
+			orders = append(orders, dbs.toStarOrder())
+		}
+	}
+	return orders, nil
+}
+
+// UpdateStarOrder saves the status and the next renewal of an auto-renewal.
+// It returns acme.ErrConflict if the stored auto-renewal does not have the
+// given status.
+func (db *DB) UpdateStarOrder(ctx context.Context, s *acme.StarOrder, from acme.StarOrderStatus) error {
+	old, err := db.getDBStarOrder(ctx, s.OrderID)
+	if err != nil {
+		return err
+	}
+	if old.Status != from {
+		return errors.Wrapf(acme.ErrConflict, "error saving acme star order; star order %s has status %s", s.OrderID, old.Status)
+	}
+
+	nu := old.clone()
+	nu.Status = s.Status
+	nu.NextRenewalAt = s.NextRenewalAt
+	return db.save(ctx, old.OrderID, nu, old, "star order", starOrderTable)
+}
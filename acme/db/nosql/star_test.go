@@ -0,0 +1,67 @@
+package nosql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/nosql"
+)
+
+func TestDB_StarOrders(t *testing.T) {
+	bdb, err := nosql.New(nosql.BBoltDriver, filepath.Join(t.TempDir(), "acme.db"))
+	assert.FatalError(t, err)
+	t.Cleanup(func() { bdb.Close() })
+	db, err := New(bdb)
+	assert.FatalError(t, err)
+	ctx := context.Background()
+
+	_, err = db.GetStarOrder(ctx, "missing")
+	var acmeErr *acme.Error
+	if assert.True(t, errors.As(err, &acmeErr)) {
+		assert.Equals(t, acmeErr.Type, "urn:ietf:params:acme:error:malformed")
+	}
+
+	now := clock.Now()
+	for _, s := range []*acme.StarOrder{
+		{OrderID: "o1", AccountID: "accID", ProvisionerID: "provID", CSR: []byte("csr1"), Status: acme.StarOrderActive, NextRenewalAt: now.Add(-time.Minute)},
+		{OrderID: "o2", AccountID: "accID", ProvisionerID: "provID", CSR: []byte("csr2"), Status: acme.StarOrderActive, NextRenewalAt: now.Add(time.Hour)},
+		{OrderID: "o3", AccountID: "accID", ProvisionerID: "provID", CSR: []byte("csr3"), Status: acme.StarOrderFinished},
+	} {
+		assert.FatalError(t, db.CreateStarOrder(ctx, s))
+		assert.False(t, s.CreatedAt.IsZero())
+	}
+
+	s, err := db.GetStarOrder(ctx, "o1")
+	assert.FatalError(t, err)
+	assert.Equals(t, s.AccountID, "accID")
+	assert.Equals(t, s.CSR, []byte("csr1"))
+	assert.Equals(t, s.Status, acme.StarOrderActive)
+
+	orders, err := db.GetStarOrders(ctx, now)
+	assert.FatalError(t, err)
+	if assert.Len(t, 1, orders) {
+		assert.Equals(t, orders[0].OrderID, "o1")
+	}
+
+	s.NextRenewalAt = now.Add(2 * time.Hour)
+	assert.FatalError(t, db.UpdateStarOrder(ctx, s, acme.StarOrderActive))
+	s.Status = acme.StarOrderCanceled
+	assert.FatalError(t, db.UpdateStarOrder(ctx, s, acme.StarOrderActive))
+	err = db.UpdateStarOrder(ctx, s, acme.StarOrderActive)
+	assert.True(t, acme.IsErrConflict(err))
+
+	s, err = db.GetStarOrder(ctx, "o1")
+	assert.FatalError(t, err)
+	assert.Equals(t, s.Status, acme.StarOrderCanceled)
+
+	orders, err = db.GetStarOrders(ctx, now.Add(3*time.Hour))
+	assert.FatalError(t, err)
+	if assert.Len(t, 1, orders) {
+		assert.Equals(t, orders[0].OrderID, "o2")
+	}
+}
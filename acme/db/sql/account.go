@@ -0,0 +1,188 @@
+package sql
+
+import (
+	"context"
+	sqlDB "database/sql"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/certificates/acme"
+	certdb "github.com/smallstep/certificates/db"
+)
+
+// selectAccounts selects the columns read by scanAccount. The client
+// certificates bound to the accounts are kept in their own table.
+const selectAccounts = "SELECT a.id, a.jwk, a.contact, a.status, a.location_prefix, a.provisioner_name, c.client_certificate " +
+	"FROM acme_accounts a LEFT JOIN acme_account_client_certificates c ON c.account_id = a.id"
+
+func scanAccount(row scanner) (*acme.Account, error) {
+	var (
+		acc               acme.Account
+		jwk, contact      string
+		clientCertificate sqlDB.NullString
+	)
+	if err := row.Scan(&acc.ID, &jwk, &contact, &acc.Status, &acc.LocationPrefix, &acc.ProvisionerName, &clientCertificate); err != nil {
+		return nil, err
+	}
+	acc.Key = new(jose.JSONWebKey)
+	if err := json.Unmarshal([]byte(jwk), acc.Key); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshaling key of account %s", acc.ID)
+	}
+	if err := json.Unmarshal([]byte(contact), &acc.Contact); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshaling contact of account %s", acc.ID)
+	}
+	if err := unmarshal(clientCertificate, &acc.ClientCertificate, "clientCertificate"); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+// GetAccount retrieves an ACME account by ID.
+func (db *DB) GetAccount(ctx context.Context, id string) (*acme.Account, error) {
+	acc, err := scanAccount(db.queryRow(ctx, db.db, selectAccounts+" WHERE a.id = ?", id))
+	switch {
+	case errors.Is(err, sqlDB.ErrNoRows):
+		return nil, acme.ErrNotFound
+	case err != nil:
+		return nil, errors.Wrapf(err, "error loading account %s", id)
+	default:
+		return acc, nil
+	}
+}
+
+// GetAccountByKeyID retrieves an ACME account by KeyID (thumbprint of the Account Key -- JWK).
+func (db *DB) GetAccountByKeyID(ctx context.Context, kid string) (*acme.Account, error) {
+	acc, err := scanAccount(db.queryRow(ctx, db.db, selectAccounts+" WHERE a.key_id = ?", kid))
+	switch {
+	case errors.Is(err, sqlDB.ErrNoRows):
+		return nil, acme.ErrNotFound
+	case err != nil:
+		return nil, errors.Wrapf(err, "error loading account for key %s", kid)
+	default:
+		return acc, nil
+	}
+}
+
+// GetAccountsByProvisioner retrieves the ACME accounts of a provisioner.
+func (db *DB) GetAccountsByProvisioner(ctx context.Context, provisionerName string) ([]*acme.Account, error) {
+	rows, err := db.query(ctx, db.db, selectAccounts+" WHERE a.provisioner_name = ? ORDER BY a.created_at", provisionerName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error loading accounts for provisioner %s", provisionerName)
+	}
+	defer rows.Close()
+
+	accounts := []*acme.Account{}
+	for rows.Next() {
+		acc, err := scanAccount(rows)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error loading accounts for provisioner %s", provisionerName)
+		}
+		accounts = append(accounts, acc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "error loading accounts for provisioner %s", provisionerName)
+	}
+	return accounts, nil
+}
+
+// CreateAccount imlements the AcmeDB.CreateAccount interface.
+func (db *DB) CreateAccount(ctx context.Context, acc *acme.Account) error {
+	var err error
+	acc.ID, err = randID()
+	if err != nil {
+		return err
+	}
+
+	kid, err := acme.KeyToID(acc.Key)
+	if err != nil {
+		return err
+	}
+	jwk, err := json.Marshal(acc.Key)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling account key")
+	}
+	contact, err := json.Marshal(acc.Contact)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling account contact")
+	}
+
+	clientCertificate, err := marshal(acc.ClientCertificate, "clientCertificate")
+	if err != nil {
+		return err
+	}
+
+	return db.withTx(ctx, func(tx *sqlDB.Tx) error {
+		err := db.insert(ctx, tx, "acme_accounts",
+			[]string{"id", "key_id", "jwk", "contact", "status", "location_prefix", "provisioner_name", "created_at", "deactivated_at"},
+			acc.ID, kid, string(jwk), string(contact), string(acc.Status), acc.LocationPrefix, acc.ProvisionerName, certdb.NullTime(clock.Now()), sqlDB.NullTime{})
+		switch {
+		case db.dialect.IsUniqueViolation(err):
+			return errors.Errorf("key-id to account-id index already exists")
+		case err != nil:
+			return errors.Wrap(err, "error saving acme account")
+		}
+		if clientCertificate.Valid {
+			if err := db.insert(ctx, tx, "acme_account_client_certificates", []string{"account_id", "client_certificate"}, acc.ID, clientCertificate); err != nil {
+				return errors.Wrap(err, "error saving acme account")
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateAccount imlements the AcmeDB.UpdateAccount interface.
+func (db *DB) UpdateAccount(ctx context.Context, acc *acme.Account) error {
+	contact, err := json.Marshal(acc.Contact)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling account contact")
+	}
+
+	return db.withTx(ctx, func(tx *sqlDB.Tx) error {
+		var status acme.Status
+		err := db.queryRow(ctx, tx, "SELECT status FROM acme_accounts WHERE id = ? FOR UPDATE", acc.ID).Scan(&status)
+		switch {
+		case errors.Is(err, sqlDB.ErrNoRows):
+			return acme.ErrNotFound
+		case err != nil:
+			return errors.Wrapf(err, "error loading account %s", acc.ID)
+		}
+
+		// If the status has changed to 'deactivated', then set deactivatedAt timestamp.
+		if acc.Status == acme.StatusDeactivated && status != acme.StatusDeactivated {
+			_, err = db.exec(ctx, tx, "UPDATE acme_accounts SET contact = ?, status = ?, deactivated_at = ? WHERE id = ?",
+				string(contact), string(acc.Status), certdb.NullTime(clock.Now()), acc.ID)
+		} else {
+			_, err = db.exec(ctx, tx, "UPDATE acme_accounts SET contact = ?, status = ? WHERE id = ?",
+				string(contact), string(acc.Status), acc.ID)
+		}
+		return errors.Wrap(err, "error saving acme account")
+	})
+}
+
+// UpdateAccountKey implements the AcmeDB.UpdateAccountKey interface. It
+// replaces the key of the account. It returns an acme.ErrConflict error if the
+// new key is already bound to an account.
+func (db *DB) UpdateAccountKey(ctx context.Context, acc *acme.Account) error {
+	kid, err := acme.KeyToID(acc.Key)
+	if err != nil {
+		return err
+	}
+	jwk, err := json.Marshal(acc.Key)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling account key")
+	}
+
+	res, err := db.exec(ctx, db.db, "UPDATE acme_accounts SET key_id = ?, jwk = ? WHERE id = ?", kid, string(jwk), acc.ID)
+	switch {
+	case db.dialect.IsUniqueViolation(err):
+		return errors.Wrapf(acme.ErrConflict, "key-id %s is already bound to an account", kid)
+	case err != nil:
+		return errors.Wrap(err, "error saving acme account")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return acme.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package sql
+
+import (
+	"context"
+	sqlDB "database/sql"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/acme"
+	certdb "github.com/smallstep/certificates/db"
+)
+
+const authzColumns = "id, account_id, identifier_type, identifier_value, status, token, fingerprint, challenge_ids, wildcard, expires_at, error"
+
+// scanAuthz scans an authorization and the ids of its challenges.
+func scanAuthz(row scanner) (*acme.Authorization, []string, error) {
+	var (
+		az           acme.Authorization
+		chIDs, azErr sqlDB.NullString
+		expiresAt    sqlDB.NullTime
+		chIDsList    []string
+	)
+	if err := row.Scan(&az.ID, &az.AccountID, &az.Identifier.Type, &az.Identifier.Value, &az.Status,
+		&az.Token, &az.Fingerprint, &chIDs, &az.Wildcard, &expiresAt, &azErr); err != nil {
+		return nil, nil, err
+	}
+	az.ExpiresAt = certdb.TimeValue(expiresAt)
+	if err := unmarshal(chIDs, &chIDsList, "challengeIDs"); err != nil {
+		return nil, nil, err
+	}
+	var err error
+	if az.Error, err = unmarshalError(azErr); err != nil {
+		return nil, nil, err
+	}
+	return &az, chIDsList, nil
+}
+
+// GetAuthorization retrieves and unmarshals an ACME authz type from the database.
+// Implements acme.DB GetAuthorization interface.
+func (db *DB) GetAuthorization(ctx context.Context, id string) (*acme.Authorization, error) {
+	az, chIDs, err := scanAuthz(db.queryRow(ctx, db.db, "SELECT "+authzColumns+" FROM acme_authzs WHERE id = ?", id))
+	switch {
+	case errors.Is(err, sqlDB.ErrNoRows):
+		return nil, acme.NewError(acme.ErrorMalformedType, "authz %s not found", id)
+	case err != nil:
+		return nil, errors.Wrapf(err, "error loading authz %s", id)
+	}
+
+	az.Challenges = make([]*acme.Challenge, len(chIDs))
+	for i, chID := range chIDs {
+		az.Challenges[i], err = db.GetChallenge(ctx, chID, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return az, nil
+}
+
+// CreateAuthorization creates an entry in the database for the Authorization.
+// Implements the acme.DB.CreateAuthorization interface.
+func (db *DB) CreateAuthorization(ctx context.Context, az *acme.Authorization) error {
+	var err error
+	az.ID, err = randID()
+	if err != nil {
+		return err
+	}
+
+	chIDs := make([]string, len(az.Challenges))
+	for i, ch := range az.Challenges {
+		chIDs[i] = ch.ID
+	}
+	chIDsValue, err := marshal(chIDs, "challengeIDs")
+	if err != nil {
+		return err
+	}
+
+	if err := db.insert(ctx, db.db, "acme_authzs",
+		[]string{"id", "account_id", "identifier_type", "identifier_value", "status", "token", "fingerprint", "challenge_ids", "wildcard", "created_at", "expires_at", "error"},
+		az.ID, az.AccountID, string(az.Identifier.Type), az.Identifier.Value, string(az.Status), az.Token, az.Fingerprint,
+		chIDsValue, az.Wildcard, certdb.NullTime(clock.Now()), certdb.NullTime(az.ExpiresAt), sqlDB.NullString{}); err != nil {
+		return errors.Wrap(err, "error saving acme authz")
+	}
+	return nil
+}
+
+// UpdateAuthorization saves an updated ACME Authorization to the database.
+func (db *DB) UpdateAuthorization(ctx context.Context, az *acme.Authorization) error {
+	azErr, err := marshalError(az.Error)
+	if err != nil {
+		return err
+	}
+	return db.withTx(ctx, func(tx *sqlDB.Tx) error {
+		var id string
+		err := db.queryRow(ctx, tx, "SELECT id FROM acme_authzs WHERE id = ? FOR UPDATE", az.ID).Scan(&id)
+		switch {
+		case errors.Is(err, sqlDB.ErrNoRows):
+			return acme.NewError(acme.ErrorMalformedType, "authz %s not found", az.ID)
+		case err != nil:
+			return errors.Wrapf(err, "error loading authz %s", az.ID)
+		}
+		_, err = db.exec(ctx, tx, "UPDATE acme_authzs SET status = ?, fingerprint = ?, error = ? WHERE id = ?",
+			string(az.Status), az.Fingerprint, azErr, az.ID)
+		return errors.Wrap(err, "error saving acme authz")
+	})
+}
+
+// GetAuthorizationsByAccountID retrieves and unmarshals ACME authz types from
+// the database. The challenges are not loaded.
+func (db *DB) GetAuthorizationsByAccountID(ctx context.Context, accountID string) ([]*acme.Authorization, error) {
+	rows, err := db.query(ctx, db.db, "SELECT "+authzColumns+" FROM acme_authzs WHERE account_id = ? ORDER BY created_at", accountID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing authz")
+	}
+	defer rows.Close()
+
+	authzs := []*acme.Authorization{}
+	for rows.Next() {
+		az, _, err := scanAuthz(rows)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error listing authz")
+		}
+		authzs = append(authzs, az)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "error listing authz")
+	}
+	return authzs, nil
+}
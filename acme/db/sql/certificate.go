@@ -0,0 +1,172 @@
+package sql
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	sqlDB "database/sql"
+	"encoding/pem"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/smallstep/certificates/acme"
+	certdb "github.com/smallstep/certificates/db"
+)
+
+// CreateCertificate creates and stores an ACME certificate type.
+func (db *DB) CreateCertificate(ctx context.Context, cert *acme.Certificate) error {
+	var err error
+	cert.ID, err = randID()
+	if err != nil {
+		return err
+	}
+
+	// SSH certificates are stored in the leaf column in the authorized keys
+	// format, the prefix of the serial number keeps them apart from the
+	// serial numbers of the X.509 certificates.
+	if cert.SSH != nil {
+		if err := db.insert(ctx, db.db, "acme_certs",
+			[]string{"id", "serial", "account_id", "order_id", "leaf", "intermediates", "not_after", "created_at"},
+			cert.ID, "ssh:"+cert.SerialNumber(), cert.AccountID, cert.OrderID, ssh.MarshalAuthorizedKey(cert.SSH), []byte{},
+			certdb.NullTime(time.Unix(int64(cert.SSH.ValidBefore), 0)), certdb.NullTime(time.Now())); err != nil {
+			return errors.Wrap(err, "error saving acme certificate")
+		}
+		return nil
+	}
+
+	leaf := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: cert.Leaf.Raw,
+	})
+	intermediates := []byte{}
+	for _, cert := range cert.Intermediates {
+		intermediates = append(intermediates, pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: cert.Raw,
+		})...)
+	}
+
+	if err := db.insert(ctx, db.db, "acme_certs",
+		[]string{"id", "serial", "account_id", "order_id", "leaf", "intermediates", "not_after", "created_at"},
+		cert.ID, cert.Leaf.SerialNumber.String(), cert.AccountID, cert.OrderID, leaf, intermediates,
+		certdb.NullTime(cert.Leaf.NotAfter), certdb.NullTime(time.Now())); err != nil {
+		return errors.Wrap(err, "error saving acme certificate")
+	}
+	return nil
+}
+
+// GetCertificate retrieves and unmarshals an ACME certificate type from the
+// datastore.
+func (db *DB) GetCertificate(ctx context.Context, id string) (*acme.Certificate, error) {
+	cert, err := db.getCertificate(ctx, "id", id)
+	switch {
+	case errors.Is(err, sqlDB.ErrNoRows):
+		return nil, acme.NewError(acme.ErrorMalformedType, "certificate %s not found", id)
+	case err != nil:
+		return nil, errors.Wrapf(err, "error loading certificate %s", id)
+	default:
+		return cert, nil
+	}
+}
+
+// GetCertificateBySerial retrieves and unmarshals an ACME certificate type from the
+// datastore based on a certificate serial number.
+func (db *DB) GetCertificateBySerial(ctx context.Context, serial string) (*acme.Certificate, error) {
+	cert, err := db.getCertificate(ctx, "serial", serial)
+	switch {
+	case errors.Is(err, sqlDB.ErrNoRows):
+		return nil, acme.NewError(acme.ErrorMalformedType, "certificate with serial %s not found", serial)
+	case err != nil:
+		return nil, errors.Wrapf(err, "error loading certificate with serial %s", serial)
+	default:
+		return cert, nil
+	}
+}
+
+// GetCertificatesByAccountID retrieves the certificates issued to an ACME
+// account.
+func (db *DB) GetCertificatesByAccountID(ctx context.Context, accountID string) ([]*acme.Certificate, error) {
+	rows, err := db.query(ctx, db.db, "SELECT "+certColumns+" FROM acme_certs WHERE account_id = ? ORDER BY created_at", accountID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error loading certificates for account %s", accountID)
+	}
+	defer rows.Close()
+
+	certs := []*acme.Certificate{}
+	for rows.Next() {
+		cert, err := scanCertificate(rows)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error loading certificates for account %s", accountID)
+		}
+		certs = append(certs, cert)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "error loading certificates for account %s", accountID)
+	}
+	return certs, nil
+}
+
+const certColumns = "id, account_id, order_id, leaf, intermediates"
+
+func (db *DB) getCertificate(ctx context.Context, column, value string) (*acme.Certificate, error) {
+	return scanCertificate(db.queryRow(ctx, db.db, "SELECT "+certColumns+" FROM acme_certs WHERE "+column+" = ?", value))
+}
+
+func scanCertificate(row scanner) (*acme.Certificate, error) {
+	var (
+		cert                acme.Certificate
+		leaf, intermediates []byte
+	)
+	if err := row.Scan(&cert.ID, &cert.AccountID, &cert.OrderID, &leaf, &intermediates); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(leaf, []byte("-----BEGIN")) {
+		key, _, _, _, err := ssh.ParseAuthorizedKey(leaf)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing ssh certificate for ACME certificate with ID %s", cert.ID)
+		}
+		var ok bool
+		if cert.SSH, ok = key.(*ssh.Certificate); !ok {
+			return nil, errors.Errorf("error parsing ssh certificate for ACME certificate with ID %s: data is not a certificate", cert.ID)
+		}
+		return &cert, nil
+	}
+	certs, err := parseBundle(append(leaf, intermediates...))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing certificate chain for ACME certificate with ID %s", cert.ID)
+	}
+	cert.Leaf, cert.Intermediates = certs[0], certs[1:]
+	return &cert, nil
+}
+
+func parseBundle(b []byte) ([]*x509.Certificate, error) {
+	var (
+		err    error
+		block  *pem.Block
+		bundle []*x509.Certificate
+	)
+	for len(b) > 0 {
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, errors.New("error decoding PEM: data contains block that is not a certificate")
+		}
+		var crt *x509.Certificate
+		crt, err = x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing x509 certificate")
+		}
+		bundle = append(bundle, crt)
+	}
+	if len(b) > 0 {
+		return nil, errors.New("error decoding PEM: unexpected data")
+	}
+	if len(bundle) == 0 {
+		return nil, errors.New("error decoding PEM: data does not contain a certificate")
+	}
+	return bundle, nil
+}
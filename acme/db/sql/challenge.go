@@ -0,0 +1,101 @@
+package sql
+
+import (
+	"context"
+	sqlDB "database/sql"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/acme"
+	certdb "github.com/smallstep/certificates/db"
+)
+
+// CreateChallenge creates a new ACME challenge data structure in the database.
+// Implements acme.DB.CreateChallenge interface.
+func (db *DB) CreateChallenge(ctx context.Context, ch *acme.Challenge) error {
+	var err error
+	ch.ID, err = randID()
+	if err != nil {
+		return errors.Wrap(err, "error generating random id for ACME challenge")
+	}
+
+	return db.withTx(ctx, func(tx *sqlDB.Tx) error {
+		if err := db.insert(ctx, tx, "acme_challenges",
+			[]string{"id", "account_id", "type", "status", "token", "value", "validated_at", "created_at", "error", "validation_record"},
+			ch.ID, ch.AccountID, string(ch.Type), string(acme.StatusPending), ch.Token, ch.Value, "",
+			certdb.NullTime(clock.Now()), sqlDB.NullString{}, sqlDB.NullString{}); err != nil {
+			return errors.Wrap(err, "error saving acme challenge")
+		}
+		// The sender and the first part of the token of the email-reply-00
+		// challenges are kept in their own table.
+		if ch.Type == acme.EMAILREPLY00 {
+			if err := db.insert(ctx, tx, "acme_email_challenges",
+				[]string{"id", "from_address", "token_part1"},
+				ch.ID, ch.From, ch.TokenPart1); err != nil {
+				return errors.Wrap(err, "error saving acme challenge")
+			}
+		}
+		return nil
+	})
+}
+
+// GetChallenge retrieves and unmarshals an ACME challenge type from the database.
+// Implements the acme.DB GetChallenge interface.
+func (db *DB) GetChallenge(ctx context.Context, id, authzID string) (*acme.Challenge, error) {
+	_ = authzID // unused input
+	return db.getChallenge(ctx, db.db, id, false)
+}
+
+func (db *DB) getChallenge(ctx context.Context, q queryer, id string, forUpdate bool) (*acme.Challenge, error) {
+	query := "SELECT id, account_id, type, status, token, value, validated_at, error, validation_record FROM acme_challenges WHERE id = ?"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	var (
+		ch             acme.Challenge
+		chErr, records sqlDB.NullString
+	)
+	err := db.queryRow(ctx, q, query, id).Scan(&ch.ID, &ch.AccountID, &ch.Type, &ch.Status, &ch.Token, &ch.Value, &ch.ValidatedAt, &chErr, &records)
+	switch {
+	case errors.Is(err, sqlDB.ErrNoRows):
+		return nil, acme.NewError(acme.ErrorMalformedType, "challenge %s not found", id)
+	case err != nil:
+		return nil, errors.Wrapf(err, "error loading acme challenge %s", id)
+	}
+	if ch.Error, err = unmarshalError(chErr); err != nil {
+		return nil, err
+	}
+	if err := unmarshal(records, &ch.ValidationRecord, "validationRecord"); err != nil {
+		return nil, err
+	}
+	if ch.Type == acme.EMAILREPLY00 {
+		err := db.queryRow(ctx, q, "SELECT from_address, token_part1 FROM acme_email_challenges WHERE id = ?", id).Scan(&ch.From, &ch.TokenPart1)
+		if err != nil && !errors.Is(err, sqlDB.ErrNoRows) {
+			return nil, errors.Wrapf(err, "error loading acme challenge %s", id)
+		}
+	}
+	return &ch, nil
+}
+
+// UpdateChallenge updates an ACME challenge type in the database. The
+// challenge is locked while it is updated, so concurrent validations of the
+// same challenge are serialized.
+func (db *DB) UpdateChallenge(ctx context.Context, ch *acme.Challenge) error {
+	chErr, err := marshalError(ch.Error)
+	if err != nil {
+		return err
+	}
+	records, err := marshal(ch.ValidationRecord, "validationRecord")
+	if err != nil {
+		return err
+	}
+	return db.withTx(ctx, func(tx *sqlDB.Tx) error {
+		if _, err := db.getChallenge(ctx, tx, ch.ID, true); err != nil {
+			return err
+		}
+		// These should be the only values changing in an Update request.
+		_, err := db.exec(ctx, tx, "UPDATE acme_challenges SET status = ?, error = ?, validated_at = ?, validation_record = ? WHERE id = ?",
+			string(ch.Status), chErr, ch.ValidatedAt, records, ch.ID)
+		return errors.Wrap(err, "error saving acme challenge")
+	})
+}
@@ -0,0 +1,109 @@
+package sql
+
+import (
+	"context"
+	sqlDB "database/sql"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/acme"
+	certdb "github.com/smallstep/certificates/db"
+)
+
+const deferredOrderColumns = "order_id, account_id, provisioner_id, identifiers, csr, status, created_at, decided_at, decided_by, reason"
+
+func scanDeferredOrder(s scanner) (*acme.DeferredOrder, error) {
+	var (
+		d                    acme.DeferredOrder
+		identifiers          sqlDB.NullString
+		createdAt, decidedAt sqlDB.NullTime
+	)
+	if err := s.Scan(&d.OrderID, &d.AccountID, &d.ProvisionerID, &identifiers, &d.CSR, &d.Status, &createdAt, &decidedAt, &d.DecidedBy, &d.Reason); err != nil {
+		return nil, err
+	}
+	d.CreatedAt, d.DecidedAt = certdb.TimeValue(createdAt), certdb.TimeValue(decidedAt)
+	if err := unmarshal(identifiers, &d.Identifiers, "identifiers"); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// CreateDeferredOrder stores the finalization of an order that requires an
+// approval. A previous deferred order of the same order is replaced.
+func (db *DB) CreateDeferredOrder(ctx context.Context, d *acme.DeferredOrder) error {
+	identifiers, err := marshal(d.Identifiers, "identifiers")
+	if err != nil {
+		return err
+	}
+	createdAt := clock.Now()
+	if err := db.withTx(ctx, func(tx *sqlDB.Tx) error {
+		if _, err := db.exec(ctx, tx, "DELETE FROM acme_deferred_orders WHERE order_id = ?", d.OrderID); err != nil {
+			return err
+		}
+		return db.insert(ctx, tx, "acme_deferred_orders",
+			[]string{"order_id", "account_id", "provisioner_id", "identifiers", "csr", "status", "created_at", "decided_at", "decided_by", "reason"},
+			d.OrderID, d.AccountID, d.ProvisionerID, identifiers, d.CSR, string(d.Status),
+			certdb.NullTime(createdAt), sqlDB.NullTime{}, "", "")
+	}); err != nil {
+		return errors.Wrapf(err, "error saving acme deferred order %s", d.OrderID)
+	}
+	d.CreatedAt = createdAt
+	return nil
+}
+
+// GetDeferredOrder retrieves the deferred order of an order.
+func (db *DB) GetDeferredOrder(ctx context.Context, orderID string) (*acme.DeferredOrder, error) {
+	d, err := scanDeferredOrder(db.queryRow(ctx, db.db, "SELECT "+deferredOrderColumns+" FROM acme_deferred_orders WHERE order_id = ?", orderID))
+	switch {
+	case errors.Is(err, sqlDB.ErrNoRows):
+		return nil, acme.NewError(acme.ErrorMalformedType, "deferred order %s not found", orderID)
+	case err != nil:
+		return nil, errors.Wrapf(err, "error loading deferred order %s", orderID)
+	}
+	return d, nil
+}
+
+// GetDeferredOrders retrieves the deferred orders of a provisioner that are
+// waiting for an approval.
+func (db *DB) GetDeferredOrders(ctx context.Context, provisionerID string) ([]*acme.DeferredOrder, error) {
+	rows, err := db.query(ctx, db.db, "SELECT "+deferredOrderColumns+" FROM acme_deferred_orders WHERE provisioner_id = ? AND status = ? ORDER BY created_at",
+		provisionerID, string(acme.ApprovalPending))
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing deferred orders")
+	}
+	defer rows.Close()
+
+	orders := []*acme.DeferredOrder{}
+	for rows.Next() {
+		d, err := scanDeferredOrder(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading deferred order")
+		}
+		orders = append(orders, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error listing deferred orders")
+	}
+	return orders, nil
+}
+
+// UpdateDeferredOrder saves the decision on a deferred order. It returns
+// acme.ErrConflict if the stored deferred order does not have the given
+// status.
+func (db *DB) UpdateDeferredOrder(ctx context.Context, d *acme.DeferredOrder, from acme.ApprovalStatus) error {
+	return db.withTx(ctx, func(tx *sqlDB.Tx) error {
+		var status acme.ApprovalStatus
+		err := db.queryRow(ctx, tx, "SELECT status FROM acme_deferred_orders WHERE order_id = ? FOR UPDATE", d.OrderID).Scan(&status)
+		switch {
+		case errors.Is(err, sqlDB.ErrNoRows):
+			return acme.NewError(acme.ErrorMalformedType, "deferred order %s not found", d.OrderID)
+		case err != nil:
+			return errors.Wrapf(err, "error loading deferred order %s", d.OrderID)
+		case status != from:
+			return errors.Wrapf(acme.ErrConflict, "error saving acme deferred order; deferred order %s has status %s", d.OrderID, status)
+		}
+		_, err = db.exec(ctx, tx, "UPDATE acme_deferred_orders SET status = ?, decided_at = ?, decided_by = ?, reason = ? WHERE order_id = ?",
+			string(d.Status), certdb.NullTime(d.DecidedAt), d.DecidedBy, d.Reason, d.OrderID)
+		return errors.Wrap(err, "error saving acme deferred order")
+	})
+}
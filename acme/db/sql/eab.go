@@ -0,0 +1,198 @@
+package sql
+
+import (
+	"context"
+	"crypto/rand"
+	sqlDB "database/sql"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/acme"
+	certdb "github.com/smallstep/certificates/db"
+)
+
+const externalAccountKeyColumns = "id, provisioner_id, reference, account_id, hmac_key, created_at, bound_at"
+
+func scanExternalAccountKey(row scanner) (*acme.ExternalAccountKey, error) {
+	var (
+		eak                acme.ExternalAccountKey
+		createdAt, boundAt sqlDB.NullTime
+	)
+	if err := row.Scan(&eak.ID, &eak.ProvisionerID, &eak.Reference, &eak.AccountID, &eak.HmacKey, &createdAt, &boundAt); err != nil {
+		return nil, err
+	}
+	eak.CreatedAt, eak.BoundAt = certdb.TimeValue(createdAt), certdb.TimeValue(boundAt)
+	return &eak, nil
+}
+
+func (db *DB) getExternalAccountKey(ctx context.Context, q queryer, id string, forUpdate bool) (*acme.ExternalAccountKey, error) {
+	query := "SELECT " + externalAccountKeyColumns + " FROM acme_external_account_keys WHERE id = ?"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	eak, err := scanExternalAccountKey(db.queryRow(ctx, q, query, id))
+	switch {
+	case errors.Is(err, sqlDB.ErrNoRows):
+		return nil, acme.ErrNotFound
+	case err != nil:
+		return nil, errors.Wrapf(err, "error loading external account key %s", id)
+	default:
+		return eak, nil
+	}
+}
+
+// CreateExternalAccountKey creates a new External Account Binding key with a name
+func (db *DB) CreateExternalAccountKey(ctx context.Context, provisionerID, reference string) (*acme.ExternalAccountKey, error) {
+	keyID, err := randID()
+	if err != nil {
+		return nil, err
+	}
+
+	random := make([]byte, 32)
+	_, err = rand.Read(random)
+	if err != nil {
+		return nil, err
+	}
+
+	eak := &acme.ExternalAccountKey{
+		ID:            keyID,
+		ProvisionerID: provisionerID,
+		Reference:     reference,
+		HmacKey:       random,
+		CreatedAt:     clock.Now(),
+	}
+	if err := db.insert(ctx, db.db, "acme_external_account_keys",
+		[]string{"id", "provisioner_id", "reference", "account_id", "hmac_key", "created_at", "bound_at"},
+		eak.ID, eak.ProvisionerID, eak.Reference, eak.AccountID, eak.HmacKey, certdb.NullTime(eak.CreatedAt), sqlDB.NullTime{}); err != nil {
+		return nil, errors.Wrap(err, "error saving acme external_account_key")
+	}
+	return eak, nil
+}
+
+// GetExternalAccountKey retrieves an External Account Binding key by KeyID
+func (db *DB) GetExternalAccountKey(ctx context.Context, provisionerID, keyID string) (*acme.ExternalAccountKey, error) {
+	eak, err := db.getExternalAccountKey(ctx, db.db, keyID, false)
+	if err != nil {
+		return nil, err
+	}
+	if eak.ProvisionerID != provisionerID {
+		return nil, acme.NewError(acme.ErrorUnauthorizedType, "provisioner does not match provisioner for which the EAB key was created")
+	}
+	return eak, nil
+}
+
+// DeleteExternalAccountKey deletes the External Account Binding key with the
+// given KeyID.
+func (db *DB) DeleteExternalAccountKey(ctx context.Context, provisionerID, keyID string) error {
+	return db.withTx(ctx, func(tx *sqlDB.Tx) error {
+		eak, err := db.getExternalAccountKey(ctx, tx, keyID, true)
+		if err != nil {
+			return errors.Wrapf(err, "error loading ACME EAB Key with Key ID %s", keyID)
+		}
+		if eak.ProvisionerID != provisionerID {
+			return errors.New("provisioner does not match provisioner for which the EAB key was created")
+		}
+		if _, err := db.exec(ctx, tx, "DELETE FROM acme_external_account_keys WHERE id = ?", keyID); err != nil {
+			return errors.Wrapf(err, "error deleting ACME EAB Key with Key ID %s", keyID)
+		}
+		return nil
+	})
+}
+
+// GetExternalAccountKeys retrieves all External Account Binding keys for a
+// provisioner. The cursor is the id of the last key returned, and limit is
+// the maximum number of keys to return, if it is greater than zero.
+func (db *DB) GetExternalAccountKeys(ctx context.Context, provisionerID, cursor string, limit int) ([]*acme.ExternalAccountKey, string, error) {
+	query := "SELECT " + externalAccountKeyColumns + " FROM acme_external_account_keys WHERE provisioner_id = ? AND id > ? ORDER BY id"
+	args := []interface{}{provisionerID, cursor}
+	if limit > 0 {
+		// Get one more key to know if there is a next page.
+		query += " LIMIT ?"
+		args = append(args, limit+1)
+	}
+	rows, err := db.query(ctx, db.db, query, args...)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "error loading ACME EAB Keys for provisioner %s", provisionerID)
+	}
+	defer rows.Close()
+
+	keys := []*acme.ExternalAccountKey{}
+	for rows.Next() {
+		eak, err := scanExternalAccountKey(rows)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "error loading ACME EAB Keys for provisioner %s", provisionerID)
+		}
+		keys = append(keys, eak)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", errors.Wrapf(err, "error loading ACME EAB Keys for provisioner %s", provisionerID)
+	}
+
+	var nextCursor string
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+		nextCursor = keys[limit-1].ID
+	}
+	return keys, nextCursor, nil
+}
+
+// GetExternalAccountKeyByReference retrieves an External Account Binding key with unique reference
+func (db *DB) GetExternalAccountKeyByReference(ctx context.Context, provisionerID, reference string) (*acme.ExternalAccountKey, error) {
+	if reference == "" {
+		//nolint:nilnil // legacy
+		return nil, nil
+	}
+
+	eak, err := scanExternalAccountKey(db.queryRow(ctx, db.db, "SELECT "+externalAccountKeyColumns+" FROM acme_external_account_keys WHERE provisioner_id = ? AND reference = ?", provisionerID, reference))
+	switch {
+	case errors.Is(err, sqlDB.ErrNoRows):
+		return nil, acme.ErrNotFound
+	case err != nil:
+		return nil, errors.Wrapf(err, "error loading ACME EAB key for reference %s", reference)
+	default:
+		return eak, nil
+	}
+}
+
+// GetExternalAccountKeyByAccountID retrieves the External Account Binding key
+// bound to the given account. It returns nil if the account is not bound to a
+// key.
+func (db *DB) GetExternalAccountKeyByAccountID(ctx context.Context, provisionerID, accountID string) (*acme.ExternalAccountKey, error) {
+	eak, err := scanExternalAccountKey(db.queryRow(ctx, db.db, "SELECT "+externalAccountKeyColumns+" FROM acme_external_account_keys WHERE provisioner_id = ? AND account_id = ?", provisionerID, accountID))
+	switch {
+	case errors.Is(err, sqlDB.ErrNoRows):
+		//nolint:nilnil // same behavior as the nosql implementation
+		return nil, nil
+	case err != nil:
+		return nil, errors.Wrapf(err, "error loading ACME EAB key for account %s", accountID)
+	default:
+		return eak, nil
+	}
+}
+
+// UpdateExternalAccountKey updates the account binding of an External Account
+// Binding key.
+func (db *DB) UpdateExternalAccountKey(ctx context.Context, provisionerID string, eak *acme.ExternalAccountKey) error {
+	return db.withTx(ctx, func(tx *sqlDB.Tx) error {
+		old, err := db.getExternalAccountKey(ctx, tx, eak.ID, true)
+		if err != nil {
+			return err
+		}
+
+		if old.ProvisionerID != provisionerID {
+			return errors.New("provisioner does not match provisioner for which the EAB key was created")
+		}
+
+		if old.ProvisionerID != eak.ProvisionerID {
+			return errors.New("cannot change provisioner for an existing ACME EAB Key")
+		}
+
+		if old.Reference != eak.Reference {
+			return errors.New("cannot change reference for an existing ACME EAB Key")
+		}
+
+		_, err = db.exec(ctx, tx, "UPDATE acme_external_account_keys SET account_id = ?, hmac_key = ?, bound_at = ? WHERE id = ?",
+			eak.AccountID, eak.HmacKey, certdb.NullTime(eak.BoundAt), eak.ID)
+		return errors.Wrap(err, "error saving acme external_account_key")
+	})
+}
@@ -0,0 +1,45 @@
+package sql
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/acme"
+	certdb "github.com/smallstep/certificates/db"
+)
+
+// CreateNonce creates, stores, and returns an ACME replay-nonce.
+// Implements the acme.DB interface.
+func (db *DB) CreateNonce(ctx context.Context) (acme.Nonce, error) {
+	_id, err := randID()
+	if err != nil {
+		return "", err
+	}
+
+	id := base64.RawURLEncoding.EncodeToString([]byte(_id))
+	if err := db.insert(ctx, db.db, "acme_nonces", []string{"id", "created_at"}, id, certdb.NullTime(clock.Now())); err != nil {
+		return "", errors.Wrap(err, "error saving acme nonce")
+	}
+	return acme.Nonce(id), nil
+}
+
+// DeleteNonce verifies that the nonce is valid (by checking if it exists),
+// and if so, consumes the nonce resource by deleting it from the database.
+// The nonce is only valid if this call deletes it.
+func (db *DB) DeleteNonce(ctx context.Context, nonce acme.Nonce) error {
+	res, err := db.exec(ctx, db.db, "DELETE FROM acme_nonces WHERE id = ?", string(nonce))
+	if err != nil {
+		return errors.Wrapf(err, "error deleting nonce %s", string(nonce))
+	}
+	n, err := res.RowsAffected()
+	switch {
+	case err != nil:
+		return errors.Wrapf(err, "error deleting nonce %s", string(nonce))
+	case n == 0:
+		return acme.NewError(acme.ErrorBadNonceType, "nonce %s not found", string(nonce))
+	default:
+		return nil
+	}
+}
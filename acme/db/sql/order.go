@@ -0,0 +1,197 @@
+package sql
+
+import (
+	"context"
+	sqlDB "database/sql"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/acme"
+	certdb "github.com/smallstep/certificates/db"
+)
+
+// GetOrder retrieves an ACME Order from the database.
+func (db *DB) GetOrder(ctx context.Context, id string) (*acme.Order, error) {
+	var (
+		o                              acme.Order
+		identifiers, authzIDs, oErr    sqlDB.NullString
+		notBefore, notAfter, expiresAt sqlDB.NullTime
+	)
+	err := db.queryRow(ctx, db.db, "SELECT id, account_id, provisioner_id, identifiers, authorization_ids, status, not_before, not_after, expires_at, certificate_id, error FROM acme_orders WHERE id = ?", id).
+		Scan(&o.ID, &o.AccountID, &o.ProvisionerID, &identifiers, &authzIDs, &o.Status, &notBefore, &notAfter, &expiresAt, &o.CertificateID, &oErr)
+	switch {
+	case errors.Is(err, sqlDB.ErrNoRows):
+		return nil, acme.NewError(acme.ErrorMalformedType, "order %s not found", id)
+	case err != nil:
+		return nil, errors.Wrapf(err, "error loading order %s", id)
+	}
+
+	o.NotBefore, o.NotAfter, o.ExpiresAt = certdb.TimeValue(notBefore), certdb.TimeValue(notAfter), certdb.TimeValue(expiresAt)
+	if err := unmarshal(identifiers, &o.Identifiers, "identifiers"); err != nil {
+		return nil, err
+	}
+	if err := unmarshal(authzIDs, &o.AuthorizationIDs, "authorizationIDs"); err != nil {
+		return nil, err
+	}
+	if o.Error, err = unmarshalError(oErr); err != nil {
+		return nil, err
+	}
+	// The auto-renewal object of the STAR orders is kept in its own table.
+	var autoRenewal sqlDB.NullString
+	err = db.queryRow(ctx, db.db, "SELECT auto_renewal FROM acme_order_auto_renewals WHERE order_id = ?", id).Scan(&autoRenewal)
+	if err != nil && !errors.Is(err, sqlDB.ErrNoRows) {
+		return nil, errors.Wrapf(err, "error loading order %s", id)
+	}
+	if err := unmarshal(autoRenewal, &o.AutoRenewal, "autoRenewal"); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// CreateOrder creates ACME Order resources and saves them to the DB.
+func (db *DB) CreateOrder(ctx context.Context, o *acme.Order) error {
+	var err error
+	o.ID, err = randID()
+	if err != nil {
+		return err
+	}
+
+	identifiers, err := marshal(o.Identifiers, "identifiers")
+	if err != nil {
+		return err
+	}
+	authzIDs, err := marshal(o.AuthorizationIDs, "authorizationIDs")
+	if err != nil {
+		return err
+	}
+
+	return db.withTx(ctx, func(tx *sqlDB.Tx) error {
+		if err := db.insert(ctx, tx, "acme_orders",
+			[]string{"id", "account_id", "provisioner_id", "identifiers", "authorization_ids", "status", "not_before", "not_after", "created_at", "expires_at", "certificate_id", "error"},
+			o.ID, o.AccountID, o.ProvisionerID, identifiers, authzIDs, string(o.Status),
+			certdb.NullTime(o.NotBefore), certdb.NullTime(o.NotAfter), certdb.NullTime(clock.Now()), certdb.NullTime(o.ExpiresAt),
+			"", sqlDB.NullString{}); err != nil {
+			return errors.Wrap(err, "error saving acme order")
+		}
+		if o.AutoRenewal != nil {
+			autoRenewal, err := marshal(o.AutoRenewal, "autoRenewal")
+			if err != nil {
+				return err
+			}
+			if err := db.insert(ctx, tx, "acme_order_auto_renewals", []string{"order_id", "auto_renewal"}, o.ID, autoRenewal); err != nil {
+				return errors.Wrap(err, "error saving acme order")
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateOrder saves an updated ACME Order to the database.
+func (db *DB) UpdateOrder(ctx context.Context, o *acme.Order) error {
+	return db.updateOrder(ctx, o, "")
+}
+
+// UpdateOrderStatus saves the status, error and certificate of an order only if
+// the stored order has the given status. It returns an acme.ErrConflict error if
+// the order has a different status.
+func (db *DB) UpdateOrderStatus(ctx context.Context, o *acme.Order, from acme.Status) error {
+	return db.updateOrder(ctx, o, from)
+}
+
+// updateOrder locks the order and updates its status, error and certificate.
+// If from is not empty, the order is only updated if it has that status.
+func (db *DB) updateOrder(ctx context.Context, o *acme.Order, from acme.Status) error {
+	oErr, err := marshalError(o.Error)
+	if err != nil {
+		return err
+	}
+	return db.withTx(ctx, func(tx *sqlDB.Tx) error {
+		var status acme.Status
+		err := db.queryRow(ctx, tx, "SELECT status FROM acme_orders WHERE id = ? FOR UPDATE", o.ID).Scan(&status)
+		switch {
+		case errors.Is(err, sqlDB.ErrNoRows):
+			return acme.NewError(acme.ErrorMalformedType, "order %s not found", o.ID)
+		case err != nil:
+			return errors.Wrapf(err, "error loading order %s", o.ID)
+		case from != "" && status != from:
+			return errors.Wrapf(acme.ErrConflict, "error saving acme order; order %s has status %s", o.ID, status)
+		}
+		_, err = db.exec(ctx, tx, "UPDATE acme_orders SET status = ?, error = ?, certificate_id = ? WHERE id = ?",
+			string(o.Status), oErr, o.CertificateID, o.ID)
+		return errors.Wrap(err, "error saving acme order")
+	})
+}
+
+// GetOrdersByAccountID returns a list of order IDs owned by the account.
+//
+// According to RFC 8555:
+// The server SHOULD include pending orders and SHOULD NOT include orders
+// that are invalid in the array of URLs.
+func (db *DB) GetOrdersByAccountID(ctx context.Context, accID string) ([]string, error) {
+	rows, err := db.query(ctx, db.db, "SELECT id FROM acme_orders WHERE account_id = ? AND status = ? ORDER BY created_at", accID, string(acme.StatusPending))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error loading orderIDs for account %s", accID)
+	}
+	var oids []string
+	for rows.Next() {
+		var oid string
+		if err := rows.Scan(&oid); err != nil {
+			rows.Close()
+			return nil, errors.Wrapf(err, "error loading orderIDs for account %s", accID)
+		}
+		oids = append(oids, oid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "error loading orderIDs for account %s", accID)
+	}
+
+	// Update the status of the pending orders, they might be ready or expired.
+	pendOids := []string{}
+	for _, oid := range oids {
+		o, err := db.GetOrder(ctx, oid)
+		if err != nil {
+			return nil, acme.WrapErrorISE(err, "error loading order %s for account %s", oid, accID)
+		}
+		if err = o.UpdateStatus(ctx, db); err != nil {
+			return nil, acme.WrapErrorISE(err, "error updating order %s for account %s", oid, accID)
+		}
+		if o.Status == acme.StatusPending {
+			pendOids = append(pendOids, oid)
+		}
+	}
+	return pendOids, nil
+}
+
+// GetAllOrdersByAccountID returns all the orders owned by the account, in any
+// status. Unlike GetOrdersByAccountID, the status of the orders is not
+// updated.
+func (db *DB) GetAllOrdersByAccountID(ctx context.Context, accID string) ([]*acme.Order, error) {
+	rows, err := db.query(ctx, db.db, "SELECT id FROM acme_orders WHERE account_id = ? ORDER BY created_at", accID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error loading orders for account %s", accID)
+	}
+	var oids []string
+	for rows.Next() {
+		var oid string
+		if err := rows.Scan(&oid); err != nil {
+			rows.Close()
+			return nil, errors.Wrapf(err, "error loading orders for account %s", accID)
+		}
+		oids = append(oids, oid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "error loading orders for account %s", accID)
+	}
+
+	orders := make([]*acme.Order, 0, len(oids))
+	for _, oid := range oids {
+		o, err := db.GetOrder(ctx, oid)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
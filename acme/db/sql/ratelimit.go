@@ -0,0 +1,123 @@
+package sql
+
+import (
+	"context"
+	sqlDB "database/sql"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/acme"
+	certdb "github.com/smallstep/certificates/db"
+)
+
+// rateLimitSweepInterval is the minimum time between two deletions of the
+// expired rate limits.
+const rateLimitSweepInterval = 10 * time.Minute
+
+// GetRateLimitEvents retrieves the times of the requests counted by a rate
+// limit. It returns nil if there are no requests.
+func (db *DB) GetRateLimitEvents(ctx context.Context, key string) ([]time.Time, error) {
+	var events sqlDB.NullString
+	err := db.queryRow(ctx, db.db, "SELECT events FROM acme_rate_limits WHERE id = ?", key).Scan(&events)
+	switch {
+	case errors.Is(err, sqlDB.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, errors.Wrapf(err, "error loading rate limit %s", key)
+	}
+	var ts []time.Time
+	if err := unmarshal(events, &ts, "events"); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// UpdateRateLimitEvents replaces the times of the requests counted by a rate
+// limit, the rate limit is deleted after expiresAt. It returns
+// acme.ErrConflict if the stored times are not the old ones.
+//
+// The expired rate limits are deleted by the updates, at most once every
+// rateLimitSweepInterval.
+func (db *DB) UpdateRateLimitEvents(ctx context.Context, key string, events, old []time.Time, expiresAt time.Time) error {
+	if err := db.updateRateLimitEvents(ctx, key, events, old, expiresAt); err != nil {
+		return err
+	}
+	if err := db.sweepRateLimits(ctx, clock.Now()); err != nil {
+		log.Printf("error deleting expired acme rate limits: %v", err)
+	}
+	return nil
+}
+
+func (db *DB) updateRateLimitEvents(ctx context.Context, key string, events, old []time.Time, expiresAt time.Time) error {
+	return db.withTx(ctx, func(tx *sqlDB.Tx) error {
+		var stored sqlDB.NullString
+		err := db.queryRow(ctx, tx, "SELECT events FROM acme_rate_limits WHERE id = ? FOR UPDATE", key).Scan(&stored)
+		switch {
+		case errors.Is(err, sqlDB.ErrNoRows):
+			if len(old) > 0 {
+				return errors.Wrapf(acme.ErrConflict, "error saving acme rate limit %s", key)
+			}
+			if len(events) == 0 {
+				return nil
+			}
+			b, err := marshal(events, "events")
+			if err != nil {
+				return err
+			}
+			if err := db.insert(ctx, tx, "acme_rate_limits", []string{"id", "events", "expires_at"}, key, b, certdb.NullTime(expiresAt)); err != nil {
+				// A concurrent request might have created the row.
+				return errors.Wrapf(acme.ErrConflict, "error saving acme rate limit %s: %v", key, err)
+			}
+			return nil
+		case err != nil:
+			return errors.Wrapf(err, "error loading rate limit %s", key)
+		}
+
+		var ts []time.Time
+		if err := unmarshal(stored, &ts, "events"); err != nil {
+			return err
+		}
+		if !equalTimes(ts, old) {
+			return errors.Wrapf(acme.ErrConflict, "error saving acme rate limit %s", key)
+		}
+		if len(events) == 0 {
+			_, err = db.exec(ctx, tx, "DELETE FROM acme_rate_limits WHERE id = ?", key)
+			return errors.Wrap(err, "error saving acme rate limit")
+		}
+		b, err := marshal(events, "events")
+		if err != nil {
+			return err
+		}
+		_, err = db.exec(ctx, tx, "UPDATE acme_rate_limits SET events = ?, expires_at = ? WHERE id = ?", b, certdb.NullTime(expiresAt), key)
+		return errors.Wrap(err, "error saving acme rate limit")
+	})
+}
+
+// sweepRateLimits deletes the rate limits that expired before now. It does
+// nothing if the last sweep was less than rateLimitSweepInterval ago.
+func (db *DB) sweepRateLimits(ctx context.Context, now time.Time) error {
+	db.sweepMu.Lock()
+	if now.Sub(db.sweptAt) < rateLimitSweepInterval {
+		db.sweepMu.Unlock()
+		return nil
+	}
+	db.sweptAt = now
+	db.sweepMu.Unlock()
+
+	_, err := db.exec(ctx, db.db, "DELETE FROM acme_rate_limits WHERE expires_at IS NULL OR expires_at < ?", certdb.NullTime(now))
+	return errors.Wrap(err, "error deleting rate limits")
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
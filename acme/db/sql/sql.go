@@ -0,0 +1,289 @@
+// Package sql implements the acme.DB interface using a relational database,
+// with a table per type of ACME object and the indexes required to look up the
+// orders, authorizations and certificates of an account.
+package sql
+
+import (
+	"context"
+	sqlDB "database/sql"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/randutil"
+
+	"github.com/smallstep/certificates/acme"
+	certdb "github.com/smallstep/certificates/db"
+)
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS acme_accounts (
+		id VARCHAR(64) NOT NULL PRIMARY KEY,
+		key_id VARCHAR(255) NOT NULL,
+		jwk TEXT NOT NULL,
+		contact TEXT NOT NULL,
+		status VARCHAR(32) NOT NULL,
+		location_prefix TEXT NOT NULL,
+		provisioner_name VARCHAR(255) NOT NULL,
+		created_at {{time}} NULL,
+		deactivated_at {{time}} NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS acme_account_client_certificates (
+		account_id VARCHAR(64) NOT NULL PRIMARY KEY,
+		client_certificate TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS acme_external_account_keys (
+		id VARCHAR(64) NOT NULL PRIMARY KEY,
+		provisioner_id VARCHAR(255) NOT NULL,
+		reference VARCHAR(255) NOT NULL,
+		account_id VARCHAR(64) NOT NULL,
+		hmac_key {{blob}} NOT NULL,
+		created_at {{time}} NULL,
+		bound_at {{time}} NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS acme_nonces (
+		id VARCHAR(255) NOT NULL PRIMARY KEY,
+		created_at {{time}} NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS acme_authzs (
+		id VARCHAR(64) NOT NULL PRIMARY KEY,
+		account_id VARCHAR(64) NOT NULL,
+		identifier_type VARCHAR(32) NOT NULL,
+		identifier_value VARCHAR(255) NOT NULL,
+		status VARCHAR(32) NOT NULL,
+		token VARCHAR(255) NOT NULL,
+		fingerprint TEXT NOT NULL,
+		challenge_ids TEXT NOT NULL,
+		wildcard BOOLEAN NOT NULL,
+		created_at {{time}} NULL,
+		expires_at {{time}} NULL,
+		error TEXT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS acme_challenges (
+		id VARCHAR(64) NOT NULL PRIMARY KEY,
+		account_id VARCHAR(64) NOT NULL,
+		type VARCHAR(32) NOT NULL,
+		status VARCHAR(32) NOT NULL,
+		token VARCHAR(255) NOT NULL,
+		value VARCHAR(255) NOT NULL,
+		validated_at VARCHAR(64) NOT NULL,
+		created_at {{time}} NULL,
+		error TEXT NULL,
+		validation_record TEXT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS acme_email_challenges (
+		id VARCHAR(64) NOT NULL PRIMARY KEY,
+		from_address VARCHAR(255) NOT NULL,
+		token_part1 VARCHAR(255) NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS acme_orders (
+		id VARCHAR(64) NOT NULL PRIMARY KEY,
+		account_id VARCHAR(64) NOT NULL,
+		provisioner_id VARCHAR(255) NOT NULL,
+		identifiers TEXT NOT NULL,
+		authorization_ids TEXT NOT NULL,
+		status VARCHAR(32) NOT NULL,
+		not_before {{time}} NULL,
+		not_after {{time}} NULL,
+		created_at {{time}} NULL,
+		expires_at {{time}} NULL,
+		certificate_id VARCHAR(64) NOT NULL,
+		error TEXT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS acme_certs (
+		id VARCHAR(64) NOT NULL PRIMARY KEY,
+		serial VARCHAR(255) NOT NULL,
+		account_id VARCHAR(64) NOT NULL,
+		order_id VARCHAR(64) NOT NULL,
+		leaf {{blob}} NOT NULL,
+		intermediates {{blob}} NOT NULL,
+		not_after {{time}} NULL,
+		created_at {{time}} NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS acme_deferred_orders (
+		order_id VARCHAR(64) NOT NULL PRIMARY KEY,
+		account_id VARCHAR(64) NOT NULL,
+		provisioner_id VARCHAR(255) NOT NULL,
+		identifiers TEXT NOT NULL,
+		csr {{blob}} NOT NULL,
+		status VARCHAR(32) NOT NULL,
+		created_at {{time}} NULL,
+		decided_at {{time}} NULL,
+		decided_by VARCHAR(255) NOT NULL,
+		reason TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS acme_order_auto_renewals (
+		order_id VARCHAR(64) NOT NULL PRIMARY KEY,
+		auto_renewal TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS acme_star_orders (
+		order_id VARCHAR(64) NOT NULL PRIMARY KEY,
+		account_id VARCHAR(64) NOT NULL,
+		provisioner_id VARCHAR(255) NOT NULL,
+		csr {{blob}} NOT NULL,
+		status VARCHAR(32) NOT NULL,
+		next_renewal_at {{time}} NULL,
+		created_at {{time}} NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS acme_rate_limits (
+		id VARCHAR(255) NOT NULL PRIMARY KEY,
+		events TEXT NOT NULL,
+		expires_at {{time}} NULL
+	)`,
+}
+
+var indexes = []struct {
+	name, table string
+	unique      bool
+	columns     []string
+}{
+	{"acme_accounts_key_id_idx", "acme_accounts", true, []string{"key_id"}},
+	{"acme_accounts_provisioner_name_idx", "acme_accounts", false, []string{"provisioner_name"}},
+	{"acme_eak_provisioner_reference_idx", "acme_external_account_keys", false, []string{"provisioner_id", "reference"}},
+	{"acme_eak_provisioner_account_idx", "acme_external_account_keys", false, []string{"provisioner_id", "account_id"}},
+	{"acme_authzs_account_id_idx", "acme_authzs", false, []string{"account_id"}},
+	{"acme_authzs_status_expires_at_idx", "acme_authzs", false, []string{"status", "expires_at"}},
+	{"acme_orders_account_id_status_idx", "acme_orders", false, []string{"account_id", "status"}},
+	{"acme_orders_status_expires_at_idx", "acme_orders", false, []string{"status", "expires_at"}},
+	{"acme_certs_serial_idx", "acme_certs", true, []string{"serial"}},
+	{"acme_certs_account_id_idx", "acme_certs", false, []string{"account_id"}},
+	{"acme_certs_not_after_idx", "acme_certs", false, []string{"not_after"}},
+	{"acme_deferred_orders_provisioner_status_idx", "acme_deferred_orders", false, []string{"provisioner_id", "status"}},
+	{"acme_star_orders_status_next_renewal_at_idx", "acme_star_orders", false, []string{"status", "next_renewal_at"}},
+	{"acme_rate_limits_expires_at_idx", "acme_rate_limits", false, []string{"expires_at"}},
+}
+
+// DB is a struct that implements the AcmeDB interface using a relational
+// database.
+type DB struct {
+	db      *sqlDB.DB
+	dialect *certdb.SQLDialect
+
+	// sweepMu guards sweptAt, the last time the expired rate limits were
+	// deleted.
+	sweepMu sync.Mutex
+	sweptAt time.Time
+}
+
+// New configures and returns a new ACME DB backend implemented using a
+// relational database. It creates the tables and indexes if they do not
+// exist.
+func New(sdb *sqlDB.DB, dialect *certdb.SQLDialect) (*DB, error) {
+	ctx := context.Background()
+	if err := dialect.Exec(ctx, sdb, schema...); err != nil {
+		return nil, err
+	}
+	for _, idx := range indexes {
+		if err := dialect.CreateIndex(ctx, sdb, idx.name, idx.table, idx.unique, idx.columns...); err != nil {
+			return nil, err
+		}
+	}
+	return &DB{db: sdb, dialect: dialect}, nil
+}
+
+// queryer is the interface shared by sql.DB and sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sqlDB.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sqlDB.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sqlDB.Row
+}
+
+// scanner is the interface shared by sql.Row and sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (db *DB) exec(ctx context.Context, q queryer, query string, args ...interface{}) (sqlDB.Result, error) {
+	return q.ExecContext(ctx, db.dialect.Rebind(query), args...)
+}
+
+func (db *DB) queryRow(ctx context.Context, q queryer, query string, args ...interface{}) *sqlDB.Row {
+	return q.QueryRowContext(ctx, db.dialect.Rebind(query), args...)
+}
+
+func (db *DB) query(ctx context.Context, q queryer, query string, args ...interface{}) (*sqlDB.Rows, error) {
+	return q.QueryContext(ctx, db.dialect.Rebind(query), args...)
+}
+
+// insert inserts a row with the given columns.
+func (db *DB) insert(ctx context.Context, q queryer, table string, columns []string, args ...interface{}) error {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	_, err := db.exec(ctx, q, "INSERT INTO "+table+" ("+strings.Join(columns, ", ")+") VALUES ("+placeholders+")", args...)
+	return err
+}
+
+// withTx runs the given function in a transaction. The transaction is
+// committed if the function does not fail.
+func (db *DB) withTx(ctx context.Context, fn func(tx *sqlDB.Tx) error) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "error starting transaction")
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback() //nolint:errcheck // the transaction already failed
+		return err
+	}
+	return errors.Wrap(tx.Commit(), "error committing transaction")
+}
+
+// marshal encodes the given value as JSON. Nil values are stored as NULL.
+func marshal(v interface{}, typ string) (sqlDB.NullString, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return sqlDB.NullString{}, errors.Wrapf(err, "error marshaling acme type: %s, value: %v", typ, v)
+	}
+	if string(b) == "null" {
+		return sqlDB.NullString{}, nil
+	}
+	return sqlDB.NullString{String: string(b), Valid: true}, nil
+}
+
+// unmarshal decodes the given JSON value if it is not NULL.
+func unmarshal(s sqlDB.NullString, v interface{}, typ string) error {
+	if !s.Valid || s.String == "" {
+		return nil
+	}
+	return errors.Wrapf(json.Unmarshal([]byte(s.String), v), "error unmarshaling acme type: %s", typ)
+}
+
+// marshalError encodes an optional ACME error.
+func marshalError(e *acme.Error) (sqlDB.NullString, error) {
+	if e == nil {
+		return sqlDB.NullString{}, nil
+	}
+	return marshal(e, "error")
+}
+
+// unmarshalError decodes an optional ACME error.
+func unmarshalError(s sqlDB.NullString) (*acme.Error, error) {
+	if !s.Valid || s.String == "" {
+		return nil, nil
+	}
+	e := new(acme.Error)
+	if err := unmarshal(s, e, "error"); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+var idLen = 32
+
+func randID() (val string, err error) {
+	val, err = randutil.Alphanumeric(idLen)
+	if err != nil {
+		return "", errors.Wrap(err, "error generating random alphanumeric ID")
+	}
+	return val, nil
+}
+
+// Clock that returns time in UTC rounded to seconds.
+type Clock struct{}
+
+// Now returns the UTC time rounded to seconds.
+func (c *Clock) Now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+var clock = new(Clock)
@@ -0,0 +1,102 @@
+package sql
+
+import (
+	"context"
+	sqlDB "database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/acme"
+	certdb "github.com/smallstep/certificates/db"
+)
+
+const starOrderColumns = "order_id, account_id, provisioner_id, csr, status, next_renewal_at, created_at"
+
+func scanStarOrder(s scanner) (*acme.StarOrder, error) {
+	var (
+		o                        acme.StarOrder
+		nextRenewalAt, createdAt sqlDB.NullTime
+	)
+	if err := s.Scan(&o.OrderID, &o.AccountID, &o.ProvisionerID, &o.CSR, &o.Status, &nextRenewalAt, &createdAt); err != nil {
+		return nil, err
+	}
+	o.NextRenewalAt, o.CreatedAt = certdb.TimeValue(nextRenewalAt), certdb.TimeValue(createdAt)
+	return &o, nil
+}
+
+// CreateStarOrder stores the auto-renewal of a finalized order. A previous
+// auto-renewal of the same order is replaced.
+func (db *DB) CreateStarOrder(ctx context.Context, s *acme.StarOrder) error {
+	createdAt := clock.Now()
+	if err := db.withTx(ctx, func(tx *sqlDB.Tx) error {
+		if _, err := db.exec(ctx, tx, "DELETE FROM acme_star_orders WHERE order_id = ?", s.OrderID); err != nil {
+			return err
+		}
+		return db.insert(ctx, tx, "acme_star_orders",
+			[]string{"order_id", "account_id", "provisioner_id", "csr", "status", "next_renewal_at", "created_at"},
+			s.OrderID, s.AccountID, s.ProvisionerID, s.CSR, string(s.Status),
+			certdb.NullTime(s.NextRenewalAt), certdb.NullTime(createdAt))
+	}); err != nil {
+		return errors.Wrapf(err, "error saving acme star order %s", s.OrderID)
+	}
+	s.CreatedAt = createdAt
+	return nil
+}
+
+// GetStarOrder retrieves the auto-renewal of an order.
+func (db *DB) GetStarOrder(ctx context.Context, orderID string) (*acme.StarOrder, error) {
+	s, err := scanStarOrder(db.queryRow(ctx, db.db, "SELECT "+starOrderColumns+" FROM acme_star_orders WHERE order_id = ?", orderID))
+	switch {
+	case errors.Is(err, sqlDB.ErrNoRows):
+		return nil, acme.NewError(acme.ErrorMalformedType, "star order %s not found", orderID)
+	case err != nil:
+		return nil, errors.Wrapf(err, "error loading star order %s", orderID)
+	}
+	return s, nil
+}
+
+// GetStarOrders retrieves the active auto-renewals that must be renewed
+// before the given time.
+func (db *DB) GetStarOrders(ctx context.Context, before time.Time) ([]*acme.StarOrder, error) {
+	rows, err := db.query(ctx, db.db, "SELECT "+starOrderColumns+" FROM acme_star_orders WHERE status = ? AND next_renewal_at <= ? ORDER BY next_renewal_at",
+		string(acme.StarOrderActive), certdb.NullTime(before))
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing star orders")
+	}
+	defer rows.Close()
+
+	orders := []*acme.StarOrder{}
+	for rows.Next() {
+		s, err := scanStarOrder(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading star order")
+		}
+		orders = append(orders, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error listing star orders")
+	}
+	return orders, nil
+}
+
+// UpdateStarOrder saves the status and the next renewal of an auto-renewal.
+// It returns acme.ErrConflict if the stored auto-renewal does not have the
+// given status.
+func (db *DB) UpdateStarOrder(ctx context.Context, s *acme.StarOrder, from acme.StarOrderStatus) error {
+	return db.withTx(ctx, func(tx *sqlDB.Tx) error {
+		var status acme.StarOrderStatus
+		err := db.queryRow(ctx, tx, "SELECT status FROM acme_star_orders WHERE order_id = ? FOR UPDATE", s.OrderID).Scan(&status)
+		switch {
+		case errors.Is(err, sqlDB.ErrNoRows):
+			return acme.NewError(acme.ErrorMalformedType, "star order %s not found", s.OrderID)
+		case err != nil:
+			return errors.Wrapf(err, "error loading star order %s", s.OrderID)
+		case status != from:
+			return errors.Wrapf(acme.ErrConflict, "error saving acme star order; star order %s has status %s", s.OrderID, status)
+		}
+		_, err = db.exec(ctx, tx, "UPDATE acme_star_orders SET status = ?, next_renewal_at = ? WHERE order_id = ?",
+			string(s.Status), certdb.NullTime(s.NextRenewalAt), s.OrderID)
+		return errors.Wrap(err, "error saving acme star order")
+	})
+}
@@ -0,0 +1,135 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/webhook"
+)
+
+// maxDelegationResponseSize is the maximum size of the responses of the
+// external validators.
+const maxDelegationResponseSize = 64 * 1024
+
+// DelegatedValidationRequest is the body sent to an external validator.
+type DelegatedValidationRequest struct {
+	Timestamp        time.Time     `json:"timestamp"`
+	Nonce            string        `json:"nonce"`
+	ProvisionerID    string        `json:"provisionerID"`
+	AccountID        string        `json:"accountID"`
+	ChallengeID      string        `json:"challengeID"`
+	Type             ChallengeType `json:"type"`
+	Identifier       Identifier    `json:"identifier"`
+	Token            string        `json:"token"`
+	KeyAuthorization string        `json:"keyAuthorization"`
+}
+
+// DelegatedValidationResponse is the response of an external validator. The
+// challenge becomes invalid, with the given error, if it is not valid.
+type DelegatedValidationResponse struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// delegatedValidate validates the challenge using an external validator
+// instead of the built-in validation of the challenge type. Errors connecting
+// to the validator are stored in the challenge, and the challenge can be
+// retried.
+func delegatedValidate(ctx context.Context, ch *Challenge, db DB, jwk *jose.JSONWebKey, p Provisioner, d *provisioner.ACMEChallengeDelegation) error {
+	keyAuth, err := KeyAuthorization(ch.Token, jwk)
+	if err != nil {
+		return err
+	}
+	nonce, err := webhook.NewNonce()
+	if err != nil {
+		return WrapErrorISE(err, "error generating nonce")
+	}
+	identifier := Identifier{Type: DNS, Value: ch.Value}
+	if net.ParseIP(ch.Value) != nil {
+		identifier.Type = IP
+	}
+
+	resp, err := postDelegatedValidation(ctx, d, &DelegatedValidationRequest{
+		Timestamp:        clock.Now(),
+		Nonce:            nonce,
+		ProvisionerID:    p.GetID(),
+		AccountID:        ch.AccountID,
+		ChallengeID:      ch.ID,
+		Type:             ch.Type,
+		Identifier:       identifier,
+		Token:            ch.Token,
+		KeyAuthorization: keyAuth,
+	})
+	if err != nil {
+		return storeError(ctx, db, ch, false, WrapError(ErrorConnectionType, err,
+			"error validating challenge with external validator"))
+	}
+	if !resp.Valid {
+		if resp.Error == "" {
+			resp.Error = "ownership of the identifier has not been proven"
+		}
+		return storeError(ctx, db, ch, true, NewDetailedError(ErrorRejectedIdentifierType,
+			"external validator rejected %s: %s", ch.Value, resp.Error))
+	}
+
+	// Update and store the challenge.
+	ch.Status = StatusValid
+	ch.Error = nil
+	ch.ValidatedAt = clock.Now().Format(time.RFC3339)
+
+	if err = db.UpdateChallenge(ctx, ch); err != nil {
+		return WrapErrorISE(err, "error updating challenge")
+	}
+	return nil
+}
+
+func postDelegatedValidation(ctx context.Context, d *provisioner.ACMEChallengeDelegation, r *DelegatedValidationRequest) (*DelegatedValidationResponse, error) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling request")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.GetTimeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", d.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.Secret != "" {
+		secret, err := base64.StdEncoding.DecodeString(d.Secret)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(webhook.SignatureHeader, webhook.Sign(secret, body))
+	}
+	if d.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+d.BearerToken)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, errors.Errorf("external validator responded with %d", resp.StatusCode)
+	}
+	var v DelegatedValidationResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDelegationResponseSize)).Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "error decoding external validator response")
+	}
+	return &v, nil
+}
@@ -0,0 +1,90 @@
+package acme
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/webhook"
+)
+
+func TestChallenge_Validate_delegated(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	keyAuth, err := KeyAuthorization("token", jwk)
+	assert.FatalError(t, err)
+	secret := []byte("secret")
+
+	tests := []struct {
+		name       string
+		status     int
+		response   *DelegatedValidationResponse
+		wantStatus Status
+		wantErr    string
+	}{
+		{"ok", http.StatusOK, &DelegatedValidationResponse{Valid: true}, StatusValid, ""},
+		{"rejected", http.StatusOK, &DelegatedValidationResponse{Error: "unknown partner"}, StatusInvalid, "urn:ietf:params:acme:error:rejectedIdentifier"},
+		{"server error", http.StatusInternalServerError, nil, StatusPending, "urn:ietf:params:acme:error:connection"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.FatalError(t, err)
+				assert.Equals(t, r.Header.Get(webhook.SignatureHeader), webhook.Sign(secret, body))
+				assert.Equals(t, r.Header.Get("Authorization"), "Bearer bearer")
+				var req DelegatedValidationRequest
+				assert.FatalError(t, json.Unmarshal(body, &req))
+				assert.Equals(t, req.ProvisionerID, "provID")
+				assert.Equals(t, req.ChallengeID, "chID")
+				assert.Equals(t, req.Type, HTTP01)
+				assert.Equals(t, req.Identifier, Identifier{Type: DNS, Value: "foo.partner.example"})
+				assert.Equals(t, req.KeyAuthorization, keyAuth)
+				w.WriteHeader(tt.status)
+				if tt.response != nil {
+					assert.FatalError(t, json.NewEncoder(w).Encode(tt.response))
+				}
+			}))
+			defer srv.Close()
+
+			delegations := []*provisioner.ACMEChallengeDelegation{{
+				Identifiers: []string{"*.partner.example"},
+				URL:         srv.URL,
+				Secret:      base64.StdEncoding.EncodeToString(secret),
+				BearerToken: "bearer",
+			}}
+			prov := &MockProvisioner{
+				MgetID: func() string { return "provID" },
+				MgetChallengeDelegation: func(value string) *provisioner.ACMEChallengeDelegation {
+					return (&provisioner.ACME{ChallengeDelegations: delegations}).GetChallengeDelegation(value)
+				},
+			}
+			var updated *Challenge
+			db := &MockDB{
+				MockUpdateChallenge: func(ctx context.Context, ch *Challenge) error {
+					updated = ch
+					return nil
+				},
+			}
+			ch := &Challenge{ID: "chID", AccountID: "accID", Type: HTTP01, Status: StatusPending, Token: "token", Value: "foo.partner.example"}
+			ctx := NewProvisionerContext(context.Background(), prov)
+			assert.FatalError(t, ch.Validate(ctx, db, jwk, nil))
+			if assert.NotNil(t, updated) {
+				assert.Equals(t, updated.Status, tt.wantStatus)
+				if tt.wantErr == "" {
+					assert.Nil(t, updated.Error)
+				} else if assert.NotNil(t, updated.Error) {
+					assert.Equals(t, updated.Error.Type, tt.wantErr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// maxDiagnosticBody is the maximum number of bytes of an http-01 response
+// body included in a diagnostic step.
+const maxDiagnosticBody = 256
+
+// DiagnosticStep is one of the operations performed while validating a
+// challenge in a diagnostic.
+type DiagnosticStep struct {
+	Name     string `json:"name"`
+	Detail   string `json:"detail"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Diagnostic is the result of a dry-run validation of a challenge.
+type Diagnostic struct {
+	Identifier       Identifier          `json:"identifier"`
+	Type             ChallengeType       `json:"type"`
+	Token            string              `json:"token"`
+	KeyAuthorization string              `json:"keyAuthorization"`
+	Status           Status              `json:"status"`
+	Error            *Error              `json:"error,omitempty"`
+	ValidationRecord []*ValidationRecord `json:"validationRecord,omitempty"`
+	Steps            []*DiagnosticStep   `json:"steps"`
+}
+
+// ValidateDiagnostic checks that the given challenge type can be used with the
+// given identifier.
+func ValidateDiagnostic(typ ChallengeType, id Identifier, token string) error {
+	if token == "" {
+		return NewError(ErrorMalformedType, "token cannot be empty")
+	}
+	wildcard := strings.HasPrefix(id.Value, "*.")
+	switch id.Type {
+	case DNS:
+		if id.Value == "" {
+			return NewError(ErrorMalformedType, "identifier value cannot be empty")
+		}
+		switch {
+		case typ == DNS01:
+		case typ == HTTP01 || typ == TLSALPN01:
+			if wildcard {
+				return NewError(ErrorMalformedType, "%s challenges cannot be used with wildcard identifiers", typ)
+			}
+		default:
+			return NewError(ErrorMalformedType, "challenge type %s is not supported for dns identifiers", typ)
+		}
+	case IP:
+		if net.ParseIP(id.Value) == nil {
+			return NewError(ErrorMalformedType, "invalid IP address: %s", id.Value)
+		}
+		if typ != HTTP01 && typ != TLSALPN01 {
+			return NewError(ErrorMalformedType, "challenge type %s is not supported for ip identifiers", typ)
+		}
+	default:
+		return NewError(ErrorUnsupportedIdentifierType, "identifier type %s is not supported in diagnostics", id.Type)
+	}
+	return nil
+}
+
+// Diagnose performs the validation of a challenge of the given type for the
+// given identifier without creating an order, an authorization or a
+// challenge. It uses the same validation logic, and it returns the operations
+// performed, the expected key authorization and the status the challenge
+// would have after the validation.
+//
+// Only http-01, dns-01 and tls-alpn-01 challenges can be diagnosed.
+func Diagnose(ctx context.Context, typ ChallengeType, id Identifier, token string, jwk *jose.JSONWebKey) (*Diagnostic, error) {
+	if err := ValidateDiagnostic(typ, id, token); err != nil {
+		return nil, err
+	}
+	keyAuth, err := KeyAuthorization(token, jwk)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Diagnostic{
+		Identifier:       id,
+		Type:             typ,
+		Token:            token,
+		KeyAuthorization: keyAuth,
+	}
+	tc := &tracingClient{
+		client:     MustClientFromContext(ctx),
+		diagnostic: d,
+	}
+
+	switch typ {
+	case HTTP01:
+		d.addStep("expect", fmt.Sprintf("http://%s/.well-known/acme-challenge/%s must return %s", http01ChallengeHost(id.Value), token, keyAuth), nil, 0)
+	case DNS01:
+		h := sha256.Sum256([]byte(keyAuth))
+		d.addStep("expect", fmt.Sprintf("_acme-challenge.%s must have a TXT record %s", strings.TrimPrefix(id.Value, "*."), base64.RawURLEncoding.EncodeToString(h[:])), nil, 0)
+	case TLSALPN01:
+		h := sha256.Sum256([]byte(keyAuth))
+		d.addStep("expect", fmt.Sprintf("%s must serve an acme-tls/1 certificate with the acmeValidationV1 extension %x", id.Value, h), nil, 0)
+	}
+
+	ch := &Challenge{
+		Value:  id.Value,
+		Type:   typ,
+		Status: StatusPending,
+		Token:  token,
+	}
+	if err := ch.Validate(NewClientContext(ctx, tc), dryRunDB{}, jwk, nil); err != nil {
+		return nil, err
+	}
+	d.Status = ch.Status
+	d.Error = ch.Error
+	d.ValidationRecord = ch.ValidationRecord
+	return d, nil
+}
+
+func (d *Diagnostic) addStep(name, detail string, err error, duration time.Duration) {
+	step := &DiagnosticStep{
+		Name:     name,
+		Detail:   detail,
+		Duration: duration.String(),
+	}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	d.Steps = append(d.Steps, step)
+}
+
+// dryRunDB is the DB used in diagnostics. The validation of http-01, dns-01
+// and tls-alpn-01 challenges only updates the challenge, and diagnostics
+// must not store anything, so the rest of the methods are never called.
+type dryRunDB struct {
+	DB
+}
+
+func (dryRunDB) UpdateChallenge(context.Context, *Challenge) error {
+	return nil
+}
+
+// tracingClient is a Client that records the operations performed while
+// validating a challenge.
+type tracingClient struct {
+	client     Client
+	diagnostic *Diagnostic
+}
+
+func (c *tracingClient) Get(url string) (*http.Response, error) {
+	start := time.Now()
+	resp, err := c.client.Get(url)
+	if err != nil {
+		c.diagnostic.addStep("http-get", "GET "+url, err, time.Since(start))
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		c.diagnostic.addStep("http-get", fmt.Sprintf("GET %s returned status %d", url, resp.StatusCode), err, time.Since(start))
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	shown := strings.TrimSpace(string(body))
+	if len(shown) > maxDiagnosticBody {
+		shown = shown[:maxDiagnosticBody] + "..."
+	}
+	c.diagnostic.addStep("http-get", fmt.Sprintf("GET %s returned status %d and body %q", url, resp.StatusCode, shown), nil, time.Since(start))
+	return resp, nil
+}
+
+func (c *tracingClient) LookupTxt(name string) ([]string, error) {
+	start := time.Now()
+	records, err := c.client.LookupTxt(name)
+	if err != nil {
+		c.diagnostic.addStep("dns-txt", "TXT "+name, err, time.Since(start))
+		return nil, err
+	}
+	c.diagnostic.addStep("dns-txt", fmt.Sprintf("TXT %s returned %q", name, records), nil, time.Since(start))
+	return records, nil
+}
+
+func (c *tracingClient) lookupCNAME(name string) (string, error) {
+	cc, ok := c.client.(cnameClient)
+	if !ok {
+		return "", errors.New("client does not support CNAME lookups")
+	}
+	start := time.Now()
+	target, err := cc.lookupCNAME(name)
+	if err != nil {
+		c.diagnostic.addStep("dns-cname", "CNAME "+name, err, time.Since(start))
+		return "", err
+	}
+	c.diagnostic.addStep("dns-cname", fmt.Sprintf("CNAME %s returned %q", name, target), nil, time.Since(start))
+	return target, nil
+}
+
+func (c *tracingClient) lookupCAA(name string) ([]*CAARecord, error) {
+	cc, ok := c.client.(caaClient)
+	if !ok {
+		return nil, errors.New("client does not support CAA lookups")
+	}
+	start := time.Now()
+	records, err := cc.lookupCAA(name)
+	if err != nil {
+		c.diagnostic.addStep("dns-caa", "CAA "+name, err, time.Since(start))
+		return nil, err
+	}
+	values := make([]string, len(records))
+	for i, r := range records {
+		values[i] = fmt.Sprintf("%d %s %q", r.Flags, r.Tag, r.Value)
+	}
+	c.diagnostic.addStep("dns-caa", fmt.Sprintf("CAA %s returned %q", name, values), nil, time.Since(start))
+	return records, nil
+}
+
+func (c *tracingClient) withResolvers(opts *provisioner.ACMEDNS01Options) Client {
+	rc, ok := c.client.(resolversClient)
+	if !ok {
+		return c
+	}
+	return &tracingClient{
+		client:     rc.withResolvers(opts),
+		diagnostic: c.diagnostic,
+	}
+}
+
+func (c *tracingClient) withHTTP01Options(opts *provisioner.ACMEHTTP01Options) Client {
+	hc, ok := c.client.(http01Client)
+	if !ok {
+		return c
+	}
+	return &tracingClient{
+		client:     hc.withHTTP01Options(opts),
+		diagnostic: c.diagnostic,
+	}
+}
+
+func (c *tracingClient) TLSDial(network, addr string, config *tls.Config) (*tls.Conn, error) {
+	start := time.Now()
+	conn, err := c.client.TLSDial(network, addr, config)
+	if err != nil {
+		c.diagnostic.addStep("tls-dial", fmt.Sprintf("dial %s with server name %q", addr, config.ServerName), err, time.Since(start))
+		return nil, err
+	}
+	cs := conn.ConnectionState()
+	detail := fmt.Sprintf("dial %s with server name %q negotiated protocol %q", addr, config.ServerName, cs.NegotiatedProtocol)
+	if len(cs.PeerCertificates) > 0 {
+		leaf := cs.PeerCertificates[0]
+		detail += fmt.Sprintf(" with certificate for DNS names %q and IP addresses %q", leaf.DNSNames, leaf.IPAddresses)
+	}
+	c.diagnostic.addStep("tls-dial", detail, nil, time.Since(start))
+	return conn, nil
+}
@@ -0,0 +1,130 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/jose"
+)
+
+func TestValidateDiagnostic(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     ChallengeType
+		id      Identifier
+		token   string
+		wantErr bool
+	}{
+		{"ok/dns-01", DNS01, Identifier{Type: DNS, Value: "*.example.com"}, "token", false},
+		{"ok/http-01", HTTP01, Identifier{Type: DNS, Value: "example.com"}, "token", false},
+		{"ok/tls-alpn-01", TLSALPN01, Identifier{Type: IP, Value: "127.0.0.1"}, "token", false},
+		{"fail/token", HTTP01, Identifier{Type: DNS, Value: "example.com"}, "", true},
+		{"fail/wildcard", HTTP01, Identifier{Type: DNS, Value: "*.example.com"}, "token", true},
+		{"fail/ip", DNS01, Identifier{Type: IP, Value: "127.0.0.1"}, "token", true},
+		{"fail/invalid-ip", HTTP01, Identifier{Type: IP, Value: "foo"}, "token", true},
+		{"fail/device-attest-01", DEVICEATTEST01, Identifier{Type: PermanentIdentifier, Value: "1234"}, "token", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDiagnostic(tt.typ, tt.id, tt.token)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	require.NoError(t, err)
+	keyAuth, err := KeyAuthorization("token", jwk)
+	require.NoError(t, err)
+	h := sha256.Sum256([]byte(keyAuth))
+	digest := base64.RawURLEncoding.EncodeToString(h[:])
+
+	t.Run("ok/http-01", func(t *testing.T) {
+		var urls []string
+		ctx := NewClientContext(context.Background(), &mockClient{
+			get: func(url string) (*http.Response, error) {
+				urls = append(urls, url)
+				return &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewBufferString(keyAuth + "\n")),
+				}, nil
+			},
+		})
+		d, err := Diagnose(ctx, HTTP01, Identifier{Type: DNS, Value: "example.com"}, "token", jwk)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"http://example.com/.well-known/acme-challenge/token"}, urls)
+		assert.Equal(t, StatusValid, d.Status)
+		assert.Nil(t, d.Error)
+		assert.Equal(t, keyAuth, d.KeyAuthorization)
+		if assert.Len(t, d.Steps, 2) {
+			assert.Equal(t, "expect", d.Steps[0].Name)
+			assert.Equal(t, "http-get", d.Steps[1].Name)
+			assert.Contains(t, d.Steps[1].Detail, "returned status 200")
+			assert.Contains(t, d.Steps[1].Detail, keyAuth)
+		}
+	})
+
+	t.Run("invalid/http-01", func(t *testing.T) {
+		ctx := NewClientContext(context.Background(), &mockClient{
+			get: func(url string) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewBufferString("foo")),
+				}, nil
+			},
+		})
+		d, err := Diagnose(ctx, HTTP01, Identifier{Type: DNS, Value: "example.com"}, "token", jwk)
+		require.NoError(t, err)
+		assert.Equal(t, StatusInvalid, d.Status)
+		if assert.NotNil(t, d.Error) {
+			assert.Equal(t, "urn:ietf:params:acme:error:rejectedIdentifier", d.Error.Type)
+		}
+	})
+
+	t.Run("pending/dns-01", func(t *testing.T) {
+		var names []string
+		ctx := NewClientContext(context.Background(), &mockClient{
+			lookupTxt: func(name string) ([]string, error) {
+				names = append(names, name)
+				return nil, errors.New("no such host")
+			},
+		})
+		d, err := Diagnose(ctx, DNS01, Identifier{Type: DNS, Value: "*.example.com"}, "token", jwk)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"_acme-challenge.example.com"}, names)
+		assert.Equal(t, StatusPending, d.Status)
+		if assert.NotNil(t, d.Error) {
+			assert.Equal(t, "urn:ietf:params:acme:error:dns", d.Error.Type)
+		}
+		if assert.Len(t, d.Steps, 2) {
+			assert.Contains(t, d.Steps[0].Detail, digest)
+			assert.Equal(t, "dns-txt", d.Steps[1].Name)
+			assert.Equal(t, "no such host", d.Steps[1].Error)
+		}
+	})
+
+	t.Run("ok/dns-01", func(t *testing.T) {
+		ctx := NewClientContext(context.Background(), &mockClient{
+			lookupTxt: func(name string) ([]string, error) {
+				return []string{"foo", digest}, nil
+			},
+		})
+		d, err := Diagnose(ctx, DNS01, Identifier{Type: DNS, Value: "example.com"}, "token", jwk)
+		require.NoError(t, err)
+		assert.Equal(t, StatusValid, d.Status)
+	})
+
+	t.Run("fail/validate", func(t *testing.T) {
+		_, err := Diagnose(context.Background(), DNS01, Identifier{Type: IP, Value: "127.0.0.1"}, "token", jwk)
+		assert.Error(t, err)
+	})
+}
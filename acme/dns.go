@@ -0,0 +1,376 @@
+package acme
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// resolvConf is the file with the system nameservers.
+var resolvConf = "/etc/resolv.conf"
+
+// dnsTimeout is the timeout of each DNS query sent to the system
+// nameservers.
+const dnsTimeout = 10 * time.Second
+
+// systemNameservers returns the addresses of the nameservers configured in the
+// system, or the local resolver if there are none.
+func systemNameservers() []string {
+	var servers []string
+	if b, err := os.ReadFile(resolvConf); err == nil {
+		for _, line := range strings.Split(string(b), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(strings.SplitN(fields[1], "%", 2)[0]) != nil {
+				servers = append(servers, net.JoinHostPort(fields[1], "53"))
+			}
+		}
+	}
+	if len(servers) == 0 {
+		servers = []string{"127.0.0.1:53"}
+	}
+	return servers
+}
+
+// dnsResolver is a DNS server and the protocol used to send the queries.
+type dnsResolver struct {
+	protocol string
+	address  string
+	timeout  time.Duration
+	// tlsConfig and http are the configuration of the DNS over TLS and DNS
+	// over HTTPS resolvers, the default one if they are not set.
+	tlsConfig *tls.Config
+	http      *http.Client
+}
+
+// newDNSResolvers returns the resolvers of the given dns-01 options.
+func newDNSResolvers(opts *provisioner.ACMEDNS01Options) []*dnsResolver {
+	timeout := opts.GetLookupTimeout()
+	var resolvers []*dnsResolver
+	for _, r := range opts.GetResolvers() {
+		resolvers = append(resolvers, &dnsResolver{
+			protocol: r.Protocol,
+			address:  r.Address,
+			timeout:  timeout,
+		})
+	}
+	return resolvers
+}
+
+// dnsExchange sends a recursive query for the given name and type to the
+// nameservers, in order, until one of them answers, and returns the answers
+// in the response. Truncated responses are retried over TCP. A name that does
+// not exist has no answers.
+func dnsExchange(servers []string, name string, typ dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	resolvers := make([]*dnsResolver, len(servers))
+	for i, server := range servers {
+		resolvers[i] = &dnsResolver{protocol: provisioner.DNSResolverUDP, address: server, timeout: dnsTimeout}
+	}
+	return dnsLookup(resolvers, name, typ)
+}
+
+// dnsLookup sends the query for the given name and type to the resolvers, in
+// order, until one of them answers, and returns the answers in the response.
+func dnsLookup(resolvers []*dnsResolver, name string, typ dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	err := errors.New("no nameservers")
+	for _, r := range resolvers {
+		var answers []dnsmessage.Resource
+		if answers, err = r.exchange(name, typ); err == nil {
+			return answers, nil
+		}
+	}
+	return nil, errors.Wrapf(err, "error looking up %s", name)
+}
+
+// exchange sends a recursive query for the given fully qualified name and
+// type to the resolver, and returns the answers in the response. A name that
+// does not exist has no answers.
+func (r *dnsResolver) exchange(name string, typ dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid name %s", name)
+	}
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			RecursionDesired: true,
+		},
+		Questions: []dnsmessage.Question{
+			{Name: qname, Type: typ, Class: dnsmessage.ClassINET},
+		},
+	}
+	// DNS over HTTPS uses the id 0 to make the responses cacheable, the
+	// connection already matches the response to the query.
+	if r.protocol != provisioner.DNSResolverHTTPS {
+		var id [2]byte
+		if _, err := io.ReadFull(rand.Reader, id[:]); err != nil {
+			return nil, errors.Wrap(err, "error generating query id")
+		}
+		query.ID = binary.BigEndian.Uint16(id[:])
+	}
+	b, err := query.Pack()
+	if err != nil {
+		return nil, errors.Wrap(err, "error packing query")
+	}
+
+	var resp *dnsmessage.Message
+	switch r.protocol {
+	case provisioner.DNSResolverHTTPS:
+		resp, err = r.httpsRoundTrip(b)
+	case provisioner.DNSResolverTLS:
+		resp, err = r.tlsRoundTrip(b)
+	case provisioner.DNSResolverTCP:
+		resp, err = dnsRoundTrip("tcp", r.address, b, r.timeout)
+	default:
+		resp, err = dnsRoundTrip("udp", r.address, b, r.timeout)
+		if err == nil && resp.Truncated {
+			resp, err = dnsRoundTrip("tcp", r.address, b, r.timeout)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.ID != query.ID:
+		return nil, errors.Errorf("nameserver %s returned an unexpected query id", r.address)
+	case resp.RCode == dnsmessage.RCodeSuccess:
+		return resp.Answers, nil
+	case resp.RCode == dnsmessage.RCodeNameError:
+		return nil, nil
+	default:
+		return nil, errors.Errorf("nameserver %s returned %s", r.address, resp.RCode)
+	}
+}
+
+// tlsRoundTrip sends the given query using DNS over TLS, RFC 7858.
+func (r *dnsResolver) tlsRoundTrip(query []byte) (*dnsmessage.Message, error) {
+	config := r.tlsConfig
+	if config == nil {
+		host, _, err := net.SplitHostPort(r.address)
+		if err != nil {
+			return nil, err
+		}
+		config = &tls.Config{
+			ServerName: host,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: r.timeout}, "tcp", r.address, config)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return dnsConnRoundTrip(conn, true, r.address, query, r.timeout)
+}
+
+// httpsRoundTrip sends the given query using DNS over HTTPS, RFC 8484.
+func (r *dnsResolver) httpsRoundTrip(query []byte) (*dnsmessage.Message, error) {
+	req, err := http.NewRequest(http.MethodPost, r.address, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", dnsMessageContentType)
+	req.Header.Set("Content-Type", dnsMessageContentType)
+
+	hc := r.http
+	if hc == nil {
+		hc = &http.Client{Timeout: r.timeout}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("nameserver %s returned status %d", r.address, resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading response from %s", r.address)
+	}
+
+	msg := new(dnsmessage.Message)
+	if err := msg.Unpack(b); err != nil {
+		return nil, errors.Wrapf(err, "error parsing response from %s", r.address)
+	}
+	return msg, nil
+}
+
+// dnsMessageContentType is the media type of the DNS over HTTPS messages.
+const dnsMessageContentType = "application/dns-message"
+
+// dnsRoundTrip sends the given query to the server and reads the response.
+func dnsRoundTrip(network, server string, query []byte, timeout time.Duration) (*dnsmessage.Message, error) {
+	conn, err := net.DialTimeout(network, server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return dnsConnRoundTrip(conn, network == "tcp", server, query, timeout)
+}
+
+// dnsConnRoundTrip sends the given query using the connection and reads the
+// response, messages over streams are prefixed by their length.
+func dnsConnRoundTrip(conn net.Conn, stream bool, server string, query []byte, timeout time.Duration) (*dnsmessage.Message, error) {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	if stream {
+		msg := make([]byte, 2+len(query))
+		binary.BigEndian.PutUint16(msg, uint16(len(query)))
+		copy(msg[2:], query)
+		if _, err := conn.Write(msg); err != nil {
+			return nil, err
+		}
+		var l [2]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return nil, err
+		}
+		b = make([]byte, binary.BigEndian.Uint16(l[:]))
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		b = make([]byte, 65535)
+		n, err := conn.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		b = b[:n]
+	}
+
+	resp := new(dnsmessage.Message)
+	if err := resp.Unpack(b); err != nil {
+		return nil, errors.Wrapf(err, "error parsing response from %s", server)
+	}
+	return resp, nil
+}
+
+// resolverClient is a Client that looks up the TXT and CNAME records using the
+// configured resolvers. With a quorum greater than one, all the resolvers are
+// queried and only the TXT records returned by at least quorum of them are
+// accepted.
+type resolverClient struct {
+	Client
+	resolvers []*dnsResolver
+	quorum    int
+}
+
+func (c *resolverClient) LookupTxt(name string) ([]string, error) {
+	if c.quorum <= 1 {
+		answers, err := dnsLookup(c.resolvers, name, dnsmessage.TypeTXT)
+		if err != nil {
+			return nil, err
+		}
+		return txtValues(answers), nil
+	}
+
+	fqdn := name
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+	type result struct {
+		values []string
+		err    error
+	}
+	results := make([]result, len(c.resolvers))
+	var wg sync.WaitGroup
+	for i, r := range c.resolvers {
+		wg.Add(1)
+		go func(i int, r *dnsResolver) {
+			defer wg.Done()
+			answers, err := r.exchange(fqdn, dnsmessage.TypeTXT)
+			results[i] = result{values: txtValues(answers), err: err}
+		}(i, r)
+	}
+	wg.Wait()
+
+	// Count the resolvers that returned each record, keeping the order in
+	// which the records were first seen.
+	var answered int
+	var values []string
+	var lastErr error
+	counts := make(map[string]int)
+	for _, res := range results {
+		if res.err != nil {
+			lastErr = res.err
+			continue
+		}
+		answered++
+		seen := make(map[string]bool)
+		for _, v := range res.values {
+			if seen[v] {
+				continue
+			}
+			seen[v] = true
+			if counts[v] == 0 {
+				values = append(values, v)
+			}
+			counts[v]++
+		}
+	}
+	// The quorum is not greater than the number of resolvers, so at least one
+	// of them failed.
+	if answered < c.quorum {
+		return nil, errors.Wrapf(lastErr, "error looking up %s: only %d of %d resolvers answered, but the quorum is %d",
+			fqdn, answered, len(c.resolvers), c.quorum)
+	}
+
+	agreed := []string{}
+	for _, v := range values {
+		if counts[v] >= c.quorum {
+			agreed = append(agreed, v)
+		}
+	}
+	return agreed, nil
+}
+
+// lookupCNAME returns the target of the CNAME record of the given name, or the
+// name if it does not have one. It uses the first resolver that answers.
+func (c *resolverClient) lookupCNAME(name string) (string, error) {
+	answers, err := dnsLookup(c.resolvers, name, dnsmessage.TypeCNAME)
+	if err != nil {
+		return "", err
+	}
+	owner := strings.TrimSuffix(strings.ToLower(name), ".")
+	for _, a := range answers {
+		body, ok := a.Body.(*dnsmessage.CNAMEResource)
+		if !ok || strings.TrimSuffix(strings.ToLower(a.Header.Name.String()), ".") != owner {
+			continue
+		}
+		return body.CNAME.String(), nil
+	}
+	return name, nil
+}
+
+// txtValues returns the values of the TXT records in the answers, the strings
+// of each record are joined like in net.LookupTXT.
+func txtValues(answers []dnsmessage.Resource) []string {
+	var values []string
+	for _, a := range answers {
+		if body, ok := a.Body.(*dnsmessage.TXTResource); ok {
+			values = append(values, strings.Join(body.TXT, ""))
+		}
+	}
+	return values
+}
@@ -0,0 +1,316 @@
+package acme
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/jose"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// dnsRecords are the records served by the test DNS servers, the TXT and
+// CNAME records by name.
+type dnsRecords struct {
+	txt   map[string][]string
+	cname map[string]string
+}
+
+func (r *dnsRecords) respond(b []byte) []byte {
+	var query dnsmessage.Message
+	if err := query.Unpack(b); err != nil || len(query.Questions) != 1 {
+		return nil
+	}
+	q := query.Questions[0]
+	name := strings.TrimSuffix(q.Name.String(), ".")
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+		Questions: query.Questions,
+	}
+	hdr := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: 60}
+	switch q.Type {
+	case dnsmessage.TypeTXT:
+		values, ok := r.txt[name]
+		if !ok {
+			resp.RCode = dnsmessage.RCodeNameError
+		}
+		for _, v := range values {
+			resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: hdr, Body: &dnsmessage.TXTResource{TXT: []string{v}}})
+		}
+	case dnsmessage.TypeCNAME:
+		if target, ok := r.cname[name]; ok {
+			resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: hdr, Body: &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(target)}})
+		}
+	}
+	b, err := resp.Pack()
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func startUDPDNS(t *testing.T, records *dnsRecords) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		b := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			if resp := records.respond(b[:n]); resp != nil {
+				pc.WriteTo(resp, addr) //nolint:errcheck // test server
+			}
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func startStreamDNS(t *testing.T, ln net.Listener, records *dnsRecords) string {
+	t.Helper()
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var l [2]byte
+				if _, err := io.ReadFull(conn, l[:]); err != nil {
+					return
+				}
+				b := make([]byte, binary.BigEndian.Uint16(l[:]))
+				if _, err := io.ReadFull(conn, b); err != nil {
+					return
+				}
+				resp := records.respond(b)
+				msg := make([]byte, 2+len(resp))
+				binary.BigEndian.PutUint16(msg, uint16(len(resp)))
+				copy(msg[2:], resp)
+				conn.Write(msg) //nolint:errcheck // test server
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func Test_dnsResolver_exchange(t *testing.T) {
+	records := &dnsRecords{
+		txt: map[string][]string{"_acme-challenge.example.com": {"token"}},
+	}
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	tcpAddr := startStreamDNS(t, tcpListener, records)
+
+	// The DNS over TLS server uses the certificate of the test server.
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dnsMessageContentType {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", dnsMessageContentType)
+		w.Write(records.respond(b)) //nolint:errcheck // test server
+	}))
+	defer srv.Close()
+	tlsListener, err := tls.Listen("tcp", "127.0.0.1:0", srv.TLS)
+	require.NoError(t, err)
+	tlsAddr := startStreamDNS(t, tlsListener, records)
+	tlsConfig := srv.Client().Transport.(*http.Transport).TLSClientConfig
+
+	tests := []struct {
+		name     string
+		resolver *dnsResolver
+		want     []string
+		wantErr  bool
+	}{
+		{"ok udp", &dnsResolver{protocol: provisioner.DNSResolverUDP, address: startUDPDNS(t, records), timeout: time.Second}, []string{"token"}, false},
+		{"ok tcp", &dnsResolver{protocol: provisioner.DNSResolverTCP, address: tcpAddr, timeout: time.Second}, []string{"token"}, false},
+		{"ok tls", &dnsResolver{protocol: provisioner.DNSResolverTLS, address: tlsAddr, timeout: time.Second, tlsConfig: tlsConfig}, []string{"token"}, false},
+		{"ok https", &dnsResolver{protocol: provisioner.DNSResolverHTTPS, address: srv.URL + "/dns-query", timeout: time.Second, http: srv.Client()}, []string{"token"}, false},
+		{"fail tls", &dnsResolver{protocol: provisioner.DNSResolverTLS, address: tlsAddr, timeout: time.Second}, nil, true},
+		{"fail https", &dnsResolver{protocol: provisioner.DNSResolverHTTPS, address: srv.URL, timeout: time.Second}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answers, err := tt.resolver.exchange("_acme-challenge.example.com.", dnsmessage.TypeTXT)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, txtValues(answers))
+		})
+	}
+}
+
+func Test_resolverClient_LookupTxt(t *testing.T) {
+	good := &dnsRecords{txt: map[string][]string{"_acme-challenge.example.com": {"good", "other"}}}
+	split := &dnsRecords{txt: map[string][]string{"_acme-challenge.example.com": {"bad", "other"}}}
+	newResolvers := func(records ...*dnsRecords) []*dnsResolver {
+		var resolvers []*dnsResolver
+		for _, r := range records {
+			resolvers = append(resolvers, &dnsResolver{protocol: provisioner.DNSResolverUDP, address: startUDPDNS(t, r), timeout: time.Second})
+		}
+		return resolvers
+	}
+
+	// A closed port does not answer.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	down := &dnsResolver{protocol: provisioner.DNSResolverUDP, address: pc.LocalAddr().String(), timeout: 100 * time.Millisecond}
+	pc.Close()
+
+	tests := []struct {
+		name      string
+		resolvers []*dnsResolver
+		quorum    int
+		want      []string
+		wantErr   string
+	}{
+		{"ok first", newResolvers(good, split), 1, []string{"good", "other"}, ""},
+		{"ok fallback", append([]*dnsResolver{down}, newResolvers(split)...), 1, []string{"bad", "other"}, ""},
+		{"ok quorum", newResolvers(good, split, good), 2, []string{"good", "other"}, ""},
+		{"ok quorum down", append(newResolvers(good, good), down), 2, []string{"good", "other"}, ""},
+		{"ok no agreement", newResolvers(good, split), 2, []string{"other"}, ""},
+		{"fail quorum", append(newResolvers(good, good), down), 3, nil, "only 2 of 3 resolvers answered, but the quorum is 3"},
+		{"fail all down", []*dnsResolver{down}, 1, nil, "error looking up _acme-challenge.example.com."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &resolverClient{Client: NewClient(), resolvers: tt.resolvers, quorum: tt.quorum}
+			got, err := c.LookupTxt("_acme-challenge.example.com")
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_resolverClient_lookupCNAME(t *testing.T) {
+	addr := startUDPDNS(t, &dnsRecords{
+		cname: map[string]string{"_acme-challenge.example.com": "example.validation.example.net."},
+	})
+	c := &resolverClient{
+		Client:    NewClient(),
+		resolvers: []*dnsResolver{{protocol: provisioner.DNSResolverUDP, address: addr, timeout: time.Second}},
+	}
+
+	target, err := c.lookupCNAME("_acme-challenge.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "example.validation.example.net.", target)
+
+	target, err = c.lookupCNAME("example.validation.example.net")
+	require.NoError(t, err)
+	assert.Equal(t, "example.validation.example.net", target)
+}
+
+func Test_withResolvers(t *testing.T) {
+	opts := &provisioner.ACMEDNS01Options{
+		Resolvers:     []string{"10.0.0.1", "tls://dns.example.com", "https://dns.example.com/dns-query"},
+		LookupTimeout: &provisioner.Duration{Duration: 3 * time.Second},
+		Quorum:        2,
+	}
+
+	c, ok := NewClient().(resolversClient).withResolvers(opts).(*resolverClient)
+	require.True(t, ok)
+	assert.Equal(t, 2, c.quorum)
+	assert.Equal(t, []*dnsResolver{
+		{protocol: "udp", address: "10.0.0.1:53", timeout: 3 * time.Second},
+		{protocol: "tls", address: "dns.example.com:853", timeout: 3 * time.Second},
+		{protocol: "https", address: "https://dns.example.com/dns-query", timeout: 3 * time.Second},
+	}, c.resolvers)
+
+	// The tracing client keeps tracing the lookups.
+	tc, ok := (&tracingClient{client: NewClient(), diagnostic: &Diagnostic{}}).withResolvers(opts).(*tracingClient)
+	require.True(t, ok)
+	assert.IsType(t, &resolverClient{}, tc.client)
+
+	// Clients without resolvers are not changed.
+	mc := &tracingClient{client: &mockClient{}}
+	assert.Same(t, mc, mc.withResolvers(opts))
+}
+
+func TestDNS01Validate_resolvers(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	require.NoError(t, err)
+	keyAuth, err := KeyAuthorization("token", jwk)
+	require.NoError(t, err)
+	h := sha256.Sum256([]byte(keyAuth))
+	txt := base64.RawURLEncoding.EncodeToString(h[:])
+
+	// The public view delegates the challenge, the internal one does not.
+	public := &dnsRecords{
+		txt:   map[string][]string{"www.validation.example.net": {txt}},
+		cname: map[string]string{"_acme-challenge.www.example.com": "www.validation.example.net."},
+	}
+	internal := &dnsRecords{
+		txt: map[string][]string{"_acme-challenge.www.example.com": {"internal"}},
+	}
+	db := &MockDB{
+		MockUpdateChallenge: func(ctx context.Context, ch *Challenge) error {
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name       string
+		opts       *provisioner.ACMEDNS01Options
+		wantStatus Status
+		wantErr    string
+	}{
+		{"ok", &provisioner.ACMEDNS01Options{
+			FollowCNAME: true,
+			Resolvers:   []string{startUDPDNS(t, public), startUDPDNS(t, public)},
+			Quorum:      2,
+		}, StatusValid, ""},
+		{"fail quorum", &provisioner.ACMEDNS01Options{
+			FollowCNAME: true,
+			Resolvers:   []string{startUDPDNS(t, public), "udp://" + startUDPDNS(t, internal)},
+			Quorum:      2,
+		}, StatusPending, "keyAuthorization does not match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			ctx := NewClientContext(context.Background(), NewClient())
+			ctx = NewProvisionerContext(ctx, &MockProvisioner{
+				MgetDNS01Options: func() *provisioner.ACMEDNS01Options { return opts },
+			})
+			ch := &Challenge{Type: DNS01, Status: StatusPending, Token: "token", Value: "www.example.com"}
+			require.NoError(t, ch.Validate(ctx, db, jwk, nil))
+			assert.Equal(t, tt.wantStatus, ch.Status)
+			if tt.wantErr == "" {
+				assert.Nil(t, ch.Error)
+			} else if assert.NotNil(t, ch.Error) {
+				assert.Contains(t, ch.Error.Err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
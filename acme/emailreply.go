@@ -0,0 +1,315 @@
+package acme
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/smallstep/certificates/notify"
+)
+
+const (
+	// emailReplySubject is the prefix of the token-part1 in the subject of
+	// the challenge email and its reply.
+	emailReplySubject = "ACME: "
+	// emailReplyBegin and emailReplyEnd enclose the response in the body of
+	// the reply.
+	emailReplyBegin = "-----BEGIN ACME RESPONSE-----"
+	emailReplyEnd   = "-----END ACME RESPONSE-----"
+	// maxMIMEDepth is the maximum number of nested multipart bodies read in a
+	// reply.
+	maxMIMEDepth = 4
+)
+
+// SendEmailChallenge sends the challenge email of an email-reply-00 challenge
+// to the email address of the identifier, as defined in RFC 8823 section 3.
+// The token-part1 is only sent in the subject of the email, and the
+// Message-ID of the email references the challenge, so its reply can be
+// matched to it. The email is sent with the email provider of the
+// notifications in the context.
+func SendEmailChallenge(ctx context.Context, ch *Challenge) error {
+	prov := MustProvisionerFromContext(ctx)
+	opts := prov.GetEmailReplyOptions()
+	if opts == nil {
+		return NewErrorISE("email-reply-00 challenges are not configured")
+	}
+	msg := &notify.Message{
+		Channel: notify.Email,
+		From:    ch.From,
+		To:      ch.Value,
+		Subject: emailReplySubject + ch.TokenPart1,
+		Header: map[string]string{
+			"Message-ID":     emailChallengeMessageID(ch.ID, ch.From),
+			"Auto-Submitted": "auto-generated; type=acme",
+		},
+		Body: fmt.Sprintf("This is an ACME challenge to validate the email address %s.\n\n"+
+			"If you requested a certificate for it, your ACME client will use this message to complete the request. "+
+			"Otherwise, you can ignore it.\n", ch.Value),
+	}
+	if err := notify.FromContext(ctx).Send(ctx, msg); err != nil {
+		return WrapErrorISE(err, "error sending challenge email to %s", ch.Value)
+	}
+	return nil
+}
+
+// emailChallengeMessageID returns the Message-ID of the challenge email of the
+// given challenge, the domain is the one of the sender.
+func emailChallengeMessageID(chID, from string) string {
+	return "<" + chID + "@" + emailDomain(from) + ">"
+}
+
+// emailDomain returns the domain of the given email address.
+func emailDomain(addr string) string {
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		return addr[i+1:]
+	}
+	return ""
+}
+
+// emailReply00Validate marks the email-reply-00 challenge as processing. The
+// challenge is validated when the reply to the challenge email is received,
+// see ValidateEmailReply.
+func emailReply00Validate(ctx context.Context, ch *Challenge, db DB) error {
+	ch.Status = StatusProcessing
+	if err := db.UpdateChallenge(ctx, ch); err != nil {
+		return WrapErrorISE(err, "error updating challenge")
+	}
+	return nil
+}
+
+// ValidateEmailReply validates an email-reply-00 challenge with the reply to
+// its challenge email, read from r in the Internet Message Format. The
+// challenge is the one referenced in the In-Reply-To or References header
+// fields.
+//
+// Replies that are not sent from the email address of the identifier, or
+// that don't pass the Authentication-Results check of the provisioner, are
+// rejected without changing the challenge. Otherwise, the challenge is valid
+// if the subject contains the token-part1 and the body contains the
+// base64url-encoded SHA-256 digest of the key authorization, and invalid if
+// they don't.
+func ValidateEmailReply(ctx context.Context, db DB, r io.Reader) (*Challenge, error) {
+	prov := MustProvisionerFromContext(ctx)
+	opts := prov.GetEmailReplyOptions()
+	if opts == nil {
+		return nil, NewError(ErrorMalformedType, "email-reply-00 challenges are not enabled")
+	}
+
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, WrapError(ErrorMalformedType, err, "error parsing email reply")
+	}
+	chID := emailReplyChallengeID(msg.Header, opts.From)
+	if chID == "" {
+		return nil, NewError(ErrorMalformedType, "email reply does not reference a challenge email")
+	}
+	ch, err := db.GetChallenge(ctx, chID, "")
+	if err != nil {
+		return nil, WrapErrorISE(err, "error retrieving challenge")
+	}
+	if ch.Type != EMAILREPLY00 {
+		return nil, NewError(ErrorMalformedType, "challenge %s is not an email-reply-00 challenge", ch.ID)
+	}
+	if ch.Status != StatusPending && ch.Status != StatusProcessing {
+		return ch, nil
+	}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil || !strings.EqualFold(from.Address, ch.Value) {
+		return nil, NewError(ErrorUnauthorizedType, "email reply for challenge %s is not from %s", ch.ID, ch.Value)
+	}
+	if opts.AuthServID != "" && !isEmailAuthenticated(msg.Header, opts.AuthServID, emailDomain(ch.Value)) {
+		return nil, NewError(ErrorUnauthorizedType, "email reply for challenge %s is not authenticated by %s", ch.ID, opts.AuthServID)
+	}
+
+	text, err := emailText(msg.Header, msg.Body, 0)
+	if err != nil {
+		return nil, WrapError(ErrorMalformedType, err, "error reading email reply")
+	}
+	if err := ch.validateEmailReply(ctx, db, msg.Header, text); err != nil {
+		return nil, err
+	}
+	if ch.Status == StatusInvalid {
+		ch.recordFailedValidation(ctx, db)
+	}
+	return ch, nil
+}
+
+// validateEmailReply checks the subject and the response of the reply. Like
+// the other validations, the attempt is reported to the meter in the
+// context.
+func (ch *Challenge) validateEmailReply(ctx context.Context, db DB, h mail.Header, text string) (err error) {
+	start := time.Now()
+	defer func() {
+		MeterFromContext(ctx).ChallengeValidated(ctx, ch, time.Since(start), err)
+	}()
+
+	subject := h.Get("Subject")
+	if s, err := new(mime.WordDecoder).DecodeHeader(subject); err == nil {
+		subject = s
+	}
+	if !strings.Contains(subject, emailReplySubject+ch.TokenPart1) {
+		return storeError(ctx, db, ch, true, NewError(ErrorIncorrectResponseType,
+			"email reply subject does not contain the token of the challenge"))
+	}
+	response, ok := emailReplyResponse(text)
+	if !ok {
+		return storeError(ctx, db, ch, true, NewError(ErrorIncorrectResponseType,
+			"email reply does not contain an ACME response"))
+	}
+
+	acc, err := db.GetAccount(ctx, ch.AccountID)
+	if err != nil {
+		return WrapErrorISE(err, "error retrieving account %s", ch.AccountID)
+	}
+	keyAuth, err := KeyAuthorization(ch.TokenPart1+ch.Token, acc.Key)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(keyAuth))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(response), []byte(expected)) != 1 {
+		return storeError(ctx, db, ch, true, NewError(ErrorIncorrectResponseType,
+			"email reply response does not match the key authorization"))
+	}
+
+	ch.Status = StatusValid
+	ch.Error = nil
+	ch.ValidatedAt = clock.Now().Format(time.RFC3339)
+	if err = db.UpdateChallenge(ctx, ch); err != nil {
+		return WrapErrorISE(err, "error updating challenge")
+	}
+	return nil
+}
+
+// emailReplyChallengeID returns the id of the challenge referenced in the
+// In-Reply-To header field, or in the last message id of the References
+// header field, sent from the domain of the given address.
+func emailReplyChallengeID(h mail.Header, from string) string {
+	domain := emailDomain(from)
+	ids := strings.Fields(h.Get("In-Reply-To"))
+	refs := strings.Fields(h.Get("References"))
+	for i := len(refs) - 1; i >= 0; i-- {
+		ids = append(ids, refs[i])
+	}
+	for _, id := range ids {
+		id = strings.TrimSuffix(strings.TrimPrefix(id, "<"), ">")
+		if i := strings.LastIndex(id, "@"); i > 0 && strings.EqualFold(id[i+1:], domain) {
+			return id[:i]
+		}
+	}
+	return ""
+}
+
+// isEmailAuthenticated returns true if the message has an
+// Authentication-Results header field, as defined in RFC 8601, added by the
+// given authentication service with a DKIM or a DMARC pass for the given
+// domain.
+func isEmailAuthenticated(h mail.Header, authServID, domain string) bool {
+	for _, v := range h["Authentication-Results"] {
+		results := strings.Split(v, ";")
+		if id := strings.Fields(results[0]); len(id) == 0 || !strings.EqualFold(id[0], authServID) {
+			continue
+		}
+		for _, res := range results[1:] {
+			fields := strings.Fields(res)
+			if len(fields) == 0 {
+				continue
+			}
+			method, result, _ := strings.Cut(fields[0], "=")
+			var property string
+			switch strings.ToLower(method) {
+			case "dkim":
+				property = "header.d"
+			case "dmarc":
+				property = "header.from"
+			default:
+				continue
+			}
+			if !strings.EqualFold(result, "pass") {
+				continue
+			}
+			for _, f := range fields[1:] {
+				if k, v, ok := strings.Cut(f, "="); ok && strings.EqualFold(k, property) && strings.EqualFold(strings.Trim(v, `"`), domain) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// emailReplyResponse returns the response enclosed in the ACME RESPONSE lines
+// of the reply, without white space.
+func emailReplyResponse(text string) (string, bool) {
+	i := strings.Index(text, emailReplyBegin)
+	if i < 0 {
+		return "", false
+	}
+	text = text[i+len(emailReplyBegin):]
+	j := strings.Index(text, emailReplyEnd)
+	if j < 0 {
+		return "", false
+	}
+	return strings.Join(strings.Fields(text[:j]), ""), true
+}
+
+// mimeHeader is the interface shared by mail.Header and textproto.MIMEHeader,
+// the header of the parts of a multipart body.
+type mimeHeader interface {
+	Get(key string) string
+}
+
+// emailText returns the text/plain content of a message body. In multipart
+// bodies the text/plain parts are concatenated.
+func emailText(h mimeHeader, body io.Reader, depth int) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		if depth >= maxMIMEDepth {
+			return "", fmt.Errorf("too many nested multipart bodies")
+		}
+		var sb strings.Builder
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return sb.String(), nil
+			}
+			if err != nil {
+				return "", err
+			}
+			s, err := emailText(part.Header, part, depth+1)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(s)
+		}
+	case mediaType == "text/plain":
+		switch strings.ToLower(h.Get("Content-Transfer-Encoding")) {
+		case "quoted-printable":
+			body = quotedprintable.NewReader(body)
+		case "base64":
+			body = base64.NewDecoder(base64.StdEncoding, body)
+		}
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	default:
+		return "", nil
+	}
+}
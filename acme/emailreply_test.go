@@ -0,0 +1,193 @@
+package acme
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/notify"
+)
+
+type fakeEmailProvider struct {
+	msg *notify.Message
+	err error
+}
+
+func (p *fakeEmailProvider) Send(_ context.Context, msg *notify.Message) error {
+	p.msg = msg
+	return p.err
+}
+
+func emailReplyContext(opts *provisioner.ACMEEmailReplyOptions) context.Context {
+	return NewProvisionerContext(context.Background(), &MockProvisioner{
+		MgetEmailReplyOptions: func() *provisioner.ACMEEmailReplyOptions { return opts },
+	})
+}
+
+func TestSendEmailChallenge(t *testing.T) {
+	opts := &provisioner.ACMEEmailReplyOptions{
+		From:   "acme@ca.example.com",
+		Secret: "secret",
+	}
+	ch := &Challenge{
+		ID:         "chID",
+		Type:       EMAILREPLY00,
+		Value:      "jane@example.com",
+		Token:      "part2",
+		TokenPart1: "part1",
+		From:       opts.From,
+	}
+
+	p := new(fakeEmailProvider)
+	notifier := notify.NewNotifier(map[notify.Channel]notify.Provider{notify.Email: p})
+	ctx := notify.NewContext(emailReplyContext(opts), notifier)
+	require.NoError(t, SendEmailChallenge(ctx, ch))
+	assert.Equal(t, notify.Email, p.msg.Channel)
+	assert.Equal(t, "acme@ca.example.com", p.msg.From)
+	assert.Equal(t, "jane@example.com", p.msg.To)
+	assert.Equal(t, "ACME: part1", p.msg.Subject)
+	assert.Equal(t, "<chID@ca.example.com>", p.msg.Header["Message-ID"])
+	assert.Equal(t, "auto-generated; type=acme", p.msg.Header["Auto-Submitted"])
+	assert.NotContains(t, p.msg.Body, "part2")
+
+	p.err = errors.New("connection refused")
+	err := SendEmailChallenge(ctx, ch)
+	assert.EqualError(t, err, "error sending challenge email to jane@example.com: connection refused")
+
+	err = SendEmailChallenge(emailReplyContext(opts), ch)
+	assert.EqualError(t, err, "error sending challenge email to jane@example.com: notification channel is not configured")
+
+	err = SendEmailChallenge(emailReplyContext(nil), ch)
+	assert.EqualError(t, err, "email-reply-00 challenges are not configured")
+}
+
+func TestValidateEmailReply(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	require.NoError(t, err)
+	pub := jwk.Public()
+	keyAuth, err := KeyAuthorization("part1part2", &pub)
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte(keyAuth))
+	response := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	opts := &provisioner.ACMEEmailReplyOptions{
+		From:   "acme@ca.example.com",
+		Secret: "secret",
+	}
+	newReply := func(headers, body string) string {
+		return "From: Jane <jane@example.com>\r\n" +
+			"To: acme@ca.example.com\r\n" +
+			"Subject: RE: ACME: part1\r\n" +
+			"In-Reply-To: <chID@ca.example.com>\r\n" + headers +
+			"\r\n" + body
+	}
+	okBody := "Hi,\r\n-----BEGIN ACME RESPONSE-----\r\n" + response[:20] + "\r\n" + response[20:] + "\r\n-----END ACME RESPONSE-----\r\n"
+
+	tests := []struct {
+		name       string
+		opts       *provisioner.ACMEEmailReplyOptions
+		reply      string
+		status     Status
+		wantErr    string
+		wantUpdate bool
+	}{
+		{"ok", opts, newReply("", okBody), StatusValid, "", true},
+		{"ok/multipart", opts, newReply("MIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=b\r\n",
+			"--b\r\nContent-Type: text/html\r\n\r\n<p>Hi</p>\r\n--b\r\nContent-Type: text/plain\r\nContent-Transfer-Encoding: base64\r\n\r\n"+
+				base64.StdEncoding.EncodeToString([]byte(okBody))+"\r\n--b--\r\n"), StatusValid, "", true},
+		{"ok/authenticated", &provisioner.ACMEEmailReplyOptions{From: opts.From, Secret: "secret", AuthServID: "mx.ca.example.com"},
+			newReply("Authentication-Results: mx.ca.example.com; spf=pass smtp.mailfrom=example.com;\r\n dkim=pass (2048-bit key) header.d=example.com header.s=s1\r\n", okBody), StatusValid, "", true},
+		{"fail/response", opts, newReply("", "-----BEGIN ACME RESPONSE-----\r\nfoo\r\n-----END ACME RESPONSE-----\r\n"), StatusInvalid, "", true},
+		{"fail/no-response", opts, newReply("", "Hi\r\n"), StatusInvalid, "", true},
+		{"fail/subject", opts, strings.Replace(newReply("", okBody), "ACME: part1", "ACME: other", 1), StatusInvalid, "", true},
+		{"fail/from", opts, strings.Replace(newReply("", okBody), "jane@example.com", "joe@example.com", 1), StatusPending,
+			"email reply for challenge chID is not from jane@example.com", false},
+		{"fail/not-authenticated", &provisioner.ACMEEmailReplyOptions{From: opts.From, Secret: "secret", AuthServID: "mx.ca.example.com"},
+			newReply("Authentication-Results: other.example.com; dkim=pass header.d=example.com\r\n", okBody), StatusPending,
+			"email reply for challenge chID is not authenticated by mx.ca.example.com", false},
+		{"fail/reference", opts, strings.Replace(newReply("", okBody), "In-Reply-To: <chID@ca.example.com>\r\n", "", 1), StatusPending,
+			"email reply does not reference a challenge email", false},
+		{"fail/disabled", nil, newReply("", okBody), StatusPending, "email-reply-00 challenges are not enabled", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated bool
+			db := &MockDB{
+				MockGetChallenge: func(ctx context.Context, id, authzID string) (*Challenge, error) {
+					assert.Equal(t, "chID", id)
+					return &Challenge{
+						ID:         "chID",
+						AccountID:  "accID",
+						Type:       EMAILREPLY00,
+						Status:     StatusProcessing,
+						Value:      "jane@example.com",
+						Token:      "part2",
+						TokenPart1: "part1",
+						From:       opts.From,
+					}, nil
+				},
+				MockGetAccount: func(ctx context.Context, id string) (*Account, error) {
+					assert.Equal(t, "accID", id)
+					return &Account{ID: id, Key: &pub}, nil
+				},
+				MockUpdateChallenge: func(ctx context.Context, ch *Challenge) error {
+					updated = true
+					return nil
+				},
+			}
+			ch, err := ValidateEmailReply(emailReplyContext(tt.opts), db, strings.NewReader(tt.reply))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.False(t, updated)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, ch.Status)
+			assert.Equal(t, tt.wantUpdate, updated)
+			if tt.status == StatusInvalid {
+				require.NotNil(t, ch.Error)
+				assert.Equal(t, 400, ch.Error.Status)
+			}
+		})
+	}
+}
+
+func Test_emailReplyChallengeID(t *testing.T) {
+	h := mail.Header{
+		"References": {"<a@example.com> <chID@ca.example.com> <b@example.com>"},
+	}
+	assert.Equal(t, "chID", emailReplyChallengeID(h, "acme@ca.example.com"))
+	h["In-Reply-To"] = []string{"<other@CA.example.com>"}
+	assert.Equal(t, "other", emailReplyChallengeID(h, "acme@ca.example.com"))
+	assert.Equal(t, "", emailReplyChallengeID(h, "acme@other.example.com"))
+}
+
+func Test_isEmailAuthenticated(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{"dkim", "mx.example.com; dkim=pass header.d=example.org", true},
+		{"dmarc", "mx.example.com 1; spf=fail; dmarc=pass (p=reject) header.from=Example.org", true},
+		{"fail/dkim", "mx.example.com; dkim=fail header.d=example.org", false},
+		{"fail/domain", "mx.example.com; dkim=pass header.d=attacker.example", false},
+		{"fail/authserv-id", "mx.attacker.example; dkim=pass header.d=example.org", false},
+		{"fail/spf", "mx.example.com; spf=pass smtp.mailfrom=example.org", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := mail.Header{"Authentication-Results": {tt.value}}
+			assert.Equal(t, tt.want, isEmailAuthenticated(h, "mx.example.com", "example.org"))
+		})
+	}
+}
@@ -3,10 +3,15 @@ package acme
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/errs"
 )
 
 // ProblemType is the type of the ACME problem.
@@ -65,6 +70,12 @@ const (
 	ErrorUserActionRequiredType
 	// ErrorNotImplementedType operation is not implemented
 	ErrorNotImplementedType
+	// ErrorAutoRenewalCanceledType the auto-renewal of the order has been canceled
+	ErrorAutoRenewalCanceledType
+	// ErrorAutoRenewalExpiredType the auto-renewal of the order has expired
+	ErrorAutoRenewalExpiredType
+	// ErrorAutoRenewalCancellationInvalidType the order cannot be canceled
+	ErrorAutoRenewalCancellationInvalidType
 )
 
 // String returns the string representation of the acme problem type,
@@ -121,6 +132,12 @@ func (ap ProblemType) String() string {
 		return "userActionRequired"
 	case ErrorNotImplementedType:
 		return "notImplemented"
+	case ErrorAutoRenewalCanceledType:
+		return "autoRenewalCanceled"
+	case ErrorAutoRenewalExpiredType:
+		return "autoRenewalExpired"
+	case ErrorAutoRenewalCancellationInvalidType:
+		return "autoRenewalCancellationInvalid"
 	default:
 		return fmt.Sprintf("unsupported type ACME error type '%d'", int(ap))
 	}
@@ -229,7 +246,7 @@ var (
 		ErrorRateLimitedType: {
 			typ:     officialACMEPrefix + ErrorRateLimitedType.String(),
 			details: "The request exceeds a rate limit",
-			status:  400,
+			status:  429,
 		},
 		ErrorRejectedIdentifierType: {
 			typ:     officialACMEPrefix + ErrorRejectedIdentifierType.String(),
@@ -266,6 +283,21 @@ var (
 			details: "Visit the “instance” URL and take actions specified there",
 			status:  400,
 		},
+		ErrorAutoRenewalCanceledType: {
+			typ:     officialACMEPrefix + ErrorAutoRenewalCanceledType.String(),
+			details: "The auto-renewal of the order has been canceled",
+			status:  403,
+		},
+		ErrorAutoRenewalExpiredType: {
+			typ:     officialACMEPrefix + ErrorAutoRenewalExpiredType.String(),
+			details: "The auto-renewal of the order has expired",
+			status:  403,
+		},
+		ErrorAutoRenewalCancellationInvalidType: {
+			typ:     officialACMEPrefix + ErrorAutoRenewalCancellationInvalidType.String(),
+			details: "The order cannot be canceled",
+			status:  400,
+		},
 		ErrorServerInternalType: errorServerInternalMetadata,
 	}
 )
@@ -273,10 +305,14 @@ var (
 // Error represents an ACME Error
 type Error struct {
 	Type        string       `json:"type"`
+	Code        errs.Code    `json:"code,omitempty"`
 	Detail      string       `json:"detail"`
 	Subproblems []Subproblem `json:"subproblems,omitempty"`
 	Err         error        `json:"-"`
 	Status      int          `json:"-"`
+	// RetryAfter is the time after which the client can retry the request,
+	// it is sent in the Retry-After header.
+	RetryAfter time.Duration `json:"-"`
 }
 
 // Subproblem represents an ACME subproblem. It's fairly
@@ -347,6 +383,7 @@ func newError(pt ProblemType, err error) *Error {
 		meta = errorServerInternalMetadata
 		return &Error{
 			Type:   meta.typ,
+			Code:   problemCode(meta.typ),
 			Detail: meta.details,
 			Status: meta.status,
 			Err:    err,
@@ -355,12 +392,19 @@ func newError(pt ProblemType, err error) *Error {
 
 	return &Error{
 		Type:   meta.typ,
+		Code:   problemCode(meta.typ),
 		Detail: meta.details,
 		Status: meta.status,
 		Err:    err,
 	}
 }
 
+// problemCode returns the code of an ACME problem type, the name of the
+// problem, for example rateLimited for urn:ietf:params:acme:error:rateLimited.
+func problemCode(typ string) errs.Code {
+	return errs.Code(typ[strings.LastIndex(typ, ":")+1:])
+}
+
 // NewErrorISE creates a new ErrorServerInternalType Error.
 func NewErrorISE(msg string, args ...interface{}) *Error {
 	return NewError(ErrorServerInternalType, msg, args...)
@@ -398,6 +442,15 @@ func (e *Error) StatusCode() int {
 	return e.Status
 }
 
+// ErrorCode implements the errs.Coder interface and returns the code of the
+// error.
+func (e *Error) ErrorCode() errs.Code {
+	if e.Code != "" {
+		return e.Code
+	}
+	return problemCode(e.Type)
+}
+
 // Error implements the error interface.
 func (e *Error) Error() string {
 	if e.Err == nil {
@@ -426,5 +479,8 @@ func (e *Error) ToLog() (interface{}, error) {
 // Render implements render.RenderableError for Error.
 func (e *Error) Render(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/problem+json")
+	if e.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(e.RetryAfter.Seconds())), 10))
+	}
 	render.JSONStatus(w, e, e.StatusCode())
 }
@@ -6,6 +6,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/smallstep/certificates/errs"
 )
 
 func mustJSON(t *testing.T, m map[string]interface{}) string {
@@ -21,15 +23,18 @@ func TestError_WithAdditionalErrorDetail(t *testing.T) {
 	internalJSON := mustJSON(t, map[string]interface{}{
 		"detail": "The server experienced an internal error",
 		"type":   "urn:ietf:params:acme:error:serverInternal",
+		"code":   "serverInternal",
 	})
 	malformedErr := NewError(ErrorMalformedType, "malformed error") // will result in Err == nil behavior
 	malformedJSON := mustJSON(t, map[string]interface{}{
 		"detail": "The request message was malformed",
 		"type":   "urn:ietf:params:acme:error:malformed",
+		"code":   "malformed",
 	})
 	withDetailJSON := mustJSON(t, map[string]interface{}{
 		"detail": "Attestation statement cannot be verified: invalid property",
 		"type":   "urn:ietf:params:acme:error:badAttestationStatement",
+		"code":   "badAttestationStatement",
 	})
 	tests := []struct {
 		name string
@@ -52,3 +57,14 @@ func TestError_WithAdditionalErrorDetail(t *testing.T) {
 		})
 	}
 }
+
+func TestError_ErrorCode(t *testing.T) {
+	assert.Equal(t, errs.CodeRateLimited, NewError(ErrorRateLimitedType, "too many requests").ErrorCode())
+	assert.Equal(t, errs.Code("badNonce"), NewError(ErrorBadNonceType, "bad nonce").ErrorCode())
+	assert.Equal(t, errs.Code("unauthorized"), (&Error{Type: "urn:ietf:params:acme:error:unauthorized"}).ErrorCode())
+
+	// The code can be more specific than the problem type.
+	err := NewErrorISE("signing key is unavailable")
+	err.Code = errs.CodeSignerUnavailable
+	assert.Equal(t, errs.CodeSignerUnavailable, errs.CodeOf(WrapErrorISE(err, "error signing certificate")))
+}
@@ -0,0 +1,102 @@
+package acme
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// WithFaults returns a client that calls inject before the outbound operations
+// of the validations, with the operation http, dns, or tls, and fails the
+// operation if it returns an error. It's used to test the reliability of the
+// validations. It returns the given client if inject is nil.
+//
+// The returned client keeps the options, the resolvers, and the validation
+// records of the given client.
+func WithFaults(c Client, inject func(op string) error) Client {
+	if inject == nil {
+		return c
+	}
+	return &faultClient{client: c, inject: inject}
+}
+
+// faultClient is a Client that injects faults before each operation.
+type faultClient struct {
+	client Client
+	inject func(op string) error
+}
+
+func (c *faultClient) Get(url string) (*http.Response, error) {
+	if err := c.inject("http"); err != nil {
+		return nil, err
+	}
+	return c.client.Get(url)
+}
+
+func (c *faultClient) getWithRecord(url string, rec *ValidationRecord) (*http.Response, error) {
+	if err := c.inject("http"); err != nil {
+		return nil, err
+	}
+	return httpGet(c.client, url, rec)
+}
+
+func (c *faultClient) LookupTxt(name string) ([]string, error) {
+	if err := c.inject("dns"); err != nil {
+		return nil, err
+	}
+	return c.client.LookupTxt(name)
+}
+
+func (c *faultClient) lookupCNAME(name string) (string, error) {
+	cc, ok := c.client.(cnameClient)
+	if !ok {
+		return "", errors.New("client does not support CNAME lookups")
+	}
+	if err := c.inject("dns"); err != nil {
+		return "", err
+	}
+	return cc.lookupCNAME(name)
+}
+
+func (c *faultClient) lookupCAA(name string) ([]*CAARecord, error) {
+	cc, ok := c.client.(caaClient)
+	if !ok {
+		return nil, errors.New("client does not support CAA lookups")
+	}
+	if err := c.inject("dns"); err != nil {
+		return nil, err
+	}
+	return cc.lookupCAA(name)
+}
+
+func (c *faultClient) withResolvers(opts *provisioner.ACMEDNS01Options) Client {
+	rc, ok := c.client.(resolversClient)
+	if !ok {
+		return c
+	}
+	return &faultClient{
+		client: rc.withResolvers(opts),
+		inject: c.inject,
+	}
+}
+
+func (c *faultClient) withHTTP01Options(opts *provisioner.ACMEHTTP01Options) Client {
+	hc, ok := c.client.(http01Client)
+	if !ok {
+		return c
+	}
+	return &faultClient{
+		client: hc.withHTTP01Options(opts),
+		inject: c.inject,
+	}
+}
+
+func (c *faultClient) TLSDial(network, addr string, config *tls.Config) (*tls.Conn, error) {
+	if err := c.inject("tls"); err != nil {
+		return nil, err
+	}
+	return c.client.TLSDial(network, addr, config)
+}
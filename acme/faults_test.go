@@ -0,0 +1,101 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func TestWithFaults(t *testing.T) {
+	c := NewClient()
+	assert.Equal(t, c, WithFaults(c, nil))
+
+	var ops []string
+	fc := WithFaults(c, func(op string) error {
+		ops = append(ops, op)
+		return errors.New("injected")
+	})
+	_, err := fc.Get("http://127.0.0.1/")
+	assert.EqualError(t, err, "injected")
+	_, err = fc.(recordingClient).getWithRecord("http://127.0.0.1/", &ValidationRecord{})
+	assert.EqualError(t, err, "injected")
+	_, err = fc.LookupTxt("example.com")
+	assert.EqualError(t, err, "injected")
+	_, err = fc.(cnameClient).lookupCNAME("example.com")
+	assert.EqualError(t, err, "injected")
+	_, err = fc.(caaClient).lookupCAA("example.com")
+	assert.EqualError(t, err, "injected")
+	_, err = fc.TLSDial("tcp", "127.0.0.1:443", nil)
+	assert.EqualError(t, err, "injected")
+	assert.Equal(t, []string{"http", "http", "dns", "dns", "dns", "tls"}, ops)
+
+	// The clients with options keep injecting faults.
+	_, err = fc.(http01Client).withHTTP01Options(&provisioner.ACMEHTTP01Options{}).Get("http://127.0.0.1/")
+	assert.EqualError(t, err, "injected")
+	_, err = fc.(resolversClient).withResolvers(&provisioner.ACMEDNS01Options{Resolvers: []string{"127.0.0.1:53"}}).LookupTxt("example.com")
+	assert.EqualError(t, err, "injected")
+}
+
+func TestWithFaults_http01Validate(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	require.NoError(t, err)
+	pub := jwk.Public()
+	keyAuth, err := KeyAuthorization("token", &pub)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, keyAuth)
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+
+	tmp := InsecurePortHTTP01
+	t.Cleanup(func() { InsecurePortHTTP01 = tmp })
+	InsecurePortHTTP01 = port
+
+	tests := []struct {
+		name       string
+		opts       *provisioner.ACMEHTTP01Options
+		wantStatus Status
+	}{
+		{"ok", nil, StatusValid},
+		{"fail/denied", &provisioner.ACMEHTTP01Options{DenyPrivateAddresses: true}, StatusPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewProvisionerContext(context.Background(), &MockProvisioner{
+				MgetHTTP01Options: func() *provisioner.ACMEHTTP01Options { return tt.opts },
+			})
+			ctx = NewClientContext(ctx, WithFaults(NewClient(), func(string) error { return nil }))
+			ch := &Challenge{ID: "chID", Type: HTTP01, Status: StatusPending, Token: "token", Value: "127.0.0.1"}
+			db := &MockDB{
+				MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+					return nil
+				},
+			}
+			require.NoError(t, http01Validate(ctx, ch, db, &pub))
+			assert.Equal(t, tt.wantStatus, ch.Status)
+			if tt.wantStatus == StatusPending {
+				require.NotNil(t, ch.Error)
+				assert.Contains(t, ch.Error.Err.Error(), "address 127.0.0.1 is not allowed")
+				return
+			}
+			require.Len(t, ch.ValidationRecord, 1)
+			assert.Equal(t, "127.0.0.1", ch.ValidationRecord[0].AddressUsed)
+		})
+	}
+}
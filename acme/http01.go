@@ -0,0 +1,242 @@
+package acme
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// http01Client is implemented by the clients that can send the requests of
+// the http-01 challenges with the options of the provisioner.
+type http01Client interface {
+	withHTTP01Options(opts *provisioner.ACMEHTTP01Options) Client
+}
+
+// sharedAddressSpace is the carrier-grade NAT network defined in RFC 6598.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPrivateAddress returns true if the given IP address is not a public
+// unicast address.
+func isPrivateAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// addressFilter rejects the addresses denied by the http-01 options.
+type addressFilter struct {
+	denyPrivate bool
+	networks    []*net.IPNet
+}
+
+func newAddressFilter(opts *provisioner.ACMEHTTP01Options) *addressFilter {
+	f := &addressFilter{
+		denyPrivate: opts.DenyPrivateAddresses,
+		networks:    opts.GetDeniedNetworks(),
+	}
+	if !f.denyPrivate && len(f.networks) == 0 {
+		return nil
+	}
+	return f
+}
+
+func (f *addressFilter) check(ip net.IP) error {
+	if f.denyPrivate && isPrivateAddress(ip) {
+		return errors.Errorf("address %s is not allowed", ip)
+	}
+	for _, n := range f.networks {
+		if n.Contains(ip) {
+			return errors.Errorf("address %s is not allowed", ip)
+		}
+	}
+	return nil
+}
+
+// control is used as the net.Dialer Control function. It runs with the
+// resolved address right before connecting, so names that resolve to a
+// different address on each lookup cannot bypass the filter.
+func (f *addressFilter) control(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	host, _, _ = strings.Cut(host, "%")
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.Errorf("address %s is not valid", address)
+	}
+	return f.check(ip)
+}
+
+// checkHost resolves the given host and checks all its addresses. It's used
+// with proxies, because the connections to the targets are opened by them.
+func (f *addressFilter) checkHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		return f.check(ip)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		if err := f.check(a.IP); err != nil {
+			return errors.Wrapf(err, "error checking %s", host)
+		}
+	}
+	return nil
+}
+
+// sourceAddresses returns the local IP addresses of the given source
+// interface, its name or one of its addresses.
+func sourceAddresses(name string) ([]net.IP, error) {
+	if ip := net.ParseIP(name); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error looking up interface %s", name)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error looking up the addresses of interface %s", name)
+	}
+	var ips []net.IP
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && !n.IP.IsLinkLocalUnicast() {
+			ips = append(ips, n.IP)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, errors.Errorf("interface %s does not have any address", name)
+	}
+	return ips, nil
+}
+
+// http01Dialer opens the connections of the http-01 requests, from the
+// source interface if one is configured.
+type http01Dialer struct {
+	dialer *net.Dialer
+	source string
+}
+
+func (d *http01Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.source == "" {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+
+	// The local address must have the family of the remote one, so the names
+	// are resolved here and each address is dialed from a local address of
+	// the same family.
+	locals, err := sourceAddresses(d.source)
+	if err != nil {
+		return nil, err
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	var remotes []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		remotes = []net.IP{ip}
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			remotes = append(remotes, a.IP)
+		}
+	}
+
+	err = errors.Errorf("interface %s cannot connect to %s", d.source, host)
+	for _, remote := range remotes {
+		for _, local := range locals {
+			if (local.To4() == nil) != (remote.To4() == nil) {
+				continue
+			}
+			dialer := *d.dialer
+			dialer.LocalAddr = &net.TCPAddr{IP: local}
+			var conn net.Conn
+			if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(remote.String(), port)); err == nil {
+				return conn, nil
+			}
+			break
+		}
+	}
+	return nil, err
+}
+
+// checkRedirect returns the CheckRedirect function of the http-01 client.
+// Each redirect must use http or https on the standard ports, the address of
+// the new host is filtered when the connection is opened.
+func checkRedirect(maxRedirects int) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return errors.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		switch req.URL.Scheme {
+		case "http", "https":
+		default:
+			return errors.Errorf("redirect to %s uses an unsupported scheme", req.URL)
+		}
+		switch port := req.URL.Port(); port {
+		case "", "80", "443":
+		default:
+			if InsecurePortHTTP01 == 0 || port != strconv.Itoa(InsecurePortHTTP01) {
+				return errors.Errorf("redirect to %s uses an unsupported port", req.URL)
+			}
+		}
+		return nil
+	}
+}
+
+// newHTTP01Client returns the HTTP client used to validate the http-01
+// challenges with the given options.
+func newHTTP01Client(opts *provisioner.ACMEHTTP01Options) *http.Client {
+	filter := newAddressFilter(opts)
+	proxy := opts.GetProxy()
+	dialer := &net.Dialer{
+		Timeout: opts.GetDialTimeout(),
+	}
+	if filter != nil && proxy == nil {
+		dialer.Control = filter.control
+	}
+
+	transport := &http.Transport{
+		DialContext: (&http01Dialer{
+			dialer: dialer,
+			source: opts.SourceInterface,
+		}).DialContext,
+		TLSClientConfig: &tls.Config{
+			//nolint:gosec // used on http-01 redirects to https
+			InsecureSkipVerify: true, // lgtm[go/disabled-certificate-check]
+		},
+		TLSHandshakeTimeout: opts.GetDialTimeout(),
+		DisableKeepAlives:   true,
+	}
+	if proxy != nil {
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if filter != nil {
+				if err := filter.checkHost(req.Context(), req.URL.Hostname()); err != nil {
+					return nil, err
+				}
+			}
+			return proxy, nil
+		}
+	}
+
+	return &http.Client{
+		Timeout:       opts.GetTimeout(),
+		Transport:     transport,
+		CheckRedirect: checkRedirect(opts.GetMaxRedirects()),
+	}
+}
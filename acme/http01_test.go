@@ -0,0 +1,208 @@
+package acme
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func Test_addressFilter(t *testing.T) {
+	f := newAddressFilter(&provisioner.ACMEHTTP01Options{
+		DenyPrivateAddresses: true,
+		DeniedNetworks:       []string{"203.0.113.0/24"},
+	})
+	for _, tt := range []struct {
+		ip      string
+		allowed bool
+	}{
+		{"8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"203.0.113.10", false},
+	} {
+		t.Run(tt.ip, func(t *testing.T) {
+			err := f.check(net.ParseIP(tt.ip))
+			assert.Equal(t, tt.allowed, err == nil, err)
+		})
+	}
+
+	assert.Nil(t, newAddressFilter(&provisioner.ACMEHTTP01Options{}))
+	assert.NoError(t, f.control("tcp", "8.8.8.8:80", nil))
+	assert.EqualError(t, f.control("tcp", "[fe80::1%eth0]:80", nil), "address fe80::1 is not allowed")
+	assert.Error(t, f.control("tcp", "localhost", nil))
+}
+
+func Test_checkRedirect(t *testing.T) {
+	tmp := InsecurePortHTTP01
+	t.Cleanup(func() { InsecurePortHTTP01 = tmp })
+	InsecurePortHTTP01 = 8080
+
+	newRequest := func(t *testing.T, u string) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, u, http.NoBody)
+		require.NoError(t, err)
+		return req
+	}
+	via := func(n int) []*http.Request {
+		return make([]*http.Request, n)
+	}
+	tests := []struct {
+		name    string
+		max     int
+		url     string
+		via     int
+		wantErr string
+	}{
+		{"ok", 10, "https://example.com/token", 1, ""},
+		{"ok/port", 1, "http://example.com:443/token", 1, ""},
+		{"ok/insecure-port", 1, "http://example.com:8080/token", 1, ""},
+		{"fail/max", 1, "http://example.com/token", 2, "stopped after 1 redirects"},
+		{"fail/disabled", 0, "http://example.com/token", 1, "stopped after 0 redirects"},
+		{"fail/scheme", 10, "ftp://example.com/token", 1, "redirect to ftp://example.com/token uses an unsupported scheme"},
+		{"fail/port", 10, "http://example.com:22/token", 1, "redirect to http://example.com:22/token uses an unsupported port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRedirect(tt.max)(newRequest(t, tt.url), via(tt.via))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_newHTTP01Client(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/ok", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+
+	// Redirects are only followed to the standard ports.
+	tmp := InsecurePortHTTP01
+	t.Cleanup(func() { InsecurePortHTTP01 = tmp })
+	InsecurePortHTTP01 = port
+
+	// The proxy answers all the requests.
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		fmt.Fprint(w, "proxied")
+	}))
+	t.Cleanup(proxy.Close)
+
+	zero := 0
+	tests := []struct {
+		name     string
+		opts     *provisioner.ACMEHTTP01Options
+		url      string
+		wantBody string
+		wantErr  string
+	}{
+		{"ok", &provisioner.ACMEHTTP01Options{}, srv.URL + "/redirect", "ok", ""},
+		{"ok/source", &provisioner.ACMEHTTP01Options{SourceInterface: "127.0.0.1"}, srv.URL + "/redirect", "ok", ""},
+		{"ok/proxy", &provisioner.ACMEHTTP01Options{Proxy: proxy.URL}, "http://192.0.2.1/token", "proxied", ""},
+		{"fail/denied", &provisioner.ACMEHTTP01Options{DenyPrivateAddresses: true}, srv.URL + "/redirect", "", "address 127.0.0.1 is not allowed"},
+		{"fail/denied-network", &provisioner.ACMEHTTP01Options{DeniedNetworks: []string{"127.0.0.0/8"}}, srv.URL + "/redirect", "", "address 127.0.0.1 is not allowed"},
+		{"fail/proxy-denied", &provisioner.ACMEHTTP01Options{Proxy: proxy.URL, DeniedNetworks: []string{"192.0.2.0/24"}}, "http://192.0.2.1/token", "", "address 192.0.2.1 is not allowed"},
+		{"fail/redirects", &provisioner.ACMEHTTP01Options{MaxRedirects: &zero}, srv.URL + "/redirect", "", "stopped after 0 redirects"},
+		{"fail/source", &provisioner.ACMEHTTP01Options{SourceInterface: "::1"}, srv.URL + "/redirect", "", "interface ::1 cannot connect to 127.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newHTTP01Client(tt.opts)
+			resp, err := c.Get(tt.url)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body := make([]byte, 16)
+			n, _ := resp.Body.Read(body)
+			assert.Equal(t, tt.wantBody, string(body[:n]))
+		})
+	}
+	assert.Equal(t, []string{"http://192.0.2.1/token"}, proxied)
+}
+
+func Test_http01Validate_options(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	require.NoError(t, err)
+	pub := jwk.Public()
+	keyAuth, err := KeyAuthorization("token", &pub)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, keyAuth)
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+
+	tmp := InsecurePortHTTP01
+	t.Cleanup(func() { InsecurePortHTTP01 = tmp })
+	InsecurePortHTTP01 = port
+
+	tests := []struct {
+		name       string
+		opts       *provisioner.ACMEHTTP01Options
+		wantStatus Status
+	}{
+		{"ok", nil, StatusValid},
+		{"ok/options", &provisioner.ACMEHTTP01Options{SourceInterface: "127.0.0.1"}, StatusValid},
+		{"fail/denied", &provisioner.ACMEHTTP01Options{DenyPrivateAddresses: true}, StatusPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := NewProvisionerContext(context.Background(), &MockProvisioner{
+				MgetHTTP01Options: func() *provisioner.ACMEHTTP01Options { return tt.opts },
+			})
+			ctx = NewClientContext(ctx, NewClient())
+			ch := &Challenge{ID: "chID", Type: HTTP01, Status: StatusPending, Token: "token", Value: "127.0.0.1"}
+			db := &MockDB{
+				MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+					return nil
+				},
+			}
+			require.NoError(t, http01Validate(ctx, ch, db, &pub))
+			assert.Equal(t, tt.wantStatus, ch.Status)
+			if tt.wantStatus == StatusPending {
+				require.NotNil(t, ch.Error)
+				assert.Contains(t, ch.Error.Err.Error(), "address 127.0.0.1 is not allowed")
+			}
+		})
+	}
+}
@@ -46,6 +46,12 @@ const (
 	RevokeCertLinkType
 	// KeyChangeLinkType key rollover
 	KeyChangeLinkType
+	// DiagnoseLinkType challenge diagnostics
+	DiagnoseLinkType
+	// EmailReplyLinkType replies of the email-reply-00 challenges
+	EmailReplyLinkType
+	// StarCertificateLinkType latest certificate of an auto-renewal order
+	StarCertificateLinkType
 )
 
 func (l LinkType) String() string {
@@ -74,6 +80,12 @@ func (l LinkType) String() string {
 		return "revoke-cert"
 	case KeyChangeLinkType:
 		return "key-change"
+	case DiagnoseLinkType:
+		return "diagnose"
+	case EmailReplyLinkType:
+		return "email-reply"
+	case StarCertificateLinkType:
+		return "star-certificate"
 	default:
 		return fmt.Sprintf("unexpected LinkType '%d'", int(l))
 	}
@@ -81,9 +93,9 @@ func (l LinkType) String() string {
 
 func GetUnescapedPathSuffix(typ LinkType, provisionerName string, inputs ...string) string {
 	switch typ {
-	case NewNonceLinkType, NewAccountLinkType, NewOrderLinkType, NewAuthzLinkType, DirectoryLinkType, KeyChangeLinkType, RevokeCertLinkType:
+	case NewNonceLinkType, NewAccountLinkType, NewOrderLinkType, NewAuthzLinkType, DirectoryLinkType, KeyChangeLinkType, RevokeCertLinkType, DiagnoseLinkType, EmailReplyLinkType:
 		return fmt.Sprintf("/%s/%s", provisionerName, typ)
-	case AccountLinkType, OrderLinkType, AuthzLinkType, CertificateLinkType:
+	case AccountLinkType, OrderLinkType, AuthzLinkType, CertificateLinkType, StarCertificateLinkType:
 		return fmt.Sprintf("/%s/%s/%s", provisionerName, typ, inputs[0])
 	case ChallengeLinkType:
 		return fmt.Sprintf("/%s/%s/%s/%s", provisionerName, typ, inputs[0], inputs[1])
@@ -233,7 +245,13 @@ func (l *linker) LinkOrder(ctx context.Context, o *Order) {
 		o.AuthorizationURLs[i] = l.GetLink(ctx, AuthzLinkType, azID)
 	}
 	o.FinalizeURL = l.GetLink(ctx, FinalizeLinkType, o.ID)
-	if o.CertificateID != "" {
+	switch {
+	case o.CertificateID == "":
+	case o.isStar():
+		// The latest certificate of auto-renewal orders is always available
+		// in the star-certificate URL, RFC 8739 section 3.1.1.
+		o.StarCertificateURL = l.GetLink(ctx, StarCertificateLinkType, o.ID)
+	default:
 		o.CertificateURL = l.GetLink(ctx, CertificateLinkType, o.CertificateID)
 	}
 }
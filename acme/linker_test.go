@@ -39,6 +39,7 @@ func TestGetUnescapedPathSuffix(t *testing.T) {
 	assert.Equals(t, getPath(NewAccountLinkType, "{provisionerID}"), "/{provisionerID}/new-account")
 	assert.Equals(t, getPath(AccountLinkType, "{provisionerID}", "{accID}"), "/{provisionerID}/account/{accID}")
 	assert.Equals(t, getPath(KeyChangeLinkType, "{provisionerID}"), "/{provisionerID}/key-change")
+	assert.Equals(t, getPath(DiagnoseLinkType, "{provisionerID}"), "/{provisionerID}/diagnose")
 	assert.Equals(t, getPath(NewOrderLinkType, "{provisionerID}"), "/{provisionerID}/new-order")
 	assert.Equals(t, getPath(OrderLinkType, "{provisionerID}", "{ordID}"), "/{provisionerID}/order/{ordID}")
 	assert.Equals(t, getPath(OrdersByAccountLinkType, "{provisionerID}", "{accID}"), "/{provisionerID}/account/{accID}/orders")
@@ -46,6 +47,7 @@ func TestGetUnescapedPathSuffix(t *testing.T) {
 	assert.Equals(t, getPath(AuthzLinkType, "{provisionerID}", "{authzID}"), "/{provisionerID}/authz/{authzID}")
 	assert.Equals(t, getPath(ChallengeLinkType, "{provisionerID}", "{authzID}", "{chID}"), "/{provisionerID}/challenge/{authzID}/{chID}")
 	assert.Equals(t, getPath(CertificateLinkType, "{provisionerID}", "{certID}"), "/{provisionerID}/certificate/{certID}")
+	assert.Equals(t, getPath(StarCertificateLinkType, "{provisionerID}", "{ordID}"), "/{provisionerID}/star-certificate/{ordID}")
 }
 
 func TestLinker_DNS(t *testing.T) {
@@ -234,6 +236,17 @@ func TestLinker_LinkOrder(t *testing.T) {
 				assert.Equals(t, o.CertificateURL, fmt.Sprintf("%s/%s/%s/certificate/%s", baseURL, linkerPrefix, provName, certID))
 			},
 		},
+		"auto-renewal": {
+			o: &Order{
+				ID:            oid,
+				CertificateID: certID,
+				AutoRenewal:   &AutoRenewal{Lifetime: 3600},
+			},
+			validate: func(o *Order) {
+				assert.Equals(t, o.CertificateURL, "")
+				assert.Equals(t, o.StarCertificateURL, fmt.Sprintf("%s/%s/%s/star-certificate/%s", baseURL, linkerPrefix, provName, oid))
+			},
+		},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -0,0 +1,274 @@
+package acme
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Meter wraps the set of callbacks used to gather the metrics and the events
+// of the ACME server. The provisioner of the request is available in the
+// given context.
+type Meter interface {
+	// AccountCreated is called when a new account has been created.
+	AccountCreated(ctx context.Context, acc *Account)
+
+	// ChallengeValidated is called after each validation attempt of a
+	// challenge, with the time spent validating it. The result of the attempt
+	// is in the status and the error of the challenge, err is an error not
+	// stored in the challenge, like a database error.
+	ChallengeValidated(ctx context.Context, ch *Challenge, d time.Duration, err error)
+
+	// OrderFinalized is called when the finalization of an order ends, with
+	// the time spent and the error, if any.
+	OrderFinalized(ctx context.Context, o *Order, d time.Duration, err error)
+
+	// OrderChanged is called when an order is created, and when it becomes
+	// ready, valid or invalid. The certificate is only set for valid orders.
+	OrderChanged(ctx context.Context, o *Order, cert *Certificate)
+
+	// DBError is called when an operation of the database fails. The not found
+	// and conflict errors, and the ACME errors caused by the client, are not
+	// reported.
+	DBError(ctx context.Context, operation string, err error)
+}
+
+type meterKey struct{}
+
+// NewMeterContext adds the given meter to the context.
+func NewMeterContext(ctx context.Context, m Meter) context.Context {
+	return context.WithValue(ctx, meterKey{}, m)
+}
+
+// MeterFromContext returns the meter in the context, or a no-op meter if
+// there is none.
+func MeterFromContext(ctx context.Context) Meter {
+	if m, ok := ctx.Value(meterKey{}).(Meter); ok && m != nil {
+		return m
+	}
+	return noopMeter{}
+}
+
+// noopMeter is the Meter used when none is configured.
+type noopMeter struct{}
+
+func (noopMeter) AccountCreated(context.Context, *Account)                             {}
+func (noopMeter) ChallengeValidated(context.Context, *Challenge, time.Duration, error) {}
+func (noopMeter) OrderFinalized(context.Context, *Order, time.Duration, error)         {}
+func (noopMeter) OrderChanged(context.Context, *Order, *Certificate)                   {}
+func (noopMeter) DBError(context.Context, string, error)                               {}
+
+// WithDBMeter returns a DB that reports the errors of the given one to the
+// meter in the context.
+func WithDBMeter(db DB) DB {
+	return &meteredDB{DB: db}
+}
+
+type meteredDB struct {
+	DB
+}
+
+func (db *meteredDB) check(ctx context.Context, operation string, err error) error {
+	if err == nil || IsErrNotFound(err) || IsErrConflict(err) {
+		return err
+	}
+	var ae *Error
+	if errors.As(err, &ae) && ae.Status < http.StatusInternalServerError {
+		return err
+	}
+	MeterFromContext(ctx).DBError(ctx, operation, err)
+	return err
+}
+
+func (db *meteredDB) CreateAccount(ctx context.Context, acc *Account) error {
+	return db.check(ctx, "CreateAccount", db.DB.CreateAccount(ctx, acc))
+}
+
+func (db *meteredDB) GetAccount(ctx context.Context, id string) (*Account, error) {
+	v, err := db.DB.GetAccount(ctx, id)
+	return v, db.check(ctx, "GetAccount", err)
+}
+
+func (db *meteredDB) GetAccountByKeyID(ctx context.Context, kid string) (*Account, error) {
+	v, err := db.DB.GetAccountByKeyID(ctx, kid)
+	return v, db.check(ctx, "GetAccountByKeyID", err)
+}
+
+func (db *meteredDB) GetAccountsByProvisioner(ctx context.Context, provisionerName string) ([]*Account, error) {
+	v, err := db.DB.GetAccountsByProvisioner(ctx, provisionerName)
+	return v, db.check(ctx, "GetAccountsByProvisioner", err)
+}
+
+func (db *meteredDB) UpdateAccount(ctx context.Context, acc *Account) error {
+	return db.check(ctx, "UpdateAccount", db.DB.UpdateAccount(ctx, acc))
+}
+
+func (db *meteredDB) UpdateAccountKey(ctx context.Context, acc *Account) error {
+	return db.check(ctx, "UpdateAccountKey", db.DB.UpdateAccountKey(ctx, acc))
+}
+
+func (db *meteredDB) CreateExternalAccountKey(ctx context.Context, provisionerID, reference string) (*ExternalAccountKey, error) {
+	v, err := db.DB.CreateExternalAccountKey(ctx, provisionerID, reference)
+	return v, db.check(ctx, "CreateExternalAccountKey", err)
+}
+
+func (db *meteredDB) GetExternalAccountKey(ctx context.Context, provisionerID, keyID string) (*ExternalAccountKey, error) {
+	v, err := db.DB.GetExternalAccountKey(ctx, provisionerID, keyID)
+	return v, db.check(ctx, "GetExternalAccountKey", err)
+}
+
+func (db *meteredDB) GetExternalAccountKeys(ctx context.Context, provisionerID, cursor string, limit int) ([]*ExternalAccountKey, string, error) {
+	v, w, err := db.DB.GetExternalAccountKeys(ctx, provisionerID, cursor, limit)
+	return v, w, db.check(ctx, "GetExternalAccountKeys", err)
+}
+
+func (db *meteredDB) GetExternalAccountKeyByReference(ctx context.Context, provisionerID, reference string) (*ExternalAccountKey, error) {
+	v, err := db.DB.GetExternalAccountKeyByReference(ctx, provisionerID, reference)
+	return v, db.check(ctx, "GetExternalAccountKeyByReference", err)
+}
+
+func (db *meteredDB) GetExternalAccountKeyByAccountID(ctx context.Context, provisionerID, accountID string) (*ExternalAccountKey, error) {
+	v, err := db.DB.GetExternalAccountKeyByAccountID(ctx, provisionerID, accountID)
+	return v, db.check(ctx, "GetExternalAccountKeyByAccountID", err)
+}
+
+func (db *meteredDB) DeleteExternalAccountKey(ctx context.Context, provisionerID, keyID string) error {
+	return db.check(ctx, "DeleteExternalAccountKey", db.DB.DeleteExternalAccountKey(ctx, provisionerID, keyID))
+}
+
+func (db *meteredDB) UpdateExternalAccountKey(ctx context.Context, provisionerID string, eak *ExternalAccountKey) error {
+	return db.check(ctx, "UpdateExternalAccountKey", db.DB.UpdateExternalAccountKey(ctx, provisionerID, eak))
+}
+
+func (db *meteredDB) CreateNonce(ctx context.Context) (Nonce, error) {
+	v, err := db.DB.CreateNonce(ctx)
+	return v, db.check(ctx, "CreateNonce", err)
+}
+
+func (db *meteredDB) DeleteNonce(ctx context.Context, nonce Nonce) error {
+	return db.check(ctx, "DeleteNonce", db.DB.DeleteNonce(ctx, nonce))
+}
+
+func (db *meteredDB) CreateAuthorization(ctx context.Context, az *Authorization) error {
+	return db.check(ctx, "CreateAuthorization", db.DB.CreateAuthorization(ctx, az))
+}
+
+func (db *meteredDB) GetAuthorization(ctx context.Context, id string) (*Authorization, error) {
+	v, err := db.DB.GetAuthorization(ctx, id)
+	return v, db.check(ctx, "GetAuthorization", err)
+}
+
+func (db *meteredDB) UpdateAuthorization(ctx context.Context, az *Authorization) error {
+	return db.check(ctx, "UpdateAuthorization", db.DB.UpdateAuthorization(ctx, az))
+}
+
+func (db *meteredDB) GetAuthorizationsByAccountID(ctx context.Context, accountID string) ([]*Authorization, error) {
+	v, err := db.DB.GetAuthorizationsByAccountID(ctx, accountID)
+	return v, db.check(ctx, "GetAuthorizationsByAccountID", err)
+}
+
+func (db *meteredDB) CreateCertificate(ctx context.Context, cert *Certificate) error {
+	return db.check(ctx, "CreateCertificate", db.DB.CreateCertificate(ctx, cert))
+}
+
+func (db *meteredDB) GetCertificate(ctx context.Context, id string) (*Certificate, error) {
+	v, err := db.DB.GetCertificate(ctx, id)
+	return v, db.check(ctx, "GetCertificate", err)
+}
+
+func (db *meteredDB) GetCertificateBySerial(ctx context.Context, serial string) (*Certificate, error) {
+	v, err := db.DB.GetCertificateBySerial(ctx, serial)
+	return v, db.check(ctx, "GetCertificateBySerial", err)
+}
+
+func (db *meteredDB) GetCertificatesByAccountID(ctx context.Context, accountID string) ([]*Certificate, error) {
+	v, err := db.DB.GetCertificatesByAccountID(ctx, accountID)
+	return v, db.check(ctx, "GetCertificatesByAccountID", err)
+}
+
+func (db *meteredDB) CreateChallenge(ctx context.Context, ch *Challenge) error {
+	return db.check(ctx, "CreateChallenge", db.DB.CreateChallenge(ctx, ch))
+}
+
+func (db *meteredDB) GetChallenge(ctx context.Context, id, authzID string) (*Challenge, error) {
+	v, err := db.DB.GetChallenge(ctx, id, authzID)
+	return v, db.check(ctx, "GetChallenge", err)
+}
+
+func (db *meteredDB) UpdateChallenge(ctx context.Context, ch *Challenge) error {
+	return db.check(ctx, "UpdateChallenge", db.DB.UpdateChallenge(ctx, ch))
+}
+
+func (db *meteredDB) CreateOrder(ctx context.Context, o *Order) error {
+	return db.check(ctx, "CreateOrder", db.DB.CreateOrder(ctx, o))
+}
+
+func (db *meteredDB) GetOrder(ctx context.Context, id string) (*Order, error) {
+	v, err := db.DB.GetOrder(ctx, id)
+	return v, db.check(ctx, "GetOrder", err)
+}
+
+func (db *meteredDB) GetOrdersByAccountID(ctx context.Context, accountID string) ([]string, error) {
+	v, err := db.DB.GetOrdersByAccountID(ctx, accountID)
+	return v, db.check(ctx, "GetOrdersByAccountID", err)
+}
+
+func (db *meteredDB) GetAllOrdersByAccountID(ctx context.Context, accountID string) ([]*Order, error) {
+	v, err := db.DB.GetAllOrdersByAccountID(ctx, accountID)
+	return v, db.check(ctx, "GetAllOrdersByAccountID", err)
+}
+
+func (db *meteredDB) UpdateOrder(ctx context.Context, o *Order) error {
+	return db.check(ctx, "UpdateOrder", db.DB.UpdateOrder(ctx, o))
+}
+
+func (db *meteredDB) UpdateOrderStatus(ctx context.Context, o *Order, from Status) error {
+	return db.check(ctx, "UpdateOrderStatus", db.DB.UpdateOrderStatus(ctx, o, from))
+}
+
+func (db *meteredDB) CreateDeferredOrder(ctx context.Context, d *DeferredOrder) error {
+	return db.check(ctx, "CreateDeferredOrder", db.DB.CreateDeferredOrder(ctx, d))
+}
+
+func (db *meteredDB) GetDeferredOrder(ctx context.Context, orderID string) (*DeferredOrder, error) {
+	v, err := db.DB.GetDeferredOrder(ctx, orderID)
+	return v, db.check(ctx, "GetDeferredOrder", err)
+}
+
+func (db *meteredDB) GetDeferredOrders(ctx context.Context, provisionerID string) ([]*DeferredOrder, error) {
+	v, err := db.DB.GetDeferredOrders(ctx, provisionerID)
+	return v, db.check(ctx, "GetDeferredOrders", err)
+}
+
+func (db *meteredDB) UpdateDeferredOrder(ctx context.Context, d *DeferredOrder, from ApprovalStatus) error {
+	return db.check(ctx, "UpdateDeferredOrder", db.DB.UpdateDeferredOrder(ctx, d, from))
+}
+
+func (db *meteredDB) CreateStarOrder(ctx context.Context, s *StarOrder) error {
+	return db.check(ctx, "CreateStarOrder", db.DB.CreateStarOrder(ctx, s))
+}
+
+func (db *meteredDB) GetStarOrder(ctx context.Context, orderID string) (*StarOrder, error) {
+	v, err := db.DB.GetStarOrder(ctx, orderID)
+	return v, db.check(ctx, "GetStarOrder", err)
+}
+
+func (db *meteredDB) GetStarOrders(ctx context.Context, before time.Time) ([]*StarOrder, error) {
+	v, err := db.DB.GetStarOrders(ctx, before)
+	return v, db.check(ctx, "GetStarOrders", err)
+}
+
+func (db *meteredDB) UpdateStarOrder(ctx context.Context, s *StarOrder, from StarOrderStatus) error {
+	return db.check(ctx, "UpdateStarOrder", db.DB.UpdateStarOrder(ctx, s, from))
+}
+
+func (db *meteredDB) GetRateLimitEvents(ctx context.Context, key string) ([]time.Time, error) {
+	v, err := db.DB.GetRateLimitEvents(ctx, key)
+	return v, db.check(ctx, "GetRateLimitEvents", err)
+}
+
+func (db *meteredDB) UpdateRateLimitEvents(ctx context.Context, key string, events, old []time.Time, expiresAt time.Time) error {
+	return db.check(ctx, "UpdateRateLimitEvents", db.DB.UpdateRateLimitEvents(ctx, key, events, old, expiresAt))
+}
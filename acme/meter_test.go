@@ -0,0 +1,99 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedMeter struct {
+	noopMeter
+	dbErrors []string
+	orders   []Status
+}
+
+func (m *recordedMeter) OrderChanged(_ context.Context, o *Order, _ *Certificate) {
+	m.orders = append(m.orders, o.Status)
+}
+
+func (m *recordedMeter) DBError(_ context.Context, operation string, _ error) {
+	m.dbErrors = append(m.dbErrors, operation)
+}
+
+func TestMeterFromContext(t *testing.T) {
+	assert.Equal(t, noopMeter{}, MeterFromContext(context.Background()))
+
+	m := new(recordedMeter)
+	assert.Equal(t, m, MeterFromContext(NewMeterContext(context.Background(), m)))
+}
+
+func TestWithDBMeter(t *testing.T) {
+	m := new(recordedMeter)
+	ctx := NewMeterContext(context.Background(), m)
+
+	var err error
+	db := WithDBMeter(&MockDB{
+		MockGetOrder: func(ctx context.Context, id string) (*Order, error) {
+			return nil, err
+		},
+	})
+
+	for _, err = range []error{
+		nil,
+		ErrNotFound,
+		ErrConflict,
+		NewError(ErrorMalformedType, "bad request"),
+	} {
+		_, e := db.GetOrder(ctx, "order-id")
+		assert.Equal(t, err, e)
+	}
+	assert.Empty(t, m.dbErrors)
+
+	for _, err = range []error{
+		errors.New("force"),
+		NewErrorISE("force"),
+	} {
+		_, e := db.GetOrder(ctx, "order-id")
+		assert.Equal(t, err, e)
+	}
+	require.Len(t, m.dbErrors, 2)
+	assert.Equal(t, []string{"GetOrder", "GetOrder"}, m.dbErrors)
+}
+
+func TestOrder_UpdateStatus_meter(t *testing.T) {
+	m := new(recordedMeter)
+	ctx := NewMeterContext(context.Background(), m)
+
+	azStatus := StatusPending
+	db := &MockDB{
+		MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+			return &Authorization{ID: id, Status: azStatus, ExpiresAt: clock.Now().Add(time.Hour)}, nil
+		},
+		MockUpdateOrder: func(ctx context.Context, o *Order) error {
+			return nil
+		},
+	}
+	o := &Order{
+		ID:               "order-id",
+		Status:           StatusPending,
+		ExpiresAt:        clock.Now().Add(time.Hour),
+		AuthorizationIDs: []string{"az-id"},
+	}
+
+	// Orders without changes are not reported.
+	require.NoError(t, o.UpdateStatus(ctx, db))
+	assert.Empty(t, m.orders)
+
+	azStatus = StatusValid
+	require.NoError(t, o.UpdateStatus(ctx, db))
+	require.NoError(t, o.UpdateStatus(ctx, db))
+	assert.Equal(t, []Status{StatusReady}, m.orders)
+
+	o.ExpiresAt = clock.Now().Add(-time.Minute)
+	require.NoError(t, o.UpdateStatus(ctx, db))
+	assert.Equal(t, []Status{StatusReady, StatusInvalid}, m.orders)
+}
@@ -1,5 +1,17 @@
 package acme
 
+import (
+	"context"
+	"encoding/base64"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/randutil"
+)
+
+// DefaultNonceTTL is the time a nonce kept in a NonceStore is valid.
+const DefaultNonceTTL = time.Hour
+
 // Nonce represents an ACME nonce type.
 type Nonce string
 
@@ -7,3 +19,63 @@ type Nonce string
 func (n Nonce) String() string {
 	return string(n)
 }
+
+// NonceStore is the interface used to keep the ACME nonces outside of the main
+// database, for example, in a Redis server shared by multiple instances.
+type NonceStore interface {
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, key string) (bool, error)
+}
+
+// nonceStoreDB is a DB that keeps the nonces in a NonceStore.
+type nonceStoreDB struct {
+	DB
+	store NonceStore
+	ttl   time.Duration
+}
+
+// WithNonceStore returns a DB that creates and consumes the nonces using the
+// given store, the rest of the ACME objects are stored in the given DB. Nonces
+// not used before the ttl are discarded, if ttl is 0, DefaultNonceTTL will be
+// used.
+func WithNonceStore(db DB, store NonceStore, ttl time.Duration) DB {
+	if ttl <= 0 {
+		ttl = DefaultNonceTTL
+	}
+	return &nonceStoreDB{
+		DB:    db,
+		store: store,
+		ttl:   ttl,
+	}
+}
+
+// CreateNonce creates, stores, and returns an ACME replay-nonce.
+func (db *nonceStoreDB) CreateNonce(ctx context.Context) (Nonce, error) {
+	id, err := randutil.Alphanumeric(32)
+	if err != nil {
+		return "", errors.Wrap(err, "error generating random alphanumeric ID")
+	}
+	id = base64.RawURLEncoding.EncodeToString([]byte(id))
+	ok, err := db.store.SetNX(ctx, "nonce:"+id, nil, db.ttl)
+	if err != nil {
+		return "", errors.Wrap(err, "error storing nonce")
+	}
+	if !ok {
+		return "", errors.New("error storing nonce: nonce already exists")
+	}
+	return Nonce(id), nil
+}
+
+// DeleteNonce verifies that the nonce is valid (by checking if it exists), and
+// if so, consumes the nonce by deleting it from the store.
+func (db *nonceStoreDB) DeleteNonce(ctx context.Context, nonce Nonce) error {
+	ok, err := db.store.Delete(ctx, "nonce:"+string(nonce))
+	switch {
+	case err != nil:
+		return errors.Wrapf(err, "error deleting nonce %s", string(nonce))
+	case !ok:
+		return NewError(ErrorBadNonceType, "nonce %s not found", string(nonce))
+	default:
+		return nil
+	}
+}
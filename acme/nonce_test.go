@@ -0,0 +1,62 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smallstep/certificates/cache"
+)
+
+type errorNonceStore struct{}
+
+func (errorNonceStore) SetNX(context.Context, string, []byte, time.Duration) (bool, error) {
+	return false, errors.New("force")
+}
+
+func (errorNonceStore) Delete(context.Context, string) (bool, error) {
+	return false, errors.New("force")
+}
+
+func TestWithNonceStore(t *testing.T) {
+	ctx := context.Background()
+	db := WithNonceStore(&MockDB{
+		MockCreateNonce: func(ctx context.Context) (Nonce, error) {
+			t.Error("DB.CreateNonce should not be called")
+			return "", nil
+		},
+	}, cache.NewMemory(), 0)
+	assert.Equal(t, DefaultNonceTTL, db.(*nonceStoreDB).ttl)
+
+	n1, err := db.CreateNonce(ctx)
+	require.NoError(t, err)
+	n2, err := db.CreateNonce(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, n1, n2)
+
+	require.NoError(t, db.DeleteNonce(ctx, n1))
+	err = db.DeleteNonce(ctx, n1)
+	var acmeErr *Error
+	if assert.ErrorAs(t, err, &acmeErr) {
+		assert.Equal(t, "urn:ietf:params:acme:error:badNonce", acmeErr.Type)
+		assert.Equal(t, "nonce "+n1.String()+" not found", acmeErr.Err.Error())
+	}
+	require.NoError(t, db.DeleteNonce(ctx, n2))
+
+	// Nonces expire
+	db = WithNonceStore(&MockDB{}, cache.NewMemory(), time.Millisecond)
+	n, err := db.CreateNonce(ctx)
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	assert.Error(t, db.DeleteNonce(ctx, n))
+
+	// Store errors
+	db = WithNonceStore(&MockDB{}, errorNonceStore{}, time.Minute)
+	_, err = db.CreateNonce(ctx)
+	assert.EqualError(t, err, "error storing nonce: force")
+	assert.EqualError(t, db.DeleteNonce(ctx, "foo"), "error deleting nonce foo: force")
+}
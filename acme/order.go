@@ -7,11 +7,18 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"net"
+	"net/http"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/authority/keypolicy"
 	"github.com/smallstep/certificates/authority/provisioner"
+	casapi "github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/smime"
 	"go.step.sm/crypto/keyutil"
 	"go.step.sm/crypto/x509util"
 )
@@ -26,6 +33,11 @@ const (
 	// PermanentIdentifier is the ACME permanent-identifier identifier type
 	// defined in https://datatracker.ietf.org/doc/html/draft-bweeks-acme-device-attest-00
 	PermanentIdentifier IdentifierType = "permanent-identifier"
+	// Email is the ACME email identifier type defined in RFC 8823
+	Email IdentifierType = "email"
+	// SSHPrincipal is the ACME identifier type of the principals of SSH user
+	// certificates.
+	SSHPrincipal IdentifierType = "ssh-principal"
 )
 
 // Identifier encodes the type that an order pertains to.
@@ -36,20 +48,22 @@ type Identifier struct {
 
 // Order contains order metadata for the ACME protocol order type.
 type Order struct {
-	ID                string       `json:"id"`
-	AccountID         string       `json:"-"`
-	ProvisionerID     string       `json:"-"`
-	Status            Status       `json:"status"`
-	ExpiresAt         time.Time    `json:"expires"`
-	Identifiers       []Identifier `json:"identifiers"`
-	NotBefore         time.Time    `json:"notBefore"`
-	NotAfter          time.Time    `json:"notAfter"`
-	Error             *Error       `json:"error,omitempty"`
-	AuthorizationIDs  []string     `json:"-"`
-	AuthorizationURLs []string     `json:"authorizations"`
-	FinalizeURL       string       `json:"finalize"`
-	CertificateID     string       `json:"-"`
-	CertificateURL    string       `json:"certificate,omitempty"`
+	ID                 string       `json:"id"`
+	AccountID          string       `json:"-"`
+	ProvisionerID      string       `json:"-"`
+	Status             Status       `json:"status"`
+	ExpiresAt          time.Time    `json:"expires"`
+	Identifiers        []Identifier `json:"identifiers"`
+	NotBefore          time.Time    `json:"notBefore"`
+	NotAfter           time.Time    `json:"notAfter"`
+	Error              *Error       `json:"error,omitempty"`
+	AuthorizationIDs   []string     `json:"-"`
+	AuthorizationURLs  []string     `json:"authorizations"`
+	FinalizeURL        string       `json:"finalize"`
+	CertificateID      string       `json:"-"`
+	CertificateURL     string       `json:"certificate,omitempty"`
+	AutoRenewal        *AutoRenewal `json:"auto-renewal,omitempty"`
+	StarCertificateURL string       `json:"star-certificate,omitempty"`
 }
 
 // ToLog enables response logging.
@@ -65,11 +79,12 @@ func (o *Order) ToLog() (interface{}, error) {
 // Changes to the order are saved using the database interface.
 func (o *Order) UpdateStatus(ctx context.Context, db DB) error {
 	now := clock.Now()
+	old := o.Status
 
 	switch o.Status {
 	case StatusInvalid:
 		return nil
-	case StatusValid:
+	case StatusValid, StatusCanceled:
 		return nil
 	case StatusReady:
 		// Check expiry
@@ -79,6 +94,46 @@ func (o *Order) UpdateStatus(ctx context.Context, db DB) error {
 			break
 		}
 		return nil
+	case StatusProcessing:
+		// The orders waiting for an approval remain processing.
+		if d, err := db.GetDeferredOrder(ctx, o.ID); err == nil && d.Status == ApprovalPending {
+			return nil
+		}
+		// A finalization that stored the certificate but failed to update
+		// the order is completed with the stored certificate.
+		cert, err := o.storedCertificate(ctx, db)
+		if err != nil {
+			return err
+		}
+		if cert != nil {
+			o.CertificateID = cert.ID
+			o.Status = StatusValid
+			o.Error = nil
+			if err := db.UpdateOrderStatus(ctx, o, StatusProcessing); err != nil {
+				if IsErrConflict(err) {
+					return o.reload(ctx, db)
+				}
+				return WrapErrorISE(err, "error updating order")
+			}
+			MeterFromContext(ctx).OrderChanged(ctx, o, cert)
+			return nil
+		}
+		// A finalization interrupted before the certificate was stored
+		// leaves the order processing. These orders become invalid when they
+		// expire.
+		if !now.After(o.ExpiresAt) {
+			return nil
+		}
+		o.Status = StatusInvalid
+		o.Error = NewError(ErrorServerInternalType, "order %s has expired before its finalization completed", o.ID)
+		if err := db.UpdateOrderStatus(ctx, o, StatusProcessing); err != nil {
+			if IsErrConflict(err) {
+				return o.reload(ctx, db)
+			}
+			return WrapErrorISE(err, "error updating order")
+		}
+		MeterFromContext(ctx).OrderChanged(ctx, o, nil)
+		return nil
 	case StatusPending:
 		// Check expiry
 		if now.After(o.ExpiresAt) {
@@ -124,6 +179,9 @@ func (o *Order) UpdateStatus(ctx context.Context, db DB) error {
 	if err := db.UpdateOrder(ctx, o); err != nil {
 		return WrapErrorISE(err, "error updating order")
 	}
+	if o.Status != old {
+		MeterFromContext(ctx).OrderChanged(ctx, o, nil)
+	}
 	return nil
 }
 
@@ -155,7 +213,7 @@ func (o *Order) getAuthorizationFingerprint(ctx context.Context, db DB) (string,
 // external validation using the identifier value and the attestation data. From
 // a validation service we can get the list of SANs to set in the final
 // certificate.
-func (o *Order) Finalize(ctx context.Context, db DB, csr *x509.CertificateRequest, auth CertificateAuthority, p Provisioner) error {
+func (o *Order) Finalize(ctx context.Context, db DB, csr *x509.CertificateRequest, auth CertificateAuthority, p Provisioner) (err error) {
 	if err := o.UpdateStatus(ctx, db); err != nil {
 		return err
 	}
@@ -163,7 +221,11 @@ func (o *Order) Finalize(ctx context.Context, db DB, csr *x509.CertificateReques
 	switch o.Status {
 	case StatusInvalid:
 		return NewError(ErrorOrderNotReadyType, "order %s has been abandoned", o.ID)
-	case StatusValid:
+	case StatusCanceled:
+		return NewError(ErrorOrderNotReadyType, "order %s has been canceled", o.ID)
+	case StatusValid, StatusProcessing:
+		// The order has already been finalized, or it is being finalized by a
+		// concurrent request, return it as it is.
 		return nil
 	case StatusPending:
 		return NewError(ErrorOrderNotReadyType, "order %s is not ready", o.ID)
@@ -173,33 +235,90 @@ func (o *Order) Finalize(ctx context.Context, db DB, csr *x509.CertificateReques
 		return NewErrorISE("unexpected status %s for order %s", o.Status, o.ID)
 	}
 
+	// Only the finalizations of ready orders are reported to the meter.
+	start := time.Now()
+	defer func() {
+		MeterFromContext(ctx).OrderFinalized(ctx, o, time.Since(start), err)
+	}()
+
+	raw := csr.Raw
+	ctx = provisioner.NewContextWithMethod(ctx, provisioner.SignMethod)
+	csr, signOps, err := o.signOptions(ctx, db, csr, p)
+	if err != nil {
+		return err
+	}
+
+	// Move the order to the processing state before signing. Only one of the
+	// concurrent requests finalizing the order signs the certificate, the
+	// others return the order as it is stored.
+	o.Status = StatusProcessing
+	if err := db.UpdateOrderStatus(ctx, o, StatusReady); err != nil {
+		if IsErrConflict(err) {
+			return o.reload(ctx, db)
+		}
+		o.Status = StatusReady
+		return WrapErrorISE(err, "error updating order %s", o.ID)
+	}
+
+	// Orders that require an approval remain in the processing state until
+	// the approval is decided.
+	if approval := p.GetApprovalOptions(); approval.IsRequired() {
+		return o.deferFinalize(ctx, db, raw, approval)
+	}
+
+	if _, err := o.sign(ctx, db, csr, auth, signOps); err != nil {
+		if o.Status == StatusProcessing {
+			o.release(ctx, db)
+		}
+		return err
+	}
+	return nil
+}
+
+// signOptions validates the CSR of the order and returns the canonicalized
+// CSR and the options used to sign the certificate.
+func (o *Order) signOptions(ctx context.Context, db DB, csr *x509.CertificateRequest, p Provisioner) (*x509.CertificateRequest, []provisioner.SignOption, error) {
 	// Get key fingerprint if any. And then compare it with the CSR fingerprint.
 	//
 	// In device-attest-01 challenges we should check that the keys in the CSR
 	// and the attestation certificate are the same.
 	fingerprint, err := o.getAuthorizationFingerprint(ctx, db)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	if fingerprint != "" {
 		fp, err := keyutil.Fingerprint(csr.PublicKey)
 		if err != nil {
-			return WrapErrorISE(err, "error calculating key fingerprint")
+			return nil, nil, WrapErrorISE(err, "error calculating key fingerprint")
 		}
 		if subtle.ConstantTimeCompare([]byte(fingerprint), []byte(fp)) == 0 {
-			return NewError(ErrorUnauthorizedType, "order %s csr does not match the attested key", o.ID)
+			return nil, nil, NewError(ErrorUnauthorizedType, "order %s csr does not match the attested key", o.ID)
 		}
 	}
 
+	// Orders of ssh-principal identifiers are used to get SSH user
+	// certificates for the key in the CSR.
+	if o.isSSH() {
+		return o.sshSignOptions(ctx, csr, p)
+	}
+
 	// canonicalize the CSR to allow for comparison
 	csr = canonicalize(csr)
 
-	// Template data
+	// Template data, the order is available in .Order.
 	data := x509util.NewTemplateData()
 	data.SetCommonName(csr.Subject.CommonName)
+	data.Set(OrderTemplateKey, &OrderTemplateData{
+		AccountID:   o.AccountID,
+		OrderID:     o.ID,
+		Identifiers: o.Identifiers,
+	})
 
-	// Custom sign options passed to authority.Sign
-	var extraOptions []provisioner.SignOption
+	// Custom sign options passed to authority.Sign, the account is the
+	// identity used to detect keys shared by different accounts.
+	extraOptions := []provisioner.SignOption{
+		provisioner.RequesterIdentity("acme/account/" + o.AccountID),
+	}
 
 	// TODO: support for multiple identifiers?
 	var permanentIdentifier string
@@ -212,7 +331,7 @@ func (o *Order) Finalize(ctx context.Context, db DB, csr *x509.CertificateReques
 			// could result in unauthorized access if a relying system relies on the Common
 			// Name in its authorization logic.
 			if csr.Subject.CommonName != "" && csr.Subject.CommonName != permanentIdentifier {
-				return NewError(ErrorBadCSRType, "CSR Subject Common Name does not match identifiers exactly: "+
+				return nil, nil, NewError(ErrorBadCSRType, "CSR Subject Common Name does not match identifiers exactly: "+
 					"CSR Subject Common Name = %s, Order Permanent Identifier = %s", csr.Subject.CommonName, permanentIdentifier)
 			}
 			break
@@ -231,18 +350,21 @@ func (o *Order) Finalize(ctx context.Context, db DB, csr *x509.CertificateReques
 		})
 	} else {
 		defaultTemplate = x509util.DefaultLeafTemplate
+		// Orders of email identifiers are used to get S/MIME certificates.
+		if numberOfIdentifierType(Email, o.Identifiers) > 0 {
+			defaultTemplate = smime.DefaultTemplate
+		}
 		sans, err := o.sans(csr)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 		data.SetSubjectAlternativeNames(sans...)
 	}
 
 	// Get authorizations from the ACME provisioner.
-	ctx = provisioner.NewContextWithMethod(ctx, provisioner.SignMethod)
 	signOps, err := p.AuthorizeSign(ctx, "")
 	if err != nil {
-		return WrapErrorISE(err, "error retrieving authorization options from ACME provisioner")
+		return nil, nil, WrapErrorISE(err, "error retrieving authorization options from ACME provisioner")
 	}
 	// Unlike most of the provisioners, ACME's AuthorizeSign method doesn't
 	// define the templates, and the template data used in WebHooks is not
@@ -255,20 +377,123 @@ func (o *Order) Finalize(ctx context.Context, db DB, csr *x509.CertificateReques
 
 	templateOptions, err := provisioner.CustomTemplateOptions(p.GetOptions(), data, defaultTemplate)
 	if err != nil {
-		return WrapErrorISE(err, "error creating template options from ACME provisioner")
+		return nil, nil, WrapErrorISE(err, "error creating template options from ACME provisioner")
 	}
 
-	// Build extra signing options.
+	// Build extra signing options. The validity options must follow the
+	// template options.
 	signOps = append(signOps, templateOptions)
+	signOps = append(signOps, provisioner.NewTemplateValidityOptions()...)
 	signOps = append(signOps, extraOptions...)
+	return csr, signOps, nil
+}
+
+// OrderTemplateKey is the name of the variable with the order data in the
+// X.509 templates of the ACME provisioners.
+const OrderTemplateKey = "Order"
+
+// OrderTemplateData is the order data available in the X.509 templates, for
+// example {{ .Order.AccountID }} or {{ range .Order.Identifiers }}.
+type OrderTemplateData struct {
+	AccountID   string       `json:"accountID"`
+	OrderID     string       `json:"orderID"`
+	Identifiers []Identifier `json:"identifiers"`
+}
+
+// sign signs the certificate of a processing order and moves the order to
+// the valid state. The renewals of auto-renewal orders are scheduled after
+// their first certificate.
+func (o *Order) sign(ctx context.Context, db DB, csr *x509.CertificateRequest, auth CertificateAuthority, signOps []provisioner.SignOption) (*Certificate, error) {
+	if o.isSSH() {
+		return o.signSSH(ctx, db, csr, auth, signOps)
+	}
+	cert, err := o.signCertificate(ctx, db, csr, auth, signOps)
+	if err != nil {
+		return nil, err
+	}
+
+	// The renewals are scheduled before the order is updated, so an order
+	// completed later with its stored certificate keeps them.
+	o.CertificateID = cert.ID
+	o.Status = StatusValid
+	var renewalErr error
+	if o.isStar() {
+		renewalErr = o.startAutoRenewal(ctx, db, csr.Raw, cert)
+	}
+	if err := o.saveSigned(ctx, db); err != nil {
+		return nil, WrapErrorISE(err, "error updating order %s", o.ID)
+	}
+	MeterFromContext(ctx).OrderChanged(ctx, o, cert)
+	if renewalErr != nil {
+		return nil, renewalErr
+	}
+	return cert, nil
+}
+
+// Retries of the update of an order after its certificate has been stored.
+var (
+	saveSignedAttempts = 3
+	saveSignedBackoff  = 100 * time.Millisecond
+)
+
+// saveSigned saves an order after its certificate has been stored. The update
+// is retried, and if it still fails the order remains processing until
+// UpdateStatus completes it with the stored certificate.
+func (o *Order) saveSigned(ctx context.Context, db DB) (err error) {
+	for i := 0; i < saveSignedAttempts; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * saveSignedBackoff)
+		}
+		if err = db.UpdateOrder(ctx, o); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// storedCertificate returns the certificate stored for the order, or nil if
+// the order does not have one.
+func (o *Order) storedCertificate(ctx context.Context, db DB) (*Certificate, error) {
+	certs, err := db.GetCertificatesByAccountID(ctx, o.AccountID)
+	if err != nil {
+		return nil, WrapErrorISE(err, "error retrieving certificates of account %s", o.AccountID)
+	}
+	for _, c := range certs {
+		if c.OrderID == o.ID {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
 
-	// Sign a new certificate.
-	certChain, err := auth.Sign(csr, provisioner.SignOptions{
+// signCertificate signs and stores an X.509 certificate for the order. The
+// certificates of auto-renewal orders are valid from the time they are
+// signed.
+func (o *Order) signCertificate(ctx context.Context, db DB, csr *x509.CertificateRequest, auth CertificateAuthority, signOps []provisioner.SignOption) (*Certificate, error) {
+	if o.isStar() {
+		o.NotBefore, o.NotAfter = o.AutoRenewal.Window(clock.Now())
+	}
+	certChain, err := auth.SignWithContext(ctx, csr, provisioner.SignOptions{
 		NotBefore: provisioner.NewTimeDuration(o.NotBefore),
 		NotAfter:  provisioner.NewTimeDuration(o.NotAfter),
 	}, signOps...)
 	if err != nil {
-		return WrapErrorISE(err, "error signing certificate for order %s", o.ID)
+		// Keys rejected by the key policy are a client error.
+		var kpErr *keypolicy.Error
+		if errors.As(errors.Cause(err), &kpErr) {
+			return nil, NewError(ErrorBadCSRType, "%s", kpErr.Error())
+		}
+		// A signing key in a KMS or HSM might be temporarily unavailable,
+		// the order is ready again and the client can retry later.
+		var ue *casapi.UnavailableError
+		if errors.As(errors.Cause(err), &ue) {
+			acmeErr := WrapErrorISE(err, "error signing certificate for order %s", o.ID)
+			acmeErr.Status = http.StatusServiceUnavailable
+			acmeErr.Code = errs.CodeSignerUnavailable
+			acmeErr.RetryAfter = ue.RetryAfter
+			return nil, acmeErr
+		}
+		return nil, WrapErrorISE(err, "error signing certificate for order %s", o.ID)
 	}
 
 	cert := &Certificate{
@@ -278,20 +503,46 @@ func (o *Order) Finalize(ctx context.Context, db DB, csr *x509.CertificateReques
 		Intermediates: certChain[1:],
 	}
 	if err := db.CreateCertificate(ctx, cert); err != nil {
-		return WrapErrorISE(err, "error creating certificate for order %s", o.ID)
+		return nil, o.abandon(ctx, db, WrapErrorISE(err, "error creating certificate for order %s", o.ID))
 	}
+	return cert, nil
+}
 
-	o.CertificateID = cert.ID
-	o.Status = StatusValid
-	if err = db.UpdateOrder(ctx, o); err != nil {
-		return WrapErrorISE(err, "error updating order %s", o.ID)
+// reload replaces the order with the one stored in the database.
+func (o *Order) reload(ctx context.Context, db DB) error {
+	nu, err := db.GetOrder(ctx, o.ID)
+	if err != nil {
+		return WrapErrorISE(err, "error retrieving order %s", o.ID)
 	}
+	*o = *nu
 	return nil
 }
 
+// release moves a processing order back to the ready state after a failed
+// finalization, so the client can try to finalize it again. Errors are
+// ignored, the original error is more relevant to the client.
+func (o *Order) release(ctx context.Context, db DB) {
+	o.Status = StatusReady
+	_ = db.UpdateOrderStatus(ctx, o, StatusProcessing)
+}
+
+// abandon moves a processing order to the invalid state when its certificate
+// has been signed but it cannot be stored. Unlike release, the order cannot be
+// finalized again, that would sign a second certificate. It returns the given
+// error, errors updating the order are ignored.
+func (o *Order) abandon(ctx context.Context, db DB, acmeErr *Error) *Error {
+	o.Status = StatusInvalid
+	o.Error = acmeErr
+	if err := db.UpdateOrderStatus(ctx, o, StatusProcessing); err == nil {
+		MeterFromContext(ctx).OrderChanged(ctx, o, nil)
+	}
+	return acmeErr
+}
+
 func (o *Order) sans(csr *x509.CertificateRequest) ([]x509util.SubjectAlternativeName, error) {
 	var sans []x509util.SubjectAlternativeName
-	if len(csr.EmailAddresses) > 0 || len(csr.URIs) > 0 {
+	orderEmails := make([]string, numberOfIdentifierType(Email, o.Identifiers))
+	if (len(csr.EmailAddresses) > 0 && len(orderEmails) == 0) || len(csr.URIs) > 0 {
 		return sans, NewError(ErrorBadCSRType, "Only DNS names and IP addresses are allowed")
 	}
 
@@ -299,7 +550,7 @@ func (o *Order) sans(csr *x509.CertificateRequest) ([]x509util.SubjectAlternativ
 	orderNames := make([]string, numberOfIdentifierType(DNS, o.Identifiers))
 	orderIPs := make([]net.IP, numberOfIdentifierType(IP, o.Identifiers))
 	orderPIDs := make([]string, numberOfIdentifierType(PermanentIdentifier, o.Identifiers))
-	indexDNS, indexIP, indexPID := 0, 0, 0
+	indexDNS, indexIP, indexPID, indexEmail := 0, 0, 0, 0
 	for _, n := range o.Identifiers {
 		switch n.Type {
 		case DNS:
@@ -311,14 +562,18 @@ func (o *Order) sans(csr *x509.CertificateRequest) ([]x509util.SubjectAlternativ
 		case PermanentIdentifier:
 			orderPIDs[indexPID] = n.Value
 			indexPID++
+		case Email:
+			orderEmails[indexEmail] = n.Value
+			indexEmail++
 		default:
 			return sans, NewErrorISE("unsupported identifier type in order: %s", n.Type)
 		}
 	}
 	orderNames = uniqueSortedLowerNames(orderNames)
 	orderIPs = uniqueSortedIPs(orderIPs)
+	orderEmails = uniqueSortedLowerNames(orderEmails)
 
-	totalNumberOfSANs := len(csr.DNSNames) + len(csr.IPAddresses)
+	totalNumberOfSANs := len(csr.DNSNames) + len(csr.IPAddresses) + len(csr.EmailAddresses)
 	sans = make([]x509util.SubjectAlternativeName, totalNumberOfSANs)
 	index := 0
 
@@ -361,6 +616,23 @@ func (o *Order) sans(csr *x509.CertificateRequest) ([]x509util.SubjectAlternativ
 		index++
 	}
 
+	if len(csr.EmailAddresses) != len(orderEmails) {
+		return sans, NewError(ErrorBadCSRType, "CSR emails do not match identifiers exactly: "+
+			"CSR emails = %v, Order emails = %v", csr.EmailAddresses, orderEmails)
+	}
+
+	for i := range csr.EmailAddresses {
+		if csr.EmailAddresses[i] != orderEmails[i] {
+			return sans, NewError(ErrorBadCSRType, "CSR emails do not match identifiers exactly: "+
+				"CSR emails = %v, Order emails = %v", csr.EmailAddresses, orderEmails)
+		}
+		sans[index] = x509util.SubjectAlternativeName{
+			Type:  x509util.EmailType,
+			Value: csr.EmailAddresses[i],
+		}
+		index++
+	}
+
 	return sans, nil
 }
 
@@ -392,17 +664,24 @@ func canonicalize(csr *x509.CertificateRequest) (canonicalized *x509.Certificate
 	// subjectAltName extension, or both. Subject Common Names that can be
 	// parsed as an IP are included as an IP address for the equality check.
 	// If these were excluded, a certificate could contain an IP as the
-	// common name without having been challenged.
-	if csr.Subject.CommonName != "" {
-		if ip := net.ParseIP(csr.Subject.CommonName); ip != nil {
+	// common name without having been challenged. In the same way, common
+	// names with an email address, used in S/MIME certificates, are included
+	// as an email address.
+	if cn := csr.Subject.CommonName; cn != "" {
+		if ip := net.ParseIP(cn); ip != nil {
 			canonicalized.IPAddresses = append(canonicalized.IPAddresses, ip)
+		} else if strings.Contains(cn, "@") {
+			canonicalized.EmailAddresses = append(canonicalized.EmailAddresses, cn)
 		} else {
-			canonicalized.DNSNames = append(canonicalized.DNSNames, csr.Subject.CommonName)
+			canonicalized.DNSNames = append(canonicalized.DNSNames, cn)
 		}
 	}
 
 	canonicalized.DNSNames = uniqueSortedLowerNames(canonicalized.DNSNames)
 	canonicalized.IPAddresses = uniqueSortedIPs(canonicalized.IPAddresses)
+	if len(canonicalized.EmailAddresses) > 0 {
+		canonicalized.EmailAddresses = uniqueSortedLowerNames(canonicalized.EmailAddresses)
+	}
 
 	return canonicalized
 }
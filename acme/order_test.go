@@ -18,9 +18,13 @@ import (
 	"github.com/pkg/errors"
 	"github.com/smallstep/assert"
 	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/keypolicy"
 	"github.com/smallstep/certificates/authority/provisioner"
+	casapi "github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/errs"
 	"go.step.sm/crypto/keyutil"
 	"go.step.sm/crypto/x509util"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestOrder_UpdateStatus(t *testing.T) {
@@ -55,6 +59,186 @@ func TestOrder_UpdateStatus(t *testing.T) {
 				err: NewErrorISE("unrecognized order status: %s", o.Status),
 			}
 		},
+		"ok/processing": func(t *testing.T) test {
+			o := &Order{
+				ID:        "oID",
+				Status:    StatusProcessing,
+				ExpiresAt: clock.Now().Add(5 * time.Minute),
+			}
+			return test{
+				o: o,
+				db: &MockDB{
+					MockGetDeferredOrder: func(ctx context.Context, orderID string) (*DeferredOrder, error) {
+						return nil, NewError(ErrorMalformedType, "deferred order %s not found", orderID)
+					},
+					MockGetCertificatesByAccountID: func(ctx context.Context, accountID string) ([]*Certificate, error) {
+						return []*Certificate{{ID: "certID", AccountID: accountID, OrderID: "otherID"}}, nil
+					},
+				},
+			}
+		},
+		"ok/processing-expired": func(t *testing.T) test {
+			o := &Order{
+				ID:        "oID",
+				AccountID: "accID",
+				Status:    StatusProcessing,
+				ExpiresAt: clock.Now().Add(-5 * time.Minute),
+			}
+			return test{
+				o: o,
+				db: &MockDB{
+					MockGetDeferredOrder: func(ctx context.Context, orderID string) (*DeferredOrder, error) {
+						return nil, NewError(ErrorMalformedType, "deferred order %s not found", orderID)
+					},
+					MockGetCertificatesByAccountID: func(ctx context.Context, accountID string) ([]*Certificate, error) {
+						return []*Certificate{{ID: "certID", AccountID: accountID, OrderID: "otherID"}}, nil
+					},
+					MockUpdateOrderStatus: func(ctx context.Context, updo *Order, from Status) error {
+						assert.Equals(t, from, StatusProcessing)
+						assert.Equals(t, updo.Status, StatusInvalid)
+						assert.Equals(t, updo.Error.Detail, "The server experienced an internal error")
+						return nil
+					},
+				},
+			}
+		},
+		"ok/processing-expired-waiting-approval": func(t *testing.T) test {
+			o := &Order{
+				ID:        "oID",
+				Status:    StatusProcessing,
+				ExpiresAt: clock.Now().Add(-5 * time.Minute),
+			}
+			return test{
+				o: o,
+				db: &MockDB{
+					MockGetDeferredOrder: func(ctx context.Context, orderID string) (*DeferredOrder, error) {
+						return &DeferredOrder{OrderID: orderID, Status: ApprovalPending}, nil
+					},
+				},
+			}
+		},
+		"ok/processing-expired-completed": func(t *testing.T) test {
+			o := &Order{
+				ID:        "oID",
+				Status:    StatusProcessing,
+				ExpiresAt: clock.Now().Add(-5 * time.Minute),
+			}
+			return test{
+				o: o,
+				db: &MockDB{
+					MockGetDeferredOrder: func(ctx context.Context, orderID string) (*DeferredOrder, error) {
+						return nil, NewError(ErrorMalformedType, "deferred order %s not found", orderID)
+					},
+					MockGetCertificatesByAccountID: func(ctx context.Context, accountID string) ([]*Certificate, error) {
+						return []*Certificate{{ID: "certID", AccountID: accountID, OrderID: "otherID"}}, nil
+					},
+					MockUpdateOrderStatus: func(ctx context.Context, updo *Order, from Status) error {
+						return ErrConflict
+					},
+					MockGetOrder: func(ctx context.Context, id string) (*Order, error) {
+						return &Order{ID: id, Status: StatusValid, CertificateID: "certID"}, nil
+					},
+				},
+			}
+		},
+		"fail/processing-expired-db.UpdateOrderStatus-error": func(t *testing.T) test {
+			o := &Order{
+				ID:        "oID",
+				Status:    StatusProcessing,
+				ExpiresAt: clock.Now().Add(-5 * time.Minute),
+			}
+			return test{
+				o: o,
+				db: &MockDB{
+					MockGetDeferredOrder: func(ctx context.Context, orderID string) (*DeferredOrder, error) {
+						return &DeferredOrder{OrderID: orderID, Status: ApprovalApproved}, nil
+					},
+					MockGetCertificatesByAccountID: func(ctx context.Context, accountID string) ([]*Certificate, error) {
+						return []*Certificate{{ID: "certID", AccountID: accountID, OrderID: "otherID"}}, nil
+					},
+					MockUpdateOrderStatus: func(ctx context.Context, updo *Order, from Status) error {
+						return errors.New("force")
+					},
+				},
+				err: NewErrorISE("error updating order: force"),
+			}
+		},
+		"ok/processing-stored-certificate": func(t *testing.T) test {
+			o := &Order{
+				ID:        "oID",
+				AccountID: "accID",
+				Status:    StatusProcessing,
+				ExpiresAt: clock.Now().Add(-5 * time.Minute),
+				Error:     NewErrorISE("force"),
+			}
+			return test{
+				o: o,
+				db: &MockDB{
+					MockGetDeferredOrder: func(ctx context.Context, orderID string) (*DeferredOrder, error) {
+						return nil, NewError(ErrorMalformedType, "deferred order %s not found", orderID)
+					},
+					MockGetCertificatesByAccountID: func(ctx context.Context, accountID string) ([]*Certificate, error) {
+						assert.Equals(t, accountID, "accID")
+						return []*Certificate{
+							{ID: "otherCertID", AccountID: accountID, OrderID: "otherID"},
+							{ID: "certID", AccountID: accountID, OrderID: "oID"},
+						}, nil
+					},
+					MockUpdateOrderStatus: func(ctx context.Context, updo *Order, from Status) error {
+						assert.Equals(t, from, StatusProcessing)
+						assert.Equals(t, updo.Status, StatusValid)
+						assert.Equals(t, updo.CertificateID, "certID")
+						assert.Nil(t, updo.Error)
+						return nil
+					},
+				},
+			}
+		},
+		"ok/processing-stored-certificate-conflict": func(t *testing.T) test {
+			o := &Order{
+				ID:        "oID",
+				AccountID: "accID",
+				Status:    StatusProcessing,
+				ExpiresAt: clock.Now().Add(5 * time.Minute),
+			}
+			return test{
+				o: o,
+				db: &MockDB{
+					MockGetDeferredOrder: func(ctx context.Context, orderID string) (*DeferredOrder, error) {
+						return nil, NewError(ErrorMalformedType, "deferred order %s not found", orderID)
+					},
+					MockGetCertificatesByAccountID: func(ctx context.Context, accountID string) ([]*Certificate, error) {
+						return []*Certificate{{ID: "certID", AccountID: accountID, OrderID: "oID"}}, nil
+					},
+					MockUpdateOrderStatus: func(ctx context.Context, updo *Order, from Status) error {
+						return ErrConflict
+					},
+					MockGetOrder: func(ctx context.Context, id string) (*Order, error) {
+						return &Order{ID: id, Status: StatusValid, CertificateID: "certID"}, nil
+					},
+				},
+			}
+		},
+		"fail/processing-db.GetCertificatesByAccountID-error": func(t *testing.T) test {
+			o := &Order{
+				ID:        "oID",
+				AccountID: "accID",
+				Status:    StatusProcessing,
+				ExpiresAt: clock.Now().Add(5 * time.Minute),
+			}
+			return test{
+				o: o,
+				db: &MockDB{
+					MockGetDeferredOrder: func(ctx context.Context, orderID string) (*DeferredOrder, error) {
+						return nil, NewError(ErrorMalformedType, "deferred order %s not found", orderID)
+					},
+					MockGetCertificatesByAccountID: func(ctx context.Context, accountID string) ([]*Certificate, error) {
+						return nil, errors.New("force")
+					},
+				},
+				err: NewErrorISE("error retrieving certificates of account accID: force"),
+			}
+		},
 		"ok/ready-expired": func(t *testing.T) test {
 			now := clock.Now()
 			o := &Order{
@@ -272,13 +456,14 @@ func TestOrder_UpdateStatus(t *testing.T) {
 
 type mockSignAuth struct {
 	sign                  func(csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
+	signSSH               func(key ssh.PublicKey, opts provisioner.SignSSHOptions, signOpts ...provisioner.SignOption) (*ssh.Certificate, error)
 	areSANsAllowed        func(ctx context.Context, sans []string) error
 	loadProvisionerByName func(string) (provisioner.Interface, error)
 	ret1, ret2            interface{}
 	err                   error
 }
 
-func (m *mockSignAuth) Sign(csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+func (m *mockSignAuth) SignWithContext(_ context.Context, csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
 	if m.sign != nil {
 		return m.sign(csr, signOpts, extraOpts...)
 	} else if m.err != nil {
@@ -287,6 +472,13 @@ func (m *mockSignAuth) Sign(csr *x509.CertificateRequest, signOpts provisioner.S
 	return []*x509.Certificate{m.ret1.(*x509.Certificate), m.ret2.(*x509.Certificate)}, m.err
 }
 
+func (m *mockSignAuth) SignSSH(_ context.Context, key ssh.PublicKey, opts provisioner.SignSSHOptions, signOpts ...provisioner.SignOption) (*ssh.Certificate, error) {
+	if m.signSSH != nil {
+		return m.signSSH(key, opts, signOpts...)
+	}
+	return nil, m.err
+}
+
 func (m *mockSignAuth) AreSANsAllowed(ctx context.Context, sans []string) error {
 	if m.areSANsAllowed != nil {
 		return m.areSANsAllowed(ctx, sans)
@@ -301,6 +493,10 @@ func (m *mockSignAuth) LoadProvisionerByName(name string) (provisioner.Interface
 	return m.ret1.(provisioner.Interface), m.err
 }
 
+func (m *mockSignAuth) GetAlternateChains(*x509.Certificate) [][]*x509.Certificate {
+	return nil
+}
+
 func (m *mockSignAuth) IsRevoked(string) (bool, error) {
 	return false, nil
 }
@@ -310,6 +506,10 @@ func (m *mockSignAuth) Revoke(context.Context, *authority.RevokeOptions) error {
 }
 
 func TestOrder_Finalize(t *testing.T) {
+	tmp := saveSignedBackoff
+	t.Cleanup(func() { saveSignedBackoff = tmp })
+	saveSignedBackoff = 0
+
 	mustSigner := func(kty, crv string, size int) crypto.Signer {
 		s, err := keyutil.GenerateSigner(kty, crv, size)
 		if err != nil {
@@ -383,6 +583,220 @@ func TestOrder_Finalize(t *testing.T) {
 				o: o,
 			}
 		},
+		"ok/already-processing": func(t *testing.T) test {
+			o := &Order{
+				ID:        "oid",
+				Status:    StatusProcessing,
+				ExpiresAt: clock.Now().Add(5 * time.Minute),
+			}
+			return test{
+				o: o,
+				db: &MockDB{
+					MockGetDeferredOrder: func(ctx context.Context, orderID string) (*DeferredOrder, error) {
+						return nil, NewError(ErrorMalformedType, "deferred order %s not found", orderID)
+					},
+					MockGetCertificatesByAccountID: func(ctx context.Context, accountID string) ([]*Certificate, error) {
+						return nil, nil
+					},
+				},
+			}
+		},
+		"ok/concurrent-finalize": func(t *testing.T) test {
+			now := clock.Now()
+			o := &Order{
+				ID:               "oID",
+				AccountID:        "accID",
+				Status:           StatusReady,
+				ExpiresAt:        now.Add(5 * time.Minute),
+				AuthorizationIDs: []string{"a", "b"},
+				Identifiers: []Identifier{
+					{Type: "dns", Value: "foo.internal"},
+				},
+			}
+			csr := &x509.CertificateRequest{
+				Subject: pkix.Name{
+					CommonName: "foo.internal",
+				},
+			}
+
+			return test{
+				o:   o,
+				csr: csr,
+				prov: &MockProvisioner{
+					MauthorizeSign: func(ctx context.Context, token string) ([]provisioner.SignOption, error) {
+						return nil, nil
+					},
+					MgetOptions: func() *provisioner.Options {
+						return nil
+					},
+				},
+				ca: &mockSignAuth{
+					sign: func(_csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+						t.Error("certificate signed by a concurrent request")
+						return nil, errors.New("force")
+					},
+				},
+				db: &MockDB{
+					MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+						return &Authorization{ID: id, Status: StatusValid}, nil
+					},
+					MockUpdateOrderStatus: func(ctx context.Context, updo *Order, from Status) error {
+						assert.Equals(t, from, StatusReady)
+						assert.Equals(t, updo.Status, StatusProcessing)
+						return errors.Wrap(ErrConflict, "error saving acme order")
+					},
+					MockGetOrder: func(ctx context.Context, id string) (*Order, error) {
+						assert.Equals(t, id, o.ID)
+						return &Order{
+							ID:            o.ID,
+							AccountID:     o.AccountID,
+							Status:        StatusValid,
+							CertificateID: "certID",
+						}, nil
+					},
+				},
+			}
+		},
+		"fail/error-db.UpdateOrderStatus": func(t *testing.T) test {
+			now := clock.Now()
+			o := &Order{
+				ID:               "oID",
+				AccountID:        "accID",
+				Status:           StatusReady,
+				ExpiresAt:        now.Add(5 * time.Minute),
+				AuthorizationIDs: []string{"a", "b"},
+				Identifiers: []Identifier{
+					{Type: "dns", Value: "foo.internal"},
+				},
+			}
+			csr := &x509.CertificateRequest{
+				Subject: pkix.Name{
+					CommonName: "foo.internal",
+				},
+			}
+
+			return test{
+				o:   o,
+				csr: csr,
+				prov: &MockProvisioner{
+					MauthorizeSign: func(ctx context.Context, token string) ([]provisioner.SignOption, error) {
+						return nil, nil
+					},
+					MgetOptions: func() *provisioner.Options {
+						return nil
+					},
+				},
+				db: &MockDB{
+					MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+						return &Authorization{ID: id, Status: StatusValid}, nil
+					},
+					MockUpdateOrderStatus: func(ctx context.Context, updo *Order, from Status) error {
+						return errors.New("force")
+					},
+				},
+				err: NewErrorISE("error updating order oID: force"),
+			}
+		},
+		"fail/error-ca-sign-release": func(t *testing.T) test {
+			now := clock.Now()
+			o := &Order{
+				ID:               "oID",
+				AccountID:        "accID",
+				Status:           StatusReady,
+				ExpiresAt:        now.Add(5 * time.Minute),
+				AuthorizationIDs: []string{"a", "b"},
+				Identifiers: []Identifier{
+					{Type: "dns", Value: "foo.internal"},
+				},
+			}
+			csr := &x509.CertificateRequest{
+				Subject: pkix.Name{
+					CommonName: "foo.internal",
+				},
+			}
+
+			var transitions []Status
+			return test{
+				o:   o,
+				csr: csr,
+				prov: &MockProvisioner{
+					MauthorizeSign: func(ctx context.Context, token string) ([]provisioner.SignOption, error) {
+						return nil, nil
+					},
+					MgetOptions: func() *provisioner.Options {
+						return nil
+					},
+				},
+				ca: &mockSignAuth{
+					sign: func(_csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+						return nil, errors.New("force")
+					},
+				},
+				db: &MockDB{
+					MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+						return &Authorization{ID: id, Status: StatusValid}, nil
+					},
+					MockUpdateOrderStatus: func(ctx context.Context, updo *Order, from Status) error {
+						transitions = append(transitions, from, updo.Status)
+						if updo.Status == StatusReady {
+							assert.Equals(t, []Status{StatusReady, StatusProcessing, StatusProcessing, StatusReady}, transitions)
+						}
+						return nil
+					},
+				},
+				err: NewErrorISE("error signing certificate for order oID: force"),
+			}
+		},
+		"fail/error-ca-sign-unavailable": func(t *testing.T) test {
+			now := clock.Now()
+			o := &Order{
+				ID:               "oID",
+				AccountID:        "accID",
+				Status:           StatusReady,
+				ExpiresAt:        now.Add(5 * time.Minute),
+				AuthorizationIDs: []string{"a", "b"},
+				Identifiers: []Identifier{
+					{Type: "dns", Value: "foo.internal"},
+				},
+			}
+			csr := &x509.CertificateRequest{
+				Subject: pkix.Name{
+					CommonName: "foo.internal",
+				},
+			}
+
+			unavailable := &casapi.UnavailableError{RetryAfter: time.Minute, Err: errors.New("force")}
+			acmeErr := NewErrorISE("error signing certificate for order oID: authority.Sign: signing key is unavailable: force")
+			acmeErr.Status = 503
+			acmeErr.RetryAfter = time.Minute
+			acmeErr.Code = errs.CodeSignerUnavailable
+			return test{
+				o:   o,
+				csr: csr,
+				prov: &MockProvisioner{
+					MauthorizeSign: func(ctx context.Context, token string) ([]provisioner.SignOption, error) {
+						return nil, nil
+					},
+					MgetOptions: func() *provisioner.Options {
+						return nil
+					},
+				},
+				ca: &mockSignAuth{
+					sign: func(_csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+						return nil, errs.Wrap(503, unavailable, "authority.Sign")
+					},
+				},
+				db: &MockDB{
+					MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+						return &Authorization{ID: id, Status: StatusValid}, nil
+					},
+					MockUpdateOrderStatus: func(ctx context.Context, updo *Order, from Status) error {
+						return nil
+					},
+				},
+				err: acmeErr,
+			}
+		},
 		"fail/error-unexpected-status": func(t *testing.T) test {
 			now := clock.Now()
 			o := &Order{
@@ -591,6 +1005,52 @@ func TestOrder_Finalize(t *testing.T) {
 				err: NewErrorISE("error signing certificate for order oID: force"),
 			}
 		},
+		"fail/error-ca-sign-key-policy": func(t *testing.T) test {
+			now := clock.Now()
+			o := &Order{
+				ID:               "oID",
+				AccountID:        "accID",
+				Status:           StatusReady,
+				ExpiresAt:        now.Add(5 * time.Minute),
+				AuthorizationIDs: []string{"a", "b"},
+				Identifiers: []Identifier{
+					{Type: "dns", Value: "foo.internal"},
+					{Type: "dns", Value: "bar.internal"},
+				},
+			}
+			csr := &x509.CertificateRequest{
+				Subject: pkix.Name{
+					CommonName: "foo.internal",
+				},
+				DNSNames: []string{"bar.internal"},
+			}
+
+			return test{
+				o:   o,
+				csr: csr,
+				prov: &MockProvisioner{
+					MauthorizeSign: func(ctx context.Context, token string) ([]provisioner.SignOption, error) {
+						assert.Equals(t, token, "")
+						return nil, nil
+					},
+					MgetOptions: func() *provisioner.Options {
+						return nil
+					},
+				},
+				ca: &mockSignAuth{
+					sign: func(_csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+						assert.Equals(t, _csr, csr)
+						return nil, errs.ForbiddenErr(&keypolicy.Error{Reason: "curve P-384 is not allowed"}, "forbidden")
+					},
+				},
+				db: &MockDB{
+					MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+						return &Authorization{ID: id, Status: StatusValid}, nil
+					},
+				},
+				err: NewError(ErrorBadCSRType, "public key not allowed: curve P-384 is not allowed"),
+			}
+		},
 		"fail/error-db.CreateCertificate": func(t *testing.T) test {
 			now := clock.Now()
 			o := &Order{
@@ -644,6 +1104,20 @@ func TestOrder_Finalize(t *testing.T) {
 						assert.Equals(t, cert.Intermediates, []*x509.Certificate{bar, baz})
 						return errors.New("force")
 					},
+					MockUpdateOrderStatus: func(ctx context.Context, updo *Order, from Status) error {
+						// The certificate has been signed, the order cannot
+						// be released, it becomes invalid.
+						switch from {
+						case StatusReady:
+							assert.Equals(t, updo.Status, StatusProcessing)
+						case StatusProcessing:
+							assert.Equals(t, updo.Status, StatusInvalid)
+							assert.Equals(t, updo.Error.Err.Error(), "error creating certificate for order oID: force")
+						default:
+							t.Errorf("unexpected transition from %s to %s", from, updo.Status)
+						}
+						return nil
+					},
 				},
 				err: NewErrorISE("error creating certificate for order oID: force"),
 			}
@@ -716,6 +1190,78 @@ func TestOrder_Finalize(t *testing.T) {
 				err: NewErrorISE("error updating order oID: force"),
 			}
 		},
+		"ok/retry-db.UpdateOrder": func(t *testing.T) test {
+			now := clock.Now()
+			o := &Order{
+				ID:               "oID",
+				AccountID:        "accID",
+				Status:           StatusReady,
+				ExpiresAt:        now.Add(5 * time.Minute),
+				AuthorizationIDs: []string{"a", "b"},
+				Identifiers: []Identifier{
+					{Type: "dns", Value: "foo.internal"},
+					{Type: "dns", Value: "bar.internal"},
+				},
+			}
+			csr := &x509.CertificateRequest{
+				Subject: pkix.Name{
+					CommonName: "foo.internal",
+				},
+				DNSNames: []string{"bar.internal"},
+			}
+
+			foo := &x509.Certificate{Subject: pkix.Name{CommonName: "foo"}}
+			bar := &x509.Certificate{Subject: pkix.Name{CommonName: "bar"}}
+			baz := &x509.Certificate{Subject: pkix.Name{CommonName: "baz"}}
+
+			var attempts int
+			return test{
+				o:   o,
+				csr: csr,
+				prov: &MockProvisioner{
+					MauthorizeSign: func(ctx context.Context, token string) ([]provisioner.SignOption, error) {
+						assert.Equals(t, token, "")
+						return nil, nil
+					},
+					MgetOptions: func() *provisioner.Options {
+						return nil
+					},
+				},
+				ca: &mockSignAuth{
+					sign: func(_csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+						assert.Equals(t, _csr, csr)
+						return []*x509.Certificate{foo, bar, baz}, nil
+					},
+				},
+				db: &MockDB{
+					MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+						return &Authorization{ID: id, Status: StatusValid}, nil
+					},
+					MockCreateCertificate: func(ctx context.Context, cert *Certificate) error {
+						cert.ID = "certID"
+						assert.Equals(t, cert.AccountID, o.AccountID)
+						assert.Equals(t, cert.OrderID, o.ID)
+						assert.Equals(t, cert.Leaf, foo)
+						assert.Equals(t, cert.Intermediates, []*x509.Certificate{bar, baz})
+						return nil
+					},
+					MockUpdateOrder: func(ctx context.Context, updo *Order) error {
+						assert.Equals(t, updo.CertificateID, "certID")
+						assert.Equals(t, updo.Status, StatusValid)
+						assert.Equals(t, updo.ID, o.ID)
+						assert.Equals(t, updo.AccountID, o.AccountID)
+						assert.Equals(t, updo.ExpiresAt, o.ExpiresAt)
+						assert.Equals(t, updo.AuthorizationIDs, o.AuthorizationIDs)
+						assert.Equals(t, updo.Identifiers, o.Identifiers)
+						// The update is retried after a failure.
+						if attempts++; attempts == 1 {
+							return errors.New("force")
+						}
+						return nil
+					},
+				},
+			}
+		},
 		"fail/csr-fingerprint": func(t *testing.T) test {
 			now := clock.Now()
 			o := &Order{
@@ -1216,6 +1762,8 @@ func TestOrder_Finalize(t *testing.T) {
 						assert.Equals(t, k.Type, tc.err.Type)
 						assert.Equals(t, k.Detail, tc.err.Detail)
 						assert.Equals(t, k.Status, tc.err.Status)
+						assert.Equals(t, k.RetryAfter, tc.err.RetryAfter)
+						assert.Equals(t, k.ErrorCode(), tc.err.ErrorCode())
 						assert.Equals(t, k.Err.Error(), tc.err.Err.Error())
 						assert.Equals(t, k.Detail, tc.err.Detail)
 					} else {
@@ -1943,3 +2491,82 @@ func TestOrder_getAuthorizationFingerprint(t *testing.T) {
 		})
 	}
 }
+
+func TestOrder_signOptions_template(t *testing.T) {
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+	csr, err := x509util.CreateCertificateRequest("foo.internal", []string{"foo.internal"}, signer)
+	assert.FatalError(t, err)
+
+	// The template adds a policy extension with the account and sets the
+	// validity using the identifiers.
+	template := `{
+		"subject": {{ toJson .Subject }},
+		"sans": {{ toJson .SANs }},
+		"extensions": [{"id": "1.3.6.1.4.1.37476.9000.64.1", "value": {{ asn1Enc (printf "utf8:%s" .Order.AccountID) | toJson }}}],
+		"extKeyUsage": ["serverAuth"],
+		{{- range .Order.Identifiers }}{{ if hasSuffix ".internal" .Value }}
+		"validity": "2160h",
+		{{- end }}{{ end }}
+		"keyUsage": ["digitalSignature"]
+	}`
+	prov := &MockProvisioner{
+		MauthorizeSign: func(ctx context.Context, token string) ([]provisioner.SignOption, error) {
+			return nil, nil
+		},
+		MgetOptions: func() *provisioner.Options {
+			return &provisioner.Options{X509: &provisioner.X509Options{Template: template}}
+		},
+	}
+	db := &MockDB{
+		MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+			return &Authorization{ID: id, Status: StatusValid}, nil
+		},
+	}
+	now := clock.Now().Truncate(time.Second)
+	o := &Order{
+		ID:               "oID",
+		AccountID:        "accID",
+		AuthorizationIDs: []string{"a"},
+		Identifiers:      []Identifier{{Type: DNS, Value: "foo.internal"}},
+		NotBefore:        now,
+		NotAfter:         now.Add(24 * time.Hour),
+	}
+
+	csr, signOps, err := o.signOptions(context.Background(), db, csr, prov)
+	assert.FatalError(t, err)
+
+	// Apply the options like the authority does.
+	so := provisioner.SignOptions{
+		NotBefore: provisioner.NewTimeDuration(o.NotBefore),
+		NotAfter:  provisioner.NewTimeDuration(o.NotAfter),
+	}
+	var certOptions []x509util.Option
+	var modifiers []provisioner.CertificateModifier
+	for _, op := range signOps {
+		switch k := op.(type) {
+		case provisioner.CertificateOptions:
+			certOptions = append(certOptions, k.Options(so)...)
+		case provisioner.CertificateModifier:
+			modifiers = append(modifiers, k)
+		}
+	}
+	c, err := x509util.NewCertificate(csr, certOptions...)
+	assert.FatalError(t, err)
+	cert := c.GetCertificate()
+	cert.NotBefore, cert.NotAfter = o.NotBefore, o.NotAfter
+	for _, m := range modifiers {
+		assert.FatalError(t, m.Modify(cert, so))
+	}
+
+	assert.Equals(t, []string{"foo.internal"}, cert.DNSNames)
+	assert.Equals(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, cert.ExtKeyUsage)
+	assert.Equals(t, now.Add(2160*time.Hour), cert.NotAfter)
+	if assert.Len(t, 1, cert.ExtraExtensions) {
+		assert.Equals(t, asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37476, 9000, 64, 1}, cert.ExtraExtensions[0].Id)
+		var accountID string
+		_, err := asn1.UnmarshalWithParams(cert.ExtraExtensions[0].Value, &accountID, "utf8")
+		assert.FatalError(t, err)
+		assert.Equals(t, "accID", accountID)
+	}
+}
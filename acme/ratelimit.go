@@ -0,0 +1,134 @@
+package acme
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// rateLimitRetries is the number of times a rate limit is read again after a
+// concurrent update.
+const rateLimitRetries = 3
+
+// RateLimitKey returns the key of the requests counted by a rate limit, the
+// kind of request followed by the provisioner and the values the requests are
+// limited by.
+func RateLimitKey(kind, provisionerID string, values ...string) string {
+	return strings.Join(append([]string{kind, provisionerID}, values...), ":")
+}
+
+// Kinds of the requests counted by the rate limits.
+const (
+	NewAccountRateLimit       = "newAccount"
+	NewOrderRateLimit         = "newOrder"
+	FailedValidationRateLimit = "failedValidation"
+)
+
+// ConsumeRateLimit counts a request in the sliding window of the given key. It
+// returns a rateLimited error, without counting the request, if the window is
+// already full. A nil limit does not limit the requests.
+func ConsumeRateLimit(ctx context.Context, db DB, key string, limit *provisioner.ACMERateLimit) error {
+	return updateRateLimit(ctx, db, key, limit, true)
+}
+
+// CheckRateLimit returns a rateLimited error if the sliding window of the
+// given key is full. The request is not counted, use RecordRateLimit to
+// count the requests that do not always consume the limit, like the failed
+// validations.
+func CheckRateLimit(ctx context.Context, db DB, key string, limit *provisioner.ACMERateLimit) error {
+	if limit == nil {
+		return nil
+	}
+	old, err := db.GetRateLimitEvents(ctx, key)
+	if err != nil {
+		return WrapErrorISE(err, "error loading rate limit %s", key)
+	}
+	now := clock.Now()
+	return rateLimitError(limit, inWindow(old, now, limit.Window.Duration), now)
+}
+
+// RecordRateLimit counts a request in the sliding window of the given key,
+// even if the window is full.
+func RecordRateLimit(ctx context.Context, db DB, key string, limit *provisioner.ACMERateLimit) error {
+	return updateRateLimit(ctx, db, key, limit, false)
+}
+
+func updateRateLimit(ctx context.Context, db DB, key string, limit *provisioner.ACMERateLimit, enforce bool) error {
+	if limit == nil {
+		return nil
+	}
+	for i := 0; ; i++ {
+		old, err := db.GetRateLimitEvents(ctx, key)
+		if err != nil {
+			return WrapErrorISE(err, "error loading rate limit %s", key)
+		}
+		now := clock.Now()
+		events := inWindow(old, now, limit.Window.Duration)
+		if enforce {
+			if err := rateLimitError(limit, events, now); err != nil {
+				return err
+			}
+		}
+		// Keep at most the events that can fill the window.
+		events = append(events, now)
+		if n := len(events) - limit.Limit; n > 0 {
+			events = events[n:]
+		}
+		err = db.UpdateRateLimitEvents(ctx, key, events, old, now.Add(limit.Window.Duration))
+		switch {
+		case err == nil:
+			return nil
+		case !IsErrConflict(err) || i == rateLimitRetries:
+			return WrapErrorISE(err, "error updating rate limit %s", key)
+		}
+	}
+}
+
+// inWindow returns the events in the window that ends now.
+func inWindow(events []time.Time, now time.Time, window time.Duration) []time.Time {
+	start := now.Add(-window)
+	ret := make([]time.Time, 0, len(events)+1)
+	for _, t := range events {
+		if t.After(start) {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+// rateLimitError returns a rateLimited error if the window is full. The client
+// can retry when the oldest event leaves the window.
+func rateLimitError(limit *provisioner.ACMERateLimit, events []time.Time, now time.Time) error {
+	if len(events) < limit.Limit {
+		return nil
+	}
+	oldest := events[len(events)-limit.Limit]
+	retryAfter := oldest.Add(limit.Window.Duration).Sub(now)
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	err := NewDetailedError(ErrorRateLimitedType, "%d requests in %s", limit.Limit, limit.Window.Duration)
+	err.RetryAfter = retryAfter
+	return err
+}
+
+// recordFailedValidation counts a failed validation of the challenge in the
+// rate limit of the hostname and account. Errors are logged, they do not
+// affect the challenge.
+func (ch *Challenge) recordFailedValidation(ctx context.Context, db DB) {
+	p, ok := ProvisionerFromContext(ctx)
+	if !ok {
+		return
+	}
+	limit := p.GetRateLimitOptions().GetFailedValidationsPerHostname()
+	if limit == nil {
+		return
+	}
+	key := RateLimitKey(FailedValidationRateLimit, p.GetID(), ch.AccountID, ch.Value)
+	if err := RecordRateLimit(ctx, db, key, limit); err != nil {
+		log.Printf("error recording failed validation of challenge %s: %v", ch.ID, err)
+	}
+}
@@ -0,0 +1,178 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// newRateLimitDB returns a MockDB that keeps the rate limits in memory.
+func newRateLimitDB() (*MockDB, map[string][]time.Time) {
+	events := map[string][]time.Time{}
+	return &MockDB{
+		MockGetRateLimitEvents: func(ctx context.Context, key string) ([]time.Time, error) {
+			return events[key], nil
+		},
+		MockUpdateRateLimitEvents: func(ctx context.Context, key string, nu, old []time.Time, expiresAt time.Time) error {
+			if !reflect.DeepEqual(events[key], old) {
+				return ErrConflict
+			}
+			if len(nu) > 0 && !expiresAt.After(nu[len(nu)-1]) {
+				return errors.New("rate limit expires before its last event")
+			}
+			events[key] = nu
+			return nil
+		},
+	}, events
+}
+
+func TestConsumeRateLimit(t *testing.T) {
+	ctx := context.Background()
+	db, events := newRateLimitDB()
+	limit := &provisioner.ACMERateLimit{Limit: 2, Window: provisioner.Duration{Duration: time.Hour}}
+	key := RateLimitKey(NewOrderRateLimit, "provID", "accID")
+	if key != "newOrder:provID:accID" {
+		t.Errorf("RateLimitKey() = %s, want newOrder:provID:accID", key)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := ConsumeRateLimit(ctx, db, key, limit); err != nil {
+			t.Fatalf("ConsumeRateLimit() error = %v", err)
+		}
+	}
+	err := ConsumeRateLimit(ctx, db, key, limit)
+	var acmeErr *Error
+	if !errors.As(err, &acmeErr) {
+		t.Fatalf("ConsumeRateLimit() error = %v, want rateLimited", err)
+	}
+	if acmeErr.Type != "urn:ietf:params:acme:error:rateLimited" || acmeErr.Status != 429 {
+		t.Errorf("ConsumeRateLimit() error = %s %d, want rateLimited 429", acmeErr.Type, acmeErr.Status)
+	}
+	if acmeErr.RetryAfter <= 59*time.Minute || acmeErr.RetryAfter > time.Hour {
+		t.Errorf("Error.RetryAfter = %s, want about 1h", acmeErr.RetryAfter)
+	}
+	if len(events[key]) != 2 {
+		t.Errorf("rate limit events = %v, want 2 events", events[key])
+	}
+
+	// The events out of the window are discarded.
+	events[key] = []time.Time{clock.Now().Add(-2 * time.Hour), clock.Now().Add(-90 * time.Minute)}
+	if err := ConsumeRateLimit(ctx, db, key, limit); err != nil {
+		t.Errorf("ConsumeRateLimit() error = %v", err)
+	}
+	if len(events[key]) != 1 {
+		t.Errorf("rate limit events = %v, want 1 event", events[key])
+	}
+
+	// A nil limit does not limit the requests.
+	if err := ConsumeRateLimit(ctx, &MockDB{MockError: errors.New("force")}, key, nil); err != nil {
+		t.Errorf("ConsumeRateLimit() error = %v", err)
+	}
+}
+
+func TestConsumeRateLimit_conflict(t *testing.T) {
+	ctx := context.Background()
+	db, events := newRateLimitDB()
+	limit := &provisioner.ACMERateLimit{Limit: 5, Window: provisioner.Duration{Duration: time.Hour}}
+
+	// A concurrent request is recorded between the read and the update.
+	var conflicts int
+	update := db.MockUpdateRateLimitEvents
+	db.MockUpdateRateLimitEvents = func(ctx context.Context, key string, nu, old []time.Time, expiresAt time.Time) error {
+		if conflicts < 2 {
+			conflicts++
+			events[key] = append(events[key], clock.Now())
+		}
+		return update(ctx, key, nu, old, expiresAt)
+	}
+	if err := ConsumeRateLimit(ctx, db, "key", limit); err != nil {
+		t.Fatalf("ConsumeRateLimit() error = %v", err)
+	}
+	if len(events["key"]) != 3 {
+		t.Errorf("rate limit events = %v, want 3 events", events["key"])
+	}
+
+	// Conflicts are not retried forever.
+	db.MockUpdateRateLimitEvents = func(ctx context.Context, key string, nu, old []time.Time, expiresAt time.Time) error {
+		return ErrConflict
+	}
+	err := ConsumeRateLimit(ctx, db, "key", limit)
+	var acmeErr *Error
+	if !errors.As(err, &acmeErr) || acmeErr.Status != 500 {
+		t.Errorf("ConsumeRateLimit() error = %v, want serverInternal", err)
+	}
+}
+
+func TestCheckRateLimit(t *testing.T) {
+	ctx := context.Background()
+	db, events := newRateLimitDB()
+	limit := &provisioner.ACMERateLimit{Limit: 2, Window: provisioner.Duration{Duration: time.Hour}}
+	key := RateLimitKey(FailedValidationRateLimit, "provID", "accID", "foo.internal")
+
+	for i := 0; i < 3; i++ {
+		if err := CheckRateLimit(ctx, db, key, limit); err != nil {
+			t.Fatalf("CheckRateLimit() error = %v", err)
+		}
+	}
+	if len(events[key]) != 0 {
+		t.Errorf("rate limit events = %v, want no events", events[key])
+	}
+
+	// Recorded requests are kept even if the window is full.
+	for i := 0; i < 3; i++ {
+		if err := RecordRateLimit(ctx, db, key, limit); err != nil {
+			t.Fatalf("RecordRateLimit() error = %v", err)
+		}
+	}
+	if len(events[key]) != 2 {
+		t.Errorf("rate limit events = %v, want 2 events", events[key])
+	}
+	err := CheckRateLimit(ctx, db, key, limit)
+	var acmeErr *Error
+	if !errors.As(err, &acmeErr) || acmeErr.Status != 429 {
+		t.Errorf("CheckRateLimit() error = %v, want rateLimited", err)
+	}
+}
+
+func TestChallenge_recordFailedValidation(t *testing.T) {
+	db, events := newRateLimitDB()
+	prov := &MockProvisioner{
+		MgetID: func() string { return "provID" },
+		MgetRateLimitOptions: func() *provisioner.ACMERateLimitOptions {
+			return &provisioner.ACMERateLimitOptions{
+				FailedValidationsPerHostname: &provisioner.ACMERateLimit{Limit: 5, Window: provisioner.Duration{Duration: time.Hour}},
+			}
+		},
+	}
+	ch := &Challenge{ID: "chID", AccountID: "accID", Type: HTTP01, Status: StatusPending, Value: "foo.internal"}
+	key := RateLimitKey(FailedValidationRateLimit, "provID", "accID", "foo.internal")
+
+	// Without a provisioner there are no limits.
+	ch.recordFailedValidation(context.Background(), db)
+	if len(events[key]) != 0 {
+		t.Errorf("rate limit events = %v, want no events", events[key])
+	}
+
+	ch.recordFailedValidation(NewProvisionerContext(context.Background(), prov), db)
+	if len(events[key]) != 1 {
+		t.Errorf("rate limit events = %v, want 1 event", events[key])
+	}
+}
+
+func TestError_Render_retryAfter(t *testing.T) {
+	err := NewError(ErrorRateLimitedType, "too many requests")
+	err.RetryAfter = 1500 * time.Millisecond
+	w := httptest.NewRecorder()
+	err.Render(w)
+	if w.Code != 429 {
+		t.Errorf("Error.Render() status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Error.Render() Retry-After = %s, want 2", got)
+	}
+}
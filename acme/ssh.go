@@ -0,0 +1,170 @@
+package acme
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/sshutil"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// loadProvisionerByName returns the provisioner of the authority with the
+// given name. It's a variable so it can be replaced in the tests.
+var loadProvisionerByName = func(ctx context.Context, name string) (provisioner.Interface, error) {
+	return authority.MustFromContext(ctx).LoadProvisionerByName(name)
+}
+
+// sshPrincipalAuthorizer is the interface implemented by the provisioners
+// that validate the ID tokens of the oidc-01 challenges, like
+// provisioner.OIDC.
+type sshPrincipalAuthorizer interface {
+	AuthorizeSSHPrincipal(ctx context.Context, token, nonce, principal string) error
+}
+
+type oidc01Payload struct {
+	Token string `json:"token"`
+}
+
+// oidc01Validate validates an oidc-01 challenge with the ID token in the
+// payload. The token is validated by the OIDC provisioner configured in the
+// ACME provisioner, its nonce must be the base64url-encoded SHA-256 digest of
+// the key authorization, so the token cannot be used by other accounts, and
+// the principal of the identifier must be allowed for the identity of the
+// token.
+func oidc01Validate(ctx context.Context, ch *Challenge, db DB, jwk *jose.JSONWebKey, payload []byte) error {
+	opts := MustProvisionerFromContext(ctx).GetOIDCOptions()
+	if opts == nil {
+		return NewErrorISE("oidc-01 challenges are not configured")
+	}
+
+	var p oidc01Payload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return WrapError(ErrorMalformedType, err, "error unmarshaling oidc-01 payload")
+	}
+	if p.Token == "" {
+		return NewError(ErrorMalformedType, "oidc-01 payload does not contain a token")
+	}
+
+	prov, err := loadProvisionerByName(ctx, opts.Provisioner)
+	if err != nil {
+		return WrapErrorISE(err, "error loading provisioner %s", opts.Provisioner)
+	}
+	authorizer, ok := prov.(sshPrincipalAuthorizer)
+	if !ok {
+		return NewErrorISE("provisioner %s cannot validate oidc-01 challenges", opts.Provisioner)
+	}
+
+	keyAuth, err := KeyAuthorization(ch.Token, jwk)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(keyAuth))
+	nonce := base64.RawURLEncoding.EncodeToString(sum[:])
+	if err := authorizer.AuthorizeSSHPrincipal(ctx, p.Token, nonce, ch.Value); err != nil {
+		return storeError(ctx, db, ch, true, WrapDetailedError(ErrorUnauthorizedType, err,
+			"error validating oidc token for %s", ch.Value))
+	}
+
+	ch.Status = StatusValid
+	ch.Error = nil
+	ch.ValidatedAt = clock.Now().Format(time.RFC3339)
+	if err := db.UpdateChallenge(ctx, ch); err != nil {
+		return WrapErrorISE(err, "error updating challenge")
+	}
+	return nil
+}
+
+// isSSH returns true if the order is for an SSH user certificate.
+func (o *Order) isSSH() bool {
+	return numberOfIdentifierType(SSHPrincipal, o.Identifiers) > 0
+}
+
+// sshPrincipals returns the principals of the SSH certificate of the order.
+func (o *Order) sshPrincipals() []string {
+	var principals []string
+	for _, id := range o.Identifiers {
+		if id.Type == SSHPrincipal {
+			principals = append(principals, id.Value)
+		}
+	}
+	return principals
+}
+
+// sshSignOptions returns the options used to sign the SSH user certificate
+// of an order of ssh-principal identifiers. The principals of the
+// certificate are the ssh-principal identifiers, and the key is the one in
+// the CSR.
+func (o *Order) sshSignOptions(ctx context.Context, csr *x509.CertificateRequest, p Provisioner) (*x509.CertificateRequest, []provisioner.SignOption, error) {
+	if _, err := ssh.NewPublicKey(csr.PublicKey); err != nil {
+		return nil, nil, WrapError(ErrorBadCSRType, err, "CSR public key cannot be used in an SSH certificate")
+	}
+
+	principals := o.sshPrincipals()
+	data := sshutil.CreateTemplateData(sshutil.UserCert, principals[0], principals)
+	data.Set(OrderTemplateKey, &OrderTemplateData{
+		AccountID:   o.AccountID,
+		OrderID:     o.ID,
+		Identifiers: o.Identifiers,
+	})
+
+	signOps, err := p.AuthorizeSSHSign(ctx, "")
+	if err != nil {
+		return nil, nil, WrapErrorISE(err, "error retrieving authorization options from ACME provisioner")
+	}
+	for _, signOp := range signOps {
+		if wc, ok := signOp.(*provisioner.WebhookController); ok {
+			wc.TemplateData = data
+		}
+	}
+
+	templateOptions, err := provisioner.CustomSSHTemplateOptions(p.GetOptions(), data, sshutil.DefaultTemplate)
+	if err != nil {
+		return nil, nil, WrapErrorISE(err, "error creating template options from ACME provisioner")
+	}
+	return csr, append(signOps, templateOptions), nil
+}
+
+// signSSH signs the SSH user certificate of a processing order and moves the
+// order to the valid state.
+func (o *Order) signSSH(ctx context.Context, db DB, csr *x509.CertificateRequest, auth CertificateAuthority, signOps []provisioner.SignOption) (*Certificate, error) {
+	key, err := ssh.NewPublicKey(csr.PublicKey)
+	if err != nil {
+		return nil, WrapError(ErrorBadCSRType, err, "CSR public key cannot be used in an SSH certificate")
+	}
+	principals := o.sshPrincipals()
+	sshCert, err := auth.SignSSH(ctx, key, provisioner.SignSSHOptions{
+		CertType:    provisioner.SSHUserCert,
+		KeyID:       principals[0],
+		Principals:  principals,
+		ValidAfter:  provisioner.NewTimeDuration(o.NotBefore),
+		ValidBefore: provisioner.NewTimeDuration(o.NotAfter),
+	}, signOps...)
+	if err != nil {
+		return nil, WrapErrorISE(err, "error signing certificate for order %s", o.ID)
+	}
+
+	cert := &Certificate{
+		AccountID: o.AccountID,
+		OrderID:   o.ID,
+		SSH:       sshCert,
+	}
+	if err := db.CreateCertificate(ctx, cert); err != nil {
+		return nil, o.abandon(ctx, db, WrapErrorISE(err, "error creating certificate for order %s", o.ID))
+	}
+
+	o.CertificateID = cert.ID
+	o.Status = StatusValid
+	if err := o.saveSigned(ctx, db); err != nil {
+		return nil, WrapErrorISE(err, "error updating order %s", o.ID)
+	}
+	MeterFromContext(ctx).OrderChanged(ctx, o, cert)
+	return cert, nil
+}
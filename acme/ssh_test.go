@@ -0,0 +1,172 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/jose"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+type fakeOIDC struct {
+	provisioner.Interface
+	token, nonce, principal string
+	err                     error
+}
+
+func (p *fakeOIDC) AuthorizeSSHPrincipal(_ context.Context, token, nonce, principal string) error {
+	p.token, p.nonce, p.principal = token, nonce, principal
+	return p.err
+}
+
+func Test_oidc01Validate(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	require.NoError(t, err)
+	pub := jwk.Public()
+	keyAuth, err := KeyAuthorization("token", &pub)
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte(keyAuth))
+	nonce := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	oidcOpts := &provisioner.ACMEOIDCOptions{Provisioner: "Google"}
+	tests := []struct {
+		name     string
+		opts     *provisioner.ACMEOIDCOptions
+		prov     provisioner.Interface
+		payload  string
+		status   Status
+		wantErr  string
+		wantCall bool
+	}{
+		{"ok", oidcOpts, &fakeOIDC{}, `{"token":"id-token"}`, StatusValid, "", true},
+		{"fail/authorize", oidcOpts, &fakeOIDC{err: errors.New("principal jane is not allowed")}, `{"token":"id-token"}`, StatusInvalid, "", true},
+		{"fail/token", oidcOpts, &fakeOIDC{}, `{}`, StatusPending, "oidc-01 payload does not contain a token", false},
+		{"fail/payload", oidcOpts, &fakeOIDC{}, `foo`, StatusPending, "error unmarshaling oidc-01 payload", false},
+		{"fail/provisioner", oidcOpts, &provisioner.JWK{}, `{"token":"id-token"}`, StatusPending, "provisioner Google cannot validate oidc-01 challenges", false},
+		{"fail/disabled", nil, &fakeOIDC{}, `{"token":"id-token"}`, StatusPending, "oidc-01 challenges are not configured", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp := loadProvisionerByName
+			t.Cleanup(func() { loadProvisionerByName = tmp })
+			loadProvisionerByName = func(_ context.Context, name string) (provisioner.Interface, error) {
+				assert.Equal(t, "Google", name)
+				return tt.prov, nil
+			}
+
+			ctx := NewProvisionerContext(context.Background(), &MockProvisioner{
+				MgetOIDCOptions: func() *provisioner.ACMEOIDCOptions { return tt.opts },
+			})
+			ch := &Challenge{ID: "chID", Type: OIDC01, Status: StatusPending, Token: "token", Value: "jane"}
+			var updated bool
+			db := &MockDB{
+				MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+					updated = true
+					assert.Equal(t, tt.status, updch.Status)
+					return nil
+				},
+			}
+
+			err := oidc01Validate(ctx, ch, db, &pub, []byte(tt.payload))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.False(t, updated)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, updated)
+			assert.Equal(t, tt.status, ch.Status)
+			if fake, ok := tt.prov.(*fakeOIDC); ok && tt.wantCall {
+				assert.Equal(t, "id-token", fake.token)
+				assert.Equal(t, nonce, fake.nonce)
+				assert.Equal(t, "jane", fake.principal)
+			}
+			if tt.status == StatusInvalid {
+				require.NotNil(t, ch.Error)
+				assert.Equal(t, 401, ch.Error.Status)
+			} else {
+				assert.NotEmpty(t, ch.ValidatedAt)
+			}
+		})
+	}
+}
+
+func TestOrder_Finalize_ssh(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	csr := &x509.CertificateRequest{PublicKey: key.Public()}
+
+	now := clock.Now()
+	o := &Order{
+		ID:               "oID",
+		AccountID:        "accID",
+		Status:           StatusReady,
+		ExpiresAt:        now.Add(5 * time.Minute),
+		NotBefore:        now,
+		NotAfter:         now.Add(16 * time.Hour),
+		AuthorizationIDs: []string{"a", "b"},
+		Identifiers: []Identifier{
+			{Type: SSHPrincipal, Value: "jane"},
+			{Type: SSHPrincipal, Value: "jane@example.com"},
+		},
+	}
+	sshCert := &ssh.Certificate{Serial: 1234, CertType: ssh.UserCert}
+
+	prov := &MockProvisioner{
+		MauthorizeSSHSign: func(ctx context.Context, token string) ([]provisioner.SignOption, error) {
+			assert.Equal(t, "", token)
+			return nil, nil
+		},
+		MgetOptions: func() *provisioner.Options { return nil },
+	}
+	ca := &mockSignAuth{
+		sign: func(*x509.CertificateRequest, provisioner.SignOptions, ...provisioner.SignOption) ([]*x509.Certificate, error) {
+			t.Fatal("unexpected call to SignWithContext")
+			return nil, nil
+		},
+		signSSH: func(k ssh.PublicKey, opts provisioner.SignSSHOptions, signOpts ...provisioner.SignOption) (*ssh.Certificate, error) {
+			assert.Equal(t, provisioner.SSHUserCert, opts.CertType)
+			assert.Equal(t, "jane", opts.KeyID)
+			assert.Equal(t, []string{"jane", "jane@example.com"}, opts.Principals)
+			assert.Equal(t, o.NotAfter, opts.ValidBefore.Time())
+			assert.Len(t, signOpts, 1) // the template options
+			expected, err := ssh.NewPublicKey(key.Public())
+			require.NoError(t, err)
+			assert.Equal(t, expected.Marshal(), k.Marshal())
+			return sshCert, nil
+		},
+	}
+	db := &MockDB{
+		MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+			return &Authorization{ID: id, Status: StatusValid}, nil
+		},
+		MockCreateCertificate: func(ctx context.Context, cert *Certificate) error {
+			cert.ID = "certID"
+			assert.Equal(t, "accID", cert.AccountID)
+			assert.Equal(t, sshCert, cert.SSH)
+			assert.Nil(t, cert.Leaf)
+			return nil
+		},
+		MockUpdateOrder: func(ctx context.Context, updo *Order) error {
+			assert.Equal(t, "certID", updo.CertificateID)
+			assert.Equal(t, StatusValid, updo.Status)
+			return nil
+		},
+	}
+
+	require.NoError(t, o.Finalize(context.Background(), db, csr, ca, prov))
+	assert.Equal(t, StatusValid, o.Status)
+	assert.Equal(t, "certID", o.CertificateID)
+}
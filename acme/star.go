@@ -0,0 +1,275 @@
+package acme
+
+import (
+	"context"
+	"crypto/x509"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// AutoRenewal is the auto-renewal object of the Short-Term, Automatically
+// Renewed (STAR) orders defined in RFC 8739. Orders with an auto-renewal
+// object get a new certificate of the given lifetime, in seconds, until the
+// end date, and the latest certificate is always available in the
+// star-certificate URL of the order.
+type AutoRenewal struct {
+	StartDate           time.Time `json:"start-date,omitempty"`
+	EndDate             time.Time `json:"end-date"`
+	Lifetime            int64     `json:"lifetime"`
+	LifetimeAdjust      int64     `json:"lifetime-adjust,omitempty"`
+	AllowCertificateGet bool      `json:"allow-certificate-get,omitempty"`
+}
+
+// GetLifetime returns the lifetime of the certificates.
+func (a *AutoRenewal) GetLifetime() time.Duration {
+	return time.Duration(a.Lifetime) * time.Second
+}
+
+// GetLifetimeAdjust returns the time the validity of the certificates is
+// moved back to tolerate clock skews.
+func (a *AutoRenewal) GetLifetimeAdjust() time.Duration {
+	return time.Duration(a.LifetimeAdjust) * time.Second
+}
+
+// Window returns the validity of the certificate issued at the given time.
+// Certificates are never valid after the end date.
+func (a *AutoRenewal) Window(now time.Time) (notBefore, notAfter time.Time) {
+	start := now
+	if start.Before(a.StartDate) {
+		start = a.StartDate
+	}
+	notBefore = start.Add(-a.GetLifetimeAdjust())
+	notAfter = start.Add(a.GetLifetime())
+	if notAfter.After(a.EndDate) {
+		notAfter = a.EndDate
+	}
+	return
+}
+
+// StarOrderStatus is the status of the renewals of an auto-renewal order.
+type StarOrderStatus string
+
+const (
+	// StarOrderActive is the status of the orders that are renewed.
+	StarOrderActive StarOrderStatus = "active"
+	// StarOrderCanceled is the status of the orders canceled by the client.
+	StarOrderCanceled StarOrderStatus = "canceled"
+	// StarOrderFinished is the status of the orders whose last certificate
+	// reaches the end date.
+	StarOrderFinished StarOrderStatus = "finished"
+)
+
+// StarOrder keeps the CSR of a finalized auto-renewal order, used to sign
+// the following certificates, and the time of the next renewal.
+type StarOrder struct {
+	OrderID       string          `json:"orderID"`
+	AccountID     string          `json:"accountID"`
+	ProvisionerID string          `json:"provisionerID"`
+	CSR           []byte          `json:"csr"`
+	Status        StarOrderStatus `json:"status"`
+	NextRenewalAt time.Time       `json:"nextRenewalAt"`
+	CreatedAt     time.Time       `json:"createdAt"`
+}
+
+// starRenewalRetry is the time between the attempts to renew a certificate
+// that cannot be signed.
+var starRenewalRetry = 5 * time.Minute
+
+// loadProvisionerByID returns the ACME provisioner of the authority with the
+// given id. It's a variable so it can be replaced in the tests.
+var loadProvisionerByID = func(ctx context.Context, id string) (Provisioner, error) {
+	prov, err := authority.MustFromContext(ctx).LoadProvisionerByID(id)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := prov.(Provisioner)
+	if !ok {
+		return nil, errors.Errorf("provisioner %s is not an ACME provisioner", id)
+	}
+	return p, nil
+}
+
+// isStar returns true if the order is an auto-renewal order.
+func (o *Order) isStar() bool {
+	return o.AutoRenewal != nil
+}
+
+// nextRenewal returns the time the certificate of an auto-renewal order is
+// renewed, when half of its lifetime has passed, and false if the order ends
+// with it.
+func (o *Order) nextRenewal(now time.Time, leaf *x509.Certificate) (time.Time, bool) {
+	if !leaf.NotAfter.Before(o.AutoRenewal.EndDate) {
+		return time.Time{}, false
+	}
+	return now.Add(leaf.NotAfter.Sub(now) / 2), true
+}
+
+// startAutoRenewal stores the CSR of a finalized auto-renewal order and
+// schedules the renewal of its first certificate.
+func (o *Order) startAutoRenewal(ctx context.Context, db DB, csr []byte, cert *Certificate) error {
+	now := clock.Now()
+	s := &StarOrder{
+		OrderID:       o.ID,
+		AccountID:     o.AccountID,
+		ProvisionerID: o.ProvisionerID,
+		CSR:           csr,
+		Status:        StarOrderActive,
+		CreatedAt:     now,
+	}
+	next, ok := o.nextRenewal(now, cert.Leaf)
+	if ok {
+		s.NextRenewalAt = next
+	} else {
+		s.Status = StarOrderFinished
+	}
+	if err := db.CreateStarOrder(ctx, s); err != nil {
+		return WrapErrorISE(err, "error creating auto-renewal of order %s", o.ID)
+	}
+	return nil
+}
+
+// Cancel cancels a valid auto-renewal order, as defined in RFC 8739 section
+// 3.1.2. Its certificate is not renewed anymore and the star-certificate URL
+// is no longer available.
+func (o *Order) Cancel(ctx context.Context, db DB) error {
+	switch {
+	case !o.isStar():
+		return NewError(ErrorAutoRenewalCancellationInvalidType, "order %s is not an auto-renewal order", o.ID)
+	case o.Status == StatusCanceled:
+		return nil
+	case o.Status != StatusValid:
+		return NewError(ErrorAutoRenewalCancellationInvalidType, "order %s is not valid", o.ID)
+	case !clock.Now().Before(o.AutoRenewal.EndDate):
+		return NewError(ErrorAutoRenewalExpiredType, "auto-renewal of order %s has expired", o.ID)
+	}
+
+	o.Status = StatusCanceled
+	if err := db.UpdateOrderStatus(ctx, o, StatusValid); err != nil {
+		if IsErrConflict(err) {
+			return o.reload(ctx, db)
+		}
+		o.Status = StatusValid
+		return WrapErrorISE(err, "error updating order %s", o.ID)
+	}
+
+	s, err := db.GetStarOrder(ctx, o.ID)
+	if err != nil {
+		return WrapErrorISE(err, "error retrieving auto-renewal of order %s", o.ID)
+	}
+	if s.Status == StarOrderActive {
+		s.Status = StarOrderCanceled
+		if err := db.UpdateStarOrder(ctx, s, StarOrderActive); err != nil && !IsErrConflict(err) {
+			return WrapErrorISE(err, "error updating auto-renewal of order %s", o.ID)
+		}
+	}
+	return nil
+}
+
+// GetStarCertificate returns the latest certificate of an auto-renewal order.
+func (o *Order) GetStarCertificate(ctx context.Context, db DB) (*Certificate, error) {
+	switch {
+	case !o.isStar():
+		return nil, NewError(ErrorMalformedType, "order %s is not an auto-renewal order", o.ID)
+	case o.Status == StatusCanceled:
+		return nil, NewError(ErrorAutoRenewalCanceledType, "auto-renewal of order %s has been canceled", o.ID)
+	case !clock.Now().Before(o.AutoRenewal.EndDate):
+		return nil, NewError(ErrorAutoRenewalExpiredType, "auto-renewal of order %s has expired", o.ID)
+	case o.Status != StatusValid || o.CertificateID == "":
+		return nil, NewError(ErrorOrderNotReadyType, "order %s does not have a certificate", o.ID)
+	}
+	cert, err := db.GetCertificate(ctx, o.CertificateID)
+	if err != nil {
+		return nil, WrapErrorISE(err, "error retrieving certificate of order %s", o.ID)
+	}
+	return cert, nil
+}
+
+// RenewStarOrders signs the new certificates of the auto-renewal orders whose
+// renewal time has passed. The certificates that cannot be signed are
+// retried later, errors are logged.
+func RenewStarOrders(ctx context.Context, db DB, auth CertificateAuthority) error {
+	now := clock.Now()
+	orders, err := db.GetStarOrders(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, s := range orders {
+		if err := renewStarOrder(ctx, db, auth, s, now); err != nil {
+			log.Printf("error renewing certificate of order %s: %v", s.OrderID, err)
+			s.NextRenewalAt = now.Add(starRenewalRetry)
+			if err := db.UpdateStarOrder(ctx, s, StarOrderActive); err != nil && !IsErrConflict(err) {
+				log.Printf("error updating auto-renewal of order %s: %v", s.OrderID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// renewStarOrder signs the next certificate of an auto-renewal order with the
+// CSR of its finalization. The CSR and the sign options are validated again,
+// the provisioner might have changed since the last renewal.
+func renewStarOrder(ctx context.Context, db DB, auth CertificateAuthority, s *StarOrder, now time.Time) error {
+	o, err := db.GetOrder(ctx, s.OrderID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case o.Status == StatusCanceled:
+		return finishStarOrder(ctx, db, s, StarOrderCanceled)
+	case o.Status != StatusValid || !o.isStar() || !now.Before(o.AutoRenewal.EndDate):
+		return finishStarOrder(ctx, db, s, StarOrderFinished)
+	}
+
+	p, err := loadProvisionerByID(ctx, s.ProvisionerID)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.ParseCertificateRequest(s.CSR)
+	if err != nil {
+		return errors.Wrap(err, "error parsing csr")
+	}
+
+	ctx = NewProvisionerContext(ctx, p)
+	ctx = provisioner.NewContextWithMethod(ctx, provisioner.SignMethod)
+	csr, signOps, err := o.signOptions(ctx, db, csr, p)
+	if err != nil {
+		return err
+	}
+	cert, err := o.signCertificate(ctx, db, csr, auth, signOps)
+	if err != nil {
+		return err
+	}
+
+	// The order might have been canceled while the certificate was signed.
+	o.CertificateID = cert.ID
+	if err := db.UpdateOrderStatus(ctx, o, StatusValid); err != nil {
+		if IsErrConflict(err) {
+			return finishStarOrder(ctx, db, s, StarOrderCanceled)
+		}
+		return err
+	}
+
+	if next, ok := o.nextRenewal(now, cert.Leaf); ok {
+		s.NextRenewalAt = next
+	} else {
+		s.Status = StarOrderFinished
+	}
+	if err := db.UpdateStarOrder(ctx, s, StarOrderActive); err != nil && !IsErrConflict(err) {
+		return err
+	}
+	return nil
+}
+
+// finishStarOrder stops the renewals of an auto-renewal order.
+func finishStarOrder(ctx context.Context, db DB, s *StarOrder, status StarOrderStatus) error {
+	s.Status = status
+	if err := db.UpdateStarOrder(ctx, s, StarOrderActive); err != nil && !IsErrConflict(err) {
+		return err
+	}
+	return nil
+}
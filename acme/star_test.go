@@ -0,0 +1,290 @@
+package acme
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func TestAutoRenewal_Window(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := &AutoRenewal{
+		StartDate:      now.Add(time.Hour),
+		EndDate:        now.Add(100 * time.Hour),
+		Lifetime:       int64((72 * time.Hour).Seconds()),
+		LifetimeAdjust: 60,
+	}
+	tests := []struct {
+		name                string
+		now                 time.Time
+		notBefore, notAfter time.Time
+	}{
+		{"before start", now, now.Add(time.Hour - time.Minute), now.Add(73 * time.Hour)},
+		{"after start", now.Add(2 * time.Hour), now.Add(2*time.Hour - time.Minute), now.Add(74 * time.Hour)},
+		{"end date", now.Add(50 * time.Hour), now.Add(50*time.Hour - time.Minute), now.Add(100 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notBefore, notAfter := a.Window(tt.now)
+			assert.Equal(t, tt.notBefore, notBefore)
+			assert.Equal(t, tt.notAfter, notAfter)
+		})
+	}
+}
+
+func newStarOrder(now time.Time) *Order {
+	return &Order{
+		ID:               "oID",
+		AccountID:        "accID",
+		ProvisionerID:    "provID",
+		Status:           StatusReady,
+		ExpiresAt:        now.Add(5 * time.Minute),
+		AuthorizationIDs: []string{"a"},
+		Identifiers:      []Identifier{{Type: "dns", Value: "foo.internal"}},
+		AutoRenewal: &AutoRenewal{
+			StartDate: now,
+			EndDate:   now.Add(30 * 24 * time.Hour),
+			Lifetime:  int64((72 * time.Hour).Seconds()),
+		},
+	}
+}
+
+func TestOrder_Finalize_star(t *testing.T) {
+	now := clock.Now()
+	o := newStarOrder(now)
+	csr := newApprovalCSR(t, "foo.internal")
+
+	var star *StarOrder
+	db := &MockDB{
+		MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+			return &Authorization{ID: id, Status: StatusValid}, nil
+		},
+		MockUpdateOrderStatus: func(ctx context.Context, updo *Order, from Status) error {
+			return nil
+		},
+		MockCreateCertificate: func(ctx context.Context, cert *Certificate) error {
+			cert.ID = "certID"
+			return nil
+		},
+		MockUpdateOrder: func(ctx context.Context, updo *Order) error {
+			assert.Equal(t, StatusValid, updo.Status)
+			return nil
+		},
+		MockCreateStarOrder: func(ctx context.Context, s *StarOrder) error {
+			star = s
+			return nil
+		},
+	}
+	ca := &mockSignAuth{
+		sign: func(_ *x509.CertificateRequest, opts provisioner.SignOptions, _ ...provisioner.SignOption) ([]*x509.Certificate, error) {
+			assert.WithinDuration(t, now, opts.NotBefore.Time(), 5*time.Second)
+			assert.Equal(t, opts.NotBefore.Time().Add(72*time.Hour), opts.NotAfter.Time())
+			return []*x509.Certificate{
+				{SerialNumber: big.NewInt(1), NotBefore: opts.NotBefore.Time(), NotAfter: opts.NotAfter.Time()},
+				{SerialNumber: big.NewInt(2)},
+			}, nil
+		},
+	}
+	prov := newApprovalProvisioner(nil)
+
+	require.NoError(t, o.Finalize(context.Background(), db, csr, ca, prov))
+	assert.Equal(t, StatusValid, o.Status)
+	assert.Equal(t, "certID", o.CertificateID)
+	require.NotNil(t, star)
+	assert.Equal(t, "oID", star.OrderID)
+	assert.Equal(t, "provID", star.ProvisionerID)
+	assert.Equal(t, csr.Raw, star.CSR)
+	assert.Equal(t, StarOrderActive, star.Status)
+	assert.WithinDuration(t, now.Add(36*time.Hour), star.NextRenewalAt, 5*time.Second)
+}
+
+func TestOrder_Cancel(t *testing.T) {
+	now := clock.Now()
+	tests := []struct {
+		name       string
+		status     Status
+		star       bool
+		updateErr  error
+		wantStatus Status
+		wantErr    string
+	}{
+		{"ok", StatusValid, true, nil, StatusCanceled, ""},
+		{"ok/canceled", StatusCanceled, true, nil, StatusCanceled, ""},
+		{"ok/conflict", StatusValid, true, ErrConflict, StatusCanceled, ""},
+		{"fail/not-star", StatusValid, false, nil, StatusValid, "order oID is not an auto-renewal order"},
+		{"fail/not-valid", StatusPending, true, nil, StatusPending, "order oID is not valid"},
+		{"fail/update", StatusValid, true, errors.New("force"), StatusValid, "error updating order oID: force"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newStarOrder(now)
+			o.Status = tt.status
+			if !tt.star {
+				o.AutoRenewal = nil
+			}
+			var star *StarOrder
+			db := &MockDB{
+				MockUpdateOrderStatus: func(ctx context.Context, updo *Order, from Status) error {
+					assert.Equal(t, StatusValid, from)
+					assert.Equal(t, StatusCanceled, updo.Status)
+					return tt.updateErr
+				},
+				MockGetOrder: func(ctx context.Context, id string) (*Order, error) {
+					o := newStarOrder(now)
+					o.Status = StatusCanceled
+					return o, nil
+				},
+				MockGetStarOrder: func(ctx context.Context, orderID string) (*StarOrder, error) {
+					return &StarOrder{OrderID: orderID, Status: StarOrderActive}, nil
+				},
+				MockUpdateStarOrder: func(ctx context.Context, s *StarOrder, from StarOrderStatus) error {
+					assert.Equal(t, StarOrderActive, from)
+					star = s
+					return nil
+				},
+			}
+			err := o.Cancel(context.Background(), db)
+			assert.Equal(t, tt.wantStatus, o.Status)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tt.name == "ok" {
+				require.NotNil(t, star)
+				assert.Equal(t, StarOrderCanceled, star.Status)
+			}
+		})
+	}
+}
+
+func TestOrder_GetStarCertificate(t *testing.T) {
+	now := clock.Now()
+	cert := &Certificate{ID: "certID"}
+	db := &MockDB{
+		MockGetCertificate: func(ctx context.Context, id string) (*Certificate, error) {
+			assert.Equal(t, "certID", id)
+			return cert, nil
+		},
+	}
+	tests := []struct {
+		name    string
+		modify  func(o *Order)
+		wantErr *Error
+	}{
+		{"ok", func(o *Order) {}, nil},
+		{"fail/canceled", func(o *Order) { o.Status = StatusCanceled }, NewError(ErrorAutoRenewalCanceledType, "auto-renewal of order oID has been canceled")},
+		{"fail/expired", func(o *Order) { o.AutoRenewal.EndDate = now.Add(-time.Minute) }, NewError(ErrorAutoRenewalExpiredType, "auto-renewal of order oID has expired")},
+		{"fail/not-ready", func(o *Order) { o.Status, o.CertificateID = StatusReady, "" }, NewError(ErrorOrderNotReadyType, "order oID does not have a certificate")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newStarOrder(now)
+			o.Status, o.CertificateID = StatusValid, "certID"
+			tt.modify(o)
+			got, err := o.GetStarCertificate(context.Background(), db)
+			if tt.wantErr != nil {
+				var acmeErr *Error
+				require.True(t, errors.As(err, &acmeErr))
+				assert.Equal(t, tt.wantErr.Type, acmeErr.Type)
+				assert.Equal(t, 403 == acmeErr.Status, tt.wantErr.Status == 403)
+				assert.Equal(t, tt.wantErr.Detail, acmeErr.Detail)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, cert, got)
+		})
+	}
+}
+
+func TestRenewStarOrders(t *testing.T) {
+	now := clock.Now()
+	csr := newApprovalCSR(t, "foo.internal")
+	prov := newApprovalProvisioner(nil)
+
+	tmp := loadProvisionerByID
+	t.Cleanup(func() { loadProvisionerByID = tmp })
+	loadProvisionerByID = func(_ context.Context, id string) (Provisioner, error) {
+		if id != "provID" {
+			return nil, errors.New("provisioner not found")
+		}
+		return prov, nil
+	}
+
+	orders := map[string]*Order{}
+	for _, id := range []string{"renew", "canceled", "expired", "last", "failed"} {
+		o := newStarOrder(now.Add(-48 * time.Hour))
+		o.ID, o.Status, o.CertificateID = id, StatusValid, "oldCertID"
+		orders[id] = o
+	}
+	orders["canceled"].Status = StatusCanceled
+	orders["expired"].AutoRenewal.EndDate = now.Add(-time.Minute)
+	orders["last"].AutoRenewal.EndDate = now.Add(time.Hour)
+
+	updates := map[string]*StarOrder{}
+	var signed []string
+	db := &MockDB{
+		MockGetStarOrders: func(ctx context.Context, before time.Time) ([]*StarOrder, error) {
+			assert.Equal(t, now.Truncate(time.Second), before.Truncate(time.Second))
+			var list []*StarOrder
+			for _, id := range []string{"renew", "canceled", "expired", "last", "failed"} {
+				provID := "provID"
+				if id == "failed" {
+					provID = "otherID"
+				}
+				list = append(list, &StarOrder{OrderID: id, ProvisionerID: provID, CSR: csr.Raw, Status: StarOrderActive})
+			}
+			return list, nil
+		},
+		MockGetOrder: func(ctx context.Context, id string) (*Order, error) {
+			return orders[id], nil
+		},
+		MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+			return &Authorization{ID: id, Status: StatusValid}, nil
+		},
+		MockCreateCertificate: func(ctx context.Context, cert *Certificate) error {
+			cert.ID = "newCertID"
+			return nil
+		},
+		MockUpdateOrderStatus: func(ctx context.Context, updo *Order, from Status) error {
+			assert.Equal(t, StatusValid, from)
+			assert.Equal(t, StatusValid, updo.Status)
+			assert.Equal(t, "newCertID", updo.CertificateID)
+			signed = append(signed, updo.ID)
+			return nil
+		},
+		MockUpdateStarOrder: func(ctx context.Context, s *StarOrder, from StarOrderStatus) error {
+			assert.Equal(t, StarOrderActive, from)
+			updates[s.OrderID] = s
+			return nil
+		},
+	}
+	ca := &mockSignAuth{
+		sign: func(_ *x509.CertificateRequest, opts provisioner.SignOptions, _ ...provisioner.SignOption) ([]*x509.Certificate, error) {
+			assert.WithinDuration(t, now, opts.NotBefore.Time(), 5*time.Second)
+			return []*x509.Certificate{
+				{SerialNumber: big.NewInt(1), NotBefore: opts.NotBefore.Time(), NotAfter: opts.NotAfter.Time()},
+				{SerialNumber: big.NewInt(2)},
+			}, nil
+		},
+	}
+
+	require.NoError(t, RenewStarOrders(context.Background(), db, ca))
+	assert.Equal(t, []string{"renew", "last"}, signed)
+
+	assert.Equal(t, StarOrderActive, updates["renew"].Status)
+	assert.WithinDuration(t, now.Add(36*time.Hour), updates["renew"].NextRenewalAt, 5*time.Second)
+	assert.Equal(t, StarOrderCanceled, updates["canceled"].Status)
+	assert.Equal(t, StarOrderFinished, updates["expired"].Status)
+	assert.Equal(t, StarOrderFinished, updates["last"].Status)
+	assert.Equal(t, StarOrderActive, updates["failed"].Status)
+	assert.WithinDuration(t, now.Add(starRenewalRetry), updates["failed"].NextRenewalAt, 5*time.Second)
+}
@@ -14,7 +14,12 @@ var (
 	StatusDeactivated = Status("deactivated")
 	// StatusReady -- ready; e.g. for an Order that is ready to be finalized.
 	StatusReady = Status("ready")
+	// StatusProcessing -- processing; e.g. for an Order whose certificate is
+	// being issued.
+	StatusProcessing = Status("processing")
+	// StatusCanceled -- canceled; e.g. for an auto-renewal Order that has been
+	// canceled by the client.
+	StatusCanceled = Status("canceled")
 	//statusExpired     = "expired"
 	//statusActive      = "active"
-	//statusProcessing  = "processing"
 )
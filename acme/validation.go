@@ -0,0 +1,116 @@
+package acme
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// backgroundValidations are the challenges validated in the background by this
+// instance.
+var backgroundValidations = &validationWorker{
+	running: make(map[string]struct{}),
+}
+
+// validationWorker runs the validations of the challenges in the background,
+// only one for each challenge.
+type validationWorker struct {
+	mu      sync.Mutex
+	running map[string]struct{}
+	wg      sync.WaitGroup
+}
+
+func (w *validationWorker) start(ctx context.Context, ch *Challenge, db DB, jwk *jose.JSONWebKey, opts *provisioner.ACMEValidationOptions) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.running[ch.ID]; ok {
+		return
+	}
+	w.running[ch.ID] = struct{}{}
+	w.wg.Add(1)
+
+	// The validation uses a copy of the challenge, and it continues after the
+	// request is done.
+	c := *ch
+	ctx = valuesContext{ctx}
+	go func() {
+		defer w.done(c.ID)
+		c.validateWithRetries(ctx, db, jwk, opts)
+	}()
+}
+
+func (w *validationWorker) done(id string) {
+	w.mu.Lock()
+	delete(w.running, id)
+	w.mu.Unlock()
+	w.wg.Done()
+}
+
+// valuesContext is a context with the values of the parent that is never
+// canceled.
+type valuesContext struct {
+	context.Context
+}
+
+func (valuesContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (valuesContext) Done() <-chan struct{}       { return nil }
+func (valuesContext) Err() error                  { return nil }
+
+// ValidateInBackground starts the validation of the challenge in the
+// background using the given options. A pending challenge moves to the
+// processing status, and the validation of a processing challenge is resumed
+// if it's not running, for example after a restart. It returns the time the
+// client should wait before checking the challenge again, zero if the
+// challenge is not processing.
+//
+// The device-attest-01 and oidc-01 challenges do not depend on the network,
+// and the email-reply-00 challenges are validated when the reply is
+// received, so they are validated in the request like in Validate.
+func (ch *Challenge) ValidateInBackground(ctx context.Context, db DB, jwk *jose.JSONWebKey, payload []byte, opts *provisioner.ACMEValidationOptions) (time.Duration, error) {
+	switch {
+	case ch.Type == DEVICEATTEST01, ch.Type == OIDC01, ch.Type == EMAILREPLY00:
+		return 0, ch.Validate(ctx, db, jwk, payload)
+	case ch.Status == StatusPending:
+		ch.Status = StatusProcessing
+		if err := db.UpdateChallenge(ctx, ch); err != nil {
+			return 0, WrapErrorISE(err, "error updating challenge")
+		}
+	case ch.Status != StatusProcessing:
+		return 0, nil
+	}
+
+	backgroundValidations.start(ctx, ch, db, jwk, opts)
+	return opts.GetInterval(), nil
+}
+
+// validateWithRetries validates a processing challenge until it is valid or
+// invalid. The failed attempts are retried, and the challenge is marked as
+// invalid, with the error of the last attempt, when all of them fail.
+func (ch *Challenge) validateWithRetries(ctx context.Context, db DB, jwk *jose.JSONWebKey, opts *provisioner.ACMEValidationOptions) {
+	attempts := opts.GetAttempts()
+	for i := 1; i <= attempts; i++ {
+		// Errors not stored in the challenge, e.g. database errors, are also
+		// retried.
+		_ = ch.validate(ctx, db, jwk, nil)
+		if ch.Status != StatusProcessing {
+			if ch.Status == StatusInvalid {
+				ch.recordFailedValidation(ctx, db)
+			}
+			return
+		}
+		if i < attempts {
+			time.Sleep(opts.GetRetryInterval(i))
+		}
+	}
+
+	ch.Status = StatusInvalid
+	if ch.Error == nil {
+		ch.Error = NewError(ErrorServerInternalType, "error validating challenge after %d attempts", attempts)
+	}
+	_ = db.UpdateChallenge(ctx, ch)
+	ch.recordFailedValidation(ctx, db)
+}
@@ -0,0 +1,167 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// recordingValidationDB records the statuses of the challenge updates.
+type recordingValidationDB struct {
+	MockDB
+	mu       sync.Mutex
+	statuses []Status
+	last     *Challenge
+}
+
+func (db *recordingValidationDB) UpdateChallenge(ctx context.Context, ch *Challenge) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.statuses = append(db.statuses, ch.Status)
+	c := *ch
+	db.last = &c
+	return nil
+}
+
+func TestChallenge_ValidateInBackground(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	require.NoError(t, err)
+	keyAuth, err := KeyAuthorization("token", jwk)
+	require.NoError(t, err)
+
+	opts := &provisioner.ACMEValidationOptions{
+		Attempts: 3,
+		Interval: &provisioner.Duration{Duration: time.Millisecond},
+	}
+	newContext := func(get func(*int) (*http.Response, error)) (context.Context, *int) {
+		calls := new(int)
+		return NewClientContext(context.Background(), &mockClient{
+			get: func(string) (*http.Response, error) {
+				*calls++
+				return get(calls)
+			},
+		}), calls
+	}
+	ok := func() (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(keyAuth)),
+		}, nil
+	}
+
+	t.Run("ok/retried", func(t *testing.T) {
+		ctx, calls := newContext(func(calls *int) (*http.Response, error) {
+			if *calls < 3 {
+				return nil, errors.New("connection refused")
+			}
+			return ok()
+		})
+		db := &recordingValidationDB{}
+		ch := &Challenge{ID: "ok-retried", Type: HTTP01, Status: StatusPending, Value: "example.com", Token: "token"}
+		retryAfter, err := ch.ValidateInBackground(ctx, db, jwk, nil, opts)
+		require.NoError(t, err)
+		assert.Equal(t, time.Millisecond, retryAfter)
+		assert.Equal(t, StatusProcessing, ch.Status)
+
+		backgroundValidations.wg.Wait()
+		assert.Equal(t, 3, *calls)
+		assert.Equal(t, []Status{StatusProcessing, StatusProcessing, StatusProcessing, StatusValid}, db.statuses)
+		assert.Nil(t, db.last.Error)
+		assert.NotEmpty(t, db.last.ValidatedAt)
+	})
+
+	t.Run("ok/exhausted", func(t *testing.T) {
+		ctx, calls := newContext(func(*int) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		})
+		db := &recordingValidationDB{}
+		ch := &Challenge{ID: "ok-exhausted", Type: HTTP01, Status: StatusPending, Value: "example.com", Token: "token"}
+		_, err := ch.ValidateInBackground(ctx, db, jwk, nil, opts)
+		require.NoError(t, err)
+
+		backgroundValidations.wg.Wait()
+		assert.Equal(t, 3, *calls)
+		assert.Equal(t, []Status{StatusProcessing, StatusProcessing, StatusProcessing, StatusProcessing, StatusInvalid}, db.statuses)
+		if assert.NotNil(t, db.last.Error) {
+			assert.Equal(t, NewError(ErrorConnectionType, "").Type, db.last.Error.Type)
+		}
+	})
+
+	t.Run("ok/rejected", func(t *testing.T) {
+		ctx, calls := newContext(func(*int) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader("foo")),
+			}, nil
+		})
+		db := &recordingValidationDB{}
+		ch := &Challenge{ID: "ok-rejected", Type: HTTP01, Status: StatusPending, Value: "example.com", Token: "token"}
+		_, err := ch.ValidateInBackground(ctx, db, jwk, nil, opts)
+		require.NoError(t, err)
+
+		backgroundValidations.wg.Wait()
+		assert.Equal(t, 1, *calls)
+		assert.Equal(t, []Status{StatusProcessing, StatusInvalid}, db.statuses)
+	})
+
+	t.Run("ok/once", func(t *testing.T) {
+		release := make(chan struct{})
+		ctx, calls := newContext(func(*int) (*http.Response, error) {
+			<-release
+			return ok()
+		})
+		db := &recordingValidationDB{}
+		ch := &Challenge{ID: "ok-once", Type: HTTP01, Status: StatusPending, Value: "example.com", Token: "token"}
+		_, err := ch.ValidateInBackground(ctx, db, jwk, nil, opts)
+		require.NoError(t, err)
+
+		// A processing challenge does not start a new validation while the
+		// first one is running.
+		retryAfter, err := (&Challenge{ID: "ok-once", Type: HTTP01, Status: StatusProcessing, Value: "example.com", Token: "token"}).ValidateInBackground(ctx, db, jwk, nil, opts)
+		require.NoError(t, err)
+		assert.Equal(t, time.Millisecond, retryAfter)
+
+		close(release)
+		backgroundValidations.wg.Wait()
+		assert.Equal(t, 1, *calls)
+		assert.Equal(t, []Status{StatusProcessing, StatusValid}, db.statuses)
+	})
+
+	t.Run("ok/valid", func(t *testing.T) {
+		db := &recordingValidationDB{}
+		ch := &Challenge{ID: "ok-valid", Type: HTTP01, Status: StatusValid}
+		retryAfter, err := ch.ValidateInBackground(context.Background(), db, jwk, nil, opts)
+		require.NoError(t, err)
+		assert.Zero(t, retryAfter)
+		assert.Empty(t, db.statuses)
+	})
+
+	t.Run("fail/db.UpdateChallenge", func(t *testing.T) {
+		db := &MockDB{MockError: errors.New("force")}
+		ch := &Challenge{ID: "fail", Type: HTTP01, Status: StatusPending}
+		_, err := ch.ValidateInBackground(context.Background(), db, jwk, nil, opts)
+		assert.ErrorContains(t, err, "error updating challenge: force")
+	})
+}
+
+func Test_valuesContext(t *testing.T) {
+	type key struct{}
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	cancel()
+
+	ctx := valuesContext{parent}
+	assert.NoError(t, ctx.Err())
+	assert.Nil(t, ctx.Done())
+	assert.Equal(t, "value", ctx.Value(key{}))
+}
@@ -25,11 +25,16 @@ import (
 	"golang.org/x/crypto/ssh"
 
 	"github.com/smallstep/certificates/api/log"
+	"github.com/smallstep/certificates/api/pagination"
 	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/attestation"
 	"github.com/smallstep/certificates/authority"
 	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/ocspcache"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/revocation"
 	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/idempotency"
 	"github.com/smallstep/certificates/logging"
 )
 
@@ -40,8 +45,10 @@ type Authority interface {
 	Authorize(ctx context.Context, ott string) ([]provisioner.SignOption, error)
 	AuthorizeRenewToken(ctx context.Context, ott string) (*x509.Certificate, error)
 	GetTLSOptions() *config.TLSOptions
+	GetBatchSignConfig() *config.BatchSignConfig
 	Root(shasum string) (*x509.Certificate, error)
-	Sign(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
+	SignWithContext(ctx context.Context, cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
+	SignDryRun(ctx context.Context, cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]byte, error)
 	Renew(peer *x509.Certificate) ([]*x509.Certificate, error)
 	RenewContext(ctx context.Context, peer *x509.Certificate, pk crypto.PublicKey) ([]*x509.Certificate, error)
 	Rekey(peer *x509.Certificate, pk crypto.PublicKey) ([]*x509.Certificate, error)
@@ -54,6 +61,19 @@ type Authority interface {
 	GetFederation() ([]*x509.Certificate, error)
 	Version() authority.Version
 	GetCertificateRevocationList() ([]byte, error)
+	GetIssuerCertificate() (*x509.Certificate, error)
+	GetCRLSignerCertificate() (*x509.Certificate, error)
+	GetRevocationEvents(ctx context.Context, since uint64) (*revocation.Page, error)
+	Timestamp(req []byte) ([]byte, error)
+	RespondOCSP(req []byte) (*ocspcache.Response, error)
+	GetDecommissionStatement() (string, error)
+	SendSMIMECode(ctx context.Context, email string) error
+	SignSMIME(ctx context.Context, csr *x509.CertificateRequest, email, code, token string) ([]*x509.Certificate, error)
+	SignAttested(ctx context.Context, csr *x509.CertificateRequest, provisionerName string, st *attestation.Statement) ([]*x509.Certificate, error)
+	Enroll(ctx context.Context, token, commonName string, sans []string, opts provisioner.SignOptions) (crypto.Signer, []*x509.Certificate, error)
+	GetCAExpirations() []*authority.CAExpiration
+	IsResponseSigned(endpoint string) bool
+	SignResponse(payload []byte) (string, error)
 }
 
 // mustAuthority will be replaced on unit tests.
@@ -217,6 +237,9 @@ type VersionResponse struct {
 // HealthResponse is the response object that returns the health of the server.
 type HealthResponse struct {
 	Status string `json:"status"`
+	// Expiring are the root and intermediate certificates that have reached
+	// one of the notification thresholds of their expiration.
+	Expiring []*authority.CAExpiration `json:"expiring,omitempty"`
 }
 
 // RootResponse is the response object that returns the PEM of a root certificate.
@@ -299,21 +322,33 @@ func Route(r Router) {
 	r.MethodFunc("GET", "/version", Version)
 	r.MethodFunc("GET", "/health", Health)
 	r.MethodFunc("GET", "/root/{sha}", Root)
-	r.MethodFunc("POST", "/sign", Sign)
-	r.MethodFunc("POST", "/renew", Renew)
-	r.MethodFunc("POST", "/rekey", Rekey)
-	r.MethodFunc("POST", "/revoke", Revoke)
+	r.MethodFunc("POST", "/sign", idempotency.Middleware(Sign))
+	r.MethodFunc("POST", "/sign/batch", idempotency.Middleware(BatchSign))
+	r.MethodFunc("POST", "/renew", idempotency.Middleware(Renew))
+	r.MethodFunc("POST", "/rekey", idempotency.Middleware(Rekey))
+	r.MethodFunc("POST", "/revoke", idempotency.Middleware(Revoke))
 	r.MethodFunc("GET", "/crl", CRL)
+	r.MethodFunc("GET", "/crl/signer", CRLSignerCertificate)
+	r.MethodFunc("GET", "/issuer", IssuerCertificate)
+	r.MethodFunc("POST", "/ocsp", OCSP)
+	r.MethodFunc("GET", "/ocsp/*", OCSP)
+	r.MethodFunc("GET", "/decommission", DecommissionStatement)
+	r.MethodFunc("GET", "/revocations/events", RevocationEvents)
+	r.MethodFunc("POST", "/tsa", Timestamp)
+	r.MethodFunc("POST", "/smime/code", SMIMECode)
+	r.MethodFunc("POST", "/smime/sign", idempotency.Middleware(SMIMESign))
+	r.MethodFunc("POST", "/attest", idempotency.Middleware(AttestedSign))
+	r.MethodFunc("POST", "/enroll", Enroll)
 	r.MethodFunc("GET", "/provisioners", Provisioners)
 	r.MethodFunc("GET", "/provisioners/{kid}/encrypted-key", ProvisionerKey)
 	r.MethodFunc("GET", "/roots", Roots)
 	r.MethodFunc("GET", "/roots.pem", RootsPEM)
 	r.MethodFunc("GET", "/federation", Federation)
 	// SSH CA
-	r.MethodFunc("POST", "/ssh/sign", SSHSign)
-	r.MethodFunc("POST", "/ssh/renew", SSHRenew)
-	r.MethodFunc("POST", "/ssh/revoke", SSHRevoke)
-	r.MethodFunc("POST", "/ssh/rekey", SSHRekey)
+	r.MethodFunc("POST", "/ssh/sign", idempotency.Middleware(SSHSign))
+	r.MethodFunc("POST", "/ssh/renew", idempotency.Middleware(SSHRenew))
+	r.MethodFunc("POST", "/ssh/revoke", idempotency.Middleware(SSHRevoke))
+	r.MethodFunc("POST", "/ssh/rekey", idempotency.Middleware(SSHRekey))
 	r.MethodFunc("GET", "/ssh/roots", SSHRoots)
 	r.MethodFunc("GET", "/ssh/federation", SSHFederation)
 	r.MethodFunc("POST", "/ssh/config", SSHConfig)
@@ -323,8 +358,8 @@ func Route(r Router) {
 	r.MethodFunc("POST", "/ssh/bastion", SSHBastion)
 
 	// For compatibility with old code:
-	r.MethodFunc("POST", "/re-sign", Renew)
-	r.MethodFunc("POST", "/sign-ssh", SSHSign)
+	r.MethodFunc("POST", "/re-sign", idempotency.Middleware(Renew))
+	r.MethodFunc("POST", "/sign-ssh", idempotency.Middleware(SSHSign))
 	r.MethodFunc("GET", "/ssh/get-hosts", SSHGetHosts)
 }
 
@@ -337,9 +372,16 @@ func Version(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// Health is an HTTP handler that returns the status of the server.
-func Health(w http.ResponseWriter, _ *http.Request) {
-	render.JSON(w, HealthResponse{Status: "ok"})
+// Health is an HTTP handler that returns the status of the server, and the CA
+// certificates that are close to their expiration.
+func Health(w http.ResponseWriter, r *http.Request) {
+	var expiring []*authority.CAExpiration
+	for _, e := range mustAuthority(r.Context()).GetCAExpirations() {
+		if e.Threshold != nil {
+			expiring = append(expiring, e)
+		}
+	}
+	render.JSON(w, HealthResponse{Status: "ok", Expiring: expiring})
 }
 
 // Root is an HTTP handler that using the SHA256 from the URL, returns the root
@@ -367,22 +409,95 @@ func certChainToPEM(certChain []*x509.Certificate) []Certificate {
 
 // Provisioners returns the list of provisioners configured in the authority.
 func Provisioners(w http.ResponseWriter, r *http.Request) {
-	cursor, limit, err := ParseCursor(r)
+	opts, err := pagination.Parse(r, ProvisionerListFields, ProvisionerListFields)
 	if err != nil {
-		render.Error(w, err)
+		render.Error(w, paginationError(err))
 		return
 	}
 
-	p, next, err := mustAuthority(r.Context()).GetProvisioners(cursor, limit)
+	p, next, err := ListProvisioners(mustAuthority(r.Context()).GetProvisioners, opts)
 	if err != nil {
-		render.Error(w, errs.InternalServerErr(err))
+		render.Error(w, err)
 		return
 	}
 
-	render.JSON(w, &ProvisionersResponse{
-		Provisioners: p,
+	pagination.SetLinkHeader(w, r, next)
+	renderSigned(w, r, config.ResponseSigningProvisioners, &ProvisionersResponse{
+		Provisioners: publishedProvisioners(p),
 		NextCursor:   next,
+	}, http.StatusOK)
+}
+
+// publishedProvisioners returns the provisioners with the credentials
+// published to the clients. The client secrets of the OIDC provisioners are
+// replaced by the ones of their active credentials.
+func publishedProvisioners(list provisioner.List) provisioner.List {
+	published := make(provisioner.List, len(list))
+	for i, p := range list {
+		published[i] = p
+		if o, ok := p.(*provisioner.OIDC); ok {
+			if secret := o.GetClientSecret(); secret != o.ClientSecret {
+				oc := *o
+				oc.ClientSecret = secret
+				published[i] = &oc
+			}
+		}
+	}
+	return published
+}
+
+// ProvisionerListFields are the fields that can be used to sort and filter
+// the list of provisioners.
+var ProvisionerListFields = []string{"name", "type"}
+
+// ListProvisioners returns a page of the provisioners returned by the given
+// function. The provisioners are paginated by the given function unless the
+// list is sorted or filtered, in that case all of them are loaded and the
+// name of the provisioner is used as the cursor.
+func ListProvisioners(fn func(cursor string, limit int) (provisioner.List, string, error), opts *pagination.Options) (provisioner.List, string, error) {
+	if opts.IsDefault() {
+		p, next, err := fn(opts.Cursor, opts.Limit)
+		if err != nil {
+			return nil, "", errs.InternalServerErr(err)
+		}
+		return p, next, nil
+	}
+
+	var all provisioner.List
+	for cursor := ""; ; {
+		p, next, err := fn(cursor, provisioner.DefaultProvisionersMax)
+		if err != nil {
+			return nil, "", errs.InternalServerErr(err)
+		}
+		all = append(all, p...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	all = pagination.Filter(all, func(p provisioner.Interface) bool {
+		return opts.Match("name", p.GetName()) && opts.Match("type", p.GetType().String())
+	})
+	pagination.Sort(all, opts, map[string]func(a, b provisioner.Interface) bool{
+		"name": func(a, b provisioner.Interface) bool { return a.GetName() < b.GetName() },
+		"type": func(a, b provisioner.Interface) bool { return a.GetType().String() < b.GetType().String() },
 	})
+	p, next, err := pagination.Paginate(all, opts, provisioner.Interface.GetName)
+	if err != nil {
+		return nil, "", paginationError(err)
+	}
+	return p, next, nil
+}
+
+// paginationError returns a bad request error with the message of the given
+// pagination error.
+func paginationError(err error) error {
+	var perr *pagination.Error
+	if errors.As(err, &perr) {
+		return errs.BadRequestErr(err, "%s", perr.Message)
+	}
+	return errs.BadRequestErr(err, "%s", err)
 }
 
 // ProvisionerKey returns the encrypted key of a provisioner by it's key id.
@@ -410,7 +525,7 @@ func Roots(w http.ResponseWriter, r *http.Request) {
 		certs[i] = Certificate{roots[i]}
 	}
 
-	render.JSONStatus(w, &RootsResponse{
+	renderSigned(w, r, config.ResponseSigningRoots, &RootsResponse{
 		Certificates: certs,
 	}, http.StatusCreated)
 }
@@ -451,11 +566,60 @@ func Federation(w http.ResponseWriter, r *http.Request) {
 		certs[i] = Certificate{federated[i]}
 	}
 
-	render.JSONStatus(w, &FederationResponse{
+	renderSigned(w, r, config.ResponseSigningFederation, &FederationResponse{
 		Certificates: certs,
 	}, http.StatusCreated)
 }
 
+// JOSEContentType is the content type of the signed responses, a JWS in
+// compact serialization.
+const JOSEContentType = "application/jose"
+
+// renderSigned writes the JSON response, or the response signed as a JWS if
+// the signing of the endpoint is enabled and the client accepts it.
+func renderSigned(w http.ResponseWriter, r *http.Request, endpoint string, v interface{}, status int) {
+	auth := mustAuthority(r.Context())
+	if !auth.IsResponseSigned(endpoint) {
+		render.JSONStatus(w, v, status)
+		return
+	}
+
+	// Caches must keep the signed and the unsigned responses apart.
+	w.Header().Add("Vary", "Accept")
+	if !acceptsJOSE(r) {
+		render.JSONStatus(w, v, status)
+		return
+	}
+
+	payload, err := json.Marshal(v)
+	if err != nil {
+		render.Error(w, errs.InternalServerErr(err))
+		return
+	}
+	jws, err := auth.SignResponse(payload)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", JOSEContentType)
+	w.WriteHeader(status)
+	if _, err := w.Write([]byte(jws)); err != nil {
+		log.Error(w, err)
+	}
+}
+
+// acceptsJOSE returns true if the request accepts signed responses.
+func acceptsJOSE(r *http.Request) bool {
+	for _, h := range r.Header.Values("Accept") {
+		for _, s := range strings.Split(h, ",") {
+			if mt, _, _ := strings.Cut(s, ";"); strings.EqualFold(strings.TrimSpace(mt), JOSEContentType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 var oidStepProvisioner = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37476, 9000, 64, 1}
 
 type stepProvisioner struct {
@@ -21,11 +21,13 @@ import (
 	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/go-chi/chi"
 	"github.com/pkg/errors"
 	sassert "github.com/stretchr/testify/assert"
@@ -37,8 +39,13 @@ import (
 
 	"github.com/smallstep/assert"
 
+	"github.com/smallstep/certificates/api/pagination"
+	"github.com/smallstep/certificates/attestation"
 	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/ocspcache"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/revocation"
 	"github.com/smallstep/certificates/errs"
 	"github.com/smallstep/certificates/logging"
 	"github.com/smallstep/certificates/templates"
@@ -191,8 +198,10 @@ type mockAuthority struct {
 	authorize                    func(ctx context.Context, ott string) ([]provisioner.SignOption, error)
 	authorizeRenewToken          func(ctx context.Context, ott string) (*x509.Certificate, error)
 	getTLSOptions                func() *authority.TLSOptions
+	getBatchSignConfig           func() *config.BatchSignConfig
 	root                         func(shasum string) (*x509.Certificate, error)
 	sign                         func(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
+	signDryRun                   func(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]byte, error)
 	renew                        func(cert *x509.Certificate) ([]*x509.Certificate, error)
 	rekey                        func(oldCert *x509.Certificate, pk crypto.PublicKey) ([]*x509.Certificate, error)
 	renewContext                 func(ctx context.Context, oldCert *x509.Certificate, pk crypto.PublicKey) ([]*x509.Certificate, error)
@@ -204,6 +213,19 @@ type mockAuthority struct {
 	getRoots                     func() ([]*x509.Certificate, error)
 	getFederation                func() ([]*x509.Certificate, error)
 	getCRL                       func() ([]byte, error)
+	getIssuerCertificate         func() (*x509.Certificate, error)
+	getCRLSignerCertificate      func() (*x509.Certificate, error)
+	getRevocationEvents          func(ctx context.Context, since uint64) (*revocation.Page, error)
+	timestamp                    func(req []byte) ([]byte, error)
+	respondOCSP                  func(req []byte) (*ocspcache.Response, error)
+	getDecommissionStatement     func() (string, error)
+	sendSMIMECode                func(ctx context.Context, email string) error
+	signSMIME                    func(ctx context.Context, csr *x509.CertificateRequest, email, code, token string) ([]*x509.Certificate, error)
+	signAttested                 func(ctx context.Context, csr *x509.CertificateRequest, provisionerName string, st *attestation.Statement) ([]*x509.Certificate, error)
+	enroll                       func(ctx context.Context, token, commonName string, sans []string, opts provisioner.SignOptions) (crypto.Signer, []*x509.Certificate, error)
+	getCAExpirations             func() []*authority.CAExpiration
+	isResponseSigned             func(endpoint string) bool
+	signResponse                 func(payload []byte) (string, error)
 	signSSH                      func(ctx context.Context, key ssh.PublicKey, opts provisioner.SignSSHOptions, signOpts ...provisioner.SignOption) (*ssh.Certificate, error)
 	signSSHAddUser               func(ctx context.Context, key ssh.PublicKey, cert *ssh.Certificate) (*ssh.Certificate, error)
 	renewSSH                     func(ctx context.Context, cert *ssh.Certificate) (*ssh.Certificate, error)
@@ -225,6 +247,106 @@ func (m *mockAuthority) GetCertificateRevocationList() ([]byte, error) {
 	return m.ret1.([]byte), m.err
 }
 
+func (m *mockAuthority) Timestamp(req []byte) ([]byte, error) {
+	if m.timestamp != nil {
+		return m.timestamp(req)
+	}
+
+	return m.ret1.([]byte), m.err
+}
+
+func (m *mockAuthority) RespondOCSP(req []byte) (*ocspcache.Response, error) {
+	if m.respondOCSP != nil {
+		return m.respondOCSP(req)
+	}
+
+	return m.ret1.(*ocspcache.Response), m.err
+}
+
+func (m *mockAuthority) GetDecommissionStatement() (string, error) {
+	if m.getDecommissionStatement != nil {
+		return m.getDecommissionStatement()
+	}
+	return m.ret1.(string), m.err
+}
+
+func (m *mockAuthority) SendSMIMECode(ctx context.Context, email string) error {
+	if m.sendSMIMECode != nil {
+		return m.sendSMIMECode(ctx, email)
+	}
+
+	return m.err
+}
+
+func (m *mockAuthority) SignSMIME(ctx context.Context, csr *x509.CertificateRequest, email, code, token string) ([]*x509.Certificate, error) {
+	if m.signSMIME != nil {
+		return m.signSMIME(ctx, csr, email, code, token)
+	}
+
+	return m.ret1.([]*x509.Certificate), m.err
+}
+
+func (m *mockAuthority) SignAttested(ctx context.Context, csr *x509.CertificateRequest, provisionerName string, st *attestation.Statement) ([]*x509.Certificate, error) {
+	if m.signAttested != nil {
+		return m.signAttested(ctx, csr, provisionerName, st)
+	}
+
+	return m.ret1.([]*x509.Certificate), m.err
+}
+
+func (m *mockAuthority) Enroll(ctx context.Context, token, commonName string, sans []string, opts provisioner.SignOptions) (crypto.Signer, []*x509.Certificate, error) {
+	if m.enroll != nil {
+		return m.enroll(ctx, token, commonName, sans, opts)
+	}
+
+	return m.ret1.(crypto.Signer), m.ret2.([]*x509.Certificate), m.err
+}
+
+func (m *mockAuthority) GetCAExpirations() []*authority.CAExpiration {
+	if m.getCAExpirations != nil {
+		return m.getCAExpirations()
+	}
+	return nil
+}
+
+func (m *mockAuthority) IsResponseSigned(endpoint string) bool {
+	if m.isResponseSigned != nil {
+		return m.isResponseSigned(endpoint)
+	}
+	return false
+}
+
+func (m *mockAuthority) SignResponse(payload []byte) (string, error) {
+	if m.signResponse != nil {
+		return m.signResponse(payload)
+	}
+	return "", m.err
+}
+
+func (m *mockAuthority) GetIssuerCertificate() (*x509.Certificate, error) {
+	if m.getIssuerCertificate != nil {
+		return m.getIssuerCertificate()
+	}
+
+	return m.ret1.(*x509.Certificate), m.err
+}
+
+func (m *mockAuthority) GetCRLSignerCertificate() (*x509.Certificate, error) {
+	if m.getCRLSignerCertificate != nil {
+		return m.getCRLSignerCertificate()
+	}
+
+	return m.ret1.(*x509.Certificate), m.err
+}
+
+func (m *mockAuthority) GetRevocationEvents(ctx context.Context, since uint64) (*revocation.Page, error) {
+	if m.getRevocationEvents != nil {
+		return m.getRevocationEvents(ctx, since)
+	}
+
+	return m.ret1.(*revocation.Page), m.err
+}
+
 // TODO: remove once Authorize is deprecated.
 func (m *mockAuthority) Authorize(ctx context.Context, ott string) ([]provisioner.SignOption, error) {
 	if m.authorize != nil {
@@ -247,6 +369,13 @@ func (m *mockAuthority) GetTLSOptions() *authority.TLSOptions {
 	return m.ret1.(*authority.TLSOptions)
 }
 
+func (m *mockAuthority) GetBatchSignConfig() *config.BatchSignConfig {
+	if m.getBatchSignConfig != nil {
+		return m.getBatchSignConfig()
+	}
+	return nil
+}
+
 func (m *mockAuthority) Root(shasum string) (*x509.Certificate, error) {
 	if m.root != nil {
 		return m.root(shasum)
@@ -254,13 +383,23 @@ func (m *mockAuthority) Root(shasum string) (*x509.Certificate, error) {
 	return m.ret1.(*x509.Certificate), m.err
 }
 
-func (m *mockAuthority) Sign(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+func (m *mockAuthority) SignWithContext(_ context.Context, cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
 	if m.sign != nil {
 		return m.sign(cr, opts, signOpts...)
 	}
 	return []*x509.Certificate{m.ret1.(*x509.Certificate), m.ret2.(*x509.Certificate)}, m.err
 }
 
+func (m *mockAuthority) SignDryRun(_ context.Context, cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]byte, error) {
+	if m.signDryRun != nil {
+		return m.signDryRun(cr, opts, signOpts...)
+	}
+	if crt, ok := m.ret1.(*x509.Certificate); ok && crt != nil {
+		return crt.RawTBSCertificate, m.err
+	}
+	return nil, m.err
+}
+
 func (m *mockAuthority) Renew(cert *x509.Certificate) ([]*x509.Certificate, error) {
 	if m.renew != nil {
 		return m.renew(cert)
@@ -638,15 +777,19 @@ func TestSignRequest_Validate(t *testing.T) {
 		OTT       string
 		NotBefore time.Time
 		NotAfter  time.Time
+		Validity  *provisioner.Duration
 	}
 	tests := []struct {
 		name   string
 		fields fields
 		err    error
 	}{
-		{"missing csr", fields{CertificateRequest{}, "foobarzar", time.Time{}, time.Time{}}, errors.New("missing csr")},
-		{"invalid csr", fields{CertificateRequest{bad}, "foobarzar", time.Time{}, time.Time{}}, errors.New("invalid csr")},
-		{"missing ott", fields{CertificateRequest{csr}, "", time.Time{}, time.Time{}}, errors.New("missing ott")},
+		{"missing csr", fields{CertificateRequest{}, "foobarzar", time.Time{}, time.Time{}, nil}, errors.New("missing csr")},
+		{"invalid csr", fields{CertificateRequest{bad}, "foobarzar", time.Time{}, time.Time{}, nil}, errors.New("invalid csr")},
+		{"missing ott", fields{CertificateRequest{csr}, "", time.Time{}, time.Time{}, nil}, errors.New("missing ott")},
+		{"invalid validity", fields{CertificateRequest{csr}, "foobarzar", time.Time{}, time.Time{}, &provisioner.Duration{}}, errors.New("validity must be greater than 0")},
+		{"validity with notAfter", fields{CertificateRequest{csr}, "foobarzar", time.Time{}, time.Now().Add(time.Hour), &provisioner.Duration{Duration: time.Hour}}, errors.New("validity cannot be used with notAfter")},
+		{"ok validity", fields{CertificateRequest{csr}, "foobarzar", time.Time{}, time.Time{}, &provisioner.Duration{Duration: time.Hour}}, nil},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -655,6 +798,7 @@ func TestSignRequest_Validate(t *testing.T) {
 				OTT:       tt.fields.OTT,
 				NotAfter:  NewTimeDuration(tt.fields.NotAfter),
 				NotBefore: NewTimeDuration(tt.fields.NotBefore),
+				Validity:  tt.fields.Validity,
 			}
 			if err := s.Validate(); err != nil {
 				if assert.NotNil(t, tt.err) {
@@ -831,6 +975,493 @@ func Test_CRLGeneration(t *testing.T) {
 	}
 }
 
+func Test_IssuerCertificate(t *testing.T) {
+	crt := parseCertificate(certPEM)
+	tests := []struct {
+		name        string
+		query       string
+		crt         *x509.Certificate
+		err         error
+		statusCode  int
+		contentType string
+		expected    []byte
+	}{
+		{"ok", "", crt, nil, http.StatusOK, "application/pkix-cert", crt.Raw},
+		{"ok/pem", "?pem", crt, nil, http.StatusOK, "application/x-pem-file", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})},
+		{"fail", "", nil, errs.NotFound("issuer certificate not found"), http.StatusNotFound, "application/json", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, &mockAuthority{ret1: tt.crt, err: tt.err})
+			w := httptest.NewRecorder()
+			IssuerCertificate(w, httptest.NewRequest("GET", "http://example.com/issuer"+tt.query, http.NoBody))
+			res := w.Result()
+
+			if res.StatusCode != tt.statusCode {
+				t.Errorf("IssuerCertificate StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+			}
+			if ct := res.Header.Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("IssuerCertificate Content-Type = %s, wants %s", ct, tt.contentType)
+			}
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Errorf("IssuerCertificate unexpected error = %v", err)
+			}
+			if tt.statusCode == 200 && !bytes.Equal(body, tt.expected) {
+				t.Errorf("IssuerCertificate body = %s, wants %s", body, tt.expected)
+			}
+		})
+	}
+}
+
+func Test_CRLSignerCertificate(t *testing.T) {
+	crt := parseCertificate(certPEM)
+	tests := []struct {
+		name       string
+		query      string
+		crt        *x509.Certificate
+		err        error
+		statusCode int
+		expected   []byte
+	}{
+		{"ok", "", crt, nil, http.StatusOK, crt.Raw},
+		{"ok/pem", "?pem", crt, nil, http.StatusOK, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})},
+		{"fail", "", nil, errs.NotFound("crl signer certificate not found"), http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, &mockAuthority{ret1: tt.crt, err: tt.err})
+			w := httptest.NewRecorder()
+			CRLSignerCertificate(w, httptest.NewRequest("GET", "http://example.com/crl/signer"+tt.query, http.NoBody))
+			res := w.Result()
+
+			if res.StatusCode != tt.statusCode {
+				t.Errorf("CRLSignerCertificate StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+			}
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Errorf("CRLSignerCertificate unexpected error = %v", err)
+			}
+			if tt.statusCode == 200 && !bytes.Equal(body, tt.expected) {
+				t.Errorf("CRLSignerCertificate body = %s, wants %s", body, tt.expected)
+			}
+		})
+	}
+}
+
+func Test_OCSP(t *testing.T) {
+	der := []byte("ocsp-request/?+")
+	now := time.Now().UTC().Truncate(time.Second)
+	resp := &ocspcache.Response{
+		SerialNumber: "1234",
+		Response:     []byte("ocsp-response"),
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(time.Hour),
+	}
+	newGetRequest := func(s string) *http.Request {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("*", s)
+		req := httptest.NewRequest("GET", "http://example.com/ocsp/request", http.NoBody)
+		return req.WithContext(context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx))
+	}
+	newPostRequest := func(contentType string, body []byte) *http.Request {
+		req := httptest.NewRequest("POST", "http://example.com/ocsp", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		return req
+	}
+
+	tests := []struct {
+		name         string
+		req          *http.Request
+		err          error
+		statusCode   int
+		cacheControl bool
+	}{
+		{"ok/post", newPostRequest("application/ocsp-request", der), nil, http.StatusOK, false},
+		{"ok/get", newGetRequest(base64.StdEncoding.EncodeToString(der)), nil, http.StatusOK, true},
+		{"ok/get-escaped", newGetRequest(url.PathEscape(base64.StdEncoding.EncodeToString(der))), nil, http.StatusOK, true},
+		{"fail/content-type", newPostRequest("application/json", der), nil, http.StatusBadRequest, false},
+		{"fail/too-large", newPostRequest("application/ocsp-request", make([]byte, maxOCSPRequestSize+1)), nil, http.StatusBadRequest, false},
+		{"fail/base64", newGetRequest("%%%"), nil, http.StatusBadRequest, false},
+		{"fail/authority", newPostRequest("application/ocsp-request", der), errs.NotImplemented("not enabled"), http.StatusNotImplemented, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, &mockAuthority{respondOCSP: func(req []byte) (*ocspcache.Response, error) {
+				if !bytes.Equal(req, der) {
+					t.Errorf("RespondOCSP() req = %s, wants %s", req, der)
+				}
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return resp, nil
+			}})
+			w := httptest.NewRecorder()
+			OCSP(w, tt.req)
+			res := w.Result()
+
+			if res.StatusCode != tt.statusCode {
+				t.Errorf("OCSP StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+			}
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Errorf("OCSP unexpected error = %v", err)
+			}
+			if tt.statusCode != 200 {
+				return
+			}
+			if ct := res.Header.Get("Content-Type"); ct != "application/ocsp-response" {
+				t.Errorf("OCSP Content-Type = %s, wants application/ocsp-response", ct)
+			}
+			if !bytes.Equal(body, resp.Response) {
+				t.Errorf("OCSP body = %s, wants %s", body, resp.Response)
+			}
+			if cc := res.Header.Get("Cache-Control"); (cc != "") != tt.cacheControl {
+				t.Errorf("OCSP Cache-Control = %q", cc)
+			}
+			if tt.cacheControl && res.Header.Get("Expires") != resp.NextUpdate.Format(http.TimeFormat) {
+				t.Errorf("OCSP Expires = %s, wants %s", res.Header.Get("Expires"), resp.NextUpdate.Format(http.TimeFormat))
+			}
+		})
+	}
+}
+
+func Test_DecommissionStatement(t *testing.T) {
+	tests := []struct {
+		name       string
+		statement  string
+		err        error
+		statusCode int
+	}{
+		{"ok", "header.payload.signature", nil, http.StatusOK},
+		{"fail", "", errs.NotFound("decommission statement not found"), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, &mockAuthority{ret1: tt.statement, err: tt.err})
+			w := httptest.NewRecorder()
+			DecommissionStatement(w, httptest.NewRequest("GET", "http://example.com/decommission", http.NoBody))
+			res := w.Result()
+
+			if res.StatusCode != tt.statusCode {
+				t.Errorf("DecommissionStatement StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+			}
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Errorf("DecommissionStatement unexpected error = %v", err)
+			}
+			if tt.statusCode == http.StatusOK {
+				if ct := res.Header.Get("Content-Type"); ct != "application/jwt" {
+					t.Errorf("DecommissionStatement Content-Type = %s, wants application/jwt", ct)
+				}
+				if string(body) != tt.statement {
+					t.Errorf("DecommissionStatement body = %s, wants %s", body, tt.statement)
+				}
+			}
+		})
+	}
+}
+
+func Test_RevocationEvents(t *testing.T) {
+	page := &revocation.Page{
+		Events: []*revocation.Event{{ID: 11, Type: revocation.X509Type, SerialNumber: "1234"}},
+		Last:   11,
+	}
+	tests := []struct {
+		name       string
+		query      string
+		err        error
+		wantSince  uint64
+		statusCode int
+	}{
+		{"ok", "", nil, 0, http.StatusOK},
+		{"ok/since", "?since=10&wait=1s", nil, 10, http.StatusOK},
+		{"fail/since", "?since=foo", nil, 0, http.StatusBadRequest},
+		{"fail/wait", "?wait=-1s", nil, 0, http.StatusBadRequest},
+		{"fail/disabled", "", errs.NotImplemented("revocation events are not enabled"), 0, http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, &mockAuthority{
+				getRevocationEvents: func(ctx context.Context, since uint64) (*revocation.Page, error) {
+					if since != tt.wantSince {
+						t.Errorf("GetRevocationEvents since = %d, wants %d", since, tt.wantSince)
+					}
+					if _, ok := ctx.Deadline(); !ok {
+						t.Error("GetRevocationEvents context does not have a deadline")
+					}
+					return page, tt.err
+				},
+			})
+			w := httptest.NewRecorder()
+			RevocationEvents(w, httptest.NewRequest("GET", "http://example.com/revocations/events"+tt.query, http.NoBody))
+			res := w.Result()
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.statusCode {
+				t.Errorf("RevocationEvents StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+			}
+			if tt.statusCode == http.StatusOK {
+				var got revocation.Page
+				if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(page, &got) {
+					t.Errorf("RevocationEvents = %v, wants %v", got, page)
+				}
+			}
+		})
+	}
+}
+
+func Test_Timestamp(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		timestamp   func(req []byte) ([]byte, error)
+		statusCode  int
+		expected    []byte
+	}{
+		{"ok", "application/timestamp-query", []byte("request"), func(req []byte) ([]byte, error) {
+			return append([]byte("response to "), req...), nil
+		}, http.StatusOK, []byte("response to request")},
+		{"fail content type", "application/json", []byte("request"), nil, http.StatusBadRequest, nil},
+		{"fail too large", "application/timestamp-query", make([]byte, maxTimestampRequestSize+1), nil, http.StatusBadRequest, nil},
+		{"fail disabled", "application/timestamp-query", []byte("request"), func(req []byte) ([]byte, error) {
+			return nil, errs.NotFound("time-stamp authority is not enabled")
+		}, http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, &mockAuthority{timestamp: tt.timestamp})
+			req := httptest.NewRequest("POST", "http://example.com/tsa", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			Timestamp(w, req)
+			res := w.Result()
+
+			if res.StatusCode != tt.statusCode {
+				t.Errorf("Timestamp StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+			}
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Errorf("Timestamp unexpected error = %v", err)
+			}
+			if tt.statusCode == http.StatusOK {
+				if ct := res.Header.Get("Content-Type"); ct != "application/timestamp-reply" {
+					t.Errorf("Timestamp Content-Type = %s, wants application/timestamp-reply", ct)
+				}
+				if !bytes.Equal(body, tt.expected) {
+					t.Errorf("Timestamp Body = %s, wants %s", body, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestSMIMESignRequest_Validate(t *testing.T) {
+	csr := parseCertificateRequest(csrPEM)
+	bad := parseCertificateRequest(csrPEM)
+	bad.Signature[0]++
+	tests := []struct {
+		name string
+		req  SMIMESignRequest
+		err  error
+	}{
+		{"ok code", SMIMESignRequest{CsrPEM: CertificateRequest{csr}, Email: "jane@smallstep.com", Code: "12345678"}, nil},
+		{"ok token", SMIMESignRequest{CsrPEM: CertificateRequest{csr}, Token: "token"}, nil},
+		{"missing csr", SMIMESignRequest{Email: "jane@smallstep.com", Code: "12345678"}, errors.New("missing csr")},
+		{"invalid csr", SMIMESignRequest{CsrPEM: CertificateRequest{bad}, Token: "token"}, errors.New("invalid csr")},
+		{"missing code", SMIMESignRequest{CsrPEM: CertificateRequest{csr}, Email: "jane@smallstep.com"}, errors.New("missing code or token")},
+		{"code and token", SMIMESignRequest{CsrPEM: CertificateRequest{csr}, Email: "jane@smallstep.com", Code: "12345678", Token: "token"}, errors.New("code and token cannot be used together")},
+		{"missing email", SMIMESignRequest{CsrPEM: CertificateRequest{csr}, Code: "12345678"}, errors.New("missing email")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); err != nil {
+				if assert.NotNil(t, tt.err) {
+					assert.HasPrefix(t, err.Error(), tt.err.Error())
+				}
+			} else {
+				assert.Nil(t, tt.err)
+			}
+		})
+	}
+}
+
+func Test_SMIMECode(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		err        error
+		statusCode int
+	}{
+		{"ok", `{"email":"jane@smallstep.com"}`, nil, http.StatusAccepted},
+		{"fail json", `{`, nil, http.StatusBadRequest},
+		{"fail email", `{}`, nil, http.StatusBadRequest},
+		{"fail disabled", `{"email":"jane@smallstep.com"}`, errs.NotFound("S/MIME issuance is not enabled"), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			mockMustAuthority(t, &mockAuthority{sendSMIMECode: func(ctx context.Context, email string) error {
+				got = email
+				return tt.err
+			}})
+			req := httptest.NewRequest("POST", "http://example.com/smime/code", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			SMIMECode(w, req)
+			res := w.Result()
+			if res.StatusCode != tt.statusCode {
+				t.Errorf("SMIMECode StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+			}
+			if tt.statusCode == http.StatusAccepted && got != "jane@smallstep.com" {
+				t.Errorf("SMIMECode email = %s, wants jane@smallstep.com", got)
+			}
+		})
+	}
+}
+
+func Test_SMIMESign(t *testing.T) {
+	csr := parseCertificateRequest(csrPEM)
+	valid, err := json.Marshal(SMIMESignRequest{
+		CsrPEM: CertificateRequest{csr},
+		Email:  "jane@smallstep.com",
+		Code:   "12345678",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte(`{"crt":"` + strings.ReplaceAll(certPEM, "\n", `\n`) + `\n","ca":"` + strings.ReplaceAll(rootPEM, "\n", `\n`) + `\n","certChain":["` + strings.ReplaceAll(certPEM, "\n", `\n`) + `\n","` + strings.ReplaceAll(rootPEM, "\n", `\n`) + `\n"]}`)
+
+	tests := []struct {
+		name       string
+		input      string
+		signErr    error
+		statusCode int
+		expected   []byte
+	}{
+		{"ok", string(valid), nil, http.StatusCreated, expected},
+		{"json read error", "{", nil, http.StatusBadRequest, nil},
+		{"validate error", `{"email":"jane@smallstep.com"}`, nil, http.StatusBadRequest, nil},
+		{"sign error", string(valid), errs.Unauthorized("invalid or expired code"), http.StatusUnauthorized, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, &mockAuthority{
+				signSMIME: func(ctx context.Context, cr *x509.CertificateRequest, email, code, token string) ([]*x509.Certificate, error) {
+					if email != "jane@smallstep.com" || code != "12345678" || token != "" {
+						t.Errorf("SignSMIME got unexpected arguments %s, %s, %s", email, code, token)
+					}
+					if tt.signErr != nil {
+						return nil, tt.signErr
+					}
+					return []*x509.Certificate{parseCertificate(certPEM), parseCertificate(rootPEM)}, nil
+				},
+			})
+			req := httptest.NewRequest("POST", "http://example.com/smime/sign", strings.NewReader(tt.input))
+			w := httptest.NewRecorder()
+			SMIMESign(logging.NewResponseLogger(w), req)
+			res := w.Result()
+
+			if res.StatusCode != tt.statusCode {
+				t.Errorf("SMIMESign StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+			}
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Errorf("SMIMESign unexpected error = %v", err)
+			}
+			if tt.statusCode < http.StatusBadRequest {
+				if !bytes.Equal(bytes.TrimSpace(body), tt.expected) {
+					t.Errorf("SMIMESign Body = %s, wants %s", body, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func Test_AttestedSign(t *testing.T) {
+	csr := parseCertificateRequest(csrPEM)
+	attObj, err := cbor.Marshal(attestation.Statement{
+		Format:       "step",
+		AttStatement: map[string]interface{}{"x5c": []interface{}{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := json.Marshal(AttestedSignRequest{
+		CsrPEM:      CertificateRequest{csr},
+		Provisioner: "acme",
+		AttObj:      base64.RawURLEncoding.EncodeToString(attObj),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte(`{"crt":"` + strings.ReplaceAll(certPEM, "\n", `\n`) + `\n","ca":"` + strings.ReplaceAll(rootPEM, "\n", `\n`) + `\n","certChain":["` + strings.ReplaceAll(certPEM, "\n", `\n`) + `\n","` + strings.ReplaceAll(rootPEM, "\n", `\n`) + `\n"]}`)
+
+	tests := []struct {
+		name       string
+		input      string
+		signErr    error
+		statusCode int
+		expected   []byte
+	}{
+		{"ok", string(valid), nil, http.StatusCreated, expected},
+		{"json read error", "{", nil, http.StatusBadRequest, nil},
+		{"validate error", `{"provisioner":"acme","attObj":"AA"}`, nil, http.StatusBadRequest, nil},
+		{"decode error", strings.Replace(string(valid), `"attObj":"`, `"attObj":"%`, 1), nil, http.StatusBadRequest, nil},
+		{"parse error", strings.Replace(string(valid), `"attObj":"`, `"attObj":"AA`, 1), nil, http.StatusBadRequest, nil},
+		{"sign error", string(valid), errs.Forbidden("certificate request key does not match the attested key"), http.StatusForbidden, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, &mockAuthority{
+				signAttested: func(ctx context.Context, cr *x509.CertificateRequest, provisionerName string, st *attestation.Statement) ([]*x509.Certificate, error) {
+					if provisionerName != "acme" || st.Format != "step" {
+						t.Errorf("SignAttested got unexpected arguments %s, %v", provisionerName, st)
+					}
+					if tt.signErr != nil {
+						return nil, tt.signErr
+					}
+					return []*x509.Certificate{parseCertificate(certPEM), parseCertificate(rootPEM)}, nil
+				},
+			})
+			req := httptest.NewRequest("POST", "http://example.com/attest", strings.NewReader(tt.input))
+			w := httptest.NewRecorder()
+			AttestedSign(logging.NewResponseLogger(w), req)
+			res := w.Result()
+
+			if res.StatusCode != tt.statusCode {
+				t.Errorf("AttestedSign StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+			}
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Errorf("AttestedSign unexpected error = %v", err)
+			}
+			if tt.statusCode < http.StatusBadRequest {
+				if !bytes.Equal(bytes.TrimSpace(body), tt.expected) {
+					t.Errorf("AttestedSign Body = %s, wants %s", body, tt.expected)
+				}
+			}
+		})
+	}
+}
+
 func Test_caHandler_Route(t *testing.T) {
 	type fields struct {
 		Authority Authority
@@ -856,23 +1487,45 @@ func Test_caHandler_Route(t *testing.T) {
 }
 
 func Test_Health(t *testing.T) {
-	req := httptest.NewRequest("GET", "http://example.com/health", http.NoBody)
-	w := httptest.NewRecorder()
-	Health(w, req)
-
-	res := w.Result()
-	if res.StatusCode != 200 {
-		t.Errorf("caHandler.Health StatusCode = %d, wants 200", res.StatusCode)
+	notAfter := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	expirations := []*authority.CAExpiration{
+		{Type: "root", Subject: "Root CA", SerialNumber: "1", Fingerprint: "abc", NotAfter: notAfter},
+		{Type: "intermediate", Subject: "Intermediate CA", SerialNumber: "2", Fingerprint: "def", NotAfter: notAfter,
+			Threshold: &provisioner.Duration{Duration: 720 * time.Hour}},
 	}
-
-	body, err := io.ReadAll(res.Body)
-	res.Body.Close()
-	if err != nil {
-		t.Errorf("caHandler.Health unexpected error = %v", err)
+	tests := []struct {
+		name        string
+		expirations []*authority.CAExpiration
+		expected    string
+	}{
+		{"ok", nil, `{"status":"ok"}`},
+		{"ok not expiring", expirations[:1], `{"status":"ok"}`},
+		{"ok expiring", expirations, `{"status":"ok","expiring":[{"type":"intermediate","subject":"Intermediate CA","serialNumber":"2","fingerprint":"def","notAfter":"2030-01-01T00:00:00Z","threshold":"720h0m0s"}]}`},
 	}
-	expected := []byte("{\"status\":\"ok\"}\n")
-	if !bytes.Equal(body, expected) {
-		t.Errorf("caHandler.Health Body = %s, wants %s", body, expected)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, &mockAuthority{getCAExpirations: func() []*authority.CAExpiration {
+				return tt.expirations
+			}})
+			req := httptest.NewRequest("GET", "http://example.com/health", http.NoBody)
+			w := httptest.NewRecorder()
+			Health(w, req)
+
+			res := w.Result()
+			if res.StatusCode != 200 {
+				t.Errorf("caHandler.Health StatusCode = %d, wants 200", res.StatusCode)
+			}
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Errorf("caHandler.Health unexpected error = %v", err)
+			}
+			expected := []byte(tt.expected + "\n")
+			if !bytes.Equal(body, expected) {
+				t.Errorf("caHandler.Health Body = %s, wants %s", body, expected)
+			}
+		})
 	}
 }
 
@@ -936,9 +1589,18 @@ func Test_Sign(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	dryRun, err := json.Marshal(SignRequest{
+		CsrPEM: CertificateRequest{csr},
+		OTT:    "foobarzar",
+		DryRun: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	expected1 := []byte(`{"crt":"` + strings.ReplaceAll(certPEM, "\n", `\n`) + `\n","ca":"` + strings.ReplaceAll(rootPEM, "\n", `\n`) + `\n","certChain":["` + strings.ReplaceAll(certPEM, "\n", `\n`) + `\n","` + strings.ReplaceAll(rootPEM, "\n", `\n`) + `\n"]}`)
 	expected2 := []byte(`{"crt":"` + strings.ReplaceAll(stepCertPEM, "\n", `\n`) + `\n","ca":"` + strings.ReplaceAll(rootPEM, "\n", `\n`) + `\n","certChain":["` + strings.ReplaceAll(stepCertPEM, "\n", `\n`) + `\n","` + strings.ReplaceAll(rootPEM, "\n", `\n`) + `\n"]}`)
+	expected3 := []byte(`{"tbsCertificate":"` + base64.StdEncoding.EncodeToString(parseCertificate(certPEM).RawTBSCertificate) + `"}`)
 
 	tests := []struct {
 		name         string
@@ -957,6 +1619,8 @@ func Test_Sign(t *testing.T) {
 		{"validate error", string(invalid), nil, nil, nil, nil, nil, http.StatusBadRequest, nil},
 		{"authorize error", string(valid), nil, fmt.Errorf("an error"), nil, nil, nil, http.StatusUnauthorized, nil},
 		{"sign error", string(valid), nil, nil, nil, nil, fmt.Errorf("an error"), http.StatusForbidden, nil},
+		{"ok dry run", string(dryRun), nil, nil, parseCertificate(certPEM), nil, nil, http.StatusOK, expected3},
+		{"dry run error", string(dryRun), nil, nil, nil, nil, fmt.Errorf("an error"), http.StatusForbidden, nil},
 	}
 
 	for _, tt := range tests {
@@ -1301,6 +1965,61 @@ func Test_Provisioners(t *testing.T) {
 	}
 }
 
+func TestListProvisioners(t *testing.T) {
+	list := provisioner.List{
+		&provisioner.JWK{Type: "JWK", Name: "max"},
+		&provisioner.ACME{Type: "ACME", Name: "acme"},
+		&provisioner.JWK{Type: "JWK", Name: "mariano"},
+	}
+	// Returns a provisioner per page to test that all pages are loaded.
+	fn := func(cursor string, limit int) (provisioner.List, string, error) {
+		for i, p := range list {
+			if cursor == "" || p.GetName() == cursor {
+				if i+1 < len(list) {
+					return list[i : i+1], list[i+1].GetName(), nil
+				}
+				return list[i:], "", nil
+			}
+		}
+		return nil, "", errors.New("force")
+	}
+	names := func(list provisioner.List) []string {
+		ret := []string{}
+		for _, p := range list {
+			ret = append(ret, p.GetName())
+		}
+		return ret
+	}
+
+	got, next, err := ListProvisioners(fn, &pagination.Options{})
+	require.NoError(t, err)
+	sassert.Equal(t, []string{"max"}, names(got))
+	sassert.Equal(t, "acme", next)
+
+	got, next, err = ListProvisioners(fn, &pagination.Options{Sort: "name", Limit: 2})
+	require.NoError(t, err)
+	sassert.Equal(t, []string{"acme", "mariano"}, names(got))
+	sassert.Equal(t, "max", next)
+
+	got, next, err = ListProvisioners(fn, &pagination.Options{Sort: "name", Desc: true, Filters: url.Values{"type": {"JWK"}}})
+	require.NoError(t, err)
+	sassert.Equal(t, []string{"max", "mariano"}, names(got))
+	sassert.Equal(t, "", next)
+
+	var sc interface{ StatusCode() int }
+	_, _, err = ListProvisioners(fn, &pagination.Options{Sort: "name", Cursor: "foo"})
+	sassert.ErrorContains(t, err, "cursor 'foo' is not valid")
+	if sassert.True(t, errors.As(err, &sc)) {
+		sassert.Equal(t, 400, sc.StatusCode())
+	}
+
+	_, _, err = ListProvisioners(fn, &pagination.Options{Cursor: "foo"})
+	sassert.ErrorContains(t, err, "force")
+	if sassert.True(t, errors.As(err, &sc)) {
+		sassert.Equal(t, 500, sc.StatusCode())
+	}
+}
+
 func Test_ProvisionerKey(t *testing.T) {
 	type fields struct {
 		Authority Authority
@@ -1491,6 +2210,68 @@ func Test_Federation(t *testing.T) {
 	}
 }
 
+func Test_Roots_signed(t *testing.T) {
+	expected := `{"crts":["` + strings.ReplaceAll(rootPEM, "\n", `\n`) + `\n"]}`
+	tests := []struct {
+		name            string
+		accept          string
+		signed          bool
+		signErr         error
+		statusCode      int
+		wantContentType string
+		wantVary        string
+		wantBody        string
+	}{
+		{"ok/disabled", "application/jose", false, nil, http.StatusCreated, "application/json", "", expected},
+		{"ok/json", "application/json", true, nil, http.StatusCreated, "application/json", "Accept", expected},
+		{"ok/jose", "application/jose", true, nil, http.StatusCreated, "application/jose", "Accept", "signed:" + expected},
+		{"ok/jose-params", "application/json;q=0.5, Application/JOSE;q=1", true, nil, http.StatusCreated, "application/jose", "Accept", "signed:" + expected},
+		{"fail/sign", "application/jose", true, errs.InternalServer("force"), http.StatusInternalServerError, "application/json", "Accept", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, &mockAuthority{
+				ret1: []*x509.Certificate{parseCertificate(rootPEM)},
+				isResponseSigned: func(endpoint string) bool {
+					return tt.signed && endpoint == "roots"
+				},
+				signResponse: func(payload []byte) (string, error) {
+					if tt.signErr != nil {
+						return "", tt.signErr
+					}
+					return "signed:" + string(payload), nil
+				},
+			})
+			req := httptest.NewRequest("GET", "http://example.com/roots", http.NoBody)
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			Roots(w, req)
+			res := w.Result()
+
+			if res.StatusCode != tt.statusCode {
+				t.Errorf("caHandler.Roots StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+			}
+			if got := res.Header.Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("caHandler.Roots Content-Type = %s, wants %s", got, tt.wantContentType)
+			}
+			if got := res.Header.Get("Vary"); got != tt.wantVary {
+				t.Errorf("caHandler.Roots Vary = %s, wants %s", got, tt.wantVary)
+			}
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Errorf("caHandler.Roots unexpected error = %v", err)
+			}
+			if tt.statusCode < http.StatusBadRequest {
+				if got := string(bytes.TrimSpace(body)); got != tt.wantBody {
+					t.Errorf("caHandler.Roots Body = %s, wants %s", got, tt.wantBody)
+				}
+			}
+		})
+	}
+}
+
 func Test_fmtPublicKey(t *testing.T) {
 	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
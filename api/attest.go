@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/attestation"
+	"github.com/smallstep/certificates/errs"
+)
+
+// AttestedSignRequest is the request body used to get a certificate for a key
+// attested by a device. The attestation object uses the same encoding as the
+// payload of the ACME device-attest-01 challenge, a base64url encoded CBOR
+// WebAuthn attestation object.
+type AttestedSignRequest struct {
+	CsrPEM      CertificateRequest `json:"csr"`
+	Provisioner string             `json:"provisioner"`
+	AttObj      string             `json:"attObj"`
+}
+
+// Validate checks the fields of the AttestedSignRequest.
+func (s *AttestedSignRequest) Validate() error {
+	switch {
+	case s.CsrPEM.CertificateRequest == nil:
+		return errs.BadRequest("missing csr")
+	case s.Provisioner == "":
+		return errs.BadRequest("missing provisioner")
+	case s.AttObj == "":
+		return errs.BadRequest("missing attObj")
+	}
+	if err := s.CsrPEM.CertificateRequest.CheckSignature(); err != nil {
+		return errs.BadRequestErr(err, "invalid csr")
+	}
+	return nil
+}
+
+// AttestedSign is an HTTP handler that reads a certificate request and an
+// attestation statement of its key, and creates a new certificate for the
+// attested device.
+func AttestedSign(w http.ResponseWriter, r *http.Request) {
+	var body AttestedSignRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, errs.BadRequestErr(err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+	attObj, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(body.AttObj, "="))
+	if err != nil {
+		render.Error(w, errs.BadRequestErr(err, "error decoding attObj"))
+		return
+	}
+	st, err := attestation.ParseStatement(attObj)
+	if err != nil {
+		render.Error(w, errs.BadRequestErr(err, "error parsing attObj"))
+		return
+	}
+
+	ctx := r.Context()
+	certChain, err := mustAuthority(ctx).SignAttested(ctx, body.CsrPEM.CertificateRequest, body.Provisioner, st)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	certChainPEM := certChainToPEM(certChain)
+	var caPEM Certificate
+	if len(certChainPEM) > 1 {
+		caPEM = certChainPEM[1]
+	}
+
+	LogCertificate(w, certChain[0])
+	render.JSONStatus(w, &SignResponse{
+		ServerPEM:    certChainPEM[0],
+		CaPEM:        caPEM,
+		CertChainPEM: certChainPEM,
+	}, http.StatusCreated)
+}
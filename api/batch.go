@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.step.sm/crypto/x509util"
+
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/logging"
+)
+
+// BatchSignRequest is the request body of a batch sign request. Each
+// certificate request is authorized with its own token, or with the token of
+// the batch if it doesn't have one. The token of the batch is used only once,
+// and all the certificate requests authorized with it must satisfy its
+// restrictions, like the SANs.
+type BatchSignRequest struct {
+	OTT      string                  `json:"ott,omitempty"`
+	Requests []*BatchSignRequestItem `json:"requests"`
+}
+
+// BatchSignRequestItem is one of the certificate requests in a batch.
+type BatchSignRequestItem struct {
+	CsrPEM       CertificateRequest   `json:"csr"`
+	OTT          string               `json:"ott,omitempty"`
+	NotAfter     TimeDuration         `json:"notAfter,omitempty"`
+	NotBefore    TimeDuration         `json:"notBefore,omitempty"`
+	TemplateData json.RawMessage      `json:"templateData,omitempty"`
+	KeyUsage     x509util.KeyUsage    `json:"keyUsage,omitempty"`
+	ExtKeyUsage  x509util.ExtKeyUsage `json:"extKeyUsage,omitempty"`
+}
+
+// Validate checks the fields of the BatchSignRequest and returns nil if they
+// are ok or an error if something is wrong. The certificate requests are
+// validated individually.
+func (s *BatchSignRequest) Validate(maxRequests int) error {
+	if len(s.Requests) == 0 {
+		return errs.BadRequest("missing requests")
+	}
+	if len(s.Requests) > maxRequests {
+		return errs.BadRequest("the batch cannot have more than %d requests", maxRequests)
+	}
+	for i, req := range s.Requests {
+		if req == nil {
+			return errs.BadRequest("request %d cannot be null", i)
+		}
+		if req.OTT == "" && s.OTT == "" {
+			return errs.BadRequest("missing ott in request %d", i)
+		}
+	}
+	return nil
+}
+
+func (s *BatchSignRequestItem) validate() error {
+	if s.CsrPEM.CertificateRequest == nil {
+		return errs.BadRequest("missing csr")
+	}
+	if err := s.CsrPEM.CertificateRequest.CheckSignature(); err != nil {
+		return errs.BadRequestErr(err, "invalid csr")
+	}
+	return nil
+}
+
+// BatchSignResponse is the response object of a batch sign request, it has
+// one result for each certificate request, in the same order.
+type BatchSignResponse struct {
+	Results []*BatchSignResult `json:"results"`
+}
+
+// BatchSignResult is the result of one of the certificate requests in a
+// batch, it has the certificate chain or the error.
+type BatchSignResult struct {
+	ServerPEM    *Certificate  `json:"crt,omitempty"`
+	CaPEM        *Certificate  `json:"ca,omitempty"`
+	CertChainPEM []Certificate `json:"certChain,omitempty"`
+	Error        *errs.Error   `json:"error,omitempty"`
+}
+
+// BatchSign is an HTTP handler that signs multiple certificate requests in
+// one request. The response is always 200 if the batch is valid, a failure
+// in one of the certificate requests is reported in its result.
+func BatchSign(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	a := mustAuthority(ctx)
+
+	cfg := a.GetBatchSignConfig()
+	if !cfg.IsEnabled() {
+		render.Error(w, errs.NotImplemented("batch sign is not enabled"))
+		return
+	}
+
+	var body BatchSignRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, errs.BadRequestErr(err, "error reading request body"))
+		return
+	}
+
+	logOtt(w, body.OTT)
+	if err := body.Validate(cfg.GetMaxRequests()); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	ctx = provisioner.NewContextWithMethod(ctx, provisioner.SignMethod)
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		ctx = provisioner.NewContextWithClientCertificate(ctx, r.TLS.PeerCertificates[0])
+	}
+
+	// The token of the batch is authorized only if it is used.
+	var (
+		batchSignOpts   []provisioner.SignOption
+		batchErr        error
+		batchAuthorized bool
+	)
+	authorizeBatch := func() ([]provisioner.SignOption, error) {
+		if !batchAuthorized {
+			batchSignOpts, batchErr = a.Authorize(ctx, body.OTT)
+			batchAuthorized = true
+		}
+		return batchSignOpts, batchErr
+	}
+
+	var serials, failures []string
+	results := make([]*BatchSignResult, len(body.Requests))
+	for i, req := range body.Requests {
+		res := new(BatchSignResult)
+		results[i] = res
+		if err := req.validate(); err != nil {
+			res.Error, failures = batchSignError(err), append(failures, err.Error())
+			continue
+		}
+
+		var signOpts []provisioner.SignOption
+		var err error
+		if req.OTT != "" {
+			signOpts, err = a.Authorize(ctx, req.OTT)
+		} else {
+			signOpts, err = authorizeBatch()
+		}
+		if err != nil {
+			res.Error, failures = batchSignError(errs.UnauthorizedErr(err)), append(failures, err.Error())
+			continue
+		}
+
+		certChain, err := a.SignWithContext(ctx, req.CsrPEM.CertificateRequest, provisioner.SignOptions{
+			NotBefore:    req.NotBefore,
+			NotAfter:     req.NotAfter,
+			TemplateData: req.TemplateData,
+			KeyUsage:     req.KeyUsage,
+			ExtKeyUsage:  req.ExtKeyUsage,
+		}, signOpts...)
+		if err != nil {
+			res.Error, failures = batchSignError(errs.ForbiddenErr(err, "error signing certificate")), append(failures, err.Error())
+			continue
+		}
+
+		certChainPEM := certChainToPEM(certChain)
+		res.ServerPEM = &certChainPEM[0]
+		if len(certChainPEM) > 1 {
+			res.CaPEM = &certChainPEM[1]
+		}
+		res.CertChainPEM = certChainPEM
+		serials = append(serials, certChain[0].SerialNumber.String())
+	}
+
+	if rl, ok := w.(logging.ResponseLogger); ok {
+		m := map[string]interface{}{
+			"batch-size": len(body.Requests),
+			"serials":    serials,
+		}
+		if len(failures) > 0 {
+			m["batch-errors"] = failures
+		}
+		rl.WithFields(m)
+	}
+
+	render.JSON(w, &BatchSignResponse{
+		Results: results,
+	})
+}
+
+// batchSignError returns the error reported in the result of a certificate
+// request.
+func batchSignError(err error) *errs.Error {
+	var e *errs.Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &errs.Error{Status: http.StatusInternalServerError, Err: err}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/logging"
+)
+
+func TestBatchSignRequest_Validate(t *testing.T) {
+	csr := CertificateRequest{parseCertificateRequest(csrPEM)}
+	tests := []struct {
+		name        string
+		req         BatchSignRequest
+		maxRequests int
+		wantErr     string
+	}{
+		{"ok", BatchSignRequest{Requests: []*BatchSignRequestItem{{CsrPEM: csr, OTT: "token"}}}, 1, ""},
+		{"ok batch ott", BatchSignRequest{OTT: "token", Requests: []*BatchSignRequestItem{{CsrPEM: csr}, {CsrPEM: csr}}}, 2, ""},
+		{"fail empty", BatchSignRequest{OTT: "token"}, 1, "missing requests"},
+		{"fail max requests", BatchSignRequest{OTT: "token", Requests: []*BatchSignRequestItem{{CsrPEM: csr}, {CsrPEM: csr}}}, 1, "the batch cannot have more than 1 requests"},
+		{"fail null", BatchSignRequest{OTT: "token", Requests: []*BatchSignRequestItem{{CsrPEM: csr}, nil}}, 2, "request 1 cannot be null"},
+		{"fail ott", BatchSignRequest{Requests: []*BatchSignRequestItem{{CsrPEM: csr, OTT: "token"}, {CsrPEM: csr}}}, 2, "missing ott in request 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate(tt.maxRequests)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_BatchSign(t *testing.T) {
+	csr := CertificateRequest{parseCertificateRequest(csrPEM)}
+	cert, root := parseCertificate(certPEM), parseCertificate(rootPEM)
+	mustJSON := func(t *testing.T, v interface{}) string {
+		t.Helper()
+		b, err := json.Marshal(v)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	type result struct {
+		Crt   *Certificate `json:"crt"`
+		Error *struct {
+			Status  int    `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	type response struct {
+		Results []result `json:"results"`
+	}
+
+	tests := []struct {
+		name           string
+		cfg            *config.BatchSignConfig
+		body           func(t *testing.T) string
+		authorize      func(ctx context.Context, ott string) ([]provisioner.SignOption, error)
+		sign           func(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
+		wantStatus     int
+		wantAuthorized []string
+		wantResults    []int
+	}{
+		{"ok", nil, func(t *testing.T) string {
+			return mustJSON(t, BatchSignRequest{Requests: []*BatchSignRequestItem{
+				{CsrPEM: csr, OTT: "token1"}, {CsrPEM: csr, OTT: "token2"},
+			}})
+		}, nil, nil, http.StatusOK, []string{"token1", "token2"}, []int{0, 0}},
+		{"ok batch ott", nil, func(t *testing.T) string {
+			return mustJSON(t, BatchSignRequest{OTT: "batch", Requests: []*BatchSignRequestItem{
+				{CsrPEM: csr}, {CsrPEM: csr, OTT: "token"}, {CsrPEM: csr},
+			}})
+		}, nil, nil, http.StatusOK, []string{"batch", "token"}, []int{0, 0, 0}},
+		{"ok with errors", nil, func(t *testing.T) string {
+			return mustJSON(t, BatchSignRequest{OTT: "batch", Requests: []*BatchSignRequestItem{
+				{CsrPEM: csr, OTT: "bad"}, {CsrPEM: csr}, {CsrPEM: CertificateRequest{}}, {CsrPEM: csr, OTT: "token", TemplateData: json.RawMessage(`{"fail":true}`)},
+			}})
+		}, func(ctx context.Context, ott string) ([]provisioner.SignOption, error) {
+			if ott == "bad" {
+				return nil, errors.New("token is not valid")
+			}
+			return nil, nil
+		}, func(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+			if opts.TemplateData != nil {
+				return nil, errors.New("template data is not allowed")
+			}
+			return []*x509.Certificate{cert, root}, nil
+		}, http.StatusOK, []string{"bad", "batch", "token"}, []int{http.StatusUnauthorized, 0, http.StatusBadRequest, http.StatusForbidden}},
+		{"ok batch ott error", nil, func(t *testing.T) string {
+			return mustJSON(t, BatchSignRequest{OTT: "bad", Requests: []*BatchSignRequestItem{
+				{CsrPEM: csr}, {CsrPEM: csr},
+			}})
+		}, func(ctx context.Context, ott string) ([]provisioner.SignOption, error) {
+			return nil, errors.New("token is not valid")
+		}, nil, http.StatusOK, []string{"bad"}, []int{http.StatusUnauthorized, http.StatusUnauthorized}},
+		{"fail disabled", &config.BatchSignConfig{Disabled: true}, func(t *testing.T) string {
+			return mustJSON(t, BatchSignRequest{OTT: "batch", Requests: []*BatchSignRequestItem{{CsrPEM: csr}}})
+		}, nil, nil, http.StatusNotImplemented, nil, nil},
+		{"fail max requests", &config.BatchSignConfig{MaxRequests: 1}, func(t *testing.T) string {
+			return mustJSON(t, BatchSignRequest{OTT: "batch", Requests: []*BatchSignRequestItem{{CsrPEM: csr}, {CsrPEM: csr}}})
+		}, nil, nil, http.StatusBadRequest, nil, nil},
+		{"fail json", nil, func(t *testing.T) string {
+			return "{"
+		}, nil, nil, http.StatusBadRequest, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var authorized []string
+			mockMustAuthority(t, &mockAuthority{
+				getBatchSignConfig: func() *config.BatchSignConfig {
+					return tt.cfg
+				},
+				authorize: func(ctx context.Context, ott string) ([]provisioner.SignOption, error) {
+					authorized = append(authorized, ott)
+					if tt.authorize != nil {
+						return tt.authorize(ctx, ott)
+					}
+					return nil, nil
+				},
+				sign: func(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+					if tt.sign != nil {
+						return tt.sign(cr, opts, signOpts...)
+					}
+					return []*x509.Certificate{cert, root}, nil
+				},
+			})
+
+			req := httptest.NewRequest("POST", "http://example.com/sign/batch", strings.NewReader(tt.body(t)))
+			w := httptest.NewRecorder()
+			BatchSign(logging.NewResponseLogger(w), req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			assert.Equal(t, tt.wantAuthorized, authorized)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp response
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&resp))
+			require.Len(t, resp.Results, len(tt.wantResults))
+			for i, status := range tt.wantResults {
+				if status == 0 {
+					assert.Nil(t, resp.Results[i].Error)
+					if assert.NotNil(t, resp.Results[i].Crt) {
+						assert.Equal(t, cert.Raw, resp.Results[i].Crt.Raw)
+					}
+				} else {
+					assert.Nil(t, resp.Results[i].Crt)
+					if assert.NotNil(t, resp.Results[i].Error) {
+						assert.Equal(t, status, resp.Results[i].Error.Status)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/smallstep/certificates/api/render"
+)
+
+// DecommissionStatement returns the signed statement of a decommissioned
+// authority. The statement is a JWS in compact serialization, signed by a
+// certificate included in the x5c header.
+func DecommissionStatement(w http.ResponseWriter, r *http.Request) {
+	statement, err := mustAuthority(r.Context()).GetDecommissionStatement()
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/jwt")
+	w.Write([]byte(statement))
+}
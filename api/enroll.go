@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/pem"
+	"net/http"
+
+	"go.step.sm/crypto/pemutil"
+
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/internal/pkcs12"
+	"github.com/smallstep/certificates/logging"
+)
+
+// Formats of the enrollment response.
+const (
+	// EnrollFormatPEM returns the key and the certificates in PEM format.
+	EnrollFormatPEM = "pem"
+	// EnrollFormatPKCS12 returns the key and the certificates in a PKCS #12
+	// file protected with the password in the request.
+	EnrollFormatPKCS12 = "pkcs12"
+)
+
+// EnrollRequest is the request body of the enrollment API, where the CA
+// generates the key of the certificate.
+type EnrollRequest struct {
+	Name      string       `json:"name"`
+	SANs      []string     `json:"sans,omitempty"`
+	Token     string       `json:"token"`
+	NotAfter  TimeDuration `json:"notAfter,omitempty"`
+	NotBefore TimeDuration `json:"notBefore,omitempty"`
+	Format    string       `json:"format,omitempty"`
+	Password  string       `json:"password,omitempty"`
+}
+
+// Validate checks the fields of the EnrollRequest.
+func (s *EnrollRequest) Validate() error {
+	switch {
+	case s.Name == "":
+		return errs.BadRequest("missing name")
+	case s.Token == "":
+		return errs.BadRequest("missing token")
+	}
+	switch s.Format {
+	case "", EnrollFormatPEM:
+		if s.Password != "" {
+			return errs.BadRequest("password can only be used with the pkcs12 format")
+		}
+	case EnrollFormatPKCS12:
+		if s.Password == "" {
+			return errs.BadRequest("missing password")
+		}
+	default:
+		return errs.BadRequest("format %q is not supported", s.Format)
+	}
+	return nil
+}
+
+// EnrollResponse is the response of the enrollment API. The key is a PKCS #8
+// PEM block if the format is pem, and PKCS12 is the PKCS #12 file if the
+// format is pkcs12.
+type EnrollResponse struct {
+	ServerPEM    Certificate   `json:"crt"`
+	CaPEM        Certificate   `json:"ca"`
+	CertChainPEM []Certificate `json:"certChain"`
+	KeyPEM       string        `json:"key,omitempty"`
+	PKCS12       []byte        `json:"pkcs12,omitempty"`
+}
+
+// Enroll is an HTTP handler that creates a new key and a certificate for it
+// with the name in the body, authorized by the one-time token. It's only
+// available if the enrollment is enabled in the configuration.
+//
+// The response contains the private key, so it's never cached, and the
+// handler does not use the idempotency middleware that would store it.
+func Enroll(w http.ResponseWriter, r *http.Request) {
+	var body EnrollRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, errs.BadRequestErr(err, "error reading request body"))
+		return
+	}
+
+	logOtt(w, body.Token)
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	opts := provisioner.SignOptions{
+		NotBefore: body.NotBefore,
+		NotAfter:  body.NotAfter,
+	}
+	signer, certChain, err := mustAuthority(r.Context()).Enroll(r.Context(), body.Token, body.Name, body.SANs, opts)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	resp := &EnrollResponse{}
+	if body.Format == EnrollFormatPKCS12 {
+		if resp.PKCS12, err = pkcs12.Encode(signer, certChain, body.Password); err != nil {
+			render.Error(w, errs.InternalServerErr(err, errs.WithMessage("error encoding pkcs12")))
+			return
+		}
+	} else {
+		block, err := pemutil.Serialize(signer, pemutil.WithPKCS8(true))
+		if err != nil {
+			render.Error(w, errs.InternalServerErr(err, errs.WithMessage("error encoding private key")))
+			return
+		}
+		resp.KeyPEM = string(pem.EncodeToMemory(block))
+	}
+
+	certChainPEM := certChainToPEM(certChain)
+	resp.ServerPEM = certChainPEM[0]
+	resp.CertChainPEM = certChainPEM
+	if len(certChainPEM) > 1 {
+		resp.CaPEM = certChainPEM[1]
+	}
+
+	LogCertificate(w, certChain[0])
+	if rl, ok := w.(logging.ResponseLogger); ok {
+		rl.WithFields(map[string]interface{}{
+			"server-side-key": true,
+		})
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	render.JSONStatus(w, resp, http.StatusCreated)
+}
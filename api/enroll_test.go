@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.step.sm/crypto/pemutil"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/logging"
+)
+
+func TestEnrollRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     EnrollRequest
+		wantErr bool
+	}{
+		{"ok", EnrollRequest{Name: "test.example.com", Token: "token"}, false},
+		{"ok/pem", EnrollRequest{Name: "test.example.com", Token: "token", Format: EnrollFormatPEM}, false},
+		{"ok/pkcs12", EnrollRequest{Name: "test.example.com", Token: "token", Format: EnrollFormatPKCS12, Password: "password"}, false},
+		{"fail/name", EnrollRequest{Token: "token"}, true},
+		{"fail/token", EnrollRequest{Name: "test.example.com"}, true},
+		{"fail/format", EnrollRequest{Name: "test.example.com", Token: "token", Format: "jks"}, true},
+		{"fail/pkcs12-password", EnrollRequest{Name: "test.example.com", Token: "token", Format: EnrollFormatPKCS12}, true},
+		{"fail/pem-password", EnrollRequest{Name: "test.example.com", Token: "token", Password: "password"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("EnrollRequest.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_Enroll(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := []*x509.Certificate{parseCertificate(certPEM), parseCertificate(rootPEM)}
+
+	tests := []struct {
+		name       string
+		input      string
+		enrollErr  error
+		statusCode int
+	}{
+		{"ok/pem", `{"name":"test.example.com","token":"token"}`, nil, http.StatusCreated},
+		{"ok/pkcs12", `{"name":"test.example.com","token":"token","format":"pkcs12","password":"password"}`, nil, http.StatusCreated},
+		{"fail/json", `{`, nil, http.StatusBadRequest},
+		{"fail/validate", `{"name":"test.example.com"}`, nil, http.StatusBadRequest},
+		{"fail/disabled", `{"name":"test.example.com","token":"token"}`, errs.NotFound("enrollment is not enabled"), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, &mockAuthority{
+				enroll: func(ctx context.Context, token, commonName string, sans []string, opts provisioner.SignOptions) (crypto.Signer, []*x509.Certificate, error) {
+					if token != "token" || commonName != "test.example.com" || len(sans) != 0 {
+						t.Errorf("Enroll got unexpected arguments %s, %s, %v", token, commonName, sans)
+					}
+					if tt.enrollErr != nil {
+						return nil, nil, tt.enrollErr
+					}
+					return key, chain, nil
+				},
+			})
+			req := httptest.NewRequest("POST", "http://example.com/enroll", strings.NewReader(tt.input))
+			w := httptest.NewRecorder()
+			Enroll(logging.NewResponseLogger(w), req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.statusCode {
+				t.Fatalf("Enroll StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+			}
+			if tt.statusCode >= http.StatusBadRequest {
+				return
+			}
+			if cc := res.Header.Get("Cache-Control"); cc != "no-store" {
+				t.Errorf("Enroll Cache-Control = %q, wants no-store", cc)
+			}
+			var resp EnrollResponse
+			if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if !resp.ServerPEM.Equal(chain[0]) || !resp.CaPEM.Equal(chain[1]) || len(resp.CertChainPEM) != 2 {
+				t.Errorf("Enroll certificates do not match")
+			}
+			if strings.Contains(tt.input, "pkcs12") {
+				if len(resp.PKCS12) == 0 || resp.KeyPEM != "" {
+					t.Errorf("Enroll PKCS12 = %x, KeyPEM = %s", resp.PKCS12, resp.KeyPEM)
+				}
+				return
+			}
+			k, err := pemutil.ParseKey([]byte(resp.KeyPEM))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !key.Equal(k) || len(resp.PKCS12) != 0 {
+				t.Errorf("Enroll KeyPEM does not match the key")
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+
+	"github.com/smallstep/certificates/api/render"
+)
+
+// IssuerCertificate returns the certificate of the intermediate CA in DER
+// format, or in PEM format if the pem query parameter is present. This is the
+// endpoint used in the caIssuers URL of the authority information access
+// extension.
+func IssuerCertificate(w http.ResponseWriter, r *http.Request) {
+	crt, err := mustAuthority(r.Context()).GetIssuerCertificate()
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	writeCertificate(w, r, crt)
+}
+
+// CRLSignerCertificate returns the certificate of the delegated signer of the
+// current CRL in DER format, or in PEM format if the pem query parameter is
+// present. This is the endpoint used in the caIssuers URL of the authority
+// information access extension of the CRL.
+func CRLSignerCertificate(w http.ResponseWriter, r *http.Request) {
+	crt, err := mustAuthority(r.Context()).GetCRLSignerCertificate()
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	writeCertificate(w, r, crt)
+}
+
+func writeCertificate(w http.ResponseWriter, r *http.Request, crt *x509.Certificate) {
+	if _, formatAsPEM := r.URL.Query()["pem"]; formatAsPEM {
+		w.Header().Add("Content-Type", "application/x-pem-file")
+		_ = pem.Encode(w, &pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: crt.Raw,
+		})
+	} else {
+		w.Header().Add("Content-Type", "application/pkix-cert")
+		w.Write(crt.Raw)
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/errs"
+)
+
+// maxOCSPRequestSize is the maximum size of an OCSP request.
+const maxOCSPRequestSize = 64 * 1024
+
+// OCSP is an HTTP handler that returns the OCSP response for the OCSP
+// request, as defined in RFC 6960 appendix A. The request is the DER encoded
+// body of POST requests, or it is base64 encoded in the path of GET requests.
+// The responses to GET requests include the caching headers defined in RFC
+// 5019.
+func OCSP(w http.ResponseWriter, r *http.Request) {
+	var (
+		s   string
+		der []byte
+		err error
+	)
+	if r.Method == http.MethodGet {
+		if s, err = url.PathUnescape(chi.URLParam(r, "*")); err != nil {
+			render.Error(w, errs.BadRequestErr(err, "error decoding ocsp request"))
+			return
+		}
+		if der, err = base64.StdEncoding.DecodeString(s); err != nil {
+			render.Error(w, errs.BadRequestErr(err, "error decoding ocsp request"))
+			return
+		}
+	} else {
+		if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/ocsp-request" {
+			render.Error(w, errs.BadRequest("content type must be application/ocsp-request"))
+			return
+		}
+		if der, err = io.ReadAll(io.LimitReader(r.Body, maxOCSPRequestSize+1)); err != nil {
+			render.Error(w, errs.BadRequestErr(err, "error reading request body"))
+			return
+		}
+	}
+	if len(der) > maxOCSPRequestSize {
+		render.Error(w, errs.BadRequest("request body is too large"))
+		return
+	}
+
+	resp, err := mustAuthority(r.Context()).RespondOCSP(der)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	if r.Method == http.MethodGet && !resp.NextUpdate.IsZero() {
+		if maxAge := int(time.Until(resp.NextUpdate).Seconds()); maxAge > 0 {
+			w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d, public, no-transform, must-revalidate", maxAge))
+		}
+		w.Header().Set("Last-Modified", resp.ThisUpdate.UTC().Format(http.TimeFormat))
+		w.Header().Set("Expires", resp.NextUpdate.UTC().Format(http.TimeFormat))
+	}
+	w.Write(resp.Response)
+}
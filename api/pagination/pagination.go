@@ -0,0 +1,193 @@
+// Package pagination implements the cursor based pagination, sorting and
+// filtering used by the list endpoints.
+//
+// A list request accepts the following query parameters:
+//
+//   - cursor: the cursor returned by the previous page.
+//   - limit: the maximum number of items in the page.
+//   - sort: the field used to sort the list, prefixed with "-" to use a
+//     descending order.
+//   - any of the fields supported by the endpoint to filter the list, if a
+//     field is repeated the items matching any of the values are returned.
+//
+// The cursor of the next page is returned in the response, and in a Link
+// header with the relation "next".
+package pagination
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Default and maximum number of items in a page.
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Error is the error returned if the options of a request are not valid.
+// The message can be returned to the client.
+type Error struct {
+	Message string
+	Err     error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Options are the pagination, sorting and filtering options of a list
+// request.
+type Options struct {
+	Cursor  string
+	Limit   int
+	Sort    string
+	Desc    bool
+	Filters url.Values
+}
+
+// Parse parses the options in the query params of the request. The sort and
+// filter fields are the fields supported by the endpoint. The returned error
+// is an *Error.
+func Parse(r *http.Request, sortFields, filterFields []string) (*Options, error) {
+	q := r.URL.Query()
+	o := &Options{
+		Cursor:  q.Get("cursor"),
+		Filters: url.Values{},
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, &Error{Message: fmt.Sprintf("limit '%s' is not an integer", v), Err: err}
+		}
+		o.Limit = limit
+	}
+	if v := q.Get("sort"); v != "" {
+		o.Sort, o.Desc = strings.TrimPrefix(v, "-"), strings.HasPrefix(v, "-")
+		if !slices.Contains(sortFields, o.Sort) {
+			return nil, &Error{Message: fmt.Sprintf("sort field '%s' is not supported", o.Sort)}
+		}
+	}
+	for _, field := range filterFields {
+		if values, ok := q[field]; ok {
+			o.Filters[field] = values
+		}
+	}
+	return o, nil
+}
+
+// IsDefault returns true if the list is not sorted or filtered.
+func (o *Options) IsDefault() bool {
+	return o.Sort == "" && len(o.Filters) == 0
+}
+
+// Match returns true if the value matches the filter of the given field, or
+// if the field is not filtered.
+func (o *Options) Match(field, value string) bool {
+	values, ok := o.Filters[field]
+	return !ok || slices.Contains(values, value)
+}
+
+// MatchAny returns true if any of the values matches the filter of the given
+// field, or if the field is not filtered.
+func (o *Options) MatchAny(field string, values []string) bool {
+	if _, ok := o.Filters[field]; !ok {
+		return true
+	}
+	for _, v := range values {
+		if o.Match(field, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the items matching the given function.
+func Filter[T any](items []T, match func(T) bool) []T {
+	ret := make([]T, 0, len(items))
+	for _, item := range items {
+		if match(item) {
+			ret = append(ret, item)
+		}
+	}
+	return ret
+}
+
+// Sort sorts the items using the less function of the sort field. The
+// order of the items is kept if the list is not sorted.
+func Sort[T any](items []T, o *Options, less map[string]func(a, b T) bool) {
+	fn, ok := less[o.Sort]
+	if !ok {
+		return
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if o.Desc {
+			return fn(items[j], items[i])
+		}
+		return fn(items[i], items[j])
+	})
+}
+
+// Paginate returns the page of the items starting at the item with the
+// cursor as id, and the cursor of the next page. The cursor of the next page
+// is empty if there are no more items.
+func Paginate[T any](items []T, o *Options, id func(T) string) ([]T, string, error) {
+	limit := o.Limit
+	switch {
+	case limit <= 0:
+		limit = DefaultLimit
+	case limit > MaxLimit:
+		limit = MaxLimit
+	}
+
+	var start int
+	if o.Cursor != "" {
+		start = slices.IndexFunc(items, func(item T) bool {
+			return id(item) == o.Cursor
+		})
+		if start == -1 {
+			return nil, "", &Error{Message: fmt.Sprintf("cursor '%s' is not valid", o.Cursor)}
+		}
+	}
+
+	end := start + limit
+	if end >= len(items) {
+		return items[start:], "", nil
+	}
+	return items[start:end], id(items[end]), nil
+}
+
+// SetLinkHeader adds the Link header with the url of the next page to the
+// response. The url is the one in the request with the given cursor.
+func SetLinkHeader(w http.ResponseWriter, r *http.Request, nextCursor string) {
+	if nextCursor == "" {
+		return
+	}
+	u := &url.URL{
+		Scheme: "https",
+		Host:   r.Host,
+		Path:   r.URL.Path,
+	}
+	if r.TLS == nil {
+		u.Scheme = "http"
+	}
+	q := r.URL.Query()
+	q.Set("cursor", nextCursor)
+	u.RawQuery = q.Encode()
+	w.Header().Add("Link", fmt.Sprintf("<%s>;rel=%q", u.String(), "next"))
+}
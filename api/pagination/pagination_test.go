@@ -0,0 +1,122 @@
+package pagination
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    *Options
+		wantErr string
+	}{
+		{"ok/empty", "", &Options{Filters: url.Values{}}, ""},
+		{"ok", "cursor=abc&limit=10&sort=-name&type=JWK&type=ACME&other=foo", &Options{
+			Cursor: "abc", Limit: 10, Sort: "name", Desc: true, Filters: url.Values{"type": {"JWK", "ACME"}},
+		}, ""},
+		{"ok/asc", "sort=name", &Options{Sort: "name", Filters: url.Values{}}, ""},
+		{"fail/limit", "limit=ten", nil, "limit 'ten' is not an integer: strconv.Atoi: parsing \"ten\": invalid syntax"},
+		{"fail/sort", "sort=-id", nil, "sort field 'id' is not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/provisioners?"+tt.query, nil)
+			got, err := Parse(r, []string{"name", "type"}, []string{"name", "type"})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOptions_Match(t *testing.T) {
+	o := &Options{Filters: url.Values{"type": {"JWK", "ACME"}}}
+	assert.True(t, o.Match("type", "JWK"))
+	assert.True(t, o.Match("type", "ACME"))
+	assert.False(t, o.Match("type", "OIDC"))
+	assert.True(t, o.Match("name", "anything"))
+	assert.True(t, o.MatchAny("type", []string{"OIDC", "ACME"}))
+	assert.False(t, o.MatchAny("type", []string{"OIDC"}))
+	assert.False(t, o.MatchAny("type", nil))
+	assert.True(t, o.MatchAny("name", nil))
+	assert.False(t, o.IsDefault())
+	assert.True(t, (&Options{}).IsDefault())
+}
+
+func TestSort(t *testing.T) {
+	less := map[string]func(a, b int) bool{
+		"value": func(a, b int) bool { return a < b },
+	}
+	items := []int{3, 1, 2}
+	Sort(items, &Options{}, less)
+	assert.Equal(t, []int{3, 1, 2}, items)
+	Sort(items, &Options{Sort: "value"}, less)
+	assert.Equal(t, []int{1, 2, 3}, items)
+	Sort(items, &Options{Sort: "value", Desc: true}, less)
+	assert.Equal(t, []int{3, 2, 1}, items)
+	assert.Equal(t, []int{2}, Filter(items, func(i int) bool { return i%2 == 0 }))
+}
+
+func TestPaginate(t *testing.T) {
+	items := make([]int, 150)
+	for i := range items {
+		items[i] = i
+	}
+	id := strconv.Itoa
+
+	tests := []struct {
+		name     string
+		opts     *Options
+		wantLen  int
+		wantNext string
+		wantErr  string
+	}{
+		{"ok/default", &Options{}, DefaultLimit, "20", ""},
+		{"ok/cursor", &Options{Cursor: "20", Limit: 10}, 10, "30", ""},
+		{"ok/max", &Options{Limit: 1000}, MaxLimit, "100", ""},
+		{"ok/last", &Options{Cursor: "140", Limit: 10}, 10, "", ""},
+		{"ok/partial", &Options{Cursor: "145", Limit: 10}, 5, "", ""},
+		{"fail/cursor", &Options{Cursor: "foo"}, 0, "", "cursor 'foo' is not valid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, next, err := Paginate(items, tt.opts, id)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, page, tt.wantLen)
+			assert.Equal(t, tt.wantNext, next)
+			if tt.opts.Cursor != "" {
+				assert.Equal(t, tt.opts.Cursor, id(page[0]))
+			}
+		})
+	}
+}
+
+func TestSetLinkHeader(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "https://ca.example.com/admin/admins?limit=10&cursor=old", nil)
+	r.TLS = &tls.ConnectionState{}
+	SetLinkHeader(w, r, "next")
+	assert.Equal(t, `<https://ca.example.com/admin/admins?cursor=next&limit=10>;rel="next"`, w.Header().Get("Link"))
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "http://ca.example.com/provisioners", nil)
+	SetLinkHeader(w, r, "")
+	assert.Empty(t, w.Header().Get("Link"))
+	SetLinkHeader(w, r, "abc")
+	assert.Equal(t, `<http://ca.example.com/provisioners?cursor=abc>;rel="next"`, w.Header().Get("Link"))
+}
@@ -32,6 +32,11 @@ func Renew(w http.ResponseWriter, r *http.Request) {
 		ctx = authority.NewTokenContext(ctx, token)
 	}
 
+	// The intermediates are used to verify certificates of federated peers.
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 1 {
+		ctx = authority.NewIntermediatesContext(ctx, r.TLS.PeerCertificates[1:])
+	}
+
 	a := mustAuthority(ctx)
 	certChain, err := a.RenewContext(ctx, cert, nil)
 	if err != nil {
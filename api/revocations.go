@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/errs"
+)
+
+// Long polling limits of the revocation events endpoint.
+var (
+	defaultRevocationEventsWait = 30 * time.Second
+	maxRevocationEventsWait     = 60 * time.Second
+)
+
+// RevocationEvents returns the revocation events after the one in the since
+// query parameter. If there are no new events, the request waits until a
+// certificate is revoked or the time in the wait query parameter is over.
+func RevocationEvents(w http.ResponseWriter, r *http.Request) {
+	var since uint64
+	query := r.URL.Query()
+	if s := query.Get("since"); s != "" {
+		var err error
+		if since, err = strconv.ParseUint(s, 10, 64); err != nil {
+			render.Error(w, errs.BadRequestErr(err, "error parsing since query param"))
+			return
+		}
+	}
+
+	wait := defaultRevocationEventsWait
+	if s := query.Get("wait"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			render.Error(w, errs.BadRequest("error parsing wait query param"))
+			return
+		}
+		wait = d
+	}
+	if wait > maxRevocationEventsWait {
+		wait = maxRevocationEventsWait
+	}
+
+	// Extend the write timeout of the server for the long polling request.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + 5*time.Second))
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+
+	page, err := mustAuthority(r.Context()).GetRevocationEvents(ctx, since)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, page)
+}
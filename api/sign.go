@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"go.step.sm/crypto/x509util"
+
 	"github.com/smallstep/certificates/api/read"
 	"github.com/smallstep/certificates/api/render"
 	"github.com/smallstep/certificates/authority/config"
@@ -14,11 +16,15 @@ import (
 
 // SignRequest is the request body for a certificate signature request.
 type SignRequest struct {
-	CsrPEM       CertificateRequest `json:"csr"`
-	OTT          string             `json:"ott"`
-	NotAfter     TimeDuration       `json:"notAfter,omitempty"`
-	NotBefore    TimeDuration       `json:"notBefore,omitempty"`
-	TemplateData json.RawMessage    `json:"templateData,omitempty"`
+	CsrPEM       CertificateRequest    `json:"csr"`
+	OTT          string                `json:"ott"`
+	NotAfter     TimeDuration          `json:"notAfter,omitempty"`
+	NotBefore    TimeDuration          `json:"notBefore,omitempty"`
+	Validity     *provisioner.Duration `json:"validity,omitempty"`
+	TemplateData json.RawMessage       `json:"templateData,omitempty"`
+	KeyUsage     x509util.KeyUsage     `json:"keyUsage,omitempty"`
+	ExtKeyUsage  x509util.ExtKeyUsage  `json:"extKeyUsage,omitempty"`
+	DryRun       bool                  `json:"dryRun,omitempty"`
 }
 
 // Validate checks the fields of the SignRequest and returns nil if they are ok
@@ -33,6 +39,14 @@ func (s *SignRequest) Validate() error {
 	if s.OTT == "" {
 		return errs.BadRequest("missing ott")
 	}
+	if s.Validity != nil {
+		if s.Validity.Duration <= 0 {
+			return errs.BadRequest("validity must be greater than 0")
+		}
+		if !s.NotAfter.IsZero() {
+			return errs.BadRequest("validity cannot be used with notAfter")
+		}
+	}
 
 	return nil
 }
@@ -46,9 +60,20 @@ type SignResponse struct {
 	TLS          *tls.ConnectionState `json:"-"`
 }
 
+// SignDryRunResponse is the response object of a certificate signature
+// request in dry-run mode. No certificate is signed, TBSCertificate is the DER
+// encoded to-be-signed certificate of RFC 5280 that the authority would sign.
+type SignDryRunResponse struct {
+	TBSCertificate []byte `json:"tbsCertificate"`
+}
+
 // Sign is an HTTP handler that reads a certificate request and an
 // one-time-token (ott) from the body and creates a new certificate with the
 // information in the certificate request.
+//
+// If dryRun is set, the templates and policies are evaluated but the
+// certificate is not signed nor stored, and the response is a
+// SignDryRunResponse. The one-time-token is still consumed.
 func Sign(w http.ResponseWriter, r *http.Request) {
 	var body SignRequest
 	if err := read.JSON(r.Body, &body); err != nil {
@@ -65,20 +90,38 @@ func Sign(w http.ResponseWriter, r *http.Request) {
 	opts := provisioner.SignOptions{
 		NotBefore:    body.NotBefore,
 		NotAfter:     body.NotAfter,
+		Validity:     body.Validity.Value(),
 		TemplateData: body.TemplateData,
+		KeyUsage:     body.KeyUsage,
+		ExtKeyUsage:  body.ExtKeyUsage,
 	}
 
 	ctx := r.Context()
 	a := mustAuthority(ctx)
 
 	ctx = provisioner.NewContextWithMethod(ctx, provisioner.SignMethod)
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		ctx = provisioner.NewContextWithClientCertificate(ctx, r.TLS.PeerCertificates[0])
+	}
 	signOpts, err := a.Authorize(ctx, body.OTT)
 	if err != nil {
 		render.Error(w, errs.UnauthorizedErr(err))
 		return
 	}
 
-	certChain, err := a.Sign(body.CsrPEM.CertificateRequest, opts, signOpts...)
+	if body.DryRun {
+		tbs, err := a.SignDryRun(ctx, body.CsrPEM.CertificateRequest, opts, signOpts...)
+		if err != nil {
+			render.Error(w, errs.ForbiddenErr(err, "error signing certificate"))
+			return
+		}
+		render.JSON(w, &SignDryRunResponse{
+			TBSCertificate: tbs,
+		})
+		return
+	}
+
+	certChain, err := a.SignWithContext(ctx, body.CsrPEM.CertificateRequest, opts, signOpts...)
 	if err != nil {
 		render.Error(w, errs.ForbiddenErr(err, "error signing certificate"))
 		return
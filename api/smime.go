@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+)
+
+// SMIMECodeRequest is the request body used to send a one-time code to an
+// email address.
+type SMIMECodeRequest struct {
+	Email string `json:"email"`
+}
+
+// Validate checks the fields of the SMIMECodeRequest.
+func (s *SMIMECodeRequest) Validate() error {
+	if s.Email == "" {
+		return errs.BadRequest("missing email")
+	}
+	return nil
+}
+
+// SMIMESignRequest is the request body used to get an S/MIME certificate. The
+// ownership of the email address is proven with the one-time code sent to it,
+// or with an ID token of a trusted OIDC provider.
+type SMIMESignRequest struct {
+	CsrPEM CertificateRequest `json:"csr"`
+	Email  string             `json:"email,omitempty"`
+	Code   string             `json:"code,omitempty"`
+	Token  string             `json:"token,omitempty"`
+}
+
+// Validate checks the fields of the SMIMESignRequest.
+func (s *SMIMESignRequest) Validate() error {
+	switch {
+	case s.CsrPEM.CertificateRequest == nil:
+		return errs.BadRequest("missing csr")
+	case s.Code == "" && s.Token == "":
+		return errs.BadRequest("missing code or token")
+	case s.Code != "" && s.Token != "":
+		return errs.BadRequest("code and token cannot be used together")
+	case s.Code != "" && s.Email == "":
+		return errs.BadRequest("missing email")
+	}
+	if err := s.CsrPEM.CertificateRequest.CheckSignature(); err != nil {
+		return errs.BadRequestErr(err, "invalid csr")
+	}
+	return nil
+}
+
+// SMIMECode is an HTTP handler that sends a one-time code to the email address
+// in the body. The code can be used to get an S/MIME certificate.
+func SMIMECode(w http.ResponseWriter, r *http.Request) {
+	var body SMIMECodeRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, errs.BadRequestErr(err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	if err := mustAuthority(r.Context()).SendSMIMECode(r.Context(), body.Email); err != nil {
+		render.Error(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// SMIMESign is an HTTP handler that reads a certificate request and the proof
+// of ownership of an email address, and creates a new S/MIME certificate for
+// it.
+func SMIMESign(w http.ResponseWriter, r *http.Request) {
+	var body SMIMESignRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, errs.BadRequestErr(err, "error reading request body"))
+		return
+	}
+	if body.Token != "" {
+		logOtt(w, body.Token)
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	ctx := provisioner.NewContextWithMethod(r.Context(), provisioner.SignMethod)
+	a := mustAuthority(ctx)
+	certChain, err := a.SignSMIME(ctx, body.CsrPEM.CertificateRequest, body.Email, body.Code, body.Token)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	certChainPEM := certChainToPEM(certChain)
+	var caPEM Certificate
+	if len(certChainPEM) > 1 {
+		caPEM = certChainPEM[1]
+	}
+
+	LogCertificate(w, certChain[0])
+	render.JSONStatus(w, &SignResponse{
+		ServerPEM:    certChainPEM[0],
+		CaPEM:        caPEM,
+		CertChainPEM: certChainPEM,
+	}, http.StatusCreated)
+}
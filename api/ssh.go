@@ -36,16 +36,17 @@ type SSHAuthority interface {
 
 // SSHSignRequest is the request body of an SSH certificate request.
 type SSHSignRequest struct {
-	PublicKey        []byte             `json:"publicKey"` // base64 encoded
-	OTT              string             `json:"ott"`
-	CertType         string             `json:"certType,omitempty"`
-	KeyID            string             `json:"keyID,omitempty"`
-	Principals       []string           `json:"principals,omitempty"`
-	ValidAfter       TimeDuration       `json:"validAfter,omitempty"`
-	ValidBefore      TimeDuration       `json:"validBefore,omitempty"`
-	AddUserPublicKey []byte             `json:"addUserPublicKey,omitempty"`
-	IdentityCSR      CertificateRequest `json:"identityCSR,omitempty"`
-	TemplateData     json.RawMessage    `json:"templateData,omitempty"`
+	PublicKey        []byte                `json:"publicKey"` // base64 encoded
+	OTT              string                `json:"ott"`
+	CertType         string                `json:"certType,omitempty"`
+	KeyID            string                `json:"keyID,omitempty"`
+	Principals       []string              `json:"principals,omitempty"`
+	ValidAfter       TimeDuration          `json:"validAfter,omitempty"`
+	ValidBefore      TimeDuration          `json:"validBefore,omitempty"`
+	Validity         *provisioner.Duration `json:"validity,omitempty"`
+	AddUserPublicKey []byte                `json:"addUserPublicKey,omitempty"`
+	IdentityCSR      CertificateRequest    `json:"identityCSR,omitempty"`
+	TemplateData     json.RawMessage       `json:"templateData,omitempty"`
 }
 
 // Validate validates the SSHSignRequest.
@@ -57,6 +58,10 @@ func (s *SSHSignRequest) Validate() error {
 		return errs.BadRequest("missing or empty publicKey")
 	case s.OTT == "":
 		return errs.BadRequest("missing or empty ott")
+	case s.Validity != nil && s.Validity.Duration <= 0:
+		return errs.BadRequest("validity must be greater than 0")
+	case s.Validity != nil && !s.ValidBefore.IsZero():
+		return errs.BadRequest("validity cannot be used with validBefore")
 	default:
 		// Validate identity signature if provided
 		if s.IdentityCSR.CertificateRequest != nil {
@@ -284,6 +289,7 @@ func SSHSign(w http.ResponseWriter, r *http.Request) {
 		Principals:   body.Principals,
 		ValidBefore:  body.ValidBefore,
 		ValidAfter:   body.ValidAfter,
+		Validity:     body.Validity.Value(),
 		TemplateData: body.TemplateData,
 	}
 
@@ -330,7 +336,7 @@ func SSHSign(w http.ResponseWriter, r *http.Request) {
 			NotAfter:  time.Unix(int64(cert.ValidBefore), 0),
 		})
 
-		certChain, err := a.Sign(cr, provisioner.SignOptions{}, signOpts...)
+		certChain, err := a.SignWithContext(ctx, cr, provisioner.SignOptions{}, signOpts...)
 		if err != nil {
 			render.Error(w, errs.ForbiddenErr(err, "error signing identity certificate"))
 			return
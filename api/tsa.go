@@ -0,0 +1,40 @@
+package api
+
+import (
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/errs"
+)
+
+// maxTimestampRequestSize is the maximum size of an RFC 3161 time-stamp
+// request.
+const maxTimestampRequestSize = 64 * 1024
+
+// Timestamp is an HTTP handler that returns an RFC 3161 time-stamp response
+// for the time-stamp request in the body.
+func Timestamp(w http.ResponseWriter, r *http.Request) {
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/timestamp-query" {
+		render.Error(w, errs.BadRequest("content type must be application/timestamp-query"))
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxTimestampRequestSize+1))
+	if err != nil {
+		render.Error(w, errs.BadRequestErr(err, "error reading request body"))
+		return
+	}
+	if len(body) > maxTimestampRequestSize {
+		render.Error(w, errs.BadRequest("request body is too large"))
+		return
+	}
+
+	resp, err := mustAuthority(r.Context()).Timestamp(body)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/timestamp-reply")
+	w.Write(resp)
+}
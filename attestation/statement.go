@@ -0,0 +1,302 @@
+package attestation
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"strconv"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/pkg/errors"
+	"github.com/smallstep/go-attestation/attest"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/x509util"
+)
+
+// Attestation statement formats supported by VerifyStatement.
+const (
+	// FormatApple is the format of the Apple managed device attestations.
+	FormatApple = "apple"
+	// FormatStep is the format of the attestation certificates of devices
+	// like the PIV interface on YubiKeys.
+	FormatStep = "step"
+	// FormatTPM is the format of keys certified by a TPM attestation key.
+	FormatTPM = "tpm"
+	// FormatAndroidKey is the format of the Android hardware-backed key
+	// attestations.
+	FormatAndroidKey = "android-key"
+)
+
+var (
+	oidAppleSerialNumber           = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 8, 9, 1}
+	oidAppleUniqueDeviceIdentifier = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 8, 9, 2}
+	oidYubicoSerialNumber          = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 41482, 3, 7}
+	oidAndroidKeyDescription       = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 1, 17}
+)
+
+// androidAttestationIDSerial is the tag of the attestationIdSerial field in
+// the Android AuthorizationList.
+const androidAttestationIDSerial = 713
+
+// Android security levels, keys generated in software are not accepted.
+const (
+	androidSecurityLevelTrustedEnvironment asn1.Enumerated = 1
+	androidSecurityLevelStrongBox          asn1.Enumerated = 2
+)
+
+// Statement is an attestation statement using the WebAuthn attestation object
+// encoding, the same one used in the device-attest-01 ACME challenge.
+type Statement struct {
+	Format       string                 `json:"fmt"`
+	AttStatement map[string]interface{} `json:"attStmt,omitempty"`
+}
+
+// ParseStatement parses a CBOR encoded attestation object.
+func ParseStatement(attObj []byte) (*Statement, error) {
+	st := new(Statement)
+	if err := cbor.Unmarshal(attObj, st); err != nil {
+		return nil, errors.Wrap(err, "error parsing attestation object")
+	}
+	if st.Format == "" {
+		return nil, errors.New("attestation object format cannot be empty")
+	}
+	return st, nil
+}
+
+// VerifyOptions are the options used to verify an attestation statement.
+type VerifyOptions struct {
+	// Roots are the roots used to verify the attestation certificates.
+	Roots *x509.CertPool
+	// CurrentTime is the time used to verify the certificates, it defaults to
+	// the current time.
+	CurrentTime time.Time
+}
+
+// KeyAttestation contains the information of a key verified by an
+// attestation statement.
+type KeyAttestation struct {
+	// Format is the format of the attestation statement.
+	Format string
+	// Certificate is the attestation certificate, or the certificate of the
+	// attestation key in TPM attestations.
+	Certificate    *x509.Certificate
+	VerifiedChains [][]*x509.Certificate
+	// PublicKey is the attested key, and Fingerprint its fingerprint.
+	PublicKey   crypto.PublicKey
+	Fingerprint string
+	// PermanentIdentifiers are the device identifiers included in the
+	// attestation, like serial numbers.
+	PermanentIdentifiers []string
+}
+
+// VerifyStatement verifies the given attestation statement and returns the
+// attested key. The statement only proves that the key lives in the device,
+// the proof of possession of the key must be verified by the caller, for
+// example checking the signature of a certificate request.
+func VerifyStatement(st *Statement, opts VerifyOptions) (*KeyAttestation, error) {
+	if st == nil {
+		return nil, errors.New("attestation statement cannot be nil")
+	}
+	if opts.Roots == nil {
+		return nil, errors.New("attestation roots cannot be empty")
+	}
+	if opts.CurrentTime.IsZero() {
+		opts.CurrentTime = time.Now()
+	}
+
+	switch st.Format {
+	case FormatApple, FormatStep, FormatAndroidKey:
+		return verifyCertificateStatement(st, opts)
+	case FormatTPM:
+		return verifyTPMStatement(st, opts)
+	default:
+		return nil, errors.Errorf("unsupported attestation format %q", st.Format)
+	}
+}
+
+// verifyCertificateStatement verifies the formats where the attested key is
+// the key of the attestation certificate.
+func verifyCertificateStatement(st *Statement, opts VerifyOptions) (*KeyAttestation, error) {
+	leaf, chains, err := verifyX5C(st, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var identifiers []string
+	switch st.Format {
+	case FormatApple:
+		for _, ext := range leaf.Extensions {
+			if ext.Id.Equal(oidAppleSerialNumber) || ext.Id.Equal(oidAppleUniqueDeviceIdentifier) {
+				identifiers = append(identifiers, string(ext.Value))
+			}
+		}
+	case FormatStep:
+		for _, ext := range leaf.Extensions {
+			if ext.Id.Equal(oidYubicoSerialNumber) {
+				var serialNumber int
+				if rest, err := asn1.Unmarshal(ext.Value, &serialNumber); err != nil || len(rest) > 0 {
+					return nil, errors.New("error parsing yubikey serial number")
+				}
+				identifiers = append(identifiers, strconv.Itoa(serialNumber))
+			}
+		}
+	case FormatAndroidKey:
+		if identifiers, err = parseAndroidKeyDescription(leaf); err != nil {
+			return nil, err
+		}
+	}
+
+	return newKeyAttestation(st.Format, leaf, chains, leaf.PublicKey, identifiers)
+}
+
+// verifyTPMStatement verifies a key certified by a TPM attestation key. The
+// certificate of the attestation key must be included in the x5c.
+func verifyTPMStatement(st *Statement, opts VerifyOptions) (*KeyAttestation, error) {
+	if ver, _ := st.AttStatement["ver"].(string); ver != "2.0" {
+		return nil, errors.Errorf("tpm version %q is not supported", ver)
+	}
+
+	akCert, chains, err := verifyX5C(st, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !hasEKUValue(akCert, OIDExtKeyUsageAIKCertificate) {
+		return nil, errors.New("ak certificate is missing extended key usage tcg-kp-AIKCertificate (2.23.133.8.3)")
+	}
+
+	var params attest.CertificationParameters
+	var ok bool
+	if params.Public, ok = st.AttStatement["pubArea"].([]byte); !ok || len(params.Public) == 0 {
+		return nil, errors.New("tpm pubArea is not valid")
+	}
+	if params.CreateAttestation, ok = st.AttStatement["certInfo"].([]byte); !ok || len(params.CreateAttestation) == 0 {
+		return nil, errors.New("tpm certInfo is not valid")
+	}
+	if params.CreateSignature, ok = st.AttStatement["sig"].([]byte); !ok || len(params.CreateSignature) == 0 {
+		return nil, errors.New("tpm sig is not valid")
+	}
+	pub, err := VerifyKeyCertification(akCert.PublicKey, params)
+	if err != nil {
+		return nil, errors.Wrap(err, "tpm key certification is not valid")
+	}
+
+	var identifiers []string
+	if sans, err := x509util.ParseSubjectAlternativeNames(akCert); err == nil {
+		for _, pi := range sans.PermanentIdentifiers {
+			identifiers = append(identifiers, pi.Identifier)
+		}
+	}
+
+	return newKeyAttestation(st.Format, akCert, chains, pub, identifiers)
+}
+
+func newKeyAttestation(format string, cert *x509.Certificate, chains [][]*x509.Certificate, pub crypto.PublicKey, identifiers []string) (*KeyAttestation, error) {
+	fp, err := keyutil.Fingerprint(pub)
+	if err != nil {
+		return nil, errors.Wrap(err, "error calculating key fingerprint")
+	}
+	return &KeyAttestation{
+		Format:               format,
+		Certificate:          cert,
+		VerifiedChains:       chains,
+		PublicKey:            pub,
+		Fingerprint:          fp,
+		PermanentIdentifiers: identifiers,
+	}, nil
+}
+
+// verifyX5C parses the x5c of the statement and verifies the first
+// certificate using the rest as intermediates.
+func verifyX5C(st *Statement, opts VerifyOptions) (*x509.Certificate, [][]*x509.Certificate, error) {
+	x5c, ok := st.AttStatement["x5c"].([]interface{})
+	if !ok || len(x5c) == 0 {
+		return nil, nil, errors.New("x5c not present")
+	}
+
+	var leaf *x509.Certificate
+	intermediates := x509.NewCertPool()
+	for i, v := range x5c {
+		der, ok := v.([]byte)
+		if !ok {
+			return nil, nil, errors.New("x5c is malformed")
+		}
+		crt, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "x5c is malformed")
+		}
+		if i == 0 {
+			leaf = crt
+		} else {
+			intermediates.AddCert(crt)
+		}
+	}
+
+	// Attestation key certificates may include a critical SAN extension
+	// with only a directoryName or permanent identifiers.
+	removeUnhandledCriticalExtension(leaf, oidSubjectAlternativeName)
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         opts.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   opts.CurrentTime.Truncate(time.Second),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "x5c is not valid")
+	}
+	return leaf, chains, nil
+}
+
+type androidKeyDescription struct {
+	AttestationVersion       int
+	AttestationSecurityLevel asn1.Enumerated
+	KeymasterVersion         int
+	KeymasterSecurityLevel   asn1.Enumerated
+	AttestationChallenge     []byte
+	UniqueID                 []byte
+	SoftwareEnforced         asn1.RawValue
+	TeeEnforced              asn1.RawValue
+}
+
+// parseAndroidKeyDescription checks that the key in the certificate was
+// generated in a trusted environment or strongbox, and returns the serial
+// number of the device if ID attestation was used.
+func parseAndroidKeyDescription(leaf *x509.Certificate) ([]string, error) {
+	var desc *androidKeyDescription
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(oidAndroidKeyDescription) {
+			desc = new(androidKeyDescription)
+			if rest, err := asn1.Unmarshal(ext.Value, desc); err != nil || len(rest) > 0 {
+				return nil, errors.New("error parsing android key description")
+			}
+			break
+		}
+	}
+	if desc == nil {
+		return nil, errors.New("android key description not present")
+	}
+	for _, level := range []asn1.Enumerated{desc.AttestationSecurityLevel, desc.KeymasterSecurityLevel} {
+		if level != androidSecurityLevelTrustedEnvironment && level != androidSecurityLevelStrongBox {
+			return nil, errors.New("android key is not hardware-backed")
+		}
+	}
+
+	var identifiers []string
+	rest := desc.TeeEnforced.Bytes
+	for len(rest) > 0 {
+		var v asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &v); err != nil {
+			return nil, errors.New("error parsing android key description")
+		}
+		if v.Class == asn1.ClassContextSpecific && v.Tag == androidAttestationIDSerial {
+			var serial []byte
+			if _, err := asn1.Unmarshal(v.Bytes, &serial); err != nil {
+				return nil, errors.New("error parsing android attestation serial number")
+			}
+			identifiers = append(identifiers, string(serial))
+		}
+	}
+	return identifiers, nil
+}
@@ -0,0 +1,211 @@
+package attestation
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+)
+
+func mustAttestationCertificate(t *testing.T, ca *minica.CA, exts ...pkix.Extension) *x509.Certificate {
+	t.Helper()
+	signer, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := ca.Sign(&x509.Certificate{
+		Subject:         pkix.Name{CommonName: "Attestation"},
+		PublicKey:       signer.Public(),
+		ExtraExtensions: exts,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+func mustExtension(t *testing.T, oid asn1.ObjectIdentifier, v interface{}) pkix.Extension {
+	t.Helper()
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.Extension{Id: oid, Value: b}
+}
+
+func mustAndroidKeyDescription(t *testing.T, level asn1.Enumerated, serial string) pkix.Extension {
+	t.Helper()
+	var tee []byte
+	if serial != "" {
+		v, err := asn1.Marshal([]byte(serial))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tee, err = asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: androidAttestationIDSerial, IsCompound: true, Bytes: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mustExtension(t, oidAndroidKeyDescription, androidKeyDescription{
+		AttestationVersion:       4,
+		AttestationSecurityLevel: level,
+		KeymasterVersion:         4,
+		KeymasterSecurityLevel:   level,
+		AttestationChallenge:     []byte("challenge"),
+		UniqueID:                 []byte{},
+		SoftwareEnforced:         asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true},
+		TeeEnforced:              asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: tee},
+	})
+}
+
+func TestParseStatement(t *testing.T) {
+	valid, err := cbor.Marshal(Statement{Format: FormatStep, AttStatement: map[string]interface{}{"x5c": []interface{}{[]byte{1, 2, 3}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := cbor.Marshal(Statement{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := ParseStatement(valid)
+	if err != nil {
+		t.Fatalf("ParseStatement() error = %v", err)
+	}
+	if x5c, ok := st.AttStatement["x5c"].([]interface{}); st.Format != FormatStep || !ok || len(x5c) != 1 {
+		t.Errorf("ParseStatement() = %v", st)
+	}
+	if _, err := ParseStatement([]byte("not cbor")); err == nil {
+		t.Error("ParseStatement() error = nil")
+	}
+	if _, err := ParseStatement(empty); err == nil {
+		t.Error("ParseStatement() error = nil")
+	}
+}
+
+func TestVerifyStatement(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Root)
+	otherCA, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(otherCA.Root)
+
+	apple := mustAttestationCertificate(t, ca,
+		pkix.Extension{Id: oidAppleSerialNumber, Value: []byte("SERIAL")},
+		pkix.Extension{Id: oidAppleUniqueDeviceIdentifier, Value: []byte("UDID")},
+	)
+	yubikey := mustAttestationCertificate(t, ca, mustExtension(t, oidYubicoSerialNumber, 112233))
+	android := mustAttestationCertificate(t, ca, mustAndroidKeyDescription(t, androidSecurityLevelStrongBox, "ANDROID-SERIAL"))
+	androidNoSerial := mustAttestationCertificate(t, ca, mustAndroidKeyDescription(t, androidSecurityLevelTrustedEnvironment, ""))
+	androidSoftware := mustAttestationCertificate(t, ca, mustAndroidKeyDescription(t, 0, "ANDROID-SERIAL"))
+	plain := mustAttestationCertificate(t, ca)
+
+	statement := func(format string, crt ...*x509.Certificate) *Statement {
+		x5c := make([]interface{}, len(crt))
+		for i, c := range crt {
+			x5c[i] = c.Raw
+		}
+		return &Statement{Format: format, AttStatement: map[string]interface{}{"x5c": x5c}}
+	}
+
+	tests := []struct {
+		name            string
+		st              *Statement
+		roots           *x509.CertPool
+		wantCert        *x509.Certificate
+		wantIdentifiers []string
+		wantErr         bool
+	}{
+		{"ok apple", statement(FormatApple, apple, ca.Intermediate), roots, apple, []string{"SERIAL", "UDID"}, false},
+		{"ok step", statement(FormatStep, yubikey, ca.Intermediate), roots, yubikey, []string{"112233"}, false},
+		{"ok android-key", statement(FormatAndroidKey, android, ca.Intermediate), roots, android, []string{"ANDROID-SERIAL"}, false},
+		{"ok android-key no serial", statement(FormatAndroidKey, androidNoSerial, ca.Intermediate), roots, androidNoSerial, nil, false},
+		{"fail nil", nil, roots, nil, nil, true},
+		{"fail roots", statement(FormatStep, yubikey, ca.Intermediate), nil, nil, nil, true},
+		{"fail format", statement("packed", yubikey, ca.Intermediate), roots, nil, nil, true},
+		{"fail x5c", &Statement{Format: FormatStep}, roots, nil, nil, true},
+		{"fail x5c malformed", &Statement{Format: FormatStep, AttStatement: map[string]interface{}{"x5c": []interface{}{"foo"}}}, roots, nil, nil, true},
+		{"fail x5c certificate", &Statement{Format: FormatStep, AttStatement: map[string]interface{}{"x5c": []interface{}{[]byte("foo")}}}, roots, nil, nil, true},
+		{"fail chain", statement(FormatStep, yubikey), roots, nil, nil, true},
+		{"fail other roots", statement(FormatStep, yubikey, ca.Intermediate), otherRoots, nil, nil, true},
+		{"fail android-key description", statement(FormatAndroidKey, plain, ca.Intermediate), roots, nil, nil, true},
+		{"fail android-key software", statement(FormatAndroidKey, androidSoftware, ca.Intermediate), roots, nil, nil, true},
+		{"fail tpm version", statement(FormatTPM, plain, ca.Intermediate), roots, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyStatement(tt.st, VerifyOptions{Roots: tt.roots})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyStatement() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			fp, err := keyutil.Fingerprint(tt.wantCert.PublicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Format != tt.st.Format || got.Certificate != nil && !got.Certificate.Equal(tt.wantCert) || got.Fingerprint != fp {
+				t.Errorf("VerifyStatement() = %v", got)
+			}
+			if len(got.PermanentIdentifiers) != len(tt.wantIdentifiers) {
+				t.Fatalf("VerifyStatement() PermanentIdentifiers = %v, want %v", got.PermanentIdentifiers, tt.wantIdentifiers)
+			}
+			for i, id := range tt.wantIdentifiers {
+				if got.PermanentIdentifiers[i] != id {
+					t.Errorf("VerifyStatement() PermanentIdentifiers = %v, want %v", got.PermanentIdentifiers, tt.wantIdentifiers)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyStatement_tpm(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Root)
+	ak := mustAttestationCertificate(t, ca, mustExtension(t, oidExtensionExtendedKeyUsage, []asn1.ObjectIdentifier{OIDExtKeyUsageAIKCertificate}))
+	notAK := mustAttestationCertificate(t, ca)
+
+	statement := func(crt *x509.Certificate, params map[string]interface{}) *Statement {
+		st := &Statement{Format: FormatTPM, AttStatement: map[string]interface{}{
+			"ver": "2.0",
+			"x5c": []interface{}{crt.Raw, ca.Intermediate.Raw},
+		}}
+		for k, v := range params {
+			st.AttStatement[k] = v
+		}
+		return st
+	}
+
+	tests := []struct {
+		name string
+		st   *Statement
+	}{
+		{"fail eku", statement(notAK, nil)},
+		{"fail pubArea", statement(ak, nil)},
+		{"fail certInfo", statement(ak, map[string]interface{}{"pubArea": []byte{1}})},
+		{"fail sig", statement(ak, map[string]interface{}{"pubArea": []byte{1}, "certInfo": []byte{1}})},
+		{"fail certification", statement(ak, map[string]interface{}{"pubArea": []byte{1}, "certInfo": []byte{1}, "sig": []byte{1}})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyStatement(tt.st, VerifyOptions{Roots: roots}); err == nil {
+				t.Error("VerifyStatement() error = nil")
+			}
+		})
+	}
+}
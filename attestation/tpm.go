@@ -0,0 +1,274 @@
+// Package attestation implements the verification of hardware attestations
+// used to bind the keys in the certificates issued by the CA to a device.
+package attestation
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/go-attestation/attest"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/x509util"
+)
+
+// DefaultActivationLifetime is the default time a client has to complete the
+// credential activation protocol.
+const DefaultActivationLifetime = 5 * time.Minute
+
+var (
+	oidSubjectAlternativeName    = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidExtensionSubjectDirAttrs  = asn1.ObjectIdentifier{2, 5, 29, 9}
+	oidExtensionExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidTCGKpEKCertificate        = asn1.ObjectIdentifier{2, 23, 133, 8, 1}
+)
+
+// OIDExtKeyUsageAIKCertificate is the tcg-kp-AIKCertificate extended key
+// usage that must be present in the certificates of attestation keys.
+var OIDExtKeyUsageAIKCertificate = asn1.ObjectIdentifier{2, 23, 133, 8, 3}
+
+// TPMOptions are the options used to create a TPMVerifier.
+type TPMOptions struct {
+	// EKRoots are the TPM manufacturer roots used to validate EK certificates.
+	EKRoots []*x509.Certificate
+	// EKIntermediates are additional intermediates used to build the chain of
+	// the EK certificates.
+	EKIntermediates []*x509.Certificate
+	// ActivationLifetime is the time a client has to complete the credential
+	// activation. It defaults to DefaultActivationLifetime.
+	ActivationLifetime time.Duration
+}
+
+// TPMVerifier validates TPM endorsement keys against the manufacturer roots,
+// runs the credential activation protocol for attestation keys, and verifies
+// the keys certified by an activated attestation key.
+type TPMVerifier struct {
+	ekRoots            *x509.CertPool
+	ekIntermediates    *x509.CertPool
+	activationLifetime time.Duration
+	now                func() time.Time
+}
+
+// NewTPMVerifier creates a new TPMVerifier with the given options.
+func NewTPMVerifier(opts TPMOptions) (*TPMVerifier, error) {
+	if len(opts.EKRoots) == 0 {
+		return nil, errors.New("tpm verifier requires at least one EK root")
+	}
+	if opts.ActivationLifetime < 0 {
+		return nil, errors.New("tpm verifier activation lifetime cannot be negative")
+	}
+
+	v := &TPMVerifier{
+		ekRoots:            x509.NewCertPool(),
+		ekIntermediates:    x509.NewCertPool(),
+		activationLifetime: opts.ActivationLifetime,
+		now:                time.Now,
+	}
+	if v.activationLifetime == 0 {
+		v.activationLifetime = DefaultActivationLifetime
+	}
+	for _, crt := range opts.EKRoots {
+		v.ekRoots.AddCert(crt)
+	}
+	for _, crt := range opts.EKIntermediates {
+		v.ekIntermediates.AddCert(crt)
+	}
+	return v, nil
+}
+
+// EndorsementKey contains the information of a verified TPM endorsement key.
+type EndorsementKey struct {
+	Certificate    *x509.Certificate
+	VerifiedChains [][]*x509.Certificate
+	PublicKey      crypto.PublicKey
+	Fingerprint    string
+	Manufacturer   string
+	Model          string
+	Version        string
+}
+
+// VerifyEKCertificate verifies the given EK certificate against the
+// configured manufacturer roots, using the given intermediates and the
+// configured ones to build the chain.
+func (v *TPMVerifier) VerifyEKCertificate(ekCert *x509.Certificate, intermediates []*x509.Certificate) (*EndorsementKey, error) {
+	if ekCert == nil {
+		return nil, errors.New("ek certificate cannot be nil")
+	}
+
+	// EK certificates usually include a critical SAN extension with only a
+	// directoryName, and the standard library does not support it.
+	removeUnhandledCriticalExtension(ekCert, oidSubjectAlternativeName)
+	removeUnhandledCriticalExtension(ekCert, oidExtensionSubjectDirAttrs)
+
+	pool := v.ekIntermediates.Clone()
+	for _, crt := range intermediates {
+		pool.AddCert(crt)
+	}
+
+	chains, err := ekCert.Verify(x509.VerifyOptions{
+		Roots:         v.ekRoots,
+		Intermediates: pool,
+		CurrentTime:   v.now().Truncate(time.Second),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "ek certificate is not valid")
+	}
+
+	// If the EKU extension is present it must contain tcg-kp-EKCertificate.
+	if hasEKU(ekCert) && !hasEKUValue(ekCert, oidTCGKpEKCertificate) {
+		return nil, errors.New("ek certificate is missing extended key usage tcg-kp-EKCertificate (2.23.133.8.1)")
+	}
+
+	fp, err := keyutil.Fingerprint(ekCert.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "error calculating ek fingerprint")
+	}
+
+	ek := &EndorsementKey{
+		Certificate:    ekCert,
+		VerifiedChains: chains,
+		PublicKey:      ekCert.PublicKey,
+		Fingerprint:    fp,
+	}
+	if sans, err := x509util.ParseSubjectAlternativeNames(ekCert); err == nil {
+		ek.Manufacturer = sans.TPMHardwareDetails.Manufacturer
+		ek.Model = sans.TPMHardwareDetails.Model
+		ek.Version = sans.TPMHardwareDetails.Version
+	}
+
+	return ek, nil
+}
+
+// ActivationChallenge is the challenge sent to a client to prove that an
+// attestation key is in the same TPM as a verified endorsement key. The
+// client must return the secret decrypted with TPM2_ActivateCredential.
+type ActivationChallenge struct {
+	Credential      []byte
+	EncryptedSecret []byte
+	EndorsementKey  *EndorsementKey
+	AttestationKey  crypto.PublicKey
+	AKFingerprint   string
+	ExpiresAt       time.Time
+	secret          []byte
+}
+
+// NewActivationChallenge creates the credential activation challenge for the
+// given attestation key parameters and verified endorsement key.
+func (v *TPMVerifier) NewActivationChallenge(ek *EndorsementKey, ak attest.AttestationParameters) (*ActivationChallenge, error) {
+	if ek == nil {
+		return nil, errors.New("endorsement key cannot be nil")
+	}
+
+	akPub, err := attest.ParseAKPublic(attest.TPMVersion20, ak.Public)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing ak public key")
+	}
+	fp, err := keyutil.Fingerprint(akPub.Public)
+	if err != nil {
+		return nil, errors.Wrap(err, "error calculating ak fingerprint")
+	}
+
+	params := attest.ActivationParameters{
+		TPMVersion: attest.TPMVersion20,
+		EK:         ek.PublicKey,
+		AK:         ak,
+	}
+	secret, ec, err := params.Generate()
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating activation challenge")
+	}
+
+	return &ActivationChallenge{
+		Credential:      ec.Credential,
+		EncryptedSecret: ec.Secret,
+		EndorsementKey:  ek,
+		AttestationKey:  akPub.Public,
+		AKFingerprint:   fp,
+		ExpiresAt:       v.now().Add(v.activationLifetime),
+		secret:          secret,
+	}, nil
+}
+
+// AttestationKey is an attestation key that completed the credential
+// activation protocol.
+type AttestationKey struct {
+	PublicKey      crypto.PublicKey
+	Fingerprint    string
+	EndorsementKey *EndorsementKey
+}
+
+// Activate verifies the secret returned by the client and returns the
+// activated attestation key.
+func (v *TPMVerifier) Activate(c *ActivationChallenge, secret []byte) (*AttestationKey, error) {
+	switch {
+	case c == nil:
+		return nil, errors.New("activation challenge cannot be nil")
+	case v.now().After(c.ExpiresAt):
+		return nil, errors.New("activation challenge has expired")
+	case len(c.secret) == 0 || subtle.ConstantTimeCompare(c.secret, secret) == 0:
+		return nil, errors.New("activation secret does not match")
+	}
+
+	return &AttestationKey{
+		PublicKey:      c.AttestationKey,
+		Fingerprint:    c.AKFingerprint,
+		EndorsementKey: c.EndorsementKey,
+	}, nil
+}
+
+// VerifyKeyCertification verifies that the key described in the given
+// certification parameters was created in the TPM and certified by the
+// attestation key with the given public key. It returns the certified key.
+func VerifyKeyCertification(akPublicKey crypto.PublicKey, params attest.CertificationParameters) (crypto.PublicKey, error) {
+	if err := params.Verify(attest.VerifyOpts{
+		Public: akPublicKey,
+		Hash:   crypto.SHA256,
+	}); err != nil {
+		return nil, err
+	}
+
+	pub, err := attest.ParseAKPublic(attest.TPMVersion20, params.Public)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing certified key")
+	}
+	return pub.Public, nil
+}
+
+// VerifyKeyCertification verifies that the key described in the given
+// certification parameters was certified by the activated attestation key,
+// and returns the certified key.
+func (ak *AttestationKey) VerifyKeyCertification(params attest.CertificationParameters) (crypto.PublicKey, error) {
+	return VerifyKeyCertification(ak.PublicKey, params)
+}
+
+func removeUnhandledCriticalExtension(c *x509.Certificate, oid asn1.ObjectIdentifier) {
+	exts := c.UnhandledCriticalExtensions[:0]
+	for _, ext := range c.UnhandledCriticalExtensions {
+		if !ext.Equal(oid) {
+			exts = append(exts, ext)
+		}
+	}
+	c.UnhandledCriticalExtensions = exts
+}
+
+func hasEKU(c *x509.Certificate) bool {
+	for _, ext := range c.Extensions {
+		if ext.Id.Equal(oidExtensionExtendedKeyUsage) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasEKUValue(c *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, v := range c.UnknownExtKeyUsage {
+		if v.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package attestation
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+)
+
+func mustEKCertificate(t *testing.T, ca *minica.CA, extKeyUsages ...asn1.ObjectIdentifier) *x509.Certificate {
+	t.Helper()
+	signer, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:       big.NewInt(1234),
+		PublicKey:          signer.Public(),
+		KeyUsage:           x509.KeyUsageKeyEncipherment,
+		UnknownExtKeyUsage: extKeyUsages,
+	}
+	crt, err := ca.Sign(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+func TestNewTPMVerifier(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		opts         TPMOptions
+		wantLifetime time.Duration
+		wantErr      bool
+	}{
+		{"ok", TPMOptions{EKRoots: []*x509.Certificate{ca.Root}}, DefaultActivationLifetime, false},
+		{"ok lifetime", TPMOptions{EKRoots: []*x509.Certificate{ca.Root}, EKIntermediates: []*x509.Certificate{ca.Intermediate}, ActivationLifetime: time.Minute}, time.Minute, false},
+		{"fail no roots", TPMOptions{}, 0, true},
+		{"fail negative lifetime", TPMOptions{EKRoots: []*x509.Certificate{ca.Root}, ActivationLifetime: -time.Minute}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewTPMVerifier(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTPMVerifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != nil && got.activationLifetime != tt.wantLifetime {
+				t.Errorf("NewTPMVerifier() activationLifetime = %v, want %v", got.activationLifetime, tt.wantLifetime)
+			}
+		})
+	}
+}
+
+func TestTPMVerifier_VerifyEKCertificate(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewTPMVerifier(TPMOptions{EKRoots: []*x509.Certificate{ca.Root}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withIntermediate, err := NewTPMVerifier(TPMOptions{
+		EKRoots:         []*x509.Certificate{ca.Root},
+		EKIntermediates: []*x509.Certificate{ca.Intermediate},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		ekCert        *x509.Certificate
+		intermediates []*x509.Certificate
+	}
+	tests := []struct {
+		name    string
+		v       *TPMVerifier
+		args    args
+		wantErr bool
+	}{
+		{"ok", v, args{mustEKCertificate(t, ca, oidTCGKpEKCertificate), []*x509.Certificate{ca.Intermediate}}, false},
+		{"ok configured intermediate", withIntermediate, args{mustEKCertificate(t, ca, oidTCGKpEKCertificate), nil}, false},
+		{"ok no eku", v, args{mustEKCertificate(t, ca), []*x509.Certificate{ca.Intermediate}}, false},
+		{"fail nil", v, args{nil, nil}, true},
+		{"fail missing intermediate", v, args{mustEKCertificate(t, ca, oidTCGKpEKCertificate), nil}, true},
+		{"fail untrusted", v, args{mustEKCertificate(t, other, oidTCGKpEKCertificate), []*x509.Certificate{other.Intermediate}}, true},
+		{"fail eku", v, args{mustEKCertificate(t, ca, OIDExtKeyUsageAIKCertificate), []*x509.Certificate{ca.Intermediate}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.v.VerifyEKCertificate(tt.args.ekCert, tt.args.intermediates)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TPMVerifier.VerifyEKCertificate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			fp, err := keyutil.Fingerprint(tt.args.ekCert.PublicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Fingerprint != fp || got.Certificate != tt.args.ekCert || len(got.VerifiedChains) == 0 {
+				t.Errorf("TPMVerifier.VerifyEKCertificate() = %v", got)
+			}
+		})
+	}
+}
+
+func TestTPMVerifier_VerifyEKCertificate_criticalSAN(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewTPMVerifier(TPMOptions{EKRoots: []*x509.Certificate{ca.Root}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ekCert := mustEKCertificate(t, ca, oidTCGKpEKCertificate)
+	ekCert.UnhandledCriticalExtensions = append(ekCert.UnhandledCriticalExtensions, oidSubjectAlternativeName)
+
+	if _, err := v.VerifyEKCertificate(ekCert, []*x509.Certificate{ca.Intermediate}); err != nil {
+		t.Errorf("TPMVerifier.VerifyEKCertificate() error = %v", err)
+	}
+	if len(ekCert.UnhandledCriticalExtensions) != 0 {
+		t.Errorf("UnhandledCriticalExtensions = %v, want []", ekCert.UnhandledCriticalExtensions)
+	}
+}
+
+func TestTPMVerifier_Activate(t *testing.T) {
+	now := time.Now()
+	v := &TPMVerifier{now: func() time.Time { return now }}
+	ek := &EndorsementKey{Fingerprint: "ek-fingerprint"}
+	newChallenge := func(expiresAt time.Time) *ActivationChallenge {
+		return &ActivationChallenge{
+			EndorsementKey: ek,
+			AttestationKey: "ak-public-key",
+			AKFingerprint:  "ak-fingerprint",
+			ExpiresAt:      expiresAt,
+			secret:         []byte("the-secret"),
+		}
+	}
+
+	type args struct {
+		c      *ActivationChallenge
+		secret []byte
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *AttestationKey
+		wantErr bool
+	}{
+		{"ok", args{newChallenge(now.Add(time.Minute)), []byte("the-secret")}, &AttestationKey{
+			PublicKey:      "ak-public-key",
+			Fingerprint:    "ak-fingerprint",
+			EndorsementKey: ek,
+		}, false},
+		{"fail nil", args{nil, []byte("the-secret")}, nil, true},
+		{"fail expired", args{newChallenge(now.Add(-time.Second)), []byte("the-secret")}, nil, true},
+		{"fail secret", args{newChallenge(now.Add(time.Minute)), []byte("other-secret")}, nil, true},
+		{"fail empty secret", args{&ActivationChallenge{ExpiresAt: now.Add(time.Minute)}, nil}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Activate(tt.args.c, tt.args.secret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TPMVerifier.Activate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want != nil && (got.PublicKey != tt.want.PublicKey || got.Fingerprint != tt.want.Fingerprint || got.EndorsementKey != tt.want.EndorsementKey) {
+				t.Errorf("TPMVerifier.Activate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
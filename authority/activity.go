@@ -0,0 +1,160 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"log"
+	"time"
+
+	"github.com/smallstep/certificates/authority/activity"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+)
+
+// initActivity creates the broker that streams the signed, renewed and
+// revoked certificates to the subscribers, the notifier that sends them to
+// the event webhooks, and the logger that writes them to a file or syslog.
+func (a *Authority) initActivity() error {
+	if cfg := a.config.Activity; cfg.IsEnabled() {
+		a.activityBroker = activity.NewBroker(cfg.BufferSize)
+	}
+	if webhooks := a.config.Webhooks.GetEvents(); len(webhooks) > 0 {
+		a.activityNotifier = activity.NewNotifier(webhooks, a.webhookClient)
+	}
+	if cfg := a.config.Activity.GetLog(); cfg != nil {
+		var err error
+		switch cfg.Type {
+		case config.ActivityLogSyslog:
+			a.activityLogger, err = activity.NewSyslogLogger(cfg.GetTag())
+		default:
+			a.activityLogger, err = activity.NewFileLogger(cfg.Path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasActivity returns true if the events are sent anywhere.
+func (a *Authority) hasActivity() bool {
+	return a.activityBroker != nil || a.activityNotifier != nil || a.activityLogger != nil
+}
+
+// stopActivity disconnects the subscribers and delivers the pending events to
+// the webhooks.
+func (a *Authority) stopActivity() {
+	if a.activityBroker != nil {
+		a.activityBroker.Close()
+	}
+	if a.activityNotifier != nil {
+		a.activityNotifier.Close()
+	}
+	if a.activityLogger != nil {
+		if err := a.activityLogger.Close(); err != nil {
+			log.Printf("error closing the activity log: %v", err)
+		}
+	}
+}
+
+// publishActivity sends the event to the subscribers, the webhooks and the
+// activity log.
+func (a *Authority) publishActivity(e *activity.Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if a.activityBroker != nil {
+		a.activityBroker.Publish(e)
+	}
+	if a.activityNotifier != nil {
+		a.activityNotifier.Send(e)
+	}
+	if a.activityLogger != nil {
+		if err := a.activityLogger.Log(e); err != nil {
+			log.Printf("error logging %s event: %v", e.Type, err)
+		}
+	}
+}
+
+// PublishEvent sends an event of another component of the CA, like the ACME
+// server, to the subscribers, the webhooks and the activity log. It does
+// nothing if the events are not sent anywhere.
+func (a *Authority) PublishEvent(e *activity.Event) {
+	if a.hasActivity() {
+		a.publishActivity(e)
+	}
+}
+
+// publishX509Sign sends the event of a signed certificate to the subscribers.
+func (a *Authority) publishX509Sign(prov provisioner.Interface, crt *x509.Certificate) {
+	if !a.hasActivity() {
+		return
+	}
+	e := &activity.Event{
+		Type: activity.X509SignType,
+		Data: newX509AuditData(crt),
+	}
+	if prov != nil {
+		e.ProvisionerID, e.ProvisionerName = prov.GetID(), prov.GetName()
+	}
+	a.publishActivity(e)
+}
+
+// publishX509Renew sends the event of a renewed or rekeyed certificate to the
+// subscribers. The provisioner is the one in the old certificate.
+func (a *Authority) publishX509Renew(oldCert, crt *x509.Certificate) {
+	if !a.hasActivity() {
+		return
+	}
+	d := newX509AuditData(crt)
+	d.OldSerialNumber = oldCert.SerialNumber.String()
+	e := &activity.Event{
+		Type: activity.X509RenewType,
+		Data: d,
+	}
+	if prov, err := a.LoadProvisionerByCertificate(oldCert); err == nil {
+		e.ProvisionerID, e.ProvisionerName = prov.GetID(), prov.GetName()
+	}
+	a.publishActivity(e)
+}
+
+// publishRevoke sends the event of a revoked certificate to the subscribers.
+func (a *Authority) publishRevoke(rci *db.RevokedCertificateInfo, isSSH bool) {
+	if !a.hasActivity() {
+		return
+	}
+	e := &activity.Event{
+		Type:          activity.RevokeType,
+		ProvisionerID: rci.ProvisionerID,
+		Data: &revokeAuditData{
+			SerialNumber:  rci.Serial,
+			ReasonCode:    rci.ReasonCode,
+			Reason:        rci.Reason,
+			ProvisionerID: rci.ProvisionerID,
+			TokenID:       rci.TokenID,
+			MTLS:          rci.MTLS,
+			ACME:          rci.ACME,
+			SSH:           isSSH,
+			RevokedAt:     rci.RevokedAt,
+		},
+	}
+	if prov, ok := a.provisioners.Load(rci.ProvisionerID); ok {
+		e.ProvisionerName = prov.GetName()
+	}
+	a.publishActivity(e)
+}
+
+// GetActivityEvents returns the events after the given id that pass the
+// filter. If there are no new events, it waits until there is one or the
+// context is done.
+func (a *Authority) GetActivityEvents(ctx context.Context, since uint64, f *activity.Filter) (*activity.Page, error) {
+	if a.activityBroker == nil {
+		return nil, admin.NewError(admin.ErrorNotImplementedType, "activity events are not enabled")
+	}
+	if a.activityBroker.Closed() {
+		return nil, admin.NewError(admin.ErrorServerInternalType, "activity events are not available")
+	}
+	return a.activityBroker.Events(ctx, since, f), nil
+}
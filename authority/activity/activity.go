@@ -0,0 +1,255 @@
+// Package activity implements the stream of the issuance activity of the
+// authority, the signed, renewed and revoked certificates, so dashboards and
+// security tools can watch it in real time without polling the database.
+//
+// Each instance of the CA keeps its own list of recent events in memory.
+package activity
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultBufferSize is the default number of events kept in memory.
+const DefaultBufferSize = 1000
+
+// Event types.
+const (
+	// X509SignType is the type of the events of signed X.509 certificates.
+	X509SignType = "x509.sign"
+	// X509RenewType is the type of the events of renewed or rekeyed X.509
+	// certificates.
+	X509RenewType = "x509.renew"
+	// RevokeType is the type of the events of revoked X.509 and SSH
+	// certificates.
+	RevokeType = "revoke"
+	// ACMEAccountType is the type of the events of new ACME accounts.
+	ACMEAccountType = "acme.account"
+	// ACMEChallengeType is the type of the events of the validation attempts
+	// of ACME challenges.
+	ACMEChallengeType = "acme.challenge"
+	// ACMEOrderCreatedType is the type of the events of new ACME orders.
+	ACMEOrderCreatedType = "acme.order.created"
+	// ACMEOrderValidatedType is the type of the events of ACME orders with all
+	// their authorizations valid, ready to be finalized.
+	ACMEOrderValidatedType = "acme.order.validated"
+	// ACMEOrderFinalizedType is the type of the events of ACME orders with a
+	// signed certificate.
+	ACMEOrderFinalizedType = "acme.order.finalized"
+	// ACMEOrderFailedType is the type of the events of invalid ACME orders,
+	// because an authorization failed, the order expired or it was rejected.
+	ACMEOrderFailedType = "acme.order.failed"
+)
+
+// ACMEAccountData is the data of the ACMEAccountType events.
+type ACMEAccountData struct {
+	AccountID     string   `json:"accountID"`
+	Contact       []string `json:"contact,omitempty"`
+	KeyThumbprint string   `json:"keyThumbprint,omitempty"`
+}
+
+// ACMEChallengeData is the data of the ACMEChallengeType events. Status is
+// the status of the challenge after the attempt, a pending or processing
+// challenge with an error will be retried. Error is set if the attempt could
+// not be completed, for example because of a database error.
+type ACMEChallengeData struct {
+	AccountID       string  `json:"accountID"`
+	AuthorizationID string  `json:"authorizationID"`
+	ChallengeID     string  `json:"challengeID"`
+	Type            string  `json:"type"`
+	Identifier      string  `json:"identifier"`
+	Status          string  `json:"status"`
+	ProblemType     string  `json:"problemType,omitempty"`
+	ProblemDetail   string  `json:"problemDetail,omitempty"`
+	Error           string  `json:"error,omitempty"`
+	Duration        float64 `json:"durationSeconds"`
+}
+
+// ACMEIdentifier is an identifier requested in an ACME order.
+type ACMEIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// ACMEOrderData is the data of the ACME order events. The validity is the one
+// requested in the order, or the one of the certificate in the finalized
+// events. IssuedNames are the SANs, or the principals, of the signed
+// certificate, so they can be reconciled with the requested identifiers.
+type ACMEOrderData struct {
+	AccountID     string           `json:"accountID"`
+	OrderID       string           `json:"orderID"`
+	Status        string           `json:"status"`
+	Identifiers   []ACMEIdentifier `json:"identifiers"`
+	ExpiresAt     time.Time        `json:"expiresAt"`
+	NotBefore     time.Time        `json:"notBefore"`
+	NotAfter      time.Time        `json:"notAfter"`
+	SerialNumber  string           `json:"serialNumber,omitempty"`
+	IssuedNames   []string         `json:"issuedNames,omitempty"`
+	ProblemType   string           `json:"problemType,omitempty"`
+	ProblemDetail string           `json:"problemDetail,omitempty"`
+}
+
+// Event is an operation of the authority. Data contains the details of the
+// operation, it depends on the type of the event.
+type Event struct {
+	ID              uint64      `json:"id"`
+	Type            string      `json:"type"`
+	Time            time.Time   `json:"time"`
+	ProvisionerID   string      `json:"provisionerID,omitempty"`
+	ProvisionerName string      `json:"provisionerName,omitempty"`
+	Data            interface{} `json:"data"`
+}
+
+// Filter selects the events sent to a subscriber. An empty list matches all
+// the events.
+type Filter struct {
+	// Provisioners is the list of names or ids of the provisioners.
+	Provisioners []string
+	// Types is the list of event types.
+	Types []string
+}
+
+// Match returns true if the event passes the filter. A nil filter matches
+// all the events.
+func (f *Filter) Match(e *Event) bool {
+	if f == nil {
+		return true
+	}
+	return matchAny(f.Provisioners, e.ProvisionerID, e.ProvisionerName) &&
+		matchAny(f.Types, e.Type)
+}
+
+func matchAny(list []string, values ...string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, s := range list {
+		for _, v := range values {
+			if v != "" && s == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Page is a list of events returned to a subscriber. Last is the id of the
+// last event seen, to be used in the following request, it can be greater
+// than the id of the last event returned if the rest don't pass the filter.
+// Truncated is true if some events after the requested one are not available
+// anymore.
+type Page struct {
+	Events    []*Event `json:"events"`
+	Last      uint64   `json:"last"`
+	Truncated bool     `json:"truncated"`
+}
+
+// Broker keeps the recent events and delivers them to the subscribers.
+type Broker struct {
+	mu     sync.Mutex
+	size   int
+	events []*Event
+	lastID uint64
+	notify chan struct{}
+	closed bool
+}
+
+// NewBroker creates a new broker that keeps the given number of events in
+// memory.
+//
+// Event ids are based on the current time, so they keep increasing after a
+// restart.
+func NewBroker(size int) *Broker {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Broker{
+		size:   size,
+		lastID: uint64(time.Now().UnixMicro()),
+		notify: make(chan struct{}),
+	}
+}
+
+// Publish adds a new event and wakes up the waiting subscribers.
+func (b *Broker) Publish(e *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.lastID++
+	e.ID = b.lastID
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b.events = append(b.events, e)
+	if len(b.events) > b.size {
+		b.events = b.events[len(b.events)-b.size:]
+	}
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// Events returns the events after the given id that pass the filter. If there
+// are no events, it waits until one is published, the context is done or the
+// broker is closed.
+func (b *Broker) Events(ctx context.Context, since uint64, f *Filter) *Page {
+	for {
+		b.mu.Lock()
+		page := b.page(since, f)
+		notify, closed := b.notify, b.closed
+		b.mu.Unlock()
+		if len(page.Events) > 0 || page.Truncated || closed {
+			return page
+		}
+		since = page.Last
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return page
+		}
+	}
+}
+
+// page must be called with the lock held.
+func (b *Broker) page(since uint64, f *Filter) *Page {
+	page := &Page{
+		Events: []*Event{},
+		Last:   b.lastID,
+	}
+	if since >= b.lastID {
+		// Do not go back if the subscriber is ahead of us.
+		page.Last = since
+		return page
+	}
+	for _, e := range b.events {
+		if e.ID > since && f.Match(e) {
+			page.Events = append(page.Events, e)
+		}
+	}
+	// Events between since and the first one are lost.
+	if len(b.events) == 0 || b.events[0].ID > since+1 {
+		page.Truncated = since != 0
+	}
+	return page
+}
+
+// Close wakes up the waiting subscribers and stops accepting new events. It
+// is used when the authority is reloaded, so the subscribers reconnect to the
+// new one.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.notify)
+	}
+}
+
+// Closed returns if the broker has been closed.
+func (b *Broker) Closed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
@@ -0,0 +1,125 @@
+package activity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter_Match(t *testing.T) {
+	e := &Event{Type: X509SignType, ProvisionerID: "jwk/id", ProvisionerName: "jwk"}
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   bool
+	}{
+		{"nil", nil, true},
+		{"empty", &Filter{}, true},
+		{"provisioner name", &Filter{Provisioners: []string{"acme", "jwk"}}, true},
+		{"provisioner id", &Filter{Provisioners: []string{"jwk/id"}}, true},
+		{"type", &Filter{Types: []string{X509SignType, RevokeType}}, true},
+		{"provisioner and type", &Filter{Provisioners: []string{"jwk"}, Types: []string{X509SignType}}, true},
+		{"fail provisioner", &Filter{Provisioners: []string{"acme"}}, false},
+		{"fail type", &Filter{Types: []string{RevokeType}}, false},
+		{"fail provisioner and type", &Filter{Provisioners: []string{"jwk"}, Types: []string{RevokeType}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Match(e))
+		})
+	}
+
+	// Events without provisioner only match filters without provisioners.
+	assert.False(t, (&Filter{Provisioners: []string{""}}).Match(&Event{Type: RevokeType}))
+}
+
+func TestBroker_Events(t *testing.T) {
+	b := NewBroker(3)
+	start := b.lastID
+	ctx := context.Background()
+
+	// No events.
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	page := b.Events(cctx, 0, nil)
+	assert.Empty(t, page.Events)
+	assert.Equal(t, start, page.Last)
+	assert.False(t, page.Truncated)
+
+	b.Publish(&Event{Type: X509SignType, ProvisionerName: "jwk"})
+	b.Publish(&Event{Type: X509SignType, ProvisionerName: "acme"})
+	b.Publish(&Event{Type: RevokeType, ProvisionerName: "jwk"})
+	page = b.Events(ctx, 0, nil)
+	if assert.Len(t, page.Events, 3) {
+		assert.Equal(t, start+1, page.Events[0].ID)
+		assert.False(t, page.Events[0].Time.IsZero())
+		assert.Equal(t, start+3, page.Events[2].ID)
+	}
+	assert.Equal(t, start+3, page.Last)
+
+	// Filtered events.
+	page = b.Events(ctx, start, &Filter{Provisioners: []string{"jwk"}})
+	if assert.Len(t, page.Events, 2) {
+		assert.Equal(t, start+1, page.Events[0].ID)
+		assert.Equal(t, start+3, page.Events[1].ID)
+	}
+	assert.Equal(t, start+3, page.Last)
+	assert.False(t, page.Truncated)
+
+	// Skipped events move the last id forward.
+	cctx, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	page = b.Events(cctx, start+1, &Filter{Types: []string{X509RenewType}})
+	assert.Empty(t, page.Events)
+	assert.Equal(t, start+3, page.Last)
+
+	// The first event is dropped from the buffer.
+	b.Publish(&Event{Type: X509RenewType})
+	page = b.Events(ctx, start, nil)
+	assert.Len(t, page.Events, 3)
+	assert.True(t, page.Truncated)
+	page = b.Events(ctx, start+1, nil)
+	assert.Len(t, page.Events, 3)
+	assert.False(t, page.Truncated)
+}
+
+func TestBroker_Events_wait(t *testing.T) {
+	b := NewBroker(0)
+	since := b.lastID
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		b.Publish(&Event{Type: X509SignType, ProvisionerName: "acme"})
+		b.Publish(&Event{Type: X509SignType, ProvisionerName: "jwk"})
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	page := b.Events(ctx, since, &Filter{Provisioners: []string{"jwk"}})
+	if assert.Len(t, page.Events, 1) {
+		assert.Equal(t, "jwk", page.Events[0].ProvisionerName)
+		assert.Equal(t, since+2, page.Last)
+	}
+}
+
+func TestBroker_Close(t *testing.T) {
+	b := NewBroker(0)
+	since := b.lastID
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		b.Close()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	page := b.Events(ctx, since, nil)
+	assert.Empty(t, page.Events)
+	assert.True(t, b.Closed())
+	assert.NoError(t, ctx.Err())
+
+	// Events are ignored after closing the broker.
+	b.Publish(&Event{Type: X509SignType})
+	page = b.Events(ctx, since, nil)
+	assert.Empty(t, page.Events)
+	assert.Equal(t, since, page.Last)
+	b.Close()
+}
@@ -0,0 +1,62 @@
+package activity
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// LogSchema is the schema of the records written by a Logger. It changes
+// only if the format of the records changes in an incompatible way.
+const LogSchema = "step-ca.activity.v1"
+
+// logRecord is a record written by a Logger.
+type logRecord struct {
+	Schema string `json:"schema"`
+	*Event
+}
+
+// Logger writes the events as JSON documents, one per line, so they can be
+// collected by log shippers and SIEMs.
+type Logger struct {
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+// NewLogger creates a logger that writes the events to the given writer.
+func NewLogger(w io.WriteCloser) *Logger {
+	return &Logger{w: w}
+}
+
+// NewFileLogger creates a logger that appends the events to the given file.
+func NewFileLogger(filename string) (*Logger, error) {
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening activity log")
+	}
+	return NewLogger(f), nil
+}
+
+// Log writes the given event.
+func (l *Logger) Log(e *Event) error {
+	b, err := json.Marshal(&logRecord{Schema: LogSchema, Event: e})
+	if err != nil {
+		return errors.Wrap(err, "error marshaling event")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(b, '\n')); err != nil {
+		return errors.Wrap(err, "error writing activity log")
+	}
+	return nil
+}
+
+// Close closes the underlying writer.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Close()
+}
@@ -0,0 +1,12 @@
+//go:build windows || plan9
+
+package activity
+
+import (
+	"github.com/pkg/errors"
+)
+
+// NewSyslogLogger returns an error, syslog is not supported on this platform.
+func NewSyslogLogger(string) (*Logger, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
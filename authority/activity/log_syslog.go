@@ -0,0 +1,19 @@
+//go:build !windows && !plan9
+
+package activity
+
+import (
+	"log/syslog"
+
+	"github.com/pkg/errors"
+)
+
+// NewSyslogLogger creates a logger that sends the events to the local syslog
+// daemon with the given tag.
+func NewSyslogLogger(tag string) (*Logger, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+	if err != nil {
+		return nil, errors.Wrap(err, "error connecting to syslog")
+	}
+	return NewLogger(w), nil
+}
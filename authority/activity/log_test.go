@@ -0,0 +1,64 @@
+package activity
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *nopCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestLogger(t *testing.T) {
+	w := new(nopCloser)
+	l := NewLogger(w)
+	require.NoError(t, l.Log(&Event{
+		ID:              1,
+		Type:            ACMEAccountType,
+		Time:            time.Unix(1700000000, 0).UTC(),
+		ProvisionerName: "acme",
+		Data:            &ACMEAccountData{AccountID: "acc-id", KeyThumbprint: "thumbprint"},
+	}))
+	require.NoError(t, l.Log(&Event{ID: 2, Type: RevokeType, Time: time.Unix(1700000001, 0).UTC()}))
+	require.NoError(t, l.Close())
+	assert.True(t, w.closed)
+
+	lines := bytes.Split(bytes.TrimSpace(w.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"schema":"step-ca.activity.v1","id":1,"type":"acme.account","time":"2023-11-14T22:13:20Z","provisionerName":"acme","data":{"accountID":"acc-id","keyThumbprint":"thumbprint"}}`, string(lines[0]))
+
+	var r map[string]interface{}
+	require.NoError(t, json.Unmarshal(lines[1], &r))
+	assert.Equal(t, LogSchema, r["schema"])
+	assert.Equal(t, RevokeType, r["type"])
+}
+
+func TestNewFileLogger(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "activity.log")
+	for i := 0; i < 2; i++ {
+		l, err := NewFileLogger(filename)
+		require.NoError(t, err)
+		require.NoError(t, l.Log(&Event{Type: X509SignType}))
+		require.NoError(t, l.Close())
+	}
+
+	b, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, 2, bytes.Count(b, []byte("\n")))
+
+	_, err = NewFileLogger(filepath.Join(t.TempDir(), "missing", "activity.log"))
+	assert.Error(t, err)
+}
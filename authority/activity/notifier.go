@@ -0,0 +1,143 @@
+package activity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/authority/revocation"
+	"github.com/smallstep/certificates/webhook"
+)
+
+// Defaults of the webhook delivery.
+var (
+	webhookTimeout    = 10 * time.Second
+	webhookRetries    = 3
+	webhookRetryDelay = time.Second
+	webhookQueueSize  = 1000
+)
+
+// RequestBody is the body sent to the webhooks.
+type RequestBody struct {
+	Timestamp time.Time `json:"timestamp"`
+	Nonce     string    `json:"nonce,omitempty"`
+	Event     *Event    `json:"event"`
+}
+
+// Notifier sends the events to the webhooks in the background. Each delivery
+// is retried a few times if the webhook server fails. The webhooks use the
+// same configuration as the revocation webhooks.
+type Notifier struct {
+	webhooks []*revocation.Webhook
+	client   *http.Client
+	queue    chan *Event
+	wg       sync.WaitGroup
+	once     sync.Once
+}
+
+// NewNotifier creates a new notifier and starts the delivery goroutine. If
+// client is nil, http.DefaultClient is used.
+func NewNotifier(webhooks []*revocation.Webhook, client *http.Client) *Notifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	n := &Notifier{
+		webhooks: webhooks,
+		client:   client,
+		queue:    make(chan *Event, webhookQueueSize),
+	}
+	n.wg.Add(1)
+	go n.run()
+	return n
+}
+
+// Send queues an event. The event is dropped if the queue is full.
+func (n *Notifier) Send(e *Event) {
+	select {
+	case n.queue <- e:
+	default:
+		log.Printf("error sending %s event: queue is full", e.Type)
+	}
+}
+
+// Close delivers the queued events and stops the notifier.
+func (n *Notifier) Close() {
+	n.once.Do(func() {
+		close(n.queue)
+	})
+	n.wg.Wait()
+}
+
+func (n *Notifier) run() {
+	defer n.wg.Done()
+	for e := range n.queue {
+		for _, w := range n.webhooks {
+			if err := n.deliver(w, e); err != nil {
+				log.Printf("error sending %s event to webhook %s: %v", e.Type, w.Name, err)
+			}
+		}
+	}
+}
+
+func (n *Notifier) deliver(w *revocation.Webhook, e *Event) error {
+	for i := 0; ; i++ {
+		nonce, err := webhook.NewNonce()
+		if err != nil {
+			return err
+		}
+		body, err := json.Marshal(&RequestBody{
+			Timestamp: time.Now().UTC(),
+			Nonce:     nonce,
+			Event:     e,
+		})
+		if err != nil {
+			return errors.Wrap(err, "error marshaling event")
+		}
+		err = n.post(w, body)
+		if err == nil || i+1 >= webhookRetries {
+			return err
+		}
+		time.Sleep(webhookRetryDelay * time.Duration(i+1))
+	}
+}
+
+func (n *Notifier) post(w *revocation.Webhook, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.IDHeader, w.Name)
+	key, bearerToken, err := w.Credentials(ctx)
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		req.Header.Set(webhook.SignatureHeader, webhook.Sign(key, body))
+	}
+	if bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+bearerToken)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook server responded with %d", resp.StatusCode)
+	}
+	return nil
+}
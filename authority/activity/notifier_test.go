@@ -0,0 +1,60 @@
+package activity
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smallstep/certificates/authority/revocation"
+	"github.com/smallstep/certificates/webhook"
+)
+
+func TestNotifier(t *testing.T) {
+	retryDelay := webhookRetryDelay
+	t.Cleanup(func() { webhookRetryDelay = retryDelay })
+	webhookRetryDelay = 0
+	secret := []byte("secret")
+	var attempts int
+	received := make(chan *RequestBody, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, webhook.Sign(secret, body), r.Header.Get(webhook.SignatureHeader))
+		assert.Equal(t, "siem", r.Header.Get(webhook.IDHeader))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		var rb RequestBody
+		require.NoError(t, json.Unmarshal(body, &rb))
+		received <- &rb
+	}))
+	defer srv.Close()
+
+	n := NewNotifier([]*revocation.Webhook{{
+		Name:        "siem",
+		URL:         srv.URL,
+		Secret:      base64.StdEncoding.EncodeToString(secret),
+		BearerToken: "token",
+	}}, srv.Client())
+	n.Send(&Event{Type: X509SignType, ProvisionerName: "acme"})
+	n.Close()
+
+	select {
+	case rb := <-received:
+		assert.Equal(t, X509SignType, rb.Event.Type)
+		assert.Equal(t, "acme", rb.Event.ProvisionerName)
+		assert.NotEmpty(t, rb.Nonce)
+	default:
+		t.Fatal("webhook did not receive the event")
+	}
+	assert.Equal(t, 2, attempts)
+}
@@ -0,0 +1,103 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smallstep/assert"
+
+	"github.com/smallstep/certificates/authority/activity"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/revocation"
+	"github.com/smallstep/certificates/db"
+)
+
+func TestAuthority_GetActivityEvents(t *testing.T) {
+	ctx := context.Background()
+
+	a := testAuthority(t)
+	_, err := a.GetActivityEvents(ctx, 0, nil)
+	assert.Error(t, err)
+
+	a = testAuthority(t, func(a *Authority) error {
+		a.config.Activity = &config.ActivityConfig{Enabled: true}
+		return nil
+	})
+	prov, ok := a.provisioners.LoadByName("step-cli")
+	assert.Fatal(t, ok)
+
+	oldCert := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "foo"}}
+	crt := &x509.Certificate{SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "foo"}, DNSNames: []string{"foo.internal"}}
+	a.publishX509Sign(prov, oldCert)
+	a.publishX509Renew(oldCert, crt)
+	a.publishRevoke(&db.RevokedCertificateInfo{Serial: "2", ProvisionerID: prov.GetID(), ReasonCode: 1}, false)
+
+	page, err := a.GetActivityEvents(ctx, 0, nil)
+	assert.FatalError(t, err)
+	if assert.Len(t, 3, page.Events) {
+		e := page.Events[0]
+		assert.Equals(t, activity.X509SignType, e.Type)
+		assert.Equals(t, prov.GetID(), e.ProvisionerID)
+		assert.Equals(t, "step-cli", e.ProvisionerName)
+		assert.Equals(t, "1", e.Data.(*x509AuditData).SerialNumber)
+
+		e = page.Events[1]
+		assert.Equals(t, activity.X509RenewType, e.Type)
+		assert.Equals(t, "", e.ProvisionerName)
+		assert.Equals(t, "2", e.Data.(*x509AuditData).SerialNumber)
+		assert.Equals(t, "1", e.Data.(*x509AuditData).OldSerialNumber)
+		assert.Equals(t, []string{"foo.internal"}, e.Data.(*x509AuditData).DNSNames)
+
+		e = page.Events[2]
+		assert.Equals(t, activity.RevokeType, e.Type)
+		assert.Equals(t, "step-cli", e.ProvisionerName)
+		assert.Equals(t, 1, e.Data.(*revokeAuditData).ReasonCode)
+	}
+
+	page, err = a.GetActivityEvents(ctx, 0, &activity.Filter{Types: []string{activity.RevokeType}})
+	assert.FatalError(t, err)
+	assert.Len(t, 1, page.Events)
+
+	a.stopActivity()
+	_, err = a.GetActivityEvents(ctx, 0, nil)
+	assert.Error(t, err)
+}
+
+func TestAuthority_publishActivity_webhooks(t *testing.T) {
+	received := make(chan *activity.RequestBody, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rb activity.RequestBody
+		assert.FatalError(t, json.NewDecoder(r.Body).Decode(&rb))
+		received <- &rb
+	}))
+	defer srv.Close()
+
+	// The events are sent to the webhooks even if the activity stream is not
+	// enabled.
+	a := testAuthority(t, func(a *Authority) error {
+		a.config.Webhooks = &config.WebhooksConfig{
+			Events: []*revocation.Webhook{{Name: "siem", URL: srv.URL}},
+		}
+		return nil
+	})
+	prov, ok := a.provisioners.LoadByName("step-cli")
+	assert.Fatal(t, ok)
+
+	a.publishX509Sign(prov, &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "foo"}})
+	a.stopActivity()
+
+	select {
+	case rb := <-received:
+		assert.Equals(t, activity.X509SignType, rb.Event.Type)
+		assert.Equals(t, "step-cli", rb.Event.ProvisionerName)
+		assert.False(t, rb.Event.Time.IsZero())
+	default:
+		t.Fatal("webhook did not receive the event")
+	}
+}
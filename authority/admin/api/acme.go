@@ -1,17 +1,29 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
+	"github.com/go-chi/chi"
 	"go.step.sm/linkedca"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/api/read"
 	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority"
 	"github.com/smallstep/certificates/authority/admin"
 )
 
+// mustACMEAuthority returns the authority used to sign the certificates of
+// the approved ACME orders.
+var mustACMEAuthority = func(ctx context.Context) acme.CertificateAuthority {
+	return authority.MustFromContext(ctx)
+}
+
 // CreateExternalAccountKeyRequest is the type for POST /admin/acme/eab requests
 type CreateExternalAccountKeyRequest struct {
 	Reference string `json:"reference"`
@@ -31,6 +43,26 @@ type GetExternalAccountKeysResponse struct {
 	NextCursor string             `json:"nextCursor"`
 }
 
+// GetDeferredOrdersResponse is the type for GET
+// /admin/acme/orders/{provisionerName}/pending-approval responses.
+type GetDeferredOrdersResponse struct {
+	Orders []*acme.DeferredOrder `json:"orders"`
+}
+
+// RejectOrderRequest is the type for POST
+// /admin/acme/orders/{provisionerName}/{id}/reject requests.
+type RejectOrderRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Validate validates a reject order request body.
+func (r *RejectOrderRequest) Validate() error {
+	if len(r.Reason) > 1024 {
+		return fmt.Errorf("reason length %d exceeds the maximum (1024)", len(r.Reason))
+	}
+	return nil
+}
+
 // requireEABEnabled is a middleware that ensures ACME EAB is enabled
 // before serving requests that act on ACME EAB credentials.
 func requireEABEnabled(next http.HandlerFunc) http.HandlerFunc {
@@ -58,6 +90,17 @@ type ACMEAdminResponder interface {
 	GetExternalAccountKeys(w http.ResponseWriter, r *http.Request)
 	CreateExternalAccountKey(w http.ResponseWriter, r *http.Request)
 	DeleteExternalAccountKey(w http.ResponseWriter, r *http.Request)
+	GetDeferredOrders(w http.ResponseWriter, r *http.Request)
+	ApproveOrder(w http.ResponseWriter, r *http.Request)
+	RejectOrder(w http.ResponseWriter, r *http.Request)
+	GetAccounts(w http.ResponseWriter, r *http.Request)
+	GetAccount(w http.ResponseWriter, r *http.Request)
+	DisableAccount(w http.ResponseWriter, r *http.Request)
+	GetAccountOrders(w http.ResponseWriter, r *http.Request)
+	GetAccountAuthorizations(w http.ResponseWriter, r *http.Request)
+	InvalidateAuthorization(w http.ResponseWriter, r *http.Request)
+	GetAccountCertificates(w http.ResponseWriter, r *http.Request)
+	RevokeCertificate(w http.ResponseWriter, r *http.Request)
 }
 
 // acmeAdminResponder implements ACMEAdminResponder.
@@ -68,19 +111,218 @@ func NewACMEAdminResponder() ACMEAdminResponder {
 	return &acmeAdminResponder{}
 }
 
-// GetExternalAccountKeys writes the response for the EAB keys GET endpoint
-func (h *acmeAdminResponder) GetExternalAccountKeys(w http.ResponseWriter, _ *http.Request) {
-	render.Error(w, admin.NewError(admin.ErrorNotImplementedType, "this functionality is currently only available in Certificate Manager: https://u.step.sm/cm"))
+// GetExternalAccountKeys writes the response for the EAB keys GET endpoint.
+// The HMAC keys are only returned on creation.
+func (h *acmeAdminResponder) GetExternalAccountKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	prov := linkedca.MustProvisionerFromContext(ctx)
+	acmeDB := acme.MustDatabaseFromContext(ctx)
+
+	cursor, limit, err := api.ParseCursor(r)
+	if err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err,
+			"error parsing cursor and limit from query params"))
+		return
+	}
+
+	var (
+		keys       []*acme.ExternalAccountKey
+		nextCursor string
+	)
+	if reference := chi.URLParam(r, "reference"); reference != "" {
+		key, err := acmeDB.GetExternalAccountKeyByReference(ctx, prov.GetId(), reference)
+		if err != nil {
+			if errors.Is(err, acme.ErrNotFound) {
+				render.Error(w, admin.NewError(admin.ErrorNotFoundType, "ACME EAB key with reference '%s' not found", reference))
+				return
+			}
+			render.Error(w, admin.WrapErrorISE(err, "error retrieving ACME EAB key with reference '%s'", reference))
+			return
+		}
+		if key != nil {
+			keys = []*acme.ExternalAccountKey{key}
+		}
+	} else {
+		if keys, nextCursor, err = acmeDB.GetExternalAccountKeys(ctx, prov.GetId(), cursor, limit); err != nil {
+			render.Error(w, admin.WrapErrorISE(err, "error retrieving ACME EAB keys"))
+			return
+		}
+	}
+
+	eaks := make([]*linkedca.EABKey, len(keys))
+	for i, k := range keys {
+		eaks[i] = eakToLinked(k)
+		eaks[i].HmacKey = []byte{}
+		eaks[i].Provisioner = prov.GetName()
+	}
+
+	render.JSON(w, &GetExternalAccountKeysResponse{
+		EAKs:       eaks,
+		NextCursor: nextCursor,
+	})
 }
 
-// CreateExternalAccountKey writes the response for the EAB key POST endpoint
-func (h *acmeAdminResponder) CreateExternalAccountKey(w http.ResponseWriter, _ *http.Request) {
-	render.Error(w, admin.NewError(admin.ErrorNotImplementedType, "this functionality is currently only available in Certificate Manager: https://u.step.sm/cm"))
+// CreateExternalAccountKey writes the response for the EAB key POST endpoint.
+// The response contains the key ID and the HMAC key that the ACME client must
+// use in the externalAccountBinding of the newAccount request.
+func (h *acmeAdminResponder) CreateExternalAccountKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	prov := linkedca.MustProvisionerFromContext(ctx)
+	acmeDB := acme.MustDatabaseFromContext(ctx)
+
+	var body CreateExternalAccountKeyRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error validating request body"))
+		return
+	}
+
+	if reference := body.Reference; reference != "" {
+		k, err := acmeDB.GetExternalAccountKeyByReference(ctx, prov.GetId(), reference)
+		if err != nil && !errors.Is(err, acme.ErrNotFound) {
+			render.Error(w, admin.WrapErrorISE(err, "error retrieving ACME EAB key with reference '%s'", reference))
+			return
+		}
+		if k != nil {
+			err := admin.NewError(admin.ErrorBadRequestType, "an ACME EAB key for provisioner '%s' with reference '%s' already exists", prov.GetName(), reference)
+			err.Status = http.StatusConflict
+			render.Error(w, err)
+			return
+		}
+	}
+
+	eak, err := acmeDB.CreateExternalAccountKey(ctx, prov.GetId(), body.Reference)
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error creating ACME EAB key for provisioner '%s'", prov.GetName()))
+		return
+	}
+
+	render.ProtoJSONStatus(w, &linkedca.EABKey{
+		Id:          eak.ID,
+		HmacKey:     eak.HmacKey,
+		Provisioner: prov.GetName(),
+		Reference:   eak.Reference,
+	}, http.StatusCreated)
+}
+
+// DeleteExternalAccountKey writes the response for the EAB key DELETE
+// endpoint. A deleted key cannot be used to create new accounts.
+func (h *acmeAdminResponder) DeleteExternalAccountKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	prov := linkedca.MustProvisionerFromContext(ctx)
+	acmeDB := acme.MustDatabaseFromContext(ctx)
+
+	keyID := chi.URLParam(r, "id")
+	if err := acmeDB.DeleteExternalAccountKey(ctx, prov.GetId(), keyID); err != nil {
+		if errors.Is(err, acme.ErrNotFound) {
+			render.Error(w, admin.NewError(admin.ErrorNotFoundType, "ACME EAB key '%s' not found", keyID))
+			return
+		}
+		render.Error(w, admin.WrapErrorISE(err, "error deleting ACME EAB key '%s'", keyID))
+		return
+	}
+
+	render.JSON(w, &DeleteResponse{Status: "ok"})
+}
+
+// GetDeferredOrders writes the response for the endpoint listing the ACME
+// orders of a provisioner that are waiting for an approval.
+func (h *acmeAdminResponder) GetDeferredOrders(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	prov := linkedca.MustProvisionerFromContext(ctx)
+	acmeDB := acme.MustDatabaseFromContext(ctx)
+
+	orders, err := acmeDB.GetDeferredOrders(ctx, prov.GetId())
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error retrieving ACME orders waiting for an approval"))
+		return
+	}
+	render.JSON(w, &GetDeferredOrdersResponse{Orders: orders})
+}
+
+// ApproveOrder writes the response for the order approval endpoint. The
+// certificate of the order is signed, and the ACME client can download it
+// the next time it polls the order.
+func (h *acmeAdminResponder) ApproveOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	o, p, ok := loadDeferredOrder(w, r)
+	if !ok {
+		return
+	}
+
+	adm := linkedca.MustAdminFromContext(ctx)
+	if err := o.Approve(ctx, acme.MustDatabaseFromContext(ctx), mustACMEAuthority(ctx), p, adm.GetSubject()); err != nil {
+		render.Error(w, orderDecisionError(err, "error approving ACME order '%s'", o.ID))
+		return
+	}
+	render.JSON(w, o)
 }
 
-// DeleteExternalAccountKey writes the response for the EAB key DELETE endpoint
-func (h *acmeAdminResponder) DeleteExternalAccountKey(w http.ResponseWriter, _ *http.Request) {
-	render.Error(w, admin.NewError(admin.ErrorNotImplementedType, "this functionality is currently only available in Certificate Manager: https://u.step.sm/cm"))
+// RejectOrder writes the response for the order rejection endpoint. The order
+// becomes invalid.
+func (h *acmeAdminResponder) RejectOrder(w http.ResponseWriter, r *http.Request) {
+	var body RejectOrderRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error validating request body"))
+		return
+	}
+
+	ctx := r.Context()
+	o, p, ok := loadDeferredOrder(w, r)
+	if !ok {
+		return
+	}
+
+	adm := linkedca.MustAdminFromContext(ctx)
+	if err := o.Reject(ctx, acme.MustDatabaseFromContext(ctx), p, adm.GetSubject(), body.Reason); err != nil {
+		render.Error(w, orderDecisionError(err, "error rejecting ACME order '%s'", o.ID))
+		return
+	}
+	render.JSON(w, o)
+}
+
+// loadDeferredOrder loads the order in the URL and the ACME provisioner in
+// the context. It writes the error and returns false if they cannot be
+// loaded.
+func loadDeferredOrder(w http.ResponseWriter, r *http.Request) (*acme.Order, acme.Provisioner, bool) {
+	ctx := r.Context()
+	prov := linkedca.MustProvisionerFromContext(ctx)
+
+	p, err := mustAuthority(ctx).LoadProvisionerByName(prov.GetName())
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error loading provisioner %s", prov.GetName()))
+		return nil, nil, false
+	}
+	acmeProv, ok := p.(acme.Provisioner)
+	if !ok {
+		render.Error(w, admin.NewError(admin.ErrorBadRequestType, "provisioner '%s' is not an ACME provisioner", prov.GetName()))
+		return nil, nil, false
+	}
+
+	id := chi.URLParam(r, "id")
+	o, err := acme.MustDatabaseFromContext(ctx).GetOrder(ctx, id)
+	if err != nil {
+		render.Error(w, orderDecisionError(err, "error retrieving ACME order '%s'", id))
+		return nil, nil, false
+	}
+	return o, acmeProv, true
+}
+
+// orderDecisionError converts the client errors returned by the acme package
+// into bad request errors.
+func orderDecisionError(err error, format string, args ...interface{}) error {
+	var acmeErr *acme.Error
+	if errors.As(err, &acmeErr) && acmeErr.StatusCode() < http.StatusInternalServerError {
+		return admin.NewError(admin.ErrorBadRequestType, "%s", acmeErr.Error())
+	}
+	return admin.WrapErrorISE(err, format, args...)
 }
 
 func eakToLinked(k *acme.ExternalAccountKey) *linkedca.EABKey {
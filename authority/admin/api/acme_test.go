@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -22,6 +23,7 @@ import (
 	"github.com/smallstep/assert"
 	"github.com/smallstep/certificates/acme"
 	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/provisioner"
 )
 
 func readProtoJSON(r io.ReadCloser, m proto.Message) error {
@@ -198,23 +200,118 @@ func TestCreateExternalAccountKeyRequest_Validate(t *testing.T) {
 }
 
 func TestHandler_CreateExternalAccountKey(t *testing.T) {
+	prov := &linkedca.Provisioner{
+		Id:   "provID",
+		Name: "provName",
+	}
 	type test struct {
 		ctx        context.Context
+		db         acme.DB
+		body       []byte
 		statusCode int
+		eak        *linkedca.EABKey
 		err        *admin.Error
 	}
 	var tests = map[string]func(t *testing.T) test{
-		"ok": func(t *testing.T) test {
-			chiCtx := chi.NewRouteContext()
-			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+		"fail/read.JSON": func(t *testing.T) test {
 			return test{
-				ctx:        ctx,
-				statusCode: 501,
+				ctx:        linkedca.NewContextWithProvisioner(context.Background(), prov),
+				db:         &acme.MockDB{},
+				body:       []byte("{!?}"),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Status:  400,
+					Detail:  "bad request",
+					Message: "error reading request body: error decoding json: invalid character '!' looking for beginning of object key string",
+				},
+			}
+		},
+		"fail/validate": func(t *testing.T) test {
+			body, err := json.Marshal(&CreateExternalAccountKeyRequest{Reference: strings.Repeat("A", 257)})
+			assert.FatalError(t, err)
+			return test{
+				ctx:        linkedca.NewContextWithProvisioner(context.Background(), prov),
+				db:         &acme.MockDB{},
+				body:       body,
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Status:  400,
+					Detail:  "bad request",
+					Message: "error validating request body: reference length 257 exceeds the maximum (256)",
+				},
+			}
+		},
+		"fail/reference-conflict": func(t *testing.T) test {
+			body, err := json.Marshal(&CreateExternalAccountKeyRequest{Reference: "ref"})
+			assert.FatalError(t, err)
+			return test{
+				ctx: linkedca.NewContextWithProvisioner(context.Background(), prov),
+				db: &acme.MockDB{
+					MockGetExternalAccountKeyByReference: func(ctx context.Context, provisionerID, reference string) (*acme.ExternalAccountKey, error) {
+						assert.Equals(t, "provID", provisionerID)
+						assert.Equals(t, "ref", reference)
+						return &acme.ExternalAccountKey{ID: "eakID", Reference: "ref"}, nil
+					},
+				},
+				body:       body,
+				statusCode: 409,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Status:  409,
+					Detail:  "bad request",
+					Message: "an ACME EAB key for provisioner 'provName' with reference 'ref' already exists",
+				},
+			}
+		},
+		"fail/db.CreateExternalAccountKey": func(t *testing.T) test {
+			body, err := json.Marshal(&CreateExternalAccountKeyRequest{})
+			assert.FatalError(t, err)
+			return test{
+				ctx: linkedca.NewContextWithProvisioner(context.Background(), prov),
+				db: &acme.MockDB{
+					MockCreateExternalAccountKey: func(ctx context.Context, provisionerID, reference string) (*acme.ExternalAccountKey, error) {
+						return nil, errors.New("force")
+					},
+				},
+				body:       body,
+				statusCode: 500,
 				err: &admin.Error{
-					Type:    admin.ErrorNotImplementedType.String(),
-					Status:  http.StatusNotImplemented,
-					Message: "this functionality is currently only available in Certificate Manager: https://u.step.sm/cm",
-					Detail:  "not implemented",
+					Type:    admin.ErrorServerInternalType.String(),
+					Status:  500,
+					Detail:  "the server experienced an internal error",
+					Message: "error creating ACME EAB key for provisioner 'provName': force",
+				},
+			}
+		},
+		"ok": func(t *testing.T) test {
+			body, err := json.Marshal(&CreateExternalAccountKeyRequest{Reference: "ref"})
+			assert.FatalError(t, err)
+			return test{
+				ctx: linkedca.NewContextWithProvisioner(context.Background(), prov),
+				db: &acme.MockDB{
+					MockGetExternalAccountKeyByReference: func(ctx context.Context, provisionerID, reference string) (*acme.ExternalAccountKey, error) {
+						return nil, acme.ErrNotFound
+					},
+					MockCreateExternalAccountKey: func(ctx context.Context, provisionerID, reference string) (*acme.ExternalAccountKey, error) {
+						assert.Equals(t, "provID", provisionerID)
+						assert.Equals(t, "ref", reference)
+						return &acme.ExternalAccountKey{
+							ID:            "eakID",
+							ProvisionerID: "provID",
+							Reference:     "ref",
+							HmacKey:       []byte{1, 3, 3, 7},
+						}, nil
+					},
+				},
+				body:       body,
+				statusCode: 201,
+				eak: &linkedca.EABKey{
+					Id:          "eakID",
+					Provisioner: "provName",
+					Reference:   "ref",
+					HmacKey:     []byte{1, 3, 3, 7},
 				},
 			}
 		},
@@ -222,62 +319,106 @@ func TestHandler_CreateExternalAccountKey(t *testing.T) {
 	for name, prep := range tests {
 		tc := prep(t)
 		t.Run(name, func(t *testing.T) {
-
-			req := httptest.NewRequest("POST", "/foo", http.NoBody) // chi routing is prepared in test setup
-			req = req.WithContext(tc.ctx)
+			ctx := acme.NewDatabaseContext(tc.ctx, tc.db)
+			req := httptest.NewRequest("POST", "/foo", bytes.NewReader(tc.body))
+			req = req.WithContext(ctx)
 			w := httptest.NewRecorder()
 			acmeResponder := NewACMEAdminResponder()
 			acmeResponder.CreateExternalAccountKey(w, req)
 			res := w.Result()
 			assert.Equals(t, tc.statusCode, res.StatusCode)
 
-			body, err := io.ReadAll(res.Body)
-			res.Body.Close()
-			assert.FatalError(t, err)
+			if res.StatusCode >= 400 {
+				body, err := io.ReadAll(res.Body)
+				res.Body.Close()
+				assert.FatalError(t, err)
 
-			adminErr := admin.Error{}
-			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
 
-			assert.Equals(t, tc.err.Type, adminErr.Type)
-			assert.Equals(t, tc.err.Message, adminErr.Message)
-			assert.Equals(t, tc.err.StatusCode(), res.StatusCode)
-			assert.Equals(t, tc.err.Detail, adminErr.Detail)
-			assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+				assert.Equals(t, tc.err.Type, adminErr.Type)
+				assert.Equals(t, tc.err.Message, adminErr.Message)
+				assert.Equals(t, tc.err.StatusCode(), res.StatusCode)
+				assert.Equals(t, tc.err.Detail, adminErr.Detail)
+				assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+				return
+			}
 
+			eak := &linkedca.EABKey{}
+			assert.FatalError(t, readProtoJSON(res.Body, eak))
+			assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+			assert.True(t, proto.Equal(tc.eak, eak))
 		})
 	}
 }
 
 func TestHandler_DeleteExternalAccountKey(t *testing.T) {
+	prov := &linkedca.Provisioner{
+		Id:   "provID",
+		Name: "provName",
+	}
 	type test struct {
-		ctx        context.Context
+		db         acme.DB
 		statusCode int
 		err        *admin.Error
 	}
 	var tests = map[string]func(t *testing.T) test{
-		"ok": func(t *testing.T) test {
-			chiCtx := chi.NewRouteContext()
-			chiCtx.URLParams.Add("provisionerName", "provName")
-			chiCtx.URLParams.Add("id", "keyID")
-			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+		"fail/not-found": func(t *testing.T) test {
 			return test{
-				ctx:        ctx,
-				statusCode: 501,
+				db: &acme.MockDB{
+					MockDeleteExternalAccountKey: func(ctx context.Context, provisionerID, keyID string) error {
+						return errors.Wrap(acme.ErrNotFound, "error loading ACME EAB Key")
+					},
+				},
+				statusCode: 404,
 				err: &admin.Error{
-					Type:    admin.ErrorNotImplementedType.String(),
-					Status:  http.StatusNotImplemented,
-					Message: "this functionality is currently only available in Certificate Manager: https://u.step.sm/cm",
-					Detail:  "not implemented",
+					Type:    admin.ErrorNotFoundType.String(),
+					Status:  404,
+					Detail:  "resource not found",
+					Message: "ACME EAB key 'keyID' not found",
 				},
 			}
 		},
+		"fail/db.DeleteExternalAccountKey": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockDeleteExternalAccountKey: func(ctx context.Context, provisionerID, keyID string) error {
+						return errors.New("force")
+					},
+				},
+				statusCode: 500,
+				err: &admin.Error{
+					Type:    admin.ErrorServerInternalType.String(),
+					Status:  500,
+					Detail:  "the server experienced an internal error",
+					Message: "error deleting ACME EAB key 'keyID': force",
+				},
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockDeleteExternalAccountKey: func(ctx context.Context, provisionerID, keyID string) error {
+						assert.Equals(t, "provID", provisionerID)
+						assert.Equals(t, "keyID", keyID)
+						return nil
+					},
+				},
+				statusCode: 200,
+			}
+		},
 	}
 	for name, prep := range tests {
 		tc := prep(t)
 		t.Run(name, func(t *testing.T) {
-
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("provisionerName", "provName")
+			chiCtx.URLParams.Add("id", "keyID")
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+			ctx = linkedca.NewContextWithProvisioner(ctx, prov)
+			ctx = acme.NewDatabaseContext(ctx, tc.db)
 			req := httptest.NewRequest("DELETE", "/foo", http.NoBody) // chi routing is prepared in test setup
-			req = req.WithContext(tc.ctx)
+			req = req.WithContext(ctx)
 			w := httptest.NewRecorder()
 			acmeResponder := NewACMEAdminResponder()
 			acmeResponder.DeleteExternalAccountKey(w, req)
@@ -287,41 +428,136 @@ func TestHandler_DeleteExternalAccountKey(t *testing.T) {
 			body, err := io.ReadAll(res.Body)
 			res.Body.Close()
 			assert.FatalError(t, err)
+			assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
 
-			adminErr := admin.Error{}
-			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tc.err.Type, adminErr.Type)
+				assert.Equals(t, tc.err.Message, adminErr.Message)
+				assert.Equals(t, tc.err.StatusCode(), res.StatusCode)
+				assert.Equals(t, tc.err.Detail, adminErr.Detail)
+				return
+			}
 
-			assert.Equals(t, tc.err.Type, adminErr.Type)
-			assert.Equals(t, tc.err.Message, adminErr.Message)
-			assert.Equals(t, tc.err.StatusCode(), res.StatusCode)
-			assert.Equals(t, tc.err.Detail, adminErr.Detail)
-			assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+			response := DeleteResponse{}
+			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &response))
+			assert.Equals(t, "ok", response.Status)
 		})
 	}
 }
 
 func TestHandler_GetExternalAccountKeys(t *testing.T) {
+	prov := &linkedca.Provisioner{
+		Id:   "provID",
+		Name: "provName",
+	}
+	createdAt := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	boundAt := time.Date(2022, time.February, 1, 0, 0, 0, 0, time.UTC)
 	type test struct {
-		ctx        context.Context
+		reference  string
+		target     string
+		db         acme.DB
 		statusCode int
-		req        *http.Request
+		resp       GetExternalAccountKeysResponse
 		err        *admin.Error
 	}
 	var tests = map[string]func(t *testing.T) test{
-		"ok": func(t *testing.T) test {
-			chiCtx := chi.NewRouteContext()
-			chiCtx.URLParams.Add("provisionerName", "provName")
-			req := httptest.NewRequest("GET", "/foo", http.NoBody)
-			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+		"fail/parse-cursor": func(t *testing.T) test {
 			return test{
-				ctx:        ctx,
-				statusCode: 501,
-				req:        req,
+				target:     "/foo?limit=A",
+				db:         &acme.MockDB{},
+				statusCode: 400,
 				err: &admin.Error{
-					Type:    admin.ErrorNotImplementedType.String(),
-					Status:  http.StatusNotImplemented,
-					Message: "this functionality is currently only available in Certificate Manager: https://u.step.sm/cm",
-					Detail:  "not implemented",
+					Type:    admin.ErrorBadRequestType.String(),
+					Status:  400,
+					Detail:  "bad request",
+					Message: "error parsing cursor and limit from query params: limit 'A' is not an integer: strconv.Atoi: parsing \"A\": invalid syntax",
+				},
+			}
+		},
+		"fail/reference-not-found": func(t *testing.T) test {
+			return test{
+				reference: "ref",
+				db: &acme.MockDB{
+					MockGetExternalAccountKeyByReference: func(ctx context.Context, provisionerID, reference string) (*acme.ExternalAccountKey, error) {
+						return nil, acme.ErrNotFound
+					},
+				},
+				statusCode: 404,
+				err: &admin.Error{
+					Type:    admin.ErrorNotFoundType.String(),
+					Status:  404,
+					Detail:  "resource not found",
+					Message: "ACME EAB key with reference 'ref' not found",
+				},
+			}
+		},
+		"fail/db.GetExternalAccountKeys": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetExternalAccountKeys: func(ctx context.Context, provisionerID, cursor string, limit int) ([]*acme.ExternalAccountKey, string, error) {
+						return nil, "", errors.New("force")
+					},
+				},
+				statusCode: 500,
+				err: &admin.Error{
+					Type:    admin.ErrorServerInternalType.String(),
+					Status:  500,
+					Detail:  "the server experienced an internal error",
+					Message: "error retrieving ACME EAB keys: force",
+				},
+			}
+		},
+		"ok/reference": func(t *testing.T) test {
+			return test{
+				reference: "ref",
+				db: &acme.MockDB{
+					MockGetExternalAccountKeyByReference: func(ctx context.Context, provisionerID, reference string) (*acme.ExternalAccountKey, error) {
+						assert.Equals(t, "provID", provisionerID)
+						assert.Equals(t, "ref", reference)
+						return &acme.ExternalAccountKey{
+							ID:            "eakID",
+							ProvisionerID: "provID",
+							Reference:     "ref",
+							HmacKey:       []byte{1, 3, 3, 7},
+							CreatedAt:     createdAt,
+						}, nil
+					},
+				},
+				statusCode: 200,
+				resp: GetExternalAccountKeysResponse{
+					EAKs: []*linkedca.EABKey{{
+						Id:          "eakID",
+						Provisioner: "provName",
+						Reference:   "ref",
+						CreatedAt:   timestamppb.New(createdAt),
+						BoundAt:     timestamppb.New(time.Time{}),
+					}},
+				},
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				target: "/foo?cursor=next&limit=10",
+				db: &acme.MockDB{
+					MockGetExternalAccountKeys: func(ctx context.Context, provisionerID, cursor string, limit int) ([]*acme.ExternalAccountKey, string, error) {
+						assert.Equals(t, "provID", provisionerID)
+						assert.Equals(t, "next", cursor)
+						assert.Equals(t, 10, limit)
+						return []*acme.ExternalAccountKey{
+							{ID: "eakID1", ProvisionerID: "provID", HmacKey: []byte{1}, CreatedAt: createdAt},
+							{ID: "eakID2", ProvisionerID: "provID", HmacKey: []byte{2}, AccountID: "accID", CreatedAt: createdAt, BoundAt: boundAt},
+						}, "nextCursor", nil
+					},
+				},
+				statusCode: 200,
+				resp: GetExternalAccountKeysResponse{
+					EAKs: []*linkedca.EABKey{
+						{Id: "eakID1", Provisioner: "provName", CreatedAt: timestamppb.New(createdAt), BoundAt: timestamppb.New(time.Time{})},
+						{Id: "eakID2", Provisioner: "provName", Account: "accID", CreatedAt: timestamppb.New(createdAt), BoundAt: timestamppb.New(boundAt)},
+					},
+					NextCursor: "nextCursor",
 				},
 			}
 		},
@@ -329,8 +565,19 @@ func TestHandler_GetExternalAccountKeys(t *testing.T) {
 	for name, prep := range tests {
 		tc := prep(t)
 		t.Run(name, func(t *testing.T) {
-
-			req := tc.req.WithContext(tc.ctx)
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("provisionerName", "provName")
+			if tc.reference != "" {
+				chiCtx.URLParams.Add("reference", tc.reference)
+			}
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+			ctx = linkedca.NewContextWithProvisioner(ctx, prov)
+			ctx = acme.NewDatabaseContext(ctx, tc.db)
+			target := tc.target
+			if target == "" {
+				target = "/foo"
+			}
+			req := httptest.NewRequest("GET", target, http.NoBody).WithContext(ctx)
 			w := httptest.NewRecorder()
 			acmeResponder := NewACMEAdminResponder()
 			acmeResponder.GetExternalAccountKeys(w, req)
@@ -341,15 +588,28 @@ func TestHandler_GetExternalAccountKeys(t *testing.T) {
 			body, err := io.ReadAll(res.Body)
 			res.Body.Close()
 			assert.FatalError(t, err)
+			assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
 
-			adminErr := admin.Error{}
-			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tc.err.Type, adminErr.Type)
+				assert.Equals(t, tc.err.Message, adminErr.Message)
+				assert.Equals(t, tc.err.StatusCode(), res.StatusCode)
+				assert.Equals(t, tc.err.Detail, adminErr.Detail)
+				return
+			}
 
-			assert.Equals(t, tc.err.Type, adminErr.Type)
-			assert.Equals(t, tc.err.Message, adminErr.Message)
-			assert.Equals(t, tc.err.StatusCode(), res.StatusCode)
-			assert.Equals(t, tc.err.Detail, adminErr.Detail)
-			assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+			response := GetExternalAccountKeysResponse{}
+			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &response))
+			assert.Equals(t, tc.resp.NextCursor, response.NextCursor)
+			if assert.Len(t, len(tc.resp.EAKs), response.EAKs) {
+				for i, eak := range tc.resp.EAKs {
+					if !proto.Equal(eak, response.EAKs[i]) {
+						t.Errorf("GetExternalAccountKeys() eak = %v, want %v", response.EAKs[i], eak)
+					}
+				}
+			}
 		})
 	}
 }
@@ -556,3 +816,226 @@ func Test_linkedEAKToCertificates(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_GetDeferredOrders(t *testing.T) {
+	prov := &linkedca.Provisioner{
+		Id:   "provID",
+		Name: "provName",
+	}
+	orders := []*acme.DeferredOrder{
+		{OrderID: "o1", AccountID: "accID", ProvisionerID: "provID", Status: acme.ApprovalPending},
+	}
+	type test struct {
+		db         acme.DB
+		statusCode int
+		err        *admin.Error
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/db.GetDeferredOrders": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetDeferredOrders: func(ctx context.Context, provisionerID string) ([]*acme.DeferredOrder, error) {
+						return nil, errors.New("force")
+					},
+				},
+				statusCode: 500,
+				err: &admin.Error{
+					Type:    admin.ErrorServerInternalType.String(),
+					Status:  500,
+					Detail:  "the server experienced an internal error",
+					Message: "error retrieving ACME orders waiting for an approval: force",
+				},
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetDeferredOrders: func(ctx context.Context, provisionerID string) ([]*acme.DeferredOrder, error) {
+						assert.Equals(t, "provID", provisionerID)
+						return orders, nil
+					},
+				},
+				statusCode: 200,
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			ctx := linkedca.NewContextWithProvisioner(context.Background(), prov)
+			ctx = acme.NewDatabaseContext(ctx, tc.db)
+			req := httptest.NewRequest("GET", "/foo", http.NoBody).WithContext(ctx)
+			w := httptest.NewRecorder()
+			NewACMEAdminResponder().GetDeferredOrders(w, req)
+			res := w.Result()
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tc.err.Type, adminErr.Type)
+				assert.Equals(t, tc.err.Message, adminErr.Message)
+				return
+			}
+
+			var resp GetDeferredOrdersResponse
+			assert.FatalError(t, json.Unmarshal(body, &resp))
+			if assert.Len(t, 1, resp.Orders) {
+				assert.Equals(t, "o1", resp.Orders[0].OrderID)
+			}
+		})
+	}
+}
+
+func TestHandler_ApproveRejectOrder(t *testing.T) {
+	prov := &linkedca.Provisioner{
+		Id:   "provID",
+		Name: "provName",
+	}
+	adm := &linkedca.Admin{
+		Subject: "admin@example.com",
+	}
+	acmeProv := &provisioner.ACME{
+		ID:   "provID",
+		Name: "provName",
+		Orders: &provisioner.ACMEOrderOptions{
+			Approval: &provisioner.ACMEApprovalOptions{Required: true},
+		},
+	}
+	type test struct {
+		auth       adminAuthority
+		db         acme.DB
+		approve    bool
+		body       string
+		statusCode int
+		err        *admin.Error
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/not-acme": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockLoadProvisionerByName: func(name string) (provisioner.Interface, error) {
+						return &provisioner.JWK{ID: "provID", Name: "provName"}, nil
+					},
+				},
+				db:         &acme.MockDB{},
+				body:       "{}",
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Message: "provisioner 'provName' is not an ACME provisioner",
+				},
+			}
+		},
+		"fail/reject-body": func(t *testing.T) test {
+			return test{
+				body:       "{",
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Message: "error reading request body: error decoding json: unexpected EOF",
+				},
+			}
+		},
+		"fail/approve-not-processing": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockLoadProvisionerByName: func(name string) (provisioner.Interface, error) {
+						return acmeProv, nil
+					},
+				},
+				db: &acme.MockDB{
+					MockGetOrder: func(ctx context.Context, id string) (*acme.Order, error) {
+						return &acme.Order{ID: id, ProvisionerID: "provID", Status: acme.StatusReady}, nil
+					},
+				},
+				approve:    true,
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Message: "order orderID is not waiting for an approval",
+				},
+			}
+		},
+		"ok/reject": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockLoadProvisionerByName: func(name string) (provisioner.Interface, error) {
+						assert.Equals(t, "provName", name)
+						return acmeProv, nil
+					},
+				},
+				db: &acme.MockDB{
+					MockGetOrder: func(ctx context.Context, id string) (*acme.Order, error) {
+						assert.Equals(t, "orderID", id)
+						return &acme.Order{ID: id, ProvisionerID: "provID", Status: acme.StatusProcessing}, nil
+					},
+					MockGetDeferredOrder: func(ctx context.Context, orderID string) (*acme.DeferredOrder, error) {
+						return &acme.DeferredOrder{OrderID: orderID, Status: acme.ApprovalPending}, nil
+					},
+					MockUpdateDeferredOrder: func(ctx context.Context, d *acme.DeferredOrder, from acme.ApprovalStatus) error {
+						assert.Equals(t, acme.ApprovalRejected, d.Status)
+						assert.Equals(t, "admin@example.com", d.DecidedBy)
+						assert.Equals(t, "not allowed", d.Reason)
+						return nil
+					},
+					MockUpdateOrder: func(ctx context.Context, o *acme.Order) error {
+						assert.Equals(t, acme.StatusInvalid, o.Status)
+						return nil
+					},
+				},
+				body:       `{"reason":"not allowed"}`,
+				statusCode: 200,
+			}
+		},
+	}
+	fn := mustACMEAuthority
+	t.Cleanup(func() {
+		mustACMEAuthority = fn
+	})
+	mustACMEAuthority = func(ctx context.Context) acme.CertificateAuthority {
+		return nil
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			mockMustAuthority(t, tc.auth)
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("provisionerName", "provName")
+			chiCtx.URLParams.Add("id", "orderID")
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+			ctx = linkedca.NewContextWithProvisioner(ctx, prov)
+			ctx = linkedca.NewContextWithAdmin(ctx, adm)
+			ctx = acme.NewDatabaseContext(ctx, tc.db)
+			req := httptest.NewRequest("POST", "/foo", strings.NewReader(tc.body)).WithContext(ctx)
+			w := httptest.NewRecorder()
+			if tc.approve {
+				NewACMEAdminResponder().ApproveOrder(w, req)
+			} else {
+				NewACMEAdminResponder().RejectOrder(w, req)
+			}
+			res := w.Result()
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tc.err.Type, adminErr.Type)
+				assert.Equals(t, tc.err.Message, adminErr.Message)
+				return
+			}
+
+			var o acme.Order
+			assert.FatalError(t, json.Unmarshal(body, &o))
+			assert.Equals(t, acme.StatusInvalid, o.Status)
+		})
+	}
+}
@@ -0,0 +1,443 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/api/pagination"
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// ACMEAccount is the representation of an ACME account in the admin API.
+type ACMEAccount struct {
+	ID          string      `json:"id"`
+	KeyID       string      `json:"keyID"`
+	Status      acme.Status `json:"status"`
+	Contact     []string    `json:"contact,omitempty"`
+	Provisioner string      `json:"provisioner"`
+}
+
+// ACMEOrder is the representation of an ACME order in the admin API.
+type ACMEOrder struct {
+	ID               string            `json:"id"`
+	Status           acme.Status       `json:"status"`
+	Identifiers      []acme.Identifier `json:"identifiers"`
+	NotBefore        time.Time         `json:"notBefore"`
+	NotAfter         time.Time         `json:"notAfter"`
+	ExpiresAt        time.Time         `json:"expiresAt"`
+	AuthorizationIDs []string          `json:"authorizationIDs"`
+	CertificateID    string            `json:"certificateID,omitempty"`
+	Error            *acme.Error       `json:"error,omitempty"`
+}
+
+// ACMEAuthorization is the representation of an ACME authorization in the
+// admin API.
+type ACMEAuthorization struct {
+	ID         string          `json:"id"`
+	Identifier acme.Identifier `json:"identifier"`
+	Status     acme.Status     `json:"status"`
+	Wildcard   bool            `json:"wildcard"`
+	ExpiresAt  time.Time       `json:"expiresAt"`
+	Error      *acme.Error     `json:"error,omitempty"`
+}
+
+// ACMECertificate is the representation of a certificate issued to an ACME
+// account in the admin API. The type is "x509" or "ssh".
+type ACMECertificate struct {
+	ID           string    `json:"id"`
+	OrderID      string    `json:"orderID"`
+	Type         string    `json:"type"`
+	SerialNumber string    `json:"serialNumber"`
+	Subject      string    `json:"subject"`
+	SANs         []string  `json:"sans"`
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `json:"notAfter"`
+	Expired      bool      `json:"expired"`
+	Revoked      bool      `json:"revoked"`
+}
+
+// GetACMEAccountsResponse is the type for GET
+// /admin/acme/accounts/{provisionerName} responses.
+type GetACMEAccountsResponse struct {
+	Accounts   []*ACMEAccount `json:"accounts"`
+	NextCursor string         `json:"nextCursor"`
+}
+
+// GetACMEOrdersResponse is the type for GET
+// /admin/acme/accounts/{provisionerName}/{id}/orders responses.
+type GetACMEOrdersResponse struct {
+	Orders []*ACMEOrder `json:"orders"`
+}
+
+// GetACMEAuthorizationsResponse is the type for GET
+// /admin/acme/accounts/{provisionerName}/{id}/authorizations responses.
+type GetACMEAuthorizationsResponse struct {
+	Authorizations []*ACMEAuthorization `json:"authorizations"`
+}
+
+// GetACMECertificatesResponse is the type for GET
+// /admin/acme/accounts/{provisionerName}/{id}/certificates responses.
+type GetACMECertificatesResponse struct {
+	Certificates []*ACMECertificate `json:"certificates"`
+}
+
+// RevokeACMECertificateRequest is the type for POST
+// /admin/acme/certificates/{provisionerName}/{serial}/revoke requests.
+type RevokeACMECertificateRequest struct {
+	ReasonCode int    `json:"reasonCode"`
+	Reason     string `json:"reason"`
+}
+
+// Validate validates a revoke certificate request body. The reason codes are
+// the ones defined in RFC 5280, section 5.3.1.
+func (r *RevokeACMECertificateRequest) Validate() error {
+	if r.ReasonCode < 0 || r.ReasonCode > 10 || r.ReasonCode == 7 {
+		return admin.NewError(admin.ErrorBadRequestType, "reason code %d is not valid", r.ReasonCode)
+	}
+	if len(r.Reason) > 1024 {
+		return admin.NewError(admin.ErrorBadRequestType, "reason length %d exceeds the maximum (1024)", len(r.Reason))
+	}
+	return nil
+}
+
+var (
+	// acmeAccountFilterFields are the fields that can be used to filter the
+	// list of ACME accounts.
+	acmeAccountFilterFields = []string{"status", "contact", "keyID"}
+)
+
+// GetAccounts writes the response for the endpoint listing the ACME accounts
+// of a provisioner. The accounts can be filtered by status, contact and key
+// id, and the id of the first account of the next page is the cursor.
+func (h *acmeAdminResponder) GetAccounts(w http.ResponseWriter, r *http.Request) {
+	opts, err := pagination.Parse(r, nil, acmeAccountFilterFields)
+	if err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err,
+			"error parsing cursor and limit from query params"))
+		return
+	}
+
+	ctx := r.Context()
+	prov := linkedca.MustProvisionerFromContext(ctx)
+	accs, err := acme.MustDatabaseFromContext(ctx).GetAccountsByProvisioner(ctx, prov.GetName())
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error retrieving ACME accounts"))
+		return
+	}
+
+	accounts := make([]*ACMEAccount, 0, len(accs))
+	for _, acc := range accs {
+		accounts = append(accounts, accountToAdmin(acc))
+	}
+	accounts = pagination.Filter(accounts, func(a *ACMEAccount) bool {
+		return opts.Match("status", string(a.Status)) &&
+			opts.MatchAny("contact", a.Contact) &&
+			opts.Match("keyID", a.KeyID)
+	})
+	page, next, err := pagination.Paginate(accounts, opts, func(a *ACMEAccount) string {
+		return a.ID
+	})
+	if err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error paginating ACME accounts"))
+		return
+	}
+
+	pagination.SetLinkHeader(w, r, next)
+	render.JSON(w, &GetACMEAccountsResponse{
+		Accounts:   page,
+		NextCursor: next,
+	})
+}
+
+// GetAccount writes the response for the ACME account GET endpoint.
+func (h *acmeAdminResponder) GetAccount(w http.ResponseWriter, r *http.Request) {
+	acc, ok := loadACMEAccount(w, r)
+	if !ok {
+		return
+	}
+	render.JSON(w, accountToAdmin(acc))
+}
+
+// DisableAccount writes the response for the endpoint disabling an ACME
+// account. The account is deactivated as if the client had requested it, its
+// pending orders and authorizations become invalid.
+func (h *acmeAdminResponder) DisableAccount(w http.ResponseWriter, r *http.Request) {
+	acc, ok := loadACMEAccount(w, r)
+	if !ok {
+		return
+	}
+	if acc.Status == acme.StatusDeactivated {
+		render.Error(w, admin.NewError(admin.ErrorBadRequestType, "ACME account '%s' is already deactivated", acc.ID))
+		return
+	}
+
+	ctx := r.Context()
+	if err := acc.Deactivate(ctx, acme.MustDatabaseFromContext(ctx)); err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error deactivating ACME account '%s'", acc.ID))
+		return
+	}
+	render.JSON(w, accountToAdmin(acc))
+}
+
+// GetAccountOrders writes the response for the endpoint listing the orders of
+// an ACME account, in any status.
+func (h *acmeAdminResponder) GetAccountOrders(w http.ResponseWriter, r *http.Request) {
+	acc, ok := loadACMEAccount(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	list, err := acme.MustDatabaseFromContext(ctx).GetAllOrdersByAccountID(ctx, acc.ID)
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error retrieving orders of ACME account '%s'", acc.ID))
+		return
+	}
+	orders := make([]*ACMEOrder, len(list))
+	for i, o := range list {
+		orders[i] = &ACMEOrder{
+			ID:               o.ID,
+			Status:           o.Status,
+			Identifiers:      o.Identifiers,
+			NotBefore:        o.NotBefore,
+			NotAfter:         o.NotAfter,
+			ExpiresAt:        o.ExpiresAt,
+			AuthorizationIDs: o.AuthorizationIDs,
+			CertificateID:    o.CertificateID,
+			Error:            o.Error,
+		}
+	}
+	render.JSON(w, &GetACMEOrdersResponse{Orders: orders})
+}
+
+// GetAccountAuthorizations writes the response for the endpoint listing the
+// authorizations of an ACME account.
+func (h *acmeAdminResponder) GetAccountAuthorizations(w http.ResponseWriter, r *http.Request) {
+	acc, ok := loadACMEAccount(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	azs, err := acme.MustDatabaseFromContext(ctx).GetAuthorizationsByAccountID(ctx, acc.ID)
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error retrieving authorizations of ACME account '%s'", acc.ID))
+		return
+	}
+	authzs := make([]*ACMEAuthorization, len(azs))
+	for i, az := range azs {
+		authzs[i] = authorizationToAdmin(az)
+	}
+	render.JSON(w, &GetACMEAuthorizationsResponse{Authorizations: authzs})
+}
+
+// InvalidateAuthorization writes the response for the endpoint invalidating
+// an authorization of an ACME account. The account must validate the
+// identifier again before getting a new certificate for it.
+func (h *acmeAdminResponder) InvalidateAuthorization(w http.ResponseWriter, r *http.Request) {
+	acc, ok := loadACMEAccount(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	db := acme.MustDatabaseFromContext(ctx)
+	authzID := chi.URLParam(r, "authzID")
+	az, err := db.GetAuthorization(ctx, authzID)
+	if err != nil {
+		render.Error(w, orderDecisionError(err, "error retrieving ACME authorization '%s'", authzID))
+		return
+	}
+	if az.AccountID != acc.ID {
+		render.Error(w, admin.NewError(admin.ErrorNotFoundType, "ACME authorization '%s' not found", authzID))
+		return
+	}
+	if az.Status == acme.StatusInvalid {
+		render.Error(w, admin.NewError(admin.ErrorBadRequestType, "ACME authorization '%s' is already invalid", authzID))
+		return
+	}
+
+	az.Status = acme.StatusInvalid
+	az.Error = acme.NewError(acme.ErrorUnauthorizedType, "authorization %s has been invalidated by an administrator", az.ID)
+	if err := db.UpdateAuthorization(ctx, az); err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error updating ACME authorization '%s'", authzID))
+		return
+	}
+	render.JSON(w, authorizationToAdmin(az))
+}
+
+// GetAccountCertificates writes the response for the endpoint listing the
+// certificates issued to an ACME account, with their expiration and
+// revocation status.
+func (h *acmeAdminResponder) GetAccountCertificates(w http.ResponseWriter, r *http.Request) {
+	acc, ok := loadACMEAccount(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	cs, err := acme.MustDatabaseFromContext(ctx).GetCertificatesByAccountID(ctx, acc.ID)
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error retrieving certificates of ACME account '%s'", acc.ID))
+		return
+	}
+	now := time.Now()
+	certs := make([]*ACMECertificate, len(cs))
+	for i, c := range cs {
+		cert := certificateToAdmin(c, now)
+		if c.Leaf != nil {
+			if cert.Revoked, err = mustACMEAuthority(ctx).IsRevoked(cert.SerialNumber); err != nil {
+				render.Error(w, admin.WrapErrorISE(err, "error checking revocation of certificate '%s'", cert.SerialNumber))
+				return
+			}
+		}
+		certs[i] = cert
+	}
+	render.JSON(w, &GetACMECertificatesResponse{Certificates: certs})
+}
+
+// RevokeCertificate writes the response for the endpoint revoking a
+// certificate issued to an ACME account of the provisioner. The serial number
+// can be in decimal, or in hexadecimal with the "0x" prefix or with colons.
+func (h *acmeAdminResponder) RevokeCertificate(w http.ResponseWriter, r *http.Request) {
+	var body RevokeACMECertificateRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+	serial, ok := parseSerialNumber(chi.URLParam(r, "serial"))
+	if !ok {
+		render.Error(w, admin.NewError(admin.ErrorBadRequestType, "serial number is not valid"))
+		return
+	}
+
+	ctx := r.Context()
+	prov := linkedca.MustProvisionerFromContext(ctx)
+	db := acme.MustDatabaseFromContext(ctx)
+	cert, err := db.GetCertificateBySerial(ctx, serial)
+	if err != nil {
+		render.Error(w, orderDecisionError(err, "error retrieving ACME certificate '%s'", serial))
+		return
+	}
+	if _, err := getProvisionerAccount(ctx, db, prov, cert.AccountID); err != nil {
+		render.Error(w, admin.NewError(admin.ErrorNotFoundType, "ACME certificate '%s' not found", serial))
+		return
+	}
+	if cert.Leaf == nil {
+		render.Error(w, admin.NewError(admin.ErrorBadRequestType, "ACME certificate '%s' is not an X.509 certificate", serial))
+		return
+	}
+
+	ctx = provisioner.NewContextWithMethod(ctx, provisioner.RevokeMethod)
+	if err := mustACMEAuthority(ctx).Revoke(ctx, &authority.RevokeOptions{
+		Serial:     serial,
+		Reason:     body.Reason,
+		ReasonCode: body.ReasonCode,
+		ACME:       true,
+		Crt:        cert.Leaf,
+	}); err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, &DeleteResponse{Status: "ok"})
+}
+
+// loadACMEAccount loads the ACME account in the URL. It writes the error and
+// returns false if the account does not exist or if it does not belong to the
+// provisioner in the context.
+func loadACMEAccount(w http.ResponseWriter, r *http.Request) (*acme.Account, bool) {
+	ctx := r.Context()
+	prov := linkedca.MustProvisionerFromContext(ctx)
+	acc, err := getProvisionerAccount(ctx, acme.MustDatabaseFromContext(ctx), prov, chi.URLParam(r, "id"))
+	if err != nil {
+		render.Error(w, err)
+		return nil, false
+	}
+	return acc, true
+}
+
+func getProvisionerAccount(ctx context.Context, db acme.DB, prov *linkedca.Provisioner, id string) (*acme.Account, error) {
+	acc, err := db.GetAccount(ctx, id)
+	switch {
+	case acme.IsErrNotFound(err):
+		return nil, admin.NewError(admin.ErrorNotFoundType, "ACME account '%s' not found", id)
+	case err != nil:
+		return nil, orderDecisionError(err, "error retrieving ACME account '%s'", id)
+	case acc.ProvisionerName != prov.GetName():
+		return nil, admin.NewError(admin.ErrorNotFoundType, "ACME account '%s' not found", id)
+	}
+	return acc, nil
+}
+
+func accountToAdmin(acc *acme.Account) *ACMEAccount {
+	// The key id is the one used to look up the account, errors are not
+	// expected with the stored keys.
+	var keyID string
+	if acc.Key != nil {
+		keyID, _ = acme.KeyToID(acc.Key)
+	}
+	return &ACMEAccount{
+		ID:          acc.ID,
+		KeyID:       keyID,
+		Status:      acc.Status,
+		Contact:     acc.Contact,
+		Provisioner: acc.ProvisionerName,
+	}
+}
+
+func authorizationToAdmin(az *acme.Authorization) *ACMEAuthorization {
+	return &ACMEAuthorization{
+		ID:         az.ID,
+		Identifier: az.Identifier,
+		Status:     az.Status,
+		Wildcard:   az.Wildcard,
+		ExpiresAt:  az.ExpiresAt,
+		Error:      az.Error,
+	}
+}
+
+func certificateToAdmin(c *acme.Certificate, now time.Time) *ACMECertificate {
+	cert := &ACMECertificate{
+		ID:           c.ID,
+		OrderID:      c.OrderID,
+		SerialNumber: c.SerialNumber(),
+	}
+	if c.SSH != nil {
+		cert.Type = "ssh"
+		cert.Subject = c.SSH.KeyId
+		cert.SANs = c.SSH.ValidPrincipals
+		cert.NotBefore = time.Unix(int64(c.SSH.ValidAfter), 0).UTC()
+		cert.NotAfter = time.Unix(int64(c.SSH.ValidBefore), 0).UTC()
+	} else {
+		cert.Type = "x509"
+		cert.Subject = c.Leaf.Subject.CommonName
+		cert.SANs = append(cert.SANs, c.Leaf.DNSNames...)
+		for _, ip := range c.Leaf.IPAddresses {
+			cert.SANs = append(cert.SANs, ip.String())
+		}
+		cert.SANs = append(cert.SANs, c.Leaf.EmailAddresses...)
+		for _, u := range c.Leaf.URIs {
+			cert.SANs = append(cert.SANs, u.String())
+		}
+		cert.NotBefore = c.Leaf.NotBefore
+		cert.NotAfter = c.Leaf.NotAfter
+	}
+	cert.Expired = !now.Before(cert.NotAfter)
+	if cert.SANs == nil {
+		cert.SANs = []string{}
+	}
+	return cert
+}
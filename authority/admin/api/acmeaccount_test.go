@@ -0,0 +1,420 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+type mockACMECertificateAuthority struct {
+	acme.CertificateAuthority
+	MockIsRevoked func(sn string) (bool, error)
+	MockRevoke    func(ctx context.Context, opts *authority.RevokeOptions) error
+}
+
+func (m *mockACMECertificateAuthority) IsRevoked(sn string) (bool, error) {
+	if m.MockIsRevoked != nil {
+		return m.MockIsRevoked(sn)
+	}
+	return false, nil
+}
+
+func (m *mockACMECertificateAuthority) Revoke(ctx context.Context, opts *authority.RevokeOptions) error {
+	if m.MockRevoke != nil {
+		return m.MockRevoke(ctx, opts)
+	}
+	return nil
+}
+
+func newACMETestCertificate(t *testing.T, serial int64, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "test.example.com"},
+		DNSNames:     []string{"test.example.com"},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	assert.FatalError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	assert.FatalError(t, err)
+	return crt
+}
+
+func newACMEAdminRequest(method, body string, db acme.DB, params map[string]string) *http.Request {
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add("provisionerName", "provName")
+	for k, v := range params {
+		chiCtx.URLParams.Add(k, v)
+	}
+	ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+	ctx = linkedca.NewContextWithProvisioner(ctx, &linkedca.Provisioner{Id: "provID", Name: "provName"})
+	ctx = acme.NewDatabaseContext(ctx, db)
+	return httptest.NewRequest(method, "/foo", strings.NewReader(body)).WithContext(ctx)
+}
+
+func readACMEAdminResponse(t *testing.T, w *httptest.ResponseRecorder, statusCode int, errMessage string, v interface{}) {
+	t.Helper()
+	res := w.Result()
+	assert.Equals(t, statusCode, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.FatalError(t, err)
+	if res.StatusCode >= 400 {
+		adminErr := admin.Error{}
+		assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+		assert.Equals(t, errMessage, adminErr.Message)
+		return
+	}
+	assert.FatalError(t, json.Unmarshal(body, v))
+}
+
+func TestHandler_GetAccounts(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	pub := jwk.Public()
+	keyID, err := acme.KeyToID(&pub)
+	assert.FatalError(t, err)
+
+	accounts := []*acme.Account{
+		{ID: "acc1", Key: &pub, Status: acme.StatusValid, Contact: []string{"mailto:a@example.com"}, ProvisionerName: "provName"},
+		{ID: "acc2", Status: acme.StatusDeactivated, ProvisionerName: "provName"},
+		{ID: "acc3", Status: acme.StatusValid, Contact: []string{"mailto:b@example.com"}, ProvisionerName: "provName"},
+	}
+	db := &acme.MockDB{
+		MockGetAccountsByProvisioner: func(ctx context.Context, provisionerName string) ([]*acme.Account, error) {
+			assert.Equals(t, "provName", provisionerName)
+			return accounts, nil
+		},
+	}
+
+	tests := []struct {
+		name       string
+		db         acme.DB
+		query      string
+		statusCode int
+		err        string
+		ids        []string
+		next       string
+	}{
+		{"ok", db, "", 200, "", []string{"acc1", "acc2", "acc3"}, ""},
+		{"ok/status", db, "?status=valid", 200, "", []string{"acc1", "acc3"}, ""},
+		{"ok/contact", db, "?contact=mailto:b@example.com", 200, "", []string{"acc3"}, ""},
+		{"ok/keyID", db, "?keyID=" + keyID, 200, "", []string{"acc1"}, ""},
+		{"ok/limit", db, "?limit=1&cursor=acc2", 200, "", []string{"acc2"}, "acc3"},
+		{"fail/cursor", db, "?cursor=missing", 400, "error paginating ACME accounts: cursor 'missing' is not valid", nil, ""},
+		{"fail/db", &acme.MockDB{MockError: errors.New("force")}, "", 500, "error retrieving ACME accounts: force", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newACMEAdminRequest("GET", "", tt.db, nil)
+			req.URL.RawQuery = strings.TrimPrefix(tt.query, "?")
+			w := httptest.NewRecorder()
+			NewACMEAdminResponder().GetAccounts(w, req)
+
+			var resp GetACMEAccountsResponse
+			readACMEAdminResponse(t, w, tt.statusCode, tt.err, &resp)
+			if tt.statusCode >= 400 {
+				return
+			}
+			ids := []string{}
+			for _, a := range resp.Accounts {
+				ids = append(ids, a.ID)
+			}
+			assert.Equals(t, tt.ids, ids)
+			assert.Equals(t, tt.next, resp.NextCursor)
+		})
+	}
+}
+
+func TestHandler_GetAccount(t *testing.T) {
+	tests := []struct {
+		name       string
+		acc        *acme.Account
+		err        error
+		statusCode int
+		errMessage string
+	}{
+		{"ok", &acme.Account{ID: "accID", Status: acme.StatusValid, ProvisionerName: "provName"}, nil, 200, ""},
+		{"fail/not-found", nil, acme.ErrNotFound, 404, "ACME account 'accID' not found"},
+		{"fail/other-provisioner", &acme.Account{ID: "accID", ProvisionerName: "other"}, nil, 404, "ACME account 'accID' not found"},
+		{"fail/db", nil, errors.New("force"), 500, "error retrieving ACME account 'accID': force"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &acme.MockDB{
+				MockGetAccount: func(ctx context.Context, id string) (*acme.Account, error) {
+					assert.Equals(t, "accID", id)
+					return tt.acc, tt.err
+				},
+			}
+			req := newACMEAdminRequest("GET", "", db, map[string]string{"id": "accID"})
+			w := httptest.NewRecorder()
+			NewACMEAdminResponder().GetAccount(w, req)
+
+			var resp ACMEAccount
+			readACMEAdminResponse(t, w, tt.statusCode, tt.errMessage, &resp)
+			if tt.statusCode < 400 {
+				assert.Equals(t, "accID", resp.ID)
+				assert.Equals(t, "provName", resp.Provisioner)
+			}
+		})
+	}
+}
+
+func TestHandler_DisableAccount(t *testing.T) {
+	t.Run("fail/deactivated", func(t *testing.T) {
+		db := &acme.MockDB{
+			MockGetAccount: func(ctx context.Context, id string) (*acme.Account, error) {
+				return &acme.Account{ID: id, Status: acme.StatusDeactivated, ProvisionerName: "provName"}, nil
+			},
+		}
+		w := httptest.NewRecorder()
+		NewACMEAdminResponder().DisableAccount(w, newACMEAdminRequest("POST", "", db, map[string]string{"id": "accID"}))
+		readACMEAdminResponse(t, w, 400, "ACME account 'accID' is already deactivated", nil)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		var updated []string
+		db := &acme.MockDB{
+			MockGetAccount: func(ctx context.Context, id string) (*acme.Account, error) {
+				return &acme.Account{ID: id, Status: acme.StatusValid, ProvisionerName: "provName"}, nil
+			},
+			MockUpdateAccount: func(ctx context.Context, acc *acme.Account) error {
+				assert.Equals(t, acme.StatusDeactivated, acc.Status)
+				return nil
+			},
+			MockGetOrdersByAccountID: func(ctx context.Context, accountID string) ([]string, error) {
+				return []string{}, nil
+			},
+			MockGetAuthorizationsByAccountID: func(ctx context.Context, accountID string) ([]*acme.Authorization, error) {
+				return []*acme.Authorization{
+					{ID: "az1", Status: acme.StatusPending},
+					{ID: "az2", Status: acme.StatusValid},
+				}, nil
+			},
+			MockUpdateAuthorization: func(ctx context.Context, az *acme.Authorization) error {
+				updated = append(updated, az.ID)
+				return nil
+			},
+		}
+		w := httptest.NewRecorder()
+		NewACMEAdminResponder().DisableAccount(w, newACMEAdminRequest("POST", "", db, map[string]string{"id": "accID"}))
+
+		var resp ACMEAccount
+		readACMEAdminResponse(t, w, 200, "", &resp)
+		assert.Equals(t, acme.StatusDeactivated, resp.Status)
+		assert.Equals(t, []string{"az1"}, updated)
+	})
+}
+
+func TestHandler_GetAccountOrdersAndAuthorizations(t *testing.T) {
+	db := &acme.MockDB{
+		MockGetAccount: func(ctx context.Context, id string) (*acme.Account, error) {
+			return &acme.Account{ID: id, Status: acme.StatusValid, ProvisionerName: "provName"}, nil
+		},
+		MockGetAllOrdersByAccountID: func(ctx context.Context, accountID string) ([]*acme.Order, error) {
+			assert.Equals(t, "accID", accountID)
+			return []*acme.Order{
+				{ID: "o1", Status: acme.StatusValid, AuthorizationIDs: []string{"az1"}, CertificateID: "certID"},
+				{ID: "o2", Status: acme.StatusInvalid},
+			}, nil
+		},
+		MockGetAuthorizationsByAccountID: func(ctx context.Context, accountID string) ([]*acme.Authorization, error) {
+			assert.Equals(t, "accID", accountID)
+			return []*acme.Authorization{
+				{ID: "az1", Status: acme.StatusValid, Identifier: acme.Identifier{Type: acme.DNS, Value: "example.com"}},
+			}, nil
+		},
+	}
+
+	w := httptest.NewRecorder()
+	NewACMEAdminResponder().GetAccountOrders(w, newACMEAdminRequest("GET", "", db, map[string]string{"id": "accID"}))
+	var orders GetACMEOrdersResponse
+	readACMEAdminResponse(t, w, 200, "", &orders)
+	if assert.Len(t, 2, orders.Orders) {
+		assert.Equals(t, "o1", orders.Orders[0].ID)
+		assert.Equals(t, "certID", orders.Orders[0].CertificateID)
+		assert.Equals(t, []string{"az1"}, orders.Orders[0].AuthorizationIDs)
+		assert.Equals(t, acme.StatusInvalid, orders.Orders[1].Status)
+	}
+
+	w = httptest.NewRecorder()
+	NewACMEAdminResponder().GetAccountAuthorizations(w, newACMEAdminRequest("GET", "", db, map[string]string{"id": "accID"}))
+	var authzs GetACMEAuthorizationsResponse
+	readACMEAdminResponse(t, w, 200, "", &authzs)
+	if assert.Len(t, 1, authzs.Authorizations) {
+		assert.Equals(t, "az1", authzs.Authorizations[0].ID)
+		assert.Equals(t, "example.com", authzs.Authorizations[0].Identifier.Value)
+	}
+}
+
+func TestHandler_InvalidateAuthorization(t *testing.T) {
+	tests := []struct {
+		name       string
+		az         *acme.Authorization
+		statusCode int
+		err        string
+	}{
+		{"ok", &acme.Authorization{ID: "azID", AccountID: "accID", Status: acme.StatusValid}, 200, ""},
+		{"fail/other-account", &acme.Authorization{ID: "azID", AccountID: "other", Status: acme.StatusValid}, 404, "ACME authorization 'azID' not found"},
+		{"fail/invalid", &acme.Authorization{ID: "azID", AccountID: "accID", Status: acme.StatusInvalid}, 400, "ACME authorization 'azID' is already invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated bool
+			db := &acme.MockDB{
+				MockGetAccount: func(ctx context.Context, id string) (*acme.Account, error) {
+					return &acme.Account{ID: id, Status: acme.StatusValid, ProvisionerName: "provName"}, nil
+				},
+				MockGetAuthorization: func(ctx context.Context, id string) (*acme.Authorization, error) {
+					assert.Equals(t, "azID", id)
+					return tt.az, nil
+				},
+				MockUpdateAuthorization: func(ctx context.Context, az *acme.Authorization) error {
+					assert.Equals(t, acme.StatusInvalid, az.Status)
+					updated = true
+					return nil
+				},
+			}
+			w := httptest.NewRecorder()
+			NewACMEAdminResponder().InvalidateAuthorization(w, newACMEAdminRequest("POST", "", db, map[string]string{"id": "accID", "authzID": "azID"}))
+
+			var resp ACMEAuthorization
+			readACMEAdminResponse(t, w, tt.statusCode, tt.err, &resp)
+			assert.Equals(t, tt.statusCode == 200, updated)
+			if tt.statusCode == 200 {
+				assert.Equals(t, acme.StatusInvalid, resp.Status)
+				assert.NotNil(t, resp.Error)
+			}
+		})
+	}
+}
+
+func TestHandler_GetAccountCertificates(t *testing.T) {
+	now := time.Now()
+	valid := newACMETestCertificate(t, 1, now.Add(time.Hour))
+	expired := newACMETestCertificate(t, 2, now.Add(-time.Hour))
+	db := &acme.MockDB{
+		MockGetAccount: func(ctx context.Context, id string) (*acme.Account, error) {
+			return &acme.Account{ID: id, Status: acme.StatusValid, ProvisionerName: "provName"}, nil
+		},
+		MockGetCertificatesByAccountID: func(ctx context.Context, accountID string) ([]*acme.Certificate, error) {
+			assert.Equals(t, "accID", accountID)
+			return []*acme.Certificate{
+				{ID: "c1", AccountID: accountID, OrderID: "o1", Leaf: valid},
+				{ID: "c2", AccountID: accountID, OrderID: "o2", Leaf: expired},
+			}, nil
+		},
+	}
+
+	fn := mustACMEAuthority
+	t.Cleanup(func() {
+		mustACMEAuthority = fn
+	})
+	mustACMEAuthority = func(ctx context.Context) acme.CertificateAuthority {
+		return &mockACMECertificateAuthority{
+			MockIsRevoked: func(sn string) (bool, error) {
+				return sn == "2", nil
+			},
+		}
+	}
+
+	w := httptest.NewRecorder()
+	NewACMEAdminResponder().GetAccountCertificates(w, newACMEAdminRequest("GET", "", db, map[string]string{"id": "accID"}))
+	var resp GetACMECertificatesResponse
+	readACMEAdminResponse(t, w, 200, "", &resp)
+	if assert.Len(t, 2, resp.Certificates) {
+		c1, c2 := resp.Certificates[0], resp.Certificates[1]
+		assert.Equals(t, "x509", c1.Type)
+		assert.Equals(t, "1", c1.SerialNumber)
+		assert.Equals(t, "test.example.com", c1.Subject)
+		assert.Equals(t, []string{"test.example.com"}, c1.SANs)
+		assert.False(t, c1.Expired)
+		assert.False(t, c1.Revoked)
+		assert.True(t, c2.Expired)
+		assert.True(t, c2.Revoked)
+	}
+}
+
+func TestHandler_RevokeCertificate(t *testing.T) {
+	crt := newACMETestCertificate(t, 255, time.Now().Add(time.Hour))
+	tests := []struct {
+		name       string
+		serial     string
+		body       string
+		provName   string
+		statusCode int
+		err        string
+	}{
+		{"ok", "0xff", `{"reasonCode":1,"reason":"key compromise"}`, "provName", 200, ""},
+		{"ok/decimal", "255", `{}`, "provName", 200, ""},
+		{"fail/body", "255", `{`, "provName", 400, "error reading request body: error decoding json: unexpected EOF"},
+		{"fail/reason-code", "255", `{"reasonCode":7}`, "provName", 400, "reason code 7 is not valid"},
+		{"fail/serial", "foo", `{}`, "provName", 400, "serial number is not valid"},
+		{"fail/other-provisioner", "255", `{}`, "other", 404, "ACME certificate '255' not found"},
+	}
+	fn := mustACMEAuthority
+	t.Cleanup(func() {
+		mustACMEAuthority = fn
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var revoked bool
+			mustACMEAuthority = func(ctx context.Context) acme.CertificateAuthority {
+				return &mockACMECertificateAuthority{
+					MockRevoke: func(ctx context.Context, opts *authority.RevokeOptions) error {
+						assert.Equals(t, provisioner.RevokeMethod, provisioner.MethodFromContext(ctx))
+						assert.Equals(t, "255", opts.Serial)
+						assert.True(t, opts.ACME)
+						assert.Equals(t, crt, opts.Crt)
+						revoked = true
+						return nil
+					},
+				}
+			}
+			db := &acme.MockDB{
+				MockGetCertificateBySerial: func(ctx context.Context, serial string) (*acme.Certificate, error) {
+					assert.Equals(t, "255", serial)
+					return &acme.Certificate{ID: "certID", AccountID: "accID", Leaf: crt}, nil
+				},
+				MockGetAccount: func(ctx context.Context, id string) (*acme.Account, error) {
+					assert.Equals(t, "accID", id)
+					return &acme.Account{ID: id, Status: acme.StatusValid, ProvisionerName: tt.provName}, nil
+				},
+			}
+			w := httptest.NewRecorder()
+			NewACMEAdminResponder().RevokeCertificate(w, newACMEAdminRequest("POST", tt.body, db, map[string]string{"serial": tt.serial}))
+
+			var resp DeleteResponse
+			readACMEAdminResponse(t, w, tt.statusCode, tt.err, &resp)
+			assert.Equals(t, tt.statusCode == 200, revoked)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/activity"
+	"github.com/smallstep/certificates/authority/admin"
+)
+
+// activityKeepAlive is the maximum time between two messages in the activity
+// stream, if there are no events a comment is sent to keep the connection
+// open.
+var activityKeepAlive = 30 * time.Second
+
+// StreamActivity streams the signed, renewed and revoked certificates using
+// server-sent events. The stream starts with the recent events kept in
+// memory, or after the event in the "since" query parameter or the
+// Last-Event-ID header sent by reconnecting clients. The events can be
+// filtered with one or more "provisioner" and "type" query parameters.
+//
+// If some of the requested events are not available anymore, a "truncated"
+// event is sent before the rest.
+func StreamActivity(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := &activity.Filter{
+		Provisioners: query["provisioner"],
+		Types:        query["type"],
+	}
+
+	var since uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		query.Set("since", v)
+	}
+	if v := query.Get("since"); v != "" {
+		var err error
+		if since, err = strconv.ParseUint(v, 10, 64); err != nil {
+			render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error parsing since query param"))
+			return
+		}
+	}
+
+	ctx := r.Context()
+	auth := mustAuthority(ctx)
+	rc := http.NewResponseController(w)
+
+	// The first request does not wait, so errors can be rendered before
+	// starting the stream.
+	var wait time.Duration
+	for i := 0; ; i++ {
+		cctx, cancel := context.WithTimeout(ctx, wait)
+		page, err := auth.GetActivityEvents(cctx, since, filter)
+		cancel()
+		if err != nil {
+			if i == 0 {
+				render.Error(w, err)
+			}
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Extend the write timeout of the server for the next messages.
+		wait = activityKeepAlive
+		_ = rc.SetWriteDeadline(time.Now().Add(wait + 5*time.Second))
+
+		if i == 0 {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("X-Accel-Buffering", "no")
+			w.WriteHeader(http.StatusOK)
+		}
+		if err := writeActivityPage(w, page); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		since = page.Last
+	}
+}
+
+func writeActivityPage(w http.ResponseWriter, page *activity.Page) error {
+	if page.Truncated {
+		if _, err := fmt.Fprint(w, "event: truncated\ndata: {}\n\n"); err != nil {
+			return err
+		}
+	}
+	if len(page.Events) == 0 {
+		_, err := fmt.Fprint(w, ": keep-alive\n\n")
+		return err
+	}
+	for _, e := range page.Events {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
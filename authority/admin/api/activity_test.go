@@ -0,0 +1,91 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/activity"
+	"github.com/smallstep/certificates/authority/admin"
+)
+
+func TestStreamActivity(t *testing.T) {
+	closed := admin.NewError(admin.ErrorServerInternalType, "activity events are not available")
+	tests := []struct {
+		name       string
+		target     string
+		lastID     string
+		pages      []*activity.Page
+		wantSince  []uint64
+		wantFilter *activity.Filter
+		statusCode int
+		message    string
+		want       string
+	}{
+		{"fail/since", "/admin/activity?since=foo", "", nil, nil, nil, 400, "error parsing since query param: strconv.ParseUint: parsing \"foo\": invalid syntax", ""},
+		{"fail/disabled", "/admin/activity", "", nil, []uint64{0}, &activity.Filter{}, 501, "activity events are not enabled", ""},
+		{"ok", "/admin/activity?provisioner=jwk&provisioner=acme&type=x509.sign", "", []*activity.Page{
+			{Events: []*activity.Event{
+				{ID: 10, Type: "x509.sign", ProvisionerName: "jwk"},
+				{ID: 12, Type: "x509.sign", ProvisionerName: "acme"},
+			}, Last: 13},
+			{Events: []*activity.Event{}, Last: 13},
+		}, []uint64{0, 13, 13}, &activity.Filter{Provisioners: []string{"jwk", "acme"}, Types: []string{"x509.sign"}}, 200, "",
+			"id: 10\nevent: x509.sign\ndata: {\"id\":10,\"type\":\"x509.sign\",\"time\":\"0001-01-01T00:00:00Z\",\"provisionerName\":\"jwk\",\"data\":null}\n\n" +
+				"id: 12\nevent: x509.sign\ndata: {\"id\":12,\"type\":\"x509.sign\",\"time\":\"0001-01-01T00:00:00Z\",\"provisionerName\":\"acme\",\"data\":null}\n\n" +
+				": keep-alive\n\n"},
+		{"ok/since", "/admin/activity?since=5", "", []*activity.Page{
+			{Events: []*activity.Event{}, Last: 5},
+		}, []uint64{5, 5}, &activity.Filter{}, 200, "", ": keep-alive\n\n"},
+		{"ok/last-event-id", "/admin/activity?since=5", "7", []*activity.Page{
+			{Events: []*activity.Event{{ID: 9, Type: "revoke"}}, Last: 9, Truncated: true},
+		}, []uint64{7, 9}, &activity.Filter{}, 200, "",
+			"event: truncated\ndata: {}\n\nid: 9\nevent: revoke\ndata: {\"id\":9,\"type\":\"revoke\",\"time\":\"0001-01-01T00:00:00Z\",\"data\":null}\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var since []uint64
+			mockMustAuthority(t, &mockAdminAuthority{
+				MockGetActivityEvents: func(ctx context.Context, s uint64, f *activity.Filter) (*activity.Page, error) {
+					assert.Equals(t, tt.wantFilter, f)
+					since = append(since, s)
+					if tt.pages == nil {
+						return nil, admin.NewError(admin.ErrorNotImplementedType, "activity events are not enabled")
+					}
+					if len(since) > len(tt.pages) {
+						return nil, closed
+					}
+					return tt.pages[len(since)-1], nil
+				},
+			})
+			req := httptest.NewRequest("GET", tt.target, http.NoBody)
+			if tt.lastID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastID)
+			}
+			w := httptest.NewRecorder()
+			StreamActivity(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tt.statusCode, res.StatusCode)
+			assert.Equals(t, tt.wantSince, since)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tt.message, adminErr.Message)
+				return
+			}
+			assert.Equals(t, "text/event-stream", res.Header.Get("Content-Type"))
+			assert.Equals(t, tt.want, string(body))
+		})
+	}
+}
@@ -2,17 +2,32 @@ package api
 
 import (
 	"context"
+	"crypto"
+	"crypto/x509"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi"
 
 	"go.step.sm/linkedca"
 
-	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/api/pagination"
 	"github.com/smallstep/certificates/api/read"
 	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/activity"
 	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/administrator"
+	"github.com/smallstep/certificates/authority/apitoken"
+	"github.com/smallstep/certificates/authority/audit"
+	"github.com/smallstep/certificates/authority/decommission"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/report"
+	"github.com/smallstep/certificates/authority/settings"
+	"github.com/smallstep/certificates/authority/subca"
+	"github.com/smallstep/certificates/authority/trustbundle"
+	"github.com/smallstep/certificates/db"
 )
 
 type adminAuthority interface {
@@ -33,6 +48,42 @@ type adminAuthority interface {
 	CreateAuthorityPolicy(ctx context.Context, admin *linkedca.Admin, policy *linkedca.Policy) (*linkedca.Policy, error)
 	UpdateAuthorityPolicy(ctx context.Context, admin *linkedca.Admin, policy *linkedca.Policy) (*linkedca.Policy, error)
 	RemoveAuthorityPolicy(ctx context.Context) error
+	ExportAuditLog(from uint64) (*audit.Export, error)
+	GetAuditLogPublicKey() (crypto.PublicKey, error)
+	VerifyAuditLog(e *audit.Export) error
+	RequestSubCA(ctx context.Context, adm *linkedca.Admin, r *subca.Request) (*subca.Request, error)
+	GetSubCARequest(id string) (*subca.Request, error)
+	GetSubCARequests() ([]*subca.Request, error)
+	ApproveSubCA(ctx context.Context, adm *linkedca.Admin, id string) (*subca.Request, error)
+	RejectSubCA(ctx context.Context, adm *linkedca.Admin, id, reason string) (*subca.Request, error)
+	SelfTest(ctx context.Context, name string) (*authority.SelfTestReport, error)
+	GetSettings() (*settings.Version, error)
+	GetSettingsVersion(version int) (*settings.Version, error)
+	GetSettingsVersions() ([]*settings.Version, error)
+	UpdateSettings(ctx context.Context, adm *linkedca.Admin, s *settings.Settings, comment string) (*settings.Version, error)
+	RollbackSettings(ctx context.Context, adm *linkedca.Admin, version int, comment string) (*settings.Version, error)
+	GetExpiringCertificates(within time.Duration, includeSuperseded bool) ([]*report.Certificate, error)
+	ListCertificates() ([]*report.Certificate, error)
+	GetSignResults(since time.Time) ([]*report.SignResult, error)
+	GetCertificateLifecycle(serialNumber string) (*report.Lifecycle, error)
+	ValidateCertificateChain(chain []*x509.Certificate) (*authority.CertificateValidation, error)
+	IntrospectToken(ctx context.Context, token string) (*authority.TokenIntrospection, error)
+	GetActivityEvents(ctx context.Context, since uint64, f *activity.Filter) (*activity.Page, error)
+	GetDecommissionStatus() (*decommission.Status, error)
+	Decommission(ctx context.Context, adm *linkedca.Admin, opts authority.DecommissionOptions) (*decommission.Status, error)
+	WriteDecommissionArchive(w io.Writer) error
+	AuthorizeAPIToken(token string, scope apitoken.Scope) (*linkedca.Admin, error)
+	GetAPITokens() ([]*apitoken.Token, error)
+	CreateAPIToken(ctx context.Context, adm *linkedca.Admin, opts authority.APITokenOptions) (*apitoken.Token, string, error)
+	RotateAPIToken(ctx context.Context, adm *linkedca.Admin, id string, gracePeriod time.Duration) (*apitoken.Token, string, error)
+	RevokeAPIToken(ctx context.Context, adm *linkedca.Admin, id string) (*apitoken.Token, error)
+	GetProvisionerCredentials(ctx context.Context, adm *linkedca.Admin, name string) ([]*provisioner.Credential, error)
+	CreateProvisionerCredential(ctx context.Context, adm *linkedca.Admin, name string, c *provisioner.Credential) (*provisioner.Credential, error)
+	UpdateProvisionerCredential(ctx context.Context, adm *linkedca.Admin, name, id string, notBefore, notAfter time.Time) (*provisioner.Credential, error)
+	DeleteProvisionerCredential(ctx context.Context, adm *linkedca.Admin, name, id string) error
+	GetDeniedIdentities() ([]*db.DeniedIdentity, error)
+	ClearDeniedIdentities(ctx context.Context, adm *linkedca.Admin, names []string) error
+	GetTrustBundle(format string) (*trustbundle.Bundle, error)
 }
 
 // CreateAdminRequest represents the body for a CreateAdmin request.
@@ -99,24 +150,70 @@ func GetAdmin(w http.ResponseWriter, r *http.Request) {
 
 // GetAdmins returns a segment of admins associated with the authority.
 func GetAdmins(w http.ResponseWriter, r *http.Request) {
-	cursor, limit, err := api.ParseCursor(r)
+	opts, err := pagination.Parse(r, adminListFields, adminListFields)
 	if err != nil {
 		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err,
 			"error parsing cursor and limit from query params"))
 		return
 	}
 
-	admins, nextCursor, err := mustAuthority(r.Context()).GetAdmins(cursor, limit)
+	admins, nextCursor, err := listAdmins(mustAuthority(r.Context()), opts)
 	if err != nil {
-		render.Error(w, admin.WrapErrorISE(err, "error retrieving paginated admins"))
+		render.Error(w, err)
 		return
 	}
+	pagination.SetLinkHeader(w, r, nextCursor)
 	render.JSON(w, &GetAdminsResponse{
 		Admins:     admins,
 		NextCursor: nextCursor,
 	})
 }
 
+// adminListFields are the fields that can be used to sort and filter the list
+// of admins.
+var adminListFields = []string{"subject", "provisionerId", "type"}
+
+// listAdmins returns a page of the admins. All the admins are loaded if the
+// list is sorted or filtered, in that case the admin id is the cursor.
+func listAdmins(auth adminAuthority, opts *pagination.Options) ([]*linkedca.Admin, string, error) {
+	if opts.IsDefault() {
+		admins, next, err := auth.GetAdmins(opts.Cursor, opts.Limit)
+		if err != nil {
+			return nil, "", admin.WrapErrorISE(err, "error retrieving paginated admins")
+		}
+		return admins, next, nil
+	}
+
+	var all []*linkedca.Admin
+	for cursor := ""; ; {
+		admins, next, err := auth.GetAdmins(cursor, administrator.DefaultAdminMax)
+		if err != nil {
+			return nil, "", admin.WrapErrorISE(err, "error retrieving paginated admins")
+		}
+		all = append(all, admins...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	all = pagination.Filter(all, func(adm *linkedca.Admin) bool {
+		return opts.Match("subject", adm.Subject) &&
+			opts.Match("provisionerId", adm.ProvisionerId) &&
+			opts.Match("type", adm.Type.String())
+	})
+	pagination.Sort(all, opts, map[string]func(a, b *linkedca.Admin) bool{
+		"subject":       func(a, b *linkedca.Admin) bool { return a.Subject < b.Subject },
+		"provisionerId": func(a, b *linkedca.Admin) bool { return a.ProvisionerId < b.ProvisionerId },
+		"type":          func(a, b *linkedca.Admin) bool { return a.Type.String() < b.Type.String() },
+	})
+	admins, next, err := pagination.Paginate(all, opts, (*linkedca.Admin).GetId)
+	if err != nil {
+		return nil, "", admin.WrapError(admin.ErrorBadRequestType, err, "error paginating admins")
+	}
+	return admins, next, nil
+}
+
 // CreateAdmin creates a new admin.
 func CreateAdmin(w http.ResponseWriter, r *http.Request) {
 	var body CreateAdminRequest
@@ -3,11 +3,15 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"testing"
 	"time"
 
@@ -19,8 +23,19 @@ import (
 	"go.step.sm/linkedca"
 
 	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/api/pagination"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/activity"
 	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/apitoken"
+	"github.com/smallstep/certificates/authority/audit"
+	"github.com/smallstep/certificates/authority/decommission"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/report"
+	"github.com/smallstep/certificates/authority/settings"
+	"github.com/smallstep/certificates/authority/subca"
+	"github.com/smallstep/certificates/authority/trustbundle"
+	"github.com/smallstep/certificates/db"
 )
 
 type mockAdminAuthority struct {
@@ -44,6 +59,49 @@ type mockAdminAuthority struct {
 	MockCreateAuthorityPolicy func(ctx context.Context, adm *linkedca.Admin, policy *linkedca.Policy) (*linkedca.Policy, error)
 	MockUpdateAuthorityPolicy func(ctx context.Context, adm *linkedca.Admin, policy *linkedca.Policy) (*linkedca.Policy, error)
 	MockRemoveAuthorityPolicy func(ctx context.Context) error
+
+	MockExportAuditLog       func(from uint64) (*audit.Export, error)
+	MockGetAuditLogPublicKey func() (crypto.PublicKey, error)
+	MockVerifyAuditLog       func(e *audit.Export) error
+
+	MockRequestSubCA     func(ctx context.Context, adm *linkedca.Admin, r *subca.Request) (*subca.Request, error)
+	MockGetSubCARequest  func(id string) (*subca.Request, error)
+	MockGetSubCARequests func() ([]*subca.Request, error)
+	MockApproveSubCA     func(ctx context.Context, adm *linkedca.Admin, id string) (*subca.Request, error)
+	MockRejectSubCA      func(ctx context.Context, adm *linkedca.Admin, id, reason string) (*subca.Request, error)
+
+	MockSelfTest func(ctx context.Context, name string) (*authority.SelfTestReport, error)
+
+	MockGetSettings         func() (*settings.Version, error)
+	MockGetSettingsVersion  func(version int) (*settings.Version, error)
+	MockGetSettingsVersions func() ([]*settings.Version, error)
+	MockUpdateSettings      func(ctx context.Context, adm *linkedca.Admin, s *settings.Settings, comment string) (*settings.Version, error)
+	MockRollbackSettings    func(ctx context.Context, adm *linkedca.Admin, version int, comment string) (*settings.Version, error)
+
+	MockGetExpiringCertificates  func(within time.Duration, includeSuperseded bool) ([]*report.Certificate, error)
+	MockListCertificates         func() ([]*report.Certificate, error)
+	MockGetSignResults           func(since time.Time) ([]*report.SignResult, error)
+	MockGetCertificateLifecycle  func(serialNumber string) (*report.Lifecycle, error)
+	MockValidateCertificateChain func(chain []*x509.Certificate) (*authority.CertificateValidation, error)
+	MockIntrospectToken          func(ctx context.Context, token string) (*authority.TokenIntrospection, error)
+	MockGetActivityEvents        func(ctx context.Context, since uint64, f *activity.Filter) (*activity.Page, error)
+	MockGetDecommissionStatus    func() (*decommission.Status, error)
+	MockDecommission             func(ctx context.Context, adm *linkedca.Admin, opts authority.DecommissionOptions) (*decommission.Status, error)
+	MockWriteDecommissionArchive func(w io.Writer) error
+
+	MockAuthorizeAPIToken func(token string, scope apitoken.Scope) (*linkedca.Admin, error)
+	MockGetAPITokens      func() ([]*apitoken.Token, error)
+	MockCreateAPIToken    func(ctx context.Context, adm *linkedca.Admin, opts authority.APITokenOptions) (*apitoken.Token, string, error)
+	MockRotateAPIToken    func(ctx context.Context, adm *linkedca.Admin, id string, gracePeriod time.Duration) (*apitoken.Token, string, error)
+	MockRevokeAPIToken    func(ctx context.Context, adm *linkedca.Admin, id string) (*apitoken.Token, error)
+
+	MockGetProvisionerCredentials   func(ctx context.Context, adm *linkedca.Admin, name string) ([]*provisioner.Credential, error)
+	MockCreateProvisionerCredential func(ctx context.Context, adm *linkedca.Admin, name string, c *provisioner.Credential) (*provisioner.Credential, error)
+	MockUpdateProvisionerCredential func(ctx context.Context, adm *linkedca.Admin, name, id string, notBefore, notAfter time.Time) (*provisioner.Credential, error)
+	MockDeleteProvisionerCredential func(ctx context.Context, adm *linkedca.Admin, name, id string) error
+	MockGetDeniedIdentities         func() ([]*db.DeniedIdentity, error)
+	MockClearDeniedIdentities       func(ctx context.Context, adm *linkedca.Admin, names []string) error
+	MockGetTrustBundle              func(format string) (*trustbundle.Bundle, error)
 }
 
 func (m *mockAdminAuthority) IsAdminAPIEnabled() bool {
@@ -165,6 +223,258 @@ func (m *mockAdminAuthority) RemoveAuthorityPolicy(ctx context.Context) error {
 	return m.MockErr
 }
 
+func (m *mockAdminAuthority) ExportAuditLog(from uint64) (*audit.Export, error) {
+	if m.MockExportAuditLog != nil {
+		return m.MockExportAuditLog(from)
+	}
+	return m.MockRet1.(*audit.Export), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetAuditLogPublicKey() (crypto.PublicKey, error) {
+	if m.MockGetAuditLogPublicKey != nil {
+		return m.MockGetAuditLogPublicKey()
+	}
+	return m.MockRet1, m.MockErr
+}
+
+func (m *mockAdminAuthority) VerifyAuditLog(e *audit.Export) error {
+	if m.MockVerifyAuditLog != nil {
+		return m.MockVerifyAuditLog(e)
+	}
+	return m.MockErr
+}
+
+func (m *mockAdminAuthority) RequestSubCA(ctx context.Context, adm *linkedca.Admin, r *subca.Request) (*subca.Request, error) {
+	if m.MockRequestSubCA != nil {
+		return m.MockRequestSubCA(ctx, adm, r)
+	}
+	return m.MockRet1.(*subca.Request), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetSubCARequest(id string) (*subca.Request, error) {
+	if m.MockGetSubCARequest != nil {
+		return m.MockGetSubCARequest(id)
+	}
+	return m.MockRet1.(*subca.Request), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetSubCARequests() ([]*subca.Request, error) {
+	if m.MockGetSubCARequests != nil {
+		return m.MockGetSubCARequests()
+	}
+	return m.MockRet1.([]*subca.Request), m.MockErr
+}
+
+func (m *mockAdminAuthority) ApproveSubCA(ctx context.Context, adm *linkedca.Admin, id string) (*subca.Request, error) {
+	if m.MockApproveSubCA != nil {
+		return m.MockApproveSubCA(ctx, adm, id)
+	}
+	return m.MockRet1.(*subca.Request), m.MockErr
+}
+
+func (m *mockAdminAuthority) RejectSubCA(ctx context.Context, adm *linkedca.Admin, id, reason string) (*subca.Request, error) {
+	if m.MockRejectSubCA != nil {
+		return m.MockRejectSubCA(ctx, adm, id, reason)
+	}
+	return m.MockRet1.(*subca.Request), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetExpiringCertificates(within time.Duration, includeSuperseded bool) ([]*report.Certificate, error) {
+	if m.MockGetExpiringCertificates != nil {
+		return m.MockGetExpiringCertificates(within, includeSuperseded)
+	}
+	return m.MockRet1.([]*report.Certificate), m.MockErr
+}
+
+func (m *mockAdminAuthority) SelfTest(ctx context.Context, name string) (*authority.SelfTestReport, error) {
+	if m.MockSelfTest != nil {
+		return m.MockSelfTest(ctx, name)
+	}
+	return m.MockRet1.(*authority.SelfTestReport), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetSettings() (*settings.Version, error) {
+	if m.MockGetSettings != nil {
+		return m.MockGetSettings()
+	}
+	return m.MockRet1.(*settings.Version), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetSettingsVersion(version int) (*settings.Version, error) {
+	if m.MockGetSettingsVersion != nil {
+		return m.MockGetSettingsVersion(version)
+	}
+	return m.MockRet1.(*settings.Version), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetSettingsVersions() ([]*settings.Version, error) {
+	if m.MockGetSettingsVersions != nil {
+		return m.MockGetSettingsVersions()
+	}
+	return m.MockRet1.([]*settings.Version), m.MockErr
+}
+
+func (m *mockAdminAuthority) UpdateSettings(ctx context.Context, adm *linkedca.Admin, s *settings.Settings, comment string) (*settings.Version, error) {
+	if m.MockUpdateSettings != nil {
+		return m.MockUpdateSettings(ctx, adm, s, comment)
+	}
+	return m.MockRet1.(*settings.Version), m.MockErr
+}
+
+func (m *mockAdminAuthority) RollbackSettings(ctx context.Context, adm *linkedca.Admin, version int, comment string) (*settings.Version, error) {
+	if m.MockRollbackSettings != nil {
+		return m.MockRollbackSettings(ctx, adm, version, comment)
+	}
+	return m.MockRet1.(*settings.Version), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetActivityEvents(ctx context.Context, since uint64, f *activity.Filter) (*activity.Page, error) {
+	if m.MockGetActivityEvents != nil {
+		return m.MockGetActivityEvents(ctx, since, f)
+	}
+	return m.MockRet1.(*activity.Page), m.MockErr
+}
+
+func (m *mockAdminAuthority) IntrospectToken(ctx context.Context, token string) (*authority.TokenIntrospection, error) {
+	if m.MockIntrospectToken != nil {
+		return m.MockIntrospectToken(ctx, token)
+	}
+	return m.MockRet1.(*authority.TokenIntrospection), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetCertificateLifecycle(serialNumber string) (*report.Lifecycle, error) {
+	if m.MockGetCertificateLifecycle != nil {
+		return m.MockGetCertificateLifecycle(serialNumber)
+	}
+	return m.MockRet1.(*report.Lifecycle), m.MockErr
+}
+
+func (m *mockAdminAuthority) ValidateCertificateChain(chain []*x509.Certificate) (*authority.CertificateValidation, error) {
+	if m.MockValidateCertificateChain != nil {
+		return m.MockValidateCertificateChain(chain)
+	}
+	return m.MockRet1.(*authority.CertificateValidation), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetDecommissionStatus() (*decommission.Status, error) {
+	if m.MockGetDecommissionStatus != nil {
+		return m.MockGetDecommissionStatus()
+	}
+	return m.MockRet1.(*decommission.Status), m.MockErr
+}
+
+func (m *mockAdminAuthority) Decommission(ctx context.Context, adm *linkedca.Admin, opts authority.DecommissionOptions) (*decommission.Status, error) {
+	if m.MockDecommission != nil {
+		return m.MockDecommission(ctx, adm, opts)
+	}
+	return m.MockRet1.(*decommission.Status), m.MockErr
+}
+
+func (m *mockAdminAuthority) WriteDecommissionArchive(w io.Writer) error {
+	if m.MockWriteDecommissionArchive != nil {
+		return m.MockWriteDecommissionArchive(w)
+	}
+	return m.MockErr
+}
+
+func (m *mockAdminAuthority) AuthorizeAPIToken(token string, scope apitoken.Scope) (*linkedca.Admin, error) {
+	if m.MockAuthorizeAPIToken != nil {
+		return m.MockAuthorizeAPIToken(token, scope)
+	}
+	return m.MockRet1.(*linkedca.Admin), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetAPITokens() ([]*apitoken.Token, error) {
+	if m.MockGetAPITokens != nil {
+		return m.MockGetAPITokens()
+	}
+	return m.MockRet1.([]*apitoken.Token), m.MockErr
+}
+
+func (m *mockAdminAuthority) CreateAPIToken(ctx context.Context, adm *linkedca.Admin, opts authority.APITokenOptions) (*apitoken.Token, string, error) {
+	if m.MockCreateAPIToken != nil {
+		return m.MockCreateAPIToken(ctx, adm, opts)
+	}
+	return m.MockRet1.(*apitoken.Token), "", m.MockErr
+}
+
+func (m *mockAdminAuthority) RotateAPIToken(ctx context.Context, adm *linkedca.Admin, id string, gracePeriod time.Duration) (*apitoken.Token, string, error) {
+	if m.MockRotateAPIToken != nil {
+		return m.MockRotateAPIToken(ctx, adm, id, gracePeriod)
+	}
+	return m.MockRet1.(*apitoken.Token), "", m.MockErr
+}
+
+func (m *mockAdminAuthority) RevokeAPIToken(ctx context.Context, adm *linkedca.Admin, id string) (*apitoken.Token, error) {
+	if m.MockRevokeAPIToken != nil {
+		return m.MockRevokeAPIToken(ctx, adm, id)
+	}
+	return m.MockRet1.(*apitoken.Token), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetProvisionerCredentials(ctx context.Context, adm *linkedca.Admin, name string) ([]*provisioner.Credential, error) {
+	if m.MockGetProvisionerCredentials != nil {
+		return m.MockGetProvisionerCredentials(ctx, adm, name)
+	}
+	return m.MockRet1.([]*provisioner.Credential), m.MockErr
+}
+
+func (m *mockAdminAuthority) CreateProvisionerCredential(ctx context.Context, adm *linkedca.Admin, name string, c *provisioner.Credential) (*provisioner.Credential, error) {
+	if m.MockCreateProvisionerCredential != nil {
+		return m.MockCreateProvisionerCredential(ctx, adm, name, c)
+	}
+	return m.MockRet1.(*provisioner.Credential), m.MockErr
+}
+
+func (m *mockAdminAuthority) UpdateProvisionerCredential(ctx context.Context, adm *linkedca.Admin, name, id string, notBefore, notAfter time.Time) (*provisioner.Credential, error) {
+	if m.MockUpdateProvisionerCredential != nil {
+		return m.MockUpdateProvisionerCredential(ctx, adm, name, id, notBefore, notAfter)
+	}
+	return m.MockRet1.(*provisioner.Credential), m.MockErr
+}
+
+func (m *mockAdminAuthority) DeleteProvisionerCredential(ctx context.Context, adm *linkedca.Admin, name, id string) error {
+	if m.MockDeleteProvisionerCredential != nil {
+		return m.MockDeleteProvisionerCredential(ctx, adm, name, id)
+	}
+	return m.MockErr
+}
+
+func (m *mockAdminAuthority) GetDeniedIdentities() ([]*db.DeniedIdentity, error) {
+	if m.MockGetDeniedIdentities != nil {
+		return m.MockGetDeniedIdentities()
+	}
+	return m.MockRet1.([]*db.DeniedIdentity), m.MockErr
+}
+
+func (m *mockAdminAuthority) ClearDeniedIdentities(ctx context.Context, adm *linkedca.Admin, names []string) error {
+	if m.MockClearDeniedIdentities != nil {
+		return m.MockClearDeniedIdentities(ctx, adm, names)
+	}
+	return m.MockErr
+}
+
+func (m *mockAdminAuthority) GetTrustBundle(format string) (*trustbundle.Bundle, error) {
+	if m.MockGetTrustBundle != nil {
+		return m.MockGetTrustBundle(format)
+	}
+	return m.MockRet1.(*trustbundle.Bundle), m.MockErr
+}
+
+func (m *mockAdminAuthority) ListCertificates() ([]*report.Certificate, error) {
+	if m.MockListCertificates != nil {
+		return m.MockListCertificates()
+	}
+	return m.MockRet1.([]*report.Certificate), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetSignResults(since time.Time) ([]*report.SignResult, error) {
+	if m.MockGetSignResults != nil {
+		return m.MockGetSignResults(since)
+	}
+	return m.MockRet1.([]*report.SignResult), m.MockErr
+}
+
 func TestCreateAdminRequest_Validate(t *testing.T) {
 	type fields struct {
 		Subject     string
@@ -944,3 +1254,55 @@ func TestHandler_UpdateAdmin(t *testing.T) {
 		})
 	}
 }
+
+func Test_listAdmins(t *testing.T) {
+	admins := []*linkedca.Admin{
+		{Id: "1", Subject: "zoe", ProvisionerId: "p1", Type: linkedca.Admin_SUPER_ADMIN},
+		{Id: "2", Subject: "ann", ProvisionerId: "p2", Type: linkedca.Admin_ADMIN},
+		{Id: "3", Subject: "max", ProvisionerId: "p1", Type: linkedca.Admin_ADMIN},
+	}
+	auth := &mockAdminAuthority{
+		MockGetAdmins: func(cursor string, limit int) ([]*linkedca.Admin, string, error) {
+			// Returns one admin per page to test that all pages are loaded.
+			i := 0
+			if cursor != "" {
+				i, _ = strconv.Atoi(cursor)
+				i--
+			}
+			if i+1 < len(admins) {
+				return admins[i : i+1], admins[i+1].Id, nil
+			}
+			return admins[i:], "", nil
+		},
+	}
+	ids := func(list []*linkedca.Admin) []string {
+		ret := []string{}
+		for _, adm := range list {
+			ret = append(ret, adm.Id)
+		}
+		return ret
+	}
+
+	// Default pagination is delegated to the authority.
+	list, next, err := listAdmins(auth, &pagination.Options{})
+	assert.FatalError(t, err)
+	assert.Equals(t, []string{"1"}, ids(list))
+	assert.Equals(t, "2", next)
+
+	list, next, err = listAdmins(auth, &pagination.Options{Sort: "subject", Limit: 2})
+	assert.FatalError(t, err)
+	assert.Equals(t, []string{"2", "3"}, ids(list))
+	assert.Equals(t, "1", next)
+
+	list, next, err = listAdmins(auth, &pagination.Options{Sort: "subject", Cursor: "1"})
+	assert.FatalError(t, err)
+	assert.Equals(t, []string{"1"}, ids(list))
+	assert.Equals(t, "", next)
+
+	list, _, err = listAdmins(auth, &pagination.Options{Filters: url.Values{"provisionerId": {"p1"}, "type": {"ADMIN"}}})
+	assert.FatalError(t, err)
+	assert.Equals(t, []string{"3"}, ids(list))
+
+	_, _, err = listAdmins(auth, &pagination.Options{Sort: "subject", Cursor: "4"})
+	assert.Equals(t, "error paginating admins: cursor '4' is not valid", err.Error())
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/apitoken"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// CreateAPITokenRequest is the type for POST /admin/api-tokens requests.
+type CreateAPITokenRequest struct {
+	Name      string           `json:"name"`
+	Scopes    []apitoken.Scope `json:"scopes"`
+	ExpiresAt time.Time        `json:"expiresAt,omitempty"`
+}
+
+// Validate validates a new API token request body.
+func (r *CreateAPITokenRequest) Validate() error {
+	switch {
+	case r.Name == "":
+		return admin.NewError(admin.ErrorBadRequestType, "name cannot be empty")
+	case len(r.Scopes) == 0:
+		return admin.NewError(admin.ErrorBadRequestType, "scopes cannot be empty")
+	}
+	for _, s := range r.Scopes {
+		if err := s.Validate(); err != nil {
+			return admin.WrapError(admin.ErrorBadRequestType, err, "invalid scopes")
+		}
+	}
+	return nil
+}
+
+// RotateAPITokenRequest is the type for POST /admin/api-tokens/{id}/rotate
+// requests. The previous secret is valid during the grace period.
+type RotateAPITokenRequest struct {
+	GracePeriod provisioner.Duration `json:"gracePeriod"`
+}
+
+// APITokenResponse is the type for the API token responses. The secret is
+// only returned when a token is created or rotated.
+type APITokenResponse struct {
+	*apitoken.Token
+	Secret string `json:"secret,omitempty"`
+}
+
+// GetAPITokensResponse is the type for GET /admin/api-tokens responses.
+type GetAPITokensResponse struct {
+	Tokens []*apitoken.Token `json:"tokens"`
+}
+
+// GetAPITokens returns all the API tokens, without their secrets.
+func GetAPITokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := mustAuthority(r.Context()).GetAPITokens()
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, &GetAPITokensResponse{Tokens: tokens})
+}
+
+// CreateAPIToken creates a new API token. The secret is only included in
+// this response.
+func CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var body CreateAPITokenRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	adm := linkedca.MustAdminFromContext(ctx)
+	t, secret, err := mustAuthority(ctx).CreateAPIToken(ctx, adm, authority.APITokenOptions{
+		Name:      body.Name,
+		Scopes:    body.Scopes,
+		ExpiresAt: body.ExpiresAt,
+	})
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSONStatus(w, &APITokenResponse{Token: t, Secret: secret}, http.StatusCreated)
+}
+
+// RotateAPIToken replaces the secret of the API token with the id in the
+// path.
+func RotateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var body RotateAPITokenRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+
+	ctx := r.Context()
+	adm := linkedca.MustAdminFromContext(ctx)
+	t, secret, err := mustAuthority(ctx).RotateAPIToken(ctx, adm, chi.URLParam(r, "id"), body.GracePeriod.Duration)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, &APITokenResponse{Token: t, Secret: secret})
+}
+
+// RevokeAPIToken revokes the API token with the id in the path.
+func RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adm := linkedca.MustAdminFromContext(ctx)
+	t, err := mustAuthority(ctx).RevokeAPIToken(ctx, adm, chi.URLParam(r, "id"))
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, &APITokenResponse{Token: t})
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/apitoken"
+)
+
+func TestCreateAPIToken(t *testing.T) {
+	adm := &linkedca.Admin{Id: "admin-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type test struct {
+		body       string
+		auth       adminAuthority
+		statusCode int
+		message    string
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/body": func(t *testing.T) test {
+			return test{
+				body:       "{",
+				statusCode: 400,
+				message:    "error reading request body: error decoding json: unexpected EOF",
+			}
+		},
+		"fail/name": func(t *testing.T) test {
+			return test{
+				body:       `{"scopes":["inventory:read"]}`,
+				statusCode: 400,
+				message:    "name cannot be empty",
+			}
+		},
+		"fail/scopes": func(t *testing.T) test {
+			return test{
+				body:       `{"name":"ci"}`,
+				statusCode: 400,
+				message:    "scopes cannot be empty",
+			}
+		},
+		"fail/scope": func(t *testing.T) test {
+			return test{
+				body:       `{"name":"ci","scopes":["admin"]}`,
+				statusCode: 400,
+				message:    "invalid scopes: scope \"admin\" is not supported",
+			}
+		},
+		"fail/create": func(t *testing.T) test {
+			return test{
+				body: `{"name":"ci","scopes":["inventory:read"]}`,
+				auth: &mockAdminAuthority{
+					MockCreateAPIToken: func(ctx context.Context, adm *linkedca.Admin, opts authority.APITokenOptions) (*apitoken.Token, string, error) {
+						return nil, "", admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to create API tokens")
+					},
+				},
+				statusCode: 401,
+				message:    "must have super admin access to create API tokens",
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				body: `{"name":"ci","scopes":["inventory:read","eab:write"],"expiresAt":"2030-01-01T00:00:00Z"}`,
+				auth: &mockAdminAuthority{
+					MockCreateAPIToken: func(ctx context.Context, a *linkedca.Admin, opts authority.APITokenOptions) (*apitoken.Token, string, error) {
+						assert.Equals(t, adm, a)
+						assert.Equals(t, authority.APITokenOptions{
+							Name:      "ci",
+							Scopes:    []apitoken.Scope{apitoken.ScopeInventoryRead, apitoken.ScopeEABWrite},
+							ExpiresAt: expiresAt,
+						}, opts)
+						return &apitoken.Token{ID: "id", Name: opts.Name, Scopes: opts.Scopes}, "stk_id.secret", nil
+					},
+				},
+				statusCode: 201,
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			mockMustAuthority(t, tc.auth)
+			ctx := linkedca.NewContextWithAdmin(context.Background(), adm)
+			req := httptest.NewRequest("POST", "/foo", strings.NewReader(tc.body)).WithContext(ctx)
+			w := httptest.NewRecorder()
+			CreateAPIToken(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tc.message, adminErr.Message)
+				return
+			}
+
+			var resp APITokenResponse
+			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &resp))
+			assert.Equals(t, "id", resp.ID)
+			assert.Equals(t, "ci", resp.Name)
+			assert.Equals(t, "stk_id.secret", resp.Secret)
+		})
+	}
+}
+
+func TestGetAPITokens(t *testing.T) {
+	mockMustAuthority(t, &mockAdminAuthority{
+		MockRet1: []*apitoken.Token{{ID: "id", Name: "ci", Scopes: []apitoken.Scope{apitoken.ScopeAuditRead}}},
+	})
+	w := httptest.NewRecorder()
+	GetAPITokens(w, httptest.NewRequest("GET", "/foo", http.NoBody))
+	res := w.Result()
+	assert.Equals(t, 200, res.StatusCode)
+
+	var resp GetAPITokensResponse
+	assert.FatalError(t, json.NewDecoder(res.Body).Decode(&resp))
+	assert.Len(t, 1, resp.Tokens)
+	assert.Equals(t, "id", resp.Tokens[0].ID)
+}
+
+func TestRotateAPIToken(t *testing.T) {
+	adm := &linkedca.Admin{Id: "admin-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+	mockMustAuthority(t, &mockAdminAuthority{
+		MockRotateAPIToken: func(ctx context.Context, a *linkedca.Admin, id string, gracePeriod time.Duration) (*apitoken.Token, string, error) {
+			assert.Equals(t, adm, a)
+			assert.Equals(t, "id", id)
+			assert.Equals(t, time.Hour, gracePeriod)
+			return &apitoken.Token{ID: id}, "stk_id.new", nil
+		},
+	})
+
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add("id", "id")
+	ctx := context.WithValue(linkedca.NewContextWithAdmin(context.Background(), adm), chi.RouteCtxKey, chiCtx)
+	req := httptest.NewRequest("POST", "/foo", strings.NewReader(`{"gracePeriod":"1h"}`)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	RotateAPIToken(w, req)
+	res := w.Result()
+	assert.Equals(t, 200, res.StatusCode)
+
+	var resp APITokenResponse
+	assert.FatalError(t, json.NewDecoder(res.Body).Decode(&resp))
+	assert.Equals(t, "stk_id.new", resp.Secret)
+}
+
+func TestRevokeAPIToken(t *testing.T) {
+	adm := &linkedca.Admin{Id: "admin-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+	mockMustAuthority(t, &mockAdminAuthority{
+		MockRevokeAPIToken: func(ctx context.Context, a *linkedca.Admin, id string) (*apitoken.Token, error) {
+			return nil, admin.NewError(admin.ErrorConflictType, "API token %s has already been revoked", id)
+		},
+	})
+
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add("id", "id")
+	ctx := context.WithValue(linkedca.NewContextWithAdmin(context.Background(), adm), chi.RouteCtxKey, chiCtx)
+	req := httptest.NewRequest("DELETE", "/foo", http.NoBody).WithContext(ctx)
+	w := httptest.NewRecorder()
+	RevokeAPIToken(w, req)
+	res := w.Result()
+	assert.Equals(t, 409, res.StatusCode)
+
+	adminErr := admin.Error{}
+	assert.FatalError(t, json.NewDecoder(res.Body).Decode(&adminErr))
+	assert.Equals(t, "API token id has already been revoked", adminErr.Message)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/pem"
+	"net/http"
+	"strconv"
+
+	"go.step.sm/crypto/pemutil"
+
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/audit"
+)
+
+// GetAuditLogResponse is the type for GET /admin/audit responses.
+type GetAuditLogResponse struct {
+	Records     []*audit.Record     `json:"records"`
+	Checkpoints []*audit.Checkpoint `json:"checkpoints"`
+	PublicKey   string              `json:"publicKey"`
+}
+
+// VerifyAuditLogResponse is the type for POST /admin/audit/verify responses.
+type VerifyAuditLogResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// GetAuditLog exports the audit log, starting at the record set in the "from"
+// query parameter, with all the signed checkpoints and the public key used to
+// verify them.
+func GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	var from uint64
+	if v := r.URL.Query().Get("from"); v != "" {
+		var err error
+		if from, err = strconv.ParseUint(v, 10, 64); err != nil {
+			render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error parsing from query param"))
+			return
+		}
+	}
+
+	auth := mustAuthority(r.Context())
+	pub, err := auth.GetAuditLogPublicKey()
+	if err != nil {
+		render.Error(w, admin.NewError(admin.ErrorNotFoundType, "audit log is not enabled"))
+		return
+	}
+	block, err := pemutil.Serialize(pub)
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error serializing audit public key"))
+		return
+	}
+	e, err := auth.ExportAuditLog(from)
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error exporting audit log"))
+		return
+	}
+
+	render.JSON(w, &GetAuditLogResponse{
+		Records:     e.Records,
+		Checkpoints: e.Checkpoints,
+		PublicKey:   string(pem.EncodeToMemory(block)),
+	})
+}
+
+// VerifyAuditLog verifies an export of the audit log against the audit key
+// of the authority.
+func VerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	var body audit.Export
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+
+	auth := mustAuthority(r.Context())
+	if _, err := auth.GetAuditLogPublicKey(); err != nil {
+		render.Error(w, admin.NewError(admin.ErrorNotFoundType, "audit log is not enabled"))
+		return
+	}
+	if err := auth.VerifyAuditLog(&body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error verifying audit log"))
+		return
+	}
+
+	render.JSON(w, &VerifyAuditLogResponse{Valid: true})
+}
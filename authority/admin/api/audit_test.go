@@ -0,0 +1,188 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.step.sm/crypto/keyutil"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/audit"
+)
+
+func TestGetAuditLog(t *testing.T) {
+	pub, _, err := keyutil.GenerateDefaultKeyPair()
+	assert.FatalError(t, err)
+	export := &audit.Export{
+		Records:     []*audit.Record{{Index: 1, Type: audit.RevokeType, Data: []byte(`{}`)}},
+		Checkpoints: []*audit.Checkpoint{{Index: 1}},
+	}
+
+	type test struct {
+		req        *http.Request
+		auth       adminAuthority
+		statusCode int
+		message    string
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/from": func(t *testing.T) test {
+			return test{
+				req:        httptest.NewRequest("GET", "/foo?from=A", http.NoBody),
+				statusCode: 400,
+				message:    `error parsing from query param: strconv.ParseUint: parsing "A": invalid syntax`,
+			}
+		},
+		"fail/disabled": func(t *testing.T) test {
+			return test{
+				req: httptest.NewRequest("GET", "/foo", http.NoBody),
+				auth: &mockAdminAuthority{
+					MockErr: errors.New("force"),
+				},
+				statusCode: 404,
+				message:    "audit log is not enabled",
+			}
+		},
+		"fail/export": func(t *testing.T) test {
+			return test{
+				req: httptest.NewRequest("GET", "/foo", http.NoBody),
+				auth: &mockAdminAuthority{
+					MockGetAuditLogPublicKey: func() (crypto.PublicKey, error) { return pub, nil },
+					MockExportAuditLog: func(from uint64) (*audit.Export, error) {
+						return nil, errors.New("force")
+					},
+				},
+				statusCode: 500,
+				message:    "error exporting audit log: force",
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				req: httptest.NewRequest("GET", "/foo?from=1", http.NoBody),
+				auth: &mockAdminAuthority{
+					MockGetAuditLogPublicKey: func() (crypto.PublicKey, error) { return pub, nil },
+					MockExportAuditLog: func(from uint64) (*audit.Export, error) {
+						assert.Equals(t, uint64(1), from)
+						return export, nil
+					},
+				},
+				statusCode: 200,
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			mockMustAuthority(t, tc.auth)
+			w := httptest.NewRecorder()
+			GetAuditLog(w, tc.req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tc.message, adminErr.Message)
+				return
+			}
+
+			var resp GetAuditLogResponse
+			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &resp))
+			assert.Equals(t, export.Records, resp.Records)
+			assert.Equals(t, export.Checkpoints, resp.Checkpoints)
+			assert.True(t, strings.HasPrefix(resp.PublicKey, "-----BEGIN PUBLIC KEY-----"))
+		})
+	}
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	type test struct {
+		body       string
+		auth       adminAuthority
+		statusCode int
+		message    string
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/body": func(t *testing.T) test {
+			return test{
+				body:       "{",
+				statusCode: 400,
+				message:    "error reading request body: error decoding json: unexpected EOF",
+			}
+		},
+		"fail/disabled": func(t *testing.T) test {
+			return test{
+				body: "{}",
+				auth: &mockAdminAuthority{
+					MockErr: errors.New("force"),
+				},
+				statusCode: 404,
+				message:    "audit log is not enabled",
+			}
+		},
+		"fail/verify": func(t *testing.T) test {
+			return test{
+				body: `{"records":[{"index":0}]}`,
+				auth: &mockAdminAuthority{
+					MockGetAuditLogPublicKey: func() (crypto.PublicKey, error) { return nil, nil },
+					MockVerifyAuditLog: func(e *audit.Export) error {
+						assert.Len(t, 1, e.Records)
+						return errors.New("audit record 0 has an invalid hash")
+					},
+				},
+				statusCode: 400,
+				message:    "error verifying audit log: audit record 0 has an invalid hash",
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				body: "{}",
+				auth: &mockAdminAuthority{
+					MockGetAuditLogPublicKey: func() (crypto.PublicKey, error) { return nil, nil },
+					MockVerifyAuditLog:       func(e *audit.Export) error { return nil },
+				},
+				statusCode: 200,
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			mockMustAuthority(t, tc.auth)
+			req := httptest.NewRequest("POST", "/foo", strings.NewReader(tc.body)).WithContext(context.Background())
+			w := httptest.NewRecorder()
+			VerifyAuditLog(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tc.message, adminErr.Message)
+				return
+			}
+
+			var resp VerifyAuditLogResponse
+			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &resp))
+			assert.True(t, resp.Valid)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"go.step.sm/crypto/pemutil"
+
+	"github.com/smallstep/certificates/api/pagination"
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/report"
+)
+
+// GetCertificatesResponse is the type for GET /admin/certificates responses.
+type GetCertificatesResponse struct {
+	Certificates []*report.Certificate `json:"certificates"`
+	NextCursor   string                `json:"nextCursor"`
+}
+
+var (
+	// certificateSortFields are the fields that can be used to sort the list
+	// of certificates.
+	certificateSortFields = []string{"serialNumber", "subject", "notBefore", "notAfter"}
+	// certificateFilterFields are the fields that can be used to filter the
+	// list of certificates.
+	certificateFilterFields = []string{"provisioner", "status", "subject", "san"}
+)
+
+// GetCertificates returns a page of the certificates issued by the authority.
+// The list is sorted by serial number by default, and the serial number of
+// the first certificate of the next page is the cursor.
+func GetCertificates(w http.ResponseWriter, r *http.Request) {
+	opts, err := pagination.Parse(r, certificateSortFields, certificateFilterFields)
+	if err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err,
+			"error parsing cursor and limit from query params"))
+		return
+	}
+
+	certs, err := mustAuthority(r.Context()).ListCertificates()
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	certs = pagination.Filter(certs, func(c *report.Certificate) bool {
+		return opts.Match("provisioner", c.ProvisionerName) &&
+			opts.Match("status", c.Status) &&
+			opts.Match("subject", c.Subject) &&
+			opts.MatchAny("san", c.SANs)
+	})
+	pagination.Sort(certs, &pagination.Options{Sort: "serialNumber"}, certificateLess)
+	pagination.Sort(certs, opts, certificateLess)
+	page, next, err := pagination.Paginate(certs, opts, func(c *report.Certificate) string {
+		return c.SerialNumber
+	})
+	if err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error paginating certificates"))
+		return
+	}
+
+	pagination.SetLinkHeader(w, r, next)
+	render.JSON(w, &GetCertificatesResponse{
+		Certificates: page,
+		NextCursor:   next,
+	})
+}
+
+// GetCertificateLifecycle returns the current status and the history of the
+// certificate with the serial number in the URL. The serial number can be in
+// decimal, or in hexadecimal with the "0x" prefix or with colons as printed by
+// OpenSSL.
+func GetCertificateLifecycle(w http.ResponseWriter, r *http.Request) {
+	serial, ok := parseSerialNumber(chi.URLParam(r, "serial"))
+	if !ok {
+		render.Error(w, admin.NewError(admin.ErrorBadRequestType, "serial number is not valid"))
+		return
+	}
+	lc, err := mustAuthority(r.Context()).GetCertificateLifecycle(serial)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, lc)
+}
+
+// ValidateCertificateRequest is the type for POST /admin/certificates/validate
+// requests. The certificate is PEM encoded, and it can be followed by the
+// intermediates used to build the chain.
+type ValidateCertificateRequest struct {
+	Certificate string `json:"certificate"`
+}
+
+// Validate validates a validate certificate request body.
+func (r *ValidateCertificateRequest) Validate() error {
+	if r.Certificate == "" {
+		return admin.NewError(admin.ErrorBadRequestType, "certificate cannot be empty")
+	}
+	return nil
+}
+
+// ValidateCertificate reports if the authority issued the certificate in the
+// request body, its revocation status, the provisioner that issued it and the
+// checks against the current policies of the authority.
+func ValidateCertificate(w http.ResponseWriter, r *http.Request) {
+	var body ValidateCertificateRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+	chain, err := pemutil.ParseCertificateBundle([]byte(body.Certificate))
+	if err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error parsing certificate"))
+		return
+	}
+
+	cv, err := mustAuthority(r.Context()).ValidateCertificateChain(chain)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, cv)
+}
+
+// parseSerialNumber returns the decimal representation of a serial number.
+func parseSerialNumber(s string) (string, bool) {
+	base := 10
+	switch {
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0X"):
+		s, base = s[2:], 16
+	case strings.Contains(s, ":"):
+		s, base = strings.ReplaceAll(s, ":", ""), 16
+	}
+	n, ok := new(big.Int).SetString(s, base)
+	if !ok || n.Sign() < 0 {
+		return "", false
+	}
+	return n.String(), true
+}
+
+var certificateLess = map[string]func(a, b *report.Certificate) bool{
+	"serialNumber": func(a, b *report.Certificate) bool {
+		x, _ := new(big.Int).SetString(a.SerialNumber, 10)
+		y, _ := new(big.Int).SetString(b.SerialNumber, 10)
+		if x == nil || y == nil {
+			return a.SerialNumber < b.SerialNumber
+		}
+		return x.Cmp(y) < 0
+	},
+	"subject":   func(a, b *report.Certificate) bool { return a.Subject < b.Subject },
+	"notBefore": func(a, b *report.Certificate) bool { return a.NotBefore.Before(b.NotBefore) },
+	"notAfter":  func(a, b *report.Certificate) bool { return a.NotAfter.Before(b.NotAfter) },
+}
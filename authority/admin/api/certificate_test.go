@@ -0,0 +1,227 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/minica"
+
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/report"
+)
+
+func TestGetCertificates(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	certs := []*report.Certificate{
+		{SerialNumber: "10", Subject: "CN=a.example.com", SANs: []string{"a.example.com"}, NotAfter: now.Add(3 * time.Hour), ProvisionerName: "acme", Status: "valid"},
+		{SerialNumber: "9", Subject: "CN=b.example.com", SANs: []string{"b.example.com"}, NotAfter: now.Add(time.Hour), ProvisionerName: "jwk", Status: "revoked"},
+		{SerialNumber: "100", Subject: "CN=c.example.com", SANs: []string{"c.example.com", "www.example.com"}, NotAfter: now.Add(2 * time.Hour), ProvisionerName: "acme", Status: "expired"},
+	}
+	list := func() ([]*report.Certificate, error) {
+		ret := make([]*report.Certificate, len(certs))
+		copy(ret, certs)
+		return ret, nil
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		auth       adminAuthority
+		statusCode int
+		message    string
+		serials    []string
+		nextCursor string
+	}{
+		{"fail/limit", "?limit=foo", nil, 400, "error parsing cursor and limit from query params: limit 'foo' is not an integer: strconv.Atoi: parsing \"foo\": invalid syntax", nil, ""},
+		{"fail/sort", "?sort=sans", nil, 400, "error parsing cursor and limit from query params: sort field 'sans' is not supported", nil, ""},
+		{"fail/authority", "", &mockAdminAuthority{
+			MockListCertificates: func() ([]*report.Certificate, error) {
+				return nil, admin.NewError(admin.ErrorNotImplementedType, "not implemented")
+			},
+		}, 501, "not implemented", nil, ""},
+		{"fail/cursor", "?cursor=foo", &mockAdminAuthority{MockListCertificates: list}, 400, "error paginating certificates: cursor 'foo' is not valid", nil, ""},
+		{"ok", "", &mockAdminAuthority{MockListCertificates: list}, 200, "", []string{"9", "10", "100"}, ""},
+		{"ok/limit", "?limit=2", &mockAdminAuthority{MockListCertificates: list}, 200, "", []string{"9", "10"}, "100"},
+		{"ok/cursor", "?limit=2&cursor=100", &mockAdminAuthority{MockListCertificates: list}, 200, "", []string{"100"}, ""},
+		{"ok/sort", "?sort=-notAfter", &mockAdminAuthority{MockListCertificates: list}, 200, "", []string{"10", "100", "9"}, ""},
+		{"ok/provisioner", "?provisioner=acme", &mockAdminAuthority{MockListCertificates: list}, 200, "", []string{"10", "100"}, ""},
+		{"ok/status", "?status=valid&status=revoked", &mockAdminAuthority{MockListCertificates: list}, 200, "", []string{"9", "10"}, ""},
+		{"ok/san", "?san=www.example.com", &mockAdminAuthority{MockListCertificates: list}, 200, "", []string{"100"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, tt.auth)
+			req := httptest.NewRequest("GET", "/admin/certificates"+tt.query, http.NoBody)
+			w := httptest.NewRecorder()
+			GetCertificates(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tt.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tt.message, adminErr.Message)
+				return
+			}
+
+			var resp GetCertificatesResponse
+			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &resp))
+			serials := []string{}
+			for _, c := range resp.Certificates {
+				serials = append(serials, c.SerialNumber)
+			}
+			assert.Equals(t, tt.serials, serials)
+			assert.Equals(t, tt.nextCursor, resp.NextCursor)
+			if tt.nextCursor != "" {
+				assert.Equals(t, `<http://example.com/admin/certificates?cursor=100&limit=2>;rel="next"`, res.Header.Get("Link"))
+			} else {
+				assert.Equals(t, "", res.Header.Get("Link"))
+			}
+		})
+	}
+}
+
+func TestGetCertificateLifecycle(t *testing.T) {
+	lc := &report.Lifecycle{
+		Certificate: &report.Certificate{SerialNumber: "255", Status: report.StatusRenewed},
+		Successors:  []string{"256"},
+		History: []*report.LifecycleEvent{
+			{Type: report.EventIssued, Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+			{Type: report.EventRenewed, Time: time.Date(2026, 1, 3, 3, 4, 5, 0, time.UTC), SerialNumber: "256"},
+		},
+	}
+	tests := []struct {
+		name       string
+		serial     string
+		err        error
+		statusCode int
+		message    string
+	}{
+		{"ok", "255", nil, 200, ""},
+		{"ok/hex", "0xff", nil, 200, ""},
+		{"ok/colons", "00:ff", nil, 200, ""},
+		{"fail/serial", "foo", nil, 400, "serial number is not valid"},
+		{"fail/negative", "-255", nil, 400, "serial number is not valid"},
+		{"fail/not-found", "255", admin.NewError(admin.ErrorNotFoundType, "certificate 255 not found"), 404, "certificate 255 not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, &mockAdminAuthority{
+				MockGetCertificateLifecycle: func(serialNumber string) (*report.Lifecycle, error) {
+					assert.Equals(t, "255", serialNumber)
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return lc, nil
+				},
+			})
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("serial", tt.serial)
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+			req := httptest.NewRequest("GET", "/admin/certificates/"+tt.serial, http.NoBody).WithContext(ctx)
+			w := httptest.NewRecorder()
+			GetCertificateLifecycle(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tt.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tt.message, adminErr.Message)
+				return
+			}
+
+			var resp report.Lifecycle
+			assert.FatalError(t, json.Unmarshal(body, &resp))
+			assert.Equals(t, "255", resp.SerialNumber)
+			assert.Equals(t, report.StatusRenewed, resp.Status)
+			assert.Equals(t, []string{"256"}, resp.Successors)
+			assert.Len(t, 2, resp.History)
+		})
+	}
+}
+
+func TestValidateCertificate(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	bundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Intermediate.Raw})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Root.Raw}))
+	body, err := json.Marshal(ValidateCertificateRequest{Certificate: bundle})
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name       string
+		body       string
+		err        error
+		statusCode int
+		message    string
+	}{
+		{"ok", string(body), nil, 200, ""},
+		{"fail/body", "{", nil, 400, "error reading request body: error decoding json: unexpected EOF"},
+		{"fail/empty", `{"certificate":""}`, nil, 400, "certificate cannot be empty"},
+		{"fail/pem", `{"certificate":"foo"}`, nil, 400, "error parsing certificate: error decoding pem block"},
+		{"fail/authority", string(body), admin.NewError(admin.ErrorServerInternalType, "database error"), 500, "database error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, &mockAdminAuthority{
+				MockValidateCertificateChain: func(chain []*x509.Certificate) (*authority.CertificateValidation, error) {
+					assert.Len(t, 2, chain)
+					assert.Equals(t, ca.Intermediate.Raw, chain[0].Raw)
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return &authority.CertificateValidation{
+						Valid:       true,
+						Issued:      true,
+						Certificate: &report.Certificate{SerialNumber: chain[0].SerialNumber.String(), Status: report.StatusValid},
+					}, nil
+				},
+			})
+			req := httptest.NewRequest("POST", "/admin/certificates/validate", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			ValidateCertificate(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tt.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tt.message, adminErr.Message)
+				return
+			}
+
+			var resp authority.CertificateValidation
+			assert.FatalError(t, json.Unmarshal(body, &resp))
+			assert.True(t, resp.Valid)
+			assert.Equals(t, ca.Intermediate.SerialNumber.String(), resp.Certificate.SerialNumber)
+		})
+	}
+}
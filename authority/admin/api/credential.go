@@ -0,0 +1,125 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// CreateProvisionerCredentialRequest is the type for POST
+// /admin/provisioners/{provisionerName}/credentials requests. Key is used in
+// JWK credentials, Secret in OIDC client secret credentials, and KeyID and
+// the optional HmacKey in External Account Binding credentials.
+type CreateProvisionerCredentialRequest struct {
+	Type      provisioner.CredentialType `json:"type"`
+	Key       *jose.JSONWebKey           `json:"key,omitempty"`
+	Secret    string                     `json:"secret,omitempty"`
+	KeyID     string                     `json:"keyID,omitempty"`
+	HmacKey   []byte                     `json:"hmacKey,omitempty"`
+	NotBefore time.Time                  `json:"notBefore,omitempty"`
+	NotAfter  time.Time                  `json:"notAfter,omitempty"`
+}
+
+// Validate validates a new provisioner credential request body.
+func (r *CreateProvisionerCredentialRequest) Validate() error {
+	if err := r.Type.Validate(); err != nil {
+		return admin.WrapError(admin.ErrorBadRequestType, err, "invalid type")
+	}
+	return nil
+}
+
+// UpdateProvisionerCredentialRequest is the type for PATCH
+// /admin/provisioners/{provisionerName}/credentials/{id} requests. It
+// replaces the validity of the credential.
+type UpdateProvisionerCredentialRequest struct {
+	NotBefore time.Time `json:"notBefore,omitempty"`
+	NotAfter  time.Time `json:"notAfter,omitempty"`
+}
+
+// GetProvisionerCredentialsResponse is the type for GET
+// /admin/provisioners/{provisionerName}/credentials responses.
+type GetProvisionerCredentialsResponse struct {
+	Credentials []*provisioner.Credential `json:"credentials"`
+}
+
+// GetProvisionerCredentials returns the credentials of the provisioner in the
+// path, without their secrets.
+func GetProvisionerCredentials(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adm := linkedca.MustAdminFromContext(ctx)
+	creds, err := mustAuthority(ctx).GetProvisionerCredentials(ctx, adm, chi.URLParam(r, "provisionerName"))
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, &GetProvisionerCredentialsResponse{Credentials: creds})
+}
+
+// CreateProvisionerCredential adds a credential to the provisioner in the
+// path. The secrets of the credential are only included in this response.
+func CreateProvisionerCredential(w http.ResponseWriter, r *http.Request) {
+	var body CreateProvisionerCredentialRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	adm := linkedca.MustAdminFromContext(ctx)
+	c, err := mustAuthority(ctx).CreateProvisionerCredential(ctx, adm, chi.URLParam(r, "provisionerName"), &provisioner.Credential{
+		Type:      body.Type,
+		Key:       body.Key,
+		Secret:    body.Secret,
+		KeyID:     body.KeyID,
+		HmacKey:   body.HmacKey,
+		NotBefore: body.NotBefore,
+		NotAfter:  body.NotAfter,
+	})
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSONStatus(w, c, http.StatusCreated)
+}
+
+// UpdateProvisionerCredential updates the validity of the credential with
+// the id in the path.
+func UpdateProvisionerCredential(w http.ResponseWriter, r *http.Request) {
+	var body UpdateProvisionerCredentialRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+
+	ctx := r.Context()
+	adm := linkedca.MustAdminFromContext(ctx)
+	c, err := mustAuthority(ctx).UpdateProvisionerCredential(ctx, adm, chi.URLParam(r, "provisionerName"), chi.URLParam(r, "id"), body.NotBefore, body.NotAfter)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, c)
+}
+
+// DeleteProvisionerCredential deletes the credential with the id in the path.
+func DeleteProvisionerCredential(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adm := linkedca.MustAdminFromContext(ctx)
+	if err := mustAuthority(ctx).DeleteProvisionerCredential(ctx, adm, chi.URLParam(r, "provisionerName"), chi.URLParam(r, "id")); err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, &DeleteResponse{Status: "ok"})
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func TestCreateProvisionerCredential(t *testing.T) {
+	adm := &linkedca.Admin{Id: "admin-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+	mockMustAuthority(t, &mockAdminAuthority{
+		MockCreateProvisionerCredential: func(ctx context.Context, a *linkedca.Admin, name string, c *provisioner.Credential) (*provisioner.Credential, error) {
+			assert.Equals(t, adm, a)
+			assert.Equals(t, "acme", name)
+			assert.Equals(t, provisioner.CredentialEABKey, c.Type)
+			assert.Equals(t, "kid", c.KeyID)
+			c.ID, c.HmacKey = "id", []byte("secret")
+			return c, nil
+		},
+	})
+
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add("provisionerName", "acme")
+	ctx := context.WithValue(linkedca.NewContextWithAdmin(context.Background(), adm), chi.RouteCtxKey, chiCtx)
+
+	// The type is validated before the request is sent to the authority.
+	req := httptest.NewRequest("POST", "/foo", strings.NewReader(`{"type":"password"}`)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	CreateProvisionerCredential(w, req)
+	assert.Equals(t, 400, w.Result().StatusCode)
+
+	req = httptest.NewRequest("POST", "/foo", strings.NewReader(`{"type":"eabKey","keyID":"kid"}`)).WithContext(ctx)
+	w = httptest.NewRecorder()
+	CreateProvisionerCredential(w, req)
+	res := w.Result()
+	assert.Equals(t, 201, res.StatusCode)
+
+	var resp provisioner.Credential
+	assert.FatalError(t, json.NewDecoder(res.Body).Decode(&resp))
+	assert.Equals(t, "id", resp.ID)
+	assert.Equals(t, []byte("secret"), resp.HmacKey)
+}
+
+func TestUpdateProvisionerCredential(t *testing.T) {
+	adm := &linkedca.Admin{Id: "admin-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+	notAfter := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockMustAuthority(t, &mockAdminAuthority{
+		MockUpdateProvisionerCredential: func(ctx context.Context, a *linkedca.Admin, name, id string, nb, na time.Time) (*provisioner.Credential, error) {
+			assert.Equals(t, "jwk", name)
+			assert.Equals(t, "id", id)
+			assert.True(t, nb.IsZero())
+			assert.Equals(t, notAfter, na)
+			return &provisioner.Credential{ID: id, NotAfter: na}, nil
+		},
+	})
+
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add("provisionerName", "jwk")
+	chiCtx.URLParams.Add("id", "id")
+	ctx := context.WithValue(linkedca.NewContextWithAdmin(context.Background(), adm), chi.RouteCtxKey, chiCtx)
+	req := httptest.NewRequest("PATCH", "/foo", strings.NewReader(`{"notAfter":"2026-01-01T00:00:00Z"}`)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	UpdateProvisionerCredential(w, req)
+	res := w.Result()
+	assert.Equals(t, 200, res.StatusCode)
+
+	var resp provisioner.Credential
+	assert.FatalError(t, json.NewDecoder(res.Body).Decode(&resp))
+	assert.Equals(t, notAfter, resp.NotAfter)
+}
+
+func TestDeleteProvisionerCredential(t *testing.T) {
+	adm := &linkedca.Admin{Id: "admin-id", Subject: "bob", Type: linkedca.Admin_ADMIN}
+	mockMustAuthority(t, &mockAdminAuthority{
+		MockDeleteProvisionerCredential: func(ctx context.Context, a *linkedca.Admin, name, id string) error {
+			return admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to delete provisioner credentials")
+		},
+	})
+
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add("provisionerName", "jwk")
+	chiCtx.URLParams.Add("id", "id")
+	ctx := context.WithValue(linkedca.NewContextWithAdmin(context.Background(), adm), chi.RouteCtxKey, chiCtx)
+	req := httptest.NewRequest("DELETE", "/foo", http.NoBody).WithContext(ctx)
+	w := httptest.NewRecorder()
+	DeleteProvisionerCredential(w, req)
+	res := w.Result()
+	assert.Equals(t, 401, res.StatusCode)
+
+	adminErr := admin.Error{}
+	assert.FatalError(t, json.NewDecoder(res.Body).Decode(&adminErr))
+	assert.Equals(t, "must have super admin access to delete provisioner credentials", adminErr.Message)
+}
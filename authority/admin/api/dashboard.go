@@ -0,0 +1,216 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/report"
+)
+
+const (
+	defaultDashboardDays   = 30
+	defaultFailureDays     = 1
+	defaultTopProvisioners = 10
+	maxTopProvisioners     = 100
+	// maxDashboardBuckets limits the size of the time series.
+	maxDashboardBuckets = maxExpiringDays
+)
+
+// DashboardSeriesResponse is the type for GET /admin/dashboard/issuance and
+// GET /admin/dashboard/expirations responses.
+type DashboardSeriesResponse struct {
+	Days        int              `json:"days"`
+	Interval    string           `json:"interval"`
+	GeneratedAt time.Time        `json:"generatedAt"`
+	Count       int              `json:"count"`
+	Buckets     []*report.Bucket `json:"buckets"`
+}
+
+// DashboardProvisionersResponse is the type for GET
+// /admin/dashboard/provisioners responses.
+type DashboardProvisionersResponse struct {
+	Days         int                        `json:"days"`
+	GeneratedAt  time.Time                  `json:"generatedAt"`
+	Provisioners []*report.ProvisionerCount `json:"provisioners"`
+}
+
+// DashboardFailuresResponse is the type for GET /admin/dashboard/failures
+// responses.
+type DashboardFailuresResponse struct {
+	Days         int                  `json:"days"`
+	GeneratedAt  time.Time            `json:"generatedAt"`
+	Requests     int                  `json:"requests"`
+	Failures     int                  `json:"failures"`
+	FailureRate  float64              `json:"failureRate"`
+	Provisioners []*report.SignResult `json:"provisioners"`
+}
+
+// GetDashboardIssuance returns the number of certificates issued in the
+// number of days in the days query parameter, 30 by default, by hour or by
+// day. The interval query parameter selects the size of the buckets.
+func GetDashboardIssuance(w http.ResponseWriter, r *http.Request) {
+	days, interval, d, err := parseDashboardSeries(r.URL.Query())
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	certs, err := mustAuthority(r.Context()).ListCertificates()
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	buckets := report.TimeSeries(certs, now.Add(-time.Duration(days)*24*time.Hour), now, d, report.IssuedAt)
+	render.JSON(w, &DashboardSeriesResponse{
+		Days:        days,
+		Interval:    interval,
+		GeneratedAt: now,
+		Count:       countBuckets(buckets),
+		Buckets:     buckets,
+	})
+}
+
+// GetDashboardExpirations returns the number of valid certificates that
+// expire in the number of days in the days query parameter, 30 by default, by
+// hour or by day. Renewed certificates are not included.
+func GetDashboardExpirations(w http.ResponseWriter, r *http.Request) {
+	days, interval, d, err := parseDashboardSeries(r.URL.Query())
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	within := time.Duration(days) * 24 * time.Hour
+	certs, err := mustAuthority(r.Context()).GetExpiringCertificates(within, false)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	buckets := report.TimeSeries(certs, now, now.Add(within), d, report.ExpiresAt)
+	render.JSON(w, &DashboardSeriesResponse{
+		Days:        days,
+		Interval:    interval,
+		GeneratedAt: now,
+		Count:       countBuckets(buckets),
+		Buckets:     buckets,
+	})
+}
+
+// GetDashboardProvisioners returns the provisioners that issued more
+// certificates in the number of days in the days query parameter, 30 by
+// default. The limit query parameter sets the number of provisioners, 10 by
+// default.
+func GetDashboardProvisioners(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	days, err := parseDashboardDays(query, defaultDashboardDays, maxExpiringDays)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	limit := defaultTopProvisioners
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTopProvisioners {
+			render.Error(w, admin.NewError(admin.ErrorBadRequestType, "limit must be a number between 1 and %d", maxTopProvisioners))
+			return
+		}
+		limit = n
+	}
+
+	certs, err := mustAuthority(r.Context()).ListCertificates()
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	render.JSON(w, &DashboardProvisionersResponse{
+		Days:         days,
+		GeneratedAt:  now,
+		Provisioners: report.TopProvisioners(certs, now.Add(-time.Duration(days)*24*time.Hour), limit),
+	})
+}
+
+// GetDashboardFailures returns the number of sign requests and the rate of
+// failures, in total and by provisioner, in the number of days in the days
+// query parameter, 1 by default. The results are kept in memory by each
+// instance of the CA for 7 days.
+func GetDashboardFailures(w http.ResponseWriter, r *http.Request) {
+	maxDays := int(report.SignStatsRetention / (24 * time.Hour))
+	days, err := parseDashboardDays(r.URL.Query(), defaultFailureDays, maxDays)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	results, err := mustAuthority(r.Context()).GetSignResults(now.Add(-time.Duration(days) * 24 * time.Hour))
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	resp := &DashboardFailuresResponse{
+		Days:         days,
+		GeneratedAt:  now,
+		Provisioners: results,
+	}
+	for _, res := range results {
+		resp.Requests += res.Requests
+		resp.Failures += res.Failures
+	}
+	if resp.Requests > 0 {
+		resp.FailureRate = float64(resp.Failures) / float64(resp.Requests)
+	}
+	render.JSON(w, resp)
+}
+
+// parseDashboardDays returns the value of the days query parameter.
+func parseDashboardDays(query url.Values, def, maxDays int) (int, error) {
+	v := query.Get("days")
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > maxDays {
+		return 0, admin.NewError(admin.ErrorBadRequestType, "days must be a number between 1 and %d", maxDays)
+	}
+	return n, nil
+}
+
+// parseDashboardSeries returns the days and the interval of a time series. The
+// interval is a day by default.
+func parseDashboardSeries(query url.Values) (int, string, time.Duration, error) {
+	days, err := parseDashboardDays(query, defaultDashboardDays, maxExpiringDays)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = report.IntervalDay
+	}
+	d, ok := report.IntervalDuration(interval)
+	if !ok {
+		return 0, "", 0, admin.NewError(admin.ErrorBadRequestType, "interval must be %s or %s", report.IntervalHour, report.IntervalDay)
+	}
+	if time.Duration(days)*24*time.Hour/d > maxDashboardBuckets {
+		return 0, "", 0, admin.NewError(admin.ErrorBadRequestType, "the number of %s intervals in %d days cannot be greater than %d", interval, days, maxDashboardBuckets)
+	}
+	return days, interval, d, nil
+}
+
+func countBuckets(buckets []*report.Bucket) int {
+	var n int
+	for _, b := range buckets {
+		n += b.Count
+	}
+	return n
+}
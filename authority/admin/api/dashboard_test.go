@@ -0,0 +1,199 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/report"
+)
+
+func doDashboardRequest(t *testing.T, handler http.HandlerFunc, query string, auth adminAuthority, statusCode int, message string, v interface{}) {
+	t.Helper()
+	mockMustAuthority(t, auth)
+	req := httptest.NewRequest("GET", "/admin/dashboard"+query, http.NoBody)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	res := w.Result()
+	assert.Equals(t, statusCode, res.StatusCode)
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.FatalError(t, err)
+
+	if res.StatusCode >= 400 {
+		adminErr := admin.Error{}
+		assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+		assert.Equals(t, message, adminErr.Message)
+		return
+	}
+	assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), v))
+}
+
+func TestGetDashboardIssuance(t *testing.T) {
+	now := time.Now()
+	certs := []*report.Certificate{
+		{SerialNumber: "1", NotBefore: now.Add(-time.Minute), ProvisionerName: "acme"},
+		{SerialNumber: "2", NotBefore: now.Add(-48 * time.Hour), ProvisionerName: "jwk"},
+		{SerialNumber: "3", NotBefore: now.Add(-60 * 24 * time.Hour), ProvisionerName: "jwk"},
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		auth       adminAuthority
+		statusCode int
+		message    string
+		buckets    int
+		count      int
+	}{
+		{"fail/days", "?days=0", nil, 400, "days must be a number between 1 and 3650", 0, 0},
+		{"fail/interval", "?interval=week", nil, 400, "interval must be hour or day", 0, 0},
+		{"fail/buckets", "?days=365&interval=hour", nil, 400, "the number of hour intervals in 365 days cannot be greater than 3650", 0, 0},
+		{"fail/authority", "", &mockAdminAuthority{
+			MockListCertificates: func() ([]*report.Certificate, error) {
+				return nil, admin.NewError(admin.ErrorNotImplementedType, "not implemented")
+			},
+		}, 501, "not implemented", 0, 0},
+		{"ok", "", &mockAdminAuthority{MockRet1: certs}, 200, "", 31, 2},
+		{"ok/hour", "?days=1&interval=hour", &mockAdminAuthority{MockRet1: certs}, 200, "", 25, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp DashboardSeriesResponse
+			doDashboardRequest(t, GetDashboardIssuance, tt.query, tt.auth, tt.statusCode, tt.message, &resp)
+			if tt.statusCode == 200 {
+				assert.Len(t, tt.buckets, resp.Buckets)
+				assert.Equals(t, tt.count, resp.Count)
+			}
+		})
+	}
+}
+
+func TestGetDashboardExpirations(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		query      string
+		auth       adminAuthority
+		statusCode int
+		message    string
+		buckets    int
+		count      int
+	}{
+		{"fail/days", "?days=foo", nil, 400, "days must be a number between 1 and 3650", 0, 0},
+		{"fail/authority", "", &mockAdminAuthority{
+			MockGetExpiringCertificates: func(within time.Duration, includeSuperseded bool) ([]*report.Certificate, error) {
+				return nil, admin.NewError(admin.ErrorNotImplementedType, "not implemented")
+			},
+		}, 501, "not implemented", 0, 0},
+		{"ok", "?days=7", &mockAdminAuthority{
+			MockGetExpiringCertificates: func(within time.Duration, includeSuperseded bool) ([]*report.Certificate, error) {
+				assert.Equals(t, 7*24*time.Hour, within)
+				assert.False(t, includeSuperseded)
+				return []*report.Certificate{
+					{SerialNumber: "1", NotAfter: now.Add(time.Hour)},
+					{SerialNumber: "2", NotAfter: now.Add(72 * time.Hour)},
+				}, nil
+			},
+		}, 200, "", 8, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp DashboardSeriesResponse
+			doDashboardRequest(t, GetDashboardExpirations, tt.query, tt.auth, tt.statusCode, tt.message, &resp)
+			if tt.statusCode == 200 {
+				assert.Len(t, tt.buckets, resp.Buckets)
+				assert.Equals(t, tt.count, resp.Count)
+				assert.Equals(t, "day", resp.Interval)
+			}
+		})
+	}
+}
+
+func TestGetDashboardProvisioners(t *testing.T) {
+	now := time.Now()
+	certs := []*report.Certificate{
+		{SerialNumber: "1", NotBefore: now, ProvisionerName: "acme", ProvisionerType: "ACME"},
+		{SerialNumber: "2", NotBefore: now, ProvisionerName: "acme", ProvisionerType: "ACME"},
+		{SerialNumber: "3", NotBefore: now, ProvisionerName: "jwk", ProvisionerType: "JWK"},
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		auth       adminAuthority
+		statusCode int
+		message    string
+		want       []string
+	}{
+		{"fail/days", "?days=4000", nil, 400, "days must be a number between 1 and 3650", nil},
+		{"fail/limit", "?limit=0", nil, 400, "limit must be a number between 1 and 100", nil},
+		{"fail/authority", "", &mockAdminAuthority{
+			MockListCertificates: func() ([]*report.Certificate, error) {
+				return nil, admin.NewError(admin.ErrorServerInternalType, "force")
+			},
+		}, 500, "force", nil},
+		{"ok", "", &mockAdminAuthority{MockRet1: certs}, 200, "", []string{"acme", "jwk"}},
+		{"ok/limit", "?limit=1", &mockAdminAuthority{MockRet1: certs}, 200, "", []string{"acme"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp DashboardProvisionersResponse
+			doDashboardRequest(t, GetDashboardProvisioners, tt.query, tt.auth, tt.statusCode, tt.message, &resp)
+			if tt.statusCode == 200 {
+				names := []string{}
+				for _, p := range resp.Provisioners {
+					names = append(names, p.Name)
+				}
+				assert.Equals(t, tt.want, names)
+			}
+		})
+	}
+}
+
+func TestGetDashboardFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		auth       adminAuthority
+		statusCode int
+		message    string
+		want       *DashboardFailuresResponse
+	}{
+		{"fail/days", "?days=8", nil, 400, "days must be a number between 1 and 7", nil},
+		{"fail/authority", "", &mockAdminAuthority{
+			MockGetSignResults: func(since time.Time) ([]*report.SignResult, error) {
+				return nil, admin.NewError(admin.ErrorNotImplementedType, "not implemented")
+			},
+		}, 501, "not implemented", nil},
+		{"ok", "?days=7", &mockAdminAuthority{
+			MockGetSignResults: func(since time.Time) ([]*report.SignResult, error) {
+				assert.True(t, time.Since(since) > 7*24*time.Hour-time.Minute)
+				return []*report.SignResult{
+					{ProvisionerName: "acme", Requests: 3, Failures: 1, FailureRate: 1.0 / 3},
+					{ProvisionerName: "jwk", Requests: 1, Failures: 0},
+				}, nil
+			},
+		}, 200, "", &DashboardFailuresResponse{Days: 7, Requests: 4, Failures: 1, FailureRate: 0.25}},
+		{"ok/empty", "", &mockAdminAuthority{MockRet1: []*report.SignResult{}}, 200, "", &DashboardFailuresResponse{Days: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp DashboardFailuresResponse
+			doDashboardRequest(t, GetDashboardFailures, tt.query, tt.auth, tt.statusCode, tt.message, &resp)
+			if tt.want != nil {
+				assert.Equals(t, tt.want.Days, resp.Days)
+				assert.Equals(t, tt.want.Requests, resp.Requests)
+				assert.Equals(t, tt.want.Failures, resp.Failures)
+				assert.Equals(t, tt.want.FailureRate, resp.FailureRate)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/admin"
+)
+
+// DecommissionRequest is the type for POST /admin/decommission requests.
+type DecommissionRequest struct {
+	Reason        string    `json:"reason,omitempty"`
+	CRLNextUpdate time.Time `json:"crlNextUpdate,omitempty"`
+	Statement     bool      `json:"statement,omitempty"`
+	Confirm       bool      `json:"confirm"`
+}
+
+// Validate validates a decommission request body.
+func (r *DecommissionRequest) Validate() error {
+	if !r.Confirm {
+		return admin.NewError(admin.ErrorBadRequestType, "decommission must be confirmed, it cannot be undone")
+	}
+	return nil
+}
+
+// GetDecommission returns the decommission status of the authority.
+func GetDecommission(w http.ResponseWriter, r *http.Request) {
+	st, err := mustAuthority(r.Context()).GetDecommissionStatus()
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, st)
+}
+
+// Decommission stops the issuance of certificates, generates the final CRL
+// and, if requested, signs the decommission statement.
+func Decommission(w http.ResponseWriter, r *http.Request) {
+	var body DecommissionRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	adm := linkedca.MustAdminFromContext(ctx)
+	st, err := mustAuthority(ctx).Decommission(ctx, adm, authority.DecommissionOptions{
+		Reason:        body.Reason,
+		CRLNextUpdate: body.CRLNextUpdate,
+		Statement:     body.Statement,
+	})
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSONStatus(w, st, http.StatusCreated)
+}
+
+// GetDecommissionArchive returns a gzipped tar archive with the inventory of
+// certificates, the audit log, the CRL and the decommission statement.
+func GetDecommissionArchive(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := mustAuthority(r.Context()).WriteDecommissionArchive(&buf); err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error writing decommission archive"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="decommission.tar.gz"`)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/decommission"
+)
+
+func TestDecommission(t *testing.T) {
+	adm := &linkedca.Admin{Id: "admin-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+	nextUpdate := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type test struct {
+		body       string
+		auth       adminAuthority
+		statusCode int
+		message    string
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/body": func(t *testing.T) test {
+			return test{
+				body:       "{",
+				statusCode: 400,
+				message:    "error reading request body: error decoding json: unexpected EOF",
+			}
+		},
+		"fail/confirm": func(t *testing.T) test {
+			return test{
+				body:       `{"reason":"retired"}`,
+				statusCode: 400,
+				message:    "decommission must be confirmed, it cannot be undone",
+			}
+		},
+		"fail/decommission": func(t *testing.T) test {
+			return test{
+				body: `{"confirm":true}`,
+				auth: &mockAdminAuthority{
+					MockDecommission: func(ctx context.Context, adm *linkedca.Admin, opts authority.DecommissionOptions) (*decommission.Status, error) {
+						return nil, admin.NewError(admin.ErrorConflictType, "authority was decommissioned")
+					},
+				},
+				statusCode: 409,
+				message:    "authority was decommissioned",
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				body: `{"reason":"retired","crlNextUpdate":"2030-01-01T00:00:00Z","statement":true,"confirm":true}`,
+				auth: &mockAdminAuthority{
+					MockDecommission: func(ctx context.Context, a *linkedca.Admin, opts authority.DecommissionOptions) (*decommission.Status, error) {
+						assert.Equals(t, adm, a)
+						assert.Equals(t, authority.DecommissionOptions{Reason: "retired", CRLNextUpdate: nextUpdate, Statement: true}, opts)
+						return &decommission.Status{Admin: a.Subject, Reason: opts.Reason, CRLNextUpdate: opts.CRLNextUpdate, Statement: "jws"}, nil
+					},
+				},
+				statusCode: 201,
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			mockMustAuthority(t, tc.auth)
+			ctx := linkedca.NewContextWithAdmin(context.Background(), adm)
+			req := httptest.NewRequest("POST", "/foo", strings.NewReader(tc.body)).WithContext(ctx)
+			w := httptest.NewRecorder()
+			Decommission(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tc.message, adminErr.Message)
+				return
+			}
+
+			var resp decommission.Status
+			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &resp))
+			assert.Equals(t, "alice", resp.Admin)
+			assert.Equals(t, "retired", resp.Reason)
+			assert.Equals(t, "jws", resp.Statement)
+		})
+	}
+}
+
+func TestGetDecommission(t *testing.T) {
+	mockMustAuthority(t, &mockAdminAuthority{
+		MockErr:  admin.NewError(admin.ErrorNotFoundType, "authority has not been decommissioned"),
+		MockRet1: (*decommission.Status)(nil),
+	})
+	w := httptest.NewRecorder()
+	GetDecommission(w, httptest.NewRequest("GET", "/foo", nil))
+	assert.Equals(t, 404, w.Result().StatusCode)
+
+	mockMustAuthority(t, &mockAdminAuthority{
+		MockRet1: &decommission.Status{Reason: "retired"},
+	})
+	w = httptest.NewRecorder()
+	GetDecommission(w, httptest.NewRequest("GET", "/foo", nil))
+	res := w.Result()
+	assert.Equals(t, 200, res.StatusCode)
+	var resp decommission.Status
+	assert.FatalError(t, json.NewDecoder(res.Body).Decode(&resp))
+	assert.Equals(t, "retired", resp.Reason)
+}
+
+func TestGetDecommissionArchive(t *testing.T) {
+	mockMustAuthority(t, &mockAdminAuthority{
+		MockWriteDecommissionArchive: func(w io.Writer) error {
+			_, err := w.Write([]byte("archive"))
+			return err
+		},
+	})
+	w := httptest.NewRecorder()
+	GetDecommissionArchive(w, httptest.NewRequest("GET", "/foo", nil))
+	res := w.Result()
+	assert.Equals(t, 200, res.StatusCode)
+	assert.Equals(t, "application/gzip", res.Header.Get("Content-Type"))
+	body, err := io.ReadAll(res.Body)
+	assert.FatalError(t, err)
+	assert.Equals(t, "archive", string(body))
+
+	mockMustAuthority(t, &mockAdminAuthority{
+		MockWriteDecommissionArchive: func(w io.Writer) error {
+			_, _ = w.Write([]byte("partial"))
+			return errors.New("force")
+		},
+	})
+	w = httptest.NewRecorder()
+	GetDecommissionArchive(w, httptest.NewRequest("GET", "/foo", nil))
+	res = w.Result()
+	assert.Equals(t, 500, res.StatusCode)
+	assert.Equals(t, "application/json", res.Header.Get("Content-Type"))
+}
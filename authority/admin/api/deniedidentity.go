@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/db"
+)
+
+// GetDeniedIdentitiesResponse is the type for GET /admin/denied-identities
+// responses.
+type GetDeniedIdentitiesResponse struct {
+	Identities []*db.DeniedIdentity `json:"identities"`
+}
+
+// ClearDeniedIdentitiesRequest is the type for POST
+// /admin/denied-identities/clear requests.
+type ClearDeniedIdentitiesRequest struct {
+	Names []string `json:"names"`
+}
+
+// Validate validates a clear denied identities request body.
+func (r *ClearDeniedIdentitiesRequest) Validate() error {
+	if len(r.Names) == 0 {
+		return admin.NewError(admin.ErrorBadRequestType, "names cannot be empty")
+	}
+	for _, name := range r.Names {
+		if name == "" {
+			return admin.NewError(admin.ErrorBadRequestType, "names cannot contain empty values")
+		}
+	}
+	return nil
+}
+
+// GetDeniedIdentities returns the common names and SANs that cannot be used in
+// new certificates after the revocation of a certificate.
+func GetDeniedIdentities(w http.ResponseWriter, r *http.Request) {
+	list, err := mustAuthority(r.Context()).GetDeniedIdentities()
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, &GetDeniedIdentitiesResponse{Identities: list})
+}
+
+// ClearDeniedIdentities allows new certificates for the names in the request.
+func ClearDeniedIdentities(w http.ResponseWriter, r *http.Request) {
+	var body ClearDeniedIdentitiesRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	adm := linkedca.MustAdminFromContext(ctx)
+	if err := mustAuthority(ctx).ClearDeniedIdentities(ctx, adm, body.Names); err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, &DeleteResponse{Status: "ok"})
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/db"
+)
+
+func TestGetDeniedIdentities(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	list := []*db.DeniedIdentity{{Name: "test.example.com", Serial: "1234", Reason: "key compromise", DeniedAt: now}}
+	mockMustAuthority(t, &mockAdminAuthority{
+		MockGetDeniedIdentities: func() ([]*db.DeniedIdentity, error) {
+			return list, nil
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/foo", http.NoBody)
+	w := httptest.NewRecorder()
+	GetDeniedIdentities(w, req)
+	res := w.Result()
+	assert.Equals(t, 200, res.StatusCode)
+
+	var resp GetDeniedIdentitiesResponse
+	assert.FatalError(t, json.NewDecoder(res.Body).Decode(&resp))
+	assert.Equals(t, list, resp.Identities)
+
+	mockMustAuthority(t, &mockAdminAuthority{
+		MockRet1: []*db.DeniedIdentity(nil),
+		MockErr:  admin.NewError(admin.ErrorNotImplementedType, "denied identities are not enabled"),
+	})
+	w = httptest.NewRecorder()
+	GetDeniedIdentities(w, req)
+	assert.Equals(t, 501, w.Result().StatusCode)
+}
+
+func TestClearDeniedIdentities(t *testing.T) {
+	adm := &linkedca.Admin{Id: "admin-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+	mockMustAuthority(t, &mockAdminAuthority{
+		MockClearDeniedIdentities: func(ctx context.Context, a *linkedca.Admin, names []string) error {
+			assert.Equals(t, adm, a)
+			if names[0] == "missing.example.com" {
+				return admin.NewError(admin.ErrorNotFoundType, "identity %s is not denied", names[0])
+			}
+			assert.Equals(t, []string{"test.example.com", "10.0.0.1"}, names)
+			return nil
+		},
+	})
+	ctx := linkedca.NewContextWithAdmin(context.Background(), adm)
+
+	tests := []struct {
+		name       string
+		body       string
+		statusCode int
+	}{
+		{"ok", `{"names":["test.example.com","10.0.0.1"]}`, 200},
+		{"fail/json", `{`, 400},
+		{"fail/empty", `{"names":[]}`, 400},
+		{"fail/empty-name", `{"names":["test.example.com",""]}`, 400},
+		{"fail/not-found", `{"names":["missing.example.com"]}`, 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/foo", strings.NewReader(tt.body)).WithContext(ctx)
+			w := httptest.NewRecorder()
+			ClearDeniedIdentities(w, req)
+			assert.Equals(t, tt.statusCode, w.Result().StatusCode)
+		})
+	}
+}
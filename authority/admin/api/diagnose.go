@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/admin"
+)
+
+// DiagnoseACMEChallengeRequest is the type for POST /admin/acme/diagnose
+// requests.
+type DiagnoseACMEChallengeRequest struct {
+	AccountID  string             `json:"accountId"`
+	Type       acme.ChallengeType `json:"type"`
+	Identifier acme.Identifier    `json:"identifier"`
+	Token      string             `json:"token"`
+}
+
+// Validate validates a diagnose request body.
+func (r *DiagnoseACMEChallengeRequest) Validate() error {
+	if r.AccountID == "" {
+		return admin.NewError(admin.ErrorBadRequestType, "accountId cannot be empty")
+	}
+	if err := acme.ValidateDiagnostic(r.Type, r.Identifier, r.Token); err != nil {
+		return admin.WrapError(admin.ErrorBadRequestType, err, "invalid diagnose request")
+	}
+	return nil
+}
+
+// DiagnoseACMEChallenge validates a challenge for an identifier with the key
+// of the given ACME account, without creating an order, and returns the
+// operations performed and their results.
+func DiagnoseACMEChallenge(w http.ResponseWriter, r *http.Request) {
+	var body DiagnoseACMEChallengeRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	db, ok := acme.DatabaseFromContext(ctx)
+	if !ok || db == nil {
+		render.Error(w, admin.NewError(admin.ErrorNotImplementedType, "acme is not configured"))
+		return
+	}
+	acc, err := db.GetAccount(ctx, body.AccountID)
+	if err != nil {
+		if acme.IsErrNotFound(err) {
+			render.Error(w, admin.NewError(admin.ErrorNotFoundType, "acme account %s not found", body.AccountID))
+			return
+		}
+		render.Error(w, admin.WrapErrorISE(err, "error loading acme account %s", body.AccountID))
+		return
+	}
+
+	d, err := acme.Diagnose(ctx, body.Type, body.Identifier, body.Token, acc.Key)
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error diagnosing acme challenge"))
+		return
+	}
+	render.JSON(w, d)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/acme"
+)
+
+type mockACMEClient struct {
+	acme.Client
+	get func(url string) (*http.Response, error)
+}
+
+func (m *mockACMEClient) Get(url string) (*http.Response, error) { return m.get(url) }
+
+func TestDiagnoseACMEChallenge(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	keyAuth, err := acme.KeyAuthorization("token", jwk)
+	assert.FatalError(t, err)
+
+	db := &acme.MockDB{
+		MockGetAccount: func(ctx context.Context, id string) (*acme.Account, error) {
+			switch id {
+			case "accountID":
+				return &acme.Account{ID: id, Key: jwk}, nil
+			case "missing":
+				return nil, acme.ErrNotFound
+			default:
+				return nil, errors.New("force")
+			}
+		},
+	}
+	client := &mockACMEClient{
+		get: func(url string) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(keyAuth)),
+			}, nil
+		},
+	}
+	body := func(accountID string) string {
+		return `{"accountId":"` + accountID + `","type":"http-01","identifier":{"type":"dns","value":"example.com"},"token":"token"}`
+	}
+
+	tests := map[string]struct {
+		db         acme.DB
+		body       string
+		statusCode int
+	}{
+		"fail/read":           {db, "{", 400},
+		"fail/validate":       {db, `{"accountId":"accountID","type":"dns-01","identifier":{"type":"ip","value":"127.0.0.1"},"token":"token"}`, 400},
+		"fail/no-account":     {db, `{"type":"http-01","identifier":{"type":"dns","value":"example.com"},"token":"token"}`, 400},
+		"fail/not-configured": {nil, body("accountID"), 501},
+		"fail/not-found":      {db, body("missing"), 404},
+		"fail/get-account":    {db, body("error"), 500},
+		"ok":                  {db, body("accountID"), 200},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := acme.NewClientContext(context.Background(), client)
+			if tc.db != nil {
+				ctx = acme.NewDatabaseContext(ctx, tc.db)
+			}
+			req := httptest.NewRequest("POST", "/acme/diagnose", strings.NewReader(tc.body))
+			req = req.WithContext(ctx)
+			w := httptest.NewRecorder()
+			DiagnoseACMEChallenge(w, req)
+			res := w.Result()
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			if res.StatusCode == 200 {
+				b, err := io.ReadAll(res.Body)
+				assert.FatalError(t, err)
+				var d acme.Diagnostic
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(b), &d))
+				assert.Equals(t, acme.StatusValid, d.Status)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/smallstep/certificates/api"
 	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/apitoken"
 )
 
 var mustAuthority = func(ctx context.Context) adminAuthority {
@@ -49,6 +50,11 @@ func Route(r api.Router, options ...RouterOption) {
 		return extractAuthorizeTokenAdmin(requireAPIEnabled(next))
 	}
 
+	// scoped also accepts API tokens with the given scope.
+	scoped := func(scope apitoken.Scope, next http.HandlerFunc) http.HandlerFunc {
+		return extractAuthorizeScopedToken(scope, requireAPIEnabled(next))
+	}
+
 	enabledInStandalone := func(next http.HandlerFunc) http.HandlerFunc {
 		return checkAction(next, true)
 	}
@@ -57,8 +63,8 @@ func Route(r api.Router, options ...RouterOption) {
 		return checkAction(next, false)
 	}
 
-	acmeEABMiddleware := func(next http.HandlerFunc) http.HandlerFunc {
-		return authnz(loadProvisionerByName(requireEABEnabled(next)))
+	acmeEABMiddleware := func(scope apitoken.Scope, next http.HandlerFunc) http.HandlerFunc {
+		return scoped(scope, loadProvisionerByName(requireEABEnabled(next)))
 	}
 
 	authorityPolicyMiddleware := func(next http.HandlerFunc) http.HandlerFunc {
@@ -91,13 +97,96 @@ func Route(r api.Router, options ...RouterOption) {
 	r.MethodFunc("PATCH", "/admins/{id}", authnz(UpdateAdmin))
 	r.MethodFunc("DELETE", "/admins/{id}", authnz(DeleteAdmin))
 
+	// Audit log
+	r.MethodFunc("GET", "/audit", scoped(apitoken.ScopeAuditRead, GetAuditLog))
+	r.MethodFunc("POST", "/audit/verify", authnz(VerifyAuditLog))
+
+	// Subordinate CAs
+	r.MethodFunc("GET", "/subca", authnz(GetSubCARequests))
+	r.MethodFunc("GET", "/subca/{id}", authnz(GetSubCARequest))
+	r.MethodFunc("POST", "/subca", authnz(CreateSubCA))
+	r.MethodFunc("POST", "/subca/{id}/approve", authnz(ApproveSubCA))
+	r.MethodFunc("POST", "/subca/{id}/reject", authnz(RejectSubCA))
+
+	// Authority settings
+	r.MethodFunc("GET", "/settings", authnz(GetSettings))
+	r.MethodFunc("PUT", "/settings", authnz(UpdateSettings))
+	r.MethodFunc("GET", "/settings/versions", authnz(GetSettingsVersions))
+	r.MethodFunc("GET", "/settings/versions/{version}", authnz(GetSettingsVersion))
+	r.MethodFunc("POST", "/settings/versions/{version}/rollback", authnz(RollbackSettings))
+
+	// Self-test
+	r.MethodFunc("POST", "/selftest", authnz(RunSelfTest))
+
+	// Decommission
+	r.MethodFunc("GET", "/decommission", authnz(GetDecommission))
+	r.MethodFunc("POST", "/decommission", authnz(Decommission))
+	r.MethodFunc("GET", "/decommission/archive", authnz(GetDecommissionArchive))
+
+	// Reports
+	r.MethodFunc("GET", "/reports/expiring", scoped(apitoken.ScopeInventoryRead, GetExpiringCertificates))
+
+	// Dashboards
+	r.MethodFunc("GET", "/dashboard/issuance", scoped(apitoken.ScopeInventoryRead, GetDashboardIssuance))
+	r.MethodFunc("GET", "/dashboard/expirations", scoped(apitoken.ScopeInventoryRead, GetDashboardExpirations))
+	r.MethodFunc("GET", "/dashboard/provisioners", scoped(apitoken.ScopeInventoryRead, GetDashboardProvisioners))
+	r.MethodFunc("GET", "/dashboard/failures", scoped(apitoken.ScopeInventoryRead, GetDashboardFailures))
+
+	// Certificates
+	r.MethodFunc("GET", "/certificates", scoped(apitoken.ScopeInventoryRead, GetCertificates))
+	r.MethodFunc("GET", "/certificates/{serial}", scoped(apitoken.ScopeInventoryRead, GetCertificateLifecycle))
+	r.MethodFunc("POST", "/certificates/validate", authnz(ValidateCertificate))
+
+	// Identities denied after a revocation
+	r.MethodFunc("GET", "/denied-identities", authnz(GetDeniedIdentities))
+	r.MethodFunc("POST", "/denied-identities/clear", authnz(ClearDeniedIdentities))
+
+	// Trust bundles
+	r.MethodFunc("GET", "/trust-bundles/{format}", scoped(apitoken.ScopeTrustBundleRead, GetTrustBundle))
+
+	// Tokens
+	r.MethodFunc("POST", "/tokens/introspect", authnz(IntrospectToken))
+
+	// API tokens
+	r.MethodFunc("GET", "/api-tokens", authnz(GetAPITokens))
+	r.MethodFunc("POST", "/api-tokens", authnz(CreateAPIToken))
+	r.MethodFunc("POST", "/api-tokens/{id}/rotate", authnz(RotateAPIToken))
+	r.MethodFunc("DELETE", "/api-tokens/{id}", authnz(RevokeAPIToken))
+
+	// Provisioner credentials
+	r.MethodFunc("GET", "/provisioners/{provisionerName}/credentials", authnz(GetProvisionerCredentials))
+	r.MethodFunc("POST", "/provisioners/{provisionerName}/credentials", authnz(CreateProvisionerCredential))
+	r.MethodFunc("PATCH", "/provisioners/{provisionerName}/credentials/{id}", authnz(UpdateProvisionerCredential))
+	r.MethodFunc("DELETE", "/provisioners/{provisionerName}/credentials/{id}", authnz(DeleteProvisionerCredential))
+
+	// Activity
+	r.MethodFunc("GET", "/activity", authnz(StreamActivity))
+
+	// ACME challenge diagnostics
+	r.MethodFunc("POST", "/acme/diagnose", authnz(DiagnoseACMEChallenge))
+
 	// ACME responder
 	if router.acmeResponder != nil {
 		// ACME External Account Binding Keys
-		r.MethodFunc("GET", "/acme/eab/{provisionerName}/{reference}", acmeEABMiddleware(router.acmeResponder.GetExternalAccountKeys))
-		r.MethodFunc("GET", "/acme/eab/{provisionerName}", acmeEABMiddleware(router.acmeResponder.GetExternalAccountKeys))
-		r.MethodFunc("POST", "/acme/eab/{provisionerName}", acmeEABMiddleware(router.acmeResponder.CreateExternalAccountKey))
-		r.MethodFunc("DELETE", "/acme/eab/{provisionerName}/{id}", acmeEABMiddleware(router.acmeResponder.DeleteExternalAccountKey))
+		r.MethodFunc("GET", "/acme/eab/{provisionerName}/{reference}", acmeEABMiddleware(apitoken.ScopeEABRead, router.acmeResponder.GetExternalAccountKeys))
+		r.MethodFunc("GET", "/acme/eab/{provisionerName}", acmeEABMiddleware(apitoken.ScopeEABRead, router.acmeResponder.GetExternalAccountKeys))
+		r.MethodFunc("POST", "/acme/eab/{provisionerName}", acmeEABMiddleware(apitoken.ScopeEABWrite, router.acmeResponder.CreateExternalAccountKey))
+		r.MethodFunc("DELETE", "/acme/eab/{provisionerName}/{id}", acmeEABMiddleware(apitoken.ScopeEABWrite, router.acmeResponder.DeleteExternalAccountKey))
+
+		// ACME orders waiting for an approval
+		r.MethodFunc("GET", "/acme/orders/{provisionerName}/pending-approval", authnz(loadProvisionerByName(router.acmeResponder.GetDeferredOrders)))
+		r.MethodFunc("POST", "/acme/orders/{provisionerName}/{id}/approve", authnz(loadProvisionerByName(router.acmeResponder.ApproveOrder)))
+		r.MethodFunc("POST", "/acme/orders/{provisionerName}/{id}/reject", authnz(loadProvisionerByName(router.acmeResponder.RejectOrder)))
+
+		// ACME accounts, their orders, authorizations and certificates
+		r.MethodFunc("GET", "/acme/accounts/{provisionerName}", authnz(loadProvisionerByName(router.acmeResponder.GetAccounts)))
+		r.MethodFunc("GET", "/acme/accounts/{provisionerName}/{id}", authnz(loadProvisionerByName(router.acmeResponder.GetAccount)))
+		r.MethodFunc("POST", "/acme/accounts/{provisionerName}/{id}/disable", authnz(loadProvisionerByName(router.acmeResponder.DisableAccount)))
+		r.MethodFunc("GET", "/acme/accounts/{provisionerName}/{id}/orders", authnz(loadProvisionerByName(router.acmeResponder.GetAccountOrders)))
+		r.MethodFunc("GET", "/acme/accounts/{provisionerName}/{id}/authorizations", authnz(loadProvisionerByName(router.acmeResponder.GetAccountAuthorizations)))
+		r.MethodFunc("POST", "/acme/accounts/{provisionerName}/{id}/authorizations/{authzID}/invalidate", authnz(loadProvisionerByName(router.acmeResponder.InvalidateAuthorization)))
+		r.MethodFunc("GET", "/acme/accounts/{provisionerName}/{id}/certificates", authnz(loadProvisionerByName(router.acmeResponder.GetAccountCertificates)))
+		r.MethodFunc("POST", "/acme/certificates/{provisionerName}/{serial}/revoke", authnz(loadProvisionerByName(router.acmeResponder.RevokeCertificate)))
 	}
 
 	// Policy responder
@@ -3,6 +3,7 @@ package api
 import (
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/smallstep/certificates/api/render"
 	"github.com/smallstep/certificates/authority/admin"
 	"github.com/smallstep/certificates/authority/admin/db/nosql"
+	"github.com/smallstep/certificates/authority/apitoken"
 	"github.com/smallstep/certificates/authority/provisioner"
 )
 
@@ -49,6 +51,28 @@ func extractAuthorizeTokenAdmin(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// extractAuthorizeScopedToken is a middleware that accepts an API token with
+// the given scope, or the token of an admin.
+func extractAuthorizeScopedToken(scope apitoken.Scope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tok := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !apitoken.IsToken(tok) {
+			extractAuthorizeTokenAdmin(next)(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		adm, err := mustAuthority(ctx).AuthorizeAPIToken(tok, scope)
+		if err != nil {
+			render.Error(w, err)
+			return
+		}
+
+		ctx = linkedca.NewContextWithAdmin(ctx, adm)
+		next(w, r.WithContext(ctx))
+	}
+}
+
 // loadProvisionerByName is a middleware that searches for a provisioner
 // by name and stores it in the context.
 func loadProvisionerByName(next http.HandlerFunc) http.HandlerFunc {
@@ -22,6 +22,7 @@ import (
 	"github.com/smallstep/certificates/acme"
 	"github.com/smallstep/certificates/authority/admin"
 	"github.com/smallstep/certificates/authority/admin/db/nosql"
+	"github.com/smallstep/certificates/authority/apitoken"
 	"github.com/smallstep/certificates/authority/provisioner"
 )
 
@@ -221,6 +222,82 @@ func TestHandler_extractAuthorizeTokenAdmin(t *testing.T) {
 	}
 }
 
+func TestHandler_extractAuthorizeScopedToken(t *testing.T) {
+	type test struct {
+		auth       adminAuthority
+		header     string
+		statusCode int
+		message    string
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/api-token": func(t *testing.T) test {
+			return test{
+				header: "Bearer stk_id.secret",
+				auth: &mockAdminAuthority{
+					MockAuthorizeAPIToken: func(token string, scope apitoken.Scope) (*linkedca.Admin, error) {
+						assert.Equals(t, "stk_id.secret", token)
+						assert.Equals(t, apitoken.ScopeInventoryRead, scope)
+						return nil, admin.NewError(admin.ErrorUnauthorizedType, "API token id does not have the inventory:read scope")
+					},
+				},
+				statusCode: 401,
+				message:    "API token id does not have the inventory:read scope",
+			}
+		},
+		"ok/api-token": func(t *testing.T) test {
+			return test{
+				header: "stk_id.secret",
+				auth: &mockAdminAuthority{
+					MockAuthorizeAPIToken: func(token string, scope apitoken.Scope) (*linkedca.Admin, error) {
+						assert.Equals(t, "stk_id.secret", token)
+						return &linkedca.Admin{Id: "api-token/id", Subject: "ci", Type: linkedca.Admin_ADMIN}, nil
+					},
+				},
+				statusCode: 200,
+			}
+		},
+		"ok/admin-token": func(t *testing.T) test {
+			return test{
+				header: "token",
+				auth: &mockAdminAuthority{
+					MockAuthorizeAdminToken: func(r *http.Request, token string) (*linkedca.Admin, error) {
+						assert.Equals(t, "token", token)
+						return &linkedca.Admin{Id: "adminID", Subject: "admin", Type: linkedca.Admin_SUPER_ADMIN}, nil
+					},
+				},
+				statusCode: 200,
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			mockMustAuthority(t, tc.auth)
+			req := httptest.NewRequest("GET", "/foo", http.NoBody)
+			req.Header.Set("Authorization", tc.header)
+			w := httptest.NewRecorder()
+			next := func(w http.ResponseWriter, r *http.Request) {
+				linkedca.MustAdminFromContext(r.Context())
+				w.Write(nil)
+			}
+			extractAuthorizeScopedToken(apitoken.ScopeInventoryRead, next)(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				err := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &err))
+				assert.Equals(t, tc.message, err.Message)
+			}
+		})
+	}
+}
+
 func TestHandler_loadProvisionerByName(t *testing.T) {
 	type test struct {
 		adminDB    admin.DB
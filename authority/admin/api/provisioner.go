@@ -11,12 +11,12 @@ import (
 	"go.step.sm/linkedca"
 
 	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/api/pagination"
 	"github.com/smallstep/certificates/api/read"
 	"github.com/smallstep/certificates/api/render"
 	"github.com/smallstep/certificates/authority"
 	"github.com/smallstep/certificates/authority/admin"
 	"github.com/smallstep/certificates/authority/provisioner"
-	"github.com/smallstep/certificates/errs"
 )
 
 // GetProvisionersResponse is the type for GET /admin/provisioners responses.
@@ -60,18 +60,19 @@ func GetProvisioner(w http.ResponseWriter, r *http.Request) {
 
 // GetProvisioners returns the given segment of  provisioners associated with the authority.
 func GetProvisioners(w http.ResponseWriter, r *http.Request) {
-	cursor, limit, err := api.ParseCursor(r)
+	opts, err := pagination.Parse(r, api.ProvisionerListFields, api.ProvisionerListFields)
 	if err != nil {
 		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err,
 			"error parsing cursor and limit from query params"))
 		return
 	}
 
-	p, next, err := mustAuthority(r.Context()).GetProvisioners(cursor, limit)
+	p, next, err := api.ListProvisioners(mustAuthority(r.Context()).GetProvisioners, opts)
 	if err != nil {
-		render.Error(w, errs.InternalServerErr(err))
+		render.Error(w, err)
 		return
 	}
+	pagination.SetLinkHeader(w, r, next)
 	render.JSON(w, &GetProvisionersResponse{
 		Provisioners: p,
 		NextCursor:   next,
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/report"
+)
+
+const (
+	defaultExpiringDays = 30
+	maxExpiringDays     = 3650
+)
+
+// ExpiringCertificatesResponse is the type for GET /admin/reports/expiring
+// responses.
+type ExpiringCertificatesResponse struct {
+	Days        int             `json:"days"`
+	GroupBy     string          `json:"groupBy"`
+	GeneratedAt time.Time       `json:"generatedAt"`
+	Count       int             `json:"count"`
+	Groups      []*report.Group `json:"groups"`
+}
+
+// GetExpiringCertificates returns the certificates that expire in the number
+// of days in the days query parameter, 30 by default, grouped by provisioner
+// or by domain. The groupBy query parameter selects the grouping, and the
+// format parameter the output, json or csv. Renewed certificates are not
+// included unless the superseded parameter is true.
+func GetExpiringCertificates(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	days := defaultExpiringDays
+	if v := query.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxExpiringDays {
+			render.Error(w, admin.NewError(admin.ErrorBadRequestType, "days must be a number between 0 and %d", maxExpiringDays))
+			return
+		}
+		days = n
+	}
+
+	groupBy := query.Get("groupBy")
+	switch groupBy {
+	case "":
+		groupBy = report.GroupByProvisioner
+	case report.GroupByProvisioner, report.GroupByDomain:
+	default:
+		render.Error(w, admin.NewError(admin.ErrorBadRequestType, "groupBy must be %s or %s", report.GroupByProvisioner, report.GroupByDomain))
+		return
+	}
+
+	format := query.Get("format")
+	switch format {
+	case "", "json", "csv":
+	default:
+		render.Error(w, admin.NewError(admin.ErrorBadRequestType, "format must be json or csv"))
+		return
+	}
+
+	var includeSuperseded bool
+	if v := query.Get("superseded"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			render.Error(w, admin.NewError(admin.ErrorBadRequestType, "superseded must be a boolean"))
+			return
+		}
+		includeSuperseded = b
+	}
+
+	certs, err := mustAuthority(r.Context()).GetExpiringCertificates(time.Duration(days)*24*time.Hour, includeSuperseded)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	groups := report.GroupCertificates(certs, groupBy)
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="expiring.csv"`)
+		if err := report.WriteCSV(w, groups); err != nil {
+			render.Error(w, admin.WrapErrorISE(err, "error writing csv report"))
+		}
+		return
+	}
+
+	render.JSON(w, &ExpiringCertificatesResponse{
+		Days:        days,
+		GroupBy:     groupBy,
+		GeneratedAt: time.Now().UTC(),
+		Count:       len(certs),
+		Groups:      groups,
+	})
+}
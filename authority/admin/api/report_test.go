@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/report"
+)
+
+func TestGetExpiringCertificates(t *testing.T) {
+	notAfter := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	certs := []*report.Certificate{
+		{SerialNumber: "1", Subject: "CN=a.example.com", SANs: []string{"a.example.com"}, NotAfter: notAfter, ProvisionerName: "acme", ProvisionerType: "ACME"},
+		{SerialNumber: "2", Subject: "CN=b.example.com", SANs: []string{"b.example.com"}, NotAfter: notAfter, ProvisionerName: "jwk", ProvisionerType: "JWK"},
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		auth        adminAuthority
+		statusCode  int
+		message     string
+		contentType string
+		body        string
+		groups      []string
+	}{
+		{"fail/days", "?days=foo", nil, 400, "days must be a number between 0 and 3650", "", "", nil},
+		{"fail/days negative", "?days=-1", nil, 400, "days must be a number between 0 and 3650", "", "", nil},
+		{"fail/groupBy", "?groupBy=foo", nil, 400, "groupBy must be provisioner or domain", "", "", nil},
+		{"fail/format", "?format=xml", nil, 400, "format must be json or csv", "", "", nil},
+		{"fail/superseded", "?superseded=foo", nil, 400, "superseded must be a boolean", "", "", nil},
+		{"fail/authority", "", &mockAdminAuthority{
+			MockGetExpiringCertificates: func(within time.Duration, includeSuperseded bool) ([]*report.Certificate, error) {
+				return nil, admin.NewError(admin.ErrorNotImplementedType, "not implemented")
+			},
+		}, 501, "not implemented", "", "", nil},
+		{"ok", "?days=7&superseded=true", &mockAdminAuthority{
+			MockGetExpiringCertificates: func(within time.Duration, includeSuperseded bool) ([]*report.Certificate, error) {
+				assert.Equals(t, 7*24*time.Hour, within)
+				assert.True(t, includeSuperseded)
+				return certs, nil
+			},
+		}, 200, "", "application/json", "", []string{"acme", "jwk"}},
+		{"ok/domain", "?groupBy=domain", &mockAdminAuthority{
+			MockGetExpiringCertificates: func(within time.Duration, includeSuperseded bool) ([]*report.Certificate, error) {
+				assert.Equals(t, 30*24*time.Hour, within)
+				assert.False(t, includeSuperseded)
+				return []*report.Certificate{}, nil
+			},
+		}, 200, "", "application/json", "", []string{}},
+		{"ok/csv", "?format=csv", &mockAdminAuthority{
+			MockRet1: certs,
+		}, 200, "", "text/csv; charset=utf-8", "group,serialNumber,subject,sans,notBefore,notAfter,provisionerName,provisionerType\n" +
+			"acme,1,CN=a.example.com,a.example.com,0001-01-01T00:00:00Z,2026-01-02T03:04:05Z,acme,ACME\n" +
+			"jwk,2,CN=b.example.com,b.example.com,0001-01-01T00:00:00Z,2026-01-02T03:04:05Z,jwk,JWK\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, tt.auth)
+			req := httptest.NewRequest("GET", "/admin/reports/expiring"+tt.query, http.NoBody)
+			w := httptest.NewRecorder()
+			GetExpiringCertificates(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tt.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tt.message, adminErr.Message)
+				return
+			}
+
+			assert.Equals(t, tt.contentType, res.Header.Get("Content-Type"))
+			if tt.body != "" {
+				assert.Equals(t, tt.body, string(body))
+				return
+			}
+
+			var resp ExpiringCertificatesResponse
+			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &resp))
+			names := []string{}
+			for _, g := range resp.Groups {
+				names = append(names, g.Name)
+			}
+			assert.Equals(t, tt.groups, names)
+		})
+	}
+}
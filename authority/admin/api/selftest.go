@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/smallstep/certificates/api/render"
+)
+
+// RunSelfTest signs a test certificate with the provisioner in the query, or
+// with the configured one, and checks it with the revocation endpoints. It
+// returns the result of each stage with a 503 status code if any of them
+// fails.
+func RunSelfTest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	report, err := mustAuthority(ctx).SelfTest(ctx, r.URL.Query().Get("provisioner"))
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	status := http.StatusOK
+	if !report.OK {
+		status = http.StatusServiceUnavailable
+	}
+	render.JSONStatus(w, report, status)
+}
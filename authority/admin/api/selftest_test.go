@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/admin"
+)
+
+func TestRunSelfTest(t *testing.T) {
+	tests := map[string]struct {
+		auth       adminAuthority
+		statusCode int
+	}{
+		"fail/not-configured": {
+			auth: &mockAdminAuthority{
+				MockSelfTest: func(ctx context.Context, name string) (*authority.SelfTestReport, error) {
+					return nil, admin.NewError(admin.ErrorBadRequestType, "self-test provisioner is not configured")
+				},
+			},
+			statusCode: 400,
+		},
+		"fail/stage": {
+			auth: &mockAdminAuthority{
+				MockSelfTest: func(ctx context.Context, name string) (*authority.SelfTestReport, error) {
+					return &authority.SelfTestReport{Provisioner: name, OK: false}, nil
+				},
+			},
+			statusCode: 503,
+		},
+		"ok": {
+			auth: &mockAdminAuthority{
+				MockSelfTest: func(ctx context.Context, name string) (*authority.SelfTestReport, error) {
+					assert.Equals(t, "self-test", name)
+					return &authority.SelfTestReport{Provisioner: name, OK: true}, nil
+				},
+			},
+			statusCode: 200,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockMustAuthority(t, tc.auth)
+			req := httptest.NewRequest("POST", "/selftest?provisioner=self-test", http.NoBody)
+			w := httptest.NewRecorder()
+			RunSelfTest(w, req)
+			assert.Equals(t, tc.statusCode, w.Result().StatusCode)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/settings"
+)
+
+// UpdateSettingsRequest is the type for PUT /admin/settings requests.
+type UpdateSettingsRequest struct {
+	Settings *settings.Settings `json:"settings"`
+	Comment  string             `json:"comment,omitempty"`
+}
+
+// RollbackSettingsRequest is the type for POST
+// /admin/settings/versions/{version}/rollback requests.
+type RollbackSettingsRequest struct {
+	Comment string `json:"comment,omitempty"`
+}
+
+// GetSettingsVersionsResponse is the type for GET /admin/settings/versions
+// responses.
+type GetSettingsVersionsResponse struct {
+	Versions []*settings.Version `json:"versions"`
+}
+
+func settingsVersion(r *http.Request) (int, error) {
+	s := chi.URLParam(r, "version")
+	v, err := strconv.Atoi(s)
+	if err != nil || v <= 0 {
+		return 0, admin.NewError(admin.ErrorBadRequestType, "version %q is not valid", s)
+	}
+	return v, nil
+}
+
+// GetSettings returns the current version of the authority settings.
+func GetSettings(w http.ResponseWriter, r *http.Request) {
+	v, err := mustAuthority(r.Context()).GetSettings()
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, v)
+}
+
+// UpdateSettings creates and applies a new version of the authority settings.
+func UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var body UpdateSettingsRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+
+	ctx := r.Context()
+	adm := linkedca.MustAdminFromContext(ctx)
+	v, err := mustAuthority(ctx).UpdateSettings(ctx, adm, body.Settings, body.Comment)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSONStatus(w, v, http.StatusCreated)
+}
+
+// GetSettingsVersions returns all the versions of the authority settings.
+func GetSettingsVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := mustAuthority(r.Context()).GetSettingsVersions()
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, &GetSettingsVersionsResponse{Versions: versions})
+}
+
+// GetSettingsVersion returns the version of the authority settings in the
+// path.
+func GetSettingsVersion(w http.ResponseWriter, r *http.Request) {
+	version, err := settingsVersion(r)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	v, err := mustAuthority(r.Context()).GetSettingsVersion(version)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, v)
+}
+
+// RollbackSettings creates and applies a new version of the authority
+// settings with the contents of the version in the path.
+func RollbackSettings(w http.ResponseWriter, r *http.Request) {
+	version, err := settingsVersion(r)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	var body RollbackSettingsRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+
+	ctx := r.Context()
+	adm := linkedca.MustAdminFromContext(ctx)
+	v, err := mustAuthority(ctx).RollbackSettings(ctx, adm, version, body.Comment)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSONStatus(w, v, http.StatusCreated)
+}
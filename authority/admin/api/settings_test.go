@@ -0,0 +1,172 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/settings"
+)
+
+func TestUpdateSettings(t *testing.T) {
+	adm := &linkedca.Admin{Id: "admin-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+
+	type test struct {
+		body       string
+		auth       adminAuthority
+		statusCode int
+		message    string
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/body": func(t *testing.T) test {
+			return test{
+				body:       "{",
+				statusCode: 400,
+				message:    "error reading request body: error decoding json: unexpected EOF",
+			}
+		},
+		"fail/update": func(t *testing.T) test {
+			return test{
+				body: `{"settings":{}}`,
+				auth: &mockAdminAuthority{
+					MockUpdateSettings: func(ctx context.Context, adm *linkedca.Admin, s *settings.Settings, comment string) (*settings.Version, error) {
+						return nil, admin.NewError(admin.ErrorBadRequestType, "invalid authority settings")
+					},
+				},
+				statusCode: 400,
+				message:    "invalid authority settings",
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				body: `{"settings":{"template":{"organization":"Smallstep"}},"comment":"new template"}`,
+				auth: &mockAdminAuthority{
+					MockUpdateSettings: func(ctx context.Context, a *linkedca.Admin, s *settings.Settings, comment string) (*settings.Version, error) {
+						assert.Equals(t, adm, a)
+						assert.Equals(t, &config.ASN1DN{Organization: "Smallstep"}, s.Template)
+						assert.Equals(t, "new template", comment)
+						return &settings.Version{Version: 2, Settings: s, Comment: comment}, nil
+					},
+				},
+				statusCode: 201,
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			mockMustAuthority(t, tc.auth)
+			ctx := linkedca.NewContextWithAdmin(context.Background(), adm)
+			req := httptest.NewRequest("PUT", "/foo", strings.NewReader(tc.body)).WithContext(ctx)
+			w := httptest.NewRecorder()
+			UpdateSettings(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tc.message, adminErr.Message)
+				return
+			}
+
+			var resp settings.Version
+			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &resp))
+			assert.Equals(t, 2, resp.Version)
+			assert.Equals(t, "Smallstep", resp.Settings.Template.Organization)
+		})
+	}
+}
+
+func TestRollbackSettings(t *testing.T) {
+	adm := &linkedca.Admin{Id: "admin-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+
+	type test struct {
+		version    string
+		auth       adminAuthority
+		statusCode int
+		message    string
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/version": func(t *testing.T) test {
+			return test{
+				version:    "foo",
+				statusCode: 400,
+				message:    `version "foo" is not valid`,
+			}
+		},
+		"fail/rollback": func(t *testing.T) test {
+			return test{
+				version: "5",
+				auth: &mockAdminAuthority{
+					MockRollbackSettings: func(ctx context.Context, adm *linkedca.Admin, version int, comment string) (*settings.Version, error) {
+						return nil, admin.NewError(admin.ErrorNotFoundType, "authority settings version %d not found", version)
+					},
+				},
+				statusCode: 404,
+				message:    "authority settings version 5 not found",
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				version: "1",
+				auth: &mockAdminAuthority{
+					MockRollbackSettings: func(ctx context.Context, a *linkedca.Admin, version int, comment string) (*settings.Version, error) {
+						assert.Equals(t, adm, a)
+						assert.Equals(t, 1, version)
+						assert.Equals(t, "revert", comment)
+						return &settings.Version{Version: 3, RollbackOf: 1, Settings: &settings.Settings{}}, nil
+					},
+				},
+				statusCode: 201,
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			mockMustAuthority(t, tc.auth)
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("version", tc.version)
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+			ctx = linkedca.NewContextWithAdmin(ctx, adm)
+			req := httptest.NewRequest("POST", "/foo", strings.NewReader(`{"comment":"revert"}`)).WithContext(ctx)
+			w := httptest.NewRecorder()
+			RollbackSettings(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tc.message, adminErr.Message)
+				return
+			}
+
+			var resp settings.Version
+			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &resp))
+			assert.Equals(t, 3, resp.Version)
+			assert.Equals(t, 1, resp.RollbackOf)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/pem"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/subca"
+)
+
+// CreateSubCARequest is the type for POST /admin/subca requests.
+type CreateSubCARequest struct {
+	CSR             string                 `json:"csr"`
+	MaxPathLen      int                    `json:"maxPathLen"`
+	NameConstraints *subca.NameConstraints `json:"nameConstraints,omitempty"`
+	Validity        provisioner.Duration   `json:"validity"`
+}
+
+// Validate validates a new subordinate CA request body.
+func (r *CreateSubCARequest) Validate() error {
+	if r.CSR == "" {
+		return admin.NewError(admin.ErrorBadRequestType, "csr cannot be empty")
+	}
+	return nil
+}
+
+// RejectSubCARequest is the type for POST /admin/subca/{id}/reject requests.
+type RejectSubCARequest struct {
+	Reason string `json:"reason"`
+}
+
+// SubCAResponse is the type for the subordinate CA requests responses. The
+// certificate request and the certificate are PEM encoded.
+type SubCAResponse struct {
+	*subca.Request
+	CSR         string `json:"csr"`
+	Certificate string `json:"certificate,omitempty"`
+}
+
+// GetSubCARequestsResponse is the type for GET /admin/subca responses.
+type GetSubCARequestsResponse struct {
+	Requests []*SubCAResponse `json:"requests"`
+}
+
+func newSubCAResponse(r *subca.Request) *SubCAResponse {
+	resp := &SubCAResponse{
+		Request: r,
+		CSR:     string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: r.CSR})),
+	}
+	if len(r.Certificate) > 0 {
+		resp.Certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: r.Certificate}))
+	}
+	return resp
+}
+
+// GetSubCARequests returns all the subordinate CA requests.
+func GetSubCARequests(w http.ResponseWriter, r *http.Request) {
+	requests, err := mustAuthority(r.Context()).GetSubCARequests()
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	resp := &GetSubCARequestsResponse{
+		Requests: make([]*SubCAResponse, len(requests)),
+	}
+	for i, req := range requests {
+		resp.Requests[i] = newSubCAResponse(req)
+	}
+	render.JSON(w, resp)
+}
+
+// GetSubCARequest returns the subordinate CA request with the id in the path.
+func GetSubCARequest(w http.ResponseWriter, r *http.Request) {
+	req, err := mustAuthority(r.Context()).GetSubCARequest(chi.URLParam(r, "id"))
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, newSubCAResponse(req))
+}
+
+// CreateSubCA creates a new subordinate CA request. The certificate is not
+// signed until the request is approved.
+func CreateSubCA(w http.ResponseWriter, r *http.Request) {
+	var body CreateSubCARequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+	block, _ := pem.Decode([]byte(body.CSR))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		render.Error(w, admin.NewError(admin.ErrorBadRequestType, "csr is not a valid PEM encoded certificate request"))
+		return
+	}
+
+	ctx := r.Context()
+	adm := linkedca.MustAdminFromContext(ctx)
+	req, err := mustAuthority(ctx).RequestSubCA(ctx, adm, &subca.Request{
+		CSR:             block.Bytes,
+		MaxPathLen:      body.MaxPathLen,
+		NameConstraints: body.NameConstraints,
+		Validity:        body.Validity,
+	})
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSONStatus(w, newSubCAResponse(req), http.StatusCreated)
+}
+
+// ApproveSubCA approves the subordinate CA request with the id in the path.
+// The certificate is signed with the last required approval.
+func ApproveSubCA(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adm := linkedca.MustAdminFromContext(ctx)
+	req, err := mustAuthority(ctx).ApproveSubCA(ctx, adm, chi.URLParam(r, "id"))
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, newSubCAResponse(req))
+}
+
+// RejectSubCA rejects the subordinate CA request with the id in the path.
+func RejectSubCA(w http.ResponseWriter, r *http.Request) {
+	var body RejectSubCARequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+
+	ctx := r.Context()
+	adm := linkedca.MustAdminFromContext(ctx)
+	req, err := mustAuthority(ctx).RejectSubCA(ctx, adm, chi.URLParam(r, "id"), body.Reason)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, newSubCAResponse(req))
+}
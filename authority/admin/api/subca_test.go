@@ -0,0 +1,247 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/x509util"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/subca"
+)
+
+func TestCreateSubCA(t *testing.T) {
+	_, priv, err := keyutil.GenerateDefaultKeyPair()
+	assert.FatalError(t, err)
+	csr, err := x509util.CreateCertificateRequest("Team CA", nil, priv.(crypto.Signer))
+	assert.FatalError(t, err)
+	csrPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr.Raw}))
+	adm := &linkedca.Admin{Id: "admin-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+
+	type test struct {
+		body       string
+		auth       adminAuthority
+		statusCode int
+		message    string
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/body": func(t *testing.T) test {
+			return test{
+				body:       "{",
+				statusCode: 400,
+				message:    "error reading request body: error decoding json: unexpected EOF",
+			}
+		},
+		"fail/validate": func(t *testing.T) test {
+			return test{
+				body:       "{}",
+				statusCode: 400,
+				message:    "csr cannot be empty",
+			}
+		},
+		"fail/pem": func(t *testing.T) test {
+			return test{
+				body:       `{"csr":"foo"}`,
+				statusCode: 400,
+				message:    "csr is not a valid PEM encoded certificate request",
+			}
+		},
+		"fail/request": func(t *testing.T) test {
+			body, err := json.Marshal(&CreateSubCARequest{CSR: csrPEM})
+			assert.FatalError(t, err)
+			return test{
+				body: string(body),
+				auth: &mockAdminAuthority{
+					MockRequestSubCA: func(ctx context.Context, adm *linkedca.Admin, r *subca.Request) (*subca.Request, error) {
+						return nil, admin.NewError(admin.ErrorBadRequestType, "invalid subordinate CA request")
+					},
+				},
+				statusCode: 400,
+				message:    "invalid subordinate CA request",
+			}
+		},
+		"ok": func(t *testing.T) test {
+			body, err := json.Marshal(&CreateSubCARequest{
+				CSR:             csrPEM,
+				MaxPathLen:      1,
+				NameConstraints: &subca.NameConstraints{PermittedDNSDomains: []string{"example.com"}},
+				Validity:        provisioner.Duration{Duration: time.Hour},
+			})
+			assert.FatalError(t, err)
+			return test{
+				body: string(body),
+				auth: &mockAdminAuthority{
+					MockRequestSubCA: func(ctx context.Context, a *linkedca.Admin, r *subca.Request) (*subca.Request, error) {
+						assert.Equals(t, adm, a)
+						assert.Equals(t, csr.Raw, r.CSR)
+						assert.Equals(t, 1, r.MaxPathLen)
+						assert.Equals(t, []string{"example.com"}, r.NameConstraints.PermittedDNSDomains)
+						assert.Equals(t, time.Hour, r.Validity.Duration)
+						r.ID = "request-id"
+						r.Status = subca.StatusPending
+						return r, nil
+					},
+				},
+				statusCode: 201,
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			mockMustAuthority(t, tc.auth)
+			ctx := linkedca.NewContextWithAdmin(context.Background(), adm)
+			req := httptest.NewRequest("POST", "/foo", strings.NewReader(tc.body)).WithContext(ctx)
+			w := httptest.NewRecorder()
+			CreateSubCA(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tc.message, adminErr.Message)
+				return
+			}
+
+			var resp SubCAResponse
+			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &resp))
+			assert.Equals(t, "request-id", resp.ID)
+			assert.Equals(t, subca.StatusPending, resp.Status)
+			assert.Equals(t, csrPEM, resp.CSR)
+			assert.Equals(t, "", resp.Certificate)
+		})
+	}
+}
+
+func TestApproveSubCA(t *testing.T) {
+	adm := &linkedca.Admin{Id: "admin-id", Subject: "bob", Type: linkedca.Admin_SUPER_ADMIN}
+
+	type test struct {
+		auth       adminAuthority
+		statusCode int
+		message    string
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/approve": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockApproveSubCA: func(ctx context.Context, adm *linkedca.Admin, id string) (*subca.Request, error) {
+						return nil, admin.NewError(admin.ErrorUnauthorizedType, "subordinate CA request %s cannot be approved by its requester", id)
+					},
+				},
+				statusCode: 401,
+				message:    "subordinate CA request request-id cannot be approved by its requester",
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockApproveSubCA: func(ctx context.Context, a *linkedca.Admin, id string) (*subca.Request, error) {
+						assert.Equals(t, adm, a)
+						assert.Equals(t, "request-id", id)
+						return &subca.Request{
+							ID:          id,
+							Status:      subca.StatusIssued,
+							Certificate: []byte("certificate"),
+						}, nil
+					},
+				},
+				statusCode: 200,
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			mockMustAuthority(t, tc.auth)
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("id", "request-id")
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+			ctx = linkedca.NewContextWithAdmin(ctx, adm)
+			req := httptest.NewRequest("POST", "/foo", http.NoBody).WithContext(ctx)
+			w := httptest.NewRecorder()
+			ApproveSubCA(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tc.message, adminErr.Message)
+				return
+			}
+
+			var resp SubCAResponse
+			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &resp))
+			assert.Equals(t, subca.StatusIssued, resp.Status)
+			assert.True(t, strings.HasPrefix(resp.Certificate, "-----BEGIN CERTIFICATE-----"))
+		})
+	}
+}
+
+func TestGetSubCARequests(t *testing.T) {
+	t.Run("fail", func(t *testing.T) {
+		mockMustAuthority(t, &mockAdminAuthority{
+			MockGetSubCARequests: func() ([]*subca.Request, error) {
+				return nil, admin.NewError(admin.ErrorNotImplementedType, "subordinate CA issuance is not enabled")
+			},
+		})
+		w := httptest.NewRecorder()
+		GetSubCARequests(w, httptest.NewRequest("GET", "/foo", http.NoBody))
+		assert.Equals(t, 501, w.Result().StatusCode)
+	})
+	t.Run("ok", func(t *testing.T) {
+		mockMustAuthority(t, &mockAdminAuthority{
+			MockGetSubCARequests: func() ([]*subca.Request, error) {
+				return []*subca.Request{{ID: "1"}, {ID: "2"}}, nil
+			},
+		})
+		w := httptest.NewRecorder()
+		GetSubCARequests(w, httptest.NewRequest("GET", "/foo", http.NoBody))
+		res := w.Result()
+		assert.Equals(t, 200, res.StatusCode)
+		var resp GetSubCARequestsResponse
+		assert.FatalError(t, json.NewDecoder(res.Body).Decode(&resp))
+		if assert.Len(t, 2, resp.Requests) {
+			assert.Equals(t, "1", resp.Requests[0].ID)
+			assert.Equals(t, "2", resp.Requests[1].ID)
+		}
+	})
+	t.Run("fail/get", func(t *testing.T) {
+		mockMustAuthority(t, &mockAdminAuthority{
+			MockErr: errors.New("force"),
+			MockGetSubCARequest: func(id string) (*subca.Request, error) {
+				return nil, admin.NewError(admin.ErrorNotFoundType, "subordinate CA request %s not found", id)
+			},
+		})
+		w := httptest.NewRecorder()
+		GetSubCARequest(w, httptest.NewRequest("GET", "/foo", http.NoBody))
+		assert.Equals(t, 404, w.Result().StatusCode)
+	})
+}
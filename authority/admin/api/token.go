@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/admin"
+)
+
+// IntrospectTokenRequest is the type for POST /admin/tokens/introspect
+// requests.
+type IntrospectTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// Validate validates an introspect token request body.
+func (r *IntrospectTokenRequest) Validate() error {
+	if r.Token == "" {
+		return admin.NewError(admin.ErrorBadRequestType, "token cannot be empty")
+	}
+	return nil
+}
+
+// IntrospectToken parses a provisioning token and reports the provisioner
+// that accepts it, the validations that fail and the names it authorizes.
+// The token is not marked as used.
+func IntrospectToken(w http.ResponseWriter, r *http.Request) {
+	var body IntrospectTokenRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	ti, err := mustAuthority(r.Context()).IntrospectToken(r.Context(), body.Token)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	render.JSON(w, ti)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/admin"
+)
+
+func TestIntrospectToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		auth       adminAuthority
+		statusCode int
+		message    string
+	}{
+		{"fail/body", "{", nil, 400, "error reading request body: error decoding json: unexpected EOF"},
+		{"fail/empty", `{"token":""}`, nil, 400, "token cannot be empty"},
+		{"fail/authority", `{"token":"foo"}`, &mockAdminAuthority{
+			MockIntrospectToken: func(ctx context.Context, token string) (*authority.TokenIntrospection, error) {
+				return nil, admin.NewError(admin.ErrorBadRequestType, "error parsing token")
+			},
+		}, 400, "error parsing token"},
+		{"ok", `{"token":"the-token"}`, &mockAdminAuthority{
+			MockIntrospectToken: func(ctx context.Context, token string) (*authority.TokenIntrospection, error) {
+				assert.Equals(t, "the-token", token)
+				return &authority.TokenIntrospection{
+					Valid:       true,
+					Provisioner: &authority.TokenProvisioner{Name: "jwk", Type: "JWK"},
+				}, nil
+			},
+		}, 200, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMustAuthority(t, tt.auth)
+			req := httptest.NewRequest("POST", "/admin/tokens/introspect", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			IntrospectToken(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tt.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+				assert.Equals(t, tt.message, adminErr.Message)
+				return
+			}
+
+			var ti authority.TokenIntrospection
+			assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &ti))
+			assert.True(t, ti.Valid)
+			assert.Equals(t, "jwk", ti.Provisioner.Name)
+		})
+	}
+}
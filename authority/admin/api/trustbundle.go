@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"github.com/smallstep/certificates/api/render"
+)
+
+// GetTrustBundle returns the root certificates in the format in the URL, ready
+// to be installed in the trust store of a device: mobileconfig for a macOS or
+// iOS configuration profile, p7b for Windows, or sh for a script that installs
+// them in a Linux system.
+func GetTrustBundle(w http.ResponseWriter, r *http.Request) {
+	format := chi.URLParam(r, "format")
+
+	bundle, err := mustAuthority(r.Context()).GetTrustBundle(format)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", bundle.ContentType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(bundle.Filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(bundle.Data)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(bundle.Data)
+}
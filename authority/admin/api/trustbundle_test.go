@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/trustbundle"
+)
+
+func TestGetTrustBundle(t *testing.T) {
+	mockMustAuthority(t, &mockAdminAuthority{
+		MockGetTrustBundle: func(format string) (*trustbundle.Bundle, error) {
+			if format != trustbundle.P7B {
+				return nil, admin.NewError(admin.ErrorBadRequestType, "trust bundle format %q is not supported", format)
+			}
+			return &trustbundle.Bundle{
+				Filename:    "step-ca.p7b",
+				ContentType: "application/x-pkcs7-certificates",
+				Data:        []byte("p7b"),
+			}, nil
+		},
+	})
+
+	tests := []struct {
+		name       string
+		format     string
+		statusCode int
+	}{
+		{"ok", "p7b", 200},
+		{"fail/format", "pem", 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("format", tt.format)
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+			req := httptest.NewRequest("GET", "/foo", http.NoBody).WithContext(ctx)
+			w := httptest.NewRecorder()
+			GetTrustBundle(w, req)
+			res := w.Result()
+			assert.Equals(t, tt.statusCode, res.StatusCode)
+			if tt.statusCode != 200 {
+				return
+			}
+			body, err := io.ReadAll(res.Body)
+			assert.FatalError(t, err)
+			assert.Equals(t, []byte("p7b"), body)
+			assert.Equals(t, "application/x-pkcs7-certificates", res.Header.Get("Content-Type"))
+			assert.Equals(t, `attachment; filename="step-ca.p7b"`, res.Header.Get("Content-Disposition"))
+		})
+	}
+}
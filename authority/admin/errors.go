@@ -7,6 +7,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/errs"
 )
 
 // ProblemType is the type of the Admin problem.
@@ -61,49 +62,58 @@ type errorMetadata struct {
 	details string
 	status  int
 	typ     string
+	code    errs.Code
 	String  string
 }
 
 var (
 	errorServerInternalMetadata = errorMetadata{
 		typ:     ErrorServerInternalType.String(),
+		code:    errs.CodeServerInternal,
 		details: "the server experienced an internal error",
 		status:  http.StatusInternalServerError,
 	}
 	errorMap = map[ProblemType]errorMetadata{
 		ErrorNotFoundType: {
 			typ:     ErrorNotFoundType.String(),
+			code:    errs.CodeNotFound,
 			details: "resource not found",
 			status:  http.StatusNotFound,
 		},
 		ErrorAuthorityMismatchType: {
 			typ:     ErrorAuthorityMismatchType.String(),
+			code:    "authorityMismatch",
 			details: "resource not owned by authority",
 			status:  http.StatusUnauthorized,
 		},
 		ErrorDeletedType: {
 			typ:     ErrorDeletedType.String(),
+			code:    "deleted",
 			details: "resource is deleted",
 			status:  http.StatusNotFound,
 		},
 		ErrorNotImplementedType: {
 			typ:     ErrorNotImplementedType.String(),
+			code:    errs.CodeNotImplemented,
 			details: "not implemented",
 			status:  http.StatusNotImplemented,
 		},
 		ErrorBadRequestType: {
 			typ:     ErrorBadRequestType.String(),
+			code:    errs.CodeBadRequest,
 			details: "bad request",
 			status:  http.StatusBadRequest,
 		},
 		ErrorUnauthorizedType: {
 			typ:     ErrorUnauthorizedType.String(),
+			code:    errs.CodeUnauthorized,
 			details: "unauthorized",
 			status:  http.StatusUnauthorized,
 		},
 		ErrorServerInternalType: errorServerInternalMetadata,
 		ErrorConflictType: {
 			typ:     ErrorConflictType.String(),
+			code:    errs.CodeConflict,
 			details: "conflict",
 			status:  http.StatusConflict,
 		},
@@ -112,11 +122,12 @@ var (
 
 // Error represents an Admin error
 type Error struct {
-	Type    string `json:"type"`
-	Detail  string `json:"detail"`
-	Message string `json:"message"`
-	Err     error  `json:"-"`
-	Status  int    `json:"-"`
+	Type    string    `json:"type"`
+	Code    errs.Code `json:"code,omitempty"`
+	Detail  string    `json:"detail"`
+	Message string    `json:"message"`
+	Err     error     `json:"-"`
+	Status  int       `json:"-"`
 }
 
 // IsType returns true if the error type matches the input type.
@@ -135,6 +146,7 @@ func newError(pt ProblemType, err error) *Error {
 		meta = errorServerInternalMetadata
 		return &Error{
 			Type:   meta.typ,
+			Code:   meta.code,
 			Detail: meta.details,
 			Status: meta.status,
 			Err:    err,
@@ -143,6 +155,7 @@ func newError(pt ProblemType, err error) *Error {
 
 	return &Error{
 		Type:   meta.typ,
+		Code:   meta.code,
 		Detail: meta.details,
 		Status: meta.status,
 		Err:    err,
@@ -182,6 +195,15 @@ func (e *Error) StatusCode() int {
 	return e.Status
 }
 
+// ErrorCode implements the errs.Coder interface and returns the code of the
+// error.
+func (e *Error) ErrorCode() errs.Code {
+	if e.Code != "" {
+		return e.Code
+	}
+	return errs.CodeFromStatus(e.Status)
+}
+
 // Error allows AError to implement the error interface.
 func (e *Error) Error() string {
 	return e.Err.Error()
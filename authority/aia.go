@@ -0,0 +1,59 @@
+package authority
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+
+	"github.com/smallstep/certificates/errs"
+)
+
+var (
+	oidExtensionAuthorityInfoAccess   = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 1}
+	oidExtensionCRLDistributionPoints = asn1.ObjectIdentifier{2, 5, 29, 31}
+)
+
+// addAuthorityInformationAccess adds the configured caIssuers, OCSP and CRL
+// distribution points URLs to the given certificate. The URLs are not added
+// if the template already defines them, either using the certificate fields
+// or as an extension. If the OCSP responses are enabled, the OCSP URL
+// defaults to the responder of the authority.
+func (a *Authority) addAuthorityInformationAccess(leaf *x509.Certificate) {
+	cfg := a.config.AIA
+	if cfg == nil {
+		return
+	}
+
+	var hasAIA, hasCDP bool
+	for _, ext := range leaf.ExtraExtensions {
+		switch {
+		case ext.Id.Equal(oidExtensionAuthorityInfoAccess):
+			hasAIA = true
+		case ext.Id.Equal(oidExtensionCRLDistributionPoints):
+			hasCDP = true
+		}
+	}
+
+	if !hasAIA && len(leaf.IssuingCertificateURL) == 0 && len(leaf.OCSPServer) == 0 {
+		leaf.IssuingCertificateURL = cfg.CAIssuers
+		leaf.OCSPServer = cfg.OCSP
+		if len(leaf.OCSPServer) == 0 && a.config.OCSP.IsEnabled() && len(a.config.DNSNames) > 0 {
+			leaf.OCSPServer = []string{a.config.Audience("/1.0/ocsp")[0]}
+		}
+	}
+	if !hasCDP && len(leaf.CRLDistributionPoints) == 0 {
+		leaf.CRLDistributionPoints = cfg.CRLDistributionPoints
+		if len(leaf.CRLDistributionPoints) == 0 && a.config.CRL.IsEnabled() && a.config.CRL.IDPurl != "" {
+			leaf.CRLDistributionPoints = []string{a.config.CRL.IDPurl}
+		}
+	}
+}
+
+// GetIssuerCertificate returns the certificate of the intermediate CA that
+// signs the X.509 certificates. This certificate is served at the caIssuers
+// URL of the authority information access extension.
+func (a *Authority) GetIssuerCertificate() (*x509.Certificate, error) {
+	if len(a.intermediateX509Certs) == 0 {
+		return nil, errs.NotFound("issuer certificate not found")
+	}
+	return a.intermediateX509Certs[0], nil
+}
@@ -0,0 +1,72 @@
+package authority
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/smallstep/assert"
+
+	"github.com/smallstep/certificates/authority/config"
+)
+
+func TestAuthority_addAuthorityInformationAccess(t *testing.T) {
+	aia := &config.AIAConfig{
+		CAIssuers:             []string{"https://ca.example.com/issuer"},
+		OCSP:                  []string{"http://ocsp.example.com"},
+		CRLDistributionPoints: []string{"https://ca.example.com/crl"},
+	}
+	crl := &config.CRLConfig{Enabled: true, IDPurl: "https://ca.example.com/1.0/crl"}
+	ocsp := &config.OCSPConfig{Enabled: true}
+
+	tests := []struct {
+		name                      string
+		aia                       *config.AIAConfig
+		crl                       *config.CRLConfig
+		ocsp                      *config.OCSPConfig
+		leaf                      *x509.Certificate
+		wantIssuingCertificateURL []string
+		wantOCSPServer            []string
+		wantCRLDistributionPoints []string
+	}{
+		{"ok", aia, nil, ocsp, &x509.Certificate{}, aia.CAIssuers, aia.OCSP, aia.CRLDistributionPoints},
+		{"ok/disabled", nil, crl, ocsp, &x509.Certificate{}, nil, nil, nil},
+		{"ok/crl idpURL", &config.AIAConfig{CAIssuers: aia.CAIssuers}, crl, nil, &x509.Certificate{}, aia.CAIssuers, nil, []string{crl.IDPurl}},
+		{"ok/ocsp responder", &config.AIAConfig{CAIssuers: aia.CAIssuers}, nil, ocsp, &x509.Certificate{}, aia.CAIssuers, []string{"https://ca.example.com/1.0/ocsp"}, nil},
+		{"ok/crl disabled", &config.AIAConfig{}, &config.CRLConfig{IDPurl: crl.IDPurl}, nil, &x509.Certificate{}, nil, nil, nil},
+		{"ok/template", aia, nil, ocsp, &x509.Certificate{
+			OCSPServer:            []string{"http://other.example.com"},
+			CRLDistributionPoints: []string{"http://other.example.com/crl"},
+		}, nil, []string{"http://other.example.com"}, []string{"http://other.example.com/crl"}},
+		{"ok/extensions", aia, nil, ocsp, &x509.Certificate{
+			ExtraExtensions: []pkix.Extension{
+				{Id: oidExtensionAuthorityInfoAccess},
+				{Id: oidExtensionCRLDistributionPoints},
+			},
+		}, nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Authority{config: &config.Config{
+				DNSNames: []string{"ca.example.com"},
+				AIA:      tt.aia,
+				CRL:      tt.crl,
+				OCSP:     tt.ocsp,
+			}}
+			a.addAuthorityInformationAccess(tt.leaf)
+			assert.Equals(t, tt.wantIssuingCertificateURL, tt.leaf.IssuingCertificateURL)
+			assert.Equals(t, tt.wantOCSPServer, tt.leaf.OCSPServer)
+			assert.Equals(t, tt.wantCRLDistributionPoints, tt.leaf.CRLDistributionPoints)
+		})
+	}
+}
+
+func TestAuthority_GetIssuerCertificate(t *testing.T) {
+	a := testAuthority(t)
+	crt, err := a.GetIssuerCertificate()
+	assert.FatalError(t, err)
+	assert.Equals(t, a.intermediateX509Certs[0], crt)
+
+	_, err = (&Authority{}).GetIssuerCertificate()
+	assert.Error(t, err)
+}
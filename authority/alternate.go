@@ -0,0 +1,24 @@
+package authority
+
+import (
+	"bytes"
+	"crypto/x509"
+)
+
+// GetAlternateChains returns the configured alternate chains that can be used
+// with the given certificate, like the chain with the intermediate
+// cross-signed by another root. The first certificate of an alternate chain
+// must have signed the given certificate. The chains do not include the
+// given certificate.
+func (a *Authority) GetAlternateChains(crt *x509.Certificate) [][]*x509.Certificate {
+	var chains [][]*x509.Certificate
+	for _, chain := range a.alternateX509Chains {
+		if len(chain) == 0 || !bytes.Equal(crt.RawIssuer, chain[0].RawSubject) {
+			continue
+		}
+		if err := crt.CheckSignatureFrom(chain[0]); err == nil {
+			chains = append(chains, chain)
+		}
+	}
+	return chains
+}
@@ -0,0 +1,39 @@
+package authority
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/keyutil"
+
+	casapi "github.com/smallstep/certificates/cas/apiv1"
+)
+
+func TestAuthority_GetAlternateChains(t *testing.T) {
+	a := testAuthority(t)
+	issuer, err := a.GetIssuerCertificate()
+	assert.FatalError(t, err)
+
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+	resp, err := a.x509CAService.CreateCertificate(&casapi.CreateCertificateRequest{
+		Template: &x509.Certificate{
+			Subject:   pkix.Name{CommonName: "leaf"},
+			PublicKey: signer.Public(),
+		},
+		Lifetime: time.Hour,
+	})
+	assert.FatalError(t, err)
+
+	assert.Len(t, 0, a.GetAlternateChains(resp.Certificate))
+
+	a.alternateX509Chains = [][]*x509.Certificate{
+		a.rootX509Certs,
+		{issuer, a.rootX509Certs[0]},
+		{},
+	}
+	assert.Equals(t, [][]*x509.Certificate{{issuer, a.rootX509Certs[0]}}, a.GetAlternateChains(resp.Certificate))
+}
@@ -0,0 +1,178 @@
+package authority
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/apitoken"
+)
+
+// APITokenOptions are the options used to create an API token.
+type APITokenOptions struct {
+	// Name is a description of the token, it's used as the subject of the
+	// requests authorized by the token.
+	Name string
+	// Scopes are the permissions granted to the token.
+	Scopes []apitoken.Scope
+	// ExpiresAt is the expiration of the token. Tokens without expiration
+	// are valid until they are revoked.
+	ExpiresAt time.Time
+}
+
+// initAPITokens creates the store of the API tokens. The tokens are kept in
+// memory if the database is not configured. The administration API cannot be
+// enabled with a database that cannot store them, the tokens would be lost on
+// restarts and they would be different on every instance.
+func (a *Authority) initAPITokens() (err error) {
+	if a.apiTokenStore != nil {
+		return nil
+	}
+	ndb, ok := nosqlDB(a.db)
+	switch {
+	case ok:
+		a.apiTokenStore, err = apitoken.NewNoSQLStore(ndb)
+		return err
+	case !a.config.AuthorityConfig.EnableAdmin:
+		a.apiTokenStore = apitoken.NewMemoryStore()
+	case noDatabase(a.db):
+		a.initLogf("The administration API is enabled without a database, API tokens will be kept in memory")
+		a.apiTokenStore = apitoken.NewMemoryStore()
+	default:
+		return errors.New("API tokens are not supported with the configured database")
+	}
+	return nil
+}
+
+func (a *Authority) getAPIToken(id string) (*apitoken.Token, error) {
+	t, err := a.apiTokenStore.Get(id)
+	if err != nil {
+		if errors.Is(err, apitoken.ErrNotFound) {
+			return nil, admin.NewError(admin.ErrorNotFoundType, "API token %s not found", id)
+		}
+		return nil, admin.WrapErrorISE(err, "error loading API token")
+	}
+	return t, nil
+}
+
+// CreateAPIToken creates a new API token. It returns the token and its
+// secret, the secret is not stored and it cannot be recovered. Only super
+// admins can create API tokens.
+func (a *Authority) CreateAPIToken(_ context.Context, adm *linkedca.Admin, opts APITokenOptions) (*apitoken.Token, string, error) {
+	if adm.GetType() != linkedca.Admin_SUPER_ADMIN {
+		return nil, "", admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to create API tokens")
+	}
+	t, secret, err := apitoken.New(opts.Name, opts.Scopes, opts.ExpiresAt, adm.GetId())
+	if err != nil {
+		return nil, "", admin.WrapError(admin.ErrorBadRequestType, err, "invalid API token")
+	}
+	if err := a.apiTokenStore.Save(t); err != nil {
+		return nil, "", admin.WrapErrorISE(err, "error storing API token")
+	}
+	return t.Public(), secret, nil
+}
+
+// GetAPITokens returns all the API tokens, including the revoked ones.
+func (a *Authority) GetAPITokens() ([]*apitoken.Token, error) {
+	tokens, err := a.apiTokenStore.List()
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error listing API tokens")
+	}
+	for i, t := range tokens {
+		tokens[i] = t.Public()
+	}
+	return tokens, nil
+}
+
+// RotateAPIToken replaces the secret of an API token and returns the new one.
+// The previous secret is valid during the given grace period, so the clients
+// can be updated without downtime. Only super admins can rotate API tokens.
+func (a *Authority) RotateAPIToken(_ context.Context, adm *linkedca.Admin, id string, gracePeriod time.Duration) (*apitoken.Token, string, error) {
+	if adm.GetType() != linkedca.Admin_SUPER_ADMIN {
+		return nil, "", admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to rotate API tokens")
+	}
+	if gracePeriod < 0 {
+		return nil, "", admin.NewError(admin.ErrorBadRequestType, "grace period cannot be negative")
+	}
+
+	a.apiTokenMutex.Lock()
+	defer a.apiTokenMutex.Unlock()
+
+	t, err := a.getAPIToken(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if t.IsRevoked() {
+		return nil, "", admin.NewError(admin.ErrorBadRequestType, "API token %s has been revoked", id)
+	}
+	secret, err := t.Rotate(gracePeriod)
+	if err != nil {
+		return nil, "", admin.WrapErrorISE(err, "error rotating API token")
+	}
+	if err := a.apiTokenStore.Save(t); err != nil {
+		return nil, "", admin.WrapErrorISE(err, "error storing API token")
+	}
+	return t.Public(), secret, nil
+}
+
+// RevokeAPIToken revokes an API token. Revoked tokens are kept, so they can
+// be listed. Only super admins can revoke API tokens.
+func (a *Authority) RevokeAPIToken(_ context.Context, adm *linkedca.Admin, id string) (*apitoken.Token, error) {
+	if adm.GetType() != linkedca.Admin_SUPER_ADMIN {
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to revoke API tokens")
+	}
+
+	a.apiTokenMutex.Lock()
+	defer a.apiTokenMutex.Unlock()
+
+	t, err := a.getAPIToken(id)
+	if err != nil {
+		return nil, err
+	}
+	if t.IsRevoked() {
+		return nil, admin.NewError(admin.ErrorConflictType, "API token %s has already been revoked", id)
+	}
+	t.Revoke(adm.GetId())
+	if err := a.apiTokenStore.Save(t); err != nil {
+		return nil, admin.WrapErrorISE(err, "error storing API token")
+	}
+	return t.Public(), nil
+}
+
+// AuthorizeAPIToken validates an API token and checks that it has been
+// granted the given scope. It returns an admin that represents the token, it
+// is never a super admin.
+func (a *Authority) AuthorizeAPIToken(token string, scope apitoken.Scope) (*linkedca.Admin, error) {
+	id, secret, err := apitoken.Parse(token)
+	if err != nil {
+		return nil, admin.WrapError(admin.ErrorUnauthorizedType, err, "error parsing API token")
+	}
+	t, err := a.apiTokenStore.Get(id)
+	switch {
+	case errors.Is(err, apitoken.ErrNotFound):
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "invalid API token")
+	case err != nil:
+		return nil, admin.WrapErrorISE(err, "error loading API token")
+	}
+
+	now := time.Now()
+	switch {
+	case !t.Verify(secret, now):
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "invalid API token")
+	case t.IsRevoked():
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "API token %s has been revoked", id)
+	case t.IsExpired(now):
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "API token %s has expired", id)
+	case !t.HasScope(scope):
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "API token %s does not have the %s scope", id, scope)
+	}
+
+	return &linkedca.Admin{
+		Id:      "api-token/" + t.ID,
+		Subject: t.Name,
+		Type:    linkedca.Admin_ADMIN,
+	}, nil
+}
@@ -0,0 +1,208 @@
+// Package apitoken implements the long-lived API tokens used to access a
+// subset of the administration API without an admin credential.
+//
+// An API token is an opaque bearer token granted a list of scopes. Only the
+// SHA-256 hash of its secret is stored. Tokens can be rotated, optionally
+// keeping the previous secret valid for a grace period, and revoked.
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/randutil"
+)
+
+// Prefix is the prefix of all the API tokens. It's used to distinguish API
+// tokens from the tokens of the admins.
+const Prefix = "stk_"
+
+// secretSize is the number of random bytes of a secret.
+const secretSize = 32
+
+// ErrNotFound is the error returned by the stores if a token does not exist.
+var ErrNotFound = errors.New("API token not found")
+
+// Scope is a permission granted to an API token.
+type Scope string
+
+const (
+	// ScopeInventoryRead grants read access to the certificates, reports and
+	// dashboards.
+	ScopeInventoryRead Scope = "inventory:read"
+	// ScopeAuditRead grants read access to the audit log.
+	ScopeAuditRead Scope = "audit:read"
+	// ScopeEABRead grants read access to the ACME External Account Binding
+	// keys.
+	ScopeEABRead Scope = "eab:read"
+	// ScopeEABWrite grants access to create and delete ACME External Account
+	// Binding keys. It includes ScopeEABRead.
+	ScopeEABWrite Scope = "eab:write"
+	// ScopeTrustBundleRead grants access to download the bundles used to
+	// install the root certificates.
+	ScopeTrustBundleRead Scope = "trust-bundle:read"
+)
+
+// Scopes is the list of supported scopes.
+var Scopes = []Scope{ScopeInventoryRead, ScopeAuditRead, ScopeEABRead, ScopeEABWrite, ScopeTrustBundleRead}
+
+// Validate returns an error if the scope is not supported.
+func (s Scope) Validate() error {
+	for _, v := range Scopes {
+		if s == v {
+			return nil
+		}
+	}
+	return errors.Errorf("scope %q is not supported", s)
+}
+
+// Token is an API token. The hashes of the secrets are never returned by the
+// authority.
+type Token struct {
+	ID                string    `json:"id"`
+	Name              string    `json:"name"`
+	Scopes            []Scope   `json:"scopes"`
+	Hash              []byte    `json:"hash,omitempty"`
+	PreviousHash      []byte    `json:"previousHash,omitempty"`
+	PreviousExpiresAt time.Time `json:"previousExpiresAt,omitempty"`
+	CreatedBy         string    `json:"createdBy"`
+	CreatedAt         time.Time `json:"createdAt"`
+	ExpiresAt         time.Time `json:"expiresAt,omitempty"`
+	RotatedAt         time.Time `json:"rotatedAt,omitempty"`
+	RevokedBy         string    `json:"revokedBy,omitempty"`
+	RevokedAt         time.Time `json:"revokedAt,omitempty"`
+}
+
+// New creates a new token with the given name and scopes. It returns the token
+// and its secret in the format used in the Authorization header. If expiresAt
+// is zero the token does not expire.
+func New(name string, scopes []Scope, expiresAt time.Time, createdBy string) (*Token, string, error) {
+	if name == "" {
+		return nil, "", errors.New("name cannot be empty")
+	}
+	if len(scopes) == 0 {
+		return nil, "", errors.New("scopes cannot be empty")
+	}
+	for _, s := range scopes {
+		if err := s.Validate(); err != nil {
+			return nil, "", err
+		}
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	if !expiresAt.IsZero() && !expiresAt.After(now) {
+		return nil, "", errors.New("expiresAt must be in the future")
+	}
+	id, err := randutil.UUIDv4()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "error generating API token id")
+	}
+	t := &Token{
+		ID:        id,
+		Name:      name,
+		Scopes:    scopes,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: expiresAt.UTC(),
+	}
+	secret, err := t.newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	return t, secret, nil
+}
+
+// Rotate replaces the secret of the token and returns the new one. The
+// previous secret stays valid for the given grace period.
+func (t *Token) Rotate(gracePeriod time.Duration) (string, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	prev := t.Hash
+	secret, err := t.newSecret()
+	if err != nil {
+		return "", err
+	}
+	t.RotatedAt = now
+	t.PreviousHash, t.PreviousExpiresAt = nil, time.Time{}
+	if gracePeriod > 0 {
+		t.PreviousHash = prev
+		t.PreviousExpiresAt = now.Add(gracePeriod)
+	}
+	return secret, nil
+}
+
+// Revoke revokes the token.
+func (t *Token) Revoke(revokedBy string) {
+	t.RevokedBy = revokedBy
+	t.RevokedAt = time.Now().UTC().Truncate(time.Second)
+	t.PreviousHash, t.PreviousExpiresAt = nil, time.Time{}
+}
+
+// IsRevoked returns if the token has been revoked.
+func (t *Token) IsRevoked() bool {
+	return !t.RevokedAt.IsZero()
+}
+
+// IsExpired returns if the token is expired at the given time.
+func (t *Token) IsExpired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// HasScope returns if the token has been granted the given scope.
+func (t *Token) HasScope(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope || (s == ScopeEABWrite && scope == ScopeEABRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify returns if the given secret is the current secret of the token, or
+// the previous one during the grace period of a rotation.
+func (t *Token) Verify(secret string, now time.Time) bool {
+	sum := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(sum[:], t.Hash) == 1 {
+		return true
+	}
+	return len(t.PreviousHash) > 0 && now.Before(t.PreviousExpiresAt) &&
+		subtle.ConstantTimeCompare(sum[:], t.PreviousHash) == 1
+}
+
+// Public returns a copy of the token without the hashes of the secrets.
+func (t *Token) Public() *Token {
+	c := *t
+	c.Hash, c.PreviousHash = nil, nil
+	return &c
+}
+
+func (t *Token) newSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "error generating API token secret")
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+	sum := sha256.Sum256([]byte(secret))
+	t.Hash = sum[:]
+	return Prefix + t.ID + "." + secret, nil
+}
+
+// IsToken returns if the given value looks like an API token.
+func IsToken(s string) bool {
+	return strings.HasPrefix(s, Prefix)
+}
+
+// Parse splits an API token into its id and secret.
+func Parse(s string) (id, secret string, err error) {
+	if !IsToken(s) {
+		return "", "", errors.New("invalid API token")
+	}
+	id, secret, ok := strings.Cut(strings.TrimPrefix(s, Prefix), ".")
+	if !ok || id == "" || secret == "" {
+		return "", "", errors.New("invalid API token")
+	}
+	return id, secret, nil
+}
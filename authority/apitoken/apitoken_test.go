@@ -0,0 +1,116 @@
+package apitoken
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tok, secret, err := New("ci", []Scope{ScopeInventoryRead}, time.Time{}, "admin-id")
+	require.NoError(t, err)
+	assert.True(t, IsToken(secret))
+	id, s, err := Parse(secret)
+	require.NoError(t, err)
+	assert.Equal(t, tok.ID, id)
+	assert.True(t, tok.Verify(s, time.Now()))
+	assert.False(t, tok.Verify(s+"x", time.Now()))
+	assert.Equal(t, "admin-id", tok.CreatedBy)
+	assert.False(t, tok.IsExpired(time.Now().Add(100*365*24*time.Hour)))
+
+	for name, fn := range map[string]func() (*Token, string, error){
+		"empty name":   func() (*Token, string, error) { return New("", []Scope{ScopeAuditRead}, time.Time{}, "") },
+		"empty scopes": func() (*Token, string, error) { return New("ci", nil, time.Time{}, "") },
+		"bad scope":    func() (*Token, string, error) { return New("ci", []Scope{"admin"}, time.Time{}, "") },
+		"expired": func() (*Token, string, error) {
+			return New("ci", []Scope{ScopeAuditRead}, time.Now().Add(-time.Minute), "")
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := fn()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{"", "foo", Prefix, Prefix + "id", Prefix + ".secret", Prefix + "id."} {
+		_, _, err := Parse(s)
+		assert.Error(t, err, s)
+	}
+	id, secret, err := Parse(Prefix + "id.sec.ret")
+	require.NoError(t, err)
+	assert.Equal(t, "id", id)
+	assert.Equal(t, "sec.ret", secret)
+}
+
+func TestToken_Rotate(t *testing.T) {
+	tok, secret, err := New("ci", []Scope{ScopeEABWrite}, time.Now().Add(time.Hour), "")
+	require.NoError(t, err)
+	_, old, err := Parse(secret)
+	require.NoError(t, err)
+
+	// Without grace period the old secret is no longer valid.
+	secret, err = tok.Rotate(0)
+	require.NoError(t, err)
+	_, current, err := Parse(secret)
+	require.NoError(t, err)
+	assert.False(t, tok.Verify(old, time.Now()))
+	assert.True(t, tok.Verify(current, time.Now()))
+
+	// With grace period the previous secret is valid until it ends.
+	secret, err = tok.Rotate(time.Hour)
+	require.NoError(t, err)
+	_, next, err := Parse(secret)
+	require.NoError(t, err)
+	assert.True(t, tok.Verify(next, time.Now()))
+	assert.True(t, tok.Verify(current, time.Now()))
+	assert.False(t, tok.Verify(current, time.Now().Add(2*time.Hour)))
+	assert.False(t, tok.RotatedAt.IsZero())
+
+	pub := tok.Public()
+	assert.Nil(t, pub.Hash)
+	assert.Nil(t, pub.PreviousHash)
+	assert.NotNil(t, tok.Hash)
+
+	tok.Revoke("admin-id")
+	assert.True(t, tok.IsRevoked())
+	assert.Equal(t, "admin-id", tok.RevokedBy)
+	assert.False(t, tok.Verify(current, time.Now()))
+}
+
+func TestToken_HasScope(t *testing.T) {
+	tok := &Token{Scopes: []Scope{ScopeInventoryRead, ScopeEABWrite}}
+	assert.True(t, tok.HasScope(ScopeInventoryRead))
+	assert.True(t, tok.HasScope(ScopeEABRead))
+	assert.True(t, tok.HasScope(ScopeEABWrite))
+	assert.False(t, tok.HasScope(ScopeAuditRead))
+
+	tok = &Token{Scopes: []Scope{ScopeEABRead}}
+	assert.False(t, tok.HasScope(ScopeEABWrite))
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	_, err := s.Get("token-id")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	now := time.Now().UTC()
+	t1 := &Token{ID: "b", Name: "first", Scopes: []Scope{ScopeAuditRead}, CreatedAt: now}
+	t2 := &Token{ID: "a", Name: "second", Scopes: []Scope{ScopeAuditRead}, CreatedAt: now.Add(time.Second)}
+	require.NoError(t, s.Save(t2))
+	require.NoError(t, s.Save(t1))
+
+	got, err := s.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, "second", got.Name)
+
+	list, err := s.List()
+	require.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "b", list[0].ID)
+		assert.Equal(t, "a", list[1].ID)
+	}
+}
@@ -0,0 +1,146 @@
+package apitoken
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/nosql"
+	"github.com/smallstep/nosql/database"
+)
+
+var tokensTable = []byte("api_tokens")
+
+// Store is the interface used to persist the API tokens.
+type Store interface {
+	Save(t *Token) error
+	Get(id string) (*Token, error)
+	List() ([]*Token, error)
+}
+
+// MemoryStore is a Store that keeps the tokens in memory. It is used when
+// the authority does not have a database.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	tokens map[string][]byte
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tokens: make(map[string][]byte),
+	}
+}
+
+// Save implements the Store interface.
+func (s *MemoryStore) Save(t *Token) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling API token")
+	}
+	s.mu.Lock()
+	s.tokens[t.ID] = b
+	s.mu.Unlock()
+	return nil
+}
+
+// Get implements the Store interface.
+func (s *MemoryStore) Get(id string) (*Token, error) {
+	s.mu.RLock()
+	b, ok := s.tokens[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return unmarshalToken(b)
+}
+
+// List implements the Store interface.
+func (s *MemoryStore) List() ([]*Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tokens := make([]*Token, 0, len(s.tokens))
+	for _, b := range s.tokens {
+		t, err := unmarshalToken(b)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	sortTokens(tokens)
+	return tokens, nil
+}
+
+// NoSQLStore is a Store that persists the tokens in the authority database.
+type NoSQLStore struct {
+	db nosql.DB
+}
+
+// NewNoSQLStore creates the tokens table in the given database and returns
+// a new store.
+func NewNoSQLStore(db nosql.DB) (*NoSQLStore, error) {
+	if err := db.CreateTable(tokensTable); err != nil {
+		return nil, errors.Wrapf(err, "error creating table %s", tokensTable)
+	}
+	return &NoSQLStore{db: db}, nil
+}
+
+// Save implements the Store interface.
+func (s *NoSQLStore) Save(t *Token) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling API token")
+	}
+	return errors.Wrap(s.db.Set(tokensTable, []byte(t.ID), b), "error storing API token")
+}
+
+// Get implements the Store interface.
+func (s *NoSQLStore) Get(id string) (*Token, error) {
+	b, err := s.db.Get(tokensTable, []byte(id))
+	switch {
+	case database.IsErrNotFound(err):
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, errors.Wrap(err, "error loading API token")
+	}
+	return unmarshalToken(b)
+}
+
+// List implements the Store interface.
+func (s *NoSQLStore) List() ([]*Token, error) {
+	entries, err := s.db.List(tokensTable)
+	switch {
+	case database.IsErrNotFound(err):
+		return []*Token{}, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "error listing API tokens")
+	}
+	tokens := make([]*Token, 0, len(entries))
+	for _, e := range entries {
+		t, err := unmarshalToken(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	sortTokens(tokens)
+	return tokens, nil
+}
+
+func unmarshalToken(b []byte) (*Token, error) {
+	t := new(Token)
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling API token")
+	}
+	return t, nil
+}
+
+func sortTokens(tokens []*Token) {
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].CreatedAt.Equal(tokens[j].CreatedAt) {
+			return tokens[i].ID < tokens[j].ID
+		}
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+}
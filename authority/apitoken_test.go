@@ -0,0 +1,135 @@
+package authority
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/apitoken"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/db"
+)
+
+func TestAuthority_APITokens(t *testing.T) {
+	a := &Authority{apiTokenStore: apitoken.NewMemoryStore()}
+	ctx := context.Background()
+	superAdmin := &linkedca.Admin{Id: "admin-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+	regularAdmin := &linkedca.Admin{Id: "other-id", Subject: "bob", Type: linkedca.Admin_ADMIN}
+
+	assertAdminError := func(t *testing.T, err error, typ admin.ProblemType) {
+		t.Helper()
+		var adminErr *admin.Error
+		if assert.True(t, errors.As(err, &adminErr), "error is not an admin error") {
+			assert.Equals(t, typ.String(), adminErr.Type)
+		}
+	}
+
+	// Only super admins can manage tokens.
+	_, _, err := a.CreateAPIToken(ctx, regularAdmin, APITokenOptions{Name: "ci", Scopes: []apitoken.Scope{apitoken.ScopeInventoryRead}})
+	assertAdminError(t, err, admin.ErrorUnauthorizedType)
+	_, _, err = a.CreateAPIToken(ctx, superAdmin, APITokenOptions{Name: "ci", Scopes: []apitoken.Scope{"admin"}})
+	assertAdminError(t, err, admin.ErrorBadRequestType)
+
+	tok, secret, err := a.CreateAPIToken(ctx, superAdmin, APITokenOptions{Name: "ci", Scopes: []apitoken.Scope{apitoken.ScopeInventoryRead}})
+	assert.FatalError(t, err)
+	assert.Nil(t, tok.Hash)
+	assert.Equals(t, "admin-id", tok.CreatedBy)
+
+	adm, err := a.AuthorizeAPIToken(secret, apitoken.ScopeInventoryRead)
+	assert.FatalError(t, err)
+	assert.Equals(t, "api-token/"+tok.ID, adm.Id)
+	assert.Equals(t, "ci", adm.Subject)
+	assert.Equals(t, linkedca.Admin_ADMIN, adm.Type)
+
+	_, err = a.AuthorizeAPIToken(secret, apitoken.ScopeEABWrite)
+	assertAdminError(t, err, admin.ErrorUnauthorizedType)
+	_, err = a.AuthorizeAPIToken("not-a-token", apitoken.ScopeInventoryRead)
+	assertAdminError(t, err, admin.ErrorUnauthorizedType)
+	_, err = a.AuthorizeAPIToken(secret+"x", apitoken.ScopeInventoryRead)
+	assertAdminError(t, err, admin.ErrorUnauthorizedType)
+
+	tokens, err := a.GetAPITokens()
+	assert.FatalError(t, err)
+	assert.Len(t, 1, tokens)
+	assert.Nil(t, tokens[0].Hash)
+
+	// Rotation keeps the previous secret during the grace period.
+	_, _, err = a.RotateAPIToken(ctx, regularAdmin, tok.ID, 0)
+	assertAdminError(t, err, admin.ErrorUnauthorizedType)
+	_, _, err = a.RotateAPIToken(ctx, superAdmin, tok.ID, -time.Second)
+	assertAdminError(t, err, admin.ErrorBadRequestType)
+	_, _, err = a.RotateAPIToken(ctx, superAdmin, "missing", 0)
+	assertAdminError(t, err, admin.ErrorNotFoundType)
+
+	_, newSecret, err := a.RotateAPIToken(ctx, superAdmin, tok.ID, time.Hour)
+	assert.FatalError(t, err)
+	_, err = a.AuthorizeAPIToken(newSecret, apitoken.ScopeInventoryRead)
+	assert.FatalError(t, err)
+	_, err = a.AuthorizeAPIToken(secret, apitoken.ScopeInventoryRead)
+	assert.FatalError(t, err)
+
+	_, rotatedSecret, err := a.RotateAPIToken(ctx, superAdmin, tok.ID, 0)
+	assert.FatalError(t, err)
+	_, err = a.AuthorizeAPIToken(newSecret, apitoken.ScopeInventoryRead)
+	assertAdminError(t, err, admin.ErrorUnauthorizedType)
+
+	// Revoked tokens are rejected.
+	_, err = a.RevokeAPIToken(ctx, regularAdmin, tok.ID)
+	assertAdminError(t, err, admin.ErrorUnauthorizedType)
+	revoked, err := a.RevokeAPIToken(ctx, superAdmin, tok.ID)
+	assert.FatalError(t, err)
+	assert.Equals(t, "admin-id", revoked.RevokedBy)
+	_, err = a.RevokeAPIToken(ctx, superAdmin, tok.ID)
+	assertAdminError(t, err, admin.ErrorConflictType)
+	_, err = a.AuthorizeAPIToken(rotatedSecret, apitoken.ScopeInventoryRead)
+	assertAdminError(t, err, admin.ErrorUnauthorizedType)
+	_, _, err = a.RotateAPIToken(ctx, superAdmin, tok.ID, 0)
+	assertAdminError(t, err, admin.ErrorBadRequestType)
+
+	// Expired tokens are rejected.
+	_, expired, err := a.CreateAPIToken(ctx, superAdmin, APITokenOptions{
+		Name:      "expired",
+		Scopes:    []apitoken.Scope{apitoken.ScopeAuditRead},
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.FatalError(t, err)
+	id, _, err := apitoken.Parse(expired)
+	assert.FatalError(t, err)
+	stored, err := a.apiTokenStore.Get(id)
+	assert.FatalError(t, err)
+	stored.ExpiresAt = time.Now().Add(-time.Minute)
+	assert.FatalError(t, a.apiTokenStore.Save(stored))
+	_, err = a.AuthorizeAPIToken(expired, apitoken.ScopeAuditRead)
+	assertAdminError(t, err, admin.ErrorUnauthorizedType)
+}
+
+func TestAuthority_initAPITokens(t *testing.T) {
+	newAuthority := func(enableAdmin bool, d db.AuthDB) *Authority {
+		return &Authority{
+			config:    &config.Config{AuthorityConfig: &config.AuthConfig{EnableAdmin: enableAdmin}},
+			db:        d,
+			quietInit: true,
+		}
+	}
+
+	a := newAuthority(true, &db.SimpleDB{})
+	assert.FatalError(t, a.initAPITokens())
+	assert.Type(t, &apitoken.MemoryStore{}, a.apiTokenStore)
+
+	a = newAuthority(false, &db.MockAuthDB{})
+	assert.FatalError(t, a.initAPITokens())
+	assert.Type(t, &apitoken.MemoryStore{}, a.apiTokenStore)
+
+	// The tokens cannot be kept in memory if a database is configured.
+	a = newAuthority(true, &db.MockAuthDB{})
+	err := a.initAPITokens()
+	if assert.NotNil(t, err) {
+		assert.Equals(t, "API tokens are not supported with the configured database", err.Error())
+	}
+	assert.Nil(t, a.apiTokenStore)
+}
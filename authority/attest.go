@@ -0,0 +1,122 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/pemutil"
+	"go.step.sm/crypto/x509util"
+
+	"github.com/smallstep/certificates/attestation"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+)
+
+// SignAttested creates a certificate for a key attested by a device, without
+// going through the ACME device-attest-01 flow. The attestation statement is
+// verified with the attestation settings of the given ACME provisioner, which
+// must have the device-attest-01 challenge enabled. The key in the
+// certificate request must be the attested key, and the certificate is issued
+// for the permanent identifier of the device.
+func (a *Authority) SignAttested(ctx context.Context, csr *x509.CertificateRequest, provisionerName string, st *attestation.Statement) ([]*x509.Certificate, error) {
+	p, err := a.LoadProvisionerByName(provisionerName)
+	if err != nil {
+		return nil, errs.BadRequestErr(err, "provisioner '%s' not found", provisionerName)
+	}
+	prov, ok := p.(*provisioner.ACME)
+	if !ok || !prov.IsChallengeEnabled(ctx, provisioner.DEVICE_ATTEST_01) {
+		return nil, errs.Forbidden("provisioner '%s' does not allow attested enrollment", provisionerName)
+	}
+	if st == nil || !prov.IsAttestationFormatEnabled(ctx, provisioner.ACMEAttestationFormat(st.Format)) {
+		return nil, errs.Forbidden("attestation format is not enabled in provisioner '%s'", provisionerName)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, errs.BadRequestErr(err, "invalid certificate request signature")
+	}
+
+	roots, err := attestationRoots(prov, st.Format)
+	if err != nil {
+		return nil, err
+	}
+	ka, err := attestation.VerifyStatement(st, attestation.VerifyOptions{Roots: roots})
+	if err != nil {
+		return nil, errs.UnauthorizedErr(err, errs.WithMessage("attestation statement is not valid"))
+	}
+	fp, err := keyutil.Fingerprint(csr.PublicKey)
+	if err != nil {
+		return nil, errs.BadRequestErr(err, "invalid certificate request public key")
+	}
+	if fp != ka.Fingerprint {
+		return nil, errs.Forbidden("certificate request key does not match the attested key")
+	}
+
+	// The certificate is issued for the attested identifier, if the CSR has
+	// a common name it must match it.
+	var permanentIdentifier string
+	for _, id := range ka.PermanentIdentifiers {
+		if csr.Subject.CommonName == "" || csr.Subject.CommonName == id {
+			permanentIdentifier = id
+			break
+		}
+	}
+	if permanentIdentifier == "" {
+		if len(ka.PermanentIdentifiers) == 0 {
+			return nil, errs.Forbidden("attestation statement does not contain a permanent identifier")
+		}
+		return nil, errs.Forbidden("certificate request common name %s does not match the attested identifiers", csr.Subject.CommonName)
+	}
+
+	data := x509util.NewTemplateData()
+	data.SetCommonName(permanentIdentifier)
+	data.SetSubjectAlternativeNames(x509util.SubjectAlternativeName{
+		Type:  x509util.PermanentIdentifierType,
+		Value: permanentIdentifier,
+	})
+
+	ctx = provisioner.NewContextWithMethod(ctx, provisioner.SignMethod)
+	signOpts, err := prov.AuthorizeSign(ctx, "")
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.SignAttested")
+	}
+	for _, op := range signOpts {
+		if wc, ok := op.(*provisioner.WebhookController); ok {
+			wc.TemplateData = data
+		}
+	}
+	templateOptions, err := provisioner.CustomTemplateOptions(prov.GetOptions(), data, x509util.DefaultAttestedLeafTemplate)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.SignAttested")
+	}
+	signOpts = append(signOpts, templateOptions, provisioner.AttestationData{
+		PermanentIdentifier: permanentIdentifier,
+	})
+
+	return a.SignWithContext(ctx, csr, provisioner.SignOptions{}, signOpts...)
+}
+
+// attestationRoots returns the roots used to verify the attestations of the
+// given format. As in device-attest-01, the Apple and Yubico roots are used if
+// the provisioner does not configure any.
+func attestationRoots(p *provisioner.ACME, format string) (*x509.CertPool, error) {
+	if roots, ok := p.GetAttestationRoots(); ok {
+		return roots, nil
+	}
+	var pem string
+	switch format {
+	case attestation.FormatApple:
+		pem = attestation.AppleEnterpriseAttestationRootCA
+	case attestation.FormatStep:
+		pem = attestation.YubicoPIVRootCA
+	default:
+		return nil, errs.Forbidden("provisioner '%s' does not have attestation roots for %s", p.GetName(), format)
+	}
+	root, err := pemutil.ParseCertificate([]byte(pem))
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.SignAttested")
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return roots, nil
+}
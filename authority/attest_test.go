@@ -0,0 +1,135 @@
+package authority
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+	"go.step.sm/crypto/x509util"
+
+	"github.com/smallstep/certificates/attestation"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+)
+
+func TestAuthority_SignAttested(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	roots := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Root.Raw})
+
+	a := testAuthority(t, func(a *Authority) error {
+		a.config.AuthorityConfig.Provisioners = append(a.config.AuthorityConfig.Provisioners,
+			&provisioner.ACME{
+				Name:               "acme",
+				Type:               "ACME",
+				Challenges:         []provisioner.ACMEChallenge{provisioner.DEVICE_ATTEST_01},
+				AttestationFormats: []provisioner.ACMEAttestationFormat{provisioner.STEP},
+				AttestationRoots:   roots,
+			},
+			&provisioner.ACME{
+				Name: "acme-no-attest",
+				Type: "ACME",
+			},
+		)
+		return nil
+	})
+
+	pub, priv, err := keyutil.GenerateDefaultKeyPair()
+	assert.FatalError(t, err)
+	_, otherPriv, err := keyutil.GenerateDefaultKeyPair()
+	assert.FatalError(t, err)
+
+	serial, err := asn1.Marshal(112233)
+	assert.FatalError(t, err)
+	attCert, err := ca.Sign(&x509.Certificate{
+		Subject:   pkix.Name{CommonName: "YubiKey PIV Attestation 9a"},
+		PublicKey: pub,
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 41482, 3, 7}, Value: serial},
+		},
+	})
+	assert.FatalError(t, err)
+	st := &attestation.Statement{
+		Format: attestation.FormatStep,
+		AttStatement: map[string]interface{}{
+			"x5c": []interface{}{attCert.Raw, ca.Intermediate.Raw},
+		},
+	}
+
+	csrWithCN := func(signer crypto.Signer, cn string) *x509.CertificateRequest {
+		csr, err := x509util.CreateCertificateRequest(cn, nil, signer)
+		assert.FatalError(t, err)
+		return csr
+	}
+
+	assertStatus := func(t *testing.T, statusCode int, err error) {
+		t.Helper()
+		var sc *errs.Error
+		if assert.True(t, errors.As(err, &sc)) {
+			assert.Equals(t, statusCode, sc.StatusCode())
+		}
+	}
+
+	ctx := context.Background()
+	t.Run("ok", func(t *testing.T) {
+		for _, cn := range []string{"", "112233"} {
+			chain, err := a.SignAttested(ctx, csrWithCN(priv.(crypto.Signer), cn), "acme", st)
+			assert.FatalError(t, err)
+			leaf := chain[0]
+			assert.Equals(t, "112233", leaf.Subject.CommonName)
+			sans, err := x509util.ParseSubjectAlternativeNames(leaf)
+			assert.FatalError(t, err)
+			if assert.Len(t, 1, sans.PermanentIdentifiers) {
+				assert.Equals(t, "112233", sans.PermanentIdentifiers[0].Identifier)
+			}
+			assert.Equals(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, leaf.ExtKeyUsage)
+		}
+	})
+
+	t.Run("fail/provisioner", func(t *testing.T) {
+		csr := csrWithCN(priv.(crypto.Signer), "")
+		_, err := a.SignAttested(ctx, csr, "missing", st)
+		assertStatus(t, http.StatusBadRequest, err)
+		_, err = a.SignAttested(ctx, csr, "step-cli", st)
+		assertStatus(t, http.StatusForbidden, err)
+		_, err = a.SignAttested(ctx, csr, "acme-no-attest", st)
+		assertStatus(t, http.StatusForbidden, err)
+	})
+
+	t.Run("fail/format", func(t *testing.T) {
+		_, err := a.SignAttested(ctx, csrWithCN(priv.(crypto.Signer), ""), "acme", &attestation.Statement{
+			Format:       attestation.FormatApple,
+			AttStatement: st.AttStatement,
+		})
+		assertStatus(t, http.StatusForbidden, err)
+	})
+
+	t.Run("fail/attestation", func(t *testing.T) {
+		_, err := a.SignAttested(ctx, csrWithCN(priv.(crypto.Signer), ""), "acme", &attestation.Statement{
+			Format: attestation.FormatStep,
+			AttStatement: map[string]interface{}{
+				"x5c": []interface{}{attCert.Raw},
+			},
+		})
+		assertStatus(t, http.StatusUnauthorized, err)
+	})
+
+	t.Run("fail/key", func(t *testing.T) {
+		_, err := a.SignAttested(ctx, csrWithCN(otherPriv.(crypto.Signer), ""), "acme", st)
+		assertStatus(t, http.StatusForbidden, err)
+	})
+
+	t.Run("fail/common name", func(t *testing.T) {
+		_, err := a.SignAttested(ctx, csrWithCN(priv.(crypto.Signer), "other"), "acme", st)
+		assertStatus(t, http.StatusForbidden, err)
+	})
+}
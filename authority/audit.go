@@ -0,0 +1,244 @@
+package authority
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	kmsapi "go.step.sm/crypto/kms/apiv1"
+
+	"github.com/smallstep/certificates/authority/audit"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/nosql"
+)
+
+// x509AuditData is the data of the audit records of signed and renewed
+// certificates.
+type x509AuditData struct {
+	SerialNumber    string    `json:"serialNumber"`
+	OldSerialNumber string    `json:"oldSerialNumber,omitempty"`
+	Subject         string    `json:"subject"`
+	DNSNames        []string  `json:"dnsNames,omitempty"`
+	EmailAddresses  []string  `json:"emailAddresses,omitempty"`
+	IPAddresses     []string  `json:"ipAddresses,omitempty"`
+	URIs            []string  `json:"uris,omitempty"`
+	NotBefore       time.Time `json:"notBefore"`
+	NotAfter        time.Time `json:"notAfter"`
+	Fingerprint     string    `json:"fingerprint"`
+	ProvisionerID   string    `json:"provisionerID,omitempty"`
+	ProvisionerName string    `json:"provisionerName,omitempty"`
+	ServerSideKey   bool      `json:"serverSideKey,omitempty"`
+}
+
+func newX509AuditData(crt *x509.Certificate) *x509AuditData {
+	sum := sha256.Sum256(crt.Raw)
+	d := &x509AuditData{
+		SerialNumber:   crt.SerialNumber.String(),
+		Subject:        crt.Subject.String(),
+		DNSNames:       crt.DNSNames,
+		EmailAddresses: crt.EmailAddresses,
+		NotBefore:      crt.NotBefore,
+		NotAfter:       crt.NotAfter,
+		Fingerprint:    hex.EncodeToString(sum[:]),
+	}
+	for _, ip := range crt.IPAddresses {
+		d.IPAddresses = append(d.IPAddresses, ip.String())
+	}
+	for _, u := range crt.URIs {
+		d.URIs = append(d.URIs, u.String())
+	}
+	return d
+}
+
+// revokeAuditData is the data of the audit records of revoked certificates.
+type revokeAuditData struct {
+	SerialNumber  string    `json:"serialNumber"`
+	ReasonCode    int       `json:"reasonCode"`
+	Reason        string    `json:"reason,omitempty"`
+	ProvisionerID string    `json:"provisionerID,omitempty"`
+	TokenID       string    `json:"tokenID,omitempty"`
+	MTLS          bool      `json:"mtls,omitempty"`
+	ACME          bool      `json:"acme,omitempty"`
+	SSH           bool      `json:"ssh,omitempty"`
+	RevokedAt     time.Time `json:"revokedAt"`
+}
+
+// initAuditLog creates the audit log and starts the goroutine that signs the
+// checkpoints.
+func (a *Authority) initAuditLog() error {
+	if !a.config.Audit.IsEnabled() {
+		return nil
+	}
+
+	key := a.config.Audit.Key
+	if key == "" {
+		key = a.config.IntermediateKey
+	}
+	if key == "" {
+		return errors.New("audit.key is required if the intermediate key is not configured")
+	}
+	signer, err := a.keyManager.CreateSigner(&kmsapi.CreateSignerRequest{
+		SigningKey: key,
+		Password:   a.password,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error creating audit signer")
+	}
+	if err := a.checkOfflineRootKey(signer.Public(), "audit key"); err != nil {
+		return err
+	}
+
+	var store audit.Store
+	if ndb, ok := nosqlDB(a.db); ok {
+		if store, err = audit.NewNoSQLStore(ndb); err != nil {
+			return err
+		}
+	} else {
+		a.initLogf("Audit log is enabled without a database, records will be kept in memory")
+		store = audit.NewMemoryStore()
+	}
+	if a.auditLog, err = audit.New(store, signer); err != nil {
+		return err
+	}
+
+	a.auditStopper = make(chan struct{}, 1)
+	a.auditTicker = time.NewTicker(a.config.Audit.TickerDuration())
+
+	go func() {
+		for {
+			select {
+			case <-a.auditTicker.C:
+				if !a.IsLeader() {
+					continue
+				}
+				if _, err := a.auditLog.Checkpoint(); err != nil {
+					log.Printf("error signing audit checkpoint: %v", err)
+				}
+			case <-a.auditStopper:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// nosqlDB returns the given database as a nosql.DB. It returns false if the
+// database cannot store data, like the db.SimpleDB used when the database is
+// not configured.
+func nosqlDB(d db.AuthDB) (nosql.DB, bool) {
+	if noDatabase(d) {
+		return nil, false
+	}
+	ndb, ok := d.(nosql.DB)
+	return ndb, ok
+}
+
+// noDatabase returns true if the given database is the db.SimpleDB used when
+// the database is not configured.
+func noDatabase(d db.AuthDB) bool {
+	_, ok := d.(*db.SimpleDB)
+	return ok
+}
+
+// stopAuditLog stops the checkpoint goroutine and signs the pending records.
+func (a *Authority) stopAuditLog() {
+	if a.auditTicker == nil {
+		return
+	}
+	a.auditTicker.Stop()
+	close(a.auditStopper)
+	if !a.IsLeader() {
+		return
+	}
+	if _, err := a.auditLog.Checkpoint(); err != nil {
+		log.Printf("error signing audit checkpoint: %v", err)
+	}
+}
+
+// auditX509Sign adds a record of a signed certificate to the audit log.
+func (a *Authority) auditX509Sign(prov provisioner.Interface, crt *x509.Certificate, serverSideKey bool) error {
+	if a.auditLog == nil {
+		return nil
+	}
+	d := newX509AuditData(crt)
+	d.ServerSideKey = serverSideKey
+	if prov != nil {
+		d.ProvisionerID = prov.GetID()
+		d.ProvisionerName = prov.GetName()
+	}
+	_, err := a.auditLog.Append(audit.X509SignType, d)
+	return err
+}
+
+// auditX509Renew adds a record of a renewed or rekeyed certificate to the
+// audit log.
+func (a *Authority) auditX509Renew(oldCert, crt *x509.Certificate) error {
+	if a.auditLog == nil {
+		return nil
+	}
+	d := newX509AuditData(crt)
+	d.OldSerialNumber = oldCert.SerialNumber.String()
+	_, err := a.auditLog.Append(audit.X509RenewType, d)
+	return err
+}
+
+// auditRevoke adds a record of a revoked certificate to the audit log.
+func (a *Authority) auditRevoke(rci *db.RevokedCertificateInfo, isSSH bool) error {
+	if a.auditLog == nil {
+		return nil
+	}
+	_, err := a.auditLog.Append(audit.RevokeType, &revokeAuditData{
+		SerialNumber:  rci.Serial,
+		ReasonCode:    rci.ReasonCode,
+		Reason:        rci.Reason,
+		ProvisionerID: rci.ProvisionerID,
+		TokenID:       rci.TokenID,
+		MTLS:          rci.MTLS,
+		ACME:          rci.ACME,
+		SSH:           isSSH,
+		RevokedAt:     rci.RevokedAt,
+	})
+	return err
+}
+
+// ExportAuditLog returns the audit records starting at the given index and
+// all the signed checkpoints.
+func (a *Authority) ExportAuditLog(from uint64) (*audit.Export, error) {
+	if a.auditLog == nil {
+		return nil, errs.NotFound("authority.ExportAuditLog; audit log is not enabled")
+	}
+	e, err := a.auditLog.Export(from)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.ExportAuditLog")
+	}
+	return e, nil
+}
+
+// GetAuditLogPublicKey returns the public key used to verify the checkpoints
+// of the audit log.
+func (a *Authority) GetAuditLogPublicKey() (crypto.PublicKey, error) {
+	if a.auditLog == nil {
+		return nil, errs.NotFound("authority.GetAuditLogPublicKey; audit log is not enabled")
+	}
+	return a.auditLog.PublicKey(), nil
+}
+
+// VerifyAuditLog verifies the integrity of an export of the audit log using
+// the key of the authority.
+func (a *Authority) VerifyAuditLog(e *audit.Export) error {
+	if a.auditLog == nil {
+		return errs.NotFound("authority.VerifyAuditLog; audit log is not enabled")
+	}
+	if err := audit.Verify(e, a.auditLog.PublicKey()); err != nil {
+		return errs.BadRequestErr(err, "audit log verification failed: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,322 @@
+// Package audit implements a tamper-evident log of the operations of the
+// authority. Every record includes the hash of the previous one, and the head
+// of the chain is periodically signed in checkpoints, so any later
+// modification, removal or reordering of the records can be detected.
+package audit
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Record types.
+const (
+	// X509SignType is the type of the records of signed X.509 certificates.
+	X509SignType = "x509.sign"
+	// X509RenewType is the type of the records of renewed or rekeyed X.509
+	// certificates.
+	X509RenewType = "x509.renew"
+	// RevokeType is the type of the records of revoked certificates.
+	RevokeType = "revoke"
+	// DecommissionType is the type of the record of the decommission of the
+	// authority.
+	DecommissionType = "decommission"
+)
+
+// ErrConflict is returned by a Store when a record cannot be appended
+// because the head of the log has changed.
+var ErrConflict = errors.New("audit log head has changed")
+
+// maxAppendRetries is the number of times an append is retried on conflicts.
+const maxAppendRetries = 10
+
+// Store is the interface used to persist the audit log.
+type Store interface {
+	// Append appends the given record. It must return ErrConflict if the
+	// record does not follow the current last record.
+	Append(r *Record) error
+	// Last returns the last record, or nil if the log is empty.
+	Last() (*Record, error)
+	// List returns the records with an index greater than or equal to the
+	// given one, sorted by index.
+	List(from uint64) ([]*Record, error)
+	// SaveCheckpoint stores the given checkpoint.
+	SaveCheckpoint(c *Checkpoint) error
+	// Checkpoints returns all the checkpoints sorted by index.
+	Checkpoints() ([]*Checkpoint, error)
+}
+
+// Record is an entry in the audit log.
+type Record struct {
+	Index    uint64          `json:"index"`
+	Time     time.Time       `json:"time"`
+	Type     string          `json:"type"`
+	Data     json.RawMessage `json:"data"`
+	PrevHash []byte          `json:"prevHash"`
+	Hash     []byte          `json:"hash"`
+}
+
+// ComputeHash returns the SHA-256 hash of the record, without the Hash
+// property.
+func (r *Record) ComputeHash() ([]byte, error) {
+	b, err := json.Marshal(struct {
+		Index    uint64          `json:"index"`
+		Time     time.Time       `json:"time"`
+		Type     string          `json:"type"`
+		Data     json.RawMessage `json:"data"`
+		PrevHash []byte          `json:"prevHash"`
+	}{r.Index, r.Time, r.Type, r.Data, r.PrevHash})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling audit record")
+	}
+	sum := sha256.Sum256(b)
+	return sum[:], nil
+}
+
+// Checkpoint is a signature over the hash of a record of the log, the
+// signature proves the integrity of all the records up to that one.
+type Checkpoint struct {
+	Index     uint64    `json:"index"`
+	Hash      []byte    `json:"hash"`
+	Time      time.Time `json:"time"`
+	Signature []byte    `json:"signature"`
+}
+
+func (c *Checkpoint) signedBytes() ([]byte, error) {
+	b, err := json.Marshal(struct {
+		Index uint64    `json:"index"`
+		Hash  []byte    `json:"hash"`
+		Time  time.Time `json:"time"`
+	}{c.Index, c.Hash, c.Time})
+	return b, errors.Wrap(err, "error marshaling audit checkpoint")
+}
+
+// Export is the export of the audit log. From is the index of the first
+// requested record.
+type Export struct {
+	From        uint64        `json:"from"`
+	Records     []*Record     `json:"records"`
+	Checkpoints []*Checkpoint `json:"checkpoints"`
+}
+
+// Log is the audit log.
+type Log struct {
+	mu     sync.Mutex
+	store  Store
+	signer crypto.Signer
+}
+
+// New creates a new audit Log that persists the records in the given store
+// and signs the checkpoints with the given signer.
+func New(store Store, signer crypto.Signer) (*Log, error) {
+	switch {
+	case store == nil:
+		return nil, errors.New("audit store cannot be nil")
+	case signer == nil:
+		return nil, errors.New("audit signer cannot be nil")
+	}
+	return &Log{store: store, signer: signer}, nil
+}
+
+// PublicKey returns the public key used to verify the checkpoints.
+func (l *Log) PublicKey() crypto.PublicKey {
+	return l.signer.Public()
+}
+
+// Append adds a new record with the given type and data to the log.
+func (l *Log) Append(typ string, data any) (*Record, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling audit data")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Other instances may be writing in the same store.
+	for i := 0; ; i++ {
+		r, err := l.newRecord(typ, b)
+		if err != nil {
+			return nil, err
+		}
+		if err = l.store.Append(r); err == nil {
+			return r, nil
+		}
+		if !errors.Is(err, ErrConflict) || i == maxAppendRetries {
+			return nil, errors.Wrap(err, "error appending audit record")
+		}
+	}
+}
+
+func (l *Log) newRecord(typ string, data []byte) (*Record, error) {
+	last, err := l.store.Last()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading last audit record")
+	}
+
+	r := &Record{
+		Time: time.Now().UTC(),
+		Type: typ,
+		Data: data,
+	}
+	if last != nil {
+		r.Index = last.Index + 1
+		r.PrevHash = last.Hash
+	}
+	if r.Hash, err = r.ComputeHash(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Checkpoint signs the current last record of the log. It returns nil if the
+// log is empty or if the last record is already signed.
+func (l *Log) Checkpoint() (*Checkpoint, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	last, err := l.store.Last()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading last audit record")
+	}
+	if last == nil {
+		return nil, nil
+	}
+	cps, err := l.store.Checkpoints()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading audit checkpoints")
+	}
+	if n := len(cps); n > 0 && cps[n-1].Index == last.Index {
+		return nil, nil
+	}
+
+	c := &Checkpoint{
+		Index: last.Index,
+		Hash:  last.Hash,
+		Time:  time.Now().UTC(),
+	}
+	b, err := c.signedBytes()
+	if err != nil {
+		return nil, err
+	}
+	if c.Signature, err = sign(l.signer, b); err != nil {
+		return nil, errors.Wrap(err, "error signing audit checkpoint")
+	}
+	if err := l.store.SaveCheckpoint(c); err != nil {
+		return nil, errors.Wrap(err, "error storing audit checkpoint")
+	}
+	return c, nil
+}
+
+// Export returns the records starting at the given index and all the
+// checkpoints.
+func (l *Log) Export(from uint64) (*Export, error) {
+	records, err := l.store.List(from)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing audit records")
+	}
+	cps, err := l.store.Checkpoints()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing audit checkpoints")
+	}
+	return &Export{From: from, Records: records, Checkpoints: cps}, nil
+}
+
+// Verify verifies the integrity of the given export. It checks that the
+// records are contiguous and correctly chained, that the checkpoints are
+// signed by the given key, and that they match the exported records. It
+// fails if a checkpoint signs a record after the last exported one, or, if
+// there are no records, a record at or after the first requested one.
+//
+// Records after the last checkpoint are only protected by the chain, they
+// will be protected by the signature of the next checkpoint.
+func Verify(e *Export, pub crypto.PublicKey) error {
+	if e == nil {
+		return errors.New("audit export cannot be nil")
+	}
+
+	hashes := make(map[uint64][]byte, len(e.Records))
+	var prev *Record
+	for _, r := range e.Records {
+		h, err := r.ComputeHash()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(h, r.Hash) {
+			return errors.Errorf("audit record %d has an invalid hash", r.Index)
+		}
+		if prev != nil {
+			if r.Index != prev.Index+1 {
+				return errors.Errorf("audit record %d is missing", prev.Index+1)
+			}
+			if !bytes.Equal(r.PrevHash, prev.Hash) {
+				return errors.Errorf("audit record %d does not follow record %d", r.Index, prev.Index)
+			}
+		} else if r.Index == 0 && r.PrevHash != nil {
+			return errors.New("audit record 0 cannot have a previous hash")
+		}
+		hashes[r.Index] = r.Hash
+		prev = r
+	}
+
+	for _, c := range e.Checkpoints {
+		b, err := c.signedBytes()
+		if err != nil {
+			return err
+		}
+		if err := verify(pub, b, c.Signature); err != nil {
+			return errors.Wrapf(err, "audit checkpoint %d has an invalid signature", c.Index)
+		}
+		// A signed checkpoint after the last record means that the records
+		// at the end of the log have been removed. Checkpoints before the
+		// first record are the ones of an export of the last records, and
+		// an export without records is valid if all the checkpoints are
+		// before the requested records.
+		if (prev == nil && c.Index >= e.From) || (prev != nil && c.Index > prev.Index) {
+			return errors.Errorf("audit record %d is missing, the log has been truncated", c.Index)
+		}
+		if h, ok := hashes[c.Index]; ok && !bytes.Equal(h, c.Hash) {
+			return errors.Errorf("audit record %d does not match its checkpoint", c.Index)
+		}
+	}
+
+	return nil
+}
+
+func sign(signer crypto.Signer, b []byte) ([]byte, error) {
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		return signer.Sign(rand.Reader, b, crypto.Hash(0))
+	}
+	sum := sha256.Sum256(b)
+	return signer.Sign(rand.Reader, sum[:], crypto.SHA256)
+}
+
+func verify(pub crypto.PublicKey, b, sig []byte) error {
+	sum := sha256.Sum256(b)
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, sum[:], sig) {
+			return errors.New("ecdsa signature verification failed")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, b, sig) {
+			return errors.New("ed25519 signature verification failed")
+		}
+		return nil
+	default:
+		return errors.Errorf("unsupported public key type %T", pub)
+	}
+}
@@ -0,0 +1,294 @@
+package audit
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/smallstep/nosql"
+	"go.step.sm/crypto/keyutil"
+)
+
+func mustSigner(t *testing.T, kty, crv string, size int) crypto.Signer {
+	t.Helper()
+	if kty == "OKP" {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return priv
+	}
+	key, err := keyutil.GenerateSigner(kty, crv, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func mustNoSQLStore(t *testing.T) *NoSQLStore {
+	t.Helper()
+	db, err := nosql.New(nosql.BBoltDriver, filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := NewNoSQLStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func fill(t *testing.T, l *Log, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := l.Append(X509SignType, map[string]any{"serial": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	signer := mustSigner(t, "EC", "P-256", 0)
+	if _, err := New(nil, signer); err == nil {
+		t.Error("New() with nil store error = nil")
+	}
+	if _, err := New(NewMemoryStore(), nil); err == nil {
+		t.Error("New() with nil signer error = nil")
+	}
+	if _, err := New(NewMemoryStore(), signer); err != nil {
+		t.Errorf("New() error = %v", err)
+	}
+}
+
+func TestLog(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(*testing.T) Store { return NewMemoryStore() },
+		"nosql":  func(t *testing.T) Store { return mustNoSQLStore(t) },
+	}
+	signers := map[string]crypto.Signer{
+		"ec":      mustSigner(t, "EC", "P-256", 0),
+		"rsa":     mustSigner(t, "RSA", "", 2048),
+		"ed25519": mustSigner(t, "OKP", "Ed25519", 0),
+	}
+	for sn, newStore := range stores {
+		for kn, signer := range signers {
+			t.Run(sn+"/"+kn, func(t *testing.T) {
+				l, err := New(newStore(t), signer)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// Empty log
+				if c, err := l.Checkpoint(); err != nil || c != nil {
+					t.Fatalf("Checkpoint() = %v, %v, want nil, nil", c, err)
+				}
+
+				fill(t, l, 3)
+				c, err := l.Checkpoint()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if c == nil || c.Index != 2 {
+					t.Fatalf("Checkpoint() = %v, want index 2", c)
+				}
+				// Unchanged log
+				if c, err := l.Checkpoint(); err != nil || c != nil {
+					t.Fatalf("Checkpoint() = %v, %v, want nil, nil", c, err)
+				}
+
+				fill(t, l, 2)
+				if _, err := l.Checkpoint(); err != nil {
+					t.Fatal(err)
+				}
+				fill(t, l, 1)
+
+				e, err := l.Export(0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(e.Records) != 6 || len(e.Checkpoints) != 2 {
+					t.Fatalf("Export() = %d records and %d checkpoints, want 6 and 2", len(e.Records), len(e.Checkpoints))
+				}
+				for i, r := range e.Records {
+					if r.Index != uint64(i) {
+						t.Fatalf("Export() record %d has index %d", i, r.Index)
+					}
+				}
+				if err := Verify(e, l.PublicKey()); err != nil {
+					t.Errorf("Verify() error = %v", err)
+				}
+
+				e, err = l.Export(4)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(e.Records) != 2 || e.Records[0].Index != 4 {
+					t.Fatalf("Export(4) = %d records, want 2", len(e.Records))
+				}
+				if err := Verify(e, l.PublicKey()); err != nil {
+					t.Errorf("Verify() error = %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestLog_Append_concurrent(t *testing.T) {
+	store := mustNoSQLStore(t)
+	signer := mustSigner(t, "EC", "P-256", 0)
+
+	// Two logs sharing the same store simulate two instances of the CA.
+	l1, err := New(store, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l2, err := New(store, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, l := range []*Log{l1, l2} {
+		wg.Add(1)
+		go func(l *Log) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if _, err := l.Append(RevokeType, i); err != nil {
+					t.Error(err)
+				}
+			}
+		}(l)
+	}
+	wg.Wait()
+
+	e, err := l1.Export(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Records) != 20 {
+		t.Errorf("Export() = %d records, want 20", len(e.Records))
+	}
+	if err := Verify(e, signer.Public()); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	signer := mustSigner(t, "EC", "P-256", 0)
+	other := mustSigner(t, "EC", "P-256", 0)
+
+	newExport := func(t *testing.T) *Export {
+		l, err := New(NewMemoryStore(), signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fill(t, l, 5)
+		if _, err := l.Checkpoint(); err != nil {
+			t.Fatal(err)
+		}
+		e, err := l.Export(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(e *Export)
+		pub     crypto.PublicKey
+		wantErr string
+	}{
+		{"ok", func(e *Export) {}, signer.Public(), ""},
+		{"ok/from", func(e *Export) {
+			e.From, e.Records = 2, e.Records[2:]
+		}, signer.Public(), ""},
+		{"ok/empty", func(e *Export) {
+			e.From, e.Records = 5, nil
+		}, signer.Public(), ""},
+		{"fail/nil", nil, signer.Public(), "audit export cannot be nil"},
+		{"fail/data", func(e *Export) {
+			e.Records[2].Data = []byte(`{"serial":42}`)
+		}, signer.Public(), "audit record 2 has an invalid hash"},
+		{"fail/removed", func(e *Export) {
+			e.Records = append(e.Records[:2], e.Records[3:]...)
+		}, signer.Public(), "audit record 2 is missing"},
+		{"fail/rewritten", func(e *Export) {
+			r := e.Records[2]
+			r.Data = []byte(`{}`)
+			r.Hash, _ = r.ComputeHash()
+		}, signer.Public(), "audit record 3 does not follow record 2"},
+		{"fail/rechained", func(e *Export) {
+			for i := 1; i < len(e.Records); i++ {
+				r := e.Records[i]
+				if i == 1 {
+					r.Data = []byte(`{}`)
+				}
+				r.PrevHash = e.Records[i-1].Hash
+				r.Hash, _ = r.ComputeHash()
+			}
+		}, signer.Public(), "audit record 4 does not match its checkpoint"},
+		{"fail/signature", func(e *Export) {}, other.Public(), "audit checkpoint 4 has an invalid signature"},
+		{"fail/checkpoint", func(e *Export) {
+			e.Checkpoints[0].Index = 3
+		}, signer.Public(), "audit checkpoint 3 has an invalid signature"},
+		{"fail/truncated", func(e *Export) {
+			e.Records = e.Records[:3]
+		}, signer.Public(), "audit record 4 is missing, the log has been truncated"},
+		{"fail/truncated-all", func(e *Export) {
+			e.Records = nil
+		}, signer.Public(), "audit record 4 is missing, the log has been truncated"},
+		{"fail/truncated-from", func(e *Export) {
+			e.From, e.Records = 4, nil
+		}, signer.Public(), "audit record 4 is missing, the log has been truncated"},
+		{"fail/first", func(e *Export) {
+			e.Records[0].PrevHash = []byte("foo")
+			e.Records[0].Hash, _ = e.Records[0].ComputeHash()
+		}, signer.Public(), "audit record 0 cannot have a previous hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e *Export
+			if tt.modify != nil {
+				e = newExport(t)
+				tt.modify(e)
+			}
+			err := Verify(e, tt.pub)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Verify() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)):
+				t.Errorf("Verify() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerify_emptyExport(t *testing.T) {
+	signer := mustSigner(t, "EC", "P-256", 0)
+	l, err := New(NewMemoryStore(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, l, 5)
+	if _, err := l.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An export of the tail of the log without new records.
+	e, err := l.Export(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Records) != 0 || len(e.Checkpoints) != 1 {
+		t.Fatalf("Log.Export() = %d records and %d checkpoints, want 0 and 1", len(e.Records), len(e.Checkpoints))
+	}
+	if err := Verify(e, signer.Public()); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/nosql"
+	"github.com/smallstep/nosql/database"
+)
+
+var (
+	recordsTable     = []byte("audit_log")
+	headTable        = []byte("audit_head")
+	checkpointsTable = []byte("audit_checkpoints")
+	headKey          = []byte("head")
+)
+
+// MemoryStore is a Store that keeps the audit log in memory. It is used when
+// the authority does not have a database.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	records     []*Record
+	checkpoints []*Checkpoint
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Append implements the Store interface.
+func (s *MemoryStore) Append(r *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Index != uint64(len(s.records)) {
+		return ErrConflict
+	}
+	s.records = append(s.records, r)
+	return nil
+}
+
+// Last implements the Store interface.
+func (s *MemoryStore) Last() (*Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.records) == 0 {
+		return nil, nil
+	}
+	return s.records[len(s.records)-1], nil
+}
+
+// List implements the Store interface.
+func (s *MemoryStore) List(from uint64) ([]*Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if from >= uint64(len(s.records)) {
+		return []*Record{}, nil
+	}
+	return append([]*Record{}, s.records[from:]...), nil
+}
+
+// SaveCheckpoint implements the Store interface.
+func (s *MemoryStore) SaveCheckpoint(c *Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints = append(s.checkpoints, c)
+	return nil
+}
+
+// Checkpoints implements the Store interface.
+func (s *MemoryStore) Checkpoints() ([]*Checkpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*Checkpoint{}, s.checkpoints...), nil
+}
+
+// NoSQLStore is a Store that persists the audit log in the authority
+// database. The head of the log is updated with a compare-and-swap, so
+// multiple instances can share the same database.
+type NoSQLStore struct {
+	db nosql.DB
+}
+
+// NewNoSQLStore creates the audit tables in the given database and returns a
+// new store.
+func NewNoSQLStore(db nosql.DB) (*NoSQLStore, error) {
+	for _, b := range [][]byte{recordsTable, headTable, checkpointsTable} {
+		if err := db.CreateTable(b); err != nil {
+			return nil, errors.Wrapf(err, "error creating table %s", b)
+		}
+	}
+	return &NoSQLStore{db: db}, nil
+}
+
+func indexKey(i uint64) []byte {
+	return []byte(fmt.Sprintf("%020d", i))
+}
+
+// Append implements the Store interface.
+func (s *NoSQLStore) Append(r *Record) error {
+	head, err := s.db.Get(headTable, headKey)
+	switch {
+	case database.IsErrNotFound(err):
+		head = nil
+	case err != nil:
+		return errors.Wrap(err, "error loading audit head")
+	}
+
+	var next uint64
+	if head != nil {
+		last := new(Record)
+		if err := json.Unmarshal(head, last); err != nil {
+			return errors.Wrap(err, "error unmarshaling audit head")
+		}
+		next = last.Index + 1
+	}
+	if r.Index != next {
+		return ErrConflict
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling audit record")
+	}
+	// The head keeps a copy of the last record, so only the instance that
+	// wins the swap writes the record.
+	_, swapped, err := s.db.CmpAndSwap(headTable, headKey, head, b)
+	switch {
+	case err != nil:
+		return errors.Wrap(err, "error updating audit head")
+	case !swapped:
+		return ErrConflict
+	}
+	return errors.Wrap(s.db.Set(recordsTable, indexKey(r.Index), b), "error storing audit record")
+}
+
+// Last implements the Store interface.
+func (s *NoSQLStore) Last() (*Record, error) {
+	head, err := s.db.Get(headTable, headKey)
+	switch {
+	case database.IsErrNotFound(err):
+		return nil, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "error loading audit head")
+	}
+	r := new(Record)
+	if err := json.Unmarshal(head, r); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling audit head")
+	}
+	return r, nil
+}
+
+// List implements the Store interface.
+func (s *NoSQLStore) List(from uint64) ([]*Record, error) {
+	last, err := s.Last()
+	if err != nil || last == nil {
+		return []*Record{}, err
+	}
+	entries, err := s.db.List(recordsTable)
+	if err != nil && !database.IsErrNotFound(err) {
+		return nil, errors.Wrap(err, "error listing audit records")
+	}
+	records := make([]*Record, 0, len(entries)+1)
+	for _, e := range entries {
+		r := new(Record)
+		if err := json.Unmarshal(e.Value, r); err != nil {
+			return nil, errors.Wrapf(err, "error unmarshaling audit record %s", e.Key)
+		}
+		if r.Index >= from && r.Index < last.Index {
+			records = append(records, r)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Index < records[j].Index
+	})
+	// The last record is always read from the head, in case the append was
+	// interrupted before writing it.
+	if last.Index >= from {
+		records = append(records, last)
+	}
+	return records, nil
+}
+
+// SaveCheckpoint implements the Store interface.
+func (s *NoSQLStore) SaveCheckpoint(c *Checkpoint) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling audit checkpoint")
+	}
+	return errors.Wrap(s.db.Set(checkpointsTable, indexKey(c.Index), b), "error storing audit checkpoint")
+}
+
+// Checkpoints implements the Store interface.
+func (s *NoSQLStore) Checkpoints() ([]*Checkpoint, error) {
+	entries, err := s.db.List(checkpointsTable)
+	switch {
+	case database.IsErrNotFound(err):
+		return []*Checkpoint{}, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "error listing audit checkpoints")
+	}
+	cps := make([]*Checkpoint, 0, len(entries))
+	for _, e := range entries {
+		c := new(Checkpoint)
+		if err := json.Unmarshal(e.Value, c); err != nil {
+			return nil, errors.Wrapf(err, "error unmarshaling audit checkpoint %s", e.Key)
+		}
+		cps = append(cps, c)
+	}
+	sort.Slice(cps, func(i, j int) bool {
+		return cps[i].Index < cps[j].Index
+	})
+	return cps, nil
+}
@@ -0,0 +1,101 @@
+package authority
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/audit"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/errs"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/keyutil"
+)
+
+func TestAuthority_auditLog(t *testing.T) {
+	a := testAuthority(t, WithDatabase(&db.MockAuthDB{
+		MUseToken:  func(id, tok string) (bool, error) { return true, nil },
+		MIsRevoked: func(string) (bool, error) { return false, nil },
+		MRevoke:    func(rci *db.RevokedCertificateInfo) error { return nil },
+		MShutdown:  func() error { return nil },
+	}), func(a *Authority) error {
+		a.config.Audit = &config.AuditConfig{Enabled: true}
+		return nil
+	})
+	t.Cleanup(func() { a.Shutdown() })
+
+	_, priv, err := keyutil.GenerateDefaultKeyPair()
+	assert.FatalError(t, err)
+	key, err := jose.ReadKey("testdata/secrets/step_cli_key_priv.jwk", jose.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+	token, err := generateToken("smallstep test", "step-cli", testAudiences.Sign[0], []string{"test.smallstep.com"}, time.Now(), key)
+	assert.FatalError(t, err)
+
+	ctx := provisioner.NewContextWithMethod(context.Background(), provisioner.SignMethod)
+	extraOpts, err := a.Authorize(ctx, token)
+	assert.FatalError(t, err)
+	chain, err := a.Sign(getCSR(t, priv), provisioner.SignOptions{}, extraOpts...)
+	assert.FatalError(t, err)
+	renewed, err := a.Renew(chain[0])
+	assert.FatalError(t, err)
+	assert.FatalError(t, a.Revoke(provisioner.NewContextWithMethod(context.Background(), provisioner.RevokeMethod), &RevokeOptions{
+		Serial:     renewed[0].SerialNumber.String(),
+		Crt:        renewed[0],
+		ReasonCode: 1,
+		MTLS:       true,
+	}))
+
+	e, err := a.ExportAuditLog(0)
+	assert.FatalError(t, err)
+	assert.Len(t, 3, e.Records)
+	assert.Len(t, 0, e.Checkpoints)
+	assert.Equals(t, audit.X509SignType, e.Records[0].Type)
+	assert.Equals(t, audit.X509RenewType, e.Records[1].Type)
+	assert.Equals(t, audit.RevokeType, e.Records[2].Type)
+
+	var data x509AuditData
+	assert.FatalError(t, json.Unmarshal(e.Records[1].Data, &data))
+	assert.Equals(t, renewed[0].SerialNumber.String(), data.SerialNumber)
+	assert.Equals(t, chain[0].SerialNumber.String(), data.OldSerialNumber)
+
+	_, err = a.auditLog.Checkpoint()
+	assert.FatalError(t, err)
+	e, err = a.ExportAuditLog(1)
+	assert.FatalError(t, err)
+	assert.Len(t, 2, e.Records)
+	assert.Len(t, 1, e.Checkpoints)
+	assert.NoError(t, a.VerifyAuditLog(e))
+
+	e.Records[0].Data = []byte(`{}`)
+	err = a.VerifyAuditLog(e)
+	assert.Error(t, err)
+	var ee *errs.Error
+	assert.Fatal(t, errors.As(err, &ee))
+	assert.Equals(t, http.StatusBadRequest, ee.StatusCode())
+}
+
+func TestAuthority_auditLog_disabled(t *testing.T) {
+	a := testAuthority(t)
+	_, err := a.ExportAuditLog(0)
+	assert.Error(t, err)
+	_, err = a.GetAuditLogPublicKey()
+	assert.Error(t, err)
+	assert.Error(t, a.VerifyAuditLog(&audit.Export{}))
+}
+
+func TestAuthority_auditLog_noDatabase(t *testing.T) {
+	a := testAuthority(t, func(a *Authority) error {
+		a.config.Audit = &config.AuditConfig{Enabled: true}
+		return nil
+	})
+	t.Cleanup(func() { a.Shutdown() })
+
+	_, err := a.ExportAuditLog(0)
+	assert.FatalError(t, err)
+}
@@ -16,24 +16,45 @@ import (
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/ssh"
 
+	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/kms"
 	kmsapi "go.step.sm/crypto/kms/apiv1"
 	"go.step.sm/crypto/kms/sshagentkms"
 	"go.step.sm/crypto/pemutil"
 	"go.step.sm/linkedca"
 
+	"github.com/smallstep/certificates/authority/activity"
 	"github.com/smallstep/certificates/authority/admin"
 	adminDBNosql "github.com/smallstep/certificates/authority/admin/db/nosql"
 	"github.com/smallstep/certificates/authority/administrator"
+	"github.com/smallstep/certificates/authority/apitoken"
+	"github.com/smallstep/certificates/authority/audit"
+	"github.com/smallstep/certificates/authority/compliance"
 	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/credential"
+	"github.com/smallstep/certificates/authority/decommission"
 	"github.com/smallstep/certificates/authority/internal/constraints"
+	"github.com/smallstep/certificates/authority/keypolicy"
+	"github.com/smallstep/certificates/authority/leader"
+	"github.com/smallstep/certificates/authority/ocspcache"
 	"github.com/smallstep/certificates/authority/policy"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/publisher"
+	"github.com/smallstep/certificates/authority/report"
+	"github.com/smallstep/certificates/authority/revocation"
+	"github.com/smallstep/certificates/authority/settings"
+	"github.com/smallstep/certificates/authority/subca"
+	"github.com/smallstep/certificates/cache"
 	"github.com/smallstep/certificates/cas"
 	casapi "github.com/smallstep/certificates/cas/apiv1"
 	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/internal/chaos"
+	"github.com/smallstep/certificates/internal/secret"
+	"github.com/smallstep/certificates/notify"
 	"github.com/smallstep/certificates/scep"
+	"github.com/smallstep/certificates/smime"
 	"github.com/smallstep/certificates/templates"
+	"github.com/smallstep/certificates/tsa"
 	"github.com/smallstep/nosql"
 )
 
@@ -57,6 +78,7 @@ type Authority struct {
 	rootX509CertPool      *x509.CertPool
 	federatedX509Certs    []*x509.Certificate
 	intermediateX509Certs []*x509.Certificate
+	alternateX509Chains   [][]*x509.Certificate
 	certificates          *sync.Map
 	x509Enforcers         []provisioner.CertificateEnforcer
 
@@ -78,6 +100,93 @@ type Authority struct {
 	crlStopper chan struct{}
 	crlMutex   sync.Mutex
 
+	// CA expiry monitoring vars
+	caExpiryTicker   *time.Ticker
+	caExpiryStopper  chan struct{}
+	caExpiryMutex    sync.Mutex
+	caExpiryNotified map[string]time.Duration
+
+	// Audit log vars
+	auditLog     *audit.Log
+	auditTicker  *time.Ticker
+	auditStopper chan struct{}
+
+	// Leader election of the instance that runs the background jobs
+	leaderLock    leader.Lock
+	leaderElector *leader.Elector
+
+	// RFC 3161 time-stamp authority
+	timestampAuthority *tsa.TSA
+
+	// Signer of the API responses
+	responseSigner jose.Signer
+
+	// Delivery of email and SMS notifications
+	notifier *notify.Notifier
+
+	// Verification of email addresses for S/MIME certificates
+	smimeVerifier *smime.Verifier
+	smimeMailer   smime.Mailer
+
+	// Cache and pre-signing of OCSP responses
+	ocspCache     *ocspcache.Cache
+	ocspTicker    *time.Ticker
+	ocspStopper   chan struct{}
+	ocspResponder *delegatedSigner
+
+	// Delegated OCSP and CRL signers
+	delegatedMutex sync.Mutex
+	ocspSigner     *delegatedSigner
+	crlSigner      *delegatedSigner
+
+	// Delivery of revocation events
+	revocationBroker *revocation.Broker
+
+	// Stream of signed, renewed and revoked certificates
+	activityBroker   *activity.Broker
+	activityNotifier *activity.Notifier
+	activityLogger   *activity.Logger
+
+	// Delivery of the certificates to the stores of the publishers
+	certPublisher *publisher.Publisher
+
+	// Results of the sign requests shown in the dashboards
+	signStats *report.SignStats
+
+	// Constraints on the keys of the signed certificates
+	keyPolicy *keypolicy.Policy
+
+	// Faults injected in the database, the KMS and the ACME validations
+	chaos *chaos.Injector
+
+	// Pending requests of subordinate CA certificates
+	subCAStore subca.Store
+	subCAMutex sync.Mutex
+
+	// Versions of the settings managed through the administration API, and
+	// the settings from the configuration file.
+	settingsStore settings.Store
+	settingsMutex sync.Mutex
+	baseSettings  *settings.Settings
+	settings      *settings.Settings
+
+	// Scoped tokens for the administration API
+	apiTokenStore apitoken.Store
+	apiTokenMutex sync.Mutex
+
+	// Credentials added to the provisioners
+	credentialStore credential.Store
+	credentialMutex sync.Mutex
+
+	// Status of a decommissioned authority
+	decommissionStore       decommission.Store
+	decommissionMutex       sync.Mutex
+	decommissionStatusMutex sync.RWMutex
+	decommissionStatus      *decommission.Status
+
+	// Authorities trusted by this one
+	federationPeers []*federatedPeer
+
 	// If true, do not re-initialize
 	initOnce  bool
 	startTime time.Time
@@ -90,10 +199,19 @@ type Authority struct {
 	authorizeRenewFunc    provisioner.AuthorizeRenewFunc
 	authorizeSSHRenewFunc provisioner.AuthorizeSSHRenewFunc
 
+	// Metrics of the sign flow
+	meter Meter
+
 	// Constraints and Policy engines
 	constraintsEngine *constraints.Engine
 	policyEngine      *policy.Engine
 
+	// Compliance profiles
+	complianceProfiles *compliance.Profiles
+
+	// Store for ephemeral state, like the token replay cache
+	cache cache.Store
+
 	adminMutex sync.RWMutex
 
 	// If true, do not initialize the authority
@@ -276,12 +394,22 @@ func (a *Authority) init() error {
 
 	// Set password if they are not set.
 	var configPassword []byte
-	if a.config.Password != "" {
+	if secret.IsReference(a.config.Password) {
+		buf, err := secret.Get(ctx, a.config.Password)
+		if err != nil {
+			return errors.Wrap(err, "error reading password")
+		}
+		defer buf.Destroy()
+		configPassword = buf.Bytes()
+	} else if a.config.Password != "" {
 		configPassword = []byte(a.config.Password)
 	}
 	if configPassword != nil && a.password == nil {
 		a.password = configPassword
 	}
+	// Keep the passwords in locked memory while the keys are loaded.
+	defer a.lockPasswords()()
+	secret.Wipe(configPassword)
 	if a.sshHostPassword == nil {
 		a.sshHostPassword = a.password
 	}
@@ -302,6 +430,26 @@ func (a *Authority) init() error {
 		}
 	}
 
+	// Inject the configured failures and latency, only binaries built with
+	// the chaos tag have an injector. The db faults are injected in the nosql
+	// databases, other databases would silently ignore them.
+	a.chaos = chaos.New(a.config.Chaos)
+	if a.chaos.HasRules(chaos.TargetDB) {
+		d, ok := a.db.(*db.DB)
+		if !ok {
+			return errors.New("chaos db rules are not supported with the configured database")
+		}
+		d.DB = a.chaos.WrapDB(d.DB)
+	}
+
+	// Initialize the ephemeral state store if it's configured and not already
+	// initialized with WithCache.
+	if a.cache == nil && a.config.Cache != nil {
+		if a.cache, err = cache.New(a.config.Cache); err != nil {
+			return err
+		}
+	}
+
 	// Initialize key manager if it has not been set in the options.
 	if a.keyManager == nil {
 		var options kmsapi.Options
@@ -331,6 +479,10 @@ func (a *Authority) init() error {
 	}
 
 	// Initialize the X.509 CA Service if it has not been set in the options.
+	var (
+		x509Signer crypto.Signer
+		x509Chain  []*x509.Certificate
+	)
 	if a.x509CAService == nil {
 		var options casapi.Options
 		if a.config.AuthorityConfig.Options != nil {
@@ -387,6 +539,12 @@ func (a *Authority) init() error {
 			if err != nil {
 				return err
 			}
+			// Keys in a cloud KMS or HSM can be slow or fail temporarily.
+			options.Signer = a.chaos.WrapSigner(options.Signer)
+			if a.config.KMSSigner != nil || isRemoteKMS(a.config.KMS) {
+				options.Signer = newKMSSigner(options.Signer, a.config.KMSSigner)
+			}
+			x509Signer, x509Chain = options.Signer, options.CertificateChain
 			// If not defined with an option, add intermediates to the list of
 			// certificates used for name constraints validation at issuance
 			// time.
@@ -432,6 +590,13 @@ func (a *Authority) init() error {
 		a.rootX509CertPool.AddCert(cert)
 	}
 
+	// Make sure that only the intermediate key is loaded with an offline root.
+	if a.config.OfflineRoot {
+		if err := a.validateOfflineRoot(x509Signer, x509Chain); err != nil {
+			return err
+		}
+	}
+
 	// Read federated certificates and store them in the certificates map.
 	if len(a.federatedX509Certs) == 0 {
 		a.federatedX509Certs = make([]*x509.Certificate, 0, len(a.config.FederatedRoots))
@@ -448,6 +613,18 @@ func (a *Authority) init() error {
 		a.certificates.Store(hex.EncodeToString(sum[:]), crt)
 	}
 
+	// Read the alternate chains of the intermediate, like the chain with the
+	// intermediate cross-signed by another root.
+	if len(a.alternateX509Chains) == 0 {
+		for _, path := range a.config.AlternateChains {
+			crts, err := pemutil.ReadCertificateBundle(path)
+			if err != nil {
+				return err
+			}
+			a.alternateX509Chains = append(a.alternateX509Chains, crts)
+		}
+	}
+
 	// Decrypt and load SSH keys
 	var tmplVars templates.Step
 	if a.config.SSH != nil {
@@ -459,6 +636,9 @@ func (a *Authority) init() error {
 			if err != nil {
 				return err
 			}
+			if err := a.checkOfflineRootKey(signer.Public(), "ssh host key"); err != nil {
+				return err
+			}
 			// If our signer is from sshagentkms, just unwrap it instead of
 			// wrapping it in another layer, and this prevents crypto from
 			// erroring out with: ssh: unsupported key type *agent.Key
@@ -485,6 +665,9 @@ func (a *Authority) init() error {
 			if err != nil {
 				return err
 			}
+			if err := a.checkOfflineRootKey(signer.Public(), "ssh user key"); err != nil {
+				return err
+			}
 			// If our signer is from sshagentkms, just unwrap it instead of
 			// wrapping it in another layer, and this prevents crypto from
 			// erroring out with: ssh: unsupported key type *agent.Key
@@ -552,7 +735,11 @@ func (a *Authority) init() error {
 			if linkedcaClient != nil {
 				a.adminDB = linkedcaClient
 			} else {
-				a.adminDB, err = adminDBNosql.New(a.db.(nosql.DB), admin.DefaultAuthorityID)
+				ndb, ok := a.db.(nosql.DB)
+				if !ok {
+					return errors.New("enableAdmin is not supported with the configured database")
+				}
+				a.adminDB, err = adminDBNosql.New(ndb, admin.DefaultAuthorityID)
 				if err != nil {
 					return err
 				}
@@ -635,6 +822,11 @@ func (a *Authority) init() error {
 		}
 	}
 
+	// Apply the settings managed through the administration API.
+	if err := a.initSettings(); err != nil {
+		return err
+	}
+
 	// Load Provisioners and Admins
 	if err := a.ReloadAdminResources(ctx); err != nil {
 		return err
@@ -704,6 +896,9 @@ func (a *Authority) init() error {
 		return err
 	}
 
+	// Load compliance profiles
+	a.complianceProfiles = compliance.New(a.config.Compliance)
+
 	// Configure templates, currently only ssh templates are supported.
 	if a.sshCAHostCertSignKey != nil || a.sshCAUserCertSignKey != nil {
 		a.templates = a.config.Templates
@@ -716,6 +911,11 @@ func (a *Authority) init() error {
 		a.templates.Data["Step"] = tmplVars
 	}
 
+	// Load the decommission status before generating the CRL.
+	if err := a.initDecommission(); err != nil {
+		return err
+	}
+
 	// Start the CRL generator, we can assume the configuration is validated.
 	if a.config.CRL.IsEnabled() {
 		// Default cache duration to the default one
@@ -728,6 +928,88 @@ func (a *Authority) init() error {
 		}
 	}
 
+	// Start the audit log.
+	if err := a.initAuditLog(); err != nil {
+		return err
+	}
+
+	// Load the time-stamp authority key and certificate.
+	if err := a.initTSA(); err != nil {
+		return err
+	}
+
+	// Load the key used to sign the API responses.
+	if err := a.initResponseSigning(); err != nil {
+		return err
+	}
+
+	// Create the providers of the email and SMS notifications.
+	if err := a.initNotifications(); err != nil {
+		return err
+	}
+
+	// Configure the one-time codes of the S/MIME issuance API.
+	if err := a.initSMIME(); err != nil {
+		return err
+	}
+
+	// Create the OCSP responses cache and start the pre-signing job.
+	if err := a.initOCSP(); err != nil {
+		return err
+	}
+
+	// Start the monitoring of the expiration of the CA certificates.
+	a.initCAExpiry()
+
+	// Start the delivery of revocation events.
+	a.initRevocationEvents()
+
+	// Start the stream of activity events.
+	if err := a.initActivity(); err != nil {
+		return err
+	}
+
+	// Start the delivery of the certificates to the external stores.
+	a.initPublisher()
+
+	// Start the stats of the sign requests.
+	a.signStats = report.NewSignStats()
+
+	// Create the policy for the keys of the signed certificates.
+	if err := a.initKeyPolicy(); err != nil {
+		return err
+	}
+
+	// Check the database of the identities denied after a revocation.
+	if err := a.initDeniedIdentities(); err != nil {
+		return err
+	}
+
+	// Create the store of the subordinate CA requests.
+	if err := a.initSubCA(); err != nil {
+		return err
+	}
+
+	// Create the store of the API tokens.
+	if err := a.initAPITokens(); err != nil {
+		return err
+	}
+
+	// Create the store of the provisioner credentials.
+	if err := a.initCredentials(); err != nil {
+		return err
+	}
+
+	// Load the roots of the federated authorities.
+	if err := a.initFederation(); err != nil {
+		return err
+	}
+
+	// Start the leader election after the background jobs.
+	if err := a.initLeaderElection(); err != nil {
+		return err
+	}
+
 	// JWT numeric dates are seconds.
 	a.startTime = time.Now().Truncate(time.Second)
 	// Set flag indicating that initialization has been completed, and should
@@ -760,6 +1042,18 @@ func (a *Authority) GetDatabase() db.AuthDB {
 	return a.db
 }
 
+// GetCache returns the store used for ephemeral state, or nil if the
+// configuration does not define one.
+func (a *Authority) GetCache() cache.Store {
+	return a.cache
+}
+
+// GetChaosInjector returns the injector of the configured faults, it is nil
+// if there are none.
+func (a *Authority) GetChaosInjector() *chaos.Injector {
+	return a.chaos
+}
+
 // GetAdminDatabase returns the admin database, if one exists.
 func (a *Authority) GetAdminDatabase() admin.DB {
 	return a.adminDB
@@ -798,10 +1092,22 @@ func (a *Authority) Shutdown() error {
 		a.crlTicker.Stop()
 		close(a.crlStopper)
 	}
+	a.stopAuditLog()
+	a.stopCAExpiry()
+	a.stopOCSP()
+	a.stopRevocationEvents()
+	a.stopActivity()
+	a.stopPublisher()
+	a.stopLeaderElection()
 
 	if err := a.keyManager.Close(); err != nil {
 		log.Printf("error closing the key manager: %v", err)
 	}
+	if a.cache != nil {
+		if err := a.cache.Close(); err != nil {
+			log.Printf("error closing the cache: %v", err)
+		}
+	}
 	return a.db.Shutdown()
 }
 
@@ -811,6 +1117,13 @@ func (a *Authority) CloseForReload() {
 		a.crlTicker.Stop()
 		close(a.crlStopper)
 	}
+	a.stopAuditLog()
+	a.stopCAExpiry()
+	a.stopOCSP()
+	a.stopRevocationEvents()
+	a.stopActivity()
+	a.stopPublisher()
+	a.stopLeaderElection()
 
 	if err := a.keyManager.Close(); err != nil {
 		log.Printf("error closing the key manager: %v", err)
@@ -874,9 +1187,12 @@ func (a *Authority) startCRLGenerator() error {
 	}
 
 	// Always create a new CRL on startup in case the CA has been down and the
-	// time to next expected CRL update is less than the cache duration.
-	if err := a.GenerateCertificateRevocationList(); err != nil {
-		return errors.Wrap(err, "could not generate a CRL")
+	// time to next expected CRL update is less than the cache duration. With
+	// leader election, the CRL is generated when this instance is elected.
+	if !a.config.LeaderElection.IsEnabled() {
+		if err := a.GenerateCertificateRevocationList(); err != nil {
+			return errors.Wrap(err, "could not generate a CRL")
+		}
 	}
 
 	a.crlStopper = make(chan struct{}, 1)
@@ -886,6 +1202,9 @@ func (a *Authority) startCRLGenerator() error {
 		for {
 			select {
 			case <-a.crlTicker.C:
+				if !a.IsLeader() {
+					continue
+				}
 				log.Println("Regenerating CRL")
 				if err := a.GenerateCertificateRevocationList(); err != nil {
 					log.Printf("error regenerating the CRL: %v", err)
@@ -16,8 +16,14 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/apitoken"
 	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/credential"
+	"github.com/smallstep/certificates/authority/decommission"
+	"github.com/smallstep/certificates/authority/keypolicy"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/settings"
+	"github.com/smallstep/certificates/authority/subca"
 	"github.com/smallstep/certificates/db"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/minica"
@@ -577,3 +583,42 @@ func TestAuthority_GetID(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthority_sqlDatabase(t *testing.T) {
+	typ, dsn := os.Getenv("STEP_TEST_SQL_TYPE"), os.Getenv("STEP_TEST_SQL_DATASOURCE")
+	if typ == "" || dsn == "" {
+		t.Skip("STEP_TEST_SQL_TYPE and STEP_TEST_SQL_DATASOURCE are not set")
+	}
+	a := testAuthority(t, func(a *Authority) error {
+		a.config.DB = &db.Config{Type: typ, DataSource: dsn}
+		a.config.AuthorityConfig.EnableAdmin = true
+		a.config.AuthorityConfig.DenyRevokedIdentities = true
+		a.config.AuthorityConfig.KeyPolicy = &keypolicy.Options{
+			BlockCompromisedKeys: true,
+			DuplicateKeys:        keypolicy.DuplicateKeysWarn,
+		}
+		a.config.LeaderElection = &config.LeaderElectionConfig{Enabled: true, ID: "sql-" + time.Now().Format(time.RFC3339Nano)}
+		a.config.Audit = &config.AuditConfig{Enabled: true}
+		a.config.SubCA = &config.SubCAConfig{Enabled: true}
+		a.config.OCSP = &config.OCSPConfig{Enabled: true}
+		return nil
+	})
+	t.Cleanup(func() { a.Shutdown() })
+
+	_, ok := a.db.(*db.SQLDB)
+	assert.True(t, ok)
+	assert.NotNil(t, a.leaderElector)
+	assert.NotNil(t, a.auditLog)
+	assert.NotNil(t, a.ocspCache)
+	assert.Type(t, &settings.NoSQLStore{}, a.settingsStore)
+	assert.Type(t, &subca.NoSQLStore{}, a.subCAStore)
+	assert.Type(t, &decommission.NoSQLStore{}, a.decommissionStore)
+	assert.Type(t, &apitoken.NoSQLStore{}, a.apiTokenStore)
+	assert.Type(t, &credential.NoSQLStore{}, a.credentialStore)
+	_, ok = a.getDeniedIdentityDB()
+	assert.True(t, ok)
+	_, ok = a.getKeyIdentityDB()
+	assert.True(t, ok)
+	_, ok = a.db.(db.BlockedKeyDB)
+	assert.True(t, ok)
+}
@@ -15,8 +15,10 @@ import (
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/authority/admin"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
 	"github.com/smallstep/certificates/errs"
 	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/x509util"
 	"go.step.sm/linkedca"
 	"golang.org/x/crypto/ssh"
 )
@@ -24,9 +26,10 @@ import (
 // Claims extends jose.Claims with step attributes.
 type Claims struct {
 	jose.Claims
-	SANs  []string `json:"sans,omitempty"`
-	Email string   `json:"email,omitempty"`
-	Nonce string   `json:"nonce,omitempty"`
+	SANs  []string                  `json:"sans,omitempty"`
+	Email string                    `json:"email,omitempty"`
+	Nonce string                    `json:"nonce,omitempty"`
+	Cnf   *provisioner.Confirmation `json:"cnf,omitempty"`
 }
 
 type skipTokenReuseKey struct{}
@@ -187,27 +190,63 @@ func (a *Authority) AuthorizeAdminToken(r *http.Request, token string) (*linkedc
 	return adm, nil
 }
 
-// UseToken stores the token to protect against reuse.
+// defaultTokenReplayTTL is the time a used token without expiration is kept in
+// the cache.
+const defaultTokenReplayTTL = 24 * time.Hour
+
+// UseToken stores the token to protect against reuse. If a cache is
+// configured, the token is stored in the cache instead of the database.
 //
 // This method currently ignores any error coming from the GetTokenID, but it
 // should specifically ignore the error provisioner.ErrAllowTokenReuse.
 func (a *Authority) UseToken(token string, prov provisioner.Interface) error {
-	if reuseKey, err := prov.GetTokenID(token); err == nil {
-		if reuseKey == "" {
-			sum := sha256.Sum256([]byte(token))
-			reuseKey = strings.ToLower(hex.EncodeToString(sum[:]))
+	if reuseKey, err := tokenReuseKey(token, prov); err == nil {
+		var ok bool
+		var err error
+		if a.cache != nil {
+			ok, err = a.cache.SetNX(context.Background(), "ott:"+reuseKey, []byte(token), tokenReplayTTL(token))
+		} else {
+			ok, err = a.db.UseToken(reuseKey, token)
 		}
-		ok, err := a.db.UseToken(reuseKey, token)
 		if err != nil {
 			return errs.Wrap(http.StatusInternalServerError, err, "failed when attempting to store token")
 		}
 		if !ok {
-			return errs.Unauthorized("token already used")
+			return errs.Unauthorized("token already used", errs.WithCode(errs.CodeTokenReused))
 		}
 	}
 	return nil
 }
 
+// tokenReuseKey returns the key used to store a token. If we cannot get a
+// token id from the provisioner, the token is hashed.
+func tokenReuseKey(token string, prov provisioner.Interface) (string, error) {
+	reuseKey, err := prov.GetTokenID(token)
+	if err != nil {
+		return "", err
+	}
+	if reuseKey == "" {
+		sum := sha256.Sum256([]byte(token))
+		reuseKey = strings.ToLower(hex.EncodeToString(sum[:]))
+	}
+	return reuseKey, nil
+}
+
+// tokenReplayTTL returns how long a used token is kept in the cache, until it
+// expires plus the leeway used to validate it. Tokens without expiration are
+// kept for defaultTokenReplayTTL.
+func tokenReplayTTL(token string) time.Duration {
+	if tok, err := jose.ParseSigned(token); err == nil {
+		var claims jose.Claims
+		if err := tok.UnsafeClaimsWithoutVerification(&claims); err == nil && claims.Expiry != nil {
+			if d := time.Until(claims.Expiry.Time()) + time.Minute; d > 0 {
+				return d
+			}
+		}
+	}
+	return defaultTokenReplayTTL
+}
+
 // Authorize grabs the method from the context and authorizes the request by
 // validating the one-time-token.
 func (a *Authority) Authorize(ctx context.Context, token string) ([]provisioner.SignOption, error) {
@@ -247,15 +286,41 @@ func (a *Authority) Authorize(ctx context.Context, token string) ([]provisioner.
 // authorizeSign loads the provisioner from the token and calls the provisioner
 // AuthorizeSign method. Returns a list of methods to apply to the signing flow.
 func (a *Authority) authorizeSign(ctx context.Context, token string) ([]provisioner.SignOption, error) {
+	start := time.Now()
 	p, err := a.authorizeToken(ctx, token)
 	if err != nil {
-		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.authorizeSign")
+		err = errs.Wrap(http.StatusInternalServerError, err, "authority.authorizeSign")
+		a.getMeter().X509Authorized(nil, time.Since(start), err)
+		return nil, err
 	}
 	signOpts, err := p.AuthorizeSign(ctx, token)
 	if err != nil {
-		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.authorizeSign")
+		err = errs.Wrap(http.StatusInternalServerError, err, "authority.authorizeSign")
+		a.getMeter().X509Authorized(p, time.Since(start), err)
+		return nil, err
 	}
-	return signOpts, nil
+	cnfOpts, err := confirmationOptions(ctx, token)
+	if err != nil {
+		err = errs.Wrap(http.StatusInternalServerError, err, "authority.authorizeSign")
+		a.getMeter().X509Authorized(p, time.Since(start), err)
+		return nil, err
+	}
+	a.getMeter().X509Authorized(p, time.Since(start), nil)
+	return append(signOpts, cnfOpts...), nil
+}
+
+// confirmationOptions returns the sign options that bind the certificate to
+// the key in the cnf claim of the token. The token must be already validated.
+func confirmationOptions(ctx context.Context, token string) ([]provisioner.SignOption, error) {
+	tok, err := jose.ParseSigned(token)
+	if err != nil {
+		return nil, errs.UnauthorizedErr(err)
+	}
+	var claims Claims
+	if err := tok.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return nil, errs.UnauthorizedErr(err)
+	}
+	return provisioner.ConfirmationOptions(ctx, claims.Cnf)
 }
 
 // AuthorizeSign authorizes a signature request by validating and authenticating
@@ -295,10 +360,16 @@ func (a *Authority) authorizeRenew(ctx context.Context, cert *x509.Certificate)
 		return errs.Wrap(http.StatusInternalServerError, err, "authority.authorizeRenew", opts...)
 	}
 	if isRevoked {
-		return errs.Unauthorized("authority.authorizeRenew: certificate has been revoked", opts...)
-	}
-	p, err := a.LoadProvisionerByCertificate(cert)
-	if err != nil {
+		return errs.Unauthorized("authority.authorizeRenew: certificate has been revoked", append(opts, errs.WithCode(errs.CodeCertificateRevoked))...)
+	}
+	var p provisioner.Interface
+	if peer := a.getRenewalPeer(ctx, cert); peer != nil {
+		// Certificates issued by a federated peer are renewed on behalf of
+		// the provisioner configured for the peer.
+		if p, err = a.loadRenewalPeerProvisioner(peer); err != nil {
+			return err
+		}
+	} else if p, err = a.LoadProvisionerByCertificate(cert); err != nil {
 		var ok bool
 		// For backward compatibility this method will also succeed if the
 		// certificate does not have a provisioner extension. LoadByCertificate
@@ -331,7 +402,7 @@ func (a *Authority) authorizeSSHCertificate(_ context.Context, cert *ssh.Certifi
 		return errs.Wrap(http.StatusInternalServerError, err, "authority.authorizeSSHCertificate", errs.WithKeyVal("serialNumber", serial))
 	}
 	if isRevoked {
-		return errs.Unauthorized("authority.authorizeSSHCertificate: certificate has been revoked", errs.WithKeyVal("serialNumber", serial))
+		return errs.Unauthorized("authority.authorizeSSHCertificate: certificate has been revoked", errs.WithKeyVal("serialNumber", serial), errs.WithCode(errs.CodeCertificateRevoked))
 	}
 	return nil
 }
@@ -392,9 +463,29 @@ func (a *Authority) authorizeSSHRevoke(ctx context.Context, token string) error
 	return nil
 }
 
-// AuthorizeRenewToken validates the renew token and returns the leaf
-// certificate in the x5cInsecure header.
-func (a *Authority) AuthorizeRenewToken(_ context.Context, ott string) (*x509.Certificate, error) {
+// RenewalClaim is the claim of a provisioner token that references the
+// certificate to renew.
+type RenewalClaim struct {
+	SerialNumber string `json:"serialNumber"`
+	Fingerprint  string `json:"fingerprint,omitempty"`
+}
+
+// AuthorizeRenewToken validates the renew token and returns the certificate to
+// renew. The token is either signed by the certificate key, with the
+// certificate in the x5cInsecure header, or it is a provisioner token with a
+// renewal claim, see AuthorizeRenewIdentityToken.
+func (a *Authority) AuthorizeRenewToken(ctx context.Context, ott string) (*x509.Certificate, error) {
+	if tok, err := jose.ParseSigned(ott); err == nil && len(tok.Headers) > 0 {
+		if _, ok := tok.Headers[0].ExtraHeaders[jose.HeaderKey(jose.X5cInsecureKey)]; !ok {
+			var claims struct {
+				Renewal *RenewalClaim `json:"renewal"`
+			}
+			if err := tok.UnsafeClaimsWithoutVerification(&claims); err == nil && claims.Renewal != nil {
+				return a.AuthorizeRenewIdentityToken(ctx, ott)
+			}
+		}
+	}
+
 	var claims jose.Claims
 	jwt, chain, err := jose.ParseX5cInsecure(ott, a.rootX509Certs)
 	if err != nil {
@@ -447,6 +538,74 @@ func (a *Authority) AuthorizeRenewToken(_ context.Context, ott string) (*x509.Ce
 	return leaf, nil
 }
 
+// AuthorizeRenewIdentityToken validates a provisioner token with a renewal
+// claim and returns the referenced certificate. It allows the renewal of
+// certificates by clients that cannot present the certificate, for example,
+// behind a TLS-terminating proxy. The token must be a sign token of the
+// provisioner that issued the certificate, and its subject and SANs must match
+// the certificate ones.
+func (a *Authority) AuthorizeRenewIdentityToken(ctx context.Context, ott string) (*x509.Certificate, error) {
+	tok, err := jose.ParseSigned(ott)
+	if err != nil {
+		return nil, errs.UnauthorizedErr(err, errs.WithMessage("error validating renew token"))
+	}
+	var claims struct {
+		Renewal *RenewalClaim `json:"renewal"`
+	}
+	if err := tok.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return nil, errs.UnauthorizedErr(err, errs.WithMessage("error validating renew token"))
+	}
+	if claims.Renewal == nil || claims.Renewal.SerialNumber == "" {
+		return nil, errs.Unauthorized("error validating renew token: missing renewal serialNumber claim")
+	}
+
+	crt, err := a.db.GetCertificate(claims.Renewal.SerialNumber)
+	if err != nil {
+		if errors.Is(err, db.ErrNotImplemented) {
+			return nil, errs.NotImplemented("error validating renew token: no persistence layer configured")
+		}
+		return nil, errs.Wrap(http.StatusUnauthorized, err, "error validating renew token: certificate not found")
+	}
+	if fp := claims.Renewal.Fingerprint; fp != "" && !strings.EqualFold(fp, x509util.Fingerprint(crt)) {
+		return nil, errs.Unauthorized("error validating renew token: invalid renewal fingerprint claim")
+	}
+
+	signOpts, err := a.authorizeSign(provisioner.NewContextWithMethod(ctx, provisioner.SignMethod), ott)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusUnauthorized, err, "error validating renew token")
+	}
+
+	// The token must be from the provisioner that issued the certificate.
+	p, err := a.LoadProvisionerByCertificate(crt)
+	if err != nil {
+		return nil, errs.Unauthorized("error validating renew token: cannot get provisioner from certificate")
+	}
+	csr := &x509.CertificateRequest{
+		Subject:        crt.Subject,
+		DNSNames:       crt.DNSNames,
+		EmailAddresses: crt.EmailAddresses,
+		IPAddresses:    crt.IPAddresses,
+		URIs:           crt.URIs,
+		PublicKey:      crt.PublicKey,
+	}
+	var tokenProvisioner provisioner.Interface
+	for _, op := range signOpts {
+		switch k := op.(type) {
+		case provisioner.Interface:
+			tokenProvisioner = k
+		case provisioner.CertificateRequestValidator:
+			if err := k.Valid(csr); err != nil {
+				return nil, errs.Forbidden("error validating renew token: the token does not match the certificate: %v", err)
+			}
+		}
+	}
+	if tokenProvisioner == nil || tokenProvisioner.GetID() != p.GetID() {
+		return nil, errs.Unauthorized("error validating renew token: the token is not from the provisioner of the certificate")
+	}
+
+	return crt, nil
+}
+
 // matchesAudience returns true if A and B share at least one element.
 func matchesAudience(as, bs []string) bool {
 	if len(bs) == 0 || len(as) == 0 {
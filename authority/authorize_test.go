@@ -5,6 +5,7 @@ import (
 	"crypto"
 	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
@@ -14,12 +15,14 @@ import (
 	"net/http"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"golang.org/x/crypto/ssh"
 
 	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/keyutil"
 	"go.step.sm/crypto/pemutil"
 	"go.step.sm/crypto/randutil"
 	"go.step.sm/crypto/x509util"
@@ -27,6 +30,7 @@ import (
 	"github.com/smallstep/assert"
 	"github.com/smallstep/certificates/api/render"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/cache"
 	"github.com/smallstep/certificates/db"
 	"github.com/smallstep/certificates/errs"
 )
@@ -317,6 +321,9 @@ func TestAuthority_authorizeToken(t *testing.T) {
 					assert.Fatal(t, errors.As(err, &sc), "error does not implement StatusCodedError interface")
 					assert.Equals(t, sc.StatusCode(), tc.code)
 					assert.HasPrefix(t, err.Error(), tc.err.Error())
+					if tc.err.Error() == "token already used" {
+						assert.Equals(t, errs.CodeTokenReused, errs.CodeOf(err))
+					}
 				}
 			} else {
 				if assert.Nil(t, tc.err) {
@@ -491,8 +498,76 @@ func TestAuthority_authorizeSign(t *testing.T) {
 				}
 			} else {
 				if assert.Nil(t, tc.err) {
-					assert.Equals(t, 10, len(got)) // number of provisioner.SignOptions returned
+					assert.Equals(t, 11, len(got)) // number of provisioner.SignOptions returned
+				}
+			}
+		})
+	}
+}
+
+func TestAuthority_authorizeSign_cnf(t *testing.T) {
+	a := testAuthority(t)
+
+	jwk, err := jose.ReadKey("testdata/secrets/step_cli_key_priv.jwk", jose.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jwk.Key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", jwk.KeyID))
+	assert.FatalError(t, err)
+
+	cert := &x509.Certificate{Raw: []byte("client certificate")}
+	sum := sha256.Sum256(cert.Raw)
+	x5t := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	now := time.Now().UTC()
+	newToken := func(id string, cnf *provisioner.Confirmation) string {
+		cl := Claims{
+			Claims: jose.Claims{
+				Subject:   "test.smallstep.com",
+				Issuer:    "step-cli",
+				NotBefore: jose.NewNumericDate(now),
+				Expiry:    jose.NewNumericDate(now.Add(time.Minute)),
+				Audience:  []string{"https://example.com/sign"},
+				ID:        id,
+			},
+			Cnf: cnf,
+		}
+		raw, err := jose.Signed(sig).Claims(cl).CompactSerialize()
+		assert.FatalError(t, err)
+		return raw
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		token    string
+		wantOpts int
+		err      error
+		code     int
+	}{
+		{"ok/x5t", provisioner.NewContextWithClientCertificate(context.Background(), cert),
+			newToken("1", &provisioner.Confirmation{X5tS256: x5t}), 11, nil, 0},
+		{"ok/jkt", context.Background(),
+			newToken("2", &provisioner.Confirmation{JKT: "thumbprint"}), 12, nil, 0},
+		{"fail/no-certificate", context.Background(),
+			newToken("3", &provisioner.Confirmation{X5tS256: x5t}), 0,
+			errors.New("authority.authorizeSign: token cnf claim requires a client certificate"), http.StatusUnauthorized},
+		{"fail/empty", context.Background(),
+			newToken("4", &provisioner.Confirmation{}), 0,
+			errors.New("authority.authorizeSign: token cnf claim does not contain a supported confirmation method"), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.authorizeSign(tt.ctx, tt.token)
+			if err != nil {
+				if assert.NotNil(t, tt.err) {
+					var sc render.StatusCodedError
+					assert.Fatal(t, errors.As(err, &sc), "error does not implement StatusCodedError interface")
+					assert.Equals(t, sc.StatusCode(), tt.code)
+					assert.HasPrefix(t, err.Error(), tt.err.Error())
 				}
+			} else if assert.Nil(t, tt.err) {
+				assert.Equals(t, tt.wantOpts, len(got))
 			}
 		})
 	}
@@ -1683,3 +1758,160 @@ func TestAuthority_AuthorizeRenewToken(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthority_UseToken_cache(t *testing.T) {
+	a := testAuthority(t, WithCache(cache.NewMemory()), WithDatabase(&db.MockAuthDB{
+		MUseToken: func(id, tok string) (bool, error) {
+			t.Error("database UseToken should not be called")
+			return false, nil
+		},
+	}))
+
+	jwk, err := jose.ReadKey("testdata/secrets/step_cli_key_priv.jwk", jose.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jwk.Key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", jwk.KeyID))
+	assert.FatalError(t, err)
+
+	now := time.Now().UTC()
+	raw, err := jose.Signed(sig).Claims(jose.Claims{
+		Subject:   "test.smallstep.com",
+		Issuer:    "step-cli",
+		NotBefore: jose.NewNumericDate(now),
+		Expiry:    jose.NewNumericDate(now.Add(time.Minute)),
+		Audience:  []string{"https://example.com/revoke"},
+		ID:        "43",
+	}).CompactSerialize()
+	assert.FatalError(t, err)
+
+	_, err = a.authorizeToken(context.Background(), raw)
+	assert.FatalError(t, err)
+	_, err = a.authorizeToken(context.Background(), raw)
+	assert.Equals(t, "token already used", err.Error())
+}
+
+func Test_tokenReplayTTL(t *testing.T) {
+	jwk, err := jose.ReadKey("testdata/secrets/step_cli_key_priv.jwk", jose.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jwk.Key}, nil)
+	assert.FatalError(t, err)
+
+	token := func(exp *jose.NumericDate) string {
+		raw, err := jose.Signed(sig).Claims(jose.Claims{Subject: "sub", Expiry: exp}).CompactSerialize()
+		assert.FatalError(t, err)
+		return raw
+	}
+
+	now := time.Now()
+	ttl := tokenReplayTTL(token(jose.NewNumericDate(now.Add(5 * time.Minute))))
+	assert.True(t, ttl > 5*time.Minute && ttl <= 6*time.Minute, ttl)
+	assert.Equals(t, defaultTokenReplayTTL, tokenReplayTTL(token(nil)))
+	assert.Equals(t, defaultTokenReplayTTL, tokenReplayTTL(token(jose.NewNumericDate(now.Add(-time.Hour)))))
+	assert.Equals(t, defaultTokenReplayTTL, tokenReplayTTL("foo"))
+}
+
+func TestAuthority_AuthorizeRenewIdentityToken(t *testing.T) {
+	ctx := context.Background()
+	a := testAuthority(t)
+	jwk, err := jose.ReadKey("testdata/secrets/step_cli_key_priv.jwk", jose.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+
+	newToken := func(sub string, sans []string, renewal *RenewalClaim) string {
+		so := new(jose.SignerOptions)
+		so.WithType("JWT")
+		so.WithHeader("kid", jwk.KeyID)
+		sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jwk.Key}, so)
+		assert.FatalError(t, err)
+		id, err := randutil.ASCII(64)
+		assert.FatalError(t, err)
+		now := time.Now()
+		tok, err := jose.Signed(sig).Claims(struct {
+			jose.Claims
+			SANs    []string      `json:"sans"`
+			Renewal *RenewalClaim `json:"renewal,omitempty"`
+		}{
+			Claims: jose.Claims{
+				ID:        id,
+				Subject:   sub,
+				Issuer:    "step-cli",
+				IssuedAt:  jose.NewNumericDate(now),
+				NotBefore: jose.NewNumericDate(now),
+				Expiry:    jose.NewNumericDate(now.Add(5 * time.Minute)),
+				Audience:  testAudiences.Sign,
+			},
+			SANs:    sans,
+			Renewal: renewal,
+		}).CompactSerialize()
+		assert.FatalError(t, err)
+		return tok
+	}
+
+	// Sign a certificate using the JWK provisioner.
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+	csr, err := x509util.CreateCertificateRequest("test.example.com", []string{"test.example.com"}, signer)
+	assert.FatalError(t, err)
+	signOpts, err := a.Authorize(provisioner.NewContextWithMethod(ctx, provisioner.SignMethod), newToken("test.example.com", []string{"test.example.com"}, nil))
+	assert.FatalError(t, err)
+	chain, err := a.Sign(csr, provisioner.SignOptions{}, signOpts...)
+	assert.FatalError(t, err)
+	crt := chain[0]
+	serial := crt.SerialNumber.String()
+
+	a.db = &db.MockAuthDB{
+		MUseToken: func(id, tok string) (bool, error) {
+			return true, nil
+		},
+		MGetCertificate: func(serialNumber string) (*x509.Certificate, error) {
+			if serialNumber == serial {
+				return crt, nil
+			}
+			return nil, errors.New("not found")
+		},
+		MGetCertificateData: func(serialNumber string) (*db.CertificateData, error) {
+			return nil, errors.New("not found")
+		},
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		wantErr  string
+		wantCode int
+	}{
+		{"ok", newToken("test.example.com", []string{"test.example.com"}, &RenewalClaim{SerialNumber: serial}), "", 0},
+		{"ok/fingerprint", newToken("test.example.com", []string{"test.example.com"}, &RenewalClaim{SerialNumber: serial, Fingerprint: strings.ToUpper(x509util.Fingerprint(crt))}), "", 0},
+		{"fail/missing-claim", newToken("test.example.com", []string{"test.example.com"}, nil), "error validating renew token: missing renewal serialNumber claim", http.StatusUnauthorized},
+		{"fail/not-found", newToken("test.example.com", []string{"test.example.com"}, &RenewalClaim{SerialNumber: "1234"}), "error validating renew token: certificate not found", http.StatusUnauthorized},
+		{"fail/fingerprint", newToken("test.example.com", []string{"test.example.com"}, &RenewalClaim{SerialNumber: serial, Fingerprint: "0123"}), "error validating renew token: invalid renewal fingerprint claim", http.StatusUnauthorized},
+		{"fail/sans", newToken("test.example.com", []string{"other.example.com"}, &RenewalClaim{SerialNumber: serial}), "error validating renew token: the token does not match the certificate", http.StatusForbidden},
+		{"fail/subject", newToken("other.example.com", []string{"test.example.com"}, &RenewalClaim{SerialNumber: serial}), "error validating renew token: the token does not match the certificate", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.AuthorizeRenewIdentityToken(ctx, tt.token)
+			if tt.wantErr != "" {
+				var sc render.StatusCodedError
+				if assert.True(t, errors.As(err, &sc), "error does not implement StatusCodedError") {
+					assert.Equals(t, tt.wantCode, sc.StatusCode())
+				}
+				assert.HasPrefix(t, err.Error(), tt.wantErr)
+				return
+			}
+			assert.FatalError(t, err)
+			assert.Equals(t, crt, got)
+		})
+	}
+
+	// AuthorizeRenewToken accepts the token with the renewal claim.
+	got, err := a.AuthorizeRenewToken(ctx, newToken("test.example.com", []string{"test.example.com"}, &RenewalClaim{SerialNumber: serial}))
+	assert.FatalError(t, err)
+	assert.Equals(t, crt, got)
+
+	// The token is a one-time token.
+	a.db.(*db.MockAuthDB).MUseToken = func(id, tok string) (bool, error) {
+		return false, nil
+	}
+	_, err = a.AuthorizeRenewToken(ctx, newToken("test.example.com", []string{"test.example.com"}, &RenewalClaim{SerialNumber: serial}))
+	assert.Error(t, err)
+}
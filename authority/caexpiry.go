@@ -0,0 +1,169 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"time"
+
+	"go.step.sm/crypto/x509util"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/notify"
+)
+
+// Types of the CA certificates in a CAExpiration.
+const (
+	CAExpirationRoot         = "root"
+	CAExpirationIntermediate = "intermediate"
+)
+
+// CAExpiration is the expiration of one of the root or intermediate
+// certificates of the CA.
+type CAExpiration struct {
+	Type         string    `json:"type"`
+	Subject      string    `json:"subject"`
+	SerialNumber string    `json:"serialNumber"`
+	Fingerprint  string    `json:"fingerprint"`
+	NotAfter     time.Time `json:"notAfter"`
+	// Threshold is the shortest notification threshold reached by the
+	// certificate, it's not set if the certificate has not reached any.
+	Threshold *provisioner.Duration `json:"threshold,omitempty"`
+}
+
+// IsExpired returns true if the certificate has expired at the given time.
+func (e *CAExpiration) IsExpired(now time.Time) bool {
+	return now.After(e.NotAfter)
+}
+
+// GetCAExpirations returns the expiration of the roots and intermediates of
+// the CA, with the notification threshold they have reached. The public keys
+// of the SSH CA have no expiration, so they are not included.
+func (a *Authority) GetCAExpirations() []*CAExpiration {
+	now := time.Now()
+	thresholds := a.config.CAExpiry.GetThresholds()
+	expirations := make([]*CAExpiration, 0, len(a.rootX509Certs)+len(a.intermediateX509Certs))
+	add := func(typ string, crt *x509.Certificate) {
+		e := &CAExpiration{
+			Type:         typ,
+			Subject:      crt.Subject.CommonName,
+			SerialNumber: crt.SerialNumber.String(),
+			Fingerprint:  x509util.Fingerprint(crt),
+			NotAfter:     crt.NotAfter,
+		}
+		remaining := crt.NotAfter.Sub(now)
+		for _, d := range thresholds {
+			if remaining <= d {
+				e.Threshold = &provisioner.Duration{Duration: d}
+			}
+		}
+		expirations = append(expirations, e)
+	}
+	for _, crt := range a.rootX509Certs {
+		add(CAExpirationRoot, crt)
+	}
+	for _, crt := range a.intermediateX509Certs {
+		add(CAExpirationIntermediate, crt)
+	}
+	return expirations
+}
+
+// initCAExpiry starts the goroutine that checks the expiration of the CA
+// certificates if it's configured.
+func (a *Authority) initCAExpiry() {
+	if a.config.CAExpiry == nil {
+		return
+	}
+	a.caExpiryNotified = make(map[string]time.Duration)
+	a.caExpiryStopper = make(chan struct{}, 1)
+	a.caExpiryTicker = time.NewTicker(a.config.CAExpiry.GetInterval())
+
+	go func() {
+		a.checkCAExpiry()
+		for {
+			select {
+			case <-a.caExpiryTicker.C:
+				a.checkCAExpiry()
+			case <-a.caExpiryStopper:
+				return
+			}
+		}
+	}()
+}
+
+// stopCAExpiry stops the goroutine that checks the expiration of the CA
+// certificates.
+func (a *Authority) stopCAExpiry() {
+	if a.caExpiryTicker == nil {
+		return
+	}
+	a.caExpiryTicker.Stop()
+	close(a.caExpiryStopper)
+}
+
+// checkCAExpiry logs and notifies the certificates that have reached a new
+// threshold. Each threshold is notified once per certificate, and only by the
+// leader.
+func (a *Authority) checkCAExpiry() {
+	if !a.IsLeader() {
+		return
+	}
+	now := time.Now()
+	for _, e := range a.GetCAExpirations() {
+		if e.Threshold == nil {
+			continue
+		}
+		a.caExpiryMutex.Lock()
+		last, ok := a.caExpiryNotified[e.Fingerprint]
+		if ok && last <= e.Threshold.Duration {
+			a.caExpiryMutex.Unlock()
+			continue
+		}
+		a.caExpiryNotified[e.Fingerprint] = e.Threshold.Duration
+		a.caExpiryMutex.Unlock()
+
+		var subject string
+		if e.IsExpired(now) {
+			subject = fmt.Sprintf("The %s certificate %q has expired", e.Type, e.Subject)
+		} else {
+			subject = fmt.Sprintf("The %s certificate %q expires in %s", e.Type, e.Subject, formatRemaining(e.NotAfter.Sub(now)))
+		}
+		log.Printf("warning: %s on %s", subject, e.NotAfter.UTC().Format(time.RFC3339))
+		a.notifyCAExpiry(e, subject)
+	}
+}
+
+// notifyCAExpiry sends an email to the configured recipients with the
+// expiration of a CA certificate. The errors are only logged.
+func (a *Authority) notifyCAExpiry(e *CAExpiration, subject string) {
+	to := a.config.CAExpiry.Notify
+	if len(to) == 0 || !a.notifier.IsEnabled(notify.Email) {
+		return
+	}
+
+	body := fmt.Sprintf("%s.\n\nSubject: %s\nSerial number: %s\nFingerprint: %s\nNot after: %s\n",
+		subject, e.Subject, e.SerialNumber, e.Fingerprint, e.NotAfter.UTC().Format(time.RFC3339))
+
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+	for _, email := range to {
+		if err := a.notifier.Send(ctx, &notify.Message{
+			Channel: notify.Email,
+			To:      email,
+			Subject: subject,
+			Body:    body,
+		}); err != nil {
+			log.Printf("error sending CA expiry notification to %s: %v", email, err)
+		}
+	}
+}
+
+// formatRemaining returns the given duration in days, or in hours if it's
+// less than 2 days.
+func formatRemaining(d time.Duration) string {
+	if d < 48*time.Hour {
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d days", int(d.Hours()/24))
+}
@@ -0,0 +1,73 @@
+package authority
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/notify"
+)
+
+func TestAuthority_GetCAExpirations(t *testing.T) {
+	now := time.Now()
+	root := &x509.Certificate{Subject: pkix.Name{CommonName: "Root CA"}, SerialNumber: big.NewInt(1), NotAfter: now.Add(10 * 365 * 24 * time.Hour)}
+	intermediate := &x509.Certificate{Subject: pkix.Name{CommonName: "Intermediate CA"}, SerialNumber: big.NewInt(2), NotAfter: now.Add(20 * 24 * time.Hour)}
+
+	a := testAuthority(t)
+	a.rootX509Certs = []*x509.Certificate{root}
+	a.intermediateX509Certs = []*x509.Certificate{intermediate}
+
+	got := a.GetCAExpirations()
+	assert.Len(t, 2, got)
+	assert.Equals(t, CAExpirationRoot, got[0].Type)
+	assert.Equals(t, "Root CA", got[0].Subject)
+	assert.Equals(t, "1", got[0].SerialNumber)
+	assert.Nil(t, got[0].Threshold)
+	assert.Equals(t, CAExpirationIntermediate, got[1].Type)
+	assert.Equals(t, "Intermediate CA", got[1].Subject)
+	assert.Equals(t, intermediate.NotAfter, got[1].NotAfter)
+	if assert.NotNil(t, got[1].Threshold) {
+		assert.Equals(t, 30*24*time.Hour, got[1].Threshold.Duration)
+	}
+}
+
+func TestAuthority_checkCAExpiry(t *testing.T) {
+	now := time.Now()
+	intermediate := &x509.Certificate{Subject: pkix.Name{CommonName: "Intermediate CA"}, SerialNumber: big.NewInt(2), NotAfter: now.Add(20 * 24 * time.Hour)}
+
+	p := make(chanProvider, 10)
+	a := testAuthority(t, WithNotifier(notify.NewNotifier(map[notify.Channel]notify.Provider{
+		notify.Email: p,
+	})))
+	a.config.CAExpiry = &config.CAExpiryConfig{Notify: []string{"security@example.com"}}
+	a.caExpiryNotified = make(map[string]time.Duration)
+	a.rootX509Certs = nil
+	a.intermediateX509Certs = []*x509.Certificate{intermediate}
+
+	a.checkCAExpiry()
+	msg := p.receive(t)
+	assert.Equals(t, "security@example.com", msg.To)
+	assert.Equals(t, `The intermediate certificate "Intermediate CA" expires in 19 days`, msg.Subject)
+
+	// The same threshold is not notified twice.
+	a.checkCAExpiry()
+	assert.Len(t, 0, p)
+
+	// The next threshold is notified.
+	intermediate.NotAfter = now.Add(5 * 24 * time.Hour)
+	a.checkCAExpiry()
+	msg = p.receive(t)
+	assert.Equals(t, `The intermediate certificate "Intermediate CA" expires in 4 days`, msg.Subject)
+
+	intermediate.NotAfter = now.Add(-time.Hour)
+	a.checkCAExpiry()
+	msg = p.receive(t)
+	assert.Equals(t, `The intermediate certificate "Intermediate CA" has expired`, msg.Subject)
+	a.checkCAExpiry()
+	assert.Len(t, 0, p)
+}
@@ -0,0 +1,74 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	"go.step.sm/crypto/x509util"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+)
+
+// SignCertManager signs a certificate request of cert-manager with the ACME
+// provisioner configured in certManager.provisioner. The requests are
+// authorized by the approval of cert-manager, so, like with the ACME orders,
+// the claims, policies, templates and webhooks of the provisioner are used
+// without a token. The given validity is clamped to the limits of the
+// provisioner, and the default one is used if it's zero. The given key usage
+// and extended key usages, the usages of the request, replace the ones of the
+// template if any of them is set.
+func (a *Authority) SignCertManager(ctx context.Context, csr *x509.CertificateRequest, validity time.Duration, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage) ([]*x509.Certificate, error) {
+	cfg := a.config.CertManager
+	if !cfg.IsEnabled() {
+		return nil, errs.NotFound("cert-manager issuer is not enabled")
+	}
+	p, err := a.LoadProvisionerByName(cfg.Provisioner)
+	if err != nil {
+		return nil, errs.InternalServerErr(err, errs.WithMessage("error loading cert-manager provisioner"))
+	}
+	acmeProv, ok := p.(*provisioner.ACME)
+	if !ok {
+		return nil, errs.InternalServer("cert-manager provisioner '%s' is not an ACME provisioner", p.GetName())
+	}
+
+	ctx = provisioner.NewContextWithMethod(ctx, provisioner.SignMethod)
+	signOpts, err := acmeProv.AuthorizeSign(ctx, "")
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.SignCertManager")
+	}
+
+	var sans []string
+	sans = append(sans, csr.DNSNames...)
+	sans = append(sans, csr.EmailAddresses...)
+	for _, ip := range csr.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, u := range csr.URIs {
+		sans = append(sans, u.String())
+	}
+	data := x509util.CreateTemplateData(csr.Subject.CommonName, sans)
+	for _, signOp := range signOpts {
+		if wc, ok := signOp.(*provisioner.WebhookController); ok {
+			wc.TemplateData = data
+		}
+	}
+	templateOptions, err := provisioner.TemplateOptions(acmeProv.GetOptions(), data)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.SignCertManager")
+	}
+
+	// The validity options must follow the template options.
+	signOpts = append(signOpts, templateOptions)
+	signOpts = append(signOpts, provisioner.NewTemplateValidityOptions()...)
+	if keyUsage != 0 || len(extKeyUsage) > 0 {
+		signOpts = append(signOpts, provisioner.CertificateModifierFunc(func(crt *x509.Certificate, _ provisioner.SignOptions) error {
+			crt.KeyUsage = keyUsage
+			crt.ExtKeyUsage = extKeyUsage
+			return nil
+		}))
+	}
+	return a.SignWithContext(ctx, csr, provisioner.SignOptions{Validity: validity}, signOpts...)
+}
@@ -0,0 +1,79 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/keyutil"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func TestAuthority_SignCertManager(t *testing.T) {
+	ctx := context.Background()
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+	der, err := x509.CreateCertificateRequest(nil, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "web.default.svc"},
+		DNSNames: []string{"web.default.svc", "web"},
+	}, signer)
+	assert.FatalError(t, err)
+	csr, err := x509.ParseCertificateRequest(der)
+	assert.FatalError(t, err)
+
+	newAuthority := func(t *testing.T) *Authority {
+		return testAuthority(t, func(a *Authority) error {
+			a.config.AuthorityConfig.Provisioners = append(a.config.AuthorityConfig.Provisioners,
+				&provisioner.ACME{Name: "acme", Type: "ACME"})
+			return nil
+		})
+	}
+	statusCode := func(err error) int {
+		var sc interface{ StatusCode() int }
+		if errors.As(err, &sc) {
+			return sc.StatusCode()
+		}
+		return 0
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		a := newAuthority(t)
+		a.config.CertManager = &config.CertManagerConfig{Provisioner: "acme"}
+		chain, err := a.SignCertManager(ctx, csr, time.Hour, 0, nil)
+		assert.FatalError(t, err)
+		leaf := chain[0]
+		assert.Equals(t, "web.default.svc", leaf.Subject.CommonName)
+		assert.Equals(t, []string{"web.default.svc", "web"}, leaf.DNSNames)
+		assert.Equals(t, time.Hour, leaf.NotAfter.Sub(leaf.NotBefore.Add(a.config.AuthorityConfig.Backdate.Duration)))
+		assert.Equals(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}, leaf.ExtKeyUsage)
+	})
+
+	t.Run("ok/usages", func(t *testing.T) {
+		a := newAuthority(t)
+		a.config.CertManager = &config.CertManagerConfig{Provisioner: "acme"}
+		chain, err := a.SignCertManager(ctx, csr, 0, x509.KeyUsageDigitalSignature, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+		assert.FatalError(t, err)
+		leaf := chain[0]
+		assert.Equals(t, x509.KeyUsageDigitalSignature, leaf.KeyUsage)
+		assert.Equals(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, leaf.ExtKeyUsage)
+	})
+
+	t.Run("fail/not-enabled", func(t *testing.T) {
+		a := newAuthority(t)
+		_, err := a.SignCertManager(ctx, csr, 0, 0, nil)
+		assert.Equals(t, 404, statusCode(err))
+	})
+
+	t.Run("fail/not-acme", func(t *testing.T) {
+		a := newAuthority(t)
+		a.config.CertManager = &config.CertManagerConfig{Provisioner: "Max"}
+		_, err := a.SignCertManager(ctx, csr, 0, 0, nil)
+		assert.Equals(t, 500, statusCode(err))
+	})
+}
@@ -0,0 +1,49 @@
+package authority
+
+import (
+	"crypto/x509"
+
+	"github.com/smallstep/certificates/authority/compliance"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+)
+
+// checkCompliance verifies that the certificate template follows the
+// compliance profile of the provisioner, or the default profile of the
+// authority.
+//
+// Certificates with the codeSigning extended key usage can only be issued by
+// provisioners with a code signing profile, so a provisioner used for TLS
+// certificates can never issue them.
+func (a *Authority) checkCompliance(prov provisioner.Interface, leaf *x509.Certificate) error {
+	var profile *compliance.Profile
+	if a.complianceProfiles != nil {
+		var name string
+		if p, ok := prov.(interface{ GetOptions() *provisioner.Options }); ok {
+			name = p.GetOptions().GetX509Options().GetComplianceProfile()
+		}
+		var err error
+		if profile, err = a.complianceProfiles.Select(name); err != nil {
+			return errs.InternalServerErr(err, errs.WithMessage("error loading compliance profile"))
+		}
+	}
+	if hasExtKeyUsage(leaf, x509.ExtKeyUsageCodeSigning) && !profile.AllowsCodeSigning() {
+		return errs.Forbidden("provisioner '%s' is not allowed to issue code signing certificates", prov.GetName())
+	}
+	if profile == nil {
+		return nil
+	}
+	if err := profile.Check(leaf); err != nil {
+		return errs.ForbiddenErr(err, err.Error())
+	}
+	return nil
+}
+
+func hasExtKeyUsage(cert *x509.Certificate, eku x509.ExtKeyUsage) bool {
+	for _, v := range cert.ExtKeyUsage {
+		if v == eku {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,418 @@
+// Package compliance implements named compliance profiles used to prevent
+// the issuance of certificates that do not follow a given set of rules, like
+// the CA/Browser Forum Baseline Requirements.
+//
+// A profile bundles the maximum validity, the allowed keys, the required key
+// usages and extensions and a set of lints. Profiles are selected using the
+// complianceProfile property in the X.509 options of a provisioner, or with
+// the default profile of the authority.
+//
+// Some profiles, like the device profiles for Matter and IEEE 802.1AR, also
+// define the template used by the provisioners without a custom template.
+package compliance
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// Names of the built-in profiles.
+const (
+	// CABFTLSProfile follows the CA/Browser Forum Baseline Requirements for
+	// publicly-trusted TLS server certificates.
+	CABFTLSProfile = "cabf-br-tls"
+	// CABFSMIMEProfile follows the CA/Browser Forum S/MIME Baseline
+	// Requirements for mailbox certificates.
+	CABFSMIMEProfile = "cabf-smime"
+	// CodeSigningProfile follows the CA/Browser Forum Baseline Requirements
+	// for code signing certificates. It's the only built-in profile that
+	// allows the code signing extended key usage.
+	CodeSigningProfile = "code-signing"
+	// InternalProfile is a relaxed profile for certificates used inside an
+	// organization.
+	InternalProfile = "internal"
+)
+
+// Key types that can be used in the allowedKeyTypes property.
+const (
+	KeyTypeRSA     = "RSA"
+	KeyTypeEC      = "EC"
+	KeyTypeEd25519 = "Ed25519"
+)
+
+var extKeyUsages = map[string]x509.ExtKeyUsage{
+	"serverAuth":      x509.ExtKeyUsageServerAuth,
+	"clientAuth":      x509.ExtKeyUsageClientAuth,
+	"codeSigning":     x509.ExtKeyUsageCodeSigning,
+	"emailProtection": x509.ExtKeyUsageEmailProtection,
+	"timeStamping":    x509.ExtKeyUsageTimeStamping,
+	"ocspSigning":     x509.ExtKeyUsageOCSPSigning,
+}
+
+var curves = map[string]bool{
+	"P-256": true,
+	"P-384": true,
+	"P-521": true,
+}
+
+// Profile is a named set of rules that a certificate must follow.
+type Profile struct {
+	Name                 string                `json:"name"`
+	MaxValidity          *provisioner.Duration `json:"maxValidity,omitempty"`
+	MinRSAKeySize        int                   `json:"minRSAKeySize,omitempty"`
+	AllowedKeyTypes      []string              `json:"allowedKeyTypes,omitempty"`
+	AllowedCurves        []string              `json:"allowedCurves,omitempty"`
+	RequiredExtKeyUsages []string              `json:"requiredExtKeyUsages,omitempty"`
+	RequiredExtensions   []string              `json:"requiredExtensions,omitempty"`
+	Lints                []string              `json:"lints,omitempty"`
+}
+
+// Options are the compliance options of the authority.
+type Options struct {
+	// DefaultProfile is the profile used by the provisioners without a
+	// compliance profile. If empty, those provisioners are not restricted.
+	DefaultProfile string `json:"defaultProfile,omitempty"`
+	// Profiles is the list of custom profiles. A custom profile with the name
+	// of a built-in one replaces it.
+	Profiles []*Profile `json:"profiles,omitempty"`
+}
+
+// Validate validates the compliance options.
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	names := make(map[string]bool, len(o.Profiles))
+	for _, p := range o.Profiles {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		if names[p.Name] {
+			return errors.Errorf("compliance profile %q is defined more than once", p.Name)
+		}
+		names[p.Name] = true
+	}
+	if o.DefaultProfile != "" {
+		if _, err := New(o).Get(o.DefaultProfile); err != nil {
+			return errors.Errorf("compliance.defaultProfile %q is not defined", o.DefaultProfile)
+		}
+	}
+	return nil
+}
+
+// Validate validates the profile.
+func (p *Profile) Validate() error {
+	if p == nil {
+		return errors.New("compliance profile cannot be null")
+	}
+	if p.Name == "" {
+		return errors.New("compliance profile name cannot be empty")
+	}
+	if p.MaxValidity != nil && p.MaxValidity.Duration < 0 {
+		return errors.Errorf("compliance profile %q: maxValidity must be greater than or equal to 0", p.Name)
+	}
+	if p.MinRSAKeySize < 0 {
+		return errors.Errorf("compliance profile %q: minRSAKeySize must be greater than or equal to 0", p.Name)
+	}
+	for _, kt := range p.AllowedKeyTypes {
+		switch kt {
+		case KeyTypeRSA, KeyTypeEC, KeyTypeEd25519:
+		default:
+			return errors.Errorf("compliance profile %q: unsupported key type %q", p.Name, kt)
+		}
+	}
+	for _, crv := range p.AllowedCurves {
+		if !curves[crv] {
+			return errors.Errorf("compliance profile %q: unsupported curve %q", p.Name, crv)
+		}
+	}
+	for _, eku := range p.RequiredExtKeyUsages {
+		if _, ok := extKeyUsages[eku]; !ok {
+			return errors.Errorf("compliance profile %q: unsupported extended key usage %q", p.Name, eku)
+		}
+	}
+	for _, oid := range p.RequiredExtensions {
+		if _, err := parseOID(oid); err != nil {
+			return errors.Errorf("compliance profile %q: invalid extension %q", p.Name, oid)
+		}
+	}
+	for _, name := range p.Lints {
+		if _, ok := lints[name]; !ok {
+			return errors.Errorf("compliance profile %q: unsupported lint %q", p.Name, name)
+		}
+	}
+	return nil
+}
+
+// Check verifies that the given certificate follows the rules of the profile.
+// All the violations are reported in the returned error.
+func (p *Profile) Check(cert *x509.Certificate) error {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if p.MaxValidity != nil && p.MaxValidity.Duration > 0 {
+		if d := cert.NotAfter.Sub(cert.NotBefore); d > p.MaxValidity.Duration {
+			add("validity %s is longer than %s", d, p.MaxValidity.Duration)
+		}
+	}
+
+	switch k := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if !p.allowsKeyType(KeyTypeRSA) {
+			add("RSA keys are not allowed")
+		} else if p.MinRSAKeySize > 0 && k.N.BitLen() < p.MinRSAKeySize {
+			add("RSA key size %d is smaller than %d", k.N.BitLen(), p.MinRSAKeySize)
+		}
+	case *ecdsa.PublicKey:
+		if !p.allowsKeyType(KeyTypeEC) {
+			add("EC keys are not allowed")
+		} else if len(p.AllowedCurves) > 0 && !contains(p.AllowedCurves, k.Curve.Params().Name) {
+			add("curve %s is not allowed", k.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		if !p.allowsKeyType(KeyTypeEd25519) {
+			add("Ed25519 keys are not allowed")
+		}
+	default:
+		add("key type %T is not allowed", cert.PublicKey)
+	}
+
+	for _, name := range p.RequiredExtKeyUsages {
+		if !hasExtKeyUsage(cert, extKeyUsages[name]) {
+			add("extended key usage %s is required", name)
+		}
+	}
+
+	for _, s := range p.RequiredExtensions {
+		oid, _ := parseOID(s)
+		if !hasExtension(cert, oid) {
+			add("extension %s is required", s)
+		}
+	}
+
+	for _, name := range p.Lints {
+		if err := lints[name](cert); err != nil {
+			add("%s: %v", name, err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("certificate does not comply with profile %q: %s", p.Name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// AllowsCodeSigning returns if the profile is a code signing profile, a
+// profile that requires the codeSigning extended key usage. Certificates with
+// this extended key usage can only be issued using one of these profiles.
+func (p *Profile) AllowsCodeSigning() bool {
+	return p != nil && contains(p.RequiredExtKeyUsages, "codeSigning")
+}
+
+func (p *Profile) allowsKeyType(kt string) bool {
+	return len(p.AllowedKeyTypes) == 0 || contains(p.AllowedKeyTypes, kt)
+}
+
+// Profiles is the set of the built-in and custom profiles available.
+type Profiles struct {
+	defaultProfile string
+	profiles       map[string]*Profile
+}
+
+// New returns the set of profiles defined by the built-in profiles and the
+// given options.
+func New(o *Options) *Profiles {
+	p := &Profiles{
+		profiles: make(map[string]*Profile),
+	}
+	for _, bp := range builtins() {
+		p.profiles[bp.Name] = bp
+	}
+	if o != nil {
+		p.defaultProfile = o.DefaultProfile
+		for _, cp := range o.Profiles {
+			p.profiles[cp.Name] = cp
+		}
+	}
+	return p
+}
+
+// Get returns the profile with the given name.
+func (p *Profiles) Get(name string) (*Profile, error) {
+	if prof, ok := p.profiles[name]; ok {
+		return prof, nil
+	}
+	return nil, errors.Errorf("compliance profile %q not found", name)
+}
+
+// Select returns the profile with the given name or the default profile if
+// the name is empty. It returns nil if none of them is set.
+func (p *Profiles) Select(name string) (*Profile, error) {
+	if name == "" {
+		if p.defaultProfile == "" {
+			return nil, nil
+		}
+		name = p.defaultProfile
+	}
+	return p.Get(name)
+}
+
+// Names returns the sorted names of the available profiles.
+func (p *Profiles) Names() []string {
+	names := make([]string, 0, len(p.profiles))
+	for name := range p.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func builtins() []*Profile {
+	return append([]*Profile{
+		{
+			Name:                 CABFTLSProfile,
+			MaxValidity:          &provisioner.Duration{Duration: 398 * 24 * time.Hour},
+			MinRSAKeySize:        2048,
+			AllowedKeyTypes:      []string{KeyTypeRSA, KeyTypeEC},
+			AllowedCurves:        []string{"P-256", "P-384", "P-521"},
+			RequiredExtKeyUsages: []string{"serverAuth"},
+			Lints: []string{
+				LintSANRequired, LintCommonNameInSAN, LintNotCA, LintKeyUsagePresent,
+				LintDNSNameValid, LintNoInternalNames, LintNoReservedIP,
+			},
+		},
+		{
+			Name:                 CABFSMIMEProfile,
+			MaxValidity:          &provisioner.Duration{Duration: 825 * 24 * time.Hour},
+			MinRSAKeySize:        2048,
+			AllowedKeyTypes:      []string{KeyTypeRSA, KeyTypeEC, KeyTypeEd25519},
+			AllowedCurves:        []string{"P-256", "P-384", "P-521"},
+			RequiredExtKeyUsages: []string{"emailProtection"},
+			Lints: []string{
+				LintEmailSANRequired, LintNotCA, LintKeyUsagePresent,
+			},
+		},
+		{
+			Name:                 CodeSigningProfile,
+			MaxValidity:          &provisioner.Duration{Duration: 460 * 24 * time.Hour},
+			MinRSAKeySize:        3072,
+			AllowedKeyTypes:      []string{KeyTypeRSA, KeyTypeEC},
+			AllowedCurves:        []string{"P-256", "P-384", "P-521"},
+			RequiredExtKeyUsages: []string{"codeSigning"},
+			Lints: []string{
+				LintNotCA, LintKeyUsagePresent, LintCodeSigningOnly,
+			},
+		},
+		{
+			Name:          InternalProfile,
+			MinRSAKeySize: 2048,
+			Lints: []string{
+				LintNotCA, LintDNSNameValid,
+			},
+		},
+	}, deviceProfiles()...)
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func hasExtKeyUsage(cert *x509.Certificate, eku x509.ExtKeyUsage) bool {
+	for _, v := range cert.ExtKeyUsage {
+		if v == eku {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	oidExtensionSubjectAltName        = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidExtensionKeyUsage              = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidExtensionExtendedKeyUsage      = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidExtensionBasicConstraints      = asn1.ObjectIdentifier{2, 5, 29, 19}
+	oidExtensionCertificatePolicies   = asn1.ObjectIdentifier{2, 5, 29, 32}
+	oidExtensionCRLDistributionPoints = asn1.ObjectIdentifier{2, 5, 29, 31}
+	oidExtensionAuthorityInfoAccess   = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 1}
+)
+
+// hasExtension returns if the certificate template will contain the given
+// extension. The standard extensions are marshaled from the properties of the
+// template at signing time.
+func hasExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	switch {
+	case oid.Equal(oidExtensionSubjectAltName):
+		if len(cert.DNSNames) > 0 || len(cert.EmailAddresses) > 0 || len(cert.IPAddresses) > 0 || len(cert.URIs) > 0 {
+			return true
+		}
+	case oid.Equal(oidExtensionKeyUsage):
+		if cert.KeyUsage != 0 {
+			return true
+		}
+	case oid.Equal(oidExtensionExtendedKeyUsage):
+		if len(cert.ExtKeyUsage) > 0 || len(cert.UnknownExtKeyUsage) > 0 {
+			return true
+		}
+	case oid.Equal(oidExtensionBasicConstraints):
+		if cert.BasicConstraintsValid {
+			return true
+		}
+	case oid.Equal(oidExtensionCertificatePolicies):
+		if len(cert.PolicyIdentifiers) > 0 {
+			return true
+		}
+	case oid.Equal(oidExtensionCRLDistributionPoints):
+		if len(cert.CRLDistributionPoints) > 0 {
+			return true
+		}
+	case oid.Equal(oidExtensionAuthorityInfoAccess):
+		if len(cert.OCSPServer) > 0 || len(cert.IssuingCertificateURL) > 0 {
+			return true
+		}
+	}
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	for _, ext := range cert.ExtraExtensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	var oid asn1.ObjectIdentifier
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, errors.Errorf("invalid object identifier %q", s)
+	}
+	for _, part := range parts {
+		var n int
+		if _, err := fmt.Sscanf(part, "%d", &n); err != nil || n < 0 || fmt.Sprint(n) != part {
+			return nil, errors.Errorf("invalid object identifier %q", s)
+		}
+		oid = append(oid, n)
+	}
+	return oid, nil
+}
@@ -0,0 +1,289 @@
+package compliance
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"go.step.sm/crypto/keyutil"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func mustPublicKey(t *testing.T, kty, crv string, size int) crypto.PublicKey {
+	t.Helper()
+	pub, _, err := keyutil.GenerateKeyPair(kty, crv, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+func tlsCertificate(pub crypto.PublicKey) *x509.Certificate {
+	now := time.Now()
+	return &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "www.smallstep.com"},
+		DNSNames:    []string{"www.smallstep.com", "*.smallstep.com"},
+		IPAddresses: []net.IP{net.ParseIP("1.1.1.1")},
+		NotBefore:   now,
+		NotAfter:    now.Add(90 * 24 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		PublicKey:   pub,
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *Options
+		wantErr string
+	}{
+		{"ok/nil", nil, ""},
+		{"ok/builtin", &Options{DefaultProfile: CABFTLSProfile}, ""},
+		{"ok/custom", &Options{DefaultProfile: "custom", Profiles: []*Profile{{
+			Name:                 "custom",
+			MaxValidity:          &provisioner.Duration{Duration: time.Hour},
+			AllowedKeyTypes:      []string{"EC"},
+			AllowedCurves:        []string{"P-256"},
+			RequiredExtKeyUsages: []string{"clientAuth"},
+			RequiredExtensions:   []string{"1.2.3.4"},
+			Lints:                []string{LintNotCA},
+		}}}, ""},
+		{"fail/default", &Options{DefaultProfile: "foo"}, `compliance.defaultProfile "foo" is not defined`},
+		{"fail/nil", &Options{Profiles: []*Profile{nil}}, "compliance profile cannot be null"},
+		{"fail/name", &Options{Profiles: []*Profile{{}}}, "compliance profile name cannot be empty"},
+		{"fail/duplicated", &Options{Profiles: []*Profile{{Name: "foo"}, {Name: "foo"}}}, `compliance profile "foo" is defined more than once`},
+		{"fail/maxValidity", &Options{Profiles: []*Profile{{Name: "foo", MaxValidity: &provisioner.Duration{Duration: -1}}}}, `compliance profile "foo": maxValidity must be greater than or equal to 0`},
+		{"fail/minRSAKeySize", &Options{Profiles: []*Profile{{Name: "foo", MinRSAKeySize: -1}}}, `compliance profile "foo": minRSAKeySize must be greater than or equal to 0`},
+		{"fail/keyType", &Options{Profiles: []*Profile{{Name: "foo", AllowedKeyTypes: []string{"DSA"}}}}, `compliance profile "foo": unsupported key type "DSA"`},
+		{"fail/curve", &Options{Profiles: []*Profile{{Name: "foo", AllowedCurves: []string{"P-224"}}}}, `compliance profile "foo": unsupported curve "P-224"`},
+		{"fail/extKeyUsage", &Options{Profiles: []*Profile{{Name: "foo", RequiredExtKeyUsages: []string{"any"}}}}, `compliance profile "foo": unsupported extended key usage "any"`},
+		{"fail/extension", &Options{Profiles: []*Profile{{Name: "foo", RequiredExtensions: []string{"1.a"}}}}, `compliance profile "foo": invalid extension "1.a"`},
+		{"fail/lint", &Options{Profiles: []*Profile{{Name: "foo", Lints: []string{"e_foo"}}}}, `compliance profile "foo": unsupported lint "e_foo"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Options.Validate() error = %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("Options.Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProfiles_Select(t *testing.T) {
+	custom := &Profile{Name: CABFTLSProfile}
+	p := New(&Options{DefaultProfile: InternalProfile, Profiles: []*Profile{custom}})
+
+	got, err := p.Select(CABFTLSProfile)
+	if err != nil || got != custom {
+		t.Errorf("Profiles.Select() = %v, %v, want the custom profile", got, err)
+	}
+	if got, err = p.Select(""); err != nil || got.Name != InternalProfile {
+		t.Errorf("Profiles.Select() = %v, %v, want the default profile", got, err)
+	}
+	if _, err = p.Select("foo"); err == nil {
+		t.Error("Profiles.Select() error = nil")
+	}
+	if got, err = New(nil).Select(""); err != nil || got != nil {
+		t.Errorf("Profiles.Select() = %v, %v, want nil, nil", got, err)
+	}
+	if names := New(nil).Names(); strings.Join(names, ",") != "cabf-br-tls,cabf-smime,code-signing,ieee-802.1ar-idevid,ieee-802.1ar-ldevid,internal,matter-dac,matter-pai" {
+		t.Errorf("Profiles.Names() = %v", names)
+	}
+}
+
+func TestProfile_Check(t *testing.T) {
+	ec := mustPublicKey(t, "EC", "P-256", 0)
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weak := &weakKey.PublicKey
+	ed := mustPublicKey(t, "OKP", "Ed25519", 0)
+	p384 := mustPublicKey(t, "EC", "P-384", 0)
+
+	profiles := New(nil)
+	tlsProfile, _ := profiles.Get(CABFTLSProfile)
+	smimeProfile, _ := profiles.Get(CABFSMIMEProfile)
+	internalProfile, _ := profiles.Get(InternalProfile)
+	codeSigningProfile, _ := profiles.Get(CodeSigningProfile)
+	codeSigningCertificate := func() *x509.Certificate {
+		return &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "Smallstep Labs"},
+			NotBefore:   time.Now(),
+			NotAfter:    time.Now().Add(365 * 24 * time.Hour),
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+			PublicKey:   p384,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		profile *Profile
+		cert    func() *x509.Certificate
+		wantErr []string
+	}{
+		{"ok/tls", tlsProfile, func() *x509.Certificate { return tlsCertificate(ec) }, nil},
+		{"ok/smime", smimeProfile, func() *x509.Certificate {
+			return &x509.Certificate{
+				EmailAddresses: []string{"jane@smallstep.com"},
+				NotBefore:      time.Now(),
+				NotAfter:       time.Now().Add(365 * 24 * time.Hour),
+				KeyUsage:       x509.KeyUsageDigitalSignature,
+				ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+				PublicKey:      ed,
+			}
+		}, nil},
+		{"ok/internal", internalProfile, func() *x509.Certificate {
+			c := tlsCertificate(ed)
+			c.DNSNames = []string{"db.internal"}
+			c.NotAfter = c.NotBefore.Add(10 * 365 * 24 * time.Hour)
+			return c
+		}, nil},
+		{"ok/code-signing", codeSigningProfile, codeSigningCertificate, nil},
+		{"ok/requiredExtensions", &Profile{Name: "custom", RequiredExtensions: []string{"2.5.29.17", "2.5.29.15", "1.2.3.4"}}, func() *x509.Certificate {
+			c := tlsCertificate(ec)
+			c.ExtraExtensions = []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3, 4}}}
+			return c
+		}, nil},
+		{"fail/tls", tlsProfile, func() *x509.Certificate {
+			c := tlsCertificate(weak)
+			c.Subject.CommonName = "foo"
+			c.DNSNames = append(c.DNSNames, "printer", "host.local", "foo_bar.smallstep.com")
+			c.IPAddresses = []net.IP{net.ParseIP("10.0.0.1")}
+			c.NotAfter = c.NotBefore.Add(400 * 24 * time.Hour)
+			c.KeyUsage = 0
+			c.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+			c.IsCA = true
+			return c
+		}, []string{
+			`certificate does not comply with profile "cabf-br-tls"`,
+			"validity 9600h0m0s is longer than 9552h0m0s",
+			"RSA key size 1024 is smaller than 2048",
+			"extended key usage serverAuth is required",
+			`e_sub_cert_cn_in_san: common name "foo" is not a subject alternative name`,
+			"e_sub_cert_not_ca: certificate cannot be a CA",
+			"e_sub_cert_key_usage_present: key usage is required",
+			`e_dns_name_valid: dns name "foo_bar.smallstep.com" is not valid`,
+			`e_no_internal_names: dns name "printer" is an internal name`,
+			"e_no_reserved_ip: ip address 10.0.0.1 is reserved",
+		}},
+		{"fail/tls-keys", tlsProfile, func() *x509.Certificate {
+			c := tlsCertificate(ed)
+			c.DNSNames, c.IPAddresses = nil, nil
+			c.Subject.CommonName = ""
+			return c
+		}, []string{
+			"Ed25519 keys are not allowed",
+			"e_sub_cert_san_required: subject alternative names are required",
+		}},
+		{"fail/curve", &Profile{Name: "custom", AllowedCurves: []string{"P-256"}}, func() *x509.Certificate {
+			return tlsCertificate(p384)
+		}, []string{"curve P-384 is not allowed"}},
+		{"fail/keyType", &Profile{Name: "custom", AllowedKeyTypes: []string{"RSA"}}, func() *x509.Certificate {
+			return tlsCertificate(ec)
+		}, []string{"EC keys are not allowed"}},
+		{"fail/smime", smimeProfile, func() *x509.Certificate {
+			return tlsCertificate(ec)
+		}, []string{
+			"extended key usage emailProtection is required",
+			"e_email_san_required: an email address is required",
+		}},
+		{"fail/code-signing", codeSigningProfile, func() *x509.Certificate {
+			c := codeSigningCertificate()
+			c.PublicKey = weak
+			c.DNSNames = []string{"www.smallstep.com"}
+			c.KeyUsage = x509.KeyUsageKeyEncipherment
+			c.ExtKeyUsage = append(c.ExtKeyUsage, x509.ExtKeyUsageServerAuth)
+			return c
+		}, []string{
+			"RSA key size 1024 is smaller than 3072",
+			"e_code_signing_only: codeSigning must be the only extended key usage",
+		}},
+		{"fail/code-signing-names", codeSigningProfile, func() *x509.Certificate {
+			c := codeSigningCertificate()
+			c.DNSNames = []string{"www.smallstep.com"}
+			return c
+		}, []string{"e_code_signing_only: DNS names and IP addresses are not allowed"}},
+		{"fail/code-signing-eku", codeSigningProfile, func() *x509.Certificate {
+			return tlsCertificate(ec)
+		}, []string{
+			"extended key usage codeSigning is required",
+			"e_code_signing_only: codeSigning must be the only extended key usage",
+		}},
+		{"fail/requiredExtensions", &Profile{Name: "custom", RequiredExtensions: []string{"2.5.29.31", "1.3.6.1.5.5.7.1.1"}}, func() *x509.Certificate {
+			return tlsCertificate(ec)
+		}, []string{
+			"extension 2.5.29.31 is required",
+			"extension 1.3.6.1.5.5.7.1.1 is required",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.profile.Check(tt.cert())
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Profile.Check() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Profile.Check() error = nil")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Profile.Check() error = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProfile_AllowsCodeSigning(t *testing.T) {
+	profiles := New(nil)
+	for _, name := range profiles.Names() {
+		p, _ := profiles.Get(name)
+		if got := p.AllowsCodeSigning(); got != (name == CodeSigningProfile) {
+			t.Errorf("Profile.AllowsCodeSigning() for %s = %v", name, got)
+		}
+	}
+	var p *Profile
+	if p.AllowsCodeSigning() {
+		t.Error("Profile.AllowsCodeSigning() for nil = true")
+	}
+}
+
+func Test_isValidDNSName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"smallstep.com", true},
+		{"*.smallstep.com", true},
+		{"a-b.smallstep.com.", true},
+		{"", false},
+		{"*", false},
+		{"foo.*.smallstep.com", false},
+		{"-foo.smallstep.com", false},
+		{"foo..com", false},
+		{strings.Repeat("a", 64) + ".com", false},
+	}
+	for _, tt := range tests {
+		if got := isValidDNSName(tt.name); got != tt.want {
+			t.Errorf("isValidDNSName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package compliance
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// Names of the built-in device profiles.
+const (
+	// MatterDACProfile follows the Matter specification for Device
+	// Attestation Certificates (DAC).
+	MatterDACProfile = "matter-dac"
+	// MatterPAIProfile follows the Matter specification for Product
+	// Attestation Intermediate (PAI) certificates.
+	MatterPAIProfile = "matter-pai"
+	// IDevIDProfile follows IEEE 802.1AR for Initial Device Identifier
+	// (IDevID) certificates.
+	IDevIDProfile = "ieee-802.1ar-idevid"
+	// LDevIDProfile follows IEEE 802.1AR for Locally significant Device
+	// Identifier (LDevID) certificates.
+	LDevIDProfile = "ieee-802.1ar-ldevid"
+)
+
+var (
+	oidMatterVendorID  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37244, 2, 1}
+	oidMatterProductID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37244, 2, 2}
+	oidSerialNumber    = asn1.ObjectIdentifier{2, 5, 4, 5}
+)
+
+// MatterDACTemplate is the default template of the matter-dac profile. The
+// vendor and product ids must be set in the template data of the provisioner
+// as vendorId and productId, using four uppercase hexadecimal digits.
+const MatterDACTemplate = `{
+	"subject": {
+		"commonName": {{ toJson .Subject.CommonName }},
+		"extraNames": [
+			{"type": "1.3.6.1.4.1.37244.2.1", "value": {{ toJson .vendorId }}},
+			{"type": "1.3.6.1.4.1.37244.2.2", "value": {{ toJson .productId }}}
+		]
+	},
+	"keyUsage": ["digitalSignature"],
+	"basicConstraints": {"isCA": false}
+}`
+
+// MatterPAITemplate is the default template of the matter-pai profile. The
+// vendor id must be set in the template data of the provisioner as vendorId,
+// and the product id can be set as productId.
+const MatterPAITemplate = `{
+	"subject": {
+		"commonName": {{ toJson .Subject.CommonName }},
+		"extraNames": [
+			{"type": "1.3.6.1.4.1.37244.2.1", "value": {{ toJson .vendorId }}}
+{{- if .productId }},
+			{"type": "1.3.6.1.4.1.37244.2.2", "value": {{ toJson .productId }}}
+{{- end }}
+		]
+	},
+	"keyUsage": ["certSign", "crlSign"],
+	"basicConstraints": {"isCA": true, "maxPathLen": 0}
+}`
+
+// IDevIDTemplate is the default template of the ieee-802.1ar-idevid profile.
+// The subject of the token is used as the device serial number. If the
+// template data of the provisioner contains a hardwareModuleType, it's used
+// with the serial number in a hardwareModuleName subject alternative name.
+const IDevIDTemplate = `{
+	"subject": {
+		"commonName": {{ toJson .Subject.CommonName }},
+		"serialNumber": {{ toJson .Subject.CommonName }}
+{{- if .organization }},
+		"organization": {{ toJson .organization }}
+{{- end }}
+	},
+	"sans": [
+{{- range $i, $san := .SANs }}{{ if $i }},{{ end }}
+		{{ toJson $san }}
+{{- end }}
+{{- if .hardwareModuleType }}{{ if .SANs }},{{ end }}
+		{"type": "hardwareModuleName", "asn1Value": {"type": {{ toJson .hardwareModuleType }}, "serialNumber": {{ .Subject.CommonName | b64enc | toJson }}}}
+{{- end }}
+	],
+{{- if typeIs "*rsa.PublicKey" .Insecure.CR.PublicKey }}
+	"keyUsage": ["digitalSignature", "keyEncipherment"],
+{{- else }}
+	"keyUsage": ["digitalSignature"],
+{{- end }}
+	"basicConstraints": {"isCA": false}
+}`
+
+// LDevIDTemplate is the default template of the ieee-802.1ar-ldevid profile.
+const LDevIDTemplate = `{
+	"subject": {{ toJson .Subject }},
+	"sans": {{ toJson .SANs }},
+{{- if typeIs "*rsa.PublicKey" .Insecure.CR.PublicKey }}
+	"keyUsage": ["digitalSignature", "keyEncipherment"],
+{{- else }}
+	"keyUsage": ["digitalSignature"],
+{{- end }}
+	"extKeyUsage": ["clientAuth"],
+	"basicConstraints": {"isCA": false}
+}`
+
+func init() {
+	provisioner.RegisterProfileTemplate(MatterDACProfile, MatterDACTemplate)
+	provisioner.RegisterProfileTemplate(MatterPAIProfile, MatterPAITemplate)
+	provisioner.RegisterProfileTemplate(IDevIDProfile, IDevIDTemplate)
+	provisioner.RegisterProfileTemplate(LDevIDProfile, LDevIDTemplate)
+}
+
+func deviceProfiles() []*Profile {
+	return []*Profile{
+		{
+			Name:            MatterDACProfile,
+			AllowedKeyTypes: []string{KeyTypeEC},
+			AllowedCurves:   []string{"P-256"},
+			Lints: []string{
+				LintMatterVendorID, LintMatterProductID, LintMatterDAC,
+			},
+		},
+		{
+			Name:            MatterPAIProfile,
+			AllowedKeyTypes: []string{KeyTypeEC},
+			AllowedCurves:   []string{"P-256"},
+			Lints: []string{
+				LintMatterVendorID, LintMatterPAI,
+			},
+		},
+		{
+			Name:            IDevIDProfile,
+			MinRSAKeySize:   2048,
+			AllowedKeyTypes: []string{KeyTypeRSA, KeyTypeEC},
+			AllowedCurves:   []string{"P-256", "P-384"},
+			Lints: []string{
+				LintNotCA, LintKeyUsagePresent, LintDevIDSerialNumber,
+			},
+		},
+		{
+			Name:            LDevIDProfile,
+			MinRSAKeySize:   2048,
+			AllowedKeyTypes: []string{KeyTypeRSA, KeyTypeEC},
+			AllowedCurves:   []string{"P-256", "P-384"},
+			Lints: []string{
+				LintNotCA, LintKeyUsagePresent,
+			},
+		},
+	}
+}
+
+// subjectValues returns the values of the subject attribute with the given
+// type. Templates add the attributes to ExtraNames, parsed certificates have
+// them in Names.
+func subjectValues(cert *x509.Certificate, oid asn1.ObjectIdentifier) []string {
+	var values []string
+	for _, names := range [][]pkix.AttributeTypeAndValue{cert.Subject.Names, cert.Subject.ExtraNames} {
+		for _, atv := range names {
+			if atv.Type.Equal(oid) {
+				values = append(values, fmt.Sprint(atv.Value))
+			}
+		}
+	}
+	return values
+}
+
+// isMatterID returns if the value is a Matter vendor or product id, encoded as
+// four uppercase hexadecimal digits.
+func isMatterID(s string) bool {
+	if len(s) != 4 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func lintMatterID(cert *x509.Certificate, oid asn1.ObjectIdentifier, name string, required bool) error {
+	values := subjectValues(cert, oid)
+	switch {
+	case len(values) == 0 && !required:
+		return nil
+	case len(values) != 1:
+		return errors.Errorf("subject must contain one %s", name)
+	case !isMatterID(values[0]):
+		return errors.Errorf("%s %q must be four uppercase hexadecimal digits", name, values[0])
+	}
+	return nil
+}
+
+func lintMatterVendorID(cert *x509.Certificate) error {
+	if err := lintMatterID(cert, oidMatterVendorID, "vendor id", true); err != nil {
+		return err
+	}
+	return lintMatterID(cert, oidMatterProductID, "product id", false)
+}
+
+func lintMatterProductID(cert *x509.Certificate) error {
+	return lintMatterID(cert, oidMatterProductID, "product id", true)
+}
+
+func lintMatterDAC(cert *x509.Certificate) error {
+	if !cert.BasicConstraintsValid || cert.IsCA {
+		return errors.New("basic constraints must be present and not a CA")
+	}
+	if cert.KeyUsage != x509.KeyUsageDigitalSignature {
+		return errors.New("key usage must only be digitalSignature")
+	}
+	return nil
+}
+
+func lintMatterPAI(cert *x509.Certificate) error {
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return errors.New("basic constraints must be present and a CA")
+	}
+	if cert.MaxPathLen != 0 || (cert.MaxPathLen == 0 && !cert.MaxPathLenZero) {
+		return errors.New("path length constraint must be 0")
+	}
+	required := x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	if cert.KeyUsage&required != required || cert.KeyUsage&^(required|x509.KeyUsageDigitalSignature) != 0 {
+		return errors.New("key usage must be keyCertSign and cRLSign, and optionally digitalSignature")
+	}
+	return nil
+}
+
+func lintDevIDSerialNumber(cert *x509.Certificate) error {
+	if cert.Subject.SerialNumber == "" && len(subjectValues(cert, oidSerialNumber)) == 0 {
+		return errors.New("subject serialNumber is required")
+	}
+	return nil
+}
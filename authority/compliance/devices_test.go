@@ -0,0 +1,197 @@
+package compliance
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"strings"
+	"testing"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/x509util"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func renderDeviceTemplate(t *testing.T, profile, templateData string, signer crypto.Signer, sans ...string) *x509.Certificate {
+	t.Helper()
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := provisioner.CustomTemplateOptions(&provisioner.Options{
+		X509: &provisioner.X509Options{ComplianceProfile: profile, TemplateData: []byte(templateData)},
+	}, x509util.CreateTemplateData("SN-1234", sans), x509util.DefaultLeafTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509util.NewCertificate(csr, opts.Options(provisioner.SignOptions{})...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.GetCertificate()
+}
+
+func TestDeviceProfiles(t *testing.T) {
+	p256, err := keyutil.GenerateSigner("EC", "P-256", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsa2048, err := keyutil.GenerateSigner("RSA", "", 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles := New(nil)
+
+	tests := []struct {
+		name         string
+		profile      string
+		templateData string
+		signer       crypto.Signer
+		sans         []string
+		check        func(t *testing.T, cert *x509.Certificate)
+	}{
+		{"matter-dac", MatterDACProfile, `{"vendorId":"FFF1","productId":"8000"}`, p256, nil, func(t *testing.T, cert *x509.Certificate) {
+			if v := subjectValues(cert, oidMatterVendorID); len(v) != 1 || v[0] != "FFF1" {
+				t.Errorf("vendor id = %v", v)
+			}
+			if v := subjectValues(cert, oidMatterProductID); len(v) != 1 || v[0] != "8000" {
+				t.Errorf("product id = %v", v)
+			}
+		}},
+		{"matter-pai", MatterPAIProfile, `{"vendorId":"FFF1"}`, p256, nil, func(t *testing.T, cert *x509.Certificate) {
+			if !cert.IsCA || !cert.MaxPathLenZero {
+				t.Errorf("IsCA = %v, MaxPathLenZero = %v", cert.IsCA, cert.MaxPathLenZero)
+			}
+			if v := subjectValues(cert, oidMatterProductID); len(v) != 0 {
+				t.Errorf("product id = %v", v)
+			}
+		}},
+		{"idevid", IDevIDProfile, `{"hardwareModuleType":"1.3.6.1.4.1.45724.1.1"}`, rsa2048, []string{"device.smallstep.com"}, func(t *testing.T, cert *x509.Certificate) {
+			if cert.Subject.SerialNumber != "SN-1234" {
+				t.Errorf("Subject.SerialNumber = %s", cert.Subject.SerialNumber)
+			}
+			if cert.KeyUsage != x509.KeyUsageDigitalSignature|x509.KeyUsageKeyEncipherment {
+				t.Errorf("KeyUsage = %v", cert.KeyUsage)
+			}
+			sans, err := provisioner.ParseOtherNameSANs(cert.ExtraExtensions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sans) != 1 || sans[0].Type != x509util.HardwareModuleNameType {
+				t.Errorf("otherName SANs = %v", sans)
+			}
+		}},
+		{"idevid/no-hardware-module", IDevIDProfile, "", p256, nil, func(t *testing.T, cert *x509.Certificate) {
+			if cert.Subject.SerialNumber != "SN-1234" {
+				t.Errorf("Subject.SerialNumber = %s", cert.Subject.SerialNumber)
+			}
+		}},
+		{"ldevid", LDevIDProfile, "", p256, []string{"device.smallstep.com"}, func(t *testing.T, cert *x509.Certificate) {
+			if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "device.smallstep.com" {
+				t.Errorf("DNSNames = %v", cert.DNSNames)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := renderDeviceTemplate(t, tt.profile, tt.templateData, tt.signer, tt.sans...)
+			p, err := profiles.Get(tt.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Check(cert); err != nil {
+				t.Errorf("Profile.Check() error = %v", err)
+			}
+			tt.check(t, cert)
+		})
+	}
+}
+
+func TestDeviceProfiles_fail(t *testing.T) {
+	p384 := mustPublicKey(t, "EC", "P-384", 0)
+	profiles := New(nil)
+	dac, _ := profiles.Get(MatterDACProfile)
+	pai, _ := profiles.Get(MatterPAIProfile)
+	idevid, _ := profiles.Get(IDevIDProfile)
+
+	matterSubject := func(vid, pid string) pkix.Name {
+		var names []pkix.AttributeTypeAndValue
+		if vid != "" {
+			names = append(names, pkix.AttributeTypeAndValue{Type: oidMatterVendorID, Value: vid})
+		}
+		if pid != "" {
+			names = append(names, pkix.AttributeTypeAndValue{Type: oidMatterProductID, Value: pid})
+		}
+		return pkix.Name{CommonName: "Matter", ExtraNames: names}
+	}
+
+	tests := []struct {
+		name    string
+		profile *Profile
+		cert    *x509.Certificate
+		wantErr []string
+	}{
+		{"matter-dac", dac, &x509.Certificate{
+			Subject:   matterSubject("fff1", ""),
+			KeyUsage:  x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			PublicKey: p384,
+		}, []string{
+			"curve P-384 is not allowed",
+			`e_matter_vid: vendor id "fff1" must be four uppercase hexadecimal digits`,
+			"e_matter_pid: subject must contain one product id",
+			"e_matter_dac: basic constraints must be present and not a CA",
+		}},
+		{"matter-dac/key-usage", dac, &x509.Certificate{
+			Subject:               matterSubject("FFF1", "8000"),
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			PublicKey:             p384,
+		}, []string{"e_matter_dac: key usage must only be digitalSignature"}},
+		{"matter-pai", pai, &x509.Certificate{
+			Subject:               matterSubject("", "80001"),
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+			MaxPathLen:            -1,
+			KeyUsage:              x509.KeyUsageCertSign,
+			PublicKey:             p384,
+		}, []string{
+			"e_matter_vid: subject must contain one vendor id",
+			"e_matter_pai: path length constraint must be 0",
+		}},
+		{"matter-pai/key-usage", pai, &x509.Certificate{
+			Subject:               matterSubject("FFF1", "80001"),
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+			MaxPathLenZero:        true,
+			KeyUsage:              x509.KeyUsageCertSign,
+			PublicKey:             p384,
+		}, []string{
+			`e_matter_vid: product id "80001" must be four uppercase hexadecimal digits`,
+			"e_matter_pai: key usage must be keyCertSign and cRLSign, and optionally digitalSignature",
+		}},
+		{"idevid", idevid, &x509.Certificate{
+			Subject:   pkix.Name{CommonName: "device"},
+			KeyUsage:  x509.KeyUsageDigitalSignature,
+			PublicKey: p384,
+		}, []string{"e_devid_serial_number: subject serialNumber is required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.profile.Check(tt.cert)
+			if err == nil {
+				t.Fatal("Profile.Check() error = nil")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Profile.Check() error = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
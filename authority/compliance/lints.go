@@ -0,0 +1,207 @@
+package compliance
+
+import (
+	"crypto/x509"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Names of the available lints.
+const (
+	// LintSANRequired requires at least one subject alternative name.
+	LintSANRequired = "e_sub_cert_san_required"
+	// LintCommonNameInSAN requires the common name, if present, to be one of
+	// the DNS or IP subject alternative names.
+	LintCommonNameInSAN = "e_sub_cert_cn_in_san"
+	// LintNotCA requires the certificate not to be a CA.
+	LintNotCA = "e_sub_cert_not_ca"
+	// LintKeyUsagePresent requires the key usage extension.
+	LintKeyUsagePresent = "e_sub_cert_key_usage_present"
+	// LintDNSNameValid requires the DNS names to be valid host names, with an
+	// optional wildcard in the left-most label.
+	LintDNSNameValid = "e_dns_name_valid"
+	// LintNoInternalNames rejects single-label DNS names and names using
+	// reserved or internal top-level domains.
+	LintNoInternalNames = "e_no_internal_names"
+	// LintNoReservedIP rejects private, loopback, link-local and unspecified
+	// IP addresses.
+	LintNoReservedIP = "e_no_reserved_ip"
+	// LintEmailSANRequired requires at least one email address.
+	LintEmailSANRequired = "e_email_san_required"
+	// LintCodeSigningOnly requires the codeSigning extended key usage to be
+	// the only one, the digitalSignature key usage and no DNS names or IP
+	// addresses.
+	LintCodeSigningOnly = "e_code_signing_only"
+	// LintMatterVendorID requires one Matter vendor id in the subject, and
+	// validates the product id if present.
+	LintMatterVendorID = "e_matter_vid"
+	// LintMatterProductID requires one Matter product id in the subject.
+	LintMatterProductID = "e_matter_pid"
+	// LintMatterDAC requires the basic constraints and key usage of a Matter
+	// device attestation certificate.
+	LintMatterDAC = "e_matter_dac"
+	// LintMatterPAI requires the basic constraints and key usage of a Matter
+	// product attestation intermediate.
+	LintMatterPAI = "e_matter_pai"
+	// LintDevIDSerialNumber requires the serialNumber attribute in the
+	// subject, as required by IEEE 802.1AR for IDevIDs.
+	LintDevIDSerialNumber = "e_devid_serial_number"
+)
+
+type lintFunc func(cert *x509.Certificate) error
+
+var lints = map[string]lintFunc{
+	LintSANRequired:      lintSANRequired,
+	LintCommonNameInSAN:  lintCommonNameInSAN,
+	LintNotCA:            lintNotCA,
+	LintKeyUsagePresent:  lintKeyUsagePresent,
+	LintDNSNameValid:     lintDNSNameValid,
+	LintNoInternalNames:  lintNoInternalNames,
+	LintNoReservedIP:     lintNoReservedIP,
+	LintEmailSANRequired: lintEmailSANRequired,
+	LintCodeSigningOnly:  lintCodeSigningOnly,
+	// Device lints
+	LintMatterVendorID:    lintMatterVendorID,
+	LintMatterProductID:   lintMatterProductID,
+	LintMatterDAC:         lintMatterDAC,
+	LintMatterPAI:         lintMatterPAI,
+	LintDevIDSerialNumber: lintDevIDSerialNumber,
+}
+
+// internalTLDs are top-level domains that cannot be included in
+// publicly-trusted certificates.
+var internalTLDs = map[string]bool{
+	"local":     true,
+	"localhost": true,
+	"internal":  true,
+	"intranet":  true,
+	"lan":       true,
+	"home":      true,
+	"corp":      true,
+	"private":   true,
+	"test":      true,
+	"example":   true,
+	"invalid":   true,
+	"onion":     true,
+}
+
+func lintSANRequired(cert *x509.Certificate) error {
+	if len(cert.DNSNames) == 0 && len(cert.IPAddresses) == 0 && len(cert.EmailAddresses) == 0 && len(cert.URIs) == 0 {
+		return errors.New("subject alternative names are required")
+	}
+	return nil
+}
+
+func lintCommonNameInSAN(cert *x509.Certificate) error {
+	cn := cert.Subject.CommonName
+	if cn == "" {
+		return nil
+	}
+	for _, name := range cert.DNSNames {
+		if strings.EqualFold(name, cn) {
+			return nil
+		}
+	}
+	if ip := net.ParseIP(cn); ip != nil {
+		for _, v := range cert.IPAddresses {
+			if v.Equal(ip) {
+				return nil
+			}
+		}
+	}
+	return errors.Errorf("common name %q is not a subject alternative name", cn)
+}
+
+func lintNotCA(cert *x509.Certificate) error {
+	if cert.IsCA {
+		return errors.New("certificate cannot be a CA")
+	}
+	return nil
+}
+
+func lintKeyUsagePresent(cert *x509.Certificate) error {
+	if cert.KeyUsage == 0 {
+		return errors.New("key usage is required")
+	}
+	return nil
+}
+
+func lintDNSNameValid(cert *x509.Certificate) error {
+	for _, name := range cert.DNSNames {
+		if !isValidDNSName(name) {
+			return errors.Errorf("dns name %q is not valid", name)
+		}
+	}
+	return nil
+}
+
+func lintNoInternalNames(cert *x509.Certificate) error {
+	for _, name := range cert.DNSNames {
+		labels := strings.Split(strings.TrimSuffix(strings.ToLower(name), "."), ".")
+		if len(labels) < 2 || internalTLDs[labels[len(labels)-1]] {
+			return errors.Errorf("dns name %q is an internal name", name)
+		}
+	}
+	return nil
+}
+
+func lintNoReservedIP(cert *x509.Certificate) error {
+	for _, ip := range cert.IPAddresses {
+		if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() ||
+			ip.IsMulticast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+			return errors.Errorf("ip address %s is reserved", ip)
+		}
+	}
+	return nil
+}
+
+func lintEmailSANRequired(cert *x509.Certificate) error {
+	if len(cert.EmailAddresses) == 0 {
+		return errors.New("an email address is required")
+	}
+	return nil
+}
+
+func isValidDNSName(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if i == 0 && label == "*" && len(labels) > 1 {
+			continue
+		}
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func lintCodeSigningOnly(cert *x509.Certificate) error {
+	for _, eku := range cert.ExtKeyUsage {
+		if eku != x509.ExtKeyUsageCodeSigning {
+			return errors.New("codeSigning must be the only extended key usage")
+		}
+	}
+	if len(cert.UnknownExtKeyUsage) > 0 {
+		return errors.New("codeSigning must be the only extended key usage")
+	}
+	if cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return errors.New("digitalSignature key usage is required")
+	}
+	if len(cert.DNSNames) > 0 || len(cert.IPAddresses) > 0 {
+		return errors.New("DNS names and IP addresses are not allowed")
+	}
+	return nil
+}
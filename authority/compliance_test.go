@@ -0,0 +1,136 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/compliance"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/keyutil"
+)
+
+func TestAuthority_checkCompliance(t *testing.T) {
+	pub, _, err := keyutil.GenerateDefaultKeyPair()
+	assert.FatalError(t, err)
+	leaf := &x509.Certificate{
+		DNSNames:    []string{"www.smallstep.com"},
+		NotBefore:   time.Now(),
+		NotAfter:    time.Now().Add(24 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		PublicKey:   pub,
+	}
+	withProfile := func(name string) provisioner.Interface {
+		return &provisioner.JWK{Name: "jwk", Options: &provisioner.Options{
+			X509: &provisioner.X509Options{ComplianceProfile: name},
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		options    *compliance.Options
+		prov       provisioner.Interface
+		statusCode int
+	}{
+		{"ok/no-profile", nil, &provisioner.JWK{Name: "jwk"}, 0},
+		{"ok/provisioner", nil, withProfile(compliance.CABFTLSProfile), 0},
+		{"ok/default", &compliance.Options{DefaultProfile: compliance.CABFTLSProfile}, &provisioner.SSHPOP{Name: "sshpop"}, 0},
+		{"ok/override", &compliance.Options{DefaultProfile: compliance.CABFSMIMEProfile}, withProfile(compliance.InternalProfile), 0},
+		{"fail/provisioner", nil, withProfile(compliance.CABFSMIMEProfile), http.StatusForbidden},
+		{"fail/default", &compliance.Options{DefaultProfile: compliance.CABFSMIMEProfile}, &provisioner.JWK{Name: "jwk"}, http.StatusForbidden},
+		{"fail/unknown", nil, withProfile("foo"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Authority{complianceProfiles: compliance.New(tt.options)}
+			err := a.checkCompliance(tt.prov, leaf)
+			if tt.statusCode == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var sc *errs.Error
+			if assert.True(t, errors.As(err, &sc)) {
+				assert.Equals(t, tt.statusCode, sc.StatusCode())
+			}
+		})
+	}
+}
+
+func TestAuthority_checkCompliance_codeSigning(t *testing.T) {
+	pub, _, err := keyutil.GenerateDefaultKeyPair()
+	assert.FatalError(t, err)
+	leaf := &x509.Certificate{
+		NotBefore:   time.Now(),
+		NotAfter:    time.Now().Add(24 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		PublicKey:   pub,
+	}
+	withProfile := func(name string) provisioner.Interface {
+		return &provisioner.JWK{Name: "jwk", Options: &provisioner.Options{
+			X509: &provisioner.X509Options{ComplianceProfile: name},
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		profiles   *compliance.Profiles
+		prov       provisioner.Interface
+		statusCode int
+	}{
+		{"ok/provisioner", compliance.New(nil), withProfile(compliance.CodeSigningProfile), 0},
+		{"ok/default", compliance.New(&compliance.Options{DefaultProfile: compliance.CodeSigningProfile}), &provisioner.JWK{Name: "jwk"}, 0},
+		{"fail/no-profiles", nil, &provisioner.JWK{Name: "jwk"}, http.StatusForbidden},
+		{"fail/no-profile", compliance.New(nil), &provisioner.JWK{Name: "jwk"}, http.StatusForbidden},
+		{"fail/tls", compliance.New(nil), withProfile(compliance.InternalProfile), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Authority{complianceProfiles: tt.profiles}
+			err := a.checkCompliance(tt.prov, leaf)
+			if tt.statusCode == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var sc *errs.Error
+			if assert.True(t, errors.As(err, &sc)) {
+				assert.Equals(t, tt.statusCode, sc.StatusCode())
+			}
+		})
+	}
+}
+
+func TestAuthority_Sign_compliance(t *testing.T) {
+	a := testAuthority(t, func(a *Authority) error {
+		a.config.Compliance = &compliance.Options{DefaultProfile: compliance.CABFTLSProfile}
+		return nil
+	})
+
+	_, priv, err := keyutil.GenerateDefaultKeyPair()
+	assert.FatalError(t, err)
+	key, err := jose.ReadKey("testdata/secrets/step_cli_key_priv.jwk", jose.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+	token, err := generateToken("smallstep test", "step-cli", testAudiences.Sign[0], []string{"test.smallstep.com"}, time.Now(), key)
+	assert.FatalError(t, err)
+
+	ctx := provisioner.NewContextWithMethod(context.Background(), provisioner.SignMethod)
+	extraOpts, err := a.Authorize(ctx, token)
+	assert.FatalError(t, err)
+
+	// The common name of the test CSR is not one of the SANs.
+	_, err = a.Sign(getCSR(t, priv), provisioner.SignOptions{}, extraOpts...)
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), `e_sub_cert_cn_in_san: common name "smallstep test" is not a subject alternative name`))
+	var sc *errs.Error
+	if assert.True(t, errors.As(err, &sc)) {
+		assert.Equals(t, http.StatusForbidden, sc.StatusCode())
+	}
+}
@@ -2,10 +2,19 @@ package config
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -13,10 +22,18 @@ import (
 	kms "go.step.sm/crypto/kms/apiv1"
 	"go.step.sm/linkedca"
 
+	"github.com/smallstep/certificates/authority/compliance"
+	"github.com/smallstep/certificates/authority/keypolicy"
 	"github.com/smallstep/certificates/authority/policy"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/revocation"
+	"github.com/smallstep/certificates/cache"
 	cas "github.com/smallstep/certificates/cas/apiv1"
 	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/internal/chaos"
+	"github.com/smallstep/certificates/internal/secret"
+	"github.com/smallstep/certificates/notify"
+	"github.com/smallstep/certificates/smime"
 	"github.com/smallstep/certificates/templates"
 )
 
@@ -65,25 +82,59 @@ var (
 
 // Config represents the CA configuration and it's mapped to a JSON object.
 type Config struct {
-	Root             multiString          `json:"root"`
-	FederatedRoots   []string             `json:"federatedRoots"`
-	IntermediateCert string               `json:"crt"`
-	IntermediateKey  string               `json:"key"`
-	Address          string               `json:"address"`
-	InsecureAddress  string               `json:"insecureAddress"`
-	DNSNames         []string             `json:"dnsNames"`
-	KMS              *kms.Options         `json:"kms,omitempty"`
-	SSH              *SSHConfig           `json:"ssh,omitempty"`
-	Logger           json.RawMessage      `json:"logger,omitempty"`
-	DB               *db.Config           `json:"db,omitempty"`
-	Monitoring       json.RawMessage      `json:"monitoring,omitempty"`
-	AuthorityConfig  *AuthConfig          `json:"authority,omitempty"`
-	TLS              *TLSOptions          `json:"tls,omitempty"`
-	Password         string               `json:"password,omitempty"`
-	Templates        *templates.Templates `json:"templates,omitempty"`
-	CommonName       string               `json:"commonName,omitempty"`
-	CRL              *CRLConfig           `json:"crl,omitempty"`
-	SkipValidation   bool                 `json:"-"`
+	Root             multiString             `json:"root"`
+	FederatedRoots   []string                `json:"federatedRoots"`
+	IntermediateCert string                  `json:"crt"`
+	IntermediateKey  string                  `json:"key"`
+	AlternateChains  []string                `json:"alternateChains,omitempty"`
+	Address          string                  `json:"address"`
+	InsecureAddress  string                  `json:"insecureAddress"`
+	MetricsAddress   string                  `json:"metricsAddress,omitempty"`
+	DNSNames         []string                `json:"dnsNames"`
+	KMS              *kms.Options            `json:"kms,omitempty"`
+	KMSSigner        *KMSSignerConfig        `json:"kmsSigner,omitempty"`
+	SSH              *SSHConfig              `json:"ssh,omitempty"`
+	Logger           json.RawMessage         `json:"logger,omitempty"`
+	DB               *db.Config              `json:"db,omitempty"`
+	Monitoring       json.RawMessage         `json:"monitoring,omitempty"`
+	AuthorityConfig  *AuthConfig             `json:"authority,omitempty"`
+	TLS              *TLSOptions             `json:"tls,omitempty"`
+	Password         string                  `json:"password,omitempty"`
+	Templates        *templates.Templates    `json:"templates,omitempty"`
+	CommonName       string                  `json:"commonName,omitempty"`
+	CRL              *CRLConfig              `json:"crl,omitempty"`
+	CertManager      *CertManagerConfig      `json:"certManager,omitempty"`
+	SDS              *SDSConfig              `json:"sds,omitempty"`
+	Kubernetes       *KubernetesConfig       `json:"kubernetes,omitempty"`
+	Audit            *AuditConfig            `json:"audit,omitempty"`
+	Compliance       *compliance.Options     `json:"compliance,omitempty"`
+	OfflineRoot      bool                    `json:"offlineRoot,omitempty"`
+	Cache            *cache.Options          `json:"cache,omitempty"`
+	LeaderElection   *LeaderElectionConfig   `json:"leaderElection,omitempty"`
+	TSA              *TSAConfig              `json:"tsa,omitempty"`
+	SMIME            *SMIMEConfig            `json:"smime,omitempty"`
+	Enrollment       *EnrollmentConfig       `json:"enrollment,omitempty"`
+	SubCA            *SubCAConfig            `json:"subCA,omitempty"`
+	AIA              *AIAConfig              `json:"aia,omitempty"`
+	OCSP             *OCSPConfig             `json:"ocsp,omitempty"`
+	RevocationEvents *RevocationEventsConfig `json:"revocationEvents,omitempty"`
+	Federation       *FederationConfig       `json:"federation,omitempty"`
+	PublicTLS        *PublicTLSConfig        `json:"publicTLS,omitempty"`
+	Idempotency      *IdempotencyConfig      `json:"idempotency,omitempty"`
+	BatchSign        *BatchSignConfig        `json:"batchSign,omitempty"`
+	Activity         *ActivityConfig         `json:"activity,omitempty"`
+	SelfTest         *SelfTestConfig         `json:"selfTest,omitempty"`
+	Shutdown         *ShutdownConfig         `json:"shutdown,omitempty"`
+	Maintenance      *MaintenanceConfig      `json:"maintenance,omitempty"`
+	LoadShedding     *LoadSheddingConfig     `json:"loadShedding,omitempty"`
+	DelegatedSigners *DelegatedSignersConfig `json:"delegatedSigners,omitempty"`
+	Notifications    *notify.Config          `json:"notifications,omitempty"`
+	CAExpiry         *CAExpiryConfig         `json:"caExpiry,omitempty"`
+	CertMetrics      *CertMetricsConfig      `json:"certificateMetrics,omitempty"`
+	Webhooks         *WebhooksConfig         `json:"webhooks,omitempty"`
+	ResponseSigning  *ResponseSigningConfig  `json:"responseSigning,omitempty"`
+	Chaos            *chaos.Config           `json:"chaos,omitempty"`
+	SkipValidation   bool                    `json:"-"`
 
 	// Keeps record of the filename the Config is read from
 	loadedFromFilepath string
@@ -139,6 +190,1620 @@ func (c *CRLConfig) TickerDuration() time.Duration {
 	return (c.CacheDuration.Duration / 3) * 2
 }
 
+// Defaults of the cert-manager issuer references signed by the CA.
+const (
+	DefaultCertManagerGroup = "ca.step.sm"
+	DefaultCertManagerKind  = "Issuer"
+	DefaultCertManagerName  = "step-ca"
+)
+
+// CertManagerConfig represents the config options of the cert-manager
+// external issuer. When the CA runs inside a cluster, it watches the
+// cert-manager CertificateRequests with an issuer reference with the given API
+// group, kind and name, and it signs the approved ones with the given ACME
+// provisioner.
+type CertManagerConfig struct {
+	// Provisioner is the name of the ACME provisioner used to sign the
+	// requests.
+	Provisioner string `json:"provisioner"`
+	// Group is the API group of the issuer references, it defaults to
+	// ca.step.sm.
+	Group string `json:"group,omitempty"`
+	// Kind is the kind of the issuer references, it defaults to Issuer.
+	Kind string `json:"kind,omitempty"`
+	// Name is the name of the issuer references, it defaults to step-ca.
+	Name string `json:"name,omitempty"`
+}
+
+// IsEnabled returns if the cert-manager issuer is enabled.
+func (c *CertManagerConfig) IsEnabled() bool {
+	return c != nil && c.Provisioner != ""
+}
+
+// GetGroup returns the API group of the issuer references signed by the CA.
+func (c *CertManagerConfig) GetGroup() string {
+	if c == nil || c.Group == "" {
+		return DefaultCertManagerGroup
+	}
+	return c.Group
+}
+
+// GetKind returns the kind of the issuer references signed by the CA.
+func (c *CertManagerConfig) GetKind() string {
+	if c == nil || c.Kind == "" {
+		return DefaultCertManagerKind
+	}
+	return c.Kind
+}
+
+// GetName returns the name of the issuer references signed by the CA.
+func (c *CertManagerConfig) GetName() string {
+	if c == nil || c.Name == "" {
+		return DefaultCertManagerName
+	}
+	return c.Name
+}
+
+// Validate validates the cert-manager configuration.
+func (c *CertManagerConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Provisioner == "" && (c.Group != "" || c.Kind != "" || c.Name != "") {
+		return errors.New("certManager.provisioner cannot be empty")
+	}
+	if c.Group == "cert-manager.io" {
+		return errors.New("certManager.group cannot be the cert-manager.io group of the built-in issuers")
+	}
+	return nil
+}
+
+// SDSConfig represents the config options of the Envoy secret discovery
+// service.
+type SDSConfig struct {
+	Address string `json:"address"`
+}
+
+// IsEnabled returns if the secret discovery service is enabled.
+func (c *SDSConfig) IsEnabled() bool {
+	return c != nil && c.Address != ""
+}
+
+// Validate validates the secret discovery service configuration.
+func (c *SDSConfig) Validate() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return errors.Errorf("invalid sds.address %s", c.Address)
+	}
+	return nil
+}
+
+// KubernetesConfig represents the config options to store the credentials of
+// the CA server in Kubernetes secrets when the CA runs inside a cluster.
+type KubernetesConfig struct {
+	// Namespace is the namespace of the secrets, it defaults to the namespace
+	// of the pod.
+	Namespace string `json:"namespace,omitempty"`
+	// TLSSecret is the name of the kubernetes.io/tls secret used to load and
+	// persist the certificate and key of the CA server.
+	TLSSecret string `json:"tlsSecret,omitempty"`
+	// RootsSecret is the name of the secret where the root certificates are
+	// published using the ca.crt key.
+	RootsSecret string `json:"rootsSecret,omitempty"`
+}
+
+// IsEnabled returns if any of the Kubernetes secrets is configured.
+func (c *KubernetesConfig) IsEnabled() bool {
+	return c != nil && (c.TLSSecret != "" || c.RootsSecret != "")
+}
+
+// PublicTLSConfig represents the config options to obtain the certificate of
+// the CA server from an external ACME authority, e.g. Let's Encrypt, so clients
+// can connect to the CA using the system trust store instead of pinning the
+// root fingerprint. The public certificate replaces the one issued by the CA,
+// so it must include all the names used to connect to the CA.
+type PublicTLSConfig struct {
+	Enabled bool `json:"enabled"`
+	// DirectoryURL is the URL of the ACME directory of the external authority.
+	DirectoryURL string `json:"directoryURL"`
+	// DNSNames are the names of the certificate, they default to the dnsNames
+	// of the CA.
+	DNSNames []string `json:"dnsNames,omitempty"`
+	// Storage is the directory used to keep the certificate and key across
+	// restarts, an empty value will order a new certificate on every start.
+	Storage string `json:"storage,omitempty"`
+	// Options are the options of the ACME client, they are the same as the
+	// ones in the config of the acmecas certificate authority service: email,
+	// eabKeyID, eabHMACKey, challengeType, webroot, selfSolve, dnsProvider, ...
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+// IsEnabled returns if the public certificate is enabled.
+func (c *PublicTLSConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// IsSelfSolving returns if the http-01 challenges of the public certificate
+// are solved by the CA itself, using the selfSolve option of the ACME client.
+// The challenges are served on the insecure address.
+func (c *PublicTLSConfig) IsSelfSolving() bool {
+	if !c.IsEnabled() || len(c.Options) == 0 {
+		return false
+	}
+	var opts struct {
+		SelfSolve bool `json:"selfSolve"`
+	}
+	return json.Unmarshal(c.Options, &opts) == nil && opts.SelfSolve
+}
+
+// Validate validates the public certificate configuration.
+func (c *PublicTLSConfig) Validate() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	if c.DirectoryURL == "" {
+		return errors.New("publicTLS.directoryURL cannot be empty")
+	}
+	if u, err := url.Parse(c.DirectoryURL); err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.Errorf("publicTLS.directoryURL %s is not a valid https url", c.DirectoryURL)
+	}
+	for _, name := range c.DNSNames {
+		if name == "" {
+			return errors.New("publicTLS.dnsNames cannot contain empty values")
+		}
+	}
+	return nil
+}
+
+// DefaultIdempotencyWindow is the default time the responses of the requests
+// with an idempotency key are kept.
+var DefaultIdempotencyWindow = 24 * time.Hour
+
+// IdempotencyConfig represents the config options of the Idempotency-Key
+// header accepted by the sign, renew, rekey and revoke endpoints. The
+// responses are kept in the cache if configured, or in memory.
+type IdempotencyConfig struct {
+	// Disabled ignores the Idempotency-Key header.
+	Disabled bool `json:"disabled,omitempty"`
+	// Window is the time the responses are kept, it defaults to 24 hours.
+	Window *provisioner.Duration `json:"window,omitempty"`
+}
+
+// IsEnabled returns if the idempotency keys are enabled, they are enabled by
+// default.
+func (c *IdempotencyConfig) IsEnabled() bool {
+	return c == nil || !c.Disabled
+}
+
+// GetWindow returns the time the responses are kept.
+func (c *IdempotencyConfig) GetWindow() time.Duration {
+	if c != nil && c.Window != nil && c.Window.Duration > 0 {
+		return c.Window.Duration
+	}
+	return DefaultIdempotencyWindow
+}
+
+// Validate validates the idempotency configuration.
+func (c *IdempotencyConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Window != nil && c.Window.Duration < 0 {
+		return errors.New("idempotency.window must be greater than or equal to 0")
+	}
+	return nil
+}
+
+// DefaultBatchSignMaxRequests is the default maximum number of certificate
+// requests in a batch sign request.
+var DefaultBatchSignMaxRequests = 100
+
+// BatchSignConfig represents the config options of the batch sign endpoint.
+type BatchSignConfig struct {
+	// Disabled disables the batch sign endpoint.
+	Disabled bool `json:"disabled,omitempty"`
+	// MaxRequests is the maximum number of certificate requests in a batch,
+	// it defaults to 100.
+	MaxRequests int `json:"maxRequests,omitempty"`
+}
+
+// IsEnabled returns if the batch sign endpoint is enabled, it is enabled by
+// default.
+func (c *BatchSignConfig) IsEnabled() bool {
+	return c == nil || !c.Disabled
+}
+
+// GetMaxRequests returns the maximum number of certificate requests in a
+// batch.
+func (c *BatchSignConfig) GetMaxRequests() int {
+	if c != nil && c.MaxRequests > 0 {
+		return c.MaxRequests
+	}
+	return DefaultBatchSignMaxRequests
+}
+
+// Validate validates the batch sign configuration.
+func (c *BatchSignConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.MaxRequests < 0 {
+		return errors.New("batchSign.maxRequests must be greater than or equal to 0")
+	}
+	return nil
+}
+
+// DefaultSelfTestValidity is the default validity of the certificates signed
+// by the self-test.
+const DefaultSelfTestValidity = 5 * time.Minute
+
+// SelfTestConfig represents the config options of the self-test that signs a
+// certificate and checks it with the revocation endpoints.
+type SelfTestConfig struct {
+	// Provisioner is the name of the provisioner used to sign the test
+	// certificates.
+	Provisioner string `json:"provisioner"`
+	// Validity is the validity of the test certificates, it defaults to 5
+	// minutes.
+	Validity *provisioner.Duration `json:"validity,omitempty"`
+}
+
+// GetValidity returns the validity of the test certificates.
+func (c *SelfTestConfig) GetValidity() time.Duration {
+	if c != nil && c.Validity != nil && c.Validity.Duration > 0 {
+		return c.Validity.Duration
+	}
+	return DefaultSelfTestValidity
+}
+
+// Validate validates the self-test configuration.
+func (c *SelfTestConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Provisioner == "" {
+		return errors.New("selfTest.provisioner cannot be empty")
+	}
+	if c.Validity != nil && c.Validity.Duration < 0 {
+		return errors.New("selfTest.validity cannot be negative")
+	}
+	return nil
+}
+
+// DefaultDelegatedSignerValidity is the default validity of the delegated
+// OCSP and CRL signing certificates.
+const DefaultDelegatedSignerValidity = 7 * 24 * time.Hour
+
+var (
+	// DefaultCAExpiryInterval is the default time between the checks of the
+	// expiration of the CA certificates.
+	DefaultCAExpiryInterval = time.Hour
+	// DefaultCAExpiryThresholds are the default remaining times at which the
+	// expiration of the CA certificates is notified.
+	DefaultCAExpiryThresholds = []time.Duration{
+		90 * 24 * time.Hour,
+		30 * 24 * time.Hour,
+		7 * 24 * time.Hour,
+		24 * time.Hour,
+	}
+)
+
+// CAExpiryConfig represents the config options of the monitoring of the
+// expiration of the root and intermediate certificates of the CA. The
+// expirations are always available in the health endpoint and the metrics,
+// this configuration enables the notifications.
+type CAExpiryConfig struct {
+	// Interval is the time between checks, it defaults to 1 hour.
+	Interval *provisioner.Duration `json:"interval,omitempty"`
+	// Thresholds are the remaining times at which a certificate is notified,
+	// it defaults to 90, 30, 7 and 1 days. Each threshold is notified once, so
+	// the notifications escalate as the expiration approaches.
+	Thresholds []provisioner.Duration `json:"thresholds,omitempty"`
+	// Notify are the email addresses notified, using the email provider of
+	// the notifications. The expirations are always logged.
+	Notify []string `json:"notify,omitempty"`
+}
+
+// GetInterval returns the time between checks.
+func (c *CAExpiryConfig) GetInterval() time.Duration {
+	if c != nil && c.Interval != nil && c.Interval.Duration > 0 {
+		return c.Interval.Duration
+	}
+	return DefaultCAExpiryInterval
+}
+
+// GetThresholds returns the remaining times at which a certificate is
+// notified, sorted from the longest to the shortest.
+func (c *CAExpiryConfig) GetThresholds() []time.Duration {
+	if c == nil || len(c.Thresholds) == 0 {
+		return DefaultCAExpiryThresholds
+	}
+	thresholds := make([]time.Duration, len(c.Thresholds))
+	for i, d := range c.Thresholds {
+		thresholds[i] = d.Duration
+	}
+	sort.Slice(thresholds, func(i, j int) bool {
+		return thresholds[i] > thresholds[j]
+	})
+	return thresholds
+}
+
+// Validate validates the CA expiry configuration.
+func (c *CAExpiryConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Interval != nil && c.Interval.Duration < 0 {
+		return errors.New("caExpiry.interval cannot be negative")
+	}
+	for _, d := range c.Thresholds {
+		if d.Duration <= 0 {
+			return errors.New("caExpiry.thresholds must be greater than 0")
+		}
+	}
+	for _, e := range c.Notify {
+		if err := smime.ValidateEmail(e); err != nil {
+			return errors.Errorf("caExpiry.notify contains an invalid email address %s", e)
+		}
+	}
+	return nil
+}
+
+// Modes of the expiry series of the issued certificates.
+const (
+	// CertMetricsPerCertificate exports one series per certificate.
+	CertMetricsPerCertificate = "certificate"
+	// CertMetricsPerSANGroup exports one series per set of SANs, with the
+	// expiration of the most recent certificate of the set.
+	CertMetricsPerSANGroup = "sanGroup"
+)
+
+var (
+	// DefaultCertMetricsInterval is the default time between the refreshes of
+	// the expiry series of the issued certificates.
+	DefaultCertMetricsInterval = 5 * time.Minute
+	// DefaultCertMetricsMaxSeries is the default maximum number of expiry
+	// series of the issued certificates.
+	DefaultCertMetricsMaxSeries = 1000
+)
+
+// CertMetricsConfig represents the config options of the expiry series of the
+// issued certificates, exported in the metrics with the names used by the
+// x509_cert exporters so the existing dashboards and alerts can be used with
+// the data of the CA. It requires the metricsAddress and a database that can
+// list the certificates. Only the valid certificates are exported, the ones
+// closer to their expiration first.
+type CertMetricsConfig struct {
+	// Mode is "certificate" to export one series per certificate, or
+	// "sanGroup" to export one series per set of SANs. It defaults to
+	// "certificate".
+	Mode string `json:"mode,omitempty"`
+	// MaxSeries is the maximum number of series exported, it defaults to
+	// 1000.
+	MaxSeries int `json:"maxSeries,omitempty"`
+	// Interval is the time between the refreshes of the list of certificates,
+	// it defaults to 5 minutes.
+	Interval *provisioner.Duration `json:"interval,omitempty"`
+}
+
+// GetMode returns the mode of the series.
+func (c *CertMetricsConfig) GetMode() string {
+	if c != nil && c.Mode != "" {
+		return c.Mode
+	}
+	return CertMetricsPerCertificate
+}
+
+// GetMaxSeries returns the maximum number of series exported.
+func (c *CertMetricsConfig) GetMaxSeries() int {
+	if c != nil && c.MaxSeries > 0 {
+		return c.MaxSeries
+	}
+	return DefaultCertMetricsMaxSeries
+}
+
+// GetInterval returns the time between the refreshes of the list of
+// certificates.
+func (c *CertMetricsConfig) GetInterval() time.Duration {
+	if c != nil && c.Interval != nil && c.Interval.Duration > 0 {
+		return c.Interval.Duration
+	}
+	return DefaultCertMetricsInterval
+}
+
+// Validate validates the certificate metrics configuration.
+func (c *CertMetricsConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	switch c.Mode {
+	case "", CertMetricsPerCertificate, CertMetricsPerSANGroup:
+	default:
+		return errors.Errorf("unsupported certificateMetrics.mode %s", c.Mode)
+	}
+	if c.MaxSeries < 0 {
+		return errors.New("certificateMetrics.maxSeries cannot be negative")
+	}
+	if c.Interval != nil && c.Interval.Duration < 0 {
+		return errors.New("certificateMetrics.interval cannot be negative")
+	}
+	return nil
+}
+
+// DelegatedSignersConfig represents the config options of the short-lived
+// certificates issued by the intermediate to sign the OCSP responses and the
+// CRLs, so the intermediate key is only used to sign certificates. The
+// certificates are issued on demand and rotated after two thirds of their
+// validity.
+type DelegatedSignersConfig struct {
+	// OCSP enables the delegated OCSP responder certificate, with the
+	// id-kp-OCSPSigning extended key usage.
+	OCSP bool `json:"ocsp,omitempty"`
+	// CRL enables the delegated CRL signing certificate. It has the same
+	// subject as the intermediate, so the CRLs keep the same issuer.
+	CRL bool `json:"crl,omitempty"`
+	// Validity is the validity of the delegated certificates, it defaults to
+	// 7 days. It must be greater than the validity of the OCSP responses and
+	// the CRLs.
+	Validity *provisioner.Duration `json:"validity,omitempty"`
+}
+
+// IsEnabled returns if any of the delegated signers is enabled.
+func (c *DelegatedSignersConfig) IsEnabled() bool {
+	return c != nil && (c.OCSP || c.CRL)
+}
+
+// GetValidity returns the validity of the delegated certificates.
+func (c *DelegatedSignersConfig) GetValidity() time.Duration {
+	if c != nil && c.Validity != nil && c.Validity.Duration > 0 {
+		return c.Validity.Duration
+	}
+	return DefaultDelegatedSignerValidity
+}
+
+// Validate validates the delegated signers configuration.
+func (c *DelegatedSignersConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Validity != nil && c.Validity.Duration < 0 {
+		return errors.New("delegatedSigners.validity cannot be negative")
+	}
+	return nil
+}
+
+// DefaultDrainTimeout is the default time to wait for the in-flight requests
+// on shutdown.
+const DefaultDrainTimeout = 60 * time.Second
+
+// ShutdownConfig represents the config options used when the CA is stopped.
+type ShutdownConfig struct {
+	// DrainTimeout is the maximum time to wait for the in-flight requests,
+	// including signings, ACME validations and their database writes, before
+	// closing the connections and the database. It defaults to 60 seconds.
+	DrainTimeout *provisioner.Duration `json:"drainTimeout,omitempty"`
+}
+
+// GetDrainTimeout returns the time to wait for the in-flight requests.
+func (c *ShutdownConfig) GetDrainTimeout() time.Duration {
+	if c != nil && c.DrainTimeout != nil && c.DrainTimeout.Duration > 0 {
+		return c.DrainTimeout.Duration
+	}
+	return DefaultDrainTimeout
+}
+
+// Validate validates the shutdown configuration.
+func (c *ShutdownConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.DrainTimeout != nil && c.DrainTimeout.Duration < 0 {
+		return errors.New("shutdown.drainTimeout cannot be negative")
+	}
+	return nil
+}
+
+// Defaults of the signers of the intermediate keys kept in a KMS or HSM.
+const (
+	DefaultKMSSignerTimeout    = 10 * time.Second
+	DefaultKMSSignerRetries    = 1
+	DefaultKMSSignerRetryAfter = 30 * time.Second
+)
+
+// KMSSignerConfig represents how the authority uses an intermediate key kept
+// in a cloud KMS or a PKCS#11 HSM. Slow or failing signatures are retried, and
+// if the key is still unavailable the requests fail with a 503 that the
+// clients can retry.
+type KMSSignerConfig struct {
+	// Timeout is the maximum time of a signature, it defaults to 10 seconds.
+	Timeout *provisioner.Duration `json:"timeout,omitempty"`
+	// Retries is the number of times a failed signature is retried, it
+	// defaults to 1. Use a negative number to disable the retries.
+	Retries *int `json:"retries,omitempty"`
+	// RetryAfter is the time the clients are asked to wait before retrying a
+	// request if the key is unavailable, it defaults to 30 seconds.
+	RetryAfter *provisioner.Duration `json:"retryAfter,omitempty"`
+}
+
+// GetTimeout returns the maximum time of a signature.
+func (c *KMSSignerConfig) GetTimeout() time.Duration {
+	if c != nil && c.Timeout != nil && c.Timeout.Duration > 0 {
+		return c.Timeout.Duration
+	}
+	return DefaultKMSSignerTimeout
+}
+
+// GetRetries returns the number of times a failed signature is retried.
+func (c *KMSSignerConfig) GetRetries() int {
+	switch {
+	case c == nil || c.Retries == nil:
+		return DefaultKMSSignerRetries
+	case *c.Retries < 0:
+		return 0
+	default:
+		return *c.Retries
+	}
+}
+
+// GetRetryAfter returns the time the clients are asked to wait before
+// retrying a request if the key is unavailable.
+func (c *KMSSignerConfig) GetRetryAfter() time.Duration {
+	if c != nil && c.RetryAfter != nil && c.RetryAfter.Duration > 0 {
+		return c.RetryAfter.Duration
+	}
+	return DefaultKMSSignerRetryAfter
+}
+
+// Validate validates the KMS signer configuration.
+func (c *KMSSignerConfig) Validate() error {
+	switch {
+	case c == nil:
+		return nil
+	case c.Timeout != nil && c.Timeout.Duration < 0:
+		return errors.New("kmsSigner.timeout cannot be negative")
+	case c.RetryAfter != nil && c.RetryAfter.Duration < 0:
+		return errors.New("kmsSigner.retryAfter cannot be negative")
+	default:
+		return nil
+	}
+}
+
+// validateKMSKey checks that a key defined with the URI of a remote KMS or HSM
+// can be loaded by the configured KMS.
+func validateKMSKey(opts *kms.Options, key string) error {
+	scheme, _, ok := strings.Cut(key, ":")
+	if !ok {
+		return nil
+	}
+	switch typ := kms.Type(strings.ToLower(scheme)); typ {
+	case kms.CloudKMS, kms.AmazonKMS, kms.AzureKMS, kms.PKCS11, kms.YubiKey, kms.TPMKMS:
+		var current kms.Type = kms.SoftKMS
+		if opts != nil {
+			var err error
+			if current, err = opts.GetType(); err != nil {
+				return errors.Wrap(err, "error parsing kms uri")
+			}
+		}
+		if !strings.EqualFold(string(current), string(typ)) {
+			return errors.Errorf("key %s requires a kms of type %s", key, typ)
+		}
+	}
+	return nil
+}
+
+// DefaultMaintenanceRetryAfter is the default time the clients are asked to
+// wait before retrying a request refused in read-only mode.
+const DefaultMaintenanceRetryAfter = 5 * time.Minute
+
+// MaintenanceConfig represents the read-only mode of the CA, used in
+// maintenance windows. In read-only mode the CA keeps serving the roots, the
+// CRLs, the ACME directory and the rest of the GET requests, but it refuses
+// any request that can issue, renew or revoke certificates, or write to the
+// database.
+type MaintenanceConfig struct {
+	Enabled bool `json:"enabled"`
+	// Message is the detail of the errors returned to the refused requests.
+	Message string `json:"message,omitempty"`
+	// RetryAfter is the time the clients are asked to wait before retrying a
+	// request, it defaults to 5 minutes.
+	RetryAfter *provisioner.Duration `json:"retryAfter,omitempty"`
+}
+
+// IsEnabled returns if the read-only mode is enabled.
+func (c *MaintenanceConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// GetMessage returns the detail of the errors returned to the refused
+// requests.
+func (c *MaintenanceConfig) GetMessage() string {
+	if c != nil && c.Message != "" {
+		return c.Message
+	}
+	return "The CA is in read-only mode for maintenance"
+}
+
+// GetRetryAfter returns the time the clients are asked to wait before
+// retrying a request.
+func (c *MaintenanceConfig) GetRetryAfter() time.Duration {
+	if c != nil && c.RetryAfter != nil && c.RetryAfter.Duration > 0 {
+		return c.RetryAfter.Duration
+	}
+	return DefaultMaintenanceRetryAfter
+}
+
+// Validate validates the maintenance configuration.
+func (c *MaintenanceConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.RetryAfter != nil && c.RetryAfter.Duration < 0 {
+		return errors.New("maintenance.retryAfter cannot be negative")
+	}
+	return nil
+}
+
+// Classes of the requests queued by the load shedding. They're listed in
+// the default order of priority.
+const (
+	// LoadSheddingOCSP are the OCSP requests.
+	LoadSheddingOCSP = "ocsp"
+	// LoadSheddingRenew are the requests renewing or rekeying an existing
+	// X.509 or SSH certificate.
+	LoadSheddingRenew = "renew"
+	// LoadSheddingSign are the requests signing a new X.509 or SSH
+	// certificate using the CA API.
+	LoadSheddingSign = "sign"
+	// LoadSheddingACME are the ACME requests creating or finalizing new
+	// orders.
+	LoadSheddingACME = "acme"
+)
+
+// LoadSheddingClasses are the classes of the requests queued by the load
+// shedding.
+var LoadSheddingClasses = []string{LoadSheddingOCSP, LoadSheddingRenew, LoadSheddingSign, LoadSheddingACME}
+
+// Defaults of the load shedding.
+const (
+	DefaultLoadSheddingMaxConcurrent = 64
+	DefaultLoadSheddingQueueSize     = 256
+	DefaultLoadSheddingQueueTimeout  = 5 * time.Second
+	DefaultLoadSheddingRetryAfter    = 30 * time.Second
+)
+
+// DefaultLoadSheddingWeights are the default weights of the classes of
+// requests. Renewals and OCSP requests are served before new enrollments, so
+// a burst of new ACME orders cannot starve the existing workloads.
+var DefaultLoadSheddingWeights = map[string]int{
+	LoadSheddingOCSP:  8,
+	LoadSheddingRenew: 8,
+	LoadSheddingSign:  2,
+	LoadSheddingACME:  1,
+}
+
+// LoadSheddingConfig represents the config options of the load shedding of
+// the requests that sign certificates or OCSP responses. At most
+// MaxConcurrent of these requests are served at the same time, and the rest
+// wait in a queue per class. The free slots are given to the queues in
+// proportion to their weights, and the requests that don't fit in their
+// queue, or that wait more than the queue timeout, are refused with a 503
+// and a Retry-After header.
+type LoadSheddingConfig struct {
+	Enabled bool `json:"enabled"`
+	// MaxConcurrent is the number of requests served at the same time, it
+	// defaults to 64.
+	MaxConcurrent int `json:"maxConcurrent,omitempty"`
+	// QueueSize is the maximum number of requests waiting in the queue of
+	// each class, it defaults to 256.
+	QueueSize int `json:"queueSize,omitempty"`
+	// QueueTimeout is the maximum time a request waits in a queue, it
+	// defaults to 5 seconds.
+	QueueTimeout *provisioner.Duration `json:"queueTimeout,omitempty"`
+	// RetryAfter is the time the clients are asked to wait before retrying a
+	// refused request, it defaults to 30 seconds.
+	RetryAfter *provisioner.Duration `json:"retryAfter,omitempty"`
+	// Weights are the weights of the classes of requests: ocsp, renew, sign
+	// and acme. A class with a weight of 0 uses its default weight.
+	Weights map[string]int `json:"weights,omitempty"`
+}
+
+// IsEnabled returns if the load shedding is enabled.
+func (c *LoadSheddingConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// GetMaxConcurrent returns the number of requests served at the same time.
+func (c *LoadSheddingConfig) GetMaxConcurrent() int {
+	if c != nil && c.MaxConcurrent > 0 {
+		return c.MaxConcurrent
+	}
+	return DefaultLoadSheddingMaxConcurrent
+}
+
+// GetQueueSize returns the maximum number of requests waiting in the queue of
+// each class.
+func (c *LoadSheddingConfig) GetQueueSize() int {
+	if c != nil && c.QueueSize > 0 {
+		return c.QueueSize
+	}
+	return DefaultLoadSheddingQueueSize
+}
+
+// GetQueueTimeout returns the maximum time a request waits in a queue.
+func (c *LoadSheddingConfig) GetQueueTimeout() time.Duration {
+	if c != nil && c.QueueTimeout != nil && c.QueueTimeout.Duration > 0 {
+		return c.QueueTimeout.Duration
+	}
+	return DefaultLoadSheddingQueueTimeout
+}
+
+// GetRetryAfter returns the time the clients are asked to wait before
+// retrying a refused request.
+func (c *LoadSheddingConfig) GetRetryAfter() time.Duration {
+	if c != nil && c.RetryAfter != nil && c.RetryAfter.Duration > 0 {
+		return c.RetryAfter.Duration
+	}
+	return DefaultLoadSheddingRetryAfter
+}
+
+// GetWeight returns the weight of the given class of requests.
+func (c *LoadSheddingConfig) GetWeight(class string) int {
+	if c != nil && c.Weights[class] > 0 {
+		return c.Weights[class]
+	}
+	return DefaultLoadSheddingWeights[class]
+}
+
+// Validate validates the load shedding configuration.
+func (c *LoadSheddingConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	switch {
+	case c.MaxConcurrent < 0:
+		return errors.New("loadShedding.maxConcurrent cannot be negative")
+	case c.QueueSize < 0:
+		return errors.New("loadShedding.queueSize cannot be negative")
+	case c.QueueTimeout != nil && c.QueueTimeout.Duration < 0:
+		return errors.New("loadShedding.queueTimeout cannot be negative")
+	case c.RetryAfter != nil && c.RetryAfter.Duration < 0:
+		return errors.New("loadShedding.retryAfter cannot be negative")
+	}
+	for class, w := range c.Weights {
+		if _, ok := DefaultLoadSheddingWeights[class]; !ok {
+			return errors.Errorf("unsupported loadShedding.weights class %s", class)
+		}
+		if w < 0 {
+			return errors.Errorf("loadShedding.weights.%s cannot be negative", class)
+		}
+	}
+	return nil
+}
+
+// ActivityConfig represents the config options of the stream of signed,
+// renewed and revoked certificates available in the admin API.
+type ActivityConfig struct {
+	Enabled bool `json:"enabled"`
+	// BufferSize is the number of recent events available to the subscribers
+	// that connect or reconnect. It defaults to 1000.
+	BufferSize int `json:"bufferSize,omitempty"`
+	// Log writes the events to a file or to syslog. It does not require the
+	// subscriptions to be enabled.
+	Log *ActivityLogConfig `json:"log,omitempty"`
+}
+
+// Types of the activity logs.
+const (
+	ActivityLogFile   = "file"
+	ActivityLogSyslog = "syslog"
+)
+
+// DefaultActivityLogTag is the default syslog tag of the activity log.
+const DefaultActivityLogTag = "step-ca"
+
+// ActivityLogConfig represents where the activity events are logged, one
+// JSON document per line.
+type ActivityLogConfig struct {
+	// Type is "file" or "syslog".
+	Type string `json:"type"`
+	// Path is the file the events are appended to, required with the file
+	// type.
+	Path string `json:"path,omitempty"`
+	// Tag is the syslog tag, it defaults to step-ca.
+	Tag string `json:"tag,omitempty"`
+}
+
+// IsEnabled returns if the activity events are enabled.
+func (c *ActivityConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// GetLog returns the configuration of the activity log, or nil if it is not
+// configured.
+func (c *ActivityConfig) GetLog() *ActivityLogConfig {
+	if c == nil {
+		return nil
+	}
+	return c.Log
+}
+
+// GetTag returns the syslog tag of the activity log.
+func (c *ActivityLogConfig) GetTag() string {
+	if c.Tag == "" {
+		return DefaultActivityLogTag
+	}
+	return c.Tag
+}
+
+// Validate validates the activity configuration.
+func (c *ActivityConfig) Validate() error {
+	if l := c.GetLog(); l != nil {
+		switch {
+		case l.Type == ActivityLogFile && l.Path == "":
+			return errors.New("activity.log.path cannot be empty")
+		case l.Type != ActivityLogFile && l.Type != ActivityLogSyslog:
+			return errors.Errorf("unsupported activity.log.type %s", l.Type)
+		}
+	}
+	if !c.IsEnabled() {
+		return nil
+	}
+	if c.BufferSize < 0 {
+		return errors.New("activity.bufferSize must be greater than or equal to 0")
+	}
+	return nil
+}
+
+// DefaultAuditCheckpointInterval is the default interval between the signed
+// checkpoints of the audit log.
+var DefaultAuditCheckpointInterval = 1 * time.Hour
+
+// AuditConfig represents the config options of the tamper-evident audit log.
+type AuditConfig struct {
+	Enabled bool `json:"enabled"`
+	// Key is the key used to sign the checkpoints of the log, it defaults to
+	// the intermediate key.
+	Key string `json:"key,omitempty"`
+	// CheckpointInterval is the interval between signed checkpoints, it
+	// defaults to one hour.
+	CheckpointInterval *provisioner.Duration `json:"checkpointInterval,omitempty"`
+}
+
+// IsEnabled returns if the audit log is enabled.
+func (c *AuditConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// Validate validates the audit log configuration.
+func (c *AuditConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.CheckpointInterval != nil && c.CheckpointInterval.Duration < 0 {
+		return errors.New("audit.checkpointInterval must be greater than or equal to 0")
+	}
+	return nil
+}
+
+// TickerDuration returns the interval between signed checkpoints.
+func (c *AuditConfig) TickerDuration() time.Duration {
+	if !c.IsEnabled() {
+		return 0
+	}
+	if c.CheckpointInterval != nil && c.CheckpointInterval.Duration > 0 {
+		return c.CheckpointInterval.Duration
+	}
+	return DefaultAuditCheckpointInterval
+}
+
+// TSAConfig represents the config options of the RFC 3161 time-stamp
+// authority.
+type TSAConfig struct {
+	Enabled bool `json:"enabled"`
+	// Certificate is the path to the TSA certificate, followed by its
+	// intermediates. It must only have the critical timeStamping extended key
+	// usage.
+	Certificate string `json:"crt"`
+	// Key is the TSA key, it can be a path or a KMS URI.
+	Key string `json:"key"`
+	// Policies are the TSA policy OIDs that can be requested, the first one is
+	// used if the request does not specify one.
+	Policies []string `json:"policies"`
+	// Accuracy is the accuracy of the time in the time-stamp tokens.
+	Accuracy *provisioner.Duration `json:"accuracy,omitempty"`
+	// URL is the public URL of the time-stamp authority. If set, it's added to
+	// the subject information access extension of the code-signing
+	// certificates.
+	URL string `json:"url,omitempty"`
+}
+
+// IsEnabled returns if the time-stamp authority is enabled.
+func (c *TSAConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// Validate validates the time-stamp authority configuration.
+func (c *TSAConfig) Validate() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	switch {
+	case c.Certificate == "":
+		return errors.New("tsa.crt cannot be empty")
+	case c.Key == "":
+		return errors.New("tsa.key cannot be empty")
+	case len(c.Policies) == 0:
+		return errors.New("tsa.policies cannot be empty")
+	case c.Accuracy != nil && c.Accuracy.Duration < 0:
+		return errors.New("tsa.accuracy must be greater than or equal to 0")
+	}
+	if c.URL != "" {
+		if u, err := url.Parse(c.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			return errors.Errorf("invalid tsa.url %s", c.URL)
+		}
+	}
+	if _, err := c.ParsePolicies(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ParsePolicies returns the TSA policies as object identifiers.
+func (c *TSAConfig) ParsePolicies() ([]asn1.ObjectIdentifier, error) {
+	oids := make([]asn1.ObjectIdentifier, len(c.Policies))
+	for i, p := range c.Policies {
+		var oid asn1.ObjectIdentifier
+		for _, s := range strings.Split(p, ".") {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return nil, errors.Errorf("invalid tsa.policies %s", p)
+			}
+			oid = append(oid, n)
+		}
+		if len(oid) < 2 {
+			return nil, errors.Errorf("invalid tsa.policies %s", p)
+		}
+		oids[i] = oid
+	}
+	return oids, nil
+}
+
+// Endpoints of the API whose responses can be signed.
+const (
+	ResponseSigningRoots        = "roots"
+	ResponseSigningFederation   = "federation"
+	ResponseSigningProvisioners = "provisioners"
+)
+
+// ResponseSigningConfig represents the config options of the signed API
+// responses. If enabled, clients that send an "Accept: application/jose"
+// header get the JSON response of the selected endpoints signed as a JWS in
+// compact serialization, so it can be verified even if it's cached or
+// mirrored by a third party.
+type ResponseSigningConfig struct {
+	Enabled bool `json:"enabled"`
+	// Certificate is the path to the certificate of the signing key, followed
+	// by its intermediates. It's added in the x5c header of the signatures.
+	// If it's empty, the intermediate certificate is used.
+	Certificate string `json:"crt,omitempty"`
+	// Key is the signing key, it can be a path or a KMS URI. If it's empty,
+	// the intermediate key is used.
+	Key string `json:"key,omitempty"`
+	// Endpoints are the endpoints with signed responses, "roots", "federation"
+	// and "provisioners". All of them are signed if it's empty.
+	Endpoints []string `json:"endpoints,omitempty"`
+}
+
+// IsEnabled returns if the signed responses are enabled.
+func (c *ResponseSigningConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// IsSigned returns if the responses of the given endpoint are signed.
+func (c *ResponseSigningConfig) IsSigned(endpoint string) bool {
+	if !c.IsEnabled() {
+		return false
+	}
+	if len(c.Endpoints) == 0 {
+		return true
+	}
+	for _, e := range c.Endpoints {
+		if e == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate validates the signed responses configuration.
+func (c *ResponseSigningConfig) Validate() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	if (c.Certificate == "") != (c.Key == "") {
+		return errors.New("responseSigning.crt and responseSigning.key must be both set or empty")
+	}
+	for _, e := range c.Endpoints {
+		switch e {
+		case ResponseSigningRoots, ResponseSigningFederation, ResponseSigningProvisioners:
+		default:
+			return errors.Errorf("unsupported responseSigning.endpoints %s", e)
+		}
+	}
+	return nil
+}
+
+// SMIMEConfig represents the config options of the S/MIME issuance API. The
+// one-time codes are sent using the email provider of the notifications, and
+// they are disabled if there is none.
+type SMIMEConfig struct {
+	Enabled bool `json:"enabled"`
+	// Provisioner is the name of the provisioner used to issue the
+	// certificates. If it's an OIDC provisioner, its ID tokens can be used to
+	// prove the ownership of the email address.
+	Provisioner string `json:"provisioner"`
+	// CodeLifetime is the time a one-time code can be used, it defaults to 10
+	// minutes.
+	CodeLifetime *provisioner.Duration `json:"codeLifetime,omitempty"`
+	// Validity is the validity of the certificates issued with a one-time
+	// code, it defaults to one year.
+	Validity *provisioner.Duration `json:"validity,omitempty"`
+}
+
+// IsEnabled returns if the S/MIME issuance API is enabled.
+func (c *SMIMEConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// Validate validates the S/MIME configuration.
+func (c *SMIMEConfig) Validate() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	switch {
+	case c.Provisioner == "":
+		return errors.New("smime.provisioner cannot be empty")
+	case c.CodeLifetime != nil && c.CodeLifetime.Duration < 0:
+		return errors.New("smime.codeLifetime must be greater than or equal to 0")
+	case c.Validity != nil && c.Validity.Duration < 0:
+		return errors.New("smime.validity must be greater than or equal to 0")
+	}
+	return nil
+}
+
+// EnrollmentConfig represents the config options of the enrollment API, where
+// the CA generates the key of the certificate. It's meant for appliances and
+// legacy systems that cannot create certificate requests, the keys of the
+// certificates issued with it are marked as server-side generated in the
+// audit log.
+type EnrollmentConfig struct {
+	Enabled bool `json:"enabled"`
+	// Provisioners are the names of the provisioners whose tokens can be used
+	// to enroll, if empty all the provisioners are allowed.
+	Provisioners []string `json:"provisioners,omitempty"`
+	// KeyType, Curve and Size define the generated keys, they default to EC
+	// keys with the P-256 curve.
+	KeyType string `json:"kty,omitempty"`
+	Curve   string `json:"crv,omitempty"`
+	Size    int    `json:"size,omitempty"`
+}
+
+// IsEnabled returns if the enrollment API is enabled.
+func (c *EnrollmentConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// IsProvisionerAllowed returns if the tokens of the provisioner with the given
+// name can be used to enroll.
+func (c *EnrollmentConfig) IsProvisionerAllowed(name string) bool {
+	if !c.IsEnabled() {
+		return false
+	}
+	if len(c.Provisioners) == 0 {
+		return true
+	}
+	for _, p := range c.Provisioners {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetKeyParams returns the key type, curve and size of the generated keys.
+func (c *EnrollmentConfig) GetKeyParams() (kty, crv string, size int) {
+	if c == nil || c.KeyType == "" {
+		return "EC", "P-256", 0
+	}
+	switch c.KeyType {
+	case "EC":
+		if c.Curve == "" {
+			return "EC", "P-256", 0
+		}
+	case "OKP":
+		return "OKP", "Ed25519", 0
+	case "RSA":
+		if c.Size == 0 {
+			return "RSA", "", 2048
+		}
+	}
+	return c.KeyType, c.Curve, c.Size
+}
+
+// Validate validates the enrollment configuration.
+func (c *EnrollmentConfig) Validate() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	kty, crv, size := c.GetKeyParams()
+	switch kty {
+	case "EC":
+		if crv != "P-256" && crv != "P-384" && crv != "P-521" {
+			return errors.Errorf("enrollment.crv %q is not supported", crv)
+		}
+	case "OKP":
+		if c.Curve != "" && c.Curve != "Ed25519" {
+			return errors.Errorf("enrollment.crv %q is not supported", c.Curve)
+		}
+	case "RSA":
+		if size < 2048 {
+			return errors.New("enrollment.size must be at least 2048")
+		}
+	default:
+		return errors.Errorf("enrollment.kty %q is not supported", kty)
+	}
+	return nil
+}
+
+// SubCAConfig represents the config options used to issue subordinate CA
+// certificates through the administration API.
+type SubCAConfig struct {
+	Enabled bool `json:"enabled"`
+	// Approvals is the number of super admins, other than the requester, that
+	// must approve a request before the certificate is signed. It defaults to
+	// 1.
+	Approvals int `json:"approvals,omitempty"`
+	// MaxPathLen is the maximum path length constraint of the subordinate
+	// CAs. It defaults to 0.
+	MaxPathLen int `json:"maxPathLen,omitempty"`
+	// MaxValidity is the maximum validity of the subordinate CAs. It defaults
+	// to 5 years.
+	MaxValidity *provisioner.Duration `json:"maxValidity,omitempty"`
+	// PermittedDNSDomains, if set, requires the subordinate CAs to be
+	// constrained to these domains or their subdomains.
+	PermittedDNSDomains []string `json:"permittedDNSDomains,omitempty"`
+	// Notify are the email addresses notified when a request is created,
+	// approved, rejected or issued. The notifications are sent using the
+	// email provider of the notifications.
+	Notify []string `json:"notify,omitempty"`
+}
+
+// IsEnabled returns if the subordinate CA issuance is enabled.
+func (c *SubCAConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// GetNotify returns the email addresses notified of the subordinate CA
+// requests.
+func (c *SubCAConfig) GetNotify() []string {
+	if !c.IsEnabled() {
+		return nil
+	}
+	return c.Notify
+}
+
+// Validate validates the subordinate CA configuration.
+func (c *SubCAConfig) Validate() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	switch {
+	case c.Approvals < 0:
+		return errors.New("subCA.approvals must be greater than or equal to 0")
+	case c.MaxPathLen < 0:
+		return errors.New("subCA.maxPathLen must be greater than or equal to 0")
+	case c.MaxValidity != nil && c.MaxValidity.Duration < 0:
+		return errors.New("subCA.maxValidity must be greater than or equal to 0")
+	}
+	for _, d := range c.PermittedDNSDomains {
+		if strings.TrimPrefix(d, ".") == "" {
+			return errors.New("subCA.permittedDNSDomains cannot contain empty values")
+		}
+	}
+	for _, e := range c.Notify {
+		if err := smime.ValidateEmail(e); err != nil {
+			return errors.Errorf("subCA.notify contains an invalid email address %s", e)
+		}
+	}
+	return nil
+}
+
+// AIAConfig represents the URLs embedded in the X.509 certificates signed by
+// the authority. CAIssuers and OCSP are added to the authority information
+// access extension, and CRLDistributionPoints to the CRL distribution points
+// extension. URLs already set by a template are not modified.
+type AIAConfig struct {
+	// CAIssuers are the URLs where the issuer certificate can be downloaded,
+	// e.g. https://ca.example.com/issuer.
+	CAIssuers []string `json:"caIssuers,omitempty"`
+	// OCSP are the URLs of the OCSP responders.
+	OCSP []string `json:"ocsp,omitempty"`
+	// CRLDistributionPoints are the URLs where the CRL can be downloaded,
+	// e.g. https://ca.example.com/crl. If not set, the CRL idpURL is used if
+	// the CRL is enabled.
+	CRLDistributionPoints []string `json:"crlDistributionPoints,omitempty"`
+}
+
+// Validate validates the AIA configuration.
+func (c *AIAConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	for name, urls := range map[string][]string{
+		"caIssuers":             c.CAIssuers,
+		"ocsp":                  c.OCSP,
+		"crlDistributionPoints": c.CRLDistributionPoints,
+	} {
+		for _, s := range urls {
+			if u, err := url.Parse(s); err != nil || u.Scheme == "" || u.Host == "" {
+				return errors.Errorf("aia.%s contains an invalid url %q", name, s)
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	// DefaultOCSPValidity is the default validity of the OCSP responses.
+	DefaultOCSPValidity = 24 * time.Hour
+	// DefaultOCSPPreSignInterval is the default interval between the runs
+	// that pre-sign the OCSP responses.
+	DefaultOCSPPreSignInterval = time.Hour
+)
+
+// OCSPConfig represents the config options used to create and cache the OCSP
+// responses of the X.509 certificates.
+type OCSPConfig struct {
+	Enabled bool `json:"enabled"`
+	// Validity is the time between the thisUpdate and nextUpdate fields of
+	// the responses. Responses are signed again after half of it. It
+	// defaults to 24h.
+	Validity *provisioner.Duration `json:"validity,omitempty"`
+	// PreSign enables the scheduled signing of the responses of all the
+	// non-revoked certificates in the database.
+	PreSign bool `json:"preSign,omitempty"`
+	// PreSignInterval is the interval between the pre-signing runs. It
+	// defaults to 1h.
+	PreSignInterval *provisioner.Duration `json:"preSignInterval,omitempty"`
+	// MemoryCacheDuration is the time that a response is kept in memory
+	// before reading it again from the database. It defaults to 1m.
+	MemoryCacheDuration *provisioner.Duration `json:"memoryCacheDuration,omitempty"`
+	// ResponderCert and ResponderKey are the certificate and the key of a
+	// dedicated OCSP responder, issued by the intermediate with the
+	// id-kp-OCSPSigning extended key usage. The key can be a KMS URI. If not
+	// set, the responses are signed by the intermediate or by the delegated
+	// OCSP signer.
+	ResponderCert string `json:"responderCert,omitempty"`
+	ResponderKey  string `json:"responderKey,omitempty"`
+	// IgnoreNonce serves the cached responses to the requests with a nonce
+	// extension, without the nonce, as RFC 8954 allows. By default the
+	// requests with a nonce get a new response with the same nonce.
+	IgnoreNonce bool `json:"ignoreNonce,omitempty"`
+}
+
+// IsEnabled returns if the OCSP responses are enabled.
+func (c *OCSPConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// GetValidity returns the validity of the OCSP responses.
+func (c *OCSPConfig) GetValidity() time.Duration {
+	if c == nil || c.Validity == nil || c.Validity.Duration == 0 {
+		return DefaultOCSPValidity
+	}
+	return c.Validity.Duration
+}
+
+// GetPreSignInterval returns the interval between the pre-signing runs.
+func (c *OCSPConfig) GetPreSignInterval() time.Duration {
+	if c == nil || c.PreSignInterval == nil || c.PreSignInterval.Duration == 0 {
+		return DefaultOCSPPreSignInterval
+	}
+	return c.PreSignInterval.Duration
+}
+
+// Validate validates the OCSP configuration.
+func (c *OCSPConfig) Validate() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	switch {
+	case c.Validity != nil && c.Validity.Duration < 0:
+		return errors.New("ocsp.validity must be greater than or equal to 0")
+	case c.PreSignInterval != nil && c.PreSignInterval.Duration < 0:
+		return errors.New("ocsp.preSignInterval must be greater than or equal to 0")
+	case c.MemoryCacheDuration != nil && c.MemoryCacheDuration.Duration < 0:
+		return errors.New("ocsp.memoryCacheDuration must be greater than or equal to 0")
+	case c.PreSign && c.GetPreSignInterval() >= c.GetValidity()/2:
+		return errors.New("ocsp.preSignInterval must be lower than half of ocsp.validity")
+	case (c.ResponderCert == "") != (c.ResponderKey == ""):
+		return errors.New("ocsp.responderCert and ocsp.responderKey must be set together")
+	}
+	return nil
+}
+
+// RevocationEventsConfig represents the config options used to notify the
+// revocation of certificates to the systems that subscribe to them.
+type RevocationEventsConfig struct {
+	Enabled bool `json:"enabled"`
+	// BufferSize is the number of recent events available to the long
+	// polling subscribers. It defaults to 1000.
+	BufferSize int `json:"bufferSize,omitempty"`
+	// Webhooks are the endpoints that receive every revocation event.
+	Webhooks []*revocation.Webhook `json:"webhooks,omitempty"`
+}
+
+// IsEnabled returns if the revocation events are enabled.
+func (c *RevocationEventsConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// Validate validates the revocation events configuration.
+func (c *RevocationEventsConfig) Validate() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	if c.BufferSize < 0 {
+		return errors.New("revocationEvents.bufferSize must be greater than or equal to 0")
+	}
+	names := make(map[string]bool)
+	for _, w := range c.Webhooks {
+		if w == nil {
+			return errors.New("revocationEvents.webhooks cannot contain empty values")
+		}
+		if err := w.Validate(); err != nil {
+			return errors.Wrap(err, "revocationEvents")
+		}
+		if names[w.Name] {
+			return errors.Errorf("revocationEvents: webhook %s is duplicated", w.Name)
+		}
+		names[w.Name] = true
+	}
+	return nil
+}
+
+// WebhooksConfig represents the webhooks called for the certificates of all
+// the provisioners.
+type WebhooksConfig struct {
+	// Issuance are the ENRICHING and AUTHORIZING webhooks called before
+	// signing a certificate, after the webhooks of the provisioner. They can
+	// add data to the templates or deny the request.
+	Issuance []*IssuanceWebhook `json:"issuance,omitempty"`
+	// Events are the endpoints that receive the events of the signed, renewed
+	// and revoked certificates. The events are delivered in the background and
+	// they do not affect the requests.
+	Events []*revocation.Webhook `json:"events,omitempty"`
+}
+
+// IssuanceWebhook is a webhook called before signing the certificates of all
+// the provisioners. The request and response are the same as the ones of the
+// provisioner webhooks.
+type IssuanceWebhook struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Kind is ENRICHING or AUTHORIZING.
+	Kind string `json:"kind"`
+	// CertType is X509, SSH or ALL, it defaults to ALL.
+	CertType                string `json:"certType,omitempty"`
+	Secret                  string `json:"secret,omitempty"`
+	BearerToken             string `json:"bearerToken,omitempty"`
+	DisableTLSClientAuth    bool   `json:"disableTLSClientAuth,omitempty"`
+	VerifyResponseSignature bool   `json:"verifyResponseSignature,omitempty"`
+}
+
+// Validate validates the issuance webhook configuration.
+func (w *IssuanceWebhook) Validate() error {
+	switch {
+	case w.Name == "":
+		return errors.New("webhook name cannot be empty")
+	case w.Kind != linkedca.Webhook_ENRICHING.String() && w.Kind != linkedca.Webhook_AUTHORIZING.String():
+		return errors.Errorf("webhook %s kind %q is not valid", w.Name, w.Kind)
+	}
+	switch w.CertType {
+	case "", linkedca.Webhook_ALL.String(), linkedca.Webhook_X509.String(), linkedca.Webhook_SSH.String():
+	default:
+		return errors.Errorf("webhook %s certType %q is not valid", w.Name, w.CertType)
+	}
+	if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.Errorf("webhook %s url %q is not valid", w.Name, w.URL)
+	}
+	if w.Secret != "" && !secret.IsReference(w.Secret) {
+		if _, err := base64.StdEncoding.DecodeString(w.Secret); err != nil {
+			return errors.Errorf("webhook %s secret must be base64 encoded", w.Name)
+		}
+	}
+	return nil
+}
+
+// ProvisionerWebhook returns the webhook used by the provisioners.
+func (w *IssuanceWebhook) ProvisionerWebhook() *provisioner.Webhook {
+	return &provisioner.Webhook{
+		ID:                      w.Name,
+		Name:                    w.Name,
+		URL:                     w.URL,
+		Kind:                    w.Kind,
+		CertType:                w.CertType,
+		Secret:                  w.Secret,
+		BearerToken:             w.BearerToken,
+		DisableTLSClientAuth:    w.DisableTLSClientAuth,
+		VerifyResponseSignature: w.VerifyResponseSignature,
+	}
+}
+
+// Validate validates the webhooks configuration.
+func (c *WebhooksConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	names := make(map[string]bool)
+	for _, w := range c.Issuance {
+		if w == nil {
+			return errors.New("webhooks.issuance cannot contain empty values")
+		}
+		if err := w.Validate(); err != nil {
+			return errors.Wrap(err, "webhooks.issuance")
+		}
+		if names[w.Name] {
+			return errors.Errorf("webhooks.issuance: webhook %s is duplicated", w.Name)
+		}
+		names[w.Name] = true
+	}
+	names = make(map[string]bool)
+	for _, w := range c.Events {
+		if w == nil {
+			return errors.New("webhooks.events cannot contain empty values")
+		}
+		if err := w.Validate(); err != nil {
+			return errors.Wrap(err, "webhooks.events")
+		}
+		if names[w.Name] {
+			return errors.Errorf("webhooks.events: webhook %s is duplicated", w.Name)
+		}
+		names[w.Name] = true
+	}
+	return nil
+}
+
+// GetIssuanceWebhooks returns the webhooks used by the provisioners.
+func (c *WebhooksConfig) GetIssuanceWebhooks() []*provisioner.Webhook {
+	if c == nil || len(c.Issuance) == 0 {
+		return nil
+	}
+	webhooks := make([]*provisioner.Webhook, len(c.Issuance))
+	for i, w := range c.Issuance {
+		webhooks[i] = w.ProvisionerWebhook()
+	}
+	return webhooks
+}
+
+// GetEvents returns the endpoints that receive the activity events.
+func (c *WebhooksConfig) GetEvents() []*revocation.Webhook {
+	if c == nil {
+		return nil
+	}
+	return c.Events
+}
+
+// FederationConfig represents the config options used to trust the
+// certificates of other authorities.
+type FederationConfig struct {
+	Peers []*FederatedPeer `json:"peers,omitempty"`
+}
+
+// FederatedPeer is an authority trusted by this one. The roots of a peer are
+// read from the given bundles, or downloaded from its URL and verified with
+// the fingerprint of one of them.
+//
+// If AcceptRenewals is true, the certificates issued by the peer can be
+// renewed using mTLS. The renewed certificates are issued by this authority
+// on behalf of the given provisioner.
+type FederatedPeer struct {
+	Name           string   `json:"name"`
+	URL            string   `json:"url,omitempty"`
+	Fingerprint    string   `json:"fingerprint,omitempty"`
+	Roots          []string `json:"roots,omitempty"`
+	AcceptRenewals bool     `json:"acceptRenewals,omitempty"`
+	Provisioner    string   `json:"provisioner,omitempty"`
+}
+
+// Validate validates the federation configuration.
+func (c *FederationConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	names := make(map[string]bool)
+	for _, p := range c.Peers {
+		if p == nil {
+			return errors.New("federation.peers cannot contain empty values")
+		}
+		if err := p.Validate(); err != nil {
+			return errors.Wrap(err, "federation")
+		}
+		if names[p.Name] {
+			return errors.Errorf("federation: peer %s is duplicated", p.Name)
+		}
+		names[p.Name] = true
+	}
+	return nil
+}
+
+// Validate validates the configuration of a federated peer.
+func (p *FederatedPeer) Validate() error {
+	switch {
+	case p.Name == "":
+		return errors.New("peer name cannot be empty")
+	case p.URL == "" && len(p.Roots) == 0:
+		return errors.Errorf("peer %s must define the url or the roots", p.Name)
+	case p.AcceptRenewals && p.Provisioner == "":
+		return errors.Errorf("peer %s provisioner cannot be empty if acceptRenewals is set", p.Name)
+	}
+	if p.URL != "" {
+		if u, err := url.Parse(p.URL); err != nil || u.Scheme != "https" || u.Host == "" {
+			return errors.Errorf("peer %s url %q is not valid", p.Name, p.URL)
+		}
+		if b, err := hex.DecodeString(p.Fingerprint); err != nil || len(b) != sha256.Size {
+			return errors.Errorf("peer %s fingerprint must be a hex encoded SHA-256 fingerprint", p.Name)
+		}
+	}
+	return nil
+}
+
+// DefaultLeaderLeaseDuration is the default duration of the lease held by the
+// leader of multiple CA instances.
+var DefaultLeaderLeaseDuration = 30 * time.Second
+
+// LeaderElectionConfig represents the config options used to elect the
+// instance that runs the background jobs, like the CRL generation, when
+// multiple instances share the same database.
+type LeaderElectionConfig struct {
+	Enabled bool `json:"enabled"`
+	// ID identifies this instance, it defaults to the hostname followed by a
+	// random string.
+	ID string `json:"id,omitempty"`
+	// LeaseDuration is the duration of the lease held by the leader, it
+	// defaults to 30 seconds. The lease is renewed every third of it.
+	LeaseDuration *provisioner.Duration `json:"leaseDuration,omitempty"`
+}
+
+// IsEnabled returns if the leader election is enabled.
+func (c *LeaderElectionConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// Validate validates the leader election configuration.
+func (c *LeaderElectionConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.LeaseDuration != nil && c.LeaseDuration.Duration < 0 {
+		return errors.New("leaderElection.leaseDuration must be greater than or equal to 0")
+	}
+	return nil
+}
+
+// LeaseTTL returns the duration of the lease held by the leader.
+func (c *LeaderElectionConfig) LeaseTTL() time.Duration {
+	if !c.IsEnabled() {
+		return 0
+	}
+	if c.LeaseDuration != nil && c.LeaseDuration.Duration > 0 {
+		return c.LeaseDuration.Duration
+	}
+	return DefaultLeaderLeaseDuration
+}
+
 // ASN1DN contains ASN1.DN attributes that are used in Subject and Issuer
 // x509 Certificate blocks.
 type ASN1DN struct {
@@ -168,6 +1833,12 @@ type AuthConfig struct {
 	Backdate             *provisioner.Duration `json:"backdate,omitempty"`
 	EnableAdmin          bool                  `json:"enableAdmin,omitempty"`
 	DisableGetSSHHosts   bool                  `json:"disableGetSSHHosts,omitempty"`
+	KeyPolicy            *keypolicy.Options    `json:"keyPolicy,omitempty"`
+	// DenyRevokedIdentities denies new X.509 certificates for the common
+	// name and SANs of the certificates revoked with the keyCompromise or
+	// cessationOfOperation reasons until an admin clears them. It requires a
+	// database that can store the denied identities.
+	DenyRevokedIdentities bool `json:"denyRevokedIdentities,omitempty"`
 }
 
 // init initializes the required fields in the AuthConfig if they are not
@@ -210,20 +1881,35 @@ func (c *AuthConfig) Validate(provisioner.Audiences) error {
 		return errors.New("authority.backdate cannot be less than 0")
 	}
 
+	// Validate key policy: nil is ok
+	if err := c.KeyPolicy.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // LoadConfiguration parses the given filename in JSON format and returns the
 // configuration struct.
+//
+// The file is validated with ValidateSchema before being parsed, the unknown
+// and deprecated options are logged as warnings.
 func LoadConfiguration(filename string) (*Config, error) {
-	f, err := os.Open(filename)
+	b, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error opening %s", filename)
 	}
-	defer f.Close()
+
+	warnings, err := ValidateSchema(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", filename)
+	}
+	for _, w := range warnings {
+		log.Printf("warning: %s: %s", filename, w)
+	}
 
 	var c Config
-	if err := json.NewDecoder(f).Decode(&c); err != nil {
+	if err := json.Unmarshal(b, &c); err != nil {
 		return nil, errors.Wrapf(err, "error parsing %s", filename)
 	}
 
@@ -327,6 +2013,13 @@ func (c *Config) Validate() error {
 		return errors.Errorf("invalid address %s", c.Address)
 	}
 
+	// Validate metrics address, empty is ok
+	if c.MetricsAddress != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddress); err != nil {
+			return errors.Errorf("invalid metricsAddress %s", c.MetricsAddress)
+		}
+	}
+
 	if c.TLS == nil {
 		c.TLS = &DefaultTLSOptions
 	} else {
@@ -349,6 +2042,12 @@ func (c *Config) Validate() error {
 	if err := c.KMS.Validate(); err != nil {
 		return err
 	}
+	if err := validateKMSKey(c.KMS, c.IntermediateKey); err != nil {
+		return err
+	}
+	if err := c.KMSSigner.Validate(); err != nil {
+		return err
+	}
 
 	// Validate RA/CAS options, nil is ok.
 	if err := ra.Validate(); err != nil {
@@ -370,6 +2069,181 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	// Validate cert-manager config: nil is ok
+	if err := c.CertManager.Validate(); err != nil {
+		return err
+	}
+
+	// Validate sds config: nil is ok
+	if err := c.SDS.Validate(); err != nil {
+		return err
+	}
+
+	// Validate tsa config: nil is ok
+	if err := c.TSA.Validate(); err != nil {
+		return err
+	}
+
+	// Validate response signing config: nil is ok
+	if err := c.ResponseSigning.Validate(); err != nil {
+		return err
+	}
+	if c.ResponseSigning.IsEnabled() && c.ResponseSigning.Key == "" && c.IntermediateKey == "" {
+		return errors.New("responseSigning.key cannot be empty without an intermediate key")
+	}
+
+	// Validate smime config: nil is ok
+	if err := c.SMIME.Validate(); err != nil {
+		return err
+	}
+
+	// Validate enrollment config: nil is ok
+	if err := c.Enrollment.Validate(); err != nil {
+		return err
+	}
+
+	// Validate subCA config: nil is ok
+	if err := c.SubCA.Validate(); err != nil {
+		return err
+	}
+
+	// Validate aia config: nil is ok
+	if err := c.AIA.Validate(); err != nil {
+		return err
+	}
+
+	// Validate ocsp config: nil is ok
+	if err := c.OCSP.Validate(); err != nil {
+		return err
+	}
+
+	// Validate revocation events config: nil is ok
+	if err := c.RevocationEvents.Validate(); err != nil {
+		return err
+	}
+
+	// Validate federation config: nil is ok
+	if err := c.Federation.Validate(); err != nil {
+		return err
+	}
+
+	// Validate public tls config: nil is ok
+	if err := c.PublicTLS.Validate(); err != nil {
+		return err
+	}
+	if c.PublicTLS.IsEnabled() && c.Kubernetes != nil && c.Kubernetes.TLSSecret != "" {
+		return errors.New("publicTLS cannot be used with kubernetes.tlsSecret")
+	}
+	if c.PublicTLS.IsSelfSolving() && c.InsecureAddress == "" {
+		return errors.New("publicTLS selfSolve requires an insecureAddress")
+	}
+
+	// Validate idempotency config: nil is ok
+	if err := c.Idempotency.Validate(); err != nil {
+		return err
+	}
+
+	// Validate batch sign config: nil is ok
+	if err := c.BatchSign.Validate(); err != nil {
+		return err
+	}
+
+	// Validate activity config: nil is ok
+	if err := c.Activity.Validate(); err != nil {
+		return err
+	}
+
+	// Validate webhooks config: nil is ok
+	if err := c.Webhooks.Validate(); err != nil {
+		return err
+	}
+
+	// Validate self-test config: nil is ok
+	if err := c.SelfTest.Validate(); err != nil {
+		return err
+	}
+
+	// Validate shutdown config: nil is ok
+	if err := c.Shutdown.Validate(); err != nil {
+		return err
+	}
+
+	// Validate maintenance config: nil is ok
+	if err := c.Maintenance.Validate(); err != nil {
+		return err
+	}
+
+	// Validate load shedding config: nil is ok
+	if err := c.LoadShedding.Validate(); err != nil {
+		return err
+	}
+
+	// Validate delegated signers config: nil is ok
+	if err := c.DelegatedSigners.Validate(); err != nil {
+		return err
+	}
+	if c.DelegatedSigners.IsEnabled() {
+		validity := c.DelegatedSigners.GetValidity()
+		if c.DelegatedSigners.OCSP && c.OCSP.IsEnabled() && c.OCSP.ResponderCert != "" {
+			return errors.New("delegatedSigners.ocsp cannot be used with ocsp.responderCert")
+		}
+		if c.DelegatedSigners.OCSP && c.OCSP.IsEnabled() && validity <= c.OCSP.GetValidity() {
+			return errors.New("delegatedSigners.validity must be greater than ocsp.validity")
+		}
+		if c.DelegatedSigners.CRL && c.CRL.IsEnabled() && c.CRL.CacheDuration != nil && validity <= c.CRL.CacheDuration.Duration {
+			return errors.New("delegatedSigners.validity must be greater than crl.cacheDuration")
+		}
+	}
+
+	// Validate notifications config: nil is ok
+	if err := c.Notifications.Validate(); err != nil {
+		return err
+	}
+	if len(c.SubCA.GetNotify()) > 0 && (c.Notifications == nil || c.Notifications.Email == nil) {
+		return errors.New("subCA.notify requires an email provider in notifications")
+	}
+
+	// Validate CA expiry config: nil is ok
+	if err := c.CAExpiry.Validate(); err != nil {
+		return err
+	}
+	if c.CAExpiry != nil && len(c.CAExpiry.Notify) > 0 && (c.Notifications == nil || c.Notifications.Email == nil) {
+		return errors.New("caExpiry.notify requires an email provider in notifications")
+	}
+
+	// Validate certificate metrics config: nil is ok
+	if err := c.CertMetrics.Validate(); err != nil {
+		return err
+	}
+	if c.CertMetrics != nil && c.MetricsAddress == "" {
+		return errors.New("certificateMetrics requires the metricsAddress")
+	}
+
+	// Validate chaos config: nil is ok
+	if err := c.Chaos.Validate(); err != nil {
+		return err
+	}
+
+	// Validate audit config: nil is ok
+	if err := c.Audit.Validate(); err != nil {
+		return err
+	}
+
+	// Validate compliance profiles: nil is ok
+	if err := c.Compliance.Validate(); err != nil {
+		return err
+	}
+
+	// Validate cache config: nil is ok
+	if err := c.Cache.Validate(); err != nil {
+		return err
+	}
+
+	// Validate leader election config: nil is ok
+	if err := c.LeaderElection.Validate(); err != nil {
+		return err
+	}
+
 	return c.AuthorityConfig.Validate(c.GetAudiences())
 }
 
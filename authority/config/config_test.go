@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/keypolicy"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/revocation"
 	_ "github.com/smallstep/certificates/cas"
 	"go.step.sm/crypto/jose"
+	kms "go.step.sm/crypto/kms/apiv1"
 )
 
 func TestConfigValidate(t *testing.T) {
@@ -287,6 +291,15 @@ func TestAuthConfigValidate(t *testing.T) {
 				asn1dn: asn1dn,
 			}
 		},
+		"fail-key-policy": func(t *testing.T) AuthConfigValidateTest {
+			return AuthConfigValidateTest{
+				ac: &AuthConfig{
+					Provisioners: p,
+					KeyPolicy:    &keypolicy.Options{Algorithms: []string{"DSA"}},
+				},
+				err: errors.New("keyPolicy.algorithms contains an unsupported algorithm \"DSA\""),
+			}
+		},
 	}
 
 	for name, get := range tests {
@@ -360,3 +373,618 @@ func TestConfig_Audience(t *testing.T) {
 		})
 	}
 }
+
+func TestTSAConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *TSAConfig
+		wantErr string
+	}{
+		{"ok/nil", nil, ""},
+		{"ok/disabled", &TSAConfig{}, ""},
+		{"ok", &TSAConfig{Enabled: true, Certificate: "tsa.crt", Key: "tsa.key", Policies: []string{"1.2.3.4"}}, ""},
+		{"fail/crt", &TSAConfig{Enabled: true, Key: "tsa.key", Policies: []string{"1.2.3.4"}}, "tsa.crt cannot be empty"},
+		{"fail/key", &TSAConfig{Enabled: true, Certificate: "tsa.crt", Policies: []string{"1.2.3.4"}}, "tsa.key cannot be empty"},
+		{"fail/policies", &TSAConfig{Enabled: true, Certificate: "tsa.crt", Key: "tsa.key"}, "tsa.policies cannot be empty"},
+		{"fail/policy", &TSAConfig{Enabled: true, Certificate: "tsa.crt", Key: "tsa.key", Policies: []string{"1.2.3.4", "1.foo"}}, "invalid tsa.policies 1.foo"},
+		{"fail/short-policy", &TSAConfig{Enabled: true, Certificate: "tsa.crt", Key: "tsa.key", Policies: []string{"1"}}, "invalid tsa.policies 1"},
+		{"fail/accuracy", &TSAConfig{Enabled: true, Certificate: "tsa.crt", Key: "tsa.key", Policies: []string{"1.2.3.4"}, Accuracy: &provisioner.Duration{Duration: -1}}, "tsa.accuracy must be greater than or equal to 0"},
+		{"ok/url", &TSAConfig{Enabled: true, Certificate: "tsa.crt", Key: "tsa.key", Policies: []string{"1.2.3.4"}, URL: "http://ca.example.com/tsa"}, ""},
+		{"fail/url", &TSAConfig{Enabled: true, Certificate: "tsa.crt", Key: "tsa.key", Policies: []string{"1.2.3.4"}, URL: "/tsa"}, "invalid tsa.url /tsa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equals(t, tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestResponseSigningConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *ResponseSigningConfig
+		wantErr string
+	}{
+		{"ok/nil", nil, ""},
+		{"ok/disabled", &ResponseSigningConfig{Endpoints: []string{"foo"}}, ""},
+		{"ok", &ResponseSigningConfig{Enabled: true}, ""},
+		{"ok/key", &ResponseSigningConfig{Enabled: true, Certificate: "signer.crt", Key: "signer.key", Endpoints: []string{"roots", "federation", "provisioners"}}, ""},
+		{"fail/crt", &ResponseSigningConfig{Enabled: true, Key: "signer.key"}, "responseSigning.crt and responseSigning.key must be both set or empty"},
+		{"fail/key", &ResponseSigningConfig{Enabled: true, Certificate: "signer.crt"}, "responseSigning.crt and responseSigning.key must be both set or empty"},
+		{"fail/endpoints", &ResponseSigningConfig{Enabled: true, Endpoints: []string{"roots", "sign"}}, "unsupported responseSigning.endpoints sign"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equals(t, tt.wantErr, err.Error())
+			}
+		})
+	}
+
+	var c *ResponseSigningConfig
+	assert.False(t, c.IsSigned(ResponseSigningRoots))
+	c = &ResponseSigningConfig{Enabled: true}
+	assert.True(t, c.IsSigned(ResponseSigningRoots))
+	assert.True(t, c.IsSigned(ResponseSigningProvisioners))
+	c.Endpoints = []string{ResponseSigningFederation}
+	assert.False(t, c.IsSigned(ResponseSigningRoots))
+	assert.True(t, c.IsSigned(ResponseSigningFederation))
+}
+
+func TestSMIMEConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *SMIMEConfig
+		wantErr string
+	}{
+		{"ok/nil", nil, ""},
+		{"ok/disabled", &SMIMEConfig{}, ""},
+		{"ok", &SMIMEConfig{Enabled: true, Provisioner: "smime"}, ""},
+		{"fail/provisioner", &SMIMEConfig{Enabled: true}, "smime.provisioner cannot be empty"},
+		{"fail/codeLifetime", &SMIMEConfig{Enabled: true, Provisioner: "smime", CodeLifetime: &provisioner.Duration{Duration: -1}}, "smime.codeLifetime must be greater than or equal to 0"},
+		{"fail/validity", &SMIMEConfig{Enabled: true, Provisioner: "smime", Validity: &provisioner.Duration{Duration: -1}}, "smime.validity must be greater than or equal to 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equals(t, tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestSubCAConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *SubCAConfig
+		wantErr string
+	}{
+		{"ok/nil", nil, ""},
+		{"ok/disabled", &SubCAConfig{Approvals: -1}, ""},
+		{"ok", &SubCAConfig{Enabled: true, Approvals: 2, MaxPathLen: 1, MaxValidity: &provisioner.Duration{Duration: time.Hour}, PermittedDNSDomains: []string{"smallstep.com", ".internal"}}, ""},
+		{"fail/approvals", &SubCAConfig{Enabled: true, Approvals: -1}, "subCA.approvals must be greater than or equal to 0"},
+		{"fail/maxPathLen", &SubCAConfig{Enabled: true, MaxPathLen: -1}, "subCA.maxPathLen must be greater than or equal to 0"},
+		{"fail/maxValidity", &SubCAConfig{Enabled: true, MaxValidity: &provisioner.Duration{Duration: -1}}, "subCA.maxValidity must be greater than or equal to 0"},
+		{"fail/permittedDNSDomains", &SubCAConfig{Enabled: true, PermittedDNSDomains: []string{"."}}, "subCA.permittedDNSDomains cannot contain empty values"},
+		{"fail/notify", &SubCAConfig{Enabled: true, Notify: []string{"security@example.com", "not-an-email"}}, "subCA.notify contains an invalid email address not-an-email"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equals(t, tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestAIAConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *AIAConfig
+		wantErr string
+	}{
+		{"ok/nil", nil, ""},
+		{"ok", &AIAConfig{
+			CAIssuers:             []string{"https://ca.example.com/issuer"},
+			OCSP:                  []string{"http://ocsp.example.com"},
+			CRLDistributionPoints: []string{"http://ca.example.com/crl"},
+		}, ""},
+		{"fail/caIssuers", &AIAConfig{CAIssuers: []string{"/issuer"}}, `aia.caIssuers contains an invalid url "/issuer"`},
+		{"fail/ocsp", &AIAConfig{OCSP: []string{"ocsp.example.com"}}, `aia.ocsp contains an invalid url "ocsp.example.com"`},
+		{"fail/crlDistributionPoints", &AIAConfig{CRLDistributionPoints: []string{":foo"}}, `aia.crlDistributionPoints contains an invalid url ":foo"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equals(t, tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestOCSPConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *OCSPConfig
+		wantErr string
+	}{
+		{"ok/nil", nil, ""},
+		{"ok/disabled", &OCSPConfig{Validity: &provisioner.Duration{Duration: -1}}, ""},
+		{"ok", &OCSPConfig{Enabled: true, PreSign: true}, ""},
+		{"ok/intervals", &OCSPConfig{Enabled: true, PreSign: true, Validity: &provisioner.Duration{Duration: time.Hour}, PreSignInterval: &provisioner.Duration{Duration: 10 * time.Minute}}, ""},
+		{"fail/validity", &OCSPConfig{Enabled: true, Validity: &provisioner.Duration{Duration: -1}}, "ocsp.validity must be greater than or equal to 0"},
+		{"fail/preSignInterval", &OCSPConfig{Enabled: true, PreSignInterval: &provisioner.Duration{Duration: -1}}, "ocsp.preSignInterval must be greater than or equal to 0"},
+		{"fail/memoryCacheDuration", &OCSPConfig{Enabled: true, MemoryCacheDuration: &provisioner.Duration{Duration: -1}}, "ocsp.memoryCacheDuration must be greater than or equal to 0"},
+		{"fail/preSign", &OCSPConfig{Enabled: true, PreSign: true, Validity: &provisioner.Duration{Duration: time.Hour}}, "ocsp.preSignInterval must be lower than half of ocsp.validity"},
+		{"ok/responder", &OCSPConfig{Enabled: true, ResponderCert: "responder.crt", ResponderKey: "responder.key"}, ""},
+		{"fail/responderKey", &OCSPConfig{Enabled: true, ResponderCert: "responder.crt"}, "ocsp.responderCert and ocsp.responderKey must be set together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equals(t, tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestRevocationEventsConfig_Validate(t *testing.T) {
+	webhook := &revocation.Webhook{Name: "sessions", URL: "https://sessions.example.com/revoked", Secret: "c2VjcmV0"}
+	tests := []struct {
+		name    string
+		config  *RevocationEventsConfig
+		wantErr string
+	}{
+		{"ok/nil", nil, ""},
+		{"ok/disabled", &RevocationEventsConfig{BufferSize: -1}, ""},
+		{"ok", &RevocationEventsConfig{Enabled: true, BufferSize: 10, Webhooks: []*revocation.Webhook{webhook}}, ""},
+		{"fail/bufferSize", &RevocationEventsConfig{Enabled: true, BufferSize: -1}, "revocationEvents.bufferSize must be greater than or equal to 0"},
+		{"fail/nil", &RevocationEventsConfig{Enabled: true, Webhooks: []*revocation.Webhook{nil}}, "revocationEvents.webhooks cannot contain empty values"},
+		{"fail/name", &RevocationEventsConfig{Enabled: true, Webhooks: []*revocation.Webhook{{URL: webhook.URL}}}, "revocationEvents: webhook name cannot be empty"},
+		{"fail/url", &RevocationEventsConfig{Enabled: true, Webhooks: []*revocation.Webhook{{Name: "foo", URL: "ftp://example.com"}}}, `revocationEvents: webhook foo url "ftp://example.com" is not valid`},
+		{"fail/secret", &RevocationEventsConfig{Enabled: true, Webhooks: []*revocation.Webhook{{Name: "foo", URL: webhook.URL, Secret: "%%"}}}, "revocationEvents: webhook foo secret must be base64 encoded"},
+		{"fail/duplicated", &RevocationEventsConfig{Enabled: true, Webhooks: []*revocation.Webhook{webhook, webhook}}, "revocationEvents: webhook sessions is duplicated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equals(t, tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestWebhooksConfig_Validate(t *testing.T) {
+	issuance := &IssuanceWebhook{Name: "inventory", URL: "https://inventory.example.com/authorize", Kind: "AUTHORIZING", Secret: "c2VjcmV0"}
+	events := &revocation.Webhook{Name: "siem", URL: "https://siem.example.com/events"}
+	tests := []struct {
+		name    string
+		config  *WebhooksConfig
+		wantErr string
+	}{
+		{"ok/nil", nil, ""},
+		{"ok", &WebhooksConfig{Issuance: []*IssuanceWebhook{issuance}, Events: []*revocation.Webhook{events}}, ""},
+		{"ok/enriching", &WebhooksConfig{Issuance: []*IssuanceWebhook{{Name: "foo", URL: issuance.URL, Kind: "ENRICHING", CertType: "X509"}}}, ""},
+		{"fail/nil", &WebhooksConfig{Issuance: []*IssuanceWebhook{nil}}, "webhooks.issuance cannot contain empty values"},
+		{"fail/name", &WebhooksConfig{Issuance: []*IssuanceWebhook{{URL: issuance.URL, Kind: "AUTHORIZING"}}}, "webhooks.issuance: webhook name cannot be empty"},
+		{"fail/kind", &WebhooksConfig{Issuance: []*IssuanceWebhook{{Name: "foo", URL: issuance.URL, Kind: "NOTIFYING"}}}, `webhooks.issuance: webhook foo kind "NOTIFYING" is not valid`},
+		{"fail/certType", &WebhooksConfig{Issuance: []*IssuanceWebhook{{Name: "foo", URL: issuance.URL, Kind: "AUTHORIZING", CertType: "PGP"}}}, `webhooks.issuance: webhook foo certType "PGP" is not valid`},
+		{"fail/url", &WebhooksConfig{Issuance: []*IssuanceWebhook{{Name: "foo", URL: "ftp://example.com", Kind: "AUTHORIZING"}}}, `webhooks.issuance: webhook foo url "ftp://example.com" is not valid`},
+		{"fail/secret", &WebhooksConfig{Issuance: []*IssuanceWebhook{{Name: "foo", URL: issuance.URL, Kind: "AUTHORIZING", Secret: "%%"}}}, "webhooks.issuance: webhook foo secret must be base64 encoded"},
+		{"fail/duplicated", &WebhooksConfig{Issuance: []*IssuanceWebhook{issuance, issuance}}, "webhooks.issuance: webhook inventory is duplicated"},
+		{"fail/events nil", &WebhooksConfig{Events: []*revocation.Webhook{nil}}, "webhooks.events cannot contain empty values"},
+		{"fail/events url", &WebhooksConfig{Events: []*revocation.Webhook{{Name: "foo"}}}, "webhooks.events: webhook foo url cannot be empty"},
+		{"fail/events duplicated", &WebhooksConfig{Events: []*revocation.Webhook{events, events}}, "webhooks.events: webhook siem is duplicated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equals(t, tt.wantErr, err.Error())
+			}
+		})
+	}
+
+	var c *WebhooksConfig
+	assert.Nil(t, c.GetIssuanceWebhooks())
+	assert.Nil(t, c.GetEvents())
+	c = &WebhooksConfig{Issuance: []*IssuanceWebhook{issuance}, Events: []*revocation.Webhook{events}}
+	assert.Equals(t, []*provisioner.Webhook{{
+		ID: "inventory", Name: "inventory", URL: issuance.URL, Kind: "AUTHORIZING", Secret: "c2VjcmV0",
+	}}, c.GetIssuanceWebhooks())
+	assert.Equals(t, []*revocation.Webhook{events}, c.GetEvents())
+}
+
+func TestFederationConfig_Validate(t *testing.T) {
+	fingerprint := "e7b8d8a1f0d7d1c1e5bd4c8b3fc1d3c5b9a4f0c2e1d2c3b4a5968778695a4b3c"
+	tests := []struct {
+		name    string
+		config  *FederationConfig
+		wantErr string
+	}{
+		{"ok/nil", nil, ""},
+		{"ok/empty", &FederationConfig{}, ""},
+		{"ok/roots", &FederationConfig{Peers: []*FederatedPeer{{Name: "eu", Roots: []string{"eu_root.crt"}}}}, ""},
+		{"ok/url", &FederationConfig{Peers: []*FederatedPeer{{Name: "eu", URL: "https://ca.eu.example.com", Fingerprint: fingerprint, AcceptRenewals: true, Provisioner: "eu"}}}, ""},
+		{"fail/nil", &FederationConfig{Peers: []*FederatedPeer{nil}}, "federation.peers cannot contain empty values"},
+		{"fail/name", &FederationConfig{Peers: []*FederatedPeer{{Roots: []string{"eu_root.crt"}}}}, "federation: peer name cannot be empty"},
+		{"fail/roots", &FederationConfig{Peers: []*FederatedPeer{{Name: "eu"}}}, "federation: peer eu must define the url or the roots"},
+		{"fail/provisioner", &FederationConfig{Peers: []*FederatedPeer{{Name: "eu", Roots: []string{"eu_root.crt"}, AcceptRenewals: true}}}, "federation: peer eu provisioner cannot be empty if acceptRenewals is set"},
+		{"fail/url", &FederationConfig{Peers: []*FederatedPeer{{Name: "eu", URL: "http://ca.eu.example.com", Fingerprint: fingerprint}}}, `federation: peer eu url "http://ca.eu.example.com" is not valid`},
+		{"fail/fingerprint", &FederationConfig{Peers: []*FederatedPeer{{Name: "eu", URL: "https://ca.eu.example.com", Fingerprint: "e7b8"}}}, "federation: peer eu fingerprint must be a hex encoded SHA-256 fingerprint"},
+		{"fail/duplicated", &FederationConfig{Peers: []*FederatedPeer{{Name: "eu", Roots: []string{"a.crt"}}, {Name: "eu", Roots: []string{"b.crt"}}}}, "federation: peer eu is duplicated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equals(t, tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestPublicTLSConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *PublicTLSConfig
+		wantErr string
+	}{
+		{"ok/nil", nil, ""},
+		{"ok/disabled", &PublicTLSConfig{}, ""},
+		{"ok", &PublicTLSConfig{Enabled: true, DirectoryURL: "https://acme-v02.api.letsencrypt.org/directory", DNSNames: []string{"ca.example.com"}}, ""},
+		{"fail/directoryURL", &PublicTLSConfig{Enabled: true}, "publicTLS.directoryURL cannot be empty"},
+		{"fail/https", &PublicTLSConfig{Enabled: true, DirectoryURL: "http://acme.example.com/directory"}, "publicTLS.directoryURL http://acme.example.com/directory is not a valid https url"},
+		{"fail/dnsNames", &PublicTLSConfig{Enabled: true, DirectoryURL: "https://acme.example.com/directory", DNSNames: []string{""}}, "publicTLS.dnsNames cannot contain empty values"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equals(t, tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestPublicTLSConfig_IsSelfSolving(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *PublicTLSConfig
+		want   bool
+	}{
+		{"nil", nil, false},
+		{"disabled", &PublicTLSConfig{Options: []byte(`{"selfSolve":true}`)}, false},
+		{"no options", &PublicTLSConfig{Enabled: true}, false},
+		{"false", &PublicTLSConfig{Enabled: true, Options: []byte(`{"webroot":"/var/www"}`)}, false},
+		{"true", &PublicTLSConfig{Enabled: true, Options: []byte(`{"selfSolve":true}`)}, true},
+		{"invalid", &PublicTLSConfig{Enabled: true, Options: []byte(`{"selfSolve":"yes"}`)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equals(t, tt.want, tt.config.IsSelfSolving())
+		})
+	}
+}
+
+func TestIdempotencyConfig(t *testing.T) {
+	var c *IdempotencyConfig
+	assert.True(t, c.IsEnabled())
+	assert.Equals(t, DefaultIdempotencyWindow, c.GetWindow())
+	assert.NoError(t, c.Validate())
+
+	c = &IdempotencyConfig{Disabled: true, Window: &provisioner.Duration{Duration: time.Hour}}
+	assert.False(t, c.IsEnabled())
+	assert.Equals(t, time.Hour, c.GetWindow())
+	assert.NoError(t, c.Validate())
+
+	c = &IdempotencyConfig{Window: &provisioner.Duration{Duration: -time.Hour}}
+	assert.Equals(t, "idempotency.window must be greater than or equal to 0", c.Validate().Error())
+}
+
+func TestBatchSignConfig(t *testing.T) {
+	var c *BatchSignConfig
+	assert.True(t, c.IsEnabled())
+	assert.Equals(t, DefaultBatchSignMaxRequests, c.GetMaxRequests())
+	assert.NoError(t, c.Validate())
+
+	c = &BatchSignConfig{Disabled: true, MaxRequests: 500}
+	assert.False(t, c.IsEnabled())
+	assert.Equals(t, 500, c.GetMaxRequests())
+	assert.NoError(t, c.Validate())
+
+	c = &BatchSignConfig{MaxRequests: -1}
+	assert.Equals(t, "batchSign.maxRequests must be greater than or equal to 0", c.Validate().Error())
+}
+
+func TestSelfTestConfig(t *testing.T) {
+	var c *SelfTestConfig
+	assert.Equals(t, DefaultSelfTestValidity, c.GetValidity())
+	assert.NoError(t, c.Validate())
+
+	c = &SelfTestConfig{Provisioner: "self-test", Validity: &provisioner.Duration{Duration: time.Minute}}
+	assert.Equals(t, time.Minute, c.GetValidity())
+	assert.NoError(t, c.Validate())
+
+	c = &SelfTestConfig{}
+	assert.Equals(t, "selfTest.provisioner cannot be empty", c.Validate().Error())
+	c = &SelfTestConfig{Provisioner: "self-test", Validity: &provisioner.Duration{Duration: -time.Minute}}
+	assert.Equals(t, "selfTest.validity cannot be negative", c.Validate().Error())
+}
+
+func TestShutdownConfig(t *testing.T) {
+	var c *ShutdownConfig
+	assert.Equals(t, DefaultDrainTimeout, c.GetDrainTimeout())
+	assert.NoError(t, c.Validate())
+
+	c = &ShutdownConfig{DrainTimeout: &provisioner.Duration{Duration: 10 * time.Second}}
+	assert.Equals(t, 10*time.Second, c.GetDrainTimeout())
+	assert.NoError(t, c.Validate())
+
+	c = &ShutdownConfig{DrainTimeout: &provisioner.Duration{Duration: -time.Second}}
+	assert.Equals(t, "shutdown.drainTimeout cannot be negative", c.Validate().Error())
+}
+
+func TestMaintenanceConfig(t *testing.T) {
+	var c *MaintenanceConfig
+	assert.False(t, c.IsEnabled())
+	assert.Equals(t, "The CA is in read-only mode for maintenance", c.GetMessage())
+	assert.Equals(t, DefaultMaintenanceRetryAfter, c.GetRetryAfter())
+	assert.NoError(t, c.Validate())
+
+	c = &MaintenanceConfig{Enabled: true, Message: "Database migration", RetryAfter: &provisioner.Duration{Duration: time.Minute}}
+	assert.True(t, c.IsEnabled())
+	assert.Equals(t, "Database migration", c.GetMessage())
+	assert.Equals(t, time.Minute, c.GetRetryAfter())
+	assert.NoError(t, c.Validate())
+
+	c = &MaintenanceConfig{Enabled: true, RetryAfter: &provisioner.Duration{Duration: -time.Minute}}
+	assert.Equals(t, "maintenance.retryAfter cannot be negative", c.Validate().Error())
+}
+
+func TestLoadSheddingConfig(t *testing.T) {
+	var c *LoadSheddingConfig
+	assert.False(t, c.IsEnabled())
+	assert.Equals(t, DefaultLoadSheddingMaxConcurrent, c.GetMaxConcurrent())
+	assert.Equals(t, DefaultLoadSheddingQueueSize, c.GetQueueSize())
+	assert.Equals(t, DefaultLoadSheddingQueueTimeout, c.GetQueueTimeout())
+	assert.Equals(t, DefaultLoadSheddingRetryAfter, c.GetRetryAfter())
+	assert.Equals(t, 8, c.GetWeight(LoadSheddingRenew))
+	assert.Equals(t, 1, c.GetWeight(LoadSheddingACME))
+	assert.NoError(t, c.Validate())
+
+	c = &LoadSheddingConfig{
+		Enabled:       true,
+		MaxConcurrent: 4,
+		QueueSize:     10,
+		QueueTimeout:  &provisioner.Duration{Duration: time.Second},
+		RetryAfter:    &provisioner.Duration{Duration: time.Minute},
+		Weights:       map[string]int{LoadSheddingACME: 3},
+	}
+	assert.True(t, c.IsEnabled())
+	assert.Equals(t, 4, c.GetMaxConcurrent())
+	assert.Equals(t, 10, c.GetQueueSize())
+	assert.Equals(t, time.Second, c.GetQueueTimeout())
+	assert.Equals(t, time.Minute, c.GetRetryAfter())
+	assert.Equals(t, 3, c.GetWeight(LoadSheddingACME))
+	assert.Equals(t, 2, c.GetWeight(LoadSheddingSign))
+	assert.NoError(t, c.Validate())
+
+	tests := map[string]*LoadSheddingConfig{
+		"loadShedding.maxConcurrent cannot be negative": {MaxConcurrent: -1},
+		"loadShedding.queueSize cannot be negative":     {QueueSize: -1},
+		"loadShedding.queueTimeout cannot be negative":  {QueueTimeout: &provisioner.Duration{Duration: -time.Second}},
+		"loadShedding.retryAfter cannot be negative":    {RetryAfter: &provisioner.Duration{Duration: -time.Second}},
+		"unsupported loadShedding.weights class scep":   {Weights: map[string]int{"scep": 1}},
+		"loadShedding.weights.ocsp cannot be negative":  {Weights: map[string]int{LoadSheddingOCSP: -1}},
+	}
+	for want, c := range tests {
+		assert.Equals(t, want, c.Validate().Error())
+	}
+}
+
+func TestKMSSignerConfig(t *testing.T) {
+	var c *KMSSignerConfig
+	assert.Equals(t, DefaultKMSSignerTimeout, c.GetTimeout())
+	assert.Equals(t, DefaultKMSSignerRetries, c.GetRetries())
+	assert.Equals(t, DefaultKMSSignerRetryAfter, c.GetRetryAfter())
+	assert.NoError(t, c.Validate())
+
+	retries := 3
+	c = &KMSSignerConfig{
+		Timeout:    &provisioner.Duration{Duration: time.Second},
+		Retries:    &retries,
+		RetryAfter: &provisioner.Duration{Duration: time.Minute},
+	}
+	assert.Equals(t, time.Second, c.GetTimeout())
+	assert.Equals(t, 3, c.GetRetries())
+	assert.Equals(t, time.Minute, c.GetRetryAfter())
+	assert.NoError(t, c.Validate())
+
+	retries = -1
+	assert.Equals(t, 0, c.GetRetries())
+
+	c = &KMSSignerConfig{Timeout: &provisioner.Duration{Duration: -time.Second}}
+	assert.Equals(t, "kmsSigner.timeout cannot be negative", c.Validate().Error())
+	c = &KMSSignerConfig{RetryAfter: &provisioner.Duration{Duration: -time.Second}}
+	assert.Equals(t, "kmsSigner.retryAfter cannot be negative", c.Validate().Error())
+}
+
+func Test_validateKMSKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    *kms.Options
+		key     string
+		wantErr string
+	}{
+		{"ok/file", nil, "/path/to/key", ""},
+		{"ok/softkms", nil, "softkms:path=/path/to/key", ""},
+		{"ok/awskms", &kms.Options{Type: "awskms", Region: "us-east-1"}, "awskms:key-id=1234", ""},
+		{"ok/pkcs11 uri", &kms.Options{URI: "pkcs11:module-path=/usr/lib/softhsm/libsofthsm2.so;token=step"}, "pkcs11:id=1000;object=intermediate-key", ""},
+		{"ok/case-insensitive", &kms.Options{Type: "CloudKMS"}, "cloudkms:projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1", ""},
+		{"fail/no kms", nil, "azurekms:name=key;vault=vault", "key azurekms:name=key;vault=vault requires a kms of type azurekms"},
+		{"fail/other kms", &kms.Options{Type: "awskms"}, "pkcs11:id=1000", "key pkcs11:id=1000 requires a kms of type pkcs11"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateKMSKey(tt.opts, tt.key)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Equals(t, tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestCAExpiryConfig(t *testing.T) {
+	var c *CAExpiryConfig
+	assert.Equals(t, DefaultCAExpiryInterval, c.GetInterval())
+	assert.Equals(t, DefaultCAExpiryThresholds, c.GetThresholds())
+	assert.NoError(t, c.Validate())
+
+	c = &CAExpiryConfig{
+		Interval:   &provisioner.Duration{Duration: 10 * time.Minute},
+		Thresholds: []provisioner.Duration{{Duration: time.Hour}, {Duration: 48 * time.Hour}},
+		Notify:     []string{"security@example.com"},
+	}
+	assert.Equals(t, 10*time.Minute, c.GetInterval())
+	assert.Equals(t, []time.Duration{48 * time.Hour, time.Hour}, c.GetThresholds())
+	assert.NoError(t, c.Validate())
+
+	c = &CAExpiryConfig{Interval: &provisioner.Duration{Duration: -time.Minute}}
+	assert.Equals(t, "caExpiry.interval cannot be negative", c.Validate().Error())
+	c = &CAExpiryConfig{Thresholds: []provisioner.Duration{{Duration: 0}}}
+	assert.Equals(t, "caExpiry.thresholds must be greater than 0", c.Validate().Error())
+	c = &CAExpiryConfig{Notify: []string{"security"}}
+	assert.Equals(t, "caExpiry.notify contains an invalid email address security", c.Validate().Error())
+}
+
+func TestCertMetricsConfig(t *testing.T) {
+	var c *CertMetricsConfig
+	assert.Equals(t, CertMetricsPerCertificate, c.GetMode())
+	assert.Equals(t, DefaultCertMetricsMaxSeries, c.GetMaxSeries())
+	assert.Equals(t, DefaultCertMetricsInterval, c.GetInterval())
+	assert.NoError(t, c.Validate())
+
+	c = &CertMetricsConfig{Mode: CertMetricsPerSANGroup, MaxSeries: 50, Interval: &provisioner.Duration{Duration: time.Minute}}
+	assert.Equals(t, CertMetricsPerSANGroup, c.GetMode())
+	assert.Equals(t, 50, c.GetMaxSeries())
+	assert.Equals(t, time.Minute, c.GetInterval())
+	assert.NoError(t, c.Validate())
+
+	c = &CertMetricsConfig{Mode: "subject"}
+	assert.Equals(t, "unsupported certificateMetrics.mode subject", c.Validate().Error())
+	c = &CertMetricsConfig{MaxSeries: -1}
+	assert.Equals(t, "certificateMetrics.maxSeries cannot be negative", c.Validate().Error())
+	c = &CertMetricsConfig{Interval: &provisioner.Duration{Duration: -time.Minute}}
+	assert.Equals(t, "certificateMetrics.interval cannot be negative", c.Validate().Error())
+}
+
+func TestEnrollmentConfig(t *testing.T) {
+	var c *EnrollmentConfig
+	assert.False(t, c.IsEnabled())
+	assert.False(t, c.IsProvisionerAllowed("jwk"))
+	assert.NoError(t, c.Validate())
+
+	c = &EnrollmentConfig{Enabled: true}
+	assert.True(t, c.IsProvisionerAllowed("jwk"))
+	kty, crv, size := c.GetKeyParams()
+	assert.Equals(t, []interface{}{"EC", "P-256", 0}, []interface{}{kty, crv, size})
+	assert.NoError(t, c.Validate())
+
+	c = &EnrollmentConfig{Enabled: true, Provisioners: []string{"jwk"}, KeyType: "RSA"}
+	assert.True(t, c.IsProvisionerAllowed("jwk"))
+	assert.False(t, c.IsProvisionerAllowed("oidc"))
+	kty, crv, size = c.GetKeyParams()
+	assert.Equals(t, []interface{}{"RSA", "", 2048}, []interface{}{kty, crv, size})
+	assert.NoError(t, c.Validate())
+
+	c = &EnrollmentConfig{Enabled: true, KeyType: "OKP"}
+	kty, crv, _ = c.GetKeyParams()
+	assert.Equals(t, []interface{}{"OKP", "Ed25519"}, []interface{}{kty, crv})
+	assert.NoError(t, c.Validate())
+
+	c = &EnrollmentConfig{Enabled: true, KeyType: "EC", Curve: "P-224"}
+	assert.Equals(t, `enrollment.crv "P-224" is not supported`, c.Validate().Error())
+	c = &EnrollmentConfig{Enabled: true, KeyType: "OKP", Curve: "X25519"}
+	assert.Equals(t, `enrollment.crv "X25519" is not supported`, c.Validate().Error())
+	c = &EnrollmentConfig{Enabled: true, KeyType: "RSA", Size: 1024}
+	assert.Equals(t, "enrollment.size must be at least 2048", c.Validate().Error())
+	c = &EnrollmentConfig{Enabled: true, KeyType: "oct"}
+	assert.Equals(t, `enrollment.kty "oct" is not supported`, c.Validate().Error())
+}
+
+func TestDelegatedSignersConfig(t *testing.T) {
+	var c *DelegatedSignersConfig
+	assert.False(t, c.IsEnabled())
+	assert.Equals(t, DefaultDelegatedSignerValidity, c.GetValidity())
+	assert.NoError(t, c.Validate())
+
+	c = &DelegatedSignersConfig{CRL: true, Validity: &provisioner.Duration{Duration: 48 * time.Hour}}
+	assert.True(t, c.IsEnabled())
+	assert.Equals(t, 48*time.Hour, c.GetValidity())
+	assert.NoError(t, c.Validate())
+
+	c = &DelegatedSignersConfig{OCSP: true, Validity: &provisioner.Duration{Duration: -time.Hour}}
+	assert.Equals(t, "delegatedSigners.validity cannot be negative", c.Validate().Error())
+}
+
+func TestActivityConfig(t *testing.T) {
+	var c *ActivityConfig
+	assert.False(t, c.IsEnabled())
+	assert.NoError(t, c.Validate())
+
+	c = &ActivityConfig{Enabled: true, BufferSize: 100}
+	assert.True(t, c.IsEnabled())
+	assert.NoError(t, c.Validate())
+
+	c = &ActivityConfig{Enabled: false, BufferSize: -1}
+	assert.NoError(t, c.Validate())
+
+	c = &ActivityConfig{Enabled: true, BufferSize: -1}
+	assert.Equals(t, "activity.bufferSize must be greater than or equal to 0", c.Validate().Error())
+
+	c = &ActivityConfig{Log: &ActivityLogConfig{Type: ActivityLogFile, Path: "/var/log/step-ca/activity.log"}}
+	assert.NoError(t, c.Validate())
+	assert.Equals(t, c.Log, c.GetLog())
+
+	c = &ActivityConfig{Log: &ActivityLogConfig{Type: ActivityLogSyslog}}
+	assert.NoError(t, c.Validate())
+	assert.Equals(t, DefaultActivityLogTag, c.GetLog().GetTag())
+
+	c = &ActivityConfig{Log: &ActivityLogConfig{Type: ActivityLogFile}}
+	assert.Equals(t, "activity.log.path cannot be empty", c.Validate().Error())
+
+	c = &ActivityConfig{Log: &ActivityLogConfig{Type: "journald"}}
+	assert.Equals(t, "unsupported activity.log.type journald", c.Validate().Error())
+}
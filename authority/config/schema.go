@@ -0,0 +1,357 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// deprecatedCipherSuites maps the legacy names of the cipher suites to the
+// current ones.
+var deprecatedCipherSuites = map[string]string{
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":   "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305": "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	provisionerListType = reflect.TypeOf(provisioner.List{})
+	multiStringType     = reflect.TypeOf(multiString{})
+	cipherSuitesType    = reflect.TypeOf(CipherSuites{})
+	tlsVersionType      = reflect.TypeOf(TLSVersion(0))
+)
+
+// ValidateSchema validates the JSON of a configuration file against the
+// schema defined by the Config type. It returns an error with the path of
+// every value with the wrong type, and it returns warnings with the path of
+// the unknown and deprecated options, for example:
+//
+//	authority.provisoners: unknown field, did you mean provisioners?
+//
+// Unknown fields are not errors because the encoding/json package ignores
+// them, and some tools add their own properties to the configuration. Fields
+// starting with an underscore, like "_logger", are considered comments.
+func ValidateSchema(data []byte) (warnings []string, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	s := new(schemaValidator)
+	s.validate("", reflect.TypeOf(Config{}), v)
+	if len(s.errors) > 0 {
+		return s.warnings, errors.New(strings.Join(s.errors, "; "))
+	}
+	return s.warnings, nil
+}
+
+type schemaValidator struct {
+	errors   []string
+	warnings []string
+}
+
+func (s *schemaValidator) errorf(path, format string, args ...interface{}) {
+	s.errors = append(s.errors, formatPath(path)+": "+fmt.Sprintf(format, args...))
+}
+
+func (s *schemaValidator) warnf(path, format string, args ...interface{}) {
+	s.warnings = append(s.warnings, formatPath(path)+": "+fmt.Sprintf(format, args...))
+}
+
+func (s *schemaValidator) validate(path string, t reflect.Type, v interface{}) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if v == nil {
+		return
+	}
+
+	// Types with a custom format.
+	switch t {
+	case provisionerListType:
+		s.validateProvisioners(path, v)
+		return
+	case multiStringType:
+		if _, ok := v.(string); !ok {
+			s.validate(path, reflect.TypeOf([]string{}), v)
+		}
+		return
+	case cipherSuitesType:
+		s.validate(path, reflect.TypeOf([]string{}), v)
+		if values, ok := v.([]interface{}); ok {
+			for i, cs := range values {
+				if name, ok := cs.(string); ok {
+					if newName, ok := deprecatedCipherSuites[name]; ok {
+						s.warnf(indexPath(path, i), "cipher suite %s is deprecated, use %s", name, newName)
+					}
+				}
+			}
+		}
+		return
+	case tlsVersionType:
+		if n, ok := v.(json.Number); !ok {
+			s.errorf(path, "expected number, got %s", jsonKind(v))
+		} else if f, err := n.Float64(); err == nil && f > 0 && f < 1.2 {
+			s.warnf(path, "TLS %s is deprecated, use 1.2 or greater", n)
+		}
+		return
+	}
+	// Other types with a custom format are validated when the config is
+	// decoded.
+	if t.Implements(unmarshalerType) || reflect.PtrTo(t).Implements(unmarshalerType) {
+		return
+	}
+	if t.Implements(textUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		if _, ok := v.(string); !ok {
+			s.errorf(path, "expected string, got %s", jsonKind(v))
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			s.errorf(path, "expected boolean, got %s", jsonKind(v))
+		}
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			s.errorf(path, "expected string, got %s", jsonKind(v))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := v.(json.Number)
+		if !ok {
+			s.errorf(path, "expected integer, got %s", jsonKind(v))
+		} else if _, err := strconv.ParseInt(n.String(), 10, 64); err != nil {
+			s.errorf(path, "expected integer, got %s", n)
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := v.(json.Number); !ok {
+			s.errorf(path, "expected number, got %s", jsonKind(v))
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if _, ok := v.(string); !ok {
+				s.errorf(path, "expected base64 string, got %s", jsonKind(v))
+			}
+			return
+		}
+		values, ok := v.([]interface{})
+		if !ok {
+			s.errorf(path, "expected array, got %s", jsonKind(v))
+			return
+		}
+		for i, vv := range values {
+			s.validate(indexPath(path, i), t.Elem(), vv)
+		}
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			s.errorf(path, "expected object, got %s", jsonKind(v))
+			return
+		}
+		for _, k := range sortedKeys(m) {
+			s.validate(fieldPath(path, k), t.Elem(), m[k])
+		}
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			s.errorf(path, "expected object, got %s", jsonKind(v))
+			return
+		}
+		fields := structFields(t)
+		for _, k := range sortedKeys(m) {
+			// Fields starting with an underscore are commented out.
+			if strings.HasPrefix(k, "_") {
+				continue
+			}
+			f, ok := lookupField(fields, k)
+			if !ok {
+				if suggestion := suggestField(fields, k); suggestion != "" {
+					s.warnf(fieldPath(path, k), "unknown field, did you mean %s?", suggestion)
+				} else {
+					s.warnf(fieldPath(path, k), "unknown field")
+				}
+				continue
+			}
+			s.validate(fieldPath(path, k), f.typ, m[k])
+		}
+	}
+}
+
+func (s *schemaValidator) validateProvisioners(path string, v interface{}) {
+	values, ok := v.([]interface{})
+	if !ok {
+		s.errorf(path, "expected array, got %s", jsonKind(v))
+		return
+	}
+	for i, vv := range values {
+		p := indexPath(path, i)
+		m, ok := vv.(map[string]interface{})
+		if !ok {
+			s.errorf(p, "expected object, got %s", jsonKind(vv))
+			continue
+		}
+		typ, ok := m["type"].(string)
+		if !ok {
+			s.errorf(fieldPath(p, "type"), "expected string, got %s", jsonKind(m["type"]))
+			continue
+		}
+		prov := provisioner.NewFromType(typ)
+		if prov == nil {
+			s.warnf(fieldPath(p, "type"), "provisioner type %s is not supported", typ)
+			continue
+		}
+		s.validate(p, reflect.TypeOf(prov), vv)
+	}
+}
+
+type schemaField struct {
+	name string
+	typ  reflect.Type
+}
+
+// structFields returns the fields of a struct as encoding/json sees them,
+// including the fields of embedded structs.
+func structFields(t reflect.Type) []schemaField {
+	var fields []schemaField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, structFields(ft)...)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, schemaField{name: name, typ: f.Type})
+	}
+	return fields
+}
+
+// lookupField returns the field with the given name, like encoding/json it
+// prefers an exact match but accepts a case-insensitive one.
+func lookupField(fields []schemaField, name string) (schemaField, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return schemaField{}, false
+}
+
+// suggestField returns the name of the field closest to the given name, or
+// an empty string if none of them is close enough.
+func suggestField(fields []schemaField, name string) string {
+	var suggestion string
+	best := len(name)/3 + 1
+	for _, f := range fields {
+		if d := levenshtein(strings.ToLower(f.name), strings.ToLower(name)); d < best {
+			best, suggestion = d, f.name
+		}
+	}
+	return suggestion
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if d := prev[j] + 1; d < curr[j] {
+				curr[j] = d
+			}
+			if d := curr[j-1] + 1; d < curr[j] {
+				curr[j] = d
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func indexPath(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+func formatPath(path string) string {
+	if path == "" {
+		return "config"
+	}
+	return path
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       string
+		wantWarnings []string
+		wantErr      string
+	}{
+		{"ok", `{
+			"root": "root.crt", "crt": "intermediate.crt", "key": "intermediate.key",
+			"address": ":443", "dnsNames": ["ca.example.com"],
+			"_logger": {"format": "text"},
+			"db": {"type": "badgerv2", "dataSource": "db"},
+			"authority": {
+				"type": "", "enableAdmin": true, "backdate": "1m",
+				"provisioners": [
+					{"type": "JWK", "name": "jane@example.com", "key": {"kty": "EC"}, "claims": {"maxTLSCertDuration": "24h"}},
+					{"type": "ACME", "name": "acme", "challenges": ["http-01"]}
+				]
+			},
+			"tls": {"cipherSuites": ["TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"], "minVersion": 1.2}
+		}`, nil, ""},
+		{"ok/root array", `{"root": ["a.crt", "b.crt"]}`, nil, ""},
+		{"ok/case-insensitive", `{"DNSNames": ["ca.example.com"]}`, nil, ""},
+		{"warn/unknown", `{"authority": {"provisoners": []}, "foo": 1}`, []string{
+			"authority.provisoners: unknown field, did you mean provisioners?",
+			"foo: unknown field",
+		}, ""},
+		{"warn/unknown in provisioner", `{"authority": {"provisioners": [{"type": "ACME", "name": "acme", "forceCNN": true}]}}`, []string{
+			"authority.provisioners[0].forceCNN: unknown field, did you mean forceCN?",
+		}, ""},
+		{"warn/provisioner type", `{"authority": {"provisioners": [{"type": "foo", "name": "foo"}]}}`, []string{
+			"authority.provisioners[0].type: provisioner type foo is not supported",
+		}, ""},
+		{"warn/deprecated", `{"tls": {"cipherSuites": ["TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305"], "minVersion": 1.0, "maxVersion": 1.3}}`, []string{
+			"tls.cipherSuites[0]: cipher suite TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305 is deprecated, use TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+			"tls.minVersion: TLS 1.0 is deprecated, use 1.2 or greater",
+		}, ""},
+		{"fail/type", `{"address": 443, "dnsNames": "ca.example.com", "authority": {"enableAdmin": "true"}}`, nil,
+			"address: expected string, got number; authority.enableAdmin: expected boolean, got string; dnsNames: expected array, got string"},
+		{"fail/provisioner", `{"authority": {"provisioners": [{"type": "JWK", "name": 1}, {"name": "foo"}, "bar"]}}`, nil,
+			"authority.provisioners[0].name: expected string, got number; authority.provisioners[1].type: expected string, got null; authority.provisioners[2]: expected object, got string"},
+		{"fail/integer", `{"authority": {"provisioners": [{"type": "ACME", "name": "acme", "challenges": [1]}]}, "crl": {"enabled": 1}}`, nil,
+			"authority.provisioners[0].challenges[0]: expected string, got number; crl.enabled: expected boolean, got number"},
+		{"fail/config", `[]`, nil, "config: expected object, got array"},
+		{"fail/json", `{`, nil, "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, err := ValidateSchema([]byte(tt.config))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantWarnings, warnings)
+		})
+	}
+}
+
+func TestLoadConfiguration_schema(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "ca.json")
+	require.NoError(t, os.WriteFile(filename, []byte(`{"authority": {"enableAdmin": "yes"}}`), 0600))
+
+	_, err := LoadConfiguration(filename)
+	assert.EqualError(t, err, "error parsing "+filename+": authority.enableAdmin: expected boolean, got string")
+}
@@ -158,6 +158,9 @@ type TLSOptions struct {
 	MinVersion    TLSVersion   `json:"minVersion"`
 	MaxVersion    TLSVersion   `json:"maxVersion"`
 	Renegotiation bool         `json:"renegotiation"`
+	// OCSPStapling enables the stapling of OCSP responses for the certificate
+	// used by the CA server. It has no effect on clients.
+	OCSPStapling bool `json:"ocspStapling,omitempty"`
 }
 
 // TLSConfig returns the tls.Config equivalent of the TLSOptions.
@@ -0,0 +1,175 @@
+// Package credential implements the stores of the provisioner credentials
+// managed with the administration API.
+package credential
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/nosql"
+	"github.com/smallstep/nosql/database"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+var credentialsTable = []byte("provisioner_credentials")
+
+// ErrNotFound is the error returned by the stores if a credential does not
+// exist.
+var ErrNotFound = errors.New("provisioner credential not found")
+
+// Store is the interface used to persist the provisioner credentials.
+type Store interface {
+	Save(c *provisioner.Credential) error
+	Get(id string) (*provisioner.Credential, error)
+	List() ([]*provisioner.Credential, error)
+	Delete(id string) error
+}
+
+// MemoryStore is a Store that keeps the credentials in memory. It is used
+// when the authority does not have a database.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	credentials map[string][]byte
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		credentials: make(map[string][]byte),
+	}
+}
+
+// Save implements the Store interface.
+func (s *MemoryStore) Save(c *provisioner.Credential) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling provisioner credential")
+	}
+	s.mu.Lock()
+	s.credentials[c.ID] = b
+	s.mu.Unlock()
+	return nil
+}
+
+// Get implements the Store interface.
+func (s *MemoryStore) Get(id string) (*provisioner.Credential, error) {
+	s.mu.RLock()
+	b, ok := s.credentials[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return unmarshalCredential(b)
+}
+
+// List implements the Store interface.
+func (s *MemoryStore) List() ([]*provisioner.Credential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	creds := make([]*provisioner.Credential, 0, len(s.credentials))
+	for _, b := range s.credentials {
+		c, err := unmarshalCredential(b)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, c)
+	}
+	sortCredentials(creds)
+	return creds, nil
+}
+
+// Delete implements the Store interface.
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.credentials[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.credentials, id)
+	return nil
+}
+
+// NoSQLStore is a Store that persists the credentials in the authority
+// database.
+type NoSQLStore struct {
+	db nosql.DB
+}
+
+// NewNoSQLStore creates the credentials table in the given database and
+// returns a new store.
+func NewNoSQLStore(db nosql.DB) (*NoSQLStore, error) {
+	if err := db.CreateTable(credentialsTable); err != nil {
+		return nil, errors.Wrapf(err, "error creating table %s", credentialsTable)
+	}
+	return &NoSQLStore{db: db}, nil
+}
+
+// Save implements the Store interface.
+func (s *NoSQLStore) Save(c *provisioner.Credential) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling provisioner credential")
+	}
+	return errors.Wrap(s.db.Set(credentialsTable, []byte(c.ID), b), "error storing provisioner credential")
+}
+
+// Get implements the Store interface.
+func (s *NoSQLStore) Get(id string) (*provisioner.Credential, error) {
+	b, err := s.db.Get(credentialsTable, []byte(id))
+	switch {
+	case database.IsErrNotFound(err):
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, errors.Wrap(err, "error loading provisioner credential")
+	}
+	return unmarshalCredential(b)
+}
+
+// List implements the Store interface.
+func (s *NoSQLStore) List() ([]*provisioner.Credential, error) {
+	entries, err := s.db.List(credentialsTable)
+	switch {
+	case database.IsErrNotFound(err):
+		return []*provisioner.Credential{}, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "error listing provisioner credentials")
+	}
+	creds := make([]*provisioner.Credential, 0, len(entries))
+	for _, e := range entries {
+		c, err := unmarshalCredential(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, c)
+	}
+	sortCredentials(creds)
+	return creds, nil
+}
+
+// Delete implements the Store interface.
+func (s *NoSQLStore) Delete(id string) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+	return errors.Wrap(s.db.Del(credentialsTable, []byte(id)), "error deleting provisioner credential")
+}
+
+func unmarshalCredential(b []byte) (*provisioner.Credential, error) {
+	c := new(provisioner.Credential)
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling provisioner credential")
+	}
+	return c, nil
+}
+
+func sortCredentials(creds []*provisioner.Credential) {
+	sort.Slice(creds, func(i, j int) bool {
+		if creds[i].CreatedAt.Equal(creds[j].CreatedAt) {
+			return creds[i].ID < creds[j].ID
+		}
+		return creds[i].CreatedAt.Before(creds[j].CreatedAt)
+	})
+}
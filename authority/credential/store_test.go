@@ -0,0 +1,40 @@
+package credential
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	_, err := s.Get("credential-id")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, s.Delete("credential-id"), ErrNotFound)
+
+	now := time.Now().UTC()
+	c1 := &provisioner.Credential{ID: "b", Provisioner: "oidc", Type: provisioner.CredentialOIDCClientSecret, Secret: "first", CreatedAt: now}
+	c2 := &provisioner.Credential{ID: "a", Provisioner: "oidc", Type: provisioner.CredentialOIDCClientSecret, Secret: "second", CreatedAt: now.Add(time.Second)}
+	require.NoError(t, s.Save(c2))
+	require.NoError(t, s.Save(c1))
+
+	got, err := s.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, "second", got.Secret)
+
+	list, err := s.List()
+	require.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "b", list[0].ID)
+		assert.Equal(t, "a", list[1].ID)
+	}
+
+	require.NoError(t, s.Delete("b"))
+	list, err = s.List()
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+}
@@ -0,0 +1,208 @@
+package authority
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/randutil"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/credential"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// eabCredentialKeySize is the size of the HMAC keys generated for the
+// External Account Binding credentials.
+const eabCredentialKeySize = 32
+
+// initCredentials creates the store of the provisioner credentials. The
+// credentials are kept in memory if the database is not configured. The
+// administration API cannot be enabled with a database that cannot store
+// them, the rotated credentials would be lost on restarts and they would be
+// different on every instance.
+func (a *Authority) initCredentials() (err error) {
+	if a.credentialStore != nil {
+		return nil
+	}
+	ndb, ok := nosqlDB(a.db)
+	switch {
+	case ok:
+		a.credentialStore, err = credential.NewNoSQLStore(ndb)
+		return err
+	case !a.config.AuthorityConfig.EnableAdmin:
+		a.credentialStore = credential.NewMemoryStore()
+	case noDatabase(a.db):
+		a.initLogf("The administration API is enabled without a database, provisioner credentials will be kept in memory")
+		a.credentialStore = credential.NewMemoryStore()
+	default:
+		return errors.New("provisioner credentials are not supported with the configured database")
+	}
+	return nil
+}
+
+// getCredentialsFunc returns the credentials of the provisioner with the
+// given name. It's used in the provisioner configuration.
+func (a *Authority) getCredentialsFunc(name string) ([]*provisioner.Credential, error) {
+	if a.credentialStore == nil {
+		return nil, nil
+	}
+	creds, err := a.credentialStore.List()
+	if err != nil {
+		return nil, err
+	}
+	var list []*provisioner.Credential
+	for _, c := range creds {
+		if c.Provisioner == name {
+			list = append(list, c)
+		}
+	}
+	return list, nil
+}
+
+// credentialType returns the type of the credentials supported by the given
+// provisioner.
+func credentialType(p provisioner.Interface) (provisioner.CredentialType, bool) {
+	switch p.(type) {
+	case *provisioner.JWK:
+		return provisioner.CredentialJWK, true
+	case *provisioner.OIDC:
+		return provisioner.CredentialOIDCClientSecret, true
+	case *provisioner.ACME:
+		return provisioner.CredentialEABKey, true
+	default:
+		return "", false
+	}
+}
+
+func (a *Authority) getProvisionerCredential(name, id string) (*provisioner.Credential, error) {
+	c, err := a.credentialStore.Get(id)
+	switch {
+	case errors.Is(err, credential.ErrNotFound):
+		return nil, admin.NewError(admin.ErrorNotFoundType, "credential %s not found", id)
+	case err != nil:
+		return nil, admin.WrapErrorISE(err, "error loading provisioner credential")
+	case c.Provisioner != name:
+		return nil, admin.NewError(admin.ErrorNotFoundType, "credential %s not found", id)
+	}
+	return c, nil
+}
+
+// GetProvisionerCredentials returns the credentials of a provisioner without
+// their secrets. Only super admins can list the credentials.
+func (a *Authority) GetProvisionerCredentials(_ context.Context, adm *linkedca.Admin, name string) ([]*provisioner.Credential, error) {
+	if adm.GetType() != linkedca.Admin_SUPER_ADMIN {
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to list provisioner credentials")
+	}
+	if _, err := a.LoadProvisionerByName(name); err != nil {
+		return nil, err
+	}
+	creds, err := a.getCredentialsFunc(name)
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error listing provisioner credentials")
+	}
+	list := make([]*provisioner.Credential, len(creds))
+	for i, c := range creds {
+		list[i] = c.Public()
+	}
+	return list, nil
+}
+
+// CreateProvisionerCredential adds a credential to a provisioner. The type of
+// the credential must be supported by the provisioner, and an HMAC key is
+// generated for the External Account Binding credentials without one. It
+// returns the credential with its secrets, they cannot be recovered. Only
+// super admins can create credentials.
+func (a *Authority) CreateProvisionerCredential(_ context.Context, adm *linkedca.Admin, name string, c *provisioner.Credential) (*provisioner.Credential, error) {
+	if adm.GetType() != linkedca.Admin_SUPER_ADMIN {
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to create provisioner credentials")
+	}
+	p, err := a.LoadProvisionerByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if typ, ok := credentialType(p); !ok || typ != c.Type {
+		return nil, admin.NewError(admin.ErrorBadRequestType, "provisioner %s does not support %q credentials", name, c.Type)
+	}
+	if c.Type == provisioner.CredentialEABKey && len(c.HmacKey) == 0 {
+		if c.HmacKey, err = randutil.Bytes(eabCredentialKeySize); err != nil {
+			return nil, admin.WrapErrorISE(err, "error generating HMAC key")
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, admin.WrapError(admin.ErrorBadRequestType, err, "invalid provisioner credential")
+	}
+
+	a.credentialMutex.Lock()
+	defer a.credentialMutex.Unlock()
+
+	// Two keys of a JWK provisioner cannot have the same key id, the key id
+	// is used to select the key that verifies a token.
+	if c.Type == provisioner.CredentialJWK {
+		creds, err := a.getCredentialsFunc(name)
+		if err != nil {
+			return nil, admin.WrapErrorISE(err, "error listing provisioner credentials")
+		}
+		for _, cc := range creds {
+			if cc.Key != nil && cc.Key.KeyID == c.Key.KeyID {
+				return nil, admin.NewError(admin.ErrorConflictType, "provisioner %s already has a credential with key id %s", name, c.Key.KeyID)
+			}
+		}
+	}
+
+	if c.ID, err = randutil.UUIDv4(); err != nil {
+		return nil, admin.WrapErrorISE(err, "error generating credential id")
+	}
+	c.Provisioner = name
+	c.CreatedBy = adm.GetId()
+	c.CreatedAt = time.Now().UTC()
+	if err := a.credentialStore.Save(c); err != nil {
+		return nil, admin.WrapErrorISE(err, "error storing provisioner credential")
+	}
+	return c, nil
+}
+
+// UpdateProvisionerCredential updates the validity of a credential, this is
+// used to set the end of the old credential once the new one is in use. Only
+// super admins can update credentials.
+func (a *Authority) UpdateProvisionerCredential(_ context.Context, adm *linkedca.Admin, name, id string, notBefore, notAfter time.Time) (*provisioner.Credential, error) {
+	if adm.GetType() != linkedca.Admin_SUPER_ADMIN {
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to update provisioner credentials")
+	}
+
+	a.credentialMutex.Lock()
+	defer a.credentialMutex.Unlock()
+
+	c, err := a.getProvisionerCredential(name, id)
+	if err != nil {
+		return nil, err
+	}
+	c.NotBefore, c.NotAfter = notBefore, notAfter
+	if err := c.Validate(); err != nil {
+		return nil, admin.WrapError(admin.ErrorBadRequestType, err, "invalid provisioner credential")
+	}
+	if err := a.credentialStore.Save(c); err != nil {
+		return nil, admin.WrapErrorISE(err, "error storing provisioner credential")
+	}
+	return c.Public(), nil
+}
+
+// DeleteProvisionerCredential deletes a credential of a provisioner. Only
+// super admins can delete credentials.
+func (a *Authority) DeleteProvisionerCredential(_ context.Context, adm *linkedca.Admin, name, id string) error {
+	if adm.GetType() != linkedca.Admin_SUPER_ADMIN {
+		return admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to delete provisioner credentials")
+	}
+
+	a.credentialMutex.Lock()
+	defer a.credentialMutex.Unlock()
+
+	if _, err := a.getProvisionerCredential(name, id); err != nil {
+		return err
+	}
+	if err := a.credentialStore.Delete(id); err != nil {
+		return admin.WrapErrorISE(err, "error deleting provisioner credential")
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package authority
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/credential"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+)
+
+func TestAuthority_ProvisionerCredentials(t *testing.T) {
+	key, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "old", 0)
+	assert.FatalError(t, err)
+	newKey, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "new", 0)
+	assert.FatalError(t, err)
+	pub, newPub := key.Public(), newKey.Public()
+
+	col := provisioner.NewCollection(provisioner.Audiences{})
+	assert.FatalError(t, col.Store(&provisioner.JWK{Name: "jwk", Type: "JWK", Key: &pub}))
+	assert.FatalError(t, col.Store(&provisioner.ACME{Name: "acme", Type: "ACME"}))
+	a := &Authority{provisioners: col, credentialStore: credential.NewMemoryStore()}
+
+	ctx := context.Background()
+	superAdmin := &linkedca.Admin{Id: "admin-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+	regularAdmin := &linkedca.Admin{Id: "other-id", Subject: "bob", Type: linkedca.Admin_ADMIN}
+
+	assertAdminError := func(t *testing.T, err error, typ admin.ProblemType) {
+		t.Helper()
+		var adminErr *admin.Error
+		if assert.True(t, errors.As(err, &adminErr), "error is not an admin error") {
+			assert.Equals(t, typ.String(), adminErr.Type)
+		}
+	}
+
+	// Only super admins can manage credentials, and the type must be the one
+	// supported by the provisioner.
+	_, err = a.CreateProvisionerCredential(ctx, regularAdmin, "jwk", &provisioner.Credential{Type: provisioner.CredentialJWK, Key: &newPub})
+	assertAdminError(t, err, admin.ErrorUnauthorizedType)
+	_, err = a.CreateProvisionerCredential(ctx, superAdmin, "missing", &provisioner.Credential{Type: provisioner.CredentialJWK, Key: &newPub})
+	assertAdminError(t, err, admin.ErrorNotFoundType)
+	_, err = a.CreateProvisionerCredential(ctx, superAdmin, "jwk", &provisioner.Credential{Type: provisioner.CredentialEABKey, KeyID: "kid"})
+	assertAdminError(t, err, admin.ErrorBadRequestType)
+	_, err = a.CreateProvisionerCredential(ctx, superAdmin, "jwk", &provisioner.Credential{Type: provisioner.CredentialJWK, Key: newKey})
+	assertAdminError(t, err, admin.ErrorBadRequestType)
+
+	c, err := a.CreateProvisionerCredential(ctx, superAdmin, "jwk", &provisioner.Credential{Type: provisioner.CredentialJWK, Key: &newPub})
+	assert.FatalError(t, err)
+	assert.NotEquals(t, "", c.ID)
+	assert.Equals(t, "jwk", c.Provisioner)
+	assert.Equals(t, "admin-id", c.CreatedBy)
+	_, err = a.CreateProvisionerCredential(ctx, superAdmin, "jwk", &provisioner.Credential{Type: provisioner.CredentialJWK, Key: &newPub})
+	assertAdminError(t, err, admin.ErrorConflictType)
+
+	// The HMAC keys of the External Account Binding credentials are
+	// generated, and only returned on creation.
+	eab, err := a.CreateProvisionerCredential(ctx, superAdmin, "acme", &provisioner.Credential{Type: provisioner.CredentialEABKey, KeyID: "kid"})
+	assert.FatalError(t, err)
+	assert.Len(t, 32, eab.HmacKey)
+	list, err := a.GetProvisionerCredentials(ctx, superAdmin, "acme")
+	assert.FatalError(t, err)
+	assert.Len(t, 1, list)
+	assert.Nil(t, list[0].HmacKey)
+	_, err = a.GetProvisionerCredentials(ctx, regularAdmin, "acme")
+	assertAdminError(t, err, admin.ErrorUnauthorizedType)
+
+	// The provisioners only get their own credentials.
+	creds, err := a.getCredentialsFunc("jwk")
+	assert.FatalError(t, err)
+	assert.Len(t, 1, creds)
+	assert.Equals(t, c.ID, creds[0].ID)
+
+	// Retire the credential.
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+	u, err := a.UpdateProvisionerCredential(ctx, superAdmin, "jwk", c.ID, time.Time{}, notAfter)
+	assert.FatalError(t, err)
+	assert.Equals(t, notAfter, u.NotAfter)
+	_, err = a.UpdateProvisionerCredential(ctx, superAdmin, "jwk", c.ID, notAfter, notAfter)
+	assertAdminError(t, err, admin.ErrorBadRequestType)
+	_, err = a.UpdateProvisionerCredential(ctx, superAdmin, "acme", c.ID, time.Time{}, notAfter)
+	assertAdminError(t, err, admin.ErrorNotFoundType)
+	_, err = a.UpdateProvisionerCredential(ctx, regularAdmin, "jwk", c.ID, time.Time{}, notAfter)
+	assertAdminError(t, err, admin.ErrorUnauthorizedType)
+
+	assertAdminError(t, a.DeleteProvisionerCredential(ctx, regularAdmin, "jwk", c.ID), admin.ErrorUnauthorizedType)
+	assert.FatalError(t, a.DeleteProvisionerCredential(ctx, superAdmin, "jwk", c.ID))
+	assertAdminError(t, a.DeleteProvisionerCredential(ctx, superAdmin, "jwk", c.ID), admin.ErrorNotFoundType)
+	creds, err = a.getCredentialsFunc("jwk")
+	assert.FatalError(t, err)
+	assert.Len(t, 0, creds)
+}
+
+func TestAuthority_initCredentials(t *testing.T) {
+	newAuthority := func(enableAdmin bool, d db.AuthDB) *Authority {
+		return &Authority{
+			config:    &config.Config{AuthorityConfig: &config.AuthConfig{EnableAdmin: enableAdmin}},
+			db:        d,
+			quietInit: true,
+		}
+	}
+
+	a := newAuthority(true, &db.SimpleDB{})
+	assert.FatalError(t, a.initCredentials())
+	assert.Type(t, &credential.MemoryStore{}, a.credentialStore)
+
+	a = newAuthority(false, &db.MockAuthDB{})
+	assert.FatalError(t, a.initCredentials())
+	assert.Type(t, &credential.MemoryStore{}, a.credentialStore)
+
+	// The credentials cannot be kept in memory if a database is configured.
+	a = newAuthority(true, &db.MockAuthDB{})
+	err := a.initCredentials()
+	if assert.NotNil(t, err) {
+		assert.Equals(t, "provisioner credentials are not supported with the configured database", err.Error())
+	}
+	assert.Nil(t, a.credentialStore)
+}
@@ -0,0 +1,380 @@
+package authority
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/nosql/database"
+	"go.step.sm/crypto/pemutil"
+	"go.step.sm/linkedca"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/audit"
+	"github.com/smallstep/certificates/authority/decommission"
+	"github.com/smallstep/certificates/authority/report"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/errs"
+)
+
+// DecommissionOptions are the options used to decommission an authority.
+type DecommissionOptions struct {
+	// Reason is a free-form description of the decommission.
+	Reason string
+	// CRLNextUpdate is the nextUpdate of the final CRL. It defaults to the
+	// expiration of the intermediate certificate.
+	CRLNextUpdate time.Time
+	// Statement enables the signed decommission statement.
+	Statement bool
+}
+
+// initDecommission creates the store of the decommission status and loads
+// it. The status is kept in memory if the database is not configured.
+func (a *Authority) initDecommission() (err error) {
+	if a.decommissionStore == nil {
+		if ndb, ok := nosqlDB(a.db); ok {
+			if a.decommissionStore, err = decommission.NewNoSQLStore(ndb); err != nil {
+				return err
+			}
+		} else {
+			a.decommissionStore = decommission.NewMemoryStore()
+		}
+	}
+	st, err := a.decommissionStore.Get()
+	switch {
+	case errors.Is(err, decommission.ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	}
+	a.setDecommissionStatus(st)
+	a.initLogf("The authority was decommissioned on %s, certificate issuance is disabled", st.DecommissionedAt.Format(time.RFC3339))
+	return nil
+}
+
+func (a *Authority) setDecommissionStatus(st *decommission.Status) {
+	a.decommissionStatusMutex.Lock()
+	a.decommissionStatus = st
+	a.decommissionStatusMutex.Unlock()
+}
+
+// getDecommissionStatus returns the decommission status, or nil if the
+// authority has not been decommissioned. The store is checked if the status
+// is not loaded, so all the instances sharing a database stop issuing
+// certificates.
+func (a *Authority) getDecommissionStatus() *decommission.Status {
+	a.decommissionStatusMutex.RLock()
+	st := a.decommissionStatus
+	a.decommissionStatusMutex.RUnlock()
+	if st != nil || a.decommissionStore == nil {
+		return st
+	}
+	if st, err := a.decommissionStore.Get(); err == nil {
+		a.setDecommissionStatus(st)
+		return st
+	}
+	return nil
+}
+
+// checkDecommissioned returns a forbidden error if the authority has been
+// decommissioned.
+func (a *Authority) checkDecommissioned() error {
+	if st := a.getDecommissionStatus(); st != nil {
+		return errs.Forbidden("the certificate authority was decommissioned on %s",
+			st.DecommissionedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// GetDecommissionStatus returns the decommission status of the authority.
+func (a *Authority) GetDecommissionStatus() (*decommission.Status, error) {
+	if st := a.getDecommissionStatus(); st != nil {
+		return st, nil
+	}
+	return nil, admin.NewError(admin.ErrorNotFoundType, "authority has not been decommissioned")
+}
+
+// GetDecommissionStatement returns the signed decommission statement.
+func (a *Authority) GetDecommissionStatement() (string, error) {
+	if st := a.getDecommissionStatus(); st != nil && st.Statement != "" {
+		return st.Statement, nil
+	}
+	return "", errs.NotFound("decommission statement not found")
+}
+
+// Decommission decommissions the authority. It stops the issuance of
+// certificates, generates a final CRL valid until the given time, appends a
+// record to the audit log and, if requested, signs a statement with the
+// details of the decommission. The statement is signed by a certificate
+// issued right before stopping the issuance, valid as long as the final CRL.
+//
+// The status is persisted, so the authority stays decommissioned after a
+// restart. Only super admins can decommission an authority, and it cannot be
+// undone using the administration API.
+func (a *Authority) Decommission(ctx context.Context, adm *linkedca.Admin, opts DecommissionOptions) (*decommission.Status, error) {
+	if adm.GetType() != linkedca.Admin_SUPER_ADMIN {
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to decommission the authority")
+	}
+
+	a.decommissionMutex.Lock()
+	defer a.decommissionMutex.Unlock()
+
+	if st := a.getDecommissionStatus(); st != nil {
+		return nil, admin.NewError(admin.ErrorConflictType, "authority was decommissioned on %s", st.DecommissionedAt.Format(time.RFC3339))
+	}
+	issuer, err := a.GetIssuerCertificate()
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error decommissioning authority")
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	nextUpdate := opts.CRLNextUpdate
+	if nextUpdate.IsZero() {
+		nextUpdate = issuer.NotAfter
+	}
+	if !nextUpdate.After(now) {
+		return nil, admin.NewError(admin.ErrorBadRequestType, "crl nextUpdate must be in the future")
+	}
+
+	// The signer of the statement is the last certificate issued.
+	var signer *delegatedSigner
+	if opts.Statement {
+		if signer, err = a.createDelegatedSigner(&x509.Certificate{
+			Subject:               pkix.Name{CommonName: issuer.Subject.CommonName + " Decommission Statement"},
+			KeyUsage:              x509.KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+		}, nextUpdate); err != nil {
+			return nil, admin.WrapErrorISE(err, "error creating decommission statement signer")
+		}
+	}
+
+	// Stop the issuance.
+	st := &decommission.Status{
+		DecommissionedAt: now,
+		Admin:            adm.GetSubject(),
+		Reason:           opts.Reason,
+		CRLNextUpdate:    nextUpdate,
+	}
+	a.setDecommissionStatus(st)
+
+	if a.config.CRL.IsEnabled() {
+		if err := a.GenerateCertificateRevocationList(); err != nil {
+			a.setDecommissionStatus(nil)
+			return nil, admin.WrapErrorISE(err, "error generating final crl")
+		}
+		if crlDB, ok := a.db.(db.CertificateRevocationListDB); ok {
+			if crlInfo, err := crlDB.GetCRL(); err == nil {
+				st.CRLNumber = crlInfo.Number
+			}
+		}
+	}
+
+	statement, err := a.newDecommissionStatement(st, issuer)
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error creating decommission statement")
+	}
+	if a.auditLog != nil {
+		r, err := a.auditLog.Append(audit.DecommissionType, statement)
+		if err != nil {
+			return nil, admin.WrapErrorISE(err, "error writing audit log")
+		}
+		if _, err := a.auditLog.Checkpoint(); err != nil {
+			return nil, admin.WrapErrorISE(err, "error signing audit log checkpoint")
+		}
+		statement.AuditLog = &decommission.AuditLog{Index: r.Index, Hash: r.Hash}
+	}
+	if signer != nil {
+		chain := append([]*x509.Certificate{signer.Certificate}, a.intermediateX509Certs...)
+		if st.Statement, err = decommission.SignStatement(statement, chain, signer.Signer); err != nil {
+			return nil, admin.WrapErrorISE(err, "error signing decommission statement")
+		}
+	}
+
+	if err := a.decommissionStore.Save(st); err != nil {
+		return nil, admin.WrapErrorISE(err, "error storing decommission status")
+	}
+	return st, nil
+}
+
+// newDecommissionStatement returns the statement of the given decommission,
+// without the audit log.
+func (a *Authority) newDecommissionStatement(st *decommission.Status, issuer *x509.Certificate) (*decommission.Statement, error) {
+	statement := &decommission.Statement{
+		Authority:        a.config.AuthorityConfig.AuthorityID,
+		DecommissionedAt: st.DecommissionedAt,
+		Reason:           st.Reason,
+		Roots:            []string{},
+		Intermediates:    []string{},
+		Keys:             []string{},
+	}
+	if statement.Authority == "" && len(a.config.DNSNames) > 0 {
+		statement.Authority = a.config.DNSNames[0]
+	}
+	for _, crt := range a.rootX509Certs {
+		statement.Roots = append(statement.Roots, decommission.Fingerprint(crt))
+	}
+	for _, crt := range a.intermediateX509Certs {
+		statement.Intermediates = append(statement.Intermediates, decommission.Fingerprint(crt))
+	}
+
+	// The keys to destroy are the intermediate and the SSH keys.
+	keys := []crypto.PublicKey{issuer.PublicKey}
+	for _, k := range []ssh.Signer{a.sshCAUserCertSignKey, a.sshCAHostCertSignKey} {
+		if k == nil {
+			continue
+		}
+		if pub, ok := k.PublicKey().(ssh.CryptoPublicKey); ok {
+			keys = append(keys, pub.CryptoPublicKey())
+		}
+	}
+	for _, pub := range keys {
+		fp, err := decommission.KeyFingerprint(pub)
+		if err != nil {
+			return nil, err
+		}
+		statement.Keys = append(statement.Keys, fp)
+	}
+
+	if a.config.CRL.IsEnabled() {
+		statement.CRL = &decommission.StatementCRL{
+			URL:        a.config.Audience("/1.0/crl")[0],
+			Number:     st.CRLNumber,
+			NextUpdate: st.CRLNextUpdate,
+		}
+		if a.config.CRL.IDPurl != "" {
+			statement.CRL.URL = a.config.CRL.IDPurl
+		}
+	}
+
+	if _, ok := a.db.(db.CertificateLister); ok {
+		certs, err := a.ListCertificates()
+		if err != nil {
+			return nil, err
+		}
+		inv := &decommission.Inventory{Total: len(certs)}
+		for _, c := range certs {
+			switch c.Status {
+			case report.StatusRevoked:
+				inv.Revoked++
+			case report.StatusExpired:
+				inv.Expired++
+			default:
+				inv.Active++
+			}
+		}
+		statement.Inventory = inv
+	}
+	return statement, nil
+}
+
+// decommissionCRLNextUpdate returns the nextUpdate of the final CRL if the
+// authority has been decommissioned.
+func (a *Authority) decommissionCRLNextUpdate(now time.Time) (time.Time, bool) {
+	if st := a.getDecommissionStatus(); st != nil && st.CRLNextUpdate.After(now) {
+		return st.CRLNextUpdate, true
+	}
+	return time.Time{}, false
+}
+
+// WriteDecommissionArchive writes a gzipped tar archive with the records of
+// the authority: the roots and intermediates, the inventory of certificates,
+// the current CRL, the audit log, and the decommission status and statement.
+// The parts that are not available are not included. The archive can be
+// created before the decommission.
+func (a *Authority) WriteDecommissionArchive(w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	now := time.Now()
+	add := func(name string, b []byte) error {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(b)),
+			ModTime: now,
+		}); err != nil {
+			return err
+		}
+		_, err := tw.Write(b)
+		return err
+	}
+	addJSON := func(name string, v any) error {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		return add(name, b)
+	}
+	addPEM := func(name string, certs []*x509.Certificate) error {
+		var b []byte
+		for _, crt := range certs {
+			b = append(b, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})...)
+		}
+		return add(name, b)
+	}
+
+	if err := addPEM("roots.pem", a.rootX509Certs); err != nil {
+		return errors.Wrap(err, "error writing roots")
+	}
+	if err := addPEM("intermediates.pem", a.intermediateX509Certs); err != nil {
+		return errors.Wrap(err, "error writing intermediates")
+	}
+	if st := a.getDecommissionStatus(); st != nil {
+		if err := addJSON("status.json", st); err != nil {
+			return errors.Wrap(err, "error writing decommission status")
+		}
+		if st.Statement != "" {
+			if err := add("statement.jwt", []byte(st.Statement)); err != nil {
+				return errors.Wrap(err, "error writing decommission statement")
+			}
+		}
+	}
+	if _, ok := a.db.(db.CertificateLister); ok {
+		certs, err := a.ListCertificates()
+		if err != nil {
+			return err
+		}
+		if err := addJSON("inventory.json", certs); err != nil {
+			return errors.Wrap(err, "error writing inventory")
+		}
+	}
+	if crlDB, ok := a.db.(db.CertificateRevocationListDB); ok && a.config.CRL.IsEnabled() {
+		crlInfo, err := crlDB.GetCRL()
+		switch {
+		case err == nil:
+			if err := add("crl.der", crlInfo.DER); err != nil {
+				return errors.Wrap(err, "error writing crl")
+			}
+		case !database.IsErrNotFound(err):
+			return errors.Wrap(err, "error loading crl")
+		}
+	}
+	if a.auditLog != nil {
+		e, err := a.auditLog.Export(0)
+		if err != nil {
+			return errors.Wrap(err, "error exporting audit log")
+		}
+		if err := addJSON("audit.json", e); err != nil {
+			return errors.Wrap(err, "error writing audit log")
+		}
+		block, err := pemutil.Serialize(a.auditLog.PublicKey())
+		if err != nil {
+			return errors.Wrap(err, "error serializing audit public key")
+		}
+		if err := add("audit.pub", pem.EncodeToMemory(block)); err != nil {
+			return errors.Wrap(err, "error writing audit public key")
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "error writing archive")
+	}
+	return errors.Wrap(gw.Close(), "error writing archive")
+}
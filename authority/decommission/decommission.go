@@ -0,0 +1,242 @@
+// Package decommission implements the state and the signed statement of a
+// decommissioned authority. Once an authority is decommissioned it stops
+// issuing certificates, but it keeps serving the final CRL and, optionally, a
+// statement signed by a certificate of the authority with the details of the
+// decommission, so relying parties and auditors can check that the
+// certificate authority has been retired.
+package decommission
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/nosql"
+	"github.com/smallstep/nosql/database"
+	"go.step.sm/crypto/jose"
+)
+
+var (
+	statusTable = []byte("authority_decommission")
+	statusKey   = []byte("status")
+)
+
+// ErrNotFound is the error returned by the stores if the authority has not
+// been decommissioned.
+var ErrNotFound = errors.New("authority has not been decommissioned")
+
+// StatementType is the type header of the signed decommission statements.
+const StatementType = "decommission-statement+jwt"
+
+// Status is the state of a decommissioned authority.
+type Status struct {
+	DecommissionedAt time.Time `json:"decommissionedAt"`
+	Admin            string    `json:"admin,omitempty"`
+	Reason           string    `json:"reason,omitempty"`
+	CRLNumber        int64     `json:"crlNumber,omitempty"`
+	CRLNextUpdate    time.Time `json:"crlNextUpdate"`
+	Statement        string    `json:"statement,omitempty"`
+}
+
+// Statement is the payload of the signed decommission statement.
+type Statement struct {
+	Authority        string        `json:"authority"`
+	DecommissionedAt time.Time     `json:"decommissionedAt"`
+	Reason           string        `json:"reason,omitempty"`
+	Roots            []string      `json:"roots"`
+	Intermediates    []string      `json:"intermediates"`
+	Keys             []string      `json:"keys"`
+	CRL              *StatementCRL `json:"crl,omitempty"`
+	Inventory        *Inventory    `json:"inventory,omitempty"`
+	AuditLog         *AuditLog     `json:"auditLog,omitempty"`
+}
+
+// StatementCRL describes the final CRL of the authority.
+type StatementCRL struct {
+	URL        string    `json:"url,omitempty"`
+	Number     int64     `json:"number"`
+	NextUpdate time.Time `json:"nextUpdate"`
+}
+
+// Inventory counts the certificates issued by the authority by their status
+// at the time of the decommission.
+type Inventory struct {
+	Total   int `json:"total"`
+	Active  int `json:"active"`
+	Expired int `json:"expired"`
+	Revoked int `json:"revoked"`
+}
+
+// AuditLog identifies the last record of the audit log at the time of the
+// decommission.
+type AuditLog struct {
+	Index uint64 `json:"index"`
+	Hash  []byte `json:"hash"`
+}
+
+// Fingerprint returns the hex encoded SHA-256 fingerprint of a certificate.
+func Fingerprint(crt *x509.Certificate) string {
+	sum := sha256.Sum256(crt.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyFingerprint returns the hex encoded SHA-256 fingerprint of the DER
+// encoded public key.
+func KeyFingerprint(pub crypto.PublicKey) (string, error) {
+	b, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", errors.Wrap(err, "error marshaling public key")
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// SignStatement signs the statement with the given key and returns the JWS in
+// compact serialization. The certificate chain of the key is added in the x5c
+// header.
+func SignStatement(st *Statement, chain []*x509.Certificate, signer crypto.Signer) (string, error) {
+	x5c, err := jose.ValidateX5C(chain, signer)
+	if err != nil {
+		return "", errors.Wrap(err, "error validating statement signer")
+	}
+	so := new(jose.SignerOptions)
+	so.WithType(StatementType)
+	so.WithHeader("x5c", x5c)
+	s, err := jose.NewSigner(jose.SigningKey{Key: signer}, so)
+	if err != nil {
+		return "", errors.Wrap(err, "error creating statement signer")
+	}
+	payload, err := json.Marshal(st)
+	if err != nil {
+		return "", errors.Wrap(err, "error marshaling statement")
+	}
+	jws, err := s.Sign(payload)
+	if err != nil {
+		return "", errors.Wrap(err, "error signing statement")
+	}
+	return jws.CompactSerialize()
+}
+
+// VerifyStatement verifies a signed statement, its certificate chain must
+// lead to one of the given roots. It returns the statement and the
+// certificate of the signer.
+func VerifyStatement(jws string, roots *x509.CertPool) (*Statement, *x509.Certificate, error) {
+	sig, err := jose.ParseJWS(jws)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error parsing statement")
+	}
+	if len(sig.Signatures) != 1 {
+		return nil, nil, errors.New("error parsing statement: statement must have one signature")
+	}
+	chains, err := sig.Signatures[0].Protected.Certificates(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error verifying statement certificate")
+	}
+	leaf := chains[0][0]
+	payload, err := sig.Verify(leaf.PublicKey)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error verifying statement")
+	}
+	st := new(Statement)
+	if err := json.Unmarshal(payload, st); err != nil {
+		return nil, nil, errors.Wrap(err, "error unmarshaling statement")
+	}
+	return st, leaf, nil
+}
+
+// Store is the interface used to persist the decommission status.
+type Store interface {
+	// Get returns the status, or ErrNotFound if the authority has not been
+	// decommissioned.
+	Get() (*Status, error)
+	// Save stores the status.
+	Save(s *Status) error
+}
+
+// MemoryStore is a Store that keeps the status in memory. It is used when
+// the authority does not have a database.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	status []byte
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Get implements the Store interface.
+func (s *MemoryStore) Get() (*Status, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.status == nil {
+		return nil, ErrNotFound
+	}
+	return unmarshalStatus(s.status)
+}
+
+// Save implements the Store interface.
+func (s *MemoryStore) Save(st *Status) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling decommission status")
+	}
+	s.mu.Lock()
+	s.status = b
+	s.mu.Unlock()
+	return nil
+}
+
+// NoSQLStore is a Store that persists the status in the authority database.
+type NoSQLStore struct {
+	db nosql.DB
+}
+
+// NewNoSQLStore creates the decommission table in the given database and
+// returns a new store.
+func NewNoSQLStore(db nosql.DB) (*NoSQLStore, error) {
+	if err := db.CreateTable(statusTable); err != nil {
+		return nil, errors.Wrapf(err, "error creating table %s", statusTable)
+	}
+	return &NoSQLStore{db: db}, nil
+}
+
+// Get implements the Store interface.
+func (s *NoSQLStore) Get() (*Status, error) {
+	b, err := s.db.Get(statusTable, statusKey)
+	switch {
+	case database.IsErrNotFound(err):
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, errors.Wrap(err, "error loading decommission status")
+	}
+	return unmarshalStatus(b)
+}
+
+// Save implements the Store interface.
+func (s *NoSQLStore) Save(st *Status) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling decommission status")
+	}
+	if err := s.db.Set(statusTable, statusKey, b); err != nil {
+		return errors.Wrap(err, "error storing decommission status")
+	}
+	return nil
+}
+
+func unmarshalStatus(b []byte) (*Status, error) {
+	st := new(Status)
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling decommission status")
+	}
+	return st, nil
+}
@@ -0,0 +1,104 @@
+package decommission
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smallstep/nosql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+)
+
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	_, err := s.Get()
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	st := &Status{
+		DecommissionedAt: now,
+		Admin:            "admin@example.com",
+		Reason:           "replaced by a new authority",
+		CRLNextUpdate:    now.Add(24 * time.Hour),
+	}
+	require.NoError(t, s.Save(st))
+	got, err := s.Get()
+	require.NoError(t, err)
+	assert.Equal(t, st, got)
+
+	st.CRLNumber = 10
+	st.Statement = "statement"
+	require.NoError(t, s.Save(st))
+	got, err = s.Get()
+	require.NoError(t, err)
+	assert.Equal(t, st, got)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestNoSQLStore(t *testing.T) {
+	db, err := nosql.New(nosql.BBoltDriver, filepath.Join(t.TempDir(), "decommission.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	s, err := NewNoSQLStore(db)
+	require.NoError(t, err)
+	testStore(t, s)
+}
+
+func TestSignStatement(t *testing.T) {
+	ca, err := minica.New()
+	require.NoError(t, err)
+	other, err := minica.New()
+	require.NoError(t, err)
+	signer, err := keyutil.GenerateDefaultSigner()
+	require.NoError(t, err)
+	crt, err := ca.Sign(&x509.Certificate{
+		Subject:   pkix.Name{CommonName: "Decommission Statement"},
+		PublicKey: signer.Public(),
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+		NotBefore: time.Now().Add(-time.Minute),
+		NotAfter:  time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	kid, err := KeyFingerprint(ca.Signer.Public())
+	require.NoError(t, err)
+	st := &Statement{
+		Authority:        "ca.example.com",
+		DecommissionedAt: time.Now().UTC().Truncate(time.Second),
+		Roots:            []string{Fingerprint(ca.Root)},
+		Intermediates:    []string{Fingerprint(ca.Intermediate)},
+		Keys:             []string{kid},
+		CRL:              &StatementCRL{Number: 3, NextUpdate: time.Now().UTC().Truncate(time.Second)},
+		Inventory:        &Inventory{Total: 3, Active: 1, Expired: 1, Revoked: 1},
+	}
+	jws, err := SignStatement(st, []*x509.Certificate{crt, ca.Intermediate}, signer)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Root)
+	got, leaf, err := VerifyStatement(jws, roots)
+	require.NoError(t, err)
+	assert.Equal(t, st, got)
+	assert.Equal(t, crt.Raw, leaf.Raw)
+
+	// The chain must lead to the given roots.
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(other.Root)
+	_, _, err = VerifyStatement(jws, otherRoots)
+	assert.Error(t, err)
+
+	// The key must match the certificate.
+	_, err = SignStatement(st, []*x509.Certificate{ca.Intermediate}, signer)
+	assert.Error(t, err)
+
+	_, _, err = VerifyStatement("foo", roots)
+	assert.Error(t, err)
+}
@@ -0,0 +1,137 @@
+package authority
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/x509"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/nosql/database"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/decommission"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+)
+
+func TestAuthority_Decommission(t *testing.T) {
+	var crlStore *db.CertificateRevocationListInfo
+	a := testAuthority(t, WithDatabase(&db.MockAuthDB{
+		MStoreCertificate: func(crt *x509.Certificate) error {
+			return nil
+		},
+		MUseToken: func(id, tok string) (bool, error) {
+			return true, nil
+		},
+		MStoreCRL: func(i *db.CertificateRevocationListInfo) error {
+			crlStore = i
+			return nil
+		},
+		MGetCRL: func() (*db.CertificateRevocationListInfo, error) {
+			if crlStore == nil {
+				return nil, database.ErrNotFound
+			}
+			return crlStore, nil
+		},
+		MGetRevokedCertificates: func() (*[]db.RevokedCertificateInfo, error) {
+			return &[]db.RevokedCertificateInfo{}, nil
+		},
+		MGetCertificates: func() ([]*x509.Certificate, error) {
+			return []*x509.Certificate{}, nil
+		},
+	}))
+	a.config.CRL = &config.CRLConfig{Enabled: true}
+
+	_, err := a.GetDecommissionStatus()
+	assert.Error(t, err)
+	_, err = a.GetDecommissionStatement()
+	assert.Error(t, err)
+	assert.NoError(t, a.checkDecommissioned())
+
+	ctx := context.Background()
+	nextUpdate := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	opts := DecommissionOptions{Reason: "retired", CRLNextUpdate: nextUpdate, Statement: true}
+
+	// Only super admins can decommission an authority.
+	_, err = a.Decommission(ctx, &linkedca.Admin{Subject: "bob", Type: linkedca.Admin_ADMIN}, opts)
+	assert.Error(t, err)
+	_, err = a.Decommission(ctx, &linkedca.Admin{Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}, DecommissionOptions{
+		CRLNextUpdate: time.Now().Add(-time.Hour),
+	})
+	assert.Error(t, err)
+	assert.NoError(t, a.checkDecommissioned())
+
+	st, err := a.Decommission(ctx, &linkedca.Admin{Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}, opts)
+	assert.FatalError(t, err)
+	assert.Equals(t, "alice", st.Admin)
+	assert.Equals(t, "retired", st.Reason)
+	assert.Equals(t, nextUpdate, st.CRLNextUpdate)
+
+	// The final CRL is valid until the given time.
+	crl, err := x509.ParseRevocationList(crlStore.DER)
+	assert.FatalError(t, err)
+	assert.Equals(t, nextUpdate, crl.NextUpdate.UTC())
+	assert.Equals(t, crl.Number.Int64(), st.CRLNumber)
+
+	// The statement is signed by a certificate of the authority.
+	roots := x509.NewCertPool()
+	for _, crt := range a.rootX509Certs {
+		roots.AddCert(crt)
+	}
+	jws, err := a.GetDecommissionStatement()
+	assert.FatalError(t, err)
+	statement, signer, err := decommission.VerifyStatement(jws, roots)
+	assert.FatalError(t, err)
+	assert.Equals(t, "retired", statement.Reason)
+	assert.Equals(t, []string{decommission.Fingerprint(a.intermediateX509Certs[0])}, statement.Intermediates)
+	fp, err := decommission.KeyFingerprint(a.intermediateX509Certs[0].PublicKey)
+	assert.FatalError(t, err)
+	assert.Equals(t, fp, statement.Keys[0])
+	if assert.NotNil(t, statement.CRL) {
+		assert.Equals(t, st.CRLNumber, statement.CRL.Number)
+	}
+	assert.False(t, signer.NotAfter.Before(nextUpdate))
+
+	// An authority can only be decommissioned once.
+	_, err = a.Decommission(ctx, &linkedca.Admin{Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}, opts)
+	assert.Error(t, err)
+
+	// Issuance is disabled.
+	assert.Error(t, a.checkDecommissioned())
+	_, priv, err := keyutil.GenerateDefaultKeyPair()
+	assert.FatalError(t, err)
+	key, err := jose.ReadKey("testdata/secrets/step_cli_key_priv.jwk", jose.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+	token, err := generateToken("smallstep test", "step-cli", testAudiences.Sign[0], []string{"test.smallstep.com"}, time.Now(), key)
+	assert.FatalError(t, err)
+	signCtx := provisioner.NewContextWithMethod(ctx, provisioner.SignMethod)
+	extraOpts, err := a.Authorize(signCtx, token)
+	assert.FatalError(t, err)
+	_, err = a.SignWithContext(signCtx, getCSR(t, priv), provisioner.SignOptions{}, extraOpts...)
+	assert.Error(t, err)
+
+	// The archive has the records of the authority.
+	var buf bytes.Buffer
+	assert.FatalError(t, a.WriteDecommissionArchive(&buf))
+	gr, err := gzip.NewReader(&buf)
+	assert.FatalError(t, err)
+	tr := tar.NewReader(gr)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.FatalError(t, err)
+		names = append(names, hdr.Name)
+	}
+	assert.Equals(t, []string{"roots.pem", "intermediates.pem", "status.json", "statement.jwt", "inventory.json", "crl.der"}, names)
+}
@@ -0,0 +1,212 @@
+package authority
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/keyutil"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/smallstep/nosql/database"
+
+	casapi "github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/errs"
+)
+
+// oidOCSPNoCheck is the id-pkix-ocsp-nocheck extension defined in RFC 6960.
+// Relying parties do not check the revocation status of OCSP responders
+// with this extension.
+var oidOCSPNoCheck = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+
+// delegatedSigner is a certificate issued by the intermediate and its key,
+// used to sign OCSP responses or CRLs. The delegated signers are short-lived,
+// the dedicated OCSP responder is loaded from the configuration.
+type delegatedSigner struct {
+	Certificate *x509.Certificate
+	Signer      crypto.Signer
+}
+
+// needsRotation returns true if the signer must be replaced, because two
+// thirds of its validity have passed or because it would expire before the
+// given time.
+func (s *delegatedSigner) needsRotation(now, until time.Time) bool {
+	if s == nil {
+		return true
+	}
+	lifetime := s.Certificate.NotAfter.Sub(s.Certificate.NotBefore)
+	return now.After(s.Certificate.NotBefore.Add(lifetime/3*2)) || until.After(s.Certificate.NotAfter)
+}
+
+// getDelegatedOCSPSigner returns the delegated OCSP responder, issuing a new
+// one if it does not exist or it must be rotated. The responder must be
+// valid until the given time.
+func (a *Authority) getDelegatedOCSPSigner(until time.Time) (*delegatedSigner, error) {
+	a.delegatedMutex.Lock()
+	defer a.delegatedMutex.Unlock()
+
+	if !a.ocspSigner.needsRotation(time.Now(), until) {
+		return a.ocspSigner, nil
+	}
+	issuer, err := a.GetIssuerCertificate()
+	if err != nil {
+		return nil, err
+	}
+	s, err := a.createDelegatedSigner(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: issuer.Subject.CommonName + " OCSP Responder"},
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+		BasicConstraintsValid: true,
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidOCSPNoCheck, Value: asn1.NullBytes},
+		},
+	}, until)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating delegated ocsp responder")
+	}
+	a.ocspSigner = s
+	return s, nil
+}
+
+// getDelegatedCRLSigner returns the delegated CRL signer, issuing a new one if
+// it does not exist or it must be rotated. The signer must be valid until the
+// given time.
+//
+// The certificate has the same subject as the intermediate so the CRLs keep
+// the same issuer, relying parties find it using the authority information
+// access extension of the CRL. It is marked as a CA because some clients,
+// like Go, only accept CRLs signed by CAs, but it cannot sign certificates and
+// its path length is zero.
+func (a *Authority) getDelegatedCRLSigner(until time.Time) (*delegatedSigner, error) {
+	a.delegatedMutex.Lock()
+	defer a.delegatedMutex.Unlock()
+
+	if !a.crlSigner.needsRotation(time.Now(), until) {
+		return a.crlSigner, nil
+	}
+	issuer, err := a.GetIssuerCertificate()
+	if err != nil {
+		return nil, err
+	}
+	s, err := a.createDelegatedSigner(&x509.Certificate{
+		Subject:               issuer.Subject,
+		KeyUsage:              x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}, until)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating delegated crl signer")
+	}
+	if len(s.Certificate.SubjectKeyId) == 0 {
+		return nil, errors.New("error creating delegated crl signer: certificate does not have a subject key identifier")
+	}
+	a.crlSigner = s
+	return s, nil
+}
+
+// createDelegatedSigner issues a new delegated signer. The certificate is
+// valid for the configured time, or until the given time if it is later.
+func (a *Authority) createDelegatedSigner(template *x509.Certificate, until time.Time) (*delegatedSigner, error) {
+	signer, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating key")
+	}
+	template.PublicKey = signer.Public()
+
+	lifetime := a.config.DelegatedSigners.GetValidity()
+	if d := time.Until(until); d > lifetime {
+		lifetime = d + time.Minute
+	}
+	resp, err := a.x509CAService.CreateCertificate(&casapi.CreateCertificateRequest{
+		Template: template,
+		Lifetime: lifetime,
+		Backdate: time.Minute,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := a.db.(db.CertificateStorer); ok {
+		if err := s.StoreCertificate(resp.Certificate); err != nil {
+			return nil, errors.Wrap(err, "error storing certificate")
+		}
+	}
+	return &delegatedSigner{
+		Certificate: resp.Certificate,
+		Signer:      signer,
+	}, nil
+}
+
+// GetCRLSignerCertificate returns the certificate of the delegated signer of
+// the current CRL. This certificate is served at the caIssuers URL of the
+// authority information access extension of the CRLs.
+func (a *Authority) GetCRLSignerCertificate() (*x509.Certificate, error) {
+	crlDB, ok := a.db.(db.CertificateRevocationListDB)
+	if !ok || !a.config.CRL.IsEnabled() {
+		return nil, errs.NotFound("crl signer certificate not found")
+	}
+	crlInfo, err := crlDB.GetCRL()
+	if err != nil {
+		if database.IsErrNotFound(err) {
+			return nil, errs.NotFound("crl signer certificate not found")
+		}
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.GetCRLSignerCertificate")
+	}
+	if len(crlInfo.SignerDER) == 0 {
+		return nil, errs.NotFound("crl signer certificate not found")
+	}
+	crt, err := x509.ParseCertificate(crlInfo.SignerDER)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.GetCRLSignerCertificate")
+	}
+	return crt, nil
+}
+
+// createDelegatedOCSPResponse signs the given OCSP response template with the
+// delegated responder. The responder certificate is included in the response.
+func (a *Authority) createDelegatedOCSPResponse(template ocsp.Response, extensions []pkix.Extension) ([]byte, error) {
+	s, err := a.getDelegatedOCSPSigner(template.NextUpdate)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.CreateOCSPResponse")
+	}
+	return a.createResponderOCSPResponse(s, template, extensions)
+}
+
+// createDelegatedCRL signs the given revocation list with the delegated CRL
+// signer. It returns the CRL and the certificate of the signer.
+func (a *Authority) createDelegatedCRL(rl *x509.RevocationList) ([]byte, *x509.Certificate, error) {
+	s, err := a.getDelegatedCRLSigner(rl.NextUpdate)
+	if err != nil {
+		return nil, nil, err
+	}
+	rl.ExtraExtensions = append(rl.ExtraExtensions, pkix.Extension{
+		Id:    oidExtensionAuthorityInfoAccess,
+		Value: marshalCAIssuers(a.config.Audience("/1.0/crl/signer")[0]),
+	})
+	b, err := x509.CreateRevocationList(rand.Reader, rl, s.Certificate, s.Signer)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, s.Certificate, nil
+}
+
+// marshalCAIssuers returns the value of an authority information access
+// extension with the given caIssuers URL.
+func marshalCAIssuers(uri string) []byte {
+	type accessDescription struct {
+		Method   asn1.ObjectIdentifier
+		Location asn1.RawValue
+	}
+	oidCAIssuers := asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 2}
+	b, _ := asn1.Marshal([]accessDescription{{
+		Method:   oidCAIssuers,
+		Location: asn1.RawValue{Tag: 6, Class: asn1.ClassContextSpecific, Bytes: []byte(uri)},
+	}})
+	return b
+}
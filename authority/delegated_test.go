@@ -0,0 +1,131 @@
+package authority
+
+import (
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/nosql/database"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/db"
+)
+
+func TestDelegatedSigner_needsRotation(t *testing.T) {
+	now := time.Now()
+	s := &delegatedSigner{Certificate: &x509.Certificate{
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(2 * time.Hour),
+	}}
+
+	tests := []struct {
+		name   string
+		signer *delegatedSigner
+		now    time.Time
+		until  time.Time
+		want   bool
+	}{
+		{"nil", nil, now, now, true},
+		{"valid", s, now, now.Add(time.Hour), false},
+		{"two thirds", s, now.Add(time.Hour + time.Second), now.Add(time.Hour), true},
+		{"until after expiration", s, now, now.Add(3 * time.Hour), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equals(t, tt.want, tt.signer.needsRotation(tt.now, tt.until))
+		})
+	}
+}
+
+func TestAuthority_CreateOCSPResponse_delegated(t *testing.T) {
+	var stored []*x509.Certificate
+	a := testAuthority(t, WithDatabase(&db.MockAuthDB{
+		MIsRevoked: func(string) (bool, error) {
+			return false, nil
+		},
+		MStoreCertificate: func(crt *x509.Certificate) error {
+			stored = append(stored, crt)
+			return nil
+		},
+	}))
+	a.config.DelegatedSigners = &config.DelegatedSignersConfig{OCSP: true}
+
+	issuer, err := a.GetIssuerCertificate()
+	assert.FatalError(t, err)
+	leaf := &x509.Certificate{SerialNumber: big.NewInt(1234), RawIssuer: issuer.RawSubject}
+
+	now := time.Now()
+	b, err := a.CreateOCSPResponse(leaf, now, now.Add(time.Hour))
+	assert.FatalError(t, err)
+	resp, err := ocsp.ParseResponseForCert(b, leaf, issuer)
+	assert.FatalError(t, err)
+	assert.Equals(t, ocsp.Good, resp.Status)
+	if assert.NotNil(t, resp.Certificate) {
+		assert.Equals(t, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}, resp.Certificate.ExtKeyUsage)
+		assert.Equals(t, issuer.Subject.CommonName+" OCSP Responder", resp.Certificate.Subject.CommonName)
+	}
+	assert.Len(t, 1, stored)
+
+	// The responder is reused while it is valid.
+	b, err = a.CreateOCSPResponse(leaf, now, now.Add(time.Hour))
+	assert.FatalError(t, err)
+	resp2, err := ocsp.ParseResponseForCert(b, leaf, issuer)
+	assert.FatalError(t, err)
+	assert.Equals(t, resp.Certificate.Raw, resp2.Certificate.Raw)
+	assert.Len(t, 1, stored)
+}
+
+func TestAuthority_GenerateCertificateRevocationList_delegated(t *testing.T) {
+	var crlStore *db.CertificateRevocationListInfo
+	a := testAuthority(t, WithDatabase(&db.MockAuthDB{
+		MStoreCertificate: func(crt *x509.Certificate) error {
+			return nil
+		},
+		MStoreCRL: func(i *db.CertificateRevocationListInfo) error {
+			crlStore = i
+			return nil
+		},
+		MGetCRL: func() (*db.CertificateRevocationListInfo, error) {
+			if crlStore == nil {
+				return nil, database.ErrNotFound
+			}
+			return crlStore, nil
+		},
+		MGetRevokedCertificates: func() (*[]db.RevokedCertificateInfo, error) {
+			return &[]db.RevokedCertificateInfo{}, nil
+		},
+	}))
+	a.config.CRL = &config.CRLConfig{Enabled: true}
+	a.config.DelegatedSigners = &config.DelegatedSignersConfig{CRL: true}
+
+	_, err := a.GetCRLSignerCertificate()
+	assert.Error(t, err)
+
+	assert.FatalError(t, a.GenerateCertificateRevocationList())
+	b, err := a.GetCertificateRevocationList()
+	assert.FatalError(t, err)
+	crl, err := x509.ParseRevocationList(b)
+	assert.FatalError(t, err)
+
+	issuer, err := a.GetIssuerCertificate()
+	assert.FatalError(t, err)
+	signer, err := a.GetCRLSignerCertificate()
+	assert.FatalError(t, err)
+
+	assert.Equals(t, issuer.RawSubject, crl.RawIssuer)
+	assert.NoError(t, signer.CheckSignatureFrom(issuer))
+	assert.NoError(t, crl.CheckSignatureFrom(signer))
+	assert.Error(t, crl.CheckSignatureFrom(issuer))
+	assert.Equals(t, signer.SubjectKeyId, crl.AuthorityKeyId)
+
+	var hasAIA bool
+	for _, ext := range crl.Extensions {
+		if ext.Id.Equal(oidExtensionAuthorityInfoAccess) {
+			hasAIA = true
+		}
+	}
+	assert.True(t, hasAIA)
+}
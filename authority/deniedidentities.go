@@ -0,0 +1,195 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/linkedca"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/errs"
+)
+
+// Tags of the GeneralName types in a SAN extension.
+const (
+	nameTypeEmail = 1
+	nameTypeDNS   = 2
+	nameTypeURI   = 6
+	nameTypeIP    = 7
+)
+
+// initDeniedIdentities checks that the database can store the denied
+// identities if the authority is configured to deny the identities of revoked
+// certificates. Otherwise, the identities of the revoked certificates could be
+// used again right away.
+func (a *Authority) initDeniedIdentities() error {
+	if !a.config.AuthorityConfig.DenyRevokedIdentities {
+		return nil
+	}
+	if _, ok := a.db.(db.DeniedIdentityDB); !ok {
+		return errors.New("denyRevokedIdentities is not supported with the configured database")
+	}
+	return nil
+}
+
+// getDeniedIdentityDB returns the database of the denied identities if the
+// authority is configured to deny the identities of revoked certificates.
+func (a *Authority) getDeniedIdentityDB() (db.DeniedIdentityDB, bool) {
+	if !a.config.AuthorityConfig.DenyRevokedIdentities {
+		return nil, false
+	}
+	ddb, ok := a.db.(db.DeniedIdentityDB)
+	return ddb, ok
+}
+
+// certificateNames returns the common name and the SANs of a certificate,
+// including the ones in a SAN extension set by the template. The common name
+// and the DNS names are lowercased, so they are compared in a case-insensitive
+// way.
+func certificateNames(crt *x509.Certificate) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	add(strings.ToLower(crt.Subject.CommonName))
+	for _, name := range crt.DNSNames {
+		add(strings.ToLower(name))
+	}
+	for _, email := range crt.EmailAddresses {
+		add(email)
+	}
+	for _, ip := range crt.IPAddresses {
+		add(ip.String())
+	}
+	for _, u := range crt.URIs {
+		add(u.String())
+	}
+	for _, ext := range crt.ExtraExtensions {
+		if !ext.Id.Equal(oidSubjectAltName) {
+			continue
+		}
+		var sans []asn1.RawValue
+		if _, err := asn1.Unmarshal(ext.Value, &sans); err != nil {
+			continue
+		}
+		for _, san := range sans {
+			if san.Class != asn1.ClassContextSpecific {
+				continue
+			}
+			switch san.Tag {
+			case nameTypeEmail, nameTypeURI:
+				add(string(san.Bytes))
+			case nameTypeDNS:
+				add(strings.ToLower(string(san.Bytes)))
+			case nameTypeIP:
+				if len(san.Bytes) == net.IPv4len || len(san.Bytes) == net.IPv6len {
+					add(net.IP(san.Bytes).String())
+				}
+			}
+		}
+	}
+	return names
+}
+
+// denyRevokedIdentities stores the common name and the SANs of a certificate
+// revoked with the keyCompromise or cessationOfOperation reasons, so a new
+// certificate for them cannot be requested until an admin clears them.
+func (a *Authority) denyRevokedIdentities(crt *x509.Certificate, rci *db.RevokedCertificateInfo) error {
+	if crt == nil {
+		return nil
+	}
+	switch rci.ReasonCode {
+	case ocsp.KeyCompromise, ocsp.CessationOfOperation:
+	default:
+		return nil
+	}
+	ddb, ok := a.getDeniedIdentityDB()
+	if !ok {
+		return nil
+	}
+	now := time.Now().UTC()
+	for _, name := range certificateNames(crt) {
+		if err := ddb.DenyIdentity(&db.DeniedIdentity{
+			Name:     name,
+			Serial:   rci.Serial,
+			Reason:   rci.Reason,
+			DeniedAt: now,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDeniedIdentities returns a forbidden error if the common name or one of
+// the SANs of a certificate was denied after a revocation.
+func (a *Authority) checkDeniedIdentities(crt *x509.Certificate) error {
+	ddb, ok := a.getDeniedIdentityDB()
+	if !ok {
+		return nil
+	}
+	for _, name := range certificateNames(crt) {
+		di, err := ddb.GetDeniedIdentity(name)
+		if err != nil {
+			return errs.Wrap(http.StatusInternalServerError, err, "authority.Sign; error checking denied identities")
+		}
+		if di != nil {
+			return errs.ApplyOptions(
+				errs.Forbidden("authority.Sign; %s cannot be used, the certificate %s was revoked", name, di.Serial),
+				errs.WithCode(errs.CodePolicyDenied),
+			)
+		}
+	}
+	return nil
+}
+
+// GetDeniedIdentities returns the identities denied after the revocation of
+// their certificates.
+func (a *Authority) GetDeniedIdentities() ([]*db.DeniedIdentity, error) {
+	ddb, ok := a.getDeniedIdentityDB()
+	if !ok {
+		return nil, admin.NewError(admin.ErrorNotImplementedType, "denied identities are not enabled")
+	}
+	list, err := ddb.GetDeniedIdentities()
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error listing denied identities")
+	}
+	return list, nil
+}
+
+// ClearDeniedIdentities allows new certificates for the given identities.
+// Only super admins can clear the denied identities.
+func (a *Authority) ClearDeniedIdentities(_ context.Context, adm *linkedca.Admin, names []string) error {
+	if adm.GetType() != linkedca.Admin_SUPER_ADMIN {
+		return admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to clear denied identities")
+	}
+	ddb, ok := a.getDeniedIdentityDB()
+	if !ok {
+		return admin.NewError(admin.ErrorNotImplementedType, "denied identities are not enabled")
+	}
+	for _, name := range names {
+		di, err := ddb.GetDeniedIdentity(name)
+		if err != nil {
+			return admin.WrapErrorISE(err, "error loading denied identity")
+		}
+		if di == nil {
+			return admin.NewError(admin.ErrorNotFoundType, "identity %s is not denied", name)
+		}
+		if err := ddb.DeleteDeniedIdentity(name); err != nil {
+			return admin.WrapErrorISE(err, "error clearing denied identity")
+		}
+	}
+	return nil
+}
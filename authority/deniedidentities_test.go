@@ -0,0 +1,180 @@
+package authority
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/pemutil"
+	"go.step.sm/linkedca"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/errs"
+)
+
+type deniedIdentityDB struct {
+	*db.MockAuthDB
+	identities map[string]*db.DeniedIdentity
+}
+
+func (m *deniedIdentityDB) DenyIdentity(info *db.DeniedIdentity) error {
+	m.identities[info.Name] = info
+	return nil
+}
+
+func (m *deniedIdentityDB) GetDeniedIdentity(name string) (*db.DeniedIdentity, error) {
+	return m.identities[name], nil
+}
+
+func (m *deniedIdentityDB) GetDeniedIdentities() ([]*db.DeniedIdentity, error) {
+	var list []*db.DeniedIdentity
+	for _, di := range m.identities {
+		list = append(list, di)
+	}
+	return list, nil
+}
+
+func (m *deniedIdentityDB) DeleteDeniedIdentity(name string) error {
+	delete(m.identities, name)
+	return nil
+}
+
+func TestAuthority_deniedIdentities(t *testing.T) {
+	caPEM, err := os.ReadFile("testdata/certs/root_ca.crt")
+	assert.FatalError(t, err)
+	crt, err := pemutil.ReadCertificate("testdata/certs/intermediate_ca.crt")
+	assert.FatalError(t, err)
+	caKey, err := pemutil.Read("testdata/secrets/intermediate_ca_key", pemutil.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+
+	newAuthority := func(t *testing.T, enabled bool) (*Authority, *deniedIdentityDB) {
+		mdb := &deniedIdentityDB{MockAuthDB: &db.MockAuthDB{}, identities: map[string]*db.DeniedIdentity{}}
+		a, err := NewEmbedded(WithConfig(&config.Config{
+			AuthorityConfig: &config.AuthConfig{
+				DenyRevokedIdentities: enabled,
+			},
+		}), WithX509RootBundle(caPEM), WithX509Signer(crt, caKey.(crypto.Signer)), WithDatabase(mdb))
+		assert.FatalError(t, err)
+		return a, mdb
+	}
+	newCSR := func(names ...string) *x509.CertificateRequest {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.FatalError(t, err)
+		cr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			DNSNames: names,
+		}, key)
+		assert.FatalError(t, err)
+		csr, err := x509.ParseCertificateRequest(cr)
+		assert.FatalError(t, err)
+		return csr
+	}
+	ctx := context.Background()
+	superAdmin := &linkedca.Admin{Id: "admin-id", Type: linkedca.Admin_SUPER_ADMIN}
+
+	t.Run("ok", func(t *testing.T) {
+		a, mdb := newAuthority(t, true)
+		chain, err := a.Sign(newCSR("foo.bar.zar", "www.bar.zar"), provisioner.SignOptions{})
+		assert.FatalError(t, err)
+		serial := chain[0].SerialNumber.String()
+
+		// Only key compromise and cessation of operation revocations deny
+		// the identities.
+		assert.FatalError(t, a.denyRevokedIdentities(chain[0], &db.RevokedCertificateInfo{Serial: serial, ReasonCode: ocsp.Superseded}))
+		assert.Len(t, 0, mdb.identities)
+		assert.FatalError(t, a.denyRevokedIdentities(chain[0], &db.RevokedCertificateInfo{Serial: serial, ReasonCode: ocsp.KeyCompromise, Reason: "leaked"}))
+		assert.Len(t, 2, mdb.identities)
+		if assert.NotNil(t, mdb.identities["foo.bar.zar"]) {
+			assert.Equals(t, serial, mdb.identities["foo.bar.zar"].Serial)
+			assert.Equals(t, "leaked", mdb.identities["foo.bar.zar"].Reason)
+		}
+
+		// A new certificate with one of the names is forbidden.
+		_, err = a.Sign(newCSR("new.bar.zar", "WWW.bar.zar"), provisioner.SignOptions{})
+		if assert.NotNil(t, err) {
+			var e *errs.Error
+			assert.Fatal(t, errors.As(err, &e), "error is not an *errs.Error")
+			assert.Equals(t, http.StatusForbidden, e.StatusCode())
+			assert.Equals(t, errs.CodePolicyDenied, e.ErrorCode())
+		}
+		_, err = a.Sign(newCSR("other.bar.zar"), provisioner.SignOptions{})
+		assert.FatalError(t, err)
+
+		list, err := a.GetDeniedIdentities()
+		assert.FatalError(t, err)
+		assert.Len(t, 2, list)
+
+		// Only super admins can clear the identities.
+		err = a.ClearDeniedIdentities(ctx, &linkedca.Admin{Type: linkedca.Admin_ADMIN}, []string{"foo.bar.zar"})
+		assert.True(t, isAdminError(err, admin.ErrorUnauthorizedType))
+		err = a.ClearDeniedIdentities(ctx, superAdmin, []string{"new.bar.zar"})
+		assert.True(t, isAdminError(err, admin.ErrorNotFoundType))
+		assert.FatalError(t, a.ClearDeniedIdentities(ctx, superAdmin, []string{"foo.bar.zar", "www.bar.zar"}))
+		assert.Len(t, 0, mdb.identities)
+		_, err = a.Sign(newCSR("foo.bar.zar", "www.bar.zar"), provisioner.SignOptions{})
+		assert.FatalError(t, err)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		a, mdb := newAuthority(t, false)
+		chain, err := a.Sign(newCSR("foo.bar.zar"), provisioner.SignOptions{})
+		assert.FatalError(t, err)
+		assert.FatalError(t, a.denyRevokedIdentities(chain[0], &db.RevokedCertificateInfo{ReasonCode: ocsp.CessationOfOperation}))
+		assert.Len(t, 0, mdb.identities)
+		_, err = a.GetDeniedIdentities()
+		assert.True(t, isAdminError(err, admin.ErrorNotImplementedType))
+		err = a.ClearDeniedIdentities(ctx, superAdmin, []string{"foo.bar.zar"})
+		assert.True(t, isAdminError(err, admin.ErrorNotImplementedType))
+	})
+
+	t.Run("fail/database", func(t *testing.T) {
+		a, err := NewEmbedded(WithConfig(&config.Config{
+			AuthorityConfig: &config.AuthConfig{
+				DenyRevokedIdentities: true,
+			},
+		}), WithX509RootBundle(caPEM), WithX509Signer(crt, caKey.(crypto.Signer)), WithDatabase(&db.MockAuthDB{}))
+		assert.Nil(t, a)
+		if assert.NotNil(t, err) {
+			assert.Equals(t, "denyRevokedIdentities is not supported with the configured database", err.Error())
+		}
+	})
+}
+
+func isAdminError(err error, typ admin.ProblemType) bool {
+	var ae *admin.Error
+	return errors.As(err, &ae) && ae.IsType(typ)
+}
+
+func Test_certificateNames(t *testing.T) {
+	u, err := url.Parse("spiffe://example.com/workload")
+	assert.FatalError(t, err)
+	crt := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "Foo.Example.com"},
+		DNSNames:       []string{"foo.example.com", "BAR.example.com"},
+		EmailAddresses: []string{"Jane@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+		URIs:           []*url.URL{u},
+		ExtraExtensions: []pkix.Extension{{
+			Id:    oidSubjectAltName,
+			Value: []byte{0x30, 0x13, 0x82, 0x0b, 'Z', 'a', 'r', '.', 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x87, 0x04, 10, 0, 0, 2},
+		}},
+	}
+	assert.Equals(t, []string{
+		"foo.example.com", "bar.example.com", "Jane@example.com", "10.0.0.1",
+		"spiffe://example.com/workload", "zar.example", "10.0.0.2",
+	}, certificateNames(crt))
+}
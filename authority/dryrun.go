@@ -0,0 +1,74 @@
+package authority
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	"go.step.sm/crypto/x509util"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+)
+
+// SignDryRun runs the sign flow for a certificate signing request, rendering
+// the templates and checking the policies of the authority, but it does not
+// sign, store or audit the certificate. The authorizing webhooks are not
+// called either.
+//
+// It returns the DER encoded TBSCertificate, the part of the certificate with
+// the extensions, issuer and validity that the authority would sign. The
+// standard library only encodes it as part of a signed certificate, so it's
+// created with a throwaway key of the same type as the key of the issuer, and
+// that certificate is discarded.
+func (a *Authority) SignDryRun(ctx context.Context, csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]byte, error) {
+	r, _, err := a.renderX509(ctx, csr, signOpts, extraOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf := r.leaf
+	if leaf.NotBefore.IsZero() {
+		leaf.NotBefore = time.Now().Add(-1 * r.signOpts.Backdate)
+	}
+	parent := &x509.Certificate{}
+	if len(a.intermediateX509Certs) > 0 {
+		issuer := *a.intermediateX509Certs[0]
+		parent = &issuer
+		leaf.Issuer = issuer.Subject
+	}
+	signer, err := newDryRunSigner(parent.PublicKey)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.SignDryRun", r.opts...)
+	}
+	parent.PublicKey = signer.Public()
+
+	crt, err := x509util.CreateCertificate(leaf, parent, leaf.PublicKey, signer)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.SignDryRun; error creating certificate", r.opts...)
+	}
+	return crt.RawTBSCertificate, nil
+}
+
+// newDryRunSigner generates a key of the same type as the given public key,
+// used to encode the TBSCertificate of dry runs.
+func newDryRunSigner(pub crypto.PublicKey) (crypto.Signer, error) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.GenerateKey(k.Curve, rand.Reader)
+	case *rsa.PublicKey:
+		return rsa.GenerateKey(rand.Reader, k.Size()*8)
+	case ed25519.PublicKey:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	default:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+}
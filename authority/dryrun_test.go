@@ -0,0 +1,100 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/keyutil"
+
+	"github.com/smallstep/certificates/authority/policy"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+)
+
+// parseTBSCertificate parses the given TBSCertificate, wrapped in a
+// certificate with an empty signature.
+func parseTBSCertificate(t *testing.T, tbs []byte) *x509.Certificate {
+	t.Helper()
+	var fields struct {
+		Version            int `asn1:"optional,explicit,default:0,tag:0"`
+		SerialNumber       *big.Int
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+	}
+	_, err := asn1.Unmarshal(tbs, &fields)
+	assert.FatalError(t, err)
+	der, err := asn1.Marshal(struct {
+		TBSCertificate     asn1.RawValue
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		SignatureValue     asn1.BitString
+	}{asn1.RawValue{FullBytes: tbs}, fields.SignatureAlgorithm, asn1.BitString{}})
+	assert.FatalError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	assert.FatalError(t, err)
+	return crt
+}
+
+func TestAuthority_SignDryRun(t *testing.T) {
+	_, priv, err := keyutil.GenerateDefaultKeyPair()
+	assert.FatalError(t, err)
+
+	a := testAuthority(t)
+	a.db = &db.MockAuthDB{
+		MStoreCertificate: func(crt *x509.Certificate) error {
+			t.Error("dry runs must not store certificates")
+			return nil
+		},
+		MUseToken: func(id, tok string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	key, err := jose.ReadKey("testdata/secrets/step_cli_key_priv.jwk", jose.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+	token, err := generateToken("smallstep test", "step-cli", testAudiences.Sign[0], []string{"test.smallstep.com"}, time.Now(), key)
+	assert.FatalError(t, err)
+	ctx := provisioner.NewContextWithMethod(context.Background(), provisioner.SignMethod)
+	extraOpts, err := a.Authorize(ctx, token)
+	assert.FatalError(t, err)
+
+	nb := time.Now().Truncate(time.Second)
+	signOpts := provisioner.SignOptions{
+		NotBefore: provisioner.NewTimeDuration(nb),
+		NotAfter:  provisioner.NewTimeDuration(nb.Add(5 * time.Minute)),
+	}
+	csr := getCSR(t, priv)
+
+	tbs, err := a.SignDryRun(ctx, csr, signOpts, extraOpts...)
+	assert.FatalError(t, err)
+	// The result is not a certificate.
+	_, err = x509.ParseCertificate(tbs)
+	assert.Error(t, err)
+
+	crt := parseTBSCertificate(t, tbs)
+	issuer := a.intermediateX509Certs[0]
+	assert.Equals(t, "smallstep test", crt.Subject.CommonName)
+	assert.Equals(t, []string{"test.smallstep.com"}, crt.DNSNames)
+	assert.Equals(t, issuer.RawSubject, crt.RawIssuer)
+	assert.Equals(t, issuer.SubjectKeyId, crt.AuthorityKeyId)
+	assert.Equals(t, nb.UTC(), crt.NotBefore)
+	assert.Equals(t, nb.Add(5*time.Minute).UTC(), crt.NotAfter)
+	assert.Equals(t, issuer.SignatureAlgorithm, crt.SignatureAlgorithm)
+	assert.NotNil(t, crt.SerialNumber)
+
+	// Policies are enforced.
+	engine, err := policy.New(&policy.Options{
+		X509: &policy.X509PolicyOptions{
+			DeniedNames: &policy.X509NameOptions{DNSDomains: []string{"test.smallstep.com"}},
+		},
+	})
+	assert.FatalError(t, err)
+	a.policyEngine = engine
+	_, err = a.SignDryRun(ctx, csr, signOpts, extraOpts...)
+	assert.Error(t, err)
+}
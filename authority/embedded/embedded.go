@@ -0,0 +1,225 @@
+// Package embedded runs a certificate authority as a library inside another Go
+// program. It provides provisioner authorization, certificate templates,
+// policies and signing without starting the HTTP API and without requiring a
+// database.
+//
+// The types and functions in this package are the stable interface for
+// products that bundle a private CA, they will only change in backwards
+// compatible ways in minor releases. The [CA.Authority] method gives access to
+// the underlying authority for everything else, but its API is not covered by
+// this guarantee.
+//
+// Without a database, the used tokens are kept in memory and certificates
+// cannot be revoked.
+package embedded
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/policy"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+)
+
+// Options are the parameters used to create an embedded CA.
+type Options struct {
+	// Roots are the root certificates of the CA. At least one is required.
+	Roots []*x509.Certificate
+	// IssuerChain is the certificate used to sign X.509 certificates followed
+	// by its intermediates, without the root. It is required.
+	IssuerChain []*x509.Certificate
+	// Signer is the private key of the first certificate in the issuer
+	// chain. It is required.
+	Signer crypto.Signer
+	// SSHHostSigner and SSHUserSigner are the keys used to sign SSH host and
+	// user certificates. They are optional.
+	SSHHostSigner crypto.Signer
+	SSHUserSigner crypto.Signer
+	// DNSNames are the names of the CA. The audiences accepted in the
+	// provisioner tokens are the URLs of the HTTP API in these names, for
+	// example https://ca.example.com/1.0/sign.
+	DNSNames []string
+	// Provisioners are the provisioners that can authorize requests.
+	Provisioners provisioner.List
+	// Claims are the global claims, used if a provisioner does not define
+	// them.
+	Claims *provisioner.Claims
+	// Policy is the global policy for the names in the certificates.
+	Policy *policy.Options
+	// Backdate is the duration subtracted from the NotBefore of the
+	// certificates. It defaults to one minute.
+	Backdate time.Duration
+	// DB stores the certificates, the used tokens and the revocations. It is
+	// optional.
+	DB db.AuthDB
+}
+
+// SignOptions are the options that can be requested when signing an X.509
+// certificate. Provisioners and templates can modify or reject them.
+type SignOptions struct {
+	NotBefore    time.Time
+	NotAfter     time.Time
+	TemplateData json.RawMessage
+}
+
+// SSHSignOptions are the options that can be requested when signing an SSH
+// certificate. Provisioners and templates can modify or reject them.
+type SSHSignOptions struct {
+	// CertType is either "user" or "host".
+	CertType     string
+	KeyID        string
+	Principals   []string
+	ValidAfter   time.Time
+	ValidBefore  time.Time
+	TemplateData json.RawMessage
+}
+
+// RevokeOptions are the options used to revoke a certificate.
+type RevokeOptions struct {
+	SerialNumber string
+	ReasonCode   int
+	Reason       string
+}
+
+// CA is a certificate authority embedded in a Go program. It is safe for
+// concurrent use.
+type CA struct {
+	auth *authority.Authority
+}
+
+// New creates an embedded CA with the given options.
+func New(opts *Options) (*CA, error) {
+	switch {
+	case opts == nil:
+		return nil, errors.New("embedded: options cannot be nil")
+	case len(opts.Roots) == 0:
+		return nil, errors.New("embedded: options must have at least one root certificate")
+	case len(opts.IssuerChain) == 0:
+		return nil, errors.New("embedded: options must have an issuer certificate")
+	case opts.Signer == nil:
+		return nil, errors.New("embedded: options must have a signer")
+	}
+
+	cfg := &config.Config{
+		DNSNames: opts.DNSNames,
+		AuthorityConfig: &config.AuthConfig{
+			Provisioners: opts.Provisioners,
+			Claims:       opts.Claims,
+			Policy:       opts.Policy,
+		},
+	}
+	if opts.Backdate > 0 {
+		cfg.AuthorityConfig.Backdate = &provisioner.Duration{Duration: opts.Backdate}
+	}
+
+	authOpts := []authority.Option{
+		authority.WithConfig(cfg),
+		authority.WithX509RootCerts(opts.Roots...),
+		authority.WithX509SignerChain(opts.IssuerChain, opts.Signer),
+		authority.WithQuietInit(),
+	}
+	if opts.SSHHostSigner != nil {
+		authOpts = append(authOpts, authority.WithSSHHostSigner(opts.SSHHostSigner))
+	}
+	if opts.SSHUserSigner != nil {
+		authOpts = append(authOpts, authority.WithSSHUserSigner(opts.SSHUserSigner))
+	}
+	if opts.DB != nil {
+		authOpts = append(authOpts, authority.WithDatabase(opts.DB))
+	}
+
+	auth, err := authority.NewEmbedded(authOpts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "embedded: error creating authority")
+	}
+	return &CA{auth: auth}, nil
+}
+
+// Sign authorizes the given provisioner token and signs an X.509 certificate
+// for the certificate request. It returns the certificate followed by the
+// issuer chain.
+func (c *CA) Sign(ctx context.Context, csr *x509.CertificateRequest, token string, opts *SignOptions) ([]*x509.Certificate, error) {
+	ctx = provisioner.NewContextWithMethod(ctx, provisioner.SignMethod)
+	signOpts, err := c.auth.Authorize(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	var so provisioner.SignOptions
+	if opts != nil {
+		so.NotBefore = provisioner.NewTimeDuration(opts.NotBefore)
+		so.NotAfter = provisioner.NewTimeDuration(opts.NotAfter)
+		so.TemplateData = opts.TemplateData
+	}
+	return c.auth.SignWithContext(ctx, csr, so, signOpts...)
+}
+
+// SignSSH authorizes the given provisioner token and signs an SSH certificate
+// for the public key.
+func (c *CA) SignSSH(ctx context.Context, key ssh.PublicKey, token string, opts *SSHSignOptions) (*ssh.Certificate, error) {
+	ctx = provisioner.NewContextWithMethod(ctx, provisioner.SSHSignMethod)
+	signOpts, err := c.auth.Authorize(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	var so provisioner.SignSSHOptions
+	if opts != nil {
+		so.CertType = opts.CertType
+		so.KeyID = opts.KeyID
+		so.Principals = opts.Principals
+		so.ValidAfter = provisioner.NewTimeDuration(opts.ValidAfter)
+		so.ValidBefore = provisioner.NewTimeDuration(opts.ValidBefore)
+		so.TemplateData = opts.TemplateData
+	}
+	return c.auth.SignSSH(ctx, key, so, signOpts...)
+}
+
+// Renew renews an X.509 certificate signed by this CA, keeping its key. The
+// caller must have verified that the requester owns the certificate, for
+// example using it as the client certificate of a TLS connection.
+func (c *CA) Renew(ctx context.Context, crt *x509.Certificate) ([]*x509.Certificate, error) {
+	return c.auth.RenewContext(ctx, crt, nil)
+}
+
+// Revoke authorizes the given provisioner token and revokes the certificate
+// with the serial number in the options. It requires a database.
+func (c *CA) Revoke(ctx context.Context, token string, opts *RevokeOptions) error {
+	if opts == nil {
+		return errors.New("embedded: revoke options cannot be nil")
+	}
+	ctx = provisioner.NewContextWithMethod(ctx, provisioner.RevokeMethod)
+	if _, err := c.auth.Authorize(ctx, token); err != nil {
+		return err
+	}
+	return c.auth.Revoke(ctx, &authority.RevokeOptions{
+		Serial:      opts.SerialNumber,
+		ReasonCode:  opts.ReasonCode,
+		Reason:      opts.Reason,
+		PassiveOnly: true,
+		OTT:         token,
+	})
+}
+
+// Roots returns the root certificates of the CA.
+func (c *CA) Roots() []*x509.Certificate {
+	roots, _ := c.auth.GetRoots()
+	return roots
+}
+
+// Authority returns the underlying authority. Its API is not stable.
+func (c *CA) Authority() *authority.Authority {
+	return c.auth
+}
+
+// Shutdown stops the CA and closes the database.
+func (c *CA) Shutdown() error {
+	return c.auth.Shutdown()
+}
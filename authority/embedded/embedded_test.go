@@ -0,0 +1,168 @@
+package embedded
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/pemutil"
+	"go.step.sm/crypto/randutil"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func testOptions(t *testing.T) (*Options, *jose.JSONWebKey) {
+	t.Helper()
+	root, err := pemutil.ReadCertificate("../testdata/certs/root_ca.crt")
+	require.NoError(t, err)
+	crt, err := pemutil.ReadCertificate("../testdata/certs/intermediate_ca.crt")
+	require.NoError(t, err)
+	key, err := pemutil.Read("../testdata/secrets/intermediate_ca_key", pemutil.WithPassword([]byte("pass")))
+	require.NoError(t, err)
+
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	require.NoError(t, err)
+	pub := jwk.Public()
+
+	return &Options{
+		Roots:       []*x509.Certificate{root},
+		IssuerChain: []*x509.Certificate{crt},
+		Signer:      key.(crypto.Signer),
+		DNSNames:    []string{"ca.smallstep.com"},
+		Provisioners: provisioner.List{
+			&provisioner.JWK{Type: "JWK", Name: "mariano@smallstep.com", Key: &pub},
+		},
+	}, jwk
+}
+
+func testToken(t *testing.T, jwk *jose.JSONWebKey, sub, aud string) string {
+	t.Helper()
+	so := new(jose.SignerOptions)
+	so.WithType("JWT")
+	so.WithHeader("kid", jwk.KeyID)
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jwk.Key}, so)
+	require.NoError(t, err)
+	id, err := randutil.ASCII(64)
+	require.NoError(t, err)
+
+	now := time.Now()
+	claims := struct {
+		jose.Claims
+		SANs []string `json:"sans"`
+	}{
+		Claims: jose.Claims{
+			ID:        id,
+			Subject:   sub,
+			Issuer:    "mariano@smallstep.com",
+			IssuedAt:  jose.NewNumericDate(now),
+			NotBefore: jose.NewNumericDate(now),
+			Expiry:    jose.NewNumericDate(now.Add(5 * time.Minute)),
+			Audience:  []string{aud},
+		},
+		SANs: []string{sub},
+	}
+	tok, err := jose.Signed(sig).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+	return tok
+}
+
+func testCSR(t *testing.T, sans ...string) *x509.CertificateRequest {
+	t.Helper()
+	signer, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	require.NoError(t, err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		DNSNames: sans,
+	}, signer.Key)
+	require.NoError(t, err)
+	csr, err := x509.ParseCertificateRequest(der)
+	require.NoError(t, err)
+	return csr
+}
+
+func TestNew(t *testing.T) {
+	opts, _ := testOptions(t)
+	tests := []struct {
+		name    string
+		opts    func() *Options
+		wantErr string
+	}{
+		{"fail/nil", func() *Options { return nil }, "embedded: options cannot be nil"},
+		{"fail/roots", func() *Options { o := *opts; o.Roots = nil; return &o }, "embedded: options must have at least one root certificate"},
+		{"fail/issuer", func() *Options { o := *opts; o.IssuerChain = nil; return &o }, "embedded: options must have an issuer certificate"},
+		{"fail/signer", func() *Options { o := *opts; o.Signer = nil; return &o }, "embedded: options must have a signer"},
+		{"ok", func() *Options { return opts }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ca, err := New(tt.opts())
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.Nil(t, ca)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, opts.Roots, ca.Roots())
+			assert.NotNil(t, ca.Authority())
+			assert.NoError(t, ca.Shutdown())
+		})
+	}
+}
+
+func TestCA_Sign(t *testing.T) {
+	opts, jwk := testOptions(t)
+	ca, err := New(opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ca.Shutdown() })
+
+	ctx := context.Background()
+	aud := "https://ca.smallstep.com/1.0/sign"
+
+	tok := testToken(t, jwk, "foo.smallstep.com", aud)
+	chain, err := ca.Sign(ctx, testCSR(t, "foo.smallstep.com"), tok, &SignOptions{
+		NotAfter: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Len(t, chain, 2)
+	assert.Equal(t, []string{"foo.smallstep.com"}, chain[0].DNSNames)
+	assert.Equal(t, opts.IssuerChain[0], chain[1])
+
+	// Tokens cannot be reused without a database.
+	_, err = ca.Sign(ctx, testCSR(t, "foo.smallstep.com"), tok, nil)
+	assert.Error(t, err)
+
+	// The token must be for the names in the request.
+	_, err = ca.Sign(ctx, testCSR(t, "bar.smallstep.com"), testToken(t, jwk, "foo.smallstep.com", aud), nil)
+	assert.Error(t, err)
+
+	// The token must be for this CA.
+	_, err = ca.Sign(ctx, testCSR(t, "foo.smallstep.com"), testToken(t, jwk, "foo.smallstep.com", "https://example.com/1.0/sign"), nil)
+	assert.Error(t, err)
+
+	// Renew
+	renewed, err := ca.Renew(ctx, chain[0])
+	require.NoError(t, err)
+	require.Len(t, renewed, 2)
+	assert.Equal(t, chain[0].DNSNames, renewed[0].DNSNames)
+	assert.Equal(t, chain[0].PublicKey, renewed[0].PublicKey)
+	assert.NotEqual(t, chain[0].SerialNumber, renewed[0].SerialNumber)
+}
+
+func TestCA_Revoke(t *testing.T) {
+	opts, jwk := testOptions(t)
+	ca, err := New(opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ca.Shutdown() })
+
+	ctx := context.Background()
+	assert.EqualError(t, ca.Revoke(ctx, "", nil), "embedded: revoke options cannot be nil")
+
+	// Revocation requires a database.
+	tok := testToken(t, jwk, "1234", "https://ca.smallstep.com/1.0/revoke")
+	assert.Error(t, ca.Revoke(ctx, tok, &RevokeOptions{SerialNumber: "1234"}))
+}
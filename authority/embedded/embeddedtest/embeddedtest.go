@@ -0,0 +1,345 @@
+// Package embeddedtest provides an embedded CA for the tests of the programs
+// that bundle it. The CA uses fixed keys and a fixed clock, and it assigns
+// sequential serial numbers, so issuing the same certificates always produces
+// the same bytes, and they can be compared with golden files using
+// AssertGolden.
+//
+// The authority still validates the requested validity with the system clock,
+// so the fixed clock must be in the future. The default one is DefaultNow.
+package embeddedtest
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/randutil"
+	"go.step.sm/crypto/x509util"
+
+	"github.com/smallstep/certificates/authority/embedded"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+const (
+	// ProvisionerName is the name of the JWK provisioner of the CA, and the
+	// issuer of its tokens.
+	ProvisionerName = "embeddedtest"
+	// DNSName is the name of the CA, the tokens are for the URLs of the HTTP
+	// API in this name.
+	DNSName = "ca.embeddedtest.local"
+)
+
+// DefaultNow is the default time used as the NotBefore of the certificates.
+var DefaultNow = time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// DefaultTemplate is the default X.509 template of the provisioner. It is the
+// default leaf template with the serial number set by the CA.
+const DefaultTemplate = `{
+	"subject": {{ toJson .Subject }},
+	"serialNumber": {{ toJson .Insecure.User.serialNumber }},
+	"sans": {{ toJson .SANs }},
+{{- if typeIs "*rsa.PublicKey" .Insecure.CR.PublicKey }}
+	"keyUsage": ["keyEncipherment", "digitalSignature"],
+{{- else }}
+	"keyUsage": ["digitalSignature"],
+{{- end }}
+	"extKeyUsage": ["serverAuth", "clientAuth"]
+}`
+
+// Options are the parameters used to create a test CA. The zero value is
+// valid.
+type Options struct {
+	// Now is the fixed time used as the NotBefore of the certificates. It
+	// defaults to DefaultNow.
+	Now time.Time
+	// Validity is the duration of the certificates. It defaults to 24 hours.
+	Validity time.Duration
+	// Template is the X.509 template of the provisioner. Templates must set
+	// the serial number from .Insecure.User.serialNumber to issue
+	// deterministic certificates. It defaults to DefaultTemplate.
+	Template string
+	// Claims are the claims of the provisioner.
+	Claims *provisioner.Claims
+}
+
+// CA is an embedded CA with fixed keys and a fixed clock.
+type CA struct {
+	*embedded.CA
+	root         *x509.Certificate
+	intermediate *x509.Certificate
+	jwk          *jose.JSONWebKey
+	now          time.Time
+	validity     time.Duration
+
+	mu     sync.Mutex
+	serial int64
+}
+
+// New creates a test CA with the given options, which can be nil. The test
+// fails if the CA cannot be created, and the CA is stopped at the end of the
+// test.
+func New(t testing.TB, opts *Options) *CA {
+	t.Helper()
+	if opts == nil {
+		opts = &Options{}
+	}
+	ca, err := newCA(opts)
+	if err != nil {
+		t.Fatalf("embeddedtest: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = ca.Shutdown()
+	})
+	return ca
+}
+
+func newCA(opts *Options) (*CA, error) {
+	now := opts.Now
+	if now.IsZero() {
+		now = DefaultNow
+	}
+	validity := opts.Validity
+	if validity == 0 {
+		validity = 24 * time.Hour
+	}
+	template := opts.Template
+	if template == "" {
+		template = DefaultTemplate
+	}
+
+	// The CA certificates are valid for two centuries around the default
+	// clock, so they cover any clock used in the tests.
+	rootKey, intKey := Key("root"), Key("intermediate")
+	root, err := newCACertificate(1, "Embeddedtest Root CA", rootKey, nil, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	intermediate, err := newCACertificate(2, "Embeddedtest Intermediate CA", intKey, root, rootKey)
+	if err != nil {
+		return nil, err
+	}
+
+	jwk := &jose.JSONWebKey{
+		Key:       Key("provisioner"),
+		Algorithm: jose.EdDSA,
+		Use:       "sig",
+	}
+	kid, err := jose.Thumbprint(jwk)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating provisioner key")
+	}
+	jwk.KeyID = kid
+	pub := jwk.Public()
+
+	c, err := embedded.New(&embedded.Options{
+		Roots:       []*x509.Certificate{root},
+		IssuerChain: []*x509.Certificate{intermediate},
+		Signer:      intKey,
+		DNSNames:    []string{DNSName},
+		Provisioners: provisioner.List{
+			&provisioner.JWK{
+				Type:   "JWK",
+				Name:   ProvisionerName,
+				Key:    &pub,
+				Claims: opts.Claims,
+				Options: &provisioner.Options{
+					X509: &provisioner.X509Options{Template: template},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CA{
+		CA:           c,
+		root:         root,
+		intermediate: intermediate,
+		jwk:          jwk,
+		now:          now,
+		validity:     validity,
+	}, nil
+}
+
+// Key returns an Ed25519 key derived from the given name. The same name
+// always returns the same key, and Ed25519 signatures are deterministic.
+func Key(name string) crypto.Signer {
+	seed := sha256.Sum256([]byte("embeddedtest " + name))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+func newCACertificate(serial int64, name string, key crypto.Signer, parent *x509.Certificate, signer crypto.Signer) (*x509.Certificate, error) {
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             DefaultNow.AddDate(-100, 0, 0),
+		NotAfter:              DefaultNow.AddDate(100, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if parent == nil {
+		parent = tmpl
+	} else {
+		tmpl.MaxPathLen = 0
+		tmpl.MaxPathLenZero = true
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), signer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating %s", name)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", name)
+	}
+	return crt, nil
+}
+
+// Root returns the root certificate of the CA.
+func (c *CA) Root() *x509.Certificate {
+	return c.root
+}
+
+// Intermediate returns the certificate that signs the issued certificates.
+func (c *CA) Intermediate() *x509.Certificate {
+	return c.intermediate
+}
+
+// Now returns the fixed time used as the NotBefore of the certificates.
+func (c *CA) Now() time.Time {
+	return c.now
+}
+
+// Token returns a token of the provisioner to sign a certificate for the
+// given subject and SANs. The subject is also used as a SAN if none is given.
+// Tokens are valid for the system clock, as the authority verifies them with
+// it.
+func (c *CA) Token(t testing.TB, subject string, sans ...string) string {
+	t.Helper()
+	tok, err := c.token(subject, sans)
+	if err != nil {
+		t.Fatalf("embeddedtest: %v", err)
+	}
+	return tok
+}
+
+func (c *CA) token(subject string, sans []string) (string, error) {
+	if len(sans) == 0 {
+		sans = []string{subject}
+	}
+	so := new(jose.SignerOptions)
+	so.WithType("JWT")
+	so.WithHeader("kid", c.jwk.KeyID)
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.EdDSA, Key: c.jwk.Key}, so)
+	if err != nil {
+		return "", errors.Wrap(err, "error creating token signer")
+	}
+	id, err := randutil.ASCII(64)
+	if err != nil {
+		return "", errors.Wrap(err, "error creating token id")
+	}
+
+	now := time.Now()
+	claims := struct {
+		jose.Claims
+		SANs []string `json:"sans"`
+	}{
+		Claims: jose.Claims{
+			ID:        id,
+			Subject:   subject,
+			Issuer:    ProvisionerName,
+			IssuedAt:  jose.NewNumericDate(now),
+			NotBefore: jose.NewNumericDate(now),
+			Expiry:    jose.NewNumericDate(now.Add(5 * time.Minute)),
+			Audience:  []string{"https://" + DNSName + "/1.0/sign"},
+		},
+		SANs: sans,
+	}
+	tok, err := jose.Signed(sig).Claims(claims).CompactSerialize()
+	if err != nil {
+		return "", errors.Wrap(err, "error signing token")
+	}
+	return tok, nil
+}
+
+// Issue signs a certificate for the given subject and SANs with the key
+// returned by Key for the subject. The subject is also used as a SAN if none
+// is given. It returns the certificate followed by the intermediate.
+//
+// The certificates are valid from the fixed clock of the CA, and their serial
+// numbers are assigned in sequence starting at 1000, so a test that issues
+// the same certificates in the same order always gets the same results.
+func (c *CA) Issue(t testing.TB, subject string, sans ...string) []*x509.Certificate {
+	t.Helper()
+	if len(sans) == 0 {
+		sans = []string{subject}
+	}
+	csr, err := NewCertificateRequest(Key(subject), subject, sans...)
+	if err != nil {
+		t.Fatalf("embeddedtest: %v", err)
+	}
+	return c.Sign(t, csr, c.Token(t, subject, sans...))
+}
+
+// Sign signs a certificate for the certificate request authorized by the
+// given token, with the fixed clock and the next serial number of the CA.
+func (c *CA) Sign(t testing.TB, csr *x509.CertificateRequest, token string) []*x509.Certificate {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{
+		"serialNumber": c.nextSerial(),
+	})
+	if err != nil {
+		t.Fatalf("embeddedtest: %v", err)
+	}
+	chain, err := c.CA.Sign(context.Background(), csr, token, &embedded.SignOptions{
+		NotBefore:    c.now,
+		NotAfter:     c.now.Add(c.validity),
+		TemplateData: data,
+	})
+	if err != nil {
+		t.Fatalf("embeddedtest: error signing certificate: %v", err)
+	}
+	return chain
+}
+
+func (c *CA) nextSerial() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.serial++
+	return big.NewInt(999 + c.serial).String()
+}
+
+// NewCertificateRequest returns a certificate request for the given subject
+// and SANs signed by the given key. SANs are added as DNS names, IP
+// addresses, emails or URIs depending on their format.
+func NewCertificateRequest(key crypto.Signer, subject string, sans ...string) (*x509.CertificateRequest, error) {
+	dnsNames, ips, emails, uris := x509util.SplitSANs(sans)
+	tmpl := &x509.CertificateRequest{
+		Subject:        pkix.Name{CommonName: subject},
+		DNSNames:       dnsNames,
+		IPAddresses:    ips,
+		EmailAddresses: emails,
+		URIs:           uris,
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing certificate request")
+	}
+	return csr, nil
+}
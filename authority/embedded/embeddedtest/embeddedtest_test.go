@@ -0,0 +1,91 @@
+package embeddedtest
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCA_Issue(t *testing.T) {
+	ca := New(t, nil)
+	chain := ca.Issue(t, "foo.example.com", "foo.example.com", "10.0.0.1", "foo@example.com")
+	require.Len(t, chain, 2)
+	crt := chain[0]
+	assert.Equal(t, "1000", crt.SerialNumber.String())
+	assert.Equal(t, "foo.example.com", crt.Subject.CommonName)
+	assert.Equal(t, []string{"foo.example.com"}, crt.DNSNames)
+	assert.Equal(t, "10.0.0.1", crt.IPAddresses[0].String())
+	assert.Equal(t, []string{"foo@example.com"}, crt.EmailAddresses)
+	assert.Equal(t, DefaultNow, crt.NotBefore)
+	assert.Equal(t, DefaultNow.Add(24*time.Hour), crt.NotAfter)
+	assert.Equal(t, ca.Intermediate(), chain[1])
+	assert.Equal(t, Key("foo.example.com").Public(), crt.PublicKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Root())
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(ca.Intermediate())
+	_, err := crt.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   ca.Now(),
+	})
+	assert.NoError(t, err)
+
+	// Serial numbers are sequential.
+	assert.Equal(t, "1001", ca.Issue(t, "bar.example.com")[0].SerialNumber.String())
+}
+
+func TestCA_Issue_deterministic(t *testing.T) {
+	opts := &Options{
+		Now:      DefaultNow.Add(time.Hour),
+		Validity: time.Hour,
+	}
+	issue := func() [][]byte {
+		ca := New(t, opts)
+		var certs [][]byte
+		for _, name := range []string{"foo.example.com", "bar.example.com"} {
+			certs = append(certs, ca.Issue(t, name)[0].Raw)
+		}
+		return certs
+	}
+	assert.Equal(t, issue(), issue())
+}
+
+func TestAssertGolden(t *testing.T) {
+	ca := New(t, nil)
+	AssertGolden(t, filepath.Join("testdata", "foo.golden"), ca.Issue(t, "foo.example.com")...)
+}
+
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertGolden_mismatch(t *testing.T) {
+	ca := New(t, nil)
+	chain := ca.Issue(t, "foo.example.com")
+
+	path := filepath.Join(t.TempDir(), "foo.golden")
+	require.NoError(t, os.WriteFile(path, encodeGolden(ca.Issue(t, "bar.example.com")), 0o600))
+
+	r := &recorder{TB: t}
+	AssertGolden(r, path, chain...)
+	require.Len(t, r.errors, 1)
+	assert.Contains(t, r.errors[0], "- [0] Serial: 1001\n")
+	assert.Contains(t, r.errors[0], "+ [0] Serial: 1000\n")
+	assert.Contains(t, r.errors[0], "+ [0] DNSNames: foo.example.com\n")
+	assert.NotContains(t, r.errors[0], "Issuer")
+}
@@ -0,0 +1,148 @@
+package embeddedtest
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("embeddedtest.update", false, "update the golden files compared by embeddedtest.AssertGolden")
+
+// AssertGolden compares the given certificates with the golden file in path.
+// The file contains a description of each certificate followed by its PEM
+// encoding. If the test is run with the -embeddedtest.update flag, the file
+// is written instead. If they are different, the test fails with the lines
+// of the descriptions that changed.
+func AssertGolden(t testing.TB, path string, certs ...*x509.Certificate) {
+	t.Helper()
+	got := encodeGolden(certs)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("embeddedtest: error creating %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, got, 0o600); err != nil {
+			t.Fatalf("embeddedtest: error writing %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("embeddedtest: error reading golden file, run the test with -embeddedtest.update to create it: %v", err)
+	}
+	if bytes.Equal(got, want) {
+		return
+	}
+
+	var wantCerts []*x509.Certificate
+	for rest := want; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		crt, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("embeddedtest: error parsing certificate in %s: %v", path, err)
+		}
+		wantCerts = append(wantCerts, crt)
+	}
+	t.Errorf("embeddedtest: certificates do not match %s:\n%s", path, diff(describeAll(wantCerts), describeAll(certs)))
+}
+
+func encodeGolden(certs []*x509.Certificate) []byte {
+	var buf bytes.Buffer
+	for i, crt := range certs {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(Describe(crt), "\n"), "\n") {
+			buf.WriteString("# " + line + "\n")
+		}
+		_ = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+	}
+	return buf.Bytes()
+}
+
+// Describe returns a text description of the fields of a certificate, one
+// per line. Extensions are described by their identifier and value.
+func Describe(crt *x509.Certificate) string {
+	var sb strings.Builder
+	field := func(name string, value interface{}) {
+		fmt.Fprintf(&sb, "%s: %v\n", name, value)
+	}
+	field("Serial", crt.SerialNumber)
+	field("Subject", crt.Subject)
+	field("Issuer", crt.Issuer)
+	field("NotBefore", crt.NotBefore.UTC().Format(time.RFC3339))
+	field("NotAfter", crt.NotAfter.UTC().Format(time.RFC3339))
+	field("PublicKeyAlgorithm", crt.PublicKeyAlgorithm)
+	field("SignatureAlgorithm", crt.SignatureAlgorithm)
+	if len(crt.DNSNames) > 0 {
+		field("DNSNames", strings.Join(crt.DNSNames, ", "))
+	}
+	if len(crt.IPAddresses) > 0 {
+		field("IPAddresses", crt.IPAddresses)
+	}
+	if len(crt.EmailAddresses) > 0 {
+		field("EmailAddresses", strings.Join(crt.EmailAddresses, ", "))
+	}
+	if len(crt.URIs) > 0 {
+		field("URIs", crt.URIs)
+	}
+	for _, ext := range crt.Extensions {
+		name := "Extension " + ext.Id.String()
+		if ext.Critical {
+			name += " (critical)"
+		}
+		field(name, hex.EncodeToString(ext.Value))
+	}
+	return sb.String()
+}
+
+func describeAll(certs []*x509.Certificate) []string {
+	var lines []string
+	for i, crt := range certs {
+		for _, line := range strings.Split(strings.TrimSuffix(Describe(crt), "\n"), "\n") {
+			lines = append(lines, fmt.Sprintf("[%d] %s", i, line))
+		}
+	}
+	return lines
+}
+
+// diff returns the lines only in want prefixed by "-" and the lines only in
+// got prefixed by "+". The signatures are not described, so two certificates
+// with the same description only differ in them.
+func diff(want, got []string) string {
+	inWant := make(map[string]bool, len(want))
+	for _, line := range want {
+		inWant[line] = true
+	}
+	inGot := make(map[string]bool, len(got))
+	for _, line := range got {
+		inGot[line] = true
+	}
+
+	var sb strings.Builder
+	for _, line := range want {
+		if !inGot[line] {
+			sb.WriteString("- " + line + "\n")
+		}
+	}
+	for _, line := range got {
+		if !inWant[line] {
+			sb.WriteString("+ " + line + "\n")
+		}
+	}
+	if sb.Len() == 0 {
+		return "descriptions are equal, the certificates differ in their signatures\n"
+	}
+	return sb.String()
+}
@@ -0,0 +1,77 @@
+package authority
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"net/http"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/x509util"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+)
+
+type serverSideKeyContextKey struct{}
+
+// newContextWithServerSideKey returns a context that marks the key of the
+// certificate as generated by the CA.
+func newContextWithServerSideKey(ctx context.Context) context.Context {
+	return context.WithValue(ctx, serverSideKeyContextKey{}, true)
+}
+
+// isServerSideKey returns if the key of the certificate signed with the given
+// context has been generated by the CA.
+func isServerSideKey(ctx context.Context) bool {
+	v, _ := ctx.Value(serverSideKeyContextKey{}).(bool)
+	return v
+}
+
+// Enroll generates a key and creates a certificate for it, for clients that
+// cannot create certificate requests. The certificate request is created with
+// the given common name and SANs, which default to the common name, and it is
+// authorized with the one-time token of a provisioner allowed in the
+// enrollment configuration. The certificates are marked as having a
+// server-side generated key in the audit log.
+func (a *Authority) Enroll(ctx context.Context, token, commonName string, sans []string, opts provisioner.SignOptions) (crypto.Signer, []*x509.Certificate, error) {
+	cfg := a.config.Enrollment
+	if !cfg.IsEnabled() {
+		return nil, nil, errs.NotFound("enrollment is not enabled")
+	}
+
+	ctx = provisioner.NewContextWithMethod(ctx, provisioner.SignMethod)
+	signOpts, err := a.Authorize(ctx, token)
+	if err != nil {
+		return nil, nil, errs.UnauthorizedErr(err)
+	}
+	var prov provisioner.Interface
+	for _, op := range signOpts {
+		if p, ok := op.(provisioner.Interface); ok {
+			prov = p
+			break
+		}
+	}
+	if prov == nil || !cfg.IsProvisionerAllowed(prov.GetName()) {
+		return nil, nil, errs.Forbidden("provisioner is not allowed to enroll")
+	}
+
+	kty, crv, size := cfg.GetKeyParams()
+	signer, err := keyutil.GenerateSigner(kty, crv, size)
+	if err != nil {
+		return nil, nil, errs.Wrap(http.StatusInternalServerError, err, "authority.Enroll; error generating key")
+	}
+	if len(sans) == 0 {
+		sans = []string{commonName}
+	}
+	csr, err := x509util.CreateCertificateRequest(commonName, sans, signer)
+	if err != nil {
+		return nil, nil, errs.Wrap(http.StatusInternalServerError, err, "authority.Enroll; error creating certificate request")
+	}
+
+	chain, err := a.SignWithContext(newContextWithServerSideKey(ctx), csr, opts, signOpts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signer, chain, nil
+}
@@ -0,0 +1,107 @@
+package authority
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/audit"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/errs"
+	"go.step.sm/crypto/jose"
+)
+
+func TestAuthority_Enroll(t *testing.T) {
+	newAuthority := func(t *testing.T, cfg *config.EnrollmentConfig) *Authority {
+		a := testAuthority(t, WithDatabase(&db.MockAuthDB{
+			MUseToken: func(id, tok string) (bool, error) { return true, nil },
+			MShutdown: func() error { return nil },
+		}), func(a *Authority) error {
+			a.config.Enrollment = cfg
+			a.config.Audit = &config.AuditConfig{Enabled: true}
+			return nil
+		})
+		t.Cleanup(func() { a.Shutdown() })
+		return a
+	}
+
+	key, err := jose.ReadKey("testdata/secrets/step_cli_key_priv.jwk", jose.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+	newToken := func(t *testing.T) string {
+		token, err := generateToken("smallstep test", "step-cli", testAudiences.Sign[0], []string{"test.smallstep.com"}, time.Now(), key)
+		assert.FatalError(t, err)
+		return token
+	}
+	assertStatus := func(t *testing.T, statusCode int, err error) {
+		t.Helper()
+		var sc *errs.Error
+		if assert.True(t, errors.As(err, &sc)) {
+			assert.Equals(t, statusCode, sc.StatusCode())
+		}
+	}
+	ctx := context.Background()
+	sans := []string{"test.smallstep.com"}
+
+	t.Run("ok", func(t *testing.T) {
+		a := newAuthority(t, &config.EnrollmentConfig{Enabled: true})
+		signer, chain, err := a.Enroll(ctx, newToken(t), "smallstep test", sans, provisioner.SignOptions{})
+		assert.FatalError(t, err)
+		_, ok := signer.(*ecdsa.PrivateKey)
+		assert.True(t, ok)
+		assert.Equals(t, signer.Public(), chain[0].PublicKey)
+		assert.Equals(t, "smallstep test", chain[0].Subject.CommonName)
+		assert.Equals(t, sans, chain[0].DNSNames)
+
+		// The key is marked as generated by the CA in the audit log.
+		e, err := a.ExportAuditLog(0)
+		assert.FatalError(t, err)
+		assert.Len(t, 1, e.Records)
+		assert.Equals(t, audit.X509SignType, e.Records[0].Type)
+		var data x509AuditData
+		assert.FatalError(t, json.Unmarshal(e.Records[0].Data, &data))
+		assert.Equals(t, chain[0].SerialNumber.String(), data.SerialNumber)
+		assert.True(t, data.ServerSideKey)
+	})
+
+	t.Run("ok/rsa", func(t *testing.T) {
+		a := newAuthority(t, &config.EnrollmentConfig{Enabled: true, KeyType: "RSA", Provisioners: []string{"step-cli"}})
+		signer, _, err := a.Enroll(ctx, newToken(t), "smallstep test", sans, provisioner.SignOptions{})
+		assert.FatalError(t, err)
+		k, ok := signer.(*rsa.PrivateKey)
+		if assert.True(t, ok) {
+			assert.Equals(t, 2048, k.N.BitLen())
+		}
+	})
+
+	t.Run("fail/disabled", func(t *testing.T) {
+		a := newAuthority(t, nil)
+		_, _, err := a.Enroll(ctx, newToken(t), "smallstep test", sans, provisioner.SignOptions{})
+		assertStatus(t, http.StatusNotFound, err)
+	})
+
+	t.Run("fail/provisioner", func(t *testing.T) {
+		a := newAuthority(t, &config.EnrollmentConfig{Enabled: true, Provisioners: []string{"other"}})
+		_, _, err := a.Enroll(ctx, newToken(t), "smallstep test", sans, provisioner.SignOptions{})
+		assertStatus(t, http.StatusForbidden, err)
+	})
+
+	t.Run("fail/token", func(t *testing.T) {
+		a := newAuthority(t, &config.EnrollmentConfig{Enabled: true})
+		_, _, err := a.Enroll(ctx, "not-a-token", "smallstep test", sans, provisioner.SignOptions{})
+		assertStatus(t, http.StatusUnauthorized, err)
+	})
+
+	t.Run("fail/names", func(t *testing.T) {
+		a := newAuthority(t, &config.EnrollmentConfig{Enabled: true})
+		_, _, err := a.Enroll(ctx, newToken(t), "smallstep test", []string{"other.smallstep.com"}, provisioner.SignOptions{})
+		assertStatus(t, http.StatusForbidden, err)
+	})
+}
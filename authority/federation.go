@@ -0,0 +1,243 @@
+package authority
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/pemutil"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+)
+
+// federationTimeout is the timeout used to download the roots of a peer.
+var federationTimeout = 10 * time.Second
+
+// federatedPeer is a peer authority and its root certificates.
+type federatedPeer struct {
+	*config.FederatedPeer
+	roots []*x509.Certificate
+	pool  *x509.CertPool
+}
+
+type intermediatesKey struct{}
+
+// NewIntermediatesContext adds to the context the intermediate certificates
+// sent by a client with its certificate. They are used to verify the
+// certificates issued by federated peers.
+func NewIntermediatesContext(ctx context.Context, intermediates []*x509.Certificate) context.Context {
+	return context.WithValue(ctx, intermediatesKey{}, intermediates)
+}
+
+// IntermediatesFromContext returns the intermediate certificates in the
+// context.
+func IntermediatesFromContext(ctx context.Context) ([]*x509.Certificate, bool) {
+	intermediates, ok := ctx.Value(intermediatesKey{}).([]*x509.Certificate)
+	return intermediates, ok
+}
+
+// initFederation loads the roots of the federated peers and adds them to the
+// federation bundle. A peer that cannot be reached does not prevent the
+// authority from starting, its roots will be downloaded in the next reload.
+func (a *Authority) initFederation() error {
+	if a.config.Federation == nil {
+		return nil
+	}
+
+	a.federationPeers = nil
+	for _, cfg := range a.config.Federation.Peers {
+		peer := &federatedPeer{
+			FederatedPeer: cfg,
+			pool:          x509.NewCertPool(),
+		}
+		for _, path := range cfg.Roots {
+			crts, err := pemutil.ReadCertificateBundle(path)
+			if err != nil {
+				return errors.Wrapf(err, "error reading roots of federated peer %s", cfg.Name)
+			}
+			peer.roots = append(peer.roots, crts...)
+		}
+		if cfg.URL != "" {
+			crts, err := fetchPeerRoots(cfg.URL, cfg.Fingerprint)
+			if err != nil {
+				a.initLogf("Failed to download the roots of federated peer %s: %v", cfg.Name, err)
+			}
+			peer.roots = appendNewCertificates(peer.roots, crts)
+		}
+		for _, crt := range peer.roots {
+			peer.pool.AddCert(crt)
+			sum := sha256.Sum256(crt.Raw)
+			a.certificates.Store(hex.EncodeToString(sum[:]), crt)
+		}
+		a.federationPeers = append(a.federationPeers, peer)
+	}
+	return nil
+}
+
+// GetFederatedRenewalRoots returns the root certificates of the federated
+// peers that can renew their certificates with this authority. These roots
+// must be trusted when verifying the mTLS client certificates.
+func (a *Authority) GetFederatedRenewalRoots() []*x509.Certificate {
+	var roots []*x509.Certificate
+	for _, p := range a.federationPeers {
+		if p.AcceptRenewals {
+			roots = append(roots, p.roots...)
+		}
+	}
+	return roots
+}
+
+// getRenewalPeer returns the federated peer that issued the given
+// certificate, if the peer accepts renewals. It returns nil if the
+// certificate has been issued by this authority.
+func (a *Authority) getRenewalPeer(ctx context.Context, cert *x509.Certificate) *federatedPeer {
+	var peers []*federatedPeer
+	for _, p := range a.federationPeers {
+		if p.AcceptRenewals {
+			peers = append(peers, p)
+		}
+	}
+	if len(peers) == 0 {
+		return nil
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, crt := range a.intermediateX509Certs {
+		intermediates.AddCert(crt)
+	}
+	if crts, ok := IntermediatesFromContext(ctx); ok {
+		for _, crt := range crts {
+			intermediates.AddCert(crt)
+		}
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         a.rootX509CertPool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := cert.Verify(opts); err == nil {
+		return nil
+	}
+	for _, p := range peers {
+		opts.Roots = p.pool
+		if _, err := cert.Verify(opts); err == nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// loadRenewalPeerProvisioner returns the provisioner used to renew the
+// certificates issued by the given peer.
+func (a *Authority) loadRenewalPeerProvisioner(peer *federatedPeer) (provisioner.Interface, error) {
+	p, err := a.LoadProvisionerByName(peer.Provisioner)
+	if err != nil {
+		return nil, errs.Unauthorized("authority.authorizeRenew: provisioner %s of federated peer %s not found", peer.Provisioner, peer.Name)
+	}
+	return p, nil
+}
+
+// fetchPeerRoots downloads the roots of a step-ca authority. The root with
+// the given fingerprint is downloaded first, and then it is used to verify
+// the connection used to get all the roots.
+func fetchPeerRoots(rawurl, fingerprint string) ([]*x509.Certificate, error) {
+	rawurl = strings.TrimSuffix(rawurl, "/")
+
+	// The root is verified with the fingerprint.
+	insecureClient := &http.Client{
+		Timeout: federationTimeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{
+				MinVersion:         tls.VersionTLS12,
+				InsecureSkipVerify: true, //nolint:gosec // the root is verified with the fingerprint
+			},
+		},
+	}
+	var rootResp struct {
+		RootPEM string `json:"ca"`
+	}
+	if err := getJSON(insecureClient, rawurl+"/root/"+fingerprint, &rootResp); err != nil {
+		return nil, err
+	}
+	root, err := pemutil.ParseCertificate([]byte(rootResp.RootPEM))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing root")
+	}
+	if sum := sha256.Sum256(root.Raw); !strings.EqualFold(hex.EncodeToString(sum[:]), fingerprint) {
+		return nil, errors.New("root fingerprint does not match")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(root)
+	client := &http.Client{
+		Timeout: federationTimeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				RootCAs:    pool,
+			},
+		},
+	}
+	var rootsResp struct {
+		Certificates []string `json:"crts"`
+	}
+	if err := getJSON(client, rawurl+"/roots", &rootsResp); err != nil {
+		return nil, err
+	}
+	roots := []*x509.Certificate{root}
+	for _, s := range rootsResp.Certificates {
+		crt, err := pemutil.ParseCertificate([]byte(s))
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing roots")
+		}
+		roots = appendNewCertificates(roots, []*x509.Certificate{crt})
+	}
+	return roots, nil
+}
+
+func getJSON(client *http.Client, rawurl string, v any) error {
+	resp, err := client.Get(rawurl)
+	if err != nil {
+		return errors.Wrapf(err, "error requesting %s", rawurl)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error requesting %s: server responded with %d", rawurl, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.Wrapf(err, "error decoding %s", rawurl)
+	}
+	return nil
+}
+
+// appendNewCertificates appends to the list the certificates that are not in
+// it.
+func appendNewCertificates(list, crts []*x509.Certificate) []*x509.Certificate {
+	for _, crt := range crts {
+		var found bool
+		for _, c := range list {
+			if bytes.Equal(c.Raw, crt.Raw) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, crt)
+		}
+	}
+	return list
+}
@@ -0,0 +1,236 @@
+package authority
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+)
+
+func mustPeerServer(t *testing.T, peer *minica.CA, roots ...*x509.Certificate) *httptest.Server {
+	t.Helper()
+
+	signer, err := keyutil.GenerateDefaultSigner()
+	require.NoError(t, err)
+	crt, err := peer.Sign(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		PublicKey:   signer.Public(),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	require.NoError(t, err)
+
+	encode := func(crt *x509.Certificate) string {
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw}))
+	}
+	sum := sha256.Sum256(peer.Root.Raw)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/root/"+hex.EncodeToString(sum[:]), func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"ca": encode(peer.Root)})
+	})
+	mux.HandleFunc("/roots", func(w http.ResponseWriter, r *http.Request) {
+		crts := []string{encode(peer.Root)}
+		for _, crt := range roots {
+			crts = append(crts, encode(crt))
+		}
+		_ = json.NewEncoder(w).Encode(map[string][]string{"crts": crts})
+	})
+
+	srv := httptest.NewUnstartedServer(mux)
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{crt.Raw, peer.Intermediate.Raw},
+			PrivateKey:  signer,
+		}},
+		MinVersion: tls.VersionTLS12,
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func Test_fetchPeerRoots(t *testing.T) {
+	peer, err := minica.New()
+	require.NoError(t, err)
+	other, err := minica.New()
+	require.NoError(t, err)
+	srv := mustPeerServer(t, peer, other.Root)
+
+	sum := sha256.Sum256(peer.Root.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	roots, err := fetchPeerRoots(srv.URL+"/", fingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, []*x509.Certificate{peer.Root, other.Root}, roots)
+
+	sum = sha256.Sum256(other.Root.Raw)
+	_, err = fetchPeerRoots(srv.URL, hex.EncodeToString(sum[:]))
+	assert.ErrorContains(t, err, "server responded with 404")
+}
+
+func TestAuthority_initFederation(t *testing.T) {
+	ca, err := minica.New()
+	require.NoError(t, err)
+	peer, err := minica.New()
+	require.NoError(t, err)
+	remote, err := minica.New()
+	require.NoError(t, err)
+	srv := mustPeerServer(t, remote)
+
+	rootFile := filepath.Join(t.TempDir(), "peer.crt")
+	require.NoError(t, os.WriteFile(rootFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: peer.Root.Raw}), 0600))
+	sum := sha256.Sum256(remote.Root.Raw)
+
+	a, err := NewEmbedded(
+		WithConfig(&Config{Federation: &config.FederationConfig{
+			Peers: []*config.FederatedPeer{
+				{Name: "peer", Roots: []string{rootFile}, AcceptRenewals: true, Provisioner: "federated"},
+				{Name: "remote", URL: srv.URL, Fingerprint: hex.EncodeToString(sum[:])},
+			},
+		}}),
+		WithX509RootCerts(ca.Root),
+		WithX509Signer(ca.Intermediate, ca.Signer),
+		WithQuietInit(),
+	)
+	require.NoError(t, err)
+
+	federation, err := a.GetFederation()
+	require.NoError(t, err)
+	assert.Len(t, federation, 3)
+	assert.Contains(t, federation, ca.Root)
+	assert.Contains(t, federation, peer.Root)
+	assert.Contains(t, federation, remote.Root)
+	assert.Equal(t, []*x509.Certificate{peer.Root}, a.GetFederatedRenewalRoots())
+
+	// Missing bundles are an error.
+	_, err = NewEmbedded(
+		WithConfig(&Config{Federation: &config.FederationConfig{
+			Peers: []*config.FederatedPeer{
+				{Name: "peer", Roots: []string{filepath.Join(t.TempDir(), "missing.crt")}},
+			},
+		}}),
+		WithX509RootCerts(ca.Root),
+		WithX509Signer(ca.Intermediate, ca.Signer),
+	)
+	assert.ErrorContains(t, err, "error reading roots of federated peer peer")
+}
+
+func TestAuthority_RenewContext_federated(t *testing.T) {
+	ca, err := minica.New()
+	require.NoError(t, err)
+	peer, err := minica.New()
+	require.NoError(t, err)
+	jwk, err := jose.ReadKey("testdata/secrets/max_pub.jwk")
+	require.NoError(t, err)
+
+	rootFile := filepath.Join(t.TempDir(), "peer.crt")
+	require.NoError(t, os.WriteFile(rootFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: peer.Root.Raw}), 0600))
+
+	// The provisioner of the peer certificate exists but it does not allow
+	// renewals.
+	disableRenewal := true
+	ext, err := (&provisioner.Extension{Type: provisioner.TypeJWK, Name: "Max", CredentialID: "peer-kid"}).ToExtension()
+	require.NoError(t, err)
+	signer, err := keyutil.GenerateDefaultSigner()
+	require.NoError(t, err)
+	leaf, err := peer.Sign(&x509.Certificate{
+		SerialNumber:    big.NewInt(1234),
+		Subject:         pkix.Name{CommonName: "leaf.example.com"},
+		DNSNames:        []string{"leaf.example.com"},
+		PublicKey:       signer.Public(),
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		OCSPServer:      []string{"https://peer.example.com/ocsp"},
+		ExtraExtensions: []pkix.Extension{ext},
+	})
+	require.NoError(t, err)
+
+	newAuthority := func(t *testing.T, acceptRenewals bool) *Authority {
+		t.Helper()
+		a, err := NewEmbedded(
+			WithConfig(&Config{
+				AuthorityConfig: &AuthConfig{
+					Provisioners: provisioner.List{
+						&provisioner.JWK{Name: "Max", Type: "JWK", Key: jwk, Claims: &provisioner.Claims{DisableRenewal: &disableRenewal}},
+						&provisioner.JWK{Name: "federated", Type: "JWK", Key: jwk},
+					},
+				},
+				AIA: &config.AIAConfig{OCSP: []string{"https://ca.example.com/ocsp"}},
+				Federation: &config.FederationConfig{
+					Peers: []*config.FederatedPeer{
+						{Name: "peer", Roots: []string{rootFile}, AcceptRenewals: acceptRenewals, Provisioner: "federated"},
+					},
+				},
+			}),
+			WithDatabase(&db.MockAuthDB{
+				MIsRevoked: func(sn string) (bool, error) { return false, nil },
+				MStoreCertificate: func(crt *x509.Certificate) error {
+					return nil
+				},
+			}),
+			WithX509RootCerts(ca.Root),
+			WithX509Signer(ca.Intermediate, ca.Signer),
+		)
+		require.NoError(t, err)
+		return a
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		a := newAuthority(t, true)
+		ctx := NewIntermediatesContext(context.Background(), []*x509.Certificate{peer.Intermediate})
+		chain, err := a.RenewContext(ctx, leaf, nil)
+		require.NoError(t, err)
+		require.Len(t, chain, 2)
+
+		crt := chain[0]
+		assert.Equal(t, leaf.DNSNames, crt.DNSNames)
+		assert.Equal(t, leaf.NotAfter.Sub(leaf.NotBefore), crt.NotAfter.Sub(crt.NotBefore))
+		assert.Equal(t, []string{"https://ca.example.com/ocsp"}, crt.OCSPServer)
+		require.NoError(t, crt.CheckSignatureFrom(ca.Intermediate))
+
+		e, ok := provisioner.GetProvisionerExtension(crt)
+		require.True(t, ok)
+		assert.Equal(t, "federated", e.Name)
+		assert.Equal(t, provisioner.TypeJWK, e.Type)
+		assert.Equal(t, "", e.CredentialID)
+
+		// The renewed certificate is now a local certificate.
+		assert.Nil(t, a.getRenewalPeer(context.Background(), crt))
+	})
+
+	t.Run("fail/missing intermediate", func(t *testing.T) {
+		a := newAuthority(t, true)
+		_, err := a.RenewContext(context.Background(), leaf, nil)
+		assert.ErrorContains(t, err, "renew is disabled")
+	})
+
+	t.Run("fail/renewals not accepted", func(t *testing.T) {
+		a := newAuthority(t, false)
+		ctx := NewIntermediatesContext(context.Background(), []*x509.Certificate{peer.Intermediate})
+		_, err := a.RenewContext(ctx, leaf, nil)
+		assert.ErrorContains(t, err, "renew is disabled")
+	})
+}
@@ -0,0 +1,153 @@
+package authority
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// TokenIntrospection is the result of the introspection of a provisioning
+// token. It is meant to debug the tokens generated by new integrations, the
+// introspection does not mark the token as used.
+type TokenIntrospection struct {
+	Valid       bool                            `json:"valid"`
+	Header      TokenHeader                     `json:"header"`
+	Claims      map[string]interface{}          `json:"claims"`
+	Provisioner *TokenProvisioner               `json:"provisioner,omitempty"`
+	Checks      []*TokenCheck                   `json:"checks"`
+	Authorized  *provisioner.SignOptionsSummary `json:"authorized,omitempty"`
+}
+
+// TokenHeader contains the relevant header parameters of a token.
+type TokenHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+	X5C       bool   `json:"x5c,omitempty"`
+}
+
+// TokenProvisioner identifies the provisioner that accepts a token.
+type TokenProvisioner struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TokenCheck is the result of one of the validations of a token.
+type TokenCheck struct {
+	Name  string `json:"name"`
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// IntrospectToken parses a provisioning token and reports the provisioner
+// that would accept it for signing an X.509 certificate, the validations that
+// fail, and the names that it authorizes. It only returns an error if the
+// token cannot be parsed.
+func (a *Authority) IntrospectToken(ctx context.Context, token string) (*TokenIntrospection, error) {
+	tok, err := jose.ParseSigned(token)
+	if err != nil {
+		return nil, admin.WrapError(admin.ErrorBadRequestType, err, "error parsing token")
+	}
+	var claims Claims
+	if err := tok.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return nil, admin.WrapError(admin.ErrorBadRequestType, err, "error parsing token claims")
+	}
+	var rawClaims map[string]interface{}
+	if err := tok.UnsafeClaimsWithoutVerification(&rawClaims); err != nil {
+		return nil, admin.WrapError(admin.ErrorBadRequestType, err, "error parsing token claims")
+	}
+
+	ti := &TokenIntrospection{
+		Claims: rawClaims,
+		Checks: []*TokenCheck{},
+	}
+	if len(tok.Headers) > 0 {
+		h := tok.Headers[0]
+		ti.Header = TokenHeader{
+			Algorithm: h.Algorithm,
+			KeyID:     h.KeyID,
+			X5C:       h.ExtraHeaders["x5c"] != nil,
+		}
+	}
+	check := func(name string, err error) bool {
+		c := &TokenCheck{Name: name, Valid: err == nil}
+		if err != nil {
+			c.Error = err.Error()
+		}
+		ti.Checks = append(ti.Checks, c)
+		return c.Valid
+	}
+
+	// This method also validates the audiences for JWK provisioners.
+	p, ok := a.provisioners.LoadByToken(tok, &claims.Claims)
+	if !check("provisioner", boolErr(ok, "no provisioner matches the issuer %q and the audience %q", claims.Issuer, strings.Join(claims.Audience, ", "))) {
+		return ti, nil
+	}
+	ti.Provisioner = &TokenProvisioner{
+		ID:   p.GetID(),
+		Name: p.GetName(),
+		Type: p.GetType().String(),
+	}
+
+	now := time.Now()
+	valid := true
+	valid = check("exp", boolErr(claims.Expiry == nil || now.Before(claims.Expiry.Time().Add(time.Minute)),
+		"token expired at %s", formatNumericDate(claims.Expiry))) && valid
+	valid = check("nbf", boolErr(claims.NotBefore == nil || !now.Add(time.Minute).Before(claims.NotBefore.Time()),
+		"token is not valid before %s", formatNumericDate(claims.NotBefore))) && valid
+	if a.config.AuthorityConfig != nil && !a.config.AuthorityConfig.DisableIssuedAtCheck {
+		valid = check("iat", boolErr(claims.IssuedAt == nil || !claims.IssuedAt.Time().Before(a.startTime),
+			"token issued before the bootstrap of certificate authority")) && valid
+	}
+	if used, ok := a.isTokenUsed(ctx, token, p); ok {
+		valid = check("reuse", boolErr(!used, "token already used")) && valid
+	}
+
+	// The provisioner validates the signature and the rest of the claims.
+	signOpts, err := p.AuthorizeSign(provisioner.NewContextWithMethod(ctx, provisioner.SignMethod), token)
+	if check("authorizeSign", err) {
+		ti.Authorized = provisioner.SummarizeSignOptions(signOpts)
+	} else {
+		valid = false
+	}
+
+	ti.Valid = valid
+	return ti, nil
+}
+
+// isTokenUsed returns if the token has been already used, the second value is
+// false if this cannot be checked without using the token.
+func (a *Authority) isTokenUsed(ctx context.Context, token string, p provisioner.Interface) (bool, bool) {
+	if a.cache == nil {
+		return false, false
+	}
+	reuseKey, err := tokenReuseKey(token, p)
+	if err != nil {
+		return false, false
+	}
+	_, used, err := a.cache.Get(ctx, "ott:"+reuseKey)
+	if err != nil {
+		return false, false
+	}
+	return used, true
+}
+
+func boolErr(ok bool, format string, args ...interface{}) error {
+	if ok {
+		return nil
+	}
+	return fmt.Errorf(format, args...)
+}
+
+func formatNumericDate(d *jose.NumericDate) string {
+	if d == nil {
+		return ""
+	}
+	return d.Time().UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,110 @@
+package authority
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/cache"
+)
+
+func TestAuthority_IntrospectToken(t *testing.T) {
+	a := testAuthority(t, WithCache(cache.NewMemory()))
+
+	jwk, err := jose.ReadKey("testdata/secrets/step_cli_key_priv.jwk", jose.WithPassword([]byte("pass")))
+	require.NoError(t, err)
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jwk.Key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", jwk.KeyID))
+	require.NoError(t, err)
+
+	now := time.Now()
+	token := func(iss string, nbf, exp time.Time, id string) string {
+		t.Helper()
+		raw, err := jose.Signed(sig).Claims(jose.Claims{
+			Subject:   "test.smallstep.com",
+			Issuer:    iss,
+			NotBefore: jose.NewNumericDate(nbf),
+			Expiry:    jose.NewNumericDate(exp),
+			Audience:  []string{"https://example.com/sign"},
+			ID:        id,
+		}).CompactSerialize()
+		require.NoError(t, err)
+		return raw
+	}
+	checks := func(ti *TokenIntrospection) map[string]string {
+		m := make(map[string]string)
+		for _, c := range ti.Checks {
+			if c.Valid {
+				m[c.Name] = "ok"
+			} else {
+				m[c.Name] = c.Error
+			}
+		}
+		return m
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		tok := token("step-cli", now, now.Add(5*time.Minute), "introspect-ok")
+		for i := 0; i < 2; i++ {
+			ti, err := a.IntrospectToken(context.Background(), tok)
+			require.NoError(t, err)
+			assert.True(t, ti.Valid)
+			assert.Equal(t, TokenHeader{Algorithm: "ES256", KeyID: jwk.KeyID}, ti.Header)
+			assert.Equal(t, "test.smallstep.com", ti.Claims["sub"])
+			assert.Equal(t, &TokenProvisioner{ID: "step-cli:" + jwk.KeyID, Name: "step-cli", Type: "JWK"}, ti.Provisioner)
+			assert.Equal(t, map[string]string{
+				"provisioner": "ok", "exp": "ok", "nbf": "ok", "iat": "ok", "reuse": "ok", "authorizeSign": "ok",
+			}, checks(ti))
+			assert.Equal(t, []string{"test.smallstep.com"}, ti.Authorized.CommonNames)
+			assert.Equal(t, []string{"test.smallstep.com"}, ti.Authorized.SANs)
+			assert.Equal(t, &provisioner.Duration{Duration: 24 * time.Hour}, ti.Authorized.MaxDuration)
+		}
+	})
+
+	t.Run("fail/used", func(t *testing.T) {
+		tok := token("step-cli", now, now.Add(5*time.Minute), "introspect-used")
+		p, err := a.LoadProvisionerByName("step-cli")
+		require.NoError(t, err)
+		require.NoError(t, a.UseToken(tok, p))
+
+		ti, err := a.IntrospectToken(context.Background(), tok)
+		require.NoError(t, err)
+		assert.False(t, ti.Valid)
+		assert.Equal(t, "token already used", checks(ti)["reuse"])
+		assert.NotNil(t, ti.Authorized)
+	})
+
+	t.Run("fail/expired", func(t *testing.T) {
+		exp := now.Add(-10 * time.Minute)
+		tok := token("step-cli", now.Add(-time.Hour), exp, "introspect-expired")
+		ti, err := a.IntrospectToken(context.Background(), tok)
+		require.NoError(t, err)
+		assert.False(t, ti.Valid)
+		c := checks(ti)
+		assert.Equal(t, "token expired at "+exp.UTC().Format(time.RFC3339), c["exp"])
+		assert.Equal(t, "ok", c["nbf"])
+		assert.Contains(t, c["authorizeSign"], "token is expired")
+		assert.Nil(t, ti.Authorized)
+	})
+
+	t.Run("fail/provisioner", func(t *testing.T) {
+		tok := token("foo", now, now.Add(5*time.Minute), "introspect-provisioner")
+		ti, err := a.IntrospectToken(context.Background(), tok)
+		require.NoError(t, err)
+		assert.False(t, ti.Valid)
+		assert.Nil(t, ti.Provisioner)
+		assert.Equal(t, map[string]string{
+			"provisioner": `no provisioner matches the issuer "foo" and the audience "https://example.com/sign"`,
+		}, checks(ti))
+	})
+
+	t.Run("fail/parse", func(t *testing.T) {
+		_, err := a.IntrospectToken(context.Background(), "foo")
+		assert.Error(t, err)
+	})
+}
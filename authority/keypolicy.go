@@ -0,0 +1,166 @@
+package authority
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ocsp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/smallstep/certificates/authority/keypolicy"
+	"github.com/smallstep/certificates/db"
+)
+
+// initKeyPolicy creates the policy applied to the public keys of the X.509
+// and SSH certificates. The keys of the roots, the intermediates and the SSH
+// certificate authorities can be rejected by the policy. The keys blocked after
+// a key compromise revocation are loaded from the database.
+func (a *Authority) initKeyPolicy() error {
+	var caKeys []crypto.PublicKey
+	for _, crt := range a.rootX509Certs {
+		caKeys = append(caKeys, crt.PublicKey)
+	}
+	for _, crt := range a.intermediateX509Certs {
+		caKeys = append(caKeys, crt.PublicKey)
+	}
+	for _, s := range []ssh.Signer{a.sshCAHostCertSignKey, a.sshCAUserCertSignKey} {
+		if s == nil {
+			continue
+		}
+		if k, ok := s.PublicKey().(ssh.CryptoPublicKey); ok {
+			caKeys = append(caKeys, k.CryptoPublicKey())
+		}
+	}
+
+	o := a.config.AuthorityConfig.KeyPolicy
+	p, err := keypolicy.New(o, caKeys...)
+	if err != nil {
+		return err
+	}
+	if o != nil && o.BlockCompromisedKeys {
+		if bdb, ok := a.db.(db.BlockedKeyDB); ok {
+			keys, err := bdb.GetBlockedKeys()
+			if err != nil {
+				return errors.Wrap(err, "error loading blocked keys")
+			}
+			for _, k := range keys {
+				p.Block(k.Fingerprint)
+			}
+		}
+	}
+	a.keyPolicy = p
+	return nil
+}
+
+// blockCompromisedKey blocks the key of a certificate revoked with the
+// keyCompromise reason if the key policy is configured to do it. The key is
+// stored in the database, if supported, so other instances and restarts will
+// also block it.
+func (a *Authority) blockCompromisedKey(crt *x509.Certificate, rci *db.RevokedCertificateInfo) error {
+	o := a.config.AuthorityConfig.KeyPolicy
+	if o == nil || !o.BlockCompromisedKeys || crt == nil || rci.ReasonCode != ocsp.KeyCompromise {
+		return nil
+	}
+	fp, err := keypolicy.Fingerprint(crt.PublicKey)
+	if err != nil {
+		return err
+	}
+	if bdb, ok := a.db.(db.BlockedKeyDB); ok {
+		if err := bdb.BlockKey(&db.BlockedKeyInfo{
+			Fingerprint: fp,
+			Serial:      rci.Serial,
+			Reason:      rci.Reason,
+			BlockedAt:   time.Now().UTC(),
+		}); err != nil {
+			return err
+		}
+	}
+	a.keyPolicy.Block(fp)
+	return nil
+}
+
+var oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+
+// certificateIdentity returns the identity of a certificate used to detect
+// keys shared by different identities when the requester identity is not
+// known. It is the common name and the sorted SANs of the certificate, or the
+// encoded SAN extension if the template sets it.
+func certificateIdentity(crt *x509.Certificate) string {
+	names := append([]string{}, crt.DNSNames...)
+	names = append(names, crt.EmailAddresses...)
+	for _, ip := range crt.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, u := range crt.URIs {
+		names = append(names, u.String())
+	}
+	for _, ext := range crt.ExtraExtensions {
+		if ext.Id.Equal(oidSubjectAltName) {
+			names = append(names, hex.EncodeToString(ext.Value))
+		}
+	}
+	sort.Strings(names)
+	return crt.Subject.CommonName + "/" + strings.Join(names, ",")
+}
+
+func (a *Authority) getKeyIdentityDB() (db.KeyIdentityDB, bool) {
+	o := a.config.AuthorityConfig.KeyPolicy
+	if o == nil || o.DuplicateKeys == "" {
+		return nil, false
+	}
+	kdb, ok := a.db.(db.KeyIdentityDB)
+	return kdb, ok
+}
+
+// checkDuplicateKey checks if the key of a certificate was first used by a
+// different provisioner or identity. Depending on the key policy the usage is
+// logged or rejected with a *keypolicy.Error.
+func (a *Authority) checkDuplicateKey(provisionerID, identity string, crt *x509.Certificate) error {
+	kdb, ok := a.getKeyIdentityDB()
+	if !ok {
+		return nil
+	}
+	fp, err := keypolicy.Fingerprint(crt.PublicKey)
+	if err != nil {
+		return err
+	}
+	ki, err := kdb.GetKeyIdentity(fp)
+	if err != nil || ki == nil {
+		return err
+	}
+	if ki.ProvisionerID == provisionerID && ki.Identity == identity {
+		return nil
+	}
+	if a.config.AuthorityConfig.KeyPolicy.DuplicateKeys == keypolicy.DuplicateKeysReject {
+		return &keypolicy.Error{Reason: "the key is used by a different identity"}
+	}
+	log.Printf("public key %s requested by %q was first used by %q in the certificate %s", fp, identity, ki.Identity, ki.Serial)
+	return nil
+}
+
+// storeKeyIdentity stores the provisioner and the identity of a certificate if
+// it is the first certificate with its key.
+func (a *Authority) storeKeyIdentity(provisionerID, identity string, crt *x509.Certificate) error {
+	kdb, ok := a.getKeyIdentityDB()
+	if !ok {
+		return nil
+	}
+	fp, err := keypolicy.Fingerprint(crt.PublicKey)
+	if err != nil {
+		return err
+	}
+	return kdb.StoreKeyIdentity(&db.KeyIdentity{
+		Fingerprint:   fp,
+		ProvisionerID: provisionerID,
+		Identity:      identity,
+		Serial:        crt.SerialNumber.String(),
+		CreatedAt:     time.Now().UTC(),
+	})
+}
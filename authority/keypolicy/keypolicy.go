@@ -0,0 +1,274 @@
+// Package keypolicy implements the authority level constraints on the public
+// keys of the certificates signed by the CA.
+package keypolicy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/smallstep/certificates/errs"
+)
+
+// Supported key algorithms.
+const (
+	RSA     = "RSA"
+	ECDSA   = "ECDSA"
+	Ed25519 = "Ed25519"
+)
+
+// Supported actions for the keys used by different identities.
+const (
+	// DuplicateKeysWarn logs the keys used by different identities.
+	DuplicateKeysWarn = "warn"
+	// DuplicateKeysReject rejects the keys used by different identities.
+	DuplicateKeysReject = "reject"
+)
+
+// Options are the constraints applied to the public keys of all the X.509 and
+// SSH certificates signed by the CA.
+type Options struct {
+	// Algorithms is the list of allowed key algorithms: RSA, ECDSA or
+	// Ed25519. All of them are allowed if it is empty.
+	Algorithms []string `json:"algorithms,omitempty"`
+	// MinRSABits is the minimum size of the RSA keys in bits.
+	MinRSABits int `json:"minRSABits,omitempty"`
+	// Curves is the list of allowed ECDSA curves: P-256, P-384 or P-521. All
+	// of them are allowed if it is empty.
+	Curves []string `json:"curves,omitempty"`
+	// RejectCAKeys rejects the keys used by the X.509 and SSH certificate
+	// authorities.
+	RejectCAKeys bool `json:"rejectCAKeys,omitempty"`
+	// RejectROCAKeys rejects the RSA keys with the fingerprint of the keys
+	// generated by the vulnerable Infineon library (CVE-2017-15361).
+	RejectROCAKeys bool `json:"rejectROCAKeys,omitempty"`
+	// DebianWeakKeys is a list of files in the openssl-blacklist format with
+	// the RSA keys generated by the Debian OpenSSL package (CVE-2008-0166).
+	DebianWeakKeys []string `json:"debianWeakKeys,omitempty"`
+	// BlockedKeys is a file with the blocked keys, one per line, encoded as
+	// the hex SHA-256 fingerprint of the DER SubjectPublicKeyInfo.
+	BlockedKeys string `json:"blockedKeys,omitempty"`
+	// BlockCompromisedKeys blocks the keys of the certificates revoked with
+	// the keyCompromise reason.
+	BlockCompromisedKeys bool `json:"blockCompromisedKeys,omitempty"`
+	// DuplicateKeys is the action taken when the key of an X.509 certificate
+	// was already used by a different provisioner or identity: "warn" or
+	// "reject". Keys are not tracked if it is empty.
+	DuplicateKeys string `json:"duplicateKeys,omitempty"`
+}
+
+// Validate validates the key policy options.
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	for _, alg := range o.Algorithms {
+		switch alg {
+		case RSA, ECDSA, Ed25519:
+		default:
+			return errors.Errorf("keyPolicy.algorithms contains an unsupported algorithm %q", alg)
+		}
+	}
+	if o.MinRSABits < 0 {
+		return errors.New("keyPolicy.minRSABits cannot be negative")
+	}
+	for _, crv := range o.Curves {
+		switch crv {
+		case "P-256", "P-384", "P-521":
+		default:
+			return errors.Errorf("keyPolicy.curves contains an unsupported curve %q", crv)
+		}
+	}
+	for _, fn := range o.DebianWeakKeys {
+		if fn == "" {
+			return errors.New("keyPolicy.debianWeakKeys cannot contain empty paths")
+		}
+	}
+	switch o.DuplicateKeys {
+	case "", DuplicateKeysWarn, DuplicateKeysReject:
+	default:
+		return errors.Errorf("keyPolicy.duplicateKeys %q is not supported, use %q or %q", o.DuplicateKeys, DuplicateKeysWarn, DuplicateKeysReject)
+	}
+	return nil
+}
+
+// Error is the error returned when a key does not satisfy the policy.
+type Error struct {
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return "public key not allowed: " + e.Reason
+}
+
+// ErrorCode implements the errs.Coder interface.
+func (e *Error) ErrorCode() errs.Code {
+	return errs.CodeBadPublicKey
+}
+
+func newError(format string, args ...interface{}) error {
+	return &Error{Reason: fmt.Sprintf(format, args...)}
+}
+
+type equaler interface {
+	Equal(crypto.PublicKey) bool
+}
+
+// Policy validates the public keys of the certificates signed by the CA. A nil
+// policy allows all keys.
+type Policy struct {
+	algorithms map[string]bool
+	minRSABits int
+	curves     map[string]bool
+	caKeys     []crypto.PublicKey
+	rejectROCA bool
+	debianKeys map[string]struct{}
+	mu         sync.RWMutex
+	blocked    map[string]struct{}
+}
+
+// New creates a policy with the given options. The CA keys are only used if
+// the options reject them. The Debian weak keys and the blocked keys are read
+// from the files in the options.
+func New(o *Options, caKeys ...crypto.PublicKey) (*Policy, error) {
+	if o == nil {
+		return nil, nil //nolint:nilnil // a nil policy allows all keys
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	p := &Policy{
+		minRSABits: o.MinRSABits,
+		rejectROCA: o.RejectROCAKeys,
+		blocked:    make(map[string]struct{}),
+	}
+	if len(o.Algorithms) > 0 {
+		p.algorithms = make(map[string]bool, len(o.Algorithms))
+		for _, alg := range o.Algorithms {
+			p.algorithms[alg] = true
+		}
+	}
+	if len(o.Curves) > 0 {
+		p.curves = make(map[string]bool, len(o.Curves))
+		for _, crv := range o.Curves {
+			p.curves[crv] = true
+		}
+	}
+	if o.RejectCAKeys {
+		for _, k := range caKeys {
+			if k != nil {
+				p.caKeys = append(p.caKeys, k)
+			}
+		}
+	}
+	if len(o.DebianWeakKeys) > 0 {
+		p.debianKeys = make(map[string]struct{})
+		for _, fn := range o.DebianWeakKeys {
+			if err := readDebianWeakKeys(fn, p.debianKeys); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if o.BlockedKeys != "" {
+		if err := readBlockedKeys(o.BlockedKeys, p.blocked); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Validate returns an error if the given public key is not allowed.
+func (p *Policy) Validate(pub crypto.PublicKey) error {
+	if p == nil {
+		return nil
+	}
+
+	var alg string
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		alg = RSA
+	case *ecdsa.PublicKey:
+		alg = ECDSA
+	case ed25519.PublicKey:
+		alg = Ed25519
+	default:
+		return newError("unsupported key type %T", pub)
+	}
+	if p.algorithms != nil && !p.algorithms[alg] {
+		return newError("algorithm %s is not allowed", alg)
+	}
+
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if bits := k.N.BitLen(); bits < p.minRSABits {
+			return newError("RSA keys must be at least %d bits, got %d", p.minRSABits, bits)
+		}
+	case *ecdsa.PublicKey:
+		if crv := k.Curve.Params().Name; p.curves != nil && !p.curves[crv] {
+			return newError("curve %s is not allowed", crv)
+		}
+	}
+
+	for _, k := range p.caKeys {
+		if e, ok := k.(equaler); ok && e.Equal(pub) {
+			return newError("the key is used by the certificate authority")
+		}
+	}
+
+	if k, ok := pub.(*rsa.PublicKey); ok {
+		if p.rejectROCA && isROCA(k.N) {
+			return newError("the key has the ROCA fingerprint (CVE-2017-15361)")
+		}
+		if p.debianKeys != nil {
+			if _, ok := p.debianKeys[debianFingerprint(k.N)]; ok {
+				return newError("the key is a known Debian weak key (CVE-2008-0166)")
+			}
+		}
+	}
+
+	fp, err := Fingerprint(pub)
+	if err != nil {
+		return newError("the key cannot be encoded: %v", err)
+	}
+	p.mu.RLock()
+	_, blocked := p.blocked[fp]
+	p.mu.RUnlock()
+	if blocked {
+		return newError("the key is blocked")
+	}
+	return nil
+}
+
+// Block adds the key with the given fingerprint to the blocked keys of the
+// policy. It can be called while the policy is in use.
+func (p *Policy) Block(fingerprint string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.blocked[strings.ToLower(fingerprint)] = struct{}{}
+	p.mu.Unlock()
+}
+
+// ValidateSSH returns an error if the given SSH public key is not allowed.
+func (p *Policy) ValidateSSH(key ssh.PublicKey) error {
+	if p == nil {
+		return nil
+	}
+	if key == nil {
+		return newError("missing key")
+	}
+	k, ok := key.(ssh.CryptoPublicKey)
+	if !ok {
+		return newError("unsupported key type %s", key.Type())
+	}
+	return p.Validate(k.CryptoPublicKey())
+}
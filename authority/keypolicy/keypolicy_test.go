@@ -0,0 +1,193 @@
+package keypolicy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func mustECKey(t *testing.T, c elliptic.Curve) *ecdsa.PrivateKey {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(c, rand.Reader)
+	require.NoError(t, err)
+	return k
+}
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *Options
+		wantErr string
+	}{
+		{"ok/nil", nil, ""},
+		{"ok", &Options{Algorithms: []string{"RSA", "ECDSA", "Ed25519"}, MinRSABits: 2048, Curves: []string{"P-256", "P-384", "P-521"}, RejectCAKeys: true}, ""},
+		{"fail/algorithm", &Options{Algorithms: []string{"ECDSA", "DSA"}}, `keyPolicy.algorithms contains an unsupported algorithm "DSA"`},
+		{"fail/minRSABits", &Options{MinRSABits: -1}, "keyPolicy.minRSABits cannot be negative"},
+		{"ok/duplicateKeys", &Options{DuplicateKeys: "reject"}, ""},
+		{"fail/duplicateKeys", &Options{DuplicateKeys: "block"}, `keyPolicy.duplicateKeys "block" is not supported, use "warn" or "reject"`},
+		{"fail/debianWeakKeys", &Options{DebianWeakKeys: []string{""}}, "keyPolicy.debianWeakKeys cannot contain empty paths"},
+		{"fail/curve", &Options{Curves: []string{"P-224"}}, `keyPolicy.curves contains an unsupported curve "P-224"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	p256 := mustECKey(t, elliptic.P256())
+	p384 := mustECKey(t, elliptic.P384())
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	caKey := mustECKey(t, elliptic.P256())
+
+	tests := []struct {
+		name    string
+		options *Options
+		pub     crypto.PublicKey
+		wantErr string
+	}{
+		{"ok/nil", nil, &rsaKey.PublicKey, ""},
+		{"ok/empty", &Options{}, &rsaKey.PublicKey, ""},
+		{"ok/rsa", &Options{MinRSABits: 1024}, &rsaKey.PublicKey, ""},
+		{"ok/ecdsa", &Options{Algorithms: []string{"ECDSA"}, Curves: []string{"P-384"}}, &p384.PublicKey, ""},
+		{"ok/ed25519", &Options{Algorithms: []string{"Ed25519"}}, edPub, ""},
+		{"ok/ca-key-allowed", &Options{}, &caKey.PublicKey, ""},
+		{"fail/rsa-bits", &Options{MinRSABits: 2048}, &rsaKey.PublicKey, "public key not allowed: RSA keys must be at least 2048 bits, got 1024"},
+		{"fail/algorithm", &Options{Algorithms: []string{"ECDSA"}, MinRSABits: 2048}, &rsaKey.PublicKey, "public key not allowed: algorithm RSA is not allowed"},
+		{"fail/curve", &Options{Curves: []string{"P-384"}}, &p256.PublicKey, "public key not allowed: curve P-256 is not allowed"},
+		{"fail/ca-key", &Options{RejectCAKeys: true}, &caKey.PublicKey, "public key not allowed: the key is used by the certificate authority"},
+		{"fail/type", &Options{}, "foo", "public key not allowed: unsupported key type string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.options, nil, &caKey.PublicKey)
+			require.NoError(t, err)
+			err = p.Validate(tt.pub)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				var kpErr *Error
+				assert.ErrorAs(t, err, &kpErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	_, err = New(&Options{Curves: []string{"P-224"}})
+	assert.Error(t, err)
+}
+
+func TestPolicy_ValidateSSH(t *testing.T) {
+	p256 := mustECKey(t, elliptic.P256())
+	p384 := mustECKey(t, elliptic.P384())
+	sshP256, err := ssh.NewPublicKey(&p256.PublicKey)
+	require.NoError(t, err)
+	sshP384, err := ssh.NewPublicKey(&p384.PublicKey)
+	require.NoError(t, err)
+
+	var nilPolicy *Policy
+	assert.NoError(t, nilPolicy.ValidateSSH(sshP256))
+
+	p, err := New(&Options{Curves: []string{"P-256"}, RejectCAKeys: true}, &p384.PublicKey)
+	require.NoError(t, err)
+	assert.NoError(t, p.ValidateSSH(sshP256))
+	assert.EqualError(t, p.ValidateSSH(sshP384), "public key not allowed: curve P-384 is not allowed")
+	assert.EqualError(t, p.ValidateSSH(nil), "public key not allowed: missing key")
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	fn := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(fn, []byte(content), 0600))
+	return fn
+}
+
+func TestPolicy_Validate_weakKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	blockedKey := mustECKey(t, elliptic.P256())
+	blockedFP, err := Fingerprint(&blockedKey.PublicKey)
+	require.NoError(t, err)
+
+	// A modulus congruent to a power of 65537 modulo the primorial has the
+	// ROCA fingerprint.
+	m := big.NewInt(2)
+	for _, p := range rocaPrimes {
+		m.Mul(m, big.NewInt(p))
+	}
+	rocaN := new(big.Int).Exp(big.NewInt(65537), big.NewInt(1234), m)
+	rocaN.Add(rocaN, new(big.Int).Lsh(m, 1024))
+	rocaKey := &rsa.PublicKey{N: rocaN, E: 65537}
+
+	debianFile := writeFile(t, "# comment\n\n"+debianFingerprint(weakKey.N)+"\n")
+	blockedFile := writeFile(t, "# comment\n"+blockedFP+"\n")
+
+	tests := []struct {
+		name    string
+		options *Options
+		pub     crypto.PublicKey
+		wantErr string
+	}{
+		{"ok/roca-allowed", &Options{}, rocaKey, ""},
+		{"ok/rsa", &Options{RejectROCAKeys: true, DebianWeakKeys: []string{debianFile}, BlockedKeys: blockedFile}, &rsaKey.PublicKey, ""},
+		{"fail/roca", &Options{RejectROCAKeys: true}, rocaKey, "public key not allowed: the key has the ROCA fingerprint (CVE-2017-15361)"},
+		{"fail/debian", &Options{DebianWeakKeys: []string{debianFile}}, &weakKey.PublicKey, "public key not allowed: the key is a known Debian weak key (CVE-2008-0166)"},
+		{"fail/blocked", &Options{BlockedKeys: blockedFile}, &blockedKey.PublicKey, "public key not allowed: the key is blocked"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.options)
+			require.NoError(t, err)
+			err = p.Validate(tt.pub)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	_, err = New(&Options{DebianWeakKeys: []string{writeFile(t, "foo\n")}})
+	assert.ErrorContains(t, err, `line 1: invalid fingerprint "foo"`)
+	_, err = New(&Options{BlockedKeys: writeFile(t, "# comment\n"+blockedFP[:10]+"\n")})
+	assert.ErrorContains(t, err, "line 2: invalid fingerprint")
+	_, err = New(&Options{BlockedKeys: filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+}
+
+func TestPolicy_Block(t *testing.T) {
+	key := mustECKey(t, elliptic.P256())
+	fp, err := Fingerprint(&key.PublicKey)
+	require.NoError(t, err)
+
+	var nilPolicy *Policy
+	nilPolicy.Block(fp)
+
+	p, err := New(&Options{})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate(&key.PublicKey))
+	p.Block(fp)
+	assert.EqualError(t, p.Validate(&key.PublicKey), "public key not allowed: the key is blocked")
+}
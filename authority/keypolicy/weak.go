@@ -0,0 +1,126 @@
+package keypolicy
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/sha1" //nolint:gosec // used to match the openssl-blacklist format
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Fingerprint returns the hex encoded SHA-256 of the DER SubjectPublicKeyInfo
+// of the given key. It is the format used for the blocked keys.
+func Fingerprint(pub crypto.PublicKey) (string, error) {
+	b, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// rocaPrimes are the small primes used to detect the ROCA fingerprint. The
+// primes of the vulnerable keys have the form k*M + (65537^a mod M), where M
+// is a primorial, so for every prime dividing M the modulus is in the
+// subgroup generated by 65537.
+var rocaPrimes = []int64{
+	3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71,
+	73, 79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131, 137, 139, 149,
+	151, 157, 163, 167,
+}
+
+// rocaSubgroups contains, for each of the rocaPrimes, the residues of the
+// subgroup generated by 65537.
+var rocaSubgroups = func() [][]bool {
+	groups := make([][]bool, len(rocaPrimes))
+	for i, p := range rocaPrimes {
+		groups[i] = make([]bool, p)
+		g := 65537 % p
+		for r := int64(1); !groups[i][r]; r = (r * g) % p {
+			groups[i][r] = true
+		}
+	}
+	return groups
+}()
+
+// isROCA returns true if the given RSA modulus has the fingerprint of the
+// keys generated by the Infineon RSA library (CVE-2017-15361).
+func isROCA(n *big.Int) bool {
+	m := new(big.Int)
+	for i, p := range rocaPrimes {
+		if !rocaSubgroups[i][m.Mod(n, big.NewInt(p)).Int64()] {
+			return false
+		}
+	}
+	return true
+}
+
+// debianFingerprint returns the fingerprint of an RSA modulus used in the
+// openssl-blacklist files, the last 80 bits of the SHA-1 of the output of
+// "openssl rsa -noout -modulus".
+func debianFingerprint(n *big.Int) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("Modulus=%X\n", n))) //nolint:gosec // see above
+	return hex.EncodeToString(sum[:])[20:]
+}
+
+// readDebianWeakKeys reads a file in the openssl-blacklist format, where each
+// line contains the last 20 hex characters of a key fingerprint.
+func readDebianWeakKeys(fn string, keys map[string]struct{}) error {
+	return readLines(fn, func(line string) error {
+		if len(line) == 40 {
+			line = line[20:]
+		}
+		if len(line) != 20 || !isHex(line) {
+			return errors.Errorf("invalid fingerprint %q", line)
+		}
+		keys[line] = struct{}{}
+		return nil
+	})
+}
+
+// readBlockedKeys reads a file with a SHA-256 key fingerprint per line.
+func readBlockedKeys(fn string, keys map[string]struct{}) error {
+	return readLines(fn, func(line string) error {
+		if len(line) != 64 || !isHex(line) {
+			return errors.Errorf("invalid fingerprint %q", line)
+		}
+		keys[line] = struct{}{}
+		return nil
+	})
+}
+
+// readLines calls fn with the lowercase content of each line in the file,
+// skipping empty lines and comments.
+func readLines(fn string, parse func(string) error) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return errors.Wrapf(err, "error opening %s", fn)
+	}
+	defer f.Close()
+
+	var n int
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		n++
+		line := strings.ToLower(strings.TrimSpace(sc.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := parse(line); err != nil {
+			return errors.Wrapf(err, "error parsing %s line %d", fn, n)
+		}
+	}
+	return errors.Wrapf(sc.Err(), "error reading %s", fn)
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
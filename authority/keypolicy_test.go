@@ -0,0 +1,231 @@
+package authority
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/pemutil"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/keypolicy"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/errs"
+)
+
+func TestAuthority_Sign_keyPolicy(t *testing.T) {
+	caPEM, err := os.ReadFile("testdata/certs/root_ca.crt")
+	assert.FatalError(t, err)
+	crt, err := pemutil.ReadCertificate("testdata/certs/intermediate_ca.crt")
+	assert.FatalError(t, err)
+	caKey, err := pemutil.Read("testdata/secrets/intermediate_ca_key", pemutil.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+
+	a, err := NewEmbedded(WithConfig(&config.Config{
+		AuthorityConfig: &config.AuthConfig{
+			KeyPolicy: &keypolicy.Options{
+				Curves:       []string{"P-256"},
+				RejectCAKeys: true,
+			},
+		},
+	}), WithX509RootBundle(caPEM), WithX509Signer(crt, caKey.(crypto.Signer)))
+	assert.FatalError(t, err)
+
+	newCSR := func(key crypto.Signer) *x509.CertificateRequest {
+		cr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			DNSNames: []string{"foo.bar.zar"},
+		}, key)
+		assert.FatalError(t, err)
+		csr, err := x509.ParseCertificateRequest(cr)
+		assert.FatalError(t, err)
+		return csr
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name    string
+		key     crypto.Signer
+		wantErr string
+	}{
+		{"ok", p256, ""},
+		{"fail/curve", p384, "The request was forbidden by the certificate authority: public key not allowed: curve P-384 is not allowed."},
+		{"fail/ca-key", caKey.(crypto.Signer), "The request was forbidden by the certificate authority: public key not allowed: the key is used by the certificate authority."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := a.Sign(newCSR(tt.key), provisioner.SignOptions{})
+			if tt.wantErr == "" {
+				assert.FatalError(t, err)
+				assert.Equals(t, []string{"foo.bar.zar"}, chain[0].DNSNames)
+				return
+			}
+			if assert.NotNil(t, err) {
+				var e *errs.Error
+				assert.Fatal(t, errors.As(err, &e), "error is not an *errs.Error")
+				assert.Equals(t, tt.wantErr, e.Message())
+				assert.Equals(t, http.StatusForbidden, e.StatusCode())
+			}
+		})
+	}
+}
+
+type blockedKeyDB struct {
+	*db.MockAuthDB
+	keys []*db.BlockedKeyInfo
+}
+
+func (m *blockedKeyDB) BlockKey(info *db.BlockedKeyInfo) error {
+	m.keys = append(m.keys, info)
+	return nil
+}
+
+func (m *blockedKeyDB) GetBlockedKeys() ([]*db.BlockedKeyInfo, error) {
+	return m.keys, nil
+}
+
+func TestAuthority_blockCompromisedKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	crt := &x509.Certificate{PublicKey: &key.PublicKey}
+	fp, err := keypolicy.Fingerprint(&key.PublicKey)
+	assert.FatalError(t, err)
+
+	mdb := &blockedKeyDB{MockAuthDB: &db.MockAuthDB{}}
+	a := &Authority{
+		config: &config.Config{
+			AuthorityConfig: &config.AuthConfig{
+				KeyPolicy: &keypolicy.Options{BlockCompromisedKeys: true},
+			},
+		},
+		db: mdb,
+	}
+	assert.FatalError(t, a.initKeyPolicy())
+
+	// Only key compromise revocations block the key.
+	assert.FatalError(t, a.blockCompromisedKey(crt, &db.RevokedCertificateInfo{Serial: "1", ReasonCode: ocsp.Superseded}))
+	assert.Len(t, 0, mdb.keys)
+	assert.FatalError(t, a.keyPolicy.Validate(&key.PublicKey))
+
+	assert.FatalError(t, a.blockCompromisedKey(crt, &db.RevokedCertificateInfo{Serial: "2", ReasonCode: ocsp.KeyCompromise, Reason: "leaked"}))
+	assert.Len(t, 1, mdb.keys)
+	assert.Equals(t, fp, mdb.keys[0].Fingerprint)
+	assert.Equals(t, "2", mdb.keys[0].Serial)
+	assert.Equals(t, "leaked", mdb.keys[0].Reason)
+	assert.Equals(t, "public key not allowed: the key is blocked", a.keyPolicy.Validate(&key.PublicKey).Error())
+
+	// The blocked keys are loaded on init.
+	assert.FatalError(t, a.initKeyPolicy())
+	assert.Equals(t, "public key not allowed: the key is blocked", a.keyPolicy.Validate(&key.PublicKey).Error())
+}
+
+type keyIdentityDB struct {
+	*db.MockAuthDB
+	identities map[string]*db.KeyIdentity
+}
+
+func (m *keyIdentityDB) GetKeyIdentity(fingerprint string) (*db.KeyIdentity, error) {
+	return m.identities[fingerprint], nil
+}
+
+func (m *keyIdentityDB) StoreKeyIdentity(ki *db.KeyIdentity) error {
+	if _, ok := m.identities[ki.Fingerprint]; !ok {
+		m.identities[ki.Fingerprint] = ki
+	}
+	return nil
+}
+
+func TestAuthority_Sign_duplicateKeys(t *testing.T) {
+	caPEM, err := os.ReadFile("testdata/certs/root_ca.crt")
+	assert.FatalError(t, err)
+	crt, err := pemutil.ReadCertificate("testdata/certs/intermediate_ca.crt")
+	assert.FatalError(t, err)
+	caKey, err := pemutil.Read("testdata/secrets/intermediate_ca_key", pemutil.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+
+	newAuthority := func(t *testing.T, action string) (*Authority, *keyIdentityDB) {
+		mdb := &keyIdentityDB{MockAuthDB: &db.MockAuthDB{}, identities: map[string]*db.KeyIdentity{}}
+		a, err := NewEmbedded(WithConfig(&config.Config{
+			AuthorityConfig: &config.AuthConfig{
+				KeyPolicy: &keypolicy.Options{DuplicateKeys: action},
+			},
+		}), WithX509RootBundle(caPEM), WithX509Signer(crt, caKey.(crypto.Signer)), WithDatabase(mdb))
+		assert.FatalError(t, err)
+		return a, mdb
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	newCSR := func(names ...string) *x509.CertificateRequest {
+		cr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			DNSNames: names,
+		}, key)
+		assert.FatalError(t, err)
+		csr, err := x509.ParseCertificateRequest(cr)
+		assert.FatalError(t, err)
+		return csr
+	}
+	fp, err := keypolicy.Fingerprint(&key.PublicKey)
+	assert.FatalError(t, err)
+
+	t.Run("reject", func(t *testing.T) {
+		a, mdb := newAuthority(t, "reject")
+		chain, err := a.Sign(newCSR("foo.bar.zar"), provisioner.SignOptions{})
+		assert.FatalError(t, err)
+		if assert.NotNil(t, mdb.identities[fp]) {
+			// Without a template the SANs are in the encoded extension.
+			assert.Equals(t, "/300d820b666f6f2e6261722e7a6172", mdb.identities[fp].Identity)
+			assert.Equals(t, chain[0].SerialNumber.String(), mdb.identities[fp].Serial)
+		}
+
+		// The same identity can reuse the key.
+		_, err = a.Sign(newCSR("foo.bar.zar"), provisioner.SignOptions{})
+		assert.FatalError(t, err)
+
+		// A different identity cannot.
+		_, err = a.Sign(newCSR("foo.bar.zar"), provisioner.SignOptions{}, provisioner.RequesterIdentity("acme/account/1234"))
+		if assert.NotNil(t, err) {
+			var e *errs.Error
+			assert.Fatal(t, errors.As(err, &e), "error is not an *errs.Error")
+			assert.Equals(t, "The request was forbidden by the certificate authority: public key not allowed: the key is used by a different identity.", e.Message())
+			assert.Equals(t, http.StatusForbidden, e.StatusCode())
+		}
+		_, err = a.Sign(newCSR("other.bar.zar"), provisioner.SignOptions{})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		a, mdb := newAuthority(t, "warn")
+		_, err := a.Sign(newCSR("foo.bar.zar"), provisioner.SignOptions{}, provisioner.RequesterIdentity("acme/account/1234"))
+		assert.FatalError(t, err)
+		_, err = a.Sign(newCSR("foo.bar.zar"), provisioner.SignOptions{}, provisioner.RequesterIdentity("acme/account/5678"))
+		assert.FatalError(t, err)
+		assert.Equals(t, "acme/account/1234", mdb.identities[fp].Identity)
+	})
+}
+
+func Test_certificateIdentity(t *testing.T) {
+	u, err := url.Parse("spiffe://example.org/foo")
+	assert.FatalError(t, err)
+	assert.Equals(t, "foo/1.1.1.1,b.example.org,foo@example.org,spiffe://example.org/foo,z.example.org", certificateIdentity(&x509.Certificate{
+		Subject:        pkix.Name{CommonName: "foo"},
+		DNSNames:       []string{"z.example.org", "b.example.org"},
+		EmailAddresses: []string{"foo@example.org"},
+		IPAddresses:    []net.IP{net.ParseIP("1.1.1.1")},
+		URIs:           []*url.URL{u},
+	}))
+	assert.Equals(t, "/", certificateIdentity(&x509.Certificate{}))
+}
@@ -0,0 +1,104 @@
+package authority
+
+import (
+	"crypto"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	kmsapi "go.step.sm/crypto/kms/apiv1"
+
+	"github.com/smallstep/certificates/authority/config"
+	casapi "github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/errs"
+)
+
+// kmsSigner is the crypto.Signer used with intermediate keys kept in a cloud
+// KMS or an HSM. Signatures that take longer than the timeout or fail are
+// retried, and if they keep failing the signer returns an UnavailableError so
+// the request fails with a 503 instead of a 500.
+type kmsSigner struct {
+	crypto.Signer
+	timeout    time.Duration
+	retries    int
+	retryAfter time.Duration
+}
+
+func newKMSSigner(signer crypto.Signer, c *config.KMSSignerConfig) *kmsSigner {
+	return &kmsSigner{
+		Signer:     signer,
+		timeout:    c.GetTimeout(),
+		retries:    c.GetRetries(),
+		retryAfter: c.GetRetryAfter(),
+	}
+}
+
+// Sign implements the crypto.Signer interface.
+func (s *kmsSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var err error
+	for i := 0; i <= s.retries; i++ {
+		var sig []byte
+		if sig, err = s.sign(rand, digest, opts); err == nil {
+			return sig, nil
+		}
+	}
+	return nil, &casapi.UnavailableError{
+		Message:    "error signing with the intermediate key",
+		RetryAfter: s.retryAfter,
+		Err:        err,
+	}
+}
+
+func (s *kmsSigner) sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	type result struct {
+		sig []byte
+		err error
+	}
+	// The channel is buffered so a signature that times out does not block
+	// the goroutine forever.
+	ch := make(chan result, 1)
+	go func() {
+		sig, err := s.Signer.Sign(rand, digest, opts)
+		ch <- result{sig, err}
+	}()
+
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		return r.sig, r.err
+	case <-timer.C:
+		return nil, errors.Errorf("signature timed out after %s", s.timeout)
+	}
+}
+
+// isRemoteKMS returns true if the keys of the KMS are kept in a cloud KMS or
+// an HSM, and the signatures can be slow or fail temporarily.
+func isRemoteKMS(opts *kmsapi.Options) bool {
+	if opts == nil {
+		return false
+	}
+	typ, err := opts.GetType()
+	if err != nil {
+		return false
+	}
+	switch kmsapi.Type(strings.ToLower(string(typ))) {
+	case kmsapi.DefaultKMS, kmsapi.SoftKMS:
+		return false
+	default:
+		return true
+	}
+}
+
+// signErrorStatus returns the HTTP status and the code of an error creating a
+// certificate, a 503 with the signerUnavailable code if the signing key is
+// temporarily unavailable, and a 500 otherwise.
+func signErrorStatus(err error) (int, errs.Option) {
+	var ue *casapi.UnavailableError
+	if errors.As(err, &ue) {
+		return http.StatusServiceUnavailable, errs.WithCode(errs.CodeSignerUnavailable)
+	}
+	return http.StatusInternalServerError, errs.WithCode(errs.CodeServerInternal)
+}
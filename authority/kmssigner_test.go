@@ -0,0 +1,126 @@
+package authority
+
+import (
+	"crypto"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/keyutil"
+	kmsapi "go.step.sm/crypto/kms/apiv1"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	casapi "github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/errs"
+)
+
+type flakySigner struct {
+	crypto.Signer
+	sign func() error
+}
+
+func (s *flakySigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if err := s.sign(); err != nil {
+		return nil, err
+	}
+	return s.Signer.Sign(rand, digest, opts)
+}
+
+func Test_kmsSigner_Sign(t *testing.T) {
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+	digest := make([]byte, 32)
+
+	retries := 2
+	cfg := &config.KMSSignerConfig{
+		Timeout:    &provisioner.Duration{Duration: 50 * time.Millisecond},
+		Retries:    &retries,
+		RetryAfter: &provisioner.Duration{Duration: time.Minute},
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		s := newKMSSigner(signer, cfg)
+		assert.Equals(t, signer.Public(), s.Public())
+		_, err := s.Sign(rand.Reader, digest, crypto.SHA256)
+		assert.NoError(t, err)
+	})
+
+	t.Run("ok/retry", func(t *testing.T) {
+		var calls int
+		s := newKMSSigner(&flakySigner{Signer: signer, sign: func() error {
+			if calls++; calls < 3 {
+				return errors.New("connection reset")
+			}
+			return nil
+		}}, cfg)
+		_, err := s.Sign(rand.Reader, digest, crypto.SHA256)
+		assert.NoError(t, err)
+		assert.Equals(t, 3, calls)
+	})
+
+	t.Run("fail/error", func(t *testing.T) {
+		var calls int
+		s := newKMSSigner(&flakySigner{Signer: signer, sign: func() error {
+			calls++
+			return errors.New("connection reset")
+		}}, cfg)
+		_, err := s.Sign(rand.Reader, digest, crypto.SHA256)
+		var ue *casapi.UnavailableError
+		if assert.True(t, errors.As(err, &ue)) {
+			assert.Equals(t, "error signing with the intermediate key: connection reset", ue.Error())
+			assert.Equals(t, time.Minute, ue.RetryAfter)
+		}
+		assert.Equals(t, 3, calls)
+	})
+
+	t.Run("fail/timeout", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+		s := newKMSSigner(&flakySigner{Signer: signer, sign: func() error {
+			<-block
+			return nil
+		}}, &config.KMSSignerConfig{Timeout: cfg.Timeout})
+		_, err := s.Sign(rand.Reader, digest, crypto.SHA256)
+		var ue *casapi.UnavailableError
+		if assert.True(t, errors.As(err, &ue)) {
+			assert.Equals(t, "error signing with the intermediate key: signature timed out after 50ms", ue.Error())
+			assert.Equals(t, config.DefaultKMSSignerRetryAfter, ue.RetryAfter)
+		}
+	})
+}
+
+func Test_isRemoteKMS(t *testing.T) {
+	assert.False(t, isRemoteKMS(nil))
+	assert.False(t, isRemoteKMS(&kmsapi.Options{}))
+	assert.False(t, isRemoteKMS(&kmsapi.Options{Type: "softkms"}))
+	assert.True(t, isRemoteKMS(&kmsapi.Options{Type: "awskms"}))
+	assert.True(t, isRemoteKMS(&kmsapi.Options{Type: "CloudKMS"}))
+	assert.True(t, isRemoteKMS(&kmsapi.Options{URI: "pkcs11:module-path=/usr/lib/softhsm/libsofthsm2.so"}))
+}
+
+func Test_signErrorStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   errs.Code
+	}{
+		{"unavailable", &casapi.UnavailableError{Err: errors.New("timeout")}, http.StatusServiceUnavailable, errs.CodeSignerUnavailable},
+		{"wrapped", fmt.Errorf("error creating certificate: %w", &casapi.UnavailableError{}), http.StatusServiceUnavailable, errs.CodeSignerUnavailable},
+		{"other", errors.New("force"), http.StatusInternalServerError, errs.CodeServerInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code := signErrorStatus(tt.err)
+			assert.Equals(t, tt.wantStatus, status)
+			err := errs.StatusCodeError(status, tt.err, code)
+			assert.Equals(t, tt.wantCode, errs.CodeOf(err))
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package authority
+
+import (
+	"log"
+	"os"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/randutil"
+
+	"github.com/smallstep/certificates/authority/leader"
+)
+
+// initLeaderElection starts the election of the instance that runs the
+// background jobs. By default, the lease is kept in the database shared by all
+// the instances, a different lock can be set using WithLeaderLock.
+func (a *Authority) initLeaderElection() error {
+	if !a.config.LeaderElection.IsEnabled() {
+		return nil
+	}
+
+	if a.leaderLock == nil {
+		ndb, ok := nosqlDB(a.db)
+		if !ok {
+			return errors.New("leader election requires a database")
+		}
+		lock, err := leader.NewNoSQLLock(ndb)
+		if err != nil {
+			return err
+		}
+		a.leaderLock = lock
+	}
+
+	id := a.config.LeaderElection.ID
+	if id == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return errors.Wrap(err, "error getting hostname")
+		}
+		suffix, err := randutil.Alphanumeric(8)
+		if err != nil {
+			return errors.Wrap(err, "error generating leader election id")
+		}
+		id = hostname + "-" + suffix
+	}
+
+	var err error
+	a.leaderElector, err = leader.NewElector(a.leaderLock, id, a.config.LeaderElection.LeaseTTL(),
+		leader.WithOnElected(a.onElected))
+	if err != nil {
+		return err
+	}
+	a.initLogf("Leader election is enabled, this instance id is %s", id)
+	a.leaderElector.Start()
+	return nil
+}
+
+// stopLeaderElection stops renewing the lease and releases it.
+func (a *Authority) stopLeaderElection() {
+	if a.leaderElector != nil {
+		a.leaderElector.Stop()
+	}
+}
+
+// onElected runs the jobs that might have been missed while there was no
+// leader.
+func (a *Authority) onElected() {
+	log.Printf("Instance %s is now the leader", a.leaderElector.ID())
+	// Always create a new CRL in case the previous leader has been down and
+	// the time to next expected CRL update is less than the cache duration.
+	if a.config.CRL.IsEnabled() {
+		if err := a.GenerateCertificateRevocationList(); err != nil {
+			log.Printf("error generating the CRL: %v", err)
+		}
+	}
+}
+
+// IsLeader returns true if this instance runs the background jobs. It is
+// always true if the leader election is not enabled.
+func (a *Authority) IsLeader() bool {
+	return a.leaderElector == nil || a.leaderElector.IsLeader()
+}
@@ -0,0 +1,155 @@
+// Package leader implements the election of a single leader between multiple
+// instances of the CA sharing the same database. The leader is the only
+// instance that runs the background jobs, like the CRL generation, so they
+// are not duplicated or racing across replicas.
+package leader
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Lock is the interface used to hold the leadership lease.
+type Lock interface {
+	// Acquire acquires or renews the lease for the given holder. It returns
+	// true if the holder owns the lease for the given ttl.
+	Acquire(ctx context.Context, holder string, ttl time.Duration) (bool, error)
+	// Release releases the lease if it is owned by the given holder.
+	Release(ctx context.Context, holder string) error
+}
+
+// Elector keeps trying to acquire or renew the leadership lease, and reports
+// if this instance is the current leader.
+type Elector struct {
+	lock      Lock
+	id        string
+	ttl       time.Duration
+	onElected func()
+
+	mu        sync.RWMutex
+	expiresAt time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Option is the type used to configure an Elector.
+type Option func(e *Elector)
+
+// WithOnElected sets a function that will be called every time this instance
+// becomes the leader.
+func WithOnElected(fn func()) Option {
+	return func(e *Elector) {
+		e.onElected = fn
+	}
+}
+
+// NewElector creates a new elector for the instance with the given id, using
+// a lease with the given ttl.
+func NewElector(lock Lock, id string, ttl time.Duration, opts ...Option) (*Elector, error) {
+	switch {
+	case lock == nil:
+		return nil, errors.New("leader election lock cannot be nil")
+	case id == "":
+		return nil, errors.New("leader election id cannot be empty")
+	case ttl <= 0:
+		return nil, errors.New("leader election lease duration must be greater than 0")
+	}
+	e := &Elector{
+		lock: lock,
+		id:   id,
+		ttl:  ttl,
+	}
+	for _, fn := range opts {
+		fn(e)
+	}
+	return e, nil
+}
+
+// ID returns the id of this instance.
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// Start tries to acquire the lease once, and then starts a goroutine that
+// renews or tries to acquire it every third of the lease duration.
+func (e *Elector) Start() {
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+	e.renew()
+
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.renew()
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops renewing the lease and releases it, so another instance can take
+// over without waiting for it to expire.
+func (e *Elector) Stop() {
+	if e.stop == nil {
+		return
+	}
+	close(e.stop)
+	<-e.done
+	e.stop = nil
+
+	wasLeader := e.IsLeader()
+	e.setExpiration(time.Time{})
+	if wasLeader {
+		ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+		defer cancel()
+		if err := e.lock.Release(ctx, e.id); err != nil {
+			log.Printf("error releasing leader lease: %v", err)
+		}
+	}
+}
+
+// IsLeader returns true if this instance holds a valid lease.
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return time.Now().Before(e.expiresAt)
+}
+
+func (e *Elector) renew() {
+	wasLeader := e.IsLeader()
+	// The local expiration is set before sending the request, so the lease
+	// always expires here before it expires in the lock.
+	expiresAt := time.Now().Add(e.ttl)
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+	defer cancel()
+	ok, err := e.lock.Acquire(ctx, e.id, e.ttl)
+	if err != nil {
+		log.Printf("error acquiring leader lease: %v", err)
+	}
+	if err != nil || !ok {
+		e.setExpiration(time.Time{})
+		return
+	}
+
+	e.setExpiration(expiresAt)
+	if !wasLeader && e.onElected != nil {
+		e.onElected()
+	}
+}
+
+func (e *Elector) setExpiration(t time.Time) {
+	e.mu.Lock()
+	e.expiresAt = t
+	e.mu.Unlock()
+}
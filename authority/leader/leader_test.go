@@ -0,0 +1,172 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smallstep/nosql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newNoSQLLock(t *testing.T) *NoSQLLock {
+	t.Helper()
+	db, err := nosql.New(nosql.BBoltDriver, filepath.Join(t.TempDir(), "leader.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	l, err := NewNoSQLLock(db)
+	require.NoError(t, err)
+	return l
+}
+
+type mockLock struct {
+	mu       sync.Mutex
+	acquire  func(holder string) (bool, error)
+	released []string
+}
+
+func (m *mockLock) Acquire(_ context.Context, holder string, _ time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.acquire(holder)
+}
+
+func (m *mockLock) Release(_ context.Context, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.released = append(m.released, holder)
+	return nil
+}
+
+func TestNewElector(t *testing.T) {
+	lock := &mockLock{}
+	_, err := NewElector(nil, "id", time.Second)
+	assert.EqualError(t, err, "leader election lock cannot be nil")
+	_, err = NewElector(lock, "", time.Second)
+	assert.EqualError(t, err, "leader election id cannot be empty")
+	_, err = NewElector(lock, "id", 0)
+	assert.EqualError(t, err, "leader election lease duration must be greater than 0")
+
+	e, err := NewElector(lock, "id", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "id", e.ID())
+	assert.False(t, e.IsLeader())
+}
+
+func TestElector(t *testing.T) {
+	var leader atomic.Value
+	leader.Store("a")
+	lock := &mockLock{
+		acquire: func(holder string) (bool, error) {
+			switch v := leader.Load().(string); v {
+			case "error":
+				return false, errors.New("force")
+			default:
+				return v == holder, nil
+			}
+		},
+	}
+
+	var elected int32
+	a, err := NewElector(lock, "a", 30*time.Millisecond, WithOnElected(func() {
+		atomic.AddInt32(&elected, 1)
+	}))
+	require.NoError(t, err)
+	b, err := NewElector(lock, "b", 30*time.Millisecond)
+	require.NoError(t, err)
+
+	a.Start()
+	b.Start()
+	assert.True(t, a.IsLeader())
+	assert.False(t, b.IsLeader())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&elected))
+
+	// Renewals do not elect again.
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, a.IsLeader())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&elected))
+
+	// Errors drop the leadership.
+	leader.Store("error")
+	assert.Eventually(t, func() bool { return !a.IsLeader() }, time.Second, 5*time.Millisecond)
+
+	leader.Store("b")
+	assert.Eventually(t, b.IsLeader, time.Second, 5*time.Millisecond)
+	assert.False(t, a.IsLeader())
+
+	leader.Store("a")
+	assert.Eventually(t, a.IsLeader, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&elected))
+
+	a.Stop()
+	b.Stop()
+	assert.False(t, a.IsLeader())
+	assert.Equal(t, []string{"a"}, lock.released)
+
+	// Stop is idempotent
+	a.Stop()
+}
+
+func TestNoSQLLock(t *testing.T) {
+	ctx := context.Background()
+	l := newNoSQLLock(t)
+
+	ok, err := l.Acquire(ctx, "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Renew
+	ok, err = l.Acquire(ctx, "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = l.Acquire(ctx, "b", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Only the holder can release the lease.
+	require.NoError(t, l.Release(ctx, "b"))
+	ok, err = l.Acquire(ctx, "b", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, l.Release(ctx, "a"))
+	ok, err = l.Acquire(ctx, "b", time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Expired leases can be acquired.
+	time.Sleep(5 * time.Millisecond)
+	ok, err = l.Acquire(ctx, "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	assert.NoError(t, newNoSQLLock(t).Release(ctx, "a"))
+}
+
+func TestNoSQLLock_concurrent(t *testing.T) {
+	l := newNoSQLLock(t)
+
+	var (
+		wg      sync.WaitGroup
+		leaders int32
+	)
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			ok, err := l.Acquire(context.Background(), id, time.Minute)
+			assert.NoError(t, err)
+			if ok {
+				atomic.AddInt32(&leaders, 1)
+			}
+		}(id)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), leaders)
+}
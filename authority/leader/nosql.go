@@ -0,0 +1,100 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/nosql"
+)
+
+var (
+	leaseTable = []byte("leader_lease")
+	leaseKey   = []byte("leader")
+)
+
+type dbLease struct {
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// NoSQLLock is a Lock that stores the lease in a nosql database shared by all
+// the instances. The lease is updated with compare-and-swap operations, so
+// only one instance can hold it at a time. The expiration is compared with
+// the clock of each instance, so the clocks need to be synchronized.
+type NoSQLLock struct {
+	db nosql.DB
+}
+
+// NewNoSQLLock creates a new lock using the given database.
+func NewNoSQLLock(db nosql.DB) (*NoSQLLock, error) {
+	if err := db.CreateTable(leaseTable); err != nil {
+		return nil, errors.Wrapf(err, "error creating table %s", leaseTable)
+	}
+	return &NoSQLLock{db: db}, nil
+}
+
+// Acquire implements the Lock interface. The lease is acquired if it does not
+// exist, if it has expired, or if it is already owned by the holder.
+func (l *NoSQLLock) Acquire(_ context.Context, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	old, err := l.db.Get(leaseTable, leaseKey)
+	switch {
+	case nosql.IsErrNotFound(err):
+		old = nil
+	case err != nil:
+		return false, errors.Wrap(err, "error loading leader lease")
+	default:
+		var lease dbLease
+		if err := json.Unmarshal(old, &lease); err != nil {
+			return false, errors.Wrap(err, "error unmarshaling leader lease")
+		}
+		if lease.Holder != holder && now.Before(lease.ExpiresAt) {
+			return false, nil
+		}
+	}
+
+	b, err := json.Marshal(&dbLease{
+		Holder:    holder,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "error marshaling leader lease")
+	}
+	_, swapped, err := l.db.CmpAndSwap(leaseTable, leaseKey, old, b)
+	if err != nil {
+		return false, errors.Wrap(err, "error storing leader lease")
+	}
+	return swapped, nil
+}
+
+// Release implements the Lock interface.
+func (l *NoSQLLock) Release(_ context.Context, holder string) error {
+	old, err := l.db.Get(leaseTable, leaseKey)
+	switch {
+	case nosql.IsErrNotFound(err):
+		return nil
+	case err != nil:
+		return errors.Wrap(err, "error loading leader lease")
+	}
+	var lease dbLease
+	if err := json.Unmarshal(old, &lease); err != nil {
+		return errors.Wrap(err, "error unmarshaling leader lease")
+	}
+	if lease.Holder != holder {
+		return nil
+	}
+
+	// Expire the lease instead of deleting it, a delete could remove the lease
+	// of a new leader.
+	lease.ExpiresAt = time.Time{}
+	b, err := json.Marshal(&lease)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling leader lease")
+	}
+	if _, _, err := l.db.CmpAndSwap(leaseTable, leaseKey, old, b); err != nil {
+		return errors.Wrap(err, "error storing leader lease")
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package authority
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/config"
+)
+
+type mockLeaderLock struct {
+	holder   string
+	released bool
+}
+
+func (m *mockLeaderLock) Acquire(_ context.Context, holder string, _ time.Duration) (bool, error) {
+	if m.holder == "" {
+		m.holder = holder
+	}
+	return m.holder == holder, nil
+}
+
+func (m *mockLeaderLock) Release(_ context.Context, holder string) error {
+	m.released = m.holder == holder
+	return nil
+}
+
+func TestAuthority_initLeaderElection(t *testing.T) {
+	enable := func(id string) Option {
+		return func(a *Authority) error {
+			a.config.LeaderElection = &config.LeaderElectionConfig{Enabled: true, ID: id}
+			return nil
+		}
+	}
+
+	// Leader election is disabled
+	a := testAuthority(t)
+	assert.Nil(t, a.leaderElector)
+	assert.True(t, a.IsLeader())
+
+	// First instance gets the lease
+	lock := &mockLeaderLock{}
+	a = testAuthority(t, WithLeaderLock(lock), enable("a"))
+	assert.True(t, a.IsLeader())
+	assert.Equals(t, "a", a.leaderElector.ID())
+
+	b := testAuthority(t, WithLeaderLock(lock), enable(""))
+	assert.False(t, b.IsLeader())
+	assert.NotEquals(t, "", b.leaderElector.ID())
+
+	assert.FatalError(t, b.Shutdown())
+	assert.False(t, lock.released)
+	assert.FatalError(t, a.Shutdown())
+	assert.True(t, lock.released)
+	assert.False(t, a.IsLeader())
+
+	// The default lock requires a database
+	c, err := New(testAuthority(t).config, enable("c"))
+	assert.Nil(t, c)
+	assert.Equals(t, "leader election requires a database", err.Error())
+}
@@ -0,0 +1,139 @@
+package authority
+
+import (
+	"time"
+
+	"github.com/smallstep/nosql"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/report"
+	"github.com/smallstep/certificates/db"
+)
+
+// maxLifecycleSuccessors is the maximum number of successors returned in the
+// lifecycle of a certificate.
+const maxLifecycleSuccessors = 1000
+
+// GetCertificateLifecycle returns the current status and the history of the
+// X.509 certificate with the given serial number: its issuance, renewals,
+// revocation and expiration, with the serial numbers of the certificate it
+// renews and the ones that descend from it.
+func (a *Authority) GetCertificateLifecycle(serialNumber string) (*report.Lifecycle, error) {
+	lcdb, ok := a.db.(db.CertificateLifecycleDB)
+	if !ok {
+		return nil, admin.NewError(admin.ErrorNotImplementedType, "database does not support certificate lifecycles")
+	}
+	crt, err := a.db.GetCertificate(serialNumber)
+	if err != nil {
+		if nosql.IsErrNotFound(err) {
+			return nil, admin.NewError(admin.ErrorNotFoundType, "certificate %s not found", serialNumber)
+		}
+		return nil, admin.WrapErrorISE(err, "error loading certificate %s", serialNumber)
+	}
+	lc, err := getCertificateLifecycle(lcdb, serialNumber)
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error loading lifecycle of certificate %s", serialNumber)
+	}
+	rci, err := lcdb.GetRevokedCertificate(serialNumber)
+	if err != nil && !nosql.IsErrNotFound(err) {
+		return nil, admin.WrapErrorISE(err, "error loading revocation of certificate %s", serialNumber)
+	}
+
+	name, typ := a.getCertificateProvisioner(crt)
+	l := &report.Lifecycle{
+		Certificate: report.NewCertificate(crt, name, typ),
+		RenewedFrom: lc.RenewedFrom,
+		Successors:  []string{},
+	}
+
+	// Certificates stored before the lifecycles were tracked don't have the
+	// issuance time.
+	issuedAt := lc.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = crt.NotBefore
+	}
+	l.History = append(l.History, &report.LifecycleEvent{
+		Type:         report.EventIssued,
+		Time:         issuedAt,
+		SerialNumber: lc.RenewedFrom,
+	})
+	for _, r := range lc.RenewedBy {
+		l.History = append(l.History, &report.LifecycleEvent{
+			Type:         report.EventRenewed,
+			Time:         r.RenewedAt,
+			SerialNumber: r.SerialNumber,
+		})
+	}
+	if rci != nil {
+		l.History = append(l.History, &report.LifecycleEvent{
+			Type:       report.EventRevoked,
+			Time:       rci.RevokedAt,
+			ReasonCode: rci.ReasonCode,
+			Reason:     rci.Reason,
+		})
+	}
+	now := time.Now()
+	if !now.Before(crt.NotAfter) {
+		l.History = append(l.History, &report.LifecycleEvent{
+			Type: report.EventExpired,
+			Time: crt.NotAfter,
+		})
+	}
+	l.SortHistory()
+
+	if l.Successors, err = getCertificateSuccessors(lcdb, serialNumber, lc); err != nil {
+		return nil, admin.WrapErrorISE(err, "error loading successors of certificate %s", serialNumber)
+	}
+
+	switch {
+	case rci != nil:
+		l.Status = report.StatusRevoked
+	case !now.Before(crt.NotAfter):
+		l.Status = report.StatusExpired
+	case len(lc.RenewedBy) > 0:
+		l.Status = report.StatusRenewed
+	default:
+		l.Status = report.StatusValid
+	}
+	return l, nil
+}
+
+// getCertificateLifecycle returns the stored lifecycle of a certificate, or an
+// empty one if it was stored before the lifecycles were tracked.
+func getCertificateLifecycle(lcdb db.CertificateLifecycleDB, serialNumber string) (*db.CertificateLifecycle, error) {
+	lc, err := lcdb.GetCertificateLifecycle(serialNumber)
+	if err != nil {
+		if nosql.IsErrNotFound(err) {
+			return &db.CertificateLifecycle{}, nil
+		}
+		return nil, err
+	}
+	return lc, nil
+}
+
+// getCertificateSuccessors walks the renewals of a certificate and returns the
+// serial numbers of all its descendants, the direct renewals first.
+func getCertificateSuccessors(lcdb db.CertificateLifecycleDB, serialNumber string, lc *db.CertificateLifecycle) ([]string, error) {
+	successors := []string{}
+	seen := map[string]bool{serialNumber: true}
+	queue := []*db.CertificateLifecycle{lc}
+	for len(queue) > 0 {
+		lc, queue = queue[0], queue[1:]
+		for _, r := range lc.RenewedBy {
+			if seen[r.SerialNumber] {
+				continue
+			}
+			if len(successors) == maxLifecycleSuccessors {
+				return successors, nil
+			}
+			seen[r.SerialNumber] = true
+			successors = append(successors, r.SerialNumber)
+			next, err := getCertificateLifecycle(lcdb, r.SerialNumber)
+			if err != nil {
+				return nil, err
+			}
+			queue = append(queue, next)
+		}
+	}
+	return successors, nil
+}
@@ -0,0 +1,129 @@
+package authority
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/nosql/database"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/report"
+	"github.com/smallstep/certificates/db"
+)
+
+type lifecycleDB struct {
+	*db.MockAuthDB
+	lifecycles map[string]*db.CertificateLifecycle
+	revoked    map[string]*db.RevokedCertificateInfo
+}
+
+func (d *lifecycleDB) GetCertificateLifecycle(sn string) (*db.CertificateLifecycle, error) {
+	if lc, ok := d.lifecycles[sn]; ok {
+		return lc, nil
+	}
+	return nil, database.ErrNotFound
+}
+
+func (d *lifecycleDB) GetRevokedCertificate(sn string) (*db.RevokedCertificateInfo, error) {
+	if rci, ok := d.revoked[sn]; ok {
+		return rci, nil
+	}
+	return nil, database.ErrNotFound
+}
+
+func TestAuthority_GetCertificateLifecycle(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	certs := map[string]*x509.Certificate{
+		"1": {SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "a"}, NotBefore: now.Add(-72 * time.Hour), NotAfter: now.Add(-time.Hour)},
+		"2": {SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "a"}, NotBefore: now.Add(-48 * time.Hour), NotAfter: now.Add(time.Hour)},
+		"3": {SerialNumber: big.NewInt(3), Subject: pkix.Name{CommonName: "a"}, NotBefore: now.Add(-24 * time.Hour), NotAfter: now.Add(time.Hour)},
+		"4": {SerialNumber: big.NewInt(4), Subject: pkix.Name{CommonName: "a"}, NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)},
+	}
+	ldb := &lifecycleDB{
+		MockAuthDB: &db.MockAuthDB{
+			MGetCertificate: func(sn string) (*x509.Certificate, error) {
+				if crt, ok := certs[sn]; ok {
+					return crt, nil
+				}
+				if sn == "500" {
+					return nil, errors.New("database error")
+				}
+				return nil, database.ErrNotFound
+			},
+			MGetCertificateData: func(sn string) (*db.CertificateData, error) {
+				return &db.CertificateData{Provisioner: &db.ProvisionerData{Name: "jwk", Type: "JWK"}}, nil
+			},
+		},
+		// Certificate 1 has no issuance time because it was stored before
+		// the lifecycles were tracked.
+		lifecycles: map[string]*db.CertificateLifecycle{
+			"1": {RenewedBy: []*db.CertificateRenewal{{SerialNumber: "2", RenewedAt: now.Add(-48 * time.Hour)}}},
+			"2": {IssuedAt: now.Add(-48 * time.Hour), RenewedFrom: "1", RenewedBy: []*db.CertificateRenewal{{SerialNumber: "3", RenewedAt: now.Add(-24 * time.Hour)}}},
+			"3": {IssuedAt: now.Add(-24 * time.Hour), RenewedFrom: "2", RenewedBy: []*db.CertificateRenewal{{SerialNumber: "4", RenewedAt: now.Add(-time.Hour)}}},
+			"4": {IssuedAt: now.Add(-time.Hour), RenewedFrom: "3"},
+		},
+		revoked: map[string]*db.RevokedCertificateInfo{
+			"3": {Serial: "3", ReasonCode: 4, Reason: "superseded", RevokedAt: now.Add(-30 * time.Minute)},
+		},
+	}
+	a := testAuthority(t)
+	a.db = ldb
+
+	// Expired certificate.
+	lc, err := a.GetCertificateLifecycle("1")
+	assert.FatalError(t, err)
+	assert.Equals(t, report.StatusExpired, lc.Status)
+	assert.Equals(t, "jwk", lc.ProvisionerName)
+	assert.Equals(t, "", lc.RenewedFrom)
+	assert.Equals(t, []string{"2", "3", "4"}, lc.Successors)
+	assert.Equals(t, []*report.LifecycleEvent{
+		{Type: report.EventIssued, Time: now.Add(-72 * time.Hour)},
+		{Type: report.EventRenewed, Time: now.Add(-48 * time.Hour), SerialNumber: "2"},
+		{Type: report.EventExpired, Time: now.Add(-time.Hour)},
+	}, lc.History)
+
+	// Renewed certificate.
+	lc, err = a.GetCertificateLifecycle("2")
+	assert.FatalError(t, err)
+	assert.Equals(t, report.StatusRenewed, lc.Status)
+	assert.Equals(t, "1", lc.RenewedFrom)
+	assert.Equals(t, []string{"3", "4"}, lc.Successors)
+
+	// Revoked certificate.
+	lc, err = a.GetCertificateLifecycle("3")
+	assert.FatalError(t, err)
+	assert.Equals(t, report.StatusRevoked, lc.Status)
+	assert.Equals(t, []*report.LifecycleEvent{
+		{Type: report.EventIssued, Time: now.Add(-24 * time.Hour), SerialNumber: "2"},
+		{Type: report.EventRenewed, Time: now.Add(-time.Hour), SerialNumber: "4"},
+		{Type: report.EventRevoked, Time: now.Add(-30 * time.Minute), ReasonCode: 4, Reason: "superseded"},
+	}, lc.History)
+
+	// Valid certificate.
+	lc, err = a.GetCertificateLifecycle("4")
+	assert.FatalError(t, err)
+	assert.Equals(t, report.StatusValid, lc.Status)
+	assert.Equals(t, []string{}, lc.Successors)
+	assert.Len(t, 1, lc.History)
+
+	// Errors.
+	var adminErr *admin.Error
+	_, err = a.GetCertificateLifecycle("5")
+	if assert.True(t, errors.As(err, &adminErr)) {
+		assert.Equals(t, 404, adminErr.StatusCode())
+	}
+	_, err = a.GetCertificateLifecycle("500")
+	if assert.True(t, errors.As(err, &adminErr)) {
+		assert.Equals(t, 500, adminErr.StatusCode())
+	}
+	a.db = ldb.MockAuthDB
+	_, err = a.GetCertificateLifecycle("1")
+	if assert.True(t, errors.As(err, &adminErr)) {
+		assert.Equals(t, 501, adminErr.StatusCode())
+	}
+}
@@ -0,0 +1,39 @@
+package authority
+
+import (
+	"time"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// Meter wraps the set of callbacks used to gather metrics of the X.509 sign
+// flow by provisioner. The provisioner passed to the callbacks is nil if the
+// request could not be associated with any of them.
+type Meter interface {
+	// X509Authorized is called when a sign token has been authorized, with
+	// the time spent in the authorization.
+	X509Authorized(p provisioner.Interface, d time.Duration, err error)
+
+	// X509Rendered is called when the certificate template has been rendered,
+	// with the time spent rendering it.
+	X509Rendered(p provisioner.Interface, d time.Duration, err error)
+
+	// X509Signed is called at the end of the sign flow, with the time spent by
+	// the CAS signing the certificate and the error of the flow, if any.
+	X509Signed(p provisioner.Interface, d time.Duration, err error)
+}
+
+// noopMeter is the Meter used when none is configured.
+type noopMeter struct{}
+
+func (noopMeter) X509Authorized(provisioner.Interface, time.Duration, error) {}
+func (noopMeter) X509Rendered(provisioner.Interface, time.Duration, error)   {}
+func (noopMeter) X509Signed(provisioner.Interface, time.Duration, error)     {}
+
+// getMeter returns the configured meter or a no-op one.
+func (a *Authority) getMeter() Meter {
+	if a.meter == nil {
+		return noopMeter{}
+	}
+	return a.meter
+}
@@ -0,0 +1,71 @@
+package authority
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/keyutil"
+)
+
+type meterCall struct {
+	stage       string
+	provisioner string
+	err         bool
+}
+
+type mockMeter struct {
+	calls []meterCall
+}
+
+func (m *mockMeter) record(stage string, p provisioner.Interface, err error) {
+	var name string
+	if p != nil {
+		name = p.GetName()
+	}
+	m.calls = append(m.calls, meterCall{stage, name, err != nil})
+}
+
+func (m *mockMeter) X509Authorized(p provisioner.Interface, _ time.Duration, err error) {
+	m.record("authorize", p, err)
+}
+
+func (m *mockMeter) X509Rendered(p provisioner.Interface, _ time.Duration, err error) {
+	m.record("render", p, err)
+}
+
+func (m *mockMeter) X509Signed(p provisioner.Interface, _ time.Duration, err error) {
+	m.record("sign", p, err)
+}
+
+func TestAuthority_meter(t *testing.T) {
+	m := new(mockMeter)
+	a := testAuthority(t, WithMeter(m))
+
+	_, priv, err := keyutil.GenerateDefaultKeyPair()
+	assert.FatalError(t, err)
+	key, err := jose.ReadKey("testdata/secrets/step_cli_key_priv.jwk", jose.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+	token, err := generateToken("smallstep test", "step-cli", testAudiences.Sign[0], []string{"test.smallstep.com"}, time.Now(), key)
+	assert.FatalError(t, err)
+
+	ctx := provisioner.NewContextWithMethod(context.Background(), provisioner.SignMethod)
+	extraOpts, err := a.Authorize(ctx, token)
+	assert.FatalError(t, err)
+	_, err = a.Sign(getCSR(t, priv), provisioner.SignOptions{}, extraOpts...)
+	assert.FatalError(t, err)
+
+	// Failed authorizations are recorded without provisioner.
+	_, err = a.Authorize(ctx, "foo")
+	assert.Error(t, err)
+
+	assert.Equals(t, []meterCall{
+		{"authorize", "step-cli", false},
+		{"render", "step-cli", false},
+		{"sign", "step-cli", false},
+		{"authorize", "", true},
+	}, m.calls)
+}
@@ -0,0 +1,87 @@
+package authority
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/smallstep/certificates/authority/subca"
+	"github.com/smallstep/certificates/notify"
+)
+
+// notificationTimeout is the maximum time used to send the notifications
+// created in the background.
+const notificationTimeout = 30 * time.Second
+
+// initNotifications creates the providers used to send the email and SMS
+// notifications.
+func (a *Authority) initNotifications() (err error) {
+	if a.notifier != nil || a.config.Notifications == nil {
+		return nil
+	}
+	a.notifier, err = notify.New(context.Background(), a.config.Notifications)
+	return err
+}
+
+// GetNotifier returns the notifier used to send the email and SMS
+// notifications, it is nil if they are not configured.
+func (a *Authority) GetNotifier() *notify.Notifier {
+	return a.notifier
+}
+
+// notifierMailer is a smime.Mailer that sends the one-time codes using the
+// email provider of the notifications.
+type notifierMailer struct {
+	notifier *notify.Notifier
+}
+
+func (m *notifierMailer) Send(to, subject, body string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+	return m.notifier.Send(ctx, &notify.Message{
+		Channel: notify.Email,
+		To:      to,
+		Subject: subject,
+		Body:    body,
+	})
+}
+
+// notifySubCA sends an email to the configured recipients with the new
+// status of a subordinate CA request. The errors are only logged.
+func (a *Authority) notifySubCA(r *subca.Request, event string) {
+	to := a.config.SubCA.GetNotify()
+	if len(to) == 0 || !a.notifier.IsEnabled(notify.Email) {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "The subordinate CA request %s has been %s.\n\n", r.ID, event)
+	fmt.Fprintf(&b, "Requested by: %s\n", r.RequesterName)
+	fmt.Fprintf(&b, "Status: %s\n", r.Status)
+	for _, ap := range r.Approvals {
+		fmt.Fprintf(&b, "Approved by: %s\n", ap.Subject)
+	}
+	if r.RejectedBy != "" {
+		fmt.Fprintf(&b, "Rejected by: %s\n", r.RejectedBy)
+		fmt.Fprintf(&b, "Reason: %s\n", r.RejectionReason)
+	}
+	subject := fmt.Sprintf("Subordinate CA request %s %s", r.ID, event)
+	body := b.String()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+		defer cancel()
+		for _, email := range to {
+			if err := a.notifier.Send(ctx, &notify.Message{
+				Channel: notify.Email,
+				To:      email,
+				Subject: subject,
+				Body:    body,
+			}); err != nil {
+				log.Printf("error sending subordinate CA notification to %s: %v", email, err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,122 @@
+package authority
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+	"go.step.sm/crypto/x509util"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/subca"
+	"github.com/smallstep/certificates/notify"
+)
+
+type chanProvider chan *notify.Message
+
+func (c chanProvider) Send(_ context.Context, msg *notify.Message) error {
+	c <- msg
+	return nil
+}
+
+func (c chanProvider) receive(t *testing.T) *notify.Message {
+	t.Helper()
+	select {
+	case msg := <-c:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification not received")
+		return nil
+	}
+}
+
+func TestAuthority_notifySubCA(t *testing.T) {
+	ca, err := minica.New(minica.WithIntermediateTemplate(`{
+		"subject": {{ toJson .Subject }},
+		"keyUsage": ["certSign", "crlSign"],
+		"basicConstraints": {"isCA": true, "maxPathLen": 1}
+	}`))
+	assert.FatalError(t, err)
+
+	p := make(chanProvider, 10)
+	a, err := NewEmbedded(
+		WithConfig(&Config{SubCA: &config.SubCAConfig{
+			Enabled: true,
+			Notify:  []string{"security@example.com"},
+		}}),
+		WithX509RootCerts(ca.Root),
+		WithX509Signer(ca.Intermediate, ca.Signer),
+		WithNotifier(notify.NewNotifier(map[notify.Channel]notify.Provider{
+			notify.Email: p,
+		})),
+	)
+	assert.FatalError(t, err)
+
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+	csr, err := x509util.CreateCertificateRequest("Team CA", nil, signer)
+	assert.FatalError(t, err)
+
+	alice := &linkedca.Admin{Id: "alice-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+	bob := &linkedca.Admin{Id: "bob-id", Subject: "bob", Type: linkedca.Admin_SUPER_ADMIN}
+
+	ctx := context.Background()
+	r, err := a.RequestSubCA(ctx, alice, &subca.Request{
+		CSR:      csr.Raw,
+		Validity: provisioner.Duration{Duration: 24 * time.Hour},
+	})
+	assert.FatalError(t, err)
+	msg := p.receive(t)
+	assert.Equals(t, notify.Email, msg.Channel)
+	assert.Equals(t, "security@example.com", msg.To)
+	assert.Equals(t, "Subordinate CA request "+r.ID+" created", msg.Subject)
+	assert.True(t, strings.Contains(msg.Body, "Requested by: alice\n"))
+
+	_, err = a.ApproveSubCA(ctx, bob, r.ID)
+	assert.FatalError(t, err)
+	msg = p.receive(t)
+	assert.Equals(t, "Subordinate CA request "+r.ID+" approved and issued", msg.Subject)
+	assert.True(t, strings.Contains(msg.Body, "Approved by: bob\n"))
+
+	r, err = a.RequestSubCA(ctx, alice, &subca.Request{
+		CSR:      csr.Raw,
+		Validity: provisioner.Duration{Duration: 24 * time.Hour},
+	})
+	assert.FatalError(t, err)
+	p.receive(t)
+	_, err = a.RejectSubCA(ctx, bob, r.ID, "not needed")
+	assert.FatalError(t, err)
+	msg = p.receive(t)
+	assert.Equals(t, "Subordinate CA request "+r.ID+" rejected", msg.Subject)
+	assert.True(t, strings.Contains(msg.Body, "Reason: not needed\n"))
+}
+
+func TestAuthority_SendSMIMECode_notifier(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+
+	p := make(chanProvider, 1)
+	a, err := NewEmbedded(
+		WithConfig(&Config{SMIME: &config.SMIMEConfig{
+			Enabled:     true,
+			Provisioner: "smime",
+		}}),
+		WithX509RootCerts(ca.Root),
+		WithX509Signer(ca.Intermediate, ca.Signer),
+		WithNotifier(notify.NewNotifier(map[notify.Channel]notify.Provider{
+			notify.Email: p,
+		})),
+	)
+	assert.FatalError(t, err)
+
+	assert.FatalError(t, a.SendSMIMECode(context.Background(), "jane@example.com"))
+	msg := p.receive(t)
+	assert.Equals(t, notify.Email, msg.Channel)
+	assert.Equals(t, "jane@example.com", msg.To)
+}
@@ -0,0 +1,464 @@
+package authority
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/nosql"
+	kmsapi "go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/pemutil"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/smallstep/certificates/authority/ocspcache"
+	casapi "github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/internal/ocsputil"
+)
+
+// CreateOCSPResponse creates an OCSP response for the given certificate, valid
+// between thisUpdate and nextUpdate, and signed by the issuer of the
+// certificate, or by the configured OCSP responder. The certificate status is
+// obtained from the revocation tables.
+//
+// It returns a NotImplemented error if the configured CAS cannot sign OCSP
+// responses.
+func (a *Authority) CreateOCSPResponse(crt *x509.Certificate, thisUpdate, nextUpdate time.Time) ([]byte, error) {
+	if !a.canSignOCSPResponses() {
+		return nil, errs.NotImplemented("authority.CreateOCSPResponse; ocsp responses are not supported by the certificate authority service")
+	}
+	template, err := a.newOCSPResponseTemplate(crt.SerialNumber, thisUpdate, nextUpdate)
+	if err != nil {
+		return nil, err
+	}
+	return a.signOCSPResponseTemplate(template, nil)
+}
+
+// canSignOCSPResponses returns if the authority has a dedicated OCSP responder
+// or if the configured CAS can sign OCSP responses.
+func (a *Authority) canSignOCSPResponses() bool {
+	if a.ocspResponder != nil {
+		return true
+	}
+	_, ok := a.x509CAService.(casapi.CertificateAuthorityOCSPSigner)
+	return ok
+}
+
+// newOCSPResponseTemplate returns the template of the OCSP response of the
+// certificate with the given serial number, with the revocation time and
+// reason if the database stores them.
+func (a *Authority) newOCSPResponseTemplate(serialNumber *big.Int, thisUpdate, nextUpdate time.Time) (ocsp.Response, error) {
+	template := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: serialNumber,
+		ThisUpdate:   thisUpdate,
+		NextUpdate:   nextUpdate,
+	}
+
+	isRevoked, err := a.IsRevoked(serialNumber.String())
+	if err != nil {
+		return template, errs.Wrap(http.StatusInternalServerError, err, "authority.CreateOCSPResponse")
+	}
+	if isRevoked {
+		template.Status = ocsp.Revoked
+		template.RevokedAt = thisUpdate
+		template.RevocationReason = ocsp.Unspecified
+		if r := a.getCertificateRevocation(serialNumber.String()); !r.RevokedAt.IsZero() {
+			template.RevokedAt = r.RevokedAt
+			template.RevocationReason = r.ReasonCode
+		}
+	}
+	return template, nil
+}
+
+// signOCSPResponseTemplate signs the OCSP response template with the
+// dedicated responder, the delegated responder, or the CAS, in that order.
+// The extensions are added to the responseExtensions of the response.
+func (a *Authority) signOCSPResponseTemplate(template ocsp.Response, extensions []pkix.Extension) ([]byte, error) {
+	switch {
+	case a.ocspResponder != nil:
+		return a.createResponderOCSPResponse(a.ocspResponder, template, extensions)
+	case a.config.DelegatedSigners.IsEnabled() && a.config.DelegatedSigners.OCSP:
+		return a.createDelegatedOCSPResponse(template, extensions)
+	}
+
+	srv, ok := a.x509CAService.(casapi.CertificateAuthorityOCSPSigner)
+	if !ok {
+		return nil, errs.NotImplemented("authority.CreateOCSPResponse; ocsp responses are not supported by the certificate authority service")
+	}
+	resp, err := srv.CreateOCSPResponse(&casapi.CreateOCSPResponseRequest{
+		Template:   template,
+		Extensions: extensions,
+	})
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.CreateOCSPResponse")
+	}
+
+	return resp.OCSPResponse, nil
+}
+
+// createResponderOCSPResponse signs the given OCSP response template with the
+// given responder. The responder certificate is included in the response.
+func (a *Authority) createResponderOCSPResponse(s *delegatedSigner, template ocsp.Response, extensions []pkix.Extension) ([]byte, error) {
+	issuer, err := a.GetIssuerCertificate()
+	if err != nil {
+		return nil, err
+	}
+	template.Certificate = s.Certificate
+	b, err := ocsputil.CreateResponse(issuer, s.Certificate, template, extensions, s.Signer)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.CreateOCSPResponse; error creating ocsp response")
+	}
+	return b, nil
+}
+
+// RespondOCSP parses a DER encoded OCSP request for a certificate issued by
+// the intermediate and returns the response. Requests without a nonce are
+// served from the cache of OCSP responses, the ones with a nonce get a new
+// response with the same nonce unless the nonces are ignored. Certificates
+// that are not in the database get a new response with the unknown status.
+//
+// Invalid requests and requests for other issuers get the malformedRequest
+// and unauthorized error responses, RFC 6960 requires them to be sent as
+// regular responses.
+func (a *Authority) RespondOCSP(der []byte) (*ocspcache.Response, error) {
+	if a.ocspCache == nil {
+		return nil, errs.NotImplemented("authority.RespondOCSP; ocsp responses are not enabled")
+	}
+	req, err := ocsp.ParseRequest(der)
+	if err != nil {
+		return &ocspcache.Response{Response: ocsp.MalformedRequestErrorResponse}, nil
+	}
+	nonce, err := ocsputil.ParseNonce(der)
+	if err != nil {
+		return &ocspcache.Response{Response: ocsp.MalformedRequestErrorResponse}, nil
+	}
+	issuer, err := a.GetIssuerCertificate()
+	if err != nil {
+		return nil, err
+	}
+	if !isOCSPRequestIssuer(req, issuer) {
+		return &ocspcache.Response{Response: ocsp.UnauthorizedErrorResponse}, nil
+	}
+
+	sn := req.SerialNumber.String()
+	crt, err := a.db.GetCertificate(sn)
+	switch {
+	case errors.Is(err, db.ErrNotImplemented):
+		// Without a database the status only depends on the revocations.
+		crt = &x509.Certificate{SerialNumber: req.SerialNumber}
+	case nosql.IsErrNotFound(err):
+		crt = nil
+	case err != nil:
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.RespondOCSP")
+	case !isIssuedBy(crt, issuer):
+		return &ocspcache.Response{Response: ocsp.UnauthorizedErrorResponse}, nil
+	}
+
+	now := time.Now()
+	if nonce != nil && a.config.OCSP.IgnoreNonce {
+		nonce = nil
+	}
+	if crt != nil && nonce == nil && req.HashAlgorithm == crypto.SHA1 {
+		return a.getOCSPResponse(crt, now)
+	}
+
+	// Responses with a nonce, with a different hash algorithm, or for
+	// unknown certificates are not cached.
+	thisUpdate, nextUpdate := a.getOCSPResponseValidity(now)
+	template, err := a.newOCSPResponseTemplate(req.SerialNumber, thisUpdate, nextUpdate)
+	if err != nil {
+		return nil, err
+	}
+	template.IssuerHash = req.HashAlgorithm
+	if crt == nil && template.Status == ocsp.Good {
+		template.Status = ocsp.Unknown
+	}
+	var extensions []pkix.Extension
+	if nonce != nil {
+		extensions = append(extensions, *nonce)
+	}
+	b, err := a.signOCSPResponseTemplate(template, extensions)
+	if err != nil {
+		return nil, err
+	}
+	return &ocspcache.Response{
+		SerialNumber: sn,
+		Response:     b,
+		ThisUpdate:   thisUpdate,
+		NextUpdate:   nextUpdate,
+	}, nil
+}
+
+// isOCSPRequestIssuer returns if the hashes of the name and the key of the
+// issuer in the OCSP request are the ones of the given certificate.
+func isOCSPRequestIssuer(req *ocsp.Request, issuer *x509.Certificate) bool {
+	if !req.HashAlgorithm.Available() {
+		return false
+	}
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return false
+	}
+	h := req.HashAlgorithm.New()
+	h.Write(issuer.RawSubject)
+	nameHash := h.Sum(nil)
+	h.Reset()
+	h.Write(spki.PublicKey.RightAlign())
+	return bytes.Equal(nameHash, req.IssuerNameHash) && bytes.Equal(h.Sum(nil), req.IssuerKeyHash)
+}
+
+// loadOCSPResponder loads the dedicated OCSP responder configured in
+// ocsp.responderCert and ocsp.responderKey. The certificate must be issued by
+// the intermediate and it must have the id-kp-OCSPSigning extended key
+// usage.
+func (a *Authority) loadOCSPResponder() error {
+	cfg := a.config.OCSP
+	crt, err := pemutil.ReadCertificate(cfg.ResponderCert)
+	if err != nil {
+		return errors.Wrap(err, "error reading ocsp responder certificate")
+	}
+	issuer, err := a.GetIssuerCertificate()
+	if err != nil {
+		return err
+	}
+	if err := crt.CheckSignatureFrom(issuer); err != nil {
+		return errors.Wrap(err, "ocsp responder certificate is not issued by the intermediate")
+	}
+	if !hasExtKeyUsage(crt, x509.ExtKeyUsageOCSPSigning) {
+		return errors.New("ocsp responder certificate does not have the OCSPSigning extended key usage")
+	}
+	signer, err := a.keyManager.CreateSigner(&kmsapi.CreateSignerRequest{
+		SigningKey: cfg.ResponderKey,
+		Password:   a.password,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error loading ocsp responder key")
+	}
+	a.ocspResponder = &delegatedSigner{
+		Certificate: crt,
+		Signer:      signer,
+	}
+	return nil
+}
+
+// initOCSP creates the cache of OCSP responses and starts the job that
+// pre-signs them. The responses are kept only in memory if the database is
+// not configured.
+func (a *Authority) initOCSP() error {
+	if !a.config.OCSP.IsEnabled() {
+		return nil
+	}
+	if a.config.OCSP.ResponderCert != "" {
+		if err := a.loadOCSPResponder(); err != nil {
+			return err
+		}
+	}
+
+	var store ocspcache.Store
+	if ndb, ok := nosqlDB(a.db); ok {
+		s, err := ocspcache.NewNoSQLStore(ndb)
+		if err != nil {
+			return err
+		}
+		store = s
+	} else {
+		a.initLogf("OCSP responses are enabled without a database, responses will be kept in memory")
+	}
+	var memoryTTL time.Duration
+	if d := a.config.OCSP.MemoryCacheDuration; d != nil {
+		memoryTTL = d.Duration
+	}
+	a.ocspCache = ocspcache.New(store, memoryTTL)
+
+	if !a.config.OCSP.PreSign {
+		return nil
+	}
+	if _, ok := a.db.(db.CertificateLister); !ok || store == nil {
+		return errors.New("ocsp pre-signing requires a database")
+	}
+
+	a.ocspStopper = make(chan struct{}, 1)
+	a.ocspTicker = time.NewTicker(a.config.OCSP.GetPreSignInterval())
+
+	go func() {
+		// With leader election, the responses are signed on the first tick
+		// after this instance is elected.
+		if !a.config.LeaderElection.IsEnabled() {
+			a.runOCSPPreSign()
+		}
+		for {
+			select {
+			case <-a.ocspTicker.C:
+				if !a.IsLeader() {
+					continue
+				}
+				a.runOCSPPreSign()
+			case <-a.ocspStopper:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// stopOCSP stops the pre-signing goroutine.
+func (a *Authority) stopOCSP() {
+	if a.ocspTicker == nil {
+		return
+	}
+	a.ocspTicker.Stop()
+	close(a.ocspStopper)
+}
+
+func (a *Authority) runOCSPPreSign() {
+	n, err := a.PreSignOCSPResponses()
+	if err != nil {
+		log.Printf("error pre-signing ocsp responses: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Pre-signed %d OCSP responses", n)
+	}
+}
+
+// GetOCSPResponse returns the cached OCSP response of the given certificate.
+// If the response does not exist or it must be refreshed, a new one is signed
+// and cached. If signing fails, a cached response is returned while it's
+// still valid.
+func (a *Authority) GetOCSPResponse(crt *x509.Certificate) ([]byte, error) {
+	if a.ocspCache == nil {
+		return nil, errs.NotImplemented("authority.GetOCSPResponse; ocsp responses are not enabled")
+	}
+	r, err := a.getOCSPResponse(crt, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return r.Response, nil
+}
+
+func (a *Authority) getOCSPResponse(crt *x509.Certificate, now time.Time) (*ocspcache.Response, error) {
+	sn := crt.SerialNumber.String()
+	cached, err := a.ocspCache.Get(sn)
+	if err != nil && !errors.Is(err, ocspcache.ErrNotFound) {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.GetOCSPResponse")
+	}
+	if cached != nil && !cached.NeedsRefresh(now) {
+		return cached, nil
+	}
+
+	r, err := a.signOCSPResponse(crt, now)
+	if err != nil {
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// PreSignOCSPResponses signs and caches the OCSP responses of all the stored
+// certificates that are not expired nor revoked, and that do not have a
+// valid cached response. Certificates signed by a different intermediate are
+// ignored. It returns the number of responses signed.
+func (a *Authority) PreSignOCSPResponses() (int, error) {
+	if a.ocspCache == nil {
+		return 0, errs.NotImplemented("authority.PreSignOCSPResponses; ocsp responses are not enabled")
+	}
+	if !a.canSignOCSPResponses() {
+		return 0, errs.NotImplemented("authority.PreSignOCSPResponses; ocsp responses are not supported by the certificate authority service")
+	}
+	lister, ok := a.db.(db.CertificateLister)
+	if !ok {
+		return 0, errs.NotImplemented("authority.PreSignOCSPResponses; database does not support listing certificates")
+	}
+	if len(a.intermediateX509Certs) == 0 {
+		return 0, errs.InternalServer("authority.PreSignOCSPResponses; issuer certificate not found")
+	}
+	issuer := a.intermediateX509Certs[0]
+
+	certs, err := lister.GetCertificates()
+	if err != nil {
+		return 0, errs.Wrap(http.StatusInternalServerError, err, "authority.PreSignOCSPResponses")
+	}
+
+	var n int
+	now := time.Now()
+	for _, crt := range certs {
+		if crt.IsCA || !now.Before(crt.NotAfter) || !isIssuedBy(crt, issuer) {
+			continue
+		}
+		sn := crt.SerialNumber.String()
+		if isRevoked, err := a.IsRevoked(sn); err != nil || isRevoked {
+			continue
+		}
+		if r, err := a.ocspCache.Get(sn); err == nil && !r.NeedsRefresh(now) {
+			continue
+		}
+		if _, err := a.signOCSPResponse(crt, now); err != nil {
+			log.Printf("error pre-signing ocsp response for %s: %v", sn, err)
+			continue
+		}
+		n++
+	}
+
+	a.ocspCache.Purge()
+	return n, nil
+}
+
+// signOCSPResponse signs an OCSP response valid for the configured time and
+// adds it to the cache.
+func (a *Authority) signOCSPResponse(crt *x509.Certificate, now time.Time) (*ocspcache.Response, error) {
+	thisUpdate, nextUpdate := a.getOCSPResponseValidity(now)
+	b, err := a.CreateOCSPResponse(crt, thisUpdate, nextUpdate)
+	if err != nil {
+		return nil, err
+	}
+	r := &ocspcache.Response{
+		SerialNumber: crt.SerialNumber.String(),
+		Response:     b,
+		ThisUpdate:   thisUpdate,
+		NextUpdate:   nextUpdate,
+	}
+	if err := a.ocspCache.Set(r); err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.signOCSPResponse; error caching ocsp response")
+	}
+	return r, nil
+}
+
+// getOCSPResponseValidity returns the thisUpdate and nextUpdate times of an
+// OCSP response signed at the given time.
+func (a *Authority) getOCSPResponseValidity(now time.Time) (time.Time, time.Time) {
+	thisUpdate := now.Add(-1 * time.Minute).UTC().Truncate(time.Second)
+	return thisUpdate, thisUpdate.Add(a.config.OCSP.GetValidity())
+}
+
+// invalidateOCSPResponse removes the cached OCSP response of a revoked
+// certificate.
+func (a *Authority) invalidateOCSPResponse(serialNumber string) error {
+	if a.ocspCache == nil {
+		return nil
+	}
+	return a.ocspCache.Delete(serialNumber)
+}
+
+// isIssuedBy returns if the certificate has been signed by the given issuer,
+// comparing the key identifiers or the names if they are not present.
+func isIssuedBy(crt, issuer *x509.Certificate) bool {
+	if len(crt.AuthorityKeyId) > 0 && len(issuer.SubjectKeyId) > 0 {
+		return bytes.Equal(crt.AuthorityKeyId, issuer.SubjectKeyId)
+	}
+	return bytes.Equal(crt.RawIssuer, issuer.RawSubject)
+}
@@ -0,0 +1,310 @@
+package authority
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/nosql/database"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+	"go.step.sm/crypto/pemutil"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/internal/ocsputil"
+)
+
+func TestAuthority_PreSignOCSPResponses(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	other, err := minica.New()
+	assert.FatalError(t, err)
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+
+	mustSign := func(ca *minica.CA, sn int64, notAfter time.Time) *x509.Certificate {
+		crt, err := ca.Sign(&x509.Certificate{
+			SerialNumber: big.NewInt(sn),
+			PublicKey:    signer.Public(),
+			DNSNames:     []string{"leaf.example.com"},
+			NotBefore:    time.Now().Add(-2 * time.Hour),
+			NotAfter:     notAfter,
+		})
+		assert.FatalError(t, err)
+		return crt
+	}
+	good := mustSign(ca, 1, time.Now().Add(time.Hour))
+	revoked := mustSign(ca, 2, time.Now().Add(time.Hour))
+	expired := mustSign(ca, 3, time.Now().Add(-time.Hour))
+	foreign := mustSign(other, 4, time.Now().Add(time.Hour))
+
+	var revokedSerials []string
+	mdb := &db.MockAuthDB{
+		MGetCertificates: func() ([]*x509.Certificate, error) {
+			return []*x509.Certificate{good, revoked, expired, foreign, ca.Intermediate}, nil
+		},
+		MIsRevoked: func(sn string) (bool, error) {
+			for _, s := range revokedSerials {
+				if s == sn {
+					return true, nil
+				}
+			}
+			return sn == "2", nil
+		},
+	}
+
+	a, err := NewEmbedded(
+		WithConfig(&Config{OCSP: &config.OCSPConfig{Enabled: true}}),
+		WithDatabase(mdb),
+		WithX509RootCerts(ca.Root),
+		WithX509Signer(ca.Intermediate, ca.Signer),
+	)
+	assert.FatalError(t, err)
+
+	n, err := a.PreSignOCSPResponses()
+	assert.FatalError(t, err)
+	assert.Equals(t, 1, n)
+
+	// Responses are not signed again until they need a refresh.
+	n, err = a.PreSignOCSPResponses()
+	assert.FatalError(t, err)
+	assert.Equals(t, 0, n)
+
+	cached, err := a.ocspCache.Get("1")
+	assert.FatalError(t, err)
+	assert.Equals(t, 24*time.Hour, cached.NextUpdate.Sub(cached.ThisUpdate))
+	b, err := a.GetOCSPResponse(good)
+	assert.FatalError(t, err)
+	assert.Equals(t, cached.Response, b)
+
+	resp, err := ocsp.ParseResponse(b, ca.Intermediate)
+	assert.FatalError(t, err)
+	assert.Equals(t, ocsp.Good, resp.Status)
+	assert.Equals(t, big.NewInt(1), resp.SerialNumber)
+
+	// Revoked certificates are signed on demand.
+	_, err = a.ocspCache.Get("2")
+	assert.Error(t, err)
+	b, err = a.GetOCSPResponse(revoked)
+	assert.FatalError(t, err)
+	resp, err = ocsp.ParseResponse(b, ca.Intermediate)
+	assert.FatalError(t, err)
+	assert.Equals(t, ocsp.Revoked, resp.Status)
+
+	// The cached response is removed after a revocation.
+	revokedSerials = append(revokedSerials, "1")
+	assert.FatalError(t, a.invalidateOCSPResponse("1"))
+	b, err = a.GetOCSPResponse(good)
+	assert.FatalError(t, err)
+	resp, err = ocsp.ParseResponse(b, ca.Intermediate)
+	assert.FatalError(t, err)
+	assert.Equals(t, ocsp.Revoked, resp.Status)
+}
+
+func TestAuthority_GetOCSPResponse_disabled(t *testing.T) {
+	a := testAuthority(t)
+	_, err := a.GetOCSPResponse(a.intermediateX509Certs[0])
+	assert.Error(t, err)
+	_, err = a.PreSignOCSPResponses()
+	assert.Error(t, err)
+}
+
+func Test_initOCSP_preSign(t *testing.T) {
+	// The test authority does not have a database.
+	a := testAuthority(t)
+	a.config.OCSP = &config.OCSPConfig{Enabled: true, PreSign: true}
+	err := a.initOCSP()
+	if assert.Error(t, err) {
+		assert.Equals(t, "ocsp pre-signing requires a database", err.Error())
+	}
+}
+
+func TestAuthority_RespondOCSP(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	other, err := minica.New()
+	assert.FatalError(t, err)
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+
+	mustSign := func(ca *minica.CA, sn int64) *x509.Certificate {
+		crt, err := ca.Sign(&x509.Certificate{
+			SerialNumber: big.NewInt(sn),
+			PublicKey:    signer.Public(),
+			DNSNames:     []string{"leaf.example.com"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		})
+		assert.FatalError(t, err)
+		return crt
+	}
+	good := mustSign(ca, 1)
+	revoked := mustSign(ca, 2)
+	unknown := mustSign(ca, 3)
+	foreign := mustSign(other, 1)
+
+	stored := map[string]*x509.Certificate{"1": good, "2": revoked}
+	revokedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	ldb := &lifecycleDB{
+		MockAuthDB: &db.MockAuthDB{
+			MGetCertificate: func(sn string) (*x509.Certificate, error) {
+				if crt, ok := stored[sn]; ok {
+					return crt, nil
+				}
+				return nil, database.ErrNotFound
+			},
+			MIsRevoked: func(sn string) (bool, error) {
+				return sn == "2", nil
+			},
+		},
+		revoked: map[string]*db.RevokedCertificateInfo{
+			"2": {Serial: "2", ReasonCode: ocsp.KeyCompromise, Reason: "key compromise", RevokedAt: revokedAt},
+		},
+	}
+
+	newAuthority := func(t *testing.T, cfg *config.OCSPConfig) *Authority {
+		t.Helper()
+		a, err := NewEmbedded(
+			WithConfig(&Config{OCSP: cfg}),
+			WithDatabase(ldb),
+			WithX509RootCerts(ca.Root),
+			WithX509Signer(ca.Intermediate, ca.Signer),
+		)
+		assert.FatalError(t, err)
+		return a
+	}
+	newRequest := func(t *testing.T, crt, issuer *x509.Certificate, nonce []byte) []byte {
+		t.Helper()
+		der, err := ocsp.CreateRequest(crt, issuer, nil)
+		assert.FatalError(t, err)
+		if nonce == nil {
+			return der
+		}
+		var req struct {
+			TBSRequest struct {
+				Version           int           `asn1:"explicit,tag:0,default:0,optional"`
+				RequestorName     asn1.RawValue `asn1:"explicit,tag:1,optional"`
+				RequestList       []asn1.RawValue
+				RequestExtensions []pkix.Extension `asn1:"explicit,tag:2,optional"`
+			}
+		}
+		_, err = asn1.Unmarshal(der, &req)
+		assert.FatalError(t, err)
+		value, err := asn1.Marshal(nonce)
+		assert.FatalError(t, err)
+		req.TBSRequest.RequestExtensions = []pkix.Extension{{Id: ocsputil.OIDNonce, Value: value}}
+		der, err = asn1.Marshal(req)
+		assert.FatalError(t, err)
+		return der
+	}
+	nonce := []byte("0123456789abcdef")
+
+	a := newAuthority(t, &config.OCSPConfig{Enabled: true})
+
+	// Requests without a nonce are cached.
+	r, err := a.RespondOCSP(newRequest(t, good, ca.Intermediate, nil))
+	assert.FatalError(t, err)
+	resp, err := ocsp.ParseResponse(r.Response, ca.Intermediate)
+	assert.FatalError(t, err)
+	assert.Equals(t, ocsp.Good, resp.Status)
+	assert.Equals(t, big.NewInt(1), resp.SerialNumber)
+	cached, err := a.ocspCache.Get("1")
+	assert.FatalError(t, err)
+	assert.Equals(t, cached.Response, r.Response)
+
+	// Requests with a nonce get a new response with the nonce.
+	r, err = a.RespondOCSP(newRequest(t, good, ca.Intermediate, nonce))
+	assert.FatalError(t, err)
+	resp, err = ocsp.ParseResponse(r.Response, ca.Intermediate)
+	assert.FatalError(t, err)
+	assert.Equals(t, ocsp.Good, resp.Status)
+	assert.True(t, bytes.Contains(resp.TBSResponseData, nonce))
+	assert.NotEquals(t, cached.Response, r.Response)
+
+	// Revoked certificates include the revocation time and reason.
+	r, err = a.RespondOCSP(newRequest(t, revoked, ca.Intermediate, nil))
+	assert.FatalError(t, err)
+	resp, err = ocsp.ParseResponse(r.Response, ca.Intermediate)
+	assert.FatalError(t, err)
+	assert.Equals(t, ocsp.Revoked, resp.Status)
+	assert.Equals(t, ocsp.KeyCompromise, resp.RevocationReason)
+	assert.Equals(t, revokedAt, resp.RevokedAt)
+
+	// Certificates not in the database have the unknown status.
+	r, err = a.RespondOCSP(newRequest(t, unknown, ca.Intermediate, nil))
+	assert.FatalError(t, err)
+	resp, err = ocsp.ParseResponse(r.Response, ca.Intermediate)
+	assert.FatalError(t, err)
+	assert.Equals(t, ocsp.Unknown, resp.Status)
+	_, err = a.ocspCache.Get("3")
+	assert.Error(t, err)
+
+	// Requests for other issuers and malformed requests.
+	r, err = a.RespondOCSP(newRequest(t, foreign, other.Intermediate, nil))
+	assert.FatalError(t, err)
+	assert.Equals(t, ocsp.UnauthorizedErrorResponse, r.Response)
+	r, err = a.RespondOCSP([]byte("foo"))
+	assert.FatalError(t, err)
+	assert.Equals(t, ocsp.MalformedRequestErrorResponse, r.Response)
+
+	// Nonces can be ignored.
+	a = newAuthority(t, &config.OCSPConfig{Enabled: true, IgnoreNonce: true})
+	r, err = a.RespondOCSP(newRequest(t, good, ca.Intermediate, nonce))
+	assert.FatalError(t, err)
+	resp, err = ocsp.ParseResponse(r.Response, ca.Intermediate)
+	assert.FatalError(t, err)
+	assert.False(t, bytes.Contains(resp.TBSResponseData, nonce))
+
+	// Responses signed by a dedicated responder.
+	responderKey, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+	responder, err := ca.Sign(&x509.Certificate{
+		SerialNumber: big.NewInt(100),
+		PublicKey:    responderKey.Public(),
+		Subject:      pkix.Name{CommonName: "OCSP Responder"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+	})
+	assert.FatalError(t, err)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "responder.crt"), filepath.Join(dir, "responder.key")
+	_, err = pemutil.Serialize(responder, pemutil.ToFile(certFile, 0600))
+	assert.FatalError(t, err)
+	_, err = pemutil.Serialize(responderKey, pemutil.ToFile(keyFile, 0600))
+	assert.FatalError(t, err)
+
+	a = newAuthority(t, &config.OCSPConfig{Enabled: true, ResponderCert: certFile, ResponderKey: keyFile})
+	r, err = a.RespondOCSP(newRequest(t, good, ca.Intermediate, nonce))
+	assert.FatalError(t, err)
+	resp, err = ocsp.ParseResponse(r.Response, ca.Intermediate)
+	assert.FatalError(t, err)
+	assert.Equals(t, ocsp.Good, resp.Status)
+	assert.Equals(t, responder.Raw, resp.Certificate.Raw)
+	assert.True(t, bytes.Contains(resp.TBSResponseData, nonce))
+
+	// The responder must be issued by the intermediate.
+	_, err = NewEmbedded(
+		WithConfig(&Config{OCSP: &config.OCSPConfig{Enabled: true, ResponderCert: certFile, ResponderKey: keyFile}}),
+		WithDatabase(ldb),
+		WithX509RootCerts(other.Root),
+		WithX509Signer(other.Intermediate, other.Signer),
+	)
+	if assert.Error(t, err) {
+		assert.HasPrefix(t, err.Error(), "ocsp responder certificate is not issued by the intermediate")
+	}
+}
+
+func TestAuthority_RespondOCSP_disabled(t *testing.T) {
+	a := testAuthority(t)
+	_, err := a.RespondOCSP([]byte("foo"))
+	assert.Error(t, err)
+}
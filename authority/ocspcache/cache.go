@@ -0,0 +1,218 @@
+// Package ocspcache implements the cache of pre-signed OCSP responses.
+//
+// Responses are kept in a memory layer in front of a persistent store, shared
+// by all the instances of the CA. The memory layer only keeps a response for a
+// short period of time, so a revocation done by another instance, that
+// deletes the response from the persistent store, is visible after that
+// period.
+package ocspcache
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/nosql"
+	"github.com/smallstep/nosql/database"
+)
+
+// DefaultMemoryTTL is the default time that a response is kept in memory
+// before reading it again from the persistent store.
+const DefaultMemoryTTL = time.Minute
+
+var responsesTable = []byte("ocsp_responses")
+
+// ErrNotFound is the error returned by the stores if a response does not
+// exist.
+var ErrNotFound = errors.New("ocsp response not found")
+
+// Response is a DER encoded OCSP response and its validity.
+type Response struct {
+	SerialNumber string    `json:"serialNumber"`
+	Response     []byte    `json:"response"`
+	ThisUpdate   time.Time `json:"thisUpdate"`
+	NextUpdate   time.Time `json:"nextUpdate"`
+}
+
+// IsValid returns if the response is valid at the given time.
+func (r *Response) IsValid(now time.Time) bool {
+	return !now.Before(r.ThisUpdate) && now.Before(r.NextUpdate)
+}
+
+// NeedsRefresh returns if the response must be signed again at the given
+// time. A response is refreshed after half of its validity.
+func (r *Response) NeedsRefresh(now time.Time) bool {
+	return !r.IsValid(now) || !now.Before(r.ThisUpdate.Add(r.NextUpdate.Sub(r.ThisUpdate)/2))
+}
+
+// Store is the interface used to persist the OCSP responses.
+type Store interface {
+	Get(serialNumber string) (*Response, error)
+	Set(r *Response) error
+	Delete(serialNumber string) error
+}
+
+// Cache is a memory cache of OCSP responses in front of a persistent store.
+type Cache struct {
+	mu        sync.RWMutex
+	store     Store
+	memoryTTL time.Duration
+	entries   map[string]*entry
+	now       func() time.Time
+}
+
+type entry struct {
+	response *Response
+	expires  time.Time
+}
+
+// New creates a new cache backed by the given store. If the store is nil,
+// the responses are only kept in memory.
+func New(store Store, memoryTTL time.Duration) *Cache {
+	if memoryTTL <= 0 {
+		memoryTTL = DefaultMemoryTTL
+	}
+	return &Cache{
+		store:     store,
+		memoryTTL: memoryTTL,
+		entries:   make(map[string]*entry),
+		now:       time.Now,
+	}
+}
+
+// Get returns the cached response for the given serial number. It returns
+// ErrNotFound if the response does not exist or it has expired.
+func (c *Cache) Get(serialNumber string) (*Response, error) {
+	now := c.now()
+	c.mu.RLock()
+	e, ok := c.entries[serialNumber]
+	c.mu.RUnlock()
+	if ok && now.Before(e.expires) {
+		if !e.response.IsValid(now) {
+			return nil, ErrNotFound
+		}
+		return e.response, nil
+	}
+	if c.store == nil {
+		if ok {
+			c.delete(serialNumber)
+		}
+		return nil, ErrNotFound
+	}
+
+	r, err := c.store.Get(serialNumber)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.delete(serialNumber)
+		}
+		return nil, err
+	}
+	c.set(r, now)
+	if !r.IsValid(now) {
+		return nil, ErrNotFound
+	}
+	return r, nil
+}
+
+// Set adds a response to the cache and the persistent store.
+func (c *Cache) Set(r *Response) error {
+	if c.store != nil {
+		if err := c.store.Set(r); err != nil {
+			return err
+		}
+	}
+	c.set(r, c.now())
+	return nil
+}
+
+// Delete removes a response from the cache and the persistent store.
+func (c *Cache) Delete(serialNumber string) error {
+	c.delete(serialNumber)
+	if c.store != nil {
+		if err := c.store.Delete(serialNumber); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Purge removes the expired responses from memory.
+func (c *Cache) Purge() {
+	now := c.now()
+	c.mu.Lock()
+	for k, e := range c.entries {
+		if !now.Before(e.expires) || !e.response.IsValid(now) {
+			delete(c.entries, k)
+		}
+	}
+	c.mu.Unlock()
+}
+
+func (c *Cache) set(r *Response, now time.Time) {
+	expires := now.Add(c.memoryTTL)
+	if c.store == nil || r.NextUpdate.Before(expires) {
+		expires = r.NextUpdate
+	}
+	c.mu.Lock()
+	c.entries[r.SerialNumber] = &entry{response: r, expires: expires}
+	c.mu.Unlock()
+}
+
+func (c *Cache) delete(serialNumber string) {
+	c.mu.Lock()
+	delete(c.entries, serialNumber)
+	c.mu.Unlock()
+}
+
+// NoSQLStore is a Store that persists the responses in the authority
+// database.
+type NoSQLStore struct {
+	db nosql.DB
+}
+
+// NewNoSQLStore creates the responses table in the given database and returns
+// a new store.
+func NewNoSQLStore(db nosql.DB) (*NoSQLStore, error) {
+	if err := db.CreateTable(responsesTable); err != nil {
+		return nil, errors.Wrapf(err, "error creating table %s", responsesTable)
+	}
+	return &NoSQLStore{db: db}, nil
+}
+
+// Get implements the Store interface.
+func (s *NoSQLStore) Get(serialNumber string) (*Response, error) {
+	b, err := s.db.Get(responsesTable, []byte(serialNumber))
+	switch {
+	case database.IsErrNotFound(err):
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, errors.Wrap(err, "error loading ocsp response")
+	}
+	r := new(Response)
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling ocsp response")
+	}
+	return r, nil
+}
+
+// Set implements the Store interface.
+func (s *NoSQLStore) Set(r *Response) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling ocsp response")
+	}
+	return errors.Wrap(s.db.Set(responsesTable, []byte(r.SerialNumber), b), "error storing ocsp response")
+}
+
+// Delete implements the Store interface.
+func (s *NoSQLStore) Delete(serialNumber string) error {
+	err := s.db.Del(responsesTable, []byte(serialNumber))
+	switch {
+	case database.IsErrNotFound(err):
+		return ErrNotFound
+	case err != nil:
+		return errors.Wrap(err, "error deleting ocsp response")
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package ocspcache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mapStore struct {
+	responses map[string]*Response
+	gets      int
+}
+
+func (s *mapStore) Get(sn string) (*Response, error) {
+	s.gets++
+	if r, ok := s.responses[sn]; ok {
+		return r, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *mapStore) Set(r *Response) error {
+	s.responses[r.SerialNumber] = r
+	return nil
+}
+
+func (s *mapStore) Delete(sn string) error {
+	if _, ok := s.responses[sn]; !ok {
+		return ErrNotFound
+	}
+	delete(s.responses, sn)
+	return nil
+}
+
+func TestResponse_NeedsRefresh(t *testing.T) {
+	now := time.Now()
+	r := &Response{ThisUpdate: now, NextUpdate: now.Add(time.Hour)}
+	assert.False(t, r.IsValid(now.Add(-time.Second)))
+	assert.True(t, r.IsValid(now))
+	assert.False(t, r.IsValid(now.Add(time.Hour)))
+
+	assert.False(t, r.NeedsRefresh(now))
+	assert.False(t, r.NeedsRefresh(now.Add(29*time.Minute)))
+	assert.True(t, r.NeedsRefresh(now.Add(30*time.Minute)))
+	assert.True(t, r.NeedsRefresh(now.Add(2*time.Hour)))
+}
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+	store := &mapStore{responses: make(map[string]*Response)}
+	c := New(store, 0)
+	c.now = func() time.Time { return now }
+
+	_, err := c.Get("1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	r := &Response{SerialNumber: "1", Response: []byte("response"), ThisUpdate: now, NextUpdate: now.Add(time.Hour)}
+	require.NoError(t, c.Set(r))
+	assert.Equal(t, r, store.responses["1"])
+
+	// Read from memory.
+	store.gets = 0
+	got, err := c.Get("1")
+	require.NoError(t, err)
+	assert.Equal(t, r, got)
+	assert.Equal(t, 0, store.gets)
+
+	// Read from the store after the memory TTL.
+	now = now.Add(DefaultMemoryTTL)
+	got, err = c.Get("1")
+	require.NoError(t, err)
+	assert.Equal(t, r, got)
+	assert.Equal(t, 1, store.gets)
+
+	// A deletion by another instance is visible after the memory TTL.
+	delete(store.responses, "1")
+	got, err = c.Get("1")
+	require.NoError(t, err)
+	assert.Equal(t, r, got)
+	now = now.Add(DefaultMemoryTTL)
+	_, err = c.Get("1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Expired responses are not returned.
+	require.NoError(t, c.Set(r))
+	now = now.Add(time.Hour)
+	_, err = c.Get("1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Delete ignores missing responses.
+	require.NoError(t, c.Delete("1"))
+	require.NoError(t, c.Delete("1"))
+	assert.Empty(t, store.responses)
+}
+
+func TestCache_memory(t *testing.T) {
+	now := time.Now()
+	c := New(nil, time.Second)
+	c.now = func() time.Time { return now }
+
+	r := &Response{SerialNumber: "1", ThisUpdate: now, NextUpdate: now.Add(time.Hour)}
+	require.NoError(t, c.Set(r))
+
+	// Without a store, responses are kept until they expire.
+	now = now.Add(30 * time.Minute)
+	got, err := c.Get("1")
+	require.NoError(t, err)
+	assert.Equal(t, r, got)
+
+	now = now.Add(30 * time.Minute)
+	c.Purge()
+	assert.Empty(t, c.entries)
+	_, err = c.Get("1")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
@@ -0,0 +1,73 @@
+package authority
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+
+	"github.com/pkg/errors"
+)
+
+// validateOfflineRoot validates the X.509 keys loaded by an authority
+// configured with an offline root. The root key is never present on the CA
+// host, so the intermediate certificate must be signed by one of the
+// configured roots, it must match the intermediate key, and the intermediate
+// key cannot be a root key.
+//
+// The signer and the chain are only available when the authority uses the
+// default SoftCAS, on any other CAS only the roots are checked.
+func (a *Authority) validateOfflineRoot(signer crypto.Signer, chain []*x509.Certificate) error {
+	if len(a.rootX509Certs) == 0 {
+		return errors.New("offline root: a root certificate is required")
+	}
+	if signer == nil {
+		return nil
+	}
+	if len(chain) == 0 {
+		return errors.New("offline root: an intermediate certificate is required")
+	}
+
+	leaf := chain[0]
+	if bytes.Equal(leaf.RawSubject, leaf.RawIssuer) && leaf.CheckSignatureFrom(leaf) == nil {
+		return errors.New("offline root: intermediate certificate cannot be self-signed")
+	}
+	if !equalPublicKeys(leaf.PublicKey, signer.Public()) {
+		return errors.New("offline root: intermediate key does not match the intermediate certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, crt := range chain[1:] {
+		intermediates.AddCert(crt)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         a.rootX509CertPool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return errors.Wrap(err, "offline root: error verifying intermediate certificate")
+	}
+
+	return a.checkOfflineRootKey(signer.Public(), "intermediate key")
+}
+
+// checkOfflineRootKey returns an error if the authority is configured with an
+// offline root and the given public key belongs to one of the roots. It is
+// used to make sure that the root key is never loaded at runtime.
+func (a *Authority) checkOfflineRootKey(pub crypto.PublicKey, name string) error {
+	if !a.config.OfflineRoot {
+		return nil
+	}
+	for _, crt := range a.rootX509Certs {
+		if equalPublicKeys(crt.PublicKey, pub) {
+			return errors.Errorf("offline root: %s cannot be a root key", name)
+		}
+	}
+	return nil
+}
+
+func equalPublicKeys(a, b crypto.PublicKey) bool {
+	if k, ok := a.(interface{ Equal(crypto.PublicKey) bool }); ok {
+		return k.Equal(b)
+	}
+	return false
+}
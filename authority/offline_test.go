@@ -0,0 +1,116 @@
+package authority
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+)
+
+func TestAuthority_validateOfflineRoot(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	other, err := minica.New()
+	assert.FatalError(t, err)
+
+	newIntermediate := func(pub crypto.PublicKey) *x509.Certificate {
+		now := time.Now()
+		b, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber:          big.NewInt(now.UnixNano()),
+			Subject:               pkix.Name{CommonName: "Offline Intermediate CA"},
+			NotBefore:             now,
+			NotAfter:              now.Add(time.Hour),
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}, ca.Root, pub, ca.RootSigner)
+		assert.FatalError(t, err)
+		crt, err := x509.ParseCertificate(b)
+		assert.FatalError(t, err)
+		return crt
+	}
+	newAuthority := func(roots ...*x509.Certificate) *Authority {
+		pool := x509.NewCertPool()
+		for _, crt := range roots {
+			pool.AddCert(crt)
+		}
+		return &Authority{
+			config:           &Config{OfflineRoot: true},
+			rootX509Certs:    roots,
+			rootX509CertPool: pool,
+		}
+	}
+
+	otherSigner, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name    string
+		auth    *Authority
+		signer  crypto.Signer
+		chain   []*x509.Certificate
+		wantErr string
+	}{
+		{"ok", newAuthority(ca.Root), ca.Signer, []*x509.Certificate{ca.Intermediate}, ""},
+		{"ok/cas", newAuthority(ca.Root), nil, nil, ""},
+		{"fail/roots", newAuthority(), ca.Signer, []*x509.Certificate{ca.Intermediate}, "offline root: a root certificate is required"},
+		{"fail/chain", newAuthority(ca.Root), ca.Signer, nil, "offline root: an intermediate certificate is required"},
+		{"fail/self-signed", newAuthority(ca.Root), ca.RootSigner, []*x509.Certificate{ca.Root}, "offline root: intermediate certificate cannot be self-signed"},
+		{"fail/key", newAuthority(ca.Root), otherSigner, []*x509.Certificate{ca.Intermediate}, "offline root: intermediate key does not match the intermediate certificate"},
+		{"fail/verify", newAuthority(other.Root), ca.Signer, []*x509.Certificate{ca.Intermediate}, "offline root: error verifying intermediate certificate"},
+		{"fail/root-key", newAuthority(ca.Root), ca.RootSigner, []*x509.Certificate{newIntermediate(ca.RootSigner.Public())}, "offline root: intermediate key cannot be a root key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.auth.validateOfflineRoot(tt.signer, tt.chain)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.True(t, strings.HasPrefix(err.Error(), tt.wantErr), err.Error())
+			}
+		})
+	}
+}
+
+func TestAuthority_checkOfflineRootKey(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+
+	a := &Authority{config: &Config{}, rootX509Certs: []*x509.Certificate{ca.Root}}
+	assert.NoError(t, a.checkOfflineRootKey(ca.RootSigner.Public(), "ssh host key"))
+
+	a.config.OfflineRoot = true
+	assert.NoError(t, a.checkOfflineRootKey(ca.Signer.Public(), "ssh host key"))
+	assert.Equals(t, "offline root: ssh host key cannot be a root key",
+		a.checkOfflineRootKey(ca.RootSigner.Public(), "ssh host key").Error())
+}
+
+func TestAuthority_offlineRoot(t *testing.T) {
+	a := testAuthority(t, func(a *Authority) error {
+		a.config.OfflineRoot = true
+		return nil
+	})
+	assert.True(t, a.config.OfflineRoot)
+
+	_, err := New(&Config{
+		Address:          "127.0.0.1:443",
+		Root:             []string{"testdata/certs/root_ca.crt"},
+		IntermediateCert: "testdata/certs/root_ca.crt",
+		IntermediateKey:  "testdata/secrets/intermediate_ca_key",
+		DNSNames:         []string{"example.com"},
+		Password:         "pass",
+		AuthorityConfig:  &AuthConfig{},
+		OfflineRoot:      true,
+	})
+	if assert.Error(t, err) {
+		assert.Equals(t, "offline root: intermediate certificate cannot be self-signed", err.Error())
+	}
+}
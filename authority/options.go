@@ -14,10 +14,14 @@ import (
 
 	"github.com/smallstep/certificates/authority/admin"
 	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/leader"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/cache"
 	"github.com/smallstep/certificates/cas"
 	casapi "github.com/smallstep/certificates/cas/apiv1"
 	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/notify"
+	"github.com/smallstep/certificates/smime"
 )
 
 // Option sets options to the Authority.
@@ -86,6 +90,25 @@ func WithDatabase(d db.AuthDB) Option {
 	}
 }
 
+// WithCache sets an already initialized store for the ephemeral state, like
+// the token replay cache. This option is intended to be use on graceful
+// reloads.
+func WithCache(s cache.Store) Option {
+	return func(a *Authority) error {
+		a.cache = s
+		return nil
+	}
+}
+
+// WithLeaderLock sets the lock used to elect the instance that runs the
+// background jobs, by default the lease is kept in the database.
+func WithLeaderLock(l leader.Lock) Option {
+	return func(a *Authority) error {
+		a.leaderLock = l
+		return nil
+	}
+}
+
 // WithQuietInit disables log output when the authority is initialized.
 func WithQuietInit() Option {
 	return func(a *Authority) error {
@@ -339,6 +362,34 @@ func WithX509Enforcers(ces ...provisioner.CertificateEnforcer) Option {
 	}
 }
 
+// WithMeter is an option that sets the Meter used to gather the metrics of the
+// sign flow by provisioner.
+func WithMeter(m Meter) Option {
+	return func(a *Authority) error {
+		a.meter = m
+		return nil
+	}
+}
+
+// WithSMIMEMailer is an option that sets the Mailer used to send the one-time
+// codes of the S/MIME issuance API. If set, it replaces the email provider of
+// the notifications.
+func WithSMIMEMailer(m smime.Mailer) Option {
+	return func(a *Authority) error {
+		a.smimeMailer = m
+		return nil
+	}
+}
+
+// WithNotifier is an option that sets the Notifier used to send the email and
+// SMS notifications. If set, it replaces the configured providers.
+func WithNotifier(n *notify.Notifier) Option {
+	return func(a *Authority) error {
+		a.notifier = n
+		return nil
+	}
+}
+
 // WithSkipInit is an option that allows the constructor to skip initializtion
 // of the authority.
 func WithSkipInit() Option {
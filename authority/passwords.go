@@ -0,0 +1,33 @@
+package authority
+
+import (
+	"github.com/smallstep/certificates/internal/secret"
+)
+
+// lockPasswords copies the passwords used to decrypt the keys of the authority
+// into locked memory. The passwords are only used to load the keys, so the
+// returned function wipes them, and it's called once the authority is
+// initialized. The slices given in the options are not modified, they belong
+// to the caller.
+func (a *Authority) lockPasswords() func() {
+	var buffers []*secret.Buffer
+	lock := func(password []byte) []byte {
+		if password == nil {
+			return nil
+		}
+		buf := secret.Copy(password)
+		buffers = append(buffers, buf)
+		return buf.Bytes()
+	}
+	a.password = lock(a.password)
+	a.sshHostPassword = lock(a.sshHostPassword)
+	a.sshUserPassword = lock(a.sshUserPassword)
+	a.issuerPassword = lock(a.issuerPassword)
+
+	return func() {
+		a.password, a.sshHostPassword, a.sshUserPassword, a.issuerPassword = nil, nil, nil, nil
+		for _, buf := range buffers {
+			buf.Destroy()
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package authority
+
+import (
+	"testing"
+
+	"github.com/smallstep/assert"
+)
+
+func TestAuthority_lockPasswords(t *testing.T) {
+	password := []byte("pass")
+	a := &Authority{password: password, sshUserPassword: []byte("user")}
+	wipe := a.lockPasswords()
+	assert.Equals(t, []byte("pass"), a.password)
+	assert.Equals(t, []byte("user"), a.sshUserPassword)
+	assert.Nil(t, a.sshHostPassword)
+	assert.Nil(t, a.issuerPassword)
+
+	// The locked copy does not share memory with the given password.
+	a.password[0] = 'P'
+	assert.Equals(t, []byte("pass"), password)
+
+	wipe()
+	assert.Nil(t, a.password)
+	assert.Nil(t, a.sshUserPassword)
+	assert.Equals(t, []byte("pass"), password)
+}
+
+func TestAuthority_init_wipesPasswords(t *testing.T) {
+	a := testAuthority(t, WithPassword([]byte("pass")))
+	assert.Nil(t, a.password)
+	assert.Nil(t, a.sshHostPassword)
+	assert.Nil(t, a.sshUserPassword)
+}
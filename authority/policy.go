@@ -226,6 +226,10 @@ func (a *Authority) reloadPolicyEngines(ctx context.Context) error {
 			}
 		}
 		policyOptions = authPolicy.LinkedToCertificates(linkedPolicy)
+		// fall back to the policy in the runtime settings
+		if linkedPolicy == nil && a.settings != nil {
+			policyOptions = a.settings.Policy
+		}
 	} else {
 		policyOptions = a.config.AuthorityConfig.Policy
 	}
@@ -3,14 +3,21 @@ package provisioner
 import (
 	"context"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
 	"fmt"
 	"net"
+	"net/mail"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	"go.step.sm/linkedca"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/exp/slices"
+
+	"github.com/smallstep/certificates/errs"
 )
 
 // ACMEChallenge represents the supported acme challenges.
@@ -26,6 +33,11 @@ const (
 	TLS_ALPN_01 ACMEChallenge = "tls-alpn-01"
 	// DEVICE_ATTEST_01 is the device-attest-01 ACME challenge.
 	DEVICE_ATTEST_01 ACMEChallenge = "device-attest-01"
+	// EMAIL_REPLY_00 is the email-reply-00 ACME challenge defined in RFC 8823.
+	EMAIL_REPLY_00 ACMEChallenge = "email-reply-00"
+	// OIDC_01 is the oidc-01 ACME challenge used to validate the
+	// ssh-principal identifiers with an OpenID Connect ID token.
+	OIDC_01 ACMEChallenge = "oidc-01"
 )
 
 // String returns a normalized version of the challenge.
@@ -36,7 +48,7 @@ func (c ACMEChallenge) String() string {
 // Validate returns an error if the acme challenge is not a valid one.
 func (c ACMEChallenge) Validate() error {
 	switch ACMEChallenge(c.String()) {
-	case HTTP_01, DNS_01, TLS_ALPN_01, DEVICE_ATTEST_01:
+	case HTTP_01, DNS_01, TLS_ALPN_01, DEVICE_ATTEST_01, EMAIL_REPLY_00, OIDC_01:
 		return nil
 	default:
 		return fmt.Errorf("acme challenge %q is not supported", c)
@@ -59,6 +71,11 @@ const (
 
 	// TPM is the format used to enable device-attest-01 with TPMs.
 	TPM ACMEAttestationFormat = "tpm"
+
+	// ANDROID is the format used to enable the attested enrollment of keys
+	// generated in the hardware-backed keystore of Android devices. It is not
+	// enabled by default, and it's not supported by device-attest-01.
+	ANDROID ACMEAttestationFormat = "android-key"
 )
 
 // String returns a normalized version of the attestation format.
@@ -69,13 +86,945 @@ func (f ACMEAttestationFormat) String() string {
 // Validate returns an error if the attestation format is not a valid one.
 func (f ACMEAttestationFormat) Validate() error {
 	switch ACMEAttestationFormat(f.String()) {
-	case APPLE, STEP, TPM:
+	case APPLE, STEP, TPM, ANDROID:
 		return nil
 	default:
 		return fmt.Errorf("acme attestation format %q is not supported", f)
 	}
 }
 
+const (
+	// DefaultACMEMaxCNAMEHops is the default maximum number of CNAME records
+	// followed in the validation of dns-01 challenges.
+	DefaultACMEMaxCNAMEHops = 8
+	// DefaultACMEDNSLookupTimeout is the default timeout of each DNS query sent
+	// to the resolvers of the dns-01 options.
+	DefaultACMEDNSLookupTimeout = 10 * time.Second
+	// DefaultACMEHTTPDialTimeout is the default timeout of the connections
+	// opened in the validation of http-01 challenges.
+	DefaultACMEHTTPDialTimeout = 10 * time.Second
+	// DefaultACMEHTTPTimeout is the default timeout of the requests sent in
+	// the validation of http-01 challenges, including the redirects.
+	DefaultACMEHTTPTimeout = 30 * time.Second
+	// DefaultACMEHTTPMaxRedirects is the default maximum number of redirects
+	// followed in the validation of http-01 challenges.
+	DefaultACMEHTTPMaxRedirects = 10
+)
+
+// Protocols of the DNS resolvers used in the validation of dns-01
+// challenges.
+const (
+	// DNSResolverUDP sends the queries over UDP, and over TCP if the response
+	// is truncated.
+	DNSResolverUDP = "udp"
+	// DNSResolverTCP sends the queries over TCP.
+	DNSResolverTCP = "tcp"
+	// DNSResolverTLS sends the queries using DNS over TLS, RFC 7858.
+	DNSResolverTLS = "tls"
+	// DNSResolverHTTPS sends the queries using DNS over HTTPS, RFC 8484.
+	DNSResolverHTTPS = "https"
+)
+
+// ACMEDNSResolver is a DNS server used in the validation of dns-01
+// challenges. The address is the host and port of the server, or the URL of
+// the endpoint with DNS over HTTPS.
+type ACMEDNSResolver struct {
+	Protocol string
+	Address  string
+}
+
+// ParseACMEDNSResolver parses the address of a DNS resolver. The address can
+// be an IP or a host name with an optional port, 53 by default, or an URL
+// with one of the schemes udp://, tcp://, tls://, where the default port is
+// 853, or https://, for DNS over HTTPS.
+func ParseACMEDNSResolver(s string) (*ACMEDNSResolver, error) {
+	protocol, address := DNSResolverUDP, s
+	if i := strings.Index(s, "://"); i >= 0 {
+		protocol, address = strings.ToLower(s[:i]), s[i+3:]
+	}
+
+	port := "53"
+	switch protocol {
+	case DNSResolverUDP, DNSResolverTCP:
+	case DNSResolverTLS:
+		port = "853"
+	case DNSResolverHTTPS:
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" {
+			return nil, errors.Errorf("dns resolver %q is not a valid url", s)
+		}
+		return &ACMEDNSResolver{Protocol: protocol, Address: u.String()}, nil
+	default:
+		return nil, errors.Errorf("dns resolver %q has an unsupported protocol", s)
+	}
+
+	if host, p, err := net.SplitHostPort(address); err == nil {
+		address, port = host, p
+	}
+	if address == "" || strings.ContainsAny(address, "/[]") {
+		return nil, errors.Errorf("dns resolver %q is not valid", s)
+	}
+	return &ACMEDNSResolver{Protocol: protocol, Address: net.JoinHostPort(address, port)}, nil
+}
+
+// ACMEDNS01Options contains the options used in the validation of dns-01
+// challenges.
+type ACMEDNS01Options struct {
+	// FollowCNAME enables following the CNAME records of the _acme-challenge
+	// names, so challenges can be delegated to another zone.
+	FollowCNAME bool `json:"followCNAME,omitempty"`
+	// MaxCNAMEHops is the maximum number of CNAME records followed. Defaults
+	// to 8.
+	MaxCNAMEHops int `json:"maxCNAMEHops,omitempty"`
+	// Delegations contains the domains that must delegate their challenges
+	// to a validation zone. The CNAME records are always followed for these
+	// domains.
+	Delegations []ACMEDNS01Delegation `json:"delegations,omitempty"`
+	// Resolvers are the DNS servers used to look up the TXT and CNAME
+	// records, instead of the system resolver. They can use plain DNS, DNS
+	// over TLS with the tls:// prefix, or DNS over HTTPS using the URL of the
+	// endpoint.
+	Resolvers []string `json:"resolvers,omitempty"`
+	// LookupTimeout is the timeout of each query sent to the resolvers.
+	// Defaults to 10s.
+	LookupTimeout *Duration `json:"lookupTimeout,omitempty"`
+	// Quorum is the number of resolvers that must return a TXT record to
+	// accept it. By default the resolvers are tried in order, and the first
+	// answer is used.
+	Quorum int `json:"quorum,omitempty"`
+}
+
+// ACMEDNS01Delegation requires the _acme-challenge name of a domain and its
+// subdomains to be delegated to the given zone.
+type ACMEDNS01Delegation struct {
+	Domain string `json:"domain"`
+	Zone   string `json:"zone"`
+}
+
+// GetMaxCNAMEHops returns the maximum number of CNAME records followed.
+func (o *ACMEDNS01Options) GetMaxCNAMEHops() int {
+	if o == nil || o.MaxCNAMEHops == 0 {
+		return DefaultACMEMaxCNAMEHops
+	}
+	return o.MaxCNAMEHops
+}
+
+// GetDelegationZone returns the zone the challenges of the given domain must
+// be delegated to, or an empty string if there is none. If multiple
+// delegations match the domain, the most specific one is used.
+func (o *ACMEDNS01Options) GetDelegationZone(domain string) string {
+	if o == nil {
+		return ""
+	}
+	var zone string
+	var matched int
+	domain = normalizeDNSName(domain)
+	for _, d := range o.Delegations {
+		name := normalizeDNSName(d.Domain)
+		if isInDNSZone(domain, name) && len(name) > matched {
+			zone, matched = normalizeDNSName(d.Zone), len(name)
+		}
+	}
+	return zone
+}
+
+// GetResolvers returns the DNS servers used to look up the records, an empty
+// list if the system resolver is used.
+func (o *ACMEDNS01Options) GetResolvers() []*ACMEDNSResolver {
+	if o == nil {
+		return nil
+	}
+	resolvers := make([]*ACMEDNSResolver, 0, len(o.Resolvers))
+	for _, s := range o.Resolvers {
+		if r, err := ParseACMEDNSResolver(s); err == nil {
+			resolvers = append(resolvers, r)
+		}
+	}
+	return resolvers
+}
+
+// GetLookupTimeout returns the timeout of each query sent to the resolvers.
+func (o *ACMEDNS01Options) GetLookupTimeout() time.Duration {
+	if o == nil || o.LookupTimeout == nil || o.LookupTimeout.Duration == 0 {
+		return DefaultACMEDNSLookupTimeout
+	}
+	return o.LookupTimeout.Duration
+}
+
+// GetQuorum returns the number of resolvers that must return a TXT record.
+func (o *ACMEDNS01Options) GetQuorum() int {
+	if o == nil || o.Quorum == 0 {
+		return 1
+	}
+	return o.Quorum
+}
+
+// FollowsCNAME returns true if the CNAME records must be followed for the
+// given domain.
+func (o *ACMEDNS01Options) FollowsCNAME(domain string) bool {
+	return o != nil && (o.FollowCNAME || o.GetDelegationZone(domain) != "")
+}
+
+// Validate returns an error if the dns-01 options are not valid.
+func (o *ACMEDNS01Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.MaxCNAMEHops < 0 {
+		return errors.New("dns01.maxCNAMEHops cannot be negative")
+	}
+	for _, d := range o.Delegations {
+		if normalizeDNSName(d.Domain) == "" || normalizeDNSName(d.Zone) == "" {
+			return errors.New("dns01.delegations must contain a domain and a zone")
+		}
+	}
+	for _, s := range o.Resolvers {
+		if _, err := ParseACMEDNSResolver(s); err != nil {
+			return errors.Wrap(err, "dns01.resolvers is not valid")
+		}
+	}
+	switch {
+	case o.LookupTimeout != nil && o.LookupTimeout.Duration < 0:
+		return errors.New("dns01.lookupTimeout cannot be negative")
+	case o.Quorum < 0:
+		return errors.New("dns01.quorum cannot be negative")
+	case o.Quorum > 1 && o.Quorum > len(o.Resolvers):
+		return errors.New("dns01.quorum cannot be greater than the number of dns01.resolvers")
+	}
+	return nil
+}
+
+// ACMEHTTP01Options contains the options of the HTTP client used in the
+// validation of http-01 challenges.
+type ACMEHTTP01Options struct {
+	// DialTimeout is the timeout of each connection. Defaults to 10s.
+	DialTimeout *Duration `json:"dialTimeout,omitempty"`
+	// Timeout is the timeout of the whole request, including the redirects
+	// and the response body. Defaults to 30s.
+	Timeout *Duration `json:"timeout,omitempty"`
+	// MaxRedirects is the maximum number of redirects followed, 0 disables
+	// them. Defaults to 10. Each redirect must use http or https on the
+	// standard ports, and its address is filtered like the first one.
+	MaxRedirects *int `json:"maxRedirects,omitempty"`
+	// Proxy is the URL of the egress proxy used to send the requests, with
+	// the http, https or socks5 schemes.
+	Proxy string `json:"proxy,omitempty"`
+	// SourceInterface is the name or the IP address of the local interface
+	// the connections are opened from.
+	SourceInterface string `json:"sourceInterface,omitempty"`
+	// DenyPrivateAddresses rejects the connections to loopback, private,
+	// shared, link-local, multicast and unspecified addresses.
+	DenyPrivateAddresses bool `json:"denyPrivateAddresses,omitempty"`
+	// DeniedNetworks are the CIDRs of other networks the connections cannot
+	// be opened to.
+	DeniedNetworks []string `json:"deniedNetworks,omitempty"`
+}
+
+// GetDialTimeout returns the timeout of each connection.
+func (o *ACMEHTTP01Options) GetDialTimeout() time.Duration {
+	if o == nil || o.DialTimeout == nil || o.DialTimeout.Duration == 0 {
+		return DefaultACMEHTTPDialTimeout
+	}
+	return o.DialTimeout.Duration
+}
+
+// GetTimeout returns the timeout of the whole request.
+func (o *ACMEHTTP01Options) GetTimeout() time.Duration {
+	if o == nil || o.Timeout == nil || o.Timeout.Duration == 0 {
+		return DefaultACMEHTTPTimeout
+	}
+	return o.Timeout.Duration
+}
+
+// GetMaxRedirects returns the maximum number of redirects followed.
+func (o *ACMEHTTP01Options) GetMaxRedirects() int {
+	if o == nil || o.MaxRedirects == nil {
+		return DefaultACMEHTTPMaxRedirects
+	}
+	return *o.MaxRedirects
+}
+
+// GetProxy returns the URL of the egress proxy, nil if the requests are sent
+// directly.
+func (o *ACMEHTTP01Options) GetProxy() *url.URL {
+	if o == nil || o.Proxy == "" {
+		return nil
+	}
+	u, err := parseACMEHTTPProxy(o.Proxy)
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+// GetDeniedNetworks returns the networks the connections cannot be opened
+// to, not including the private ones.
+func (o *ACMEHTTP01Options) GetDeniedNetworks() []*net.IPNet {
+	if o == nil {
+		return nil
+	}
+	networks := make([]*net.IPNet, 0, len(o.DeniedNetworks))
+	for _, s := range o.DeniedNetworks {
+		if _, n, err := net.ParseCIDR(s); err == nil {
+			networks = append(networks, n)
+		}
+	}
+	return networks
+}
+
+// Validate returns an error if the http-01 options are not valid.
+func (o *ACMEHTTP01Options) Validate() error {
+	switch {
+	case o == nil:
+		return nil
+	case o.DialTimeout != nil && o.DialTimeout.Duration < 0:
+		return errors.New("http01.dialTimeout cannot be negative")
+	case o.Timeout != nil && o.Timeout.Duration < 0:
+		return errors.New("http01.timeout cannot be negative")
+	case o.MaxRedirects != nil && *o.MaxRedirects < 0:
+		return errors.New("http01.maxRedirects cannot be negative")
+	}
+	if o.Proxy != "" {
+		if _, err := parseACMEHTTPProxy(o.Proxy); err != nil {
+			return errors.Wrap(err, "http01.proxy is not valid")
+		}
+	}
+	for _, s := range o.DeniedNetworks {
+		if _, _, err := net.ParseCIDR(s); err != nil {
+			return errors.Wrap(err, "http01.deniedNetworks is not valid")
+		}
+	}
+	return nil
+}
+
+// parseACMEHTTPProxy parses the URL of an egress proxy.
+func parseACMEHTTPProxy(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5":
+		return nil, errors.Errorf("unsupported proxy scheme %q", u.Scheme)
+	case u.Host == "":
+		return nil, errors.Errorf("proxy %q does not have a host", s)
+	}
+	return u, nil
+}
+
+// isInDNSZone returns true if the given DNS name is the zone or one of its
+// subdomains. Both values must be normalized.
+func isInDNSZone(name, zone string) bool {
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+// normalizeDNSName returns the given DNS name in lower case and without the
+// trailing dot.
+func normalizeDNSName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// ACMECAAOptions contains the options used to check the Certification
+// Authority Authorization (CAA) records, RFC 8659, before validating
+// http-01, dns-01 and tls-alpn-01 challenges.
+type ACMECAAOptions struct {
+	// IssuerDomainNames are the names that identify the CA in the issue and
+	// issuewild records. Defaults to the caaIdentities of the provisioner.
+	IssuerDomainNames []string `json:"issuerDomainNames,omitempty"`
+	// SoftFail allows the validation of the challenges if the CAA records
+	// cannot be looked up. Records that forbid the issuance are always
+	// enforced.
+	SoftFail bool `json:"softFail,omitempty"`
+}
+
+// ACME token and lifetime defaults.
+const (
+	// DefaultACMETokenLength is the default number of alphanumeric characters
+	// of the challenge tokens.
+	DefaultACMETokenLength = 32
+	// MinACMETokenLength is the minimum number of alphanumeric characters of
+	// the challenge tokens, RFC 8555 requires at least 128 bits of entropy.
+	MinACMETokenLength = 22
+	// DefaultACMEOrderLifetime is the default time an order can be completed.
+	DefaultACMEOrderLifetime = 24 * time.Hour
+)
+
+// ACMEOrderOptions contains the options used in the creation of orders,
+// authorizations and challenges.
+type ACMEOrderOptions struct {
+	// TokenLength is the number of alphanumeric characters of the challenge
+	// tokens. Defaults to 32, and it cannot be less than 22.
+	TokenLength int `json:"tokenLength,omitempty"`
+	// OrderLifetime is the time a new order can be completed. Defaults to
+	// 24h.
+	OrderLifetime *Duration `json:"orderLifetime,omitempty"`
+	// AuthorizationLifetime is the time the authorizations of a new order, and
+	// their challenges, can be validated. Defaults to the order lifetime, and
+	// it cannot be greater.
+	AuthorizationLifetime *Duration `json:"authorizationLifetime,omitempty"`
+	// PreAuthorization enables the newAuthz endpoint, so clients can
+	// validate the identifiers before creating the orders.
+	PreAuthorization bool `json:"preAuthorization,omitempty"`
+	// AuthorizationReuse is the time a valid authorization can be used by
+	// the new orders of the same account, counting from its validation. The
+	// authorizations are not reused by default.
+	AuthorizationReuse *Duration `json:"authorizationReuse,omitempty"`
+	// Approval defers the issuance of the certificates until an
+	// administrator approves the orders.
+	Approval *ACMEApprovalOptions `json:"approval,omitempty"`
+	// AutoRenewal enables the Short-Term, Automatically Renewed (STAR)
+	// orders defined in RFC 8739.
+	AutoRenewal *ACMEAutoRenewalOptions `json:"autoRenewal,omitempty"`
+}
+
+// GetTokenLength returns the number of characters of the challenge tokens.
+func (o *ACMEOrderOptions) GetTokenLength() int {
+	if o == nil || o.TokenLength == 0 {
+		return DefaultACMETokenLength
+	}
+	return o.TokenLength
+}
+
+// GetOrderLifetime returns the time a new order can be completed.
+func (o *ACMEOrderOptions) GetOrderLifetime() time.Duration {
+	if o == nil || o.OrderLifetime == nil || o.OrderLifetime.Duration == 0 {
+		return DefaultACMEOrderLifetime
+	}
+	return o.OrderLifetime.Duration
+}
+
+// GetAuthorizationLifetime returns the time the authorizations of a new order
+// can be validated.
+func (o *ACMEOrderOptions) GetAuthorizationLifetime() time.Duration {
+	if o == nil || o.AuthorizationLifetime == nil || o.AuthorizationLifetime.Duration == 0 {
+		return o.GetOrderLifetime()
+	}
+	return o.AuthorizationLifetime.Duration
+}
+
+// IsPreAuthorizationEnabled returns true if the clients can create
+// authorizations with the newAuthz endpoint.
+func (o *ACMEOrderOptions) IsPreAuthorizationEnabled() bool {
+	return o != nil && o.PreAuthorization
+}
+
+// GetAuthorizationReuse returns the time a valid authorization can be used
+// by new orders, 0 if the authorizations are not reused.
+func (o *ACMEOrderOptions) GetAuthorizationReuse() time.Duration {
+	if o == nil || o.AuthorizationReuse == nil {
+		return 0
+	}
+	return o.AuthorizationReuse.Duration
+}
+
+// Validate returns an error if the order options are not valid.
+func (o *ACMEOrderOptions) Validate() error {
+	switch {
+	case o == nil:
+		return nil
+	case o.TokenLength < 0:
+		return errors.New("orders.tokenLength cannot be negative")
+	case o.TokenLength != 0 && o.TokenLength < MinACMETokenLength:
+		return errors.Errorf("orders.tokenLength cannot be less than %d", MinACMETokenLength)
+	case o.OrderLifetime != nil && o.OrderLifetime.Duration < 0:
+		return errors.New("orders.orderLifetime cannot be negative")
+	case o.AuthorizationLifetime != nil && o.AuthorizationLifetime.Duration < 0:
+		return errors.New("orders.authorizationLifetime cannot be negative")
+	case o.GetAuthorizationLifetime() > o.GetOrderLifetime():
+		return errors.New("orders.authorizationLifetime cannot be greater than orders.orderLifetime")
+	case o.AuthorizationReuse != nil && o.AuthorizationReuse.Duration < 0:
+		return errors.New("orders.authorizationReuse cannot be negative")
+	}
+	if err := o.Approval.Validate(); err != nil {
+		return err
+	}
+	return o.AutoRenewal.Validate()
+}
+
+// GetApprovalOptions returns the options of the orders that require an
+// approval.
+func (o *ACMEOrderOptions) GetApprovalOptions() *ACMEApprovalOptions {
+	if o == nil {
+		return nil
+	}
+	return o.Approval
+}
+
+// GetAutoRenewalOptions returns the options of the auto-renewal orders, nil
+// if they are not enabled.
+func (o *ACMEOrderOptions) GetAutoRenewalOptions() *ACMEAutoRenewalOptions {
+	if o == nil {
+		return nil
+	}
+	return o.AutoRenewal
+}
+
+// Auto-renewal defaults.
+const (
+	// DefaultACMEAutoRenewalMinLifetime is the default minimum lifetime of
+	// the certificates of the auto-renewal orders.
+	DefaultACMEAutoRenewalMinLifetime = time.Hour
+	// DefaultACMEAutoRenewalMaxDuration is the default maximum time between
+	// the start and the end dates of an auto-renewal order.
+	DefaultACMEAutoRenewalMaxDuration = 365 * 24 * time.Hour
+)
+
+// ACMEAutoRenewalOptions are the options of the Short-Term, Automatically
+// Renewed (STAR) orders. The certificates of these orders are renewed by the
+// CA until the end date of the order, or until the client cancels it, and
+// the latest one is always available in the star-certificate URL of the
+// order.
+type ACMEAutoRenewalOptions struct {
+	// Enabled allows the clients to request auto-renewal orders.
+	Enabled bool `json:"enabled"`
+	// MinLifetime is the minimum lifetime of the certificates. Defaults to
+	// 1h.
+	MinLifetime *Duration `json:"minLifetime,omitempty"`
+	// MaxDuration is the maximum time between the start and the end dates
+	// of an order. Defaults to 365d.
+	MaxDuration *Duration `json:"maxDuration,omitempty"`
+	// AllowCertificateGet allows the clients to request that the
+	// certificates are also available with unauthenticated GET requests.
+	AllowCertificateGet bool `json:"allowCertificateGet,omitempty"`
+}
+
+// IsEnabled returns true if the clients can request auto-renewal orders.
+func (o *ACMEAutoRenewalOptions) IsEnabled() bool {
+	return o != nil && o.Enabled
+}
+
+// GetMinLifetime returns the minimum lifetime of the certificates of the
+// auto-renewal orders.
+func (o *ACMEAutoRenewalOptions) GetMinLifetime() time.Duration {
+	if o == nil || o.MinLifetime == nil || o.MinLifetime.Duration == 0 {
+		return DefaultACMEAutoRenewalMinLifetime
+	}
+	return o.MinLifetime.Duration
+}
+
+// GetMaxDuration returns the maximum time between the start and the end
+// dates of an auto-renewal order.
+func (o *ACMEAutoRenewalOptions) GetMaxDuration() time.Duration {
+	if o == nil || o.MaxDuration == nil || o.MaxDuration.Duration == 0 {
+		return DefaultACMEAutoRenewalMaxDuration
+	}
+	return o.MaxDuration.Duration
+}
+
+// IsCertificateGetAllowed returns true if the certificates of the
+// auto-renewal orders can be fetched with unauthenticated GET requests.
+func (o *ACMEAutoRenewalOptions) IsCertificateGetAllowed() bool {
+	return o.IsEnabled() && o.AllowCertificateGet
+}
+
+// Validate returns an error if the auto-renewal options are not valid.
+func (o *ACMEAutoRenewalOptions) Validate() error {
+	switch {
+	case o == nil:
+		return nil
+	case o.MinLifetime != nil && o.MinLifetime.Duration < 0:
+		return errors.New("orders.autoRenewal.minLifetime cannot be negative")
+	case o.MaxDuration != nil && o.MaxDuration.Duration < 0:
+		return errors.New("orders.autoRenewal.maxDuration cannot be negative")
+	case o.GetMinLifetime() > o.GetMaxDuration():
+		return errors.New("orders.autoRenewal.minLifetime cannot be greater than orders.autoRenewal.maxDuration")
+	default:
+		return nil
+	}
+}
+
+// DefaultACMEApprovalRetryAfter is the default time a client should wait
+// before polling an order waiting for an approval.
+const DefaultACMEApprovalRetryAfter = time.Minute
+
+// ACMEApprovalOptions are the options of the orders that require an
+// approval. The finalized orders stay in the processing state until an
+// administrator approves or rejects them using the admin API.
+type ACMEApprovalOptions struct {
+	// Required defers the issuance of the certificates until the orders are
+	// approved.
+	Required bool `json:"required"`
+	// RetryAfter is the time sent in the Retry-After header of the orders
+	// waiting for an approval. Defaults to 1m.
+	RetryAfter *Duration `json:"retryAfter,omitempty"`
+	// Webhook is notified when an order needs an approval, when its
+	// certificate is ready, and when it is rejected.
+	Webhook *ACMEApprovalWebhook `json:"webhook,omitempty"`
+}
+
+// ACMEApprovalWebhook is the endpoint that receives the events of the orders
+// that require an approval. If the secret is set, the body is signed with
+// HMAC-SHA256 and the signature is sent in the X-Smallstep-Signature header.
+type ACMEApprovalWebhook struct {
+	URL         string `json:"url"`
+	Secret      string `json:"secret,omitempty"`
+	BearerToken string `json:"bearerToken,omitempty"`
+}
+
+// IsRequired returns true if the orders require an approval.
+func (o *ACMEApprovalOptions) IsRequired() bool {
+	return o != nil && o.Required
+}
+
+// GetRetryAfter returns the time a client should wait before polling an
+// order waiting for an approval.
+func (o *ACMEApprovalOptions) GetRetryAfter() time.Duration {
+	if o == nil || o.RetryAfter == nil || o.RetryAfter.Duration == 0 {
+		return DefaultACMEApprovalRetryAfter
+	}
+	return o.RetryAfter.Duration
+}
+
+// GetWebhook returns the webhook notified of the order events, or nil.
+func (o *ACMEApprovalOptions) GetWebhook() *ACMEApprovalWebhook {
+	if o == nil {
+		return nil
+	}
+	return o.Webhook
+}
+
+// Validate returns an error if the approval options are not valid.
+func (o *ACMEApprovalOptions) Validate() error {
+	switch {
+	case o == nil:
+		return nil
+	case o.RetryAfter != nil && o.RetryAfter.Duration < 0:
+		return errors.New("orders.approval.retryAfter cannot be negative")
+	case o.Webhook == nil:
+		return nil
+	}
+	if u, err := url.Parse(o.Webhook.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.Errorf("orders.approval.webhook.url %q is not valid", o.Webhook.URL)
+	}
+	if o.Webhook.Secret != "" {
+		if _, err := base64.StdEncoding.DecodeString(o.Webhook.Secret); err != nil {
+			return errors.New("orders.approval.webhook.secret must be base64 encoded")
+		}
+	}
+	return nil
+}
+
+const (
+	// DefaultACMEValidationAttempts is the default number of attempts to
+	// validate a challenge in the background.
+	DefaultACMEValidationAttempts = 5
+	// DefaultACMEValidationInterval is the default time between the first
+	// and the second attempt to validate a challenge.
+	DefaultACMEValidationInterval = 5 * time.Second
+	// DefaultACMEValidationMaxInterval is the default maximum time between
+	// two attempts to validate a challenge.
+	DefaultACMEValidationMaxInterval = time.Minute
+)
+
+// ACMEValidationOptions enables the validation of the http-01, dns-01 and
+// tls-alpn-01 challenges in the background. The challenges are in the
+// processing status while they are validated, and the failed attempts are
+// retried with an exponential backoff. A challenge is only invalid after all
+// the attempts fail.
+type ACMEValidationOptions struct {
+	// Attempts is the number of times a challenge is validated. Defaults to
+	// 5.
+	Attempts int `json:"attempts,omitempty"`
+	// Interval is the time between the first and the second attempt, it is
+	// doubled after each attempt. Defaults to 5s.
+	Interval *Duration `json:"interval,omitempty"`
+	// MaxInterval is the maximum time between two attempts. Defaults to 1m.
+	MaxInterval *Duration `json:"maxInterval,omitempty"`
+}
+
+// GetAttempts returns the number of times a challenge is validated.
+func (o *ACMEValidationOptions) GetAttempts() int {
+	if o == nil || o.Attempts == 0 {
+		return DefaultACMEValidationAttempts
+	}
+	return o.Attempts
+}
+
+// GetInterval returns the time between the first and the second attempt.
+func (o *ACMEValidationOptions) GetInterval() time.Duration {
+	if o == nil || o.Interval == nil || o.Interval.Duration == 0 {
+		return DefaultACMEValidationInterval
+	}
+	return o.Interval.Duration
+}
+
+// GetMaxInterval returns the maximum time between two attempts.
+func (o *ACMEValidationOptions) GetMaxInterval() time.Duration {
+	if o == nil || o.MaxInterval == nil || o.MaxInterval.Duration == 0 {
+		if d := o.GetInterval(); d > DefaultACMEValidationMaxInterval {
+			return d
+		}
+		return DefaultACMEValidationMaxInterval
+	}
+	return o.MaxInterval.Duration
+}
+
+// GetRetryInterval returns the time to wait after the given attempt.
+func (o *ACMEValidationOptions) GetRetryInterval(attempt int) time.Duration {
+	d, maxInterval := o.GetInterval(), o.GetMaxInterval()
+	for i := 1; i < attempt && d < maxInterval; i++ {
+		d *= 2
+	}
+	if d > maxInterval {
+		return maxInterval
+	}
+	return d
+}
+
+// Validate returns an error if the validation options are not valid.
+func (o *ACMEValidationOptions) Validate() error {
+	switch {
+	case o == nil:
+		return nil
+	case o.Attempts < 0:
+		return errors.New("validation.attempts cannot be negative")
+	case o.Interval != nil && o.Interval.Duration < 0:
+		return errors.New("validation.interval cannot be negative")
+	case o.MaxInterval != nil && o.MaxInterval.Duration < 0:
+		return errors.New("validation.maxInterval cannot be negative")
+	case o.GetMaxInterval() < o.GetInterval():
+		return errors.New("validation.maxInterval cannot be less than validation.interval")
+	default:
+		return nil
+	}
+}
+
+// ACMERateLimit limits the number of requests in a sliding window.
+type ACMERateLimit struct {
+	// Limit is the maximum number of requests in the window.
+	Limit int `json:"limit"`
+	// Window is the length of the sliding window.
+	Window Duration `json:"window"`
+}
+
+// Validate returns an error if the rate limit is not valid.
+func (l *ACMERateLimit) Validate(name string) error {
+	switch {
+	case l == nil:
+		return nil
+	case l.Limit <= 0:
+		return errors.Errorf("rateLimits.%s.limit must be greater than 0", name)
+	case l.Window.Duration <= 0:
+		return errors.Errorf("rateLimits.%s.window must be greater than 0", name)
+	default:
+		return nil
+	}
+}
+
+// ACMERateLimitOptions are the rate limits of the ACME endpoints. The
+// requests are counted in sliding windows stored in the database, a request
+// over the limit fails with a rateLimited error and a Retry-After header.
+type ACMERateLimitOptions struct {
+	// NewAccountsPerIP limits the accounts created from the same IP address.
+	NewAccountsPerIP *ACMERateLimit `json:"newAccountsPerIP,omitempty"`
+	// NewOrdersPerAccount limits the orders created by the same account.
+	NewOrdersPerAccount *ACMERateLimit `json:"newOrdersPerAccount,omitempty"`
+	// FailedValidationsPerHostname limits the failed validations of the
+	// challenges of the same hostname and account.
+	FailedValidationsPerHostname *ACMERateLimit `json:"failedValidationsPerHostname,omitempty"`
+}
+
+// GetNewAccountsPerIP returns the limit of the accounts created from the same
+// IP address, nil if there is no limit.
+func (o *ACMERateLimitOptions) GetNewAccountsPerIP() *ACMERateLimit {
+	if o == nil {
+		return nil
+	}
+	return o.NewAccountsPerIP
+}
+
+// GetNewOrdersPerAccount returns the limit of the orders created by the same
+// account, nil if there is no limit.
+func (o *ACMERateLimitOptions) GetNewOrdersPerAccount() *ACMERateLimit {
+	if o == nil {
+		return nil
+	}
+	return o.NewOrdersPerAccount
+}
+
+// GetFailedValidationsPerHostname returns the limit of the failed validations
+// of the same hostname and account, nil if there is no limit.
+func (o *ACMERateLimitOptions) GetFailedValidationsPerHostname() *ACMERateLimit {
+	if o == nil {
+		return nil
+	}
+	return o.FailedValidationsPerHostname
+}
+
+// Validate returns an error if the rate limits are not valid.
+func (o *ACMERateLimitOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if err := o.NewAccountsPerIP.Validate("newAccountsPerIP"); err != nil {
+		return err
+	}
+	if err := o.NewOrdersPerAccount.Validate("newOrdersPerAccount"); err != nil {
+		return err
+	}
+	return o.FailedValidationsPerHostname.Validate("failedValidationsPerHostname")
+}
+
+// DefaultACMEDelegationTimeout is the default timeout of the requests to an
+// external validator.
+var DefaultACMEDelegationTimeout = 30 * time.Second
+
+// ACMEChallengeDelegation delegates the validation of the challenges of some
+// identifiers to an external validator. The validator receives the challenge,
+// and its key authorization, and responds whether the client has proven the
+// ownership of the identifier. If the secret is set, the body is signed with
+// HMAC-SHA256 and the signature is sent in the X-Smallstep-Signature header.
+type ACMEChallengeDelegation struct {
+	// Identifiers are the names or IP addresses delegated, a name starting
+	// with "*." matches all the subdomains of the name, e.g. *.partner.example
+	// matches foo.partner.example and foo.bar.partner.example.
+	Identifiers []string  `json:"identifiers"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	BearerToken string    `json:"bearerToken,omitempty"`
+	Timeout     *Duration `json:"timeout,omitempty"`
+}
+
+// Matches returns true if the given identifier value is delegated.
+func (d *ACMEChallengeDelegation) Matches(value string) bool {
+	value = strings.ToLower(strings.TrimSuffix(value, "."))
+	for _, pattern := range d.Identifiers {
+		pattern = strings.ToLower(pattern)
+		if suffix := strings.TrimPrefix(pattern, "*"); suffix != pattern {
+			if len(value) > len(suffix) && strings.HasSuffix(value, suffix) {
+				return true
+			}
+		} else if value == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTimeout returns the timeout of the requests to the external validator.
+func (d *ACMEChallengeDelegation) GetTimeout() time.Duration {
+	if d == nil || d.Timeout == nil || d.Timeout.Duration == 0 {
+		return DefaultACMEDelegationTimeout
+	}
+	return d.Timeout.Duration
+}
+
+// Validate returns an error if the delegation is not valid.
+func (d *ACMEChallengeDelegation) Validate() error {
+	if len(d.Identifiers) == 0 {
+		return errors.New("challengeDelegations.identifiers cannot be empty")
+	}
+	for _, pattern := range d.Identifiers {
+		if name := strings.TrimPrefix(pattern, "*."); name == "" || strings.Contains(name, "*") {
+			return errors.Errorf("challengeDelegations.identifiers %q is not valid", pattern)
+		}
+	}
+	if u, err := url.Parse(d.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.Errorf("challengeDelegations.url %q is not valid", d.URL)
+	}
+	if d.Secret != "" {
+		if _, err := base64.StdEncoding.DecodeString(d.Secret); err != nil {
+			return errors.New("challengeDelegations.secret must be base64 encoded")
+		}
+	}
+	if d.Timeout != nil && d.Timeout.Duration < 0 {
+		return errors.New("challengeDelegations.timeout cannot be negative")
+	}
+	return nil
+}
+
+// ACMEEmailReplyOptions are the options of the email-reply-00 challenges used
+// to validate the email identifiers, as defined in RFC 8823. The challenge
+// emails are sent using the SMTP relay, and the service receiving the replies
+// sent to the From address must post them to the email-reply endpoint of the
+// provisioner, using the secret as a bearer token.
+type ACMEEmailReplyOptions struct {
+	// From is the address used to send the challenge emails, the replies
+	// are sent to it.
+	From string `json:"from"`
+	// Secret is the bearer token required to post the replies.
+	Secret string `json:"secret"`
+	// AuthServID, if set, requires the replies to have an
+	// Authentication-Results header field added by the given authentication
+	// service, with a DKIM or DMARC pass for the domain of the sender.
+	AuthServID string `json:"authServID,omitempty"`
+}
+
+// Validate returns an error if the options are not valid.
+func (o *ACMEEmailReplyOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if addr, err := mail.ParseAddress(o.From); err != nil || addr.Name != "" || addr.Address != o.From {
+		return errors.Errorf("emailReply.from %q is not valid", o.From)
+	}
+	if o.Secret == "" {
+		return errors.New("emailReply.secret cannot be empty")
+	}
+	return nil
+}
+
+// ACMEOIDCOptions are the options of the oidc-01 challenges used to validate
+// the ssh-principal identifiers. The client answers the challenge with an ID
+// token of the OIDC provisioner, its nonce must be the base64url-encoded
+// SHA-256 digest of the key authorization. The principal must be one of the
+// principals of the identity of the token, unless the token is from an admin.
+type ACMEOIDCOptions struct {
+	// Provisioner is the name of the OIDC provisioner that validates the ID
+	// tokens.
+	Provisioner string `json:"provisioner"`
+}
+
+// Validate returns an error if the options are not valid.
+func (o *ACMEOIDCOptions) Validate() error {
+	if o != nil && o.Provisioner == "" {
+		return errors.New("oidc.provisioner cannot be empty")
+	}
+	return nil
+}
+
+// ACMEClientCertificateOptions requires the ACME requests to be made over mTLS
+// with a client certificate issued by one of the given roots. The subject of
+// the client certificate used to create an account is bound to it, and the
+// following requests of the account must use a client certificate with the
+// same subject.
+//
+// The roots are trusted for client authentication by the CA server, but the
+// certificates that only verify with them are ignored outside the ACME API.
+// Changes to the roots require a restart of the CA.
+type ACMEClientCertificateOptions struct {
+	// Roots is a bundle of root certificates in PEM format used to verify
+	// the client certificates.
+	Roots []byte `json:"roots"`
+	roots []*x509.Certificate
+	pool  *x509.CertPool
+}
+
+// Validate returns an error if the options are not valid. It parses the
+// roots.
+func (o *ACMEClientCertificateOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	o.roots, o.pool = nil, x509.NewCertPool()
+	for rest := o.Roots; len(rest) > 0; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return errors.New("error parsing clientCertificate.roots: malformed certificate")
+		}
+		o.roots = append(o.roots, cert)
+		o.pool.AddCert(cert)
+	}
+	if len(o.roots) == 0 {
+		return errors.New("error parsing clientCertificate.roots: no certificates found")
+	}
+	return nil
+}
+
 // ACME is the acme provisioner type, an entity that can authorize the ACME
 // provisioning flow.
 type ACME struct {
@@ -108,12 +1057,45 @@ type ACME struct {
 	// provisioner. If this value is not set the default apple, step and tpm
 	// will be used.
 	AttestationFormats []ACMEAttestationFormat `json:"attestationFormats,omitempty"`
+	// DNS01 contains the options used in the validation of dns-01
+	// challenges.
+	DNS01 *ACMEDNS01Options `json:"dns01,omitempty"`
+	// HTTP01 contains the options of the HTTP client used in the validation
+	// of http-01 challenges.
+	HTTP01 *ACMEHTTP01Options `json:"http01,omitempty"`
+	// CAA enables the verification of the CAA records of the identifiers
+	// before validating their challenges.
+	CAA *ACMECAAOptions `json:"caa,omitempty"`
+	// Orders contains the options used in the creation of orders,
+	// authorizations and challenges, like the length of the challenge
+	// tokens and their lifetimes.
+	Orders *ACMEOrderOptions `json:"orders,omitempty"`
+	// Validation enables the validation of the challenges in the background,
+	// retrying the failed attempts.
+	Validation *ACMEValidationOptions `json:"validation,omitempty"`
+	// RateLimits limits the accounts, orders and failed validations of the
+	// ACME clients.
+	RateLimits *ACMERateLimitOptions `json:"rateLimits,omitempty"`
+	// ChallengeDelegations delegates the validation of the http-01, dns-01
+	// and tls-alpn-01 challenges of some identifiers to external validators.
+	// The first delegation matching the identifier is used.
+	ChallengeDelegations []*ACMEChallengeDelegation `json:"challengeDelegations,omitempty"`
+	// EmailReply contains the options of the email-reply-00 challenges, they
+	// are required to enable the challenge.
+	EmailReply *ACMEEmailReplyOptions `json:"emailReply,omitempty"`
+	// OIDC contains the options of the oidc-01 challenges, they are required
+	// to enable the challenge.
+	OIDC *ACMEOIDCOptions `json:"oidc,omitempty"`
 	// AttestationRoots contains a bundle of root certificates in PEM format
 	// that will be used to verify the attestation certificates. If provided,
 	// this bundle will be used even for well-known CAs like Apple and Yubico.
-	AttestationRoots    []byte   `json:"attestationRoots,omitempty"`
-	Claims              *Claims  `json:"claims,omitempty"`
-	Options             *Options `json:"options,omitempty"`
+	AttestationRoots []byte `json:"attestationRoots,omitempty"`
+	// ClientCertificate requires the ACME requests to be made over mTLS with
+	// a client certificate issued by the configured roots, and binds the
+	// accounts to the subject of the certificate.
+	ClientCertificate   *ACMEClientCertificateOptions `json:"clientCertificate,omitempty"`
+	Claims              *Claims                       `json:"claims,omitempty"`
+	Options             *Options                      `json:"options,omitempty"`
 	attestationRootPool *x509.CertPool
 	ctl                 *Controller
 }
@@ -163,6 +1145,23 @@ func (p *ACME) DefaultTLSCertDuration() time.Duration {
 	return p.ctl.Claimer.DefaultTLSCertDuration()
 }
 
+// DefaultUserSSHCertDuration returns the default SSH user cert duration
+// enforced by the provisioner.
+func (p *ACME) DefaultUserSSHCertDuration() time.Duration {
+	return p.ctl.Claimer.DefaultUserSSHCertDuration()
+}
+
+// GetMaxOrderIdentifiers returns the maximum number of identifiers in an
+// order, or 0 if it is not limited. An order cannot have more identifiers than
+// the subject alternative names allowed in a certificate.
+func (p *ACME) GetMaxOrderIdentifiers() int {
+	n, sans := p.ctl.Claimer.MaxOrderIdentifiers(), p.ctl.Claimer.MaxCertificateSANs()
+	if sans > 0 && (n == 0 || sans < n) {
+		return sans
+	}
+	return n
+}
+
 // Init initializes and validates the fields of an ACME type.
 func (p *ACME) Init(config Config) (err error) {
 	switch {
@@ -182,6 +1181,48 @@ func (p *ACME) Init(config Config) (err error) {
 			return err
 		}
 	}
+	if err := p.DNS01.Validate(); err != nil {
+		return err
+	}
+	if err := p.HTTP01.Validate(); err != nil {
+		return err
+	}
+	if err := p.Validation.Validate(); err != nil {
+		return err
+	}
+	if err := p.Orders.Validate(); err != nil {
+		return err
+	}
+	if err := p.RateLimits.Validate(); err != nil {
+		return err
+	}
+	for _, d := range p.ChallengeDelegations {
+		if err := d.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := p.EmailReply.Validate(); err != nil {
+		return err
+	}
+	if p.EmailReply == nil && slices.ContainsFunc(p.Challenges, func(c ACMEChallenge) bool {
+		return c.String() == string(EMAIL_REPLY_00)
+	}) {
+		return errors.New("emailReply is required to enable the email-reply-00 challenge")
+	}
+	if err := p.OIDC.Validate(); err != nil {
+		return err
+	}
+	if err := p.ClientCertificate.Validate(); err != nil {
+		return err
+	}
+	if p.OIDC == nil && slices.ContainsFunc(p.Challenges, func(c ACMEChallenge) bool {
+		return c.String() == string(OIDC_01)
+	}) {
+		return errors.New("oidc is required to enable the oidc-01 challenge")
+	}
+	if p.CAA != nil && len(p.CAA.IssuerDomainNames) == 0 && len(p.CaaIdentities) == 0 {
+		return errors.New("caa.issuerDomainNames or caaIdentities are required")
+	}
 
 	// Parse attestation roots.
 	// The pool will be nil if there are no roots.
@@ -218,6 +1259,11 @@ const (
 	IP ACMEIdentifierType = "ip"
 	// DNS is the ACME dns identifier type
 	DNS ACMEIdentifierType = "dns"
+	// Email is the ACME email identifier type defined in RFC 8823
+	Email ACMEIdentifierType = "email"
+	// SSHPrincipal is the ACME identifier type of the principals of SSH user
+	// certificates.
+	SSHPrincipal ACMEIdentifierType = "ssh-principal"
 )
 
 // ACMEIdentifier encodes ACME Order Identifiers
@@ -229,6 +1275,21 @@ type ACMEIdentifier struct {
 // AuthorizeOrderIdentifier verifies the provisioner is allowed to issue a
 // certificate for an ACME Order Identifier.
 func (p *ACME) AuthorizeOrderIdentifier(_ context.Context, identifier ACMEIdentifier) error {
+	// ssh-principal identifiers are evaluated with the SSH user policy
+	if identifier.Type == SSHPrincipal {
+		if !p.ctl.Claimer.IsSSHCAEnabled() {
+			return errors.Errorf("sshCA is disabled for acme provisioner '%s'", p.GetName())
+		}
+		userPolicy := p.ctl.getPolicy().getSSHUser()
+		if userPolicy == nil {
+			return nil
+		}
+		return userPolicy.IsSSHCertificateAllowed(&ssh.Certificate{
+			CertType:        ssh.UserCert,
+			ValidPrincipals: []string{identifier.Value},
+		})
+	}
+
 	x509Policy := p.ctl.getPolicy().getX509()
 
 	// identifier is allowed if no policy is configured
@@ -236,13 +1297,15 @@ func (p *ACME) AuthorizeOrderIdentifier(_ context.Context, identifier ACMEIdenti
 		return nil
 	}
 
-	// assuming only valid identifiers (IP or DNS) are provided
+	// assuming only valid identifiers (IP, DNS or email) are provided
 	var err error
 	switch identifier.Type {
 	case IP:
 		err = x509Policy.IsIPAllowed(net.ParseIP(identifier.Value))
 	case DNS:
 		err = x509Policy.IsDNSAllowed(identifier.Value)
+	case Email:
+		err = x509Policy.AreSANsAllowed([]string{identifier.Value})
 	default:
 		err = fmt.Errorf("invalid ACME identifier type '%s' provided", identifier.Type)
 	}
@@ -263,6 +1326,7 @@ func (p *ACME) AuthorizeSign(context.Context, string) ([]SignOption, error) {
 		// validators
 		defaultPublicKeyValidator{},
 		newValidityValidator(p.ctl.Claimer.MinTLSCertDuration(), p.ctl.Claimer.MaxTLSCertDuration()),
+		newMaxSANsValidator(p.ctl.Claimer.MaxCertificateSANs()),
 		newX509NamePolicyValidator(p.ctl.getPolicy().getX509()),
 		p.ctl.newWebhookController(nil, linkedca.Webhook_X509),
 	}
@@ -270,6 +1334,39 @@ func (p *ACME) AuthorizeSign(context.Context, string) ([]SignOption, error) {
 	return opts, nil
 }
 
+// AuthorizeSSHSign returns the modifiers and validators of the SSH user
+// certificates signed for the orders of ssh-principal identifiers. Like
+// AuthorizeSign, the validation is handled in the ACME protocol, and the
+// templates are defined by the ACME server.
+//
+// ACME provisioners cannot be used with one-time tokens, the certificates can
+// only be signed through orders.
+func (p *ACME) AuthorizeSSHSign(_ context.Context, token string) ([]SignOption, error) {
+	if token != "" {
+		return nil, errs.Unauthorized("acme.AuthorizeSSHSign; acme provisioner '%s' does not accept tokens", p.GetName())
+	}
+	if !p.ctl.Claimer.IsSSHCAEnabled() {
+		return nil, errs.Unauthorized("acme.AuthorizeSSHSign; sshCA is disabled for acme provisioner '%s'", p.GetName())
+	}
+	return []SignOption{
+		p,
+		// Only user certificates can be signed.
+		sshCertOptionsValidator(SignSSHOptions{CertType: SSHUserCert}),
+		// Set the validity bounds if not set.
+		&sshDefaultDuration{p.ctl.Claimer},
+		// Validate public key
+		&sshDefaultPublicKeyValidator{},
+		// Validate the validity period.
+		&sshCertValidityValidator{p.ctl.Claimer},
+		// Require and validate all the default fields in the SSH certificate.
+		&sshCertDefaultValidator{},
+		// Ensure that all principal names are allowed
+		newSSHNamePolicyValidator(nil, p.ctl.getPolicy().getSSHUser()),
+		// Call webhooks
+		p.ctl.newWebhookController(nil, linkedca.Webhook_SSH),
+	}, nil
+}
+
 // AuthorizeRevoke is called just before the certificate is to be revoked by
 // the CA. It can be used to authorize revocation of a certificate. With the
 // ACME protocol, revocation authorization is specified and performed as part
@@ -322,6 +1419,134 @@ func (p *ACME) IsAttestationFormatEnabled(_ context.Context, format ACMEAttestat
 	return false
 }
 
+// GetDNS01Options returns the options used in the validation of dns-01
+// challenges.
+func (p *ACME) GetDNS01Options() *ACMEDNS01Options {
+	return p.DNS01
+}
+
+// GetHTTP01Options returns the options of the HTTP client used in the
+// validation of http-01 challenges.
+func (p *ACME) GetHTTP01Options() *ACMEHTTP01Options {
+	return p.HTTP01
+}
+
+// GetEmailReplyOptions returns the options of the email-reply-00 challenges.
+func (p *ACME) GetEmailReplyOptions() *ACMEEmailReplyOptions {
+	return p.EmailReply
+}
+
+// GetOIDCOptions returns the options of the oidc-01 challenges.
+func (p *ACME) GetOIDCOptions() *ACMEOIDCOptions {
+	return p.OIDC
+}
+
+// GetCAAOptions returns the options used to check the CAA records, or nil if
+// they are not checked. The issuer domain names default to the CAA
+// identities.
+func (p *ACME) GetCAAOptions() *ACMECAAOptions {
+	if p.CAA == nil || len(p.CAA.IssuerDomainNames) > 0 {
+		return p.CAA
+	}
+	return &ACMECAAOptions{
+		IssuerDomainNames: p.CaaIdentities,
+		SoftFail:          p.CAA.SoftFail,
+	}
+}
+
+// GetOrderOptions returns the options used in the creation of orders,
+// authorizations and challenges.
+func (p *ACME) GetOrderOptions() *ACMEOrderOptions {
+	return p.Orders
+}
+
+// GetApprovalOptions returns the options of the orders that require an
+// approval, nil if the certificates are issued when the orders are
+// finalized.
+func (p *ACME) GetApprovalOptions() *ACMEApprovalOptions {
+	return p.Orders.GetApprovalOptions()
+}
+
+// GetAutoRenewalOptions returns the options of the auto-renewal orders, nil
+// if they are not enabled.
+func (p *ACME) GetAutoRenewalOptions() *ACMEAutoRenewalOptions {
+	return p.Orders.GetAutoRenewalOptions()
+}
+
+// GetRateLimitOptions returns the rate limits of the ACME endpoints, nil if
+// the requests are not limited.
+func (p *ACME) GetRateLimitOptions() *ACMERateLimitOptions {
+	return p.RateLimits
+}
+
+// GetChallengeDelegation returns the delegation of the challenges of the given
+// identifier value, nil if the challenges are validated by the CA.
+func (p *ACME) GetChallengeDelegation(value string) *ACMEChallengeDelegation {
+	for _, d := range p.ChallengeDelegations {
+		if d.Matches(value) {
+			return d
+		}
+	}
+	return nil
+}
+
+// GetValidationOptions returns the options of the validation of the
+// challenges in the background, nil if the challenges are validated in the
+// request.
+func (p *ACME) GetValidationOptions() *ACMEValidationOptions {
+	return p.Validation
+}
+
+// GetExternalAccountKeys returns the HMAC keys that can be used to sign the
+// External Account Bindings of the given key. These are the stored HMAC key
+// and the ones of the External Account Binding credentials valid at this time.
+func (p *ACME) GetExternalAccountKeys(keyID string, hmacKey []byte) ([][]byte, error) {
+	creds, err := p.ctl.GetCredentials(CredentialEABKey)
+	if err != nil {
+		return nil, err
+	}
+	return activeHmacKeys(creds, keyID, hmacKey, time.Now()), nil
+}
+
+// RequireClientCertificate returns true if the ACME requests must be made with
+// a client certificate issued by the configured roots.
+func (p *ACME) RequireClientCertificate() bool {
+	return p.ClientCertificate != nil
+}
+
+// GetClientCertificateRoots returns the roots of the client certificates of
+// the ACME requests.
+func (p *ACME) GetClientCertificateRoots() []*x509.Certificate {
+	if p.ClientCertificate == nil {
+		return nil
+	}
+	return p.ClientCertificate.roots
+}
+
+// VerifyClientCertificate verifies that the first certificate in the given
+// chain is a client certificate issued by the configured roots, the rest of
+// the chain are the intermediates sent by the client.
+func (p *ACME) VerifyClientCertificate(chain []*x509.Certificate) error {
+	if p.ClientCertificate == nil {
+		return nil
+	}
+	if len(chain) == 0 {
+		return errors.New("client certificate is required")
+	}
+	intermediates := x509.NewCertPool()
+	for _, crt := range chain[1:] {
+		intermediates.AddCert(crt)
+	}
+	if _, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         p.ClientCertificate.pool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return errors.Wrap(err, "client certificate is not valid")
+	}
+	return nil
+}
+
 // GetAttestationRoots returns certificate pool with the configured attestation
 // roots and reports if the pool contains at least one certificate.
 //
@@ -0,0 +1,39 @@
+package provisioner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestACME_GetCAAOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		p    *ACME
+		want *ACMECAAOptions
+	}{
+		{"disabled", &ACME{CaaIdentities: []string{"ca.example.net"}}, nil},
+		{"issuer domain names", &ACME{CaaIdentities: []string{"ca.example.net"}, CAA: &ACMECAAOptions{IssuerDomainNames: []string{"ca.example.org"}}},
+			&ACMECAAOptions{IssuerDomainNames: []string{"ca.example.org"}}},
+		{"caa identities", &ACME{CaaIdentities: []string{"ca.example.net"}, CAA: &ACMECAAOptions{SoftFail: true}},
+			&ACMECAAOptions{IssuerDomainNames: []string{"ca.example.net"}, SoftFail: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.GetCAAOptions(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ACME.GetCAAOptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACME_Init_caa(t *testing.T) {
+	config := Config{Claims: globalProvisionerClaims}
+	p := &ACME{Type: "ACME", Name: "acme", CAA: &ACMECAAOptions{}}
+	if err := p.Init(config); err == nil {
+		t.Error("ACME.Init() error = nil, want error")
+	}
+	p = &ACME{Type: "ACME", Name: "acme", CaaIdentities: []string{"ca.example.net"}, CAA: &ACMECAAOptions{}}
+	if err := p.Init(config); err != nil {
+		t.Errorf("ACME.Init() error = %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package provisioner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"go.step.sm/crypto/minica"
+)
+
+func newClientCertificate(t *testing.T, ca *minica.CA, usage x509.ExtKeyUsage) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := ca.Sign(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client.internal"},
+		PublicKey:   key.Public(),
+		NotBefore:   time.Now().Add(-time.Minute),
+		NotAfter:    time.Now().Add(time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{usage},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+func TestACMEClientCertificateOptions_Validate(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Root.Raw})
+
+	tests := []struct {
+		name    string
+		opts    *ACMEClientCertificateOptions
+		want    int
+		wantErr bool
+	}{
+		{"nil", nil, 0, false},
+		{"ok", &ACMEClientCertificateOptions{Roots: roots}, 1, false},
+		{"fail empty", &ACMEClientCertificateOptions{}, 0, true},
+		{"fail malformed", &ACMEClientCertificateOptions{Roots: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("foo")})}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("ACMEClientCertificateOptions.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			p := &ACME{ClientCertificate: tt.opts}
+			if got := len(p.GetClientCertificateRoots()); !tt.wantErr && got != tt.want {
+				t.Errorf("ACME.GetClientCertificateRoots() = %d roots, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACME_VerifyClientCertificate(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &ACMEClientCertificateOptions{
+		Roots: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Root.Raw}),
+	}
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	client := newClientCertificate(t, ca, x509.ExtKeyUsageClientAuth)
+	tests := []struct {
+		name    string
+		p       *ACME
+		chain   []*x509.Certificate
+		wantErr bool
+	}{
+		{"ok not required", &ACME{}, nil, false},
+		{"ok", &ACME{ClientCertificate: opts}, []*x509.Certificate{client, ca.Intermediate}, false},
+		{"fail missing", &ACME{ClientCertificate: opts}, nil, true},
+		{"fail missing intermediate", &ACME{ClientCertificate: opts}, []*x509.Certificate{client}, true},
+		{"fail other root", &ACME{ClientCertificate: opts}, []*x509.Certificate{newClientCertificate(t, other, x509.ExtKeyUsageClientAuth), other.Intermediate}, true},
+		{"fail server auth", &ACME{ClientCertificate: opts}, []*x509.Certificate{newClientCertificate(t, ca, x509.ExtKeyUsageServerAuth), ca.Intermediate}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.RequireClientCertificate(); got != (tt.p.ClientCertificate != nil) {
+				t.Errorf("ACME.RequireClientCertificate() = %v", got)
+			}
+			if err := tt.p.VerifyClientCertificate(tt.chain); (err != nil) != tt.wantErr {
+				t.Errorf("ACME.VerifyClientCertificate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package provisioner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestACMEChallengeDelegation_Matches(t *testing.T) {
+	d := &ACMEChallengeDelegation{Identifiers: []string{"*.partner.example", "exact.example", "10.0.0.1"}}
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"foo.partner.example", true},
+		{"foo.bar.partner.example", true},
+		{"FOO.Partner.Example.", true},
+		{"partner.example", false},
+		{"foopartner.example", false},
+		{"exact.example", true},
+		{"foo.exact.example", false},
+		{"10.0.0.1", true},
+		{"10.0.0.2", false},
+	}
+	for _, tt := range tests {
+		if got := d.Matches(tt.value); got != tt.want {
+			t.Errorf("ACMEChallengeDelegation.Matches(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	p := &ACME{ChallengeDelegations: []*ACMEChallengeDelegation{d}}
+	if got := p.GetChallengeDelegation("foo.partner.example"); got != d {
+		t.Errorf("ACME.GetChallengeDelegation() = %v, want %v", got, d)
+	}
+	if got := p.GetChallengeDelegation("foo.example"); got != nil {
+		t.Errorf("ACME.GetChallengeDelegation() = %v, want nil", got)
+	}
+}
+
+func TestACMEChallengeDelegation_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		d           *ACMEChallengeDelegation
+		wantTimeout time.Duration
+		wantErr     bool
+	}{
+		{"ok", &ACMEChallengeDelegation{Identifiers: []string{"*.partner.example"}, URL: "https://validator.example.com"}, DefaultACMEDelegationTimeout, false},
+		{"ok timeout", &ACMEChallengeDelegation{Identifiers: []string{"partner.example"}, URL: "http://validator", Secret: "c2VjcmV0", Timeout: &Duration{Duration: time.Second}}, time.Second, false},
+		{"fail identifiers", &ACMEChallengeDelegation{URL: "https://validator.example.com"}, DefaultACMEDelegationTimeout, true},
+		{"fail wildcard", &ACMEChallengeDelegation{Identifiers: []string{"foo.*.example"}, URL: "https://validator.example.com"}, DefaultACMEDelegationTimeout, true},
+		{"fail empty wildcard", &ACMEChallengeDelegation{Identifiers: []string{"*."}, URL: "https://validator.example.com"}, DefaultACMEDelegationTimeout, true},
+		{"fail url", &ACMEChallengeDelegation{Identifiers: []string{"partner.example"}, URL: "ftp://validator.example.com"}, DefaultACMEDelegationTimeout, true},
+		{"fail secret", &ACMEChallengeDelegation{Identifiers: []string{"partner.example"}, URL: "https://validator.example.com", Secret: "%%%"}, DefaultACMEDelegationTimeout, true},
+		{"fail timeout", &ACMEChallengeDelegation{Identifiers: []string{"partner.example"}, URL: "https://validator.example.com", Timeout: &Duration{Duration: -time.Second}}, -time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.d.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("ACMEChallengeDelegation.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tt.d.GetTimeout(); got != tt.wantTimeout {
+				t.Errorf("ACMEChallengeDelegation.GetTimeout() = %v, want %v", got, tt.wantTimeout)
+			}
+		})
+	}
+}
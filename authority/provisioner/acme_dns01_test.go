@@ -0,0 +1,137 @@
+package provisioner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestACMEDNS01Options(t *testing.T) {
+	opts := &ACMEDNS01Options{
+		Delegations: []ACMEDNS01Delegation{
+			{Domain: "example.com", Zone: "acme.example.net."},
+			{Domain: "Internal.Example.com", Zone: "internal.acme.example.net"},
+		},
+	}
+	tests := []struct {
+		name        string
+		opts        *ACMEDNS01Options
+		domain      string
+		wantZone    string
+		wantFollows bool
+	}{
+		{"nil", nil, "example.com", "", false},
+		{"follow", &ACMEDNS01Options{FollowCNAME: true}, "example.com", "", true},
+		{"domain", opts, "example.com", "acme.example.net", true},
+		{"subdomain", opts, "www.example.com", "acme.example.net", true},
+		{"most specific", opts, "host.internal.example.com.", "internal.acme.example.net", true},
+		{"no match", opts, "example.org", "", false},
+		{"no suffix match", opts, "badexample.com", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.GetDelegationZone(tt.domain); got != tt.wantZone {
+				t.Errorf("ACMEDNS01Options.GetDelegationZone() = %v, want %v", got, tt.wantZone)
+			}
+			if got := tt.opts.FollowsCNAME(tt.domain); got != tt.wantFollows {
+				t.Errorf("ACMEDNS01Options.FollowsCNAME() = %v, want %v", got, tt.wantFollows)
+			}
+		})
+	}
+}
+
+func TestACMEDNS01Options_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     *ACMEDNS01Options
+		wantHops int
+		wantErr  bool
+	}{
+		{"nil", nil, DefaultACMEMaxCNAMEHops, false},
+		{"ok", &ACMEDNS01Options{MaxCNAMEHops: 2, Delegations: []ACMEDNS01Delegation{{Domain: "example.com", Zone: "acme.example.net"}}}, 2, false},
+		{"fail hops", &ACMEDNS01Options{MaxCNAMEHops: -1}, -1, true},
+		{"fail domain", &ACMEDNS01Options{Delegations: []ACMEDNS01Delegation{{Zone: "acme.example.net"}}}, DefaultACMEMaxCNAMEHops, true},
+		{"fail zone", &ACMEDNS01Options{Delegations: []ACMEDNS01Delegation{{Domain: "example.com", Zone: "."}}}, DefaultACMEMaxCNAMEHops, true},
+		{"ok resolvers", &ACMEDNS01Options{Resolvers: []string{"1.1.1.1", "tls://dns.example.com"}, Quorum: 2}, DefaultACMEMaxCNAMEHops, false},
+		{"fail resolver", &ACMEDNS01Options{Resolvers: []string{"quic://dns.example.com"}}, DefaultACMEMaxCNAMEHops, true},
+		{"fail quorum", &ACMEDNS01Options{Resolvers: []string{"1.1.1.1"}, Quorum: 2}, DefaultACMEMaxCNAMEHops, true},
+		{"fail negative quorum", &ACMEDNS01Options{Quorum: -1}, DefaultACMEMaxCNAMEHops, true},
+		{"fail lookupTimeout", &ACMEDNS01Options{LookupTimeout: &Duration{Duration: -time.Second}}, DefaultACMEMaxCNAMEHops, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("ACMEDNS01Options.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tt.opts.GetMaxCNAMEHops(); got != tt.wantHops {
+				t.Errorf("ACMEDNS01Options.GetMaxCNAMEHops() = %v, want %v", got, tt.wantHops)
+			}
+		})
+	}
+}
+
+func TestParseACMEDNSResolver(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    *ACMEDNSResolver
+		wantErr bool
+	}{
+		{"1.1.1.1", &ACMEDNSResolver{DNSResolverUDP, "1.1.1.1:53"}, false},
+		{"1.1.1.1:5353", &ACMEDNSResolver{DNSResolverUDP, "1.1.1.1:5353"}, false},
+		{"2606:4700:4700::1111", &ACMEDNSResolver{DNSResolverUDP, "[2606:4700:4700::1111]:53"}, false},
+		{"[2606:4700:4700::1111]:53", &ACMEDNSResolver{DNSResolverUDP, "[2606:4700:4700::1111]:53"}, false},
+		{"udp://dns.example.com", &ACMEDNSResolver{DNSResolverUDP, "dns.example.com:53"}, false},
+		{"tcp://dns.example.com:5353", &ACMEDNSResolver{DNSResolverTCP, "dns.example.com:5353"}, false},
+		{"tls://dns.example.com", &ACMEDNSResolver{DNSResolverTLS, "dns.example.com:853"}, false},
+		{"TLS://1.1.1.1:8853", &ACMEDNSResolver{DNSResolverTLS, "1.1.1.1:8853"}, false},
+		{"https://dns.example.com/dns-query", &ACMEDNSResolver{DNSResolverHTTPS, "https://dns.example.com/dns-query"}, false},
+		{"", nil, true},
+		{"quic://dns.example.com", nil, true},
+		{"tls://", nil, true},
+		{"tcp://dns.example.com/path", nil, true},
+		{"https:///dns-query", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseACMEDNSResolver(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseACMEDNSResolver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseACMEDNSResolver() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACMEDNS01Options_resolvers(t *testing.T) {
+	var o *ACMEDNS01Options
+	if got := o.GetResolvers(); len(got) != 0 {
+		t.Errorf("ACMEDNS01Options.GetResolvers() = %v, want empty", got)
+	}
+	if got := o.GetLookupTimeout(); got != DefaultACMEDNSLookupTimeout {
+		t.Errorf("ACMEDNS01Options.GetLookupTimeout() = %v, want %v", got, DefaultACMEDNSLookupTimeout)
+	}
+	if got := o.GetQuorum(); got != 1 {
+		t.Errorf("ACMEDNS01Options.GetQuorum() = %v, want 1", got)
+	}
+
+	o = &ACMEDNS01Options{
+		Resolvers:     []string{"1.1.1.1", "https://dns.example.com/dns-query"},
+		LookupTimeout: &Duration{Duration: 2 * time.Second},
+		Quorum:        2,
+	}
+	want := []*ACMEDNSResolver{
+		{DNSResolverUDP, "1.1.1.1:53"},
+		{DNSResolverHTTPS, "https://dns.example.com/dns-query"},
+	}
+	if got := o.GetResolvers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ACMEDNS01Options.GetResolvers() = %v, want %v", got, want)
+	}
+	if got := o.GetLookupTimeout(); got != 2*time.Second {
+		t.Errorf("ACMEDNS01Options.GetLookupTimeout() = %v, want 2s", got)
+	}
+	if got := o.GetQuorum(); got != 2 {
+		t.Errorf("ACMEDNS01Options.GetQuorum() = %v, want 2", got)
+	}
+}
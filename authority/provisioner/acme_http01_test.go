@@ -0,0 +1,54 @@
+package provisioner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestACMEHTTP01Options_Validate(t *testing.T) {
+	zero, five, negative := 0, 5, -1
+	tests := []struct {
+		name             string
+		opts             *ACMEHTTP01Options
+		wantDialTimeout  time.Duration
+		wantTimeout      time.Duration
+		wantMaxRedirects int
+		wantProxy        string
+		wantErr          bool
+	}{
+		{"nil", nil, DefaultACMEHTTPDialTimeout, DefaultACMEHTTPTimeout, DefaultACMEHTTPMaxRedirects, "", false},
+		{"empty", &ACMEHTTP01Options{}, DefaultACMEHTTPDialTimeout, DefaultACMEHTTPTimeout, DefaultACMEHTTPMaxRedirects, "", false},
+		{"ok", &ACMEHTTP01Options{DialTimeout: &Duration{Duration: time.Second}, Timeout: &Duration{Duration: 5 * time.Second}, MaxRedirects: &five, Proxy: "http://proxy.internal:3128", SourceInterface: "eth1", DenyPrivateAddresses: true, DeniedNetworks: []string{"203.0.113.0/24", "2001:db8::/32"}}, time.Second, 5 * time.Second, 5, "http://proxy.internal:3128", false},
+		{"ok no redirects", &ACMEHTTP01Options{MaxRedirects: &zero}, DefaultACMEHTTPDialTimeout, DefaultACMEHTTPTimeout, 0, "", false},
+		{"ok socks5", &ACMEHTTP01Options{Proxy: "socks5://proxy.internal:1080"}, DefaultACMEHTTPDialTimeout, DefaultACMEHTTPTimeout, DefaultACMEHTTPMaxRedirects, "socks5://proxy.internal:1080", false},
+		{"fail dialTimeout", &ACMEHTTP01Options{DialTimeout: &Duration{Duration: -time.Second}}, -time.Second, DefaultACMEHTTPTimeout, DefaultACMEHTTPMaxRedirects, "", true},
+		{"fail timeout", &ACMEHTTP01Options{Timeout: &Duration{Duration: -time.Second}}, DefaultACMEHTTPDialTimeout, -time.Second, DefaultACMEHTTPMaxRedirects, "", true},
+		{"fail maxRedirects", &ACMEHTTP01Options{MaxRedirects: &negative}, DefaultACMEHTTPDialTimeout, DefaultACMEHTTPTimeout, -1, "", true},
+		{"fail proxy scheme", &ACMEHTTP01Options{Proxy: "ftp://proxy.internal"}, DefaultACMEHTTPDialTimeout, DefaultACMEHTTPTimeout, DefaultACMEHTTPMaxRedirects, "", true},
+		{"fail proxy host", &ACMEHTTP01Options{Proxy: "http://"}, DefaultACMEHTTPDialTimeout, DefaultACMEHTTPTimeout, DefaultACMEHTTPMaxRedirects, "", true},
+		{"fail deniedNetworks", &ACMEHTTP01Options{DeniedNetworks: []string{"10.0.0.1"}}, DefaultACMEHTTPDialTimeout, DefaultACMEHTTPTimeout, DefaultACMEHTTPMaxRedirects, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("ACMEHTTP01Options.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tt.opts.GetDialTimeout(); got != tt.wantDialTimeout {
+				t.Errorf("ACMEHTTP01Options.GetDialTimeout() = %v, want %v", got, tt.wantDialTimeout)
+			}
+			if got := tt.opts.GetTimeout(); got != tt.wantTimeout {
+				t.Errorf("ACMEHTTP01Options.GetTimeout() = %v, want %v", got, tt.wantTimeout)
+			}
+			if got := tt.opts.GetMaxRedirects(); got != tt.wantMaxRedirects {
+				t.Errorf("ACMEHTTP01Options.GetMaxRedirects() = %v, want %v", got, tt.wantMaxRedirects)
+			}
+			var proxy string
+			if u := tt.opts.GetProxy(); u != nil {
+				proxy = u.String()
+			}
+			if proxy != tt.wantProxy {
+				t.Errorf("ACMEHTTP01Options.GetProxy() = %v, want %v", proxy, tt.wantProxy)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package provisioner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestACMEOrderOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      *ACMEOrderOptions
+		wantToken int
+		wantOrder time.Duration
+		wantAuthz time.Duration
+		wantErr   bool
+	}{
+		{"nil", nil, DefaultACMETokenLength, DefaultACMEOrderLifetime, DefaultACMEOrderLifetime, false},
+		{"empty", &ACMEOrderOptions{}, DefaultACMETokenLength, DefaultACMEOrderLifetime, DefaultACMEOrderLifetime, false},
+		{"ok", &ACMEOrderOptions{
+			TokenLength:           64,
+			OrderLifetime:         &Duration{Duration: time.Hour},
+			AuthorizationLifetime: &Duration{Duration: 30 * time.Minute},
+		}, 64, time.Hour, 30 * time.Minute, false},
+		{"ok order", &ACMEOrderOptions{OrderLifetime: &Duration{Duration: time.Hour}}, DefaultACMETokenLength, time.Hour, time.Hour, false},
+		{"ok minimum token", &ACMEOrderOptions{TokenLength: MinACMETokenLength}, MinACMETokenLength, DefaultACMEOrderLifetime, DefaultACMEOrderLifetime, false},
+		{"fail negative token", &ACMEOrderOptions{TokenLength: -1}, -1, DefaultACMEOrderLifetime, DefaultACMEOrderLifetime, true},
+		{"fail short token", &ACMEOrderOptions{TokenLength: 16}, 16, DefaultACMEOrderLifetime, DefaultACMEOrderLifetime, true},
+		{"fail negative order", &ACMEOrderOptions{OrderLifetime: &Duration{Duration: -time.Hour}}, DefaultACMETokenLength, -time.Hour, -time.Hour, true},
+		{"fail negative authz", &ACMEOrderOptions{AuthorizationLifetime: &Duration{Duration: -time.Hour}}, DefaultACMETokenLength, DefaultACMEOrderLifetime, -time.Hour, true},
+		{"fail authz greater than order", &ACMEOrderOptions{
+			OrderLifetime:         &Duration{Duration: time.Hour},
+			AuthorizationLifetime: &Duration{Duration: 2 * time.Hour},
+		}, DefaultACMETokenLength, time.Hour, 2 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("ACMEOrderOptions.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tt.opts.GetTokenLength(); got != tt.wantToken {
+				t.Errorf("ACMEOrderOptions.GetTokenLength() = %v, want %v", got, tt.wantToken)
+			}
+			if got := tt.opts.GetOrderLifetime(); got != tt.wantOrder {
+				t.Errorf("ACMEOrderOptions.GetOrderLifetime() = %v, want %v", got, tt.wantOrder)
+			}
+			if got := tt.opts.GetAuthorizationLifetime(); got != tt.wantAuthz {
+				t.Errorf("ACMEOrderOptions.GetAuthorizationLifetime() = %v, want %v", got, tt.wantAuthz)
+			}
+		})
+	}
+}
+
+func TestACMEOrderOptions_preAuthorization(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        *ACMEOrderOptions
+		wantEnabled bool
+		wantReuse   time.Duration
+		wantErr     bool
+	}{
+		{"nil", nil, false, 0, false},
+		{"empty", &ACMEOrderOptions{}, false, 0, false},
+		{"ok", &ACMEOrderOptions{PreAuthorization: true, AuthorizationReuse: &Duration{Duration: 8 * time.Hour}}, true, 8 * time.Hour, false},
+		{"ok reuse", &ACMEOrderOptions{AuthorizationReuse: &Duration{Duration: time.Hour}}, false, time.Hour, false},
+		{"fail negative reuse", &ACMEOrderOptions{AuthorizationReuse: &Duration{Duration: -time.Hour}}, false, -time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("ACMEOrderOptions.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tt.opts.IsPreAuthorizationEnabled(); got != tt.wantEnabled {
+				t.Errorf("ACMEOrderOptions.IsPreAuthorizationEnabled() = %v, want %v", got, tt.wantEnabled)
+			}
+			if got := tt.opts.GetAuthorizationReuse(); got != tt.wantReuse {
+				t.Errorf("ACMEOrderOptions.GetAuthorizationReuse() = %v, want %v", got, tt.wantReuse)
+			}
+		})
+	}
+}
+
+func TestACMEOrderOptions_approval(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           *ACMEOrderOptions
+		wantRequired   bool
+		wantRetryAfter time.Duration
+		wantErr        bool
+	}{
+		{"nil", nil, false, DefaultACMEApprovalRetryAfter, false},
+		{"empty", &ACMEOrderOptions{Approval: &ACMEApprovalOptions{}}, false, DefaultACMEApprovalRetryAfter, false},
+		{"ok", &ACMEOrderOptions{Approval: &ACMEApprovalOptions{Required: true, RetryAfter: &Duration{Duration: 5 * time.Minute}}}, true, 5 * time.Minute, false},
+		{"ok webhook", &ACMEOrderOptions{Approval: &ACMEApprovalOptions{Required: true, Webhook: &ACMEApprovalWebhook{URL: "https://approvals.example.com", Secret: "c2VjcmV0"}}}, true, DefaultACMEApprovalRetryAfter, false},
+		{"fail negative retryAfter", &ACMEOrderOptions{Approval: &ACMEApprovalOptions{Required: true, RetryAfter: &Duration{Duration: -time.Minute}}}, true, -time.Minute, true},
+		{"fail webhook url", &ACMEOrderOptions{Approval: &ACMEApprovalOptions{Required: true, Webhook: &ACMEApprovalWebhook{URL: "ftp://approvals.example.com"}}}, true, DefaultACMEApprovalRetryAfter, true},
+		{"fail webhook secret", &ACMEOrderOptions{Approval: &ACMEApprovalOptions{Required: true, Webhook: &ACMEApprovalWebhook{URL: "https://approvals.example.com", Secret: "%%%"}}}, true, DefaultACMEApprovalRetryAfter, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("ACMEOrderOptions.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			p := &ACME{Orders: tt.opts}
+			if got := p.GetApprovalOptions().IsRequired(); got != tt.wantRequired {
+				t.Errorf("ACMEApprovalOptions.IsRequired() = %v, want %v", got, tt.wantRequired)
+			}
+			if got := p.GetApprovalOptions().GetRetryAfter(); got != tt.wantRetryAfter {
+				t.Errorf("ACMEApprovalOptions.GetRetryAfter() = %v, want %v", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestACMEOrderOptions_autoRenewal(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		name            string
+		opts            *ACMEOrderOptions
+		wantEnabled     bool
+		wantMinLifetime time.Duration
+		wantMaxDuration time.Duration
+		wantGet         bool
+		wantErr         bool
+	}{
+		{"nil", nil, false, DefaultACMEAutoRenewalMinLifetime, DefaultACMEAutoRenewalMaxDuration, false, false},
+		{"empty", &ACMEOrderOptions{AutoRenewal: &ACMEAutoRenewalOptions{}}, false, DefaultACMEAutoRenewalMinLifetime, DefaultACMEAutoRenewalMaxDuration, false, false},
+		{"ok", &ACMEOrderOptions{AutoRenewal: &ACMEAutoRenewalOptions{Enabled: true, MinLifetime: &Duration{Duration: day}, MaxDuration: &Duration{Duration: 30 * day}, AllowCertificateGet: true}}, true, day, 30 * day, true, false},
+		{"ok get disabled", &ACMEOrderOptions{AutoRenewal: &ACMEAutoRenewalOptions{AllowCertificateGet: true}}, false, DefaultACMEAutoRenewalMinLifetime, DefaultACMEAutoRenewalMaxDuration, false, false},
+		{"fail negative minLifetime", &ACMEOrderOptions{AutoRenewal: &ACMEAutoRenewalOptions{Enabled: true, MinLifetime: &Duration{Duration: -day}}}, true, -day, DefaultACMEAutoRenewalMaxDuration, false, true},
+		{"fail negative maxDuration", &ACMEOrderOptions{AutoRenewal: &ACMEAutoRenewalOptions{Enabled: true, MaxDuration: &Duration{Duration: -day}}}, true, DefaultACMEAutoRenewalMinLifetime, -day, false, true},
+		{"fail minLifetime > maxDuration", &ACMEOrderOptions{AutoRenewal: &ACMEAutoRenewalOptions{Enabled: true, MinLifetime: &Duration{Duration: 2 * day}, MaxDuration: &Duration{Duration: day}}}, true, 2 * day, day, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("ACMEOrderOptions.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			p := &ACME{Orders: tt.opts}
+			o := p.GetAutoRenewalOptions()
+			if got := o.IsEnabled(); got != tt.wantEnabled {
+				t.Errorf("ACMEAutoRenewalOptions.IsEnabled() = %v, want %v", got, tt.wantEnabled)
+			}
+			if got := o.GetMinLifetime(); got != tt.wantMinLifetime {
+				t.Errorf("ACMEAutoRenewalOptions.GetMinLifetime() = %v, want %v", got, tt.wantMinLifetime)
+			}
+			if got := o.GetMaxDuration(); got != tt.wantMaxDuration {
+				t.Errorf("ACMEAutoRenewalOptions.GetMaxDuration() = %v, want %v", got, tt.wantMaxDuration)
+			}
+			if got := o.IsCertificateGetAllowed(); got != tt.wantGet {
+				t.Errorf("ACMEAutoRenewalOptions.IsCertificateGetAllowed() = %v, want %v", got, tt.wantGet)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package provisioner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestACMERateLimitOptions(t *testing.T) {
+	hour := Duration{Duration: time.Hour}
+	tests := []struct {
+		name    string
+		opts    *ACMERateLimitOptions
+		wantNil bool
+		wantErr bool
+	}{
+		{"nil", nil, true, false},
+		{"empty", &ACMERateLimitOptions{}, true, false},
+		{"ok", &ACMERateLimitOptions{
+			NewAccountsPerIP:             &ACMERateLimit{Limit: 10, Window: hour},
+			NewOrdersPerAccount:          &ACMERateLimit{Limit: 300, Window: hour},
+			FailedValidationsPerHostname: &ACMERateLimit{Limit: 5, Window: hour},
+		}, false, false},
+		{"fail limit", &ACMERateLimitOptions{NewAccountsPerIP: &ACMERateLimit{Window: hour}}, false, true},
+		{"fail window", &ACMERateLimitOptions{NewOrdersPerAccount: &ACMERateLimit{Limit: 10}}, true, true},
+		{"fail negative window", &ACMERateLimitOptions{FailedValidationsPerHostname: &ACMERateLimit{Limit: 10, Window: Duration{Duration: -time.Hour}}}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("ACMERateLimitOptions.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			p := &ACME{RateLimits: tt.opts}
+			if got := p.GetRateLimitOptions().GetNewAccountsPerIP(); (got == nil) != tt.wantNil {
+				t.Errorf("ACMERateLimitOptions.GetNewAccountsPerIP() = %v, wantNil %v", got, tt.wantNil)
+			}
+		})
+	}
+}
@@ -28,6 +28,8 @@ func TestACMEChallenge_Validate(t *testing.T) {
 		{"dns-01", DNS_01, false},
 		{"tls-alpn-01", TLS_ALPN_01, false},
 		{"device-attest-01", DEVICE_ATTEST_01, false},
+		{"email-reply-00", EMAIL_REPLY_00, false},
+		{"oidc-01", OIDC_01, false},
 		{"uppercase", "HTTP-01", false},
 		{"fail", "http-02", true},
 	}
@@ -146,11 +148,57 @@ func TestACME_Init(t *testing.T) {
 				err: errors.New("error parsing attestationRoots: no certificates found"),
 			}
 		},
+		"fail-email-reply-required": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p:   &ACME{Name: "foo", Type: "bar", Challenges: []ACMEChallenge{EMAIL_REPLY_00}},
+				err: errors.New("emailReply is required to enable the email-reply-00 challenge"),
+			}
+		},
+		"fail-email-reply-from": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p: &ACME{Name: "foo", Type: "bar", Challenges: []ACMEChallenge{EMAIL_REPLY_00}, EmailReply: &ACMEEmailReplyOptions{
+					From: "ACME <acme@example.com>", Secret: "secret",
+				}},
+				err: errors.New("emailReply.from \"ACME <acme@example.com>\" is not valid"),
+			}
+		},
+		"fail-email-reply-secret": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p: &ACME{Name: "foo", Type: "bar", Challenges: []ACMEChallenge{EMAIL_REPLY_00}, EmailReply: &ACMEEmailReplyOptions{
+					From: "acme@example.com",
+				}},
+				err: errors.New("emailReply.secret cannot be empty"),
+			}
+		},
+		"fail-oidc-required": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p:   &ACME{Name: "foo", Type: "bar", Challenges: []ACMEChallenge{OIDC_01}},
+				err: errors.New("oidc is required to enable the oidc-01 challenge"),
+			}
+		},
+		"fail-oidc-provisioner": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p:   &ACME{Name: "foo", Type: "bar", Challenges: []ACMEChallenge{OIDC_01}, OIDC: &ACMEOIDCOptions{}},
+				err: errors.New("oidc.provisioner cannot be empty"),
+			}
+		},
 		"ok": func(t *testing.T) ProvisionerValidateTest {
 			return ProvisionerValidateTest{
 				p: &ACME{Name: "foo", Type: "bar"},
 			}
 		},
+		"ok oidc": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p: &ACME{Name: "foo", Type: "bar", Challenges: []ACMEChallenge{OIDC_01}, OIDC: &ACMEOIDCOptions{Provisioner: "Google"}},
+			}
+		},
+		"ok email-reply": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p: &ACME{Name: "foo", Type: "bar", Challenges: []ACMEChallenge{EMAIL_REPLY_00}, EmailReply: &ACMEEmailReplyOptions{
+					From: "acme@example.com", Secret: "secret",
+				}},
+			}
+		},
 		"ok attestation": func(t *testing.T) ProvisionerValidateTest {
 			return ProvisionerValidateTest{
 				p: &ACME{
@@ -269,7 +317,7 @@ func TestACME_AuthorizeSign(t *testing.T) {
 				}
 			} else {
 				if assert.Nil(t, tc.err) && assert.NotNil(t, opts) {
-					assert.Equals(t, 8, len(opts)) // number of SignOptions returned
+					assert.Equals(t, 9, len(opts)) // number of SignOptions returned
 					for _, o := range opts {
 						switch v := o.(type) {
 						case *ACME:
@@ -286,6 +334,8 @@ func TestACME_AuthorizeSign(t *testing.T) {
 						case *validityValidator:
 							assert.Equals(t, v.min, tc.p.ctl.Claimer.MinTLSCertDuration())
 							assert.Equals(t, v.max, tc.p.ctl.Claimer.MaxTLSCertDuration())
+						case maxSANsValidator:
+							assert.Equals(t, int(v), tc.p.ctl.Claimer.MaxCertificateSANs())
 						case *x509NamePolicyValidator:
 							assert.Equals(t, nil, v.policyEngine)
 						case *WebhookController:
@@ -300,6 +350,61 @@ func TestACME_AuthorizeSign(t *testing.T) {
 	}
 }
 
+func TestACME_AuthorizeSSHSign(t *testing.T) {
+	p, err := generateACME()
+	assert.FatalError(t, err)
+	opts, err := p.AuthorizeSSHSign(context.Background(), "")
+	assert.FatalError(t, err)
+	assert.Equals(t, 8, len(opts)) // number of SignOptions returned
+	for _, o := range opts {
+		switch v := o.(type) {
+		case *ACME:
+		case sshCertOptionsValidator:
+			assert.Equals(t, SignSSHOptions{CertType: SSHUserCert}, SignSSHOptions(v))
+		case *sshDefaultDuration:
+		case *sshDefaultPublicKeyValidator:
+		case *sshCertValidityValidator:
+		case *sshCertDefaultValidator:
+		case *sshNamePolicyValidator:
+		case *WebhookController:
+			assert.Len(t, 0, v.webhooks)
+		default:
+			assert.FatalError(t, fmt.Errorf("unexpected sign option of type %T", v))
+		}
+	}
+
+	// Tokens are not accepted.
+	_, err = p.AuthorizeSSHSign(context.Background(), "token")
+	var sc render.StatusCodedError
+	if assert.True(t, errors.As(err, &sc)) {
+		assert.Equals(t, http.StatusUnauthorized, sc.StatusCode())
+	}
+
+	disable := false
+	p.Claims = &Claims{EnableSSHCA: &disable}
+	p.ctl.Claimer, err = NewClaimer(p.Claims, globalProvisionerClaims)
+	assert.FatalError(t, err)
+	_, err = p.AuthorizeSSHSign(context.Background(), "")
+	if assert.True(t, errors.As(err, &sc)) {
+		assert.Equals(t, http.StatusUnauthorized, sc.StatusCode())
+	}
+}
+
+func TestACME_AuthorizeOrderIdentifier_sshPrincipal(t *testing.T) {
+	p, err := generateACME()
+	assert.FatalError(t, err)
+	assert.NoError(t, p.AuthorizeOrderIdentifier(context.Background(), ACMEIdentifier{Type: SSHPrincipal, Value: "jane"}))
+
+	disable := false
+	p.Claims = &Claims{EnableSSHCA: &disable}
+	p.ctl.Claimer, err = NewClaimer(p.Claims, globalProvisionerClaims)
+	assert.FatalError(t, err)
+	err = p.AuthorizeOrderIdentifier(context.Background(), ACMEIdentifier{Type: SSHPrincipal, Value: "jane"})
+	if assert.Error(t, err) {
+		assert.Equals(t, "sshCA is disabled for acme provisioner 'test@acme-provisioner.com'", err.Error())
+	}
+}
+
 func TestACME_IsChallengeEnabled(t *testing.T) {
 	ctx := context.Background()
 	type fields struct {
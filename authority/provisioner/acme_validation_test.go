@@ -0,0 +1,78 @@
+package provisioner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestACMEValidationOptions(t *testing.T) {
+	tests := []struct {
+		name            string
+		opts            *ACMEValidationOptions
+		wantAttempts    int
+		wantInterval    time.Duration
+		wantMaxInterval time.Duration
+		wantErr         bool
+	}{
+		{"nil", nil, DefaultACMEValidationAttempts, DefaultACMEValidationInterval, DefaultACMEValidationMaxInterval, false},
+		{"empty", &ACMEValidationOptions{}, DefaultACMEValidationAttempts, DefaultACMEValidationInterval, DefaultACMEValidationMaxInterval, false},
+		{"ok", &ACMEValidationOptions{
+			Attempts:    10,
+			Interval:    &Duration{Duration: time.Second},
+			MaxInterval: &Duration{Duration: 10 * time.Second},
+		}, 10, time.Second, 10 * time.Second, false},
+		{"ok interval", &ACMEValidationOptions{Interval: &Duration{Duration: 2 * time.Minute}}, DefaultACMEValidationAttempts, 2 * time.Minute, 2 * time.Minute, false},
+		{"fail negative attempts", &ACMEValidationOptions{Attempts: -1}, -1, DefaultACMEValidationInterval, DefaultACMEValidationMaxInterval, true},
+		{"fail negative interval", &ACMEValidationOptions{Interval: &Duration{Duration: -time.Second}}, DefaultACMEValidationAttempts, -time.Second, DefaultACMEValidationMaxInterval, true},
+		{"fail negative max interval", &ACMEValidationOptions{MaxInterval: &Duration{Duration: -time.Second}}, DefaultACMEValidationAttempts, DefaultACMEValidationInterval, -time.Second, true},
+		{"fail max interval less than interval", &ACMEValidationOptions{
+			Interval:    &Duration{Duration: time.Minute},
+			MaxInterval: &Duration{Duration: time.Second},
+		}, DefaultACMEValidationAttempts, time.Minute, time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("ACMEValidationOptions.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tt.opts.GetAttempts(); got != tt.wantAttempts {
+				t.Errorf("ACMEValidationOptions.GetAttempts() = %v, want %v", got, tt.wantAttempts)
+			}
+			if got := tt.opts.GetInterval(); got != tt.wantInterval {
+				t.Errorf("ACMEValidationOptions.GetInterval() = %v, want %v", got, tt.wantInterval)
+			}
+			if got := tt.opts.GetMaxInterval(); got != tt.wantMaxInterval {
+				t.Errorf("ACMEValidationOptions.GetMaxInterval() = %v, want %v", got, tt.wantMaxInterval)
+			}
+		})
+	}
+}
+
+func TestACMEValidationOptions_GetRetryInterval(t *testing.T) {
+	opts := &ACMEValidationOptions{
+		Interval:    &Duration{Duration: time.Second},
+		MaxInterval: &Duration{Duration: 5 * time.Second},
+	}
+	for attempt, want := range []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := opts.GetRetryInterval(attempt); got != want {
+			t.Errorf("ACMEValidationOptions.GetRetryInterval(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestACME_Init_validation(t *testing.T) {
+	config := Config{Claims: globalProvisionerClaims, Audiences: testAudiences}
+	p := &ACME{Type: "ACME", Name: "acme", Validation: &ACMEValidationOptions{Attempts: -1}}
+	if err := p.Init(config); err == nil || err.Error() != "validation.attempts cannot be negative" {
+		t.Errorf("ACME.Init() error = %v, want validation.attempts cannot be negative", err)
+	}
+
+	opts := &ACMEValidationOptions{Attempts: 3}
+	p = &ACME{Type: "ACME", Name: "acme", Validation: opts}
+	if err := p.Init(config); err != nil {
+		t.Fatalf("ACME.Init() error = %v", err)
+	}
+	if got := p.GetValidationOptions(); got != opts {
+		t.Errorf("ACME.GetValidationOptions() = %v, want %v", got, opts)
+	}
+}
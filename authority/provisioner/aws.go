@@ -220,6 +220,11 @@ func (p *AWS) GetType() Type {
 	return TypeAWS
 }
 
+// GetOptions returns the configured provisioner options.
+func (p *AWS) GetOptions() *Options {
+	return p.Options
+}
+
 // GetEncryptedKey is not available in an AWS provisioner.
 func (p *AWS) GetEncryptedKey() (kid, key string, ok bool) {
 	return "", "", false
@@ -385,6 +390,7 @@ func (p *AWS) AuthorizeSign(_ context.Context, token string) ([]SignOption, erro
 		defaultPublicKeyValidator{},
 		commonNameValidator(payload.Claims.Subject),
 		newValidityValidator(p.ctl.Claimer.MinTLSCertDuration(), p.ctl.Claimer.MaxTLSCertDuration()),
+		newMaxSANsValidator(p.ctl.Claimer.MaxCertificateSANs()),
 		newX509NamePolicyValidator(p.ctl.getPolicy().getX509()),
 		p.ctl.newWebhookController(
 			data,
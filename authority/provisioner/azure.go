@@ -175,6 +175,11 @@ func (p *Azure) GetType() Type {
 	return TypeAzure
 }
 
+// GetOptions returns the configured provisioner options.
+func (p *Azure) GetOptions() *Options {
+	return p.Options
+}
+
 // GetEncryptedKey is not available in an Azure provisioner.
 func (p *Azure) GetEncryptedKey() (kid, key string, ok bool) {
 	return "", "", false
@@ -403,6 +408,7 @@ func (p *Azure) AuthorizeSign(_ context.Context, token string) ([]SignOption, er
 		// validators
 		defaultPublicKeyValidator{},
 		newValidityValidator(p.ctl.Claimer.MinTLSCertDuration(), p.ctl.Claimer.MaxTLSCertDuration()),
+		newMaxSANsValidator(p.ctl.Claimer.MaxCertificateSANs()),
 		newX509NamePolicyValidator(p.ctl.getPolicy().getX509()),
 		p.ctl.newWebhookController(
 			data,
@@ -510,11 +510,11 @@ func TestAzure_AuthorizeSign(t *testing.T) {
 		code    int
 		wantErr bool
 	}{
-		{"ok", p1, args{t1}, 8, http.StatusOK, false},
-		{"ok", p2, args{t2}, 13, http.StatusOK, false},
-		{"ok", p1, args{t11}, 8, http.StatusOK, false},
-		{"ok", p5, args{t5}, 8, http.StatusOK, false},
-		{"ok", p7, args{t7}, 8, http.StatusOK, false},
+		{"ok", p1, args{t1}, 9, http.StatusOK, false},
+		{"ok", p2, args{t2}, 14, http.StatusOK, false},
+		{"ok", p1, args{t11}, 9, http.StatusOK, false},
+		{"ok", p5, args{t5}, 9, http.StatusOK, false},
+		{"ok", p7, args{t7}, 9, http.StatusOK, false},
 		{"fail tenant", p3, args{t3}, 0, http.StatusUnauthorized, true},
 		{"fail resource group", p4, args{t4}, 0, http.StatusUnauthorized, true},
 		{"fail subscription", p6, args{t6}, 0, http.StatusUnauthorized, true},
@@ -556,6 +556,8 @@ func TestAzure_AuthorizeSign(t *testing.T) {
 					case *validityValidator:
 						assert.Equals(t, v.min, tt.azure.ctl.Claimer.MinTLSCertDuration())
 						assert.Equals(t, v.max, tt.azure.ctl.Claimer.MaxTLSCertDuration())
+					case maxSANsValidator:
+						assert.Equals(t, int(v), tt.azure.ctl.Claimer.MaxCertificateSANs())
 					case ipAddressesValidator:
 						assert.Equals(t, v, nil)
 					case emailAddressesValidator:
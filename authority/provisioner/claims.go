@@ -27,6 +27,10 @@ type Claims struct {
 	DisableRenewal          *bool `json:"disableRenewal,omitempty"`
 	AllowRenewalAfterExpiry *bool `json:"allowRenewalAfterExpiry,omitempty"`
 
+	// Limits, 0 means unlimited
+	MaxOrderIdentifiers *int `json:"maxOrderIdentifiers,omitempty"`
+	MaxCertificateSANs  *int `json:"maxCertificateSANs,omitempty"`
+
 	// Other properties
 	DisableSmallstepExtensions *bool `json:"disableSmallstepExtensions,omitempty"`
 }
@@ -51,6 +55,8 @@ func (c *Claimer) Claims() Claims {
 	allowRenewalAfterExpiry := c.AllowRenewalAfterExpiry()
 	enableSSHCA := c.IsSSHCAEnabled()
 	disableSmallstepExtensions := c.IsDisableSmallstepExtensions()
+	maxOrderIdentifiers := c.MaxOrderIdentifiers()
+	maxCertificateSANs := c.MaxCertificateSANs()
 
 	return Claims{
 		MinTLSDur:                  &Duration{c.MinTLSCertDuration()},
@@ -65,6 +71,8 @@ func (c *Claimer) Claims() Claims {
 		EnableSSHCA:                &enableSSHCA,
 		DisableRenewal:             &disableRenewal,
 		AllowRenewalAfterExpiry:    &allowRenewalAfterExpiry,
+		MaxOrderIdentifiers:        &maxOrderIdentifiers,
+		MaxCertificateSANs:         &maxCertificateSANs,
 		DisableSmallstepExtensions: &disableSmallstepExtensions,
 	}
 }
@@ -134,6 +142,34 @@ func (c *Claimer) AllowRenewalAfterExpiry() bool {
 	return *c.claims.AllowRenewalAfterExpiry
 }
 
+// MaxOrderIdentifiers returns the maximum number of identifiers in an ACME
+// order. If the property is not set within the provisioner, then the global
+// value from the authority configuration will be used. A value of 0 means that
+// the number of identifiers is not limited.
+func (c *Claimer) MaxOrderIdentifiers() int {
+	if c.claims == nil || c.claims.MaxOrderIdentifiers == nil {
+		if c.global.MaxOrderIdentifiers == nil {
+			return 0
+		}
+		return *c.global.MaxOrderIdentifiers
+	}
+	return *c.claims.MaxOrderIdentifiers
+}
+
+// MaxCertificateSANs returns the maximum number of subject alternative names
+// in an X.509 certificate. If the property is not set within the provisioner,
+// then the global value from the authority configuration will be used. A value
+// of 0 means that the number of SANs is not limited.
+func (c *Claimer) MaxCertificateSANs() int {
+	if c.claims == nil || c.claims.MaxCertificateSANs == nil {
+		if c.global.MaxCertificateSANs == nil {
+			return 0
+		}
+		return *c.global.MaxCertificateSANs
+	}
+	return *c.claims.MaxCertificateSANs
+}
+
 // DefaultSSHCertDuration returns the default SSH certificate duration for the
 // given certificate type.
 func (c *Claimer) DefaultSSHCertDuration(certType uint32) (time.Duration, error) {
@@ -252,6 +288,10 @@ func (c *Claimer) Validate() error {
 		return errors.Errorf("claims: DefaultCertDuration cannot be less than MinCertDuration: DefaultCertDuration - %v, MinCertDuration - %v", def, min)
 	case max < def:
 		return errors.Errorf("claims: MaxCertDuration cannot be less than DefaultCertDuration: MaxCertDuration - %v, DefaultCertDuration - %v", max, def)
+	case c.MaxOrderIdentifiers() < 0:
+		return errors.Errorf("claims: MaxOrderIdentifiers cannot be less than 0")
+	case c.MaxCertificateSANs() < 0:
+		return errors.Errorf("claims: MaxCertificateSANs cannot be less than 0")
 	default:
 		return nil
 	}
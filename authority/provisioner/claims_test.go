@@ -49,3 +49,40 @@ func TestClaimer_DefaultSSHCertDuration(t *testing.T) {
 		})
 	}
 }
+
+func TestClaimer_limits(t *testing.T) {
+	zero, one, two, negative := 0, 1, 2, -1
+	global := globalProvisionerClaims
+	global.MaxOrderIdentifiers = &two
+	global.MaxCertificateSANs = &two
+
+	tests := []struct {
+		name               string
+		global             Claims
+		claims             *Claims
+		wantIdentifiers    int
+		wantCertificateSAN int
+		wantErr            bool
+	}{
+		{"unlimited", globalProvisionerClaims, nil, 0, 0, false},
+		{"global", global, nil, 2, 2, false},
+		{"provisioner", globalProvisionerClaims, &Claims{MaxOrderIdentifiers: &one, MaxCertificateSANs: &two}, 1, 2, false},
+		{"provisioner unlimited", global, &Claims{MaxOrderIdentifiers: &zero, MaxCertificateSANs: &zero}, 0, 0, false},
+		{"fail identifiers", globalProvisionerClaims, &Claims{MaxOrderIdentifiers: &negative}, -1, 0, true},
+		{"fail sans", globalProvisionerClaims, &Claims{MaxCertificateSANs: &negative}, 0, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClaimer(tt.claims, tt.global)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewClaimer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := c.MaxOrderIdentifiers(); got != tt.wantIdentifiers {
+				t.Errorf("Claimer.MaxOrderIdentifiers() = %v, want %v", got, tt.wantIdentifiers)
+			}
+			if got := c.MaxCertificateSANs(); got != tt.wantCertificateSAN {
+				t.Errorf("Claimer.MaxCertificateSANs() = %v, want %v", got, tt.wantCertificateSAN)
+			}
+		})
+	}
+}
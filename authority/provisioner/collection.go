@@ -101,7 +101,17 @@ func (c *Collection) LoadByToken(token *jose.JSONWebToken, claims *jose.Claims)
 		// If matches with stored audiences it will be a JWT token (default), and
 		// the id would be <issuer>:<kid>.
 		// TODO: is this ok?
-		return c.LoadByTokenID(claims.Issuer + ":" + token.Headers[0].KeyID)
+		if p, ok := c.LoadByTokenID(claims.Issuer + ":" + token.Headers[0].KeyID); ok {
+			return p, ok
+		}
+		// Tokens signed with the key of a JWK credential are loaded by the
+		// name of the provisioner, its validity is checked by the provisioner.
+		if p, ok := c.LoadByName(claims.Issuer); ok {
+			if jwk, ok := p.(*JWK); ok && jwk.hasCredentialKey(token.Headers[0].KeyID) {
+				return p, true
+			}
+		}
+		return nil, false
 	}
 
 	// The ID will be just the clientID stored in azp, aud or tid.
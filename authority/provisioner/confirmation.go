@@ -0,0 +1,96 @@
+package provisioner
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+
+	"github.com/smallstep/certificates/errs"
+	"go.step.sm/crypto/jose"
+)
+
+// Confirmation is the "cnf" claim defined in RFC 7800. It can be added to a
+// token to bind it to a key, so a stolen token cannot be used to sign a
+// certificate for a different key.
+type Confirmation struct {
+	// X5tS256 is the base64url-encoded SHA-256 thumbprint of the DER of the
+	// client certificate used in the mTLS connection. Defined in RFC 8705.
+	X5tS256 string `json:"x5t#S256,omitempty"`
+	// JKT is the base64url-encoded RFC 7638 thumbprint of the public key in
+	// the certificate request. Defined in RFC 9449.
+	JKT string `json:"jkt,omitempty"`
+}
+
+type clientCertificateKey struct{}
+
+// NewContextWithClientCertificate creates a new context with the client
+// certificate used in the TLS connection.
+func NewContextWithClientCertificate(ctx context.Context, cert *x509.Certificate) context.Context {
+	return context.WithValue(ctx, clientCertificateKey{}, cert)
+}
+
+// ClientCertificateFromContext returns the client certificate stored in the
+// given context.
+func ClientCertificateFromContext(ctx context.Context) (*x509.Certificate, bool) {
+	cert, ok := ctx.Value(clientCertificateKey{}).(*x509.Certificate)
+	return cert, ok && cert != nil
+}
+
+// ConfirmationOptions validates the "x5t#S256" confirmation against the client
+// certificate stored in the context, and returns the sign options required to
+// validate the "jkt" confirmation against the certificate request. A nil
+// confirmation is always valid.
+func ConfirmationOptions(ctx context.Context, cnf *Confirmation) ([]SignOption, error) {
+	if cnf == nil {
+		return nil, nil
+	}
+	if cnf.X5tS256 == "" && cnf.JKT == "" {
+		return nil, errs.Unauthorized("token cnf claim does not contain a supported confirmation method")
+	}
+
+	if cnf.X5tS256 != "" {
+		cert, ok := ClientCertificateFromContext(ctx)
+		if !ok {
+			return nil, errs.Unauthorized("token cnf claim requires a client certificate")
+		}
+		sum := sha256.Sum256(cert.Raw)
+		if !equalThumbprints(cnf.X5tS256, sum[:]) {
+			return nil, errs.Unauthorized("client certificate does not match the token cnf claim")
+		}
+	}
+
+	var opts []SignOption
+	if cnf.JKT != "" {
+		opts = append(opts, jktValidator(cnf.JKT))
+	}
+	return opts, nil
+}
+
+// jktValidator validates that the public key of a certificate request matches
+// the "jkt" confirmation of a token.
+type jktValidator string
+
+// Valid checks that the thumbprint of the certificate request key matches
+// the one in the token.
+func (v jktValidator) Valid(req *x509.CertificateRequest) error {
+	jwk := jose.JSONWebKey{Key: req.PublicKey}
+	sum, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return errs.BadRequestErr(err, "error generating certificate request key thumbprint")
+	}
+	if !equalThumbprints(string(v), sum) {
+		return errs.Forbidden("certificate request key does not match the token cnf claim")
+	}
+	return nil
+}
+
+func equalThumbprints(encoded string, sum []byte) bool {
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(b, sum) == 1
+}
@@ -0,0 +1,70 @@
+package provisioner
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/jose"
+)
+
+func Test_ConfirmationOptions(t *testing.T) {
+	cert := &x509.Certificate{Raw: []byte("client certificate")}
+	sum := sha256.Sum256(cert.Raw)
+	x5t := base64.RawURLEncoding.EncodeToString(sum[:])
+	certCtx := NewContextWithClientCertificate(context.Background(), cert)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		cnf     *Confirmation
+		want    []SignOption
+		wantErr string
+	}{
+		{"ok/nil", context.Background(), nil, nil, ""},
+		{"ok/x5t", certCtx, &Confirmation{X5tS256: x5t}, nil, ""},
+		{"ok/jkt", context.Background(), &Confirmation{JKT: "thumbprint"}, []SignOption{jktValidator("thumbprint")}, ""},
+		{"ok/both", certCtx, &Confirmation{X5tS256: x5t, JKT: "thumbprint"}, []SignOption{jktValidator("thumbprint")}, ""},
+		{"fail/empty", certCtx, &Confirmation{}, nil, "token cnf claim does not contain a supported confirmation method"},
+		{"fail/no-certificate", context.Background(), &Confirmation{X5tS256: x5t}, nil, "token cnf claim requires a client certificate"},
+		{"fail/nil-certificate", NewContextWithClientCertificate(context.Background(), nil), &Confirmation{X5tS256: x5t}, nil, "token cnf claim requires a client certificate"},
+		{"fail/mismatch", certCtx, &Confirmation{X5tS256: base64.RawURLEncoding.EncodeToString(make([]byte, 32))}, nil, "client certificate does not match the token cnf claim"},
+		{"fail/encoding", certCtx, &Confirmation{X5tS256: "not base64url!"}, nil, "client certificate does not match the token cnf claim"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConfirmationOptions(tt.ctx, tt.cnf)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_jktValidator_Valid(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	sum, err := (&jose.JSONWebKey{Key: key.Public()}).Thumbprint(crypto.SHA256)
+	require.NoError(t, err)
+	jkt := jktValidator(base64.RawURLEncoding.EncodeToString(sum))
+
+	assert.NoError(t, jkt.Valid(&x509.CertificateRequest{PublicKey: key.Public()}))
+	assert.EqualError(t, jkt.Valid(&x509.CertificateRequest{PublicKey: other.Public()}),
+		"certificate request key does not match the token cnf claim")
+	assert.EqualError(t, jkt.Valid(&x509.CertificateRequest{PublicKey: "foo"}),
+		"error generating certificate request key thumbprint: square/go-jose: unknown key type 'string'")
+}
@@ -23,6 +23,7 @@ type Controller struct {
 	IdentityFunc          GetIdentityFunc
 	AuthorizeRenewFunc    AuthorizeRenewFunc
 	AuthorizeSSHRenewFunc AuthorizeSSHRenewFunc
+	CredentialsFunc       GetCredentialsFunc
 	policy                *policyEngine
 	webhookClient         *http.Client
 	webhooks              []*Webhook
@@ -38,6 +39,15 @@ func NewController(p Interface, claims *Claims, config Config, options *Options)
 	if err != nil {
 		return nil, err
 	}
+	for _, pub := range options.GetPublishers() {
+		if err := pub.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	webhooks := options.GetWebhooks()
+	if len(config.Webhooks) > 0 {
+		webhooks = append(append([]*Webhook{}, webhooks...), config.Webhooks...)
+	}
 	return &Controller{
 		Interface:             p,
 		Audiences:             &config.Audiences,
@@ -45,9 +55,10 @@ func NewController(p Interface, claims *Claims, config Config, options *Options)
 		IdentityFunc:          config.GetIdentityFunc,
 		AuthorizeRenewFunc:    config.AuthorizeRenewFunc,
 		AuthorizeSSHRenewFunc: config.AuthorizeSSHRenewFunc,
+		CredentialsFunc:       config.GetCredentialsFunc,
 		policy:                policy,
 		webhookClient:         config.WebhookClient,
-		webhooks:              options.GetWebhooks(),
+		webhooks:              webhooks,
 	}, nil
 }
 
@@ -59,6 +70,25 @@ func (c *Controller) GetIdentity(ctx context.Context, email string) (*Identity,
 	return DefaultIdentityFunc(ctx, c.Interface, email)
 }
 
+// GetCredentials returns the credentials of the given type of the
+// provisioner.
+func (c *Controller) GetCredentials(typ CredentialType) ([]*Credential, error) {
+	if c == nil || c.CredentialsFunc == nil {
+		return nil, nil
+	}
+	creds, err := c.CredentialsFunc(c.GetName())
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading provisioner credentials")
+	}
+	var list []*Credential
+	for _, cred := range creds {
+		if cred.Type == typ {
+			list = append(list, cred)
+		}
+	}
+	return list, nil
+}
+
 // AuthorizeRenew returns nil if the given cert can be renewed, returns an error
 // otherwise.
 func (c *Controller) AuthorizeRenew(ctx context.Context, cert *x509.Certificate) error {
@@ -111,6 +111,19 @@ func TestNewController(t *testing.T) {
 			}, globalProvisionerClaims),
 			policy: mustNewPolicyEngine(t, options),
 		}, false},
+		{"ok with authority webhooks", args{&JWK{}, nil, Config{
+			Claims:    globalProvisionerClaims,
+			Audiences: testAudiences,
+			Webhooks:  []*Webhook{{Name: "inventory", Kind: "AUTHORIZING"}},
+		}, &Options{
+			Webhooks: []*Webhook{{Name: "enrich", Kind: "ENRICHING"}},
+		}}, &Controller{
+			Interface: &JWK{},
+			Audiences: &testAudiences,
+			Claimer:   mustClaimer(t, nil, globalProvisionerClaims),
+			policy:    mustNewPolicyEngine(t, &Options{Webhooks: []*Webhook{{Name: "enrich", Kind: "ENRICHING"}}}),
+			webhooks:  []*Webhook{{Name: "enrich", Kind: "ENRICHING"}, {Name: "inventory", Kind: "AUTHORIZING"}},
+		}, false},
 		{"fail claimer", args{&JWK{}, &Claims{
 			MinTLSDur: mustDuration(t, "24h"),
 			MaxTLSDur: mustDuration(t, "2h"),
@@ -0,0 +1,163 @@
+package provisioner
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/jose"
+)
+
+// CredentialType is the type of a provisioner credential.
+type CredentialType string
+
+const (
+	// CredentialJWK is an additional public key of a JWK provisioner.
+	CredentialJWK CredentialType = "jwk"
+	// CredentialOIDCClientSecret is a client secret of an OIDC provisioner.
+	CredentialOIDCClientSecret CredentialType = "oidcClientSecret"
+	// CredentialEABKey is an additional HMAC key of an ACME External Account
+	// Binding key.
+	CredentialEABKey CredentialType = "eabKey"
+)
+
+// Validate returns an error if the credential type is not supported.
+func (t CredentialType) Validate() error {
+	switch t {
+	case CredentialJWK, CredentialOIDCClientSecret, CredentialEABKey:
+		return nil
+	default:
+		return errors.Errorf("credential type %q is not supported", t)
+	}
+}
+
+// GetCredentialsFunc is a function that returns the credentials of the
+// provisioner with the given name.
+type GetCredentialsFunc func(name string) ([]*Credential, error)
+
+// Credential is a credential of a provisioner that is only valid between
+// NotBefore and NotAfter. Credentials are added to the ones in the
+// provisioner configuration, so a new credential can be used before the old
+// one is retired and the clients can be updated without downtime. A
+// credential with the key id or the HMAC key of a configured one limits the
+// validity of the configured one.
+type Credential struct {
+	ID          string           `json:"id"`
+	Provisioner string           `json:"provisioner"`
+	Type        CredentialType   `json:"type"`
+	Key         *jose.JSONWebKey `json:"key,omitempty"`
+	Secret      string           `json:"secret,omitempty"`
+	KeyID       string           `json:"keyID,omitempty"`
+	HmacKey     []byte           `json:"hmacKey,omitempty"`
+	NotBefore   time.Time        `json:"notBefore,omitempty"`
+	NotAfter    time.Time        `json:"notAfter,omitempty"`
+	CreatedBy   string           `json:"createdBy"`
+	CreatedAt   time.Time        `json:"createdAt"`
+}
+
+// Validate validates the fields of the credential.
+func (c *Credential) Validate() error {
+	if err := c.Type.Validate(); err != nil {
+		return err
+	}
+	switch c.Type {
+	case CredentialJWK:
+		switch {
+		case c.Key == nil:
+			return errors.New("credential key cannot be empty")
+		case !c.Key.Valid():
+			return errors.New("credential key is not valid")
+		case !c.Key.IsPublic():
+			return errors.New("credential key must be a public key")
+		case c.Key.KeyID == "":
+			return errors.New("credential key must have a key id")
+		}
+	case CredentialOIDCClientSecret:
+		if c.Secret == "" {
+			return errors.New("credential secret cannot be empty")
+		}
+	case CredentialEABKey:
+		switch {
+		case c.KeyID == "":
+			return errors.New("credential keyID cannot be empty")
+		case len(c.HmacKey) == 0:
+			return errors.New("credential hmacKey cannot be empty")
+		}
+	}
+	if !c.NotBefore.IsZero() && !c.NotAfter.IsZero() && !c.NotAfter.After(c.NotBefore) {
+		return errors.New("credential notAfter must be after notBefore")
+	}
+	return nil
+}
+
+// IsActive returns true if the credential is valid at the given time.
+func (c *Credential) IsActive(now time.Time) bool {
+	return (c.NotBefore.IsZero() || !now.Before(c.NotBefore)) &&
+		(c.NotAfter.IsZero() || now.Before(c.NotAfter))
+}
+
+// Public returns a copy of the credential without the secrets.
+func (c *Credential) Public() *Credential {
+	cc := *c
+	cc.Secret = ""
+	cc.HmacKey = nil
+	return &cc
+}
+
+// activeJWK returns the key used to verify the tokens signed with the given
+// key id. The credentials with the key id must be valid at the given time,
+// the configured key is used if there are none.
+func activeJWK(creds []*Credential, key *jose.JSONWebKey, kid string, now time.Time) (*jose.JSONWebKey, error) {
+	for _, c := range creds {
+		if c.Key != nil && c.Key.KeyID == kid {
+			if !c.IsActive(now) {
+				return nil, errors.Errorf("key %s is not valid at this time", kid)
+			}
+			return c.Key, nil
+		}
+	}
+	return key, nil
+}
+
+// activeSecret returns the secret of the most recent credential valid at the
+// given time, or the configured secret if the credentials do not replace it.
+func activeSecret(creds []*Credential, secret string, now time.Time) string {
+	var active *Credential
+	for _, c := range creds {
+		if !c.IsActive(now) {
+			continue
+		}
+		if active == nil || c.NotBefore.After(active.NotBefore) ||
+			(c.NotBefore.Equal(active.NotBefore) && c.CreatedAt.After(active.CreatedAt)) {
+			active = c
+		}
+	}
+	if active != nil {
+		return active.Secret
+	}
+	return secret
+}
+
+// activeHmacKeys returns the HMAC keys of the given External Account Binding
+// key valid at the given time. The stored key is not valid if a credential
+// with the same HMAC key is not.
+func activeHmacKeys(creds []*Credential, keyID string, hmacKey []byte, now time.Time) [][]byte {
+	stored := true
+	var keys [][]byte
+	for _, c := range creds {
+		if c.KeyID != keyID {
+			continue
+		}
+		if bytes.Equal(c.HmacKey, hmacKey) {
+			stored = c.IsActive(now)
+			continue
+		}
+		if c.IsActive(now) {
+			keys = append(keys, c.HmacKey)
+		}
+	}
+	if stored && len(hmacKey) > 0 {
+		keys = append([][]byte{hmacKey}, keys...)
+	}
+	return keys
+}
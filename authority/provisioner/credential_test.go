@@ -0,0 +1,212 @@
+package provisioner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.step.sm/crypto/jose"
+)
+
+func TestCredential_Validate(t *testing.T) {
+	key, err := generateJSONWebKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public()
+	noKid := key.Public()
+	noKid.KeyID = ""
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		cred    *Credential
+		wantErr bool
+	}{
+		{"ok/jwk", &Credential{Type: CredentialJWK, Key: &pub}, false},
+		{"ok/oidc", &Credential{Type: CredentialOIDCClientSecret, Secret: "secret"}, false},
+		{"ok/eab", &Credential{Type: CredentialEABKey, KeyID: "kid", HmacKey: []byte("key"), NotBefore: now, NotAfter: now.Add(time.Hour)}, false},
+		{"fail/type", &Credential{Type: "password", Secret: "secret"}, true},
+		{"fail/jwk-empty", &Credential{Type: CredentialJWK}, true},
+		{"fail/jwk-private", &Credential{Type: CredentialJWK, Key: key}, true},
+		{"fail/jwk-kid", &Credential{Type: CredentialJWK, Key: &noKid}, true},
+		{"fail/oidc-empty", &Credential{Type: CredentialOIDCClientSecret}, true},
+		{"fail/eab-keyID", &Credential{Type: CredentialEABKey, HmacKey: []byte("key")}, true},
+		{"fail/eab-hmacKey", &Credential{Type: CredentialEABKey, KeyID: "kid"}, true},
+		{"fail/window", &Credential{Type: CredentialOIDCClientSecret, Secret: "secret", NotBefore: now, NotAfter: now}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cred.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Credential.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCredential_IsActive(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		cred *Credential
+		want bool
+	}{
+		{"no window", &Credential{}, true},
+		{"started", &Credential{NotBefore: now.Add(-time.Minute)}, true},
+		{"not started", &Credential{NotBefore: now.Add(time.Minute)}, false},
+		{"not ended", &Credential{NotAfter: now.Add(time.Minute)}, true},
+		{"ended", &Credential{NotAfter: now}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cred.IsActive(now); got != tt.want {
+				t.Errorf("Credential.IsActive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWK_authorizeToken_credentials(t *testing.T) {
+	p, err := generateJWK()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey, err := decryptJSONWebKey(p.EncryptedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := generateJSONWebKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := generateJSONWebKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPub, newPub := oldKey.Public(), newKey.Public()
+
+	var creds []*Credential
+	p.ctl.CredentialsFunc = func(name string) ([]*Credential, error) {
+		if name != p.Name {
+			t.Errorf("GetCredentialsFunc() name = %s, want %s", name, p.Name)
+		}
+		return creds, nil
+	}
+	col := NewCollection(testAudiences)
+	if err := col.Store(p); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		creds   []*Credential
+		key     *jose.JSONWebKey
+		loaded  bool
+		wantErr bool
+	}{
+		{"ok/configured", nil, oldKey, true, false},
+		{"ok/new", []*Credential{{Type: CredentialJWK, Key: &newPub}}, newKey, true, false},
+		{"ok/overlap", []*Credential{{Type: CredentialJWK, Key: &newPub}, {Type: CredentialJWK, Key: &oldPub, NotAfter: now.Add(time.Hour)}}, oldKey, true, false},
+		{"fail/retired", []*Credential{{Type: CredentialJWK, Key: &newPub}, {Type: CredentialJWK, Key: &oldPub, NotAfter: now}}, oldKey, true, true},
+		{"fail/not-started", []*Credential{{Type: CredentialJWK, Key: &newPub, NotBefore: now.Add(time.Hour)}}, newKey, true, true},
+		{"fail/unknown", []*Credential{{Type: CredentialJWK, Key: &newPub}}, otherKey, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds = tt.creds
+			tok, err := generateSimpleToken(p.Name, testAudiences.Sign[0], tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			jwt, err := jose.ParseSigned(tok)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var claims jose.Claims
+			if err := jwt.UnsafeClaimsWithoutVerification(&claims); err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := col.LoadByToken(jwt, &claims); ok != tt.loaded || (ok && got != p) {
+				t.Errorf("Collection.LoadByToken() = %v, %v, want %v", got, ok, tt.loaded)
+			}
+			if _, err := p.authorizeToken(tok, testAudiences.Sign); (err != nil) != tt.wantErr {
+				t.Errorf("JWK.authorizeToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOIDC_GetClientSecret(t *testing.T) {
+	p, err := generateOIDC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ClientSecret = "configured"
+
+	now := time.Now()
+	tests := []struct {
+		name  string
+		creds []*Credential
+		want  string
+	}{
+		{"configured", nil, "configured"},
+		{"not started", []*Credential{{Type: CredentialOIDCClientSecret, Secret: "new", NotBefore: now.Add(time.Hour)}}, "configured"},
+		{"active", []*Credential{{Type: CredentialOIDCClientSecret, Secret: "new", NotBefore: now.Add(-time.Hour)}}, "new"},
+		{"most recent", []*Credential{
+			{Type: CredentialOIDCClientSecret, Secret: "newer", NotBefore: now.Add(-time.Minute)},
+			{Type: CredentialOIDCClientSecret, Secret: "new", NotBefore: now.Add(-time.Hour)},
+		}, "newer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p.ctl.CredentialsFunc = func(string) ([]*Credential, error) {
+				return tt.creds, nil
+			}
+			if got := p.GetClientSecret(); got != tt.want {
+				t.Errorf("OIDC.GetClientSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACME_GetExternalAccountKeys(t *testing.T) {
+	p, err := generateACME()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, rotated := []byte("stored"), []byte("rotated")
+
+	now := time.Now()
+	tests := []struct {
+		name  string
+		creds []*Credential
+		want  [][]byte
+	}{
+		{"stored", nil, [][]byte{stored}},
+		{"other key", []*Credential{{Type: CredentialEABKey, KeyID: "other", HmacKey: rotated}}, [][]byte{stored}},
+		{"overlap", []*Credential{
+			{Type: CredentialEABKey, KeyID: "kid", HmacKey: rotated},
+			{Type: CredentialEABKey, KeyID: "kid", HmacKey: stored, NotAfter: now.Add(time.Hour)},
+		}, [][]byte{stored, rotated}},
+		{"retired", []*Credential{
+			{Type: CredentialEABKey, KeyID: "kid", HmacKey: rotated},
+			{Type: CredentialEABKey, KeyID: "kid", HmacKey: stored, NotAfter: now},
+		}, [][]byte{rotated}},
+		{"not started", []*Credential{{Type: CredentialEABKey, KeyID: "kid", HmacKey: rotated, NotBefore: now.Add(time.Hour)}}, [][]byte{stored}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p.ctl.CredentialsFunc = func(string) ([]*Credential, error) {
+				return tt.creds, nil
+			}
+			got, err := p.GetExternalAccountKeys("kid", stored)
+			if err != nil {
+				t.Fatalf("ACME.GetExternalAccountKeys() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ACME.GetExternalAccountKeys() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
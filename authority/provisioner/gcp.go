@@ -151,6 +151,11 @@ func (p *GCP) GetType() Type {
 	return TypeGCP
 }
 
+// GetOptions returns the configured provisioner options.
+func (p *GCP) GetOptions() *Options {
+	return p.Options
+}
+
 // GetEncryptedKey is not available in a GCP provisioner.
 func (p *GCP) GetEncryptedKey() (kid, key string, ok bool) {
 	return "", "", false
@@ -275,6 +280,7 @@ func (p *GCP) AuthorizeSign(_ context.Context, token string) ([]SignOption, erro
 		// validators
 		defaultPublicKeyValidator{},
 		newValidityValidator(p.ctl.Claimer.MinTLSCertDuration(), p.ctl.Claimer.MaxTLSCertDuration()),
+		newMaxSANsValidator(p.ctl.Claimer.MaxCertificateSANs()),
 		newX509NamePolicyValidator(p.ctl.getPolicy().getX509()),
 		p.ctl.newWebhookController(
 			data,
@@ -516,9 +516,9 @@ func TestGCP_AuthorizeSign(t *testing.T) {
 		code    int
 		wantErr bool
 	}{
-		{"ok", p1, args{t1}, 8, http.StatusOK, false},
-		{"ok", p2, args{t2}, 13, http.StatusOK, false},
-		{"ok", p3, args{t3}, 8, http.StatusOK, false},
+		{"ok", p1, args{t1}, 9, http.StatusOK, false},
+		{"ok", p2, args{t2}, 14, http.StatusOK, false},
+		{"ok", p3, args{t3}, 9, http.StatusOK, false},
 		{"fail token", p1, args{"token"}, 0, http.StatusUnauthorized, true},
 		{"fail key", p1, args{failKey}, 0, http.StatusUnauthorized, true},
 		{"fail iss", p1, args{failIss}, 0, http.StatusUnauthorized, true},
@@ -563,6 +563,8 @@ func TestGCP_AuthorizeSign(t *testing.T) {
 					case *validityValidator:
 						assert.Equals(t, v.min, tt.gcp.ctl.Claimer.MinTLSCertDuration())
 						assert.Equals(t, v.max, tt.gcp.ctl.Claimer.MaxTLSCertDuration())
+					case maxSANsValidator:
+						assert.Equals(t, int(v), tt.gcp.ctl.Claimer.MaxCertificateSANs())
 					case ipAddressesValidator:
 						assert.Equals(t, v, nil)
 					case emailAddressesValidator:
@@ -85,6 +85,11 @@ func (p *JWK) GetType() Type {
 	return TypeJWK
 }
 
+// GetOptions returns the configured provisioner options.
+func (p *JWK) GetOptions() *Options {
+	return p.Options
+}
+
 // GetEncryptedKey returns the base provisioner encrypted key if it's defined.
 func (p *JWK) GetEncryptedKey() (string, string, bool) {
 	return p.Key.KeyID, p.EncryptedKey, len(p.EncryptedKey) > 0
@@ -105,6 +110,35 @@ func (p *JWK) Init(config Config) (err error) {
 	return
 }
 
+// verificationKey returns the key used to verify the given token. Tokens can
+// be signed with the configured key or with the keys added as provisioner
+// credentials during their validity.
+func (p *JWK) verificationKey(token *jose.JSONWebToken) (*jose.JSONWebKey, error) {
+	if len(token.Headers) == 0 {
+		return p.Key, nil
+	}
+	creds, err := p.ctl.GetCredentials(CredentialJWK)
+	if err != nil {
+		return nil, err
+	}
+	return activeJWK(creds, p.Key, token.Headers[0].KeyID, time.Now())
+}
+
+// hasCredentialKey returns true if the provisioner has a JWK credential with
+// the given key id.
+func (p *JWK) hasCredentialKey(kid string) bool {
+	creds, err := p.ctl.GetCredentials(CredentialJWK)
+	if err != nil {
+		return false
+	}
+	for _, c := range creds {
+		if c.Key != nil && c.Key.KeyID == kid {
+			return true
+		}
+	}
+	return false
+}
+
 // authorizeToken performs common jwt authorization actions and returns the
 // claims for case specific downstream parsing.
 // e.g. a Sign request will auth/validate different fields than a Revoke request.
@@ -114,8 +148,13 @@ func (p *JWK) authorizeToken(token string, audiences []string) (*jwtPayload, err
 		return nil, errs.Wrap(http.StatusUnauthorized, err, "jwk.authorizeToken; error parsing jwk token")
 	}
 
+	key, err := p.verificationKey(jwt)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusUnauthorized, err, "jwk.authorizeToken; error loading jwk key")
+	}
+
 	var claims jwtPayload
-	if err = jwt.Claims(p.Key, &claims); err != nil {
+	if err = jwt.Claims(key, &claims); err != nil {
 		return nil, errs.Wrap(http.StatusUnauthorized, err, "jwk.authorizeToken; error parsing jwk claims")
 	}
 
@@ -194,6 +233,7 @@ func (p *JWK) AuthorizeSign(_ context.Context, token string) ([]SignOption, erro
 		defaultPublicKeyValidator{},
 		defaultSANsValidator(claims.SANs),
 		newValidityValidator(p.ctl.Claimer.MinTLSCertDuration(), p.ctl.Claimer.MaxTLSCertDuration()),
+		newMaxSANsValidator(p.ctl.Claimer.MaxCertificateSANs()),
 		newX509NamePolicyValidator(p.ctl.getPolicy().getX509()),
 		p.ctl.newWebhookController(data, linkedca.Webhook_X509),
 	}, nil
@@ -297,7 +297,7 @@ func TestJWK_AuthorizeSign(t *testing.T) {
 				}
 			} else {
 				if assert.NotNil(t, got) {
-					assert.Equals(t, 10, len(got))
+					assert.Equals(t, 11, len(got))
 					for _, o := range got {
 						switch v := o.(type) {
 						case *JWK:
@@ -315,6 +315,8 @@ func TestJWK_AuthorizeSign(t *testing.T) {
 						case *validityValidator:
 							assert.Equals(t, v.min, tt.prov.ctl.Claimer.MinTLSCertDuration())
 							assert.Equals(t, v.max, tt.prov.ctl.Claimer.MaxTLSCertDuration())
+						case maxSANsValidator:
+							assert.Equals(t, int(v), tt.prov.ctl.Claimer.MaxCertificateSANs())
 						case defaultSANsValidator:
 							assert.Equals(t, []string(v), tt.sans)
 						case *x509NamePolicyValidator:
@@ -86,6 +86,11 @@ func (p *K8sSA) GetType() Type {
 	return TypeK8sSA
 }
 
+// GetOptions returns the configured provisioner options.
+func (p *K8sSA) GetOptions() *Options {
+	return p.Options
+}
+
 // GetEncryptedKey returns false, because the kubernetes provisioner does not
 // have access to the private key.
 func (p *K8sSA) GetEncryptedKey() (string, string, bool) {
@@ -243,6 +248,7 @@ func (p *K8sSA) AuthorizeSign(_ context.Context, token string) ([]SignOption, er
 		// validators
 		defaultPublicKeyValidator{},
 		newValidityValidator(p.ctl.Claimer.MinTLSCertDuration(), p.ctl.Claimer.MaxTLSCertDuration()),
+		newMaxSANsValidator(p.ctl.Claimer.MaxCertificateSANs()),
 		newX509NamePolicyValidator(p.ctl.getPolicy().getX509()),
 		p.ctl.newWebhookController(data, linkedca.Webhook_X509),
 	}, nil
@@ -295,6 +295,8 @@ func TestK8sSA_AuthorizeSign(t *testing.T) {
 							case *validityValidator:
 								assert.Equals(t, v.min, tc.p.ctl.Claimer.MinTLSCertDuration())
 								assert.Equals(t, v.max, tc.p.ctl.Claimer.MaxTLSCertDuration())
+							case maxSANsValidator:
+								assert.Equals(t, int(v), tc.p.ctl.Claimer.MaxCertificateSANs())
 							case *x509NamePolicyValidator:
 								assert.Equals(t, nil, v.policyEngine)
 							case *WebhookController:
@@ -303,7 +305,7 @@ func TestK8sSA_AuthorizeSign(t *testing.T) {
 								assert.FatalError(t, fmt.Errorf("unexpected sign option of type %T", v))
 							}
 						}
-						assert.Equals(t, 8, len(opts))
+						assert.Equals(t, 9, len(opts))
 					}
 				}
 			}
@@ -0,0 +1,69 @@
+package provisioner
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/x509util"
+)
+
+// RequestedKeyUsagesModifier returns a modifier that replaces the key usages
+// and the extended key usages of a certificate with the ones in the sign
+// options. The requested usages must be already in the certificate, or they
+// must be allowed by the given X.509 options, so requesters can always narrow
+// the usages of the template, but extending them requires a provisioner
+// that allows it.
+func RequestedKeyUsagesModifier(o *X509Options) CertificateModifierFunc {
+	var (
+		allowed    x509.KeyUsage
+		allowedExt []x509.ExtKeyUsage
+	)
+	if o != nil {
+		allowed = x509.KeyUsage(o.AllowedKeyUsages)
+		allowedExt = o.AllowedExtKeyUsages
+	}
+	return func(cert *x509.Certificate, so SignOptions) error {
+		if so.KeyUsage != 0 {
+			requested := x509.KeyUsage(so.KeyUsage)
+			if denied := requested &^ (cert.KeyUsage | allowed); denied != 0 {
+				return errors.Errorf("key usage %s is not allowed", usageNames(x509util.KeyUsage(denied)))
+			}
+			cert.KeyUsage = requested
+		}
+		if len(so.ExtKeyUsage) > 0 {
+			for _, eku := range so.ExtKeyUsage {
+				if !containsExtKeyUsage(cert.ExtKeyUsage, eku) && !containsExtKeyUsage(allowedExt, eku) {
+					return errors.Errorf("extended key usage %s is not allowed", usageNames(x509util.ExtKeyUsage{eku}))
+				}
+			}
+			cert.ExtKeyUsage = []x509.ExtKeyUsage(so.ExtKeyUsage)
+		}
+		return nil
+	}
+}
+
+func containsExtKeyUsage(usages []x509.ExtKeyUsage, eku x509.ExtKeyUsage) bool {
+	for _, u := range usages {
+		if u == eku {
+			return true
+		}
+	}
+	return false
+}
+
+// usageNames returns the names used in the templates of the given key usages
+// or extended key usages.
+func usageNames(v json.Marshaler) string {
+	b, err := v.MarshalJSON()
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	var names []string
+	if err := json.Unmarshal(b, &names); err != nil {
+		return string(b)
+	}
+	return strings.Join(names, ", ")
+}
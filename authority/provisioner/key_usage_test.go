@@ -0,0 +1,66 @@
+package provisioner
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/x509util"
+)
+
+func TestRequestedKeyUsagesModifier(t *testing.T) {
+	newCert := func() *x509.Certificate {
+		return &x509.Certificate{
+			KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		}
+	}
+	tests := []struct {
+		name    string
+		options *X509Options
+		so      SignOptions
+		want    *x509.Certificate
+		wantErr string
+	}{
+		{"ok/empty", nil, SignOptions{}, newCert(), ""},
+		{"ok/narrow", nil, SignOptions{
+			KeyUsage:    x509util.KeyUsage(x509.KeyUsageDigitalSignature),
+			ExtKeyUsage: x509util.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}, &x509.Certificate{
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}, ""},
+		{"ok/allowed", &X509Options{
+			AllowedKeyUsages:    x509util.KeyUsage(x509.KeyUsageContentCommitment),
+			AllowedExtKeyUsages: x509util.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		}, SignOptions{
+			KeyUsage:    x509util.KeyUsage(x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment),
+			ExtKeyUsage: x509util.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		}, &x509.Certificate{
+			KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		}, ""},
+		{"fail/keyUsage", nil, SignOptions{
+			KeyUsage: x509util.KeyUsage(x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign),
+		}, nil, "key usage certsign is not allowed"},
+		{"fail/extKeyUsage", &X509Options{
+			AllowedKeyUsages: x509util.KeyUsage(x509.KeyUsageCertSign),
+		}, SignOptions{
+			ExtKeyUsage: x509util.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageCodeSigning},
+		}, nil, "extended key usage codesigning is not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := newCert()
+			err := RequestedKeyUsagesModifier(tt.options).Modify(cert, tt.so)
+			if tt.wantErr != "" {
+				if assert.Error(t, err) {
+					assert.Equals(t, tt.wantErr, err.Error())
+				}
+				return
+			}
+			assert.FatalError(t, err)
+			assert.Equals(t, tt.want, cert)
+		})
+	}
+}
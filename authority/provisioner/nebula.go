@@ -110,6 +110,11 @@ func (p *Nebula) GetType() Type {
 	return TypeNebula
 }
 
+// GetOptions returns the configured provisioner options.
+func (p *Nebula) GetOptions() *Options {
+	return p.Options
+}
+
 // GetEncryptedKey returns the base provisioner encrypted key if it's defined.
 func (p *Nebula) GetEncryptedKey() (kid, key string, ok bool) {
 	return "", "", false
@@ -164,6 +169,7 @@ func (p *Nebula) AuthorizeSign(_ context.Context, token string) ([]SignOption, e
 		},
 		defaultPublicKeyValidator{},
 		newValidityValidator(p.ctl.Claimer.MinTLSCertDuration(), p.ctl.Claimer.MaxTLSCertDuration()),
+		newMaxSANsValidator(p.ctl.Claimer.MaxCertificateSANs()),
 		newX509NamePolicyValidator(p.ctl.getPolicy().getX509()),
 		p.ctl.newWebhookController(data, linkedca.Webhook_X509),
 	}, nil
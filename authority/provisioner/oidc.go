@@ -2,6 +2,7 @@ package provisioner
 
 import (
 	"context"
+	"crypto/subtle"
 	"crypto/x509"
 	"encoding/json"
 	"net"
@@ -149,11 +150,27 @@ func (o *OIDC) GetType() Type {
 	return TypeOIDC
 }
 
+// GetOptions returns the configured provisioner options.
+func (o *OIDC) GetOptions() *Options {
+	return o.Options
+}
+
 // GetEncryptedKey is not available in an OIDC provisioner.
 func (o *OIDC) GetEncryptedKey() (kid, key string, ok bool) {
 	return "", "", false
 }
 
+// GetClientSecret returns the client secret published to the clients. It's
+// the secret of the most recent client secret credential valid at this time,
+// or the configured one.
+func (o *OIDC) GetClientSecret() string {
+	creds, err := o.ctl.GetCredentials(CredentialOIDCClientSecret)
+	if err != nil {
+		return o.ClientSecret
+	}
+	return activeSecret(creds, o.ClientSecret, time.Now())
+}
+
 // Init validates and initializes the OIDC provider.
 func (o *OIDC) Init(config Config) (err error) {
 	switch {
@@ -356,6 +373,7 @@ func (o *OIDC) AuthorizeSign(_ context.Context, token string) ([]SignOption, err
 		// validators
 		defaultPublicKeyValidator{},
 		newValidityValidator(o.ctl.Claimer.MinTLSCertDuration(), o.ctl.Claimer.MaxTLSCertDuration()),
+		newMaxSANsValidator(o.ctl.Claimer.MaxCertificateSANs()),
 		newX509NamePolicyValidator(o.ctl.getPolicy().getX509()),
 		// webhooks
 		o.ctl.newWebhookController(data, linkedca.Webhook_X509),
@@ -462,6 +480,36 @@ func (o *OIDC) AuthorizeSSHSign(ctx context.Context, token string) ([]SignOption
 	), nil
 }
 
+// AuthorizeSSHPrincipal validates the given ID token and returns an error if
+// its nonce is not the given one, or if the principal is not one of the
+// principals of the identity of the token. Admins can use any principal. It is
+// used in the oidc-01 challenges of the ACME provisioners.
+func (o *OIDC) AuthorizeSSHPrincipal(ctx context.Context, token, nonce, principal string) error {
+	claims, err := o.authorizeToken(token)
+	if err != nil {
+		return errs.Wrap(http.StatusInternalServerError, err, "oidc.AuthorizeSSHPrincipal")
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return errs.Unauthorized("oidc.AuthorizeSSHPrincipal; oidc token nonce is not valid")
+	}
+	if claims.IsAdmin(o.Admins) {
+		return nil
+	}
+	if claims.Email == "" {
+		return errs.Unauthorized("oidc.AuthorizeSSHPrincipal; oidc token does not have an email")
+	}
+	iden, err := o.ctl.GetIdentity(ctx, claims.Email)
+	if err != nil {
+		return errs.Wrap(http.StatusInternalServerError, err, "oidc.AuthorizeSSHPrincipal")
+	}
+	for _, name := range iden.Usernames {
+		if name == principal {
+			return nil
+		}
+	}
+	return errs.Forbidden("oidc.AuthorizeSSHPrincipal; principal %s is not allowed for %s", principal, claims.Email)
+}
+
 // AuthorizeSSHRevoke returns nil if the token is valid, false otherwise.
 func (o *OIDC) AuthorizeSSHRevoke(_ context.Context, token string) error {
 	claims, err := o.authorizeToken(token)
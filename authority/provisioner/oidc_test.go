@@ -322,7 +322,7 @@ func TestOIDC_AuthorizeSign(t *testing.T) {
 				assert.Equals(t, sc.StatusCode(), tt.code)
 				assert.Nil(t, got)
 			} else if assert.NotNil(t, got) {
-				assert.Equals(t, 8, len(got))
+				assert.Equals(t, 9, len(got))
 				for _, o := range got {
 					switch v := o.(type) {
 					case *OIDC:
@@ -338,6 +338,8 @@ func TestOIDC_AuthorizeSign(t *testing.T) {
 					case *validityValidator:
 						assert.Equals(t, v.min, tt.prov.ctl.Claimer.MinTLSCertDuration())
 						assert.Equals(t, v.max, tt.prov.ctl.Claimer.MaxTLSCertDuration())
+					case maxSANsValidator:
+						assert.Equals(t, int(v), tt.prov.ctl.Claimer.MaxCertificateSANs())
 					case *x509NamePolicyValidator:
 						assert.Equals(t, nil, v.policyEngine)
 					case *WebhookController:
@@ -635,6 +637,76 @@ func TestOIDC_AuthorizeSSHSign(t *testing.T) {
 	}
 }
 
+func TestOIDC_AuthorizeSSHPrincipal(t *testing.T) {
+	srv := generateJWKServer(2)
+	defer srv.Close()
+
+	var keys jose.JSONWebKeySet
+	assert.FatalError(t, getAndDecode(srv.URL+"/private", &keys))
+
+	p1, err := generateOIDC()
+	assert.FatalError(t, err)
+	p2, err := generateOIDC()
+	assert.FatalError(t, err)
+	p2.Admins = []string{"root@example.com"}
+	config := Config{Claims: globalProvisionerClaims}
+	p1.ConfigurationEndpoint = srv.URL + "/.well-known/openid-configuration"
+	p2.ConfigurationEndpoint = srv.URL + "/.well-known/openid-configuration"
+	assert.FatalError(t, p1.Init(config))
+	assert.FatalError(t, p2.Init(config))
+
+	newToken := func(aud, email, nonce string) string {
+		so := new(jose.SignerOptions)
+		so.WithType("JWT")
+		so.WithHeader("kid", keys.Keys[0].KeyID)
+		sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: keys.Keys[0].Key}, so)
+		assert.FatalError(t, err)
+		now := time.Now()
+		tok, err := jose.Signed(sig).Claims(openIDPayload{
+			Claims: jose.Claims{
+				Subject:   "subject",
+				Issuer:    "the-issuer",
+				IssuedAt:  jose.NewNumericDate(now),
+				NotBefore: jose.NewNumericDate(now),
+				Expiry:    jose.NewNumericDate(now.Add(5 * time.Minute)),
+				Audience:  []string{aud},
+			},
+			Email: email,
+			Nonce: nonce,
+		}).CompactSerialize()
+		assert.FatalError(t, err)
+		return tok
+	}
+
+	tests := []struct {
+		name      string
+		prov      *OIDC
+		token     string
+		principal string
+		code      int
+	}{
+		{"ok", p1, newToken(p1.ClientID, "name@smallstep.com", "nonce"), "name", http.StatusOK},
+		{"ok-email", p1, newToken(p1.ClientID, "name@smallstep.com", "nonce"), "name@smallstep.com", http.StatusOK},
+		{"ok-admin", p2, newToken(p2.ClientID, "root@example.com", "nonce"), "admin", http.StatusOK},
+		{"fail-token", p1, "foo", "name", http.StatusUnauthorized},
+		{"fail-nonce", p1, newToken(p1.ClientID, "name@smallstep.com", "other"), "name", http.StatusUnauthorized},
+		{"fail-email", p1, newToken(p1.ClientID, "", "nonce"), "name", http.StatusUnauthorized},
+		{"fail-principal", p1, newToken(p1.ClientID, "name@smallstep.com", "nonce"), "root", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.prov.AuthorizeSSHPrincipal(context.Background(), tt.token, "nonce", tt.principal)
+			if tt.code == http.StatusOK {
+				assert.NoError(t, err)
+				return
+			}
+			var sc render.StatusCodedError
+			assert.Fatal(t, errors.As(err, &sc), "error does not implement StatusCodedError interface")
+			assert.Equals(t, tt.code, sc.StatusCode())
+		})
+	}
+}
+
 func TestOIDC_AuthorizeSSHRevoke(t *testing.T) {
 	p1, err := generateOIDC()
 	assert.FatalError(t, err)
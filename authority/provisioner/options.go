@@ -1,8 +1,11 @@
 package provisioner
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -33,6 +36,10 @@ type Options struct {
 
 	// Webhooks is a list of webhooks that can augment template data
 	Webhooks []*Webhook `json:"webhooks,omitempty"`
+
+	// Publishers is a list of external stores where the signed and renewed
+	// certificates are written.
+	Publishers []*Publisher `json:"publishers,omitempty"`
 }
 
 // GetX509Options returns the X.509 options.
@@ -59,6 +66,14 @@ func (o *Options) GetWebhooks() []*Webhook {
 	return o.Webhooks
 }
 
+// GetPublishers returns the publishers options.
+func (o *Options) GetPublishers() []*Publisher {
+	if o == nil {
+		return nil
+	}
+	return o.Publishers
+}
+
 // X509Options contains specific options for X.509 certificates.
 type X509Options struct {
 	// Template contains a X.509 certificate template. It can be a JSON template
@@ -72,6 +87,19 @@ type X509Options struct {
 	// templates.
 	TemplateData json.RawMessage `json:"templateData,omitempty"`
 
+	// ComplianceProfile is the name of the compliance profile that the
+	// certificates signed by the provisioner must follow. If empty, the default
+	// profile of the authority, if any, will be used.
+	ComplianceProfile string `json:"complianceProfile,omitempty"`
+
+	// AllowedKeyUsages are the key usages that can be requested in a sign
+	// request in addition to the ones in the template.
+	AllowedKeyUsages x509util.KeyUsage `json:"allowedKeyUsages,omitempty"`
+
+	// AllowedExtKeyUsages are the extended key usages that can be requested in
+	// a sign request in addition to the ones in the template.
+	AllowedExtKeyUsages x509util.ExtKeyUsage `json:"allowedExtKeyUsages,omitempty"`
+
 	// AllowedNames contains the SANs the provisioner is authorized to sign
 	AllowedNames *policy.X509NameOptions `json:"-"`
 
@@ -88,6 +116,15 @@ func (o *X509Options) HasTemplate() bool {
 	return o != nil && (o.Template != "" || o.TemplateFile != "")
 }
 
+// GetComplianceProfile returns the name of the compliance profile configured
+// in the X.509 options.
+func (o *X509Options) GetComplianceProfile() string {
+	if o == nil {
+		return ""
+	}
+	return o.ComplianceProfile
+}
+
 // GetAllowedNameOptions returns the AllowedNames, which models the
 // SANs that a provisioner is authorized to sign x509 certificates for.
 func (o *X509Options) GetAllowedNameOptions() *policy.X509NameOptions {
@@ -113,6 +150,25 @@ func (o *X509Options) AreWildcardNamesAllowed() bool {
 	return o.AllowWildcardNames
 }
 
+var profileTemplates sync.Map
+
+// RegisterProfileTemplate registers the default template of a compliance
+// profile. Provisioners with the given compliance profile and without a custom
+// template will use it instead of their default template.
+func RegisterProfileTemplate(profile, template string) {
+	profileTemplates.Store(profile, template)
+}
+
+// LoadProfileTemplate returns the default template registered for the given
+// compliance profile.
+func LoadProfileTemplate(profile string) (string, bool) {
+	v, ok := profileTemplates.Load(profile)
+	if !ok {
+		return "", false
+	}
+	return v.(string), true
+}
+
 // TemplateOptions generates a CertificateOptions with the template and data
 // defined in the ProvisionerOptions, the provisioner generated data, and the
 // user data provided in the request. If no template has been provided,
@@ -131,6 +187,14 @@ func CustomTemplateOptions(o *Options, data x509util.TemplateData, defaultTempla
 		data = x509util.NewTemplateData()
 	}
 
+	// Use the template of the compliance profile if the provisioner does not
+	// define one.
+	if !opts.HasTemplate() {
+		if tpl, ok := LoadProfileTemplate(opts.GetComplianceProfile()); ok {
+			defaultTemplate = tpl
+		}
+	}
+
 	if opts != nil {
 		// Add template data if any.
 		if len(opts.TemplateData) > 0 && string(opts.TemplateData) != "null" {
@@ -148,7 +212,8 @@ func CustomTemplateOptions(o *Options, data x509util.TemplateData, defaultTempla
 			}
 		}
 
-		// Add user provided data.
+		// Add user provided data. The otherName SANs in the certificate request
+		// will be available in .Insecure.OtherNames.
 		if len(so.TemplateData) > 0 {
 			userObject := make(map[string]interface{})
 			if err := json.Unmarshal(so.TemplateData, &userObject); err != nil {
@@ -161,6 +226,7 @@ func CustomTemplateOptions(o *Options, data x509util.TemplateData, defaultTempla
 		// Load a template from a file if Template is not defined.
 		if opts.Template == "" && opts.TemplateFile != "" {
 			return []x509util.Option{
+				withOtherNamesData(data),
 				x509util.WithTemplateFile(step.Abs(opts.TemplateFile), data),
 			}
 		}
@@ -170,16 +236,84 @@ func CustomTemplateOptions(o *Options, data x509util.TemplateData, defaultTempla
 		template := strings.TrimSpace(opts.Template)
 		if strings.HasPrefix(template, "{") {
 			return []x509util.Option{
+				withOtherNamesData(data),
 				x509util.WithTemplate(template, data),
 			}
 		}
 		// 2. As a base64 encoded JSON.
 		return []x509util.Option{
+			withOtherNamesData(data),
 			x509util.WithTemplateBase64(template, data),
 		}
 	}), nil
 }
 
+// NewTemplateValidityOptions returns the sign options that set the validity
+// of a certificate using the "validity" property of the rendered X.509
+// template, for example:
+//
+//	{
+//		"subject": {{ toJson .Subject }},
+//		"sans": {{ toJson .SANs }},
+//		"validity": "{{ if hasSuffix ".internal" .Subject.CommonName }}2160h{{ else }}720h{{ end }}"
+//	}
+//
+// The validity starts at the requested notBefore, and it is still limited by
+// the claims of the provisioner. The options must be added after the template
+// options.
+func NewTemplateValidityOptions() []SignOption {
+	v := new(templateValidity)
+	return []SignOption{
+		certificateOptionsFunc(func(SignOptions) []x509util.Option {
+			return []x509util.Option{v.read}
+		}),
+		v,
+	}
+}
+
+type templateValidity struct {
+	validity time.Duration
+}
+
+// read reads the validity from the rendered template.
+func (v *templateValidity) read(_ *x509.CertificateRequest, o *x509util.Options) error {
+	if o.CertBuffer == nil {
+		return nil
+	}
+	var tpl map[string]json.RawMessage
+	if err := json.Unmarshal(o.CertBuffer.Bytes(), &tpl); err != nil {
+		// Errors in the template are reported when the certificate is created.
+		return nil
+	}
+	raw, ok := tpl["validity"]
+	if !ok {
+		return nil
+	}
+	var d Duration
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return &x509util.TemplateError{Message: "error parsing template validity: " + err.Error()}
+	}
+	if d.Duration <= 0 {
+		return &x509util.TemplateError{Message: "template validity must be greater than 0"}
+	}
+	v.validity = d.Duration
+	return nil
+}
+
+// Modify implements the CertificateModifier interface and sets the validity
+// of the template.
+func (v *templateValidity) Modify(cert *x509.Certificate, so SignOptions) error {
+	if v.validity == 0 {
+		return nil
+	}
+	notBefore := so.NotBefore.Time()
+	if notBefore.IsZero() {
+		notBefore = now()
+	}
+	cert.NotAfter = notBefore.Add(v.validity)
+	return nil
+}
+
 // unsafeParseSigned parses the given token and returns all the claims without
 // verifying the signature of the token.
 func unsafeParseSigned(s string) (map[string]interface{}, error) {
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 
 	"go.step.sm/crypto/pemutil"
 	"go.step.sm/crypto/x509util"
@@ -353,3 +354,63 @@ func TestX509Options_IsWildcardLiteralAllowed(t *testing.T) {
 		})
 	}
 }
+
+func TestNewTemplateValidityOptions(t *testing.T) {
+	csr := parseCertificateRequest(t, "testdata/certs/ecdsa.csr")
+	notBefore := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name         string
+		template     string
+		wantValidity time.Duration
+		wantErr      string
+	}{
+		{"ok", `{"subject": {{ toJson .Subject }}, "validity": "{{ if eq .Subject.CommonName "foo" }}2160h{{ else }}1h{{ end }}"}`, 2160 * time.Hour, ""},
+		{"ok/no validity", `{"subject": {{ toJson .Subject }}}`, 24 * time.Hour, ""},
+		{"fail/parse", `{"subject": {{ toJson .Subject }}, "validity": "90 days"}`, 0, "error parsing template validity: error parsing 90 days as duration: time: unknown unit \" days\" in duration \"90 days\""},
+		{"fail/negative", `{"subject": {{ toJson .Subject }}, "validity": "-1h"}`, 0, "template validity must be greater than 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := x509util.NewTemplateData()
+			data.SetSubject(x509util.Subject{CommonName: "foo"})
+			tplOpts, err := CustomTemplateOptions(&Options{X509: &X509Options{Template: tt.template}}, data, x509util.DefaultLeafTemplate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			so := SignOptions{NotBefore: NewTimeDuration(notBefore)}
+			signOpts := append([]SignOption{tplOpts, profileDefaultDuration(24 * time.Hour)}, NewTemplateValidityOptions()...)
+
+			// Apply the options like the authority does.
+			var certOptions []x509util.Option
+			var modifiers []CertificateModifier
+			for _, op := range signOpts {
+				switch o := op.(type) {
+				case CertificateOptions:
+					certOptions = append(certOptions, o.Options(so)...)
+				case CertificateModifier:
+					modifiers = append(modifiers, o)
+				}
+			}
+			c, err := x509util.NewCertificate(csr, certOptions...)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("x509util.NewCertificate() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			cert := c.GetCertificate()
+			for _, m := range modifiers {
+				if err := m.Modify(cert, so); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := cert.NotAfter.Sub(cert.NotBefore); got != tt.wantValidity {
+				t.Errorf("certificate validity = %s, want %s", got, tt.wantValidity)
+			}
+		})
+	}
+}
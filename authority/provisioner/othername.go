@@ -0,0 +1,184 @@
+package provisioner
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/x509util"
+)
+
+// OtherNamesKey is the key used in the insecure template data to store the
+// otherName subject alternative names present in the certificate request.
+const OtherNamesKey = "OtherNames"
+
+var (
+	oidExtensionSubjectAltName      = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidPermanentIdentifier          = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 8, 3}
+	oidHardwareModuleNameIdentifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 8, 4}
+	// oidUserPrincipalName is the Microsoft User Principal Name used for smart
+	// card logon.
+	oidUserPrincipalName = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 20, 2, 3}
+)
+
+// otherName represents the otherName GeneralName defined in RFC 5280:
+//
+//	OtherName ::= SEQUENCE {
+//	  type-id    OBJECT IDENTIFIER,
+//	  value      [0] EXPLICIT ANY DEFINED BY type-id }
+type otherName struct {
+	TypeID asn1.ObjectIdentifier
+	Value  asn1.RawValue
+}
+
+type asn1PermanentIdentifier struct {
+	IdentifierValue string                `asn1:"utf8,optional"`
+	Assigner        asn1.ObjectIdentifier `asn1:"optional"`
+}
+
+type asn1HardwareModuleName struct {
+	Type         asn1.ObjectIdentifier
+	SerialNumber []byte `asn1:"tag:4"`
+}
+
+// NewUserPrincipalNameSAN returns a subject alternative name with the given
+// Microsoft User Principal Name. The UPN is encoded as an UTF8String in an
+// otherName with the type 1.3.6.1.4.1.311.20.2.3.
+func NewUserPrincipalNameSAN(upn string) x509util.SubjectAlternativeName {
+	return x509util.SubjectAlternativeName{
+		Type:  oidUserPrincipalName.String(),
+		Value: "utf8:" + upn,
+	}
+}
+
+// NewPermanentIdentifierSAN returns a subject alternative name with the given
+// RFC 4043 permanent identifier and optional assigner.
+func NewPermanentIdentifierSAN(identifier string, assigner asn1.ObjectIdentifier) (x509util.SubjectAlternativeName, error) {
+	b, err := json.Marshal(x509util.PermanentIdentifier{
+		Identifier: identifier,
+		Assigner:   x509util.ObjectIdentifier(assigner),
+	})
+	if err != nil {
+		return x509util.SubjectAlternativeName{}, errors.Wrap(err, "error marshaling permanentIdentifier")
+	}
+	return x509util.SubjectAlternativeName{
+		Type:      x509util.PermanentIdentifierType,
+		ASN1Value: b,
+	}, nil
+}
+
+// NewHardwareModuleNameSAN returns a subject alternative name with the given
+// RFC 4108 hardware module type and serial number.
+func NewHardwareModuleNameSAN(hwType asn1.ObjectIdentifier, serialNumber []byte) (x509util.SubjectAlternativeName, error) {
+	if len(hwType) == 0 {
+		return x509util.SubjectAlternativeName{}, errors.New("hardwareModuleName type cannot be empty")
+	}
+	b, err := json.Marshal(x509util.HardwareModuleName{
+		Type:         x509util.ObjectIdentifier(hwType),
+		SerialNumber: serialNumber,
+	})
+	if err != nil {
+		return x509util.SubjectAlternativeName{}, errors.Wrap(err, "error marshaling hardwareModuleName")
+	}
+	return x509util.SubjectAlternativeName{
+		Type:      x509util.HardwareModuleNameType,
+		ASN1Value: b,
+	}, nil
+}
+
+// ParseOtherNameSANs returns the otherName subject alternative names in the
+// given extensions. Permanent identifiers and hardware module names are
+// returned with their own types, user principal names are returned as an
+// UTF8String, and any other value is returned using the raw type, ready to be
+// used in a template.
+func ParseOtherNameSANs(extensions []pkix.Extension) ([]x509util.SubjectAlternativeName, error) {
+	var sans []x509util.SubjectAlternativeName
+	for _, ext := range extensions {
+		if !ext.Id.Equal(oidExtensionSubjectAltName) {
+			continue
+		}
+
+		var seq asn1.RawValue
+		rest, err := asn1.Unmarshal(ext.Value, &seq)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing subjectAltName extension")
+		}
+		if len(rest) > 0 || !seq.IsCompound || seq.Tag != asn1.TagSequence || seq.Class != asn1.ClassUniversal {
+			return nil, errors.New("error parsing subjectAltName extension: bad sequence")
+		}
+
+		rest = seq.Bytes
+		for len(rest) > 0 {
+			var v asn1.RawValue
+			if rest, err = asn1.Unmarshal(rest, &v); err != nil {
+				return nil, errors.Wrap(err, "error parsing subjectAltName extension")
+			}
+			if v.Class != asn1.ClassContextSpecific || v.Tag != 0 {
+				continue
+			}
+			san, err := parseOtherName(v.FullBytes)
+			if err != nil {
+				return nil, err
+			}
+			sans = append(sans, san)
+		}
+	}
+	return sans, nil
+}
+
+func parseOtherName(der []byte) (x509util.SubjectAlternativeName, error) {
+	var (
+		zero x509util.SubjectAlternativeName
+		on   otherName
+	)
+	if _, err := asn1.UnmarshalWithParams(der, &on, "tag:0"); err != nil {
+		return zero, errors.Wrap(err, "error parsing otherName")
+	}
+
+	switch {
+	case on.TypeID.Equal(oidPermanentIdentifier):
+		var v asn1PermanentIdentifier
+		if _, err := asn1.UnmarshalWithParams(on.Value.FullBytes, &v, "explicit,tag:0"); err != nil {
+			return zero, errors.Wrap(err, "error parsing permanentIdentifier")
+		}
+		return NewPermanentIdentifierSAN(v.IdentifierValue, v.Assigner)
+	case on.TypeID.Equal(oidHardwareModuleNameIdentifier):
+		var v asn1HardwareModuleName
+		if _, err := asn1.UnmarshalWithParams(on.Value.FullBytes, &v, "explicit,tag:0"); err != nil {
+			return zero, errors.Wrap(err, "error parsing hardwareModuleName")
+		}
+		return NewHardwareModuleNameSAN(v.Type, v.SerialNumber)
+	case on.TypeID.Equal(oidUserPrincipalName):
+		var upn string
+		if _, err := asn1.UnmarshalWithParams(on.Value.FullBytes, &upn, "explicit,tag:0,utf8"); err != nil {
+			return zero, errors.Wrap(err, "error parsing userPrincipalName")
+		}
+		return NewUserPrincipalNameSAN(upn), nil
+	default:
+		// The raw type passes the explicitly tagged value unaltered.
+		return x509util.SubjectAlternativeName{
+			Type:  on.TypeID.String(),
+			Value: "raw:" + base64.StdEncoding.EncodeToString(on.Value.FullBytes),
+		}, nil
+	}
+}
+
+// withOtherNamesData returns an x509util.Option that adds the otherName
+// subject alternative names present in the certificate request to the
+// insecure template data. It must be added before the template options.
+func withOtherNamesData(data x509util.TemplateData) x509util.Option {
+	return func(cr *x509.CertificateRequest, _ *x509util.Options) error {
+		sans, err := ParseOtherNameSANs(cr.Extensions)
+		if err != nil {
+			return err
+		}
+		if sans == nil {
+			sans = []x509util.SubjectAlternativeName{}
+		}
+		data.SetInsecure(OtherNamesKey, sans)
+		return nil
+	}
+}
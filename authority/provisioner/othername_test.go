@@ -0,0 +1,129 @@
+package provisioner
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"reflect"
+	"testing"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/x509util"
+)
+
+func mustSubjectAltNameExtension(t *testing.T, sans ...x509util.SubjectAlternativeName) pkix.Extension {
+	t.Helper()
+	var rawValues []asn1.RawValue
+	for _, san := range sans {
+		v, err := san.RawValue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rawValues = append(rawValues, v)
+	}
+	b, err := asn1.Marshal(rawValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.Extension{Id: oidExtensionSubjectAltName, Value: b}
+}
+
+func TestParseOtherNameSANs(t *testing.T) {
+	permanentIdentifier, err := NewPermanentIdentifierSAN("device-1234", asn1.ObjectIdentifier{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hardwareModuleName, err := NewHardwareModuleNameSAN(asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37476, 9000, 1}, []byte("serial"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	upn := NewUserPrincipalNameSAN("jane@example.com")
+	custom := x509util.SubjectAlternativeName{Type: "1.2.3.4.5", Value: "utf8:custom"}
+	dns := x509util.SubjectAlternativeName{Type: x509util.DNSType, Value: "example.com"}
+
+	ext := mustSubjectAltNameExtension(t, dns, permanentIdentifier, hardwareModuleName, upn, custom)
+
+	type args struct {
+		extensions []pkix.Extension
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []x509util.SubjectAlternativeName
+		wantErr bool
+	}{
+		{"ok", args{[]pkix.Extension{ext}}, []x509util.SubjectAlternativeName{
+			permanentIdentifier, hardwareModuleName, upn,
+			{Type: "1.2.3.4.5", Value: "raw:oAgMBmN1c3RvbQ=="},
+		}, false},
+		{"ok no otherNames", args{[]pkix.Extension{mustSubjectAltNameExtension(t, dns)}}, nil, false},
+		{"ok no extensions", args{nil}, nil, false},
+		{"fail bad extension", args{[]pkix.Extension{{Id: oidExtensionSubjectAltName, Value: []byte("bad")}}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOtherNameSANs(tt.args.extensions)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOtherNameSANs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOtherNameSANs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHardwareModuleNameSAN(t *testing.T) {
+	if _, err := NewHardwareModuleNameSAN(nil, []byte("serial")); err == nil {
+		t.Error("NewHardwareModuleNameSAN() error = nil, want error")
+	}
+}
+
+func TestCustomTemplateOptions_otherNames(t *testing.T) {
+	signer, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	permanentIdentifier, err := NewPermanentIdentifierSAN("device-1234", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext := mustSubjectAltNameExtension(t, permanentIdentifier, NewUserPrincipalNameSAN("jane@example.com"))
+	der, err := x509.CreateCertificateRequest(nil, &x509.CertificateRequest{
+		Subject:         pkix.Name{CommonName: "jane"},
+		ExtraExtensions: []pkix.Extension{ext},
+	}, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := CustomTemplateOptions(&Options{X509: &X509Options{
+		Template: `{"subject": {{ toJson .Subject }}, "sans": {{ toJson .Insecure.OtherNames }}}`,
+	}}, x509util.CreateTemplateData("jane", nil), x509util.DefaultLeafTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509util.NewCertificate(csr, opts.Options(SignOptions{})...)
+	if err != nil {
+		t.Fatalf("x509util.NewCertificate() error = %v", err)
+	}
+
+	var found bool
+	for _, e := range cert.GetCertificate().ExtraExtensions {
+		if e.Id.Equal(oidExtensionSubjectAltName) {
+			found = true
+			if !bytes.Equal(e.Value, ext.Value) {
+				t.Errorf("subjectAltName = %x, want %x", e.Value, ext.Value)
+			}
+		}
+	}
+	if !found {
+		t.Error("subjectAltName extension not found")
+	}
+}
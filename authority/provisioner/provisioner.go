@@ -224,8 +224,14 @@ type Config struct {
 	// AuthorizeSSHRenewFunc is a function that returns nil if a given SSH
 	// certificate can be renewed.
 	AuthorizeSSHRenewFunc AuthorizeSSHRenewFunc
+	// GetCredentialsFunc is a function that returns the credentials added to
+	// the provisioners with the administration API.
+	GetCredentialsFunc GetCredentialsFunc
 	// WebhookClient is an http client to use in webhook request
 	WebhookClient *http.Client
+	// Webhooks are the webhooks of the authority, they are called after the
+	// webhooks of the provisioner.
+	Webhooks []*Webhook
 }
 
 type provisioner struct {
@@ -249,31 +255,8 @@ func (l *List) UnmarshalJSON(data []byte) error {
 		if err := json.Unmarshal(data, &typ); err != nil {
 			return errors.Errorf("error unmarshaling provisioner")
 		}
-		var p Interface
-		switch strings.ToLower(typ.Type) {
-		case "jwk":
-			p = &JWK{}
-		case "oidc":
-			p = &OIDC{}
-		case "gcp":
-			p = &GCP{}
-		case "aws":
-			p = &AWS{}
-		case "azure":
-			p = &Azure{}
-		case "acme":
-			p = &ACME{}
-		case "x5c":
-			p = &X5C{}
-		case "k8ssa":
-			p = &K8sSA{}
-		case "sshpop":
-			p = &SSHPOP{}
-		case "scep":
-			p = &SCEP{}
-		case "nebula":
-			p = &Nebula{}
-		default:
+		p := NewFromType(typ.Type)
+		if p == nil {
 			// Skip unsupported provisioners. A client using this method may be
 			// compiled with a version of smallstep/certificates that does not
 			// support a specific provisioner type. If we don't skip unknown
@@ -294,6 +277,37 @@ func (l *List) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// NewFromType returns an empty provisioner of the given type, the type is
+// case-insensitive. It returns nil if the type is not supported.
+func NewFromType(typ string) Interface {
+	switch strings.ToLower(typ) {
+	case "jwk":
+		return &JWK{}
+	case "oidc":
+		return &OIDC{}
+	case "gcp":
+		return &GCP{}
+	case "aws":
+		return &AWS{}
+	case "azure":
+		return &Azure{}
+	case "acme":
+		return &ACME{}
+	case "x5c":
+		return &X5C{}
+	case "k8ssa":
+		return &K8sSA{}
+	case "sshpop":
+		return &SSHPOP{}
+	case "scep":
+		return &SCEP{}
+	case "nebula":
+		return &Nebula{}
+	default:
+		return nil
+	}
+}
+
 type base struct{}
 
 // AuthorizeSign returns an unimplemented error. Provisioners should overwrite
@@ -0,0 +1,97 @@
+package provisioner
+
+import (
+	"bytes"
+	"crypto/x509"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// Types of the stores where the publishers write the certificates.
+const (
+	// VaultPublisher writes the certificates in a KV version 2 secrets engine
+	// of HashiCorp Vault. The client is configured with the VAULT_*
+	// environment variables.
+	VaultPublisher = "vault"
+	// AWSSecretsManagerPublisher writes the certificates in AWS Secrets
+	// Manager using the default credentials.
+	AWSSecretsManagerPublisher = "awssm"
+	// KubernetesPublisher writes the certificates in a Kubernetes secret using
+	// the service account of the CA.
+	KubernetesPublisher = "kubernetes"
+)
+
+// Publisher writes the certificates signed or renewed with a provisioner to an
+// external store, so the systems that cannot run a renewal client receive the
+// new certificates. The name of the secret is a template with the fields
+// CommonName, SerialNumber, DNSNames and Provisioner, for example
+// "certs/{{ .CommonName }}".
+type Publisher struct {
+	// Type is the type of the store, vault, awssm or kubernetes.
+	Type string `json:"type"`
+	// Name is the template of the name of the secret. It is the path of the
+	// secret in the Vault secrets engine, the name of the AWS secret, or the
+	// name of the Kubernetes secret.
+	Name string `json:"name"`
+	// Mount is the path of the Vault secrets engine, the default is "secret".
+	Mount string `json:"mount,omitempty"`
+	// Namespace is the namespace of the Kubernetes secret, the default is the
+	// namespace of the CA.
+	Namespace string `json:"namespace,omitempty"`
+
+	name *template.Template
+}
+
+// PublisherData is the data used to render the name of the secrets of the
+// publishers.
+type PublisherData struct {
+	CommonName   string
+	SerialNumber string
+	DNSNames     []string
+	Provisioner  string
+}
+
+// Validate validates and initializes the publisher.
+func (p *Publisher) Validate() error {
+	switch {
+	case p == nil:
+		return errors.New("publisher cannot be nil")
+	case p.Type != VaultPublisher && p.Type != AWSSecretsManagerPublisher && p.Type != KubernetesPublisher:
+		return errors.Errorf("publisher type %q is not supported", p.Type)
+	case p.Name == "":
+		return errors.New("publisher name cannot be empty")
+	}
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(p.Name)
+	if err != nil {
+		return errors.Wrapf(err, "error parsing publisher name %q", p.Name)
+	}
+	p.name = tmpl
+	return nil
+}
+
+// SecretName returns the name of the secret for the given certificate.
+func (p *Publisher) SecretName(crt *x509.Certificate, provisionerName string) (string, error) {
+	tmpl := p.name
+	if tmpl == nil {
+		var err error
+		if tmpl, err = template.New("name").Option("missingkey=error").Parse(p.Name); err != nil {
+			return "", errors.Wrapf(err, "error parsing publisher name %q", p.Name)
+		}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, PublisherData{
+		CommonName:   crt.Subject.CommonName,
+		SerialNumber: crt.SerialNumber.String(),
+		DNSNames:     crt.DNSNames,
+		Provisioner:  provisionerName,
+	}); err != nil {
+		return "", errors.Wrapf(err, "error rendering publisher name %q", p.Name)
+	}
+	name := strings.TrimSpace(buf.String())
+	if name == "" {
+		return "", errors.Errorf("publisher name %q is empty for certificate %s", p.Name, crt.SerialNumber)
+	}
+	return name, nil
+}
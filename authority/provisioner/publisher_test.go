@@ -0,0 +1,66 @@
+package provisioner
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+)
+
+func TestPublisher_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       *Publisher
+		wantErr bool
+	}{
+		{"ok vault", &Publisher{Type: VaultPublisher, Name: "certs/{{ .CommonName }}"}, false},
+		{"ok awssm", &Publisher{Type: AWSSecretsManagerPublisher, Name: "certs/{{ .SerialNumber }}"}, false},
+		{"ok kubernetes", &Publisher{Type: KubernetesPublisher, Name: "web-tls", Namespace: "web"}, false},
+		{"fail nil", nil, true},
+		{"fail type", &Publisher{Type: "gcpsm", Name: "web"}, true},
+		{"fail name", &Publisher{Type: VaultPublisher}, true},
+		{"fail template", &Publisher{Type: VaultPublisher, Name: "certs/{{ .CommonName"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Publisher.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPublisher_SecretName(t *testing.T) {
+	crt := &x509.Certificate{
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		SerialNumber: big.NewInt(1234),
+		DNSNames:     []string{"www.example.com", "example.com"},
+	}
+	tests := []struct {
+		name    string
+		tmpl    string
+		want    string
+		wantErr bool
+	}{
+		{"ok", "certs/{{ .Provisioner }}/{{ .CommonName }}", "certs/jwk/www.example.com", false},
+		{"ok serial", "{{ .SerialNumber }}", "1234", false},
+		{"ok dns names", "{{ index .DNSNames 1 }}-tls", "example.com-tls", false},
+		{"fail empty", "{{ if false }}name{{ end }}", "", true},
+		{"fail missing field", "{{ .Missing }}", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Publisher{Type: VaultPublisher, Name: tt.tmpl}
+			if err := p.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.SecretName(crt, "jwk")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Publisher.SecretName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Publisher.SecretName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package provisioner
 import (
 	"context"
 	"crypto/subtle"
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"time"
@@ -14,6 +15,10 @@ import (
 	"github.com/smallstep/certificates/webhook"
 )
 
+// SCEPCompatibilityNDES is the compatibility mode of the SCEP provisioners
+// that behave like Microsoft NDES.
+const SCEPCompatibilityNDES = "ndes"
+
 // SCEP is the SCEP provisioner type, an entity that can authorize the
 // SCEP provisioning flow
 type SCEP struct {
@@ -32,6 +37,19 @@ type SCEP struct {
 	// MinimumPublicKeyLength is the minimum length for public keys in CSRs
 	MinimumPublicKeyLength int `json:"minimumPublicKeyLength,omitempty"`
 
+	// AllowCertificateRenewal allows the renewal requests signed with a valid
+	// certificate previously issued by the provisioner to skip the challenge
+	// validation. The new certificate can only include the names of the
+	// existing one.
+	AllowCertificateRenewal bool `json:"allowCertificateRenewal,omitempty"`
+
+	// Compatibility makes the provisioner behave like other SCEP servers. The
+	// only supported value is "ndes", matching Microsoft NDES: it enables the
+	// GetCACertChain operation and returns the NDES capabilities by default,
+	// so Intune SCEP profiles can use the provisioner. The dynamic challenges
+	// of Intune are validated using a SCEPCHALLENGE webhook.
+	Compatibility string `json:"compatibility,omitempty"`
+
 	// Numerical identifier for the ContentEncryptionAlgorithm as defined in github.com/mozilla-services/pkcs7
 	// at https://github.com/mozilla-services/pkcs7/blob/33d05740a3526e382af6395d3513e73d4e66d1cb/encrypt.go#L63
 	// Defaults to 0, being DES-CBC
@@ -117,17 +135,24 @@ var (
 )
 
 // Validate executes zero or more configured webhooks to
-// validate the SCEP challenge. If at least one of them indicates
+// validate the SCEP challenge. The webhooks also receive the
+// CSR, if any, as Intune requires it to validate its dynamic
+// challenges. If at least one of them indicates
 // the challenge value is accepted, validation succeeds. In
 // that case, the other webhooks will be skipped. If none of
 // the webhooks indicates the value of the challenge was accepted,
 // an error is returned.
-func (c *challengeValidationController) Validate(ctx context.Context, challenge, transactionID string) error {
+func (c *challengeValidationController) Validate(ctx context.Context, csr *x509.CertificateRequest, challenge, transactionID string) error {
 	for _, wh := range c.webhooks {
-		req := &webhook.RequestBody{
-			SCEPChallenge:     challenge,
-			SCEPTransactionID: transactionID,
+		req := &webhook.RequestBody{}
+		if csr != nil {
+			var err error
+			if req, err = webhook.NewRequestBody(webhook.WithX509CertificateRequest(csr)); err != nil {
+				return fmt.Errorf("failed creating webhook request: %w", err)
+			}
 		}
+		req.SCEPChallenge = challenge
+		req.SCEPTransactionID = transactionID
 		resp, err := wh.DoWithContext(ctx, c.client, req, nil) // TODO(hs): support templated URL? Requires some refactoring
 		if err != nil {
 			return fmt.Errorf("failed executing webhook request: %w", err)
@@ -172,6 +197,12 @@ func (s *SCEP) Init(config Config) (err error) {
 		return errors.New("only encryption algorithm identifiers from 0 to 4 are valid")
 	}
 
+	switch s.Compatibility {
+	case "", SCEPCompatibilityNDES:
+	default:
+		return errors.Errorf("compatibility %q is not supported", s.Compatibility)
+	}
+
 	s.challengeValidationController = newChallengeValidationController(
 		config.WebhookClient,
 		s.GetOptions().GetWebhooks(),
@@ -196,6 +227,7 @@ func (s *SCEP) AuthorizeSign(context.Context, string) ([]SignOption, error) {
 		// validators
 		newPublicKeyMinimumLengthValidator(s.MinimumPublicKeyLength),
 		newValidityValidator(s.ctl.Claimer.MinTLSCertDuration(), s.ctl.Claimer.MaxTLSCertDuration()),
+		newMaxSANsValidator(s.ctl.Claimer.MaxCertificateSANs()),
 		newX509NamePolicyValidator(s.ctl.getPolicy().getX509()),
 		s.ctl.newWebhookController(nil, linkedca.Webhook_X509),
 	}, nil
@@ -214,6 +246,17 @@ func (s *SCEP) ShouldIncludeRootInChain() bool {
 	return s.IncludeRoot
 }
 
+// ShouldAllowCertificateRenewal indicates if the renewal requests can be
+// authenticated with an existing certificate instead of the challenge.
+func (s *SCEP) ShouldAllowCertificateRenewal() bool {
+	return s.AllowCertificateRenewal
+}
+
+// IsNDESCompatible indicates if the provisioner behaves like Microsoft NDES.
+func (s *SCEP) IsNDESCompatible() bool {
+	return s.Compatibility == SCEPCompatibilityNDES
+}
+
 // GetContentEncryptionAlgorithm returns the numeric identifier
 // for the pkcs7 package encryption algorithm to use.
 func (s *SCEP) GetContentEncryptionAlgorithm() int {
@@ -223,13 +266,13 @@ func (s *SCEP) GetContentEncryptionAlgorithm() int {
 // ValidateChallenge validates the provided challenge. It starts by
 // selecting the validation method to use, then performs validation
 // according to that method.
-func (s *SCEP) ValidateChallenge(ctx context.Context, challenge, transactionID string) error {
+func (s *SCEP) ValidateChallenge(ctx context.Context, csr *x509.CertificateRequest, challenge, transactionID string) error {
 	if s.challengeValidationController == nil {
 		return fmt.Errorf("provisioner %q wasn't initialized", s.Name)
 	}
 	switch s.selectValidationMethod() {
 	case validationMethodWebhook:
-		return s.challengeValidationController.Validate(ctx, challenge, transactionID)
+		return s.challengeValidationController.Validate(ctx, csr, challenge, transactionID)
 	default:
 		if subtle.ConstantTimeCompare([]byte(s.ChallengePassword), []byte(challenge)) == 0 {
 			return errors.New("invalid challenge password provided")
@@ -2,6 +2,7 @@ package provisioner
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -15,10 +16,15 @@ import (
 )
 
 func Test_challengeValidationController_Validate(t *testing.T) {
+	type csrRequest struct {
+		Raw []byte `json:"raw"`
+	}
 	type request struct {
-		Challenge     string `json:"scepChallenge"`
-		TransactionID string `json:"scepTransactionID"`
+		Challenge     string      `json:"scepChallenge"`
+		TransactionID string      `json:"scepTransactionID"`
+		CSR           *csrRequest `json:"x509CertificateRequest"`
 	}
+	csr := &x509.CertificateRequest{Raw: []byte("csr")}
 	type response struct {
 		Allow bool `json:"allow"`
 	}
@@ -39,6 +45,9 @@ func Test_challengeValidationController_Validate(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, "challenge", req.Challenge)
 		assert.Equal(t, "transaction-1", req.TransactionID)
+		if assert.NotNil(t, req.CSR) {
+			assert.Equal(t, csr.Raw, req.CSR.Raw)
+		}
 		b, err := json.Marshal(response{Allow: true})
 		require.NoError(t, err)
 		w.WriteHeader(200)
@@ -51,6 +60,7 @@ func Test_challengeValidationController_Validate(t *testing.T) {
 	type args struct {
 		challenge     string
 		transactionID string
+		csr           *x509.CertificateRequest
 	}
 	tests := []struct {
 		name   string
@@ -62,7 +72,7 @@ func Test_challengeValidationController_Validate(t *testing.T) {
 		{
 			name:   "fail/no-webhook",
 			fields: fields{http.DefaultClient, nil},
-			args:   args{"no-webhook", "transaction-1"},
+			args:   args{"no-webhook", "transaction-1", nil},
 			expErr: errors.New("webhook server did not allow request"),
 		},
 		{
@@ -73,7 +83,7 @@ func Test_challengeValidationController_Validate(t *testing.T) {
 					CertType: linkedca.Webhook_SSH.String(),
 				},
 			}},
-			args:   args{"wrong-cert-type", "transaction-1"},
+			args:   args{"wrong-cert-type", "transaction-1", nil},
 			expErr: errors.New("webhook server did not allow request"),
 		},
 		{
@@ -128,6 +138,7 @@ func Test_challengeValidationController_Validate(t *testing.T) {
 			args: args{
 				challenge:     "challenge",
 				transactionID: "transaction-1",
+				csr:           csr,
 			},
 			server: okServer,
 		},
@@ -141,7 +152,7 @@ func Test_challengeValidationController_Validate(t *testing.T) {
 			}
 
 			ctx := context.Background()
-			err := c.Validate(ctx, tt.args.challenge, tt.args.transactionID)
+			err := c.Validate(ctx, tt.args.csr, tt.args.challenge, tt.args.transactionID)
 
 			if tt.expErr != nil {
 				assert.EqualError(t, err, tt.expErr.Error())
@@ -330,7 +341,7 @@ func TestSCEP_ValidateChallenge(t *testing.T) {
 			require.NoError(t, err)
 			ctx := context.Background()
 
-			err = tt.p.ValidateChallenge(ctx, tt.args.challenge, tt.args.transactionID)
+			err = tt.p.ValidateChallenge(ctx, nil, tt.args.challenge, tt.args.transactionID)
 			if tt.expErr != nil {
 				assert.EqualError(t, err, tt.expErr.Error())
 				return
@@ -340,3 +351,16 @@ func TestSCEP_ValidateChallenge(t *testing.T) {
 		})
 	}
 }
+
+func TestSCEP_Init_compatibility(t *testing.T) {
+	p := &SCEP{Name: "SCEP", Type: "SCEP", Compatibility: SCEPCompatibilityNDES}
+	require.NoError(t, p.Init(Config{Claims: globalProvisionerClaims}))
+	assert.True(t, p.IsNDESCompatible())
+
+	p = &SCEP{Name: "SCEP", Type: "SCEP"}
+	require.NoError(t, p.Init(Config{Claims: globalProvisionerClaims}))
+	assert.False(t, p.IsNDESCompatible())
+
+	p = &SCEP{Name: "SCEP", Type: "SCEP", Compatibility: "jscep"}
+	assert.EqualError(t, p.Init(Config{Claims: globalProvisionerClaims}), `compatibility "jscep" is not supported`)
+}
@@ -28,7 +28,43 @@ type SignOptions struct {
 	NotAfter     TimeDuration    `json:"notAfter"`
 	NotBefore    TimeDuration    `json:"notBefore"`
 	TemplateData json.RawMessage `json:"templateData"`
-	Backdate     time.Duration   `json:"-"`
+	// KeyUsage and ExtKeyUsage are the usages requested for the certificate,
+	// they replace the ones in the template if the provisioner allows them.
+	KeyUsage    x509util.KeyUsage    `json:"keyUsage,omitempty"`
+	ExtKeyUsage x509util.ExtKeyUsage `json:"extKeyUsage,omitempty"`
+	// Validity is the requested duration of the certificate. Unlike NotAfter,
+	// it is clamped to the durations authorized by the provisioner.
+	Validity time.Duration `json:"-"`
+	Backdate time.Duration `json:"-"`
+}
+
+// ClampValidity sets NotAfter to the requested Validity, clamped to the
+// minimum and maximum durations authorized by the validators in the given sign
+// options. It does nothing if a Validity was not requested or if NotAfter is
+// already set.
+func (o *SignOptions) ClampValidity(opts []SignOption) {
+	if o.Validity <= 0 || !o.NotAfter.IsZero() {
+		return
+	}
+	d := o.Validity
+	for _, op := range opts {
+		if v, ok := op.(*validityValidator); ok {
+			d = clampDuration(d, v.min, v.max)
+		}
+	}
+	o.NotAfter.SetDuration(d)
+}
+
+// clampDuration returns the duration d within min and max, a zero min or max
+// is not enforced.
+func clampDuration(d, min, max time.Duration) time.Duration {
+	if min > 0 && d < min {
+		d = min
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return d
 }
 
 // SignOption is the interface used to collect all extra options used in the
@@ -83,6 +119,12 @@ type AttestationData struct {
 	PermanentIdentifier string
 }
 
+// RequesterIdentity is a SignOption with the identity of the requester, for
+// example an ACME account. It is used to detect the same key used by different
+// identities, if it is not set the identity is the subject and the SANs of the
+// certificate.
+type RequesterIdentity string
+
 // defaultPublicKeyValidator validates the public key of a certificate request.
 type defaultPublicKeyValidator struct{}
 
@@ -275,6 +317,49 @@ func (v defaultSANsValidator) Valid(req *x509.CertificateRequest) (err error) {
 	return
 }
 
+// SignOptionsSummary describes the names and validity that a list of sign
+// options authorizes. Empty values are not restricted.
+type SignOptionsSummary struct {
+	CommonNames []string  `json:"commonNames,omitempty"`
+	SANs        []string  `json:"sans,omitempty"`
+	MinDuration *Duration `json:"minDuration,omitempty"`
+	MaxDuration *Duration `json:"maxDuration,omitempty"`
+}
+
+// SummarizeSignOptions returns the names and validity authorized by the
+// validators in the given sign options.
+func SummarizeSignOptions(opts []SignOption) *SignOptionsSummary {
+	s := new(SignOptionsSummary)
+	for _, o := range opts {
+		switch v := o.(type) {
+		case commonNameValidator:
+			if v != "" {
+				s.CommonNames = append(s.CommonNames, string(v))
+			}
+		case commonNameSliceValidator:
+			s.CommonNames = append(s.CommonNames, v...)
+		case defaultSANsValidator:
+			s.SANs = append(s.SANs, v...)
+		case dnsNamesValidator:
+			s.SANs = append(s.SANs, v...)
+		case emailAddressesValidator:
+			s.SANs = append(s.SANs, v...)
+		case ipAddressesValidator:
+			for _, ip := range v {
+				s.SANs = append(s.SANs, ip.String())
+			}
+		case urisValidator:
+			for _, u := range v {
+				s.SANs = append(s.SANs, u.String())
+			}
+		case *validityValidator:
+			s.MinDuration = &Duration{Duration: v.min}
+			s.MaxDuration = &Duration{Duration: v.max}
+		}
+	}
+	return s
+}
+
 // profileDefaultDuration is a modifier that sets the certificate
 // duration.
 type profileDefaultDuration time.Duration
@@ -383,6 +468,29 @@ func (v *validityValidator) Valid(cert *x509.Certificate, o SignOptions) error {
 	return nil
 }
 
+// maxSANsValidator validates the number of subject alternative names of the
+// certificate.
+type maxSANsValidator int
+
+// newMaxSANsValidator returns a new validator that limits the number of
+// subject alternative names to the given value, if it is greater than 0.
+func newMaxSANsValidator(max int) maxSANsValidator {
+	return maxSANsValidator(max)
+}
+
+// Valid validates that the certificate (to be signed) does not contain more
+// subject alternative names than the configured maximum.
+func (v maxSANsValidator) Valid(cert *x509.Certificate, _ SignOptions) error {
+	if v <= 0 {
+		return nil
+	}
+	n := len(cert.DNSNames) + len(cert.EmailAddresses) + len(cert.IPAddresses) + len(cert.URIs)
+	if n > int(v) {
+		return errs.BadRequest("certificate request has %d subject alternative names, the maximum allowed is %d", n, int(v))
+	}
+	return nil
+}
+
 // x509NamePolicyValidator validates that the certificate (to be signed)
 // contains only allowed SANs.
 type x509NamePolicyValidator struct {
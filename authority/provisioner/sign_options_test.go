@@ -422,6 +422,37 @@ func Test_validityValidator_Valid(t *testing.T) {
 	}
 }
 
+func Test_maxSANsValidator_Valid(t *testing.T) {
+	u, err := url.Parse("spiffe://example.com/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &x509.Certificate{
+		DNSNames:       []string{"foo.example.com", "bar.example.com"},
+		EmailAddresses: []string{"jane@example.com"},
+		IPAddresses:    []net.IP{net.IPv4(10, 3, 2, 1)},
+		URIs:           []*url.URL{u},
+	}
+	tests := []struct {
+		name    string
+		v       maxSANsValidator
+		cert    *x509.Certificate
+		wantErr bool
+	}{
+		{"ok unlimited", newMaxSANsValidator(0), cert, false},
+		{"ok empty", newMaxSANsValidator(1), &x509.Certificate{}, false},
+		{"ok max", newMaxSANsValidator(5), cert, false},
+		{"fail", newMaxSANsValidator(4), cert, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.v.Valid(tt.cert, SignOptions{}); (err != nil) != tt.wantErr {
+				t.Errorf("maxSANsValidator.Valid() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func Test_forceCN_Option(t *testing.T) {
 	type test struct {
 		so    SignOptions
@@ -773,3 +804,27 @@ func Test_profileLimitDuration_Option(t *testing.T) {
 		})
 	}
 }
+
+func TestSignOptions_ClampValidity(t *testing.T) {
+	notAfter := NewTimeDuration(time.Now().Add(time.Hour))
+	opts := []SignOption{newValidityValidator(5*time.Minute, 24*time.Hour)}
+	tests := []struct {
+		name     string
+		so       SignOptions
+		opts     []SignOption
+		expected TimeDuration
+	}{
+		{"ok", SignOptions{Validity: 8 * time.Hour}, opts, TimeDuration{d: 8 * time.Hour}},
+		{"ok/min", SignOptions{Validity: time.Minute}, opts, TimeDuration{d: 5 * time.Minute}},
+		{"ok/max", SignOptions{Validity: 720 * time.Hour}, opts, TimeDuration{d: 24 * time.Hour}},
+		{"ok/no-validator", SignOptions{Validity: 720 * time.Hour}, nil, TimeDuration{d: 720 * time.Hour}},
+		{"ok/no-validity", SignOptions{}, opts, TimeDuration{}},
+		{"ok/notAfter", SignOptions{NotAfter: notAfter, Validity: 720 * time.Hour}, opts, notAfter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.so.ClampValidity(tt.opts)
+			assert.Equals(t, tt.expected, tt.so.NotAfter)
+		})
+	}
+}
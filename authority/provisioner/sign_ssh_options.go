@@ -52,7 +52,11 @@ type SignSSHOptions struct {
 	ValidAfter   TimeDuration    `json:"validAfter,omitempty"`
 	ValidBefore  TimeDuration    `json:"validBefore,omitempty"`
 	TemplateData json.RawMessage `json:"templateData,omitempty"`
-	Backdate     time.Duration   `json:"-"`
+	// Validity is the requested duration of the certificate. Unlike
+	// ValidBefore, it is clamped to the durations authorized by the
+	// provisioner.
+	Validity time.Duration `json:"-"`
+	Backdate time.Duration `json:"-"`
 }
 
 // Validate validates the given SignSSHOptions.
@@ -107,6 +111,47 @@ func (o SignSSHOptions) ModifyValidity(cert *ssh.Certificate) error {
 	return nil
 }
 
+// ClampValidity sets ValidBefore to the requested Validity, clamped to the
+// minimum and maximum durations authorized by the validators in the given sign
+// options for the type of the certificate. It does nothing if a Validity was
+// not requested or if ValidBefore is already set.
+func (o *SignSSHOptions) ClampValidity(opts []SignOption) {
+	if o.Validity <= 0 || !o.ValidBefore.IsZero() {
+		return
+	}
+	var claimer *Claimer
+	certType := o.CertType
+	for _, op := range opts {
+		switch v := op.(type) {
+		case *sshCertValidityValidator:
+			claimer = v.Claimer
+		case sshCertOptionsValidator:
+			if certType == "" {
+				certType = v.CertType
+			}
+		}
+	}
+
+	d := o.Validity
+	if claimer != nil {
+		switch certType {
+		case SSHUserCert:
+			d = clampDuration(d, claimer.MinUserSSHCertDuration(), claimer.MaxUserSSHCertDuration())
+		case SSHHostCert:
+			d = clampDuration(d, claimer.MinHostSSHCertDuration(), claimer.MaxHostSSHCertDuration())
+		}
+	}
+
+	// ValidBefore is relative to the current time, not to ValidAfter.
+	if o.ValidAfter.IsZero() {
+		o.ValidBefore.SetDuration(d)
+		return
+	}
+	validAfter := o.ValidAfter.RelativeTime(now())
+	o.ValidAfter.SetTime(validAfter)
+	o.ValidBefore.SetTime(validAfter.Add(d))
+}
+
 // match compares two SSHOptions and return an error if they don't match. It
 // ignores zero values.
 func (o SignSSHOptions) match(got SignSSHOptions) error {
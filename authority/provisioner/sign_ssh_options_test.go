@@ -736,3 +736,41 @@ func Test_sshDefaultDuration_Option(t *testing.T) {
 		})
 	}
 }
+
+func TestSignSSHOptions_ClampValidity(t *testing.T) {
+	tm, fn := mockNow()
+	defer fn()
+
+	p, err := generateX5C(nil)
+	assert.FatalError(t, err)
+	opts := []SignOption{&sshCertValidityValidator{p.ctl.Claimer}}
+	tokenOpts := append([]SignOption{sshCertOptionsValidator(SignSSHOptions{CertType: SSHHostCert})}, opts...)
+	validBefore := NewTimeDuration(tm.Add(time.Hour))
+
+	tests := []struct {
+		name            string
+		so              SignSSHOptions
+		opts            []SignOption
+		wantValidAfter  TimeDuration
+		wantValidBefore TimeDuration
+	}{
+		{"ok/user", SignSSHOptions{CertType: SSHUserCert, Validity: 8 * time.Hour}, opts, TimeDuration{}, TimeDuration{d: 8 * time.Hour}},
+		{"ok/user-min", SignSSHOptions{CertType: SSHUserCert, Validity: time.Minute}, opts, TimeDuration{}, TimeDuration{d: 5 * time.Minute}},
+		{"ok/user-max", SignSSHOptions{CertType: SSHUserCert, Validity: 720 * time.Hour}, opts, TimeDuration{}, TimeDuration{d: 24 * time.Hour}},
+		{"ok/host", SignSSHOptions{CertType: SSHHostCert, Validity: 720 * time.Hour}, opts, TimeDuration{}, TimeDuration{d: 720 * time.Hour}},
+		{"ok/host-max", SignSSHOptions{CertType: SSHHostCert, Validity: 1000 * time.Hour}, opts, TimeDuration{}, TimeDuration{d: 720 * time.Hour}},
+		{"ok/token-cert-type", SignSSHOptions{Validity: 1000 * time.Hour}, tokenOpts, TimeDuration{}, TimeDuration{d: 720 * time.Hour}},
+		{"ok/unknown-cert-type", SignSSHOptions{Validity: 1000 * time.Hour}, opts, TimeDuration{}, TimeDuration{d: 1000 * time.Hour}},
+		{"ok/validAfter", SignSSHOptions{CertType: SSHUserCert, ValidAfter: TimeDuration{d: time.Hour}, Validity: 720 * time.Hour}, opts,
+			NewTimeDuration(tm.Add(time.Hour)), NewTimeDuration(tm.Add(25 * time.Hour))},
+		{"ok/no-validity", SignSSHOptions{CertType: SSHUserCert}, opts, TimeDuration{}, TimeDuration{}},
+		{"ok/validBefore", SignSSHOptions{CertType: SSHUserCert, ValidBefore: validBefore, Validity: 720 * time.Hour}, opts, TimeDuration{}, validBefore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.so.ClampValidity(tt.opts)
+			assert.Equals(t, tt.wantValidAfter, tt.so.ValidAfter)
+			assert.Equals(t, tt.wantValidBefore, tt.so.ValidBefore)
+		})
+	}
+}
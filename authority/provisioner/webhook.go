@@ -3,18 +3,18 @@ package provisioner
 import (
 	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
 	"encoding/base64"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"text/template"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/internal/secret"
+	"github.com/smallstep/certificates/logging"
 	"github.com/smallstep/certificates/templates"
 	"github.com/smallstep/certificates/webhook"
 	"go.step.sm/linkedca"
@@ -36,7 +36,7 @@ type WebhookController struct {
 
 // Enrich fetches data from remote servers and adds returned data to the
 // templateData
-func (wc *WebhookController) Enrich(req *webhook.RequestBody) error {
+func (wc *WebhookController) Enrich(ctx context.Context, req *webhook.RequestBody) error {
 	if wc == nil {
 		return nil
 	}
@@ -55,7 +55,7 @@ func (wc *WebhookController) Enrich(req *webhook.RequestBody) error {
 		if !wc.isCertTypeOK(wh) {
 			continue
 		}
-		resp, err := wh.Do(wc.client, req, wc.TemplateData)
+		resp, err := wh.do(ctx, wc.client, req, wc.TemplateData)
 		if err != nil {
 			return err
 		}
@@ -68,7 +68,7 @@ func (wc *WebhookController) Enrich(req *webhook.RequestBody) error {
 }
 
 // Authorize checks that all remote servers allow the request
-func (wc *WebhookController) Authorize(req *webhook.RequestBody) error {
+func (wc *WebhookController) Authorize(ctx context.Context, req *webhook.RequestBody) error {
 	if wc == nil {
 		return nil
 	}
@@ -87,7 +87,7 @@ func (wc *WebhookController) Authorize(req *webhook.RequestBody) error {
 		if !wc.isCertTypeOK(wh) {
 			continue
 		}
-		resp, err := wh.Do(wc.client, req, wc.TemplateData)
+		resp, err := wh.do(ctx, wc.client, req, wc.TemplateData)
 		if err != nil {
 			return err
 		}
@@ -115,16 +115,24 @@ type Webhook struct {
 	Kind                 string `json:"kind"`
 	DisableTLSClientAuth bool   `json:"disableTLSClientAuth,omitempty"`
 	CertType             string `json:"certType"`
-	Secret               string `json:"-"`
-	BearerToken          string `json:"-"`
-	BasicAuth            struct {
+	// VerifyResponseSignature requires the webhook server to sign its
+	// responses with the webhook secret, see webhook.SignResponse.
+	VerifyResponseSignature bool   `json:"verifyResponseSignature,omitempty"`
+	Secret                  string `json:"-"`
+	BearerToken             string `json:"-"`
+	BasicAuth               struct {
 		Username string
 		Password string
 	} `json:"-"`
 }
 
 func (w *Webhook) Do(client *http.Client, reqBody *webhook.RequestBody, data any) (*webhook.ResponseBody, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	return w.do(context.Background(), client, reqBody, data)
+}
+
+// do calls DoWithContext with the default timeout of the webhooks.
+func (w *Webhook) do(ctx context.Context, client *http.Client, reqBody *webhook.RequestBody, data any) (*webhook.ResponseBody, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 
 	return w.DoWithContext(ctx, client, reqBody, data)
@@ -154,31 +162,49 @@ func (w *Webhook) DoWithContext(ctx context.Context, client *http.Client, reqBod
 		}
 	*/
 
-	reqBody.Timestamp = time.Now()
-
-	reqBytes, err := json.Marshal(reqBody)
+	// The secret and the bearer token can be references to a secret source,
+	// they're resolved on every call so rotated values are used.
+	encodedSecret, err := secret.Resolve(ctx, w.Secret)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error resolving webhook %s secret", w.Name)
+	}
+	bearerToken, err := secret.Resolve(ctx, w.BearerToken)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error resolving webhook %s bearer token", w.Name)
+	}
+	secret, err := base64.StdEncoding.DecodeString(encodedSecret)
 	if err != nil {
 		return nil, err
 	}
+	if w.VerifyResponseSignature && len(secret) == 0 {
+		return nil, errors.Errorf("webhook %s secret cannot be empty if the response signature is verified", w.Name)
+	}
 
 	retries := 1
 retry:
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBytes))
+	// Every attempt has its own timestamp and nonce, so the receiver can
+	// reject replays without rejecting the retries.
+	reqBody.Timestamp = time.Now()
+	if reqBody.Nonce, err = webhook.NewNonce(); err != nil {
+		return nil, err
+	}
+	reqBytes, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, err
 	}
 
-	secret, err := base64.StdEncoding.DecodeString(w.Secret)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBytes))
 	if err != nil {
 		return nil, err
 	}
-	sig := hmac.New(sha256.New, secret).Sum(reqBytes)
-	req.Header.Set("X-Smallstep-Signature", hex.EncodeToString(sig))
-	req.Header.Set("X-Smallstep-Webhook-ID", w.ID)
 
-	if w.BearerToken != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", w.BearerToken))
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(secret, reqBytes))
+	req.Header.Set(webhook.IDHeader, w.ID)
+	logging.InjectTraceHeaders(ctx, req.Header)
+
+	if bearerToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", bearerToken))
 	} else if w.BasicAuth.Username != "" || w.BasicAuth.Password != "" {
 		req.SetBasicAuth(w.BasicAuth.Username, w.BasicAuth.Password)
 	}
@@ -222,8 +248,18 @@ retry:
 		return nil, fmt.Errorf("Webhook server responded with %d", resp.StatusCode)
 	}
 
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if w.VerifyResponseSignature {
+		if err := webhook.VerifyResponse(secret, reqBody.Nonce, b, resp.Header.Get(webhook.SignatureHeader)); err != nil {
+			return nil, errors.Wrapf(err, "error verifying response from webhook %s", w.Name)
+		}
+	}
+
 	respBody := &webhook.ResponseBody{}
-	if err := json.NewDecoder(resp.Body).Decode(respBody); err != nil {
+	if err := json.Unmarshal(b, respBody); err != nil {
 		return nil, err
 	}
 
@@ -1,6 +1,7 @@
 package provisioner
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/tls"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/logging"
 	"github.com/smallstep/certificates/webhook"
 	"go.step.sm/crypto/pemutil"
 	"go.step.sm/crypto/x509util"
@@ -240,7 +242,7 @@ func TestWebhookController_Enrich(t *testing.T) {
 				wh.URL = ts.URL
 			}
 
-			err := test.ctl.Enrich(test.req)
+			err := test.ctl.Enrich(context.Background(), test.req)
 			if (err != nil) != test.expectErr {
 				t.Fatalf("Got err %v, want %v", err, test.expectErr)
 			}
@@ -350,7 +352,7 @@ func TestWebhookController_Authorize(t *testing.T) {
 				wh.URL = ts.URL
 			}
 
-			err := test.ctl.Authorize(test.req)
+			err := test.ctl.Authorize(context.Background(), test.req)
 			if (err != nil) != test.expectErr {
 				t.Fatalf("Got err %v, want %v", err, test.expectErr)
 			}
@@ -370,6 +372,7 @@ func TestWebhook_Do(t *testing.T) {
 		expectPath      string
 		errStatusCode   int
 		serverErrMsg    string
+		responseSecret  string
 		expectErr       error
 		// expectToken     any
 	}
@@ -455,6 +458,51 @@ func TestWebhook_Do(t *testing.T) {
 				Allow: true,
 			},
 		},
+		"ok/response-signature": {
+			webhook: Webhook{
+				ID:                      "abc123",
+				Name:                    "people",
+				Secret:                  "c2VjcmV0Cg==",
+				VerifyResponseSignature: true,
+			},
+			webhookResponse: webhook.ResponseBody{
+				Allow: true,
+			},
+			responseSecret: "c2VjcmV0Cg==",
+		},
+		"fail/response-signature": {
+			webhook: Webhook{
+				ID:                      "abc123",
+				Name:                    "people",
+				Secret:                  "c2VjcmV0Cg==",
+				VerifyResponseSignature: true,
+			},
+			webhookResponse: webhook.ResponseBody{
+				Allow: true,
+			},
+			responseSecret: "b3RoZXIK",
+			expectErr:      errors.New("error verifying response from webhook people: webhook signature does not match"),
+		},
+		"fail/response-signature-missing": {
+			webhook: Webhook{
+				ID:                      "abc123",
+				Name:                    "people",
+				Secret:                  "c2VjcmV0Cg==",
+				VerifyResponseSignature: true,
+			},
+			webhookResponse: webhook.ResponseBody{
+				Allow: true,
+			},
+			expectErr: errors.New("error verifying response from webhook people: webhook signature is not valid"),
+		},
+		"fail/response-signature-no-secret": {
+			webhook: Webhook{
+				ID:                      "abc123",
+				Name:                    "people",
+				VerifyResponseSignature: true,
+			},
+			expectErr: errors.New("webhook people secret cannot be empty if the response signature is verified"),
+		},
 		"fail/404": {
 			webhook: Webhook{
 				ID:     "abc123",
@@ -482,8 +530,9 @@ func TestWebhook_Do(t *testing.T) {
 
 				secret, err := base64.StdEncoding.DecodeString(tc.webhook.Secret)
 				assert.FatalError(t, err)
-				mac := hmac.New(sha256.New, secret).Sum(body)
-				assert.True(t, hmac.Equal(sig, mac))
+				mac := hmac.New(sha256.New, secret)
+				mac.Write(body)
+				assert.True(t, hmac.Equal(sig, mac.Sum(nil)))
 
 				switch {
 				case tc.webhook.BearerToken != "":
@@ -512,8 +561,16 @@ func TestWebhook_Do(t *testing.T) {
 				err = json.Unmarshal(body, reqBody)
 				assert.FatalError(t, err)
 				// assert.Equals(t, tc.expectToken, reqBody.Token)
+				assert.Equals(t, 32, len(reqBody.Nonce))
 
-				err = json.NewEncoder(w).Encode(tc.webhookResponse)
+				b, err := json.Marshal(tc.webhookResponse)
+				assert.FatalError(t, err)
+				if tc.responseSecret != "" {
+					responseSecret, err := base64.StdEncoding.DecodeString(tc.responseSecret)
+					assert.FatalError(t, err)
+					w.Header().Set("X-Smallstep-Signature", webhook.SignResponse(responseSecret, reqBody.Nonce, b))
+				}
+				_, err = w.Write(b)
 				assert.FatalError(t, err)
 			}))
 			defer ts.Close()
@@ -560,4 +617,21 @@ func TestWebhook_Do(t *testing.T) {
 		_, err = wh.Do(client, reqBody, nil)
 		assert.Error(t, err)
 	})
+	t.Run("traceContext", func(t *testing.T) {
+		tc := logging.NewTraceContext("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "")
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equals(t, tc.TraceParent(), r.Header.Get("traceparent"))
+			assert.Equals(t, "request-id", r.Header.Get("X-Request-Id"))
+			w.Write([]byte("{}"))
+		}))
+		defer ts.Close()
+		wh := Webhook{
+			URL: ts.URL,
+		}
+		ctx := logging.WithRequestID(logging.WithTraceContext(context.Background(), tc), "request-id")
+		reqBody, err := webhook.NewRequestBody(webhook.WithX509CertificateRequest(csr))
+		assert.FatalError(t, err)
+		_, err = wh.DoWithContext(ctx, http.DefaultClient, reqBody, nil)
+		assert.FatalError(t, err)
+	})
 }
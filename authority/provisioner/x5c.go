@@ -30,14 +30,17 @@ type x5cPayload struct {
 // signature requests.
 type X5C struct {
 	*base
-	ID       string   `json:"-"`
-	Type     string   `json:"type"`
-	Name     string   `json:"name"`
-	Roots    []byte   `json:"roots"`
-	Claims   *Claims  `json:"claims,omitempty"`
-	Options  *Options `json:"options,omitempty"`
-	ctl      *Controller
-	rootPool *x509.CertPool
+	ID      string   `json:"-"`
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Roots   []byte   `json:"roots"`
+	Claims  *Claims  `json:"claims,omitempty"`
+	Options *Options `json:"options,omitempty"`
+	// Validation are additional requirements on the chains used to
+	// authenticate with the provisioner.
+	Validation *X5CValidation `json:"validation,omitempty"`
+	ctl        *Controller
+	rootPool   *x509.CertPool
 }
 
 // GetID returns the provisioner unique identifier. The name and credential id
@@ -83,6 +86,11 @@ func (p *X5C) GetType() Type {
 	return TypeX5C
 }
 
+// GetOptions returns the configured provisioner options.
+func (p *X5C) GetOptions() *Options {
+	return p.Options
+}
+
 // GetEncryptedKey returns the base provisioner encrypted key if it's defined.
 func (p *X5C) GetEncryptedKey() (string, string, bool) {
 	return "", "", false
@@ -98,6 +106,9 @@ func (p *X5C) Init(config Config) (err error) {
 	case len(p.Roots) == 0:
 		return errors.New("provisioner root(s) cannot be empty")
 	}
+	if err := p.Validation.Validate(); err != nil {
+		return err
+	}
 
 	p.rootPool = x509.NewCertPool()
 
@@ -140,12 +151,16 @@ func (p *X5C) authorizeToken(token string, audiences []string) (*x5cPayload, err
 
 	verifiedChains, err := jwt.Headers[0].Certificates(x509.VerifyOptions{
 		Roots:     p.rootPool,
-		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsages: p.Validation.keyUsages(),
 	})
 	if err != nil {
 		return nil, errs.Wrap(http.StatusUnauthorized, err,
 			"x5c.authorizeToken; error verifying x5c certificate chain in token")
 	}
+	if verifiedChains, err = p.Validation.verifyChains(verifiedChains); err != nil {
+		return nil, errs.Wrap(http.StatusUnauthorized, err,
+			"x5c.authorizeToken; error validating x5c certificate chain in token")
+	}
 	leaf := verifiedChains[0][0]
 
 	if leaf.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
@@ -247,6 +262,7 @@ func (p *X5C) AuthorizeSign(_ context.Context, token string) ([]SignOption, erro
 		defaultSANsValidator(claims.SANs),
 		defaultPublicKeyValidator{},
 		newValidityValidator(p.ctl.Claimer.MinTLSCertDuration(), p.ctl.Claimer.MaxTLSCertDuration()),
+		newMaxSANsValidator(p.ctl.Claimer.MaxCertificateSANs()),
 		newX509NamePolicyValidator(p.ctl.getPolicy().getX509()),
 		p.ctl.newWebhookController(
 			data,
@@ -86,6 +86,15 @@ func TestX5C_Init(t *testing.T) {
 				err: errors.New("claims: MinTLSCertDuration must be greater than 0"),
 			}
 		},
+		"fail/invalid-validation": func(t *testing.T) ProvisionerValidateTest {
+			p, err := generateX5C(nil)
+			assert.FatalError(t, err)
+			p.Validation = &X5CValidation{MaxChainDepth: -1}
+			return ProvisionerValidateTest{
+				p:   p,
+				err: errors.New("validation.maxChainDepth cannot be negative"),
+			}
+		},
 		"ok": func(t *testing.T) ProvisionerValidateTest {
 			p, err := generateX5C(nil)
 			assert.FatalError(t, err)
@@ -471,7 +480,7 @@ func TestX5C_AuthorizeSign(t *testing.T) {
 			} else {
 				if assert.Nil(t, tc.err) {
 					if assert.NotNil(t, opts) {
-						assert.Equals(t, 10, len(opts))
+						assert.Equals(t, 11, len(opts))
 						for _, o := range opts {
 							switch v := o.(type) {
 							case *X5C:
@@ -494,6 +503,8 @@ func TestX5C_AuthorizeSign(t *testing.T) {
 							case *validityValidator:
 								assert.Equals(t, v.min, tc.p.ctl.Claimer.MinTLSCertDuration())
 								assert.Equals(t, v.max, tc.p.ctl.Claimer.MaxTLSCertDuration())
+							case maxSANsValidator:
+								assert.Equals(t, int(v), tc.p.ctl.Claimer.MaxCertificateSANs())
 							case *x509NamePolicyValidator:
 								assert.Equals(t, nil, v.policyEngine)
 							case *WebhookController:
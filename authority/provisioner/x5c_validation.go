@@ -0,0 +1,256 @@
+package provisioner
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/x509util"
+	"golang.org/x/crypto/ocsp"
+)
+
+// X5CValidation are the additional requirements on the certificate chains
+// used to authenticate with an X5C provisioner.
+type X5CValidation struct {
+	// MaxChainDepth is the maximum number of certificates in the verified
+	// chain, including the leaf and the root.
+	MaxChainDepth int `json:"maxChainDepth,omitempty"`
+	// ExtKeyUsages are the extended key usages that the leaf must have. If
+	// empty, the leaf must have the clientAuth extended key usage.
+	ExtKeyUsages x509util.ExtKeyUsage `json:"extKeyUsages,omitempty"`
+	// PolicyIdentifiers are the certificate policies that the leaf must have.
+	PolicyIdentifiers x509util.PolicyIdentifiers `json:"policyIdentifiers,omitempty"`
+	// CheckRevocation checks the status of the leaf and the intermediates
+	// using their OCSP responders or CRL distribution points. Certificates
+	// without them are not checked.
+	CheckRevocation bool `json:"checkRevocation,omitempty"`
+	// RevocationSoftFail accepts the chain if the revocation status cannot be
+	// retrieved.
+	RevocationSoftFail bool `json:"revocationSoftFail,omitempty"`
+}
+
+// Validate validates the X5C validation options.
+func (v *X5CValidation) Validate() error {
+	if v == nil {
+		return nil
+	}
+	if v.MaxChainDepth < 0 {
+		return errors.New("validation.maxChainDepth cannot be negative")
+	}
+	if v.MaxChainDepth == 1 {
+		return errors.New("validation.maxChainDepth must be at least 2")
+	}
+	return nil
+}
+
+// keyUsages returns the extended key usages used to verify the chains.
+func (v *X5CValidation) keyUsages() []x509.ExtKeyUsage {
+	if v == nil || len(v.ExtKeyUsages) == 0 {
+		return []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	return v.ExtKeyUsages
+}
+
+// verifyChains returns the verified chains that satisfy the validation
+// options, the first one is checked for revocation.
+func (v *X5CValidation) verifyChains(chains [][]*x509.Certificate) ([][]*x509.Certificate, error) {
+	if v == nil {
+		return chains, nil
+	}
+
+	var valid [][]*x509.Certificate
+	for _, chain := range chains {
+		if v.MaxChainDepth == 0 || len(chain) <= v.MaxChainDepth {
+			valid = append(valid, chain)
+		}
+	}
+	if len(valid) == 0 {
+		return nil, errors.Errorf("certificate chain exceeds the maximum depth of %d", v.MaxChainDepth)
+	}
+
+	leaf := valid[0][0]
+	for _, eku := range v.ExtKeyUsages {
+		if eku != x509.ExtKeyUsageAny && !hasExtKeyUsage(leaf, eku) {
+			return nil, errors.Errorf("certificate does not have the required extended key usage %d", eku)
+		}
+	}
+	for _, oid := range v.PolicyIdentifiers {
+		if !hasPolicyIdentifier(leaf, oid) {
+			return nil, errors.Errorf("certificate does not have the required policy %s", oid)
+		}
+	}
+
+	if v.CheckRevocation {
+		chain := valid[0]
+		for i := 0; i < len(chain)-1; i++ {
+			if err := checkRevocation(chain[i], chain[i+1], v.RevocationSoftFail); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return valid, nil
+}
+
+func hasExtKeyUsage(crt *x509.Certificate, eku x509.ExtKeyUsage) bool {
+	for _, u := range crt.ExtKeyUsage {
+		if u == eku {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPolicyIdentifier(crt *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, id := range crt.PolicyIdentifiers {
+		if id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+// revocationClient is the client used to get OCSP responses and CRLs.
+var revocationClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// checkRevocation returns an error if crt is revoked. The status is checked
+// with the OCSP responders first, and then with the CRLs. If the status cannot
+// be retrieved an error is returned unless softFail is true.
+func checkRevocation(crt, issuer *x509.Certificate, softFail bool) error {
+	if len(crt.OCSPServer) == 0 && len(crt.CRLDistributionPoints) == 0 {
+		return nil
+	}
+
+	var lastErr error
+	for _, u := range crt.OCSPServer {
+		revoked, err := ocspStatus(u, crt, issuer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if revoked {
+			return errors.Errorf("certificate with serial number %s is revoked", crt.SerialNumber)
+		}
+		return nil
+	}
+	for _, u := range crt.CRLDistributionPoints {
+		revoked, err := crlStatus(u, crt, issuer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if revoked {
+			return errors.Errorf("certificate with serial number %s is revoked", crt.SerialNumber)
+		}
+		return nil
+	}
+
+	if softFail {
+		return nil
+	}
+	return errors.Wrapf(lastErr, "error checking the revocation status of the certificate with serial number %s", crt.SerialNumber)
+}
+
+func ocspStatus(u string, crt, issuer *x509.Certificate) (bool, error) {
+	req, err := ocsp.CreateRequest(crt, issuer, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "error creating ocsp request")
+	}
+	resp, err := revocationClient.Post(u, "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return false, errors.Wrapf(err, "error requesting %s", u)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.Errorf("error requesting %s: status code %d", u, resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return false, errors.Wrapf(err, "error reading %s", u)
+	}
+	r, err := ocsp.ParseResponseForCert(b, crt, issuer)
+	if err != nil {
+		return false, errors.Wrapf(err, "error parsing ocsp response from %s", u)
+	}
+	switch r.Status {
+	case ocsp.Good:
+		return false, nil
+	case ocsp.Revoked:
+		return true, nil
+	default:
+		return false, errors.Errorf("ocsp responder %s returned an unknown status", u)
+	}
+}
+
+type cachedCRL struct {
+	crl       *x509.RevocationList
+	expiresAt time.Time
+}
+
+// crlCache caches the CRLs until their next update.
+var crlCache = struct {
+	sync.Mutex
+	m map[string]cachedCRL
+}{m: make(map[string]cachedCRL)}
+
+func crlStatus(u string, crt, issuer *x509.Certificate) (bool, error) {
+	crl, err := getCRL(u, issuer)
+	if err != nil {
+		return false, err
+	}
+	for _, rc := range crl.RevokedCertificates { //nolint:staticcheck // RevokedCertificateEntries requires Go 1.21
+		if rc.SerialNumber.Cmp(crt.SerialNumber) == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func getCRL(u string, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	now := time.Now()
+	key := u + "\x00" + string(issuer.Raw)
+	crlCache.Lock()
+	c, ok := crlCache.m[key]
+	crlCache.Unlock()
+	if ok && now.Before(c.expiresAt) {
+		return c.crl, nil
+	}
+
+	resp, err := revocationClient.Get(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error requesting %s", u)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("error requesting %s: status code %d", u, resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", u)
+	}
+	crl, err := x509.ParseRevocationList(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing crl from %s", u)
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return nil, errors.Wrapf(err, "error validating crl from %s", u)
+	}
+	if !crl.NextUpdate.IsZero() && now.After(crl.NextUpdate) {
+		return nil, errors.Errorf("crl from %s is expired", u)
+	}
+
+	expiresAt := crl.NextUpdate
+	if expiresAt.IsZero() {
+		expiresAt = now.Add(time.Hour)
+	}
+	crlCache.Lock()
+	crlCache.m[key] = cachedCRL{crl: crl, expiresAt: expiresAt}
+	crlCache.Unlock()
+	return crl, nil
+}
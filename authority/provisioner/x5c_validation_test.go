@@ -0,0 +1,131 @@
+package provisioner
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+	"go.step.sm/crypto/x509util"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestX5CValidation_Validate(t *testing.T) {
+	var v *X5CValidation
+	assert.NoError(t, v.Validate())
+	assert.NoError(t, (&X5CValidation{MaxChainDepth: 2}).Validate())
+	assert.EqualError(t, (&X5CValidation{MaxChainDepth: -1}).Validate(), "validation.maxChainDepth cannot be negative")
+	assert.EqualError(t, (&X5CValidation{MaxChainDepth: 1}).Validate(), "validation.maxChainDepth must be at least 2")
+}
+
+func TestX5CValidation_verifyChains(t *testing.T) {
+	ca, err := minica.New()
+	require.NoError(t, err)
+
+	revokedSerial := big.NewInt(666)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ocsp":
+			b, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			req, err := ocsp.ParseRequest(b)
+			require.NoError(t, err)
+			status := ocsp.Good
+			if req.SerialNumber.Cmp(revokedSerial) == 0 {
+				status = ocsp.Revoked
+			}
+			resp, err := ocsp.CreateResponse(ca.Intermediate, ca.Intermediate, ocsp.Response{
+				Status:       status,
+				SerialNumber: req.SerialNumber,
+				ThisUpdate:   time.Now(),
+				NextUpdate:   time.Now().Add(time.Hour),
+				RevokedAt:    time.Now(),
+			}, ca.Signer)
+			require.NoError(t, err)
+			w.Write(resp)
+		case "/crl":
+			crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+				Number:     big.NewInt(1),
+				ThisUpdate: time.Now(),
+				NextUpdate: time.Now().Add(time.Hour),
+				RevokedCertificates: []pkix.RevokedCertificate{ //nolint:staticcheck // see crlStatus
+					{SerialNumber: revokedSerial, RevocationTime: time.Now()},
+				},
+			}, ca.Intermediate, ca.Signer)
+			require.NoError(t, err)
+			w.Write(crl)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	policy := asn1.ObjectIdentifier{1, 2, 3, 4}
+	newChain := func(serial int64, fn func(*x509.Certificate)) [][]*x509.Certificate {
+		signer, err := keyutil.GenerateDefaultSigner()
+		require.NoError(t, err)
+		tpl := &x509.Certificate{
+			SerialNumber:       big.NewInt(serial),
+			PublicKey:          signer.Public(),
+			ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			PolicyIdentifiers:  []asn1.ObjectIdentifier{policy},
+			KeyUsage:           x509.KeyUsageDigitalSignature,
+			NotBefore:          time.Now(),
+			NotAfter:           time.Now().Add(time.Hour),
+			DNSNames:           []string{"foo.example.com"},
+			SignatureAlgorithm: x509.ECDSAWithSHA256,
+		}
+		if fn != nil {
+			fn(tpl)
+		}
+		leaf, err := ca.Sign(tpl)
+		require.NoError(t, err)
+		return [][]*x509.Certificate{{leaf, ca.Intermediate, ca.Root}}
+	}
+	withOCSP := func(c *x509.Certificate) { c.OCSPServer = []string{srv.URL + "/ocsp"} }
+	withCRL := func(c *x509.Certificate) { c.CRLDistributionPoints = []string{srv.URL + "/crl"} }
+	withMissing := func(c *x509.Certificate) { c.OCSPServer = []string{srv.URL + "/missing"} }
+
+	tests := []struct {
+		name       string
+		validation *X5CValidation
+		chains     [][]*x509.Certificate
+		wantErr    string
+	}{
+		{"ok/nil", nil, newChain(1, nil), ""},
+		{"ok/depth", &X5CValidation{MaxChainDepth: 3}, newChain(1, nil), ""},
+		{"ok/ekus", &X5CValidation{ExtKeyUsages: x509util.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, newChain(1, nil), ""},
+		{"ok/policy", &X5CValidation{PolicyIdentifiers: x509util.PolicyIdentifiers{policy}}, newChain(1, nil), ""},
+		{"ok/no-revocation-info", &X5CValidation{CheckRevocation: true}, newChain(666, nil), ""},
+		{"ok/ocsp", &X5CValidation{CheckRevocation: true}, newChain(1, withOCSP), ""},
+		{"ok/crl", &X5CValidation{CheckRevocation: true}, newChain(1, withCRL), ""},
+		{"ok/soft-fail", &X5CValidation{CheckRevocation: true, RevocationSoftFail: true}, newChain(1, withMissing), ""},
+		{"fail/depth", &X5CValidation{MaxChainDepth: 2}, newChain(1, nil), "certificate chain exceeds the maximum depth of 2"},
+		{"fail/ekus", &X5CValidation{ExtKeyUsages: x509util.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}}, newChain(1, nil), "certificate does not have the required extended key usage 1"},
+		{"fail/policy", &X5CValidation{PolicyIdentifiers: x509util.PolicyIdentifiers{{1, 2, 3, 5}}}, newChain(1, nil), "certificate does not have the required policy 1.2.3.5"},
+		{"fail/ocsp", &X5CValidation{CheckRevocation: true}, newChain(666, withOCSP), "certificate with serial number 666 is revoked"},
+		{"fail/crl", &X5CValidation{CheckRevocation: true}, newChain(666, withCRL), "certificate with serial number 666 is revoked"},
+		{"fail/unavailable", &X5CValidation{CheckRevocation: true}, newChain(1, withMissing), "error checking the revocation status of the certificate with serial number 1: error requesting " + srv.URL + "/missing: status code 404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.validation.verifyChains(tt.chains)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.chains, got)
+		})
+	}
+}
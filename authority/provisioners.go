@@ -99,6 +99,27 @@ func (a *Authority) GetProvisioners(cursor string, limit int) (provisioner.List,
 	return provisioners, nextCursor, nil
 }
 
+// GetACMEClientCertificateRoots returns the roots of the client certificates
+// required by the ACME provisioners. The CA trusts them in the TLS handshake,
+// so they are only read when the CA starts.
+func (a *Authority) GetACMEClientCertificateRoots() []*x509.Certificate {
+	a.adminMutex.RLock()
+	defer a.adminMutex.RUnlock()
+	var roots []*x509.Certificate
+	for cursor := ""; ; {
+		var list provisioner.List
+		list, cursor = a.provisioners.Find(cursor, 100)
+		for _, p := range list {
+			if acmeProv, ok := p.(*provisioner.ACME); ok {
+				roots = append(roots, acmeProv.GetClientCertificateRoots()...)
+			}
+		}
+		if cursor == "" {
+			return roots
+		}
+	}
+}
+
 // LoadProvisionerByCertificate returns an interface to the provisioner that
 // provisioned the certificate.
 func (a *Authority) LoadProvisionerByCertificate(crt *x509.Certificate) (provisioner.Interface, error) {
@@ -201,7 +222,9 @@ func (a *Authority) generateProvisionerConfig(ctx context.Context) (provisioner.
 		GetIdentityFunc:       a.getIdentityFunc,
 		AuthorizeRenewFunc:    a.authorizeRenewFunc,
 		AuthorizeSSHRenewFunc: a.authorizeSSHRenewFunc,
+		GetCredentialsFunc:    a.getCredentialsFunc,
 		WebhookClient:         a.webhookClient,
+		Webhooks:              a.config.Webhooks.GetIssuanceWebhooks(),
 	}, nil
 }
 
@@ -0,0 +1,168 @@
+// Package publisher writes the certificates signed by the CA to external
+// stores, HashiCorp Vault, AWS Secrets Manager and Kubernetes secrets, as
+// configured in the publishers of the provisioners. The systems that read the
+// certificates from these stores receive the new certificates without running
+// a renewal client.
+//
+// The CA does not have the private keys of the certificates. The values of a
+// secret other than the certificates are kept, so the private key can be
+// written in the same secret once by the owner of the key.
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// Keys of the values written in the secrets.
+const (
+	// CertificateKey is the key of the certificate followed by the
+	// intermediates, in PEM format.
+	CertificateKey = "tls.crt"
+	// RootsKey is the key of the root certificates of the CA, in PEM format.
+	RootsKey = "ca.crt"
+)
+
+// Defaults of the delivery.
+var (
+	publishTimeout    = 30 * time.Second
+	publishRetries    = 3
+	publishRetryDelay = time.Second
+	publishQueueSize  = 1000
+)
+
+// Store is the interface implemented by the external stores.
+type Store interface {
+	// Put writes the given values in the secret with the given name, keeping
+	// the other values of the secret if it already exists. The publisher has
+	// the options of the store, like the Vault mount or the Kubernetes
+	// namespace.
+	Put(ctx context.Context, pub *provisioner.Publisher, name string, values map[string][]byte) error
+}
+
+// NewStoreFunc is the function that creates a store. Stores are created the
+// first time a certificate is published in them.
+type NewStoreFunc func(ctx context.Context) (Store, error)
+
+// newStores are the functions that create the stores of each publisher type.
+// They can be replaced in tests.
+var newStores = map[string]NewStoreFunc{
+	provisioner.VaultPublisher:             newVaultStore,
+	provisioner.AWSSecretsManagerPublisher: newAWSStore,
+	provisioner.KubernetesPublisher:        newKubernetesStore,
+}
+
+type job struct {
+	publishers  []*provisioner.Publisher
+	provisioner string
+	chain       []*x509.Certificate
+}
+
+// Publisher writes the certificates in the stores in the background. Each
+// write is retried a few times if the store fails.
+type Publisher struct {
+	roots  []byte
+	stores map[string]Store
+	queue  chan *job
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// New creates a new publisher and starts the delivery goroutine. The given
+// roots are written with every certificate.
+func New(roots []*x509.Certificate) *Publisher {
+	var buf bytes.Buffer
+	for _, crt := range roots {
+		_ = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+	}
+	p := &Publisher{
+		roots:  buf.Bytes(),
+		stores: make(map[string]Store),
+		queue:  make(chan *job, publishQueueSize),
+	}
+	p.wg.Add(1)
+	go p.run()
+	return p
+}
+
+// Publish queues a certificate chain to be written by the given publishers of
+// a provisioner. The chain is dropped if the queue is full.
+func (p *Publisher) Publish(publishers []*provisioner.Publisher, provisionerName string, chain []*x509.Certificate) {
+	if len(publishers) == 0 || len(chain) == 0 {
+		return
+	}
+	select {
+	case p.queue <- &job{publishers: publishers, provisioner: provisionerName, chain: chain}:
+	default:
+		log.Printf("error publishing certificate %s: queue is full", chain[0].SerialNumber)
+	}
+}
+
+// Close writes the queued certificates and stops the publisher.
+func (p *Publisher) Close() {
+	p.once.Do(func() {
+		close(p.queue)
+	})
+	p.wg.Wait()
+}
+
+func (p *Publisher) run() {
+	defer p.wg.Done()
+	for j := range p.queue {
+		var buf bytes.Buffer
+		for _, crt := range j.chain {
+			_ = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+		}
+		values := map[string][]byte{
+			CertificateKey: buf.Bytes(),
+			RootsKey:       p.roots,
+		}
+		for _, pub := range j.publishers {
+			if err := p.deliver(pub, j, values); err != nil {
+				log.Printf("error publishing certificate %s to %s: %v", j.chain[0].SerialNumber, pub.Type, err)
+			}
+		}
+	}
+}
+
+func (p *Publisher) deliver(pub *provisioner.Publisher, j *job, values map[string][]byte) error {
+	name, err := pub.SecretName(j.chain[0], j.provisioner)
+	if err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		err := p.put(pub, name, values)
+		if err == nil || i+1 >= publishRetries {
+			return errors.Wrapf(err, "error writing secret %s", name)
+		}
+		time.Sleep(publishRetryDelay * time.Duration(i+1))
+	}
+}
+
+func (p *Publisher) put(pub *provisioner.Publisher, name string, values map[string][]byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	s, ok := p.stores[pub.Type]
+	if !ok {
+		fn, ok := newStores[pub.Type]
+		if !ok {
+			return errors.Errorf("publisher type %q is not supported", pub.Type)
+		}
+		var err error
+		if s, err = fn(ctx); err != nil {
+			return errors.Wrapf(err, "error creating %s client", pub.Type)
+		}
+		p.stores[pub.Type] = s
+	}
+	return s.Put(ctx, pub, name, values)
+}
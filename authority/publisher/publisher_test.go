@@ -0,0 +1,121 @@
+package publisher
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+type fakeStore struct {
+	mu      sync.Mutex
+	fails   int
+	secrets map[string]map[string][]byte
+}
+
+func (s *fakeStore) Put(_ context.Context, pub *provisioner.Publisher, name string, values map[string][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fails > 0 {
+		s.fails--
+		return errors.New("force")
+	}
+	s.secrets[pub.Namespace+"/"+name] = values
+	return nil
+}
+
+func setFakeStore(t *testing.T, typ string, s Store) {
+	t.Helper()
+	old, oldDelay := newStores, publishRetryDelay
+	newStores = map[string]NewStoreFunc{
+		typ: func(context.Context) (Store, error) { return s, nil },
+	}
+	publishRetryDelay = time.Millisecond
+	t.Cleanup(func() {
+		newStores, publishRetryDelay = old, oldDelay
+	})
+}
+
+func TestPublisher(t *testing.T) {
+	store := &fakeStore{fails: 1, secrets: map[string]map[string][]byte{}}
+	setFakeStore(t, provisioner.KubernetesPublisher, store)
+
+	root := &x509.Certificate{Raw: []byte("root")}
+	intermediate := &x509.Certificate{Raw: []byte("intermediate")}
+	leaf := &x509.Certificate{
+		Raw:          []byte("leaf"),
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		SerialNumber: big.NewInt(1234),
+	}
+	publishers := []*provisioner.Publisher{
+		{Type: provisioner.KubernetesPublisher, Name: "{{ .CommonName }}-tls", Namespace: "web"},
+		{Type: provisioner.KubernetesPublisher, Name: "{{ .Provisioner }}-{{ .SerialNumber }}"},
+		{Type: provisioner.VaultPublisher, Name: "certs/{{ .CommonName }}"},
+	}
+	for _, pub := range publishers {
+		if err := pub.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := New([]*x509.Certificate{root})
+	p.Publish(publishers, "jwk", []*x509.Certificate{leaf, intermediate})
+	p.Publish(publishers, "jwk", nil)
+	p.Close()
+	p.Close()
+
+	encode := func(certs ...*x509.Certificate) string {
+		var s string
+		for _, crt := range certs {
+			s += string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw}))
+		}
+		return s
+	}
+	if len(store.secrets) != 2 {
+		t.Fatalf("stored secrets = %v, want 2 secrets", store.secrets)
+	}
+	for _, name := range []string{"web/www.example.com-tls", "/jwk-1234"} {
+		values, ok := store.secrets[name]
+		if !ok {
+			t.Errorf("secret %s was not stored", name)
+			continue
+		}
+		if got, want := string(values[CertificateKey]), encode(leaf, intermediate); got != want {
+			t.Errorf("secret %s %s = %q, want %q", name, CertificateKey, got, want)
+		}
+		if got, want := string(values[RootsKey]), encode(root); got != want {
+			t.Errorf("secret %s %s = %q, want %q", name, RootsKey, got, want)
+		}
+	}
+}
+
+func TestPublisher_deliver(t *testing.T) {
+	store := &fakeStore{fails: publishRetries, secrets: map[string]map[string][]byte{}}
+	setFakeStore(t, provisioner.AWSSecretsManagerPublisher, store)
+
+	p := &Publisher{stores: map[string]Store{}}
+	j := &job{
+		provisioner: "jwk",
+		chain:       []*x509.Certificate{{Subject: pkix.Name{CommonName: "www.example.com"}, SerialNumber: big.NewInt(1)}},
+	}
+	pub := &provisioner.Publisher{Type: provisioner.AWSSecretsManagerPublisher, Name: "{{ .CommonName }}"}
+	if err := p.deliver(pub, j, nil); err == nil {
+		t.Error("Publisher.deliver() error = nil")
+	}
+	if err := p.deliver(pub, j, nil); err != nil {
+		t.Errorf("Publisher.deliver() error = %v", err)
+	}
+	if err := p.deliver(&provisioner.Publisher{Type: "gcpsm", Name: "{{ .CommonName }}"}, j, nil); err == nil {
+		t.Error("Publisher.deliver() error = nil")
+	}
+	if err := p.deliver(&provisioner.Publisher{Type: provisioner.AWSSecretsManagerPublisher, Name: "{{ .Missing }}"}, j, nil); err == nil {
+		t.Error("Publisher.deliver() error = nil")
+	}
+}
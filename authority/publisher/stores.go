@@ -0,0 +1,157 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	vault "github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/kubernetes"
+)
+
+// defaultVaultMount is the default mount path of the KV secrets engine.
+const defaultVaultMount = "secret"
+
+// vaultStore writes the certificates in a KV version 2 secrets engine of
+// Vault.
+type vaultStore struct {
+	client *vault.Client
+}
+
+func newVaultStore(context.Context) (Store, error) {
+	client, err := vault.NewClient(vault.DefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	return &vaultStore{client: client}, nil
+}
+
+func (s *vaultStore) Put(ctx context.Context, pub *provisioner.Publisher, name string, values map[string][]byte) error {
+	mount := pub.Mount
+	if mount == "" {
+		mount = defaultVaultMount
+	}
+	kv := s.client.KVv2(mount)
+
+	data := make(map[string]interface{})
+	sec, err := kv.Get(ctx, name)
+	switch {
+	case errors.Is(err, vault.ErrSecretNotFound):
+	case err != nil:
+		return err
+	case sec != nil:
+		for k, v := range sec.Data {
+			data[k] = v
+		}
+	}
+	for k, v := range values {
+		data[k] = string(v)
+	}
+	_, err = kv.Put(ctx, name, data)
+	return err
+}
+
+// awsStore writes the certificates in AWS Secrets Manager. The value of the
+// secret is a JSON object.
+type awsStore struct {
+	client secretsmanageriface.SecretsManagerAPI
+}
+
+func newAWSStore(context.Context) (Store, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &awsStore{client: secretsmanager.New(sess)}, nil
+}
+
+func (s *awsStore) Put(ctx context.Context, _ *provisioner.Publisher, name string, values map[string][]byte) error {
+	data := make(map[string]string)
+	out, err := s.client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	var aerr awserr.Error
+	exists := true
+	switch {
+	case errors.As(err, &aerr) && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException:
+		exists = false
+	case err != nil:
+		return err
+	case out.SecretString != nil:
+		if err := json.Unmarshal([]byte(*out.SecretString), &data); err != nil {
+			return errors.Errorf("secret %s is not a JSON object of strings", name)
+		}
+	}
+	for k, v := range values {
+		data[k] = string(v)
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrap(err, "error encoding secret")
+	}
+
+	if !exists {
+		_, err = s.client.CreateSecretWithContext(ctx, &secretsmanager.CreateSecretInput{
+			Name:         aws.String(name),
+			SecretString: aws.String(string(b)),
+		})
+		return err
+	}
+	_, err = s.client.PutSecretValueWithContext(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(name),
+		SecretString: aws.String(string(b)),
+	})
+	return err
+}
+
+// newKubernetesClient is the function used to create the client of the
+// Kubernetes API. It can be replaced in tests.
+var newKubernetesClient = kubernetes.NewInClusterClient
+
+// kubernetesStore writes the certificates in Kubernetes secrets. New secrets
+// are Opaque secrets, the type of an existing secret is kept.
+type kubernetesStore struct {
+	client *kubernetes.Client
+}
+
+func newKubernetesStore(context.Context) (Store, error) {
+	client, err := newKubernetesClient()
+	if err != nil {
+		return nil, err
+	}
+	return &kubernetesStore{client: client}, nil
+}
+
+func (s *kubernetesStore) Put(ctx context.Context, pub *provisioner.Publisher, name string, values map[string][]byte) error {
+	sec := &kubernetes.Secret{
+		Metadata: kubernetes.Metadata{Name: name, Namespace: pub.Namespace},
+		Type:     "Opaque",
+		Data:     make(map[string][]byte),
+	}
+	current, err := s.client.GetSecret(ctx, pub.Namespace, name)
+	switch {
+	case errors.Is(err, kubernetes.ErrNotFound):
+	case err != nil:
+		return err
+	default:
+		if current.Type != "" {
+			sec.Type = current.Type
+		}
+		for k, v := range current.Data {
+			sec.Data[k] = v
+		}
+	}
+	for k, v := range values {
+		sec.Data[k] = v
+	}
+	return s.client.ApplySecret(ctx, sec)
+}
@@ -0,0 +1,164 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/kubernetes"
+)
+
+// fakeKubernetesAPI stores the Kubernetes secrets in memory.
+type fakeKubernetesAPI struct {
+	mu      sync.Mutex
+	secrets map[string]*kubernetes.Secret
+}
+
+func (f *fakeKubernetesAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/namespaces/"), "/")
+	switch r.Method {
+	case http.MethodGet:
+		s, ok := f.secrets[parts[0]+"/"+parts[2]]
+		if !ok {
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(s)
+	case http.MethodPost, http.MethodPut:
+		var s kubernetes.Secret
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, `{"message":"bad request"}`, http.StatusBadRequest)
+			return
+		}
+		f.secrets[parts[0]+"/"+s.Metadata.Name] = &s
+		json.NewEncoder(w).Encode(s)
+	default:
+		http.Error(w, `{"message":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestKubernetesStore_Put(t *testing.T) {
+	f := &fakeKubernetesAPI{secrets: map[string]*kubernetes.Secret{
+		"web/web-tls": {
+			Metadata: kubernetes.Metadata{Name: "web-tls", Namespace: "web"},
+			Type:     "kubernetes.io/tls",
+			Data:     map[string][]byte{"tls.key": []byte("key"), "tls.crt": []byte("old")},
+		},
+	}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	old := newKubernetesClient
+	newKubernetesClient = func() (*kubernetes.Client, error) {
+		return kubernetes.NewClient(srv.URL, "the-token", "default", srv.Client())
+	}
+	t.Cleanup(func() { newKubernetesClient = old })
+
+	s, err := newKubernetesStore(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string][]byte{CertificateKey: []byte("crt"), RootsKey: []byte("roots")}
+
+	// Update keeps the type and the private key.
+	if err := s.Put(context.Background(), &provisioner.Publisher{Namespace: "web"}, "web-tls", values); err != nil {
+		t.Fatalf("kubernetesStore.Put() error = %v", err)
+	}
+	got := f.secrets["web/web-tls"]
+	if got.Type != "kubernetes.io/tls" {
+		t.Errorf("kubernetesStore.Put() type = %s, want kubernetes.io/tls", got.Type)
+	}
+	if want := map[string][]byte{"tls.key": []byte("key"), "tls.crt": []byte("crt"), "ca.crt": []byte("roots")}; !reflect.DeepEqual(got.Data, want) {
+		t.Errorf("kubernetesStore.Put() data = %v, want %v", got.Data, want)
+	}
+
+	// Create uses the default namespace.
+	if err := s.Put(context.Background(), &provisioner.Publisher{}, "api-tls", values); err != nil {
+		t.Fatalf("kubernetesStore.Put() error = %v", err)
+	}
+	got, ok := f.secrets["default/api-tls"]
+	if !ok {
+		t.Fatal("kubernetesStore.Put() did not create the secret")
+	}
+	if got.Type != "Opaque" || !reflect.DeepEqual(got.Data, values) {
+		t.Errorf("kubernetesStore.Put() secret = %v", got)
+	}
+}
+
+// fakeSecretsManager stores the AWS secrets in memory.
+type fakeSecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+	secrets map[string]string
+}
+
+func (f *fakeSecretsManager) GetSecretValueWithContext(_ aws.Context, in *secretsmanager.GetSecretValueInput, _ ...request.Option) (*secretsmanager.GetSecretValueOutput, error) {
+	v, ok := f.secrets[*in.SecretId]
+	if !ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(v)}, nil
+}
+
+func (f *fakeSecretsManager) CreateSecretWithContext(_ aws.Context, in *secretsmanager.CreateSecretInput, _ ...request.Option) (*secretsmanager.CreateSecretOutput, error) {
+	f.secrets[*in.Name] = *in.SecretString
+	return &secretsmanager.CreateSecretOutput{}, nil
+}
+
+func (f *fakeSecretsManager) PutSecretValueWithContext(_ aws.Context, in *secretsmanager.PutSecretValueInput, _ ...request.Option) (*secretsmanager.PutSecretValueOutput, error) {
+	f.secrets[*in.SecretId] = *in.SecretString
+	return &secretsmanager.PutSecretValueOutput{}, nil
+}
+
+func TestAWSStore_Put(t *testing.T) {
+	f := &fakeSecretsManager{secrets: map[string]string{
+		"certs/web":     `{"tls.key":"key","tls.crt":"old"}`,
+		"certs/invalid": `not json`,
+	}}
+	s := &awsStore{client: f}
+	values := map[string][]byte{CertificateKey: []byte("crt"), RootsKey: []byte("roots")}
+	pub := &provisioner.Publisher{Type: provisioner.AWSSecretsManagerPublisher}
+
+	tests := []struct {
+		name    string
+		secret  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"ok update", "certs/web", map[string]string{"tls.key": "key", "tls.crt": "crt", "ca.crt": "roots"}, false},
+		{"ok create", "certs/api", map[string]string{"tls.crt": "crt", "ca.crt": "roots"}, false},
+		{"fail invalid", "certs/invalid", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Put(context.Background(), pub, tt.secret, values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("awsStore.Put() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var got map[string]string
+			if err := json.Unmarshal([]byte(f.secrets[tt.secret]), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("awsStore.Put() secret = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package authority
+
+import (
+	"crypto/x509"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/publisher"
+)
+
+// initPublisher starts the publisher that writes the certificates in the
+// external stores configured in the provisioners.
+func (a *Authority) initPublisher() {
+	a.certPublisher = publisher.New(a.rootX509Certs)
+}
+
+// stopPublisher writes the pending certificates and stops the publisher.
+func (a *Authority) stopPublisher() {
+	if a.certPublisher != nil {
+		a.certPublisher.Close()
+	}
+}
+
+// publishCertificate queues the certificate chain to be written in the
+// external stores configured in the publishers of the provisioner.
+func (a *Authority) publishCertificate(prov provisioner.Interface, fullchain []*x509.Certificate) {
+	if a.certPublisher == nil {
+		return
+	}
+	p, ok := prov.(interface{ GetOptions() *provisioner.Options })
+	if !ok {
+		return
+	}
+	if publishers := p.GetOptions().GetPublishers(); len(publishers) > 0 {
+		a.certPublisher.Publish(publishers, prov.GetName(), fullchain)
+	}
+}
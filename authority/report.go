@@ -0,0 +1,134 @@
+package authority
+
+import (
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/report"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/errs"
+)
+
+// GetExpiringCertificates returns the stored X.509 certificates that expire
+// in the given period. Revoked, expired and CA certificates are not
+// included. If includeSuperseded is false, the certificates that have been
+// replaced by a newer one with the same subject, SANs and provisioner, for
+// example after a renewal, are not included either.
+func (a *Authority) GetExpiringCertificates(within time.Duration, includeSuperseded bool) ([]*report.Certificate, error) {
+	lister, ok := a.db.(db.CertificateLister)
+	if !ok {
+		return nil, errs.NotImplemented("authority.GetExpiringCertificates; database does not support listing certificates")
+	}
+	certs, err := lister.GetCertificates()
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.GetExpiringCertificates")
+	}
+
+	now := time.Now()
+	limit := now.Add(within)
+	var valid, expiring []*report.Certificate
+	for _, crt := range certs {
+		if crt.IsCA || !now.Before(crt.NotAfter) {
+			continue
+		}
+		if isRevoked, err := a.IsRevoked(crt.SerialNumber.String()); err != nil || isRevoked {
+			continue
+		}
+		name, typ := a.getCertificateProvisioner(crt)
+		c := report.NewCertificate(crt, name, typ)
+		valid = append(valid, c)
+		if !crt.NotAfter.After(limit) {
+			expiring = append(expiring, c)
+		}
+	}
+
+	if includeSuperseded {
+		return expiring, nil
+	}
+	return report.RemoveSuperseded(expiring, valid), nil
+}
+
+// ListCertificates returns all the stored X.509 certificates that are not CA
+// certificates, with the provisioner that issued them and their status.
+func (a *Authority) ListCertificates() ([]*report.Certificate, error) {
+	lister, ok := a.db.(db.CertificateLister)
+	if !ok {
+		return nil, errs.NotImplemented("authority.ListCertificates; database does not support listing certificates")
+	}
+	certs, err := lister.GetCertificates()
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.ListCertificates")
+	}
+
+	now := time.Now()
+	list := make([]*report.Certificate, 0, len(certs))
+	for _, crt := range certs {
+		if crt.IsCA {
+			continue
+		}
+		name, typ := a.getCertificateProvisioner(crt)
+		c := report.NewCertificate(crt, name, typ)
+		switch isRevoked, err := a.IsRevoked(c.SerialNumber); {
+		case err != nil:
+			return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.ListCertificates")
+		case isRevoked:
+			c.Status = report.StatusRevoked
+		case !now.Before(crt.NotAfter):
+			c.Status = report.StatusExpired
+		default:
+			c.Status = report.StatusValid
+		}
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+// getCertificateProvisioner returns the name and type of the provisioner that
+// issued the certificate. It uses the data stored with the certificate, and
+// the provisioner extension if it is not available.
+func (a *Authority) getCertificateProvisioner(crt *x509.Certificate) (string, string) {
+	type certificateDataGetter interface {
+		GetCertificateData(string) (*db.CertificateData, error)
+	}
+	if cdg, ok := a.db.(certificateDataGetter); ok {
+		if data, err := cdg.GetCertificateData(crt.SerialNumber.String()); err == nil && data != nil && data.Provisioner != nil {
+			return data.Provisioner.Name, data.Provisioner.Type
+		}
+	}
+
+	a.adminMutex.RLock()
+	defer a.adminMutex.RUnlock()
+	if p, ok := a.provisioners.LoadByCertificate(crt); ok && p.GetType() != 0 {
+		return p.GetName(), p.GetType().String()
+	}
+	return "", ""
+}
+
+// recordSign adds the result of a sign request to the stats. The provisioner of
+// the signed certificates is wrapped, and the wrapped one is nil if the sign
+// request did not have a provisioner.
+func (a *Authority) recordSign(prov provisioner.Interface, err error) {
+	if a.signStats == nil {
+		return
+	}
+	if wp, ok := prov.(*wrappedProvisioner); ok {
+		prov = wp.Interface
+	}
+	var name, typ string
+	if prov != nil {
+		name, typ = prov.GetName(), prov.GetType().String()
+	}
+	a.signStats.Record(time.Now(), name, typ, err != nil)
+}
+
+// GetSignResults returns the number of sign requests and failures by
+// provisioner since the given time. The results are kept in memory by each
+// instance of the CA for the report.SignStatsRetention period.
+func (a *Authority) GetSignResults(since time.Time) ([]*report.SignResult, error) {
+	if a.signStats == nil {
+		return nil, errs.NotImplemented("authority.GetSignResults; sign stats are not available")
+	}
+	return a.signStats.Results(since), nil
+}
@@ -0,0 +1,107 @@
+package report
+
+import (
+	"sort"
+	"time"
+)
+
+// Supported intervals of the buckets of a time series.
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+)
+
+// IntervalDuration returns the duration of an interval.
+func IntervalDuration(interval string) (time.Duration, bool) {
+	switch interval {
+	case IntervalHour:
+		return time.Hour, true
+	case IntervalDay:
+		return 24 * time.Hour, true
+	default:
+		return 0, false
+	}
+}
+
+// Bucket is the number of certificates in a period of a time series.
+type Bucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// TimeSeries counts the certificates by periods of the given duration between
+// from and to, using the time returned by fn. Periods are aligned to UTC, and
+// the ones without certificates are also included.
+func TimeSeries(certs []*Certificate, from, to time.Time, d time.Duration, fn func(*Certificate) time.Time) []*Bucket {
+	start := from.UTC().Truncate(d)
+	if to.Before(start) {
+		return []*Bucket{}
+	}
+	buckets := make([]*Bucket, int(to.Sub(start)/d)+1)
+	for i := range buckets {
+		buckets[i] = &Bucket{Start: start.Add(time.Duration(i) * d)}
+	}
+	for _, c := range certs {
+		t := fn(c)
+		if t.Before(from) || t.After(to) {
+			continue
+		}
+		buckets[int(t.Sub(start)/d)].Count++
+	}
+	return buckets
+}
+
+// IssuedAt returns the time a certificate was issued, the start of its
+// validity.
+func IssuedAt(c *Certificate) time.Time {
+	return c.NotBefore
+}
+
+// ExpiresAt returns the time a certificate expires.
+func ExpiresAt(c *Certificate) time.Time {
+	return c.NotAfter
+}
+
+// ProvisionerCount is the number of certificates issued by a provisioner.
+type ProvisionerCount struct {
+	Name  string `json:"name"`
+	Type  string `json:"type,omitempty"`
+	Count int    `json:"count"`
+}
+
+// TopProvisioners returns the provisioners that issued more certificates
+// since the given time, sorted by the number of certificates and name. It
+// returns at most limit provisioners, all of them if limit is 0.
+func TopProvisioners(certs []*Certificate, since time.Time, limit int) []*ProvisionerCount {
+	counts := make(map[string]*ProvisionerCount)
+	for _, c := range certs {
+		if c.NotBefore.Before(since) {
+			continue
+		}
+		name := c.ProvisionerName
+		if name == "" {
+			name = Unknown
+		}
+		pc, ok := counts[name]
+		if !ok {
+			pc = &ProvisionerCount{Name: name, Type: c.ProvisionerType}
+			counts[name] = pc
+		}
+		pc.Count++
+	}
+
+	ret := make([]*ProvisionerCount, 0, len(counts))
+	for _, pc := range counts {
+		ret = append(ret, pc)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Count != ret[j].Count {
+			return ret[i].Count > ret[j].Count
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	if limit > 0 && len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret
+}
@@ -0,0 +1,76 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntervalDuration(t *testing.T) {
+	d, ok := IntervalDuration(IntervalHour)
+	assert.True(t, ok)
+	assert.Equal(t, time.Hour, d)
+	d, ok = IntervalDuration(IntervalDay)
+	assert.True(t, ok)
+	assert.Equal(t, 24*time.Hour, d)
+	_, ok = IntervalDuration("week")
+	assert.False(t, ok)
+}
+
+func TestTimeSeries(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	certs := []*Certificate{
+		{SerialNumber: "1", NotBefore: day.Add(time.Hour)},
+		{SerialNumber: "2", NotBefore: day.Add(2 * time.Hour)},
+		{SerialNumber: "3", NotBefore: day.Add(50 * time.Hour)},
+		{SerialNumber: "4", NotBefore: day.Add(-time.Hour)},
+		{SerialNumber: "5", NotBefore: day.Add(100 * time.Hour)},
+	}
+
+	got := TimeSeries(certs, day.Add(30*time.Minute), day.Add(72*time.Hour), 24*time.Hour, IssuedAt)
+	assert.Equal(t, []*Bucket{
+		{Start: day, Count: 2},
+		{Start: day.Add(24 * time.Hour), Count: 0},
+		{Start: day.Add(48 * time.Hour), Count: 1},
+		{Start: day.Add(72 * time.Hour), Count: 0},
+	}, got)
+
+	got = TimeSeries(certs, day, day.Add(2*time.Hour), time.Hour, IssuedAt)
+	assert.Equal(t, []*Bucket{
+		{Start: day, Count: 0},
+		{Start: day.Add(time.Hour), Count: 1},
+		{Start: day.Add(2 * time.Hour), Count: 1},
+	}, got)
+
+	assert.Equal(t, []*Bucket{}, TimeSeries(certs, day, day.Add(-48*time.Hour), time.Hour, IssuedAt))
+}
+
+func TestTopProvisioners(t *testing.T) {
+	now := time.Now()
+	certs := []*Certificate{
+		{NotBefore: now, ProvisionerName: "jwk", ProvisionerType: "JWK"},
+		{NotBefore: now, ProvisionerName: "acme", ProvisionerType: "ACME"},
+		{NotBefore: now, ProvisionerName: "acme", ProvisionerType: "ACME"},
+		{NotBefore: now, ProvisionerName: "x5c", ProvisionerType: "X5C"},
+		{NotBefore: now},
+		{NotBefore: now.Add(-48 * time.Hour), ProvisionerName: "x5c", ProvisionerType: "X5C"},
+		{NotBefore: now.Add(-48 * time.Hour), ProvisionerName: "old", ProvisionerType: "JWK"},
+	}
+
+	assert.Equal(t, []*ProvisionerCount{
+		{Name: "acme", Type: "ACME", Count: 2},
+		{Name: "jwk", Type: "JWK", Count: 1},
+		{Name: "unknown", Count: 1},
+		{Name: "x5c", Type: "X5C", Count: 1},
+	}, TopProvisioners(certs, now.Add(-time.Hour), 0))
+
+	assert.Equal(t, []*ProvisionerCount{
+		{Name: "acme", Type: "ACME", Count: 2},
+	}, TopProvisioners(certs, now.Add(-time.Hour), 1))
+
+	assert.Equal(t, []*ProvisionerCount{
+		{Name: "acme", Type: "ACME", Count: 2},
+		{Name: "x5c", Type: "X5C", Count: 2},
+	}, TopProvisioners(certs, now.Add(-72*time.Hour), 2))
+}
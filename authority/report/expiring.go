@@ -0,0 +1,208 @@
+// Package report implements the reports of the certificates issued by the
+// authority.
+package report
+
+import (
+	"crypto/x509"
+	"encoding/csv"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Supported values to group the certificates.
+const (
+	GroupByProvisioner = "provisioner"
+	GroupByDomain      = "domain"
+)
+
+// Status of the certificates in a list.
+const (
+	StatusValid   = "valid"
+	StatusExpired = "expired"
+	StatusRevoked = "revoked"
+)
+
+// Unknown is the name of the group of certificates without provisioner or
+// without domain.
+const Unknown = "unknown"
+
+// Certificate is a certificate in a report.
+type Certificate struct {
+	SerialNumber    string    `json:"serialNumber"`
+	Subject         string    `json:"subject"`
+	SANs            []string  `json:"sans"`
+	NotBefore       time.Time `json:"notBefore"`
+	NotAfter        time.Time `json:"notAfter"`
+	ProvisionerName string    `json:"provisionerName,omitempty"`
+	ProvisionerType string    `json:"provisionerType,omitempty"`
+	Status          string    `json:"status,omitempty"`
+
+	commonName string
+	dnsNames   []string
+}
+
+// NewCertificate creates a report certificate from the given X.509
+// certificate and the provisioner that issued it.
+func NewCertificate(crt *x509.Certificate, provisionerName, provisionerType string) *Certificate {
+	sans := make([]string, 0, len(crt.DNSNames)+len(crt.IPAddresses)+len(crt.EmailAddresses)+len(crt.URIs))
+	sans = append(sans, crt.DNSNames...)
+	for _, ip := range crt.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, crt.EmailAddresses...)
+	for _, u := range crt.URIs {
+		sans = append(sans, u.String())
+	}
+	return &Certificate{
+		SerialNumber:    crt.SerialNumber.String(),
+		Subject:         crt.Subject.String(),
+		SANs:            sans,
+		NotBefore:       crt.NotBefore,
+		NotAfter:        crt.NotAfter,
+		ProvisionerName: provisionerName,
+		ProvisionerType: provisionerType,
+		commonName:      crt.Subject.CommonName,
+		dnsNames:        crt.DNSNames,
+	}
+}
+
+// CommonName returns the common name of the subject of the certificate.
+func (c *Certificate) CommonName() string {
+	return c.commonName
+}
+
+// identity returns the key used to detect if a certificate has been replaced
+// by a newer one.
+func (c *Certificate) identity() string {
+	sans := slices.Clone(c.SANs)
+	sort.Strings(sans)
+	return c.ProvisionerName + "\x00" + c.Subject + "\x00" + strings.Join(sans, "\x00")
+}
+
+// RemoveSuperseded removes the certificates that have been replaced by a newer
+// certificate, not in the list, with the same subject, SANs and provisioner.
+// The renewed certificates are an example of those. The all list must contain
+// all the valid certificates.
+func RemoveSuperseded(expiring, all []*Certificate) []*Certificate {
+	latest := make(map[string]time.Time, len(all))
+	for _, c := range all {
+		id := c.identity()
+		if c.NotAfter.After(latest[id]) {
+			latest[id] = c.NotAfter
+		}
+	}
+	ret := make([]*Certificate, 0, len(expiring))
+	for _, c := range expiring {
+		if !latest[c.identity()].After(c.NotAfter) {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// Group is a list of certificates with the same provisioner or domain.
+type Group struct {
+	Name         string         `json:"name"`
+	Count        int            `json:"count"`
+	Certificates []*Certificate `json:"certificates"`
+}
+
+// GroupCertificates groups the certificates by provisioner name, or by the
+// registered domain of their DNS names. A certificate with names in multiple
+// domains is added to all of them. Groups are sorted by name, and
+// certificates by expiration.
+func GroupCertificates(certs []*Certificate, groupBy string) []*Group {
+	groups := make(map[string]*Group)
+	add := func(name string, c *Certificate) {
+		g, ok := groups[name]
+		if !ok {
+			g = &Group{Name: name}
+			groups[name] = g
+		}
+		g.Certificates = append(g.Certificates, c)
+		g.Count++
+	}
+
+	for _, c := range certs {
+		switch groupBy {
+		case GroupByDomain:
+			domains := c.domains()
+			if len(domains) == 0 {
+				add(Unknown, c)
+			}
+			for _, d := range domains {
+				add(d, c)
+			}
+		default:
+			name := c.ProvisionerName
+			if name == "" {
+				name = Unknown
+			}
+			add(name, c)
+		}
+	}
+
+	ret := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		sort.SliceStable(g.Certificates, func(i, j int) bool {
+			return g.Certificates[i].NotAfter.Before(g.Certificates[j].NotAfter)
+		})
+		ret = append(ret, g)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// domains returns the registered domains of the DNS names.
+func (c *Certificate) domains() []string {
+	var domains []string
+	for _, name := range c.dnsNames {
+		name = strings.ToLower(strings.TrimPrefix(name, "*."))
+		if net.ParseIP(name) != nil {
+			continue
+		}
+		domain, err := publicsuffix.EffectiveTLDPlusOne(name)
+		if err != nil {
+			domain = name
+		}
+		if !slices.Contains(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// WriteCSV writes the groups in CSV format, with a header and one line per
+// certificate and group.
+func WriteCSV(w io.Writer, groups []*Group) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"group", "serialNumber", "subject", "sans", "notBefore", "notAfter", "provisionerName", "provisionerType"}); err != nil {
+		return err
+	}
+	for _, g := range groups {
+		for _, c := range g.Certificates {
+			if err := cw.Write([]string{
+				g.Name,
+				c.SerialNumber,
+				c.Subject,
+				strings.Join(c.SANs, " "),
+				c.NotBefore.UTC().Format(time.RFC3339),
+				c.NotAfter.UTC().Format(time.RFC3339),
+				c.ProvisionerName,
+				c.ProvisionerType,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,103 @@
+package report
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustCertificate(sn int64, provisioner string, notAfter time.Time, names ...string) *Certificate {
+	return NewCertificate(&x509.Certificate{
+		SerialNumber: big.NewInt(sn),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotAfter:     notAfter,
+	}, provisioner, "JWK")
+}
+
+func TestNewCertificate(t *testing.T) {
+	notAfter := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := NewCertificate(&x509.Certificate{
+		SerialNumber:   big.NewInt(1234),
+		Subject:        pkix.Name{CommonName: "example.com"},
+		DNSNames:       []string{"example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+		EmailAddresses: []string{"jane@example.com"},
+		URIs:           []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/foo"}},
+		NotAfter:       notAfter,
+	}, "jwk", "JWK")
+	assert.Equal(t, "1234", c.SerialNumber)
+	assert.Equal(t, "CN=example.com", c.Subject)
+	assert.Equal(t, []string{"example.com", "10.0.0.1", "jane@example.com", "spiffe://example.com/foo"}, c.SANs)
+	assert.Equal(t, notAfter, c.NotAfter)
+	assert.Equal(t, "jwk", c.ProvisionerName)
+	assert.Equal(t, "JWK", c.ProvisionerType)
+}
+
+func TestRemoveSuperseded(t *testing.T) {
+	now := time.Now()
+	a := mustCertificate(1, "jwk", now.Add(time.Hour), "a.example.com")
+	b := mustCertificate(2, "jwk", now.Add(time.Hour), "b.example.com")
+	bRenewed := mustCertificate(3, "jwk", now.Add(48*time.Hour), "b.example.com")
+	c := mustCertificate(4, "jwk", now.Add(time.Hour), "c.example.com")
+	cOther := mustCertificate(5, "acme", now.Add(48*time.Hour), "c.example.com")
+
+	got := RemoveSuperseded([]*Certificate{a, b, c}, []*Certificate{a, b, bRenewed, c, cOther})
+	assert.Equal(t, []*Certificate{a, c}, got)
+}
+
+func TestGroupCertificates(t *testing.T) {
+	now := time.Now()
+	a := mustCertificate(1, "jwk", now.Add(2*time.Hour), "a.example.com", "www.example.org")
+	b := mustCertificate(2, "jwk", now.Add(time.Hour), "*.b.example.com")
+	c := mustCertificate(3, "", now.Add(time.Hour), "foo.svc.cluster.local", "localhost")
+	d := NewCertificate(&x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+		NotAfter:     now,
+	}, "acme", "ACME")
+	certs := []*Certificate{a, b, c, d}
+
+	tests := []struct {
+		name    string
+		groupBy string
+		want    []*Group
+	}{
+		{"provisioner", GroupByProvisioner, []*Group{
+			{Name: "acme", Count: 1, Certificates: []*Certificate{d}},
+			{Name: "jwk", Count: 2, Certificates: []*Certificate{b, a}},
+			{Name: "unknown", Count: 1, Certificates: []*Certificate{c}},
+		}},
+		{"domain", GroupByDomain, []*Group{
+			{Name: "cluster.local", Count: 1, Certificates: []*Certificate{c}},
+			{Name: "example.com", Count: 2, Certificates: []*Certificate{b, a}},
+			{Name: "example.org", Count: 1, Certificates: []*Certificate{a}},
+			{Name: "localhost", Count: 1, Certificates: []*Certificate{c}},
+			{Name: "unknown", Count: 1, Certificates: []*Certificate{d}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, GroupCertificates(certs, tt.groupBy))
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	notAfter := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	a := mustCertificate(1, "jwk", notAfter, "a.example.com", "b.example.com")
+	a.NotBefore = notAfter.Add(-24 * time.Hour)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, GroupCertificates([]*Certificate{a}, GroupByProvisioner)))
+	assert.Equal(t, "group,serialNumber,subject,sans,notBefore,notAfter,provisionerName,provisionerType\n"+
+		"jwk,1,CN=a.example.com,a.example.com b.example.com,2026-01-01T03:04:05Z,2026-01-02T03:04:05Z,jwk,JWK\n", buf.String())
+}
@@ -0,0 +1,48 @@
+package report
+
+import (
+	"sort"
+	"time"
+)
+
+// StatusRenewed is the status of the valid certificates that have been
+// replaced by a renewed or rekeyed one.
+const StatusRenewed = "renewed"
+
+// Lifecycle event types.
+const (
+	EventIssued  = "issued"
+	EventRenewed = "renewed"
+	EventRevoked = "revoked"
+	EventExpired = "expired"
+)
+
+// Lifecycle is the history of a certificate. RenewedFrom is the serial number
+// of the certificate renewed to get this one, and Successors are the serial
+// numbers of all the certificates that descend from it by renewals, the
+// direct renewals first.
+type Lifecycle struct {
+	*Certificate
+	RenewedFrom string            `json:"renewedFrom,omitempty"`
+	Successors  []string          `json:"successors"`
+	History     []*LifecycleEvent `json:"history"`
+}
+
+// LifecycleEvent is a change in the lifecycle of a certificate. The serial
+// number is the one of the new certificate in renewals and the one of the
+// renewed certificate in the issuance of a renewal.
+type LifecycleEvent struct {
+	Type         string    `json:"type"`
+	Time         time.Time `json:"time"`
+	SerialNumber string    `json:"serialNumber,omitempty"`
+	ReasonCode   int       `json:"reasonCode,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+}
+
+// SortHistory sorts the history by time, keeping the order of the events with
+// the same time.
+func (l *Lifecycle) SortHistory() {
+	sort.SliceStable(l.History, func(i, j int) bool {
+		return l.History[i].Time.Before(l.History[j].Time)
+	})
+}
@@ -0,0 +1,113 @@
+package report
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SignStatsRetention is the time the results of the sign requests are kept.
+const SignStatsRetention = 7 * 24 * time.Hour
+
+// SignResult is the number of sign requests of a provisioner and how many of
+// them failed.
+type SignResult struct {
+	ProvisionerName string  `json:"provisionerName"`
+	ProvisionerType string  `json:"provisionerType,omitempty"`
+	Requests        int     `json:"requests"`
+	Failures        int     `json:"failures"`
+	FailureRate     float64 `json:"failureRate"`
+}
+
+func (r *SignResult) add(o *SignResult) {
+	r.Requests += o.Requests
+	r.Failures += o.Failures
+	r.FailureRate = float64(r.Failures) / float64(r.Requests)
+}
+
+// SignStats keeps the results of the sign requests by provisioner in hourly
+// buckets for the SignStatsRetention period.
+//
+// Each instance of the CA keeps its own stats in memory.
+type SignStats struct {
+	mu      sync.Mutex
+	buckets map[int64]map[string]*SignResult
+}
+
+// NewSignStats creates a new empty SignStats.
+func NewSignStats() *SignStats {
+	return &SignStats{
+		buckets: make(map[int64]map[string]*SignResult),
+	}
+}
+
+// Record adds the result of a sign request made at the given time. An empty
+// provisioner name is recorded as unknown.
+func (s *SignStats) Record(t time.Time, provisionerName, provisionerType string, failed bool) {
+	if provisionerName == "" {
+		provisionerName = Unknown
+	}
+	r := &SignResult{
+		ProvisionerName: provisionerName,
+		ProvisionerType: provisionerType,
+		Requests:        1,
+	}
+	if failed {
+		r.Failures = 1
+	}
+
+	hour := t.Unix() / 3600
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[hour]
+	if !ok {
+		b = make(map[string]*SignResult)
+		s.buckets[hour] = b
+		// Remove the expired buckets when a new one is created.
+		oldest := t.Add(-SignStatsRetention).Unix() / 3600
+		for h := range s.buckets {
+			if h < oldest {
+				delete(s.buckets, h)
+			}
+		}
+	}
+	if v, ok := b[provisionerName]; ok {
+		v.add(r)
+	} else {
+		r.FailureRate = float64(r.Failures)
+		b[provisionerName] = r
+	}
+}
+
+// Results returns the results of the sign requests made since the given time,
+// by provisioner and sorted by provisioner name. The hour of the given time is
+// fully included.
+func (s *SignStats) Results(since time.Time) []*SignResult {
+	first := since.Unix() / 3600
+	results := make(map[string]*SignResult)
+
+	s.mu.Lock()
+	for hour, b := range s.buckets {
+		if hour < first {
+			continue
+		}
+		for name, v := range b {
+			if r, ok := results[name]; ok {
+				r.add(v)
+			} else {
+				r := *v
+				results[name] = &r
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	ret := make([]*SignResult, 0, len(results))
+	for _, r := range results {
+		ret = append(ret, r)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ProvisionerName < ret[j].ProvisionerName
+	})
+	return ret
+}
@@ -0,0 +1,42 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignStats(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	s := NewSignStats()
+	s.Record(now, "acme", "ACME", false)
+	s.Record(now, "acme", "ACME", true)
+	s.Record(now.Add(-time.Hour), "acme", "ACME", false)
+	s.Record(now.Add(-time.Hour), "acme", "ACME", false)
+	s.Record(now.Add(-time.Hour), "", "", true)
+	s.Record(now.Add(-3*time.Hour), "jwk", "JWK", false)
+
+	assert.Equal(t, []*SignResult{
+		{ProvisionerName: "acme", ProvisionerType: "ACME", Requests: 2, Failures: 1, FailureRate: 0.5},
+	}, s.Results(now.Add(-time.Minute)))
+
+	assert.Equal(t, []*SignResult{
+		{ProvisionerName: "acme", ProvisionerType: "ACME", Requests: 4, Failures: 1, FailureRate: 0.25},
+		{ProvisionerName: "unknown", Requests: 1, Failures: 1, FailureRate: 1},
+	}, s.Results(now.Add(-time.Hour)))
+
+	assert.Len(t, s.Results(now.Add(-24*time.Hour)), 3)
+
+	// Results must not change the stored values.
+	assert.Equal(t, []*SignResult{
+		{ProvisionerName: "acme", ProvisionerType: "ACME", Requests: 2, Failures: 1, FailureRate: 0.5},
+	}, s.Results(now))
+
+	// Expired buckets are removed.
+	s.Record(now.Add(SignStatsRetention+time.Hour), "jwk", "JWK", true)
+	assert.Equal(t, []*SignResult{
+		{ProvisionerName: "jwk", ProvisionerType: "JWK", Requests: 1, Failures: 1, FailureRate: 1},
+	}, s.Results(now.Add(-24*time.Hour)))
+	assert.Len(t, s.buckets, 1)
+}
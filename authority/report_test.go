@@ -0,0 +1,171 @@
+package authority
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+
+	"github.com/smallstep/certificates/authority/report"
+	"github.com/smallstep/certificates/db"
+)
+
+func TestAuthority_GetExpiringCertificates(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+
+	mustSign := func(sn int64, cn string, notAfter time.Time) *x509.Certificate {
+		crt, err := ca.Sign(&x509.Certificate{
+			SerialNumber: big.NewInt(sn),
+			Subject:      pkix.Name{CommonName: cn},
+			PublicKey:    signer.Public(),
+			DNSNames:     []string{cn},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     notAfter,
+		})
+		assert.FatalError(t, err)
+		return crt
+	}
+	now := time.Now()
+	expiring := mustSign(1, "a.example.com", now.Add(24*time.Hour))
+	renewed := mustSign(2, "b.example.com", now.Add(48*time.Hour))
+	renewal := mustSign(3, "b.example.com", now.Add(30*24*time.Hour))
+	revoked := mustSign(4, "c.example.com", now.Add(24*time.Hour))
+	expired := mustSign(5, "d.example.com", now.Add(-time.Minute))
+	later := mustSign(6, "e.example.com", now.Add(10*24*time.Hour))
+
+	mdb := &db.MockAuthDB{
+		MGetCertificates: func() ([]*x509.Certificate, error) {
+			return []*x509.Certificate{expiring, renewed, renewal, revoked, expired, later, ca.Intermediate}, nil
+		},
+		MIsRevoked: func(sn string) (bool, error) {
+			return sn == "4", nil
+		},
+		MGetCertificateData: func(sn string) (*db.CertificateData, error) {
+			if sn == "1" {
+				return &db.CertificateData{
+					Provisioner: &db.ProvisionerData{ID: "id", Name: "acme", Type: "ACME"},
+				}, nil
+			}
+			return nil, errors.New("not found")
+		},
+	}
+
+	a, err := NewEmbedded(
+		WithConfig(&Config{}),
+		WithDatabase(mdb),
+		WithX509RootCerts(ca.Root),
+		WithX509Signer(ca.Intermediate, ca.Signer),
+	)
+	assert.FatalError(t, err)
+
+	serials := func(within time.Duration, includeSuperseded bool) []string {
+		certs, err := a.GetExpiringCertificates(within, includeSuperseded)
+		assert.FatalError(t, err)
+		ret := []string{}
+		for _, c := range certs {
+			ret = append(ret, c.SerialNumber)
+		}
+		return ret
+	}
+	assert.Equals(t, []string{"1"}, serials(7*24*time.Hour, false))
+	assert.Equals(t, []string{"1", "2"}, serials(7*24*time.Hour, true))
+	assert.Equals(t, []string{"1", "2", "6"}, serials(15*24*time.Hour, true))
+
+	certs, err := a.GetExpiringCertificates(time.Hour*24*2, false)
+	assert.FatalError(t, err)
+	assert.Len(t, 1, certs)
+	assert.Equals(t, "acme", certs[0].ProvisionerName)
+	assert.Equals(t, "ACME", certs[0].ProvisionerType)
+	assert.Equals(t, []string{"a.example.com"}, certs[0].SANs)
+}
+
+func TestAuthority_GetExpiringCertificates_notImplemented(t *testing.T) {
+	a := testAuthority(t)
+	_, err := a.GetExpiringCertificates(time.Hour, false)
+	var sc interface{ StatusCode() int }
+	if assert.True(t, errors.As(err, &sc)) {
+		assert.Equals(t, 501, sc.StatusCode())
+	}
+}
+
+func TestAuthority_ListCertificates(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+
+	mustSign := func(sn int64, notAfter time.Time) *x509.Certificate {
+		crt, err := ca.Sign(&x509.Certificate{
+			SerialNumber: big.NewInt(sn),
+			Subject:      pkix.Name{CommonName: "www.example.com"},
+			PublicKey:    signer.Public(),
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     notAfter,
+		})
+		assert.FatalError(t, err)
+		return crt
+	}
+	now := time.Now()
+	valid := mustSign(1, now.Add(time.Hour))
+	revoked := mustSign(2, now.Add(time.Hour))
+	expired := mustSign(3, now.Add(-time.Minute))
+
+	a, err := NewEmbedded(
+		WithConfig(&Config{}),
+		WithDatabase(&db.MockAuthDB{
+			MGetCertificates: func() ([]*x509.Certificate, error) {
+				return []*x509.Certificate{valid, revoked, expired, ca.Intermediate}, nil
+			},
+			MIsRevoked: func(sn string) (bool, error) {
+				return sn == "2", nil
+			},
+		}),
+		WithX509RootCerts(ca.Root),
+		WithX509Signer(ca.Intermediate, ca.Signer),
+	)
+	assert.FatalError(t, err)
+
+	certs, err := a.ListCertificates()
+	assert.FatalError(t, err)
+	status := map[string]string{}
+	for _, c := range certs {
+		status[c.SerialNumber] = c.Status
+	}
+	assert.Equals(t, map[string]string{"1": "valid", "2": "revoked", "3": "expired"}, status)
+
+	_, err = testAuthority(t).ListCertificates()
+	var sc interface{ StatusCode() int }
+	if assert.True(t, errors.As(err, &sc)) {
+		assert.Equals(t, 501, sc.StatusCode())
+	}
+}
+
+func TestAuthority_GetSignResults(t *testing.T) {
+	a := testAuthority(t)
+	p := a.config.AuthorityConfig.Provisioners[0]
+	a.recordSign(p, nil)
+	a.recordSign(p, errors.New("force"))
+	a.recordSign(nil, errors.New("force"))
+
+	results, err := a.GetSignResults(time.Now().Add(-time.Hour))
+	assert.FatalError(t, err)
+	assert.Equals(t, []*report.SignResult{
+		{ProvisionerName: p.GetName(), ProvisionerType: p.GetType().String(), Requests: 2, Failures: 1, FailureRate: 0.5},
+		{ProvisionerName: report.Unknown, Requests: 1, Failures: 1, FailureRate: 1},
+	}, results)
+
+	_, err = (&Authority{}).GetSignResults(time.Now())
+	var sc interface{ StatusCode() int }
+	if assert.True(t, errors.As(err, &sc)) {
+		assert.Equals(t, 501, sc.StatusCode())
+	}
+}
@@ -0,0 +1,84 @@
+package authority
+
+import (
+	"crypto"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/jose"
+	kmsapi "go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/pemutil"
+
+	"github.com/smallstep/certificates/errs"
+)
+
+// ResponseType is the type in the header of the signed API responses.
+const ResponseType = "step-response+jws"
+
+// initResponseSigning loads the key and certificate used to sign the API
+// responses. By default the intermediate key and certificate are used.
+func (a *Authority) initResponseSigning() error {
+	c := a.config.ResponseSigning
+	if !c.IsEnabled() {
+		return nil
+	}
+
+	crt, key := c.Certificate, c.Key
+	if key == "" {
+		crt, key = a.config.IntermediateCert, a.config.IntermediateKey
+	}
+	chain, err := pemutil.ReadCertificateBundle(crt)
+	if err != nil {
+		return errors.Wrap(err, "error reading response signing certificate")
+	}
+	signer, err := a.keyManager.CreateSigner(&kmsapi.CreateSignerRequest{
+		SigningKey: key,
+		Password:   a.password,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error creating response signer")
+	}
+	if err := a.checkOfflineRootKey(signer.Public(), "response signing key"); err != nil {
+		return err
+	}
+	if pub, ok := chain[0].PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(signer.Public()) {
+		return errors.New("error creating response signer: certificate does not match the key")
+	}
+
+	x5c := make([]string, len(chain))
+	for i, crt := range chain {
+		x5c[i] = base64.StdEncoding.EncodeToString(crt.Raw)
+	}
+	so := new(jose.SignerOptions)
+	so.WithType(ResponseType)
+	so.WithHeader("x5c", x5c)
+	if a.responseSigner, err = jose.NewSigner(jose.SigningKey{Key: signer}, so); err != nil {
+		return errors.Wrap(err, "error creating response signer")
+	}
+	return nil
+}
+
+// IsResponseSigned returns if the responses of the given endpoint can be
+// signed.
+func (a *Authority) IsResponseSigned(endpoint string) bool {
+	return a.responseSigner != nil && a.config.ResponseSigning.IsSigned(endpoint)
+}
+
+// SignResponse signs the given JSON payload and returns the JWS in compact
+// serialization. The certificate chain of the signing key is added in the x5c
+// header.
+func (a *Authority) SignResponse(payload []byte) (string, error) {
+	if a.responseSigner == nil {
+		return "", errs.Wrap(http.StatusNotFound, errors.New("response signing is not enabled"), "authority.SignResponse")
+	}
+	jws, err := a.responseSigner.Sign(payload)
+	if err != nil {
+		return "", errs.Wrap(http.StatusInternalServerError, err, "authority.SignResponse")
+	}
+	s, err := jws.CompactSerialize()
+	if err != nil {
+		return "", errs.Wrap(http.StatusInternalServerError, err, "authority.SignResponse")
+	}
+	return s, nil
+}
@@ -0,0 +1,74 @@
+package authority
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/pemutil"
+
+	"github.com/smallstep/certificates/authority/config"
+)
+
+func TestAuthority_SignResponse(t *testing.T) {
+	root, err := pemutil.ReadCertificate("testdata/certs/root_ca.crt")
+	assert.FatalError(t, err)
+	intermediate, err := pemutil.ReadCertificate("testdata/certs/intermediate_ca.crt")
+	assert.FatalError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	t.Run("ok", func(t *testing.T) {
+		a := testAuthority(t, func(a *Authority) error {
+			a.config.ResponseSigning = &config.ResponseSigningConfig{
+				Enabled:   true,
+				Endpoints: []string{config.ResponseSigningRoots},
+			}
+			return nil
+		})
+		assert.True(t, a.IsResponseSigned(config.ResponseSigningRoots))
+		assert.False(t, a.IsResponseSigned(config.ResponseSigningProvisioners))
+
+		s, err := a.SignResponse([]byte(`{"crts":[]}`))
+		assert.FatalError(t, err)
+		jws, err := jose.ParseJWS(s)
+		assert.FatalError(t, err)
+		if assert.Len(t, 1, jws.Signatures) {
+			h := jws.Signatures[0].Protected
+			assert.Equals(t, ResponseType, h.ExtraHeaders["typ"])
+			chains, err := h.Certificates(x509.VerifyOptions{
+				Roots:     roots,
+				KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+			})
+			assert.FatalError(t, err)
+			assert.Equals(t, intermediate, chains[0][0])
+		}
+		payload, err := jws.Verify(intermediate.PublicKey)
+		assert.FatalError(t, err)
+		assert.Equals(t, `{"crts":[]}`, string(payload))
+	})
+
+	t.Run("ok/disabled", func(t *testing.T) {
+		a := testAuthority(t)
+		assert.FatalError(t, a.initResponseSigning())
+		assert.False(t, a.IsResponseSigned(config.ResponseSigningRoots))
+		_, err := a.SignResponse([]byte(`{}`))
+		assert.Equals(t, "authority.SignResponse: response signing is not enabled", err.Error())
+	})
+
+	t.Run("fail/mismatch", func(t *testing.T) {
+		a := testAuthority(t)
+		a.config.ResponseSigning = &config.ResponseSigningConfig{
+			Enabled:     true,
+			Certificate: "testdata/certs/root_ca.crt",
+			Key:         "testdata/secrets/intermediate_ca_key",
+		}
+		// The passwords are wiped once the authority is initialized.
+		a.password = []byte("pass")
+		err := a.initResponseSigning()
+		if assert.Error(t, err) {
+			assert.Equals(t, "error creating response signer: certificate does not match the key", err.Error())
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package authority
+
+import (
+	"context"
+
+	"github.com/smallstep/certificates/authority/revocation"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/errs"
+)
+
+// initRevocationEvents creates the broker that delivers revocation events to
+// the webhooks and the long polling subscribers.
+func (a *Authority) initRevocationEvents() {
+	cfg := a.config.RevocationEvents
+	if !cfg.IsEnabled() {
+		return
+	}
+	var notifier *revocation.Notifier
+	if len(cfg.Webhooks) > 0 {
+		notifier = revocation.NewNotifier(cfg.Webhooks, a.webhookClient)
+	}
+	a.revocationBroker = revocation.NewBroker(cfg.BufferSize, notifier)
+}
+
+// stopRevocationEvents delivers the pending events to the webhooks.
+func (a *Authority) stopRevocationEvents() {
+	if a.revocationBroker != nil {
+		a.revocationBroker.Close()
+	}
+}
+
+// publishRevocation sends the revocation event to the subscribers.
+func (a *Authority) publishRevocation(rci *db.RevokedCertificateInfo, isSSH bool) {
+	if a.revocationBroker == nil {
+		return
+	}
+	typ := revocation.X509Type
+	if isSSH {
+		typ = revocation.SSHType
+	}
+	a.revocationBroker.Publish(&revocation.Event{
+		Type:          typ,
+		SerialNumber:  rci.Serial,
+		ReasonCode:    rci.ReasonCode,
+		Reason:        rci.Reason,
+		ProvisionerID: rci.ProvisionerID,
+		RevokedAt:     rci.RevokedAt,
+		ExpiresAt:     rci.ExpiresAt,
+	})
+}
+
+// GetRevocationEvents returns the revocation events after the given id. If
+// there are no new events, it waits until there is one or the context is done.
+func (a *Authority) GetRevocationEvents(ctx context.Context, since uint64) (*revocation.Page, error) {
+	if a.revocationBroker == nil {
+		return nil, errs.NotImplemented("authority.GetRevocationEvents; revocation events are not enabled")
+	}
+	return a.revocationBroker.Events(ctx, since), nil
+}
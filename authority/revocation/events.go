@@ -0,0 +1,153 @@
+// Package revocation implements the delivery of revocation events to the
+// systems that subscribe to them, so they can act on a revocation without
+// waiting for the next CRL or OCSP refresh.
+//
+// Events are delivered to the configured webhooks, and they can be read with
+// long polling requests. Each instance of the CA keeps its own list of recent
+// events in memory.
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultBufferSize is the default number of events kept in memory.
+const DefaultBufferSize = 1000
+
+// Event types.
+const (
+	X509Type = "x509"
+	SSHType  = "ssh"
+)
+
+// Event is the revocation of an X.509 or SSH certificate.
+type Event struct {
+	ID            uint64    `json:"id"`
+	Type          string    `json:"type"`
+	SerialNumber  string    `json:"serialNumber"`
+	ReasonCode    int       `json:"reasonCode"`
+	Reason        string    `json:"reason,omitempty"`
+	ProvisionerID string    `json:"provisionerID,omitempty"`
+	RevokedAt     time.Time `json:"revokedAt"`
+	ExpiresAt     time.Time `json:"expiresAt,omitempty"`
+}
+
+// Page is a list of events returned to a subscriber. Last is the id of the
+// last event, to be used in the following request. Truncated is true if some
+// events after the requested one are not available anymore, in that case the
+// subscriber should check the CRL.
+type Page struct {
+	Events    []*Event `json:"events"`
+	Last      uint64   `json:"last"`
+	Truncated bool     `json:"truncated"`
+}
+
+// Broker keeps the recent events and delivers them to the subscribers.
+type Broker struct {
+	mu       sync.Mutex
+	size     int
+	events   []*Event
+	lastID   uint64
+	notify   chan struct{}
+	notifier *Notifier
+}
+
+// NewBroker creates a new broker that keeps the given number of events in
+// memory. If notifier is not nil, the events are also sent to its webhooks.
+//
+// Event ids are based on the current time, so they keep increasing after a
+// restart.
+func NewBroker(size int, notifier *Notifier) *Broker {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Broker{
+		size:     size,
+		lastID:   uint64(time.Now().UnixMicro()),
+		notify:   make(chan struct{}),
+		notifier: notifier,
+	}
+}
+
+// Publish adds a new event, wakes up the waiting subscribers and sends it to
+// the webhooks.
+func (b *Broker) Publish(e *Event) {
+	b.mu.Lock()
+	b.lastID++
+	e.ID = b.lastID
+	b.events = append(b.events, e)
+	if len(b.events) > b.size {
+		b.events = b.events[len(b.events)-b.size:]
+	}
+	close(b.notify)
+	b.notify = make(chan struct{})
+	// Send does not block, and it cannot be called after the notifier is
+	// replaced.
+	if b.notifier != nil {
+		b.notifier.Send(e)
+	}
+	b.mu.Unlock()
+}
+
+// SetNotifier replaces the notifier used to send the events to the webhooks.
+// The pending events of the previous notifier are delivered before it is
+// closed.
+func (b *Broker) SetNotifier(notifier *Notifier) {
+	b.mu.Lock()
+	old := b.notifier
+	b.notifier = notifier
+	b.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+}
+
+// Events returns the events after the given id. If there are no events, it
+// waits until one is published or the context is done.
+func (b *Broker) Events(ctx context.Context, since uint64) *Page {
+	for {
+		b.mu.Lock()
+		page := b.page(since)
+		notify := b.notify
+		b.mu.Unlock()
+		if len(page.Events) > 0 || page.Truncated {
+			return page
+		}
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return page
+		}
+	}
+}
+
+// page must be called with the lock held.
+func (b *Broker) page(since uint64) *Page {
+	page := &Page{
+		Events: []*Event{},
+		Last:   b.lastID,
+	}
+	if since >= b.lastID {
+		// Do not go back if the subscriber is ahead of us.
+		page.Last = since
+		return page
+	}
+	for i, e := range b.events {
+		if e.ID > since {
+			page.Events = append(page.Events, b.events[i:]...)
+			break
+		}
+	}
+	// Events between since and the first one are lost.
+	if len(b.events) == 0 || b.events[0].ID > since+1 {
+		page.Truncated = since != 0
+	}
+	return page
+}
+
+// Close stops the delivery of events to the webhooks.
+func (b *Broker) Close() {
+	b.SetNotifier(nil)
+}
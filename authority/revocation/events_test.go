@@ -0,0 +1,146 @@
+package revocation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_Events(t *testing.T) {
+	b := NewBroker(2, nil)
+	start := b.lastID
+	ctx := context.Background()
+
+	// No events.
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	page := b.Events(cctx, 0)
+	assert.Empty(t, page.Events)
+	assert.Equal(t, start, page.Last)
+	assert.False(t, page.Truncated)
+
+	b.Publish(&Event{SerialNumber: "1"})
+	b.Publish(&Event{SerialNumber: "2"})
+	page = b.Events(ctx, 0)
+	if assert.Len(t, page.Events, 2) {
+		assert.Equal(t, "1", page.Events[0].SerialNumber)
+		assert.Equal(t, start+1, page.Events[0].ID)
+		assert.Equal(t, "2", page.Events[1].SerialNumber)
+	}
+	assert.Equal(t, start+2, page.Last)
+	assert.False(t, page.Truncated)
+
+	page = b.Events(ctx, start+1)
+	if assert.Len(t, page.Events, 1) {
+		assert.Equal(t, "2", page.Events[0].SerialNumber)
+	}
+
+	// The first event is dropped from the buffer.
+	b.Publish(&Event{SerialNumber: "3"})
+	page = b.Events(ctx, start)
+	assert.Len(t, page.Events, 2)
+	assert.True(t, page.Truncated)
+	page = b.Events(ctx, start+1)
+	assert.Len(t, page.Events, 2)
+	assert.False(t, page.Truncated)
+
+	// Events from a previous run are truncated.
+	page = b.Events(ctx, 10)
+	assert.True(t, page.Truncated)
+
+	// Subscribers ahead of the broker wait.
+	cctx, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	page = b.Events(cctx, start+100)
+	assert.Empty(t, page.Events)
+	assert.Equal(t, start+100, page.Last)
+}
+
+func TestBroker_Events_wait(t *testing.T) {
+	b := NewBroker(0, nil)
+	since := b.lastID
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		b.Publish(&Event{SerialNumber: "1"})
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	page := b.Events(ctx, since)
+	if assert.Len(t, page.Events, 1) {
+		assert.Equal(t, "1", page.Events[0].SerialNumber)
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	retryDelay := webhookRetryDelay
+	t.Cleanup(func() { webhookRetryDelay = retryDelay })
+	webhookRetryDelay = 0
+	secret := []byte("secret")
+	var attempts int
+	received := make(chan *RequestBody, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, Sign(secret, body), r.Header.Get("X-Smallstep-Signature"))
+		assert.Equal(t, "sessions", r.Header.Get("X-Smallstep-Webhook-ID"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		var rb RequestBody
+		require.NoError(t, json.Unmarshal(body, &rb))
+		received <- &rb
+	}))
+	defer srv.Close()
+
+	n := NewNotifier([]*Webhook{{
+		Name:        "sessions",
+		URL:         srv.URL,
+		Secret:      base64.StdEncoding.EncodeToString(secret),
+		BearerToken: "token",
+	}}, srv.Client())
+	b := NewBroker(0, n)
+	b.Publish(&Event{Type: X509Type, SerialNumber: "1234"})
+	b.Close()
+
+	select {
+	case rb := <-received:
+		assert.Equal(t, "1234", rb.Event.SerialNumber)
+		assert.Equal(t, X509Type, rb.Event.Type)
+	default:
+		t.Fatal("webhook did not receive the event")
+	}
+	assert.Equal(t, 2, attempts)
+}
+
+func TestWebhook_Credentials(t *testing.T) {
+	t.Setenv("STEP_TEST_WEBHOOK_SECRET", base64.StdEncoding.EncodeToString([]byte("secret")))
+	t.Setenv("STEP_TEST_WEBHOOK_TOKEN", "token")
+
+	w := &Webhook{Name: "sessions", URL: "https://example.com", Secret: "env:STEP_TEST_WEBHOOK_SECRET", BearerToken: "env:STEP_TEST_WEBHOOK_TOKEN"}
+	require.NoError(t, w.Validate())
+	key, token, err := w.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), key)
+	assert.Equal(t, "token", token)
+
+	w = &Webhook{Name: "sessions", URL: "https://example.com", Secret: "c2VjcmV0", BearerToken: "token"}
+	key, token, err = w.Credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), key)
+	assert.Equal(t, "token", token)
+
+	w = &Webhook{Name: "sessions", URL: "https://example.com", Secret: "env:STEP_TEST_WEBHOOK_MISSING"}
+	_, _, err = w.Credentials(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,203 @@
+package revocation
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/internal/secret"
+	"github.com/smallstep/certificates/webhook"
+)
+
+// Defaults of the webhook delivery.
+var (
+	webhookTimeout    = 10 * time.Second
+	webhookRetries    = 3
+	webhookRetryDelay = time.Second
+	webhookQueueSize  = 1000
+)
+
+// Webhook is an endpoint that receives the revocation events. The request
+// body is signed with the secret using HMAC-SHA256, and the hex encoded
+// signature is sent in the X-Smallstep-Signature header.
+type Webhook struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Secret      string `json:"secret,omitempty"`
+	BearerToken string `json:"bearerToken,omitempty"`
+}
+
+// Validate validates the webhook configuration.
+func (w *Webhook) Validate() error {
+	switch {
+	case w.Name == "":
+		return errors.New("webhook name cannot be empty")
+	case w.URL == "":
+		return errors.Errorf("webhook %s url cannot be empty", w.Name)
+	}
+	if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.Errorf("webhook %s url %q is not valid", w.Name, w.URL)
+	}
+	if w.Secret != "" && !secret.IsReference(w.Secret) {
+		if _, err := base64.StdEncoding.DecodeString(w.Secret); err != nil {
+			return errors.Errorf("webhook %s secret must be base64 encoded", w.Name)
+		}
+	}
+	return nil
+}
+
+// Credentials returns the key used to sign the requests and the bearer token.
+// The secret and the bearer token can be references to a secret source, e.g.
+// "vault:secret/data/webhooks#events", they're resolved on every call so
+// rotated values are used.
+func (w *Webhook) Credentials(ctx context.Context) (key []byte, bearerToken string, err error) {
+	if w.Secret != "" {
+		s, err := secret.Resolve(ctx, w.Secret)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "error resolving webhook %s secret", w.Name)
+		}
+		if key, err = base64.StdEncoding.DecodeString(s); err != nil {
+			return nil, "", errors.Errorf("webhook %s secret must be base64 encoded", w.Name)
+		}
+	}
+	if bearerToken, err = secret.Resolve(ctx, w.BearerToken); err != nil {
+		return nil, "", errors.Wrapf(err, "error resolving webhook %s bearer token", w.Name)
+	}
+	return key, bearerToken, nil
+}
+
+// RequestBody is the body sent to the webhooks.
+type RequestBody struct {
+	Timestamp time.Time `json:"timestamp"`
+	Nonce     string    `json:"nonce,omitempty"`
+	Event     *Event    `json:"event"`
+}
+
+// Notifier sends the events to the webhooks in the background. Each delivery
+// is retried a few times if the webhook server fails.
+type Notifier struct {
+	webhooks []*Webhook
+	client   *http.Client
+	queue    chan *Event
+	wg       sync.WaitGroup
+	once     sync.Once
+}
+
+// NewNotifier creates a new notifier and starts the delivery goroutine. If
+// client is nil, http.DefaultClient is used.
+func NewNotifier(webhooks []*Webhook, client *http.Client) *Notifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	n := &Notifier{
+		webhooks: webhooks,
+		client:   client,
+		queue:    make(chan *Event, webhookQueueSize),
+	}
+	n.wg.Add(1)
+	go n.run()
+	return n
+}
+
+// Send queues an event. The event is dropped if the queue is full.
+func (n *Notifier) Send(e *Event) {
+	select {
+	case n.queue <- e:
+	default:
+		log.Printf("error sending revocation event %d: queue is full", e.ID)
+	}
+}
+
+// Close delivers the queued events and stops the notifier.
+func (n *Notifier) Close() {
+	n.once.Do(func() {
+		close(n.queue)
+	})
+	n.wg.Wait()
+}
+
+func (n *Notifier) run() {
+	defer n.wg.Done()
+	for e := range n.queue {
+		for _, w := range n.webhooks {
+			if err := n.deliver(w, e); err != nil {
+				log.Printf("error sending revocation event %d to webhook %s: %v", e.ID, w.Name, err)
+			}
+		}
+	}
+}
+
+func (n *Notifier) deliver(w *Webhook, e *Event) error {
+	for i := 0; ; i++ {
+		// Every attempt has its own timestamp and nonce, so the receiver can
+		// reject replays without rejecting the retries.
+		nonce, err := webhook.NewNonce()
+		if err != nil {
+			return err
+		}
+		body, err := json.Marshal(&RequestBody{
+			Timestamp: time.Now().UTC(),
+			Nonce:     nonce,
+			Event:     e,
+		})
+		if err != nil {
+			return errors.Wrap(err, "error marshaling event")
+		}
+		err = n.post(w, body)
+		if err == nil || i+1 >= webhookRetries {
+			return err
+		}
+		time.Sleep(webhookRetryDelay * time.Duration(i+1))
+	}
+}
+
+func (n *Notifier) post(w *Webhook, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.IDHeader, w.Name)
+	key, bearerToken, err := w.Credentials(ctx)
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		req.Header.Set(webhook.SignatureHeader, webhook.Sign(key, body))
+	}
+	if bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+bearerToken)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook server responded with %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the body, as sent in the
+// X-Smallstep-Signature header.
+//
+// Deprecated: use webhook.Sign.
+func Sign(secret, body []byte) string {
+	return webhook.Sign(secret, body)
+}
@@ -0,0 +1,49 @@
+package authority
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/revocation"
+	"github.com/smallstep/certificates/db"
+)
+
+func TestAuthority_GetRevocationEvents(t *testing.T) {
+	ctx := context.Background()
+
+	a := testAuthority(t)
+	_, err := a.GetRevocationEvents(ctx, 0)
+	assert.Error(t, err)
+
+	a = testAuthority(t, func(a *Authority) error {
+		a.config.RevocationEvents = &config.RevocationEventsConfig{Enabled: true}
+		return nil
+	})
+	revokedAt := time.Now().UTC()
+	a.publishRevocation(&db.RevokedCertificateInfo{
+		Serial:        "1234",
+		ProvisionerID: "provisioner-id",
+		ReasonCode:    1,
+		Reason:        "key compromise",
+		RevokedAt:     revokedAt,
+	}, false)
+	a.publishRevocation(&db.RevokedCertificateInfo{Serial: "5678"}, true)
+
+	page, err := a.GetRevocationEvents(ctx, 0)
+	assert.FatalError(t, err)
+	if assert.Len(t, 2, page.Events) {
+		e := page.Events[0]
+		assert.Equals(t, revocation.X509Type, e.Type)
+		assert.Equals(t, "1234", e.SerialNumber)
+		assert.Equals(t, "provisioner-id", e.ProvisionerID)
+		assert.Equals(t, 1, e.ReasonCode)
+		assert.Equals(t, "key compromise", e.Reason)
+		assert.Equals(t, revokedAt, e.RevokedAt)
+		assert.Equals(t, revocation.SSHType, page.Events[1].Type)
+		assert.Equals(t, page.Events[1].ID, page.Last)
+	}
+}
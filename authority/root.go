@@ -3,6 +3,10 @@ package authority
 import (
 	"crypto/x509"
 
+	"golang.org/x/exp/slices"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/trustbundle"
 	"github.com/smallstep/certificates/errs"
 )
 
@@ -42,6 +46,20 @@ func (a *Authority) GetRoots() ([]*x509.Certificate, error) {
 	return a.rootX509Certs, nil
 }
 
+// GetTrustBundle returns the root certificates in a file that can be installed
+// in the trust store of a platform. The supported formats are the ones in
+// trustbundle.Formats.
+func (a *Authority) GetTrustBundle(format string) (*trustbundle.Bundle, error) {
+	if !slices.Contains(trustbundle.Formats, format) {
+		return nil, admin.NewError(admin.ErrorBadRequestType, "trust bundle format %q is not supported", format)
+	}
+	b, err := trustbundle.New(format, a.config.CommonName, a.rootX509Certs)
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error creating trust bundle")
+	}
+	return b, nil
+}
+
 // GetFederation returns all the root certificates in the federation.
 // This method implements the Authority interface.
 func (a *Authority) GetFederation() (federation []*x509.Certificate, err error) {
@@ -152,3 +152,49 @@ func TestAuthority_GetFederation(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthority_GetTrustBundle(t *testing.T) {
+	tests := []struct {
+		name            string
+		format          string
+		fn              func(a *Authority)
+		wantFilename    string
+		wantContentType string
+		wantErr         bool
+	}{
+		{"ok/mobileconfig", "mobileconfig", nil, "step-ca.mobileconfig", "application/x-apple-aspen-config", false},
+		{"ok/p7b", "p7b", nil, "step-ca.p7b", "application/x-pkcs7-certificates", false},
+		{"ok/sh", "sh", func(a *Authority) {
+			a.config.CommonName = "Smallstep Test CA"
+		}, "install-smallstep-test-ca.sh", "text/x-shellscript", false},
+		{"fail/format", "pem", nil, "", "", true},
+		{"fail/roots", "p7b", func(a *Authority) {
+			a.rootX509Certs = nil
+		}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testAuthority(t)
+			if tt.fn != nil {
+				tt.fn(a)
+			}
+			got, err := a.GetTrustBundle(tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Authority.GetTrustBundle() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Filename != tt.wantFilename {
+				t.Errorf("Authority.GetTrustBundle() Filename = %v, want %v", got.Filename, tt.wantFilename)
+			}
+			if got.ContentType != tt.wantContentType {
+				t.Errorf("Authority.GetTrustBundle() ContentType = %v, want %v", got.ContentType, tt.wantContentType)
+			}
+			if len(got.Data) == 0 {
+				t.Error("Authority.GetTrustBundle() Data is empty")
+			}
+		})
+	}
+}
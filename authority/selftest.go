@@ -0,0 +1,230 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/x509util"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// Status of the self-test stages.
+const (
+	SelfTestOK      = "ok"
+	SelfTestFailed  = "failed"
+	SelfTestSkipped = "skipped"
+)
+
+// SelfTestStage is the result of one of the stages of the self-test.
+type SelfTestStage struct {
+	Name     string               `json:"name"`
+	Status   string               `json:"status"`
+	Message  string               `json:"message,omitempty"`
+	Duration provisioner.Duration `json:"duration"`
+}
+
+// SelfTestReport is the result of the self-test.
+type SelfTestReport struct {
+	Provisioner  string           `json:"provisioner"`
+	SerialNumber string           `json:"serialNumber,omitempty"`
+	OK           bool             `json:"ok"`
+	Stages       []*SelfTestStage `json:"stages"`
+	StartedAt    time.Time        `json:"startedAt"`
+}
+
+// skipStage is the error returned by the stages that do not apply to the
+// current configuration.
+type skipStage string
+
+func (e skipStage) Error() string {
+	return string(e)
+}
+
+// run runs a stage of the self-test. The stages after a failure are skipped.
+func (r *SelfTestReport) run(name string, fn func() (string, error)) {
+	stage := &SelfTestStage{Name: name}
+	r.Stages = append(r.Stages, stage)
+	if !r.OK {
+		stage.Status = SelfTestSkipped
+		stage.Message = "a previous stage failed"
+		return
+	}
+
+	start := time.Now()
+	msg, err := fn()
+	stage.Duration = provisioner.Duration{Duration: time.Since(start)}
+
+	var skip skipStage
+	switch {
+	case errors.As(err, &skip):
+		stage.Status = SelfTestSkipped
+		stage.Message = skip.Error()
+	case err != nil:
+		stage.Status = SelfTestFailed
+		stage.Message = err.Error()
+		r.OK = false
+	default:
+		stage.Status = SelfTestOK
+		stage.Message = msg
+	}
+}
+
+// SelfTest signs a short-lived certificate with the given provisioner, or
+// with the one in the self-test configuration if empty, verifies its chain,
+// and checks its status with the OCSP responder and the CRL. It returns the
+// result of each stage, the report is only OK if all of them succeed or are
+// skipped.
+//
+// The certificate goes through the same path as any other X.509 certificate,
+// including the policies and the webhooks of the provisioner, and it is
+// stored in the database.
+func (a *Authority) SelfTest(ctx context.Context, name string) (*SelfTestReport, error) {
+	cfg := a.config.SelfTest
+	if name == "" {
+		if cfg == nil {
+			return nil, admin.NewError(admin.ErrorBadRequestType, "self-test provisioner is not configured")
+		}
+		name = cfg.Provisioner
+	}
+
+	r := &SelfTestReport{
+		Provisioner: name,
+		OK:          true,
+		StartedAt:   time.Now().UTC(),
+	}
+
+	var (
+		p     provisioner.Interface
+		chain []*x509.Certificate
+	)
+	r.run("provisioner", func() (msg string, err error) {
+		if p, err = a.LoadProvisionerByName(name); err != nil {
+			return "", err
+		}
+		return "loaded " + p.GetType().String() + " provisioner " + p.GetName(), nil
+	})
+	r.run("sign", func() (msg string, err error) {
+		if chain, err = a.selfTestSign(ctx, p, cfg.GetValidity()); err != nil {
+			return "", err
+		}
+		r.SerialNumber = chain[0].SerialNumber.String()
+		return "signed certificate " + r.SerialNumber, nil
+	})
+	r.run("chain", func() (string, error) {
+		return a.selfTestVerify(chain)
+	})
+	r.run("ocsp", func() (string, error) {
+		return a.selfTestOCSP(chain)
+	})
+	r.run("crl", func() (string, error) {
+		return a.selfTestCRL(chain)
+	})
+	return r, nil
+}
+
+func (a *Authority) selfTestSign(ctx context.Context, p provisioner.Interface, validity time.Duration) ([]*x509.Certificate, error) {
+	signer, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating key")
+	}
+	csr, err := x509util.CreateCertificateRequest("step-ca self-test", nil, signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating certificate request")
+	}
+	return a.SignWithContext(ctx, csr, provisioner.SignOptions{},
+		p,
+		provisioner.CertificateModifierFunc(func(cert *x509.Certificate, so provisioner.SignOptions) error {
+			now := time.Now()
+			cert.NotBefore = now.Add(-so.Backdate)
+			cert.NotAfter = now.Add(validity)
+			return nil
+		}),
+	)
+}
+
+func (a *Authority) selfTestVerify(chain []*x509.Certificate) (string, error) {
+	roots := x509.NewCertPool()
+	for _, crt := range a.rootX509Certs {
+		roots.AddCert(crt)
+	}
+	intermediates := x509.NewCertPool()
+	for _, crt := range chain[1:] {
+		intermediates.AddCert(crt)
+	}
+	chains, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "error verifying certificate")
+	}
+	return "certificate verified with root " + chains[0][len(chains[0])-1].Subject.CommonName, nil
+}
+
+func (a *Authority) selfTestOCSP(chain []*x509.Certificate) (string, error) {
+	if a.ocspCache == nil {
+		return "", skipStage("ocsp responses are not enabled")
+	}
+	if len(chain) < 2 {
+		return "", skipStage("the certificate issuer is not available")
+	}
+	b, err := a.GetOCSPResponse(chain[0])
+	if err != nil {
+		return "", errors.Wrap(err, "error getting ocsp response")
+	}
+	resp, err := ocsp.ParseResponseForCert(b, chain[0], chain[1])
+	if err != nil {
+		return "", errors.Wrap(err, "error parsing ocsp response")
+	}
+	if resp.Status != ocsp.Good {
+		return "", errors.Errorf("ocsp response status is %d, expected good", resp.Status)
+	}
+	return "ocsp response status is good", nil
+}
+
+func (a *Authority) selfTestCRL(chain []*x509.Certificate) (string, error) {
+	if !a.config.CRL.IsEnabled() {
+		return "", skipStage("certificate revocation lists are not enabled")
+	}
+	if len(chain) < 2 {
+		return "", skipStage("the certificate issuer is not available")
+	}
+	b, err := a.GetCertificateRevocationList()
+	if err != nil {
+		return "", errors.Wrap(err, "error getting crl")
+	}
+	crl, err := x509.ParseRevocationList(b)
+	if err != nil {
+		return "", errors.Wrap(err, "error parsing crl")
+	}
+	issuer := chain[1]
+	if a.config.DelegatedSigners.IsEnabled() && a.config.DelegatedSigners.CRL {
+		signer, err := a.GetCRLSignerCertificate()
+		if err != nil {
+			return "", errors.Wrap(err, "error getting crl signer")
+		}
+		if err := signer.CheckSignatureFrom(chain[1]); err != nil {
+			return "", errors.Wrap(err, "error validating crl signer")
+		}
+		issuer = signer
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return "", errors.Wrap(err, "error validating crl signature")
+	}
+	if !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate) {
+		return "", errors.Errorf("crl expired at %s", crl.NextUpdate.Format(time.RFC3339))
+	}
+	for _, rc := range crl.RevokedCertificates { //nolint:staticcheck // RevokedCertificateEntries requires Go 1.21
+		if rc.SerialNumber.Cmp(chain[0].SerialNumber) == 0 {
+			return "", errors.New("certificate is in the crl")
+		}
+	}
+	return "crl is valid and the certificate is not revoked", nil
+}
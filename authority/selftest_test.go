@@ -0,0 +1,76 @@
+package authority
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func TestAuthority_SelfTest(t *testing.T) {
+	ctx := context.Background()
+	statuses := func(r *SelfTestReport) string {
+		s := make([]string, len(r.Stages))
+		for i, stage := range r.Stages {
+			s[i] = stage.Name + ":" + stage.Status
+		}
+		return strings.Join(s, ",")
+	}
+
+	t.Run("fail/not-configured", func(t *testing.T) {
+		a := testAuthority(t)
+		_, err := a.SelfTest(ctx, "")
+		var sc interface{ StatusCode() int }
+		if assert.True(t, errors.As(err, &sc)) {
+			assert.Equals(t, 400, sc.StatusCode())
+		}
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		a := testAuthority(t)
+		a.config.SelfTest = &config.SelfTestConfig{
+			Provisioner: "Max",
+			Validity:    &provisioner.Duration{Duration: time.Minute},
+		}
+		r, err := a.SelfTest(ctx, "")
+		assert.FatalError(t, err)
+		assert.True(t, r.OK)
+		assert.Equals(t, "Max", r.Provisioner)
+		assert.NotEquals(t, "", r.SerialNumber)
+		assert.Equals(t, "provisioner:ok,sign:ok,chain:ok,ocsp:skipped,crl:skipped", statuses(r))
+		assert.Equals(t, "ocsp responses are not enabled", r.Stages[3].Message)
+	})
+
+	t.Run("ok/provisioner", func(t *testing.T) {
+		a := testAuthority(t)
+		r, err := a.SelfTest(ctx, "step-cli")
+		assert.FatalError(t, err)
+		assert.True(t, r.OK)
+		assert.Equals(t, "step-cli", r.Provisioner)
+	})
+
+	t.Run("fail/provisioner", func(t *testing.T) {
+		a := testAuthority(t)
+		r, err := a.SelfTest(ctx, "missing")
+		assert.FatalError(t, err)
+		assert.False(t, r.OK)
+		assert.Equals(t, "provisioner:failed,sign:skipped,chain:skipped,ocsp:skipped,crl:skipped", statuses(r))
+		assert.Equals(t, "a previous stage failed", r.Stages[1].Message)
+	})
+
+	t.Run("fail/crl", func(t *testing.T) {
+		a := testAuthority(t)
+		a.config.CRL = &config.CRLConfig{Enabled: true}
+		r, err := a.SelfTest(ctx, "Max")
+		assert.FatalError(t, err)
+		assert.False(t, r.OK)
+		assert.Equals(t, "provisioner:ok,sign:ok,chain:ok,ocsp:skipped,crl:failed", statuses(r))
+		assert.True(t, strings.HasPrefix(r.Stages[4].Message, "error getting crl"))
+	})
+}
@@ -0,0 +1,235 @@
+package authority
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/revocation"
+	"github.com/smallstep/certificates/authority/settings"
+)
+
+// initSettings creates the store of the authority settings and applies the
+// last version to the configuration. The settings are only available with
+// the administration API, and they are kept in memory if the database is not
+// configured.
+func (a *Authority) initSettings() (err error) {
+	if !a.config.AuthorityConfig.EnableAdmin {
+		return nil
+	}
+	if a.settingsStore == nil {
+		if ndb, ok := nosqlDB(a.db); ok {
+			if a.settingsStore, err = settings.NewNoSQLStore(ndb); err != nil {
+				return err
+			}
+		} else {
+			a.settingsStore = settings.NewMemoryStore()
+		}
+	}
+
+	a.baseSettings = a.configSettings()
+	latest, err := settings.Latest(a.settingsStore)
+	if err != nil {
+		return errors.Wrap(err, "error loading authority settings")
+	}
+	if latest != nil {
+		a.setSettings(latest.Settings)
+		a.initLogf("Using authority settings version %d", latest.Version)
+	}
+	return nil
+}
+
+// configSettings returns the settings defined in the configuration file.
+func (a *Authority) configSettings() *settings.Settings {
+	s := &settings.Settings{
+		Claims:   a.config.AuthorityConfig.Claims,
+		Template: a.config.AuthorityConfig.Template,
+		Policy:   a.config.AuthorityConfig.Policy,
+	}
+	if a.config.RevocationEvents != nil {
+		s.Notifications = a.config.RevocationEvents.Webhooks
+	}
+	return s
+}
+
+// setSettings sets the configuration fields managed by the given settings.
+// The fields that are not defined use the values from the configuration file.
+func (a *Authority) setSettings(s *settings.Settings) {
+	merged := s.Merge(a.baseSettings)
+	a.settings = s
+	a.config.AuthorityConfig.Claims = merged.Claims
+	a.config.AuthorityConfig.Template = merged.Template
+	a.config.AuthorityConfig.Policy = merged.Policy
+	if len(merged.Notifications) > 0 && a.config.RevocationEvents == nil {
+		a.config.RevocationEvents = &config.RevocationEventsConfig{Enabled: true}
+	}
+	if a.config.RevocationEvents != nil {
+		a.config.RevocationEvents.Webhooks = merged.Notifications
+	}
+}
+
+// applySettings sets the given settings and reloads the provisioners, the
+// policy engines and the revocation webhooks. The previous settings are
+// restored if the new ones cannot be applied.
+func (a *Authority) applySettings(ctx context.Context, s *settings.Settings) error {
+	a.adminMutex.Lock()
+	defer a.adminMutex.Unlock()
+
+	previous := a.settings
+	a.setSettings(s)
+	if err := a.reloadSettings(ctx); err != nil {
+		a.setSettings(previous)
+		if rerr := a.reloadSettings(ctx); rerr != nil {
+			return errors.Wrapf(rerr, "error restoring authority settings after %v", err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (a *Authority) reloadSettings(ctx context.Context) error {
+	if err := a.ReloadAdminResources(ctx); err != nil {
+		return err
+	}
+	if err := a.reloadPolicyEngines(ctx); err != nil {
+		return err
+	}
+
+	cfg := a.config.RevocationEvents
+	var notifier *revocation.Notifier
+	if cfg != nil && len(cfg.Webhooks) > 0 {
+		notifier = revocation.NewNotifier(cfg.Webhooks, a.webhookClient)
+	}
+	switch {
+	case a.revocationBroker != nil:
+		a.revocationBroker.SetNotifier(notifier)
+	case notifier != nil:
+		a.revocationBroker = revocation.NewBroker(cfg.BufferSize, notifier)
+	}
+	return nil
+}
+
+func (a *Authority) requireSettings() error {
+	if a.settingsStore == nil {
+		return admin.NewError(admin.ErrorNotImplementedType, "authority settings require the administration API")
+	}
+	return nil
+}
+
+// GetSettings returns the current version of the authority settings. If the
+// settings have not been modified, it returns the version 0 with the settings
+// from the configuration file.
+func (a *Authority) GetSettings() (*settings.Version, error) {
+	if err := a.requireSettings(); err != nil {
+		return nil, err
+	}
+	latest, err := settings.Latest(a.settingsStore)
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error loading authority settings")
+	}
+	if latest == nil {
+		return &settings.Version{Settings: a.baseSettings}, nil
+	}
+	return latest, nil
+}
+
+// GetSettingsVersion returns the given version of the authority settings.
+func (a *Authority) GetSettingsVersion(version int) (*settings.Version, error) {
+	if err := a.requireSettings(); err != nil {
+		return nil, err
+	}
+	v, err := a.settingsStore.Get(version)
+	if err != nil {
+		if errors.Is(err, settings.ErrNotFound) {
+			return nil, admin.NewError(admin.ErrorNotFoundType, "authority settings version %d not found", version)
+		}
+		return nil, admin.WrapErrorISE(err, "error loading authority settings")
+	}
+	return v, nil
+}
+
+// GetSettingsVersions returns all the versions of the authority settings.
+func (a *Authority) GetSettingsVersions() ([]*settings.Version, error) {
+	if err := a.requireSettings(); err != nil {
+		return nil, err
+	}
+	versions, err := a.settingsStore.List()
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error listing authority settings")
+	}
+	return versions, nil
+}
+
+// UpdateSettings creates a new version of the authority settings and applies
+// it. Only super admins can modify the settings.
+func (a *Authority) UpdateSettings(ctx context.Context, adm *linkedca.Admin, s *settings.Settings, comment string) (*settings.Version, error) {
+	if adm.GetType() != linkedca.Admin_SUPER_ADMIN {
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to modify the authority settings")
+	}
+	if err := s.Validate(); err != nil {
+		return nil, admin.WrapError(admin.ErrorBadRequestType, err, "invalid authority settings")
+	}
+	return a.createSettingsVersion(ctx, adm, s, comment, 0)
+}
+
+// RollbackSettings creates a new version of the authority settings with the
+// contents of the given version and applies it. Only super admins can modify
+// the settings.
+func (a *Authority) RollbackSettings(ctx context.Context, adm *linkedca.Admin, version int, comment string) (*settings.Version, error) {
+	if adm.GetType() != linkedca.Admin_SUPER_ADMIN {
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to modify the authority settings")
+	}
+	v, err := a.GetSettingsVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	return a.createSettingsVersion(ctx, adm, v.Settings, comment, version)
+}
+
+func (a *Authority) createSettingsVersion(ctx context.Context, adm *linkedca.Admin, s *settings.Settings, comment string, rollbackOf int) (*settings.Version, error) {
+	if err := a.requireSettings(); err != nil {
+		return nil, err
+	}
+	if s == nil {
+		s = &settings.Settings{}
+	}
+
+	a.settingsMutex.Lock()
+	defer a.settingsMutex.Unlock()
+
+	latest, err := settings.Latest(a.settingsStore)
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error loading authority settings")
+	}
+	v := &settings.Version{
+		Version:    1,
+		Settings:   s,
+		Comment:    comment,
+		RollbackOf: rollbackOf,
+		CreatedBy:  adm.GetSubject(),
+		CreatedAt:  time.Now().UTC(),
+	}
+	var previous *settings.Settings
+	if latest != nil {
+		v.Version = latest.Version + 1
+		previous = latest.Settings
+	}
+
+	if err := a.applySettings(ctx, s); err != nil {
+		return nil, admin.WrapError(admin.ErrorBadRequestType, err, "error applying authority settings")
+	}
+	if err := a.settingsStore.Create(v); err != nil {
+		if rerr := a.applySettings(ctx, previous); rerr != nil {
+			return nil, admin.WrapErrorISE(rerr, "error restoring authority settings")
+		}
+		if errors.Is(err, settings.ErrConflict) {
+			return nil, admin.NewError(admin.ErrorConflictType, "authority settings version %d already exists", v.Version)
+		}
+		return nil, admin.WrapErrorISE(err, "error storing authority settings")
+	}
+	return v, nil
+}
@@ -0,0 +1,101 @@
+// Package settings implements the authority level settings that can be
+// managed at runtime through the administration API.
+//
+// Every change creates a new version of the settings, the previous versions
+// are kept so an admin can review the history and roll back to any of them.
+// The settings of a version override the ones in the configuration file, a
+// nil field keeps the value from the configuration.
+package settings
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/policy"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/revocation"
+)
+
+// ErrNotFound is the error returned by the stores if a version does not exist.
+var ErrNotFound = errors.New("authority settings version not found")
+
+// ErrConflict is the error returned by the stores if a version already exists.
+var ErrConflict = errors.New("authority settings version already exists")
+
+// Settings are the authority level settings managed through the
+// administration API.
+type Settings struct {
+	// Claims are the default claims of the provisioners.
+	Claims *provisioner.Claims `json:"claims,omitempty"`
+	// Template is the default subject of the X.509 certificates.
+	Template *config.ASN1DN `json:"template,omitempty"`
+	// Policy is the authority X.509 and SSH policy. It is only used if the
+	// authority policy is not defined using the policy endpoints.
+	Policy *policy.Options `json:"policy,omitempty"`
+	// Notifications are the webhooks that receive the revocation events.
+	Notifications []*revocation.Webhook `json:"notifications,omitempty"`
+}
+
+// Validate validates the settings.
+func (s *Settings) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if _, err := provisioner.NewClaimer(s.Claims, config.GlobalProvisionerClaims); err != nil {
+		return errors.Wrap(err, "invalid claims")
+	}
+	if _, err := policy.New(s.Policy); err != nil {
+		return errors.Wrap(err, "invalid policy")
+	}
+	names := make(map[string]bool, len(s.Notifications))
+	for _, w := range s.Notifications {
+		if w == nil {
+			return errors.New("notifications cannot contain null webhooks")
+		}
+		if err := w.Validate(); err != nil {
+			return err
+		}
+		if names[w.Name] {
+			return errors.Errorf("notifications contain a duplicated webhook %s", w.Name)
+		}
+		names[w.Name] = true
+	}
+	return nil
+}
+
+// Merge returns the settings with the nil fields replaced by the ones in
+// base.
+func (s *Settings) Merge(base *Settings) *Settings {
+	if base == nil {
+		base = &Settings{}
+	}
+	if s == nil {
+		return base
+	}
+	merged := *s
+	if merged.Claims == nil {
+		merged.Claims = base.Claims
+	}
+	if merged.Template == nil {
+		merged.Template = base.Template
+	}
+	if merged.Policy == nil {
+		merged.Policy = base.Policy
+	}
+	if merged.Notifications == nil {
+		merged.Notifications = base.Notifications
+	}
+	return &merged
+}
+
+// Version is a version of the authority settings.
+type Version struct {
+	Version    int       `json:"version"`
+	Settings   *Settings `json:"settings"`
+	Comment    string    `json:"comment,omitempty"`
+	RollbackOf int       `json:"rollbackOf,omitempty"`
+	CreatedBy  string    `json:"createdBy,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
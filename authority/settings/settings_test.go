@@ -0,0 +1,116 @@
+package settings
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smallstep/nosql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/policy"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/revocation"
+)
+
+func TestSettings_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings *Settings
+		wantErr  string
+	}{
+		{"ok/nil", nil, ""},
+		{"ok/empty", &Settings{}, ""},
+		{"ok", &Settings{
+			Claims:   &provisioner.Claims{MaxTLSDur: &provisioner.Duration{Duration: 48 * time.Hour}},
+			Template: &config.ASN1DN{Organization: "Smallstep"},
+			Policy: &policy.Options{X509: &policy.X509PolicyOptions{
+				AllowedNames: &policy.X509NameOptions{DNSDomains: []string{"*.example.com"}},
+			}},
+			Notifications: []*revocation.Webhook{{Name: "siem", URL: "https://siem.example.com"}},
+		}, ""},
+		{"fail/claims", &Settings{
+			Claims: &provisioner.Claims{MinTLSDur: &provisioner.Duration{Duration: -time.Hour}},
+		}, "invalid claims: claims: MinTLSCertDuration must be greater than 0"},
+		{"fail/webhook", &Settings{
+			Notifications: []*revocation.Webhook{{Name: "siem"}},
+		}, "webhook siem url cannot be empty"},
+		{"fail/null-webhook", &Settings{
+			Notifications: []*revocation.Webhook{nil},
+		}, "notifications cannot contain null webhooks"},
+		{"fail/duplicated-webhook", &Settings{
+			Notifications: []*revocation.Webhook{
+				{Name: "siem", URL: "https://siem.example.com"},
+				{Name: "siem", URL: "https://other.example.com"},
+			},
+		}, "notifications contain a duplicated webhook siem"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.Validate()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSettings_Merge(t *testing.T) {
+	base := &Settings{
+		Claims:   &provisioner.Claims{},
+		Template: &config.ASN1DN{Organization: "base"},
+	}
+	template := &config.ASN1DN{Organization: "override"}
+
+	var nilSettings *Settings
+	assert.Equal(t, base, nilSettings.Merge(base))
+	assert.Equal(t, &Settings{}, nilSettings.Merge(nil))
+	assert.Equal(t, &Settings{Claims: base.Claims, Template: template}, (&Settings{Template: template}).Merge(base))
+}
+
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	_, err := s.Get(1)
+	assert.ErrorIs(t, err, ErrNotFound)
+	latest, err := Latest(s)
+	require.NoError(t, err)
+	assert.Nil(t, latest)
+
+	v1 := &Version{Version: 1, Settings: &Settings{Template: &config.ASN1DN{Organization: "one"}}, CreatedAt: time.Now().UTC()}
+	v2 := &Version{Version: 2, Settings: &Settings{}, RollbackOf: 1, CreatedAt: time.Now().UTC()}
+	require.NoError(t, s.Create(v2))
+	require.NoError(t, s.Create(v1))
+	assert.ErrorIs(t, s.Create(v1), ErrConflict)
+
+	got, err := s.Get(1)
+	require.NoError(t, err)
+	assert.Equal(t, "one", got.Settings.Template.Organization)
+
+	list, err := s.List()
+	require.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, 1, list[0].Version)
+		assert.Equal(t, 2, list[1].Version)
+	}
+	latest, err = Latest(s)
+	require.NoError(t, err)
+	assert.Equal(t, 2, latest.Version)
+	assert.Equal(t, 1, latest.RollbackOf)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestNoSQLStore(t *testing.T) {
+	db, err := nosql.New(nosql.BBoltDriver, filepath.Join(t.TempDir(), "settings.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	s, err := NewNoSQLStore(db)
+	require.NoError(t, err)
+	testStore(t, s)
+}
@@ -0,0 +1,169 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/nosql"
+	"github.com/smallstep/nosql/database"
+)
+
+var versionsTable = []byte("authority_settings")
+
+// Store is the interface used to persist the versions of the authority
+// settings. Versions are never modified once created.
+type Store interface {
+	Create(v *Version) error
+	Get(version int) (*Version, error)
+	List() ([]*Version, error)
+}
+
+// Latest returns the last version in the store, or nil if the store is empty.
+func Latest(s Store) (*Version, error) {
+	versions, err := s.List()
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return versions[len(versions)-1], nil
+}
+
+// MemoryStore is a Store that keeps the versions in memory. It is used when
+// the authority does not have a database.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	versions map[int][]byte
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		versions: make(map[int][]byte),
+	}
+}
+
+// Create implements the Store interface.
+func (s *MemoryStore) Create(v *Version) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling authority settings")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.versions[v.Version]; ok {
+		return ErrConflict
+	}
+	s.versions[v.Version] = b
+	return nil
+}
+
+// Get implements the Store interface.
+func (s *MemoryStore) Get(version int) (*Version, error) {
+	s.mu.RLock()
+	b, ok := s.versions[version]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return unmarshalVersion(b)
+}
+
+// List implements the Store interface.
+func (s *MemoryStore) List() ([]*Version, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	versions := make([]*Version, 0, len(s.versions))
+	for _, b := range s.versions {
+		v, err := unmarshalVersion(b)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	sortVersions(versions)
+	return versions, nil
+}
+
+// NoSQLStore is a Store that persists the versions in the authority database.
+type NoSQLStore struct {
+	db nosql.DB
+}
+
+// NewNoSQLStore creates the settings table in the given database and returns
+// a new store.
+func NewNoSQLStore(db nosql.DB) (*NoSQLStore, error) {
+	if err := db.CreateTable(versionsTable); err != nil {
+		return nil, errors.Wrapf(err, "error creating table %s", versionsTable)
+	}
+	return &NoSQLStore{db: db}, nil
+}
+
+func versionKey(version int) []byte {
+	return []byte(fmt.Sprintf("%010d", version))
+}
+
+// Create implements the Store interface.
+func (s *NoSQLStore) Create(v *Version) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling authority settings")
+	}
+	_, swapped, err := s.db.CmpAndSwap(versionsTable, versionKey(v.Version), nil, b)
+	switch {
+	case err != nil:
+		return errors.Wrap(err, "error storing authority settings")
+	case !swapped:
+		return ErrConflict
+	default:
+		return nil
+	}
+}
+
+// Get implements the Store interface.
+func (s *NoSQLStore) Get(version int) (*Version, error) {
+	b, err := s.db.Get(versionsTable, versionKey(version))
+	switch {
+	case database.IsErrNotFound(err):
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, errors.Wrap(err, "error loading authority settings")
+	}
+	return unmarshalVersion(b)
+}
+
+// List implements the Store interface.
+func (s *NoSQLStore) List() ([]*Version, error) {
+	entries, err := s.db.List(versionsTable)
+	switch {
+	case database.IsErrNotFound(err):
+		return []*Version{}, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "error listing authority settings")
+	}
+	versions := make([]*Version, 0, len(entries))
+	for _, e := range entries {
+		v, err := unmarshalVersion(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	sortVersions(versions)
+	return versions, nil
+}
+
+func unmarshalVersion(b []byte) (*Version, error) {
+	v := new(Version)
+	if err := json.Unmarshal(b, v); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling authority settings")
+	}
+	return v, nil
+}
+
+func sortVersions(versions []*Version) {
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+}
@@ -0,0 +1,117 @@
+package authority
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/revocation"
+	"github.com/smallstep/certificates/authority/settings"
+)
+
+func TestAuthority_Settings(t *testing.T) {
+	a := testAuthority(t)
+	template := a.config.AuthorityConfig.Template
+	a.settingsStore = settings.NewMemoryStore()
+	a.baseSettings = a.configSettings()
+	t.Cleanup(a.stopRevocationEvents)
+
+	alice := &linkedca.Admin{Id: "alice-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+	carol := &linkedca.Admin{Id: "carol-id", Subject: "carol", Type: linkedca.Admin_ADMIN}
+	ctx := context.Background()
+
+	assertStatus := func(t *testing.T, statusCode int, err error) {
+		t.Helper()
+		var sc interface{ StatusCode() int }
+		if assert.True(t, errors.As(err, &sc)) {
+			assert.Equals(t, statusCode, sc.StatusCode())
+		}
+	}
+
+	// Without changes the settings are the ones in the configuration.
+	v, err := a.GetSettings()
+	assert.FatalError(t, err)
+	assert.Equals(t, 0, v.Version)
+	assert.Equals(t, template, v.Settings.Template)
+
+	_, err = a.UpdateSettings(ctx, carol, &settings.Settings{}, "")
+	assertStatus(t, 401, err)
+
+	_, err = a.UpdateSettings(ctx, alice, &settings.Settings{
+		Claims: &provisioner.Claims{MinTLSDur: &provisioner.Duration{Duration: -time.Hour}},
+	}, "")
+	assertStatus(t, 400, err)
+
+	// Version 1 overrides the claims, the template and the webhooks.
+	provisioners := a.provisioners
+	claims := &provisioner.Claims{DefaultTLSDur: &provisioner.Duration{Duration: 2 * time.Hour}}
+	v1, err := a.UpdateSettings(ctx, alice, &settings.Settings{
+		Claims:        claims,
+		Template:      &config.ASN1DN{Organization: "Smallstep"},
+		Notifications: []*revocation.Webhook{{Name: "siem", URL: "https://siem.example.com"}},
+	}, "first version")
+	assert.FatalError(t, err)
+	assert.Equals(t, 1, v1.Version)
+	assert.Equals(t, "alice", v1.CreatedBy)
+	assert.Equals(t, "first version", v1.Comment)
+	assert.Equals(t, claims, a.config.AuthorityConfig.Claims)
+	assert.Equals(t, "Smallstep", a.config.AuthorityConfig.Template.Organization)
+	assert.True(t, a.provisioners != provisioners)
+	if assert.NotNil(t, a.revocationBroker) {
+		assert.True(t, a.config.RevocationEvents.Enabled)
+		assert.Len(t, 1, a.config.RevocationEvents.Webhooks)
+	}
+
+	// Version 2 only overrides the template.
+	v2, err := a.UpdateSettings(ctx, alice, &settings.Settings{
+		Template: &config.ASN1DN{Organization: "Other"},
+	}, "")
+	assert.FatalError(t, err)
+	assert.Equals(t, 2, v2.Version)
+	assert.Equals(t, "Other", a.config.AuthorityConfig.Template.Organization)
+	assert.Equals(t, a.baseSettings.Claims, a.config.AuthorityConfig.Claims)
+	assert.Len(t, 0, a.config.RevocationEvents.Webhooks)
+
+	// Version 3 is a rollback to version 1.
+	_, err = a.RollbackSettings(ctx, alice, 5, "")
+	assertStatus(t, 404, err)
+	v3, err := a.RollbackSettings(ctx, alice, 1, "rollback")
+	assert.FatalError(t, err)
+	assert.Equals(t, 3, v3.Version)
+	assert.Equals(t, 1, v3.RollbackOf)
+	assert.Equals(t, claims, a.config.AuthorityConfig.Claims)
+	assert.Equals(t, "Smallstep", a.config.AuthorityConfig.Template.Organization)
+
+	versions, err := a.GetSettingsVersions()
+	assert.FatalError(t, err)
+	assert.Len(t, 3, versions)
+	v, err = a.GetSettings()
+	assert.FatalError(t, err)
+	assert.Equals(t, 3, v.Version)
+	v, err = a.GetSettingsVersion(2)
+	assert.FatalError(t, err)
+	assert.Equals(t, "Other", v.Settings.Template.Organization)
+
+	// The last version is applied on initialization.
+	a.config.AuthorityConfig.EnableAdmin = true
+	a.config.AuthorityConfig.Template = template
+	a.config.AuthorityConfig.Claims = nil
+	assert.FatalError(t, a.initSettings())
+	assert.Equals(t, claims, a.config.AuthorityConfig.Claims)
+	assert.Equals(t, "Smallstep", a.config.AuthorityConfig.Template.Organization)
+}
+
+func TestAuthority_Settings_disabled(t *testing.T) {
+	a := testAuthority(t)
+	_, err := a.GetSettings()
+	var sc interface{ StatusCode() int }
+	if assert.True(t, errors.As(err, &sc)) {
+		assert.Equals(t, 501, sc.StatusCode())
+	}
+}
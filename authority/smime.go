@@ -0,0 +1,164 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/cache"
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/notify"
+	"github.com/smallstep/certificates/smime"
+)
+
+// initSMIME configures the one-time codes used to verify the email addresses
+// in the S/MIME issuance API. The codes are kept in the ephemeral state store,
+// or in memory if it's not configured.
+func (a *Authority) initSMIME() error {
+	cfg := a.config.SMIME
+	if !cfg.IsEnabled() {
+		return nil
+	}
+	mailer := a.smimeMailer
+	switch {
+	case mailer != nil:
+	case a.notifier.IsEnabled(notify.Email):
+		mailer = &notifierMailer{notifier: a.notifier}
+	default:
+		return nil
+	}
+	var store cache.Store = cache.NewMemory()
+	if a.cache != nil {
+		store = a.cache
+	}
+	var lifetime = smime.DefaultCodeLifetime
+	if cfg.CodeLifetime != nil && cfg.CodeLifetime.Duration > 0 {
+		lifetime = cfg.CodeLifetime.Duration
+	}
+	a.smimeVerifier = smime.NewVerifier(store, mailer, lifetime)
+	return nil
+}
+
+// SendSMIMECode sends a one-time code to the given email address. The code can
+// be used to get an S/MIME certificate for it.
+func (a *Authority) SendSMIMECode(ctx context.Context, email string) error {
+	if !a.config.SMIME.IsEnabled() {
+		return errs.NotFound("S/MIME issuance is not enabled")
+	}
+	if a.smimeVerifier == nil {
+		return errs.BadRequest("S/MIME one-time codes are not enabled")
+	}
+	if err := smime.ValidateEmail(email); err != nil {
+		return errs.BadRequestErr(err, err.Error())
+	}
+	if err := a.smimeVerifier.SendCode(ctx, email); err != nil {
+		if errors.Is(err, smime.ErrTooManyRequests) {
+			return errs.New(http.StatusTooManyRequests, err.Error())
+		}
+		return errs.Wrap(http.StatusInternalServerError, err, "authority.SendSMIMECode")
+	}
+	return nil
+}
+
+// SignSMIME creates an S/MIME certificate for the given email address. The
+// ownership of the email address is proven with a one-time code or with an ID
+// token of the configured OIDC provisioner. If a token is used, the email
+// address is optional, but if given, it must match the one in the token.
+func (a *Authority) SignSMIME(ctx context.Context, csr *x509.CertificateRequest, email, code, token string) ([]*x509.Certificate, error) {
+	cfg := a.config.SMIME
+	if !cfg.IsEnabled() {
+		return nil, errs.NotFound("S/MIME issuance is not enabled")
+	}
+	p, err := a.LoadProvisionerByName(cfg.Provisioner)
+	if err != nil {
+		return nil, errs.InternalServerErr(err, errs.WithMessage("error loading S/MIME provisioner"))
+	}
+
+	var signOpts []provisioner.SignOption
+	switch {
+	case token != "":
+		if signOpts, err = a.authorizeSMIMEToken(ctx, p, email, token); err != nil {
+			return nil, err
+		}
+	case code != "":
+		if a.smimeVerifier == nil {
+			return nil, errs.BadRequest("S/MIME one-time codes are not enabled")
+		}
+		if err := smime.ValidateEmail(email); err != nil {
+			return nil, errs.BadRequestErr(err, err.Error())
+		}
+		if err := a.smimeVerifier.VerifyCode(ctx, email, code); err != nil {
+			switch {
+			case errors.Is(err, smime.ErrInvalidCode):
+				return nil, errs.UnauthorizedErr(err, errs.WithMessage(err.Error()))
+			case errors.Is(err, smime.ErrTooManyRequests):
+				return nil, errs.New(http.StatusTooManyRequests, err.Error())
+			default:
+				return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.SignSMIME")
+			}
+		}
+		var validity = smime.DefaultValidity
+		if cfg.Validity != nil && cfg.Validity.Duration > 0 {
+			validity = cfg.Validity.Duration
+		}
+		opts, err := smime.SignOptions(email, validity)
+		if err != nil {
+			return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.SignSMIME")
+		}
+		signOpts = append([]provisioner.SignOption{p}, opts...)
+	default:
+		return nil, errs.BadRequest("missing code or token")
+	}
+
+	return a.SignWithContext(ctx, csr, provisioner.SignOptions{}, signOpts...)
+}
+
+// authorizeSMIMEToken validates the ID token with the OIDC provisioner and
+// returns its sign options with the S/MIME template for the email address in
+// the token.
+func (a *Authority) authorizeSMIMEToken(ctx context.Context, p provisioner.Interface, email, token string) ([]provisioner.SignOption, error) {
+	if _, ok := p.(*provisioner.OIDC); !ok {
+		return nil, errs.BadRequest("provisioner '%s' does not accept ID tokens", p.GetName())
+	}
+	signOpts, err := p.AuthorizeSign(ctx, token)
+	if err != nil {
+		return nil, errs.UnauthorizedErr(err)
+	}
+
+	// The token has been validated by the provisioner.
+	tok, err := jose.ParseSigned(token)
+	if err != nil {
+		return nil, errs.UnauthorizedErr(err)
+	}
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified *bool  `json:"email_verified"`
+	}
+	if err := tok.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return nil, errs.UnauthorizedErr(err)
+	}
+	switch {
+	case claims.Email == "":
+		return nil, errs.Forbidden("token does not contain an email address")
+	case claims.EmailVerified != nil && !*claims.EmailVerified:
+		return nil, errs.Forbidden("token email address is not verified")
+	case email != "" && !strings.EqualFold(email, claims.Email):
+		return nil, errs.Forbidden("token email address does not match %s", email)
+	}
+
+	templateOptions, err := smime.TemplateOptions(claims.Email)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.SignSMIME")
+	}
+	for i, op := range signOpts {
+		if _, ok := op.(provisioner.CertificateOptions); ok {
+			signOpts[i] = templateOptions
+		}
+	}
+	return signOpts, nil
+}
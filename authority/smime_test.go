@@ -0,0 +1,88 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/errs"
+	"go.step.sm/crypto/keyutil"
+)
+
+type mockSMIMEMailer struct {
+	to, body string
+}
+
+func (m *mockSMIMEMailer) Send(to, _, body string) error {
+	m.to, m.body = to, body
+	return nil
+}
+
+func TestAuthority_SignSMIME(t *testing.T) {
+	mailer := &mockSMIMEMailer{}
+	a := testAuthority(t, WithSMIMEMailer(mailer), func(a *Authority) error {
+		a.config.SMIME = &config.SMIMEConfig{Enabled: true, Provisioner: "step-cli"}
+		return nil
+	})
+	disabled := testAuthority(t)
+
+	_, priv, err := keyutil.GenerateDefaultKeyPair()
+	assert.FatalError(t, err)
+	csr := getCSR(t, priv)
+	ctx := context.Background()
+	email := "jane@smallstep.com"
+
+	assertStatus := func(t *testing.T, statusCode int, err error) {
+		t.Helper()
+		var sc *errs.Error
+		if assert.True(t, errors.As(err, &sc)) {
+			assert.Equals(t, statusCode, sc.StatusCode())
+		}
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		assert.FatalError(t, a.SendSMIMECode(ctx, email))
+		assert.Equals(t, email, mailer.to)
+		code := regexp.MustCompile(`\d{8}`).FindString(mailer.body)
+
+		chain, err := a.SignSMIME(ctx, csr, email, code, "")
+		assert.FatalError(t, err)
+		leaf := chain[0]
+		assert.Equals(t, email, leaf.Subject.CommonName)
+		assert.Equals(t, []string{email}, leaf.EmailAddresses)
+		assert.Len(t, 0, leaf.DNSNames)
+		assert.Equals(t, []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection}, leaf.ExtKeyUsage)
+
+		// The code cannot be reused
+		_, err = a.SignSMIME(ctx, csr, email, code, "")
+		assertStatus(t, http.StatusUnauthorized, err)
+	})
+
+	t.Run("fail/disabled", func(t *testing.T) {
+		assertStatus(t, http.StatusNotFound, disabled.SendSMIMECode(ctx, email))
+		_, err := disabled.SignSMIME(ctx, csr, email, "12345678", "")
+		assertStatus(t, http.StatusNotFound, err)
+	})
+
+	t.Run("fail/email", func(t *testing.T) {
+		assertStatus(t, http.StatusBadRequest, a.SendSMIMECode(ctx, "jane"))
+		_, err := a.SignSMIME(ctx, csr, "jane", "12345678", "")
+		assertStatus(t, http.StatusBadRequest, err)
+	})
+
+	t.Run("fail/token", func(t *testing.T) {
+		// step-cli is not an OIDC provisioner
+		_, err := a.SignSMIME(ctx, csr, email, "", "token")
+		assertStatus(t, http.StatusBadRequest, err)
+	})
+
+	t.Run("fail/missing", func(t *testing.T) {
+		_, err := a.SignSMIME(ctx, csr, email, "", "")
+		assertStatus(t, http.StatusBadRequest, err)
+	})
+}
@@ -0,0 +1,253 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/randutil"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/subca"
+	casapi "github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/db"
+)
+
+// initSubCA creates the store of the subordinate CA requests. The requests
+// are kept in memory if the database is not configured.
+func (a *Authority) initSubCA() (err error) {
+	if !a.config.SubCA.IsEnabled() {
+		return nil
+	}
+	if ndb, ok := nosqlDB(a.db); ok {
+		a.subCAStore, err = subca.NewNoSQLStore(ndb)
+		return err
+	}
+	a.initLogf("Subordinate CA issuance is enabled without a database, requests will be kept in memory")
+	a.subCAStore = subca.NewMemoryStore()
+	return nil
+}
+
+func (a *Authority) subCAPolicy() *subca.Policy {
+	cfg := a.config.SubCA
+	p := &subca.Policy{
+		Approvals:           cfg.Approvals,
+		MaxPathLen:          cfg.MaxPathLen,
+		PermittedDNSDomains: cfg.PermittedDNSDomains,
+	}
+	if cfg.MaxValidity != nil {
+		p.MaxValidity = cfg.MaxValidity.Duration
+	}
+	return p
+}
+
+func (a *Authority) requireSubCA() error {
+	if a.subCAStore == nil {
+		return admin.NewError(admin.ErrorNotImplementedType, "subordinate CA issuance is not enabled")
+	}
+	return nil
+}
+
+// RequestSubCA creates a new pending request of a subordinate CA certificate.
+// The request is validated against the subordinate CA policy, but it's not
+// signed until it gets the required approvals.
+func (a *Authority) RequestSubCA(_ context.Context, adm *linkedca.Admin, r *subca.Request) (*subca.Request, error) {
+	if err := a.requireSubCA(); err != nil {
+		return nil, err
+	}
+	var issuer *x509.Certificate
+	if len(a.intermediateX509Certs) > 0 {
+		issuer = a.intermediateX509Certs[0]
+	}
+	if err := a.subCAPolicy().Validate(r, issuer); err != nil {
+		return nil, admin.WrapError(admin.ErrorBadRequestType, err, "invalid subordinate CA request")
+	}
+
+	id, err := randutil.UUIDv4()
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error generating subordinate CA request id")
+	}
+	now := time.Now().UTC()
+	req := &subca.Request{
+		ID:              id,
+		Status:          subca.StatusPending,
+		CSR:             r.CSR,
+		MaxPathLen:      r.MaxPathLen,
+		NameConstraints: r.NameConstraints,
+		Validity:        r.Validity,
+		RequestedBy:     adm.GetId(),
+		RequesterName:   adm.GetSubject(),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := a.subCAStore.Save(req); err != nil {
+		return nil, admin.WrapErrorISE(err, "error storing subordinate CA request")
+	}
+	a.notifySubCA(req, "created")
+	return req, nil
+}
+
+// GetSubCARequest returns the subordinate CA request with the given id.
+func (a *Authority) GetSubCARequest(id string) (*subca.Request, error) {
+	if err := a.requireSubCA(); err != nil {
+		return nil, err
+	}
+	r, err := a.subCAStore.Get(id)
+	if err != nil {
+		if errors.Is(err, subca.ErrNotFound) {
+			return nil, admin.NewError(admin.ErrorNotFoundType, "subordinate CA request %s not found", id)
+		}
+		return nil, admin.WrapErrorISE(err, "error loading subordinate CA request")
+	}
+	return r, nil
+}
+
+// GetSubCARequests returns all the subordinate CA requests.
+func (a *Authority) GetSubCARequests() ([]*subca.Request, error) {
+	if err := a.requireSubCA(); err != nil {
+		return nil, err
+	}
+	requests, err := a.subCAStore.List()
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error listing subordinate CA requests")
+	}
+	return requests, nil
+}
+
+// ApproveSubCA adds the approval of the given super admin to a pending
+// request. The requester cannot approve its own request. After the last
+// required approval, the request is validated again and the certificate is
+// signed.
+func (a *Authority) ApproveSubCA(_ context.Context, adm *linkedca.Admin, id string) (*subca.Request, error) {
+	if adm.GetType() != linkedca.Admin_SUPER_ADMIN {
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to approve a subordinate CA request")
+	}
+
+	a.subCAMutex.Lock()
+	defer a.subCAMutex.Unlock()
+
+	r, err := a.loadPendingSubCARequest(id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case r.RequestedBy == adm.GetId():
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "subordinate CA request %s cannot be approved by its requester", id)
+	case r.IsApprovedBy(adm.GetId()):
+		return nil, admin.NewError(admin.ErrorConflictType, "subordinate CA request %s has already been approved by %s", id, adm.GetSubject())
+	}
+
+	now := time.Now().UTC()
+	r.Approvals = append(r.Approvals, subca.Approval{
+		AdminID:    adm.GetId(),
+		Subject:    adm.GetSubject(),
+		ApprovedAt: now,
+	})
+	r.UpdatedAt = now
+
+	policy := a.subCAPolicy()
+	if len(r.Approvals) >= policy.RequiredApprovals() {
+		crt, err := a.signSubCA(policy, r)
+		if err != nil {
+			return nil, err
+		}
+		r.Status = subca.StatusIssued
+		r.Certificate = crt.Raw
+	}
+
+	if err := a.subCAStore.Save(r); err != nil {
+		return nil, admin.WrapErrorISE(err, "error storing subordinate CA request")
+	}
+	if r.Status == subca.StatusIssued {
+		a.notifySubCA(r, "approved and issued")
+	} else {
+		a.notifySubCA(r, "approved by "+adm.GetSubject())
+	}
+	return r, nil
+}
+
+// RejectSubCA rejects a pending subordinate CA request. Any super admin,
+// including the requester, can reject a request.
+func (a *Authority) RejectSubCA(_ context.Context, adm *linkedca.Admin, id, reason string) (*subca.Request, error) {
+	if adm.GetType() != linkedca.Admin_SUPER_ADMIN {
+		return nil, admin.NewError(admin.ErrorUnauthorizedType, "must have super admin access to reject a subordinate CA request")
+	}
+
+	a.subCAMutex.Lock()
+	defer a.subCAMutex.Unlock()
+
+	r, err := a.loadPendingSubCARequest(id)
+	if err != nil {
+		return nil, err
+	}
+	r.Status = subca.StatusRejected
+	r.RejectedBy = adm.GetSubject()
+	r.RejectionReason = reason
+	r.UpdatedAt = time.Now().UTC()
+	if err := a.subCAStore.Save(r); err != nil {
+		return nil, admin.WrapErrorISE(err, "error storing subordinate CA request")
+	}
+	a.notifySubCA(r, "rejected")
+	return r, nil
+}
+
+func (a *Authority) loadPendingSubCARequest(id string) (*subca.Request, error) {
+	r, err := a.GetSubCARequest(id)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != subca.StatusPending {
+		return nil, admin.NewError(admin.ErrorConflictType, "subordinate CA request %s is %s", id, r.Status)
+	}
+	return r, nil
+}
+
+// signSubCA signs the certificate of an approved request. The request is
+// validated again in case the policy or the issuer have changed since it was
+// created.
+func (a *Authority) signSubCA(policy *subca.Policy, r *subca.Request) (*x509.Certificate, error) {
+	if err := a.checkDecommissioned(); err != nil {
+		return nil, err
+	}
+	var issuer *x509.Certificate
+	if len(a.intermediateX509Certs) > 0 {
+		issuer = a.intermediateX509Certs[0]
+	}
+	if err := policy.Validate(r, issuer); err != nil {
+		return nil, admin.WrapError(admin.ErrorBadRequestType, err, "invalid subordinate CA request")
+	}
+	csr, err := r.CertificateRequest()
+	if err != nil {
+		return nil, admin.WrapError(admin.ErrorBadRequestType, err, "invalid subordinate CA request")
+	}
+	if err := a.keyPolicy.Validate(csr.PublicKey); err != nil {
+		return nil, admin.WrapError(admin.ErrorBadRequestType, err, "invalid subordinate CA request")
+	}
+	tpl, err := r.Template(csr)
+	if err != nil {
+		return nil, admin.WrapError(admin.ErrorBadRequestType, err, "invalid subordinate CA request")
+	}
+
+	resp, err := a.x509CAService.CreateCertificate(&casapi.CreateCertificateRequest{
+		Template: tpl,
+		CSR:      csr,
+		Lifetime: r.Validity.Duration,
+		Backdate: time.Minute,
+	})
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error signing subordinate CA certificate")
+	}
+
+	if s, ok := a.db.(db.CertificateStorer); ok {
+		if err := s.StoreCertificate(resp.Certificate); err != nil && !errors.Is(err, db.ErrNotImplemented) {
+			return nil, admin.WrapErrorISE(err, "error storing subordinate CA certificate")
+		}
+	}
+	if err := a.auditX509Sign(nil, resp.Certificate, false); err != nil {
+		return nil, admin.WrapErrorISE(err, "error auditing subordinate CA certificate")
+	}
+	a.publishX509Sign(nil, resp.Certificate)
+	return resp.Certificate, nil
+}
@@ -0,0 +1,146 @@
+package subca
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/nosql"
+	"github.com/smallstep/nosql/database"
+)
+
+var requestsTable = []byte("subca_requests")
+
+// Store is the interface used to persist the subordinate CA requests.
+type Store interface {
+	Save(r *Request) error
+	Get(id string) (*Request, error)
+	List() ([]*Request, error)
+}
+
+// MemoryStore is a Store that keeps the requests in memory. It is used when
+// the authority does not have a database.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	requests map[string][]byte
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		requests: make(map[string][]byte),
+	}
+}
+
+// Save implements the Store interface.
+func (s *MemoryStore) Save(r *Request) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling subordinate CA request")
+	}
+	s.mu.Lock()
+	s.requests[r.ID] = b
+	s.mu.Unlock()
+	return nil
+}
+
+// Get implements the Store interface.
+func (s *MemoryStore) Get(id string) (*Request, error) {
+	s.mu.RLock()
+	b, ok := s.requests[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return unmarshalRequest(b)
+}
+
+// List implements the Store interface.
+func (s *MemoryStore) List() ([]*Request, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	requests := make([]*Request, 0, len(s.requests))
+	for _, b := range s.requests {
+		r, err := unmarshalRequest(b)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, r)
+	}
+	sortRequests(requests)
+	return requests, nil
+}
+
+// NoSQLStore is a Store that persists the requests in the authority database.
+type NoSQLStore struct {
+	db nosql.DB
+}
+
+// NewNoSQLStore creates the requests table in the given database and returns
+// a new store.
+func NewNoSQLStore(db nosql.DB) (*NoSQLStore, error) {
+	if err := db.CreateTable(requestsTable); err != nil {
+		return nil, errors.Wrapf(err, "error creating table %s", requestsTable)
+	}
+	return &NoSQLStore{db: db}, nil
+}
+
+// Save implements the Store interface.
+func (s *NoSQLStore) Save(r *Request) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling subordinate CA request")
+	}
+	return errors.Wrap(s.db.Set(requestsTable, []byte(r.ID), b), "error storing subordinate CA request")
+}
+
+// Get implements the Store interface.
+func (s *NoSQLStore) Get(id string) (*Request, error) {
+	b, err := s.db.Get(requestsTable, []byte(id))
+	switch {
+	case database.IsErrNotFound(err):
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, errors.Wrap(err, "error loading subordinate CA request")
+	}
+	return unmarshalRequest(b)
+}
+
+// List implements the Store interface.
+func (s *NoSQLStore) List() ([]*Request, error) {
+	entries, err := s.db.List(requestsTable)
+	switch {
+	case database.IsErrNotFound(err):
+		return []*Request{}, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "error listing subordinate CA requests")
+	}
+	requests := make([]*Request, 0, len(entries))
+	for _, e := range entries {
+		r, err := unmarshalRequest(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, r)
+	}
+	sortRequests(requests)
+	return requests, nil
+}
+
+func unmarshalRequest(b []byte) (*Request, error) {
+	r := new(Request)
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling subordinate CA request")
+	}
+	return r, nil
+}
+
+func sortRequests(requests []*Request) {
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].CreatedAt.Equal(requests[j].CreatedAt) {
+			return requests[i].ID < requests[j].ID
+		}
+		return requests[i].CreatedAt.Before(requests[j].CreatedAt)
+	})
+}
@@ -0,0 +1,239 @@
+// Package subca implements the requests used to issue subordinate CA
+// certificates through the administration API.
+//
+// A request is created by an admin and it's kept pending until it has been
+// approved by the configured number of super admins, other than the one that
+// created it. The certificate is only signed after the last approval.
+package subca
+
+import (
+	"crypto/x509"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// DefaultMaxValidity is the default maximum validity of a subordinate CA.
+const DefaultMaxValidity = 5 * 365 * 24 * time.Hour
+
+// Status is the status of a subordinate CA request.
+type Status string
+
+const (
+	// StatusPending is the status of a request waiting for approvals.
+	StatusPending Status = "pending"
+	// StatusIssued is the status of an approved request with a signed
+	// certificate.
+	StatusIssued Status = "issued"
+	// StatusRejected is the status of a rejected request.
+	StatusRejected Status = "rejected"
+)
+
+// ErrNotFound is the error returned by the stores if a request does not exist.
+var ErrNotFound = errors.New("subordinate CA request not found")
+
+// NameConstraints are the name constraints of a subordinate CA. The IP ranges
+// use the CIDR notation.
+type NameConstraints struct {
+	PermittedDNSDomains     []string `json:"permittedDNSDomains,omitempty"`
+	ExcludedDNSDomains      []string `json:"excludedDNSDomains,omitempty"`
+	PermittedIPRanges       []string `json:"permittedIPRanges,omitempty"`
+	ExcludedIPRanges        []string `json:"excludedIPRanges,omitempty"`
+	PermittedEmailAddresses []string `json:"permittedEmailAddresses,omitempty"`
+	ExcludedEmailAddresses  []string `json:"excludedEmailAddresses,omitempty"`
+	PermittedURIDomains     []string `json:"permittedURIDomains,omitempty"`
+	ExcludedURIDomains      []string `json:"excludedURIDomains,omitempty"`
+}
+
+// IsEmpty returns if the name constraints do not contain any name.
+func (nc *NameConstraints) IsEmpty() bool {
+	return nc == nil || len(nc.PermittedDNSDomains)+len(nc.ExcludedDNSDomains)+
+		len(nc.PermittedIPRanges)+len(nc.ExcludedIPRanges)+
+		len(nc.PermittedEmailAddresses)+len(nc.ExcludedEmailAddresses)+
+		len(nc.PermittedURIDomains)+len(nc.ExcludedURIDomains) == 0
+}
+
+// Approval is the approval of a request by a super admin.
+type Approval struct {
+	AdminID    string    `json:"adminID"`
+	Subject    string    `json:"subject"`
+	ApprovedAt time.Time `json:"approvedAt"`
+}
+
+// Request is a request to issue a subordinate CA certificate.
+type Request struct {
+	ID              string               `json:"id"`
+	Status          Status               `json:"status"`
+	CSR             []byte               `json:"csr"`
+	MaxPathLen      int                  `json:"maxPathLen"`
+	NameConstraints *NameConstraints     `json:"nameConstraints,omitempty"`
+	Validity        provisioner.Duration `json:"validity"`
+	RequestedBy     string               `json:"requestedBy"`
+	RequesterName   string               `json:"requesterName"`
+	Approvals       []Approval           `json:"approvals,omitempty"`
+	RejectedBy      string               `json:"rejectedBy,omitempty"`
+	RejectionReason string               `json:"rejectionReason,omitempty"`
+	Certificate     []byte               `json:"certificate,omitempty"`
+	CreatedAt       time.Time            `json:"createdAt"`
+	UpdatedAt       time.Time            `json:"updatedAt"`
+}
+
+// IsApprovedBy returns if the admin with the given id has approved the
+// request.
+func (r *Request) IsApprovedBy(adminID string) bool {
+	for _, a := range r.Approvals {
+		if a.AdminID == adminID {
+			return true
+		}
+	}
+	return false
+}
+
+// CertificateRequest parses the certificate request and checks its signature.
+func (r *Request) CertificateRequest() (*x509.CertificateRequest, error) {
+	csr, err := x509.ParseCertificateRequest(r.CSR)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing certificate request")
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, errors.Wrap(err, "invalid certificate request signature")
+	}
+	return csr, nil
+}
+
+// Template returns the certificate template of the subordinate CA for the
+// given certificate request. The name constraints are always critical.
+func (r *Request) Template(csr *x509.CertificateRequest) (*x509.Certificate, error) {
+	cert := &x509.Certificate{
+		Subject:               csr.Subject,
+		PublicKey:             csr.PublicKey,
+		PublicKeyAlgorithm:    csr.PublicKeyAlgorithm,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            r.MaxPathLen,
+		MaxPathLenZero:        r.MaxPathLen == 0,
+	}
+	if nc := r.NameConstraints; !nc.IsEmpty() {
+		var err error
+		cert.PermittedDNSDomainsCritical = true
+		cert.PermittedDNSDomains = nc.PermittedDNSDomains
+		cert.ExcludedDNSDomains = nc.ExcludedDNSDomains
+		cert.PermittedEmailAddresses = nc.PermittedEmailAddresses
+		cert.ExcludedEmailAddresses = nc.ExcludedEmailAddresses
+		cert.PermittedURIDomains = nc.PermittedURIDomains
+		cert.ExcludedURIDomains = nc.ExcludedURIDomains
+		if cert.PermittedIPRanges, err = parseIPRanges(nc.PermittedIPRanges); err != nil {
+			return nil, err
+		}
+		if cert.ExcludedIPRanges, err = parseIPRanges(nc.ExcludedIPRanges); err != nil {
+			return nil, err
+		}
+	}
+	return cert, nil
+}
+
+func parseIPRanges(ranges []string) ([]*net.IPNet, error) {
+	var ret []*net.IPNet
+	for _, s := range ranges {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, errors.Errorf("invalid ip range %s", s)
+		}
+		ret = append(ret, ipNet)
+	}
+	return ret, nil
+}
+
+// Policy contains the rules that a subordinate CA request must follow.
+type Policy struct {
+	// Approvals is the number of super admins that must approve a request.
+	Approvals int
+	// MaxPathLen is the maximum path length constraint of the subordinate
+	// CAs.
+	MaxPathLen int
+	// MaxValidity is the maximum validity of the subordinate CAs.
+	MaxValidity time.Duration
+	// PermittedDNSDomains, if set, requires the subordinate CAs to be
+	// constrained to these domains or their subdomains.
+	PermittedDNSDomains []string
+}
+
+// Validate checks the request against the policy and the certificate of the
+// issuer.
+func (p *Policy) Validate(r *Request, issuer *x509.Certificate) error {
+	if _, err := r.CertificateRequest(); err != nil {
+		return err
+	}
+	if _, err := r.Template(&x509.CertificateRequest{}); err != nil {
+		return err
+	}
+
+	switch {
+	case r.MaxPathLen < 0:
+		return errors.New("maxPathLen cannot be negative")
+	case r.MaxPathLen > p.MaxPathLen:
+		return errors.Errorf("maxPathLen cannot be greater than %d", p.MaxPathLen)
+	case r.Validity.Duration <= 0:
+		return errors.New("validity must be greater than 0")
+	case r.Validity.Duration > p.maxValidity():
+		return errors.Errorf("validity cannot be greater than %s", p.maxValidity())
+	}
+
+	// The issuer must be allowed to sign a CA with the requested path length.
+	if issuer != nil && issuer.BasicConstraintsValid && (issuer.MaxPathLen > 0 || issuer.MaxPathLenZero) {
+		if r.MaxPathLen >= issuer.MaxPathLen {
+			return errors.Errorf("maxPathLen must be lower than the issuer path length constraint %d", issuer.MaxPathLen)
+		}
+	}
+
+	if len(p.PermittedDNSDomains) > 0 {
+		if r.NameConstraints == nil || len(r.NameConstraints.PermittedDNSDomains) == 0 {
+			return errors.New("nameConstraints must contain permitted dns domains")
+		}
+		for _, domain := range r.NameConstraints.PermittedDNSDomains {
+			if !isSubdomain(domain, p.PermittedDNSDomains) {
+				return errors.Errorf("permitted dns domain %s is not allowed", domain)
+			}
+		}
+	}
+	return nil
+}
+
+// RequiredApprovals returns the number of approvals required, at least one.
+func (p *Policy) RequiredApprovals() int {
+	if p.Approvals < 1 {
+		return 1
+	}
+	return p.Approvals
+}
+
+func (p *Policy) maxValidity() time.Duration {
+	if p.MaxValidity > 0 {
+		return p.MaxValidity
+	}
+	return DefaultMaxValidity
+}
+
+// isSubdomain returns if the given domain is one of the given domains or a
+// subdomain of them. Domains starting with a dot only match subdomains.
+func isSubdomain(domain string, domains []string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	for _, d := range domains {
+		d = strings.ToLower(d)
+		if strings.HasPrefix(d, ".") {
+			if strings.HasSuffix(domain, d) {
+				return true
+			}
+			continue
+		}
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package subca
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/x509util"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func mustRequest(t *testing.T) *Request {
+	t.Helper()
+	signer, err := keyutil.GenerateDefaultSigner()
+	require.NoError(t, err)
+	csr, err := x509util.CreateCertificateRequest("Team CA", nil, signer)
+	require.NoError(t, err)
+	return &Request{
+		ID:       "request-id",
+		CSR:      csr.Raw,
+		Validity: provisioner.Duration{Duration: time.Hour},
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	issuer := &x509.Certificate{BasicConstraintsValid: true, IsCA: true, MaxPathLen: 1}
+	type args struct {
+		modify func(r *Request)
+		issuer *x509.Certificate
+	}
+	tests := []struct {
+		name    string
+		policy  *Policy
+		args    args
+		wantErr string
+	}{
+		{"ok", &Policy{}, args{func(r *Request) {}, issuer}, ""},
+		{"ok no issuer constraint", &Policy{MaxPathLen: 2}, args{func(r *Request) { r.MaxPathLen = 2 }, &x509.Certificate{BasicConstraintsValid: true, IsCA: true, MaxPathLen: -1}}, ""},
+		{"ok permitted domains", &Policy{PermittedDNSDomains: []string{"example.com"}}, args{func(r *Request) {
+			r.NameConstraints = &NameConstraints{PermittedDNSDomains: []string{"example.com", "team.example.com", ".example.com"}}
+		}, issuer}, ""},
+		{"fail csr", &Policy{}, args{func(r *Request) { r.CSR = []byte("foo") }, issuer}, "error parsing certificate request"},
+		{"fail ip range", &Policy{}, args{func(r *Request) {
+			r.NameConstraints = &NameConstraints{PermittedIPRanges: []string{"10.0.0.1"}}
+		}, issuer}, "invalid ip range 10.0.0.1"},
+		{"fail negative path length", &Policy{}, args{func(r *Request) { r.MaxPathLen = -1 }, issuer}, "maxPathLen cannot be negative"},
+		{"fail path length", &Policy{}, args{func(r *Request) { r.MaxPathLen = 1 }, issuer}, "maxPathLen cannot be greater than 0"},
+		{"fail issuer path length", &Policy{MaxPathLen: 1}, args{func(r *Request) { r.MaxPathLen = 1 }, issuer}, "maxPathLen must be lower than the issuer path length constraint 1"},
+		{"fail issuer path length zero", &Policy{}, args{func(r *Request) {}, &x509.Certificate{BasicConstraintsValid: true, IsCA: true, MaxPathLenZero: true}}, "maxPathLen must be lower than the issuer path length constraint 0"},
+		{"fail validity", &Policy{}, args{func(r *Request) { r.Validity.Duration = 0 }, issuer}, "validity must be greater than 0"},
+		{"fail max validity", &Policy{MaxValidity: time.Minute}, args{func(r *Request) {}, issuer}, "validity cannot be greater than 1m0s"},
+		{"fail missing constraints", &Policy{PermittedDNSDomains: []string{"example.com"}}, args{func(r *Request) {}, issuer}, "nameConstraints must contain permitted dns domains"},
+		{"fail permitted domains", &Policy{PermittedDNSDomains: []string{".example.com"}}, args{func(r *Request) {
+			r.NameConstraints = &NameConstraints{PermittedDNSDomains: []string{"example.com"}}
+		}, issuer}, "permitted dns domain example.com is not allowed"},
+		{"fail other domain", &Policy{PermittedDNSDomains: []string{"example.com"}}, args{func(r *Request) {
+			r.NameConstraints = &NameConstraints{PermittedDNSDomains: []string{"badexample.com"}}
+		}, issuer}, "permitted dns domain badexample.com is not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mustRequest(t)
+			tt.args.modify(r)
+			err := tt.policy.Validate(r, tt.args.issuer)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRequest_Template(t *testing.T) {
+	r := mustRequest(t)
+	r.MaxPathLen = 1
+	r.NameConstraints = &NameConstraints{
+		PermittedDNSDomains: []string{"example.com"},
+		ExcludedIPRanges:    []string{"10.0.0.0/8"},
+	}
+	csr, err := r.CertificateRequest()
+	require.NoError(t, err)
+	cert, err := r.Template(csr)
+	require.NoError(t, err)
+	assert.True(t, cert.IsCA)
+	assert.True(t, cert.BasicConstraintsValid)
+	assert.Equal(t, 1, cert.MaxPathLen)
+	assert.False(t, cert.MaxPathLenZero)
+	assert.Equal(t, x509.KeyUsageCertSign|x509.KeyUsageCRLSign|x509.KeyUsageDigitalSignature, cert.KeyUsage)
+	assert.True(t, cert.PermittedDNSDomainsCritical)
+	assert.Equal(t, []string{"example.com"}, cert.PermittedDNSDomains)
+	if assert.Len(t, cert.ExcludedIPRanges, 1) {
+		assert.Equal(t, "10.0.0.0/8", cert.ExcludedIPRanges[0].String())
+	}
+
+	r.MaxPathLen = 0
+	r.NameConstraints = nil
+	cert, err = r.Template(csr)
+	require.NoError(t, err)
+	assert.True(t, cert.MaxPathLenZero)
+	assert.False(t, cert.PermittedDNSDomainsCritical)
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	_, err := s.Get("request-id")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	now := time.Now().UTC()
+	r1, r2 := mustRequest(t), mustRequest(t)
+	r1.ID, r1.CreatedAt = "b", now
+	r2.ID, r2.CreatedAt = "a", now.Add(time.Second)
+	require.NoError(t, s.Save(r2))
+	require.NoError(t, s.Save(r1))
+
+	got, err := s.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, r2.CSR, got.CSR)
+	assert.Equal(t, time.Hour, got.Validity.Duration)
+
+	list, err := s.List()
+	require.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "b", list[0].ID)
+		assert.Equal(t, "a", list[1].ID)
+	}
+}
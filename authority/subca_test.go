@@ -0,0 +1,155 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+	"go.step.sm/crypto/x509util"
+	"go.step.sm/linkedca"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/subca"
+)
+
+func TestAuthority_SubCA(t *testing.T) {
+	ca, err := minica.New(minica.WithIntermediateTemplate(`{
+		"subject": {{ toJson .Subject }},
+		"keyUsage": ["certSign", "crlSign"],
+		"basicConstraints": {"isCA": true, "maxPathLen": 1}
+	}`))
+	assert.FatalError(t, err)
+
+	a, err := NewEmbedded(
+		WithConfig(&Config{SubCA: &config.SubCAConfig{
+			Enabled:             true,
+			PermittedDNSDomains: []string{"example.com"},
+		}}),
+		WithX509RootCerts(ca.Root),
+		WithX509Signer(ca.Intermediate, ca.Signer),
+	)
+	assert.FatalError(t, err)
+
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+	csr, err := x509util.CreateCertificateRequest("Team CA", nil, signer)
+	assert.FatalError(t, err)
+
+	alice := &linkedca.Admin{Id: "alice-id", Subject: "alice", Type: linkedca.Admin_SUPER_ADMIN}
+	bob := &linkedca.Admin{Id: "bob-id", Subject: "bob", Type: linkedca.Admin_SUPER_ADMIN}
+	carol := &linkedca.Admin{Id: "carol-id", Subject: "carol", Type: linkedca.Admin_ADMIN}
+
+	newRequest := func() *subca.Request {
+		return &subca.Request{
+			CSR:             csr.Raw,
+			NameConstraints: &subca.NameConstraints{PermittedDNSDomains: []string{"team.example.com"}},
+			Validity:        provisioner.Duration{Duration: 24 * time.Hour},
+		}
+	}
+
+	assertStatus := func(t *testing.T, statusCode int, err error) {
+		t.Helper()
+		var sc interface{ StatusCode() int }
+		if assert.True(t, errors.As(err, &sc)) {
+			assert.Equals(t, statusCode, sc.StatusCode())
+		}
+	}
+
+	ctx := context.Background()
+	t.Run("ok", func(t *testing.T) {
+		r, err := a.RequestSubCA(ctx, alice, newRequest())
+		assert.FatalError(t, err)
+		assert.Equals(t, subca.StatusPending, r.Status)
+		assert.Equals(t, "alice-id", r.RequestedBy)
+
+		_, err = a.ApproveSubCA(ctx, alice, r.ID)
+		assertStatus(t, http.StatusUnauthorized, err)
+		_, err = a.ApproveSubCA(ctx, carol, r.ID)
+		assertStatus(t, http.StatusUnauthorized, err)
+
+		r, err = a.ApproveSubCA(ctx, bob, r.ID)
+		assert.FatalError(t, err)
+		assert.Equals(t, subca.StatusIssued, r.Status)
+		if assert.Len(t, 1, r.Approvals) {
+			assert.Equals(t, "bob", r.Approvals[0].Subject)
+		}
+
+		crt, err := x509.ParseCertificate(r.Certificate)
+		assert.FatalError(t, err)
+		assert.Equals(t, "Team CA", crt.Subject.CommonName)
+		assert.True(t, crt.IsCA)
+		assert.Equals(t, 0, crt.MaxPathLen)
+		assert.True(t, crt.MaxPathLenZero)
+		assert.True(t, crt.PermittedDNSDomainsCritical)
+		assert.Equals(t, []string{"team.example.com"}, crt.PermittedDNSDomains)
+		assert.FatalError(t, crt.CheckSignatureFrom(ca.Intermediate))
+
+		_, err = a.ApproveSubCA(ctx, bob, r.ID)
+		assertStatus(t, http.StatusConflict, err)
+
+		stored, err := a.GetSubCARequest(r.ID)
+		assert.FatalError(t, err)
+		assert.Equals(t, subca.StatusIssued, stored.Status)
+	})
+
+	t.Run("ok/reject", func(t *testing.T) {
+		r, err := a.RequestSubCA(ctx, alice, newRequest())
+		assert.FatalError(t, err)
+		_, err = a.RejectSubCA(ctx, carol, r.ID, "no")
+		assertStatus(t, http.StatusUnauthorized, err)
+		r, err = a.RejectSubCA(ctx, alice, r.ID, "not needed")
+		assert.FatalError(t, err)
+		assert.Equals(t, subca.StatusRejected, r.Status)
+		assert.Equals(t, "not needed", r.RejectionReason)
+		_, err = a.ApproveSubCA(ctx, bob, r.ID)
+		assertStatus(t, http.StatusConflict, err)
+	})
+
+	t.Run("fail/policy", func(t *testing.T) {
+		r := newRequest()
+		r.NameConstraints.PermittedDNSDomains = []string{"example.org"}
+		_, err := a.RequestSubCA(ctx, alice, r)
+		assertStatus(t, http.StatusBadRequest, err)
+
+		r = newRequest()
+		r.MaxPathLen = 1
+		_, err = a.RequestSubCA(ctx, alice, r)
+		assertStatus(t, http.StatusBadRequest, err)
+	})
+
+	t.Run("fail/not found", func(t *testing.T) {
+		_, err := a.GetSubCARequest("missing")
+		assertStatus(t, http.StatusNotFound, err)
+	})
+
+	t.Run("fail/disabled", func(t *testing.T) {
+		a, err := NewEmbedded(WithX509RootCerts(ca.Root), WithX509Signer(ca.Intermediate, ca.Signer))
+		assert.FatalError(t, err)
+		_, err = a.RequestSubCA(ctx, alice, newRequest())
+		assertStatus(t, http.StatusNotImplemented, err)
+	})
+}
+
+func TestAuthority_SubCA_pathLen(t *testing.T) {
+	// The test intermediate has a path length constraint of 0.
+	a := testAuthority(t, func(a *Authority) error {
+		a.config.SubCA = &config.SubCAConfig{Enabled: true}
+		return nil
+	})
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+	csr, err := x509util.CreateCertificateRequest("Team CA", nil, signer)
+	assert.FatalError(t, err)
+	_, err = a.RequestSubCA(context.Background(), &linkedca.Admin{Id: "id"}, &subca.Request{
+		CSR:      csr.Raw,
+		Validity: provisioner.Duration{Duration: time.Hour},
+	})
+	assert.Error(t, err)
+}
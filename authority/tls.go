@@ -26,6 +26,7 @@ import (
 	"go.step.sm/crypto/x509util"
 
 	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/keypolicy"
 	"github.com/smallstep/certificates/authority/provisioner"
 	casapi "github.com/smallstep/certificates/cas/apiv1"
 	"github.com/smallstep/certificates/db"
@@ -52,6 +53,12 @@ func (a *Authority) GetTLSOptions() *config.TLSOptions {
 	return a.config.TLS
 }
 
+// GetBatchSignConfig returns the configuration of the batch sign endpoint, a
+// nil value uses the defaults.
+func (a *Authority) GetBatchSignConfig() *config.BatchSignConfig {
+	return a.config.BatchSign
+}
+
 var (
 	oidAuthorityKeyIdentifier            = asn1.ObjectIdentifier{2, 5, 29, 35}
 	oidSubjectKeyIdentifier              = asn1.ObjectIdentifier{2, 5, 29, 14}
@@ -93,6 +100,105 @@ func withDefaultASN1DN(def *config.ASN1DN) provisioner.CertificateModifierFunc {
 
 // Sign creates a signed certificate from a certificate signing request.
 func (a *Authority) Sign(csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+	return a.SignWithContext(context.Background(), csr, signOpts, extraOpts...)
+}
+
+// SignWithContext creates a signed certificate from a certificate signing
+// request. The trace context and the request id in the given context are sent
+// to the webhooks and the CAS.
+func (a *Authority) SignWithContext(ctx context.Context, csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+	chain, prov, d, err := a.signX509(ctx, csr, signOpts, extraOpts...)
+	a.getMeter().X509Signed(prov, d, err)
+	a.recordSign(prov, err)
+	return chain, err
+}
+
+// renderedX509 is a certificate template rendered and validated by
+// renderX509, with the information required to sign it.
+type renderedX509 struct {
+	cert          *x509util.Certificate
+	leaf          *x509.Certificate
+	prov          provisioner.Interface
+	pInfo         *casapi.ProvisionerInfo
+	attData       *provisioner.AttestationData
+	webhookCtl    webhookController
+	provisionerID string
+	identity      string
+	signOpts      provisioner.SignOptions
+	opts          []interface{}
+}
+
+// signX509 implements the sign flow. It returns the provisioner used and the
+// time spent by the CAS signing the certificate, so they can be measured.
+func (a *Authority) signX509(ctx context.Context, csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, provisioner.Interface, time.Duration, error) {
+	if err := a.checkDecommissioned(); err != nil {
+		return nil, nil, 0, err
+	}
+	r, prov, err := a.renderX509(ctx, csr, signOpts, extraOpts...)
+	if err != nil {
+		return nil, prov, 0, err
+	}
+	leaf, opts := r.leaf, r.opts
+	signOpts, pInfo, attData := r.signOpts, r.pInfo, r.attData
+	provisionerID, identity := r.provisionerID, r.identity
+
+	// Send certificate to webhooks for authorization
+	if err := callAuthorizingWebhooksX509(ctx, r.webhookCtl, r.cert, leaf, attData); err != nil {
+		return nil, prov, 0, errs.ApplyOptions(
+			errs.ForbiddenErr(err, "error creating certificate"),
+			opts...,
+		)
+	}
+
+	// Sign certificate
+	lifetime := leaf.NotAfter.Sub(leaf.NotBefore.Add(signOpts.Backdate))
+	signStart := time.Now()
+	resp, err := a.x509CAService.CreateCertificate(&casapi.CreateCertificateRequest{
+		Template:    leaf,
+		CSR:         csr,
+		Lifetime:    lifetime,
+		Backdate:    signOpts.Backdate,
+		Provisioner: pInfo,
+		Context:     ctx,
+	})
+	signDuration := time.Since(signStart)
+	if err != nil {
+		status, code := signErrorStatus(err)
+		return nil, prov, signDuration, errs.Wrap(status, err, "authority.Sign; error creating certificate", append(opts, code)...)
+	}
+
+	fullchain := append([]*x509.Certificate{resp.Certificate}, resp.CertificateChain...)
+
+	// Wrap provisioner with extra information.
+	prov = wrapProvisioner(prov, attData)
+
+	// Store certificate in the db.
+	if err = a.storeCertificate(prov, fullchain); err != nil {
+		if !errors.Is(err, db.ErrNotImplemented) {
+			return nil, prov, signDuration, errs.Wrap(http.StatusInternalServerError, err,
+				"authority.Sign; error storing certificate in db", opts...)
+		}
+	}
+	if err = a.storeKeyIdentity(provisionerID, identity, resp.Certificate); err != nil {
+		return nil, prov, signDuration, errs.Wrap(http.StatusInternalServerError, err,
+			"authority.Sign; error storing key identity", opts...)
+	}
+
+	// Record the certificate in the audit log.
+	if err = a.auditX509Sign(prov, resp.Certificate, isServerSideKey(ctx)); err != nil {
+		return nil, prov, signDuration, errs.Wrap(http.StatusInternalServerError, err,
+			"authority.Sign; error writing audit log", opts...)
+	}
+	a.publishX509Sign(prov, resp.Certificate)
+	a.publishCertificate(r.prov, fullchain)
+
+	return fullchain, prov, signDuration, nil
+}
+
+// renderX509 renders the certificate template of the sign flow and runs all
+// the validations, modifiers and policies, except the authorizing webhooks.
+// It returns the provisioner used even on error.
+func (a *Authority) renderX509(ctx context.Context, csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) (*renderedX509, provisioner.Interface, error) {
 	var (
 		certOptions    []x509util.Option
 		certValidators []provisioner.CertificateValidator
@@ -102,19 +208,31 @@ func (a *Authority) Sign(csr *x509.CertificateRequest, signOpts provisioner.Sign
 
 	opts := []interface{}{errs.WithKeyVal("csr", csr), errs.WithKeyVal("signOptions", signOpts)}
 	if err := csr.CheckSignature(); err != nil {
-		return nil, errs.ApplyOptions(
+		return nil, nil, errs.ApplyOptions(
 			errs.BadRequestErr(err, "invalid certificate request"),
 			opts...,
 		)
 	}
 
+	// Check the key before rendering the templates.
+	if err := a.keyPolicy.Validate(csr.PublicKey); err != nil {
+		return nil, nil, errs.ApplyOptions(
+			errs.ForbiddenErr(err, err.Error()),
+			opts...,
+		)
+	}
+
 	// Set backdate with the configured value
 	signOpts.Backdate = a.config.AuthorityConfig.Backdate.Duration
 
+	// Set the requested validity within the limits of the provisioner
+	signOpts.ClampValidity(extraOpts)
+
 	var prov provisioner.Interface
 	var pInfo *casapi.ProvisionerInfo
 	var attData *provisioner.AttestationData
 	var webhookCtl webhookController
+	var identity string
 	for _, op := range extraOpts {
 		switch k := op.(type) {
 		// Capture current provisioner
@@ -132,7 +250,7 @@ func (a *Authority) Sign(csr *x509.CertificateRequest, signOpts provisioner.Sign
 		// Validate the given certificate request.
 		case provisioner.CertificateRequestValidator:
 			if err := k.Valid(csr); err != nil {
-				return nil, errs.ApplyOptions(
+				return nil, prov, errs.ApplyOptions(
 					errs.ForbiddenErr(err, "error validating certificate"),
 					opts...,
 				)
@@ -158,24 +276,30 @@ func (a *Authority) Sign(csr *x509.CertificateRequest, signOpts provisioner.Sign
 		case webhookController:
 			webhookCtl = k
 
+		// The identity of the requester, used to detect shared keys.
+		case provisioner.RequesterIdentity:
+			identity = string(k)
+
 		default:
-			return nil, errs.InternalServer("authority.Sign; invalid extra option type %T", append([]interface{}{k}, opts...)...)
+			return nil, prov, errs.InternalServer("authority.Sign; invalid extra option type %T", append([]interface{}{k}, opts...)...)
 		}
 	}
 
-	if err := callEnrichingWebhooksX509(webhookCtl, attData, csr); err != nil {
-		return nil, errs.ApplyOptions(
+	if err := callEnrichingWebhooksX509(ctx, webhookCtl, attData, csr); err != nil {
+		return nil, prov, errs.ApplyOptions(
 			errs.ForbiddenErr(err, err.Error()),
 			errs.WithKeyVal("csr", csr),
 			errs.WithKeyVal("signOptions", signOpts),
 		)
 	}
 
+	renderStart := time.Now()
 	cert, err := x509util.NewCertificate(csr, certOptions...)
+	a.getMeter().X509Rendered(prov, time.Since(renderStart), err)
 	if err != nil {
 		var te *x509util.TemplateError
 		if errors.As(err, &te) {
-			return nil, errs.ApplyOptions(
+			return nil, prov, errs.ApplyOptions(
 				errs.BadRequestErr(err, err.Error()),
 				errs.WithKeyVal("csr", csr),
 				errs.WithKeyVal("signOptions", signOpts),
@@ -183,13 +307,13 @@ func (a *Authority) Sign(csr *x509.CertificateRequest, signOpts provisioner.Sign
 		}
 		// explicitly check for unmarshaling errors, which are most probably caused by JSON template (syntax) errors
 		if strings.HasPrefix(err.Error(), "error unmarshaling certificate") {
-			return nil, errs.InternalServerErr(templatingError(err),
+			return nil, prov, errs.InternalServerErr(templatingError(err),
 				errs.WithKeyVal("csr", csr),
 				errs.WithKeyVal("signOptions", signOpts),
 				errs.WithMessage("error applying certificate template"),
 			)
 		}
-		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.Sign", opts...)
+		return nil, prov, errs.Wrap(http.StatusInternalServerError, err, "authority.Sign", opts...)
 	}
 
 	// Certificate modifiers before validation
@@ -197,7 +321,7 @@ func (a *Authority) Sign(csr *x509.CertificateRequest, signOpts provisioner.Sign
 
 	// Set default subject
 	if err := withDefaultASN1DN(a.config.AuthorityConfig.Template).Modify(leaf, signOpts); err != nil {
-		return nil, errs.ApplyOptions(
+		return nil, prov, errs.ApplyOptions(
 			errs.ForbiddenErr(err, "error creating certificate"),
 			opts...,
 		)
@@ -205,17 +329,31 @@ func (a *Authority) Sign(csr *x509.CertificateRequest, signOpts provisioner.Sign
 
 	for _, m := range certModifiers {
 		if err := m.Modify(leaf, signOpts); err != nil {
-			return nil, errs.ApplyOptions(
+			return nil, prov, errs.ApplyOptions(
 				errs.ForbiddenErr(err, "error creating certificate"),
 				opts...,
 			)
 		}
 	}
 
+	// Set the key usages in the request if the provisioner allows them
+	if signOpts.KeyUsage != 0 || len(signOpts.ExtKeyUsage) > 0 {
+		var x509Options *provisioner.X509Options
+		if p, ok := prov.(interface{ GetOptions() *provisioner.Options }); ok {
+			x509Options = p.GetOptions().GetX509Options()
+		}
+		if err := provisioner.RequestedKeyUsagesModifier(x509Options).Modify(leaf, signOpts); err != nil {
+			return nil, prov, errs.ApplyOptions(
+				errs.ForbiddenErr(err, err.Error()),
+				opts...,
+			)
+		}
+	}
+
 	// Certificate validation.
 	for _, v := range certValidators {
 		if err := v.Valid(leaf, signOpts); err != nil {
-			return nil, errs.ApplyOptions(
+			return nil, prov, errs.ApplyOptions(
 				errs.ForbiddenErr(err, "error validating certificate"),
 				opts...,
 			)
@@ -225,7 +363,7 @@ func (a *Authority) Sign(csr *x509.CertificateRequest, signOpts provisioner.Sign
 	// Certificate modifiers after validation
 	for _, m := range certEnforcers {
 		if err := m.Enforce(leaf); err != nil {
-			return nil, errs.ApplyOptions(
+			return nil, prov, errs.ApplyOptions(
 				errs.ForbiddenErr(err, "error creating certificate"),
 				opts...,
 			)
@@ -235,61 +373,81 @@ func (a *Authority) Sign(csr *x509.CertificateRequest, signOpts provisioner.Sign
 	// Process injected modifiers after validation
 	for _, m := range a.x509Enforcers {
 		if err := m.Enforce(leaf); err != nil {
-			return nil, errs.ApplyOptions(
+			return nil, prov, errs.ApplyOptions(
 				errs.ForbiddenErr(err, "error creating certificate"),
 				opts...,
 			)
 		}
 	}
 
+	// Add the configured AIA and CRL distribution points URLs
+	a.addAuthorityInformationAccess(leaf)
+
+	// Add the location of the time-stamp authority to code signing
+	// certificates
+	if err := a.addTimestampingLinkage(leaf); err != nil {
+		return nil, prov, errs.InternalServerErr(err,
+			errs.WithKeyVal("csr", csr),
+			errs.WithKeyVal("signOptions", signOpts),
+			errs.WithMessage("error creating certificate"),
+		)
+	}
+
+	// Check the compliance profile
+	if err := a.checkCompliance(prov, leaf); err != nil {
+		return nil, prov, errs.ApplyOptions(err, opts...)
+	}
+
+	// Check if the names were denied after the revocation of a certificate
+	if err := a.checkDeniedIdentities(leaf); err != nil {
+		return nil, prov, errs.ApplyOptions(err, opts...)
+	}
+
 	// Check if authority is allowed to sign the certificate
 	if err := a.isAllowedToSignX509Certificate(leaf); err != nil {
 		var ee *errs.Error
 		if errors.As(err, &ee) {
-			return nil, errs.ApplyOptions(ee, opts...)
+			return nil, prov, errs.ApplyOptions(ee, opts...)
 		}
-		return nil, errs.InternalServerErr(err,
+		return nil, prov, errs.InternalServerErr(err,
 			errs.WithKeyVal("csr", csr),
 			errs.WithKeyVal("signOptions", signOpts),
 			errs.WithMessage("error creating certificate"),
 		)
 	}
 
-	// Send certificate to webhooks for authorization
-	if err := callAuthorizingWebhooksX509(webhookCtl, cert, leaf, attData); err != nil {
-		return nil, errs.ApplyOptions(
-			errs.ForbiddenErr(err, "error creating certificate"),
-			opts...,
-		)
+	// Check if the key was used by a different identity
+	var provisionerID string
+	if pInfo != nil {
+		provisionerID = pInfo.ID
 	}
-
-	// Sign certificate
-	lifetime := leaf.NotAfter.Sub(leaf.NotBefore.Add(signOpts.Backdate))
-	resp, err := a.x509CAService.CreateCertificate(&casapi.CreateCertificateRequest{
-		Template:    leaf,
-		CSR:         csr,
-		Lifetime:    lifetime,
-		Backdate:    signOpts.Backdate,
-		Provisioner: pInfo,
-	})
-	if err != nil {
-		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.Sign; error creating certificate", opts...)
+	if identity == "" {
+		identity = certificateIdentity(leaf)
 	}
-
-	fullchain := append([]*x509.Certificate{resp.Certificate}, resp.CertificateChain...)
-
-	// Wrap provisioner with extra information.
-	prov = wrapProvisioner(prov, attData)
-
-	// Store certificate in the db.
-	if err = a.storeCertificate(prov, fullchain); err != nil {
-		if !errors.Is(err, db.ErrNotImplemented) {
-			return nil, errs.Wrap(http.StatusInternalServerError, err,
-				"authority.Sign; error storing certificate in db", opts...)
+	if err := a.checkDuplicateKey(provisionerID, identity, leaf); err != nil {
+		var kpErr *keypolicy.Error
+		if errors.As(err, &kpErr) {
+			return nil, prov, errs.ApplyOptions(
+				errs.ForbiddenErr(err, err.Error()),
+				opts...,
+			)
 		}
-	}
-
-	return fullchain, nil
+		return nil, prov, errs.Wrap(http.StatusInternalServerError, err,
+			"authority.Sign; error checking key identity", opts...)
+	}
+
+	return &renderedX509{
+		cert:          cert,
+		leaf:          leaf,
+		prov:          prov,
+		pInfo:         pInfo,
+		attData:       attData,
+		webhookCtl:    webhookCtl,
+		provisionerID: provisionerID,
+		identity:      identity,
+		signOpts:      signOpts,
+		opts:          opts,
+	}, prov, nil
 }
 
 // isAllowedToSignX509Certificate checks if the Authority is allowed
@@ -341,6 +499,16 @@ func (a *Authority) RenewContext(ctx context.Context, oldCert *x509.Certificate,
 	opts := []errs.Option{
 		errs.WithKeyVal("serialNumber", oldCert.SerialNumber.String()),
 	}
+	if err := a.checkDecommissioned(); err != nil {
+		return nil, err
+	}
+
+	// Check the new key of a rekey.
+	if isRekey {
+		if err := a.keyPolicy.Validate(pk); err != nil {
+			return nil, errs.StatusCodeError(http.StatusForbidden, err, opts...)
+		}
+	}
 
 	// Check step provisioner extensions
 	if err := a.authorizeRenew(ctx, oldCert); err != nil {
@@ -398,10 +566,20 @@ func (a *Authority) RenewContext(ctx context.Context, oldCert *x509.Certificate,
 	//  2. Subject Key Identifier, if rekey - For rekey, SubjectKeyIdentifier
 	//  extension will be calculated for the new public key by
 	//  x509util.CreateCertificate()
+	//
+	//  3. Provisioner, authority information access and CRL distribution
+	//  points, if the certificate was issued by a federated peer - They are
+	//  replaced by the ones of this authority.
+	peer := a.getRenewalPeer(ctx, oldCert)
 	for _, ext := range oldCert.Extensions {
 		if ext.Id.Equal(oidAuthorityKeyIdentifier) {
 			continue
 		}
+		if peer != nil && (ext.Id.Equal(provisioner.StepOIDProvisioner) ||
+			ext.Id.Equal(oidExtensionAuthorityInfoAccess) ||
+			ext.Id.Equal(oidExtensionCRLDistributionPoints)) {
+			continue
+		}
 		if ext.Id.Equal(oidSubjectKeyIdentifier) && isRekey {
 			newCert.SubjectKeyId = nil
 			continue
@@ -409,6 +587,27 @@ func (a *Authority) RenewContext(ctx context.Context, oldCert *x509.Certificate,
 		newCert.ExtraExtensions = append(newCert.ExtraExtensions, ext)
 	}
 
+	var peerProvisioner provisioner.Interface
+	if peer != nil {
+		p, err := a.loadRenewalPeerProvisioner(peer)
+		if err != nil {
+			return nil, errs.StatusCodeError(http.StatusUnauthorized, err, opts...)
+		}
+		ext, err := (&provisioner.Extension{
+			Type: p.GetType(),
+			Name: p.GetName(),
+		}).ToExtension()
+		if err != nil {
+			return nil, errs.StatusCodeError(http.StatusInternalServerError, err, opts...)
+		}
+		newCert.ExtraExtensions = append(newCert.ExtraExtensions, ext)
+		newCert.OCSPServer = nil
+		newCert.IssuingCertificateURL = nil
+		newCert.CRLDistributionPoints = nil
+		a.addAuthorityInformationAccess(newCert)
+		peerProvisioner = p
+	}
+
 	// Check if the certificate is allowed to be renewed, name constraints might
 	// change over time.
 	//
@@ -434,18 +633,36 @@ func (a *Authority) RenewContext(ctx context.Context, oldCert *x509.Certificate,
 		Lifetime: lifetime,
 		Backdate: backdate,
 		Token:    token,
+		Context:  ctx,
 	})
 	if err != nil {
-		return nil, errs.StatusCodeError(http.StatusInternalServerError, err, opts...)
+		status, code := signErrorStatus(err)
+		return nil, errs.StatusCodeError(status, err, append(opts, code)...)
 	}
 
 	fullchain := append([]*x509.Certificate{resp.Certificate}, resp.CertificateChain...)
-	if err = a.storeRenewedCertificate(oldCert, fullchain); err != nil {
+	if peerProvisioner != nil {
+		// The old certificate is not in the database.
+		err = a.storeCertificate(peerProvisioner, fullchain)
+	} else {
+		err = a.storeRenewedCertificate(oldCert, fullchain)
+	}
+	if err != nil {
 		if !errors.Is(err, db.ErrNotImplemented) {
 			return nil, errs.StatusCodeError(http.StatusInternalServerError, err, opts...)
 		}
 	}
 
+	if err = a.auditX509Renew(oldCert, resp.Certificate); err != nil {
+		return nil, errs.StatusCodeError(http.StatusInternalServerError, err, opts...)
+	}
+	a.publishX509Renew(oldCert, resp.Certificate)
+	if peerProvisioner != nil {
+		a.publishCertificate(peerProvisioner, fullchain)
+	} else if p, err := a.LoadProvisionerByCertificate(oldCert); err == nil {
+		a.publishCertificate(p, fullchain)
+	}
+
 	return fullchain, nil
 }
 
@@ -615,6 +832,11 @@ func (a *Authority) Revoke(ctx context.Context, revokeOpts *RevokeOptions) error
 		if err := a.revokeSSH(nil, rci); err != nil {
 			return failRevoke(err)
 		}
+		if err := a.auditRevoke(rci, true); err != nil {
+			return errs.Wrap(http.StatusInternalServerError, err, "authority.Revoke; error writing audit log", opts...)
+		}
+		a.publishRevocation(rci, true)
+		a.publishRevoke(rci, true)
 	} else {
 		// Revoke an X.509 certificate using CAS. If the certificate is not
 		// provided we will try to read it from the db. If the read fails we
@@ -635,6 +857,7 @@ func (a *Authority) Revoke(ctx context.Context, revokeOpts *RevokeOptions) error
 			Reason:       rci.Reason,
 			ReasonCode:   rci.ReasonCode,
 			PassiveOnly:  revokeOpts.PassiveOnly,
+			Context:      ctx,
 		})
 		if err != nil {
 			return errs.Wrap(http.StatusInternalServerError, err, "authority.Revoke", opts...)
@@ -644,6 +867,24 @@ func (a *Authority) Revoke(ctx context.Context, revokeOpts *RevokeOptions) error
 		if err := a.revoke(revokedCert, rci); err != nil {
 			return failRevoke(err)
 		}
+		// Remove the cached OCSP response with a good status.
+		if err := a.invalidateOCSPResponse(rci.Serial); err != nil {
+			return errs.Wrap(http.StatusInternalServerError, err, "authority.Revoke; error invalidating ocsp response", opts...)
+		}
+		if err := a.auditRevoke(rci, false); err != nil {
+			return errs.Wrap(http.StatusInternalServerError, err, "authority.Revoke; error writing audit log", opts...)
+		}
+		a.publishRevocation(rci, false)
+		a.publishRevoke(rci, false)
+
+		// Do not allow new certificates with a compromised key.
+		if err := a.blockCompromisedKey(revokedCert, rci); err != nil {
+			return errs.Wrap(http.StatusInternalServerError, err, "authority.Revoke; error blocking compromised key", opts...)
+		}
+		// Do not allow new certificates for the identities of the certificate.
+		if err := a.denyRevokedIdentities(revokedCert, rci); err != nil {
+			return errs.Wrap(http.StatusInternalServerError, err, "authority.Revoke; error denying revoked identities", opts...)
+		}
 
 		// Generate a new CRL so CRL requesters will always get an up-to-date
 		// CRL whenever they request it.
@@ -762,6 +1003,11 @@ func (a *Authority) GenerateCertificateRevocationList() error {
 	} else if crlInfo != nil {
 		updateDuration = crlInfo.Duration
 	}
+	// The final CRL of a decommissioned authority is valid until the
+	// configured time.
+	if nextUpdate, ok := a.decommissionCRLNextUpdate(now); ok {
+		updateDuration = nextUpdate.Sub(now)
+	}
 
 	// Create a RevocationList representation ready for the CAS to sign
 	// TODO: allow SignatureAlgorithm to be specified?
@@ -790,9 +1036,20 @@ func (a *Authority) GenerateCertificateRevocationList() error {
 		}
 	}
 
-	certificateRevocationList, err := caCRLGenerator.CreateCRL(&casapi.CreateCRLRequest{RevocationList: &revocationList})
-	if err != nil {
-		return errors.Wrap(err, "could not create CRL")
+	// Sign the CRL with the delegated signer if enabled, or with the CAS.
+	var crlDER, signerDER []byte
+	if a.config.DelegatedSigners.IsEnabled() && a.config.DelegatedSigners.CRL {
+		b, signer, err := a.createDelegatedCRL(&revocationList)
+		if err != nil {
+			return errors.Wrap(err, "could not create CRL")
+		}
+		crlDER, signerDER = b, signer.Raw
+	} else {
+		certificateRevocationList, err := caCRLGenerator.CreateCRL(&casapi.CreateCRLRequest{RevocationList: &revocationList})
+		if err != nil {
+			return errors.Wrap(err, "could not create CRL")
+		}
+		crlDER = certificateRevocationList.CRL
 	}
 
 	// Create a new db.CertificateRevocationListInfo, which stores the new Number we just generated, the
@@ -800,8 +1057,9 @@ func (a *Authority) GenerateCertificateRevocationList() error {
 	newCRLInfo := db.CertificateRevocationListInfo{
 		Number:    bn.Int64(),
 		ExpiresAt: revocationList.NextUpdate,
-		DER:       certificateRevocationList.CRL,
+		DER:       crlDER,
 		Duration:  updateDuration,
+		SignerDER: signerDER,
 	}
 
 	// Store the CRL in the database ready for retrieval by api endpoints
@@ -952,7 +1210,7 @@ func templatingError(err error) error {
 	return errors.Wrap(cause, "error applying certificate template")
 }
 
-func callEnrichingWebhooksX509(webhookCtl webhookController, attData *provisioner.AttestationData, csr *x509.CertificateRequest) error {
+func callEnrichingWebhooksX509(ctx context.Context, webhookCtl webhookController, attData *provisioner.AttestationData, csr *x509.CertificateRequest) error {
 	if webhookCtl == nil {
 		return nil
 	}
@@ -969,10 +1227,10 @@ func callEnrichingWebhooksX509(webhookCtl webhookController, attData *provisione
 	if err != nil {
 		return err
 	}
-	return webhookCtl.Enrich(whEnrichReq)
+	return webhookCtl.Enrich(ctx, whEnrichReq)
 }
 
-func callAuthorizingWebhooksX509(webhookCtl webhookController, cert *x509util.Certificate, leaf *x509.Certificate, attData *provisioner.AttestationData) error {
+func callAuthorizingWebhooksX509(ctx context.Context, webhookCtl webhookController, cert *x509util.Certificate, leaf *x509.Certificate, attData *provisioner.AttestationData) error {
 	if webhookCtl == nil {
 		return nil
 	}
@@ -989,5 +1247,5 @@ func callAuthorizingWebhooksX509(webhookCtl webhookController, cert *x509util.Ce
 	if err != nil {
 		return err
 	}
-	return webhookCtl.Authorize(whAuthBody)
+	return webhookCtl.Authorize(ctx, whAuthBody)
 }
@@ -612,6 +612,22 @@ ZYtQ9Ot36qc=
 				extensionsCount: 6,
 			}
 		},
+		"ok with clamped validity": func(t *testing.T) *signTest {
+			csr := getCSR(t, priv)
+			_signOpts := provisioner.SignOptions{
+				NotBefore: provisioner.NewTimeDuration(nb),
+				Validity:  time.Hour * 25,
+			}
+			return &signTest{
+				auth:            a,
+				csr:             csr,
+				extraOpts:       extraOpts,
+				signOpts:        _signOpts,
+				notBefore:       nb.UTC().Truncate(time.Second),
+				notAfter:        nb.UTC().Add(time.Hour * 24).Truncate(time.Second),
+				extensionsCount: 6,
+			}
+		},
 		"ok with enforced modifier": func(t *testing.T) *signTest {
 			bcExt := pkix.Extension{}
 			bcExt.Id = asn1.ObjectIdentifier{2, 5, 29, 19}
@@ -933,6 +949,53 @@ ZYtQ9Ot36qc=
 	}
 }
 
+func TestAuthority_Sign_keyUsages(t *testing.T) {
+	_, priv, err := keyutil.GenerateDefaultKeyPair()
+	assert.FatalError(t, err)
+
+	a := testAuthority(t)
+	p := a.config.AuthorityConfig.Provisioners[1].(*provisioner.JWK)
+	p.Options = &provisioner.Options{X509: &provisioner.X509Options{
+		AllowedExtKeyUsages: x509util.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}}
+	key, err := jose.ReadKey("testdata/secrets/step_cli_key_priv.jwk", jose.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+
+	sign := func(so provisioner.SignOptions) ([]*x509.Certificate, error) {
+		token, err := generateToken("smallstep test", "step-cli", testAudiences.Sign[0], []string{"test.smallstep.com"}, time.Now(), key)
+		assert.FatalError(t, err)
+		ctx := provisioner.NewContextWithMethod(context.Background(), provisioner.SignMethod)
+		extraOpts, err := a.Authorize(ctx, token)
+		assert.FatalError(t, err)
+		return a.Sign(getCSR(t, priv), so, extraOpts...)
+	}
+
+	// Narrow the usages of the template.
+	certs, err := sign(provisioner.SignOptions{
+		ExtKeyUsage: x509util.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	assert.FatalError(t, err)
+	assert.Equals(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, certs[0].ExtKeyUsage)
+	assert.Equals(t, x509.KeyUsageDigitalSignature, certs[0].KeyUsage&x509.KeyUsageDigitalSignature)
+
+	// Extend them with the usages allowed by the provisioner.
+	certs, err = sign(provisioner.SignOptions{
+		ExtKeyUsage: x509util.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageEmailProtection},
+	})
+	assert.FatalError(t, err)
+	assert.Equals(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageEmailProtection}, certs[0].ExtKeyUsage)
+
+	// Other usages are forbidden.
+	_, err = sign(provisioner.SignOptions{
+		KeyUsage: x509util.KeyUsage(x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign),
+	})
+	var sc render.StatusCodedError
+	if assert.True(t, errors.As(err, &sc)) {
+		assert.Equals(t, http.StatusForbidden, sc.StatusCode())
+	}
+	assert.HasPrefix(t, err.Error(), "key usage certsign is not allowed")
+}
+
 func TestAuthority_Renew(t *testing.T) {
 	a := testAuthority(t)
 	a.config.AuthorityConfig.Template = &ASN1DN{
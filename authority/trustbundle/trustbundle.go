@@ -0,0 +1,281 @@
+// Package trustbundle generates the files used to install the root
+// certificates of the CA in the trust stores of the different platforms.
+package trustbundle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"go.mozilla.org/pkcs7"
+)
+
+// Supported formats of the bundles.
+const (
+	// MobileConfig is an Apple configuration profile for macOS and iOS.
+	MobileConfig = "mobileconfig"
+	// P7B is a PKCS #7 certificates file that can be imported in Windows.
+	P7B = "p7b"
+	// Shell is a shell script that installs the roots in the trust store of
+	// the Linux distributions using update-ca-certificates or update-ca-trust.
+	Shell = "sh"
+)
+
+// Formats is the list of supported formats.
+var Formats = []string{MobileConfig, P7B, Shell}
+
+// Bundle is a file with the root certificates ready to be installed.
+type Bundle struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// New returns the bundle with the given roots in the given format. The name
+// is used to identify the bundle, for example in the name of the profile.
+func New(format, name string, roots []*x509.Certificate) (*Bundle, error) {
+	if len(roots) == 0 {
+		return nil, errors.New("trust bundle requires at least one root certificate")
+	}
+	if name == "" {
+		name = "Step CA"
+	}
+	switch format {
+	case MobileConfig:
+		b, err := newMobileConfig(name, roots)
+		if err != nil {
+			return nil, err
+		}
+		return &Bundle{
+			Filename:    slug(name) + ".mobileconfig",
+			ContentType: "application/x-apple-aspen-config",
+			Data:        b,
+		}, nil
+	case P7B:
+		var der []byte
+		for _, crt := range roots {
+			der = append(der, crt.Raw...)
+		}
+		b, err := pkcs7.DegenerateCertificate(der)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating pkcs7 bundle")
+		}
+		return &Bundle{
+			Filename:    slug(name) + ".p7b",
+			ContentType: "application/x-pkcs7-certificates",
+			Data:        b,
+		}, nil
+	case Shell:
+		b, err := newShellScript(name, roots)
+		if err != nil {
+			return nil, err
+		}
+		return &Bundle{
+			Filename:    "install-" + slug(name) + ".sh",
+			ContentType: "text/x-shellscript",
+			Data:        b,
+		}, nil
+	default:
+		return nil, errors.Errorf("trust bundle format %q is not supported", format)
+	}
+}
+
+var mobileConfigTemplate = template.Must(template.New("mobileconfig").Funcs(template.FuncMap{
+	"xml": escapeXML,
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadContent</key>
+	<array>
+{{- range .Roots }}
+		<dict>
+			<key>PayloadCertificateFileName</key>
+			<string>{{ xml .Filename }}</string>
+			<key>PayloadContent</key>
+			<data>{{ .Data }}</data>
+			<key>PayloadDescription</key>
+			<string>Adds a root certificate</string>
+			<key>PayloadDisplayName</key>
+			<string>{{ xml .Name }}</string>
+			<key>PayloadIdentifier</key>
+			<string>com.apple.security.root.{{ .UUID }}</string>
+			<key>PayloadType</key>
+			<string>com.apple.security.root</string>
+			<key>PayloadUUID</key>
+			<string>{{ .UUID }}</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+		</dict>
+{{- end }}
+	</array>
+	<key>PayloadDescription</key>
+	<string>Installs the root certificates of {{ xml .Name }}</string>
+	<key>PayloadDisplayName</key>
+	<string>{{ xml .Name }}</string>
+	<key>PayloadIdentifier</key>
+	<string>sm.step.ca.trust.{{ .UUID }}</string>
+	<key>PayloadRemovalDisallowed</key>
+	<false/>
+	<key>PayloadType</key>
+	<string>Configuration</string>
+	<key>PayloadUUID</key>
+	<string>{{ .UUID }}</string>
+	<key>PayloadVersion</key>
+	<integer>1</integer>
+</dict>
+</plist>
+`))
+
+type mobileConfigRoot struct {
+	Name     string
+	Filename string
+	Data     string
+	UUID     string
+}
+
+// newMobileConfig returns an unsigned configuration profile with a
+// certificate payload for each root. The identifiers of the payloads are
+// derived from the certificates, so installing the profile again with the
+// same roots replaces the previous one.
+func newMobileConfig(name string, roots []*x509.Certificate) ([]byte, error) {
+	data := struct {
+		Name  string
+		UUID  string
+		Roots []mobileConfigRoot
+	}{Name: name}
+
+	// The profile and the root payloads need different identifiers, even if
+	// there is only one root.
+	h := sha256.New()
+	h.Write([]byte("Configuration"))
+	for _, crt := range roots {
+		h.Write(crt.Raw)
+		sum := sha256.Sum256(crt.Raw)
+		rootName := crt.Subject.CommonName
+		if rootName == "" {
+			rootName = name
+		}
+		data.Roots = append(data.Roots, mobileConfigRoot{
+			Name:     rootName,
+			Filename: hex.EncodeToString(sum[:8]) + ".crt",
+			Data:     base64.StdEncoding.EncodeToString(crt.Raw),
+			UUID:     newUUID(sum[:]),
+		})
+	}
+	data.UUID = newUUID(h.Sum(nil))
+
+	var buf bytes.Buffer
+	if err := mobileConfigTemplate.Execute(&buf, data); err != nil {
+		return nil, errors.Wrap(err, "error creating configuration profile")
+	}
+	return buf.Bytes(), nil
+}
+
+var shellTemplate = template.Must(template.New("sh").Parse(`#!/bin/sh
+# Installs the root certificates of {{ .Name }} in the trust store of the
+# system. It must be run as root.
+set -e
+
+if command -v update-ca-certificates >/dev/null 2>&1; then
+	dir=/usr/local/share/ca-certificates
+	update="update-ca-certificates"
+elif command -v update-ca-trust >/dev/null 2>&1; then
+	if [ -d /etc/ca-certificates/trust-source/anchors ]; then
+		dir=/etc/ca-certificates/trust-source/anchors
+	else
+		dir=/etc/pki/ca-trust/source/anchors
+	fi
+	update="update-ca-trust extract"
+else
+	echo "update-ca-certificates or update-ca-trust is required" >&2
+	exit 1
+fi
+
+mkdir -p "$dir"
+{{- range .Roots }}
+cat > "$dir/{{ .Filename }}" <<'EOF'
+{{ .PEM -}}
+EOF
+{{- end }}
+$update
+`))
+
+// newShellScript returns a script that writes each root certificate in PEM
+// format in the anchors directory of the distribution and updates the trust
+// store.
+func newShellScript(name string, roots []*x509.Certificate) ([]byte, error) {
+	type root struct {
+		Filename string
+		PEM      string
+	}
+	data := struct {
+		Name  string
+		Roots []root
+	}{Name: strings.ReplaceAll(name, "\n", " ")}
+
+	prefix := slug(name)
+	for _, crt := range roots {
+		sum := sha256.Sum256(crt.Raw)
+		data.Roots = append(data.Roots, root{
+			Filename: prefix + "-" + hex.EncodeToString(sum[:8]) + ".crt",
+			PEM: string(pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE",
+				Bytes: crt.Raw,
+			})),
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := shellTemplate.Execute(&buf, data); err != nil {
+		return nil, errors.Wrap(err, "error creating install script")
+	}
+	return buf.Bytes(), nil
+}
+
+// newUUID formats the first 16 bytes of a hash as a name-based UUID.
+func newUUID(sum []byte) string {
+	b := make([]byte, 16)
+	copy(b, sum)
+	b[6] = (b[6] & 0x0f) | 0x50
+	b[8] = (b[8] & 0x3f) | 0x80
+	return strings.ToUpper(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]))
+}
+
+// slug returns the name in lowercase with the characters other than letters
+// and digits replaced by dashes. It is used in the names of the files.
+func slug(name string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			sb.WriteRune(r)
+			dash = false
+		case !dash && sb.Len() > 0:
+			sb.WriteByte('-')
+			dash = true
+		}
+	}
+	s := strings.TrimSuffix(sb.String(), "-")
+	if s == "" {
+		return "ca"
+	}
+	return s
+}
+
+func escapeXML(s string) (string, error) {
+	var buf bytes.Buffer
+	if err := xml.EscapeText(&buf, []byte(s)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
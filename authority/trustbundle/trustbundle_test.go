@@ -0,0 +1,171 @@
+package trustbundle
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math/big"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mozilla.org/pkcs7"
+)
+
+func newRoot(t *testing.T, cn string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+func sha256Sum(b []byte) []byte {
+	sum := sha256.Sum256(b)
+	return sum[:]
+}
+
+func TestNew(t *testing.T) {
+	roots := []*x509.Certificate{newRoot(t, "Root & CA"), newRoot(t, "")}
+
+	t.Run(MobileConfig, func(t *testing.T) {
+		b, err := New(MobileConfig, "Smallstep <Test>", roots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Filename != "smallstep-test.mobileconfig" || b.ContentType != "application/x-apple-aspen-config" {
+			t.Errorf("New() = %s, %s", b.Filename, b.ContentType)
+		}
+		dec := xml.NewDecoder(bytes.NewReader(b.Data))
+		dec.Strict = false
+		for {
+			if _, err := dec.Token(); err != nil {
+				if !errors.Is(err, io.EOF) {
+					t.Fatalf("mobileconfig is not valid xml: %v", err)
+				}
+				break
+			}
+		}
+		s := string(b.Data)
+		for _, want := range []string{
+			base64.StdEncoding.EncodeToString(roots[0].Raw),
+			base64.StdEncoding.EncodeToString(roots[1].Raw),
+			"<string>Root &amp; CA</string>",
+			"<string>Smallstep &lt;Test&gt;</string>",
+		} {
+			if !strings.Contains(s, want) {
+				t.Errorf("mobileconfig does not contain %q:\n%s", want, s)
+			}
+		}
+		if n := strings.Count(s, newUUID(sha256Sum(roots[0].Raw))); n != 2 {
+			t.Errorf("mobileconfig contains the identifier of the first root %d times, want 2", n)
+		}
+		// The identifiers only depend on the roots.
+		other, err := New(MobileConfig, "Smallstep <Test>", roots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b.Data, other.Data) {
+			t.Error("mobileconfig is not deterministic")
+		}
+	})
+
+	t.Run(P7B, func(t *testing.T) {
+		b, err := New(P7B, "Smallstep", roots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Filename != "smallstep.p7b" || b.ContentType != "application/x-pkcs7-certificates" {
+			t.Errorf("New() = %s, %s", b.Filename, b.ContentType)
+		}
+		p7, err := pkcs7.Parse(b.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p7.Certificates) != 2 || !p7.Certificates[0].Equal(roots[0]) || !p7.Certificates[1].Equal(roots[1]) {
+			t.Errorf("p7b certificates do not match")
+		}
+	})
+
+	t.Run(Shell, func(t *testing.T) {
+		b, err := New(Shell, "", roots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Filename != "install-step-ca.sh" || b.ContentType != "text/x-shellscript" {
+			t.Errorf("New() = %s, %s", b.Filename, b.ContentType)
+		}
+		s := string(b.Data)
+		for _, crt := range roots {
+			if !strings.Contains(s, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw}))+"EOF\n") {
+				t.Errorf("script does not contain the root %s:\n%s", crt.Subject, s)
+			}
+		}
+		if sh, err := exec.LookPath("sh"); err == nil {
+			cmd := exec.Command(sh, "-n")
+			cmd.Stdin = bytes.NewReader(b.Data)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("script is not valid: %v\n%s", err, out)
+			}
+		}
+	})
+
+	if _, err := New("jks", "Smallstep", roots); err == nil {
+		t.Error("New() with an unsupported format expected an error")
+	}
+	if _, err := New(P7B, "Smallstep", nil); err == nil {
+		t.Error("New() without roots expected an error")
+	}
+}
+
+func Test_slug(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Step Online CA", "step-online-ca"},
+		{"  Acme, Inc. ", "acme-inc"},
+		{"Ünïcode", "n-code"},
+		{"***", "ca"},
+	}
+	for _, tt := range tests {
+		if got := slug(tt.name); got != tt.want {
+			t.Errorf("slug(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_newUUID(t *testing.T) {
+	sum := bytes.Repeat([]byte{0xff}, 32)
+	if got := newUUID(sum); got != "FFFFFFFF-FFFF-5FFF-BFFF-FFFFFFFFFFFF" {
+		t.Errorf("newUUID() = %s", got)
+	}
+}
@@ -0,0 +1,100 @@
+package authority
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net/http"
+
+	"github.com/pkg/errors"
+	kmsapi "go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/pemutil"
+
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/tsa"
+)
+
+// initTSA loads the key and certificate of the time-stamp authority.
+func (a *Authority) initTSA() error {
+	if !a.config.TSA.IsEnabled() {
+		return nil
+	}
+
+	chain, err := pemutil.ReadCertificateBundle(a.config.TSA.Certificate)
+	if err != nil {
+		return errors.Wrap(err, "error reading tsa certificate")
+	}
+	signer, err := a.keyManager.CreateSigner(&kmsapi.CreateSignerRequest{
+		SigningKey: a.config.TSA.Key,
+		Password:   a.password,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error creating tsa signer")
+	}
+	if err := a.checkOfflineRootKey(signer.Public(), "tsa key"); err != nil {
+		return err
+	}
+	policies, err := a.config.TSA.ParsePolicies()
+	if err != nil {
+		return err
+	}
+	opts := tsa.Options{Policies: policies}
+	if a.config.TSA.Accuracy != nil {
+		opts.Accuracy = a.config.TSA.Accuracy.Duration
+	}
+	if a.timestampAuthority, err = tsa.New(signer, chain, opts); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Timestamp returns the DER encoded RFC 3161 TimeStampResp for the given DER
+// encoded TimeStampReq.
+func (a *Authority) Timestamp(req []byte) ([]byte, error) {
+	if a.timestampAuthority == nil {
+		return nil, errs.Wrap(http.StatusNotFound, errors.New("time-stamp authority is not enabled"), "authority.Timestamp")
+	}
+	resp, err := a.timestampAuthority.Respond(req)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.Timestamp")
+	}
+	return resp, nil
+}
+
+var (
+	oidExtensionSubjectInfoAccess = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 11}
+	oidAccessMethodTimeStamping   = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 3}
+)
+
+type accessDescription struct {
+	Method   asn1.ObjectIdentifier
+	Location asn1.RawValue
+}
+
+// addTimestampingLinkage adds the location of the time-stamp authority in the
+// subject information access extension of code signing certificates, as
+// defined in RFC 3161, section 2.2. It does nothing if the time-stamp
+// authority is not enabled, if it does not have a public URL, or if the
+// template already has the extension.
+func (a *Authority) addTimestampingLinkage(leaf *x509.Certificate) error {
+	if a.timestampAuthority == nil || a.config.TSA.URL == "" || !hasExtKeyUsage(leaf, x509.ExtKeyUsageCodeSigning) {
+		return nil
+	}
+	for _, ext := range leaf.ExtraExtensions {
+		if ext.Id.Equal(oidExtensionSubjectInfoAccess) {
+			return nil
+		}
+	}
+	b, err := asn1.Marshal([]accessDescription{{
+		Method:   oidAccessMethodTimeStamping,
+		Location: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 6, Bytes: []byte(a.config.TSA.URL)},
+	}})
+	if err != nil {
+		return errors.Wrap(err, "error marshaling subject information access extension")
+	}
+	leaf.ExtraExtensions = append(leaf.ExtraExtensions, pkix.Extension{
+		Id:    oidExtensionSubjectInfoAccess,
+		Value: b,
+	})
+	return nil
+}
@@ -0,0 +1,65 @@
+package authority
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/tsa"
+)
+
+func TestAuthority_addTimestampingLinkage(t *testing.T) {
+	codeSigning := func() *x509.Certificate {
+		return &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}}
+	}
+	withTSA := func(u string) *Authority {
+		return &Authority{
+			config:             &config.Config{TSA: &config.TSAConfig{Enabled: true, URL: u}},
+			timestampAuthority: &tsa.TSA{},
+		}
+	}
+	sia := asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 11}
+
+	t.Run("ok", func(t *testing.T) {
+		leaf := codeSigning()
+		assert.NoError(t, withTSA("http://ca.smallstep.com/tsa").addTimestampingLinkage(leaf))
+		if assert.Len(t, 1, leaf.ExtraExtensions) {
+			assert.Equals(t, sia, leaf.ExtraExtensions[0].Id)
+			var ads []accessDescription
+			_, err := asn1.Unmarshal(leaf.ExtraExtensions[0].Value, &ads)
+			assert.FatalError(t, err)
+			assert.Equals(t, []accessDescription{{
+				Method: oidAccessMethodTimeStamping,
+				Location: asn1.RawValue{
+					Class: asn1.ClassContextSpecific, Tag: 6,
+					Bytes:     []byte("http://ca.smallstep.com/tsa"),
+					FullBytes: append([]byte{0x86, 27}, "http://ca.smallstep.com/tsa"...),
+				},
+			}}, ads)
+		}
+	})
+
+	t.Run("ok/skip", func(t *testing.T) {
+		tls := &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}
+		existing := codeSigning()
+		existing.ExtraExtensions = []pkix.Extension{{Id: sia, Value: []byte{0x30, 0x00}}}
+		tests := []struct {
+			name string
+			a    *Authority
+			leaf *x509.Certificate
+			want int
+		}{
+			{"disabled", &Authority{config: &config.Config{}}, codeSigning(), 0},
+			{"no-url", withTSA(""), codeSigning(), 0},
+			{"tls", withTSA("http://ca.smallstep.com/tsa"), tls, 0},
+			{"existing", withTSA("http://ca.smallstep.com/tsa"), existing, 1},
+		}
+		for _, tt := range tests {
+			assert.NoError(t, tt.a.addTimestampingLinkage(tt.leaf))
+			assert.Len(t, tt.want, tt.leaf.ExtraExtensions, tt.name)
+		}
+	})
+}
@@ -0,0 +1,199 @@
+package authority
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"time"
+
+	"github.com/smallstep/nosql"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/authority/report"
+	"github.com/smallstep/certificates/db"
+)
+
+// CertificateValidation is the result of the validation of a certificate
+// presented to the authority. It reports if the authority issued it, its
+// current status, the provisioner and the template it was issued with, and
+// the checks against the current policies of the authority.
+type CertificateValidation struct {
+	Valid       bool                   `json:"valid"`
+	Issued      bool                   `json:"issued"`
+	Certificate *report.Certificate    `json:"certificate"`
+	Template    string                 `json:"template,omitempty"`
+	Revocation  *CertificateRevocation `json:"revocation,omitempty"`
+	Checks      []*CertificateCheck    `json:"checks"`
+}
+
+// CertificateRevocation contains the details of the revocation of a
+// certificate.
+type CertificateRevocation struct {
+	RevokedAt  time.Time `json:"revokedAt,omitempty"`
+	ReasonCode int       `json:"reasonCode"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// CertificateCheck is the result of one of the validations of a certificate.
+type CertificateCheck struct {
+	Name  string `json:"name"`
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// ValidateCertificateChain validates the first certificate in the chain,
+// using the rest of the chain and the intermediates of the authority to build
+// the path to one of the roots of the authority. The certificate is only
+// reported as issued by the authority if it chains to one of its roots and,
+// if the database stores the certificates, the stored one is the same.
+//
+// Besides the revocation status and the validity period, the certificate is
+// checked against the current policy, name constraints and compliance profile
+// of the authority, so a certificate issued before a change of any of them is
+// reported as invalid.
+func (a *Authority) ValidateCertificateChain(chain []*x509.Certificate) (*CertificateValidation, error) {
+	if len(chain) == 0 {
+		return nil, admin.NewError(admin.ErrorBadRequestType, "certificate chain cannot be empty")
+	}
+	leaf := chain[0]
+	sn := leaf.SerialNumber.String()
+
+	cv := &CertificateValidation{
+		Checks: []*CertificateCheck{},
+	}
+	check := func(name string, err error) bool {
+		c := &CertificateCheck{Name: name, Valid: err == nil}
+		if err != nil {
+			c.Error = err.Error()
+		}
+		cv.Checks = append(cv.Checks, c)
+		return c.Valid
+	}
+
+	roots := x509.NewCertPool()
+	for _, crt := range a.rootX509Certs {
+		roots.AddCert(crt)
+	}
+	intermediates := x509.NewCertPool()
+	for _, crt := range a.intermediateX509Certs {
+		intermediates.AddCert(crt)
+	}
+	for _, crt := range chain[1:] {
+		intermediates.AddCert(crt)
+	}
+	// The chain of expired or not yet valid certificates is verified at the
+	// closest time within the validity period, the expiration is reported by
+	// its own check.
+	now := time.Now()
+	verifyTime := now
+	switch {
+	case now.Before(leaf.NotBefore):
+		verifyTime = leaf.NotBefore
+	case now.After(leaf.NotAfter):
+		verifyTime = leaf.NotAfter
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   verifyTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	issued := check("chain", err)
+
+	// Certificates are not stored if the database is disabled.
+	stored, err := a.db.GetCertificate(sn)
+	switch {
+	case errors.Is(err, db.ErrNotImplemented):
+	case nosql.IsErrNotFound(err):
+		issued = check("stored", boolErr(false, "certificate %s is not in the database", sn)) && issued
+	case err != nil:
+		return nil, admin.WrapErrorISE(err, "error loading certificate %s", sn)
+	default:
+		issued = check("stored", boolErr(bytes.Equal(stored.Raw, leaf.Raw),
+			"certificate %s does not match the one in the database", sn)) && issued
+	}
+	cv.Issued = issued
+
+	valid := issued
+	valid = check("expiry", boolErr(!now.Before(leaf.NotBefore) && now.Before(leaf.NotAfter),
+		"certificate is only valid from %s to %s", leaf.NotBefore.UTC().Format(time.RFC3339),
+		leaf.NotAfter.UTC().Format(time.RFC3339))) && valid
+
+	revoked, err := a.IsRevoked(sn)
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error checking revocation of certificate %s", sn)
+	}
+	valid = check("revocation", boolErr(!revoked, "certificate has been revoked")) && valid
+	if revoked {
+		cv.Revocation = a.getCertificateRevocation(sn)
+	}
+
+	valid = check("policy", a.policyEngine.IsX509CertificateAllowed(leaf)) && valid
+	valid = check("constraints", a.constraintsEngine.ValidateCertificate(leaf)) && valid
+
+	name, typ := a.getCertificateProvisioner(leaf)
+	cv.Certificate = report.NewCertificate(leaf, name, typ)
+	if p, ok := a.loadCertificateProvisioner(name, leaf); ok {
+		cv.Template = provisionerTemplate(p)
+		valid = check("compliance", a.checkCompliance(p, leaf)) && valid
+	}
+
+	switch {
+	case revoked:
+		cv.Certificate.Status = report.StatusRevoked
+	case !now.Before(leaf.NotAfter):
+		cv.Certificate.Status = report.StatusExpired
+	case issued:
+		cv.Certificate.Status = report.StatusValid
+	}
+	cv.Valid = valid
+	return cv, nil
+}
+
+// getCertificateRevocation returns the details of the revocation of a
+// certificate, or an empty revocation if the database does not store them.
+func (a *Authority) getCertificateRevocation(serialNumber string) *CertificateRevocation {
+	type revokedCertificateGetter interface {
+		GetRevokedCertificate(string) (*db.RevokedCertificateInfo, error)
+	}
+	if rcg, ok := a.db.(revokedCertificateGetter); ok {
+		if rci, err := rcg.GetRevokedCertificate(serialNumber); err == nil && rci != nil {
+			return &CertificateRevocation{
+				RevokedAt:  rci.RevokedAt,
+				ReasonCode: rci.ReasonCode,
+				Reason:     rci.Reason,
+			}
+		}
+	}
+	return &CertificateRevocation{}
+}
+
+// loadCertificateProvisioner loads the provisioner that issued a certificate,
+// by the name stored in the database or by the provisioner extension.
+func (a *Authority) loadCertificateProvisioner(name string, crt *x509.Certificate) (provisioner.Interface, bool) {
+	a.adminMutex.RLock()
+	defer a.adminMutex.RUnlock()
+	if name != "" {
+		if p, ok := a.provisioners.LoadByName(name); ok {
+			return p, true
+		}
+	}
+	return a.provisioners.LoadByCertificate(crt)
+}
+
+// provisionerTemplate returns the X.509 template configured in a provisioner:
+// the template file, "custom" for an inline template, or "default".
+func provisionerTemplate(p provisioner.Interface) string {
+	if po, ok := p.(interface{ GetOptions() *provisioner.Options }); ok {
+		if o := po.GetOptions().GetX509Options(); o != nil {
+			switch {
+			case o.TemplateFile != "":
+				return o.TemplateFile
+			case o.Template != "":
+				return "custom"
+			}
+		}
+	}
+	return "default"
+}
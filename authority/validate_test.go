@@ -0,0 +1,163 @@
+package authority
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/nosql/database"
+	"go.step.sm/crypto/minica"
+
+	"github.com/smallstep/certificates/authority/policy"
+	"github.com/smallstep/certificates/authority/report"
+	"github.com/smallstep/certificates/db"
+)
+
+func TestAuthority_ValidateCertificateChain(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	other, err := minica.New()
+	assert.FatalError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+
+	// Backdate the authority so it can sign certificates that are already
+	// expired.
+	now := time.Now()
+	tmpl := *ca.Root
+	tmpl.NotBefore = now.Add(-24 * time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, ca.RootSigner.Public(), ca.RootSigner)
+	assert.FatalError(t, err)
+	ca.Root, err = x509.ParseCertificate(der)
+	assert.FatalError(t, err)
+	tmpl = *ca.Intermediate
+	tmpl.NotBefore = now.Add(-24 * time.Hour)
+	der, err = x509.CreateCertificate(rand.Reader, &tmpl, ca.Root, ca.Signer.Public(), ca.RootSigner)
+	assert.FatalError(t, err)
+	ca.Intermediate, err = x509.ParseCertificate(der)
+	assert.FatalError(t, err)
+
+	sign := func(ca *minica.CA, sn int64, notBefore, notAfter time.Time) *x509.Certificate {
+		t.Helper()
+		crt, err := ca.Sign(&x509.Certificate{
+			SerialNumber: big.NewInt(sn),
+			Subject:      pkix.Name{CommonName: "test.smallstep.com"},
+			DNSNames:     []string{"test.smallstep.com"},
+			NotBefore:    notBefore,
+			NotAfter:     notAfter,
+			PublicKey:    key.Public(),
+		})
+		assert.FatalError(t, err)
+		return crt
+	}
+	valid := sign(ca, 1, now.Add(-time.Hour), now.Add(time.Hour))
+	revoked := sign(ca, 2, now.Add(-time.Hour), now.Add(time.Hour))
+	expired := sign(ca, 3, now.Add(-2*time.Hour), now.Add(-time.Hour))
+	missing := sign(ca, 4, now.Add(-time.Hour), now.Add(time.Hour))
+	foreign := sign(other, 1, now.Add(-time.Hour), now.Add(time.Hour))
+
+	stored := map[string]*x509.Certificate{"1": valid, "2": revoked, "3": expired}
+	revokedAt := now.Add(-time.Minute).UTC().Truncate(time.Second)
+	ldb := &lifecycleDB{
+		MockAuthDB: &db.MockAuthDB{
+			MGetCertificate: func(sn string) (*x509.Certificate, error) {
+				if crt, ok := stored[sn]; ok {
+					return crt, nil
+				}
+				return nil, database.ErrNotFound
+			},
+			MGetCertificateData: func(sn string) (*db.CertificateData, error) {
+				return &db.CertificateData{Provisioner: &db.ProvisionerData{Name: "step-cli", Type: "JWK"}}, nil
+			},
+			MIsRevoked: func(sn string) (bool, error) {
+				return sn == "2", nil
+			},
+		},
+		revoked: map[string]*db.RevokedCertificateInfo{
+			"2": {Serial: "2", ReasonCode: 1, Reason: "key compromise", RevokedAt: revokedAt},
+		},
+	}
+	a := testAuthority(t)
+	a.db = ldb
+	a.rootX509Certs = []*x509.Certificate{ca.Root}
+	a.intermediateX509Certs = []*x509.Certificate{ca.Intermediate}
+
+	failed := func(cv *CertificateValidation) []string {
+		names := []string{}
+		for _, c := range cv.Checks {
+			if !c.Valid {
+				names = append(names, c.Name)
+			}
+		}
+		return names
+	}
+
+	// Valid certificate.
+	cv, err := a.ValidateCertificateChain([]*x509.Certificate{valid})
+	assert.FatalError(t, err)
+	assert.True(t, cv.Valid)
+	assert.True(t, cv.Issued)
+	assert.Equals(t, []string{}, failed(cv))
+	assert.Equals(t, report.StatusValid, cv.Certificate.Status)
+	assert.Equals(t, "step-cli", cv.Certificate.ProvisionerName)
+	assert.Equals(t, "default", cv.Template)
+	assert.Nil(t, cv.Revocation)
+
+	// Revoked certificate.
+	cv, err = a.ValidateCertificateChain([]*x509.Certificate{revoked, ca.Intermediate})
+	assert.FatalError(t, err)
+	assert.False(t, cv.Valid)
+	assert.True(t, cv.Issued)
+	assert.Equals(t, []string{"revocation"}, failed(cv))
+	assert.Equals(t, report.StatusRevoked, cv.Certificate.Status)
+	assert.Equals(t, &CertificateRevocation{RevokedAt: revokedAt, ReasonCode: 1, Reason: "key compromise"}, cv.Revocation)
+
+	// Expired certificate.
+	cv, err = a.ValidateCertificateChain([]*x509.Certificate{expired})
+	assert.FatalError(t, err)
+	assert.False(t, cv.Valid)
+	assert.True(t, cv.Issued)
+	assert.Equals(t, []string{"expiry"}, failed(cv))
+	assert.Equals(t, report.StatusExpired, cv.Certificate.Status)
+
+	// Certificate signed by the authority but not in the database.
+	cv, err = a.ValidateCertificateChain([]*x509.Certificate{missing})
+	assert.FatalError(t, err)
+	assert.False(t, cv.Valid)
+	assert.False(t, cv.Issued)
+	assert.Equals(t, []string{"stored"}, failed(cv))
+	assert.Equals(t, "", cv.Certificate.Status)
+
+	// Certificate signed by another authority, with the serial number of a
+	// stored one.
+	cv, err = a.ValidateCertificateChain([]*x509.Certificate{foreign, other.Intermediate})
+	assert.FatalError(t, err)
+	assert.False(t, cv.Valid)
+	assert.False(t, cv.Issued)
+	assert.Equals(t, []string{"chain", "stored"}, failed(cv))
+
+	// Certificate not allowed by the current policy.
+	engine, err := policy.New(&policy.Options{
+		X509: &policy.X509PolicyOptions{
+			DeniedNames: &policy.X509NameOptions{DNSDomains: []string{"test.smallstep.com"}},
+		},
+	})
+	assert.FatalError(t, err)
+	a.policyEngine = engine
+	cv, err = a.ValidateCertificateChain([]*x509.Certificate{valid})
+	assert.FatalError(t, err)
+	assert.False(t, cv.Valid)
+	assert.True(t, cv.Issued)
+	assert.Equals(t, []string{"policy"}, failed(cv))
+	assert.Equals(t, report.StatusValid, cv.Certificate.Status)
+
+	// Empty chain.
+	_, err = a.ValidateCertificateChain(nil)
+	assert.Error(t, err)
+}
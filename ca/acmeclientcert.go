@@ -0,0 +1,61 @@
+package ca
+
+import (
+	"crypto/x509"
+	"net/http"
+)
+
+// acmeClientCertificates removes the client certificates issued by the roots
+// of the ACME provisioners from the requests that are not ACME requests. These
+// roots are trusted in the TLS handshake so the ACME provisioners can require
+// client certificates from them, but other endpoints, like the renewal one,
+// must only see the certificates issued by the CA or its federated peers.
+type acmeClientCertificates struct {
+	roots map[string]bool
+}
+
+// newACMEClientCertificates returns the middleware for the given ACME client
+// certificate roots. The roots that are also trusted by the CA are ignored. It
+// returns nil if there are no roots left.
+func newACMEClientCertificates(roots, trusted []*x509.Certificate) *acmeClientCertificates {
+	isTrusted := make(map[string]bool, len(trusted))
+	for _, crt := range trusted {
+		isTrusted[string(crt.Raw)] = true
+	}
+	m := &acmeClientCertificates{roots: make(map[string]bool)}
+	for _, crt := range roots {
+		if !isTrusted[string(crt.Raw)] {
+			m.roots[string(crt.Raw)] = true
+		}
+	}
+	if len(m.roots) == 0 {
+		return nil
+	}
+	return m
+}
+
+// Middleware removes the client certificates that are only verified by ACME
+// client certificate roots from the requests that are not ACME requests.
+func (m *acmeClientCertificates) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && !isACMERequest(r) && m.onlyACMEChains(r.TLS.VerifiedChains) {
+			cs := *r.TLS
+			cs.PeerCertificates = nil
+			cs.VerifiedChains = nil
+			r = r.Clone(r.Context())
+			r.TLS = &cs
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// onlyACMEChains returns true if all the chains end in an ACME client
+// certificate root.
+func (m *acmeClientCertificates) onlyACMEChains(chains [][]*x509.Certificate) bool {
+	for _, chain := range chains {
+		if len(chain) == 0 || !m.roots[string(chain[len(chain)-1].Raw)] {
+			return false
+		}
+	}
+	return true
+}
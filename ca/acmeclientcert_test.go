@@ -0,0 +1,54 @@
+package ca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACMEClientCertificates_Middleware(t *testing.T) {
+	caRoot := &x509.Certificate{Raw: []byte("ca root")}
+	acmeRoot := &x509.Certificate{Raw: []byte("acme root")}
+	leaf := &x509.Certificate{Raw: []byte("leaf")}
+
+	assert.Nil(t, newACMEClientCertificates(nil, []*x509.Certificate{caRoot}))
+	assert.Nil(t, newACMEClientCertificates([]*x509.Certificate{caRoot}, []*x509.Certificate{caRoot}))
+	m := newACMEClientCertificates([]*x509.Certificate{acmeRoot, caRoot}, []*x509.Certificate{caRoot})
+	require.NotNil(t, m)
+
+	tests := []struct {
+		name     string
+		path     string
+		chains   [][]*x509.Certificate
+		wantPeer bool
+	}{
+		{"acme", "/acme/mtls/new-order", [][]*x509.Certificate{{leaf, acmeRoot}}, true},
+		{"acme 2.0", "/2.0/acme/mtls/new-order", [][]*x509.Certificate{{leaf, acmeRoot}}, true},
+		{"renew acme", "/renew", [][]*x509.Certificate{{leaf, acmeRoot}}, false},
+		{"renew ca", "/renew", [][]*x509.Certificate{{leaf, caRoot}}, true},
+		{"renew both", "/renew", [][]*x509.Certificate{{leaf, acmeRoot}, {leaf, caRoot}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+			}))
+			req := httptest.NewRequest("POST", tt.path, http.NoBody)
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}, VerifiedChains: tt.chains}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			require.NotNil(t, got)
+			require.NotNil(t, got.TLS)
+			assert.Equal(t, tt.wantPeer, len(got.TLS.PeerCertificates) > 0)
+			assert.Equal(t, tt.wantPeer, len(got.TLS.VerifiedChains) > 0)
+			// The connection state of the original request is not modified.
+			assert.Len(t, req.TLS.PeerCertificates, 1)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package ca
+
+import (
+	"context"
+	"crypto/x509"
+	"time"
+
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/activity"
+	"github.com/smallstep/certificates/monitoring"
+)
+
+// acmeMeter is the acme.Meter of the CA. It records the metrics of the ACME
+// server, if they are enabled, and publishes the new accounts, the validation
+// attempts of the challenges and the changes of the orders in the activity
+// events.
+type acmeMeter struct {
+	metrics *monitoring.Metrics
+	auth    *authority.Authority
+}
+
+func newACMEMeter(metrics *monitoring.Metrics, auth *authority.Authority) *acmeMeter {
+	return &acmeMeter{
+		metrics: metrics,
+		auth:    auth,
+	}
+}
+
+func (m *acmeMeter) AccountCreated(ctx context.Context, acc *acme.Account) {
+	id, name := acmeProvisioner(ctx)
+	if m.metrics != nil {
+		m.metrics.ACMEAccountCreated(name)
+	}
+	data := &activity.ACMEAccountData{
+		AccountID: acc.ID,
+		Contact:   acc.Contact,
+	}
+	if acc.Key != nil {
+		data.KeyThumbprint, _ = acme.KeyToID(acc.Key)
+	}
+	m.auth.PublishEvent(&activity.Event{
+		Type:            activity.ACMEAccountType,
+		ProvisionerID:   id,
+		ProvisionerName: name,
+		Data:            data,
+	})
+}
+
+func (m *acmeMeter) ChallengeValidated(ctx context.Context, ch *acme.Challenge, d time.Duration, err error) {
+	id, name := acmeProvisioner(ctx)
+	outcome := string(ch.Status)
+	if err != nil {
+		outcome = "error"
+	}
+	if m.metrics != nil {
+		m.metrics.ACMEChallengeValidated(name, string(ch.Type), outcome, d)
+	}
+	data := &activity.ACMEChallengeData{
+		AccountID:       ch.AccountID,
+		AuthorizationID: ch.AuthorizationID,
+		ChallengeID:     ch.ID,
+		Type:            string(ch.Type),
+		Identifier:      ch.Value,
+		Status:          string(ch.Status),
+		Duration:        d.Seconds(),
+	}
+	if ch.Error != nil {
+		data.ProblemType, data.ProblemDetail = ch.Error.Type, ch.Error.Detail
+	}
+	if err != nil {
+		data.Error = err.Error()
+	}
+	m.auth.PublishEvent(&activity.Event{
+		Type:            activity.ACMEChallengeType,
+		ProvisionerID:   id,
+		ProvisionerName: name,
+		Data:            data,
+	})
+}
+
+func (m *acmeMeter) OrderFinalized(ctx context.Context, o *acme.Order, d time.Duration, err error) {
+	if m.metrics == nil {
+		return
+	}
+	_, name := acmeProvisioner(ctx)
+	outcome := string(o.Status)
+	if err != nil {
+		outcome = "error"
+	}
+	m.metrics.ACMEOrderFinalized(name, outcome, d)
+}
+
+func (m *acmeMeter) OrderChanged(ctx context.Context, o *acme.Order, cert *acme.Certificate) {
+	var typ string
+	switch o.Status {
+	case acme.StatusPending:
+		typ = activity.ACMEOrderCreatedType
+	case acme.StatusReady:
+		typ = activity.ACMEOrderValidatedType
+	case acme.StatusValid:
+		typ = activity.ACMEOrderFinalizedType
+	case acme.StatusInvalid:
+		typ = activity.ACMEOrderFailedType
+	default:
+		return
+	}
+
+	data := &activity.ACMEOrderData{
+		AccountID:   o.AccountID,
+		OrderID:     o.ID,
+		Status:      string(o.Status),
+		Identifiers: make([]activity.ACMEIdentifier, len(o.Identifiers)),
+		ExpiresAt:   o.ExpiresAt,
+		NotBefore:   o.NotBefore,
+		NotAfter:    o.NotAfter,
+	}
+	for i, id := range o.Identifiers {
+		data.Identifiers[i] = activity.ACMEIdentifier{Type: string(id.Type), Value: id.Value}
+	}
+	switch {
+	case cert == nil:
+	case cert.SSH != nil:
+		data.SerialNumber = cert.SerialNumber()
+		data.IssuedNames = cert.SSH.ValidPrincipals
+		data.NotBefore = time.Unix(int64(cert.SSH.ValidAfter), 0).UTC()
+		data.NotAfter = time.Unix(int64(cert.SSH.ValidBefore), 0).UTC()
+	case cert.Leaf != nil:
+		data.SerialNumber = cert.SerialNumber()
+		data.IssuedNames = x509Names(cert.Leaf)
+		data.NotBefore, data.NotAfter = cert.Leaf.NotBefore, cert.Leaf.NotAfter
+	}
+	if o.Error != nil {
+		data.ProblemType, data.ProblemDetail = o.Error.Type, o.Error.Detail
+	}
+
+	id, name := acmeProvisioner(ctx)
+	m.auth.PublishEvent(&activity.Event{
+		Type:            typ,
+		ProvisionerID:   id,
+		ProvisionerName: name,
+		Data:            data,
+	})
+}
+
+func (m *acmeMeter) DBError(_ context.Context, operation string, _ error) {
+	if m.metrics != nil {
+		m.metrics.DBError(operation)
+	}
+}
+
+// x509Names returns the SANs of a certificate.
+func x509Names(crt *x509.Certificate) []string {
+	names := append([]string{}, crt.DNSNames...)
+	for _, ip := range crt.IPAddresses {
+		names = append(names, ip.String())
+	}
+	names = append(names, crt.EmailAddresses...)
+	for _, u := range crt.URIs {
+		names = append(names, u.String())
+	}
+	return names
+}
+
+// acmeProvisioner returns the id and the name of the ACME provisioner in the
+// context.
+func acmeProvisioner(ctx context.Context) (string, string) {
+	if p, ok := acme.ProvisionerFromContext(ctx); ok {
+		return p.GetID(), p.GetName()
+	}
+	return "", "unknown"
+}
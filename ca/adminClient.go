@@ -1223,6 +1223,153 @@ retry:
 	return nil
 }
 
+// GetACMEAccountsPaginate returns a page from the GET
+// /admin/acme/accounts/{provisionerName} request to the CA. The filter can
+// contain the status, contact and keyID fields.
+func (c *AdminClient) GetACMEAccountsPaginate(provisionerName string, filter url.Values, opts ...AdminOption) (*adminAPI.GetACMEAccountsResponse, error) {
+	o := new(adminOptions)
+	if err := o.apply(opts); err != nil {
+		return nil, err
+	}
+	q, err := url.ParseQuery(o.rawQuery())
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range filter {
+		q[k] = v
+	}
+	u := c.endpoint.ResolveReference(&url.URL{
+		Path:     path.Join(adminURLPrefix, "acme/accounts", provisionerName),
+		RawQuery: q.Encode(),
+	})
+	var body = new(adminAPI.GetACMEAccountsResponse)
+	if err := c.doACMERequest("GET", u, nil, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// GetACMEAccount performs the GET /admin/acme/accounts/{provisionerName}/{id}
+// request to the CA.
+func (c *AdminClient) GetACMEAccount(provisionerName, id string) (*adminAPI.ACMEAccount, error) {
+	u := c.endpoint.ResolveReference(&url.URL{Path: path.Join(adminURLPrefix, "acme/accounts", provisionerName, id)})
+	var acc = new(adminAPI.ACMEAccount)
+	if err := c.doACMERequest("GET", u, nil, acc); err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// DisableACMEAccount performs the POST
+// /admin/acme/accounts/{provisionerName}/{id}/disable request to the CA.
+func (c *AdminClient) DisableACMEAccount(provisionerName, id string) (*adminAPI.ACMEAccount, error) {
+	u := c.endpoint.ResolveReference(&url.URL{Path: path.Join(adminURLPrefix, "acme/accounts", provisionerName, id, "disable")})
+	var acc = new(adminAPI.ACMEAccount)
+	if err := c.doACMERequest("POST", u, struct{}{}, acc); err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// GetACMEAccountOrders performs the GET
+// /admin/acme/accounts/{provisionerName}/{id}/orders request to the CA.
+func (c *AdminClient) GetACMEAccountOrders(provisionerName, id string) ([]*adminAPI.ACMEOrder, error) {
+	u := c.endpoint.ResolveReference(&url.URL{Path: path.Join(adminURLPrefix, "acme/accounts", provisionerName, id, "orders")})
+	var body = new(adminAPI.GetACMEOrdersResponse)
+	if err := c.doACMERequest("GET", u, nil, body); err != nil {
+		return nil, err
+	}
+	return body.Orders, nil
+}
+
+// GetACMEAccountAuthorizations performs the GET
+// /admin/acme/accounts/{provisionerName}/{id}/authorizations request to the
+// CA.
+func (c *AdminClient) GetACMEAccountAuthorizations(provisionerName, id string) ([]*adminAPI.ACMEAuthorization, error) {
+	u := c.endpoint.ResolveReference(&url.URL{Path: path.Join(adminURLPrefix, "acme/accounts", provisionerName, id, "authorizations")})
+	var body = new(adminAPI.GetACMEAuthorizationsResponse)
+	if err := c.doACMERequest("GET", u, nil, body); err != nil {
+		return nil, err
+	}
+	return body.Authorizations, nil
+}
+
+// InvalidateACMEAuthorization performs the POST
+// /admin/acme/accounts/{provisionerName}/{id}/authorizations/{authzID}/invalidate
+// request to the CA.
+func (c *AdminClient) InvalidateACMEAuthorization(provisionerName, id, authzID string) (*adminAPI.ACMEAuthorization, error) {
+	u := c.endpoint.ResolveReference(&url.URL{Path: path.Join(adminURLPrefix, "acme/accounts", provisionerName, id, "authorizations", authzID, "invalidate")})
+	var az = new(adminAPI.ACMEAuthorization)
+	if err := c.doACMERequest("POST", u, struct{}{}, az); err != nil {
+		return nil, err
+	}
+	return az, nil
+}
+
+// GetACMEAccountCertificates performs the GET
+// /admin/acme/accounts/{provisionerName}/{id}/certificates request to the CA.
+func (c *AdminClient) GetACMEAccountCertificates(provisionerName, id string) ([]*adminAPI.ACMECertificate, error) {
+	u := c.endpoint.ResolveReference(&url.URL{Path: path.Join(adminURLPrefix, "acme/accounts", provisionerName, id, "certificates")})
+	var body = new(adminAPI.GetACMECertificatesResponse)
+	if err := c.doACMERequest("GET", u, nil, body); err != nil {
+		return nil, err
+	}
+	return body.Certificates, nil
+}
+
+// RevokeACMECertificate performs the POST
+// /admin/acme/certificates/{provisionerName}/{serial}/revoke request to the
+// CA.
+func (c *AdminClient) RevokeACMECertificate(provisionerName, serial string, rr *adminAPI.RevokeACMECertificateRequest) error {
+	u := c.endpoint.ResolveReference(&url.URL{Path: path.Join(adminURLPrefix, "acme/certificates", provisionerName, serial, "revoke")})
+	return c.doACMERequest("POST", u, rr, nil)
+}
+
+// doACMERequest performs a request to the ACME admin endpoints. The body is
+// sent as JSON if it is not nil, and the response is decoded into v if it is
+// not nil.
+func (c *AdminClient) doACMERequest(method string, u *url.URL, body, v interface{}) error {
+	var (
+		retried bool
+		b       []byte
+	)
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return errs.Wrap(http.StatusInternalServerError, err, "error marshaling request")
+		}
+	}
+	tok, err := c.generateAdminToken(u)
+	if err != nil {
+		return errors.Wrapf(err, "error generating admin token")
+	}
+retry:
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrapf(err, "create %s %s request failed", method, u)
+	}
+	req.Header.Add("Authorization", tok)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return clientError(err)
+	}
+	if resp.StatusCode >= 400 {
+		if !retried && c.retryOnError(resp) {
+			retried = true
+			goto retry
+		}
+		return readAdminError(resp.Body)
+	}
+	if v == nil {
+		resp.Body.Close()
+		return nil
+	}
+	if err := readJSON(resp.Body, v); err != nil {
+		return errors.Wrapf(err, "error reading %s", u)
+	}
+	return nil
+}
+
 func readAdminError(r io.ReadCloser) error {
 	// TODO: not all errors can be read (i.e. 404); seems to be a bigger issue
 	defer r.Close()
@@ -21,31 +21,46 @@ import (
 	"github.com/smallstep/certificates/acme"
 	acmeAPI "github.com/smallstep/certificates/acme/api"
 	acmeNoSQL "github.com/smallstep/certificates/acme/db/nosql"
+	acmeSQL "github.com/smallstep/certificates/acme/db/sql"
 	"github.com/smallstep/certificates/api"
 	"github.com/smallstep/certificates/authority"
 	"github.com/smallstep/certificates/authority/admin"
 	adminAPI "github.com/smallstep/certificates/authority/admin/api"
 	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/report"
+	"github.com/smallstep/certificates/cache"
+	"github.com/smallstep/certificates/cas/acmecas"
+	"github.com/smallstep/certificates/certmanager"
 	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/idempotency"
+	"github.com/smallstep/certificates/kubernetes"
 	"github.com/smallstep/certificates/logging"
 	"github.com/smallstep/certificates/monitoring"
+	"github.com/smallstep/certificates/notify"
 	"github.com/smallstep/certificates/scep"
 	scepAPI "github.com/smallstep/certificates/scep/api"
+	"github.com/smallstep/certificates/sds"
 	"github.com/smallstep/certificates/server"
 	"github.com/smallstep/nosql"
 	"go.step.sm/cli-utils/step"
 	"go.step.sm/crypto/x509util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type options struct {
 	configFile      string
 	linkedCAToken   string
 	quiet           bool
+	selfTest        bool
 	password        []byte
 	issuerPassword  []byte
 	sshHostPassword []byte
 	sshUserPassword []byte
 	database        db.AuthDB
+	cache           cache.Store
+	metrics         *monitoring.Metrics
+	inflight        *inflight
 }
 
 func (o *options) apply(opts []Option) {
@@ -118,6 +133,38 @@ func WithQuiet(quiet bool) Option {
 	}
 }
 
+// WithSelfTest runs the self-test of the authority after its initialization.
+// The CA is not created if the self-test fails.
+func WithSelfTest(selfTest bool) Option {
+	return func(o *options) {
+		o.selfTest = selfTest
+	}
+}
+
+// withMetrics sets the metrics gathered by the CA. It's used to keep the
+// metrics on reloads.
+func withMetrics(m *monitoring.Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// withCache sets the store used for the ephemeral state. It's used to keep the
+// state on reloads.
+func withCache(s cache.Store) Option {
+	return func(o *options) {
+		o.cache = s
+	}
+}
+
+// withInflight sets the tracker of the requests being served. It's used to
+// wait for the requests served before a reload on shutdown.
+func withInflight(f *inflight) Option {
+	return func(o *options) {
+		o.inflight = f
+	}
+}
+
 // CA is the type used to build the complete certificate authority. It builds
 // the HTTP server, set ups the middlewares and the HTTP handlers.
 type CA struct {
@@ -125,9 +172,17 @@ type CA struct {
 	config      *config.Config
 	srv         *server.Server
 	insecureSrv *server.Server
+	certManager *certmanager.Controller
+	sds         *sds.Server
+	sdsSrv      *grpc.Server
+	metricsSrv  *http.Server
 	opts        *options
 	renewer     *TLSRenewer
+	secrets     *secretStore
 	compactStop chan struct{}
+	starStop    chan struct{}
+	starMu      sync.Mutex
+	starContext context.Context
 }
 
 // New creates and initializes the CA with the given configuration and options.
@@ -136,8 +191,12 @@ func New(cfg *config.Config, opts ...Option) (*CA, error) {
 		config:      cfg,
 		opts:        new(options),
 		compactStop: make(chan struct{}),
+		starStop:    make(chan struct{}),
 	}
 	ca.opts.apply(opts)
+	if ca.opts.inflight == nil {
+		ca.opts.inflight = newInflight()
+	}
 	return ca.Init(cfg)
 }
 
@@ -159,10 +218,21 @@ func (ca *CA) Init(cfg *config.Config) (*CA, error) {
 		opts = append(opts, authority.WithDatabase(ca.opts.database))
 	}
 
+	if ca.opts.cache != nil {
+		opts = append(opts, authority.WithCache(ca.opts.cache))
+	}
+
 	if ca.opts.quiet {
 		opts = append(opts, authority.WithQuietInit())
 	}
 
+	if cfg.MetricsAddress != "" {
+		if ca.opts.metrics == nil {
+			ca.opts.metrics = monitoring.NewMetrics()
+		}
+		opts = append(opts, authority.WithMeter(ca.opts.metrics))
+	}
+
 	webhookTransport := http.DefaultTransport.(*http.Transport).Clone()
 	opts = append(opts, authority.WithWebhookClient(&http.Client{Transport: webhookTransport}))
 
@@ -172,6 +242,17 @@ func (ca *CA) Init(cfg *config.Config) (*CA, error) {
 	}
 	ca.auth = auth
 
+	if ca.opts.metrics != nil {
+		ca.opts.metrics.SetCAExpirations(caExpirations(auth))
+		if cfg.CertMetrics != nil {
+			ca.opts.metrics.SetIssuedCertificates(issuedCertificates(auth), monitoring.CertificateSeriesOptions{
+				Mode:      cfg.CertMetrics.GetMode(),
+				MaxSeries: cfg.CertMetrics.GetMaxSeries(),
+				Interval:  cfg.CertMetrics.GetInterval(),
+			})
+		}
+	}
+
 	tlsConfig, clientTLSConfig, err := ca.getTLSConfig(auth)
 	if err != nil {
 		return nil, err
@@ -179,6 +260,12 @@ func (ca *CA) Init(cfg *config.Config) (*CA, error) {
 
 	webhookTransport.TLSClientConfig = clientTLSConfig
 
+	if ca.opts.selfTest {
+		if err := runSelfTest(auth); err != nil {
+			return nil, err
+		}
+	}
+
 	// Using chi as the main router
 	mux := chi.NewRouter()
 	handler := http.Handler(mux)
@@ -200,6 +287,18 @@ func (ca *CA) Init(cfg *config.Config) (*CA, error) {
 	insecureMux.Get("/crl", api.CRL)
 	insecureMux.Get("/1.0/crl", api.CRL)
 
+	// Mount the time-stamp authority to the insecure mux
+	if cfg.TSA.IsEnabled() {
+		insecureMux.Post("/tsa", api.Timestamp)
+		insecureMux.Post("/1.0/tsa", api.Timestamp)
+	}
+
+	// Mount the http-01 challenges of the public certificate to the insecure
+	// mux
+	if cfg.PublicTLS.IsSelfSolving() {
+		insecureMux.Handle("/.well-known/acme-challenge/*", acmecas.HTTP01Handler())
+	}
+
 	// Add ACME api endpoints in /acme and /1.0/acme
 	dns := cfg.DNSNames[0]
 	u, err := url.Parse("https://" + cfg.Address)
@@ -215,10 +314,20 @@ func (ca *CA) Init(cfg *config.Config) (*CA, error) {
 	var acmeDB acme.DB
 	var acmeLinker acme.Linker
 	if cfg.DB != nil {
-		acmeDB, err = acmeNoSQL.New(auth.GetDatabase().(nosql.DB))
+		if sdb, ok := auth.GetDatabase().(*db.SQLDB); ok {
+			acmeDB, err = acmeSQL.New(sdb.DB(), sdb.Dialect())
+		} else {
+			acmeDB, err = acmeNoSQL.New(auth.GetDatabase().(nosql.DB))
+		}
 		if err != nil {
 			return nil, errors.Wrap(err, "error configuring ACME DB interface")
 		}
+		// Keep the nonces in the cache if configured.
+		if c := auth.GetCache(); c != nil {
+			acmeDB = acme.WithNonceStore(acmeDB, c, acme.DefaultNonceTTL)
+		}
+		// Report the database errors in the metrics.
+		acmeDB = acme.WithDBMeter(acmeDB)
 		acmeLinker = acme.NewLinker(dns, "acme")
 		mux.Route("/acme", func(r chi.Router) {
 			acmeAPI.Route(r)
@@ -282,6 +391,29 @@ func (ca *CA) Init(cfg *config.Config) (*CA, error) {
 	//dumpRoutes(mux)
 	//dumpRoutes(insecureMux)
 
+	// Hide the client certificates only trusted for the ACME provisioners
+	// from the rest of the endpoints.
+	var trusted []*x509.Certificate
+	trusted = append(trusted, auth.GetRootCertificates()...)
+	trusted = append(trusted, auth.GetFederatedRenewalRoots()...)
+	if m := newACMEClientCertificates(auth.GetACMEClientCertificateRoots(), trusted); m != nil {
+		handler = m.Middleware(handler)
+	}
+
+	// Refuse the requests that change the state of the CA in read-only mode
+	if cfg.Maintenance.IsEnabled() {
+		ro := newReadOnly(cfg.Maintenance)
+		handler = ro.Middleware(handler)
+		insecureHandler = ro.Middleware(insecureHandler)
+	}
+
+	// Prioritize renewals and OCSP over new enrollments when overloaded
+	if cfg.LoadShedding.IsEnabled() {
+		ls := newLoadShedder(cfg.LoadShedding, ca.opts.metrics)
+		handler = ls.Middleware(handler)
+		insecureHandler = ls.Middleware(insecureHandler)
+	}
+
 	// Add monitoring if configured
 	if len(cfg.Monitoring) > 0 {
 		m, err := monitoring.New(cfg.Monitoring)
@@ -302,8 +434,19 @@ func (ca *CA) Init(cfg *config.Config) (*CA, error) {
 		insecureHandler = logger.Middleware(insecureHandler)
 	}
 
+	// Keep track of the requests being served, so they can be drained on
+	// shutdown.
+	if ca.opts.inflight != nil {
+		handler = ca.opts.inflight.Middleware(handler)
+		insecureHandler = ca.opts.inflight.Middleware(insecureHandler)
+	}
+
 	// Create context with all the necessary values.
 	baseContext := buildContext(auth, scepAuthority, acmeDB, acmeLinker)
+	if acmeDB != nil {
+		baseContext = acme.NewMeterContext(baseContext, newACMEMeter(ca.opts.metrics, auth))
+		ca.setStarContext(baseContext)
+	}
 
 	ca.srv = server.New(cfg.Address, handler, tlsConfig)
 	ca.srv.BaseContext = func(net.Listener) context.Context {
@@ -323,13 +466,46 @@ func (ca *CA) Init(cfg *config.Config) (*CA, error) {
 		}
 	}
 
+	// only start the metrics server if an address is configured.
+	if cfg.MetricsAddress != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", ca.opts.metrics)
+		ca.metricsSrv = &http.Server{
+			Addr:              cfg.MetricsAddress,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: 15 * time.Second,
+		}
+	}
+
+	// only start the cert-manager issuer if a provisioner is configured.
+	if cfg.CertManager.IsEnabled() {
+		client, err := newKubernetesClient()
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating cert-manager issuer")
+		}
+		ca.certManager = certmanager.New(auth, client, kubernetes.IssuerRef{
+			Group: cfg.CertManager.GetGroup(),
+			Kind:  cfg.CertManager.GetKind(),
+			Name:  cfg.CertManager.GetName(),
+		})
+	}
+
+	// only start the secret discovery service if an address is configured.
+	if cfg.SDS.IsEnabled() {
+		ca.sds = sds.New(auth)
+		ca.sdsSrv = grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)), sds.ServerOption())
+		ca.sds.Register(ca.sdsSrv)
+	}
+
 	return ca, nil
 }
 
 // shouldServeInsecureServer returns whether or not the insecure
 // server should also be started. This is (currently) only the case
 // if the insecure address has been configured AND when a SCEP
-// provisioner is configured or when a CRL is configured.
+// provisioner is configured, when a CRL is configured, when the time-stamp
+// authority is enabled or when the CA solves the http-01 challenges of its
+// public certificate.
 func (ca *CA) shouldServeInsecureServer() bool {
 	switch {
 	case ca.config.InsecureAddress == "":
@@ -338,6 +514,10 @@ func (ca *CA) shouldServeInsecureServer() bool {
 		return true
 	case ca.config.CRL.IsEnabled():
 		return true
+	case ca.config.TSA.IsEnabled():
+		return true
+	case ca.config.PublicTLS.IsSelfSolving():
+		return true
 	default:
 		return false
 	}
@@ -352,19 +532,81 @@ func buildContext(a *authority.Authority, scepAuthority *scep.Authority, acmeDB
 	if adminDB := a.GetAdminDatabase(); adminDB != nil {
 		ctx = admin.NewContext(ctx, adminDB)
 	}
+	if notifier := a.GetNotifier(); notifier != nil {
+		ctx = notify.NewContext(ctx, notifier)
+	}
 	if scepAuthority != nil {
 		ctx = scep.NewContext(ctx, scepAuthority)
 	}
 	if acmeDB != nil {
-		ctx = acme.NewContext(ctx, acmeDB, acme.NewClient(), acmeLinker, nil)
+		ctx = acme.NewContext(ctx, acmeDB, acme.WithFaults(acme.NewClient(), a.GetChaosInjector().ValidationFaults()), acmeLinker, nil)
+	}
+	if cfg := a.GetConfig().Idempotency; cfg.IsEnabled() {
+		// Without a shared cache the responses are only kept in this instance.
+		store := a.GetCache()
+		if store == nil {
+			store = cache.NewMemory()
+		}
+		ctx = idempotency.NewContext(ctx, idempotency.New(store, cfg.GetWindow()))
 	}
 	return ctx
 }
 
+// caExpirations returns the function used by the metrics to get the
+// expiration of the CA certificates.
+func caExpirations(auth *authority.Authority) func() []monitoring.CertificateExpiry {
+	return func() []monitoring.CertificateExpiry {
+		var expirations []monitoring.CertificateExpiry
+		for _, e := range auth.GetCAExpirations() {
+			expirations = append(expirations, monitoring.CertificateExpiry{
+				Type:         e.Type,
+				Subject:      e.Subject,
+				SerialNumber: e.SerialNumber,
+				NotAfter:     e.NotAfter,
+			})
+		}
+		return expirations
+	}
+}
+
+// issuedCertificates returns the function used by the metrics to get the
+// valid certificates issued by the CA.
+func issuedCertificates(auth *authority.Authority) func() ([]monitoring.IssuedCertificate, error) {
+	return func() ([]monitoring.IssuedCertificate, error) {
+		certs, err := auth.ListCertificates()
+		if err != nil {
+			return nil, err
+		}
+		var issued []monitoring.IssuedCertificate
+		for _, c := range certs {
+			if c.Status != report.StatusValid {
+				continue
+			}
+			issued = append(issued, monitoring.IssuedCertificate{
+				SerialNumber: c.SerialNumber,
+				CommonName:   c.CommonName(),
+				SANs:         c.SANs,
+				Provisioner:  c.ProvisionerName,
+				NotBefore:    c.NotBefore,
+				NotAfter:     c.NotAfter,
+			})
+		}
+		return issued, nil
+	}
+}
+
 // Run starts the CA calling to the server ListenAndServe method.
 func (ca *CA) Run() error {
 	var wg sync.WaitGroup
-	errs := make(chan error, 1)
+	// buffered with a slot for each server, only the first error is read
+	// below, so the rest of the servers can always return
+	servers := 1
+	for _, started := range []bool{ca.insecureSrv != nil, ca.metricsSrv != nil, ca.sdsSrv != nil} {
+		if started {
+			servers++
+		}
+	}
+	errs := make(chan error, servers)
 
 	if !ca.opts.quiet {
 		authorityInfo := ca.auth.GetInfo()
@@ -401,6 +643,12 @@ func (ca *CA) Run() error {
 		ca.runCompactJob()
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ca.runStarJob()
+	}()
+
 	if ca.insecureSrv != nil {
 		wg.Add(1)
 		go func() {
@@ -409,6 +657,36 @@ func (ca *CA) Run() error {
 		}()
 	}
 
+	if ca.metricsSrv != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := ca.metricsSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+			}
+		}()
+	}
+
+	if ca.certManager != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ca.certManager.Run()
+		}()
+	}
+
+	if ca.sdsSrv != nil {
+		ln, err := net.Listen("tcp", ca.config.SDS.Address)
+		if err != nil {
+			return errors.Wrapf(err, "error listening on %s", ca.config.SDS.Address)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- ca.sdsSrv.Serve(ln)
+		}()
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -423,19 +701,65 @@ func (ca *CA) Run() error {
 	return err
 }
 
-// Stop stops the CA calling to the server Shutdown method.
+// Stop stops the CA calling to the server Shutdown method. It waits for the
+// in-flight requests up to the drain timeout in the configuration before
+// stopping the authority and closing the database.
 func (ca *CA) Stop() error {
+	timeout := ca.config.Shutdown.GetDrainTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Stop accepting new requests and wait for the in-flight ones, including
+	// signings, ACME validations and their database writes. The connections
+	// still active after the drain timeout are closed.
+	var (
+		wg                  sync.WaitGroup
+		insecureShutdownErr error
+		secureErr           error
+	)
+	if ca.insecureSrv != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			insecureShutdownErr = ca.insecureSrv.ShutdownContext(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		secureErr = ca.srv.ShutdownContext(ctx)
+	}()
+	// The secret discovery streams do not end, so they are closed.
+	if ca.sdsSrv != nil {
+		ca.sdsSrv.Stop()
+	}
+	wg.Wait()
+
+	// Handlers can keep running after their connections are closed, wait for
+	// them before closing the database.
+	if ca.opts.inflight != nil {
+		if err := ca.opts.inflight.Wait(ctx); err != nil {
+			log.Printf("error draining requests: %d requests still in flight after %s", ca.opts.inflight.Len(), timeout)
+		}
+	}
+
 	close(ca.compactStop)
+	close(ca.starStop)
+	if ca.certManager != nil {
+		ca.certManager.Stop()
+	}
 	ca.renewer.Stop()
+	if ca.secrets != nil {
+		ca.secrets.Stop()
+	}
+	if ca.metricsSrv != nil {
+		if err := ca.metricsSrv.Close(); err != nil {
+			log.Printf("error stopping metrics server: %v", err)
+		}
+	}
 	if err := ca.auth.Shutdown(); err != nil {
 		log.Printf("error stopping ca.Authority: %+v\n", err)
 	}
-	var insecureShutdownErr error
-	if ca.insecureSrv != nil {
-		insecureShutdownErr = ca.insecureSrv.Shutdown()
-	}
-
-	secureErr := ca.srv.Shutdown()
 
 	if insecureShutdownErr != nil {
 		return insecureShutdownErr
@@ -463,6 +787,12 @@ func (ca *CA) Reload() error {
 		return errors.New("error reloading ca: database configuration cannot change")
 	}
 
+	// Keep the ephemeral state if the cache configuration has not changed.
+	var reuseCache cache.Store
+	if reflect.DeepEqual(ca.config.Cache, cfg.Cache) {
+		reuseCache = ca.auth.GetCache()
+	}
+
 	newCA, err := New(cfg,
 		WithPassword(ca.opts.password),
 		WithSSHHostPassword(ca.opts.sshHostPassword),
@@ -472,6 +802,9 @@ func (ca *CA) Reload() error {
 		WithQuiet(ca.opts.quiet),
 		WithConfigFile(ca.opts.configFile),
 		WithDatabase(ca.auth.GetDatabase()),
+		withCache(reuseCache),
+		withMetrics(ca.opts.metrics),
+		withInflight(ca.opts.inflight),
 	)
 	if err != nil {
 		logContinue("Reload failed because the CA with new configuration could not be initialized.")
@@ -495,19 +828,63 @@ func (ca *CA) Reload() error {
 	// 3. Replace ca properties
 	// Do not replace ca.srv
 	ca.renewer.Stop()
+	if ca.secrets != nil {
+		ca.secrets.Stop()
+	}
 	ca.auth.CloseForReload()
+	if c := ca.auth.GetCache(); c != nil && reuseCache == nil {
+		if err := c.Close(); err != nil {
+			log.Printf("error closing the cache: %v", err)
+		}
+	}
+	if ca.certManager != nil {
+		ca.certManager.SetAuthority(newCA.auth)
+	}
+	if ca.sds != nil {
+		ca.sds.SetAuthority(newCA.auth)
+	}
 	ca.auth = newCA.auth
 	ca.config = newCA.config
 	ca.opts = newCA.opts
 	ca.renewer = newCA.renewer
+	ca.secrets = newCA.secrets
+	ca.setStarContext(newCA.getStarContext())
 	return nil
 }
 
 // get TLSConfig returns separate TLSConfigs for server and client with the
 // same self-renewing certificate.
 func (ca *CA) getTLSConfig(auth *authority.Authority) (*tls.Config, *tls.Config, error) {
-	// Create initial TLS certificate
-	tlsCrt, err := auth.GetTLSCertificate()
+	var (
+		tlsCrt  *tls.Certificate
+		getFn   RenewFunc = auth.GetTLSCertificate
+		renewFn RenewFunc = auth.GetTLSCertificate
+		err     error
+	)
+
+	// Order the certificate from an external ACME authority if configured.
+	if ca.config.PublicTLS.IsEnabled() {
+		store, err := newPublicTLS(ca.config)
+		if err != nil {
+			return nil, nil, err
+		}
+		getFn, renewFn = store.GetCertificate, store.Renew
+	}
+
+	// Create initial TLS certificate, loading it from a Kubernetes secret if
+	// configured.
+	if ca.config.Kubernetes.IsEnabled() {
+		if ca.secrets != nil {
+			ca.secrets.Stop()
+		}
+		if ca.secrets, err = newSecretStore(ca.config, auth.GetRootCertificates()); err != nil {
+			return nil, nil, err
+		}
+		renewFn = ca.secrets.RenewFunc(renewFn)
+		tlsCrt, err = ca.secrets.GetCertificate(getFn)
+	} else {
+		tlsCrt, err = getFn()
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -518,11 +895,14 @@ func (ca *CA) getTLSConfig(auth *authority.Authority) (*tls.Config, *tls.Config,
 		ca.renewer.Stop()
 	}
 
-	ca.renewer, err = NewTLSRenewer(tlsCrt, auth.GetTLSCertificate)
+	ca.renewer, err = NewTLSRenewer(tlsCrt, renewFn)
 	if err != nil {
 		return nil, nil, err
 	}
 	ca.renewer.Run()
+	if ca.secrets != nil {
+		ca.secrets.Watch(ca.renewer)
+	}
 
 	var serverTLSConfig *tls.Config
 	if ca.config.TLS != nil {
@@ -545,6 +925,9 @@ func (ca *CA) getTLSConfig(auth *authority.Authority) (*tls.Config, *tls.Config,
 	clientTLSConfig := serverTLSConfig.Clone()
 
 	serverTLSConfig.GetCertificate = ca.renewer.GetCertificateForCA
+	if ca.config.TLS != nil && ca.config.TLS.OCSPStapling {
+		serverTLSConfig.GetCertificate = newOCSPStapler(ca.renewer.GetCertificateForCA, auth.CreateOCSPResponse).GetCertificate
+	}
 	clientTLSConfig.GetClientCertificate = ca.renewer.GetClientCertificate
 
 	// initialize a certificate pool with root CA certificates to trust when doing mTLS.
@@ -560,16 +943,31 @@ func (ca *CA) getTLSConfig(auth *authority.Authority) (*tls.Config, *tls.Config,
 		rootCAsPool.AddCert(crt)
 	}
 
+	// trust the roots of the federated authorities that can renew their
+	// certificates with this one.
+	for _, crt := range auth.GetFederatedRenewalRoots() {
+		certPool.AddCert(crt)
+	}
+
+	// trust the roots of the client certificates required by the ACME
+	// provisioners, these certificates are only used in the ACME requests.
+	for _, crt := range auth.GetACMEClientCertificateRoots() {
+		certPool.AddCert(crt)
+	}
+
 	// adding the intermediate CA certificates to the pool will allow clients that
 	// do mTLS but don't send an intermediate to successfully connect. The intermediates
-	// added here are used when building a certificate chain.
+	// added here are used when building a certificate chain. The intermediates of
+	// a public certificate must not be trusted for client authentication.
 	intermediates := tlsCrt.Certificate[1:]
 	for _, certBytes := range intermediates {
 		cert, err := x509.ParseCertificate(certBytes)
 		if err != nil {
 			return nil, nil, err
 		}
-		certPool.AddCert(cert)
+		if !ca.config.PublicTLS.IsEnabled() {
+			certPool.AddCert(cert)
+		}
 		rootCAsPool.AddCert(cert)
 	}
 
@@ -616,7 +1014,11 @@ func (ca *CA) runCompactJob() {
 	if !ok {
 		return
 	}
-	compactor, ok := caDB.DB.(nosql.Compactor)
+	ndb := caDB.DB
+	if nsdb, ok := ndb.(*db.NamespacedDB); ok {
+		ndb = nsdb.Unwrap()
+	}
+	compactor, ok := ndb.(nosql.Compactor)
 	if !ok {
 		return
 	}
@@ -638,9 +1040,65 @@ func (ca *CA) runCompactJob() {
 	}
 }
 
+// setStarContext sets the context used to renew the certificates of the ACME
+// auto-renewal orders, it's replaced when the CA is reloaded.
+func (ca *CA) setStarContext(ctx context.Context) {
+	ca.starMu.Lock()
+	ca.starContext = ctx
+	ca.starMu.Unlock()
+}
+
+func (ca *CA) getStarContext() context.Context {
+	ca.starMu.Lock()
+	defer ca.starMu.Unlock()
+	return ca.starContext
+}
+
+// runStarJob renews the certificates of the ACME auto-renewal orders every
+// minute. Only the leader instance renews them.
+func (ca *CA) runStarJob() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ca.starStop:
+			return
+		case <-ticker.C:
+			ctx := ca.getStarContext()
+			if ctx == nil {
+				continue
+			}
+			auth := authority.MustFromContext(ctx)
+			if !auth.IsLeader() {
+				continue
+			}
+			if err := acme.RenewStarOrders(ctx, acme.MustDatabaseFromContext(ctx), auth); err != nil {
+				log.Printf("error renewing acme auto-renewal orders: %v", err)
+			}
+		}
+	}
+}
+
 // runCompact executes the compact job until it returns an error.
 func runCompact(c nosql.Compactor) {
 	for err := error(nil); err == nil; {
 		err = c.Compact(0.7)
 	}
 }
+
+// runSelfTest runs the self-test of the authority and logs the result of each
+// stage.
+func runSelfTest(auth *authority.Authority) error {
+	report, err := auth.SelfTest(context.Background(), "")
+	if err != nil {
+		return errors.Wrap(err, "error running self-test")
+	}
+	for _, stage := range report.Stages {
+		log.Printf("Self-test %s: %s %s", stage.Name, stage.Status, stage.Message)
+	}
+	if !report.OK {
+		return errors.New("self-test failed")
+	}
+	return nil
+}
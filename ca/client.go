@@ -29,6 +29,7 @@ import (
 	"github.com/smallstep/certificates/authority/provisioner"
 	"github.com/smallstep/certificates/ca/identity"
 	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/logging"
 	"go.step.sm/cli-utils/step"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/keyutil"
@@ -85,6 +86,7 @@ func (c *uaClient) GetWithContext(ctx context.Context, u string) (*http.Response
 		return nil, errors.Wrapf(err, "create GET %s request failed", u)
 	}
 	req.Header.Set("User-Agent", UserAgent)
+	logging.InjectTraceHeaders(ctx, req.Header)
 	return c.Client.Do(req)
 }
 
@@ -99,11 +101,13 @@ func (c *uaClient) PostWithContext(ctx context.Context, u, contentType string, b
 	}
 	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("User-Agent", UserAgent)
+	logging.InjectTraceHeaders(ctx, req.Header)
 	return c.Client.Do(req)
 }
 
 func (c *uaClient) Do(req *http.Request) (*http.Response, error) {
 	req.Header.Set("User-Agent", UserAgent)
+	logging.InjectTraceHeaders(req.Context(), req.Header)
 	return c.Client.Do(req)
 }
 
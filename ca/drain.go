@@ -0,0 +1,74 @@
+package ca
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// inflight keeps track of the requests being served, so the CA can wait for
+// them on shutdown before closing the database. Handlers can keep running
+// after their connections are closed, so waiting for the HTTP servers is not
+// enough.
+type inflight struct {
+	mu    sync.Mutex
+	count int
+	idle  chan struct{}
+}
+
+func newInflight() *inflight {
+	return &inflight{}
+}
+
+// Middleware tracks the requests served by the given handler.
+func (f *inflight) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.add()
+		defer f.done()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (f *inflight) add() {
+	f.mu.Lock()
+	f.count++
+	f.mu.Unlock()
+}
+
+func (f *inflight) done() {
+	f.mu.Lock()
+	f.count--
+	if f.count == 0 && f.idle != nil {
+		close(f.idle)
+		f.idle = nil
+	}
+	f.mu.Unlock()
+}
+
+// Len returns the number of requests being served.
+func (f *inflight) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.count
+}
+
+// Wait waits until all the requests are served or the context is done.
+func (f *inflight) Wait(ctx context.Context) error {
+	f.mu.Lock()
+	if f.count == 0 {
+		f.mu.Unlock()
+		return nil
+	}
+	if f.idle == nil {
+		f.idle = make(chan struct{})
+	}
+	idle := f.idle
+	f.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
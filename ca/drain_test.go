@@ -0,0 +1,49 @@
+package ca
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInflight(t *testing.T) {
+	f := newInflight()
+	require.NoError(t, f.Wait(context.Background()))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := f.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	served := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/1.0/sign", http.NoBody))
+		served <- w.Code
+	}()
+	<-started
+	assert.Equal(t, 1, f.Len())
+
+	// The request is still in flight.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, f.Wait(ctx), context.DeadlineExceeded)
+
+	// The request completes while waiting.
+	waitErr := make(chan error)
+	go func() {
+		waitErr <- f.Wait(context.Background())
+	}()
+	close(release)
+	assert.Equal(t, http.StatusNoContent, <-served)
+	assert.NoError(t, <-waitErr)
+	assert.Equal(t, 0, f.Len())
+}
@@ -0,0 +1,218 @@
+package ca
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/kubernetes"
+	"go.step.sm/crypto/pemutil"
+)
+
+// kubernetesTimeout is the timeout of the requests to the Kubernetes API.
+var kubernetesTimeout = 30 * time.Second
+
+// newKubernetesClient is the function used to create the client of the
+// Kubernetes API. It can be replaced in tests.
+var newKubernetesClient = kubernetes.NewInClusterClient
+
+// secretStore keeps the certificate of the CA server in a kubernetes.io/tls
+// secret, so it persists across restarts and it's shared between replicas,
+// and publishes the root certificates in another secret.
+type secretStore struct {
+	client      *kubernetes.Client
+	namespace   string
+	tlsSecret   string
+	rootsSecret string
+	dnsNames    []string
+	roots       []*x509.Certificate
+	cancel      context.CancelFunc
+}
+
+func newSecretStore(cfg *config.Config, roots []*x509.Certificate) (*secretStore, error) {
+	client, err := newKubernetesClient()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &secretStore{
+		client:      client,
+		namespace:   cfg.Kubernetes.Namespace,
+		tlsSecret:   cfg.Kubernetes.TLSSecret,
+		rootsSecret: cfg.Kubernetes.RootsSecret,
+		dnsNames:    cfg.DNSNames,
+		roots:       roots,
+		cancel:      func() {},
+	}
+	if err := s.publishRoots(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// publishRoots writes the root certificates in the ca.crt key of the roots
+// secret.
+func (s *secretStore) publishRoots() error {
+	if s.rootsSecret == "" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, crt := range s.roots {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw}); err != nil {
+			return errors.Wrap(err, "error encoding root certificate")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kubernetesTimeout)
+	defer cancel()
+	return errors.Wrapf(s.client.ApplySecret(ctx, &kubernetes.Secret{
+		Metadata: kubernetes.Metadata{Name: s.rootsSecret, Namespace: s.namespace},
+		Type:     "Opaque",
+		Data:     map[string][]byte{"ca.crt": buf.Bytes()},
+	}), "error writing secret %s", s.rootsSecret)
+}
+
+// GetCertificate returns the certificate stored in the TLS secret if it's
+// still valid, or creates and stores a new one using the given function.
+func (s *secretStore) GetCertificate(fn RenewFunc) (*tls.Certificate, error) {
+	if s.tlsSecret == "" {
+		return fn()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kubernetesTimeout)
+	defer cancel()
+	secret, err := s.client.GetSecret(ctx, s.namespace, s.tlsSecret)
+	switch {
+	case errors.Is(err, kubernetes.ErrNotFound):
+	case err != nil:
+		return nil, errors.Wrapf(err, "error reading secret %s", s.tlsSecret)
+	default:
+		cert, err := s.parseSecret(secret)
+		if err == nil {
+			return cert, nil
+		}
+		log.Printf("Ignoring certificate in secret %s: %v", s.tlsSecret, err)
+	}
+
+	return s.RenewFunc(fn)()
+}
+
+// RenewFunc returns a RenewFunc that stores the certificates created by the
+// given function in the TLS secret.
+func (s *secretStore) RenewFunc(fn RenewFunc) RenewFunc {
+	if s.tlsSecret == "" {
+		return fn
+	}
+	return func() (*tls.Certificate, error) {
+		cert, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.storeCertificate(cert); err != nil {
+			// The certificate is still usable by this instance.
+			log.Printf("error storing certificate in secret %s: %v", s.tlsSecret, err)
+		}
+		return cert, nil
+	}
+}
+
+// Watch starts watching the TLS secret, updating the certificate in the given
+// renewer when a newer one is stored, e.g. by another replica.
+func (s *secretStore) Watch(r *TLSRenewer) {
+	if s.tlsSecret == "" {
+		return
+	}
+
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	go s.client.WatchSecret(ctx, s.namespace, s.tlsSecret, func(secret *kubernetes.Secret) {
+		cert, err := s.parseSecret(secret)
+		if err != nil {
+			return
+		}
+		current := r.getCertificate()
+		if current.Leaf.SerialNumber.Cmp(cert.Leaf.SerialNumber) != 0 && cert.Leaf.NotAfter.After(current.Leaf.NotAfter) {
+			r.updateCertificate(cert)
+		}
+	})
+}
+
+// Stop stops watching the TLS secret.
+func (s *secretStore) Stop() {
+	s.cancel()
+}
+
+func (s *secretStore) storeCertificate(cert *tls.Certificate) error {
+	var chain bytes.Buffer
+	for _, b := range cert.Certificate {
+		if err := pem.Encode(&chain, &pem.Block{Type: "CERTIFICATE", Bytes: b}); err != nil {
+			return errors.Wrap(err, "error encoding certificate")
+		}
+	}
+	block, err := pemutil.Serialize(cert.PrivateKey, pemutil.WithPKCS8(true))
+	if err != nil {
+		return errors.Wrap(err, "error encoding private key")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kubernetesTimeout)
+	defer cancel()
+	return s.client.ApplySecret(ctx, &kubernetes.Secret{
+		Metadata: kubernetes.Metadata{Name: s.tlsSecret, Namespace: s.namespace},
+		Type:     "kubernetes.io/tls",
+		Data: map[string][]byte{
+			"tls.crt": chain.Bytes(),
+			"tls.key": pem.EncodeToMemory(block),
+		},
+	})
+}
+
+// parseSecret returns the certificate in the given secret. The certificate
+// must be signed by the current roots, include the configured DNS names, and
+// not be past the point where it would be renewed.
+func (s *secretStore) parseSecret(secret *kubernetes.Secret) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(secret.Data["tls.crt"], secret.Data["tls.key"])
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	for _, crt := range s.roots {
+		roots.AddCert(crt)
+	}
+	intermediates := x509.NewCertPool()
+	for _, b := range cert.Certificate[1:] {
+		crt, err := x509.ParseCertificate(b)
+		if err != nil {
+			return nil, err
+		}
+		intermediates.AddCert(crt)
+	}
+	if _, err := cert.Leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}); err != nil {
+		return nil, err
+	}
+	for _, name := range s.dnsNames {
+		if err := cert.Leaf.VerifyHostname(name); err != nil {
+			return nil, err
+		}
+	}
+
+	lifetime := cert.Leaf.NotAfter.Sub(cert.Leaf.NotBefore)
+	if time.Until(cert.Leaf.NotAfter) < lifetime/3 {
+		return nil, errors.New("certificate is about to expire")
+	}
+	return &cert, nil
+}
@@ -0,0 +1,185 @@
+package ca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/kubernetes"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+)
+
+type fakeKubernetesAPI struct {
+	mu      sync.Mutex
+	secrets map[string]*kubernetes.Secret
+}
+
+func (f *fakeKubernetesAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	switch r.Method {
+	case http.MethodGet:
+		if s, ok := f.secrets[name]; ok {
+			json.NewEncoder(w).Encode(s)
+			return
+		}
+		http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+	default:
+		var s kubernetes.Secret
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, `{"message":"bad request"}`, http.StatusBadRequest)
+			return
+		}
+		f.secrets[s.Metadata.Name] = &s
+		json.NewEncoder(w).Encode(s)
+	}
+}
+
+func newTestSecretStore(t *testing.T, roots []*x509.Certificate) (*fakeKubernetesAPI, *secretStore) {
+	t.Helper()
+	f := &fakeKubernetesAPI{secrets: map[string]*kubernetes.Secret{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	tmp := newKubernetesClient
+	t.Cleanup(func() { newKubernetesClient = tmp })
+	newKubernetesClient = func() (*kubernetes.Client, error) {
+		return kubernetes.NewClient(srv.URL, "", "step", srv.Client())
+	}
+
+	s, err := newSecretStore(&config.Config{
+		DNSNames: []string{"ca.smallstep.com"},
+		Kubernetes: &config.KubernetesConfig{
+			TLSSecret:   "step-ca-tls",
+			RootsSecret: "step-ca-roots",
+		},
+	}, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, s
+}
+
+func newTestTLSCertificate(t *testing.T, ca *minica.CA, dnsName string, lifetime time.Duration) RenewFunc {
+	t.Helper()
+	return func() (*tls.Certificate, error) {
+		signer, err := keyutil.GenerateDefaultSigner()
+		if err != nil {
+			return nil, err
+		}
+		crt, err := ca.Sign(&x509.Certificate{
+			DNSNames:    []string{dnsName},
+			PublicKey:   signer.Public(),
+			NotBefore:   time.Now().Add(-time.Minute),
+			NotAfter:    time.Now().Add(lifetime),
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Certificate{
+			Certificate: [][]byte{crt.Raw, ca.Intermediate.Raw},
+			PrivateKey:  signer,
+			Leaf:        crt,
+		}, nil
+	}
+}
+
+func Test_secretStore(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, s := newTestSecretStore(t, []*x509.Certificate{ca.Root})
+
+	// Roots are published on creation.
+	block, _ := pem.Decode(f.secrets["step-ca-roots"].Data["ca.crt"])
+	if block == nil || string(block.Bytes) != string(ca.Root.Raw) {
+		t.Fatal("roots secret does not contain the root certificate")
+	}
+
+	// The first certificate is created and stored.
+	var calls int
+	renew := newTestTLSCertificate(t, ca, "ca.smallstep.com", time.Hour)
+	fn := func() (*tls.Certificate, error) {
+		calls++
+		return renew()
+	}
+	first, err := s.GetCertificate(fn)
+	if err != nil {
+		t.Fatalf("secretStore.GetCertificate() error = %v", err)
+	}
+	if calls != 1 || f.secrets["step-ca-tls"].Type != "kubernetes.io/tls" {
+		t.Fatalf("secretStore.GetCertificate() calls = %d, secrets = %v", calls, f.secrets)
+	}
+
+	// The stored certificate is loaded.
+	second, err := s.GetCertificate(fn)
+	if err != nil {
+		t.Fatalf("secretStore.GetCertificate() error = %v", err)
+	}
+	if calls != 1 || !second.Leaf.Equal(first.Leaf) {
+		t.Errorf("secretStore.GetCertificate() calls = %d, want 1", calls)
+	}
+
+	// Renewed certificates are stored.
+	third, err := s.RenewFunc(fn)()
+	if err != nil {
+		t.Fatalf("secretStore.RenewFunc() error = %v", err)
+	}
+	if got, err := s.parseSecret(f.secrets["step-ca-tls"]); err != nil || !got.Leaf.Equal(third.Leaf) {
+		t.Errorf("secretStore.RenewFunc() did not store the certificate, error = %v", err)
+	}
+}
+
+func Test_secretStore_parseSecret(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, s := newTestSecretStore(t, []*x509.Certificate{ca.Root})
+
+	store := func(fn RenewFunc) *kubernetes.Secret {
+		cert, err := fn()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.storeCertificate(cert); err != nil {
+			t.Fatal(err)
+		}
+		return f.secrets["step-ca-tls"]
+	}
+
+	tests := []struct {
+		name    string
+		secret  *kubernetes.Secret
+		wantErr bool
+	}{
+		{"ok", store(newTestTLSCertificate(t, ca, "ca.smallstep.com", time.Hour)), false},
+		{"fail untrusted", store(newTestTLSCertificate(t, other, "ca.smallstep.com", time.Hour)), true},
+		{"fail dnsNames", store(newTestTLSCertificate(t, ca, "other.smallstep.com", time.Hour)), true},
+		{"fail expiring", store(newTestTLSCertificate(t, ca, "ca.smallstep.com", 10*time.Second)), true},
+		{"fail empty", &kubernetes.Secret{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.parseSecret(tt.secret); (err != nil) != tt.wantErr {
+				t.Errorf("secretStore.parseSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
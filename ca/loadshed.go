@@ -0,0 +1,233 @@
+package ca
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/monitoring"
+)
+
+// loadShedder limits the number of requests signing certificates or OCSP
+// responses served at the same time. The requests that cannot be served wait
+// in a queue per class, and the free slots are given to the queues using a
+// smooth weighted round-robin, so the classes with a higher weight, renewals
+// and OCSP by default, are served first without starving the rest.
+//
+// The requests that don't fit in their queue, or that wait more than the
+// queue timeout, are refused with a 503 and a Retry-After header.
+type loadShedder struct {
+	mu         sync.Mutex
+	free       int
+	queueSize  int
+	timeout    time.Duration
+	retryAfter string
+	queues     []*shedQueue
+	metrics    *monitoring.Metrics
+}
+
+type shedQueue struct {
+	class   string
+	weight  int
+	current int
+	waiters []*shedWaiter
+}
+
+type shedWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// newLoadShedder creates a new loadShedder. If metrics are given, the depth
+// of the queues and the refused requests are reported to them.
+func newLoadShedder(cfg *config.LoadSheddingConfig, metrics *monitoring.Metrics) *loadShedder {
+	s := &loadShedder{
+		free:       cfg.GetMaxConcurrent(),
+		queueSize:  cfg.GetQueueSize(),
+		timeout:    cfg.GetQueueTimeout(),
+		retryAfter: strconv.Itoa(int(cfg.GetRetryAfter().Seconds())),
+		metrics:    metrics,
+	}
+	for _, class := range config.LoadSheddingClasses {
+		s.queues = append(s.queues, &shedQueue{
+			class:  class,
+			weight: cfg.GetWeight(class),
+		})
+	}
+	if metrics != nil {
+		metrics.SetQueueDepths(s.QueueDepths)
+	}
+	return s
+}
+
+// Middleware queues the requests that sign certificates or OCSP responses,
+// the rest of the requests are served without waiting.
+func (s *loadShedder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := s.queue(loadSheddingClass(r))
+		if q == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if reason := s.acquire(r, q); reason != "" {
+			if s.metrics != nil {
+				s.metrics.RequestShed(q.class, reason)
+			}
+			s.shed(w, r)
+			return
+		}
+		defer s.release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// QueueDepths returns the number of requests waiting in each queue.
+func (s *loadShedder) QueueDepths() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	depths := make(map[string]int, len(s.queues))
+	for _, q := range s.queues {
+		depths[q.class] = len(q.waiters)
+	}
+	return depths
+}
+
+func (s *loadShedder) queue(class string) *shedQueue {
+	for _, q := range s.queues {
+		if q.class == class {
+			return q
+		}
+	}
+	return nil
+}
+
+// acquire waits for a free slot. It returns the reason the request is refused
+// or an empty string if the slot is acquired.
+func (s *loadShedder) acquire(r *http.Request, q *shedQueue) string {
+	s.mu.Lock()
+	if s.free > 0 && s.waiting() == 0 {
+		s.free--
+		s.mu.Unlock()
+		return ""
+	}
+	if len(q.waiters) >= s.queueSize {
+		s.mu.Unlock()
+		return "queue_full"
+	}
+	w := &shedWaiter{ready: make(chan struct{})}
+	q.waiters = append(q.waiters, w)
+	s.mu.Unlock()
+
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+
+	reason := "timeout"
+	select {
+	case <-w.ready:
+		return ""
+	case <-timer.C:
+	case <-r.Context().Done():
+		reason = "canceled"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The slot may have been granted while the timer fired.
+	if w.granted {
+		return ""
+	}
+	for i, v := range q.waiters {
+		if v == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			break
+		}
+	}
+	return reason
+}
+
+// release gives the slot to the next request or returns it to the pool.
+func (s *loadShedder) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if q := s.next(); q != nil {
+		w := q.waiters[0]
+		q.waiters[0] = nil
+		q.waiters = q.waiters[1:]
+		w.granted = true
+		close(w.ready)
+		return
+	}
+	s.free++
+}
+
+// next returns the queue that gets the next slot using a smooth weighted
+// round-robin across the queues with waiting requests.
+func (s *loadShedder) next() *shedQueue {
+	var best *shedQueue
+	var total int
+	for _, q := range s.queues {
+		if len(q.waiters) == 0 {
+			continue
+		}
+		q.current += q.weight
+		total += q.weight
+		if best == nil || q.current > best.current {
+			best = q
+		}
+	}
+	if best != nil {
+		best.current -= total
+	}
+	return best
+}
+
+func (s *loadShedder) waiting() int {
+	var n int
+	for _, q := range s.queues {
+		n += len(q.waiters)
+	}
+	return n
+}
+
+func (s *loadShedder) shed(w http.ResponseWriter, r *http.Request) {
+	const msg = "The CA is overloaded, please try again later"
+	w.Header().Set("Retry-After", s.retryAfter)
+	if isACMERequest(r) {
+		e := acme.NewError(acme.ErrorServerInternalType, msg)
+		e.Detail = msg
+		e.Status = http.StatusServiceUnavailable
+		e.Code = errs.CodeOverloaded
+		render.Error(w, e)
+		return
+	}
+	render.Error(w, errs.ApplyOptions(errs.New(http.StatusServiceUnavailable, msg), errs.WithCode(errs.CodeOverloaded)))
+}
+
+// loadSheddingClass returns the class of the request, or an empty string if
+// the request is not queued.
+func loadSheddingClass(r *http.Request) string {
+	if isACMERequest(r) {
+		if r.Method == http.MethodPost && (strings.HasSuffix(r.URL.Path, "/new-order") || strings.HasSuffix(r.URL.Path, "/finalize")) {
+			return config.LoadSheddingACME
+		}
+		return ""
+	}
+	switch path := strings.TrimPrefix(r.URL.Path, "/1.0"); {
+	case path == "/ocsp" || strings.HasPrefix(path, "/ocsp/"):
+		return config.LoadSheddingOCSP
+	case r.Method != http.MethodPost:
+		return ""
+	case path == "/renew", path == "/rekey", path == "/re-sign", path == "/ssh/renew", path == "/ssh/rekey":
+		return config.LoadSheddingRenew
+	case path == "/sign", path == "/sign/batch", path == "/sign-ssh", path == "/ssh/sign", path == "/smime/sign", path == "/attest":
+		return config.LoadSheddingSign
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,166 @@
+package ca
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/monitoring"
+)
+
+func Test_loadSheddingClass(t *testing.T) {
+	tests := []struct {
+		method string
+		target string
+		want   string
+	}{
+		{"POST", "/1.0/ocsp", config.LoadSheddingOCSP},
+		{"GET", "/ocsp/MEMwQTA", config.LoadSheddingOCSP},
+		{"POST", "/renew", config.LoadSheddingRenew},
+		{"POST", "/1.0/rekey", config.LoadSheddingRenew},
+		{"POST", "/ssh/renew", config.LoadSheddingRenew},
+		{"POST", "/1.0/sign", config.LoadSheddingSign},
+		{"POST", "/ssh/sign", config.LoadSheddingSign},
+		{"POST", "/acme/acme/new-order", config.LoadSheddingACME},
+		{"POST", "/2.0/acme/acme/order/foo/finalize", config.LoadSheddingACME},
+		{"POST", "/acme/acme/new-account", ""},
+		{"POST", "/acme/acme/challenge/foo/bar", ""},
+		{"GET", "/1.0/sign", ""},
+		{"GET", "/roots", ""},
+		{"POST", "/revoke", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, http.NoBody)
+			assert.Equal(t, tt.want, loadSheddingClass(r))
+		})
+	}
+}
+
+func TestLoadShedder_Middleware(t *testing.T) {
+	metrics := monitoring.NewMetrics()
+	s := newLoadShedder(&config.LoadSheddingConfig{
+		Enabled:       true,
+		MaxConcurrent: 1,
+		QueueSize:     1,
+		QueueTimeout:  &provisioner.Duration{Duration: 200 * time.Millisecond},
+		RetryAfter:    &provisioner.Duration{Duration: time.Minute},
+	}, metrics)
+
+	block, started := make(chan struct{}), make(chan struct{}, 1)
+	h := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Block") != "" {
+			started <- struct{}{}
+			<-block
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(method, target string, block bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, http.NoBody)
+		if block {
+			r.Header.Set("Block", "true")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// Take the only slot.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serve("POST", "/1.0/sign", true)
+	}()
+	<-started
+
+	// Requests not queued are served.
+	assert.Equal(t, http.StatusNoContent, serve("GET", "/roots", false).Code)
+
+	// The request waits in the queue and times out.
+	w := serve("POST", "/1.0/renew", false)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "overloaded", body["code"])
+
+	// The request does not fit in the queue.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serve("POST", "/acme/acme/new-order", false)
+	}()
+	require.Eventually(t, func() bool {
+		return s.QueueDepths()[config.LoadSheddingACME] == 1
+	}, time.Second, time.Millisecond)
+	w = serve("POST", "/acme/acme/new-order", false)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	body = nil
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "urn:ietf:params:acme:error:serverInternal", body["type"])
+	assert.Equal(t, "overloaded", body["code"])
+	wg.Wait()
+
+	close(block)
+	<-done
+	assert.Equal(t, http.StatusNoContent, serve("POST", "/1.0/renew", false).Code)
+	assert.Equal(t, map[string]int{"ocsp": 0, "renew": 0, "sign": 0, "acme": 0}, s.QueueDepths())
+
+	var sb strings.Builder
+	_, err := metrics.WriteTo(&sb)
+	require.NoError(t, err)
+	for _, want := range []string{
+		`step_ca_load_shedding_shed_total{class="renew",reason="timeout"} 1`,
+		`step_ca_load_shedding_shed_total{class="acme",reason="queue_full"} 1`,
+		`step_ca_load_shedding_shed_total{class="acme",reason="timeout"} 1`,
+		`step_ca_load_shedding_queue_depth{class="acme"} 0`,
+	} {
+		assert.Contains(t, sb.String(), want+"\n")
+	}
+}
+
+func TestLoadShedder_priority(t *testing.T) {
+	s := newLoadShedder(&config.LoadSheddingConfig{
+		Enabled:       true,
+		MaxConcurrent: 1,
+		QueueTimeout:  &provisioner.Duration{Duration: time.Minute},
+	}, nil)
+
+	// Queue 4 ACME and 4 renew requests while the only slot is taken.
+	s.free = 0
+	for i := 0; i < 4; i++ {
+		for _, class := range []string{config.LoadSheddingACME, config.LoadSheddingRenew} {
+			q := s.queue(class)
+			q.waiters = append(q.waiters, &shedWaiter{ready: make(chan struct{})})
+		}
+	}
+
+	var order []string
+	for s.waiting() > 0 {
+		q := s.next()
+		q.waiters = q.waiters[1:]
+		order = append(order, q.class)
+	}
+	// Renewals have 8 times the weight of ACME, so they're served first but
+	// the ACME requests still get a slot.
+	assert.Equal(t, []string{"renew", "renew", "renew", "renew", "acme", "acme", "acme", "acme"}, order)
+
+	q := s.queue(config.LoadSheddingRenew)
+	w := &shedWaiter{ready: make(chan struct{})}
+	q.waiters = append(q.waiters, w)
+	s.release()
+	assert.True(t, w.granted)
+	assert.Empty(t, q.waiters)
+	s.release()
+	assert.Equal(t, 1, s.free)
+}
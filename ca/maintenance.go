@@ -0,0 +1,67 @@
+package ca
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/errs"
+)
+
+// readOnly refuses the requests that can issue, renew or revoke certificates,
+// or write to the database, while the CA is in read-only mode. GET and HEAD
+// requests are served, so the roots, the CRLs and the ACME directory and
+// nonces remain available.
+//
+// ACME requests, including POST-as-GET requests, are refused with an ACME
+// problem document, and the rest with the errors of the CA API.
+type readOnly struct {
+	message    string
+	retryAfter string
+}
+
+func newReadOnly(cfg *config.MaintenanceConfig) *readOnly {
+	return &readOnly{
+		message:    cfg.GetMessage(),
+		retryAfter: strconv.Itoa(int(cfg.GetRetryAfter().Seconds())),
+	}
+}
+
+// Middleware refuses the requests not allowed in read-only mode.
+func (m *readOnly) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isReadOnlyRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", m.retryAfter)
+		if isACMERequest(r) {
+			e := acme.NewError(acme.ErrorServerInternalType, m.message)
+			e.Detail = m.message
+			e.Status = http.StatusServiceUnavailable
+			e.Code = errs.CodeReadOnly
+			render.Error(w, e)
+			return
+		}
+		render.Error(w, errs.ApplyOptions(errs.New(http.StatusServiceUnavailable, m.message), errs.WithCode(errs.CodeReadOnly)))
+	})
+}
+
+// isReadOnlyRequest returns true if the request does not change the state of
+// the CA. SCEP uses GET requests for the PKIOperation operation, so these are
+// not considered read-only.
+func isReadOnlyRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return r.URL.Query().Get("operation") != "PKIOperation"
+	default:
+		return false
+	}
+}
+
+func isACMERequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/acme/") || strings.HasPrefix(r.URL.Path, "/2.0/acme/")
+}
@@ -0,0 +1,70 @@
+package ca
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func TestReadOnly_Middleware(t *testing.T) {
+	m := newReadOnly(&config.MaintenanceConfig{
+		Enabled:    true,
+		Message:    "Database migration in progress",
+		RetryAfter: &provisioner.Duration{Duration: 2 * time.Minute},
+	})
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		wantCode int
+		wantBody map[string]any
+	}{
+		{"roots", "GET", "/1.0/roots", http.StatusNoContent, nil},
+		{"crl", "GET", "/1.0/crl", http.StatusNoContent, nil},
+		{"acme directory", "GET", "/acme/acme/directory", http.StatusNoContent, nil},
+		{"acme nonce", "HEAD", "/acme/acme/new-nonce", http.StatusNoContent, nil},
+		{"scep cacert", "GET", "/scep/scep?operation=GetCACert", http.StatusNoContent, nil},
+		{"sign", "POST", "/1.0/sign", http.StatusServiceUnavailable, map[string]any{
+			"status": float64(503), "code": "readOnly", "message": "Database migration in progress",
+		}},
+		{"admin", "DELETE", "/admin/provisioners/foo", http.StatusServiceUnavailable, map[string]any{
+			"status": float64(503), "code": "readOnly", "message": "Database migration in progress",
+		}},
+		{"scep pkioperation", "GET", "/scep/scep?operation=PKIOperation&message=foo", http.StatusServiceUnavailable, map[string]any{
+			"status": float64(503), "code": "readOnly", "message": "Database migration in progress",
+		}},
+		{"acme new-order", "POST", "/acme/acme/new-order", http.StatusServiceUnavailable, map[string]any{
+			"type": "urn:ietf:params:acme:error:serverInternal", "code": "readOnly", "detail": "Database migration in progress",
+		}},
+		{"acme 2.0 finalize", "POST", "/2.0/acme/acme/order/foo/finalize", http.StatusServiceUnavailable, map[string]any{
+			"type": "urn:ietf:params:acme:error:serverInternal", "code": "readOnly", "detail": "Database migration in progress",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, http.NoBody))
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantBody == nil {
+				assert.Empty(t, w.Header().Get("Retry-After"))
+				return
+			}
+			assert.Equal(t, "120", w.Header().Get("Retry-After"))
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantBody, body)
+		})
+	}
+}
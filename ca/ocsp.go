@@ -0,0 +1,116 @@
+package ca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"log"
+	"sync"
+	"time"
+)
+
+// ocspStapleValidity is the validity of the OCSP responses stapled to the
+// certificate used by the CA server.
+var ocspStapleValidity = 4 * time.Hour
+
+// OCSPFunc defines the type of the functions used to create an OCSP response
+// for a certificate.
+type OCSPFunc func(crt *x509.Certificate, thisUpdate, nextUpdate time.Time) ([]byte, error)
+
+// ocspStapler adds an OCSP response to the certificates returned by a
+// GetCertificate function. The response is created in the background the
+// first time it is required and refreshed after 2/3 of its validity, or when
+// the certificate changes. The handshakes do not wait for the responses, they
+// use the last response created for the certificate while it's valid.
+type ocspStapler struct {
+	mutex          sync.Mutex
+	getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	createResponse OCSPFunc
+	serialNumber   string
+	staple         []byte
+	refreshAt      time.Time
+	nextUpdate     time.Time
+	refreshing     bool
+}
+
+func newOCSPStapler(getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error), fn OCSPFunc) *ocspStapler {
+	return &ocspStapler{
+		getCertificate: getCertificate,
+		createResponse: fn,
+	}
+}
+
+// GetCertificate returns the current certificate with an OCSP response
+// stapled. If there is no valid OCSP response yet the certificate is returned
+// without it.
+//
+// This method is set in the tls.Config GetCertificate property.
+func (s *ocspStapler) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert, err := s.getCertificate(hello)
+	if err != nil || cert.Leaf == nil {
+		return cert, err
+	}
+
+	staple := s.getStaple(cert.Leaf)
+	if staple == nil {
+		return cert, nil
+	}
+
+	// Do not modify the certificate shared with the renewer.
+	stapled := *cert
+	stapled.OCSPStaple = staple
+	return &stapled, nil
+}
+
+// getStaple returns the cached response of the given certificate, or nil if
+// there is no valid one, and starts a refresh if the response must be
+// refreshed. Only one refresh runs at a time.
+func (s *ocspStapler) getStaple(leaf *x509.Certificate) []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	sn := leaf.SerialNumber.String()
+	if s.serialNumber != sn {
+		s.serialNumber = sn
+		s.staple = nil
+		s.refreshAt = time.Time{}
+		s.nextUpdate = time.Time{}
+	}
+	if !now.Before(s.refreshAt) && !s.refreshing {
+		s.refreshing = true
+		go s.refresh(leaf)
+	}
+	if now.Before(s.nextUpdate) {
+		return s.staple
+	}
+	return nil
+}
+
+// refresh creates a new response for the given certificate. The response is
+// discarded if the certificate has changed.
+func (s *ocspStapler) refresh(leaf *x509.Certificate) {
+	now := time.Now()
+	thisUpdate := now.Add(-1 * time.Minute)
+	nextUpdate := now.Add(ocspStapleValidity)
+	if leaf.NotAfter.Before(nextUpdate) {
+		nextUpdate = leaf.NotAfter
+	}
+	staple, err := s.createResponse(leaf, thisUpdate, nextUpdate)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.refreshing = false
+	if s.serialNumber != leaf.SerialNumber.String() {
+		return
+	}
+	if err != nil {
+		log.Printf("error creating ocsp staple: %v", err)
+		// Keep serving a previous response while it's still valid, and retry
+		// in a minute.
+		s.refreshAt = time.Now().Add(time.Minute)
+		return
+	}
+	s.staple = staple
+	s.refreshAt = thisUpdate.Add(nextUpdate.Sub(thisUpdate) * 2 / 3)
+	s.nextUpdate = nextUpdate
+}
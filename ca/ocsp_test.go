@@ -0,0 +1,158 @@
+package ca
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_ocspStapler_GetCertificate(t *testing.T) {
+	newCert := func(sn int64) *tls.Certificate {
+		return &tls.Certificate{Leaf: &x509.Certificate{
+			SerialNumber: big.NewInt(sn),
+			NotAfter:     time.Now().Add(time.Hour),
+		}}
+	}
+	cert := newCert(1)
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return cert, nil
+	}
+
+	var calls int
+	var fail bool
+	s := newOCSPStapler(getCertificate, func(crt *x509.Certificate, thisUpdate, nextUpdate time.Time) ([]byte, error) {
+		calls++
+		if fail {
+			return nil, errors.New("force")
+		}
+		if !nextUpdate.After(thisUpdate) || nextUpdate.After(crt.NotAfter) {
+			t.Errorf("unexpected validity %s - %s", thisUpdate, nextUpdate)
+		}
+		return crt.SerialNumber.Bytes(), nil
+	})
+
+	// waitRefresh waits for the refresh started by GetCertificate.
+	waitRefresh := func() {
+		for {
+			s.mutex.Lock()
+			refreshing := s.refreshing
+			s.mutex.Unlock()
+			if !refreshing {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// First handshake starts the creation of the staple.
+	got, err := s.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("ocspStapler.GetCertificate() error = %v", err)
+	}
+	if got.OCSPStaple != nil {
+		t.Errorf("ocspStapler.GetCertificate() staple = %v, want nil", got.OCSPStaple)
+	}
+	waitRefresh()
+	if got, _ = s.GetCertificate(nil); !bytes.Equal(got.OCSPStaple, []byte{1}) || calls != 1 {
+		t.Errorf("ocspStapler.GetCertificate() staple = %v, calls = %d", got.OCSPStaple, calls)
+	}
+	if cert.OCSPStaple != nil {
+		t.Error("ocspStapler.GetCertificate() modified the original certificate")
+	}
+
+	// The staple is cached.
+	if got, _ = s.GetCertificate(nil); !bytes.Equal(got.OCSPStaple, []byte{1}) || calls != 1 {
+		t.Errorf("ocspStapler.GetCertificate() staple = %v, calls = %d", got.OCSPStaple, calls)
+	}
+
+	// On refresh errors the previous staple is kept.
+	fail = true
+	s.mutex.Lock()
+	s.refreshAt = time.Now().Add(-time.Second)
+	s.mutex.Unlock()
+	if got, _ = s.GetCertificate(nil); !bytes.Equal(got.OCSPStaple, []byte{1}) {
+		t.Errorf("ocspStapler.GetCertificate() staple = %v", got.OCSPStaple)
+	}
+	waitRefresh()
+	if got, _ = s.GetCertificate(nil); !bytes.Equal(got.OCSPStaple, []byte{1}) || calls != 2 {
+		t.Errorf("ocspStapler.GetCertificate() staple = %v, calls = %d", got.OCSPStaple, calls)
+	}
+
+	// A new certificate without a valid response is returned without staple.
+	cert = newCert(2)
+	if got, _ = s.GetCertificate(nil); got.OCSPStaple != nil {
+		t.Errorf("ocspStapler.GetCertificate() staple = %v", got.OCSPStaple)
+	}
+	waitRefresh()
+	if got, _ = s.GetCertificate(nil); got.OCSPStaple != nil || calls != 3 {
+		t.Errorf("ocspStapler.GetCertificate() staple = %v, calls = %d", got.OCSPStaple, calls)
+	}
+
+	// And a new staple is created after the retry delay.
+	fail = false
+	s.mutex.Lock()
+	s.refreshAt = time.Now().Add(-time.Second)
+	s.mutex.Unlock()
+	s.GetCertificate(nil)
+	waitRefresh()
+	if got, _ = s.GetCertificate(nil); !bytes.Equal(got.OCSPStaple, []byte{2}) || calls != 4 {
+		t.Errorf("ocspStapler.GetCertificate() staple = %v, calls = %d", got.OCSPStaple, calls)
+	}
+}
+
+func Test_ocspStapler_GetCertificate_refresh(t *testing.T) {
+	cert := &tls.Certificate{Leaf: &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotAfter:     time.Now().Add(time.Hour),
+	}}
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return cert, nil
+	}
+
+	var calls int32
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	s := newOCSPStapler(getCertificate, func(crt *x509.Certificate, thisUpdate, nextUpdate time.Time) ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		started <- struct{}{}
+		<-release
+		return []byte{byte(atomic.LoadInt32(&calls))}, nil
+	})
+	s.serialNumber = "1"
+	s.staple = []byte{0}
+	s.nextUpdate = time.Now().Add(time.Hour)
+
+	// The handshakes do not wait for the refresh, they keep using the last
+	// staple, and only one refresh runs at a time.
+	for i := 0; i < 10; i++ {
+		got, err := s.GetCertificate(nil)
+		if err != nil {
+			t.Fatalf("ocspStapler.GetCertificate() error = %v", err)
+		}
+		if !bytes.Equal(got.OCSPStaple, []byte{0}) {
+			t.Errorf("ocspStapler.GetCertificate() staple = %v, want [0]", got.OCSPStaple)
+		}
+	}
+	<-started
+	close(release)
+	for {
+		s.mutex.Lock()
+		staple := s.staple
+		s.mutex.Unlock()
+		if bytes.Equal(staple, []byte{1}) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("ocspStapler.createResponse calls = %d, want 1", n)
+	}
+	if got, _ := s.GetCertificate(nil); !bytes.Equal(got.OCSPStaple, []byte{1}) {
+		t.Errorf("ocspStapler.GetCertificate() staple = %v, want [1]", got.OCSPStaple)
+	}
+}
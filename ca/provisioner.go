@@ -1,16 +1,19 @@
 package ca
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"net/url"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/authority"
 	"github.com/smallstep/certificates/authority/provisioner"
 	"go.step.sm/cli-utils/token"
 	"go.step.sm/cli-utils/token/provision"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/randutil"
+	"go.step.sm/crypto/x509util"
 )
 
 const tokenLifetime = 5 * time.Minute
@@ -85,6 +88,33 @@ func (p *Provisioner) SetFingerprint(sum string) {
 
 // Token generates a bootstrap token for a subject.
 func (p *Provisioner) Token(subject string, sans ...string) (string, error) {
+	return p.token(subject, sans)
+}
+
+// RenewalToken generates a token to renew the given certificate without
+// presenting it or using its key. The token is sent with Client.RenewWithToken
+// and it requires a CA with a database.
+func (p *Provisioner) RenewalToken(crt *x509.Certificate) (string, error) {
+	var sans []string
+	sans = append(sans, crt.DNSNames...)
+	for _, ip := range crt.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, crt.EmailAddresses...)
+	for _, u := range crt.URIs {
+		sans = append(sans, u.String())
+	}
+	subject := crt.Subject.CommonName
+	if subject == "" && len(sans) > 0 {
+		subject = sans[0]
+	}
+	return p.token(subject, sans, token.WithClaim("renewal", authority.RenewalClaim{
+		SerialNumber: crt.SerialNumber.String(),
+		Fingerprint:  x509util.Fingerprint(crt),
+	}))
+}
+
+func (p *Provisioner) token(subject string, sans []string, opts ...token.Options) (string, error) {
 	if len(sans) == 0 {
 		sans = []string{subject}
 	}
@@ -109,6 +139,7 @@ func (p *Provisioner) Token(subject string, sans ...string) (string, error) {
 	if p.fingerprint != "" {
 		tokOptions = append(tokOptions, token.WithSHA(p.fingerprint))
 	}
+	tokOptions = append(tokOptions, opts...)
 
 	tok, err := provision.New(subject, tokOptions...)
 	if err != nil {
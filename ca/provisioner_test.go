@@ -1,6 +1,10 @@
 package ca
 
 import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
 	"net/url"
 	"os"
 	"reflect"
@@ -397,3 +401,51 @@ func TestProvisioner_SSHToken(t *testing.T) {
 		})
 	}
 }
+
+func TestProvisioner_RenewalToken(t *testing.T) {
+	p := getTestProvisioner(t, "https://127.0.0.1:9000")
+	crt := &x509.Certificate{
+		Raw:          []byte("certificate"),
+		SerialNumber: big.NewInt(1234),
+		Subject:      pkix.Name{CommonName: "foo.smallstep.com"},
+		DNSNames:     []string{"foo.smallstep.com"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	got, err := p.RenewalToken(crt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwt, err := jose.ParseSigned(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims struct {
+		jose.Claims
+		SANs    []string `json:"sans"`
+		Renewal struct {
+			SerialNumber string `json:"serialNumber"`
+			Fingerprint  string `json:"fingerprint"`
+		} `json:"renewal"`
+	}
+	if err := jwt.Claims(p.jwk.Public(), &claims); err != nil {
+		t.Fatal(err)
+	}
+	if err := claims.ValidateWithLeeway(jose.Expected{
+		Audience: []string{"https://127.0.0.1:9000/1.0/sign"},
+		Issuer:   "mariano",
+		Subject:  "foo.smallstep.com",
+		Time:     time.Now().UTC(),
+	}, time.Minute); err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(claims.SANs, []string{"foo.smallstep.com", "127.0.0.1"}) {
+		t.Errorf("Provisioner.RenewalToken() sans = %v", claims.SANs)
+	}
+	if claims.Renewal.SerialNumber != "1234" {
+		t.Errorf("Provisioner.RenewalToken() serialNumber = %s, want 1234", claims.Renewal.SerialNumber)
+	}
+	if claims.Renewal.Fingerprint != x509util.Fingerprint(crt) {
+		t.Errorf("Provisioner.RenewalToken() fingerprint = %s, want %s", claims.Renewal.Fingerprint, x509util.Fingerprint(crt))
+	}
+}
@@ -0,0 +1,205 @@
+package ca
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/pemutil"
+	"go.step.sm/crypto/x509util"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/cas/acmecas"
+	"github.com/smallstep/certificates/cas/apiv1"
+)
+
+// certificateIssuer is the interface used to order the public certificate of
+// the CA server.
+type certificateIssuer interface {
+	CreateCertificate(req *apiv1.CreateCertificateRequest) (*apiv1.CreateCertificateResponse, error)
+}
+
+// publicTLS obtains the certificate of the CA server from an external ACME
+// authority, and keeps it in the storage directory so it persists across
+// restarts.
+type publicTLS struct {
+	issuer   certificateIssuer
+	dnsNames []string
+	storage  string
+	// solverAddress is the address where the http-01 challenges are served
+	// while the CA is not running, it is only set with the selfSolve option.
+	solverAddress string
+}
+
+func newPublicTLS(cfg *config.Config) (*publicTLS, error) {
+	dnsNames := cfg.PublicTLS.DNSNames
+	if len(dnsNames) == 0 {
+		dnsNames = cfg.DNSNames
+	}
+	if len(dnsNames) == 0 {
+		return nil, errors.New("publicTLS requires at least one DNS name")
+	}
+
+	issuer, err := acmecas.New(context.Background(), apiv1.Options{
+		CertificateAuthority: cfg.PublicTLS.DirectoryURL,
+		Config:               cfg.PublicTLS.Options,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error initializing publicTLS")
+	}
+
+	p := &publicTLS{
+		issuer:   issuer,
+		dnsNames: dnsNames,
+		storage:  cfg.PublicTLS.Storage,
+	}
+	if cfg.PublicTLS.IsSelfSolving() {
+		p.solverAddress = cfg.InsecureAddress
+	}
+	return p, nil
+}
+
+// GetCertificate returns the certificate in the storage directory if it's
+// still valid, or orders a new one.
+func (p *publicTLS) GetCertificate() (*tls.Certificate, error) {
+	if p.storage != "" {
+		cert, err := p.loadCertificate()
+		if err == nil {
+			return cert, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Ignoring certificate in %s: %v", p.storage, err)
+		}
+	}
+	if p.solverAddress != "" {
+		return p.bootstrap()
+	}
+	return p.Renew()
+}
+
+// bootstrap orders the first certificate with the selfSolve option. The
+// insecure server is not running yet, so the http-01 challenges are served
+// on the insecure address until the order completes. Renewals are solved by
+// the insecure server.
+func (p *publicTLS) bootstrap() (*tls.Certificate, error) {
+	ln, err := net.Listen("tcp", p.solverAddress)
+	if err != nil {
+		// The address might be already served by the insecure server, e.g.
+		// on a reload.
+		log.Printf("error listening on %s, ordering public certificate without it: %v", p.solverAddress, err)
+		return p.Renew()
+	}
+	srv := &http.Server{
+		Handler:           acmecas.HTTP01Handler(),
+		ReadHeaderTimeout: 15 * time.Second,
+	}
+	go srv.Serve(ln) //nolint:errcheck // closed after the order
+	defer srv.Close()
+
+	return p.Renew()
+}
+
+// Renew orders a new certificate with a new key to the ACME authority and
+// stores it. It implements a RenewFunc.
+func (p *publicTLS) Renew() (*tls.Certificate, error) {
+	signer, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509util.CreateCertificateRequest(p.dnsNames[0], p.dnsNames, signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating certificate request")
+	}
+
+	resp, err := p.issuer.CreateCertificate(&apiv1.CreateCertificateRequest{
+		Template: &x509.Certificate{
+			DNSNames:    csr.DNSNames,
+			IPAddresses: csr.IPAddresses,
+		},
+		CSR: csr,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error ordering public certificate")
+	}
+
+	cert := &tls.Certificate{
+		Certificate: [][]byte{resp.Certificate.Raw},
+		PrivateKey:  signer,
+		Leaf:        resp.Certificate,
+	}
+	for _, crt := range resp.CertificateChain {
+		cert.Certificate = append(cert.Certificate, crt.Raw)
+	}
+
+	if p.storage != "" {
+		if err := p.storeCertificate(cert); err != nil {
+			// The certificate is still usable by this instance.
+			log.Printf("error storing certificate in %s: %v", p.storage, err)
+		}
+	}
+	return cert, nil
+}
+
+func (p *publicTLS) certificatePaths() (string, string) {
+	return filepath.Join(p.storage, "tls.crt"), filepath.Join(p.storage, "tls.key")
+}
+
+func (p *publicTLS) storeCertificate(cert *tls.Certificate) error {
+	var chain bytes.Buffer
+	for _, b := range cert.Certificate {
+		if err := pem.Encode(&chain, &pem.Block{Type: "CERTIFICATE", Bytes: b}); err != nil {
+			return errors.Wrap(err, "error encoding certificate")
+		}
+	}
+	block, err := pemutil.Serialize(cert.PrivateKey, pemutil.WithPKCS8(true))
+	if err != nil {
+		return errors.Wrap(err, "error encoding private key")
+	}
+
+	if err := os.MkdirAll(p.storage, 0700); err != nil {
+		return errors.Wrap(err, "error creating storage directory")
+	}
+	crtFile, keyFile := p.certificatePaths()
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		return errors.Wrap(err, "error writing private key")
+	}
+	if err := os.WriteFile(crtFile, chain.Bytes(), 0600); err != nil {
+		return errors.Wrap(err, "error writing certificate")
+	}
+	return nil
+}
+
+// loadCertificate returns the stored certificate. The certificate must
+// include the configured DNS names, and not be past the point where it would
+// be renewed.
+func (p *publicTLS) loadCertificate() (*tls.Certificate, error) {
+	crtFile, keyFile := p.certificatePaths()
+	cert, err := tls.LoadX509KeyPair(crtFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, err
+	}
+	for _, name := range p.dnsNames {
+		if err := cert.Leaf.VerifyHostname(name); err != nil {
+			return nil, err
+		}
+	}
+
+	lifetime := cert.Leaf.NotAfter.Sub(cert.Leaf.NotBefore)
+	if time.Until(cert.Leaf.NotAfter) < lifetime/3 {
+		return nil, errors.New("certificate is about to expire")
+	}
+	return &cert, nil
+}
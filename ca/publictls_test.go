@@ -0,0 +1,154 @@
+package ca
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/minica"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/cas/apiv1"
+)
+
+type fakeIssuer struct {
+	ca       *minica.CA
+	lifetime time.Duration
+	calls    int
+	err      error
+	hook     func()
+}
+
+func (f *fakeIssuer) CreateCertificate(req *apiv1.CreateCertificateRequest) (*apiv1.CreateCertificateResponse, error) {
+	f.calls++
+	if f.hook != nil {
+		f.hook()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	crt, err := f.ca.Sign(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: req.CSR.Subject.CommonName},
+		DNSNames:    req.Template.DNSNames,
+		IPAddresses: req.Template.IPAddresses,
+		PublicKey:   req.CSR.PublicKey,
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(f.lifetime),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &apiv1.CreateCertificateResponse{
+		Certificate:      crt,
+		CertificateChain: []*x509.Certificate{f.ca.Intermediate},
+	}, nil
+}
+
+func Test_publicTLS_GetCertificate(t *testing.T) {
+	ca, err := minica.New()
+	require.NoError(t, err)
+
+	storage := t.TempDir()
+	issuer := &fakeIssuer{ca: ca, lifetime: 90 * 24 * time.Hour}
+	p := &publicTLS{issuer: issuer, dnsNames: []string{"ca.example.com"}, storage: storage}
+
+	// Orders a new certificate and stores it.
+	cert, err := p.GetCertificate()
+	require.NoError(t, err)
+	require.Len(t, cert.Certificate, 2)
+	assert.Equal(t, []string{"ca.example.com"}, cert.Leaf.DNSNames)
+	assert.Equal(t, ca.Intermediate.Raw, cert.Certificate[1])
+	assert.Equal(t, 1, issuer.calls)
+
+	// Loads the stored certificate.
+	stored, err := (&publicTLS{issuer: issuer, dnsNames: []string{"ca.example.com"}, storage: storage}).GetCertificate()
+	require.NoError(t, err)
+	assert.Equal(t, cert.Certificate, stored.Certificate)
+	assert.Equal(t, 1, issuer.calls)
+
+	// The stored certificate does not have the new name.
+	other, err := (&publicTLS{issuer: issuer, dnsNames: []string{"ca.example.com", "ca.example.org"}, storage: storage}).GetCertificate()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ca.example.com", "ca.example.org"}, other.Leaf.DNSNames)
+	assert.Equal(t, 2, issuer.calls)
+
+	// Renew always orders a new certificate.
+	renewed, err := p.Renew()
+	require.NoError(t, err)
+	assert.NotEqual(t, cert.Leaf.SerialNumber, renewed.Leaf.SerialNumber)
+	assert.Equal(t, 3, issuer.calls)
+
+	// Order errors.
+	issuer.err = errors.New("rate limited")
+	_, err = (&publicTLS{issuer: issuer, dnsNames: []string{"ca.example.com"}}).GetCertificate()
+	assert.EqualError(t, err, "error ordering public certificate: rate limited")
+}
+
+func Test_publicTLS_loadCertificate(t *testing.T) {
+	ca, err := minica.New()
+	require.NoError(t, err)
+
+	// A certificate that should be already renewed.
+	issuer := &fakeIssuer{ca: ca, lifetime: time.Minute}
+	p := &publicTLS{issuer: issuer, dnsNames: []string{"ca.example.com"}, storage: t.TempDir()}
+	_, err = p.Renew()
+	require.NoError(t, err)
+	_, err = p.loadCertificate()
+	assert.EqualError(t, err, "certificate is about to expire")
+
+	// GetCertificate orders a new one.
+	issuer.lifetime = 24 * time.Hour
+	cert, err := p.GetCertificate()
+	require.NoError(t, err)
+	assert.Equal(t, 2, issuer.calls)
+	assert.True(t, time.Until(cert.Leaf.NotAfter) > time.Hour)
+}
+
+func Test_publicTLS_bootstrap(t *testing.T) {
+	ca, err := minica.New()
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	// The challenges are served while the certificate is ordered.
+	var status int
+	issuer := &fakeIssuer{ca: ca, lifetime: 24 * time.Hour, hook: func() {
+		resp, err := http.Get("http://" + addr + "/.well-known/acme-challenge/missing")
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+			status = resp.StatusCode
+		}
+	}}
+	p := &publicTLS{issuer: issuer, dnsNames: []string{"ca.example.com"}, solverAddress: addr}
+	_, err = p.GetCertificate()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// The address is released after the order.
+	ln, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	// The certificate is ordered if the address is in use.
+	issuer.hook = nil
+	_, err = p.GetCertificate()
+	require.NoError(t, err)
+	assert.Equal(t, 2, issuer.calls)
+}
+
+func Test_newPublicTLS(t *testing.T) {
+	_, err := newPublicTLS(&config.Config{
+		PublicTLS: &config.PublicTLSConfig{Enabled: true, DirectoryURL: "https://acme.example.com/directory"},
+	})
+	assert.EqualError(t, err, "publicTLS requires at least one DNS name")
+}
@@ -168,6 +168,17 @@ func (r *TLSRenewer) setCertificate(cert *tls.Certificate) {
 	r.renewMutex.Unlock()
 }
 
+// updateCertificate replaces the certificate with one that was obtained
+// externally and reschedules the renewal.
+func (r *TLSRenewer) updateCertificate(cert *tls.Certificate) {
+	r.setCertificate(cert)
+	r.renewMutex.Lock()
+	if r.timer != nil {
+		r.timer.Reset(r.nextRenewDuration(cert.Leaf.NotAfter))
+	}
+	r.renewMutex.Unlock()
+}
+
 func (r *TLSRenewer) renewCertificate() {
 	var next time.Duration
 	cert, err := r.RenewCertificate()
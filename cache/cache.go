@@ -0,0 +1,102 @@
+// Package cache implements the stores used for the high-churn ephemeral state
+// of the CA, like ACME nonces, rate-limit counters, the token replay cache, or
+// the responses of the requests with idempotency keys.
+// This state can be kept outside of the main database, in a shared store like
+// Redis, so multiple stateless instances of the CA can share it.
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Supported cache types.
+const (
+	// MemoryType keeps the state in memory. It can only be used with a single
+	// instance of the CA.
+	MemoryType = "memory"
+	// RedisType keeps the state in a Redis server.
+	RedisType = "redis"
+)
+
+// Store is the interface implemented by the ephemeral state stores. All the
+// keys stored have an expiration, once expired they are removed from the
+// store.
+type Store interface {
+	// SetNX sets the value of the given key only if it does not exist. It
+	// returns true if the key has been set.
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Set sets the value of the given key, replacing the existing one.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Get returns the value of the given key. It returns false if the key
+	// does not exist.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Delete deletes the given key. It returns true if the key existed.
+	Delete(ctx context.Context, key string) (bool, error)
+	// Incr increments the counter in the given key and returns the new value.
+	// The expiration is only set when the counter is created.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Close closes the store.
+	Close() error
+}
+
+// Options is the configuration of the ephemeral state store.
+type Options struct {
+	Type string `json:"type"`
+	// Address is the host and port of the Redis server.
+	Address string `json:"address,omitempty"`
+	// Username and Password are the credentials used to authenticate with the
+	// Redis server, both are optional.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Database is the Redis logical database to use.
+	Database int `json:"database,omitempty"`
+	// TLS enables TLS on the connections to the Redis server.
+	TLS bool `json:"tls,omitempty"`
+	// KeyPrefix is prepended to all the keys, it defaults to "step-ca:".
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+	// PoolSize is the maximum number of idle connections to the Redis server,
+	// it defaults to 10.
+	PoolSize int `json:"poolSize,omitempty"`
+}
+
+// Validate validates the cache options.
+func (o *Options) Validate() error {
+	switch {
+	case o == nil:
+		return nil
+	case o.Type == MemoryType:
+		return nil
+	case o.Type == RedisType:
+		if o.Address == "" {
+			return errors.New("cache.address cannot be empty")
+		}
+		if o.Database < 0 {
+			return errors.New("cache.database must be greater than or equal to 0")
+		}
+		if o.PoolSize < 0 {
+			return errors.New("cache.poolSize must be greater than or equal to 0")
+		}
+		return nil
+	default:
+		return errors.Errorf("unsupported cache.type %q", o.Type)
+	}
+}
+
+// New creates a new store with the given options.
+func New(o *Options) (Store, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	if o == nil {
+		return nil, errors.New("cache options cannot be empty")
+	}
+	switch o.Type {
+	case RedisType:
+		return NewRedis(o), nil
+	default:
+		return NewMemory(), nil
+	}
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *Options
+		wantErr string
+	}{
+		{"ok/nil", nil, ""},
+		{"ok/memory", &Options{Type: MemoryType}, ""},
+		{"ok/redis", &Options{Type: RedisType, Address: "localhost:6379", Database: 1, PoolSize: 5}, ""},
+		{"fail/type", &Options{Type: "memcached"}, `unsupported cache.type "memcached"`},
+		{"fail/address", &Options{Type: RedisType}, "cache.address cannot be empty"},
+		{"fail/database", &Options{Type: RedisType, Address: "localhost:6379", Database: -1}, "cache.database must be greater than or equal to 0"},
+		{"fail/poolSize", &Options{Type: RedisType, Address: "localhost:6379", PoolSize: -1}, "cache.poolSize must be greater than or equal to 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	s, err := New(&Options{Type: MemoryType})
+	require.NoError(t, err)
+	assert.IsType(t, &Memory{}, s)
+
+	s, err = New(&Options{Type: RedisType, Address: "localhost:6379"})
+	require.NoError(t, err)
+	assert.IsType(t, &Redis{}, s)
+
+	_, err = New(nil)
+	assert.EqualError(t, err, "cache options cannot be empty")
+	_, err = New(&Options{Type: "foo"})
+	assert.EqualError(t, err, `unsupported cache.type "foo"`)
+}
+
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+
+	ok, err := s.SetNX(ctx, "nonce", []byte("value"), time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = s.SetNX(ctx, "nonce", []byte("other"), time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	b, ok, err := s.Get(ctx, "nonce")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), b)
+	require.NoError(t, s.Set(ctx, "nonce", []byte("other"), time.Minute))
+	b, ok, err = s.Get(ctx, "nonce")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("other"), b)
+
+	ok, err = s.Delete(ctx, "nonce")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, ok, err = s.Get(ctx, "nonce")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = s.Delete(ctx, "nonce")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	for i := int64(1); i <= 3; i++ {
+		n, err := s.Incr(ctx, "counter", time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, i, n)
+	}
+
+	// Expired keys
+	ok, err = s.SetNX(ctx, "expired", nil, time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	n, err := s.Incr(ctx, "expired-counter", time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	time.Sleep(10 * time.Millisecond)
+	ok, err = s.SetNX(ctx, "expired", nil, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	n, err = s.Incr(ctx, "expired-counter", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	assert.NoError(t, s.Close())
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestMemory_sweep(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	for i := 0; i < sweepInterval-1; i++ {
+		_, err := m.Incr(ctx, string(rune(i)), time.Nanosecond)
+		require.NoError(t, err)
+	}
+	assert.Len(t, m.entries, sweepInterval-1)
+	time.Sleep(time.Millisecond)
+	_, err := m.SetNX(ctx, "key", nil, time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, m.entries, 1)
+}
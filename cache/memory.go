@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is the number of writes after which the expired entries are
+// removed from the memory store.
+const sweepInterval = 1024
+
+type memoryEntry struct {
+	value     []byte
+	counter   int64
+	expiresAt time.Time
+}
+
+// Memory is a Store that keeps all the keys in memory.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	writes  int
+}
+
+// NewMemory creates a new memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		entries: make(map[string]*memoryEntry),
+	}
+}
+
+// SetNX implements the Store interface.
+func (m *Memory) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if _, ok := m.get(key, now); ok {
+		return false, nil
+	}
+	m.set(key, &memoryEntry{value: value, expiresAt: now.Add(ttl)}, now)
+	return true, nil
+}
+
+// Set implements the Store interface.
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.set(key, &memoryEntry{value: value, expiresAt: now.Add(ttl)}, now)
+	return nil
+}
+
+// Get implements the Store interface.
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.get(key, time.Now())
+	if !ok {
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+// Delete implements the Store interface.
+func (m *Memory) Delete(_ context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.get(key, time.Now())
+	delete(m.entries, key)
+	return ok, nil
+}
+
+// Incr implements the Store interface.
+func (m *Memory) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	e, ok := m.get(key, now)
+	if !ok {
+		e = &memoryEntry{expiresAt: now.Add(ttl)}
+		m.set(key, e, now)
+	}
+	e.counter++
+	return e.counter, nil
+}
+
+// Close implements the Store interface.
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	m.entries = make(map[string]*memoryEntry)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) get(key string, now time.Time) (*memoryEntry, bool) {
+	e, ok := m.entries[key]
+	if !ok || !now.Before(e.expiresAt) {
+		return nil, false
+	}
+	return e, true
+}
+
+func (m *Memory) set(key string, e *memoryEntry, now time.Time) {
+	m.entries[key] = e
+	if m.writes++; m.writes >= sweepInterval {
+		m.writes = 0
+		for k, v := range m.entries {
+			if !now.Before(v.expiresAt) {
+				delete(m.entries, k)
+			}
+		}
+	}
+}
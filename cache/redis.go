@@ -0,0 +1,323 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultKeyPrefix    = "step-ca:"
+	defaultPoolSize     = 10
+	defaultRedisTimeout = 5 * time.Second
+)
+
+// redisError is an error reply from the Redis server.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// Redis is a Store backed by a Redis server. It implements the subset of the
+// Redis protocol (RESP) required by the Store interface, and keeps a pool of
+// idle connections to the server.
+type Redis struct {
+	address   string
+	username  string
+	password  string
+	database  int
+	tlsConfig *tls.Config
+	prefix    string
+	pool      chan *redisConn
+	dialer    *net.Dialer
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// NewRedis creates a new Redis store with the given options. Connections to the
+// server are created on demand.
+func NewRedis(o *Options) *Redis {
+	r := &Redis{
+		address:  o.Address,
+		username: o.Username,
+		password: o.Password,
+		database: o.Database,
+		prefix:   o.KeyPrefix,
+		dialer:   &net.Dialer{Timeout: defaultRedisTimeout},
+	}
+	if r.prefix == "" {
+		r.prefix = defaultKeyPrefix
+	}
+	if o.TLS {
+		host, _, err := net.SplitHostPort(o.Address)
+		if err != nil {
+			host = o.Address
+		}
+		r.tlsConfig = &tls.Config{
+			ServerName: host,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	size := o.PoolSize
+	if size == 0 {
+		size = defaultPoolSize
+	}
+	r.pool = make(chan *redisConn, size)
+	return r
+}
+
+// SetNX implements the Store interface using "SET key value NX PX ttl".
+func (r *Redis) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	v, err := r.do(ctx, "SET", r.prefix+key, string(value), "NX", "PX", milliseconds(ttl))
+	if err != nil {
+		return false, errors.Wrapf(err, "error setting %s", key)
+	}
+	return v != nil, nil
+}
+
+// Set implements the Store interface using "SET key value PX ttl".
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if _, err := r.do(ctx, "SET", r.prefix+key, string(value), "PX", milliseconds(ttl)); err != nil {
+		return errors.Wrapf(err, "error setting %s", key)
+	}
+	return nil
+}
+
+// Get implements the Store interface using "GET key".
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := r.do(ctx, "GET", r.prefix+key)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "error getting %s", key)
+	}
+	if v == nil {
+		return nil, false, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, false, errors.Errorf("error getting %s: unexpected reply %v", key, v)
+	}
+	return []byte(s), true, nil
+}
+
+// Delete implements the Store interface using "DEL key".
+func (r *Redis) Delete(ctx context.Context, key string) (bool, error) {
+	v, err := r.do(ctx, "DEL", r.prefix+key)
+	if err != nil {
+		return false, errors.Wrapf(err, "error deleting %s", key)
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return false, errors.Errorf("error deleting %s: unexpected reply %v", key, v)
+	}
+	return n > 0, nil
+}
+
+// Incr implements the Store interface using "INCR key", and "PEXPIRE key ttl"
+// when the counter is created.
+func (r *Redis) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	v, err := r.do(ctx, "INCR", r.prefix+key)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error incrementing %s", key)
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return 0, errors.Errorf("error incrementing %s: unexpected reply %v", key, v)
+	}
+	if n == 1 {
+		if _, err := r.do(ctx, "PEXPIRE", r.prefix+key, milliseconds(ttl)); err != nil {
+			return 0, errors.Wrapf(err, "error setting expiration of %s", key)
+		}
+	}
+	return n, nil
+}
+
+// Close closes all the idle connections.
+func (r *Redis) Close() error {
+	for {
+		select {
+		case c := <-r.pool:
+			c.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends a command to the server and returns the reply. Error replies are
+// returned as errors, nil replies as nil.
+func (r *Redis) do(ctx context.Context, args ...string) (interface{}, error) {
+	c, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v, err := c.do(ctx, args...)
+	switch {
+	case err == nil:
+		r.put(c)
+	case errors.As(err, new(redisError)):
+		r.put(c)
+	default:
+		// Do not reuse connections with network or protocol errors.
+		c.conn.Close()
+	}
+	return v, err
+}
+
+func (r *Redis) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-r.pool:
+		return c, nil
+	default:
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if r.tlsConfig != nil {
+		d := &tls.Dialer{NetDialer: r.dialer, Config: r.tlsConfig}
+		conn, err = d.DialContext(ctx, "tcp", r.address)
+	} else {
+		conn, err = r.dialer.DialContext(ctx, "tcp", r.address)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error connecting to redis %s", r.address)
+	}
+
+	c := &redisConn{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}
+	if r.password != "" {
+		args := []string{"AUTH", r.password}
+		if r.username != "" {
+			args = []string{"AUTH", r.username, r.password}
+		}
+		if _, err := c.do(ctx, args...); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "error authenticating with redis")
+		}
+	}
+	if r.database > 0 {
+		if _, err := c.do(ctx, "SELECT", strconv.Itoa(r.database)); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "error selecting redis database")
+		}
+	}
+	return c, nil
+}
+
+func (r *Redis) put(c *redisConn) {
+	select {
+	case r.pool <- c:
+	default:
+		c.conn.Close()
+	}
+}
+
+func (c *redisConn) do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultRedisTimeout)
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	return readReply(c.r)
+}
+
+// readReply reads a RESP reply. Simple strings and bulk strings are returned as
+// strings, integers as int64, arrays as []interface{}, and nil bulk strings
+// and arrays as nil.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "redis: invalid integer reply")
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errors.Wrap(err, "redis: invalid bulk string reply")
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errors.Wrap(err, "redis: invalid array reply")
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, errors.Errorf("redis: unexpected reply %q", line)
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errors.Errorf("redis: invalid reply %q", line)
+	}
+	return line[:len(line)-2], nil
+}
+
+func milliseconds(d time.Duration) string {
+	ms := d.Milliseconds()
+	if ms <= 0 {
+		ms = 1
+	}
+	return strconv.FormatInt(ms, 10)
+}
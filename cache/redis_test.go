@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is a minimal Redis server that implements the commands used by
+// the Redis store.
+type fakeRedis struct {
+	mu       sync.Mutex
+	password string
+	values   map[string]string
+	expires  map[string]time.Time
+	commands []string
+	ln       net.Listener
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f := &fakeRedis{
+		password: password,
+		values:   make(map[string]string),
+		expires:  make(map[string]time.Time),
+		ln:       ln,
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authenticated := f.password == ""
+	for {
+		v, err := readReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, a := range v.([]interface{}) {
+			args = append(args, a.(string))
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, strings.Join(args, " "))
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			if args[len(args)-1] == f.password {
+				authenticated = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		default:
+			reply = f.exec(args)
+		}
+		f.mu.Unlock()
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	key := ""
+	if len(args) > 1 {
+		key = args[1]
+		if exp, ok := f.expires[key]; ok && !time.Now().Before(exp) {
+			delete(f.values, key)
+			delete(f.expires, key)
+		}
+	}
+	switch args[0] {
+	case "SELECT":
+		return "+OK\r\n"
+	case "SET":
+		nx := args[3] == "NX"
+		if _, ok := f.values[key]; ok && nx {
+			return "$-1\r\n"
+		}
+		ms, _ := strconv.Atoi(args[len(args)-1])
+		f.values[key] = args[2]
+		f.expires[key] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return "+OK\r\n"
+	case "GET":
+		v, ok := f.values[key]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case "DEL":
+		if _, ok := f.values[key]; ok {
+			delete(f.values, key)
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "INCR":
+		n, _ := strconv.Atoi(f.values[key])
+		f.values[key] = strconv.Itoa(n + 1)
+		return fmt.Sprintf(":%d\r\n", n+1)
+	case "PEXPIRE":
+		ms, _ := strconv.Atoi(args[2])
+		f.expires[key] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return ":1\r\n"
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func TestRedis(t *testing.T) {
+	f := newFakeRedis(t, "")
+	testStore(t, NewRedis(&Options{Address: f.ln.Addr().String()}))
+	assert.Contains(t, f.commands, "SET step-ca:nonce value NX PX 60000")
+	assert.Contains(t, f.commands, "PEXPIRE step-ca:counter 60000")
+}
+
+func TestRedis_auth(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+	ctx := context.Background()
+
+	r := NewRedis(&Options{Address: f.ln.Addr().String(), Username: "step", Password: "secret", Database: 2, KeyPrefix: "test:"})
+	ok, err := r.SetNX(ctx, "key", []byte("value"), time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"AUTH step secret", "SELECT 2", "SET test:key value NX PX 60000"}, f.commands)
+
+	// The connection is reused.
+	_, err = r.Delete(ctx, "key")
+	require.NoError(t, err)
+	assert.Len(t, f.commands, 4)
+	require.NoError(t, r.Close())
+
+	r = NewRedis(&Options{Address: f.ln.Addr().String(), Password: "foo"})
+	_, err = r.SetNX(ctx, "key", nil, time.Minute)
+	assert.EqualError(t, err, "error setting key: error authenticating with redis: redis: WRONGPASS invalid password")
+
+	r = NewRedis(&Options{Address: f.ln.Addr().String()})
+	_, err = r.Incr(ctx, "key", time.Minute)
+	assert.EqualError(t, err, "error incrementing key: redis: NOAUTH Authentication required.")
+}
+
+func TestRedis_connect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	r := NewRedis(&Options{Address: addr})
+	_, err = r.Delete(context.Background(), "key")
+	assert.ErrorContains(t, err, "error deleting key: error connecting to redis "+addr)
+}
+
+func Test_readReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		want    interface{}
+		wantErr string
+	}{
+		{"ok/simple", "+OK\r\n", "OK", ""},
+		{"ok/integer", ":42\r\n", int64(42), ""},
+		{"ok/bulk", "$5\r\nhello\r\n", "hello", ""},
+		{"ok/nil", "$-1\r\n", nil, ""},
+		{"ok/array", "*2\r\n$1\r\na\r\n:1\r\n", []interface{}{"a", int64(1)}, ""},
+		{"ok/nil-array", "*-1\r\n", nil, ""},
+		{"fail/error", "-ERR foo\r\n", nil, "redis: ERR foo"},
+		{"fail/integer", ":a\r\n", nil, `redis: invalid integer reply: strconv.ParseInt: parsing "a": invalid syntax`},
+		{"fail/line", "+OK\n", nil, `redis: invalid reply "+OK\n"`},
+		{"fail/type", "?\r\n", nil, `redis: unexpected reply "?"`},
+		{"fail/bulk", "$5\r\nhe", nil, "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readReply(bufio.NewReader(strings.NewReader(tt.reply)))
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package acmecas
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/pemutil"
+	"golang.org/x/crypto/acme"
+
+	"github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/internal/secret"
+	"github.com/smallstep/certificates/logging"
+)
+
+func init() {
+	apiv1.Register(apiv1.ACMECAS, func(ctx context.Context, opts apiv1.Options) (apiv1.CertificateAuthorityService, error) {
+		return New(ctx, opts)
+	})
+}
+
+// Supported challenge types.
+const (
+	HTTP01 = "http-01"
+	DNS01  = "dns-01"
+)
+
+// Defaults used if they are not configured.
+const (
+	DefaultTimeout          = 2 * time.Minute
+	DefaultPropagationDelay = 30 * time.Second
+)
+
+// Options defines the configuration options added using the apiv1.Options.Config
+// field.
+//
+// The http-01 challenge writes the key authorizations in the
+// .well-known/acme-challenge directory of the webroot, that must be served by
+// the web server of the domains. With SelfSolve the key authorizations are
+// kept in memory instead, and they are served by the insecure server of the
+// CA, so the domains must point to it on port 80. The dns-01 challenge uses
+// the DNSProvider registered with the given name and configuration. DNSHook is a shortcut for
+// the "exec" provider, it runs the command with the arguments "present" or
+// "cleanup", the name of the TXT record and its value.
+type Options struct {
+	AccountKey        string          `json:"accountKey,omitempty"`
+	Email             string          `json:"email,omitempty"`
+	EABKeyID          string          `json:"eabKeyID,omitempty"`
+	EABHMACKey        string          `json:"eabHMACKey,omitempty"`
+	ChallengeType     string          `json:"challengeType,omitempty"`
+	Webroot           string          `json:"webroot,omitempty"`
+	SelfSolve         bool            `json:"selfSolve,omitempty"`
+	DNSHook           string          `json:"dnsHook,omitempty"`
+	DNSProvider       string          `json:"dnsProvider,omitempty"`
+	DNSProviderConfig json.RawMessage `json:"dnsProviderConfig,omitempty"`
+	PropagationDelay  string          `json:"propagationDelay,omitempty"`
+	Timeout           string          `json:"timeout,omitempty"`
+}
+
+// ACMECAS implements a Certificate Authority Service using an upstream ACME
+// certificate authority. The local authority authorizes the requests as
+// usual, and then it orders the certificates upstream using its own ACME
+// account. The validity of the certificates is defined by the upstream
+// authority.
+type ACMECAS struct {
+	client           *acme.Client
+	challengeType    string
+	webroot          string
+	selfSolve        bool
+	dns              DNSProvider
+	propagationDelay time.Duration
+	timeout          time.Duration
+}
+
+// New creates a new CertificateAuthorityService implementation using an ACME
+// certificate authority. The account is registered if it does not exist.
+func New(ctx context.Context, opts apiv1.Options) (*ACMECAS, error) {
+	if opts.CertificateAuthority == "" {
+		return nil, errors.New("acmeCAS 'certificateAuthority' cannot be empty")
+	}
+
+	var o Options
+	if len(opts.Config) > 0 {
+		if err := json.Unmarshal(opts.Config, &o); err != nil {
+			return nil, errors.Wrap(err, "error decoding acmeCAS config")
+		}
+	}
+
+	// The EAB key can be a reference to a secret source.
+	hmacKey, err := secret.Resolve(ctx, o.EABHMACKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading acmeCAS 'eabHMACKey'")
+	}
+	o.EABHMACKey = hmacKey
+
+	c := &ACMECAS{
+		challengeType:    o.ChallengeType,
+		webroot:          o.Webroot,
+		selfSolve:        o.SelfSolve,
+		propagationDelay: DefaultPropagationDelay,
+		timeout:          DefaultTimeout,
+	}
+	switch c.challengeType {
+	case "", HTTP01:
+		c.challengeType = HTTP01
+		if c.webroot == "" && !c.selfSolve {
+			return nil, errors.New("acmeCAS 'webroot' cannot be empty with the http-01 challenge")
+		}
+	case DNS01:
+		var err error
+		switch {
+		case o.DNSProvider != "":
+			c.dns, err = NewDNSProvider(o.DNSProvider, o.DNSProviderConfig)
+		case o.DNSHook != "":
+			c.dns = &execProvider{Command: o.DNSHook}
+		default:
+			err = errors.New("acmeCAS 'dnsHook' or 'dnsProvider' cannot be empty with the dns-01 challenge")
+		}
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("acmeCAS 'challengeType' %s is not supported", c.challengeType)
+	}
+	if o.PropagationDelay != "" {
+		d, err := time.ParseDuration(o.PropagationDelay)
+		if err != nil {
+			return nil, errors.Wrap(err, "acmeCAS 'propagationDelay' is not valid")
+		}
+		c.propagationDelay = d
+	}
+	if o.Timeout != "" {
+		d, err := time.ParseDuration(o.Timeout)
+		if err != nil || d <= 0 {
+			return nil, errors.Errorf("acmeCAS 'timeout' %q is not valid", o.Timeout)
+		}
+		c.timeout = d
+	}
+
+	var key crypto.Signer
+	if o.AccountKey != "" {
+		k, err := pemutil.Read(o.AccountKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading acmeCAS 'accountKey'")
+		}
+		var ok bool
+		if key, ok = k.(crypto.Signer); !ok {
+			return nil, errors.New("acmeCAS 'accountKey' is not a private key")
+		}
+	} else {
+		k, err := keyutil.GenerateDefaultSigner()
+		if err != nil {
+			return nil, err
+		}
+		key = k
+	}
+
+	c.client = &acme.Client{
+		Key:          key,
+		DirectoryURL: opts.CertificateAuthority,
+		UserAgent:    "step-ca",
+		HTTPClient: &http.Client{
+			Transport: logging.NewTraceTransport(nil),
+		},
+	}
+
+	acct := new(acme.Account)
+	if o.Email != "" {
+		acct.Contact = []string{"mailto:" + o.Email}
+	}
+	if o.EABKeyID != "" {
+		hmacKey, err := base64.RawURLEncoding.DecodeString(o.EABHMACKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "acmeCAS 'eabHMACKey' is not valid")
+		}
+		acct.ExternalAccountBinding = &acme.ExternalAccountBinding{
+			KID: o.EABKeyID,
+			Key: hmacKey,
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	if _, err := c.client.Register(ctx, acct, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, errors.Wrap(err, "error registering acmeCAS account")
+	}
+
+	return c, nil
+}
+
+// CreateCertificate orders a new certificate to the upstream ACME authority
+// using the names in the template, that must match the ones in the
+// certificate request.
+func (c *ACMECAS) CreateCertificate(req *apiv1.CreateCertificateRequest) (*apiv1.CreateCertificateResponse, error) {
+	switch {
+	case req.CSR == nil:
+		return nil, errors.New("createCertificateRequest `csr` cannot be nil")
+	case req.Template == nil:
+		return nil, errors.New("createCertificateRequest `template` cannot be nil")
+	}
+
+	ids, err := identifiers(req.Template, req.CSR)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(apiv1.RequestContext(req.Context), c.timeout)
+	defer cancel()
+
+	order, err := c.client.AuthorizeOrder(ctx, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating acme order")
+	}
+	for _, u := range order.AuthzURLs {
+		if err := c.authorize(ctx, u); err != nil {
+			return nil, err
+		}
+	}
+	if order, err = c.client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, errors.Wrap(err, "error waiting for acme order")
+	}
+
+	der, _, err := c.client.CreateOrderCert(ctx, order.FinalizeURL, req.CSR.Raw, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "error finalizing acme order")
+	}
+	certs := make([]*x509.Certificate, len(der))
+	for i, b := range der {
+		if certs[i], err = x509.ParseCertificate(b); err != nil {
+			return nil, errors.Wrap(err, "error parsing acme certificate")
+		}
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("acme authority did not return a certificate")
+	}
+
+	return &apiv1.CreateCertificateResponse{
+		Certificate:      certs[0],
+		CertificateChain: certs[1:],
+	}, nil
+}
+
+// RenewCertificate will always return a non-implemented error as ACME
+// authorities do not support renewals, a new certificate must be requested.
+func (c *ACMECAS) RenewCertificate(*apiv1.RenewCertificateRequest) (*apiv1.RenewCertificateResponse, error) {
+	return nil, apiv1.NotImplementedError{Message: "acmeCAS does not support renewals"}
+}
+
+// RevokeCertificate revokes the given certificate in the upstream authority.
+func (c *ACMECAS) RevokeCertificate(req *apiv1.RevokeCertificateRequest) (*apiv1.RevokeCertificateResponse, error) {
+	if req.Certificate == nil {
+		return nil, apiv1.ValidationError{Message: "revokeCertificateRequest `certificate` cannot be nil"}
+	}
+
+	ctx, cancel := context.WithTimeout(apiv1.RequestContext(req.Context), c.timeout)
+	defer cancel()
+	if err := c.client.RevokeCert(ctx, nil, req.Certificate.Raw, acme.CRLReasonCode(req.ReasonCode)); err != nil {
+		return nil, errors.Wrap(err, "error revoking acme certificate")
+	}
+
+	return &apiv1.RevokeCertificateResponse{
+		Certificate:      req.Certificate,
+		CertificateChain: nil,
+	}, nil
+}
+
+// authorize solves the configured challenge of the given authorization if it
+// is not valid yet.
+func (c *ACMECAS) authorize(ctx context.Context, u string) error {
+	authz, err := c.client.GetAuthorization(ctx, u)
+	if err != nil {
+		return errors.Wrap(err, "error getting acme authorization")
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var chal *acme.Challenge
+	for _, ch := range authz.Challenges {
+		if ch.Type == c.challengeType {
+			chal = ch
+			break
+		}
+	}
+	if chal == nil {
+		return errors.Errorf("acme authority does not offer the %s challenge for %s", c.challengeType, authz.Identifier.Value)
+	}
+
+	cleanup, err := c.present(ctx, authz.Identifier.Value, chal)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if _, err := c.client.Accept(ctx, chal); err != nil {
+		return errors.Wrapf(err, "error accepting acme challenge for %s", authz.Identifier.Value)
+	}
+	if _, err := c.client.WaitAuthorization(ctx, u); err != nil {
+		return errors.Wrapf(err, "error validating acme challenge for %s", authz.Identifier.Value)
+	}
+	return nil
+}
+
+// present prepares the response of a challenge and returns a function to
+// remove it.
+func (c *ACMECAS) present(ctx context.Context, domain string, chal *acme.Challenge) (func(), error) {
+	switch chal.Type {
+	case HTTP01:
+		keyAuth, err := c.client.HTTP01ChallengeResponse(chal.Token)
+		if err != nil {
+			return nil, err
+		}
+		if c.selfSolve {
+			selfSolver.present(chal.Token, keyAuth)
+			return func() { selfSolver.cleanup(chal.Token) }, nil
+		}
+		name := filepath.Join(c.webroot, filepath.FromSlash(c.client.HTTP01ChallengePath(chal.Token)))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return nil, errors.Wrap(err, "error creating acme challenge directory")
+		}
+		if err := os.WriteFile(name, []byte(keyAuth), 0644); err != nil { //nolint:gosec // the response must be public
+			return nil, errors.Wrap(err, "error writing acme challenge")
+		}
+		return func() { os.Remove(name) }, nil
+	default:
+		value, err := c.client.DNS01ChallengeRecord(chal.Token)
+		if err != nil {
+			return nil, err
+		}
+		fqdn := "_acme-challenge." + domain + "."
+		if err := c.dns.Present(ctx, fqdn, value); err != nil {
+			return nil, errors.Wrap(err, "error presenting acme dns record")
+		}
+		cleanup := func() {
+			_ = c.dns.CleanUp(context.Background(), fqdn, value)
+		}
+		select {
+		case <-time.After(c.propagationDelay):
+			return cleanup, nil
+		case <-ctx.Done():
+			cleanup()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// identifiers returns the ACME identifiers of the template. The names in the
+// template must be the same as the ones in the certificate request, because
+// the upstream authority only uses the request.
+func identifiers(tpl *x509.Certificate, csr *x509.CertificateRequest) ([]acme.AuthzID, error) {
+	if len(tpl.EmailAddresses) > 0 || len(tpl.URIs) > 0 {
+		return nil, apiv1.ValidationError{Message: "acmeCAS only supports DNS names and IP addresses"}
+	}
+	names := func(dnsNames []string, ips []net.IP) []string {
+		ret := append([]string{}, dnsNames...)
+		for _, ip := range ips {
+			ret = append(ret, ip.String())
+		}
+		sort.Strings(ret)
+		return ret
+	}
+	want, got := names(tpl.DNSNames, tpl.IPAddresses), names(csr.DNSNames, csr.IPAddresses)
+	if len(want) == 0 {
+		return nil, apiv1.ValidationError{Message: "acmeCAS requires at least one DNS name or IP address"}
+	}
+	if len(want) != len(got) {
+		return nil, apiv1.ValidationError{Message: "acmeCAS requires the same names in the template and the certificate request"}
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			return nil, apiv1.ValidationError{Message: "acmeCAS requires the same names in the template and the certificate request"}
+		}
+	}
+
+	ids := make([]acme.AuthzID, 0, len(want))
+	for _, name := range tpl.DNSNames {
+		ids = append(ids, acme.AuthzID{Type: "dns", Value: name})
+	}
+	for _, ip := range tpl.IPAddresses {
+		ids = append(ids, acme.AuthzID{Type: "ip", Value: ip.String()})
+	}
+	return ids, nil
+}
@@ -0,0 +1,388 @@
+package acmecas
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+	"go.step.sm/crypto/x509util"
+	"golang.org/x/crypto/acme"
+
+	"github.com/smallstep/certificates/cas/apiv1"
+)
+
+// fakeACME is a minimal ACME server that validates http-01 challenges
+// reading the key authorization from the webroot.
+type fakeACME struct {
+	t       *testing.T
+	ca      *minica.CA
+	webroot string
+	mu      sync.Mutex
+	srv     *httptest.Server
+	authzOK bool
+	order   map[string]any
+	cert    *x509.Certificate
+	revoked []byte
+}
+
+func newFakeACME(t *testing.T, webroot string) *fakeACME {
+	t.Helper()
+	ca, err := minica.New()
+	require.NoError(t, err)
+	f := &fakeACME{t: t, ca: ca, webroot: webroot}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeACME) url(p string) string {
+	return f.srv.URL + p
+}
+
+func (f *fakeACME) payload(r *http.Request) []byte {
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	require.NoError(f.t, json.NewDecoder(r.Body).Decode(&jws))
+	b, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	require.NoError(f.t, err)
+	return b
+}
+
+func (f *fakeACME) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("Replay-Nonce", "nonce")
+	writeJSON := func(status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(v)
+	}
+
+	switch r.URL.Path {
+	case "/directory":
+		writeJSON(200, map[string]string{
+			"newNonce":   f.url("/nonce"),
+			"newAccount": f.url("/account"),
+			"newOrder":   f.url("/order"),
+			"revokeCert": f.url("/revoke"),
+		})
+	case "/nonce":
+		w.WriteHeader(200)
+	case "/account":
+		w.Header().Set("Location", f.url("/account/1"))
+		writeJSON(201, map[string]any{"status": "valid"})
+	case "/order":
+		var req struct {
+			Identifiers []map[string]string `json:"identifiers"`
+		}
+		require.NoError(f.t, json.Unmarshal(f.payload(r), &req))
+		f.order = map[string]any{
+			"status":         "pending",
+			"identifiers":    req.Identifiers,
+			"authorizations": []string{f.url("/authz/1")},
+			"finalize":       f.url("/finalize/1"),
+		}
+		w.Header().Set("Location", f.url("/order/1"))
+		writeJSON(201, f.order)
+	case "/order/1":
+		f.payload(r)
+		w.Header().Set("Location", f.url("/order/1"))
+		writeJSON(200, f.order)
+	case "/authz/1":
+		f.payload(r)
+		status := "pending"
+		if f.authzOK {
+			status = "valid"
+		}
+		writeJSON(200, map[string]any{
+			"status":     status,
+			"identifier": map[string]string{"type": "dns", "value": "www.example.com"},
+			"challenges": []map[string]string{
+				{"type": "dns-01", "url": f.url("/chall/2"), "token": "token", "status": "pending"},
+				{"type": "http-01", "url": f.url("/chall/1"), "token": "token", "status": "pending"},
+			},
+		})
+	case "/chall/1":
+		f.payload(r)
+		b, err := os.ReadFile(filepath.Join(f.webroot, ".well-known", "acme-challenge", "token"))
+		if err == nil && strings.HasPrefix(string(b), "token.") {
+			f.authzOK = true
+			f.order["status"] = "ready"
+		}
+		writeJSON(200, map[string]string{"type": "http-01", "url": f.url("/chall/1"), "token": "token", "status": "processing"})
+	case "/finalize/1":
+		var req struct {
+			CSR string `json:"csr"`
+		}
+		require.NoError(f.t, json.Unmarshal(f.payload(r), &req))
+		der, err := base64.RawURLEncoding.DecodeString(req.CSR)
+		require.NoError(f.t, err)
+		csr, err := x509.ParseCertificateRequest(der)
+		require.NoError(f.t, err)
+		if f.cert, err = f.ca.SignCSR(csr); err != nil {
+			writeJSON(500, map[string]string{"type": "urn:ietf:params:acme:error:serverInternal"})
+			return
+		}
+		f.order["status"] = "valid"
+		f.order["certificate"] = f.url("/cert/1")
+		w.Header().Set("Location", f.url("/order/1"))
+		writeJSON(200, f.order)
+	case "/cert/1":
+		f.payload(r)
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: f.cert.Raw})
+		_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: f.ca.Intermediate.Raw})
+	case "/revoke":
+		var req struct {
+			Certificate string `json:"certificate"`
+		}
+		require.NoError(f.t, json.Unmarshal(f.payload(r), &req))
+		f.revoked, _ = base64.RawURLEncoding.DecodeString(req.Certificate)
+		w.WriteHeader(200)
+	default:
+		writeJSON(404, map[string]string{"type": "urn:ietf:params:acme:error:malformed"})
+	}
+}
+
+func mustConfig(t *testing.T, v any) json.RawMessage {
+	t.Helper()
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return b
+}
+
+func TestNew(t *testing.T) {
+	webroot := t.TempDir()
+	f := newFakeACME(t, webroot)
+
+	tests := []struct {
+		name    string
+		opts    apiv1.Options
+		wantErr string
+	}{
+		{"ok", apiv1.Options{CertificateAuthority: f.url("/directory"), Config: mustConfig(t, Options{Webroot: webroot, Email: "jane@example.com"})}, ""},
+		{"ok/selfSolve", apiv1.Options{CertificateAuthority: f.url("/directory"), Config: mustConfig(t, Options{SelfSolve: true})}, ""},
+		{"ok/dns-01", apiv1.Options{CertificateAuthority: f.url("/directory"), Config: mustConfig(t, Options{ChallengeType: "dns-01", DNSHook: "/bin/true", PropagationDelay: "1s"})}, ""},
+		{"fail/certificateAuthority", apiv1.Options{}, "acmeCAS 'certificateAuthority' cannot be empty"},
+		{"fail/config", apiv1.Options{CertificateAuthority: f.url("/directory"), Config: json.RawMessage("{")}, "error decoding acmeCAS config: unexpected end of JSON input"},
+		{"fail/webroot", apiv1.Options{CertificateAuthority: f.url("/directory")}, "acmeCAS 'webroot' cannot be empty with the http-01 challenge"},
+		{"fail/dnsHook", apiv1.Options{CertificateAuthority: f.url("/directory"), Config: mustConfig(t, Options{ChallengeType: "dns-01"})}, "acmeCAS 'dnsHook' or 'dnsProvider' cannot be empty with the dns-01 challenge"},
+		{"ok/dnsProvider", apiv1.Options{CertificateAuthority: f.url("/directory"), Config: mustConfig(t, Options{ChallengeType: "dns-01", DNSProvider: "exec", DNSProviderConfig: json.RawMessage(`{"command":"/bin/true"}`)})}, ""},
+		{"fail/dnsProvider", apiv1.Options{CertificateAuthority: f.url("/directory"), Config: mustConfig(t, Options{ChallengeType: "dns-01", DNSProvider: "powerdns"})}, "dns provider powerdns is not supported"},
+		{"fail/dnsProviderConfig", apiv1.Options{CertificateAuthority: f.url("/directory"), Config: mustConfig(t, Options{ChallengeType: "dns-01", DNSProvider: "exec"})}, "exec dns provider command cannot be empty"},
+		{"fail/rfc2136", apiv1.Options{CertificateAuthority: f.url("/directory"), Config: mustConfig(t, Options{ChallengeType: "dns-01", DNSProvider: "rfc2136", DNSProviderConfig: json.RawMessage(`{"nameserver":"127.0.0.1"}`)})}, "rfc2136 dns provider zone cannot be empty"},
+		{"fail/challengeType", apiv1.Options{CertificateAuthority: f.url("/directory"), Config: mustConfig(t, Options{ChallengeType: "tls-alpn-01"})}, "acmeCAS 'challengeType' tls-alpn-01 is not supported"},
+		{"fail/timeout", apiv1.Options{CertificateAuthority: f.url("/directory"), Config: mustConfig(t, Options{Webroot: webroot, Timeout: "0s"})}, `acmeCAS 'timeout' "0s" is not valid`},
+		{"fail/accountKey", apiv1.Options{CertificateAuthority: f.url("/directory"), Config: mustConfig(t, Options{Webroot: webroot, AccountKey: filepath.Join(webroot, "missing.key")})}, "error reading acmeCAS 'accountKey'"},
+		{"fail/eabHMACKey", apiv1.Options{CertificateAuthority: f.url("/directory"), Config: mustConfig(t, Options{Webroot: webroot, EABKeyID: "kid", EABHMACKey: "%%"})}, "acmeCAS 'eabHMACKey' is not valid"},
+		{"fail/register", apiv1.Options{CertificateAuthority: f.url("/missing"), Config: mustConfig(t, Options{Webroot: webroot})}, "error registering acmeCAS account"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(context.Background(), tt.opts)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestACMECAS_CreateCertificate(t *testing.T) {
+	webroot := t.TempDir()
+	f := newFakeACME(t, webroot)
+	c, err := New(context.Background(), apiv1.Options{
+		CertificateAuthority: f.url("/directory"),
+		Config:               mustConfig(t, Options{Webroot: webroot}),
+	})
+	require.NoError(t, err)
+
+	signer, err := keyutil.GenerateDefaultSigner()
+	require.NoError(t, err)
+	csr, err := x509util.CreateCertificateRequest("www.example.com", []string{"www.example.com"}, signer)
+	require.NoError(t, err)
+
+	resp, err := c.CreateCertificate(&apiv1.CreateCertificateRequest{
+		Template: &x509.Certificate{DNSNames: []string{"www.example.com"}},
+		CSR:      csr,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"www.example.com"}, resp.Certificate.DNSNames)
+	assert.Equal(t, []*x509.Certificate{f.ca.Intermediate}, resp.CertificateChain)
+	assert.Equal(t, []map[string]string{{"type": "dns", "value": "www.example.com"}}, f.order["identifiers"])
+
+	// The challenge response is removed.
+	_, err = os.Stat(filepath.Join(webroot, ".well-known", "acme-challenge", "token"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Revocation
+	_, err = c.RevokeCertificate(&apiv1.RevokeCertificateRequest{Certificate: resp.Certificate, ReasonCode: 1})
+	require.NoError(t, err)
+	assert.Equal(t, resp.Certificate.Raw, f.revoked)
+
+	_, err = c.RevokeCertificate(&apiv1.RevokeCertificateRequest{SerialNumber: "1234"})
+	assert.EqualError(t, err, "revokeCertificateRequest `certificate` cannot be nil")
+
+	_, err = c.RenewCertificate(&apiv1.RenewCertificateRequest{})
+	assert.EqualError(t, err, "acmeCAS does not support renewals")
+}
+
+func TestACMECAS_present_dns01(t *testing.T) {
+	f := newFakeACME(t, t.TempDir())
+	hook := filepath.Join(t.TempDir(), "hook.sh")
+	out := filepath.Join(t.TempDir(), "hook.out")
+	require.NoError(t, os.WriteFile(hook, []byte(fmt.Sprintf("#!/bin/sh\necho \"$@\" >> %s\n", out)), 0700)) //nolint:gosec // test script
+
+	c, err := New(context.Background(), apiv1.Options{
+		CertificateAuthority: f.url("/directory"),
+		Config:               mustConfig(t, Options{ChallengeType: "dns-01", DNSHook: hook, PropagationDelay: "0s"}),
+	})
+	require.NoError(t, err)
+
+	cleanup, err := c.present(context.Background(), "www.example.com", &acme.Challenge{Type: DNS01, Token: "token"})
+	require.NoError(t, err)
+	cleanup()
+
+	value, err := c.client.DNS01ChallengeRecord("token")
+	require.NoError(t, err)
+	b, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("present _acme-challenge.www.example.com. %[1]s\ncleanup _acme-challenge.www.example.com. %[1]s\n", value), string(b))
+
+	// Hook errors
+	c.dns = &execProvider{Command: filepath.Join(t.TempDir(), "missing.sh")}
+	_, err = c.present(context.Background(), "www.example.com", &acme.Challenge{Type: DNS01, Token: "token"})
+	assert.ErrorContains(t, err, "error presenting acme dns record")
+}
+
+func TestACMECAS_present_selfSolve(t *testing.T) {
+	f := newFakeACME(t, t.TempDir())
+	c, err := New(context.Background(), apiv1.Options{
+		CertificateAuthority: f.url("/directory"),
+		Config:               mustConfig(t, Options{SelfSolve: true}),
+	})
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(HTTP01Handler())
+	defer srv.Close()
+	get := func(path string) (int, string) {
+		resp, err := http.Get(srv.URL + path) //nolint:gosec // test server
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(b)
+	}
+
+	cleanup, err := c.present(context.Background(), "www.example.com", &acme.Challenge{Type: HTTP01, Token: "token"})
+	require.NoError(t, err)
+	keyAuth, err := c.client.HTTP01ChallengeResponse("token")
+	require.NoError(t, err)
+
+	code, body := get("/.well-known/acme-challenge/token")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, keyAuth, body)
+	code, _ = get("/.well-known/acme-challenge/other")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = get("/token")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	cleanup()
+	code, _ = get("/.well-known/acme-challenge/token")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+type recordingProvider struct {
+	records []string
+}
+
+func (p *recordingProvider) Present(ctx context.Context, fqdn, value string) error {
+	p.records = append(p.records, "present "+fqdn+" "+value)
+	return nil
+}
+
+func (p *recordingProvider) CleanUp(ctx context.Context, fqdn, value string) error {
+	p.records = append(p.records, "cleanup "+fqdn+" "+value)
+	return nil
+}
+
+func TestRegisterDNSProvider(t *testing.T) {
+	p := new(recordingProvider)
+	RegisterDNSProvider("recording", func(config json.RawMessage) (DNSProvider, error) {
+		return p, nil
+	})
+
+	f := newFakeACME(t, t.TempDir())
+	c, err := New(context.Background(), apiv1.Options{
+		CertificateAuthority: f.url("/directory"),
+		Config:               mustConfig(t, Options{ChallengeType: "dns-01", DNSProvider: "recording", PropagationDelay: "0s"}),
+	})
+	require.NoError(t, err)
+
+	cleanup, err := c.present(context.Background(), "www.example.com", &acme.Challenge{Type: DNS01, Token: "token"})
+	require.NoError(t, err)
+	cleanup()
+
+	value, err := c.client.DNS01ChallengeRecord("token")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"present _acme-challenge.www.example.com. " + value,
+		"cleanup _acme-challenge.www.example.com. " + value,
+	}, p.records)
+}
+
+func Test_identifiers(t *testing.T) {
+	signer, err := keyutil.GenerateDefaultSigner()
+	require.NoError(t, err)
+	csr, err := x509util.CreateCertificateRequest("www.example.com", []string{"www.example.com", "10.0.0.1"}, signer)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		tpl     *x509.Certificate
+		want    []map[string]string
+		wantErr string
+	}{
+		{"ok", &x509.Certificate{DNSNames: []string{"www.example.com"}, IPAddresses: []net.IP{net.ParseIP("10.0.0.1")}}, []map[string]string{
+			{"type": "dns", "value": "www.example.com"}, {"type": "ip", "value": "10.0.0.1"},
+		}, ""},
+		{"fail/email", &x509.Certificate{EmailAddresses: []string{"jane@example.com"}}, nil, "acmeCAS only supports DNS names and IP addresses"},
+		{"fail/empty", &x509.Certificate{}, nil, "acmeCAS requires at least one DNS name or IP address"},
+		{"fail/length", &x509.Certificate{DNSNames: []string{"www.example.com"}}, nil, "acmeCAS requires the same names in the template and the certificate request"},
+		{"fail/names", &x509.Certificate{DNSNames: []string{"www.example.org"}, IPAddresses: []net.IP{net.ParseIP("10.0.0.1")}}, nil, "acmeCAS requires the same names in the template and the certificate request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := identifiers(tt.tpl, csr)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			var got []map[string]string
+			for _, id := range ids {
+				got = append(got, map[string]string{"type": id.Type, "value": id.Value})
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package acmecas
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ExecDNSProvider is the name of the DNS provider that runs a command.
+const ExecDNSProvider = "exec"
+
+// DNSProvider is the interface implemented by the providers used to solve
+// dns-01 challenges. Present creates the TXT record with the given fully
+// qualified name and value, and CleanUp removes it.
+type DNSProvider interface {
+	Present(ctx context.Context, fqdn, value string) error
+	CleanUp(ctx context.Context, fqdn, value string) error
+}
+
+// DNSProviderNewFunc is the type of the functions that create a DNS provider
+// with the given configuration.
+type DNSProviderNewFunc func(config json.RawMessage) (DNSProvider, error)
+
+var dnsProviders = new(sync.Map)
+
+func init() {
+	RegisterDNSProvider(ExecDNSProvider, func(config json.RawMessage) (DNSProvider, error) {
+		var p execProvider
+		if len(config) > 0 {
+			if err := json.Unmarshal(config, &p); err != nil {
+				return nil, errors.Wrap(err, "error decoding exec dns provider config")
+			}
+		}
+		if p.Command == "" {
+			return nil, errors.New("exec dns provider command cannot be empty")
+		}
+		return &p, nil
+	})
+}
+
+// RegisterDNSProvider adds a new DNS provider to the registry. Packages
+// implementing a provider call this method in their init function.
+func RegisterDNSProvider(name string, fn DNSProviderNewFunc) {
+	dnsProviders.Store(name, fn)
+}
+
+// NewDNSProvider creates a new DNS provider of the given type.
+func NewDNSProvider(name string, config json.RawMessage) (DNSProvider, error) {
+	v, ok := dnsProviders.Load(name)
+	if !ok {
+		return nil, errors.Errorf("dns provider %s is not supported", name)
+	}
+	return v.(DNSProviderNewFunc)(config)
+}
+
+// execProvider is a DNS provider that runs a command with the arguments
+// "present" or "cleanup", the name of the TXT record and its value.
+type execProvider struct {
+	Command string `json:"command"`
+}
+
+func (p *execProvider) Present(ctx context.Context, fqdn, value string) error {
+	return p.run(ctx, "present", fqdn, value)
+}
+
+func (p *execProvider) CleanUp(ctx context.Context, fqdn, value string) error {
+	return p.run(ctx, "cleanup", fqdn, value)
+}
+
+func (p *execProvider) run(ctx context.Context, action, fqdn, value string) error {
+	if out, err := exec.CommandContext(ctx, p.Command, action, fqdn, value).CombinedOutput(); err != nil { //nolint:gosec // the command is configured by the administrator
+		return errors.Wrapf(err, "error running %s: %s", p.Command, out)
+	}
+	return nil
+}
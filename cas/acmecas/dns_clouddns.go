@@ -0,0 +1,98 @@
+package acmecas
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+	dns "google.golang.org/api/dns/v1"
+	"google.golang.org/api/option"
+)
+
+// CloudDNSProvider is the name of the DNS provider that creates the records
+// in Google Cloud DNS.
+const CloudDNSProvider = "clouddns"
+
+const defaultCloudDNSTTL = 60
+
+func init() {
+	RegisterDNSProvider(CloudDNSProvider, func(config json.RawMessage) (DNSProvider, error) {
+		return newCloudDNSProvider(context.Background(), config)
+	})
+}
+
+// cloudDNSConfig is the configuration of the Cloud DNS provider. By default,
+// the client uses the application default credentials.
+type cloudDNSConfig struct {
+	Project         string `json:"project"`
+	ManagedZone     string `json:"managedZone"`
+	CredentialsFile string `json:"credentialsFile,omitempty"`
+	TTL             int64  `json:"ttl,omitempty"`
+}
+
+// cloudDNSProvider is a DNS provider that uses Google Cloud DNS.
+type cloudDNSProvider struct {
+	service     *dns.Service
+	project     string
+	managedZone string
+	ttl         int64
+}
+
+func newCloudDNSProvider(ctx context.Context, config json.RawMessage, opts ...option.ClientOption) (*cloudDNSProvider, error) {
+	var c cloudDNSConfig
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, errors.Wrap(err, "error decoding clouddns dns provider config")
+		}
+	}
+	switch {
+	case c.Project == "":
+		return nil, errors.New("clouddns dns provider project cannot be empty")
+	case c.ManagedZone == "":
+		return nil, errors.New("clouddns dns provider managedZone cannot be empty")
+	}
+
+	if c.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(c.CredentialsFile))
+	}
+	service, err := dns.NewService(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating clouddns client")
+	}
+
+	p := &cloudDNSProvider{
+		service:     service,
+		project:     c.Project,
+		managedZone: c.ManagedZone,
+		ttl:         c.TTL,
+	}
+	if p.ttl == 0 {
+		p.ttl = defaultCloudDNSTTL
+	}
+	return p, nil
+}
+
+func (p *cloudDNSProvider) Present(ctx context.Context, fqdn, value string) error {
+	return p.change(ctx, &dns.Change{Additions: []*dns.ResourceRecordSet{p.recordSet(fqdn, value)}})
+}
+
+func (p *cloudDNSProvider) CleanUp(ctx context.Context, fqdn, value string) error {
+	return p.change(ctx, &dns.Change{Deletions: []*dns.ResourceRecordSet{p.recordSet(fqdn, value)}})
+}
+
+func (p *cloudDNSProvider) recordSet(fqdn, value string) *dns.ResourceRecordSet {
+	return &dns.ResourceRecordSet{
+		Name:    fqdnName(fqdn),
+		Type:    "TXT",
+		Ttl:     p.ttl,
+		Rrdatas: []string{strconv.Quote(value)},
+	}
+}
+
+func (p *cloudDNSProvider) change(ctx context.Context, change *dns.Change) error {
+	if _, err := p.service.Changes.Create(p.project, p.managedZone, change).Context(ctx).Do(); err != nil {
+		return errors.Wrap(err, "error changing clouddns records")
+	}
+	return nil
+}
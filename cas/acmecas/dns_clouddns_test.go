@@ -0,0 +1,63 @@
+package acmecas
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	dns "google.golang.org/api/dns/v1"
+	"google.golang.org/api/option"
+)
+
+func Test_cloudDNSProvider(t *testing.T) {
+	var changes []*dns.Change
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/dns/v1/projects/my-project/managedZones/my-zone/changes" {
+			http.Error(w, `{"error":{"code":404,"message":"not found"}}`, http.StatusNotFound)
+			return
+		}
+		var change dns.Change
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&change))
+		changes = append(changes, &change)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"1","status":"pending"}`) //nolint:errcheck // test server
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	opts := []option.ClientOption{option.WithEndpoint(srv.URL + "/"), option.WithoutAuthentication()}
+	p, err := newCloudDNSProvider(ctx, json.RawMessage(`{"project":"my-project","managedZone":"my-zone"}`), opts...)
+	require.NoError(t, err)
+
+	require.NoError(t, p.Present(ctx, "_acme-challenge.www.example.com", "value"))
+	require.NoError(t, p.CleanUp(ctx, "_acme-challenge.www.example.com.", "value"))
+	want := &dns.ResourceRecordSet{Name: "_acme-challenge.www.example.com.", Type: "TXT", Ttl: 60, Rrdatas: []string{`"value"`}}
+	require.Len(t, changes, 2)
+	assert.Equal(t, []*dns.ResourceRecordSet{want}, changes[0].Additions)
+	assert.Empty(t, changes[0].Deletions)
+	assert.Equal(t, []*dns.ResourceRecordSet{want}, changes[1].Deletions)
+
+	p.managedZone = "missing"
+	assert.ErrorContains(t, p.Present(ctx, "_acme-challenge.www.example.com.", "value"), "error changing clouddns records")
+
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"fail config", `{`, "error decoding clouddns dns provider config"},
+		{"fail project", `{"managedZone":"my-zone"}`, "clouddns dns provider project cannot be empty"},
+		{"fail managedZone", `{"project":"my-project"}`, "clouddns dns provider managedZone cannot be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newCloudDNSProvider(ctx, json.RawMessage(tt.config), opts...)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
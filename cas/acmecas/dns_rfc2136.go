@@ -0,0 +1,295 @@
+package acmecas
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // hmac-sha1 is supported by old servers
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"hash"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// RFC2136DNSProvider is the name of the DNS provider that creates the records
+// using dynamic updates, RFC 2136, signed with TSIG, RFC 8945.
+const RFC2136DNSProvider = "rfc2136"
+
+// Defaults of the RFC 2136 provider.
+const (
+	defaultRFC2136TTL       = 60
+	defaultRFC2136Algorithm = "hmac-sha256"
+	rfc2136Timeout          = 10 * time.Second
+	tsigFudge               = 300
+)
+
+const typeTSIG = dnsmessage.Type(250)
+
+var tsigAlgorithms = map[string]func() hash.Hash{
+	"hmac-sha1":   sha1.New,
+	"hmac-sha256": sha256.New,
+	"hmac-sha512": sha512.New,
+}
+
+func init() {
+	RegisterDNSProvider(RFC2136DNSProvider, func(config json.RawMessage) (DNSProvider, error) {
+		return newRFC2136Provider(config)
+	})
+}
+
+// rfc2136Config is the configuration of the RFC 2136 provider. The nameserver
+// is the primary server of the zone. The update is signed if the TSIG key name
+// and secret are set, the secret is base64 encoded.
+type rfc2136Config struct {
+	Nameserver    string `json:"nameserver"`
+	Zone          string `json:"zone"`
+	TTL           uint32 `json:"ttl,omitempty"`
+	TSIGKeyName   string `json:"tsigKeyName,omitempty"`
+	TSIGAlgorithm string `json:"tsigAlgorithm,omitempty"`
+	TSIGSecret    string `json:"tsigSecret,omitempty"`
+}
+
+// rfc2136Provider is a DNS provider that sends dynamic updates to the primary
+// nameserver of the zone over TCP.
+type rfc2136Provider struct {
+	nameserver string
+	zone       string
+	ttl        uint32
+	keyName    string
+	algorithm  string
+	secret     []byte
+	now        func() time.Time
+}
+
+func newRFC2136Provider(config json.RawMessage) (*rfc2136Provider, error) {
+	var c rfc2136Config
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, errors.Wrap(err, "error decoding rfc2136 dns provider config")
+		}
+	}
+	switch {
+	case c.Nameserver == "":
+		return nil, errors.New("rfc2136 dns provider nameserver cannot be empty")
+	case c.Zone == "":
+		return nil, errors.New("rfc2136 dns provider zone cannot be empty")
+	case (c.TSIGKeyName == "") != (c.TSIGSecret == ""):
+		return nil, errors.New("rfc2136 dns provider tsigKeyName and tsigSecret must be set together")
+	}
+
+	p := &rfc2136Provider{
+		nameserver: c.Nameserver,
+		zone:       fqdnName(c.Zone),
+		ttl:        c.TTL,
+		algorithm:  strings.ToLower(strings.TrimSuffix(c.TSIGAlgorithm, ".")),
+		now:        time.Now,
+	}
+	if _, _, err := net.SplitHostPort(p.nameserver); err != nil {
+		p.nameserver = net.JoinHostPort(p.nameserver, "53")
+	}
+	if p.ttl == 0 {
+		p.ttl = defaultRFC2136TTL
+	}
+	if p.algorithm == "" {
+		p.algorithm = defaultRFC2136Algorithm
+	}
+	if _, ok := tsigAlgorithms[p.algorithm]; !ok {
+		return nil, errors.Errorf("rfc2136 dns provider tsigAlgorithm %s is not supported", c.TSIGAlgorithm)
+	}
+	if c.TSIGKeyName != "" {
+		secret, err := base64.StdEncoding.DecodeString(c.TSIGSecret)
+		if err != nil {
+			return nil, errors.Wrap(err, "rfc2136 dns provider tsigSecret is not valid")
+		}
+		p.keyName = fqdnName(c.TSIGKeyName)
+		p.secret = secret
+	}
+	return p, nil
+}
+
+func (p *rfc2136Provider) Present(ctx context.Context, fqdn, value string) error {
+	return p.update(ctx, fqdn, value, dnsmessage.ClassINET, p.ttl)
+}
+
+// CleanUp deletes the record with the given value, the class NONE removes a
+// record from an RRset.
+func (p *rfc2136Provider) CleanUp(ctx context.Context, fqdn, value string) error {
+	return p.update(ctx, fqdn, value, dnsmessage.Class(254), 0)
+}
+
+func (p *rfc2136Provider) update(ctx context.Context, fqdn, value string, class dnsmessage.Class, ttl uint32) error {
+	fqdn = fqdnName(fqdn)
+	if fqdn != p.zone && !strings.HasSuffix(fqdn, "."+p.zone) {
+		return errors.Errorf("%s is not in the zone %s", fqdn, p.zone)
+	}
+	msg, id, err := p.newUpdate(fqdn, value, class, ttl)
+	if err != nil {
+		return err
+	}
+
+	d := &net.Dialer{Timeout: rfc2136Timeout}
+	conn, err := d.DialContext(ctx, "tcp", p.nameserver)
+	if err != nil {
+		return errors.Wrapf(err, "error connecting to %s", p.nameserver)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(rfc2136Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	b := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(b, uint16(len(msg)))
+	copy(b[2:], msg)
+	if _, err := conn.Write(b); err != nil {
+		return errors.Wrapf(err, "error sending update to %s", p.nameserver)
+	}
+	var l [2]byte
+	if _, err := io.ReadFull(conn, l[:]); err != nil {
+		return errors.Wrapf(err, "error reading response from %s", p.nameserver)
+	}
+	b = make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(conn, b); err != nil {
+		return errors.Wrapf(err, "error reading response from %s", p.nameserver)
+	}
+
+	// Only the status of the response is checked, its signature is not
+	// verified.
+	var parser dnsmessage.Parser
+	h, err := parser.Start(b)
+	if err != nil {
+		return errors.Wrapf(err, "error parsing response from %s", p.nameserver)
+	}
+	switch {
+	case h.ID != id:
+		return errors.Errorf("nameserver %s returned an unexpected id", p.nameserver)
+	case h.RCode != dnsmessage.RCodeSuccess:
+		return errors.Errorf("nameserver %s returned %s updating %s", p.nameserver, h.RCode, fqdn)
+	}
+	return nil
+}
+
+// newUpdate returns an update message that adds or deletes the TXT record,
+// and its id. The message is signed if the provider has a TSIG key.
+func (p *rfc2136Provider) newUpdate(fqdn, value string, class dnsmessage.Class, ttl uint32) ([]byte, uint16, error) {
+	zone, err := dnsmessage.NewName(p.zone)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "invalid zone %s", p.zone)
+	}
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "invalid name %s", fqdn)
+	}
+	var b [2]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return nil, 0, errors.Wrap(err, "error generating update id")
+	}
+	id := binary.BigEndian.Uint16(b[:])
+
+	// The sections of an update are the zone, the prerequisites, the updates
+	// and the additional data, they are packed as the ones of a query.
+	const opcodeUpdate = 5
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, OpCode: opcodeUpdate})
+	if err := builder.StartQuestions(); err != nil {
+		return nil, 0, err
+	}
+	if err := builder.Question(dnsmessage.Question{Name: zone, Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, 0, err
+	}
+	if err := builder.StartAuthorities(); err != nil {
+		return nil, 0, err
+	}
+	if err := builder.TXTResource(dnsmessage.ResourceHeader{Name: name, Class: class, TTL: ttl}, dnsmessage.TXTResource{TXT: []string{value}}); err != nil {
+		return nil, 0, err
+	}
+	msg, err := builder.Finish()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "error packing update")
+	}
+	if p.keyName == "" {
+		return msg, id, nil
+	}
+	msg, err = p.sign(msg, id)
+	return msg, id, err
+}
+
+// sign adds the TSIG record to the message.
+func (p *rfc2136Provider) sign(msg []byte, id uint16) ([]byte, error) {
+	keyName, err := wireName(p.keyName)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, err := wireName(p.algorithm + ".")
+	if err != nil {
+		return nil, err
+	}
+	var timeSigned [6]byte
+	now := uint64(p.now().Unix())
+	binary.BigEndian.PutUint16(timeSigned[:2], uint16(now>>32))
+	binary.BigEndian.PutUint32(timeSigned[2:], uint32(now))
+
+	// The MAC covers the message and the TSIG variables: the key name, class
+	// ANY, TTL 0, the algorithm, the time, the fudge, the error and the
+	// length of the other data.
+	mac := hmac.New(tsigAlgorithms[p.algorithm], p.secret)
+	mac.Write(msg)
+	mac.Write(keyName)
+	mac.Write([]byte{0, 255, 0, 0, 0, 0})
+	mac.Write(algorithm)
+	mac.Write(timeSigned[:])
+	mac.Write([]byte{tsigFudge >> 8, tsigFudge & 0xff, 0, 0, 0, 0})
+	sum := mac.Sum(nil)
+
+	var rdata []byte
+	rdata = append(rdata, algorithm...)
+	rdata = append(rdata, timeSigned[:]...)
+	rdata = append(rdata, tsigFudge>>8, tsigFudge&0xff)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(sum)))
+	rdata = append(rdata, sum...)
+	rdata = binary.BigEndian.AppendUint16(rdata, id)
+	rdata = append(rdata, 0, 0, 0, 0)
+
+	rr := append([]byte{}, keyName...)
+	rr = binary.BigEndian.AppendUint16(rr, uint16(typeTSIG))
+	rr = append(rr, 0, 255, 0, 0, 0, 0)
+	rr = binary.BigEndian.AppendUint16(rr, uint16(len(rdata)))
+	rr = append(rr, rdata...)
+
+	signed := append(append([]byte{}, msg...), rr...)
+	binary.BigEndian.PutUint16(signed[10:12], binary.BigEndian.Uint16(msg[10:12])+1)
+	return signed, nil
+}
+
+// fqdnName returns the lower case name with a trailing dot.
+func fqdnName(name string) string {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return name
+}
+
+// wireName returns the uncompressed wire format of a fully qualified name.
+func wireName(name string) ([]byte, error) {
+	var b []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return nil, errors.Errorf("invalid name %s", name)
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0), nil
+}
@@ -0,0 +1,188 @@
+package acmecas
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// rfc2136Update is an update received by the test nameserver.
+type rfc2136Update struct {
+	zone   string
+	name   string
+	class  dnsmessage.Class
+	ttl    uint32
+	txt    []string
+	signed bool
+}
+
+// startRFC2136Server starts a nameserver that verifies the TSIG signature of
+// the updates with the given secret and responds with the given code.
+func startRFC2136Server(t *testing.T, secret []byte, rcode dnsmessage.RCode) (string, <-chan *rfc2136Update) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	updates := make(chan *rfc2136Update, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			var l [2]byte
+			if _, err := io.ReadFull(conn, l[:]); err != nil {
+				conn.Close()
+				continue
+			}
+			b := make([]byte, binary.BigEndian.Uint16(l[:]))
+			if _, err := io.ReadFull(conn, b); err != nil {
+				conn.Close()
+				continue
+			}
+
+			var msg dnsmessage.Message
+			if err := msg.Unpack(b); err != nil {
+				conn.Close()
+				continue
+			}
+			u := &rfc2136Update{zone: msg.Questions[0].Name.String()}
+			for _, r := range msg.Authorities {
+				u.name, u.class, u.ttl = r.Header.Name.String(), r.Header.Class, r.Header.TTL
+				// The TXT records with class NONE are parsed as unknown.
+				switch body := r.Body.(type) {
+				case *dnsmessage.TXTResource:
+					u.txt = body.TXT
+				case *dnsmessage.UnknownResource:
+					u.txt = []string{string(body.Data[1:])}
+				}
+			}
+			if len(msg.Additionals) == 1 {
+				u.signed = verifyTSIG(t, b, "acme-key.", secret)
+			}
+			updates <- u
+
+			h := msg.Header
+			h.Response = true
+			h.RCode = rcode
+			resp, _ := (&dnsmessage.Message{Header: h}).Pack()
+			out := binary.BigEndian.AppendUint16(nil, uint16(len(resp)))
+			conn.Write(append(out, resp...)) //nolint:errcheck // test server
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String(), updates
+}
+
+// verifyTSIG checks the hmac-sha256 signature of the message.
+func verifyTSIG(t *testing.T, b []byte, keyName string, secret []byte) bool {
+	t.Helper()
+	key, err := wireName(keyName)
+	require.NoError(t, err)
+	i := bytes.LastIndex(b, append(key, 0, byte(typeTSIG)))
+	if i < 0 {
+		return false
+	}
+	unsigned := append([]byte{}, b[:i]...)
+	binary.BigEndian.PutUint16(unsigned[10:12], binary.BigEndian.Uint16(unsigned[10:12])-1)
+
+	// Skip the key name, type, class, TTL and length of the record.
+	rdata := b[i+len(key)+10:]
+	algorithm, err := wireName("hmac-sha256.")
+	require.NoError(t, err)
+	if !bytes.HasPrefix(rdata, algorithm) {
+		return false
+	}
+	rest := rdata[len(algorithm):]
+	timeAndFudge, macSize := rest[:8], binary.BigEndian.Uint16(rest[8:10])
+	got := rest[10 : 10+int(macSize)]
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(unsigned)
+	mac.Write(key)
+	mac.Write([]byte{0, 255, 0, 0, 0, 0})
+	mac.Write(algorithm)
+	mac.Write(timeAndFudge)
+	mac.Write([]byte{0, 0, 0, 0})
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func Test_newRFC2136Provider(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    *rfc2136Provider
+		wantErr string
+	}{
+		{"ok", `{"nameserver":"ns.example.com","zone":"Example.com"}`, &rfc2136Provider{
+			nameserver: "ns.example.com:53", zone: "example.com.", ttl: 60, algorithm: "hmac-sha256",
+		}, ""},
+		{"ok tsig", `{"nameserver":"10.0.0.1:5353","zone":"example.com.","ttl":30,"tsigKeyName":"acme-key","tsigAlgorithm":"hmac-sha512.","tsigSecret":"c2VjcmV0"}`, &rfc2136Provider{
+			nameserver: "10.0.0.1:5353", zone: "example.com.", ttl: 30, keyName: "acme-key.", algorithm: "hmac-sha512", secret: []byte("secret"),
+		}, ""},
+		{"fail config", `{`, nil, "error decoding rfc2136 dns provider config"},
+		{"fail nameserver", `{"zone":"example.com"}`, nil, "rfc2136 dns provider nameserver cannot be empty"},
+		{"fail zone", `{"nameserver":"ns.example.com"}`, nil, "rfc2136 dns provider zone cannot be empty"},
+		{"fail tsig", `{"nameserver":"ns.example.com","zone":"example.com","tsigKeyName":"acme-key"}`, nil, "tsigKeyName and tsigSecret must be set together"},
+		{"fail algorithm", `{"nameserver":"ns.example.com","zone":"example.com","tsigAlgorithm":"hmac-md5"}`, nil, "tsigAlgorithm hmac-md5 is not supported"},
+		{"fail secret", `{"nameserver":"ns.example.com","zone":"example.com","tsigKeyName":"acme-key","tsigSecret":"%%"}`, nil, "rfc2136 dns provider tsigSecret is not valid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newRFC2136Provider(json.RawMessage(tt.config))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			got.now = nil
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_rfc2136Provider(t *testing.T) {
+	secret := []byte("secret")
+	addr, updates := startRFC2136Server(t, secret, dnsmessage.RCodeSuccess)
+	p, err := newRFC2136Provider(json.RawMessage(`{"nameserver":"` + addr + `","zone":"example.com","tsigKeyName":"acme-key","tsigSecret":"c2VjcmV0"}`))
+	require.NoError(t, err)
+	p.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	ctx := context.Background()
+	require.NoError(t, p.Present(ctx, "_acme-challenge.www.example.com.", "value"))
+	assert.Equal(t, &rfc2136Update{
+		zone: "example.com.", name: "_acme-challenge.www.example.com.", class: dnsmessage.ClassINET,
+		ttl: 60, txt: []string{"value"}, signed: true,
+	}, <-updates)
+
+	require.NoError(t, p.CleanUp(ctx, "_acme-challenge.www.example.com", "value"))
+	assert.Equal(t, &rfc2136Update{
+		zone: "example.com.", name: "_acme-challenge.www.example.com.", class: dnsmessage.Class(254),
+		txt: []string{"value"}, signed: true,
+	}, <-updates)
+
+	assert.EqualError(t, p.Present(ctx, "_acme-challenge.example.net.", "value"), "_acme-challenge.example.net. is not in the zone example.com.")
+
+	// A different secret is not valid.
+	p.secret = []byte("other")
+	require.NoError(t, p.Present(ctx, "_acme-challenge.www.example.com.", "value"))
+	assert.False(t, (<-updates).signed)
+
+	refused, refusedUpdates := startRFC2136Server(t, nil, dnsmessage.RCodeRefused)
+	p, err = newRFC2136Provider(json.RawMessage(`{"nameserver":"` + refused + `","zone":"example.com"}`))
+	require.NoError(t, err)
+	assert.ErrorContains(t, p.Present(ctx, "_acme-challenge.example.com.", "value"), "returned RCodeRefused")
+	assert.False(t, (<-refusedUpdates).signed)
+}
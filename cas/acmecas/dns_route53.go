@@ -0,0 +1,138 @@
+package acmecas
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/pkg/errors"
+)
+
+// Route53DNSProvider is the name of the DNS provider that creates the records
+// in Amazon Route 53.
+const Route53DNSProvider = "route53"
+
+const defaultRoute53TTL = 60
+
+func init() {
+	RegisterDNSProvider(Route53DNSProvider, func(config json.RawMessage) (DNSProvider, error) {
+		return newRoute53Provider(config)
+	})
+}
+
+// route53Config is the configuration of the Route 53 provider. By default, the
+// session uses the credentials in ~/.aws/credentials, but they can also be
+// configured using the environment variables of the AWS SDK. The hosted zone
+// is looked up by name if its id is not set.
+type route53Config struct {
+	HostedZoneID    string `json:"hostedZoneID,omitempty"`
+	Region          string `json:"region,omitempty"`
+	Profile         string `json:"profile,omitempty"`
+	CredentialsFile string `json:"credentialsFile,omitempty"`
+	TTL             int64  `json:"ttl,omitempty"`
+}
+
+// route53Provider is a DNS provider that uses Amazon Route 53.
+type route53Provider struct {
+	service      *route53.Route53
+	hostedZoneID string
+	ttl          int64
+}
+
+func newRoute53Provider(config json.RawMessage, cfgs ...*aws.Config) (*route53Provider, error) {
+	var c route53Config
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, errors.Wrap(err, "error decoding route53 dns provider config")
+		}
+	}
+
+	var o session.Options
+	o.Profile = c.Profile
+	if c.Region != "" {
+		o.Config.Region = aws.String(c.Region)
+	}
+	if c.CredentialsFile != "" {
+		o.SharedConfigFiles = []string{c.CredentialsFile}
+	}
+	sess, err := session.NewSessionWithOptions(o)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating AWS session")
+	}
+
+	p := &route53Provider{
+		service:      route53.New(sess, cfgs...),
+		hostedZoneID: c.HostedZoneID,
+		ttl:          c.TTL,
+	}
+	if p.ttl == 0 {
+		p.ttl = defaultRoute53TTL
+	}
+	return p, nil
+}
+
+func (p *route53Provider) Present(ctx context.Context, fqdn, value string) error {
+	return p.change(ctx, route53.ChangeActionUpsert, fqdn, value)
+}
+
+func (p *route53Provider) CleanUp(ctx context.Context, fqdn, value string) error {
+	return p.change(ctx, route53.ChangeActionDelete, fqdn, value)
+}
+
+func (p *route53Provider) change(ctx context.Context, action, fqdn, value string) error {
+	fqdn = fqdnName(fqdn)
+	zoneID, err := p.getHostedZoneID(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	_, err = p.service.ChangeResourceRecordSetsWithContext(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+		ChangeBatch: &route53.ChangeBatch{
+			Comment: aws.String("step-ca acme challenge"),
+			Changes: []*route53.Change{{
+				Action: aws.String(action),
+				ResourceRecordSet: &route53.ResourceRecordSet{
+					Name: aws.String(fqdn),
+					Type: aws.String(route53.RRTypeTxt),
+					TTL:  aws.Int64(p.ttl),
+					ResourceRecords: []*route53.ResourceRecord{
+						{Value: aws.String(strconv.Quote(value))},
+					},
+				},
+			}},
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "error changing route53 record %s", fqdn)
+	}
+	return nil
+}
+
+// getHostedZoneID returns the configured hosted zone, or the public hosted
+// zone with the longest name that contains the given name.
+func (p *route53Provider) getHostedZoneID(ctx context.Context, fqdn string) (string, error) {
+	if p.hostedZoneID != "" {
+		return p.hostedZoneID, nil
+	}
+	labels := strings.Split(strings.TrimSuffix(fqdn, "."), ".")
+	for i := range labels {
+		name := strings.Join(labels[i:], ".") + "."
+		resp, err := p.service.ListHostedZonesByNameWithContext(ctx, &route53.ListHostedZonesByNameInput{
+			DNSName: aws.String(name),
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "error looking up route53 hosted zone for %s", fqdn)
+		}
+		for _, z := range resp.HostedZones {
+			if fqdnName(aws.StringValue(z.Name)) != name || (z.Config != nil && aws.BoolValue(z.Config.PrivateZone)) {
+				continue
+			}
+			return strings.TrimPrefix(aws.StringValue(z.Id), "/hostedzone/"), nil
+		}
+	}
+	return "", errors.Errorf("route53 hosted zone for %s not found", fqdn)
+}
@@ -0,0 +1,72 @@
+package acmecas
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_route53Provider(t *testing.T) {
+	var changes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/2013-04-01/hostedzonesbyname":
+			// Only a private and a public zone for example.com exist.
+			zones := ""
+			if r.URL.Query().Get("dnsname") == "example.com." {
+				zones = `<HostedZone><Id>/hostedzone/ZPRIVATE</Id><Name>example.com.</Name><CallerReference>a</CallerReference><Config><PrivateZone>true</PrivateZone></Config></HostedZone>` +
+					`<HostedZone><Id>/hostedzone/ZPUBLIC</Id><Name>example.com.</Name><CallerReference>b</CallerReference><Config><PrivateZone>false</PrivateZone></Config></HostedZone>`
+			}
+			io.WriteString(w, `<ListHostedZonesByNameResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/"><HostedZones>`+zones+`</HostedZones><IsTruncated>false</IsTruncated><MaxItems>100</MaxItems></ListHostedZonesByNameResponse>`) //nolint:errcheck // test server
+		case r.Method == http.MethodPost:
+			b, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			changes = append(changes, r.URL.Path+" "+string(b))
+			io.WriteString(w, `<ChangeResourceRecordSetsResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/"><ChangeInfo><Id>/change/C1</Id><Status>PENDING</Status><SubmittedAt>2023-01-01T00:00:00Z</SubmittedAt></ChangeInfo></ChangeResourceRecordSetsResponse>`) //nolint:errcheck // test server
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := &aws.Config{
+		Endpoint:    aws.String(srv.URL),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}
+	p, err := newRoute53Provider(json.RawMessage(`{"ttl":30}`), cfg)
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), p.ttl)
+
+	ctx := context.Background()
+	require.NoError(t, p.Present(ctx, "_acme-challenge.www.example.com.", "value"))
+	require.NoError(t, p.CleanUp(ctx, "_acme-challenge.www.example.com.", "value"))
+	require.Len(t, changes, 2)
+	assert.Contains(t, changes[0], "/2013-04-01/hostedzone/ZPUBLIC/rrset/")
+	assert.Contains(t, changes[0], "<Action>UPSERT</Action>")
+	assert.Contains(t, changes[0], "<Name>_acme-challenge.www.example.com.</Name>")
+	assert.Contains(t, changes[0], "<Value>&#34;value&#34;</Value>")
+	assert.Contains(t, changes[0], "<TTL>30</TTL>")
+	assert.Contains(t, changes[1], "<Action>DELETE</Action>")
+
+	assert.ErrorContains(t, p.Present(ctx, "_acme-challenge.example.net.", "value"), "route53 hosted zone for _acme-challenge.example.net. not found")
+
+	// The configured hosted zone is used without looking it up.
+	p, err = newRoute53Provider(json.RawMessage(`{"hostedZoneID":"ZCONFIGURED"}`), cfg)
+	require.NoError(t, err)
+	require.NoError(t, p.Present(ctx, "_acme-challenge.example.net.", "value"))
+	assert.Contains(t, changes[2], "/2013-04-01/hostedzone/ZCONFIGURED/rrset/")
+	assert.Contains(t, changes[2], "<TTL>60</TTL>")
+
+	_, err = newRoute53Provider(json.RawMessage(`{`))
+	assert.ErrorContains(t, err, "error decoding route53 dns provider config")
+}
@@ -0,0 +1,54 @@
+package acmecas
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// http01ChallengePrefix is the path where the http-01 challenges are served.
+const http01ChallengePrefix = "/.well-known/acme-challenge/"
+
+// http01Solver keeps in memory the key authorizations of the http-01
+// challenges being solved.
+type http01Solver struct {
+	mu       sync.RWMutex
+	keyAuths map[string]string
+}
+
+// selfSolver is the solver used with the selfSolve option. It is shared by
+// all the instances so the CA can serve it on the insecure address.
+var selfSolver = &http01Solver{
+	keyAuths: make(map[string]string),
+}
+
+// HTTP01Handler returns the handler that serves the http-01 challenges solved
+// with the selfSolve option at /.well-known/acme-challenge/{token}.
+func HTTP01Handler() http.Handler {
+	return selfSolver
+}
+
+func (s *http01Solver) present(token, keyAuth string) {
+	s.mu.Lock()
+	s.keyAuths[token] = keyAuth
+	s.mu.Unlock()
+}
+
+func (s *http01Solver) cleanup(token string) {
+	s.mu.Lock()
+	delete(s.keyAuths, token)
+	s.mu.Unlock()
+}
+
+func (s *http01Solver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, http01ChallengePrefix)
+	s.mu.RLock()
+	keyAuth, ok := s.keyAuths[token]
+	s.mu.RUnlock()
+	if !ok || token == r.URL.Path || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte(keyAuth))
+}
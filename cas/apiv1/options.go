@@ -24,6 +24,8 @@ type Options struct {
 	// In StepCAS the value is the CA url, e.g., "https://ca.smallstep.com:9000".
 	// In CloudCAS the format is "projects/*/locations/*/certificateAuthorities/*".
 	// In VaultCAS the value is the url, e.g., "https://vault.smallstep.com".
+	// In ACMECAS the value is the directory url, e.g.,
+	// "https://acme-v02.api.letsencrypt.org/directory".
 	CertificateAuthority string `json:"certificateAuthority,omitempty"`
 
 	// CertificateAuthorityFingerprint is the root fingerprint used to
@@ -1,10 +1,14 @@
 package apiv1
 
 import (
+	"context"
 	"crypto"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"time"
 
+	"golang.org/x/crypto/ocsp"
+
 	"go.step.sm/crypto/kms/apiv1"
 )
 
@@ -59,6 +63,10 @@ type CreateCertificateRequest struct {
 	RequestID      string
 	Provisioner    *ProvisionerInfo
 	IsCAServerCert bool
+	// Context is the context of the request that originated the operation.
+	// It is used to propagate the trace context to the CAS, and it can be
+	// nil.
+	Context context.Context //nolint:containedctx // optional trace context
 }
 
 // ProvisionerInfo contains information of the provisioner used to authorize a
@@ -83,6 +91,10 @@ type RenewCertificateRequest struct {
 	Backdate  time.Duration
 	Token     string
 	RequestID string
+	// Context is the context of the request that originated the operation.
+	// It is used to propagate the trace context to the CAS, and it can be
+	// nil.
+	Context context.Context //nolint:containedctx // optional trace context
 }
 
 // RenewCertificateResponse is the response to a renew certificate request.
@@ -99,6 +111,10 @@ type RevokeCertificateRequest struct {
 	ReasonCode   int
 	PassiveOnly  bool
 	RequestID    string
+	// Context is the context of the request that originated the operation.
+	// It is used to propagate the trace context to the CAS, and it can be
+	// nil.
+	Context context.Context //nolint:containedctx // optional trace context
 }
 
 // RevokeCertificateResponse is the response to a revoke certificate request.
@@ -165,3 +181,26 @@ type CreateCRLRequest struct {
 type CreateCRLResponse struct {
 	CRL []byte //the CRL in DER format
 }
+
+// CreateOCSPResponseRequest is the request to sign an OCSP response. The
+// SerialNumber, Status, ThisUpdate and NextUpdate attributes of the template
+// are used to build the response. Extensions are added to the
+// responseExtensions of the response, e.g. the nonce of the request.
+type CreateOCSPResponseRequest struct {
+	Template   ocsp.Response
+	Extensions []pkix.Extension
+}
+
+// CreateOCSPResponseResponse is the response to an OCSP signing request.
+type CreateOCSPResponseResponse struct {
+	OCSPResponse []byte // the OCSP response in DER format
+}
+
+// RequestContext returns the given context of a request, or
+// context.Background() if it is nil.
+func RequestContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
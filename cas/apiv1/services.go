@@ -4,6 +4,7 @@ import (
 	"crypto/x509"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // CertificateAuthorityService is the interface implemented to support external
@@ -20,6 +21,13 @@ type CertificateAuthorityCRLGenerator interface {
 	CreateCRL(req *CreateCRLRequest) (*CreateCRLResponse, error)
 }
 
+// CertificateAuthorityOCSPSigner is an optional interface implemented by
+// CertificateAuthorityService that has a method to sign OCSP responses with the
+// issuer key.
+type CertificateAuthorityOCSPSigner interface {
+	CreateOCSPResponse(req *CreateOCSPResponseRequest) (*CreateOCSPResponseResponse, error)
+}
+
 // CertificateAuthorityGetter is an interface implemented by a
 // CertificateAuthorityService that has a method to get the root certificate.
 type CertificateAuthorityGetter interface {
@@ -53,6 +61,9 @@ const (
 	StepCAS = "stepcas"
 	// VaultCAS is a CertificateAuthorityService using Hasicorp Vault PKI.
 	VaultCAS = "vaultcas"
+	// ACMECAS is a CertificateAuthorityService using an ACME certificate
+	// authority, like Let's Encrypt.
+	ACMECAS = "acmecas"
 )
 
 // String returns a string from the type. It will always return the lower case
@@ -103,3 +114,35 @@ func (e ValidationError) Error() string {
 func (e ValidationError) StatusCode() int {
 	return http.StatusBadRequest
 }
+
+// UnavailableError is the type of error returned if the signing key is
+// temporarily unavailable, for example if a KMS or HSM times out. The request
+// can be retried after RetryAfter.
+type UnavailableError struct {
+	Message    string
+	RetryAfter time.Duration
+	Err        error
+}
+
+// Error implements the error interface.
+func (e *UnavailableError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = "signing key is unavailable"
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode implements the StatusCoder interface and returns the HTTP 503
+// error.
+func (e *UnavailableError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
@@ -1,6 +1,7 @@
 package apiv1
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -119,3 +120,25 @@ func TestValidationError_StatusCode(t *testing.T) {
 		})
 	}
 }
+
+func TestUnavailableError_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		e    *UnavailableError
+		want string
+	}{
+		{"default", &UnavailableError{}, "signing key is unavailable"},
+		{"with message", &UnavailableError{Message: "kms timeout"}, "kms timeout"},
+		{"with error", &UnavailableError{Err: errors.New("not found")}, "signing key is unavailable: not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.e.Error(); got != tt.want {
+				t.Errorf("UnavailableError.Error() = %v, want %v", got, tt.want)
+			}
+			if got := tt.e.StatusCode(); got != 503 {
+				t.Errorf("UnavailableError.StatusCode() = %v, want 503", got)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"encoding/asn1"
 	"encoding/pem"
+	"net/http"
 	"regexp"
 	"strings"
 	"time"
@@ -16,9 +17,11 @@ import (
 	gax "github.com/googleapis/gax-go/v2"
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/logging"
 	"go.step.sm/crypto/x509util"
 	"google.golang.org/api/option"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	durationpb "google.golang.org/protobuf/types/known/durationpb"
 )
@@ -196,7 +199,7 @@ func (c *CloudCAS) CreateCertificate(req *apiv1.CreateCertificateRequest) (*apiv
 		return nil, errors.New("createCertificateRequest `lifetime` cannot be 0")
 	}
 
-	cert, chain, err := c.createCertificate(req.Template, req.Lifetime, req.RequestID)
+	cert, chain, err := c.createCertificate(req.Context, req.Template, req.Lifetime, req.RequestID)
 	if err != nil {
 		return nil, err
 	}
@@ -218,7 +221,7 @@ func (c *CloudCAS) RenewCertificate(req *apiv1.RenewCertificateRequest) (*apiv1.
 		return nil, errors.New("renewCertificateRequest `lifetime` cannot be 0")
 	}
 
-	cert, chain, err := c.createCertificate(req.Template, req.Lifetime, req.RequestID)
+	cert, chain, err := c.createCertificate(req.Context, req.Template, req.Lifetime, req.RequestID)
 	if err != nil {
 		return nil, err
 	}
@@ -249,7 +252,7 @@ func (c *CloudCAS) RevokeCertificate(req *apiv1.RevokeCertificateRequest) (*apiv
 		return nil, errors.Wrap(err, "error unmarshaling certificate authority extension")
 	}
 
-	ctx, cancel := defaultContext()
+	ctx, cancel := requestContext(req.Context)
 	defer cancel()
 
 	certpb, err := c.client.RevokeCertificate(ctx, &pb.RevokeCertificateRequest{
@@ -496,7 +499,7 @@ func (c *CloudCAS) enableCertificateAuthority(ca *pb.CertificateAuthority) (*pb.
 	return ca, nil
 }
 
-func (c *CloudCAS) createCertificate(tpl *x509.Certificate, lifetime time.Duration, requestID string) (*x509.Certificate, []*x509.Certificate, error) {
+func (c *CloudCAS) createCertificate(parent context.Context, tpl *x509.Certificate, lifetime time.Duration, requestID string) (*x509.Certificate, []*x509.Certificate, error) {
 	// Removes the CAS extension if it exists.
 	apiv1.RemoveCertificateAuthorityExtension(tpl)
 
@@ -517,7 +520,7 @@ func (c *CloudCAS) createCertificate(tpl *x509.Certificate, lifetime time.Durati
 		return nil, nil, err
 	}
 
-	ctx, cancel := defaultContext()
+	ctx, cancel := requestContext(parent)
 	defer cancel()
 
 	cert, err := c.client.CreateCertificate(ctx, &pb.CreateCertificateRequest{
@@ -648,6 +651,19 @@ func defaultContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), 15*time.Second)
 }
 
+// requestContext returns a context with the default timeout for the operations
+// originated by a request to the CA. The trace context and the request id in
+// the given context are sent as gRPC metadata.
+func requestContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx := apiv1.RequestContext(parent)
+	h := make(http.Header)
+	logging.InjectTraceHeaders(ctx, h)
+	for k := range h {
+		ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(k), h.Get(k))
+	}
+	return context.WithTimeout(ctx, 15*time.Second)
+}
+
 func defaultInitiatorContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), 60*time.Second)
 }
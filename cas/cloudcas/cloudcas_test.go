@@ -590,7 +590,7 @@ func TestCloudCAS_createCertificate(t *testing.T) {
 				client:               tt.fields.client,
 				certificateAuthority: tt.fields.certificateAuthority,
 			}
-			got, got1, err := c.createCertificate(tt.args.tpl, tt.args.lifetime, tt.args.requestID)
+			got, got1, err := c.createCertificate(context.Background(), tt.args.tpl, tt.args.lifetime, tt.args.requestID)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CloudCAS.createCertificate() error = %v, wantErr %v", err, tt.wantErr)
 				return
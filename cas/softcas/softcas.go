@@ -15,6 +15,7 @@ import (
 	"go.step.sm/crypto/x509util"
 
 	"github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/internal/ocsputil"
 )
 
 func init() {
@@ -147,6 +148,24 @@ func (c *SoftCAS) CreateCRL(req *apiv1.CreateCRLRequest) (*apiv1.CreateCRLRespon
 	return &apiv1.CreateCRLResponse{CRL: revocationListBytes}, nil
 }
 
+// CreateOCSPResponse signs an OCSP response for the given template using the
+// issuer certificate and key as the responder.
+func (c *SoftCAS) CreateOCSPResponse(req *apiv1.CreateOCSPResponseRequest) (*apiv1.CreateOCSPResponseResponse, error) {
+	if req.Template.SerialNumber == nil {
+		return nil, errors.New("createOCSPResponseRequest `template.serialNumber` cannot be nil")
+	}
+	certChain, signer, err := c.getCertSigner()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ocsputil.CreateResponse(certChain[0], certChain[0], req.Template, req.Extensions, signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating ocsp response")
+	}
+
+	return &apiv1.CreateOCSPResponseResponse{OCSPResponse: b}, nil
+}
+
 // CreateCertificateAuthority creates a root or an intermediate certificate.
 func (c *SoftCAS) CreateCertificateAuthority(req *apiv1.CreateCertificateAuthorityRequest) (*apiv1.CreateCertificateAuthorityResponse, error) {
 	switch {
@@ -19,10 +19,12 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/cas/apiv1"
+	"go.step.sm/crypto/keyutil"
 	"go.step.sm/crypto/kms"
 	kmsapi "go.step.sm/crypto/kms/apiv1"
 	"go.step.sm/crypto/pemutil"
 	"go.step.sm/crypto/x509util"
+	"golang.org/x/crypto/ocsp"
 )
 
 var (
@@ -890,3 +892,81 @@ func Test_isRSA(t *testing.T) {
 		})
 	}
 }
+
+func TestSoftCAS_CreateOCSPResponse(t *testing.T) {
+	signer, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := x509util.CreateCertificate(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		KeyUsage:              x509.KeyUsageCRLSign | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             testNow,
+		NotAfter:              testNow.Add(time.Hour),
+	}, &x509.Certificate{Subject: pkix.Name{CommonName: "Test Intermediate CA"}}, signer.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type fields struct {
+		Issuer            *x509.Certificate
+		Signer            crypto.Signer
+		CertificateSigner func() ([]*x509.Certificate, crypto.Signer, error)
+	}
+	type args struct {
+		req *apiv1.CreateOCSPResponseRequest
+	}
+	tests := []struct {
+		name       string
+		fields     fields
+		args       args
+		wantStatus int
+		wantErr    bool
+	}{
+		{"ok", fields{issuer, signer, nil}, args{&apiv1.CreateOCSPResponseRequest{
+			Template: ocsp.Response{Status: ocsp.Good, SerialNumber: big.NewInt(1234), ThisUpdate: testNow, NextUpdate: testNow.Add(time.Hour)},
+		}}, ocsp.Good, false},
+		{"ok revoked", fields{issuer, signer, nil}, args{&apiv1.CreateOCSPResponseRequest{
+			Template: ocsp.Response{Status: ocsp.Revoked, SerialNumber: big.NewInt(1234), RevokedAt: testNow, ThisUpdate: testNow, NextUpdate: testNow.Add(time.Hour)},
+		}}, ocsp.Revoked, false},
+		{"fail serialNumber", fields{issuer, signer, nil}, args{&apiv1.CreateOCSPResponseRequest{
+			Template: ocsp.Response{Status: ocsp.Good, ThisUpdate: testNow, NextUpdate: testNow.Add(time.Hour)},
+		}}, 0, true},
+		{"fail with callback", fields{nil, nil, testFailCertificateSigner}, args{&apiv1.CreateOCSPResponseRequest{
+			Template: ocsp.Response{Status: ocsp.Good, SerialNumber: big.NewInt(1234), ThisUpdate: testNow, NextUpdate: testNow.Add(time.Hour)},
+		}}, 0, true},
+		{"fail signer", fields{issuer, &badSigner{}, nil}, args{&apiv1.CreateOCSPResponseRequest{
+			Template: ocsp.Response{Status: ocsp.Good, SerialNumber: big.NewInt(1234), ThisUpdate: testNow, NextUpdate: testNow.Add(time.Hour)},
+		}}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &SoftCAS{
+				CertificateChain:  []*x509.Certificate{tt.fields.Issuer},
+				Signer:            tt.fields.Signer,
+				CertificateSigner: tt.fields.CertificateSigner,
+			}
+			got, err := c.CreateOCSPResponse(tt.args.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SoftCAS.CreateOCSPResponse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			resp, err := ocsp.ParseResponse(got.OCSPResponse, issuer)
+			if err != nil {
+				t.Fatalf("ocsp.ParseResponse() error = %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Errorf("SoftCAS.CreateOCSPResponse() status = %d, want %d", resp.Status, tt.wantStatus)
+			}
+			if resp.SerialNumber.Cmp(tt.args.req.Template.SerialNumber) != 0 {
+				t.Errorf("SoftCAS.CreateOCSPResponse() serialNumber = %s, want %s", resp.SerialNumber, tt.args.req.Template.SerialNumber)
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/ca"
 	"github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/internal/secret"
 )
 
 // raAuthorityNS is a custom namespace used to generate endpoint ids based on
@@ -43,6 +44,17 @@ func newStepIssuer(ctx context.Context, caURL *url.URL, client *ca.Client, iss *
 		return nil, err
 	}
 
+	// The password can be a reference to a secret source.
+	if secret.IsReference(iss.Password) {
+		password, err := secret.Resolve(ctx, iss.Password)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading stepCAS `certificateIssuer.password`")
+		}
+		cp := *iss
+		cp.Password = password
+		iss = &cp
+	}
+
 	switch strings.ToLower(iss.Type) {
 	case "x5c":
 		return newX5CIssuer(caURL, iss)
@@ -11,6 +11,7 @@ import (
 	"github.com/smallstep/certificates/authority/provisioner"
 	"github.com/smallstep/certificates/ca"
 	"github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/internal/secret"
 	"go.step.sm/cli-utils/ui"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/randutil"
@@ -119,9 +120,12 @@ func newJWKSigner(keyFile, password string) (jose.Signer, error) {
 func newJWKSignerFromEncryptedKey(kid, key, password string) (jose.Signer, error) {
 	var jwk jose.JSONWebKey
 
+	pass := []byte(password)
+	defer secret.Wipe(pass)
+
 	// If the password is empty it will use the password prompter.
 	b, err := jose.Decrypt([]byte(key),
-		jose.WithPassword([]byte(password)),
+		jose.WithPassword(pass),
 		jose.WithPasswordPrompter("Please enter the password to decrypt the provisioner key", func(msg string) ([]byte, error) {
 			return ui.PromptPassword(msg)
 		}))
@@ -130,6 +134,7 @@ func newJWKSignerFromEncryptedKey(kid, key, password string) (jose.Signer, error
 	}
 
 	// Decrypt returns the JSON representation of the JWK.
+	defer secret.Wipe(b)
 	if err := json.Unmarshal(b, &jwk); err != nil {
 		return nil, errors.Wrap(err, "error parsing provisioner key")
 	}
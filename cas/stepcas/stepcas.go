@@ -87,7 +87,7 @@ func (s *StepCAS) CreateCertificate(req *apiv1.CreateCertificateRequest) (*apiv1
 		info.ProvisionerName = p.Name
 	}
 
-	cert, chain, err := s.createCertificate(req.CSR, req.Lifetime, info)
+	cert, chain, err := s.createCertificate(apiv1.RequestContext(req.Context), req.CSR, req.Lifetime, info)
 	if err != nil {
 		return nil, err
 	}
@@ -105,7 +105,7 @@ func (s *StepCAS) RenewCertificate(req *apiv1.RenewCertificateRequest) (*apiv1.R
 		return nil, apiv1.ValidationError{Message: "renewCertificateRequest `token` cannot be empty"}
 	}
 
-	resp, err := s.client.RenewWithToken(req.Token)
+	resp, err := s.client.RenewWithTokenAndContext(apiv1.RequestContext(req.Context), req.Token)
 	if err != nil {
 		return nil, err
 	}
@@ -138,7 +138,7 @@ func (s *StepCAS) RevokeCertificate(req *apiv1.RevokeCertificateRequest) (*apiv1
 		return nil, err
 	}
 
-	_, err = s.client.Revoke(&api.RevokeRequest{
+	_, err = s.client.RevokeWithContext(apiv1.RequestContext(req.Context), &api.RevokeRequest{
 		Serial:     serialNumber,
 		ReasonCode: req.ReasonCode,
 		Reason:     req.Reason,
@@ -167,7 +167,7 @@ func (s *StepCAS) GetCertificateAuthority(*apiv1.GetCertificateAuthorityRequest)
 	}, nil
 }
 
-func (s *StepCAS) createCertificate(cr *x509.CertificateRequest, lifetime time.Duration, raInfo *raInfo) (*x509.Certificate, []*x509.Certificate, error) {
+func (s *StepCAS) createCertificate(ctx context.Context, cr *x509.CertificateRequest, lifetime time.Duration, raInfo *raInfo) (*x509.Certificate, []*x509.Certificate, error) {
 	sans := make([]string, 0, len(cr.DNSNames)+len(cr.EmailAddresses)+len(cr.IPAddresses)+len(cr.URIs))
 	sans = append(sans, cr.DNSNames...)
 	sans = append(sans, cr.EmailAddresses...)
@@ -188,7 +188,7 @@ func (s *StepCAS) createCertificate(cr *x509.CertificateRequest, lifetime time.D
 		return nil, nil, err
 	}
 
-	resp, err := s.client.Sign(&api.SignRequest{
+	resp, err := s.client.SignWithContext(ctx, &api.SignRequest{
 		CsrPEM:   api.CertificateRequest{CertificateRequest: cr},
 		OTT:      token,
 		NotAfter: s.lifetime(lifetime),
@@ -10,6 +10,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/internal/secret"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/pemutil"
 	"go.step.sm/crypto/randutil"
@@ -126,7 +127,9 @@ func defaultClaims(iss, sub, aud, id string) jose.Claims {
 func readKey(keyFile, password string) (crypto.Signer, error) {
 	var opts []pemutil.Options
 	if password != "" {
-		opts = append(opts, pemutil.WithPassword([]byte(password)))
+		pass := []byte(password)
+		defer secret.Wipe(pass)
+		opts = append(opts, pemutil.WithPassword(pass))
 	}
 	key, err := pemutil.Read(keyFile, opts...)
 	if err != nil {
@@ -11,12 +11,14 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/smallstep/certificates/cas/apiv1"
 	"github.com/smallstep/certificates/cas/vaultcas/auth/approle"
 	"github.com/smallstep/certificates/cas/vaultcas/auth/kubernetes"
+	"github.com/smallstep/certificates/logging"
 
 	vault "github.com/hashicorp/vault/api"
 )
@@ -119,7 +121,7 @@ func (v *VaultCAS) CreateCertificate(req *apiv1.CreateCertificateRequest) (*apiv
 		return nil, errors.New("createCertificate `lifetime` cannot be 0")
 	}
 
-	cert, chain, err := v.createCertificate(req.CSR, req.Lifetime)
+	cert, chain, err := v.createCertificate(apiv1.RequestContext(req.Context), req.CSR, req.Lifetime)
 	if err != nil {
 		return nil, err
 	}
@@ -189,7 +191,8 @@ func (v *VaultCAS) RevokeCertificate(req *apiv1.RevokeCertificateRequest) (*apiv
 	vaultReq := map[string]interface{}{
 		"serial_number": formatSerialNumber(sn),
 	}
-	_, err := v.client.Logical().Write(v.config.PKIMountPath+"/revoke/", vaultReq)
+	ctx := apiv1.RequestContext(req.Context)
+	_, err := v.logical(ctx).WriteWithContext(ctx, v.config.PKIMountPath+"/revoke/", vaultReq)
 	if err != nil {
 		return nil, fmt.Errorf("error revoking certificate: %w", err)
 	}
@@ -200,7 +203,7 @@ func (v *VaultCAS) RevokeCertificate(req *apiv1.RevokeCertificateRequest) (*apiv
 	}, nil
 }
 
-func (v *VaultCAS) createCertificate(cr *x509.CertificateRequest, lifetime time.Duration) (*x509.Certificate, []*x509.Certificate, error) {
+func (v *VaultCAS) createCertificate(ctx context.Context, cr *x509.CertificateRequest, lifetime time.Duration) (*x509.Certificate, []*x509.Certificate, error) {
 	var vaultPKIRole string
 
 	switch {
@@ -223,7 +226,7 @@ func (v *VaultCAS) createCertificate(cr *x509.CertificateRequest, lifetime time.
 		"ttl":    lifetime.String(),
 	}
 
-	secret, err := v.client.Logical().Write(v.config.PKIMountPath+"/sign/"+vaultPKIRole, vaultReq)
+	secret, err := v.logical(ctx).WriteWithContext(ctx, v.config.PKIMountPath+"/sign/"+vaultPKIRole, vaultReq)
 	if err != nil {
 		return nil, nil, fmt.Errorf("error signing certificate: %w", err)
 	}
@@ -332,3 +335,14 @@ func formatSerialNumber(sn *big.Int) string {
 	}
 	return ret.String()
 }
+
+// logical returns the logical backend of the client, sending the trace context
+// and the request id in the given context to Vault.
+func (v *VaultCAS) logical(ctx context.Context) *vault.Logical {
+	return v.client.WithRequestCallbacks(func(r *vault.Request) {
+		if r.Headers == nil {
+			r.Headers = make(http.Header)
+		}
+		logging.InjectTraceHeaders(ctx, r.Headers)
+	}).Logical()
+}
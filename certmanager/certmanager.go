@@ -0,0 +1,296 @@
+// Package certmanager implements a cert-manager external issuer, so the
+// Issuers of a Kubernetes cluster can be pointed directly at the CA.
+//
+// The controller watches the cert-manager CertificateRequest resources of all
+// the namespaces. The requests with the configured issuer reference are signed
+// once they are approved, the certificate and the root are
+// written in the status of the request with the Ready condition, as defined by
+// the cert-manager external issuer contract. The requested usages replace the
+// key usages and the extended key usages of the provisioner template. Denied
+// and invalid requests are marked as failed.
+package certmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/kubernetes"
+)
+
+// Reasons of the Ready condition, as used by cert-manager.
+const (
+	ReasonIssued  = "Issued"
+	ReasonPending = "Pending"
+	ReasonFailed  = "Failed"
+	ReasonDenied  = "Denied"
+)
+
+// requestTimeout is the timeout used to sign a request and update its status.
+var requestTimeout = 30 * time.Second
+
+// now returns the current time, it can be replaced in tests.
+var now = time.Now
+
+// Authority is the interface implemented by the CA authority used to sign the
+// requests.
+type Authority interface {
+	SignCertManager(ctx context.Context, csr *x509.CertificateRequest, validity time.Duration, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage) ([]*x509.Certificate, error)
+	GetRoots() ([]*x509.Certificate, error)
+	IsLeader() bool
+}
+
+// Client is the interface of the Kubernetes API used by the controller.
+type Client interface {
+	WatchCertificateRequests(ctx context.Context, fn func(*kubernetes.CertificateRequest))
+	UpdateCertificateRequestStatus(ctx context.Context, cr *kubernetes.CertificateRequest) error
+}
+
+// keyUsages and extKeyUsages map the usages of cert-manager to the key usages
+// and the extended key usages of the certificates. The usages of the CA
+// certificates and the OCSP responders, cert sign, crl sign, ocsp signing, and
+// any, cannot be requested.
+var (
+	keyUsages = map[string]x509.KeyUsage{
+		"signing":            x509.KeyUsageDigitalSignature,
+		"digital signature":  x509.KeyUsageDigitalSignature,
+		"content commitment": x509.KeyUsageContentCommitment,
+		"key encipherment":   x509.KeyUsageKeyEncipherment,
+		"key agreement":      x509.KeyUsageKeyAgreement,
+		"data encipherment":  x509.KeyUsageDataEncipherment,
+		"encipher only":      x509.KeyUsageEncipherOnly,
+		"decipher only":      x509.KeyUsageDecipherOnly,
+	}
+	extKeyUsages = map[string]x509.ExtKeyUsage{
+		"server auth":      x509.ExtKeyUsageServerAuth,
+		"client auth":      x509.ExtKeyUsageClientAuth,
+		"code signing":     x509.ExtKeyUsageCodeSigning,
+		"email protection": x509.ExtKeyUsageEmailProtection,
+		"s/mime":           x509.ExtKeyUsageEmailProtection,
+		"ipsec end system": x509.ExtKeyUsageIPSECEndSystem,
+		"ipsec tunnel":     x509.ExtKeyUsageIPSECTunnel,
+		"ipsec user":       x509.ExtKeyUsageIPSECUser,
+		"timestamping":     x509.ExtKeyUsageTimeStamping,
+		"microsoft sgc":    x509.ExtKeyUsageMicrosoftServerGatedCrypto,
+		"netscape sgc":     x509.ExtKeyUsageNetscapeServerGatedCrypto,
+	}
+)
+
+// defaultIssuerKind is the kind used by cert-manager if the issuer reference
+// does not have one.
+const defaultIssuerKind = "Issuer"
+
+// Controller signs the cert-manager certificate requests of an issuer.
+type Controller struct {
+	mu     sync.RWMutex
+	auth   Authority
+	client Client
+	issuer kubernetes.IssuerRef
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// New creates a new Controller that signs the requests with the given issuer
+// reference with the given authority. The requests for other issuers, even in
+// the same API group, are ignored.
+func New(auth Authority, client Client, issuer kubernetes.IssuerRef) *Controller {
+	if issuer.Kind == "" {
+		issuer.Kind = defaultIssuerKind
+	}
+	return &Controller{
+		auth:   auth,
+		client: client,
+		issuer: issuer,
+		stop:   make(chan struct{}),
+	}
+}
+
+// SetAuthority replaces the authority used by the controller. It's used when
+// the CA configuration is reloaded.
+func (c *Controller) SetAuthority(auth Authority) {
+	c.mu.Lock()
+	c.auth = auth
+	c.mu.Unlock()
+}
+
+func (c *Controller) getAuthority() Authority {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.auth
+}
+
+// Run watches and signs the certificate requests until Stop is called.
+func (c *Controller) Run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-c.stop
+		cancel()
+	}()
+	c.client.WatchCertificateRequests(ctx, func(cr *kubernetes.CertificateRequest) {
+		if err := c.process(ctx, cr); err != nil && ctx.Err() == nil {
+			log.Printf("error signing certificate request %s/%s: %v", cr.Metadata.Namespace, cr.Metadata.Name, err)
+		}
+	})
+}
+
+// Stop stops the controller.
+func (c *Controller) Stop() {
+	c.once.Do(func() {
+		close(c.stop)
+	})
+}
+
+// process signs the given certificate request if it belongs to the issuer
+// and it is approved. The requests that have already been signed or that have
+// failed are skipped.
+func (c *Controller) process(ctx context.Context, cr *kubernetes.CertificateRequest) error {
+	if !c.isIssuer(cr.Spec.IssuerRef) {
+		return nil
+	}
+	if ready := cr.GetCondition(kubernetes.CertificateRequestConditionReady); ready != nil {
+		if ready.Status == kubernetes.ConditionTrue || ready.Reason == ReasonFailed || ready.Reason == ReasonDenied {
+			return nil
+		}
+	}
+
+	// With more than one replica, only the leader signs the requests.
+	auth := c.getAuthority()
+	if !auth.IsLeader() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	switch {
+	case cr.HasCondition(kubernetes.CertificateRequestConditionDenied, kubernetes.ConditionTrue):
+		return c.fail(ctx, cr, ReasonDenied, "The certificate request has been denied")
+	case !cr.HasCondition(kubernetes.CertificateRequestConditionApproved, kubernetes.ConditionTrue):
+		// Wait for the approval of the request.
+		return nil
+	}
+
+	req, err := parseRequest(&cr.Spec)
+	if err != nil {
+		return c.fail(ctx, cr, ReasonFailed, err.Error())
+	}
+
+	chain, err := auth.SignCertManager(ctx, req.csr, req.validity, req.keyUsage, req.extKeyUsage)
+	if err != nil {
+		// The client errors are permanent, the rest are retried the next
+		// time the request is listed.
+		var sc interface{ StatusCode() int }
+		if errors.As(err, &sc) && sc.StatusCode() >= http.StatusBadRequest && sc.StatusCode() < http.StatusInternalServerError {
+			return c.fail(ctx, cr, ReasonFailed, "Error signing certificate: "+err.Error())
+		}
+		cr.SetCondition(kubernetes.CertificateRequestConditionReady, kubernetes.ConditionFalse,
+			ReasonPending, "Error signing certificate, it will be retried: "+err.Error(), now())
+		if uerr := c.client.UpdateCertificateRequestStatus(ctx, cr); uerr != nil {
+			return uerr
+		}
+		return err
+	}
+	roots, err := auth.GetRoots()
+	if err != nil {
+		return err
+	}
+
+	cr.Status.Certificate = encodeCertificates(chain)
+	if len(roots) > 0 {
+		cr.Status.CA = encodeCertificates(roots[:1])
+	}
+	cr.SetCondition(kubernetes.CertificateRequestConditionReady, kubernetes.ConditionTrue,
+		ReasonIssued, "Certificate issued", now())
+	return c.client.UpdateCertificateRequestStatus(ctx, cr)
+}
+
+// isIssuer returns true if the given issuer reference is the one of the
+// controller.
+func (c *Controller) isIssuer(ref kubernetes.IssuerRef) bool {
+	kind := ref.Kind
+	if kind == "" {
+		kind = defaultIssuerKind
+	}
+	return ref.Group == c.issuer.Group && kind == c.issuer.Kind && ref.Name == c.issuer.Name
+}
+
+// fail marks the given request as failed, cert-manager does not retry it.
+func (c *Controller) fail(ctx context.Context, cr *kubernetes.CertificateRequest, reason, message string) error {
+	t := now()
+	cr.Status.FailureTime = &t
+	cr.SetCondition(kubernetes.CertificateRequestConditionReady, kubernetes.ConditionFalse, reason, message, t)
+	return c.client.UpdateCertificateRequestStatus(ctx, cr)
+}
+
+// request is a parsed cert-manager certificate request.
+type request struct {
+	csr         *x509.CertificateRequest
+	validity    time.Duration
+	keyUsage    x509.KeyUsage
+	extKeyUsage []x509.ExtKeyUsage
+}
+
+func parseRequest(spec *kubernetes.CertificateRequestSpec) (*request, error) {
+	if spec.IsCA {
+		return nil, errors.New("isCA is not supported")
+	}
+	req := new(request)
+	for _, u := range spec.Usages {
+		if ku, ok := keyUsages[strings.ToLower(u)]; ok {
+			req.keyUsage |= ku
+		} else if eku, ok := extKeyUsages[strings.ToLower(u)]; ok {
+			req.extKeyUsage = appendExtKeyUsage(req.extKeyUsage, eku)
+		} else {
+			return nil, errors.Errorf("unsupported usage %q", u)
+		}
+	}
+	if spec.Duration != "" {
+		d, err := time.ParseDuration(spec.Duration)
+		if err != nil || d <= 0 {
+			return nil, errors.Errorf("invalid duration %q", spec.Duration)
+		}
+		req.validity = d
+	}
+
+	block, _ := pem.Decode(spec.Request)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, errors.New("request is not a PEM encoded certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing certificate request")
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, errors.Wrap(err, "invalid certificate request signature")
+	}
+	req.csr = csr
+	return req, nil
+}
+
+// appendExtKeyUsage appends an extended key usage if it's not already in the
+// list, "email protection" and "s/mime" are the same usage.
+func appendExtKeyUsage(list []x509.ExtKeyUsage, eku x509.ExtKeyUsage) []x509.ExtKeyUsage {
+	for _, e := range list {
+		if e == eku {
+			return list
+		}
+	}
+	return append(list, eku)
+}
+
+func encodeCertificates(certs []*x509.Certificate) []byte {
+	var b []byte
+	for _, crt := range certs {
+		b = append(b, pem.EncodeToMemory(&pem.Block{
+			Type: "CERTIFICATE", Bytes: crt.Raw,
+		})...)
+	}
+	return b
+}
@@ -0,0 +1,287 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/kubernetes"
+)
+
+type mockAuthority struct {
+	ca       *minica.CA
+	follower bool
+	sign     func(csr *x509.CertificateRequest, validity time.Duration) ([]*x509.Certificate, error)
+}
+
+func (m *mockAuthority) SignCertManager(_ context.Context, csr *x509.CertificateRequest, validity time.Duration, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage) ([]*x509.Certificate, error) {
+	if m.sign != nil {
+		return m.sign(csr, validity)
+	}
+	crt, err := m.ca.SignCSR(csr, minica.WithModifyFunc(func(crt *x509.Certificate) error {
+		if keyUsage != 0 || len(extKeyUsage) > 0 {
+			crt.KeyUsage, crt.ExtKeyUsage = keyUsage, extKeyUsage
+		}
+		return nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return []*x509.Certificate{crt, m.ca.Intermediate}, nil
+}
+
+func (m *mockAuthority) GetRoots() ([]*x509.Certificate, error) {
+	return []*x509.Certificate{m.ca.Root}, nil
+}
+
+func (m *mockAuthority) IsLeader() bool {
+	return !m.follower
+}
+
+// mockClient sends the given requests to the watch, and keeps the updated
+// ones.
+type mockClient struct {
+	mu       sync.Mutex
+	requests []*kubernetes.CertificateRequest
+	updated  []*kubernetes.CertificateRequest
+	err      error
+}
+
+func (m *mockClient) WatchCertificateRequests(ctx context.Context, fn func(*kubernetes.CertificateRequest)) {
+	for _, cr := range m.requests {
+		fn(cr)
+	}
+	<-ctx.Done()
+}
+
+func (m *mockClient) UpdateCertificateRequestStatus(_ context.Context, cr *kubernetes.CertificateRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.updated = append(m.updated, cr)
+	return nil
+}
+
+func mustCSR(t *testing.T) []byte {
+	t.Helper()
+	signer, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(nil, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "test.example.com"},
+		DNSNames: []string{"test.example.com"},
+	}, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+func newRequest(spec kubernetes.CertificateRequestSpec, conditions ...string) *kubernetes.CertificateRequest {
+	cr := &kubernetes.CertificateRequest{
+		Metadata: kubernetes.Metadata{Name: "web", Namespace: "default"},
+		Spec:     spec,
+	}
+	if spec.IssuerRef.Group == "" {
+		cr.Spec.IssuerRef = kubernetes.IssuerRef{Name: "step-ca", Kind: "Issuer", Group: "ca.step.sm"}
+	}
+	for i := 0; i+1 < len(conditions); i += 2 {
+		cr.SetCondition(conditions[i], conditions[i+1], "", "", time.Now())
+	}
+	return cr
+}
+
+var testIssuer = kubernetes.IssuerRef{Name: "step-ca", Kind: "Issuer", Group: "ca.step.sm"}
+
+func TestController_process(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr := mustCSR(t)
+
+	var gotValidity time.Duration
+	auth := &mockAuthority{ca: ca}
+	withValidity := &mockAuthority{ca: ca, sign: func(csr *x509.CertificateRequest, validity time.Duration) ([]*x509.Certificate, error) {
+		gotValidity = validity
+		return auth.SignCertManager(context.Background(), csr, validity, 0, nil)
+	}}
+	forbidden := &mockAuthority{ca: ca, sign: func(*x509.CertificateRequest, time.Duration) ([]*x509.Certificate, error) {
+		return nil, errs.Forbidden("not allowed")
+	}}
+	unavailable := &mockAuthority{ca: ca, sign: func(*x509.CertificateRequest, time.Duration) ([]*x509.Certificate, error) {
+		return nil, errors.New("connection refused")
+	}}
+
+	approved := []string{kubernetes.CertificateRequestConditionApproved, kubernetes.ConditionTrue}
+	tests := []struct {
+		name       string
+		auth       Authority
+		cr         *kubernetes.CertificateRequest
+		wantUpdate bool
+		wantReady  string
+		wantReason string
+		wantErr    bool
+	}{
+		{"ok", auth, newRequest(kubernetes.CertificateRequestSpec{Request: csr, Usages: []string{"digital signature", "Server Auth"}}, approved...), true, kubernetes.ConditionTrue, ReasonIssued, false},
+		{"ok duration", withValidity, newRequest(kubernetes.CertificateRequestSpec{Request: csr, Duration: "2160h0m0s"}, approved...), true, kubernetes.ConditionTrue, ReasonIssued, false},
+		{"ok default kind", auth, newRequest(kubernetes.CertificateRequestSpec{Request: csr, IssuerRef: kubernetes.IssuerRef{Name: "step-ca", Group: "ca.step.sm"}}, approved...), true, kubernetes.ConditionTrue, ReasonIssued, false},
+		{"skip group", auth, newRequest(kubernetes.CertificateRequestSpec{Request: csr, IssuerRef: kubernetes.IssuerRef{Name: "ca", Kind: "Issuer", Group: "cert-manager.io"}}, approved...), false, "", "", false},
+		{"skip kind", auth, newRequest(kubernetes.CertificateRequestSpec{Request: csr, IssuerRef: kubernetes.IssuerRef{Name: "step-ca", Kind: "ClusterIssuer", Group: "ca.step.sm"}}, approved...), false, "", "", false},
+		{"skip name", auth, newRequest(kubernetes.CertificateRequestSpec{Request: csr, IssuerRef: kubernetes.IssuerRef{Name: "other", Kind: "Issuer", Group: "ca.step.sm"}}, approved...), false, "", "", false},
+		{"skip not approved", auth, newRequest(kubernetes.CertificateRequestSpec{Request: csr}), false, "", "", false},
+		{"skip ready", auth, newRequest(kubernetes.CertificateRequestSpec{Request: csr}, append(approved, kubernetes.CertificateRequestConditionReady, kubernetes.ConditionTrue)...), false, "", "", false},
+		{"skip follower", &mockAuthority{ca: ca, follower: true}, newRequest(kubernetes.CertificateRequestSpec{Request: csr}, approved...), false, "", "", false},
+		{"fail denied", auth, newRequest(kubernetes.CertificateRequestSpec{Request: csr}, kubernetes.CertificateRequestConditionDenied, kubernetes.ConditionTrue), true, kubernetes.ConditionFalse, ReasonDenied, false},
+		{"fail isCA", auth, newRequest(kubernetes.CertificateRequestSpec{Request: csr, IsCA: true}, approved...), true, kubernetes.ConditionFalse, ReasonFailed, false},
+		{"fail usage ocsp signing", auth, newRequest(kubernetes.CertificateRequestSpec{Request: csr, Usages: []string{"ocsp signing"}}, approved...), true, kubernetes.ConditionFalse, ReasonFailed, false},
+		{"fail usage", auth, newRequest(kubernetes.CertificateRequestSpec{Request: csr, Usages: []string{"foo"}}, approved...), true, kubernetes.ConditionFalse, ReasonFailed, false},
+		{"fail duration", auth, newRequest(kubernetes.CertificateRequestSpec{Request: csr, Duration: "-1h"}, approved...), true, kubernetes.ConditionFalse, ReasonFailed, false},
+		{"fail request", auth, newRequest(kubernetes.CertificateRequestSpec{Request: []byte("not a csr")}, approved...), true, kubernetes.ConditionFalse, ReasonFailed, false},
+		{"fail forbidden", forbidden, newRequest(kubernetes.CertificateRequestSpec{Request: csr}, approved...), true, kubernetes.ConditionFalse, ReasonFailed, false},
+		{"fail unavailable", unavailable, newRequest(kubernetes.CertificateRequestSpec{Request: csr}, approved...), true, kubernetes.ConditionFalse, ReasonPending, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := new(mockClient)
+			c := New(tt.auth, client, testIssuer)
+			if err := c.process(context.Background(), tt.cr); (err != nil) != tt.wantErr {
+				t.Fatalf("Controller.process() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantUpdate {
+				if len(client.updated) != 0 {
+					t.Errorf("Controller.process() updated %d requests, want 0", len(client.updated))
+				}
+				return
+			}
+			if len(client.updated) != 1 {
+				t.Fatalf("Controller.process() updated %d requests, want 1", len(client.updated))
+			}
+
+			cr := client.updated[0]
+			ready := cr.GetCondition(kubernetes.CertificateRequestConditionReady)
+			if ready == nil || ready.Status != tt.wantReady || ready.Reason != tt.wantReason {
+				t.Fatalf("Controller.process() Ready condition = %+v, want %s/%s", ready, tt.wantReady, tt.wantReason)
+			}
+			if tt.wantReason == ReasonFailed || tt.wantReason == ReasonDenied {
+				if cr.Status.FailureTime == nil {
+					t.Error("Controller.process() failureTime is not set")
+				}
+			}
+			if tt.wantReady != kubernetes.ConditionTrue {
+				return
+			}
+
+			var blocks []*pem.Block
+			for rest := cr.Status.Certificate; len(rest) > 0; {
+				var block *pem.Block
+				if block, rest = pem.Decode(rest); block == nil {
+					break
+				}
+				blocks = append(blocks, block)
+			}
+			if len(blocks) != 2 {
+				t.Fatalf("Controller.process() certificate has %d blocks, want 2", len(blocks))
+			}
+			if len(tt.cr.Spec.Usages) > 0 {
+				leaf, err := x509.ParseCertificate(blocks[0].Bytes)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if leaf.KeyUsage != x509.KeyUsageDigitalSignature {
+					t.Errorf("Controller.process() key usage = %v, want %v", leaf.KeyUsage, x509.KeyUsageDigitalSignature)
+				}
+				if !reflect.DeepEqual(leaf.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}) {
+					t.Errorf("Controller.process() extended key usages = %v, want server auth", leaf.ExtKeyUsage)
+				}
+			}
+			if block, _ := pem.Decode(cr.Status.CA); block == nil || string(block.Bytes) != string(ca.Root.Raw) {
+				t.Error("Controller.process() ca does not match the root")
+			}
+			if tt.cr.Spec.Duration != "" && gotValidity != 2160*time.Hour {
+				t.Errorf("Controller.process() validity = %v, want %v", gotValidity, 2160*time.Hour)
+			}
+		})
+	}
+}
+
+func TestController_Run(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	approved := []string{kubernetes.CertificateRequestConditionApproved, kubernetes.ConditionTrue}
+	client := &mockClient{requests: []*kubernetes.CertificateRequest{
+		newRequest(kubernetes.CertificateRequestSpec{Request: mustCSR(t)}, approved...),
+		newRequest(kubernetes.CertificateRequestSpec{Request: mustCSR(t)}),
+	}}
+
+	c := New(&mockAuthority{ca: ca, follower: true}, client, testIssuer)
+	c.SetAuthority(&mockAuthority{ca: ca})
+	done := make(chan struct{})
+	go func() {
+		c.Run()
+		close(done)
+	}()
+	c.Stop()
+	c.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Controller.Run() did not return")
+	}
+
+	if len(client.updated) != 1 || !client.updated[0].HasCondition(kubernetes.CertificateRequestConditionReady, kubernetes.ConditionTrue) {
+		t.Errorf("Controller.Run() updated = %v, want 1 ready request", client.updated)
+	}
+}
+
+func TestController_process_updateError(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &mockClient{err: errs.New(http.StatusConflict, "conflict")}
+	c := New(&mockAuthority{ca: ca}, client, testIssuer)
+	cr := newRequest(kubernetes.CertificateRequestSpec{Request: mustCSR(t)},
+		kubernetes.CertificateRequestConditionApproved, kubernetes.ConditionTrue)
+	if err := c.process(context.Background(), cr); err == nil {
+		t.Error("Controller.process() error = nil, want error")
+	}
+}
+
+func Test_parseRequest_usages(t *testing.T) {
+	req, err := parseRequest(&kubernetes.CertificateRequestSpec{
+		Request: mustCSR(t),
+		Usages:  []string{"Digital Signature", "key encipherment", "server auth", "client auth", "email protection", "s/mime"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment; req.keyUsage != want {
+		t.Errorf("parseRequest() keyUsage = %v, want %v", req.keyUsage, want)
+	}
+	if want := []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageEmailProtection}; !reflect.DeepEqual(req.extKeyUsage, want) {
+		t.Errorf("parseRequest() extKeyUsage = %v, want %v", req.extKeyUsage, want)
+	}
+
+	for _, u := range []string{"cert sign", "crl sign", "ocsp signing", "any"} {
+		if _, err := parseRequest(&kubernetes.CertificateRequestSpec{Request: mustCSR(t), Usages: []string{u}}); err == nil {
+			t.Errorf("parseRequest() with usage %q error = nil", u)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"net"
@@ -10,7 +9,6 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"unicode"
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/acme"
@@ -18,6 +16,7 @@ import (
 	"github.com/smallstep/certificates/authority/provisioner"
 	"github.com/smallstep/certificates/ca"
 	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/internal/secret"
 	"github.com/smallstep/certificates/pki"
 	"github.com/urfave/cli"
 	"go.step.sm/cli-utils/errs"
@@ -35,24 +34,26 @@ var AppCommand = cli.Command{
 		cli.StringFlag{
 			Name: "password-file",
 			Usage: `path to the <file> containing the password to decrypt the
-intermediate private key.`,
+intermediate private key. It can also be a reference to a secret source, e.g.
+env:STEP_CA_PASSWORD, vault:<path>#<field>, awssm:<name>, or gcpsm:<name>.`,
 		},
 		cli.StringFlag{
 			Name: "ssh-host-password-file",
 			Usage: `path to the <file> containing the password to decrypt the
 private key used to sign SSH host certificates. If the flag is not passed it
-will default to --password-file.`,
+will default to --password-file. It can also be a reference to a secret source.`,
 		},
 		cli.StringFlag{
 			Name: "ssh-user-password-file",
 			Usage: `path to the <file> containing the password to decrypt the
 private key used to sign SSH user certificates. If the flag is not passed it
-will default to --password-file.`,
+will default to --password-file. It can also be a reference to a secret source.`,
 		},
 		cli.StringFlag{
 			Name: "issuer-password-file",
 			Usage: `path to the <file> containing the password to decrypt the
-certificate issuer private key used in the RA mode.`,
+certificate issuer private key used in the RA mode. It can also be a reference
+to a secret source.`,
 		},
 		cli.StringFlag{
 			Name:  "resolver",
@@ -91,6 +92,11 @@ Requires **--insecure** flag.`,
 			Name:  "insecure",
 			Usage: "enable insecure flags.",
 		},
+		cli.BoolFlag{
+			Name: "self-test",
+			Usage: `sign a test certificate with the provisioner in the selfTest configuration
+and check it with the revocation endpoints before starting the server.`,
+		},
 	},
 }
 
@@ -197,34 +203,42 @@ To get a linked authority token:
 
 	var password []byte
 	if passFile != "" {
-		if password, err = os.ReadFile(passFile); err != nil {
-			fatal(errors.Wrapf(err, "error reading %s", passFile))
+		buf, err := secret.Load(context.Background(), passFile)
+		if err != nil {
+			fatal(err)
 		}
-		password = bytes.TrimRightFunc(password, unicode.IsSpace)
+		defer buf.Destroy()
+		password = buf.Bytes()
 	}
 
 	var sshHostPassword []byte
 	if sshHostPassFile != "" {
-		if sshHostPassword, err = os.ReadFile(sshHostPassFile); err != nil {
-			fatal(errors.Wrapf(err, "error reading %s", sshHostPassFile))
+		buf, err := secret.Load(context.Background(), sshHostPassFile)
+		if err != nil {
+			fatal(err)
 		}
-		sshHostPassword = bytes.TrimRightFunc(sshHostPassword, unicode.IsSpace)
+		defer buf.Destroy()
+		sshHostPassword = buf.Bytes()
 	}
 
 	var sshUserPassword []byte
 	if sshUserPassFile != "" {
-		if sshUserPassword, err = os.ReadFile(sshUserPassFile); err != nil {
-			fatal(errors.Wrapf(err, "error reading %s", sshUserPassFile))
+		buf, err := secret.Load(context.Background(), sshUserPassFile)
+		if err != nil {
+			fatal(err)
 		}
-		sshUserPassword = bytes.TrimRightFunc(sshUserPassword, unicode.IsSpace)
+		defer buf.Destroy()
+		sshUserPassword = buf.Bytes()
 	}
 
 	var issuerPassword []byte
 	if issuerPassFile != "" {
-		if issuerPassword, err = os.ReadFile(issuerPassFile); err != nil {
-			fatal(errors.Wrapf(err, "error reading %s", issuerPassFile))
+		buf, err := secret.Load(context.Background(), issuerPassFile)
+		if err != nil {
+			fatal(err)
 		}
-		issuerPassword = bytes.TrimRightFunc(issuerPassword, unicode.IsSpace)
+		defer buf.Destroy()
+		issuerPassword = buf.Bytes()
 	}
 
 	if filename := ctx.String("pidfile"); filename != "" {
@@ -251,7 +265,8 @@ To get a linked authority token:
 		ca.WithSSHUserPassword(sshUserPassword),
 		ca.WithIssuerPassword(issuerPassword),
 		ca.WithLinkedCAToken(token),
-		ca.WithQuiet(quiet))
+		ca.WithQuiet(quiet),
+		ca.WithSelfTest(ctx.Bool("self-test")))
 	if err != nil {
 		fatal(err)
 	}
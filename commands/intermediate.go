@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"context"
+	"crypto"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/internal/secret"
+	"github.com/smallstep/certificates/pki"
+	"github.com/urfave/cli"
+	"go.step.sm/cli-utils/command"
+	"go.step.sm/cli-utils/errs"
+	"go.step.sm/cli-utils/fileutil"
+	"go.step.sm/crypto/kms"
+	kmsapi "go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/pemutil"
+)
+
+func init() {
+	passwordFileFlag := cli.StringFlag{
+		Name: "password-file",
+		Usage: `path to the <file> containing the password to decrypt the
+intermediate private key. It can also be a reference to a secret source, e.g.
+env:STEP_CA_PASSWORD.`,
+	}
+
+	command.Register(cli.Command{
+		Name:      "intermediate",
+		Usage:     "re-issue the intermediate certificate using an offline root",
+		UsageText: "**step-ca intermediate** <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step-ca intermediate** command group provides the key ceremony workflow
+used to re-issue the intermediate certificate of a CA with an offline root.
+
+The root key never needs to be present on the CA host: a certificate signing
+request is created with the current intermediate key, signed offline with the
+root key, and the resulting certificate is installed after verifying it
+against the configured roots.
+
+## EXAMPLES
+
+Create a certificate signing request for the intermediate key:
+'''
+$ step-ca intermediate csr $(step path)/config/ca.json intermediate_ca.csr
+'''
+
+Install the intermediate certificate signed by the offline root:
+'''
+$ step-ca intermediate install $(step path)/config/ca.json intermediate_ca.crt
+'''`,
+		Subcommands: cli.Commands{
+			{
+				Name:      "csr",
+				Usage:     "create a certificate signing request for the intermediate key",
+				UsageText: "**step-ca intermediate csr** <config> <csr-file>",
+				Action:    intermediateCSRAction,
+				Description: `**step-ca intermediate csr** creates a certificate signing request using the
+intermediate key and the subject of the current intermediate certificate.
+
+## POSITIONAL ARGUMENTS
+
+<config>
+:  The ca.json that contains the step-ca configuration.
+
+<csr-file>
+:  The path where the certificate signing request will be written.`,
+				Flags: []cli.Flag{passwordFileFlag},
+			},
+			{
+				Name:      "install",
+				Usage:     "install an intermediate certificate signed by the offline root",
+				UsageText: "**step-ca intermediate install** <config> <crt-file>",
+				Action:    intermediateInstallAction,
+				Description: `**step-ca intermediate install** verifies that the given certificate is a CA
+certificate, signed by one of the configured roots and for the intermediate
+key, and replaces the configured intermediate certificate with it.
+
+## POSITIONAL ARGUMENTS
+
+<config>
+:  The ca.json that contains the step-ca configuration.
+
+<crt-file>
+:  The path to the intermediate certificate, optionally followed by other
+intermediates in the chain.`,
+				Flags: []cli.Flag{passwordFileFlag},
+			},
+		},
+	})
+}
+
+func intermediateCSRAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 2); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfiguration(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	km, signer, err := intermediateSigner(cfg, ctx.String("password-file"))
+	if err != nil {
+		return err
+	}
+	defer km.Close()
+	crt, err := pemutil.ReadCertificate(cfg.IntermediateCert)
+	if err != nil {
+		return err
+	}
+
+	csr, err := pki.NewIntermediateCSR(signer, crt.Subject)
+	if err != nil {
+		return err
+	}
+
+	csrFile := ctx.Args().Get(1)
+	if err := fileutil.WriteFile(csrFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE REQUEST",
+		Bytes: csr.Raw,
+	}), 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Your certificate signing request has been saved in %s.\n", csrFile)
+	return nil
+}
+
+func intermediateInstallAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 2); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfiguration(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	crtFile := ctx.Args().Get(1)
+	chain, err := pemutil.ReadCertificateBundle(crtFile)
+	if err != nil {
+		return err
+	}
+	km, signer, err := intermediateSigner(cfg, ctx.String("password-file"))
+	if err != nil {
+		return err
+	}
+	defer km.Close()
+
+	var roots = chain[1:]
+	for _, path := range cfg.Root {
+		crts, err := pemutil.ReadCertificateBundle(path)
+		if err != nil {
+			return err
+		}
+		roots = append(roots, crts...)
+	}
+	if err := pki.VerifyIntermediateCertificate(chain[0], roots, signer.Public()); err != nil {
+		return err
+	}
+
+	b, err := os.ReadFile(crtFile)
+	if err != nil {
+		return errs.FileError(err, crtFile)
+	}
+	if err := fileutil.WriteFile(cfg.IntermediateCert, b, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Your intermediate certificate has been installed in %s.\n", cfg.IntermediateCert)
+	return nil
+}
+
+// intermediateSigner returns the key manager and the signer for the
+// intermediate key in the given configuration. The key manager must be closed
+// after using the signer.
+func intermediateSigner(cfg *config.Config, passwordFile string) (kmsapi.KeyManager, crypto.Signer, error) {
+	var password []byte
+	if passwordFile != "" {
+		buf, err := secret.Load(context.Background(), passwordFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer buf.Destroy()
+		password = buf.Bytes()
+	} else if secret.IsReference(cfg.Password) {
+		buf, err := secret.Get(context.Background(), cfg.Password)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer buf.Destroy()
+		password = buf.Bytes()
+	} else if cfg.Password != "" {
+		password = []byte(cfg.Password)
+	}
+
+	var options kmsapi.Options
+	if cfg.KMS != nil {
+		options = *cfg.KMS
+	}
+	km, err := kms.New(context.Background(), options)
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, err := km.CreateSigner(&kmsapi.CreateSignerRequest{
+		SigningKey: cfg.IntermediateKey,
+		Password:   password,
+	})
+	if err != nil {
+		km.Close()
+		return nil, nil, err
+	}
+	return km, signer, nil
+}
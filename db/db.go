@@ -10,6 +10,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/internal/secret"
 	"github.com/smallstep/nosql"
 	"github.com/smallstep/nosql/database"
 	"golang.org/x/crypto/ssh"
@@ -18,6 +19,7 @@ import (
 var (
 	certsTable             = []byte("x509_certs")
 	certsDataTable         = []byte("x509_certs_data")
+	certsLifecycleTable    = []byte("x509_certs_lifecycle")
 	revokedCertsTable      = []byte("revoked_x509_certs")
 	crlTable               = []byte("x509_crl")
 	revokedSSHCertsTable   = []byte("revoked_ssh_certs")
@@ -26,6 +28,9 @@ var (
 	sshHostsTable          = []byte("ssh_hosts")
 	sshUsersTable          = []byte("ssh_users")
 	sshHostPrincipalsTable = []byte("ssh_host_principals")
+	blockedKeysTable       = []byte("blocked_keys")
+	keyIdentitiesTable     = []byte("key_identities")
+	deniedIdentitiesTable  = []byte("denied_identities")
 )
 
 // TODO: at the moment we store a single CRL in the database, in a dedicated table.
@@ -42,6 +47,10 @@ type Config struct {
 	DataSource string `json:"dataSource"`
 	ValueDir   string `json:"valueDir,omitempty"`
 	Database   string `json:"database,omitempty"`
+	// Namespace prefixes the tables of the authority, so multiple authorities
+	// can share the same database. It is not supported by the relational
+	// database types.
+	Namespace string `json:"namespace,omitempty"`
 
 	// BadgerFileLoadingMode can be set to 'FileIO' (instead of the default
 	// 'MemoryMap') to avoid memory-mapping log files. This can be useful
@@ -92,6 +101,69 @@ type CertificateStorer interface {
 	StoreSSHCertificate(crt *ssh.Certificate) error
 }
 
+// CertificateLister is an extension of AuthDB that allows to list the stored
+// X.509 certificates.
+type CertificateLister interface {
+	GetCertificates() ([]*x509.Certificate, error)
+}
+
+// CertificateLifecycleDB is an extension of AuthDB that allows to read the
+// renewals of the stored X.509 certificates and their revocation details.
+type CertificateLifecycleDB interface {
+	GetCertificateLifecycle(serialNumber string) (*CertificateLifecycle, error)
+	GetRevokedCertificate(serialNumber string) (*RevokedCertificateInfo, error)
+}
+
+// BlockedKeyDB is an extension of AuthDB that stores the public keys that
+// cannot be used in new certificates.
+type BlockedKeyDB interface {
+	BlockKey(info *BlockedKeyInfo) error
+	GetBlockedKeys() ([]*BlockedKeyInfo, error)
+}
+
+// BlockedKeyInfo contains the information of a blocked public key.
+type BlockedKeyInfo struct {
+	Fingerprint string    `json:"fingerprint"`
+	Serial      string    `json:"serial,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	BlockedAt   time.Time `json:"blockedAt"`
+}
+
+// DeniedIdentityDB is an extension of AuthDB that stores the identities that
+// cannot be used in new certificates until an admin clears them.
+type DeniedIdentityDB interface {
+	DenyIdentity(info *DeniedIdentity) error
+	GetDeniedIdentity(name string) (*DeniedIdentity, error)
+	GetDeniedIdentities() ([]*DeniedIdentity, error)
+	DeleteDeniedIdentity(name string) error
+}
+
+// DeniedIdentity contains the information of a denied identity, a common name
+// or SAN of a revoked certificate.
+type DeniedIdentity struct {
+	Name     string    `json:"name"`
+	Serial   string    `json:"serial,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	DeniedAt time.Time `json:"deniedAt"`
+}
+
+// KeyIdentityDB is an extension of AuthDB that stores the first identity that
+// used a public key.
+type KeyIdentityDB interface {
+	GetKeyIdentity(fingerprint string) (*KeyIdentity, error)
+	StoreKeyIdentity(ki *KeyIdentity) error
+}
+
+// KeyIdentity contains the provisioner and the identity that first used a
+// public key.
+type KeyIdentity struct {
+	Fingerprint   string    `json:"fingerprint"`
+	ProvisionerID string    `json:"provisionerID"`
+	Identity      string    `json:"identity"`
+	Serial        string    `json:"serial"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
 // CertificateRevocationListDB is an interface to indicate whether the DB supports CRL generation
 type CertificateRevocationListDB interface {
 	GetRevokedCertificates() (*[]RevokedCertificateInfo, error)
@@ -110,6 +182,29 @@ func New(c *Config) (AuthDB, error) {
 	if c == nil {
 		return newSimpleDB(c)
 	}
+	// The data source can be a reference to a secret source, e.g.
+	// "env:STEP_CA_DB_DSN", so the credentials are not kept in the config.
+	if secret.IsReference(c.DataSource) {
+		dataSource, err := secret.Resolve(context.Background(), c.DataSource)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading database dataSource")
+		}
+		cc := *c
+		cc.DataSource = dataSource
+		c = &cc
+	}
+	if IsSQLType(c.Type) {
+		if c.Namespace != "" {
+			return nil, errors.Errorf("database type %s does not support namespaces", c.Type)
+		}
+		return newSQLDB(c)
+	}
+	if c.Namespace != "" {
+		// Validate the namespace before opening the database.
+		if err := ValidateNamespace(c.Namespace); err != nil {
+			return nil, err
+		}
+	}
 
 	opts := []nosql.Option{nosql.WithDatabase(c.Database),
 		nosql.WithValueDir(c.ValueDir)}
@@ -121,11 +216,17 @@ func New(c *Config) (AuthDB, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "Error opening database of Type %s", c.Type)
 	}
+	if c.Namespace != "" {
+		if db, err = NewNamespacedDB(db, c.Namespace); err != nil {
+			return nil, err
+		}
+	}
 
 	tables := [][]byte{
 		revokedCertsTable, certsTable, usedOTTTable,
 		sshCertsTable, sshHostsTable, sshHostPrincipalsTable, sshUsersTable,
-		revokedSSHCertsTable, certsDataTable, crlTable,
+		revokedSSHCertsTable, certsDataTable, crlTable, certsLifecycleTable,
+		blockedKeysTable, keyIdentitiesTable, deniedIdentitiesTable,
 	}
 	for _, b := range tables {
 		if err := db.CreateTable(b); err != nil {
@@ -158,6 +259,9 @@ type CertificateRevocationListInfo struct {
 	ExpiresAt time.Time
 	Duration  time.Duration
 	DER       []byte
+	// SignerDER is the certificate of the delegated CRL signer, it's empty if
+	// the CRL is signed by the intermediate.
+	SignerDER []byte `json:",omitempty"`
 }
 
 // IsRevoked returns whether or not a certificate with the given identifier
@@ -300,6 +404,164 @@ func (db *DB) GetCertificate(serialNumber string) (*x509.Certificate, error) {
 	return cert, nil
 }
 
+// GetCertificates returns all the stored X.509 certificates.
+func (db *DB) GetCertificates() ([]*x509.Certificate, error) {
+	entries, err := db.List(certsTable)
+	if err != nil {
+		return nil, errors.Wrap(err, "database List error")
+	}
+	certs := make([]*x509.Certificate, 0, len(entries))
+	for _, e := range entries {
+		cert, err := x509.ParseCertificate(e.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing certificate with serial number %s", e.Key)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// GetRevokedCertificate returns the revocation details of the certificate with
+// the given serial number.
+func (db *DB) GetRevokedCertificate(serialNumber string) (*RevokedCertificateInfo, error) {
+	b, err := db.Get(revokedCertsTable, []byte(serialNumber))
+	if err != nil {
+		return nil, errors.Wrap(err, "database Get error")
+	}
+	var rci RevokedCertificateInfo
+	if err := json.Unmarshal(b, &rci); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling json")
+	}
+	return &rci, nil
+}
+
+// GetCertificateLifecycle returns the lifecycle stored for the certificate
+// with the given serial number.
+func (db *DB) GetCertificateLifecycle(serialNumber string) (*CertificateLifecycle, error) {
+	b, err := db.Get(certsLifecycleTable, []byte(serialNumber))
+	if err != nil {
+		return nil, errors.Wrap(err, "database Get error")
+	}
+	var lc CertificateLifecycle
+	if err := json.Unmarshal(b, &lc); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling json")
+	}
+	return &lc, nil
+}
+
+// BlockKey stores the fingerprint of a public key that cannot be used anymore.
+func (db *DB) BlockKey(info *BlockedKeyInfo) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling blocked key information")
+	}
+	if err := db.Set(blockedKeysTable, []byte(info.Fingerprint), b); err != nil {
+		return errors.Wrap(err, "database Set error")
+	}
+	return nil
+}
+
+// GetBlockedKeys returns all the blocked public keys.
+func (db *DB) GetBlockedKeys() ([]*BlockedKeyInfo, error) {
+	entries, err := db.List(blockedKeysTable)
+	if err != nil {
+		return nil, errors.Wrap(err, "database List error")
+	}
+	keys := make([]*BlockedKeyInfo, 0, len(entries))
+	for _, e := range entries {
+		var info BlockedKeyInfo
+		if err := json.Unmarshal(e.Value, &info); err != nil {
+			return nil, errors.Wrap(err, "error unmarshaling json")
+		}
+		keys = append(keys, &info)
+	}
+	return keys, nil
+}
+
+// DenyIdentity stores an identity that cannot be used anymore.
+func (db *DB) DenyIdentity(info *DeniedIdentity) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling denied identity information")
+	}
+	if err := db.Set(deniedIdentitiesTable, []byte(info.Name), b); err != nil {
+		return errors.Wrap(err, "database Set error")
+	}
+	return nil
+}
+
+// GetDeniedIdentity returns the denied identity with the given name. It
+// returns nil if the identity is not denied.
+func (db *DB) GetDeniedIdentity(name string) (*DeniedIdentity, error) {
+	b, err := db.Get(deniedIdentitiesTable, []byte(name))
+	switch {
+	case nosql.IsErrNotFound(err):
+		return nil, nil //nolint:nilnil // nil means not denied
+	case err != nil:
+		return nil, errors.Wrap(err, "database Get error")
+	}
+	var info DeniedIdentity
+	if err := json.Unmarshal(b, &info); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling json")
+	}
+	return &info, nil
+}
+
+// GetDeniedIdentities returns all the denied identities.
+func (db *DB) GetDeniedIdentities() ([]*DeniedIdentity, error) {
+	entries, err := db.List(deniedIdentitiesTable)
+	if err != nil {
+		return nil, errors.Wrap(err, "database List error")
+	}
+	list := make([]*DeniedIdentity, 0, len(entries))
+	for _, e := range entries {
+		var info DeniedIdentity
+		if err := json.Unmarshal(e.Value, &info); err != nil {
+			return nil, errors.Wrap(err, "error unmarshaling json")
+		}
+		list = append(list, &info)
+	}
+	return list, nil
+}
+
+// DeleteDeniedIdentity removes an identity from the denied identities.
+func (db *DB) DeleteDeniedIdentity(name string) error {
+	if err := db.Del(deniedIdentitiesTable, []byte(name)); err != nil {
+		return errors.Wrap(err, "database Del error")
+	}
+	return nil
+}
+
+// GetKeyIdentity returns the identity that first used the key with the given
+// fingerprint. It returns nil if the key has not been used.
+func (db *DB) GetKeyIdentity(fingerprint string) (*KeyIdentity, error) {
+	b, err := db.Get(keyIdentitiesTable, []byte(fingerprint))
+	switch {
+	case nosql.IsErrNotFound(err):
+		return nil, nil //nolint:nilnil // nil means not used
+	case err != nil:
+		return nil, errors.Wrap(err, "database Get error")
+	}
+	var ki KeyIdentity
+	if err := json.Unmarshal(b, &ki); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling json")
+	}
+	return &ki, nil
+}
+
+// StoreKeyIdentity stores the identity that used a key if the key has not been
+// used before.
+func (db *DB) StoreKeyIdentity(ki *KeyIdentity) error {
+	b, err := json.Marshal(ki)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling key identity")
+	}
+	if _, _, err := db.CmpAndSwap(keyIdentitiesTable, []byte(ki.Fingerprint), nil, b); err != nil {
+		return errors.Wrap(err, "database CmpAndSwap error")
+	}
+	return nil
+}
+
 // GetCertificateData returns the data stored for a provisioner
 func (db *DB) GetCertificateData(serialNumber string) (*CertificateData, error) {
 	b, err := db.Get(certsDataTable, []byte(serialNumber))
@@ -336,6 +598,21 @@ type ProvisionerData struct {
 	Type string `json:"type"`
 }
 
+// CertificateLifecycle is the JSON representation of the data stored in the
+// x509_certs_lifecycle table. It links a certificate with the one it renews
+// and the ones that renew it.
+type CertificateLifecycle struct {
+	IssuedAt    time.Time             `json:"issuedAt"`
+	RenewedFrom string                `json:"renewedFrom,omitempty"`
+	RenewedBy   []*CertificateRenewal `json:"renewedBy,omitempty"`
+}
+
+// CertificateRenewal is a renewal or rekey of a certificate.
+type CertificateRenewal struct {
+	SerialNumber string    `json:"serialNumber"`
+	RenewedAt    time.Time `json:"renewedAt"`
+}
+
 type raProvisioner interface {
 	RAInfo() *provisioner.RAInfo
 }
@@ -360,10 +637,17 @@ func (db *DB) StoreCertificateChain(p provisioner.Interface, chain ...*x509.Cert
 	if err != nil {
 		return errors.Wrap(err, "error marshaling json")
 	}
-	// Add certificate and certificate data in one transaction.
+	lc, err := json.Marshal(&CertificateLifecycle{
+		IssuedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "error marshaling json")
+	}
+	// Add certificate, certificate data and lifecycle in one transaction.
 	tx := new(database.Tx)
 	tx.Set(certsTable, serialNumber, leaf.Raw)
 	tx.Set(certsDataTable, serialNumber, b)
+	tx.Set(certsLifecycleTable, serialNumber, lc)
 	if err := db.Update(tx); err != nil {
 		return errors.Wrap(err, "database Update error")
 	}
@@ -382,17 +666,66 @@ func (db *DB) StoreRenewedCertificate(oldCert *x509.Certificate, chain ...*x509.
 
 	leaf := chain[0]
 	serialNumber := []byte(leaf.SerialNumber.String())
+	now := time.Now().UTC()
+	lc, err := json.Marshal(&CertificateLifecycle{
+		IssuedAt:    now,
+		RenewedFrom: oldCert.SerialNumber.String(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "error marshaling json")
+	}
 
-	// Add certificate and certificate data in one transaction.
+	// Add certificate, certificate data and lifecycle in one transaction.
 	tx := new(database.Tx)
 	tx.Set(certsTable, serialNumber, leaf.Raw)
 	if certificateData != nil {
 		tx.Set(certsDataTable, serialNumber, certificateData)
 	}
+	tx.Set(certsLifecycleTable, serialNumber, lc)
 	if err := db.Update(tx); err != nil {
 		return errors.Wrap(err, "database Update error")
 	}
-	return nil
+
+	return db.addCertificateRenewal(oldCert.SerialNumber.String(), &CertificateRenewal{
+		SerialNumber: leaf.SerialNumber.String(),
+		RenewedAt:    now,
+	})
+}
+
+// maxLifecycleRetries is the number of times the update of a lifecycle is
+// retried if it is modified concurrently.
+const maxLifecycleRetries = 10
+
+// addCertificateRenewal adds a renewal to the lifecycle of the certificate
+// with the given serial number. The lifecycle is created if the certificate
+// was stored before the lifecycles were tracked.
+func (db *DB) addCertificateRenewal(serialNumber string, r *CertificateRenewal) error {
+	key := []byte(serialNumber)
+	for i := 0; i < maxLifecycleRetries; i++ {
+		var lc CertificateLifecycle
+		old, err := db.Get(certsLifecycleTable, key)
+		switch {
+		case nosql.IsErrNotFound(err):
+			old = nil
+		case err != nil:
+			return errors.Wrap(err, "database Get error")
+		default:
+			if err := json.Unmarshal(old, &lc); err != nil {
+				return errors.Wrap(err, "error unmarshaling json")
+			}
+		}
+		lc.RenewedBy = append(lc.RenewedBy, r)
+		b, err := json.Marshal(&lc)
+		if err != nil {
+			return errors.Wrap(err, "error marshaling json")
+		}
+		if _, swapped, err := db.CmpAndSwap(certsLifecycleTable, key, old, b); err != nil {
+			return errors.Wrap(err, "database CmpAndSwap error")
+		} else if swapped {
+			return nil
+		}
+	}
+	return errors.Errorf("error updating lifecycle of certificate %s: too many concurrent updates", serialNumber)
 }
 
 // UseToken returns true if we were able to successfully store the token for
@@ -489,6 +822,7 @@ type MockAuthDB struct {
 	MRevoke                 func(rci *RevokedCertificateInfo) error
 	MRevokeSSH              func(rci *RevokedCertificateInfo) error
 	MGetCertificate         func(serialNumber string) (*x509.Certificate, error)
+	MGetCertificates        func() ([]*x509.Certificate, error)
 	MGetCertificateData     func(serialNumber string) (*CertificateData, error)
 	MStoreCertificate       func(crt *x509.Certificate) error
 	MUseToken               func(id, tok string) (bool, error)
@@ -573,6 +907,14 @@ func (m *MockAuthDB) GetCertificate(serialNumber string) (*x509.Certificate, err
 	return m.Ret1.(*x509.Certificate), m.Err
 }
 
+// GetCertificates mock.
+func (m *MockAuthDB) GetCertificates() ([]*x509.Certificate, error) {
+	if m.MGetCertificates != nil {
+		return m.MGetCertificates()
+	}
+	return m.Ret1.([]*x509.Certificate), m.Err
+}
+
 // GetCertificateData mock.
 func (m *MockAuthDB) GetCertificateData(serialNumber string) (*CertificateData, error) {
 	if m.MGetCertificateData != nil {
@@ -3,15 +3,19 @@ package db
 import (
 	"bytes"
 	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"math/big"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/smallstep/assert"
 	"github.com/smallstep/certificates/authority/provisioner"
 	"github.com/smallstep/nosql"
 	"github.com/smallstep/nosql/database"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
 )
 
 func TestIsRevoked(t *testing.T) {
@@ -208,7 +212,7 @@ func TestDB_StoreCertificateChain(t *testing.T) {
 	}{
 		{"ok", fields{&MockNoSQLDB{
 			MUpdate: func(tx *database.Tx) error {
-				if len(tx.Operations) != 2 {
+				if len(tx.Operations) != 3 {
 					t.Fatal("unexpected number of operations")
 				}
 				assert.Equals(t, []byte("x509_certs"), tx.Operations[0].Bucket)
@@ -217,12 +221,18 @@ func TestDB_StoreCertificateChain(t *testing.T) {
 				assert.Equals(t, []byte("x509_certs_data"), tx.Operations[1].Bucket)
 				assert.Equals(t, []byte("1234"), tx.Operations[1].Key)
 				assert.Equals(t, []byte(`{"provisioner":{"id":"some-id","name":"admin","type":"JWK"}}`), tx.Operations[1].Value)
+				assert.Equals(t, []byte("x509_certs_lifecycle"), tx.Operations[2].Bucket)
+				assert.Equals(t, []byte("1234"), tx.Operations[2].Key)
+				var lc CertificateLifecycle
+				assert.FatalError(t, json.Unmarshal(tx.Operations[2].Value, &lc))
+				assert.False(t, lc.IssuedAt.IsZero())
+				assert.Equals(t, "", lc.RenewedFrom)
 				return nil
 			},
 		}, true}, args{p, chain}, false},
 		{"ok ra provisioner", fields{&MockNoSQLDB{
 			MUpdate: func(tx *database.Tx) error {
-				if len(tx.Operations) != 2 {
+				if len(tx.Operations) != 3 {
 					t.Fatal("unexpected number of operations")
 				}
 				assert.Equals(t, []byte("x509_certs"), tx.Operations[0].Bucket)
@@ -237,7 +247,7 @@ func TestDB_StoreCertificateChain(t *testing.T) {
 		}, true}, args{rap, chain}, false},
 		{"ok no provisioner", fields{&MockNoSQLDB{
 			MUpdate: func(tx *database.Tx) error {
-				if len(tx.Operations) != 2 {
+				if len(tx.Operations) != 3 {
 					t.Fatal("unexpected number of operations")
 				}
 				assert.Equals(t, []byte("x509_certs"), tx.Operations[0].Bucket)
@@ -328,6 +338,61 @@ func TestDB_GetCertificateData(t *testing.T) {
 	}
 }
 
+func TestDB_GetCertificates(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	signer, err := keyutil.GenerateDefaultSigner()
+	assert.FatalError(t, err)
+	crt, err := ca.Sign(&x509.Certificate{
+		SerialNumber: big.NewInt(1234),
+		PublicKey:    signer.Public(),
+		DNSNames:     []string{"leaf.example.com"},
+	})
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name    string
+		db      nosql.DB
+		want    []*x509.Certificate
+		wantErr bool
+	}{
+		{"ok", &MockNoSQLDB{
+			MList: func(bucket []byte) ([]*database.Entry, error) {
+				assert.Equals(t, bucket, []byte("x509_certs"))
+				return []*database.Entry{{Bucket: bucket, Key: []byte("1234"), Value: crt.Raw}}, nil
+			},
+		}, []*x509.Certificate{crt}, false},
+		{"ok empty", &MockNoSQLDB{
+			MList: func(bucket []byte) ([]*database.Entry, error) {
+				return []*database.Entry{}, nil
+			},
+		}, []*x509.Certificate{}, false},
+		{"fail db", &MockNoSQLDB{
+			MList: func(bucket []byte) ([]*database.Entry, error) {
+				return nil, errors.New("an error")
+			},
+		}, nil, true},
+		{"fail parse", &MockNoSQLDB{
+			MList: func(bucket []byte) ([]*database.Entry, error) {
+				return []*database.Entry{{Bucket: bucket, Key: []byte("1234"), Value: []byte("foo")}}, nil
+			},
+		}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &DB{DB: tt.db, isUp: true}
+			got, err := db.GetCertificates()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DB.GetCertificates() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DB.GetCertificates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestDB_StoreRenewedCertificate(t *testing.T) {
 	oldCert := &x509.Certificate{SerialNumber: big.NewInt(1)}
 	chain := []*x509.Certificate{
@@ -360,15 +425,18 @@ func TestDB_StoreRenewedCertificate(t *testing.T) {
 				if bytes.Equal(bucket, certsDataTable) && bytes.Equal(key, []byte("1")) {
 					return certsData, nil
 				}
+				if bytes.Equal(bucket, certsLifecycleTable) && bytes.Equal(key, []byte("1")) {
+					return []byte(`{"issuedAt":"2023-01-01T00:00:00Z"}`), nil
+				}
 				t.Error("ok failed: unexpected get")
 				return nil, testErr
 			},
 			MUpdate: func(tx *database.Tx) error {
-				if len(tx.Operations) != 2 {
+				if len(tx.Operations) != 3 {
 					t.Error("ok failed: unexpected number of operations")
 					return testErr
 				}
-				op0, op1 := tx.Operations[0], tx.Operations[1]
+				op0, op1, op2 := tx.Operations[0], tx.Operations[1], tx.Operations[2]
 				if !matchOperation(op0, certsTable, []byte("2"), []byte("raw")) {
 					t.Errorf("ok failed: unexpected entry 0, %s[%s]=%s", op0.Bucket, op0.Key, op0.Value)
 					return testErr
@@ -377,15 +445,31 @@ func TestDB_StoreRenewedCertificate(t *testing.T) {
 					t.Errorf("ok failed: unexpected entry 1, %s[%s]=%s", op1.Bucket, op1.Key, op1.Value)
 					return testErr
 				}
+				var lc CertificateLifecycle
+				if !bytes.Equal(op2.Bucket, certsLifecycleTable) || json.Unmarshal(op2.Value, &lc) != nil || lc.RenewedFrom != "1" {
+					t.Errorf("ok failed: unexpected entry 2, %s[%s]=%s", op2.Bucket, op2.Key, op2.Value)
+					return testErr
+				}
 				return nil
 			},
+			MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+				var lc CertificateLifecycle
+				if !bytes.Equal(bucket, certsLifecycleTable) || !bytes.Equal(key, []byte("1")) || json.Unmarshal(newval, &lc) != nil {
+					t.Errorf("ok failed: unexpected swap, %s[%s]=%s", bucket, key, newval)
+					return nil, false, testErr
+				}
+				if len(lc.RenewedBy) != 1 || lc.RenewedBy[0].SerialNumber != "2" || lc.IssuedAt.IsZero() {
+					t.Errorf("ok failed: unexpected lifecycle %s", newval)
+				}
+				return newval, true, nil
+			},
 		}, true}, args{oldCert, chain}, false},
 		{"ok no data", fields{&MockNoSQLDB{
 			MGet: func(bucket, key []byte) ([]byte, error) {
 				return nil, database.ErrNotFound
 			},
 			MUpdate: func(tx *database.Tx) error {
-				if len(tx.Operations) != 1 {
+				if len(tx.Operations) != 2 {
 					t.Error("ok failed: unexpected number of operations")
 					return testErr
 				}
@@ -396,13 +480,19 @@ func TestDB_StoreRenewedCertificate(t *testing.T) {
 				}
 				return nil
 			},
+			MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+				return newval, true, nil
+			},
 		}, true}, args{oldCert, chain}, false},
 		{"ok fail marshal", fields{&MockNoSQLDB{
 			MGet: func(bucket, key []byte) ([]byte, error) {
+				if bytes.Equal(bucket, certsLifecycleTable) {
+					return nil, database.ErrNotFound
+				}
 				return []byte(`{"bad":"json"`), nil
 			},
 			MUpdate: func(tx *database.Tx) error {
-				if len(tx.Operations) != 1 {
+				if len(tx.Operations) != 2 {
 					t.Error("ok failed: unexpected number of operations")
 					return testErr
 				}
@@ -413,7 +503,18 @@ func TestDB_StoreRenewedCertificate(t *testing.T) {
 				}
 				return nil
 			},
+			MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+				return newval, true, nil
+			},
 		}, true}, args{oldCert, chain}, false},
+		{"fail lifecycle", fields{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return nil, database.ErrNotFound
+			},
+			MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+				return []byte("other"), false, nil
+			},
+		}, true}, args{oldCert, chain}, true},
 		{"fail", fields{&MockNoSQLDB{
 			MGet: func(bucket, key []byte) ([]byte, error) {
 				return certsData, nil
@@ -435,3 +536,186 @@ func TestDB_StoreRenewedCertificate(t *testing.T) {
 		})
 	}
 }
+
+func TestDB_GetCertificateLifecycle(t *testing.T) {
+	d := &DB{DB: &MockNoSQLDB{
+		MGet: func(bucket, key []byte) ([]byte, error) {
+			assert.Equals(t, certsLifecycleTable, bucket)
+			switch string(key) {
+			case "1":
+				return []byte(`{"issuedAt":"2023-01-01T00:00:00Z","renewedFrom":"0","renewedBy":[{"serialNumber":"2","renewedAt":"2023-02-01T00:00:00Z"}]}`), nil
+			case "2":
+				return []byte(`{"bad":"json"`), nil
+			default:
+				return nil, database.ErrNotFound
+			}
+		},
+	}, isUp: true}
+
+	lc, err := d.GetCertificateLifecycle("1")
+	assert.FatalError(t, err)
+	assert.Equals(t, "0", lc.RenewedFrom)
+	if assert.Len(t, 1, lc.RenewedBy) {
+		assert.Equals(t, "2", lc.RenewedBy[0].SerialNumber)
+	}
+	_, err = d.GetCertificateLifecycle("2")
+	assert.Error(t, err)
+	_, err = d.GetCertificateLifecycle("3")
+	assert.True(t, nosql.IsErrNotFound(err))
+}
+
+func TestDB_GetRevokedCertificate(t *testing.T) {
+	d := &DB{DB: &MockNoSQLDB{
+		MGet: func(bucket, key []byte) ([]byte, error) {
+			assert.Equals(t, revokedCertsTable, bucket)
+			if string(key) == "1" {
+				return []byte(`{"Serial":"1","ReasonCode":1,"Reason":"key compromise"}`), nil
+			}
+			return nil, database.ErrNotFound
+		},
+	}, isUp: true}
+
+	rci, err := d.GetRevokedCertificate("1")
+	assert.FatalError(t, err)
+	assert.Equals(t, &RevokedCertificateInfo{Serial: "1", ReasonCode: 1, Reason: "key compromise"}, rci)
+	_, err = d.GetRevokedCertificate("2")
+	assert.True(t, nosql.IsErrNotFound(err))
+}
+
+func TestDB_BlockedKeys(t *testing.T) {
+	store := map[string][]byte{}
+	d := &DB{&MockNoSQLDB{
+		MSet: func(bucket, key, value []byte) error {
+			assert.Equals(t, bucket, blockedKeysTable)
+			store[string(key)] = value
+			return nil
+		},
+		MList: func(bucket []byte) ([]*database.Entry, error) {
+			assert.Equals(t, bucket, blockedKeysTable)
+			var entries []*database.Entry
+			for k, v := range store {
+				entries = append(entries, &database.Entry{Bucket: bucket, Key: []byte(k), Value: v})
+			}
+			return entries, nil
+		},
+	}, true}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	info := &BlockedKeyInfo{Fingerprint: "0123", Serial: "1234", Reason: "keyCompromise", BlockedAt: now}
+	assert.FatalError(t, d.BlockKey(info))
+	keys, err := d.GetBlockedKeys()
+	assert.FatalError(t, err)
+	assert.Equals(t, []*BlockedKeyInfo{info}, keys)
+
+	d = &DB{&MockNoSQLDB{
+		MList: func(bucket []byte) ([]*database.Entry, error) {
+			return nil, errors.New("force")
+		},
+	}, true}
+	_, err = d.GetBlockedKeys()
+	assert.Error(t, err)
+}
+
+func TestDB_KeyIdentity(t *testing.T) {
+	store := map[string][]byte{}
+	d := &DB{&MockNoSQLDB{
+		MGet: func(bucket, key []byte) ([]byte, error) {
+			assert.Equals(t, bucket, keyIdentitiesTable)
+			if b, ok := store[string(key)]; ok {
+				return b, nil
+			}
+			return nil, database.ErrNotFound
+		},
+		MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+			assert.Equals(t, bucket, keyIdentitiesTable)
+			if b, ok := store[string(key)]; ok {
+				return b, false, nil
+			}
+			store[string(key)] = newval
+			return newval, true, nil
+		},
+	}, true}
+
+	ki, err := d.GetKeyIdentity("0123")
+	assert.FatalError(t, err)
+	assert.Nil(t, ki)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	first := &KeyIdentity{Fingerprint: "0123", ProvisionerID: "prov", Identity: "acme/account/1", Serial: "1", CreatedAt: now}
+	assert.FatalError(t, d.StoreKeyIdentity(first))
+	assert.FatalError(t, d.StoreKeyIdentity(&KeyIdentity{Fingerprint: "0123", Identity: "acme/account/2", CreatedAt: now}))
+	ki, err = d.GetKeyIdentity("0123")
+	assert.FatalError(t, err)
+	assert.Equals(t, first, ki)
+
+	d = &DB{&MockNoSQLDB{
+		MGet: func(bucket, key []byte) ([]byte, error) {
+			return nil, errors.New("force")
+		},
+	}, true}
+	_, err = d.GetKeyIdentity("0123")
+	assert.Error(t, err)
+}
+
+func TestDB_DeniedIdentities(t *testing.T) {
+	store := map[string][]byte{}
+	d := &DB{&MockNoSQLDB{
+		MGet: func(bucket, key []byte) ([]byte, error) {
+			assert.Equals(t, bucket, deniedIdentitiesTable)
+			if b, ok := store[string(key)]; ok {
+				return b, nil
+			}
+			return nil, database.ErrNotFound
+		},
+		MSet: func(bucket, key, value []byte) error {
+			assert.Equals(t, bucket, deniedIdentitiesTable)
+			store[string(key)] = value
+			return nil
+		},
+		MDel: func(bucket, key []byte) error {
+			assert.Equals(t, bucket, deniedIdentitiesTable)
+			delete(store, string(key))
+			return nil
+		},
+		MList: func(bucket []byte) ([]*database.Entry, error) {
+			assert.Equals(t, bucket, deniedIdentitiesTable)
+			var entries []*database.Entry
+			for k, v := range store {
+				entries = append(entries, &database.Entry{Bucket: bucket, Key: []byte(k), Value: v})
+			}
+			return entries, nil
+		},
+	}, true}
+
+	info, err := d.GetDeniedIdentity("test.example.com")
+	assert.FatalError(t, err)
+	assert.Nil(t, info)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	denied := &DeniedIdentity{Name: "test.example.com", Serial: "1234", Reason: "keyCompromise", DeniedAt: now}
+	assert.FatalError(t, d.DenyIdentity(denied))
+	info, err = d.GetDeniedIdentity("test.example.com")
+	assert.FatalError(t, err)
+	assert.Equals(t, denied, info)
+	list, err := d.GetDeniedIdentities()
+	assert.FatalError(t, err)
+	assert.Equals(t, []*DeniedIdentity{denied}, list)
+
+	assert.FatalError(t, d.DeleteDeniedIdentity("test.example.com"))
+	info, err = d.GetDeniedIdentity("test.example.com")
+	assert.FatalError(t, err)
+	assert.Nil(t, info)
+
+	d = &DB{&MockNoSQLDB{
+		MGet: func(bucket, key []byte) ([]byte, error) {
+			return nil, errors.New("force")
+		},
+		MList: func(bucket []byte) ([]*database.Entry, error) {
+			return nil, errors.New("force")
+		},
+	}, true}
+	_, err = d.GetDeniedIdentity("test.example.com")
+	assert.Error(t, err)
+	_, err = d.GetDeniedIdentities()
+	assert.Error(t, err)
+}
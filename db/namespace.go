@@ -0,0 +1,147 @@
+package db
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/nosql"
+	"github.com/smallstep/nosql/database"
+)
+
+// namespaceSeparator separates the namespace from the name of the table. The
+// namespaces and the tables of the authority never contain it, so a table of
+// a namespace never matches a table of another namespace or a table without
+// a namespace.
+const namespaceSeparator = "__"
+
+// maxNamespaceLength keeps the namespaced tables under the 64 characters
+// limit of the MySQL table names.
+const maxNamespaceLength = 24
+
+var namespaceRegexp = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*$`)
+
+// ValidateNamespace returns an error if the given namespace cannot be used.
+// A namespace is up to 24 lowercase letters, digits and single underscores,
+// it cannot start or end with an underscore.
+func ValidateNamespace(namespace string) error {
+	switch {
+	case namespace == "":
+		return errors.New("namespace cannot be empty")
+	case len(namespace) > maxNamespaceLength:
+		return errors.Errorf("namespace %q is too long, the maximum length is %d", namespace, maxNamespaceLength)
+	case !namespaceRegexp.MatchString(namespace):
+		return errors.Errorf("namespace %q is not valid, it must contain only lowercase letters, digits, and single underscores", namespace)
+	default:
+		return nil
+	}
+}
+
+// NamespacedDB is a nosql.DB that stores the data in tables prefixed with a
+// namespace. It allows multiple authorities, or multiple tenants of an
+// authority, to share a database without sharing any key.
+type NamespacedDB struct {
+	db        nosql.DB
+	namespace string
+	prefix    []byte
+}
+
+// NewNamespacedDB returns a database that isolates the tables of the given
+// namespace.
+func NewNamespacedDB(db nosql.DB, namespace string) (*NamespacedDB, error) {
+	if err := ValidateNamespace(namespace); err != nil {
+		return nil, err
+	}
+	return &NamespacedDB{
+		db:        db,
+		namespace: namespace,
+		prefix:    []byte(namespace + namespaceSeparator),
+	}, nil
+}
+
+// Namespace returns the namespace of the database.
+func (db *NamespacedDB) Namespace() string {
+	return db.namespace
+}
+
+// Unwrap returns the underlying database.
+func (db *NamespacedDB) Unwrap() nosql.DB {
+	return db.db
+}
+
+func (db *NamespacedDB) table(bucket []byte) []byte {
+	b := make([]byte, 0, len(db.prefix)+len(bucket))
+	return append(append(b, db.prefix...), bucket...)
+}
+
+// Open implements the nosql.DB interface.
+func (db *NamespacedDB) Open(dataSourceName string, opt ...database.Option) error {
+	return db.db.Open(dataSourceName, opt...)
+}
+
+// Close implements the nosql.DB interface.
+func (db *NamespacedDB) Close() error {
+	return db.db.Close()
+}
+
+// Get implements the nosql.DB interface.
+func (db *NamespacedDB) Get(bucket, key []byte) ([]byte, error) {
+	return db.db.Get(db.table(bucket), key)
+}
+
+// Set implements the nosql.DB interface.
+func (db *NamespacedDB) Set(bucket, key, value []byte) error {
+	return db.db.Set(db.table(bucket), key, value)
+}
+
+// CmpAndSwap implements the nosql.DB interface.
+func (db *NamespacedDB) CmpAndSwap(bucket, key, oldValue, newValue []byte) ([]byte, bool, error) {
+	return db.db.CmpAndSwap(db.table(bucket), key, oldValue, newValue)
+}
+
+// Del implements the nosql.DB interface.
+func (db *NamespacedDB) Del(bucket, key []byte) error {
+	return db.db.Del(db.table(bucket), key)
+}
+
+// List implements the nosql.DB interface. The entries returned have the
+// bucket without the namespace.
+func (db *NamespacedDB) List(bucket []byte) ([]*database.Entry, error) {
+	entries, err := db.db.List(db.table(bucket))
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		e.Bucket = bytes.TrimPrefix(e.Bucket, db.prefix)
+	}
+	return entries, nil
+}
+
+// Update implements the nosql.DB interface. The results of the operations are
+// copied to the entries of the given transaction.
+func (db *NamespacedDB) Update(tx *database.Tx) error {
+	ntx := &database.Tx{
+		Operations: make([]*database.TxEntry, len(tx.Operations)),
+	}
+	for i, op := range tx.Operations {
+		nop := *op
+		nop.Bucket = db.table(op.Bucket)
+		ntx.Operations[i] = &nop
+	}
+	err := db.db.Update(ntx)
+	for i, op := range tx.Operations {
+		op.Result = ntx.Operations[i].Result
+		op.Swapped = ntx.Operations[i].Swapped
+	}
+	return err
+}
+
+// CreateTable implements the nosql.DB interface.
+func (db *NamespacedDB) CreateTable(bucket []byte) error {
+	return db.db.CreateTable(db.table(bucket))
+}
+
+// DeleteTable implements the nosql.DB interface.
+func (db *NamespacedDB) DeleteTable(bucket []byte) error {
+	return db.db.DeleteTable(db.table(bucket))
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/nosql"
+	"github.com/smallstep/nosql/database"
+)
+
+func TestValidateNamespace(t *testing.T) {
+	tests := map[string]bool{
+		"tenant":                    true,
+		"tenant_1":                  true,
+		"a1_b2_c3":                  true,
+		"abcdefghijklmnopqrstuvwx":  true,
+		"":                          false,
+		"abcdefghijklmnopqrstuvwxy": false,
+		"Tenant":                    false,
+		"tenant__1":                 false,
+		"_tenant":                   false,
+		"tenant_":                   false,
+		"tenant-1":                  false,
+		"tenant.1":                  false,
+	}
+	for namespace, ok := range tests {
+		t.Run(namespace, func(t *testing.T) {
+			err := ValidateNamespace(namespace)
+			assert.Equals(t, ok, err == nil)
+		})
+	}
+}
+
+func TestNamespacedDB(t *testing.T) {
+	ndb, err := nosql.New(nosql.BBoltDriver, filepath.Join(t.TempDir(), "shared.db"))
+	assert.FatalError(t, err)
+	t.Cleanup(func() { ndb.Close() })
+
+	_, err = NewNamespacedDB(ndb, "Tenant")
+	assert.Error(t, err)
+
+	a, err := NewNamespacedDB(ndb, "tenant_a")
+	assert.FatalError(t, err)
+	assert.Equals(t, "tenant_a", a.Namespace())
+	assert.Equals(t, ndb, a.Unwrap())
+	b, err := NewNamespacedDB(ndb, "tenant_b")
+	assert.FatalError(t, err)
+
+	table := []byte("x509_certs")
+	for _, d := range []nosql.DB{ndb, a, b} {
+		assert.FatalError(t, d.CreateTable(table))
+	}
+	assert.FatalError(t, ndb.Set(table, []byte("sn"), []byte("none")))
+	assert.FatalError(t, a.Set(table, []byte("sn"), []byte("a")))
+	assert.FatalError(t, b.Set(table, []byte("sn"), []byte("b")))
+
+	// Each namespace has its own keys.
+	for d, want := range map[nosql.DB]string{ndb: "none", a: "a", b: "b"} {
+		v, err := d.Get(table, []byte("sn"))
+		assert.FatalError(t, err)
+		assert.Equals(t, want, string(v))
+	}
+
+	entries, err := a.List(table)
+	assert.FatalError(t, err)
+	assert.Len(t, 1, entries)
+	assert.Equals(t, table, entries[0].Bucket)
+	assert.Equals(t, []byte("a"), entries[0].Value)
+
+	// Transactions use the tables of the namespace.
+	tx := new(database.Tx)
+	tx.Set(table, []byte("sn"), []byte("a2"))
+	tx.Get(table, []byte("sn"))
+	assert.FatalError(t, a.Update(tx))
+	assert.Equals(t, table, tx.Operations[0].Bucket)
+	assert.Equals(t, []byte("a2"), tx.Operations[1].Result)
+
+	_, swapped, err := b.CmpAndSwap(table, []byte("sn"), []byte("a2"), []byte("b2"))
+	assert.FatalError(t, err)
+	assert.False(t, swapped)
+
+	assert.FatalError(t, a.Del(table, []byte("sn")))
+	_, err = a.Get(table, []byte("sn"))
+	assert.True(t, database.IsErrNotFound(err))
+	v, err := b.Get(table, []byte("sn"))
+	assert.FatalError(t, err)
+	assert.Equals(t, []byte("b"), v)
+
+	assert.FatalError(t, b.DeleteTable(table))
+	_, err = b.Get(table, []byte("sn"))
+	assert.Error(t, err)
+	v, err = ndb.Get(table, []byte("sn"))
+	assert.FatalError(t, err)
+	assert.Equals(t, []byte("none"), v)
+}
+
+func TestNew_namespace(t *testing.T) {
+	_, err := New(&Config{Type: SQLPostgreSQL, DataSource: "postgresql://localhost/step", Namespace: "tenant"})
+	assert.Error(t, err)
+
+	_, err = New(&Config{Type: nosql.BBoltDriver, DataSource: filepath.Join(t.TempDir(), "step.db"), Namespace: "Tenant"})
+	assert.Error(t, err)
+
+	d, err := New(&Config{Type: nosql.BBoltDriver, DataSource: filepath.Join(t.TempDir(), "step.db"), Namespace: "tenant"})
+	assert.FatalError(t, err)
+	t.Cleanup(func() { d.Shutdown() })
+	nsdb, ok := d.(*DB).DB.(*NamespacedDB)
+	assert.True(t, ok)
+	assert.Equals(t, "tenant", nsdb.Namespace())
+}
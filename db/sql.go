@@ -0,0 +1,710 @@
+package db
+
+import (
+	"context"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/nosql/database"
+	"golang.org/x/crypto/ssh"
+)
+
+// sqlSchema is the schema of the relational database. The certificates table
+// also contains the provisioner and the lifecycle data, that are stored in
+// separate buckets in the nosql databases.
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS x509_certs (
+		serial VARCHAR(255) NOT NULL PRIMARY KEY,
+		der {{blob}} NOT NULL,
+		not_before {{time}} NULL,
+		not_after {{time}} NULL,
+		provisioner_id VARCHAR(255) NOT NULL,
+		provisioner_name VARCHAR(255) NOT NULL,
+		provisioner_type VARCHAR(64) NOT NULL,
+		ra_info TEXT NULL,
+		issued_at {{time}} NULL,
+		renewed_from VARCHAR(255) NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS revoked_x509_certs (
+		serial VARCHAR(255) NOT NULL PRIMARY KEY,
+		provisioner_id VARCHAR(255) NOT NULL,
+		reason_code INTEGER NOT NULL,
+		reason TEXT NOT NULL,
+		revoked_at {{time}} NULL,
+		expires_at {{time}} NULL,
+		token_id VARCHAR(255) NOT NULL,
+		mtls BOOLEAN NOT NULL,
+		acme BOOLEAN NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS revoked_ssh_certs (
+		serial VARCHAR(255) NOT NULL PRIMARY KEY,
+		provisioner_id VARCHAR(255) NOT NULL,
+		reason_code INTEGER NOT NULL,
+		reason TEXT NOT NULL,
+		revoked_at {{time}} NULL,
+		expires_at {{time}} NULL,
+		token_id VARCHAR(255) NOT NULL,
+		mtls BOOLEAN NOT NULL,
+		acme BOOLEAN NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS x509_crl (
+		id VARCHAR(32) NOT NULL PRIMARY KEY,
+		number BIGINT NOT NULL,
+		expires_at {{time}} NULL,
+		duration BIGINT NOT NULL,
+		der {{blob}} NOT NULL,
+		signer_der {{blob}} NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS used_ott (
+		id VARCHAR(255) NOT NULL PRIMARY KEY,
+		token TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS ssh_certs (
+		serial VARCHAR(32) NOT NULL PRIMARY KEY,
+		data {{blob}} NOT NULL,
+		cert_type INTEGER NOT NULL,
+		valid_before {{time}} NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS ssh_host_principals (
+		principal VARCHAR(255) NOT NULL PRIMARY KEY,
+		serial VARCHAR(32) NOT NULL,
+		expires_at {{time}} NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS ssh_users (
+		principal VARCHAR(255) NOT NULL PRIMARY KEY,
+		serial VARCHAR(32) NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS blocked_keys (
+		fingerprint VARCHAR(255) NOT NULL PRIMARY KEY,
+		serial VARCHAR(255) NOT NULL,
+		reason TEXT NOT NULL,
+		blocked_at {{time}} NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS denied_identities (
+		name VARCHAR(255) NOT NULL PRIMARY KEY,
+		serial VARCHAR(255) NOT NULL,
+		reason TEXT NOT NULL,
+		denied_at {{time}} NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS key_identities (
+		fingerprint VARCHAR(255) NOT NULL PRIMARY KEY,
+		provisioner_id VARCHAR(255) NOT NULL,
+		identity TEXT NOT NULL,
+		serial VARCHAR(255) NOT NULL,
+		created_at {{time}} NULL
+	)`,
+	// kv_store keeps the data of the features that use the database as a
+	// key-value store, like the audit log, the leader election or the
+	// settings. A bucket of the nosql databases is a set of rows with the same
+	// bucket name.
+	`CREATE TABLE IF NOT EXISTS kv_store (
+		bucket VARCHAR(255) NOT NULL,
+		nkey {{key}} NOT NULL,
+		nvalue {{blob}} NOT NULL,
+		PRIMARY KEY (bucket, nkey)
+	)`,
+}
+
+// SQLDB is an implementation of the AuthDB interface that uses a relational
+// database with a table per type of data. It also implements the nosql.DB
+// interface on the kv_store table, for the features that use the database as
+// a key-value store.
+type SQLDB struct {
+	db      *sql.DB
+	dialect *SQLDialect
+}
+
+func newSQLDB(c *Config) (*SQLDB, error) {
+	dialect := sqlDialects[strings.ToLower(c.Type)]
+	dsn, err := dialect.dataSource(c.DataSource)
+	if err != nil {
+		return nil, err
+	}
+	sdb, err := sql.Open(dialect.DriverName, dsn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening database of type %s", c.Type)
+	}
+
+	ctx := context.Background()
+	if err := dialect.Exec(ctx, sdb, sqlSchema...); err != nil {
+		sdb.Close()
+		return nil, err
+	}
+	indexes := []struct {
+		name, table string
+		columns     []string
+	}{
+		{"x509_certs_not_after_idx", "x509_certs", []string{"not_after"}},
+		{"x509_certs_provisioner_idx", "x509_certs", []string{"provisioner_id"}},
+		{"x509_certs_renewed_from_idx", "x509_certs", []string{"renewed_from"}},
+		{"revoked_x509_certs_expires_at_idx", "revoked_x509_certs", []string{"expires_at"}},
+		{"ssh_host_principals_expires_at_idx", "ssh_host_principals", []string{"expires_at"}},
+	}
+	for _, idx := range indexes {
+		if err := dialect.CreateIndex(ctx, sdb, idx.name, idx.table, false, idx.columns...); err != nil {
+			sdb.Close()
+			return nil, err
+		}
+	}
+
+	return &SQLDB{db: sdb, dialect: dialect}, nil
+}
+
+// DB returns the underlying database. It is used to store the ACME data in
+// the same database.
+func (db *SQLDB) DB() *sql.DB {
+	return db.db
+}
+
+// Dialect returns the dialect of the underlying database.
+func (db *SQLDB) Dialect() *SQLDialect {
+	return db.dialect
+}
+
+func (db *SQLDB) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.db.ExecContext(ctx, db.dialect.Rebind(query), args...)
+}
+
+func (db *SQLDB) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.db.QueryRowContext(ctx, db.dialect.Rebind(query), args...)
+}
+
+func (db *SQLDB) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.db.QueryContext(ctx, db.dialect.Rebind(query), args...)
+}
+
+// withTx runs the given function in a transaction. The transaction is
+// committed if the function does not fail.
+func (db *SQLDB) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "error starting transaction")
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback() //nolint:errcheck // the transaction already failed
+		return err
+	}
+	return errors.Wrap(tx.Commit(), "error committing transaction")
+}
+
+// replace deletes the row with the given key and inserts a new one. It is
+// used instead of the upserts that are different in every database.
+func (db *SQLDB) replace(ctx context.Context, tx *sql.Tx, table, keyColumn string, columns []string, args ...interface{}) error {
+	if _, err := tx.ExecContext(ctx, db.dialect.Rebind("DELETE FROM "+table+" WHERE "+keyColumn+" = ?"), args[0]); err != nil {
+		return err
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	_, err := tx.ExecContext(ctx, db.dialect.Rebind("INSERT INTO "+table+" ("+strings.Join(columns, ", ")+") VALUES ("+placeholders+")"), args...)
+	return err
+}
+
+// notFound converts sql.ErrNoRows to the nosql not found error that the
+// callers of the AuthDB expect.
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.ErrNotFound
+	}
+	return err
+}
+
+func (db *SQLDB) exists(query string, args ...interface{}) (bool, error) {
+	var n int
+	switch err := db.queryRow(context.Background(), query, args...).Scan(&n); {
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	case err != nil:
+		return false, err
+	default:
+		return true, nil
+	}
+}
+
+// IsRevoked returns whether or not a certificate with the given serial number
+// has been revoked.
+func (db *SQLDB) IsRevoked(sn string) (bool, error) {
+	ok, err := db.exists("SELECT 1 FROM revoked_x509_certs WHERE serial = ?", sn)
+	if err != nil {
+		return false, errors.Wrap(err, "error checking revocation table")
+	}
+	return ok, nil
+}
+
+// IsSSHRevoked returns whether or not an SSH certificate with the given serial
+// number has been revoked.
+func (db *SQLDB) IsSSHRevoked(sn string) (bool, error) {
+	ok, err := db.exists("SELECT 1 FROM revoked_ssh_certs WHERE serial = ?", sn)
+	if err != nil {
+		return false, errors.Wrap(err, "error checking revocation table")
+	}
+	return ok, nil
+}
+
+// Revoke adds a certificate to the revocation table.
+func (db *SQLDB) Revoke(rci *RevokedCertificateInfo) error {
+	return db.revoke("revoked_x509_certs", rci)
+}
+
+// RevokeSSH adds an SSH certificate to the revocation table.
+func (db *SQLDB) RevokeSSH(rci *RevokedCertificateInfo) error {
+	return db.revoke("revoked_ssh_certs", rci)
+}
+
+func (db *SQLDB) revoke(table string, rci *RevokedCertificateInfo) error {
+	_, err := db.exec(context.Background(), "INSERT INTO "+table+" (serial, provisioner_id, reason_code, reason, revoked_at, expires_at, token_id, mtls, acme) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		rci.Serial, rci.ProvisionerID, rci.ReasonCode, rci.Reason, NullTime(rci.RevokedAt), NullTime(rci.ExpiresAt), rci.TokenID, rci.MTLS, rci.ACME)
+	switch {
+	case db.dialect.IsUniqueViolation(err):
+		return ErrAlreadyExists
+	case err != nil:
+		return errors.Wrap(err, "error inserting revoked certificate")
+	default:
+		return nil
+	}
+}
+
+const revokedColumns = "serial, provisioner_id, reason_code, reason, revoked_at, expires_at, token_id, mtls, acme"
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRevokedCertificate(row scanner) (*RevokedCertificateInfo, error) {
+	var (
+		rci                  RevokedCertificateInfo
+		revokedAt, expiresAt sql.NullTime
+	)
+	if err := row.Scan(&rci.Serial, &rci.ProvisionerID, &rci.ReasonCode, &rci.Reason, &revokedAt, &expiresAt, &rci.TokenID, &rci.MTLS, &rci.ACME); err != nil {
+		return nil, err
+	}
+	rci.RevokedAt, rci.ExpiresAt = TimeValue(revokedAt), TimeValue(expiresAt)
+	return &rci, nil
+}
+
+// GetRevokedCertificates gets a list of all revoked certificates.
+func (db *SQLDB) GetRevokedCertificates() (*[]RevokedCertificateInfo, error) {
+	rows, err := db.query(context.Background(), "SELECT "+revokedColumns+" FROM revoked_x509_certs ORDER BY revoked_at")
+	if err != nil {
+		return nil, errors.Wrap(err, "database query error")
+	}
+	defer rows.Close()
+	var revokedCerts []RevokedCertificateInfo
+	for rows.Next() {
+		rci, err := scanRevokedCertificate(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "database scan error")
+		}
+		revokedCerts = append(revokedCerts, *rci)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "database query error")
+	}
+	return &revokedCerts, nil
+}
+
+// GetRevokedCertificate returns the revocation details of the certificate with
+// the given serial number.
+func (db *SQLDB) GetRevokedCertificate(serialNumber string) (*RevokedCertificateInfo, error) {
+	rci, err := scanRevokedCertificate(db.queryRow(context.Background(), "SELECT "+revokedColumns+" FROM revoked_x509_certs WHERE serial = ?", serialNumber))
+	if err != nil {
+		return nil, errors.Wrap(notFound(err), "database Get error")
+	}
+	return rci, nil
+}
+
+// StoreCRL stores a CRL in the DB.
+func (db *SQLDB) StoreCRL(crlInfo *CertificateRevocationListInfo) error {
+	ctx := context.Background()
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		return db.replace(ctx, tx, "x509_crl", "id", []string{"id", "number", "expires_at", "duration", "der", "signer_der"},
+			string(crlKey), crlInfo.Number, NullTime(crlInfo.ExpiresAt), int64(crlInfo.Duration), crlInfo.DER, crlInfo.SignerDER)
+	})
+	return errors.Wrap(err, "database Set error")
+}
+
+// GetCRL gets the existing CRL from the database.
+func (db *SQLDB) GetCRL() (*CertificateRevocationListInfo, error) {
+	var (
+		crlInfo   CertificateRevocationListInfo
+		expiresAt sql.NullTime
+		duration  int64
+	)
+	if err := db.queryRow(context.Background(), "SELECT number, expires_at, duration, der, signer_der FROM x509_crl WHERE id = ?", string(crlKey)).
+		Scan(&crlInfo.Number, &expiresAt, &duration, &crlInfo.DER, &crlInfo.SignerDER); err != nil {
+		return nil, errors.Wrap(notFound(err), "database Get error")
+	}
+	crlInfo.ExpiresAt, crlInfo.Duration = TimeValue(expiresAt), time.Duration(duration)
+	return &crlInfo, nil
+}
+
+// GetCertificate retrieves a certificate by the serial number.
+func (db *SQLDB) GetCertificate(serialNumber string) (*x509.Certificate, error) {
+	var der []byte
+	if err := db.queryRow(context.Background(), "SELECT der FROM x509_certs WHERE serial = ?", serialNumber).Scan(&der); err != nil {
+		return nil, errors.Wrap(notFound(err), "database Get error")
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing certificate with serial number %s", serialNumber)
+	}
+	return cert, nil
+}
+
+// GetCertificates returns all the stored X.509 certificates.
+func (db *SQLDB) GetCertificates() ([]*x509.Certificate, error) {
+	rows, err := db.query(context.Background(), "SELECT serial, der FROM x509_certs ORDER BY issued_at")
+	if err != nil {
+		return nil, errors.Wrap(err, "database query error")
+	}
+	defer rows.Close()
+	var certs []*x509.Certificate
+	for rows.Next() {
+		var (
+			serial string
+			der    []byte
+		)
+		if err := rows.Scan(&serial, &der); err != nil {
+			return nil, errors.Wrap(err, "database scan error")
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing certificate with serial number %s", serial)
+		}
+		certs = append(certs, cert)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "database query error")
+	}
+	return certs, nil
+}
+
+// GetCertificateData returns the data stored for a provisioner.
+func (db *SQLDB) GetCertificateData(serialNumber string) (*CertificateData, error) {
+	var (
+		p      ProvisionerData
+		raInfo sql.NullString
+	)
+	if err := db.queryRow(context.Background(), "SELECT provisioner_id, provisioner_name, provisioner_type, ra_info FROM x509_certs WHERE serial = ?", serialNumber).
+		Scan(&p.ID, &p.Name, &p.Type, &raInfo); err != nil {
+		return nil, errors.Wrap(notFound(err), "database Get error")
+	}
+	data := new(CertificateData)
+	if p.ID != "" || p.Name != "" {
+		data.Provisioner = &p
+	}
+	if raInfo.Valid && raInfo.String != "" {
+		data.RaInfo = new(provisioner.RAInfo)
+		if err := json.Unmarshal([]byte(raInfo.String), data.RaInfo); err != nil {
+			return nil, errors.Wrap(err, "error unmarshaling json")
+		}
+	}
+	return data, nil
+}
+
+// GetCertificateLifecycle returns the lifecycle of the certificate with the
+// given serial number. The renewals are the certificates renewed from it.
+func (db *SQLDB) GetCertificateLifecycle(serialNumber string) (*CertificateLifecycle, error) {
+	ctx := context.Background()
+	var (
+		lc       CertificateLifecycle
+		issuedAt sql.NullTime
+	)
+	if err := db.queryRow(ctx, "SELECT issued_at, renewed_from FROM x509_certs WHERE serial = ?", serialNumber).Scan(&issuedAt, &lc.RenewedFrom); err != nil {
+		return nil, errors.Wrap(notFound(err), "database Get error")
+	}
+	lc.IssuedAt = TimeValue(issuedAt)
+
+	rows, err := db.query(ctx, "SELECT serial, issued_at FROM x509_certs WHERE renewed_from = ? ORDER BY issued_at", serialNumber)
+	if err != nil {
+		return nil, errors.Wrap(err, "database query error")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r CertificateRenewal
+		if err := rows.Scan(&r.SerialNumber, &issuedAt); err != nil {
+			return nil, errors.Wrap(err, "database scan error")
+		}
+		r.RenewedAt = TimeValue(issuedAt)
+		lc.RenewedBy = append(lc.RenewedBy, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "database query error")
+	}
+	return &lc, nil
+}
+
+// StoreCertificate stores a certificate.
+func (db *SQLDB) StoreCertificate(crt *x509.Certificate) error {
+	return db.storeCertificate(crt, &CertificateData{}, "")
+}
+
+// StoreCertificateChain stores the leaf certificate and the provisioner that
+// authorized the certificate.
+func (db *SQLDB) StoreCertificateChain(p provisioner.Interface, chain ...*x509.Certificate) error {
+	data := &CertificateData{}
+	if p != nil {
+		data.Provisioner = &ProvisionerData{
+			ID:   p.GetID(),
+			Name: p.GetName(),
+			Type: p.GetType().String(),
+		}
+		if rap, ok := p.(raProvisioner); ok {
+			data.RaInfo = rap.RAInfo()
+		}
+	}
+	return db.storeCertificate(chain[0], data, "")
+}
+
+// StoreRenewedCertificate stores the leaf certificate and the provisioner that
+// authorized the old certificate if available.
+func (db *SQLDB) StoreRenewedCertificate(oldCert *x509.Certificate, chain ...*x509.Certificate) error {
+	data, err := db.GetCertificateData(oldCert.SerialNumber.String())
+	if err != nil {
+		data = &CertificateData{}
+	}
+	return db.storeCertificate(chain[0], data, oldCert.SerialNumber.String())
+}
+
+func (db *SQLDB) storeCertificate(crt *x509.Certificate, data *CertificateData, renewedFrom string) error {
+	var p ProvisionerData
+	if data.Provisioner != nil {
+		p = *data.Provisioner
+	}
+	var raInfo sql.NullString
+	if data.RaInfo != nil {
+		b, err := json.Marshal(data.RaInfo)
+		if err != nil {
+			return errors.Wrap(err, "error marshaling json")
+		}
+		raInfo = sql.NullString{String: string(b), Valid: true}
+	}
+
+	ctx := context.Background()
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		return db.replace(ctx, tx, "x509_certs", "serial",
+			[]string{"serial", "der", "not_before", "not_after", "provisioner_id", "provisioner_name", "provisioner_type", "ra_info", "issued_at", "renewed_from"},
+			crt.SerialNumber.String(), crt.Raw, NullTime(crt.NotBefore), NullTime(crt.NotAfter),
+			p.ID, p.Name, p.Type, raInfo, NullTime(time.Now()), renewedFrom)
+	})
+	return errors.Wrap(err, "database Set error")
+}
+
+// UseToken returns true if we were able to successfully store the token for
+// for the first time, false otherwise.
+func (db *SQLDB) UseToken(id, tok string) (bool, error) {
+	_, err := db.exec(context.Background(), "INSERT INTO used_ott (id, token) VALUES (?, ?)", id, tok)
+	switch {
+	case db.dialect.IsUniqueViolation(err):
+		return false, nil
+	case err != nil:
+		return false, errors.Wrapf(err, "error storing used token used_ott/%s", id)
+	default:
+		return true, nil
+	}
+}
+
+// BlockKey stores the fingerprint of a public key that cannot be used anymore.
+func (db *SQLDB) BlockKey(info *BlockedKeyInfo) error {
+	ctx := context.Background()
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		return db.replace(ctx, tx, "blocked_keys", "fingerprint", []string{"fingerprint", "serial", "reason", "blocked_at"},
+			info.Fingerprint, info.Serial, info.Reason, NullTime(info.BlockedAt))
+	})
+	return errors.Wrap(err, "database Set error")
+}
+
+// GetBlockedKeys returns all the blocked public keys.
+func (db *SQLDB) GetBlockedKeys() ([]*BlockedKeyInfo, error) {
+	rows, err := db.query(context.Background(), "SELECT fingerprint, serial, reason, blocked_at FROM blocked_keys")
+	if err != nil {
+		return nil, errors.Wrap(err, "database query error")
+	}
+	defer rows.Close()
+	var keys []*BlockedKeyInfo
+	for rows.Next() {
+		var (
+			info      BlockedKeyInfo
+			blockedAt sql.NullTime
+		)
+		if err := rows.Scan(&info.Fingerprint, &info.Serial, &info.Reason, &blockedAt); err != nil {
+			return nil, errors.Wrap(err, "database scan error")
+		}
+		info.BlockedAt = TimeValue(blockedAt)
+		keys = append(keys, &info)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "database query error")
+	}
+	return keys, nil
+}
+
+// DenyIdentity stores an identity that cannot be used anymore.
+func (db *SQLDB) DenyIdentity(info *DeniedIdentity) error {
+	ctx := context.Background()
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		return db.replace(ctx, tx, "denied_identities", "name", []string{"name", "serial", "reason", "denied_at"},
+			info.Name, info.Serial, info.Reason, NullTime(info.DeniedAt))
+	})
+	return errors.Wrap(err, "database Set error")
+}
+
+const deniedIdentityColumns = "name, serial, reason, denied_at"
+
+func scanDeniedIdentity(row scanner) (*DeniedIdentity, error) {
+	var (
+		info     DeniedIdentity
+		deniedAt sql.NullTime
+	)
+	if err := row.Scan(&info.Name, &info.Serial, &info.Reason, &deniedAt); err != nil {
+		return nil, err
+	}
+	info.DeniedAt = TimeValue(deniedAt)
+	return &info, nil
+}
+
+// GetDeniedIdentity returns the denied identity with the given name. It
+// returns nil if the identity is not denied.
+func (db *SQLDB) GetDeniedIdentity(name string) (*DeniedIdentity, error) {
+	info, err := scanDeniedIdentity(db.queryRow(context.Background(), "SELECT "+deniedIdentityColumns+" FROM denied_identities WHERE name = ?", name))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil //nolint:nilnil // nil means not denied
+	case err != nil:
+		return nil, errors.Wrap(err, "database Get error")
+	default:
+		return info, nil
+	}
+}
+
+// GetDeniedIdentities returns all the denied identities.
+func (db *SQLDB) GetDeniedIdentities() ([]*DeniedIdentity, error) {
+	rows, err := db.query(context.Background(), "SELECT "+deniedIdentityColumns+" FROM denied_identities")
+	if err != nil {
+		return nil, errors.Wrap(err, "database query error")
+	}
+	defer rows.Close()
+	var list []*DeniedIdentity
+	for rows.Next() {
+		info, err := scanDeniedIdentity(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "database scan error")
+		}
+		list = append(list, info)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "database query error")
+	}
+	return list, nil
+}
+
+// DeleteDeniedIdentity removes an identity from the denied identities.
+func (db *SQLDB) DeleteDeniedIdentity(name string) error {
+	if _, err := db.exec(context.Background(), "DELETE FROM denied_identities WHERE name = ?", name); err != nil {
+		return errors.Wrap(err, "database Del error")
+	}
+	return nil
+}
+
+// GetKeyIdentity returns the identity that first used the key with the given
+// fingerprint. It returns nil if the key has not been used.
+func (db *SQLDB) GetKeyIdentity(fingerprint string) (*KeyIdentity, error) {
+	var (
+		ki        KeyIdentity
+		createdAt sql.NullTime
+	)
+	err := db.queryRow(context.Background(), "SELECT fingerprint, provisioner_id, identity, serial, created_at FROM key_identities WHERE fingerprint = ?", fingerprint).
+		Scan(&ki.Fingerprint, &ki.ProvisionerID, &ki.Identity, &ki.Serial, &createdAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, nil //nolint:nilnil // nil means not used
+	case err != nil:
+		return nil, errors.Wrap(err, "database Get error")
+	}
+	ki.CreatedAt = TimeValue(createdAt)
+	return &ki, nil
+}
+
+// StoreKeyIdentity stores the identity that used a key if the key has not been
+// used before.
+func (db *SQLDB) StoreKeyIdentity(ki *KeyIdentity) error {
+	_, err := db.exec(context.Background(), "INSERT INTO key_identities (fingerprint, provisioner_id, identity, serial, created_at) VALUES (?, ?, ?, ?, ?)",
+		ki.Fingerprint, ki.ProvisionerID, ki.Identity, ki.Serial, NullTime(ki.CreatedAt))
+	if err != nil && !db.dialect.IsUniqueViolation(err) {
+		return errors.Wrap(err, "database insert error")
+	}
+	return nil
+}
+
+// IsSSHHost returns if a principal is present in the ssh hosts table.
+func (db *SQLDB) IsSSHHost(principal string) (bool, error) {
+	ok, err := db.exists("SELECT 1 FROM ssh_host_principals WHERE principal = ?", strings.ToLower(principal))
+	if err != nil {
+		return false, errors.Wrap(err, "database Get error")
+	}
+	return ok, nil
+}
+
+// StoreSSHCertificate stores an SSH certificate.
+func (db *SQLDB) StoreSSHCertificate(crt *ssh.Certificate) error {
+	ctx := context.Background()
+	serial := strconv.FormatUint(crt.Serial, 10)
+	validBefore := time.Time{}
+	if crt.ValidBefore != ssh.CertTimeInfinity {
+		validBefore = time.Unix(int64(crt.ValidBefore), 0)
+	}
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		if err := db.replace(ctx, tx, "ssh_certs", "serial", []string{"serial", "data", "cert_type", "valid_before"},
+			serial, crt.Marshal(), int(crt.CertType), NullTime(validBefore)); err != nil {
+			return err
+		}
+		for _, p := range crt.ValidPrincipals {
+			var err error
+			if crt.CertType == ssh.HostCert {
+				err = db.replace(ctx, tx, "ssh_host_principals", "principal", []string{"principal", "serial", "expires_at"},
+					strings.ToLower(p), serial, NullTime(validBefore))
+			} else {
+				err = db.replace(ctx, tx, "ssh_users", "principal", []string{"principal", "serial"},
+					strings.ToLower(p), serial)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return errors.Wrap(err, "database Update error")
+}
+
+// GetSSHHostPrincipals gets a list of all valid host principals.
+func (db *SQLDB) GetSSHHostPrincipals() ([]string, error) {
+	rows, err := db.query(context.Background(), "SELECT principal FROM ssh_host_principals WHERE expires_at IS NULL OR expires_at > ? ORDER BY principal", time.Now().UTC())
+	if err != nil {
+		return nil, errors.Wrap(err, "database query error")
+	}
+	defer rows.Close()
+	var principals []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, errors.Wrap(err, "database scan error")
+		}
+		principals = append(principals, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "database query error")
+	}
+	return principals, nil
+}
+
+// Shutdown closes the database.
+func (db *SQLDB) Shutdown() error {
+	if err := db.db.Close(); err != nil {
+		return errors.Wrap(err, "database shutdown error")
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/pkg/errors"
+
+	// Register the database/sql drivers.
+	_ "github.com/jackc/pgx/v4/stdlib"
+)
+
+const (
+	// SQLPostgreSQL is the database type of a relational PostgreSQL database.
+	// Unlike the "postgresql" type, that uses PostgreSQL as a key-value store,
+	// this type stores the data in tables with typed columns and indexes.
+	SQLPostgreSQL = "sql-postgresql"
+	// SQLMySQL is the database type of a relational MySQL database. Unlike the
+	// "mysql" type, that uses MySQL as a key-value store, this type stores the
+	// data in tables with typed columns and indexes.
+	SQLMySQL = "sql-mysql"
+)
+
+// SQLDialect contains the differences between the supported relational
+// databases.
+type SQLDialect struct {
+	// DriverName is the name of the database/sql driver.
+	DriverName string
+	// BlobType is the column type used for binary data.
+	BlobType string
+	// KeyType is the column type used for the binary keys of the key-value
+	// store, they can be part of a primary key.
+	KeyType string
+	// TimeType is the column type used for timestamps.
+	TimeType string
+	// numbered indicates if the placeholders are numbered ($1, $2, ...)
+	// instead of question marks.
+	numbered bool
+}
+
+var sqlDialects = map[string]*SQLDialect{
+	SQLPostgreSQL: {DriverName: "pgx", BlobType: "BYTEA", KeyType: "BYTEA", TimeType: "TIMESTAMPTZ", numbered: true},
+	SQLMySQL:      {DriverName: "mysql", BlobType: "LONGBLOB", KeyType: "VARBINARY(255)", TimeType: "DATETIME(6)"},
+}
+
+// IsSQLType returns true if the given database type is one of the relational
+// database types.
+func IsSQLType(typ string) bool {
+	_, ok := sqlDialects[strings.ToLower(typ)]
+	return ok
+}
+
+// Rebind replaces the question mark placeholders in the query with the ones
+// used by the database.
+func (d *SQLDialect) Rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var (
+		n  int
+		sb strings.Builder
+	)
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// CreateIndex creates the index with the given name if it does not exist.
+func (d *SQLDialect) CreateIndex(ctx context.Context, db *sql.DB, name, table string, unique bool, columns ...string) error {
+	createIndex := "CREATE INDEX "
+	if unique {
+		createIndex = "CREATE UNIQUE INDEX "
+	}
+	if d.numbered {
+		_, err := db.ExecContext(ctx, createIndex+"IF NOT EXISTS "+name+" ON "+table+" ("+strings.Join(columns, ", ")+")")
+		return errors.Wrapf(err, "error creating index %s", name)
+	}
+
+	// MySQL does not support CREATE INDEX IF NOT EXISTS.
+	var n int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?", table, name).Scan(&n); err != nil {
+		return errors.Wrapf(err, "error creating index %s", name)
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, createIndex+name+" ON "+table+" ("+strings.Join(columns, ", ")+")")
+	return errors.Wrapf(err, "error creating index %s", name)
+}
+
+// IsUniqueViolation returns true if the error is caused by an insert of a
+// duplicate key.
+func (d *SQLDialect) IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1062
+	}
+	return false
+}
+
+// dataSource returns the data source name used to open the database. MySQL
+// connections are configured to parse the timestamps in UTC.
+func (d *SQLDialect) dataSource(dsn string) (string, error) {
+	if d.numbered {
+		return dsn, nil
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", errors.Wrap(err, "error parsing mysql data source")
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	return cfg.FormatDSN(), nil
+}
+
+// Exec runs the statements of a schema, replacing the {{blob}}, {{key}} and
+// {{time}} column types with the ones used by the database.
+func (d *SQLDialect) Exec(ctx context.Context, db *sql.DB, statements ...string) error {
+	r := strings.NewReplacer("{{blob}}", d.BlobType, "{{key}}", d.KeyType, "{{time}}", d.TimeType)
+	for _, s := range statements {
+		if _, err := db.ExecContext(ctx, r.Replace(s)); err != nil {
+			return errors.Wrap(err, "error creating database schema")
+		}
+	}
+	return nil
+}
+
+// NullTime returns the given time as a sql.NullTime, that is not valid if the
+// time is zero.
+func NullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+// TimeValue returns the time of the given sql.NullTime in UTC, or the zero
+// time if it is not valid.
+func TimeValue(t sql.NullTime) time.Time {
+	if !t.Valid {
+		return time.Time{}
+	}
+	return t.Time.UTC()
+}
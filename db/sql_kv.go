@@ -0,0 +1,197 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/nosql/database"
+)
+
+// The methods in this file implement the nosql.DB interface on the kv_store
+// table, a bucket is a set of rows with the same bucket name and the buckets
+// do not need to be created.
+
+// Open implements the nosql.DB interface. The database is opened when the
+// SQLDB is created.
+func (db *SQLDB) Open(string, ...database.Option) error {
+	return nil
+}
+
+// Close implements the nosql.DB interface and closes the database.
+func (db *SQLDB) Close() error {
+	return db.Shutdown()
+}
+
+// Get returns the value stored in the given bucket and key.
+func (db *SQLDB) Get(bucket, key []byte) ([]byte, error) {
+	var val []byte
+	err := db.queryRow(context.Background(), "SELECT nvalue FROM kv_store WHERE bucket = ? AND nkey = ?", string(bucket), key).Scan(&val)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, errors.Wrapf(database.ErrNotFound, "%s/%s not found", bucket, key)
+	case err != nil:
+		return nil, errors.Wrapf(err, "failed to get %s/%s", bucket, key)
+	default:
+		return val, nil
+	}
+}
+
+// Set stores the value in the given bucket and key.
+func (db *SQLDB) Set(bucket, key, value []byte) error {
+	ctx := context.Background()
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		return db.kvSet(ctx, tx, bucket, key, value)
+	})
+	return errors.Wrapf(err, "failed to set %s/%s", bucket, key)
+}
+
+// Del deletes the given bucket and key.
+func (db *SQLDB) Del(bucket, key []byte) error {
+	_, err := db.exec(context.Background(), "DELETE FROM kv_store WHERE bucket = ? AND nkey = ?", string(bucket), key)
+	return errors.Wrapf(err, "failed to delete %s/%s", bucket, key)
+}
+
+// List returns all the entries in the given bucket.
+func (db *SQLDB) List(bucket []byte) ([]*database.Entry, error) {
+	rows, err := db.query(context.Background(), "SELECT nkey, nvalue FROM kv_store WHERE bucket = ?", string(bucket))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error querying bucket %s", bucket)
+	}
+	defer rows.Close()
+	var entries []*database.Entry
+	for rows.Next() {
+		var key, value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, errors.Wrap(err, "error getting key and value from row")
+		}
+		entries = append(entries, &database.Entry{
+			Bucket: bucket,
+			Key:    key,
+			Value:  value,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error accessing row")
+	}
+	return entries, nil
+}
+
+// CmpAndSwap sets the value of the given bucket and key to newValue only if
+// the current value is oldValue. A nil oldValue means that the key must not
+// exist. It returns the current value and whether the value was swapped.
+func (db *SQLDB) CmpAndSwap(bucket, key, oldValue, newValue []byte) ([]byte, bool, error) {
+	ctx := context.Background()
+	var (
+		current []byte
+		swapped bool
+	)
+	err := db.withTx(ctx, func(tx *sql.Tx) (err error) {
+		current, swapped, err = db.kvCmpAndSwap(ctx, tx, bucket, key, oldValue, newValue)
+		return err
+	})
+	switch {
+	case db.dialect.IsUniqueViolation(errors.Cause(err)):
+		// Another transaction created the key after it was read.
+		val, err := db.Get(bucket, key)
+		if err != nil {
+			return nil, false, err
+		}
+		return val, false, nil
+	case err != nil:
+		return nil, false, errors.Wrapf(err, "failed to load-or-store %s/%s", bucket, key)
+	default:
+		return current, swapped, nil
+	}
+}
+
+// Update runs all the operations of the given transaction in one database
+// transaction.
+func (db *SQLDB) Update(tx *database.Tx) error {
+	ctx := context.Background()
+	err := db.withTx(ctx, func(sqlTx *sql.Tx) error {
+		for _, q := range tx.Operations {
+			switch q.Cmd {
+			case database.CreateTable:
+			case database.DeleteTable:
+				if _, err := sqlTx.ExecContext(ctx, db.dialect.Rebind("DELETE FROM kv_store WHERE bucket = ?"), string(q.Bucket)); err != nil {
+					return errors.Wrapf(err, "failed to delete bucket %s", q.Bucket)
+				}
+			case database.Get:
+				var val []byte
+				err := sqlTx.QueryRowContext(ctx, db.dialect.Rebind("SELECT nvalue FROM kv_store WHERE bucket = ? AND nkey = ?"), string(q.Bucket), q.Key).Scan(&val)
+				switch {
+				case errors.Is(err, sql.ErrNoRows):
+					return errors.Wrapf(database.ErrNotFound, "%s/%s not found", q.Bucket, q.Key)
+				case err != nil:
+					return errors.Wrapf(err, "failed to get %s/%s", q.Bucket, q.Key)
+				}
+				q.Result = val
+			case database.Set:
+				if err := db.kvSet(ctx, sqlTx, q.Bucket, q.Key, q.Value); err != nil {
+					return errors.Wrapf(err, "failed to set %s/%s", q.Bucket, q.Key)
+				}
+			case database.Delete:
+				if _, err := sqlTx.ExecContext(ctx, db.dialect.Rebind("DELETE FROM kv_store WHERE bucket = ? AND nkey = ?"), string(q.Bucket), q.Key); err != nil {
+					return errors.Wrapf(err, "failed to delete %s/%s", q.Bucket, q.Key)
+				}
+			case database.CmpAndSwap:
+				var err error
+				q.Result, q.Swapped, err = db.kvCmpAndSwap(ctx, sqlTx, q.Bucket, q.Key, q.CmpValue, q.Value)
+				if err != nil {
+					return errors.Wrapf(err, "failed to load-or-store %s/%s", q.Bucket, q.Key)
+				}
+			default:
+				return database.ErrOpNotSupported
+			}
+		}
+		return nil
+	})
+	return errors.Wrap(err, "UPDATE failed")
+}
+
+// CreateTable implements the nosql.DB interface. The buckets do not need to be
+// created.
+func (db *SQLDB) CreateTable([]byte) error {
+	return nil
+}
+
+// DeleteTable deletes all the entries in the given bucket.
+func (db *SQLDB) DeleteTable(bucket []byte) error {
+	_, err := db.exec(context.Background(), "DELETE FROM kv_store WHERE bucket = ?", string(bucket))
+	return errors.Wrapf(err, "failed to delete bucket %s", bucket)
+}
+
+func (db *SQLDB) kvSet(ctx context.Context, tx *sql.Tx, bucket, key, value []byte) error {
+	if _, err := tx.ExecContext(ctx, db.dialect.Rebind("DELETE FROM kv_store WHERE bucket = ? AND nkey = ?"), string(bucket), key); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, db.dialect.Rebind("INSERT INTO kv_store (bucket, nkey, nvalue) VALUES (?, ?, ?)"), string(bucket), key, value)
+	return err
+}
+
+func (db *SQLDB) kvCmpAndSwap(ctx context.Context, tx *sql.Tx, bucket, key, oldValue, newValue []byte) ([]byte, bool, error) {
+	var current []byte
+	err := tx.QueryRowContext(ctx, db.dialect.Rebind("SELECT nvalue FROM kv_store WHERE bucket = ? AND nkey = ? FOR UPDATE"), string(bucket), key).Scan(&current)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if oldValue != nil {
+			return nil, false, nil
+		}
+		// The insert fails with a unique violation if another transaction
+		// creates the key at the same time.
+		if _, err := tx.ExecContext(ctx, db.dialect.Rebind("INSERT INTO kv_store (bucket, nkey, nvalue) VALUES (?, ?, ?)"), string(bucket), key, newValue); err != nil {
+			return nil, false, err
+		}
+		return newValue, true, nil
+	case err != nil:
+		return nil, false, err
+	case oldValue == nil || !bytes.Equal(current, oldValue):
+		return current, false, nil
+	}
+	if _, err := tx.ExecContext(ctx, db.dialect.Rebind("UPDATE kv_store SET nvalue = ? WHERE bucket = ? AND nkey = ?"), newValue, string(bucket), key); err != nil {
+		return nil, false, err
+	}
+	return newValue, true, nil
+}
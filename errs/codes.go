@@ -0,0 +1,113 @@
+package errs
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Code is a stable and machine-readable identifier of an error. Codes are
+// sent in the "code" property of the error responses, and unlike the error
+// messages they never change, so clients and alerting rules can use them to
+// identify an error.
+type Code string
+
+// Generic codes of the errors, they are derived from the HTTP status if an
+// error does not have a more specific code.
+const (
+	CodeBadRequest         Code = "badRequest"
+	CodeUnauthorized       Code = "unauthorized"
+	CodeForbidden          Code = "forbidden"
+	CodeNotFound           Code = "notFound"
+	CodeConflict           Code = "conflict"
+	CodeRateLimited        Code = "rateLimited"
+	CodeServerInternal     Code = "serverInternal"
+	CodeNotImplemented     Code = "notImplemented"
+	CodeServiceUnavailable Code = "serviceUnavailable"
+)
+
+// Specific codes of the errors.
+const (
+	// CodeTokenReused is used when a one-time token is used more than once.
+	CodeTokenReused Code = "tokenReused"
+	// CodeCertificateRevoked is used when a revoked certificate is renewed
+	// or used to authenticate a request.
+	CodeCertificateRevoked Code = "certificateRevoked"
+	// CodePolicyDenied is used when a name is not allowed by the X.509 or
+	// SSH policies, or it was denied after the revocation of a certificate.
+	CodePolicyDenied Code = "policyDenied"
+	// CodeBadPublicKey is used when a public key is rejected by the key
+	// policy.
+	CodeBadPublicKey Code = "badPublicKey"
+	// CodeSignerUnavailable is used when the signing key, in a KMS or HSM, is
+	// temporarily unavailable.
+	CodeSignerUnavailable Code = "signerUnavailable"
+	// CodeReadOnly is used when a request is refused because the CA is in
+	// read-only mode.
+	CodeReadOnly Code = "readOnly"
+	// CodeOverloaded is used when a request is refused by the load shedding
+	// because the CA is overloaded.
+	CodeOverloaded Code = "overloaded"
+)
+
+// Coder is the interface implemented by the errors with a code.
+type Coder interface {
+	ErrorCode() Code
+}
+
+// WithCode returns an Option that sets the code of the error.
+func WithCode(code Code) Option {
+	return func(e *Error) error {
+		e.Code = code
+		return e
+	}
+}
+
+// CodeFromStatus returns the generic code of an HTTP status.
+func CodeFromStatus(status int) Code {
+	switch {
+	case status == http.StatusBadRequest:
+		return CodeBadRequest
+	case status == http.StatusUnauthorized:
+		return CodeUnauthorized
+	case status == http.StatusForbidden:
+		return CodeForbidden
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusConflict:
+		return CodeConflict
+	case status == http.StatusTooManyRequests:
+		return CodeRateLimited
+	case status == http.StatusNotImplemented:
+		return CodeNotImplemented
+	case status == http.StatusServiceUnavailable:
+		return CodeServiceUnavailable
+	case status >= 400 && status < 500:
+		return CodeBadRequest
+	default:
+		return CodeServerInternal
+	}
+}
+
+// CodeOf returns the code of an error. It returns the code of the first error
+// in the chain that implements the Coder interface, the code derived from the
+// status of the first error that implements the StatusCode method, or
+// CodeServerInternal.
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var c Coder
+	if errors.As(err, &c) {
+		if code := c.ErrorCode(); code != "" {
+			return code
+		}
+	}
+	var sc interface {
+		StatusCode() int
+	}
+	if errors.As(err, &sc) {
+		return CodeFromStatus(sc.StatusCode())
+	}
+	return CodeServerInternal
+}
@@ -0,0 +1,75 @@
+package errs
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type codedError struct {
+	code Code
+}
+
+func (e codedError) Error() string   { return "coded error" }
+func (e codedError) ErrorCode() Code { return e.code }
+
+type statusError struct {
+	status int
+}
+
+func (e statusError) Error() string   { return "status error" }
+func (e statusError) StatusCode() int { return e.status }
+
+func TestCodeFromStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   Code
+	}{
+		{http.StatusBadRequest, CodeBadRequest},
+		{http.StatusUnauthorized, CodeUnauthorized},
+		{http.StatusForbidden, CodeForbidden},
+		{http.StatusNotFound, CodeNotFound},
+		{http.StatusConflict, CodeConflict},
+		{http.StatusTooManyRequests, CodeRateLimited},
+		{http.StatusInternalServerError, CodeServerInternal},
+		{http.StatusNotImplemented, CodeNotImplemented},
+		{http.StatusServiceUnavailable, CodeServiceUnavailable},
+		{http.StatusUnprocessableEntity, CodeBadRequest},
+		{http.StatusBadGateway, CodeServerInternal},
+		{0, CodeServerInternal},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.status), func(t *testing.T) {
+			if got := CodeFromStatus(tt.status); got != tt.want {
+				t.Errorf("CodeFromStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{"nil", nil, ""},
+		{"plain", errors.New("force"), CodeServerInternal},
+		{"status", Unauthorized("unauthorized"), CodeUnauthorized},
+		{"with code", Unauthorized("token already used", WithCode(CodeTokenReused)), CodeTokenReused},
+		{"wrapped with code", Wrap(http.StatusForbidden, ApplyOptions(BadRequest("bad key"), WithCode(CodeBadPublicKey)), "authority.Sign"), CodeBadPublicKey},
+		{"coder", errors.Wrap(codedError{CodePolicyDenied}, "error"), CodePolicyDenied},
+		{"coder in error", ForbiddenErr(codedError{CodePolicyDenied}, "denied"), CodePolicyDenied},
+		{"empty coder in error", ForbiddenErr(codedError{}, "denied"), CodeForbidden},
+		{"status coder", fmt.Errorf("error: %w", statusError{http.StatusNotFound}), CodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CodeOf(tt.err); got != tt.want {
+				t.Errorf("CodeOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -50,6 +50,7 @@ func WithKeyVal(key string, val interface{}) Option {
 // Error represents the CA API errors.
 type Error struct {
 	Status  int
+	Code    Code
 	Err     error
 	Msg     string
 	Details map[string]interface{}
@@ -58,6 +59,7 @@ type Error struct {
 // ErrorResponse represents an error in JSON format.
 type ErrorResponse struct {
 	Status  int    `json:"status"`
+	Code    Code   `json:"code,omitempty"`
 	Message string `json:"message"`
 }
 
@@ -77,6 +79,22 @@ func (e *Error) StatusCode() int {
 	return e.Status
 }
 
+// ErrorCode implements the Coder interface and returns the code of the error.
+// If the error does not have a code, it returns the code of the wrapped error
+// or the one derived from the status.
+func (e *Error) ErrorCode() Code {
+	if e.Code != "" {
+		return e.Code
+	}
+	var c Coder
+	if e.Err != nil && errors.As(e.Err, &c) {
+		if code := c.ErrorCode(); code != "" {
+			return code
+		}
+	}
+	return CodeFromStatus(e.Status)
+}
+
 // Message returns a user friendly error, if one is set.
 func (e *Error) Message() string {
 	if len(e.Msg) > 0 {
@@ -127,7 +145,7 @@ func (e *Error) MarshalJSON() ([]byte, error) {
 	} else {
 		msg = http.StatusText(e.Status)
 	}
-	return json.Marshal(&ErrorResponse{Status: e.Status, Message: msg})
+	return json.Marshal(&ErrorResponse{Status: e.Status, Code: e.ErrorCode(), Message: msg})
 }
 
 // UnmarshalJSON implements json.Unmarshaler interface for the Error struct.
@@ -137,6 +155,7 @@ func (e *Error) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	e.Status = er.Status
+	e.Code = er.Code
 	e.Err = fmt.Errorf("%s", er.Message)
 	return nil
 }
@@ -171,6 +190,9 @@ func StatusCodeError(code int, e error, opts ...Option) error {
 		return InternalServerErr(e, opts...)
 	case http.StatusNotImplemented:
 		return NotImplementedErr(e, opts...)
+	case http.StatusServiceUnavailable:
+		opts = append(opts, withDefaultMessage(ServiceUnavailableDefaultMsg))
+		return NewErr(http.StatusServiceUnavailable, e, opts...)
 	default:
 		return UnexpectedErr(code, e, opts...)
 	}
@@ -190,6 +212,8 @@ var (
 	InternalServerErrorDefaultMsg = "The certificate authority encountered an Internal Server Error. " + seeLogs
 	// NotImplementedDefaultMsg 501 default msg
 	NotImplementedDefaultMsg = "The requested method is not implemented by the certificate authority. " + seeLogs
+	// ServiceUnavailableDefaultMsg 503 default msg
+	ServiceUnavailableDefaultMsg = "The certificate authority is temporarily unavailable, please try again later. " + seeLogs
 )
 
 var (
@@ -17,8 +17,8 @@ func TestError_MarshalJSON(t *testing.T) {
 		want    []byte
 		wantErr bool
 	}{
-		{"ok", fields{400, fmt.Errorf("bad request")}, []byte(`{"status":400,"message":"Bad Request"}`), false},
-		{"ok no error", fields{500, nil}, []byte(`{"status":500,"message":"Internal Server Error"}`), false},
+		{"ok", fields{400, fmt.Errorf("bad request")}, []byte(`{"status":400,"code":"badRequest","message":"Bad Request"}`), false},
+		{"ok no error", fields{500, nil}, []byte(`{"status":500,"code":"serverInternal","message":"Internal Server Error"}`), false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -49,6 +49,7 @@ func TestError_UnmarshalJSON(t *testing.T) {
 		wantErr  bool
 	}{
 		{"ok", args{[]byte(`{"status":400,"message":"bad request"}`)}, &Error{Status: 400, Err: fmt.Errorf("bad request")}, false},
+		{"ok with code", args{[]byte(`{"status":401,"code":"tokenReused","message":"token already used"}`)}, &Error{Status: 401, Code: CodeTokenReused, Err: fmt.Errorf("token already used")}, false},
 		{"fail", args{[]byte(`{"status":"400","message":"bad request"}`)}, &Error{}, true},
 	}
 	for _, tt := range tests {
@@ -0,0 +1,204 @@
+// Package idempotency implements the Idempotency-Key header used to retry
+// the requests that create or revoke certificates safely. The response of the
+// first request with a key is stored, and it is returned to the retries with
+// the same key, request and credentials during the configured window.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/cache"
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/logging"
+)
+
+// Header is the header with the idempotency key of a request.
+const Header = "Idempotency-Key"
+
+// ReplayedHeader is the header added to the responses returned from the
+// store.
+const ReplayedHeader = "Idempotent-Replayed"
+
+// maxKeyLength is the maximum length of an idempotency key.
+const maxKeyLength = 255
+
+// pendingTTL is the maximum time a request is considered in progress.
+const pendingTTL = 5 * time.Minute
+
+// record is a stored response.
+type record struct {
+	Pending     bool   `json:"pending,omitempty"`
+	RequestHash string `json:"requestHash,omitempty"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Handler keeps the responses of the requests with an idempotency key.
+type Handler struct {
+	store  cache.Store
+	window time.Duration
+}
+
+// New creates a new Handler that keeps the responses in the given store for
+// the given window of time.
+func New(store cache.Store, window time.Duration) *Handler {
+	return &Handler{
+		store:  store,
+		window: window,
+	}
+}
+
+type handlerKey struct{}
+
+// NewContext adds the given handler to the context.
+func NewContext(ctx context.Context, h *Handler) context.Context {
+	return context.WithValue(ctx, handlerKey{}, h)
+}
+
+// FromContext returns the handler in the context.
+func FromContext(ctx context.Context) (*Handler, bool) {
+	h, ok := ctx.Value(handlerKey{}).(*Handler)
+	return h, ok
+}
+
+// Middleware returns the stored response if the request has an idempotency
+// key that has already been used. It calls the next handler and stores its
+// response otherwise. The requests are served normally if they don't have
+// an idempotency key, or if there's no handler in the context.
+//
+// The key is scoped to the request path and credentials, the authorization
+// header and the client certificate. A retry with the same key but a
+// different body fails with a 422 status code, and a retry while the first
+// request is still in progress with a 409 status code. Responses with a 5xx
+// status code are not stored, so the request can be retried.
+func Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get(Header)
+		h, ok := FromContext(r.Context())
+		if idempotencyKey == "" || !ok {
+			next(w, r)
+			return
+		}
+		if len(idempotencyKey) > maxKeyLength {
+			render.Error(w, errs.BadRequest("%s header cannot be longer than %d characters", Header, maxKeyLength))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			render.Error(w, errs.BadRequestErr(err, "error reading request body"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		key := storeKey(r, idempotencyKey)
+		requestHash := hash(body)
+		pending, _ := json.Marshal(record{Pending: true, RequestHash: requestHash})
+
+		created, err := h.store.SetNX(ctx, key, pending, pendingTTL)
+		if err != nil {
+			render.Error(w, errs.InternalServerErr(err))
+			return
+		}
+		if !created {
+			h.replay(w, r, key, requestHash)
+			return
+		}
+
+		rec := &recorder{ResponseLogger: logging.NewResponseLogger(w)}
+		next(rec, r)
+
+		status := rec.StatusCode()
+		if status >= http.StatusInternalServerError {
+			_, _ = h.store.Delete(ctx, key)
+			return
+		}
+		b, err := json.Marshal(record{
+			RequestHash: requestHash,
+			Status:      status,
+			ContentType: rec.Header().Get("Content-Type"),
+			Body:        rec.body.Bytes(),
+		})
+		if err == nil {
+			err = h.store.Set(ctx, key, b, h.window)
+		}
+		if err != nil {
+			// The response has been already sent, the retries will fail
+			// until the pending record expires.
+			rec.WithFields(map[string]interface{}{"idempotency-error": err.Error()})
+		}
+	}
+}
+
+// replay writes the stored response for the given key.
+func (h *Handler) replay(w http.ResponseWriter, r *http.Request, key, requestHash string) {
+	b, ok, err := h.store.Get(r.Context(), key)
+	if err != nil {
+		render.Error(w, errs.InternalServerErr(err))
+		return
+	}
+	var rec record
+	if ok {
+		if err := json.Unmarshal(b, &rec); err != nil {
+			render.Error(w, errs.InternalServerErr(err))
+			return
+		}
+	}
+
+	switch {
+	case !ok:
+		// The record expired between the two calls.
+		render.Error(w, errs.New(http.StatusConflict, "a request with the same %s is being processed, retry later", Header))
+	case rec.RequestHash != requestHash:
+		render.Error(w, errs.New(http.StatusUnprocessableEntity, "the %s has been already used with a different request", Header))
+	case rec.Pending:
+		render.Error(w, errs.New(http.StatusConflict, "a request with the same %s is being processed, retry later", Header))
+	default:
+		if rec.ContentType != "" {
+			w.Header().Set("Content-Type", rec.ContentType)
+		}
+		w.Header().Set(ReplayedHeader, "true")
+		w.WriteHeader(rec.Status)
+		_, _ = w.Write(rec.Body)
+	}
+}
+
+// storeKey returns the key used in the store for the given request and
+// idempotency key.
+func storeKey(r *http.Request, idempotencyKey string) string {
+	h := sha256.New()
+	for _, s := range []string{r.Method, r.URL.Path, idempotencyKey, r.Header.Get("Authorization")} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		h.Write(r.TLS.PeerCertificates[0].Raw)
+	}
+	return "idempotency:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func hash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// recorder is a response writer that keeps a copy of the response body.
+type recorder struct {
+	logging.ResponseLogger
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseLogger.Write(b)
+}
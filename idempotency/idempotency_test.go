@@ -0,0 +1,119 @@
+package idempotency
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smallstep/certificates/cache"
+)
+
+func TestMiddleware(t *testing.T) {
+	var calls int32
+	next := func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		b, _ := io.ReadAll(r.Body)
+		if string(b) == "fail" {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"call":` + string(rune('0'+n)) + `}`))
+	}
+
+	store := cache.NewMemory()
+	ctx := NewContext(context.Background(), New(store, time.Hour))
+	do := func(ctx context.Context, key, auth, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/sign", strings.NewReader(body)).WithContext(ctx)
+		if key != "" {
+			r.Header.Set(Header, key)
+		}
+		r.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		Middleware(next)(w, r)
+		return w
+	}
+
+	// First request is served and stored.
+	w := do(ctx, "key-1", "token", "body")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `{"call":1}`, w.Body.String())
+	assert.Empty(t, w.Header().Get(ReplayedHeader))
+
+	// The retry returns the stored response.
+	w = do(ctx, "key-1", "token", "body")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `{"call":1}`, w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "true", w.Header().Get(ReplayedHeader))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// The same key with a different body is rejected.
+	w = do(ctx, "key-1", "token", "other")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// The key is scoped to the credentials.
+	w = do(ctx, "key-1", "other-token", "body")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `{"call":2}`, w.Body.String())
+
+	// Requests without key or without handler are not stored.
+	w = do(ctx, "", "token", "body")
+	assert.Equal(t, `{"call":3}`, w.Body.String())
+	w = do(context.Background(), "key-1", "token", "body")
+	assert.Equal(t, `{"call":4}`, w.Body.String())
+
+	// Server errors are not stored.
+	w = do(ctx, "key-2", "token", "fail")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	w = do(ctx, "key-2", "token", "fail")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, int32(6), atomic.LoadInt32(&calls))
+
+	// Invalid keys.
+	w = do(ctx, strings.Repeat("a", 256), "token", "body")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, int32(6), atomic.LoadInt32(&calls))
+}
+
+func TestMiddleware_pending(t *testing.T) {
+	store := cache.NewMemory()
+	ctx := NewContext(context.Background(), New(store, time.Hour))
+
+	started := make(chan struct{})
+	done := make(chan struct{})
+	next := func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-done
+		w.WriteHeader(http.StatusOK)
+	}
+
+	first := httptest.NewRecorder()
+	finished := make(chan struct{})
+	go func() {
+		r := httptest.NewRequest("POST", "/revoke", strings.NewReader("body")).WithContext(ctx)
+		r.Header.Set(Header, "key")
+		Middleware(next)(first, r)
+		close(finished)
+	}()
+	<-started
+
+	r := httptest.NewRequest("POST", "/revoke", strings.NewReader("body")).WithContext(ctx)
+	r.Header.Set(Header, "key")
+	w := httptest.NewRecorder()
+	Middleware(next)(w, r)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	close(done)
+	<-finished
+	assert.Equal(t, http.StatusOK, first.Code)
+}
@@ -0,0 +1,177 @@
+// Package chaos implements the injection of failures and latency in the
+// database, the KMS signer, and the outbound validations of the CA. It allows
+// testing the reliability of clients and deployments against a real CA.
+//
+// Faults are only injected in binaries built with the chaos tag:
+//
+//	go build -tags chaos ./cmd/step-ca
+package chaos
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// Targets of the injected faults.
+const (
+	// TargetDB injects faults in the operations of the nosql databases, the
+	// relational databases are not supported. The operations are get, set,
+	// del, list, update, cmpAndSwap, createTable, and deleteTable.
+	TargetDB = "db"
+	// TargetKMS injects faults in the signatures with the intermediate key.
+	// The only operation is sign.
+	TargetKMS = "kms"
+	// TargetValidation injects faults in the outbound connections of the
+	// ACME validations. The operations are http, dns, and tls.
+	TargetValidation = "validation"
+)
+
+var operations = map[string][]string{
+	TargetDB:         {"get", "set", "del", "list", "update", "cmpAndSwap", "createTable", "deleteTable"},
+	TargetKMS:        {"sign"},
+	TargetValidation: {"http", "dns", "tls"},
+}
+
+// defaultErrorMessage is the message of the injected errors if a rule does not
+// define one.
+const defaultErrorMessage = "chaos: injected failure"
+
+// Config is the configuration of the injected faults.
+type Config struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Rule defines the faults injected in the operations of a target. The latency
+// is added to every matching operation, and the operation fails with the
+// given error rate, a number between 0 and 1.
+type Rule struct {
+	Target    string                `json:"target"`
+	Operation string                `json:"operation,omitempty"`
+	ErrorRate float64               `json:"errorRate,omitempty"`
+	Latency   *provisioner.Duration `json:"latency,omitempty"`
+	Error     string                `json:"error,omitempty"`
+}
+
+// Validate validates the chaos configuration.
+func (c *Config) Validate() error {
+	switch {
+	case c == nil:
+		return nil
+	case !Enabled:
+		return errors.New("chaos is not supported, the binary must be built with the chaos tag")
+	case len(c.Rules) == 0:
+		return errors.New("chaos.rules cannot be empty")
+	}
+	for i, r := range c.Rules {
+		if err := r.validate(); err != nil {
+			return errors.Wrapf(err, "chaos.rules[%d]", i)
+		}
+	}
+	return nil
+}
+
+func (r *Rule) validate() error {
+	if r == nil {
+		return errors.New("rule cannot be empty")
+	}
+	ops, ok := operations[r.Target]
+	if !ok {
+		return errors.Errorf("target %q is not supported", r.Target)
+	}
+	if r.Operation != "" && !contains(ops, r.Operation) {
+		return errors.Errorf("operation %q is not supported, it must be one of %s", r.Operation, strings.Join(ops, ", "))
+	}
+	if r.ErrorRate < 0 || r.ErrorRate > 1 {
+		return errors.Errorf("errorRate %v must be between 0 and 1", r.ErrorRate)
+	}
+	if r.Latency != nil && r.Latency.Duration < 0 {
+		return errors.New("latency cannot be negative")
+	}
+	return nil
+}
+
+func (r *Rule) matches(target, op string) bool {
+	return r.Target == target && (r.Operation == "" || r.Operation == op)
+}
+
+// Injector injects the faults of the configured rules. A nil injector does
+// not inject any fault.
+type Injector struct {
+	rules []*Rule
+	sleep func(time.Duration)
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// New returns an injector for the given configuration. It returns nil if the
+// configuration is nil or the binary has not been built with the chaos tag.
+func New(c *Config) *Injector {
+	if c == nil || !Enabled {
+		return nil
+	}
+	return &Injector{
+		rules: c.Rules,
+		sleep: time.Sleep,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec // not used for security
+	}
+}
+
+// Inject applies the faults of the rules matching the given target and
+// operation. It blocks for the configured latency, and returns an error if the
+// operation must fail.
+func (i *Injector) Inject(target, op string) error {
+	if i == nil {
+		return nil
+	}
+	for _, r := range i.rules {
+		if !r.matches(target, op) {
+			continue
+		}
+		if r.Latency != nil && r.Latency.Duration > 0 {
+			i.sleep(r.Latency.Duration)
+		}
+		if r.ErrorRate > 0 && i.float64() < r.ErrorRate {
+			msg := r.Error
+			if msg == "" {
+				msg = defaultErrorMessage
+			}
+			return errors.Errorf("%s: %s %s", msg, target, op)
+		}
+	}
+	return nil
+}
+
+// HasRules reports whether any rule matches the given target. The authority
+// uses it to reject the db rules if the database cannot be wrapped.
+func (i *Injector) HasRules(target string) bool {
+	if i == nil {
+		return false
+	}
+	for _, r := range i.rules {
+		if r.Target == target {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Injector) float64() float64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rand.Float64()
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package chaos
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	mathrand "math/rand"
+	"testing"
+	"time"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/nosql/database"
+)
+
+func newTestInjector(rules ...*Rule) (*Injector, *time.Duration) {
+	var slept time.Duration
+	return &Injector{
+		rules: rules,
+		sleep: func(d time.Duration) { slept += d },
+		rand:  mathrand.New(mathrand.NewSource(1)), //nolint:gosec // test
+	}, &slept
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *Config
+		wantErr bool
+	}{
+		{"ok/db", &Config{Rules: []*Rule{{Target: TargetDB, ErrorRate: 0.5}}}, false},
+		{"ok/kms", &Config{Rules: []*Rule{{Target: TargetKMS, Operation: "sign", Latency: &provisioner.Duration{Duration: time.Second}}}}, false},
+		{"ok/validation", &Config{Rules: []*Rule{{Target: TargetValidation, Operation: "dns", ErrorRate: 1, Error: "lookup failed"}}}, false},
+		{"fail/empty", &Config{}, true},
+		{"fail/nil-rule", &Config{Rules: []*Rule{nil}}, true},
+		{"fail/target", &Config{Rules: []*Rule{{Target: "cache"}}}, true},
+		{"fail/operation", &Config{Rules: []*Rule{{Target: TargetKMS, Operation: "get"}}}, true},
+		{"fail/errorRate", &Config{Rules: []*Rule{{Target: TargetDB, ErrorRate: 1.5}}}, true},
+		{"fail/latency", &Config{Rules: []*Rule{{Target: TargetDB, Latency: &provisioner.Duration{Duration: -time.Second}}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Without the chaos tag any configuration is rejected.
+			wantErr := tt.wantErr || !Enabled
+			if err := tt.config.Validate(); (err != nil) != wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, wantErr)
+			}
+		})
+	}
+
+	var c *Config
+	if err := c.Validate(); err != nil {
+		t.Errorf("Config.Validate() error = %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if New(nil) != nil {
+		t.Error("New(nil) is not nil")
+	}
+	inj := New(&Config{Rules: []*Rule{{Target: TargetDB}}})
+	if Enabled != (inj != nil) {
+		t.Errorf("New() = %v, chaos enabled %v", inj, Enabled)
+	}
+}
+
+func TestInjector_Inject(t *testing.T) {
+	inj, slept := newTestInjector(
+		&Rule{Target: TargetDB, Operation: "get", ErrorRate: 1, Error: "database is down"},
+		&Rule{Target: TargetKMS, Latency: &provisioner.Duration{Duration: time.Second}},
+	)
+	if err := inj.Inject(TargetDB, "get"); err == nil || err.Error() != "database is down: db get" {
+		t.Errorf("Injector.Inject() error = %v", err)
+	}
+	if err := inj.Inject(TargetDB, "set"); err != nil {
+		t.Errorf("Injector.Inject() error = %v", err)
+	}
+	if err := inj.Inject(TargetKMS, "sign"); err != nil {
+		t.Errorf("Injector.Inject() error = %v", err)
+	}
+	if *slept != time.Second {
+		t.Errorf("Injector.Inject() slept %v, want %v", *slept, time.Second)
+	}
+
+	var nilInjector *Injector
+	if err := nilInjector.Inject(TargetDB, "get"); err != nil {
+		t.Errorf("Injector.Inject() error = %v", err)
+	}
+}
+
+func TestInjector_Inject_errorRate(t *testing.T) {
+	inj, _ := newTestInjector(&Rule{Target: TargetValidation, ErrorRate: 0.25})
+	var failures int
+	for i := 0; i < 1000; i++ {
+		if inj.Inject(TargetValidation, "http") != nil {
+			failures++
+		}
+	}
+	if failures < 200 || failures > 300 {
+		t.Errorf("Injector.Inject() failures = %d, want about 250", failures)
+	}
+}
+
+func TestInjector_HasRules(t *testing.T) {
+	var nilInjector *Injector
+	if nilInjector.HasRules(TargetDB) {
+		t.Error("Injector.HasRules() = true with a nil injector")
+	}
+	inj, _ := newTestInjector(&Rule{Target: TargetDB, Operation: "get", ErrorRate: 1})
+	if !inj.HasRules(TargetDB) {
+		t.Error("Injector.HasRules() = false, want true")
+	}
+	if inj.HasRules(TargetKMS) {
+		t.Error("Injector.HasRules() = true, want false")
+	}
+}
+
+type testDB struct {
+	database.DB
+	gets int
+}
+
+func (db *testDB) Get(bucket, key []byte) ([]byte, error) {
+	db.gets++
+	return []byte("value"), nil
+}
+
+func TestInjector_WrapDB(t *testing.T) {
+	db := &testDB{}
+	var nilInjector *Injector
+	if nilInjector.WrapDB(db) != db {
+		t.Error("Injector.WrapDB() wrapped the database without rules")
+	}
+
+	inj, _ := newTestInjector(&Rule{Target: TargetDB, Operation: "get", ErrorRate: 1})
+	wrapped := inj.WrapDB(db)
+	if _, err := wrapped.Get([]byte("bucket"), []byte("key")); err == nil {
+		t.Error("chaosDB.Get() error = nil")
+	}
+	if db.gets != 0 {
+		t.Errorf("chaosDB.Get() called the database %d times", db.gets)
+	}
+}
+
+func TestInjector_WrapSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte("message"))
+
+	inj, _ := newTestInjector(&Rule{Target: TargetDB, ErrorRate: 1})
+	if inj.WrapSigner(key) != crypto.Signer(key) {
+		t.Error("Injector.WrapSigner() wrapped the signer without kms rules")
+	}
+
+	inj, _ = newTestInjector(&Rule{Target: TargetKMS, ErrorRate: 1})
+	signer := inj.WrapSigner(key)
+	if signer.Public() != key.Public() {
+		t.Error("chaosSigner.Public() does not match the key")
+	}
+	if _, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256); err == nil {
+		t.Error("chaosSigner.Sign() error = nil")
+	}
+}
+
+func TestInjector_ValidationFaults(t *testing.T) {
+	inj, _ := newTestInjector(&Rule{Target: TargetDB, ErrorRate: 1})
+	if inj.ValidationFaults() != nil {
+		t.Error("Injector.ValidationFaults() is not nil without validation rules")
+	}
+
+	inj, _ = newTestInjector(&Rule{Target: TargetValidation, Operation: "dns", ErrorRate: 1})
+	inject := inj.ValidationFaults()
+	if err := inject("http"); err != nil {
+		t.Errorf("Injector.ValidationFaults() http error = %v", err)
+	}
+	if err := inject("dns"); err == nil {
+		t.Error("Injector.ValidationFaults() dns error = nil")
+	}
+}
@@ -0,0 +1,7 @@
+//go:build chaos
+
+package chaos
+
+// Enabled reports whether the binary has been built with the chaos tag. Faults
+// are only injected in binaries built with it.
+const Enabled = true
@@ -0,0 +1,116 @@
+package chaos
+
+import (
+	"crypto"
+	"io"
+
+	"github.com/smallstep/nosql"
+	"github.com/smallstep/nosql/database"
+)
+
+// WrapDB returns a database that injects the faults of the db rules before
+// each operation. It returns the given database if there are no db rules.
+func (i *Injector) WrapDB(db nosql.DB) nosql.DB {
+	if !i.HasRules(TargetDB) {
+		return db
+	}
+	return &chaosDB{DB: db, inj: i}
+}
+
+type chaosDB struct {
+	nosql.DB
+	inj *Injector
+}
+
+func (db *chaosDB) inject(op string) error {
+	return db.inj.Inject(TargetDB, op)
+}
+
+func (db *chaosDB) Get(bucket, key []byte) ([]byte, error) {
+	if err := db.inject("get"); err != nil {
+		return nil, err
+	}
+	return db.DB.Get(bucket, key)
+}
+
+func (db *chaosDB) Set(bucket, key, value []byte) error {
+	if err := db.inject("set"); err != nil {
+		return err
+	}
+	return db.DB.Set(bucket, key, value)
+}
+
+func (db *chaosDB) CmpAndSwap(bucket, key, oldValue, newValue []byte) ([]byte, bool, error) {
+	if err := db.inject("cmpAndSwap"); err != nil {
+		return nil, false, err
+	}
+	return db.DB.CmpAndSwap(bucket, key, oldValue, newValue)
+}
+
+func (db *chaosDB) Del(bucket, key []byte) error {
+	if err := db.inject("del"); err != nil {
+		return err
+	}
+	return db.DB.Del(bucket, key)
+}
+
+func (db *chaosDB) List(bucket []byte) ([]*database.Entry, error) {
+	if err := db.inject("list"); err != nil {
+		return nil, err
+	}
+	return db.DB.List(bucket)
+}
+
+func (db *chaosDB) Update(tx *database.Tx) error {
+	if err := db.inject("update"); err != nil {
+		return err
+	}
+	return db.DB.Update(tx)
+}
+
+func (db *chaosDB) CreateTable(bucket []byte) error {
+	if err := db.inject("createTable"); err != nil {
+		return err
+	}
+	return db.DB.CreateTable(bucket)
+}
+
+func (db *chaosDB) DeleteTable(bucket []byte) error {
+	if err := db.inject("deleteTable"); err != nil {
+		return err
+	}
+	return db.DB.DeleteTable(bucket)
+}
+
+// WrapSigner returns a signer that injects the faults of the kms rules before
+// each signature. It returns the given signer if there are no kms rules.
+func (i *Injector) WrapSigner(signer crypto.Signer) crypto.Signer {
+	if !i.HasRules(TargetKMS) {
+		return signer
+	}
+	return &chaosSigner{Signer: signer, inj: i}
+}
+
+type chaosSigner struct {
+	crypto.Signer
+	inj *Injector
+}
+
+func (s *chaosSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if err := s.inj.Inject(TargetKMS, "sign"); err != nil {
+		return nil, err
+	}
+	return s.Signer.Sign(rand, digest, opts)
+}
+
+// ValidationFaults returns the function that injects the faults of the
+// validation rules, it's used with acme.WithFaults to wrap the client of the
+// ACME validations. It returns nil if there are no validation rules.
+func (i *Injector) ValidationFaults() func(op string) error {
+	if !i.HasRules(TargetValidation) {
+		return nil
+	}
+	return func(op string) error {
+		return i.Inject(TargetValidation, op)
+	}
+}
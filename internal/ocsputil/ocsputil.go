@@ -0,0 +1,148 @@
+// Package ocsputil implements the parts of RFC 6960 and RFC 8954 that are not
+// supported by golang.org/x/crypto/ocsp: the request extensions and the
+// response extensions, like the nonce.
+package ocsputil
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ocsp"
+)
+
+// OIDNonce is the OCSP nonce extension defined in RFC 8954.
+var OIDNonce = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+
+// MaxNonceLength is the maximum length of a nonce defined in RFC 8954.
+const MaxNonceLength = 32
+
+var oidBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+type requestASN1 struct {
+	TBSRequest        tbsRequest
+	OptionalSignature asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type tbsRequest struct {
+	Version           int           `asn1:"explicit,tag:0,default:0,optional"`
+	RequestorName     asn1.RawValue `asn1:"explicit,tag:1,optional"`
+	RequestList       []asn1.RawValue
+	RequestExtensions []pkix.Extension `asn1:"explicit,tag:2,optional"`
+}
+
+type responseASN1 struct {
+	Status   asn1.Enumerated
+	Response responseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type responseData struct {
+	Version            int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID     asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []asn1.RawValue
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+// ParseNonce returns the nonce extension of a DER encoded OCSP request, or
+// nil if the request does not have one. It fails if the nonce is empty or
+// longer than MaxNonceLength.
+func ParseNonce(der []byte) (*pkix.Extension, error) {
+	var req requestASN1
+	if rest, err := asn1.Unmarshal(der, &req); err != nil {
+		return nil, errors.Wrap(err, "error parsing ocsp request")
+	} else if len(rest) > 0 {
+		return nil, errors.New("error parsing ocsp request: trailing data")
+	}
+	for _, ext := range req.TBSRequest.RequestExtensions {
+		if !ext.Id.Equal(OIDNonce) {
+			continue
+		}
+		var nonce []byte
+		if _, err := asn1.Unmarshal(ext.Value, &nonce); err != nil {
+			return nil, errors.Wrap(err, "error parsing ocsp nonce")
+		}
+		if len(nonce) == 0 || len(nonce) > MaxNonceLength {
+			return nil, errors.Errorf("ocsp nonce must have between 1 and %d bytes", MaxNonceLength)
+		}
+		return &pkix.Extension{Id: OIDNonce, Value: ext.Value}, nil
+	}
+	return nil, nil
+}
+
+// CreateResponse works like ocsp.CreateResponse, but it also adds the given
+// extensions to the responseExtensions of the response.
+func CreateResponse(issuer, responderCert *x509.Certificate, template ocsp.Response, extensions []pkix.Extension, priv crypto.Signer) ([]byte, error) {
+	if len(extensions) == 0 {
+		return ocsp.CreateResponse(issuer, responderCert, template, priv)
+	}
+
+	// The response is created with a fake signature, the real one is set
+	// after adding the extensions.
+	s := &hashRecorder{Signer: priv}
+	b, err := ocsp.CreateResponse(issuer, responderCert, template, s)
+	if err != nil {
+		return nil, err
+	}
+	var resp responseASN1
+	if _, err := asn1.Unmarshal(b, &resp); err != nil {
+		return nil, errors.Wrap(err, "error parsing ocsp response")
+	}
+	var basic basicResponse
+	if _, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil {
+		return nil, errors.Wrap(err, "error parsing ocsp response")
+	}
+	var tbs responseData
+	if _, err := asn1.Unmarshal(basic.TBSResponseData.FullBytes, &tbs); err != nil {
+		return nil, errors.Wrap(err, "error parsing ocsp response")
+	}
+
+	tbs.ResponseExtensions = append(tbs.ResponseExtensions, extensions...)
+	tbsDER, err := asn1.Marshal(tbs)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling ocsp response")
+	}
+	h := s.opts.HashFunc().New()
+	h.Write(tbsDER)
+	signature, err := priv.Sign(rand.Reader, h.Sum(nil), s.opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "error signing ocsp response")
+	}
+
+	basic.TBSResponseData = asn1.RawValue{FullBytes: tbsDER}
+	basic.Signature = asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)}
+	if resp.Response.Response, err = asn1.Marshal(basic); err != nil {
+		return nil, errors.Wrap(err, "error marshaling ocsp response")
+	}
+	resp.Response.ResponseType = oidBasicResponse
+	return asn1.Marshal(resp)
+}
+
+// hashRecorder is a crypto.Signer that records the signer options and returns
+// an empty signature.
+type hashRecorder struct {
+	crypto.Signer
+	opts crypto.SignerOpts
+}
+
+func (s *hashRecorder) Sign(_ io.Reader, _ []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.opts = opts
+	return []byte{}, nil
+}
@@ -0,0 +1,162 @@
+package ocsputil
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func newIssuer(t *testing.T, signer crypto.Signer) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+func newRequest(t *testing.T, issuer *x509.Certificate, extensions ...pkix.Extension) []byte {
+	t.Helper()
+	der, err := ocsp.CreateRequest(&x509.Certificate{SerialNumber: big.NewInt(1234)}, issuer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req requestASN1
+	if _, err := asn1.Unmarshal(der, &req); err != nil {
+		t.Fatal(err)
+	}
+	req.TBSRequest.RequestExtensions = extensions
+	if der, err = asn1.Marshal(req); err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func nonceExtension(t *testing.T, nonce []byte) pkix.Extension {
+	t.Helper()
+	value, err := asn1.Marshal(nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.Extension{Id: OIDNonce, Value: value}
+}
+
+func TestParseNonce(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := newIssuer(t, key)
+	nonce := nonceExtension(t, []byte("0123456789abcdef"))
+
+	tests := []struct {
+		name    string
+		der     []byte
+		want    *pkix.Extension
+		wantErr bool
+	}{
+		{"ok", newRequest(t, issuer, nonce), &nonce, false},
+		{"ok/other", newRequest(t, issuer, pkix.Extension{Id: asn1.ObjectIdentifier{1, 2, 3}, Value: asn1.NullBytes}, nonce), &nonce, false},
+		{"ok/no-nonce", newRequest(t, issuer), nil, false},
+		{"fail/empty", newRequest(t, issuer, nonceExtension(t, []byte{})), nil, true},
+		{"fail/long", newRequest(t, issuer, nonceExtension(t, make([]byte, MaxNonceLength+1))), nil, true},
+		{"fail/value", newRequest(t, issuer, pkix.Extension{Id: OIDNonce, Value: []byte{0x01}}), nil, true},
+		{"fail/request", []byte("foo"), nil, true},
+		{"fail/trailing", append(newRequest(t, issuer), 0), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNonce(tt.der)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNonce() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("ParseNonce() = %v, want nil", got)
+			case tt.want != nil && (got == nil || !got.Id.Equal(tt.want.Id) || !bytes.Equal(got.Value, tt.want.Value)):
+				t.Errorf("ParseNonce() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateResponse(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := nonceExtension(t, []byte("0123456789abcdef"))
+
+	now := time.Now().Truncate(time.Second)
+	template := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: big.NewInt(1234),
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(time.Hour),
+	}
+	for name, key := range map[string]crypto.Signer{"ecdsa": ecKey, "rsa": rsaKey} {
+		t.Run(name, func(t *testing.T) {
+			issuer := newIssuer(t, key)
+			for _, extensions := range [][]pkix.Extension{nil, {nonce}} {
+				b, err := CreateResponse(issuer, issuer, template, extensions, key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, err := ocsp.ParseResponse(b, issuer)
+				if err != nil {
+					t.Fatalf("ocsp.ParseResponse() error = %v", err)
+				}
+				if resp.Status != ocsp.Good || resp.SerialNumber.Cmp(template.SerialNumber) != 0 || !resp.NextUpdate.Equal(template.NextUpdate) {
+					t.Errorf("ocsp.ParseResponse() = %v", resp)
+				}
+
+				var r responseASN1
+				if _, err := asn1.Unmarshal(b, &r); err != nil {
+					t.Fatal(err)
+				}
+				var basic basicResponse
+				if _, err := asn1.Unmarshal(r.Response.Response, &basic); err != nil {
+					t.Fatal(err)
+				}
+				var tbs responseData
+				if _, err := asn1.Unmarshal(basic.TBSResponseData.FullBytes, &tbs); err != nil {
+					t.Fatal(err)
+				}
+				if len(tbs.ResponseExtensions) != len(extensions) {
+					t.Fatalf("responseExtensions = %v, want %v", tbs.ResponseExtensions, extensions)
+				}
+				if len(extensions) > 0 && (!tbs.ResponseExtensions[0].Id.Equal(OIDNonce) || !bytes.Equal(tbs.ResponseExtensions[0].Value, nonce.Value)) {
+					t.Errorf("responseExtensions = %v, want %v", tbs.ResponseExtensions, extensions)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+// Package pkcs12 implements the encoding of a private key and its certificate
+// chain in a PKCS #12 file, as defined in RFC 7292.
+//
+// The key is encrypted with PBES2 using PBKDF2 with HMAC-SHA256 and AES-256-CBC,
+// and the integrity of the file is protected with an HMAC-SHA256, the same
+// algorithms used by default in OpenSSL 3.
+package pkcs12
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // used for the localKeyId, not for security
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// DefaultIterations is the number of iterations used to derive the encryption
+// and the integrity keys.
+const DefaultIterations = 2048
+
+const saltSize = 16
+
+var (
+	oidDataContentType         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidCertBag                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidPKCS8ShroudedKeyBag     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertTypeX509Certificate = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidLocalKeyID              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBES2                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256          = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA256                  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+type pfx struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type digestInfo struct {
+	Algorithm algorithmIdentifier
+	Digest    []byte
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     algorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc algorithmIdentifier
+	EncryptionScheme  algorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	PRF        algorithmIdentifier
+}
+
+// Encode returns the PKCS #12 file with the given key and certificates, the
+// first certificate must be the certificate of the key. The key and the
+// integrity of the file are protected with the given password.
+func Encode(key crypto.PrivateKey, certs []*x509.Certificate, password string) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("certificate cannot be empty")
+	}
+	sum := sha1.Sum(certs[0].Raw) //nolint:gosec // used as an identifier
+	localKeyID, err := localKeyIDAttribute(sum[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// The certificates are not encrypted.
+	var certBags []safeBag
+	for i, crt := range certs {
+		b, err := asn1.Marshal(certBag{ID: oidCertTypeX509Certificate, Data: crt.Raw})
+		if err != nil {
+			return nil, errors.Wrap(err, "error encoding certificate")
+		}
+		bag := safeBag{ID: oidCertBag, Value: explicit(b)}
+		if i == 0 {
+			bag.Attributes = []pkcs12Attribute{localKeyID}
+		}
+		certBags = append(certBags, bag)
+	}
+	certsInfo, err := dataContentInfo(certBags)
+	if err != nil {
+		return nil, err
+	}
+
+	keyBag, err := shroudedKeyBag(key, password)
+	if err != nil {
+		return nil, err
+	}
+	keyBag.Attributes = []pkcs12Attribute{localKeyID}
+	keyInfo, err := dataContentInfo([]safeBag{keyBag})
+	if err != nil {
+		return nil, err
+	}
+
+	authSafe, err := asn1.Marshal([]contentInfo{certsInfo, keyInfo})
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding authenticated safe")
+	}
+	mac, err := computeMac(authSafe, password)
+	if err != nil {
+		return nil, err
+	}
+	content, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding authenticated safe")
+	}
+	b, err := asn1.Marshal(pfx{
+		Version: 3,
+		AuthSafe: contentInfo{
+			ContentType: oidDataContentType,
+			Content:     explicit(content),
+		},
+		MacData: mac,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding pkcs12")
+	}
+	return b, nil
+}
+
+func localKeyIDAttribute(id []byte) (pkcs12Attribute, error) {
+	b, err := asn1.Marshal(id)
+	if err != nil {
+		return pkcs12Attribute{}, errors.Wrap(err, "error encoding localKeyId")
+	}
+	return pkcs12Attribute{
+		ID:    oidLocalKeyID,
+		Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: b},
+	}, nil
+}
+
+// dataContentInfo returns a data content info with the given bags.
+func dataContentInfo(bags []safeBag) (contentInfo, error) {
+	safeContents, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, errors.Wrap(err, "error encoding safe contents")
+	}
+	b, err := asn1.Marshal(safeContents)
+	if err != nil {
+		return contentInfo{}, errors.Wrap(err, "error encoding safe contents")
+	}
+	return contentInfo{ContentType: oidDataContentType, Content: explicit(b)}, nil
+}
+
+// shroudedKeyBag returns the bag with the key encrypted using PBES2.
+func shroudedKeyBag(key crypto.PrivateKey, password string) (safeBag, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return safeBag{}, errors.Wrap(err, "error encoding private key")
+	}
+	salt, err := randomBytes(saltSize)
+	if err != nil {
+		return safeBag{}, err
+	}
+	iv, err := randomBytes(aes.BlockSize)
+	if err != nil {
+		return safeBag{}, err
+	}
+
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(password), salt, DefaultIterations, 32, sha256.New))
+	if err != nil {
+		return safeBag{}, errors.Wrap(err, "error creating cipher")
+	}
+	// PKCS #7 padding.
+	n := aes.BlockSize - len(der)%aes.BlockSize
+	for i := 0; i < n; i++ {
+		der = append(der, byte(n))
+	}
+	encrypted := make([]byte, len(der))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, der)
+
+	prfParams, err := asn1.Marshal(asn1.NullRawValue)
+	if err != nil {
+		return safeBag{}, errors.Wrap(err, "error encoding parameters")
+	}
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: DefaultIterations,
+		PRF:        algorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: fullBytes(prfParams)},
+	})
+	if err != nil {
+		return safeBag{}, errors.Wrap(err, "error encoding parameters")
+	}
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return safeBag{}, errors.Wrap(err, "error encoding parameters")
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: algorithmIdentifier{Algorithm: oidPBKDF2, Parameters: fullBytes(kdfParams)},
+		EncryptionScheme:  algorithmIdentifier{Algorithm: oidAES256CBC, Parameters: fullBytes(ivParams)},
+	})
+	if err != nil {
+		return safeBag{}, errors.Wrap(err, "error encoding parameters")
+	}
+	b, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     algorithmIdentifier{Algorithm: oidPBES2, Parameters: fullBytes(params)},
+		EncryptedData: encrypted,
+	})
+	if err != nil {
+		return safeBag{}, errors.Wrap(err, "error encoding private key")
+	}
+	return safeBag{ID: oidPKCS8ShroudedKeyBag, Value: explicit(b)}, nil
+}
+
+// computeMac returns the HMAC-SHA256 of the given data with the key derived
+// from the password as defined in RFC 7292, appendix B.
+func computeMac(data []byte, password string) (macData, error) {
+	salt, err := randomBytes(saltSize)
+	if err != nil {
+		return macData{}, err
+	}
+	key := deriveKey(bmpString(password), salt, DefaultIterations, 3, sha256.Size)
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+
+	null, err := asn1.Marshal(asn1.NullRawValue)
+	if err != nil {
+		return macData{}, errors.Wrap(err, "error encoding parameters")
+	}
+	return macData{
+		Mac: digestInfo{
+			Algorithm: algorithmIdentifier{Algorithm: oidSHA256, Parameters: fullBytes(null)},
+			Digest:    h.Sum(nil),
+		},
+		MacSalt:    salt,
+		Iterations: DefaultIterations,
+	}, nil
+}
+
+// deriveKey implements the key derivation function of RFC 7292, appendix B.2,
+// using SHA-256.
+func deriveKey(password, salt []byte, iterations int, id byte, size int) []byte {
+	const v = sha256.BlockSize
+
+	fill := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+		out := make([]byte, (len(b)+v-1)/v*v)
+		for i := range out {
+			out[i] = b[i%len(b)]
+		}
+		return out
+	}
+
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+	in := append(fill(salt), fill(password)...)
+
+	var out []byte
+	for len(out) < size {
+		h := sha256.New()
+		h.Write(d)
+		h.Write(in)
+		a := h.Sum(nil)
+		for j := 1; j < iterations; j++ {
+			sum := sha256.Sum256(a)
+			a = sum[:]
+		}
+		out = append(out, a...)
+
+		// Ij = (Ij + B + 1) mod 2^(v*8) for each block of I.
+		b := fill(a)
+		for j := 0; j < len(in); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				carry += int(in[j+k]) + int(b[k])
+				in[j+k] = byte(carry)
+				carry >>= 8
+			}
+		}
+	}
+	return out[:size]
+}
+
+// bmpString returns the password as a null terminated BMPString.
+func bmpString(s string) []byte {
+	runes := utf16.Encode([]rune(s))
+	b := make([]byte, 0, 2*len(runes)+2)
+	for _, r := range runes {
+		b = append(b, byte(r>>8), byte(r))
+	}
+	return append(b, 0, 0)
+}
+
+func fullBytes(der []byte) asn1.RawValue {
+	return asn1.RawValue{FullBytes: der}
+}
+
+// explicit returns the given DER value with the [0] EXPLICIT tag.
+func explicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "error generating random bytes")
+	}
+	return b, nil
+}
@@ -0,0 +1,119 @@
+package pkcs12
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestCertificate(t *testing.T) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test.example.com"},
+		DNSNames:     []string{"test.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, crt
+}
+
+// Test vectors of the key derivation function with SHA-256, computed with the
+// PKCS12KDF of OpenSSL, which uses the password as it is instead of the
+// BMPString:
+//
+//	openssl kdf -keylen 24 -kdfopt digest:SHA2-256 -kdfopt pass:smeg \
+//	  -kdfopt hexsalt:0a58cf64530d823f -kdfopt iter:1 -kdfopt id:1 PKCS12KDF
+func Test_deriveKey(t *testing.T) {
+	tests := []struct {
+		password   string
+		salt       string
+		iterations int
+		id         byte
+		want       string
+	}{
+		{"smeg", "0a58cf64530d823f", 1, 1, "c6c3e6456ae5b69c048dd8900b8e98a874f1a163244c69ba"},
+		{"queeg", "05dec959acff72f7", 1000, 3, "3e59be2cf6e323aa51495ac840d4bf2a070e9311f742044179f2f755c88ba9564e18f618edeb4df582ac283854a3022dbc6f4769885bc7bbf96e8cb97ba5076ccc0fb56c839e8f8d82978c52f1b47511"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			salt, err := hex.DecodeString(tt.salt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := hex.DecodeString(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := deriveKey([]byte(tt.password), salt, tt.iterations, tt.id, len(want)); !bytes.Equal(got, want) {
+				t.Errorf("deriveKey() = %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func Test_bmpString(t *testing.T) {
+	if got := bmpString("Beavis"); !bytes.Equal(got, []byte{0, 'B', 0, 'e', 0, 'a', 0, 'v', 0, 'i', 0, 's', 0, 0}) {
+		t.Errorf("bmpString() = %x", got)
+	}
+	if got := bmpString(""); !bytes.Equal(got, []byte{0, 0}) {
+		t.Errorf("bmpString() = %x", got)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	key, crt := newTestCertificate(t)
+	b, err := Encode(key, []*x509.Certificate{crt}, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the integrity of the file with the password.
+	var p pfx
+	if rest, err := asn1.Unmarshal(b, &p); err != nil || len(rest) > 0 {
+		t.Fatalf("asn1.Unmarshal() error = %v", err)
+	}
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(p.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		t.Fatal(err)
+	}
+	key3 := deriveKey(bmpString("password"), p.MacData.MacSalt, p.MacData.Iterations, 3, sha256.Size)
+	h := hmac.New(sha256.New, key3)
+	h.Write(authSafe)
+	if !hmac.Equal(h.Sum(nil), p.MacData.Mac.Digest) {
+		t.Error("Encode() mac does not match")
+	}
+	var infos []contentInfo
+	if _, err := asn1.Unmarshal(authSafe, &infos); err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 {
+		t.Errorf("Encode() authenticated safe has %d contents, want 2", len(infos))
+	}
+
+	if _, err := Encode(key, nil, "password"); err == nil {
+		t.Error("Encode() error = nil")
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix || zos
+
+package secret
+
+// alloc allocates the memory of a buffer. Memory locking is not supported on
+// this platform, so the memory is allocated in the heap.
+func alloc(n int) (mem []byte, mapped, locked bool) {
+	return make([]byte, n), false, false
+}
+
+// free releases the memory of a buffer.
+func free(mem []byte, mapped, locked bool) {}
@@ -0,0 +1,40 @@
+//go:build unix && !zos
+
+package secret
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// alloc allocates the memory of a buffer in anonymous pages outside the Go
+// heap and tries to lock them. If the pages cannot be locked, for example
+// because of RLIMIT_MEMLOCK, they're used unlocked, and if they cannot be
+// mapped the memory is allocated in the heap.
+func alloc(n int) (mem []byte, mapped, locked bool) {
+	size := roundPage(n)
+	mem, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANON)
+	if err != nil {
+		return make([]byte, size), false, false
+	}
+	return mem, true, unix.Mlock(mem) == nil
+}
+
+// free unlocks and unmaps the memory of a buffer.
+func free(mem []byte, mapped, locked bool) {
+	if locked {
+		_ = unix.Munlock(mem)
+	}
+	if mapped {
+		_ = unix.Munmap(mem)
+	}
+}
+
+func roundPage(n int) int {
+	page := os.Getpagesize()
+	if n <= 0 {
+		return page
+	}
+	return (n + page - 1) / page * page
+}
@@ -0,0 +1,132 @@
+// Package secret implements the handling of passwords and private key
+// material in memory. Secrets are kept in buffers allocated outside the Go
+// heap, so they're never moved or copied by the garbage collector, and where
+// supported the buffers are locked so they're never swapped to disk. The
+// buffers are zeroized when they're destroyed, and they're never included in
+// the output of the fmt and log packages, or in JSON documents.
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Redacted is the text used in place of a secret.
+const Redacted = "[REDACTED]"
+
+// Buffer is a buffer holding a secret.
+type Buffer struct {
+	mu     sync.Mutex
+	data   []byte
+	mem    []byte
+	mapped bool
+	locked bool
+}
+
+// Copy returns a new buffer with a copy of the given secret. The caller is
+// responsible for wiping the given slice if it owns it, and for destroying
+// the buffer once the secret is not needed. The memory of the buffer is not
+// managed by the garbage collector, and it's only released by Destroy.
+func Copy(b []byte) *Buffer {
+	mem, mapped, locked := alloc(len(b))
+	buf := &Buffer{
+		data:   mem[:len(b):len(b)],
+		mem:    mem,
+		mapped: mapped,
+		locked: locked,
+	}
+	copy(buf.data, b)
+	return buf
+}
+
+// ReadPassword reads a password from the given file. The trailing white space
+// is removed and the contents of the file are wiped once they are copied into
+// the buffer.
+func ReadPassword(filename string) (*Buffer, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", filename)
+	}
+	defer Wipe(b)
+	return Copy(bytes.TrimRightFunc(b, unicode.IsSpace)), nil
+}
+
+// Bytes returns the secret. The slice must not be used after the buffer is
+// destroyed. It returns nil if the buffer is nil or it has been destroyed.
+func (b *Buffer) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.data
+}
+
+// Len returns the length of the secret.
+func (b *Buffer) Len() int {
+	return len(b.Bytes())
+}
+
+// Locked returns if the memory of the buffer is locked.
+func (b *Buffer) Locked() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.locked
+}
+
+// Destroy wipes the secret and releases the memory of the buffer. It is safe
+// to call it more than once.
+func (b *Buffer) Destroy() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.mem == nil {
+		return
+	}
+	Wipe(b.mem)
+	free(b.mem, b.mapped, b.locked)
+	b.data, b.mem, b.mapped, b.locked = nil, nil, false, false
+}
+
+// String implements the fmt.Stringer interface. It never returns the secret.
+func (b *Buffer) String() string {
+	return Redacted
+}
+
+// GoString implements the fmt.GoStringer interface. It never returns the
+// secret.
+func (b *Buffer) GoString() string {
+	return Redacted
+}
+
+// Format implements the fmt.Formatter interface, so the secret is not printed
+// with any of the verbs.
+func (b *Buffer) Format(f fmt.State, _ rune) {
+	_, _ = f.Write([]byte(Redacted))
+}
+
+// MarshalJSON implements the json.Marshaler interface. It never returns the
+// secret.
+func (b *Buffer) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + Redacted + `"`), nil
+}
+
+// Wipe overwrites the given slice with zeros.
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	// Make sure the writes are not removed.
+	runtime.KeepAlive(b)
+}
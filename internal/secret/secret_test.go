@@ -0,0 +1,104 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	src := []byte("password")
+	buf := Copy(src)
+	if got := string(buf.Bytes()); got != "password" {
+		t.Errorf("Buffer.Bytes() = %s, want password", got)
+	}
+	if buf.Len() != 8 {
+		t.Errorf("Buffer.Len() = %d, want 8", buf.Len())
+	}
+
+	// The copy does not share memory with the source.
+	src[0] = 'P'
+	if got := string(buf.Bytes()); got != "password" {
+		t.Errorf("Buffer.Bytes() = %s, want password", got)
+	}
+
+	buf.Destroy()
+	if buf.Bytes() != nil || buf.Len() != 0 || buf.Locked() {
+		t.Error("Buffer.Destroy() did not release the buffer")
+	}
+	// Destroy is idempotent.
+	buf.Destroy()
+
+	t.Run("empty", func(t *testing.T) {
+		buf := Copy(nil)
+		defer buf.Destroy()
+		if buf.Len() != 0 {
+			t.Errorf("Buffer.Len() = %d, want 0", buf.Len())
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var buf *Buffer
+		if buf.Bytes() != nil || buf.Len() != 0 || buf.Locked() {
+			t.Error("nil Buffer is not empty")
+		}
+		buf.Destroy()
+	})
+}
+
+func TestBuffer_redacted(t *testing.T) {
+	buf := Copy([]byte("password"))
+	defer buf.Destroy()
+
+	for _, s := range []string{
+		fmt.Sprint(buf),
+		fmt.Sprintf("%s %v %+v %#v %x %q %d", buf, buf, buf, buf, buf, buf, buf),
+		fmt.Sprintf("%v", struct{ Password *Buffer }{buf}),
+	} {
+		if bytes.Contains([]byte(s), []byte("password")) {
+			t.Errorf("formatted buffer %q contains the secret", s)
+		}
+	}
+
+	b, err := json.Marshal(struct {
+		Password *Buffer `json:"password"`
+	}{buf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"password":"[REDACTED]"}`; string(b) != want {
+		t.Errorf("json.Marshal() = %s, want %s", b, want)
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "password.txt")
+	if err := os.WriteFile(filename, []byte("password\n \t"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := ReadPassword(filename)
+	if err != nil {
+		t.Fatalf("ReadPassword() error = %v", err)
+	}
+	defer buf.Destroy()
+	if got := string(buf.Bytes()); got != "password" {
+		t.Errorf("ReadPassword() = %q, want password", got)
+	}
+
+	if _, err := ReadPassword(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("ReadPassword() error = nil, want an error")
+	}
+}
+
+func TestWipe(t *testing.T) {
+	b := []byte("password")
+	Wipe(b)
+	if !bytes.Equal(b, make([]byte, 8)) {
+		t.Errorf("Wipe() = %v, want zeros", b)
+	}
+	Wipe(nil)
+}
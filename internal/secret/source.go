@@ -0,0 +1,207 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Schemes of the references to the secrets. A reference is a string like
+// "env:STEP_CA_PASSWORD" or "vault:secret/data/step-ca#password", where the
+// optional fragment selects a field of a secret encoded as a JSON object.
+const (
+	// FileScheme reads the secret from a file, the trailing white space is
+	// removed, e.g. "file:/run/secrets/password".
+	FileScheme = "file"
+	// EnvScheme reads the secret from an environment variable, e.g.
+	// "env:STEP_CA_PASSWORD".
+	EnvScheme = "env"
+	// VaultScheme reads the secret from HashiCorp Vault, the client is
+	// configured with the VAULT_* environment variables, e.g.
+	// "vault:secret/data/step-ca#password".
+	VaultScheme = "vault"
+	// AWSScheme reads the secret from AWS Secrets Manager using the default
+	// credentials, e.g. "awssm:step-ca/password".
+	AWSScheme = "awssm"
+	// GCPScheme reads the secret from Google Cloud Secret Manager using the
+	// application default credentials, e.g.
+	// "gcpsm:projects/my-project/secrets/password/versions/latest".
+	GCPScheme = "gcpsm"
+)
+
+// Source is the interface implemented by the stores of secrets.
+type Source interface {
+	// Secret returns the secret with the given name. The caller is
+	// responsible for destroying the returned buffer.
+	Secret(ctx context.Context, name string) (*Buffer, error)
+}
+
+// NewSourceFunc is the function that creates a source. Sources are created the
+// first time a secret with their scheme is requested.
+type NewSourceFunc func(ctx context.Context) (Source, error)
+
+var (
+	sourcesMu  sync.Mutex
+	newSources = map[string]NewSourceFunc{
+		FileScheme:  func(context.Context) (Source, error) { return fileSource{}, nil },
+		EnvScheme:   func(context.Context) (Source, error) { return envSource{}, nil },
+		VaultScheme: newVaultSource,
+		AWSScheme:   newAWSSource,
+		GCPScheme:   newGCPSource,
+	}
+	sources = map[string]Source{}
+)
+
+// RegisterSource registers the function that creates the source of the given
+// scheme, replacing the existing one. A nil function removes the scheme.
+func RegisterSource(scheme string, fn NewSourceFunc) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if fn == nil {
+		delete(newSources, scheme)
+	} else {
+		newSources[scheme] = fn
+	}
+	delete(sources, scheme)
+}
+
+func getSource(ctx context.Context, scheme string) (Source, error) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if s, ok := sources[scheme]; ok {
+		return s, nil
+	}
+	fn, ok := newSources[scheme]
+	if !ok {
+		return nil, errors.Errorf("secret source %q is not supported", scheme)
+	}
+	s, err := fn(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating secret source %q", scheme)
+	}
+	sources[scheme] = s
+	return s, nil
+}
+
+// parseReference returns the scheme, the name and the field of a reference.
+// It returns false if the string does not start with a registered scheme.
+func parseReference(ref string) (scheme, name, field string, ok bool) {
+	i := strings.IndexByte(ref, ':')
+	if i <= 0 {
+		return "", "", "", false
+	}
+	scheme, name = ref[:i], ref[i+1:]
+	sourcesMu.Lock()
+	_, ok = newSources[scheme]
+	sourcesMu.Unlock()
+	if !ok {
+		return "", "", "", false
+	}
+	if j := strings.LastIndexByte(name, '#'); j >= 0 {
+		name, field = name[:j], name[j+1:]
+	}
+	return scheme, name, field, true
+}
+
+// IsReference returns if the given string is a reference to a secret, a
+// string that starts with the scheme of a registered source.
+func IsReference(s string) bool {
+	_, _, _, ok := parseReference(s)
+	return ok
+}
+
+// Get returns the secret of the given reference. The caller is responsible
+// for destroying the returned buffer.
+func Get(ctx context.Context, ref string) (*Buffer, error) {
+	scheme, name, field, ok := parseReference(ref)
+	if !ok {
+		return nil, errors.Errorf("%q is not a secret reference", ref)
+	}
+	if name == "" {
+		return nil, errors.Errorf("secret reference %q does not have a name", ref)
+	}
+	s, err := getSource(ctx, scheme)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := s.Secret(ctx, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading secret %s:%s", scheme, name)
+	}
+	if field == "" {
+		return buf, nil
+	}
+	defer buf.Destroy()
+	return jsonField(buf.Bytes(), field, scheme+":"+name)
+}
+
+// Load returns the secret of the given reference, or the contents of the file
+// if it is not a reference. It's used for the flags that used to take only
+// files with passwords.
+func Load(ctx context.Context, ref string) (*Buffer, error) {
+	if IsReference(ref) {
+		return Get(ctx, ref)
+	}
+	return ReadPassword(ref)
+}
+
+// Resolve returns the secret of the given reference, or the string itself if
+// it is not a reference, so the values of the configuration can be inline or
+// kept in a source. The returned string is not kept in locked memory.
+func Resolve(ctx context.Context, s string) (string, error) {
+	if !IsReference(s) {
+		return s, nil
+	}
+	buf, err := Get(ctx, s)
+	if err != nil {
+		return "", err
+	}
+	defer buf.Destroy()
+	return string(buf.Bytes()), nil
+}
+
+// jsonField returns the value of a field of a secret encoded as a JSON object.
+// String values are returned as they are, other values are returned in JSON.
+func jsonField(b []byte, field, name string) (*Buffer, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errors.Errorf("secret %s is not a JSON object", name)
+	}
+	defer func() {
+		for _, v := range m {
+			Wipe(v)
+		}
+	}()
+	v, ok := m[field]
+	if !ok {
+		return nil, errors.Errorf("secret %s does not have the field %q", name, field)
+	}
+	var str string
+	if err := json.Unmarshal(v, &str); err == nil {
+		sb := []byte(str)
+		defer Wipe(sb)
+		return Copy(sb), nil
+	}
+	return Copy(v), nil
+}
+
+type fileSource struct{}
+
+func (fileSource) Secret(_ context.Context, name string) (*Buffer, error) {
+	return ReadPassword(name)
+}
+
+type envSource struct{}
+
+func (envSource) Secret(_ context.Context, name string) (*Buffer, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, errors.Errorf("environment variable %s is not set", name)
+	}
+	return Copy(bytes.TrimRight([]byte(v), "\r\n")), nil
+}
@@ -0,0 +1,142 @@
+package secret
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	vault "github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+)
+
+// vaultSource reads the secrets from the KV secrets engines of Vault. The
+// data of a secret is returned as a JSON object, a field of the reference
+// selects one of its values.
+type vaultSource struct {
+	client *vault.Client
+}
+
+func newVaultSource(context.Context) (Source, error) {
+	client, err := vault.NewClient(vault.DefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	return &vaultSource{client: client}, nil
+}
+
+func (s *vaultSource) Secret(ctx context.Context, name string) (*Buffer, error) {
+	sec, err := s.client.Logical().ReadWithContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if sec == nil || sec.Data == nil {
+		return nil, errors.New("secret not found")
+	}
+	data := sec.Data
+	// The version 2 of the KV engine keeps the values in data.data.
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding secret")
+	}
+	defer Wipe(b)
+	return Copy(b), nil
+}
+
+// awsSource reads the secrets from AWS Secrets Manager. The name is the name
+// or the ARN of the secret.
+type awsSource struct {
+	client *secretsmanager.SecretsManager
+}
+
+func newAWSSource(context.Context) (Source, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &awsSource{client: secretsmanager.New(sess)}, nil
+}
+
+func (s *awsSource) Secret(ctx context.Context, name string) (*Buffer, error) {
+	out, err := s.client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.SecretString != nil {
+		b := []byte(*out.SecretString)
+		defer Wipe(b)
+		return Copy(b), nil
+	}
+	defer Wipe(out.SecretBinary)
+	return Copy(out.SecretBinary), nil
+}
+
+// gcpSecretManagerURL is the endpoint of the Google Cloud Secret Manager API.
+var gcpSecretManagerURL = "https://secretmanager.googleapis.com/v1/"
+
+// gcpSource reads the secrets from Google Cloud Secret Manager. The name is
+// the resource name of a version of the secret.
+type gcpSource struct {
+	client *http.Client
+}
+
+func newGCPSource(ctx context.Context) (Source, error) {
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return nil, err
+	}
+	return &gcpSource{client: client}, nil
+}
+
+func (s *gcpSource) Secret(ctx context.Context, name string) (*Buffer, error) {
+	u, err := url.Parse(gcpSecretManagerURL + name + ":access")
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing secret name")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading response")
+	}
+	defer Wipe(b)
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("secret manager responded with status %d", resp.StatusCode)
+	}
+	var body struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(b, &body); err != nil {
+		return nil, errors.Wrap(err, "error decoding response")
+	}
+	data, err := base64.StdEncoding.DecodeString(body.Payload.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding secret")
+	}
+	defer Wipe(data)
+	return Copy(data), nil
+}
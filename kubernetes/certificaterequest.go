@@ -0,0 +1,165 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// certificateRequestsPath is the path of the cert-manager CertificateRequest
+// resources in all the namespaces.
+const certificateRequestsPath = "/apis/cert-manager.io/v1/certificaterequests"
+
+// certificateRequestsWatchTimeout is the duration of each watch of the
+// certificate requests. Every new watch lists again all the requests, so the
+// requests that could not be signed are retried.
+var certificateRequestsWatchTimeout = 5 * time.Minute
+
+// Condition types and statuses of the cert-manager certificate requests.
+const (
+	CertificateRequestConditionReady    = "Ready"
+	CertificateRequestConditionApproved = "Approved"
+	CertificateRequestConditionDenied   = "Denied"
+	ConditionTrue                       = "True"
+	ConditionFalse                      = "False"
+)
+
+// CertificateRequest is a cert-manager.io/v1 CertificateRequest. Only the
+// properties used by the CA are defined.
+type CertificateRequest struct {
+	APIVersion string                   `json:"apiVersion,omitempty"`
+	Kind       string                   `json:"kind,omitempty"`
+	Metadata   Metadata                 `json:"metadata"`
+	Spec       CertificateRequestSpec   `json:"spec"`
+	Status     CertificateRequestStatus `json:"status"`
+}
+
+// CertificateRequestSpec is the spec of a cert-manager CertificateRequest.
+type CertificateRequestSpec struct {
+	// Request is the PEM encoded certificate signing request.
+	Request []byte `json:"request"`
+	// IssuerRef is the reference to the issuer that signs the request.
+	IssuerRef IssuerRef `json:"issuerRef"`
+	// Duration is the requested validity of the certificate, e.g. "2160h0m0s".
+	Duration string `json:"duration,omitempty"`
+	// IsCA requests a CA certificate.
+	IsCA bool `json:"isCA,omitempty"`
+	// Usages are the requested key usages.
+	Usages []string `json:"usages,omitempty"`
+}
+
+// IssuerRef is the reference to the issuer of a cert-manager
+// CertificateRequest.
+type IssuerRef struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind,omitempty"`
+	Group string `json:"group,omitempty"`
+}
+
+// CertificateRequestStatus is the status of a cert-manager
+// CertificateRequest.
+type CertificateRequestStatus struct {
+	Conditions []CertificateRequestCondition `json:"conditions,omitempty"`
+	// Certificate is the PEM encoded certificate followed by the
+	// intermediates.
+	Certificate []byte `json:"certificate,omitempty"`
+	// CA is the PEM encoded root certificate.
+	CA          []byte     `json:"ca,omitempty"`
+	FailureTime *time.Time `json:"failureTime,omitempty"`
+}
+
+// CertificateRequestCondition is a condition of the status of a cert-manager
+// CertificateRequest.
+type CertificateRequestCondition struct {
+	Type               string     `json:"type"`
+	Status             string     `json:"status"`
+	Reason             string     `json:"reason,omitempty"`
+	Message            string     `json:"message,omitempty"`
+	LastTransitionTime *time.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// GetCondition returns the condition with the given type, or nil if the
+// request does not have it.
+func (cr *CertificateRequest) GetCondition(typ string) *CertificateRequestCondition {
+	for i := range cr.Status.Conditions {
+		if cr.Status.Conditions[i].Type == typ {
+			return &cr.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// HasCondition returns true if the request has the condition with the given
+// type and status.
+func (cr *CertificateRequest) HasCondition(typ, status string) bool {
+	c := cr.GetCondition(typ)
+	return c != nil && c.Status == status
+}
+
+// SetCondition adds or replaces the condition with the given type. The
+// transition time is only updated if the status changes.
+func (cr *CertificateRequest) SetCondition(typ, status, reason, message string, now time.Time) {
+	if c := cr.GetCondition(typ); c != nil {
+		if c.Status != status {
+			c.LastTransitionTime = &now
+		}
+		c.Status, c.Reason, c.Message = status, reason, message
+		return
+	}
+	cr.Status.Conditions = append(cr.Status.Conditions, CertificateRequestCondition{
+		Type:               typ,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: &now,
+	})
+}
+
+// WatchCertificateRequests calls the given function with the cert-manager
+// certificate requests of all the namespaces, every time they are added or
+// modified, and with all of them every time the watch is restarted. It
+// blocks until the context is done.
+func (c *Client) WatchCertificateRequests(ctx context.Context, fn func(*CertificateRequest)) {
+	query := url.Values{
+		"watch":          []string{"true"},
+		"timeoutSeconds": []string{strconv.Itoa(int(certificateRequestsWatchTimeout.Seconds()))},
+	}
+	for {
+		err := c.watch(ctx, certificateRequestsPath, query, func(obj json.RawMessage) error {
+			var cr CertificateRequest
+			if err := json.Unmarshal(obj, &cr); err != nil {
+				return errors.Wrap(err, "error decoding certificate request")
+			}
+			fn(&cr)
+			return nil
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("error watching certificate requests: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRetryDelay):
+		}
+	}
+}
+
+// UpdateCertificateRequestStatus replaces the status of the given
+// certificate request. It fails if the request has been modified since it
+// was read.
+func (c *Client) UpdateCertificateRequestStatus(ctx context.Context, cr *CertificateRequest) error {
+	cr.APIVersion, cr.Kind = "cert-manager.io/v1", "CertificateRequest"
+	p := "/apis/cert-manager.io/v1/namespaces/" + url.PathEscape(cr.Metadata.Namespace) +
+		"/certificaterequests/" + url.PathEscape(cr.Metadata.Name) + "/status"
+	resp, err := c.do(ctx, http.MethodPut, p, nil, cr)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,106 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCertificateRequest_SetCondition(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	t1 := t0.Add(time.Minute)
+
+	cr := new(CertificateRequest)
+	cr.SetCondition(CertificateRequestConditionReady, ConditionFalse, "Pending", "pending", t0)
+	cr.SetCondition(CertificateRequestConditionReady, ConditionFalse, "Pending", "still pending", t1)
+	if c := cr.GetCondition(CertificateRequestConditionReady); c == nil || c.Message != "still pending" || !c.LastTransitionTime.Equal(t0) {
+		t.Errorf("CertificateRequest.SetCondition() = %+v", c)
+	}
+	cr.SetCondition(CertificateRequestConditionReady, ConditionTrue, "Issued", "issued", t1)
+	if len(cr.Status.Conditions) != 1 || !cr.HasCondition(CertificateRequestConditionReady, ConditionTrue) || !cr.Status.Conditions[0].LastTransitionTime.Equal(t1) {
+		t.Errorf("CertificateRequest.SetCondition() = %+v", cr.Status.Conditions)
+	}
+	if cr.HasCondition(CertificateRequestConditionApproved, ConditionTrue) {
+		t.Error("CertificateRequest.HasCondition() = true, want false")
+	}
+}
+
+func TestClient_CertificateRequests(t *testing.T) {
+	tmp := watchRetryDelay
+	t.Cleanup(func() { watchRetryDelay = tmp })
+	watchRetryDelay = 10 * time.Millisecond
+
+	updated := make(chan *CertificateRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == certificateRequestsPath:
+			if r.URL.Query().Get("watch") != "true" || r.URL.Query().Get("timeoutSeconds") != "300" {
+				http.Error(w, `{"message":"bad request"}`, http.StatusBadRequest)
+				return
+			}
+			b, _ := json.Marshal(CertificateRequest{
+				Metadata: Metadata{Name: "web", Namespace: "default", ResourceVersion: "1"},
+				Spec:     CertificateRequestSpec{Request: []byte("csr"), IssuerRef: IssuerRef{Name: "step-ca", Group: "ca.step.sm"}},
+			})
+			ev, _ := json.Marshal(watchEvent{Type: "ADDED", Object: b})
+			fmt.Fprintf(w, "%s\n", ev)
+		case r.Method == http.MethodPut && r.URL.Path == "/apis/cert-manager.io/v1/namespaces/default/certificaterequests/web/status":
+			var cr CertificateRequest
+			if err := json.NewDecoder(r.Body).Decode(&cr); err != nil {
+				http.Error(w, `{"message":"bad request"}`, http.StatusBadRequest)
+				return
+			}
+			if cr.Metadata.ResourceVersion != "1" {
+				http.Error(w, `{"message":"conflict"}`, http.StatusConflict)
+				return
+			}
+			updated <- &cr
+			json.NewEncoder(w).Encode(cr)
+		default:
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c, err := NewClient(srv.URL, "", "default", srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got := make(chan *CertificateRequest, 10)
+	go c.WatchCertificateRequests(ctx, func(cr *CertificateRequest) {
+		got <- cr
+	})
+
+	// The requests are listed again on every watch.
+	var cr *CertificateRequest
+	for i := 0; i < 2; i++ {
+		select {
+		case cr = <-got:
+			if cr.Metadata.Name != "web" || string(cr.Spec.Request) != "csr" || cr.Spec.IssuerRef.Group != "ca.step.sm" {
+				t.Fatalf("Client.WatchCertificateRequests() request = %+v", cr)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Client.WatchCertificateRequests() timeout")
+		}
+	}
+
+	cr.Status.Certificate = []byte("crt")
+	cr.SetCondition(CertificateRequestConditionReady, ConditionTrue, "Issued", "Certificate issued", time.Now())
+	if err := c.UpdateCertificateRequestStatus(ctx, cr); err != nil {
+		t.Fatalf("Client.UpdateCertificateRequestStatus() error = %v", err)
+	}
+	if u := <-updated; u.Kind != "CertificateRequest" || string(u.Status.Certificate) != "crt" || !u.HasCondition(CertificateRequestConditionReady, ConditionTrue) {
+		t.Errorf("Client.UpdateCertificateRequestStatus() request = %+v", u)
+	}
+
+	cr.Metadata.ResourceVersion = "2"
+	if err := c.UpdateCertificateRequestStatus(ctx, cr); err == nil {
+		t.Error("Client.UpdateCertificateRequestStatus() error = nil, want conflict")
+	}
+}
@@ -0,0 +1,290 @@
+// Package kubernetes implements a minimal client of the Kubernetes API used to
+// read, write and watch the secrets that store the credentials of the CA when
+// it runs inside a cluster, and to sign the cert-manager certificate requests.
+package kubernetes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ServiceAccountDir is the directory where the service account credentials are
+// mounted in a pod.
+var ServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// ErrNotFound is returned when a secret does not exist.
+var ErrNotFound = errors.New("secret not found")
+
+// watchRetryDelay is the time to wait before restarting a failed watch.
+var watchRetryDelay = 5 * time.Second
+
+// Secret is a Kubernetes v1 Secret. Only the properties used by the CA are
+// defined.
+type Secret struct {
+	APIVersion string            `json:"apiVersion,omitempty"`
+	Kind       string            `json:"kind,omitempty"`
+	Metadata   Metadata          `json:"metadata"`
+	Type       string            `json:"type,omitempty"`
+	Data       map[string][]byte `json:"data,omitempty"`
+}
+
+// Metadata is the object metadata of a Kubernetes resource.
+type Metadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+type status struct {
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+	Code    int    `json:"code"`
+}
+
+// Client is a client of the Kubernetes API.
+type Client struct {
+	baseURL   *url.URL
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// NewClient creates a new client for the API server in the given URL. The
+// given token, if not empty, is sent as a bearer token, and the namespace is
+// the one used by default.
+func NewClient(baseURL, token, namespace string, client *http.Client) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", baseURL)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{
+		baseURL:   u,
+		token:     token,
+		namespace: namespace,
+		client:    client,
+	}, nil
+}
+
+// NewInClusterClient creates a new client using the service account of the
+// pod where the CA is running.
+func NewInClusterClient() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("error creating kubernetes client: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be defined")
+	}
+
+	token, err := os.ReadFile(filepath.Join(ServiceAccountDir, "token"))
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading service account token")
+	}
+	namespace, err := os.ReadFile(filepath.Join(ServiceAccountDir, "namespace"))
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading service account namespace")
+	}
+	caPEM, err := os.ReadFile(filepath.Join(ServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading service account ca.crt")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("error parsing service account ca.crt")
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	return NewClient("https://"+net.JoinHostPort(host, port),
+		strings.TrimSpace(string(token)), strings.TrimSpace(string(namespace)),
+		&http.Client{Transport: tr})
+}
+
+// Namespace returns the default namespace of the client.
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// GetSecret returns the secret with the given namespace and name. If the
+// namespace is empty the default one is used. It returns ErrNotFound if the
+// secret does not exist.
+func (c *Client) GetSecret(ctx context.Context, namespace, name string) (*Secret, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.secretPath(namespace, name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var s Secret
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return nil, errors.Wrap(err, "error decoding secret")
+	}
+	return &s, nil
+}
+
+// ApplySecret creates the given secret, or replaces its data if it already
+// exists.
+func (c *Client) ApplySecret(ctx context.Context, s *Secret) error {
+	s.APIVersion, s.Kind = "v1", "Secret"
+	if s.Metadata.Namespace == "" {
+		s.Metadata.Namespace = c.namespace
+	}
+
+	current, err := c.GetSecret(ctx, s.Metadata.Namespace, s.Metadata.Name)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		s.Metadata.ResourceVersion = ""
+		resp, err := c.do(ctx, http.MethodPost, c.secretPath(s.Metadata.Namespace, ""), nil, s)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	case err != nil:
+		return err
+	default:
+		s.Metadata.ResourceVersion = current.Metadata.ResourceVersion
+		resp, err := c.do(ctx, http.MethodPut, c.secretPath(s.Metadata.Namespace, s.Metadata.Name), nil, s)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+}
+
+// WatchSecret calls the given function every time the secret with the given
+// namespace and name is added or modified. It blocks until the context is
+// done, restarting the watch if the connection fails.
+func (c *Client) WatchSecret(ctx context.Context, namespace, name string, fn func(*Secret)) {
+	for {
+		if err := c.watchSecret(ctx, namespace, name, fn); err != nil && ctx.Err() == nil {
+			log.Printf("error watching secret %s: %v", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRetryDelay):
+		}
+	}
+}
+
+func (c *Client) watchSecret(ctx context.Context, namespace, name string, fn func(*Secret)) error {
+	query := url.Values{
+		"watch":         []string{"true"},
+		"fieldSelector": []string{"metadata.name=" + name},
+	}
+	return c.watch(ctx, c.secretPath(namespace, ""), query, func(obj json.RawMessage) error {
+		var s Secret
+		if err := json.Unmarshal(obj, &s); err != nil {
+			return errors.Wrap(err, "error decoding secret")
+		}
+		fn(&s)
+		return nil
+	})
+}
+
+// watch calls the given function with the objects added or modified in the
+// watch of the given path. It returns when the API server closes the watch.
+func (c *Client) watch(ctx context.Context, path string, query url.Values, fn func(json.RawMessage) error) error {
+	resp, err := c.do(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var ev watchEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			return errors.Wrap(err, "error decoding watch event")
+		}
+		switch ev.Type {
+		case "ADDED", "MODIFIED":
+			if err := fn(ev.Object); err != nil {
+				return err
+			}
+		case "ERROR":
+			var st status
+			_ = json.Unmarshal(ev.Object, &st)
+			return errors.Errorf("watch error: %s", st.Message)
+		}
+	}
+	// The API server closes watches periodically.
+	return scanner.Err()
+}
+
+func (c *Client) secretPath(namespace, name string) string {
+	if namespace == "" {
+		namespace = c.namespace
+	}
+	p := "/api/v1/namespaces/" + url.PathEscape(namespace) + "/secrets"
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	return p
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	u := c.baseURL.ResolveReference(&url.URL{Path: path, RawQuery: query.Encode()})
+
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, errors.Wrap(err, "error marshaling request")
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating request")
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error doing %s %s", method, u.Path)
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound && method == http.MethodGet {
+			return nil, ErrNotFound
+		}
+		var st status
+		if err := json.NewDecoder(resp.Body).Decode(&st); err != nil || st.Message == "" {
+			return nil, errors.Errorf("error doing %s %s: %s", method, u.Path, resp.Status)
+		}
+		return nil, errors.Errorf("error doing %s %s: %s", method, u.Path, st.Message)
+	}
+	return resp, nil
+}
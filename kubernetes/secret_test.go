@@ -0,0 +1,183 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPI is a minimal Kubernetes API server that stores secrets in memory.
+type fakeAPI struct {
+	mu      sync.Mutex
+	secrets map[string]*Secret
+	version int
+	events  chan watchEvent
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, *Client) {
+	t.Helper()
+	f := &fakeAPI{secrets: map[string]*Secret{}, events: make(chan watchEvent, 10)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	c, err := NewClient(srv.URL, "the-token", "default", srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, c
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer the-token" {
+		http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/namespaces/"), "/")
+	key := parts[0] + "/"
+	if len(parts) > 2 {
+		key += parts[2]
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Query().Get("watch") == "true":
+		f.mu.Unlock()
+		defer f.mu.Lock()
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case ev := <-f.events:
+				b, _ := json.Marshal(ev)
+				fmt.Fprintf(w, "%s\n", b)
+				w.(http.Flusher).Flush()
+			}
+		}
+	case r.Method == http.MethodGet:
+		s, ok := f.secrets[key]
+		if !ok {
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(s)
+	case r.Method == http.MethodPost, r.Method == http.MethodPut:
+		var s Secret
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, `{"message":"bad request"}`, http.StatusBadRequest)
+			return
+		}
+		key = parts[0] + "/" + s.Metadata.Name
+		if current, ok := f.secrets[key]; ok && (r.Method == http.MethodPost || current.Metadata.ResourceVersion != s.Metadata.ResourceVersion) {
+			http.Error(w, `{"message":"conflict"}`, http.StatusConflict)
+			return
+		}
+		f.version++
+		s.Metadata.ResourceVersion = fmt.Sprint(f.version)
+		f.secrets[key] = &s
+		json.NewEncoder(w).Encode(s)
+	default:
+		http.Error(w, `{"message":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestClient_ApplySecret(t *testing.T) {
+	f, c := newFakeAPI(t)
+	ctx := context.Background()
+
+	if _, err := c.GetSecret(ctx, "", "foo"); err != ErrNotFound {
+		t.Fatalf("Client.GetSecret() error = %v, want %v", err, ErrNotFound)
+	}
+
+	// Create
+	if err := c.ApplySecret(ctx, &Secret{
+		Metadata: Metadata{Name: "foo"},
+		Data:     map[string][]byte{"key": []byte("value")},
+	}); err != nil {
+		t.Fatalf("Client.ApplySecret() error = %v", err)
+	}
+	// Update
+	if err := c.ApplySecret(ctx, &Secret{
+		Metadata: Metadata{Name: "foo"},
+		Data:     map[string][]byte{"key": []byte("new-value")},
+	}); err != nil {
+		t.Fatalf("Client.ApplySecret() error = %v", err)
+	}
+
+	got, err := c.GetSecret(ctx, "default", "foo")
+	if err != nil {
+		t.Fatalf("Client.GetSecret() error = %v", err)
+	}
+	if !reflect.DeepEqual(got.Data, map[string][]byte{"key": []byte("new-value")}) {
+		t.Errorf("Client.GetSecret() data = %v", got.Data)
+	}
+	if got.Metadata.Namespace != "default" || got.Metadata.ResourceVersion != "2" || len(f.secrets) != 1 {
+		t.Errorf("Client.GetSecret() metadata = %v", got.Metadata)
+	}
+}
+
+func TestClient_errors(t *testing.T) {
+	_, c := newFakeAPI(t)
+	c.token = "bad-token"
+
+	_, err := c.GetSecret(context.Background(), "", "foo")
+	if err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("Client.GetSecret() error = %v, want unauthorized", err)
+	}
+	if err := c.ApplySecret(context.Background(), &Secret{Metadata: Metadata{Name: "foo"}}); err == nil {
+		t.Error("Client.ApplySecret() error = nil, want error")
+	}
+}
+
+func TestClient_WatchSecret(t *testing.T) {
+	f, c := newFakeAPI(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	got := make(chan *Secret, 1)
+	go c.WatchSecret(ctx, "", "foo", func(s *Secret) {
+		got <- s
+	})
+
+	b, err := json.Marshal(Secret{Metadata: Metadata{Name: "foo"}, Data: map[string][]byte{"key": []byte("value")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.events <- watchEvent{Type: "DELETED", Object: b}
+	f.events <- watchEvent{Type: "MODIFIED", Object: b}
+
+	select {
+	case s := <-got:
+		if s.Metadata.Name != "foo" || string(s.Data["key"]) != "value" {
+			t.Errorf("Client.WatchSecret() secret = %v", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Client.WatchSecret() timeout")
+	}
+}
+
+func TestNewInClusterClient(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if _, err := NewInClusterClient(); err == nil {
+		t.Error("NewInClusterClient() error = nil, want error")
+	}
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "443")
+	tmp := ServiceAccountDir
+	t.Cleanup(func() { ServiceAccountDir = tmp })
+	ServiceAccountDir = t.TempDir()
+	if _, err := NewInClusterClient(); err == nil {
+		t.Error("NewInClusterClient() error = nil, want error")
+	}
+}
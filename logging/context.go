@@ -14,6 +14,8 @@ const (
 	RequestIDKey key = iota
 	// UserIDKey is the context key that should store the user identifier.
 	UserIDKey
+	// TraceContextKey is the context key that should store the trace context.
+	TraceContextKey
 )
 
 // NewRequestID creates a new request id using github.com/rs/xid.
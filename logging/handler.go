@@ -30,15 +30,16 @@ type options struct {
 // NewLoggerHandler returns the given http.Handler with the logger integrated.
 func NewLoggerHandler(name string, logger *Logger, next http.Handler) http.Handler {
 	h := RequestID(logger.GetTraceHeader())
+	t := Trace()
 	onlyTraceHealthEndpoint, _ := strconv.ParseBool(os.Getenv("STEP_LOGGER_ONLY_TRACE_HEALTH_ENDPOINT"))
-	return h(&LoggerHandler{
+	return h(t(&LoggerHandler{
 		name:   name,
 		logger: logger.GetImpl(),
 		options: options{
 			onlyTraceHealthEndpoint: onlyTraceHealthEndpoint,
 		},
 		next: next,
-	})
+	}))
 }
 
 // ServeHTTP implements the http.Handler and call to the handler to log with a
@@ -54,7 +55,7 @@ func (l *LoggerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // writeEntry writes to the Logger writer the request information in the logger.
 func (l *LoggerHandler) writeEntry(w ResponseLogger, r *http.Request, t time.Time, d time.Duration) {
-	var reqID, user string
+	var reqID, user, traceID string
 
 	ctx := r.Context()
 	if v, ok := ctx.Value(RequestIDKey).(string); ok && v != "" {
@@ -63,6 +64,9 @@ func (l *LoggerHandler) writeEntry(w ResponseLogger, r *http.Request, t time.Tim
 	if v, ok := ctx.Value(UserIDKey).(string); ok && v != "" {
 		user = v
 	}
+	if v, ok := GetTraceContext(ctx); ok {
+		traceID = v.TraceID
+	}
 
 	// Remote hostname
 	addr, _, err := net.SplitHostPort(r.RemoteAddr)
@@ -86,6 +90,7 @@ func (l *LoggerHandler) writeEntry(w ResponseLogger, r *http.Request, t time.Tim
 
 	fields := logrus.Fields{
 		"request-id":     reqID,
+		"trace-id":       traceID,
 		"remote-address": addr,
 		"name":           l.name,
 		"user-id":        user,
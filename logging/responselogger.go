@@ -64,6 +64,12 @@ func (r *rwDefault) WriteHeader(code int) {
 	r.code = code
 }
 
+// Unwrap returns the original response writer, it is used by
+// http.ResponseController.
+func (r *rwDefault) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 func (r *rwDefault) Size() int {
 	return r.size
 }
@@ -94,6 +100,10 @@ func (r *rwFlusher) Flush() {
 	r.f.Flush()
 }
 
+func (r *rwFlusher) Unwrap() http.ResponseWriter {
+	return r.ResponseLogger
+}
+
 type rwHijacker struct {
 	ResponseLogger
 	h http.Hijacker
@@ -103,6 +113,10 @@ func (r *rwHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return r.h.Hijack()
 }
 
+func (r *rwHijacker) Unwrap() http.ResponseWriter {
+	return r.ResponseLogger
+}
+
 type rwPusher struct {
 	ResponseLogger
 	p http.Pusher
@@ -111,3 +125,7 @@ type rwPusher struct {
 func (rw *rwPusher) Push(target string, opts *http.PushOptions) error {
 	return rw.p.Push(target, opts)
 }
+
+func (rw *rwPusher) Unwrap() http.ResponseWriter {
+	return rw.ResponseLogger
+}
@@ -0,0 +1,165 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const (
+	// TraceParentHeader is the W3C Trace Context header with the trace id and
+	// the id of the parent span.
+	TraceParentHeader = "traceparent"
+	// TraceStateHeader is the W3C Trace Context header with vendor specific
+	// trace information.
+	TraceStateHeader = "tracestate"
+	// RequestIDHeader is the header used to send the request id to external
+	// services.
+	RequestIDHeader = "X-Request-Id"
+)
+
+// TraceContext is a W3C trace context. TraceID identifies the distributed
+// trace, and SpanID the request served by the CA, it is sent as the parent id
+// in the outbound requests.
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+	Flags   string
+	State   string
+}
+
+// NewTraceContext returns the trace context of a request with the given
+// traceparent and tracestate headers. If the traceparent is valid the trace
+// continues with a new span id, otherwise a new sampled trace is started.
+func NewTraceContext(traceParent, traceState string) *TraceContext {
+	tc := &TraceContext{
+		SpanID: newTraceID(8),
+	}
+	if traceID, flags, ok := parseTraceParent(traceParent); ok {
+		tc.TraceID, tc.Flags, tc.State = traceID, flags, traceState
+	} else {
+		tc.TraceID, tc.Flags = newTraceID(16), "01"
+	}
+	return tc
+}
+
+// TraceParent returns the value of the traceparent header for the outbound
+// requests.
+func (t *TraceContext) TraceParent() string {
+	return "00-" + t.TraceID + "-" + t.SpanID + "-" + t.Flags
+}
+
+// WithTraceContext returns a new context with the given trace context.
+func WithTraceContext(ctx context.Context, tc *TraceContext) context.Context {
+	return context.WithValue(ctx, TraceContextKey, tc)
+}
+
+// GetTraceContext returns the trace context from the context if it exists.
+func GetTraceContext(ctx context.Context) (*TraceContext, bool) {
+	v, ok := ctx.Value(TraceContextKey).(*TraceContext)
+	return v, ok && v != nil
+}
+
+// Trace returns a new middleware that reads the W3C Trace Context headers and
+// sets the trace context of the request.
+func Trace() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, req *http.Request) {
+			tc := NewTraceContext(req.Header.Get(TraceParentHeader), req.Header.Get(TraceStateHeader))
+			ctx := WithTraceContext(req.Context(), tc)
+			next.ServeHTTP(w, req.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// InjectTraceHeaders adds the trace context and the request id in the context
+// to the headers of an outbound request.
+func InjectTraceHeaders(ctx context.Context, h http.Header) {
+	if ctx == nil {
+		return
+	}
+	if tc, ok := GetTraceContext(ctx); ok {
+		h.Set(TraceParentHeader, tc.TraceParent())
+		if tc.State != "" {
+			h.Set(TraceStateHeader, tc.State)
+		}
+	}
+	if v, ok := GetRequestID(ctx); ok && v != "" {
+		h.Set(RequestIDHeader, v)
+	}
+}
+
+// NewTraceTransport returns an http.RoundTripper that adds the trace context
+// and the request id in the context of the requests to their headers. If the
+// given transport is nil, http.DefaultTransport is used.
+func NewTraceTransport(tr http.RoundTripper) http.RoundTripper {
+	if tr == nil {
+		tr = http.DefaultTransport
+	}
+	return &traceTransport{next: tr}
+}
+
+type traceTransport struct {
+	next http.RoundTripper
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h := make(http.Header)
+	InjectTraceHeaders(req.Context(), h)
+	if len(h) == 0 {
+		return t.next.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the request.
+	req = req.Clone(req.Context())
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	return t.next.RoundTrip(req)
+}
+
+// parseTraceParent parses a traceparent header as defined in
+// https://www.w3.org/TR/trace-context/#traceparent-header and returns the
+// trace id and the flags.
+func parseTraceParent(s string) (traceID, flags string, ok bool) {
+	s = strings.TrimSpace(s)
+	// Future versions can append fields after the flags.
+	if len(s) < 55 || (len(s) > 55 && s[55] != '-') {
+		return "", "", false
+	}
+	version, traceID, parentID, flags := s[0:2], s[3:35], s[36:52], s[53:55]
+	switch {
+	case s[2] != '-' || s[35] != '-' || s[52] != '-':
+		return "", "", false
+	case !isLowerHex(version) || version == "ff" || (version == "00" && len(s) != 55):
+		return "", "", false
+	case !isLowerHex(traceID) || traceID == strings.Repeat("0", 32):
+		return "", "", false
+	case !isLowerHex(parentID) || parentID == strings.Repeat("0", 16):
+		return "", "", false
+	case !isLowerHex(flags):
+		return "", "", false
+	}
+	return traceID, flags, true
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func newTraceID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// This should never happen, an all zeros id is invalid so the
+		// receivers will ignore it.
+		return strings.Repeat("0", 2*n)
+	}
+	return hex.EncodeToString(b)
+}
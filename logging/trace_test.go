@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smallstep/assert"
+)
+
+func TestNewTraceContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceParent string
+		traceState  string
+		wantTraceID string
+		wantFlags   string
+		wantState   string
+	}{
+		{"ok", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "rojo=00f067aa0ba902b7", "4bf92f3577b34da6a3ce929d0e0e4736", "01", "rojo=00f067aa0ba902b7"},
+		{"ok/not-sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", "", "4bf92f3577b34da6a3ce929d0e0e4736", "00", ""},
+		{"ok/future-version", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-foo", "", "4bf92f3577b34da6a3ce929d0e0e4736", "01", ""},
+		{"new/empty", "", "rojo=00f067aa0ba902b7", "", "01", ""},
+		{"new/version-ff", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "", "", "01", ""},
+		{"new/version-00-extra", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-foo", "", "", "01", ""},
+		{"new/uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", "", "", "01", ""},
+		{"new/zero-trace-id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "", "", "01", ""},
+		{"new/zero-parent-id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", "", "", "01", ""},
+		{"new/separator", "00-4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7-01", "", "", "01", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := NewTraceContext(tt.traceParent, tt.traceState)
+			if tt.wantTraceID != "" {
+				assert.Equals(t, tt.wantTraceID, tc.TraceID)
+			} else {
+				assert.Len(t, 32, tc.TraceID)
+				assert.NotEquals(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceID)
+			}
+			assert.Len(t, 16, tc.SpanID)
+			assert.NotEquals(t, "00f067aa0ba902b7", tc.SpanID)
+			assert.Equals(t, tt.wantFlags, tc.Flags)
+			assert.Equals(t, tt.wantState, tc.State)
+
+			// The generated traceparent must be valid.
+			traceID, flags, ok := parseTraceParent(tc.TraceParent())
+			assert.True(t, ok)
+			assert.Equals(t, tc.TraceID, traceID)
+			assert.Equals(t, tc.Flags, flags)
+		})
+	}
+}
+
+func TestInjectTraceHeaders(t *testing.T) {
+	tc := &TraceContext{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:  "00f067aa0ba902b7",
+		Flags:   "01",
+		State:   "rojo=00f067aa0ba902b7",
+	}
+	ctx := WithRequestID(WithTraceContext(context.Background(), tc), "request-id")
+
+	h := make(http.Header)
+	InjectTraceHeaders(ctx, h)
+	assert.Equals(t, http.Header{
+		"Traceparent":  []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		"Tracestate":   []string{"rojo=00f067aa0ba902b7"},
+		"X-Request-Id": []string{"request-id"},
+	}, h)
+
+	h = make(http.Header)
+	InjectTraceHeaders(context.Background(), h)
+	assert.Equals(t, http.Header{}, h)
+}
+
+func TestTraceTransport(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	// The middleware sets the trace context of the outbound requests.
+	var ctx context.Context
+	h := RequestID(defaultTraceIDHeader)(Trace()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	})))
+	req := httptest.NewRequest("GET", "/", http.NoBody)
+	req.Header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(defaultTraceIDHeader, "request-id")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	tc, ok := GetTraceContext(ctx)
+	assert.True(t, ok)
+	assert.Equals(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceID)
+
+	client := &http.Client{Transport: NewTraceTransport(nil)}
+	outReq, err := http.NewRequestWithContext(ctx, "GET", srv.URL, http.NoBody)
+	assert.FatalError(t, err)
+	resp, err := client.Do(outReq)
+	assert.FatalError(t, err)
+	resp.Body.Close()
+
+	assert.Equals(t, tc.TraceParent(), got.Get(TraceParentHeader))
+	assert.Equals(t, "request-id", got.Get(RequestIDHeader))
+	// The original request is not modified.
+	assert.Equals(t, "", outReq.Header.Get(TraceParentHeader))
+}
@@ -0,0 +1,184 @@
+package monitoring
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Modes of the expiry series of the issued certificates.
+const (
+	// PerCertificate exports one series per certificate.
+	PerCertificate = "certificate"
+	// PerSANGroup exports one series per set of SANs, with the expiration of
+	// the most recent certificate of the set.
+	PerSANGroup = "sanGroup"
+)
+
+// maxLabelLength is the maximum length of the values of the labels of the
+// expiry series, the SANs of a certificate can be arbitrarily long.
+const maxLabelLength = 256
+
+// IssuedCertificate is a certificate issued by the CA, exported in the
+// x509_cert_* series.
+type IssuedCertificate struct {
+	SerialNumber string
+	CommonName   string
+	SANs         []string
+	Provisioner  string
+	NotBefore    time.Time
+	NotAfter     time.Time
+}
+
+// CertificateSeriesOptions are the options of the expiry series of the issued
+// certificates.
+type CertificateSeriesOptions struct {
+	Mode      string
+	MaxSeries int
+	Interval  time.Duration
+}
+
+type certificateSeries struct {
+	fn   func() ([]IssuedCertificate, error)
+	opts CertificateSeriesOptions
+
+	mu        sync.Mutex
+	updatedAt time.Time
+	series    []expirySeries
+	truncated int
+}
+
+type expirySeries struct {
+	commonName  string
+	san         string
+	serial      string
+	provisioner string
+	notBefore   time.Time
+	notAfter    time.Time
+}
+
+// SetIssuedCertificates sets the function that returns the valid certificates
+// issued by the CA, exported as the x509_cert_expiry, x509_cert_enddate and
+// x509_cert_startdate gauges used by the x509_cert exporters. The list is
+// refreshed at the interval in the options, and at most MaxSeries series,
+// the ones closer to their expiration, are exported.
+func (m *Metrics) SetIssuedCertificates(fn func() ([]IssuedCertificate, error), opts CertificateSeriesOptions) {
+	m.mu.Lock()
+	m.certificates = &certificateSeries{fn: fn, opts: opts}
+	m.mu.Unlock()
+}
+
+// get returns the current series, refreshing them if they are older than the
+// interval. The previous series are kept if the list cannot be refreshed.
+func (c *certificateSeries) get(now time.Time) ([]expirySeries, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.updatedAt.IsZero() || now.Sub(c.updatedAt) >= c.opts.Interval {
+		if certs, err := c.fn(); err == nil {
+			c.series, c.truncated = newCertificateSeries(certs, c.opts)
+			c.updatedAt = now
+		}
+	}
+	return c.series, c.truncated
+}
+
+func newCertificateSeries(certs []IssuedCertificate, opts CertificateSeriesOptions) ([]expirySeries, int) {
+	var series []expirySeries
+	if opts.Mode == PerSANGroup {
+		groups := make(map[string]int)
+		for _, crt := range certs {
+			s := newExpirySeries(crt)
+			s.serial = ""
+			key := s.provisioner + "\x00" + s.san
+			if s.san == "" {
+				key += "\x00" + s.commonName
+			}
+			if i, ok := groups[key]; ok {
+				if s.notAfter.After(series[i].notAfter) {
+					series[i] = s
+				}
+				continue
+			}
+			groups[key] = len(series)
+			series = append(series, s)
+		}
+	} else {
+		series = make([]expirySeries, len(certs))
+		for i, crt := range certs {
+			series[i] = newExpirySeries(crt)
+		}
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		a, b := series[i], series[j]
+		if !a.notAfter.Equal(b.notAfter) {
+			return a.notAfter.Before(b.notAfter)
+		}
+		if a.san != b.san {
+			return a.san < b.san
+		}
+		return a.serial < b.serial
+	})
+	if opts.MaxSeries > 0 && len(series) > opts.MaxSeries {
+		return series[:opts.MaxSeries], len(series) - opts.MaxSeries
+	}
+	return series, 0
+}
+
+func newExpirySeries(crt IssuedCertificate) expirySeries {
+	sans := make([]string, len(crt.SANs))
+	copy(sans, crt.SANs)
+	sort.Strings(sans)
+	return expirySeries{
+		commonName:  labelValue(crt.CommonName),
+		san:         labelValue(strings.Join(sans, ",")),
+		serial:      labelValue(crt.SerialNumber),
+		provisioner: labelValue(crt.Provisioner),
+		notBefore:   crt.NotBefore,
+		notAfter:    crt.NotAfter,
+	}
+}
+
+func writeCertificateSeries(sb *strings.Builder, series []expirySeries, truncated int, now time.Time) {
+	labels := func(s expirySeries) string {
+		if s.serial == "" {
+			return fmt.Sprintf("common_name=%s,san=%s,provisioner=%s", quote(s.commonName), quote(s.san), quote(s.provisioner))
+		}
+		return fmt.Sprintf("common_name=%s,san=%s,serial_number=%s,provisioner=%s", quote(s.commonName), quote(s.san), quote(s.serial), quote(s.provisioner))
+	}
+
+	sb.WriteString("# HELP x509_cert_expiry Number of seconds until the expiration of the certificates issued by the CA.\n")
+	sb.WriteString("# TYPE x509_cert_expiry gauge\n")
+	for _, s := range series {
+		fmt.Fprintf(sb, "x509_cert_expiry{%s} %d\n", labels(s), int64(s.notAfter.Sub(now).Seconds()))
+	}
+	sb.WriteString("# HELP x509_cert_enddate Expiration of the certificates issued by the CA.\n")
+	sb.WriteString("# TYPE x509_cert_enddate gauge\n")
+	for _, s := range series {
+		fmt.Fprintf(sb, "x509_cert_enddate{%s} %d\n", labels(s), s.notAfter.Unix())
+	}
+	sb.WriteString("# HELP x509_cert_startdate Start of the validity of the certificates issued by the CA.\n")
+	sb.WriteString("# TYPE x509_cert_startdate gauge\n")
+	for _, s := range series {
+		fmt.Fprintf(sb, "x509_cert_startdate{%s} %d\n", labels(s), s.notBefore.Unix())
+	}
+	sb.WriteString("# HELP step_ca_certificate_series_truncated Number of certificate expiry series not exported because of the maximum number of series.\n")
+	sb.WriteString("# TYPE step_ca_certificate_series_truncated gauge\n")
+	fmt.Fprintf(sb, "step_ca_certificate_series_truncated %d\n", truncated)
+}
+
+// labelValue returns a valid UTF-8 value with at most maxLabelLength bytes.
+func labelValue(s string) string {
+	s = strings.ToValidUTF8(s, "�")
+	if len(s) <= maxLabelLength {
+		return s
+	}
+	s = s[:maxLabelLength]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,127 @@
+package monitoring
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics_SetIssuedCertificates(t *testing.T) {
+	notAfter := time.Unix(1767225600, 0)
+	certs := []IssuedCertificate{
+		{SerialNumber: "1", CommonName: "a.example.com", SANs: []string{"a.example.com"}, Provisioner: "acme", NotBefore: notAfter.Add(-time.Hour), NotAfter: notAfter},
+		{SerialNumber: "2", CommonName: "a.example.com", SANs: []string{"a.example.com"}, Provisioner: "acme", NotBefore: notAfter, NotAfter: notAfter.Add(time.Hour)},
+		{SerialNumber: "3", CommonName: "b.example.com", SANs: []string{"www.b.example.com", "b.example.com"}, Provisioner: "jwk", NotBefore: notAfter.Add(-time.Hour), NotAfter: notAfter.Add(-time.Minute)},
+	}
+
+	tests := []struct {
+		name      string
+		opts      CertificateSeriesOptions
+		want      []string
+		notWanted []string
+	}{
+		{"certificate", CertificateSeriesOptions{Mode: PerCertificate}, []string{
+			`x509_cert_enddate{common_name="b.example.com",san="b.example.com,www.b.example.com",serial_number="3",provisioner="jwk"} 1767225540`,
+			`x509_cert_enddate{common_name="a.example.com",san="a.example.com",serial_number="1",provisioner="acme"} 1767225600`,
+			`x509_cert_enddate{common_name="a.example.com",san="a.example.com",serial_number="2",provisioner="acme"} 1767229200`,
+			`x509_cert_startdate{common_name="a.example.com",san="a.example.com",serial_number="1",provisioner="acme"} 1767222000`,
+			`step_ca_certificate_series_truncated 0`,
+		}, nil},
+		{"certificate/max", CertificateSeriesOptions{Mode: PerCertificate, MaxSeries: 2}, []string{
+			`x509_cert_enddate{common_name="b.example.com",san="b.example.com,www.b.example.com",serial_number="3",provisioner="jwk"} 1767225540`,
+			`x509_cert_enddate{common_name="a.example.com",san="a.example.com",serial_number="1",provisioner="acme"} 1767225600`,
+			`step_ca_certificate_series_truncated 1`,
+		}, []string{`serial_number="2"`}},
+		{"sanGroup", CertificateSeriesOptions{Mode: PerSANGroup}, []string{
+			`x509_cert_enddate{common_name="b.example.com",san="b.example.com,www.b.example.com",provisioner="jwk"} 1767225540`,
+			`x509_cert_enddate{common_name="a.example.com",san="a.example.com",provisioner="acme"} 1767229200`,
+			`step_ca_certificate_series_truncated 0`,
+		}, []string{
+			`serial_number=`,
+			`x509_cert_enddate{common_name="a.example.com",san="a.example.com",provisioner="acme"} 1767225600`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMetrics()
+			m.SetIssuedCertificates(func() ([]IssuedCertificate, error) {
+				return certs, nil
+			}, tt.opts)
+			var sb strings.Builder
+			if _, err := m.WriteTo(&sb); err != nil {
+				t.Fatal(err)
+			}
+			got := sb.String()
+			last := -1
+			for _, want := range tt.want {
+				i := strings.Index(got, want+"\n")
+				if i == -1 {
+					t.Errorf("metrics do not contain %q:\n%s", want, got)
+					continue
+				}
+				if strings.HasPrefix(want, "x509_cert_enddate") {
+					if i < last {
+						t.Errorf("metrics are not sorted by expiration:\n%s", got)
+					}
+					last = i
+				}
+			}
+			for _, s := range tt.notWanted {
+				if strings.Contains(got, s) {
+					t.Errorf("metrics contain %q:\n%s", s, got)
+				}
+			}
+			if !strings.Contains(got, "# TYPE x509_cert_expiry gauge\n") {
+				t.Errorf("metrics do not contain x509_cert_expiry:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestCertificateSeries_get(t *testing.T) {
+	var calls int
+	var err error
+	c := &certificateSeries{
+		fn: func() ([]IssuedCertificate, error) {
+			calls++
+			return []IssuedCertificate{{SerialNumber: "1", NotAfter: time.Now()}}, err
+		},
+		opts: CertificateSeriesOptions{Interval: time.Minute},
+	}
+
+	now := time.Now()
+	if series, _ := c.get(now); len(series) != 1 || calls != 1 {
+		t.Fatalf("certificateSeries.get() = %v, calls = %d", series, calls)
+	}
+	// The list is cached until the interval expires.
+	if c.get(now.Add(30 * time.Second)); calls != 1 {
+		t.Errorf("certificateSeries.get() calls = %d, want 1", calls)
+	}
+	// The previous series are kept on errors.
+	err = errors.New("an error")
+	if series, _ := c.get(now.Add(time.Minute)); len(series) != 1 || calls != 2 {
+		t.Errorf("certificateSeries.get() = %v, calls = %d", series, calls)
+	}
+}
+
+func Test_labelValue(t *testing.T) {
+	long := strings.Repeat("a", maxLabelLength-1) + "é"
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"ok", "a.example.com", "a.example.com"},
+		{"invalid utf-8", "a\xffb", "a�b"},
+		{"truncated", strings.Repeat("a", 300), strings.Repeat("a", maxLabelLength)},
+		{"truncated rune", long, strings.Repeat("a", maxLabelLength-1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := labelValue(tt.value); got != tt.want {
+				t.Errorf("labelValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package monitoring
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the histogram buckets.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics gathers per-provisioner metrics of the sign flow and exposes them
+// using the Prometheus text format. It implements the authority.Meter
+// interface.
+type Metrics struct {
+	mu            sync.Mutex
+	authorization map[string]*histogram
+	rendering     map[string]*histogram
+	signing       map[string]*histogram
+	signed        map[string]uint64
+	errors        map[errorKey]uint64
+	caExpirations func() []CertificateExpiry
+	queueDepths   func() map[string]int
+	certificates  *certificateSeries
+
+	acmeAccounts    map[labels]uint64
+	acmeValidations map[labels]uint64
+	acmeValidation  map[string]*histogram
+	acmeOrders      map[labels]uint64
+	acmeFinalize    map[string]*histogram
+	dbErrors        map[labels]uint64
+	shed            map[labels]uint64
+}
+
+// labels are the values of the labels of a counter.
+type labels [3]string
+
+// CertificateExpiry is the expiration of one of the root or intermediate
+// certificates of the CA.
+type CertificateExpiry struct {
+	Type         string
+	Subject      string
+	SerialNumber string
+	NotAfter     time.Time
+}
+
+type errorKey struct {
+	provisioner string
+	stage       string
+	class       string
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	v := d.Seconds()
+	for i, le := range DefaultBuckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// NewMetrics creates a new Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		authorization: make(map[string]*histogram),
+		rendering:     make(map[string]*histogram),
+		signing:       make(map[string]*histogram),
+		signed:        make(map[string]uint64),
+		errors:        make(map[errorKey]uint64),
+
+		acmeAccounts:    make(map[labels]uint64),
+		acmeValidations: make(map[labels]uint64),
+		acmeValidation:  make(map[string]*histogram),
+		acmeOrders:      make(map[labels]uint64),
+		acmeFinalize:    make(map[string]*histogram),
+		dbErrors:        make(map[labels]uint64),
+		shed:            make(map[labels]uint64),
+	}
+}
+
+// SetCAExpirations sets the function that returns the expiration of the CA
+// certificates, exposed as the step_ca_certificate_expiry_timestamp_seconds
+// gauge.
+func (m *Metrics) SetCAExpirations(fn func() []CertificateExpiry) {
+	m.mu.Lock()
+	m.caExpirations = fn
+	m.mu.Unlock()
+}
+
+// SetQueueDepths sets the function that returns the number of requests
+// waiting in the load shedding queues by class, exposed as the
+// step_ca_load_shedding_queue_depth gauge.
+func (m *Metrics) SetQueueDepths(fn func() map[string]int) {
+	m.mu.Lock()
+	m.queueDepths = fn
+	m.mu.Unlock()
+}
+
+// X509Authorized records the authorization latency and errors of the given
+// provisioner.
+func (m *Metrics) X509Authorized(p provisioner.Interface, d time.Duration, err error) {
+	m.record(m.authorization, "authorize", p, d, err)
+}
+
+// X509Rendered records the template rendering time and errors of the given
+// provisioner.
+func (m *Metrics) X509Rendered(p provisioner.Interface, d time.Duration, err error) {
+	m.record(m.rendering, "render", p, d, err)
+}
+
+// X509Signed records the signing time, the number of certificates signed and
+// the errors of the sign flow of the given provisioner.
+func (m *Metrics) X509Signed(p provisioner.Interface, d time.Duration, err error) {
+	name := provisionerName(p)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d > 0 {
+		m.observe(m.signing, name, d)
+	}
+	if err != nil {
+		m.errors[errorKey{name, "sign", errorClass(err)}]++
+		return
+	}
+	m.signed[name]++
+}
+
+// ACMEAccountCreated records a new ACME account of the given provisioner.
+func (m *Metrics) ACMEAccountCreated(provisionerName string) {
+	m.mu.Lock()
+	m.acmeAccounts[labels{provisionerName}]++
+	m.mu.Unlock()
+}
+
+// ACMEChallengeValidated records a validation attempt of an ACME challenge of
+// the given type. The outcome is the status of the challenge after the
+// attempt, or "error" if the attempt failed before it could be completed.
+func (m *Metrics) ACMEChallengeValidated(provisionerName, typ, outcome string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acmeValidations[labels{provisionerName, typ, outcome}]++
+	m.observe(m.acmeValidation, typ, d)
+}
+
+// ACMEOrderFinalized records the finalization of an ACME order. The outcome
+// is the status of the order after the finalization, or "error" if it failed.
+func (m *Metrics) ACMEOrderFinalized(provisionerName, outcome string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acmeOrders[labels{provisionerName, outcome}]++
+	m.observe(m.acmeFinalize, provisionerName, d)
+}
+
+// DBError records a failed operation of the database.
+func (m *Metrics) DBError(operation string) {
+	m.mu.Lock()
+	m.dbErrors[labels{operation}]++
+	m.mu.Unlock()
+}
+
+// RequestShed records a request of the given class refused by the load
+// shedding. The reason is "queue_full", "timeout" or "canceled".
+func (m *Metrics) RequestShed(class, reason string) {
+	m.mu.Lock()
+	m.shed[labels{class, reason}]++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) record(hs map[string]*histogram, stage string, p provisioner.Interface, d time.Duration, err error) {
+	name := provisionerName(p)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observe(hs, name, d)
+	if err != nil {
+		m.errors[errorKey{name, stage, errorClass(err)}]++
+	}
+}
+
+func (m *Metrics) observe(hs map[string]*histogram, name string, d time.Duration) {
+	h, ok := hs[name]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(DefaultBuckets))}
+		hs[name] = h
+	}
+	h.observe(d)
+}
+
+// ServeHTTP writes the metrics using the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w) //nolint:errcheck // nothing to do on write errors
+}
+
+// WriteTo writes the metrics to the given writer using the Prometheus text
+// format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	// Listing the issued certificates can take a while, it's done without
+	// holding the lock used to record the metrics.
+	m.mu.Lock()
+	certificates := m.certificates
+	m.mu.Unlock()
+	var (
+		series    []expirySeries
+		truncated int
+		now       = time.Now()
+	)
+	if certificates != nil {
+		series, truncated = certificates.get(now)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expirations []CertificateExpiry
+	if m.caExpirations != nil {
+		expirations = m.caExpirations()
+	}
+	var depths map[string]int
+	if m.queueDepths != nil {
+		depths = m.queueDepths()
+	}
+
+	var sb strings.Builder
+	writeHistograms(&sb, "step_ca_x509_authorization_duration_seconds", "Time spent authorizing X.509 sign requests.", "provisioner", m.authorization)
+	writeHistograms(&sb, "step_ca_x509_template_duration_seconds", "Time spent rendering X.509 certificate templates.", "provisioner", m.rendering)
+	writeHistograms(&sb, "step_ca_x509_signing_duration_seconds", "Time spent signing X.509 certificates.", "provisioner", m.signing)
+
+	sb.WriteString("# HELP step_ca_x509_signed_total Number of X.509 certificates signed.\n")
+	sb.WriteString("# TYPE step_ca_x509_signed_total counter\n")
+	for _, name := range sortedKeys(m.signed) {
+		fmt.Fprintf(&sb, "step_ca_x509_signed_total{provisioner=%s} %d\n", quote(name), m.signed[name])
+	}
+
+	keys := make([]errorKey, 0, len(m.errors))
+	for k := range m.errors {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.provisioner != b.provisioner {
+			return a.provisioner < b.provisioner
+		}
+		if a.stage != b.stage {
+			return a.stage < b.stage
+		}
+		return a.class < b.class
+	})
+	sb.WriteString("# HELP step_ca_x509_errors_total Number of errors in the X.509 sign flow by stage and class.\n")
+	sb.WriteString("# TYPE step_ca_x509_errors_total counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "step_ca_x509_errors_total{provisioner=%s,stage=%s,class=%s} %d\n",
+			quote(k.provisioner), quote(k.stage), quote(k.class), m.errors[k])
+	}
+
+	sb.WriteString("# HELP step_ca_certificate_expiry_timestamp_seconds Expiration of the root and intermediate certificates of the CA.\n")
+	sb.WriteString("# TYPE step_ca_certificate_expiry_timestamp_seconds gauge\n")
+	for _, e := range expirations {
+		fmt.Fprintf(&sb, "step_ca_certificate_expiry_timestamp_seconds{type=%s,subject=%s,serial=%s} %d\n",
+			quote(e.Type), quote(e.Subject), quote(e.SerialNumber), e.NotAfter.Unix())
+	}
+
+	if certificates != nil {
+		writeCertificateSeries(&sb, series, truncated, now)
+	}
+
+	writeCounters(&sb, "step_ca_acme_accounts_created_total", "Number of ACME accounts created.", []string{"provisioner"}, m.acmeAccounts)
+	writeCounters(&sb, "step_ca_acme_challenge_validations_total", "Number of ACME challenge validation attempts by type and outcome.", []string{"provisioner", "type", "outcome"}, m.acmeValidations)
+	writeHistograms(&sb, "step_ca_acme_challenge_validation_duration_seconds", "Time spent validating ACME challenges.", "type", m.acmeValidation)
+	writeCounters(&sb, "step_ca_acme_order_finalizations_total", "Number of ACME order finalizations by outcome.", []string{"provisioner", "outcome"}, m.acmeOrders)
+	writeHistograms(&sb, "step_ca_acme_order_finalization_duration_seconds", "Time spent finalizing ACME orders.", "provisioner", m.acmeFinalize)
+	writeCounters(&sb, "step_ca_db_errors_total", "Number of failed database operations.", []string{"operation"}, m.dbErrors)
+
+	sb.WriteString("# HELP step_ca_load_shedding_queue_depth Number of requests waiting in the load shedding queues.\n")
+	sb.WriteString("# TYPE step_ca_load_shedding_queue_depth gauge\n")
+	for _, class := range sortedKeys(depths) {
+		fmt.Fprintf(&sb, "step_ca_load_shedding_queue_depth{class=%s} %d\n", quote(class), depths[class])
+	}
+	writeCounters(&sb, "step_ca_load_shedding_shed_total", "Number of requests refused by the load shedding by class and reason.", []string{"class", "reason"}, m.shed)
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+func writeHistograms(sb *strings.Builder, metric, help, labelName string, hs map[string]*histogram) {
+	fmt.Fprintf(sb, "# HELP %s %s\n", metric, help)
+	fmt.Fprintf(sb, "# TYPE %s histogram\n", metric)
+	for _, name := range sortedKeys(hs) {
+		h, label := hs[name], labelName+"="+quote(name)
+		for i, le := range DefaultBuckets {
+			fmt.Fprintf(sb, "%s_bucket{%s,le=%q} %d\n", metric, label, strconv.FormatFloat(le, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(sb, "%s_bucket{%s,le=\"+Inf\"} %d\n", metric, label, h.count)
+		fmt.Fprintf(sb, "%s_sum{%s} %s\n", metric, label, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(sb, "%s_count{%s} %d\n", metric, label, h.count)
+	}
+}
+
+func writeCounters(sb *strings.Builder, metric, help string, names []string, counters map[labels]uint64) {
+	keys := make([]labels, 0, len(counters))
+	for k := range counters {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		for n := range keys[i] {
+			if keys[i][n] != keys[j][n] {
+				return keys[i][n] < keys[j][n]
+			}
+		}
+		return false
+	})
+	fmt.Fprintf(sb, "# HELP %s %s\n", metric, help)
+	fmt.Fprintf(sb, "# TYPE %s counter\n", metric)
+	for _, k := range keys {
+		pairs := make([]string, len(names))
+		for i, name := range names {
+			pairs[i] = name + "=" + quote(k[i])
+		}
+		fmt.Fprintf(sb, "%s{%s} %d\n", metric, strings.Join(pairs, ","), counters[k])
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// quote returns the given label value quoted and escaped.
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func provisionerName(p provisioner.Interface) string {
+	if p == nil {
+		return "unknown"
+	}
+	return p.GetName()
+}
+
+// errorClass returns the class of an error using the HTTP status code of the
+// error, if available.
+func errorClass(err error) string {
+	var sc interface{ StatusCode() int }
+	if !errors.As(err, &sc) {
+		return "internal"
+	}
+	switch code := sc.StatusCode(); {
+	case code == http.StatusBadRequest:
+		return "bad_request"
+	case code == http.StatusUnauthorized:
+		return "unauthorized"
+	case code == http.StatusForbidden:
+		return "forbidden"
+	case code == http.StatusNotFound:
+		return "not_found"
+	case code == http.StatusNotImplemented:
+		return "not_implemented"
+	case code >= 500:
+		return "internal"
+	default:
+		return "client"
+	}
+}
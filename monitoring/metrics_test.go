@@ -0,0 +1,87 @@
+package monitoring
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+)
+
+func TestMetrics(t *testing.T) {
+	p := &provisioner.JWK{Name: "jane@example.com"}
+	m := NewMetrics()
+	m.X509Authorized(p, 20*time.Millisecond, nil)
+	m.X509Authorized(nil, time.Millisecond, errs.Unauthorized("bad token"))
+	m.X509Rendered(p, 2*time.Millisecond, errs.BadRequest("bad template"))
+	m.X509Signed(p, 0, errs.BadRequest("bad template"))
+	m.X509Authorized(p, 3*time.Second, nil)
+	m.X509Rendered(p, time.Millisecond, nil)
+	m.X509Signed(p, 50*time.Millisecond, nil)
+	m.X509Signed(p, 0, errors.New("an error"))
+	m.ACMEAccountCreated("acme")
+	m.ACMEChallengeValidated("acme", "http-01", "valid", 200*time.Millisecond)
+	m.ACMEChallengeValidated("acme", "http-01", "error", 2*time.Second)
+	m.ACMEOrderFinalized("acme", "valid", 30*time.Millisecond)
+	m.DBError("GetOrder")
+	m.DBError("GetOrder")
+	m.RequestShed("acme", "queue_full")
+	m.SetQueueDepths(func() map[string]int {
+		return map[string]int{"acme": 12, "renew": 0}
+	})
+	m.SetCAExpirations(func() []CertificateExpiry {
+		return []CertificateExpiry{
+			{Type: "root", Subject: "Root CA", SerialNumber: "1", NotAfter: time.Unix(1893456000, 0)},
+			{Type: "intermediate", Subject: "Intermediate CA", SerialNumber: "2", NotAfter: time.Unix(1767225600, 0)},
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	got := rec.Body.String()
+
+	for _, want := range []string{
+		`step_ca_x509_authorization_duration_seconds_bucket{provisioner="jane@example.com",le="0.025"} 1`,
+		`step_ca_x509_authorization_duration_seconds_bucket{provisioner="jane@example.com",le="5"} 2`,
+		`step_ca_x509_authorization_duration_seconds_bucket{provisioner="jane@example.com",le="+Inf"} 2`,
+		`step_ca_x509_authorization_duration_seconds_count{provisioner="jane@example.com"} 2`,
+		`step_ca_x509_authorization_duration_seconds_count{provisioner="unknown"} 1`,
+		`step_ca_x509_template_duration_seconds_count{provisioner="jane@example.com"} 2`,
+		`step_ca_x509_signing_duration_seconds_count{provisioner="jane@example.com"} 1`,
+		`step_ca_x509_signing_duration_seconds_sum{provisioner="jane@example.com"} 0.05`,
+		`step_ca_x509_signed_total{provisioner="jane@example.com"} 1`,
+		`step_ca_x509_errors_total{provisioner="jane@example.com",stage="render",class="bad_request"} 1`,
+		`step_ca_x509_errors_total{provisioner="jane@example.com",stage="sign",class="bad_request"} 1`,
+		`step_ca_x509_errors_total{provisioner="jane@example.com",stage="sign",class="internal"} 1`,
+		`step_ca_x509_errors_total{provisioner="unknown",stage="authorize",class="unauthorized"} 1`,
+		`step_ca_acme_accounts_created_total{provisioner="acme"} 1`,
+		`step_ca_acme_challenge_validations_total{provisioner="acme",type="http-01",outcome="valid"} 1`,
+		`step_ca_acme_challenge_validations_total{provisioner="acme",type="http-01",outcome="error"} 1`,
+		`step_ca_acme_challenge_validation_duration_seconds_count{type="http-01"} 2`,
+		`step_ca_acme_order_finalizations_total{provisioner="acme",outcome="valid"} 1`,
+		`step_ca_acme_order_finalization_duration_seconds_count{provisioner="acme"} 1`,
+		`step_ca_db_errors_total{operation="GetOrder"} 2`,
+		`step_ca_load_shedding_queue_depth{class="acme"} 12`,
+		`step_ca_load_shedding_queue_depth{class="renew"} 0`,
+		`step_ca_load_shedding_shed_total{class="acme",reason="queue_full"} 1`,
+		`step_ca_certificate_expiry_timestamp_seconds{type="root",subject="Root CA",serial="1"} 1893456000`,
+		`step_ca_certificate_expiry_timestamp_seconds{type="intermediate",subject="Intermediate CA",serial="2"} 1767225600`,
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("metrics do not contain %q:\n%s", want, got)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+func Test_quote(t *testing.T) {
+	if got := quote("a\"b\\c\nd"); got != `"a\"b\\c\nd"` {
+		t.Errorf("quote() = %s", got)
+	}
+}
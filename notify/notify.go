@@ -0,0 +1,169 @@
+// Package notify implements the delivery of the email and SMS notifications
+// sent by the CA, like the one-time codes used to verify an address or the
+// notifications of the approval workflows. The providers are configured once
+// and shared by all the features.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Channel is the kind of address a notification is delivered to.
+type Channel string
+
+const (
+	// Email is the channel of the notifications sent to email addresses.
+	Email Channel = "email"
+	// SMS is the channel of the notifications sent to phone numbers.
+	SMS Channel = "sms"
+)
+
+// ErrNotConfigured is the error returned when there is no provider for the
+// channel of a message.
+var ErrNotConfigured = errors.New("notification channel is not configured")
+
+// Message is a notification. The subject, the sender and the header fields
+// are not used in SMS messages.
+type Message struct {
+	Channel Channel `json:"channel"`
+	To      string  `json:"to"`
+	Subject string  `json:"subject,omitempty"`
+	Body    string  `json:"body"`
+	// From, if set, replaces the sender configured in the provider.
+	From string `json:"from,omitempty"`
+	// Header are additional header fields of the email messages, like the
+	// Message-ID.
+	Header map[string]string `json:"header,omitempty"`
+}
+
+// Provider is the interface implemented by the services that deliver the
+// notifications.
+type Provider interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewProviderFunc is the type of the functions that create a provider with
+// the given options.
+type NewProviderFunc func(ctx context.Context, options json.RawMessage) (Provider, error)
+
+var registry = new(sync.Map)
+
+// Register adds to the registry a function to create a provider of type t.
+func Register(t string, fn NewProviderFunc) {
+	registry.Store(t, fn)
+}
+
+// LoadProviderNewFunc returns the function to create a provider of type t.
+func LoadProviderNewFunc(t string) (NewProviderFunc, bool) {
+	v, ok := registry.Load(t)
+	if !ok {
+		return nil, false
+	}
+	fn, ok := v.(NewProviderFunc)
+	return fn, ok
+}
+
+// ProviderConfig is the configuration of a provider. The options depend on
+// the type of the provider.
+type ProviderConfig struct {
+	Type    string          `json:"type"`
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+// Config is the configuration of the notifications, with the provider used
+// in each channel.
+type Config struct {
+	Email *ProviderConfig `json:"email,omitempty"`
+	SMS   *ProviderConfig `json:"sms,omitempty"`
+}
+
+func (c *Config) providers() map[Channel]*ProviderConfig {
+	m := make(map[Channel]*ProviderConfig)
+	if c != nil && c.Email != nil {
+		m[Email] = c.Email
+	}
+	if c != nil && c.SMS != nil {
+		m[SMS] = c.SMS
+	}
+	return m
+}
+
+// Validate validates the notifications configuration.
+func (c *Config) Validate() error {
+	for ch, pc := range c.providers() {
+		if pc.Type == "" {
+			return errors.Errorf("notifications.%s.type cannot be empty", ch)
+		}
+		if _, ok := LoadProviderNewFunc(pc.Type); !ok {
+			return errors.Errorf("notifications.%s.type %s is not supported", ch, pc.Type)
+		}
+	}
+	return nil
+}
+
+// Notifier sends the notifications using the provider of each channel.
+type Notifier struct {
+	providers map[Channel]Provider
+}
+
+// New creates a Notifier with the providers in the given configuration.
+func New(ctx context.Context, c *Config) (*Notifier, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	providers := make(map[Channel]Provider)
+	for ch, pc := range c.providers() {
+		fn, _ := LoadProviderNewFunc(pc.Type)
+		p, err := fn(ctx, pc.Options)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error creating %s notifications provider", ch)
+		}
+		providers[ch] = p
+	}
+	return NewNotifier(providers), nil
+}
+
+// NewNotifier creates a Notifier with the given providers.
+func NewNotifier(providers map[Channel]Provider) *Notifier {
+	return &Notifier{providers: providers}
+}
+
+// IsEnabled returns if there is a provider for the given channel.
+func (n *Notifier) IsEnabled(ch Channel) bool {
+	if n == nil {
+		return false
+	}
+	_, ok := n.providers[ch]
+	return ok
+}
+
+// Send sends the message using the provider of its channel. It returns
+// ErrNotConfigured if there is none.
+func (n *Notifier) Send(ctx context.Context, msg *Message) error {
+	if n == nil {
+		return ErrNotConfigured
+	}
+	p, ok := n.providers[msg.Channel]
+	if !ok {
+		return ErrNotConfigured
+	}
+	return p.Send(ctx, msg)
+}
+
+type notifierKey struct{}
+
+// NewContext adds the given notifier to the context.
+func NewContext(ctx context.Context, n *Notifier) context.Context {
+	return context.WithValue(ctx, notifierKey{}, n)
+}
+
+// FromContext returns the notifier in the context. The returned notifier can
+// be nil, sending a message with it returns ErrNotConfigured.
+func FromContext(ctx context.Context) *Notifier {
+	n, _ := ctx.Value(notifierKey{}).(*Notifier)
+	return n
+}
@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockProvider struct {
+	messages []*Message
+	err      error
+}
+
+func (m *mockProvider) Send(_ context.Context, msg *Message) error {
+	m.messages = append(m.messages, msg)
+	return m.err
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *Config
+		wantErr string
+	}{
+		{"nil", nil, ""},
+		{"empty", &Config{}, ""},
+		{"ok", &Config{
+			Email: &ProviderConfig{Type: "smtp"},
+			SMS:   &ProviderConfig{Type: "webhook"},
+		}, ""},
+		{"fail type", &Config{Email: &ProviderConfig{}}, "notifications.email.type cannot be empty"},
+		{"fail unsupported", &Config{SMS: &ProviderConfig{Type: "foo"}}, "notifications.sms.type foo is not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	n, err := New(ctx, &Config{
+		Email: &ProviderConfig{Type: "smtp", Options: json.RawMessage(`{"address":"localhost:25","from":"ca@example.com"}`)},
+		SMS:   &ProviderConfig{Type: "webhook", Options: json.RawMessage(`{"url":"https://sms.example.com/send"}`)},
+	})
+	require.NoError(t, err)
+	assert.True(t, n.IsEnabled(Email))
+	assert.True(t, n.IsEnabled(SMS))
+
+	n, err = New(ctx, nil)
+	require.NoError(t, err)
+	assert.False(t, n.IsEnabled(Email))
+
+	_, err = New(ctx, &Config{
+		Email: &ProviderConfig{Type: "smtp", Options: json.RawMessage(`{"address":"localhost"}`)},
+	})
+	assert.EqualError(t, err, "error creating email notifications provider: invalid smtp address localhost")
+}
+
+func TestNotifier_Send(t *testing.T) {
+	ctx := context.Background()
+	p := &mockProvider{}
+	n := NewNotifier(map[Channel]Provider{Email: p})
+
+	msg := &Message{Channel: Email, To: "jane@example.com", Subject: "subject", Body: "body"}
+	require.NoError(t, n.Send(ctx, msg))
+	assert.Equal(t, []*Message{msg}, p.messages)
+
+	assert.ErrorIs(t, n.Send(ctx, &Message{Channel: SMS, To: "+15555550100"}), ErrNotConfigured)
+
+	var nilNotifier *Notifier
+	assert.False(t, nilNotifier.IsEnabled(Email))
+	assert.ErrorIs(t, nilNotifier.Send(ctx, msg), ErrNotConfigured)
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	Register("smtp", func(_ context.Context, options json.RawMessage) (Provider, error) {
+		var o SMTPOptions
+		if err := json.Unmarshal(options, &o); err != nil {
+			return nil, errors.Wrap(err, "error parsing smtp options")
+		}
+		return NewSMTPProvider(&o)
+	})
+}
+
+// SMTPOptions are the options of the SMTP server used to send the email
+// notifications.
+type SMTPOptions struct {
+	// Address is the host and port of the SMTP server.
+	Address string `json:"address"`
+	// Username and Password are the credentials used to authenticate with the
+	// SMTP server, both are optional.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// From is the email address used as the sender of the messages.
+	From string `json:"from"`
+}
+
+// Validate validates the SMTP options.
+func (o *SMTPOptions) Validate() error {
+	if _, _, err := net.SplitHostPort(o.Address); err != nil {
+		return errors.Errorf("invalid smtp address %s", o.Address)
+	}
+	if _, err := mail.ParseAddress(o.From); err != nil {
+		return errors.Errorf("invalid smtp from %s", o.From)
+	}
+	return nil
+}
+
+// SMTPProvider is a Provider that sends emails using an SMTP server.
+type SMTPProvider struct {
+	options SMTPOptions
+	send    func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPProvider creates a new SMTPProvider with the given options.
+func NewSMTPProvider(o *SMTPOptions) (*SMTPProvider, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	return &SMTPProvider{
+		options: *o,
+		send:    smtp.SendMail,
+	}, nil
+}
+
+// Send implements the Provider interface. The connection uses STARTTLS if the
+// server supports it, and the credentials are only sent over TLS or if the
+// server is on localhost.
+func (p *SMTPProvider) Send(_ context.Context, msg *Message) error {
+	if msg.Channel != Email {
+		return errors.Errorf("smtp provider cannot send %s messages", msg.Channel)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return errors.Errorf("invalid email address %s", msg.To)
+	}
+	from := p.options.From
+	if msg.From != "" {
+		from = msg.From
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return errors.Errorf("invalid email address %s", from)
+	}
+
+	var auth smtp.Auth
+	if p.options.Username != "" || p.options.Password != "" {
+		host, _, _ := net.SplitHostPort(p.options.Address)
+		auth = smtp.PlainAuth("", p.options.Username, p.options.Password, host)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	keys := make([]string, 0, len(msg.Header))
+	for k := range msg.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, msg.Header[k])
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	if err := p.send(p.options.Address, auth, sender.Address, []string{to.Address}, b.Bytes()); err != nil {
+		return errors.Wrap(err, "error sending email")
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMTPProvider_Send(t *testing.T) {
+	ctx := context.Background()
+	p, err := NewSMTPProvider(&SMTPOptions{
+		Address:  "smtp.example.com:587",
+		Username: "user",
+		Password: "pass",
+		From:     "ca@example.com",
+	})
+	require.NoError(t, err)
+
+	var gotTo []string
+	var gotMsg string
+	p.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, "smtp.example.com:587", addr)
+		assert.NotNil(t, a)
+		assert.Equal(t, "ca@example.com", from)
+		gotTo, gotMsg = to, string(msg)
+		return nil
+	}
+
+	require.NoError(t, p.Send(ctx, &Message{Channel: Email, To: "jane@example.com", Subject: "Código", Body: "the body"}))
+	assert.Equal(t, []string{"jane@example.com"}, gotTo)
+	assert.Contains(t, gotMsg, "To: jane@example.com\r\n")
+	assert.Contains(t, gotMsg, "Subject: =?utf-8?q?C=C3=B3digo?=\r\n")
+	assert.True(t, strings.HasSuffix(gotMsg, "\r\n\r\nthe body"))
+
+	assert.EqualError(t, p.Send(ctx, &Message{Channel: SMS, To: "+15555550100"}), "smtp provider cannot send sms messages")
+	assert.EqualError(t, p.Send(ctx, &Message{Channel: Email, To: "jane"}), "invalid email address jane")
+
+	p.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, "acme@example.com", from)
+		gotTo, gotMsg = to, string(msg)
+		return nil
+	}
+	require.NoError(t, p.Send(ctx, &Message{
+		Channel: Email, To: "Jane <jane@example.com>", Subject: "ACME: token", Body: "line 1\nline 2\n",
+		From: "acme@example.com", Header: map[string]string{"Message-ID": "<id@example.com>", "Auto-Submitted": "auto-generated"},
+	}))
+	assert.Equal(t, []string{"jane@example.com"}, gotTo)
+	assert.Contains(t, gotMsg, "From: acme@example.com\r\n")
+	assert.Contains(t, gotMsg, "Auto-Submitted: auto-generated\r\nMessage-ID: <id@example.com>\r\n")
+	assert.True(t, strings.HasSuffix(gotMsg, "\r\n\r\nline 1\r\nline 2\r\n"))
+	assert.EqualError(t, p.Send(ctx, &Message{Channel: Email, To: "jane@example.com", From: "acme"}), "invalid email address acme")
+
+	p.send = func(string, smtp.Auth, string, []string, []byte) error {
+		return errors.New("force")
+	}
+	assert.EqualError(t, p.Send(ctx, &Message{Channel: Email, To: "jane@example.com"}), "error sending email: force")
+}
+
+func TestNewSMTPProvider(t *testing.T) {
+	_, err := NewSMTPProvider(&SMTPOptions{Address: "smtp.example.com", From: "ca@example.com"})
+	assert.EqualError(t, err, "invalid smtp address smtp.example.com")
+	_, err = NewSMTPProvider(&SMTPOptions{Address: "smtp.example.com:25", From: "ca"})
+	assert.EqualError(t, err, "invalid smtp from ca")
+}
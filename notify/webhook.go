@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	Register("webhook", func(_ context.Context, options json.RawMessage) (Provider, error) {
+		var o WebhookOptions
+		if err := json.Unmarshal(options, &o); err != nil {
+			return nil, errors.Wrap(err, "error parsing webhook options")
+		}
+		return NewWebhookProvider(&o)
+	})
+}
+
+// WebhookOptions are the options of the HTTP endpoint used to deliver the
+// notifications. It can be used with the HTTP APIs of email and SMS services,
+// usually through a small adapter.
+type WebhookOptions struct {
+	// URL is the endpoint that receives the messages in a POST request with
+	// the JSON representation of the message.
+	URL string `json:"url"`
+	// BearerToken, if set, is sent in the Authorization header.
+	BearerToken string `json:"bearerToken,omitempty"`
+	// Headers are additional headers added to the requests.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Validate validates the webhook options.
+func (o *WebhookOptions) Validate() error {
+	u, err := url.Parse(o.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.Errorf("invalid webhook url %s", o.URL)
+	}
+	return nil
+}
+
+// WebhookProvider is a Provider that sends the messages to an HTTP endpoint.
+type WebhookProvider struct {
+	options WebhookOptions
+	client  *http.Client
+}
+
+// NewWebhookProvider creates a new WebhookProvider with the given options.
+func NewWebhookProvider(o *WebhookOptions) (*WebhookProvider, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	return &WebhookProvider{
+		options: *o,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Send implements the Provider interface. Any response status other than 2xx
+// is considered an error.
+func (p *WebhookProvider) Send(ctx context.Context, msg *Message) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling message")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.options.URL, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "error creating request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range p.options.Headers {
+		req.Header.Set(k, v)
+	}
+	if p.options.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.options.BearerToken)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error sending notification")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("error sending notification: webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
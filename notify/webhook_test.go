@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookProvider_Send(t *testing.T) {
+	ctx := context.Background()
+	var got Message
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "bar", r.Header.Get("X-Foo"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	p, err := NewWebhookProvider(&WebhookOptions{
+		URL:         srv.URL,
+		BearerToken: "token",
+		Headers:     map[string]string{"X-Foo": "bar"},
+	})
+	require.NoError(t, err)
+
+	msg := &Message{Channel: SMS, To: "+15555550100", Body: "your code is 123456"}
+	require.NoError(t, p.Send(ctx, msg))
+	assert.Equal(t, *msg, got)
+
+	status = http.StatusBadRequest
+	assert.EqualError(t, p.Send(ctx, msg), "error sending notification: webhook returned status 400")
+}
+
+func TestNewWebhookProvider(t *testing.T) {
+	for _, u := range []string{"", "ftp://example.com", "https://"} {
+		_, err := NewWebhookProvider(&WebhookOptions{URL: u})
+		assert.EqualError(t, err, "invalid webhook url "+u)
+	}
+}
@@ -0,0 +1,147 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+	kmsapi "go.step.sm/crypto/kms/apiv1"
+	"go.step.sm/crypto/pemutil"
+)
+
+// GetIntermediateCSRPath returns the path of the certificate signing request
+// generated for an offline root.
+func (p *PKI) GetIntermediateCSRPath() string {
+	return p.intermediateCSR
+}
+
+// GenerateIntermediateCSR generates the intermediate key and a certificate
+// signing request that must be signed by an offline root. The encrypted key,
+// if available, and the request are added to the files to write.
+//
+// Once signed, the certificate is added to the PKI using
+// WriteIntermediateCertificate.
+func (p *PKI) GenerateIntermediateCSR(name, org string, pass []byte) (*x509.CertificateRequest, error) {
+	if uri := p.options.intermediateKeyURI; uri != "" {
+		p.IntermediateKey = uri
+	}
+
+	resp, err := p.keyManager.CreateKey(&kmsapi.CreateKeyRequest{
+		Name:               p.IntermediateKey,
+		SignatureAlgorithm: kmsapi.UnspecifiedSignAlgorithm,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating intermediate key")
+	}
+
+	signer := resp.CreateSignerRequest.Signer
+	if signer == nil {
+		if signer, err = p.keyManager.CreateSigner(&resp.CreateSignerRequest); err != nil {
+			return nil, errors.Wrap(err, "error creating intermediate signer")
+		}
+	}
+
+	csr, err := NewIntermediateCSR(signer, pkix.Name{
+		CommonName:   name + " Intermediate CA",
+		Organization: []string{org},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace the key name with the one from the key manager. On softkms this
+	// will be the original filename, on any other kms will be the uri to the
+	// key.
+	if resp.Name != "" {
+		p.IntermediateKey = resp.Name
+	}
+
+	// If a kms is used it will not have the private key
+	if resp.PrivateKey != nil {
+		if p.Files[p.IntermediateKey], err = encodePrivateKey(resp.PrivateKey, pass); err != nil {
+			return nil, err
+		}
+	}
+	p.Files[p.intermediateCSR] = pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE REQUEST",
+		Bytes: csr.Raw,
+	})
+
+	return csr, nil
+}
+
+// WriteIntermediateCertificate adds to the PKI the intermediate certificate
+// signed by the offline root, and the root certificate without its key. The
+// intermediate certificate must match the request generated with
+// GenerateIntermediateCSR. If the request is not in memory, it will be read
+// from disk.
+func (p *PKI) WriteIntermediateCertificate(crt, root *x509.Certificate) error {
+	var (
+		csr *x509.CertificateRequest
+		err error
+	)
+	if b, ok := p.Files[p.intermediateCSR]; ok {
+		csr, err = pemutil.ParseCertificateRequest(b)
+	} else {
+		csr, err = pemutil.ReadCertificateRequest(p.intermediateCSR)
+	}
+	if err != nil {
+		return errors.Wrap(err, "error reading intermediate certificate request")
+	}
+
+	if err := VerifyIntermediateCertificate(crt, []*x509.Certificate{root}, csr.PublicKey); err != nil {
+		return err
+	}
+
+	p.Files[p.Intermediate] = encodeCertificate(crt)
+	return p.WriteRootCertificate(root, nil, nil)
+}
+
+// NewIntermediateCSR creates a certificate signing request for an intermediate
+// CA with the given signer and subject. It can be used to re-issue the
+// intermediate certificate of an existing authority using an offline root.
+func NewIntermediateCSR(signer crypto.Signer, subject pkix.Name) (*x509.CertificateRequest, error) {
+	b, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: subject,
+	}, signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing certificate request")
+	}
+	return csr, nil
+}
+
+// VerifyIntermediateCertificate verifies that the given certificate is a valid
+// intermediate CA for the given public key, signed by one of the roots.
+func VerifyIntermediateCertificate(crt *x509.Certificate, roots []*x509.Certificate, pub crypto.PublicKey) error {
+	if !crt.BasicConstraintsValid || !crt.IsCA {
+		return errors.New("intermediate certificate is not a CA")
+	}
+	if crt.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return errors.New("intermediate certificate does not have the certSign key usage")
+	}
+	if k, ok := crt.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !k.Equal(pub) {
+		return errors.New("intermediate certificate does not match the intermediate key")
+	}
+
+	pool := x509.NewCertPool()
+	for _, root := range roots {
+		if root.Equal(crt) {
+			return errors.New("intermediate certificate cannot be a root certificate")
+		}
+		pool.AddCert(root)
+	}
+	if _, err := crt.Verify(x509.VerifyOptions{
+		Roots:     pool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return errors.Wrap(err, "error verifying intermediate certificate")
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+	"go.step.sm/crypto/pemutil"
+
+	"github.com/smallstep/certificates/cas/apiv1"
+)
+
+func signIntermediate(t *testing.T, ca *minica.CA, pub crypto.PublicKey, isCA bool) *x509.Certificate {
+	t.Helper()
+	now := time.Now()
+	b, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:          big.NewInt(now.UnixNano()),
+		Subject:               pkix.Name{CommonName: "Offline Intermediate CA"},
+		NotBefore:             now,
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		MaxPathLenZero:        isCA,
+	}, ca.Root, pub, ca.RootSigner)
+	require.NoError(t, err)
+	crt, err := x509.ParseCertificate(b)
+	require.NoError(t, err)
+	return crt
+}
+
+func TestPKI_offlineRoot(t *testing.T) {
+	ca, err := minica.New()
+	require.NoError(t, err)
+
+	p, err := New(apiv1.Options{Type: "softcas", IsCreator: true}, WithHelm(), WithOfflineRoot())
+	require.NoError(t, err)
+
+	_, err = p.GenerateRootCertificate("Smallstep", "Smallstep", "smallstep", []byte("pass"))
+	assert.EqualError(t, err, "cannot generate a root certificate with an offline root")
+
+	csr, err := p.GenerateIntermediateCSR("Smallstep", "Smallstep", []byte("pass"))
+	require.NoError(t, err)
+	require.NoError(t, csr.CheckSignature())
+	assert.Equal(t, "Smallstep Intermediate CA", csr.Subject.CommonName)
+	assert.Equal(t, []string{"Smallstep"}, csr.Subject.Organization)
+	assert.Equal(t, "/home/step/certs/intermediate_ca.csr", p.GetIntermediateCSRPath())
+	assert.Contains(t, p.Files, p.IntermediateKey)
+
+	parsed, err := pemutil.ParseCertificateRequest(p.Files[p.GetIntermediateCSRPath()])
+	require.NoError(t, err)
+	assert.Equal(t, csr.Raw, parsed.Raw)
+
+	// The certificate must be for the intermediate key.
+	otherKey, _, err := keyutil.GenerateDefaultKeyPair()
+	require.NoError(t, err)
+	assert.EqualError(t, p.WriteIntermediateCertificate(signIntermediate(t, ca, otherKey, true), ca.Root),
+		"intermediate certificate does not match the intermediate key")
+	assert.NotContains(t, p.Files, p.Root[0])
+
+	crt := signIntermediate(t, ca, csr.PublicKey, true)
+	require.NoError(t, p.WriteIntermediateCertificate(crt, ca.Root))
+	assert.Equal(t, encodeCertificate(crt), p.Files[p.Intermediate])
+	assert.Equal(t, encodeCertificate(ca.Root), p.Files[p.Root[0]])
+	assert.NotContains(t, p.Files, p.RootKey[0])
+	assert.NotEmpty(t, p.GetRootFingerprint())
+
+	require.NoError(t, p.GenerateKeyPairs([]byte("pass")))
+	cfg, err := p.GenerateConfig()
+	require.NoError(t, err)
+	assert.True(t, cfg.OfflineRoot)
+}
+
+func TestVerifyIntermediateCertificate(t *testing.T) {
+	ca, err := minica.New()
+	require.NoError(t, err)
+	other, err := minica.New()
+	require.NoError(t, err)
+
+	pub, _, err := keyutil.GenerateDefaultKeyPair()
+	require.NoError(t, err)
+	crt := signIntermediate(t, ca, pub, true)
+
+	tests := []struct {
+		name    string
+		crt     *x509.Certificate
+		roots   []*x509.Certificate
+		pub     crypto.PublicKey
+		wantErr string
+	}{
+		{"ok", crt, []*x509.Certificate{other.Root, ca.Root}, pub, ""},
+		{"fail/not-ca", signIntermediate(t, ca, pub, false), []*x509.Certificate{ca.Root}, pub, "intermediate certificate is not a CA"},
+		{"ok/minica", ca.Intermediate, []*x509.Certificate{ca.Root}, ca.Signer.Public(), ""},
+		{"fail/public-key", crt, []*x509.Certificate{ca.Root}, ca.Signer.Public(), "intermediate certificate does not match the intermediate key"},
+		{"fail/root", ca.Root, []*x509.Certificate{ca.Root}, ca.RootSigner.Public(), "intermediate certificate cannot be a root certificate"},
+		{"fail/verify", crt, []*x509.Certificate{other.Root}, pub, "error verifying intermediate certificate: x509: certificate signed by unknown authority"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyIntermediateCertificate(tt.crt, tt.roots, tt.pub)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -188,6 +188,7 @@ type options struct {
 	intermediateKeyURI string
 	hostKeyURI         string
 	userKeyURI         string
+	offlineRoot        bool
 }
 
 // Option is the type of a configuration option on the pki constructor.
@@ -299,6 +300,15 @@ func WithKeyURIs(rootKey, intermediateKey, hostKey, userKey string) Option {
 	}
 }
 
+// WithOfflineRoot configures a PKI with an offline root. The root key is never
+// created or written, the intermediate certificate is signed offline using a
+// certificate signing request generated with GenerateIntermediateCSR.
+func WithOfflineRoot() Option {
+	return func(p *PKI) {
+		p.options.offlineRoot = true
+	}
+}
+
 // PKI represents the Public Key Infrastructure used by a certificate authority.
 type PKI struct {
 	linkedca.Configuration
@@ -307,6 +317,7 @@ type PKI struct {
 	caService       apiv1.CertificateAuthorityService
 	caCreator       apiv1.CertificateAuthorityCreator
 	keyManager      kmsapi.KeyManager
+	intermediateCSR string
 	config          string
 	defaults        string
 	profileDefaults string
@@ -424,6 +435,9 @@ func New(o apiv1.Options, opts ...Option) (*PKI, error) {
 	if p.IntermediateKey, err = getPath(private, "intermediate_ca_key"); err != nil {
 		return nil, err
 	}
+	if p.intermediateCSR, err = getPath(public, "intermediate_ca.csr"); err != nil {
+		return nil, err
+	}
 	if p.Ssh.HostPublicKey, err = getPath(public, "ssh_host_ca_key.pub"); err != nil {
 		return nil, err
 	}
@@ -508,6 +522,9 @@ func (p *PKI) GenerateKeyPairs(pass []byte) error {
 // GenerateRootCertificate generates a root certificate with the given name
 // and using the default key type.
 func (p *PKI) GenerateRootCertificate(name, org, resource string, pass []byte) (*apiv1.CreateCertificateAuthorityResponse, error) {
+	if p.options.offlineRoot {
+		return nil, errors.New("cannot generate a root certificate with an offline root")
+	}
 	if uri := p.options.rootKeyURI; uri != "" {
 		p.RootKey[0] = uri
 	}
@@ -756,7 +773,11 @@ func (p *PKI) tellPKI() {
 	switch {
 	case p.casOptions.Is(apiv1.SoftCAS):
 		ui.PrintSelected("Root certificate", p.Root[0])
-		ui.PrintSelected("Root private key", p.RootKey[0])
+		if p.options.offlineRoot {
+			ui.PrintSelected("Root private key", "offline")
+		} else {
+			ui.PrintSelected("Root private key", p.RootKey[0])
+		}
 		ui.PrintSelected("Root fingerprint", p.Defaults.Fingerprint)
 		ui.PrintSelected("Intermediate certificate", p.Intermediate)
 		ui.PrintSelected("Intermediate private key", p.IntermediateKey)
@@ -817,6 +838,11 @@ func (p *PKI) GenerateConfig(opt ...ConfigOption) (*authconfig.Config, error) {
 		cfg.DB = nil
 	}
 
+	// Validate on startup that only the intermediate key is available.
+	if p.options.offlineRoot {
+		cfg.OfflineRoot = true
+	}
+
 	// Add linked as a deployment type to detect it on start and provide a
 	// message if the token is not given.
 	if p.options.deploymentType == LinkedDeployment {
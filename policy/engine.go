@@ -71,6 +71,7 @@ func (e *NamePolicyError) As(v any) bool {
 		if err, ok := v.(**errs.Error); ok {
 			*err = &errs.Error{
 				Status: http.StatusForbidden,
+				Code:   errs.CodePolicyDenied,
 				Msg:    fmt.Sprintf("The request was forbidden by the certificate authority: %s", e.Error()),
 				Err:    e,
 			}
@@ -80,6 +81,15 @@ func (e *NamePolicyError) As(v any) bool {
 	return false
 }
 
+// ErrorCode implements the errs.Coder interface. Names not allowed by the
+// policy use the policyDenied code.
+func (e *NamePolicyError) ErrorCode() errs.Code {
+	if e.Reason == NotAllowed {
+		return errs.CodePolicyDenied
+	}
+	return errs.CodeBadRequest
+}
+
 func (e *NamePolicyError) Detail() string {
 	return e.detail
 }
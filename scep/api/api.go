@@ -23,9 +23,10 @@ import (
 )
 
 const (
-	opnGetCACert    = "GetCACert"
-	opnGetCACaps    = "GetCACaps"
-	opnPKIOperation = "PKIOperation"
+	opnGetCACert      = "GetCACert"
+	opnGetCACaps      = "GetCACaps"
+	opnPKIOperation   = "PKIOperation"
+	opnGetCACertChain = "GetCACertChain"
 
 	// TODO: add other (more optional) operations and handling
 )
@@ -108,6 +109,8 @@ func Get(w http.ResponseWriter, r *http.Request) {
 		res, err = GetCACert(ctx)
 	case opnGetCACaps:
 		res, err = GetCACaps(ctx)
+	case opnGetCACertChain:
+		res, err = GetCACertChain(ctx)
 	case opnPKIOperation:
 		res, err = PKIOperation(ctx, req)
 	default:
@@ -160,7 +163,7 @@ func decodeRequest(r *http.Request) (request, error) {
 	switch method {
 	case http.MethodGet:
 		switch operation {
-		case opnGetCACert, opnGetCACaps:
+		case opnGetCACert, opnGetCACaps, opnGetCACertChain:
 			return request{
 				Operation: operation,
 				Message:   []byte{},
@@ -259,6 +262,27 @@ func GetCACert(ctx context.Context) (Response, error) {
 	return res, nil
 }
 
+// GetCACertChain returns the full chain of the CA in a SCEP response, as a
+// degenerate PKCS#7 structure. It's an operation of Microsoft NDES.
+func GetCACertChain(ctx context.Context) (Response, error) {
+	auth := scep.MustFromContext(ctx)
+	certs, err := auth.GetCACertificateChain(ctx)
+	if err != nil {
+		return Response{}, err
+	}
+
+	data, err := microscep.DegenerateCertificates(certs)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return Response{
+		Operation: opnGetCACertChain,
+		CACertNum: len(certs),
+		Data:      data,
+	}, nil
+}
+
 // GetCACaps returns the CA capabilities in a SCEP response
 func GetCACaps(ctx context.Context) (Response, error) {
 	auth := scep.MustFromContext(ctx)
@@ -313,23 +337,21 @@ func PKIOperation(ctx context.Context, req request) (Response, error) {
 	// even if using the renewal flow as described in the README.md. MicroMDM SCEP client also only does PKCSreq by default, unless
 	// a certificate exists; then it will use RenewalReq. Adding the challenge check here may be a small breaking change for clients.
 	// We'll have to see how it works out.
+	//
+	// If the provisioner allows it, both kinds of messages can be authenticated by the existing certificate instead, when they are
+	// signed with a valid certificate issued by the provisioner. Renewals SHOULD omit the challenge in that case. Any other signer,
+	// like the self-signed certificates used in the initial enrollment, requires the challenge.
 	if msg.MessageType == microscep.PKCSReq || msg.MessageType == microscep.RenewalReq {
-		if err := auth.ValidateChallenge(ctx, challengePassword, transactionID); err != nil {
-			if errors.Is(err, provisioner.ErrSCEPChallengeInvalid) {
-				return createFailureResponse(ctx, csr, msg, microscep.BadRequest, err)
+		if err := auth.ValidateRenewal(ctx, p7.GetOnlySigner(), csr); err != nil {
+			if err := auth.ValidateChallenge(ctx, csr, challengePassword, transactionID); err != nil {
+				if errors.Is(err, provisioner.ErrSCEPChallengeInvalid) {
+					return createFailureResponse(ctx, csr, msg, microscep.BadRequest, err)
+				}
+				return createFailureResponse(ctx, csr, msg, microscep.BadRequest, errors.New("failed validating challenge password"))
 			}
-			return createFailureResponse(ctx, csr, msg, microscep.BadRequest, errors.New("failed validating challenge password"))
 		}
 	}
 
-	// TODO: authorize renewal: we can authorize renewals with the challenge password (if reusable secrets are used).
-	// Renewals OPTIONALLY include the challenge if the existing cert is used as authentication, but client SHOULD omit the challenge.
-	// This means that for renewal requests we should check the certificate provided to be signed before by the CA. We could
-	// enforce use of the challenge if we want too. That way we could be more flexible in terms of authentication scheme (i.e. reusing
-	// tokens from other provisioners, calling a webhook, storing multiple secrets, allowing them to be multi-use, etc).
-	// Authentication by the (self-signed) certificate with an optional challenge is required; supporting renewals incl. verification
-	// of the client cert is not.
-
 	certRep, err := auth.SignCSR(ctx, csr, msg)
 	if err != nil {
 		return createFailureResponse(ctx, csr, msg, microscep.BadRequest, fmt.Errorf("error when signing new certificate: %w", err))
@@ -390,6 +412,8 @@ func contentHeader(r Response) string {
 			return "application/x-x509-ca-ra-cert"
 		}
 		return "application/x-x509-ca-cert"
+	case opnGetCACertChain:
+		return "application/x-x509-ca-ra-cert-chain"
 	case opnPKIOperation:
 		return "application/x-pki-message"
 	}
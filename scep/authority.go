@@ -64,7 +64,7 @@ type AuthorityOptions struct {
 
 // SignAuthority is the interface for a signing authority
 type SignAuthority interface {
-	Sign(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
+	SignWithContext(ctx context.Context, cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
 	LoadProvisionerByName(string) (provisioner.Interface, error)
 }
 
@@ -100,6 +100,16 @@ var (
 		"SCEPStandard",
 		"POSTPKIOperation",
 	}
+
+	// ndesCapabilities are the capabilities returned by Microsoft NDES.
+	ndesCapabilities = []string{
+		"POSTPKIOperation",
+		"Renewal",
+		"SHA-512",
+		"SHA-256",
+		"SHA-1",
+		"DES3",
+	}
 )
 
 // LoadProvisionerByName calls out to the SignAuthority interface to load a
@@ -181,6 +191,45 @@ func (a *Authority) GetCACertificates(ctx context.Context) ([]*x509.Certificate,
 	return certs, nil
 }
 
+// rootsAuthority is implemented by the sign authorities that can return the
+// root certificates of the CA.
+type rootsAuthority interface {
+	GetRootCertificates() []*x509.Certificate
+}
+
+// GetCACertificateChain returns the full chain of the CA, including the
+// roots, for the GetCACertChain operation of Microsoft NDES. It's only
+// supported by provisioners in NDES compatibility mode.
+func (a *Authority) GetCACertificateChain(ctx context.Context) ([]*x509.Certificate, error) {
+	p, err := provisionerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !p.IsNDESCompatible() {
+		return nil, errors.New("GetCACertChain requires a provisioner in NDES compatibility mode")
+	}
+	if len(a.caCerts) == 0 {
+		return nil, errors.New("no intermediate certificate available in SCEP authority")
+	}
+
+	certs := append([]*x509.Certificate{}, a.caCerts...)
+	if ra, ok := a.signAuth.(rootsAuthority); ok {
+		for _, root := range ra.GetRootCertificates() {
+			var found bool
+			for _, c := range certs {
+				if c.Equal(root) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				certs = append(certs, root)
+			}
+		}
+	}
+	return certs, nil
+}
+
 // DecryptPKIEnvelope decrypts an enveloped message
 func (a *Authority) DecryptPKIEnvelope(_ context.Context, msg *PKIMessage) error {
 	p7c, err := pkcs7.Parse(msg.P7.Content)
@@ -296,7 +345,7 @@ func (a *Authority) SignCSR(ctx context.Context, csr *x509.CertificateRequest, m
 	}
 	signOps = append(signOps, templateOptions)
 
-	certChain, err := a.signAuth.Sign(csr, opts, signOps...)
+	certChain, err := a.signAuth.SignWithContext(ctx, csr, opts, signOps...)
 	if err != nil {
 		return nil, fmt.Errorf("error generating certificate for order: %w", err)
 	}
@@ -464,6 +513,9 @@ func (a *Authority) GetCACaps(ctx context.Context) []string {
 
 	caps := p.GetCapabilities()
 	if len(caps) == 0 {
+		if p.IsNDESCompatible() {
+			return ndesCapabilities
+		}
 		return defaultCapabilities
 	}
 
@@ -476,10 +528,12 @@ func (a *Authority) GetCACaps(ctx context.Context) []string {
 	return caps
 }
 
-func (a *Authority) ValidateChallenge(ctx context.Context, challenge, transactionID string) error {
+// ValidateChallenge validates the challenge of a request using the
+// provisioner.
+func (a *Authority) ValidateChallenge(ctx context.Context, csr *x509.CertificateRequest, challenge, transactionID string) error {
 	p, err := provisionerFromContext(ctx)
 	if err != nil {
 		return err
 	}
-	return p.ValidateChallenge(ctx, challenge, transactionID)
+	return p.ValidateChallenge(ctx, csr, challenge, transactionID)
 }
@@ -0,0 +1,75 @@
+package scep
+
+import (
+	"context"
+	"crypto/x509"
+	"reflect"
+	"testing"
+
+	"go.step.sm/crypto/minica"
+)
+
+type mockRootsAuthority struct {
+	SignAuthority
+	roots []*x509.Certificate
+}
+
+func (m *mockRootsAuthority) GetRootCertificates() []*x509.Certificate {
+	return m.roots
+}
+
+func TestAuthority_GetCACertificateChain(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ndes := &mockProvisioner{ndes: true}
+
+	tests := []struct {
+		name     string
+		prov     Provisioner
+		caCerts  []*x509.Certificate
+		signAuth SignAuthority
+		want     []*x509.Certificate
+		wantErr  bool
+	}{
+		{"ok", ndes, []*x509.Certificate{ca.Intermediate}, &mockRootsAuthority{roots: []*x509.Certificate{ca.Root}}, []*x509.Certificate{ca.Intermediate, ca.Root}, false},
+		{"ok root in chain", ndes, []*x509.Certificate{ca.Intermediate, ca.Root}, &mockRootsAuthority{roots: []*x509.Certificate{ca.Root}}, []*x509.Certificate{ca.Intermediate, ca.Root}, false},
+		{"ok without roots", ndes, []*x509.Certificate{ca.Intermediate}, nil, []*x509.Certificate{ca.Intermediate}, false},
+		{"fail not ndes", &mockProvisioner{}, []*x509.Certificate{ca.Intermediate}, nil, nil, true},
+		{"fail no certificates", ndes, nil, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Authority{caCerts: tt.caCerts, signAuth: tt.signAuth}
+			ctx := context.WithValue(context.Background(), ProvisionerContextKey, tt.prov)
+			got, err := a.GetCACertificateChain(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authority.GetCACertificateChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Authority.GetCACertificateChain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthority_GetCACaps(t *testing.T) {
+	tests := []struct {
+		name string
+		prov Provisioner
+		want []string
+	}{
+		{"default", &mockProvisioner{}, defaultCapabilities},
+		{"ndes", &mockProvisioner{ndes: true}, ndesCapabilities},
+		{"configured", &mockProvisioner{ndes: true, capabilities: []string{"SHA-256"}}, []string{"SHA-256"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), ProvisionerContextKey, tt.prov)
+			if got := (&Authority{}).GetCACaps(ctx); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Authority.GetCACaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
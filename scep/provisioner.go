@@ -2,6 +2,7 @@ package scep
 
 import (
 	"context"
+	"crypto/x509"
 	"time"
 
 	"github.com/smallstep/certificates/authority/provisioner"
@@ -16,6 +17,8 @@ type Provisioner interface {
 	GetOptions() *provisioner.Options
 	GetCapabilities() []string
 	ShouldIncludeRootInChain() bool
+	ShouldAllowCertificateRenewal() bool
+	IsNDESCompatible() bool
 	GetContentEncryptionAlgorithm() int
-	ValidateChallenge(ctx context.Context, challenge, transactionID string) error
+	ValidateChallenge(ctx context.Context, csr *x509.CertificateRequest, challenge, transactionID string) error
 }
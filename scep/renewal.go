@@ -0,0 +1,124 @@
+package scep
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// ErrCertificateRenewalNotAllowed is returned by ValidateRenewal when the
+// provisioner does not allow renewals authenticated with a certificate.
+var ErrCertificateRenewalNotAllowed = errors.New("renewal with certificate is not allowed")
+
+// revocationChecker is implemented by the sign authorities that can check if a
+// certificate has been revoked.
+type revocationChecker interface {
+	IsRevoked(sn string) (bool, error)
+}
+
+// ValidateRenewal validates that a renewal request is authenticated by the
+// certificate used to sign the SCEP message. The certificate must have been
+// issued by the CA using the current provisioner, it must be valid and not
+// revoked, and the CSR cannot request other names than the ones in the
+// certificate.
+//
+// Clients sign the initial enrollment with a self-signed certificate, so an
+// error means that the request must be authenticated with the challenge.
+func (a *Authority) ValidateRenewal(ctx context.Context, signer *x509.Certificate, csr *x509.CertificateRequest) error {
+	p, err := provisionerFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !p.ShouldAllowCertificateRenewal() {
+		return ErrCertificateRenewalNotAllowed
+	}
+	if signer == nil || csr == nil {
+		return errors.New("renewal request does not have a signer certificate")
+	}
+	if a.intermediateCertificate == nil {
+		return errors.New("no intermediate certificate available in SCEP authority")
+	}
+
+	// The certificates are issued by the intermediate, so it's used as the
+	// trust anchor.
+	roots := x509.NewCertPool()
+	roots.AddCert(a.intermediateCertificate)
+	if _, err := signer.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: time.Now(),
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return fmt.Errorf("error verifying signer certificate: %w", err)
+	}
+
+	ext, ok := provisioner.GetProvisionerExtension(signer)
+	if !ok || ext.Type != provisioner.TypeSCEP || ext.Name != p.GetName() {
+		return fmt.Errorf("signer certificate was not issued by the provisioner %q", p.GetName())
+	}
+
+	if rc, ok := a.signAuth.(revocationChecker); ok {
+		revoked, err := rc.IsRevoked(signer.SerialNumber.String())
+		if err != nil {
+			return fmt.Errorf("error checking if the signer certificate is revoked: %w", err)
+		}
+		if revoked {
+			return errors.New("signer certificate has been revoked")
+		}
+	}
+
+	return validateRenewalNames(signer, csr)
+}
+
+// validateRenewalNames checks that the CSR has the same common name as the
+// certificate, and that all the names in the CSR are in the certificate.
+func validateRenewalNames(cert *x509.Certificate, csr *x509.CertificateRequest) error {
+	if csr.Subject.CommonName != cert.Subject.CommonName {
+		return fmt.Errorf("renewal request common name %q does not match %q", csr.Subject.CommonName, cert.Subject.CommonName)
+	}
+
+	names := make(map[string]bool)
+	for _, n := range cert.DNSNames {
+		names["dns:"+n] = true
+	}
+	for _, n := range cert.EmailAddresses {
+		names["email:"+n] = true
+	}
+	for _, ip := range cert.IPAddresses {
+		names["ip:"+ip.String()] = true
+	}
+	for _, u := range cert.URIs {
+		names["uri:"+u.String()] = true
+	}
+
+	check := func(kind, name string) error {
+		if !names[kind+":"+name] {
+			return fmt.Errorf("renewal request name %q is not in the signer certificate", name)
+		}
+		return nil
+	}
+	for _, n := range csr.DNSNames {
+		if err := check("dns", n); err != nil {
+			return err
+		}
+	}
+	for _, n := range csr.EmailAddresses {
+		if err := check("email", n); err != nil {
+			return err
+		}
+	}
+	for _, ip := range csr.IPAddresses {
+		if err := check("ip", ip.String()); err != nil {
+			return err
+		}
+	}
+	for _, u := range csr.URIs {
+		if err := check("uri", u.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package scep
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"go.step.sm/crypto/minica"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+type mockProvisioner struct {
+	Provisioner
+	name         string
+	allowRenew   bool
+	ndes         bool
+	capabilities []string
+}
+
+func (p *mockProvisioner) GetName() string                     { return p.name }
+func (p *mockProvisioner) ShouldAllowCertificateRenewal() bool { return p.allowRenew }
+func (p *mockProvisioner) IsNDESCompatible() bool              { return p.ndes }
+func (p *mockProvisioner) GetCapabilities() []string           { return p.capabilities }
+
+type mockRevocationAuthority struct {
+	SignAuthority
+	revoked map[string]bool
+	err     error
+}
+
+func (m *mockRevocationAuthority) IsRevoked(sn string) (bool, error) {
+	return m.revoked[sn], m.err
+}
+
+func TestAuthority_ValidateRenewal(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newCert := func(name string, notAfter time.Time) *x509.Certificate {
+		t.Helper()
+		ext, err := (&provisioner.Extension{Type: provisioner.TypeSCEP, Name: name}).ToExtension()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ca.Sign(&x509.Certificate{
+			Subject:         pkix.Name{CommonName: "device"},
+			DNSNames:        []string{"device.example.com"},
+			IPAddresses:     []net.IP{net.ParseIP("10.0.0.1")},
+			NotBefore:       time.Now().Add(-time.Hour),
+			NotAfter:        notAfter,
+			PublicKey:       key.Public(),
+			ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			ExtraExtensions: []pkix.Extension{ext},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	valid := newCert("scep", time.Now().Add(time.Hour))
+	expired := newCert("scep", time.Now().Add(-time.Minute))
+	otherProvisioner := newCert("other", time.Now().Add(time.Hour))
+
+	selfSigned, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: valid.SerialNumber,
+		Subject:      pkix.Name{CommonName: "device"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}, &x509.Certificate{Subject: pkix.Name{CommonName: "device"}}, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selfSignedCert, err := x509.ParseCertificate(selfSigned)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csr := &x509.CertificateRequest{
+		Subject:     pkix.Name{CommonName: "device"},
+		DNSNames:    []string{"device.example.com"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+	}
+	allow := &mockProvisioner{name: "scep", allowRenew: true}
+
+	tests := []struct {
+		name     string
+		prov     Provisioner
+		signAuth SignAuthority
+		signer   *x509.Certificate
+		csr      *x509.CertificateRequest
+		wantErr  string
+	}{
+		{"ok", allow, &mockRevocationAuthority{}, valid, csr, ""},
+		{"ok without revocation checker", allow, nil, valid, csr, ""},
+		{"ok fewer names", allow, nil, valid, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "device"}}, ""},
+		{"fail not allowed", &mockProvisioner{name: "scep"}, nil, valid, csr, ErrCertificateRenewalNotAllowed.Error()},
+		{"fail no signer", allow, nil, nil, csr, "does not have a signer certificate"},
+		{"fail self-signed", allow, nil, selfSignedCert, csr, "error verifying signer certificate"},
+		{"fail expired", allow, nil, expired, csr, "error verifying signer certificate"},
+		{"fail provisioner", allow, nil, otherProvisioner, csr, `was not issued by the provisioner "scep"`},
+		{"fail revoked", allow, &mockRevocationAuthority{revoked: map[string]bool{valid.SerialNumber.String(): true}}, valid, csr, "has been revoked"},
+		{"fail revocation check", allow, &mockRevocationAuthority{err: errors.New("force")}, valid, csr, "error checking if the signer certificate is revoked: force"},
+		{"fail common name", allow, nil, valid, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "other"}}, `common name "other" does not match "device"`},
+		{"fail dns", allow, nil, valid, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "device"}, DNSNames: []string{"other.example.com"}}, `name "other.example.com" is not in the signer certificate`},
+		{"fail ip", allow, nil, valid, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "device"}, IPAddresses: []net.IP{net.ParseIP("10.0.0.2")}}, `name "10.0.0.2" is not in the signer certificate`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Authority{intermediateCertificate: ca.Intermediate, signAuth: tt.signAuth}
+			ctx := context.WithValue(context.Background(), ProvisionerContextKey, tt.prov)
+			err := a.ValidateRenewal(ctx, tt.signer, tt.csr)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Authority.ValidateRenewal() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Authority.ValidateRenewal() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package sds
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// SecretTypeURL is the type url of the resources served by the service.
+const SecretTypeURL = "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.Secret"
+
+// The messages in this file implement the subset of the Envoy xDS API used by
+// the secret discovery service. They use the same field numbers as the
+// protobuf definitions in github.com/envoyproxy/envoy/api, unknown fields are
+// ignored.
+
+// Node is the envoy.config.core.v3.Node message.
+type Node struct {
+	ID      string
+	Cluster string
+}
+
+// Status is the google.rpc.Status message.
+type Status struct {
+	Code    int32
+	Message string
+}
+
+// DiscoveryRequest is the envoy.service.discovery.v3.DiscoveryRequest message.
+type DiscoveryRequest struct {
+	VersionInfo   string
+	Node          *Node
+	ResourceNames []string
+	TypeURL       string
+	ResponseNonce string
+	ErrorDetail   *Status
+}
+
+// DiscoveryResponse is the envoy.service.discovery.v3.DiscoveryResponse
+// message. The resources are always secrets.
+type DiscoveryResponse struct {
+	VersionInfo string
+	Resources   []*Secret
+	TypeURL     string
+	Nonce       string
+}
+
+// Secret is the envoy.extensions.transport_sockets.tls.v3.Secret message. Only
+// one of TLSCertificate or ValidationContext is set.
+type Secret struct {
+	Name              string
+	TLSCertificate    *TLSCertificate
+	ValidationContext *ValidationContext
+}
+
+// TLSCertificate is the envoy.extensions.transport_sockets.tls.v3.TlsCertificate
+// message, with the certificate chain and private key as inline PEM bytes.
+type TLSCertificate struct {
+	CertificateChain []byte
+	PrivateKey       []byte
+}
+
+// ValidationContext is the
+// envoy.extensions.transport_sockets.tls.v3.CertificateValidationContext
+// message, with the trusted roots as inline PEM bytes.
+type ValidationContext struct {
+	TrustedCA []byte
+}
+
+// Marshal returns the protobuf encoding of the node.
+func (m *Node) Marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.ID)
+	b = appendString(b, 2, m.Cluster)
+	return b
+}
+
+// Unmarshal parses the protobuf encoding of a node.
+func (m *Node) Unmarshal(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, v []byte) error {
+		switch num {
+		case 1:
+			m.ID = string(v)
+		case 2:
+			m.Cluster = string(v)
+		}
+		return nil
+	})
+}
+
+// Marshal returns the protobuf encoding of the status.
+func (m *Status) Marshal() []byte {
+	var b []byte
+	if m.Code != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(m.Code))
+	}
+	b = appendString(b, 2, m.Message)
+	return b
+}
+
+// Unmarshal parses the protobuf encoding of a status.
+func (m *Status) Unmarshal(b []byte) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errors.Wrap(protowire.ParseError(n), "error parsing status")
+		}
+		b = b[n:]
+		if num == 1 && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return errors.Wrap(protowire.ParseError(n), "error parsing status")
+			}
+			m.Code = int32(v)
+			b = b[n:]
+			continue
+		}
+		if num == 2 && typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return errors.Wrap(protowire.ParseError(n), "error parsing status")
+			}
+			m.Message = string(v)
+			b = b[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return errors.Wrap(protowire.ParseError(n), "error parsing status")
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// Marshal returns the protobuf encoding of the request.
+func (m *DiscoveryRequest) Marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.VersionInfo)
+	if m.Node != nil {
+		b = appendBytes(b, 2, m.Node.Marshal())
+	}
+	for _, name := range m.ResourceNames {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendString(b, name)
+	}
+	b = appendString(b, 4, m.TypeURL)
+	b = appendString(b, 5, m.ResponseNonce)
+	if m.ErrorDetail != nil {
+		b = appendBytes(b, 6, m.ErrorDetail.Marshal())
+	}
+	return b
+}
+
+// Unmarshal parses the protobuf encoding of a request.
+func (m *DiscoveryRequest) Unmarshal(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, v []byte) error {
+		switch num {
+		case 1:
+			m.VersionInfo = string(v)
+		case 2:
+			m.Node = new(Node)
+			return m.Node.Unmarshal(v)
+		case 3:
+			m.ResourceNames = append(m.ResourceNames, string(v))
+		case 4:
+			m.TypeURL = string(v)
+		case 5:
+			m.ResponseNonce = string(v)
+		case 6:
+			m.ErrorDetail = new(Status)
+			return m.ErrorDetail.Unmarshal(v)
+		}
+		return nil
+	})
+}
+
+// Marshal returns the protobuf encoding of the response. The secrets are
+// encoded as google.protobuf.Any messages.
+func (m *DiscoveryResponse) Marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.VersionInfo)
+	for _, s := range m.Resources {
+		var res []byte
+		res = appendString(res, 1, SecretTypeURL)
+		res = appendBytes(res, 2, s.Marshal())
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, res)
+	}
+	b = appendString(b, 4, m.TypeURL)
+	b = appendString(b, 5, m.Nonce)
+	return b
+}
+
+// Unmarshal parses the protobuf encoding of a response.
+func (m *DiscoveryResponse) Unmarshal(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, v []byte) error {
+		switch num {
+		case 1:
+			m.VersionInfo = string(v)
+		case 2:
+			var typeURL string
+			var value []byte
+			if err := consumeFields(v, func(num protowire.Number, v []byte) error {
+				switch num {
+				case 1:
+					typeURL = string(v)
+				case 2:
+					value = v
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			if typeURL != SecretTypeURL {
+				return errors.Errorf("unsupported resource type %q", typeURL)
+			}
+			s := new(Secret)
+			if err := s.Unmarshal(value); err != nil {
+				return err
+			}
+			m.Resources = append(m.Resources, s)
+		case 4:
+			m.TypeURL = string(v)
+		case 5:
+			m.Nonce = string(v)
+		}
+		return nil
+	})
+}
+
+// Marshal returns the protobuf encoding of the secret.
+func (m *Secret) Marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.Name)
+	switch {
+	case m.TLSCertificate != nil:
+		var crt []byte
+		crt = appendBytes(crt, 1, inlineBytes(m.TLSCertificate.CertificateChain))
+		crt = appendBytes(crt, 2, inlineBytes(m.TLSCertificate.PrivateKey))
+		b = appendBytes(b, 2, crt)
+	case m.ValidationContext != nil:
+		b = appendBytes(b, 4, appendBytes(nil, 1, inlineBytes(m.ValidationContext.TrustedCA)))
+	}
+	return b
+}
+
+// Unmarshal parses the protobuf encoding of a secret.
+func (m *Secret) Unmarshal(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, v []byte) error {
+		switch num {
+		case 1:
+			m.Name = string(v)
+		case 2:
+			m.TLSCertificate = new(TLSCertificate)
+			return consumeFields(v, func(num protowire.Number, v []byte) (err error) {
+				switch num {
+				case 1:
+					m.TLSCertificate.CertificateChain, err = consumeInlineBytes(v)
+				case 2:
+					m.TLSCertificate.PrivateKey, err = consumeInlineBytes(v)
+				}
+				return
+			})
+		case 4:
+			m.ValidationContext = new(ValidationContext)
+			return consumeFields(v, func(num protowire.Number, v []byte) (err error) {
+				if num == 1 {
+					m.ValidationContext.TrustedCA, err = consumeInlineBytes(v)
+				}
+				return
+			})
+		}
+		return nil
+	})
+}
+
+// inlineBytes returns the envoy.config.core.v3.DataSource message with the
+// given inline bytes.
+func inlineBytes(v []byte) []byte {
+	return appendBytes(nil, 2, v)
+}
+
+func consumeInlineBytes(b []byte) (v []byte, err error) {
+	err = consumeFields(b, func(num protowire.Number, data []byte) error {
+		if num == 2 {
+			v = data
+		}
+		return nil
+	})
+	return
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// consumeFields calls fn with the value of each length-delimited field in b.
+// Fields with other wire types are skipped.
+func consumeFields(b []byte, fn func(num protowire.Number, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errors.Wrap(protowire.ParseError(n), "error parsing message")
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return errors.Wrap(protowire.ParseError(n), "error parsing message")
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return errors.Wrap(protowire.ParseError(n), "error parsing message")
+		}
+		if err := fn(num, v); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
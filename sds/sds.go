@@ -0,0 +1,420 @@
+// Package sds implements the Envoy secret discovery service (SDS), so Envoy
+// proxies and service mesh sidecars can get their certificates and roots
+// directly from the CA.
+//
+// The clients are authenticated with a client certificate issued by the CA,
+// usually a bootstrap certificate. The service serves the following secrets:
+//
+//   - "default": a certificate with a key generated by the CA and the same
+//     identity as the client certificate. The certificate is renewed and pushed
+//     to the client when two thirds of its lifetime have passed.
+//   - "ROOTCA": a validation context with the roots of the CA.
+//
+// Other secret names are ignored.
+package sds
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/pemutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName is the full name of the gRPC service.
+const ServiceName = "envoy.service.secret.v3.SecretDiscoveryService"
+
+const (
+	// CertificateName is the name of the secret with the workload certificate.
+	CertificateName = "default"
+	// RootCAName is the name of the secret with the roots of the CA.
+	RootCAName = "ROOTCA"
+)
+
+// Authority is the interface implemented by the CA authority used to issue the
+// certificates.
+type Authority interface {
+	RenewContext(ctx context.Context, oldCert *x509.Certificate, pk crypto.PublicKey) ([]*x509.Certificate, error)
+	GetRoots() ([]*x509.Certificate, error)
+}
+
+// Server implements the secret discovery gRPC service.
+type Server struct {
+	mu   sync.RWMutex
+	auth Authority
+}
+
+// New creates a new Server that issues certificates with the given authority.
+func New(auth Authority) *Server {
+	return &Server{auth: auth}
+}
+
+// SetAuthority replaces the authority used by the server. It's used when the
+// CA configuration is reloaded.
+func (s *Server) SetAuthority(auth Authority) {
+	s.mu.Lock()
+	s.auth = auth
+	s.mu.Unlock()
+}
+
+func (s *Server) getAuthority() Authority {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.auth
+}
+
+// ServerOption returns the option that needs to be used when the gRPC server
+// is created. The messages of the service use their own protobuf codec.
+func ServerOption() grpc.ServerOption {
+	return grpc.ForceServerCodec(Codec{})
+}
+
+// Register registers the service in the given gRPC server.
+func (s *Server) Register(gs *grpc.Server) {
+	gs.RegisterService(&serviceDesc, s)
+}
+
+// FetchSecrets returns the requested secrets.
+func (s *Server) FetchSecrets(ctx context.Context, req *DiscoveryRequest) (*DiscoveryResponse, error) {
+	cert, err := clientCertificate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTypeURL(req); err != nil {
+		return nil, err
+	}
+	w := &workload{auth: s.getAuthority(), cert: cert}
+	resp, err := w.response(ctx, req.ResourceNames)
+	if err != nil {
+		return nil, err
+	}
+	resp.Nonce = "1"
+	return resp, nil
+}
+
+// StreamSecrets sends the requested secrets, and sends them again every time
+// the certificate is renewed.
+func (s *Server) StreamSecrets(stream SecretDiscoveryService_StreamSecretsServer) error {
+	ctx := stream.Context()
+	cert, err := clientCertificate(ctx)
+	if err != nil {
+		return err
+	}
+
+	reqs := make(chan *DiscoveryRequest)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		names []string
+		nonce string
+		count int
+		timer *time.Timer
+		renew <-chan time.Time
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	w := &workload{auth: s.getAuthority(), cert: cert}
+	send := func() error {
+		w.auth = s.getAuthority()
+		resp, err := w.response(ctx, names)
+		if err != nil {
+			return err
+		}
+		count++
+		nonce = strconv.Itoa(count)
+		resp.Nonce = nonce
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+		if w.secret != nil {
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(time.Until(renewAt(w.cert)))
+			renew = timer.C
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case req := <-reqs:
+			if err := validateTypeURL(req); err != nil {
+				return err
+			}
+			// Ignore the requests with the nonce of a previous response.
+			if req.ResponseNonce != "" && req.ResponseNonce != nonce {
+				continue
+			}
+			if req.ErrorDetail != nil {
+				log.Printf("sds: secrets rejected by %s: %s", cert.Subject, req.ErrorDetail.Message)
+				continue
+			}
+			// Do not send the secrets again on ACKs.
+			if req.ResponseNonce != "" && equalNames(names, req.ResourceNames) {
+				continue
+			}
+			names = req.ResourceNames
+			if err := send(); err != nil {
+				return err
+			}
+		case <-renew:
+			// Renew the certificate
+			w.secret = nil
+			if err := send(); err != nil {
+				return err
+			}
+		case err := <-errc:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// workload keeps the last certificate and key issued to a client. Before the
+// first certificate is issued, cert is the client certificate.
+type workload struct {
+	auth   Authority
+	cert   *x509.Certificate
+	secret *Secret
+}
+
+// response returns the discovery response with the secrets in names. The
+// certificate is only issued if it has not been issued before.
+func (w *workload) response(ctx context.Context, names []string) (*DiscoveryResponse, error) {
+	resp := &DiscoveryResponse{
+		TypeURL: SecretTypeURL,
+	}
+	for _, name := range names {
+		switch name {
+		case CertificateName:
+			if w.secret == nil {
+				if err := w.issue(ctx); err != nil {
+					return nil, err
+				}
+			}
+			resp.Resources = append(resp.Resources, w.secret)
+		case RootCAName:
+			roots, err := w.auth.GetRoots()
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			resp.Resources = append(resp.Resources, &Secret{
+				Name: RootCAName,
+				ValidationContext: &ValidationContext{
+					TrustedCA: encodeCertificates(roots),
+				},
+			})
+		}
+	}
+
+	// The version changes every time the certificate is renewed.
+	if w.secret != nil {
+		resp.VersionInfo = w.cert.SerialNumber.String()
+	} else {
+		resp.VersionInfo = "0"
+	}
+	return resp, nil
+}
+
+// issue creates a new key and renews the last certificate of the client with
+// it.
+func (w *workload) issue(ctx context.Context) error {
+	signer, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	block, err := pemutil.Serialize(signer)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	chain, err := w.auth.RenewContext(ctx, w.cert, signer.Public())
+	if err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	w.cert = chain[0]
+	w.secret = &Secret{
+		Name: CertificateName,
+		TLSCertificate: &TLSCertificate{
+			CertificateChain: encodeCertificates(chain),
+			PrivateKey:       pem.EncodeToMemory(block),
+		},
+	}
+	return nil
+}
+
+// renewAt returns the time when the given certificate must be renewed.
+func renewAt(cert *x509.Certificate) time.Time {
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	return cert.NotBefore.Add(lifetime * 2 / 3)
+}
+
+func encodeCertificates(certs []*x509.Certificate) []byte {
+	var b []byte
+	for _, crt := range certs {
+		b = append(b, pem.EncodeToMemory(&pem.Block{
+			Type: "CERTIFICATE", Bytes: crt.Raw,
+		})...)
+	}
+	return b
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func validateTypeURL(req *DiscoveryRequest) error {
+	if req.TypeURL != "" && req.TypeURL != SecretTypeURL {
+		return status.Errorf(codes.InvalidArgument, "unsupported type url %q", req.TypeURL)
+	}
+	return nil
+}
+
+// clientCertificate returns the verified client certificate of the
+// connection.
+func clientCertificate(ctx context.Context) (*x509.Certificate, error) {
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			return info.State.VerifiedChains[0][0], nil
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "missing client certificate")
+}
+
+// Codec is the gRPC codec used by the service. It encodes the messages defined
+// in this package using the protobuf wire format.
+type Codec struct{}
+
+type message interface {
+	Marshal() []byte
+	Unmarshal([]byte) error
+}
+
+// Marshal implements the encoding.Codec interface.
+func (Codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, errors.Errorf("failed to marshal, message is %T", v)
+	}
+	return m.Marshal(), nil
+}
+
+// Unmarshal implements the encoding.Codec interface.
+func (Codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(message)
+	if !ok {
+		return errors.Errorf("failed to unmarshal, message is %T", v)
+	}
+	return m.Unmarshal(data)
+}
+
+// Name implements the encoding.Codec interface.
+func (Codec) Name() string { return "proto" }
+
+// SecretDiscoveryService_StreamSecretsServer is the server API for the
+// StreamSecrets method.
+type SecretDiscoveryService_StreamSecretsServer interface { //nolint:revive,stylecheck // gRPC naming
+	Send(*DiscoveryResponse) error
+	Recv() (*DiscoveryRequest, error)
+	grpc.ServerStream
+}
+
+type streamSecretsServer struct {
+	grpc.ServerStream
+}
+
+func (x *streamSecretsServer) Send(m *DiscoveryResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *streamSecretsServer) Recv() (*DiscoveryRequest, error) {
+	m := new(DiscoveryRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// secretDiscoveryServer is the interface implemented by the service.
+type secretDiscoveryServer interface {
+	StreamSecrets(SecretDiscoveryService_StreamSecretsServer) error
+	FetchSecrets(context.Context, *DiscoveryRequest) (*DiscoveryResponse, error)
+}
+
+func streamSecretsHandler(srv any, stream grpc.ServerStream) error {
+	return srv.(secretDiscoveryServer).StreamSecrets(&streamSecretsServer{stream})
+}
+
+func fetchSecretsHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	req := new(DiscoveryRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(secretDiscoveryServer).FetchSecrets(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + ServiceName + "/FetchSecrets",
+	}
+	return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+		return srv.(secretDiscoveryServer).FetchSecrets(ctx, req.(*DiscoveryRequest))
+	})
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*secretDiscoveryServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "FetchSecrets", Handler: fetchSecretsHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamSecrets",
+			Handler:       streamSecretsHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
@@ -0,0 +1,338 @@
+package sds
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+	"go.step.sm/crypto/pemutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type mockAuthority struct {
+	ca       *minica.CA
+	lifetime time.Duration
+	err      error
+
+	mu      sync.Mutex
+	renewed []*x509.Certificate
+}
+
+func (m *mockAuthority) RenewContext(_ context.Context, oldCert *x509.Certificate, pk crypto.PublicKey) ([]*x509.Certificate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	now := time.Now()
+	crt, err := m.ca.Sign(&x509.Certificate{
+		Subject:     oldCert.Subject,
+		DNSNames:    oldCert.DNSNames,
+		PublicKey:   pk,
+		NotBefore:   now,
+		NotAfter:    now.Add(m.lifetime),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.renewed = append(m.renewed, oldCert)
+	return []*x509.Certificate{crt, m.ca.Intermediate}, nil
+}
+
+func (m *mockAuthority) GetRoots() ([]*x509.Certificate, error) {
+	return []*x509.Certificate{m.ca.Root}, nil
+}
+
+func mustCertificate(t *testing.T, ca *minica.CA, name string) tls.Certificate {
+	t.Helper()
+	signer, err := keyutil.GenerateDefaultSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	crt, err := ca.Sign(&x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{name},
+		PublicKey:    signer.Public(),
+		NotBefore:    now,
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{crt.Raw, ca.Intermediate.Raw},
+		PrivateKey:  signer,
+		Leaf:        crt,
+	}
+}
+
+func newClient(t *testing.T, s *Server, ca *minica.CA, clientCert bool) *grpc.ClientConn {
+	t.Helper()
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Root)
+
+	ln := bufconn.Listen(1024 * 1024)
+	gs := grpc.NewServer(ServerOption(), grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{mustCertificate(t, ca, "sds.example.com")},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+	})))
+	s.Register(gs)
+	go gs.Serve(ln) //nolint:errcheck // Serve returns on Stop
+	t.Cleanup(gs.Stop)
+
+	clientConfig := &tls.Config{
+		ServerName: "sds.example.com",
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	if clientCert {
+		clientConfig.Certificates = []tls.Certificate{mustCertificate(t, ca, "workload.example.com")}
+	}
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(credentials.NewTLS(clientConfig)),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(Codec{})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func parseTLSCertificate(t *testing.T, s *Secret) *x509.Certificate {
+	t.Helper()
+	if s.Name != CertificateName || s.TLSCertificate == nil {
+		t.Fatalf("unexpected secret %+v", s)
+	}
+	block, _ := pem.Decode(s.TLSCertificate.CertificateChain)
+	if block == nil {
+		t.Fatal("certificate chain is not PEM encoded")
+	}
+	crt, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := pemutil.Parse(s.TLSCertificate.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(key.(crypto.Signer).Public(), crt.PublicKey) {
+		t.Fatal("private key does not match the certificate")
+	}
+	return crt
+}
+
+func TestServer_FetchSecrets(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		auth       *mockAuthority
+		clientCert bool
+		req        *DiscoveryRequest
+		wantNames  []string
+		wantCode   codes.Code
+	}{
+		{"ok", &mockAuthority{ca: ca, lifetime: time.Hour}, true, &DiscoveryRequest{
+			Node:          &Node{ID: "sidecar", Cluster: "mesh"},
+			ResourceNames: []string{CertificateName, RootCAName, "unknown"},
+			TypeURL:       SecretTypeURL,
+		}, []string{CertificateName, RootCAName}, codes.OK},
+		{"ok roots", &mockAuthority{ca: ca, lifetime: time.Hour}, true, &DiscoveryRequest{
+			ResourceNames: []string{RootCAName},
+		}, []string{RootCAName}, codes.OK},
+		{"fail client certificate", &mockAuthority{ca: ca, lifetime: time.Hour}, false, &DiscoveryRequest{
+			ResourceNames: []string{CertificateName},
+		}, nil, codes.Unauthenticated},
+		{"fail type url", &mockAuthority{ca: ca, lifetime: time.Hour}, true, &DiscoveryRequest{
+			ResourceNames: []string{CertificateName},
+			TypeURL:       "type.googleapis.com/envoy.config.cluster.v3.Cluster",
+		}, nil, codes.InvalidArgument},
+		{"fail renew", &mockAuthority{ca: ca, err: errors.New("forbidden")}, true, &DiscoveryRequest{
+			ResourceNames: []string{CertificateName},
+		}, nil, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newClient(t, New(tt.auth), ca, tt.clientCert)
+			resp := new(DiscoveryResponse)
+			err := conn.Invoke(context.Background(), "/"+ServiceName+"/FetchSecrets", tt.req, resp)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("FetchSecrets() code = %v, want %v, error = %v", code, tt.wantCode, err)
+			}
+			if err != nil {
+				return
+			}
+
+			var names []string
+			for _, s := range resp.Resources {
+				names = append(names, s.Name)
+				switch s.Name {
+				case CertificateName:
+					crt := parseTLSCertificate(t, s)
+					if !reflect.DeepEqual(crt.DNSNames, []string{"workload.example.com"}) {
+						t.Errorf("FetchSecrets() dnsNames = %v, want [workload.example.com]", crt.DNSNames)
+					}
+					if resp.VersionInfo != crt.SerialNumber.String() {
+						t.Errorf("FetchSecrets() version = %s, want %s", resp.VersionInfo, crt.SerialNumber)
+					}
+				case RootCAName:
+					block, _ := pem.Decode(s.ValidationContext.TrustedCA)
+					if block == nil || string(block.Bytes) != string(ca.Root.Raw) {
+						t.Error("FetchSecrets() trusted ca does not match the root")
+					}
+				}
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("FetchSecrets() names = %v, want %v", names, tt.wantNames)
+			}
+			if resp.TypeURL != SecretTypeURL {
+				t.Errorf("FetchSecrets() type url = %s, want %s", resp.TypeURL, SecretTypeURL)
+			}
+		})
+	}
+}
+
+func TestServer_StreamSecrets(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Certificate times have a precision of one second.
+	auth := &mockAuthority{ca: ca, lifetime: 3 * time.Second}
+	conn := newClient(t, New(auth), ca, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := conn.NewStream(ctx, &serviceDesc.Streams[0], "/"+ServiceName+"/StreamSecrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func() *DiscoveryResponse {
+		t.Helper()
+		resp := new(DiscoveryResponse)
+		if err := stream.RecvMsg(resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if err := stream.SendMsg(&DiscoveryRequest{ResourceNames: []string{CertificateName}, TypeURL: SecretTypeURL}); err != nil {
+		t.Fatal(err)
+	}
+	first := recv()
+	crt1 := parseTLSCertificate(t, first.Resources[0])
+
+	// The ACK and NACK do not send the secrets again, but a new
+	// subscription does.
+	if err := stream.SendMsg(&DiscoveryRequest{ResourceNames: []string{CertificateName}, ResponseNonce: first.Nonce, VersionInfo: first.VersionInfo}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&DiscoveryRequest{ResourceNames: []string{CertificateName}, ResponseNonce: first.Nonce, ErrorDetail: &Status{Code: 3, Message: "bad secret"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&DiscoveryRequest{ResourceNames: []string{CertificateName, RootCAName}, ResponseNonce: first.Nonce}); err != nil {
+		t.Fatal(err)
+	}
+	second := recv()
+	if second.Nonce == first.Nonce || len(second.Resources) != 2 {
+		t.Fatalf("unexpected response %+v", second)
+	}
+	// The certificate is not issued again.
+	if second.VersionInfo != first.VersionInfo {
+		t.Errorf("StreamSecrets() version = %s, want %s", second.VersionInfo, first.VersionInfo)
+	}
+
+	// The certificate is renewed after two thirds of its lifetime.
+	third := recv()
+	crt2 := parseTLSCertificate(t, third.Resources[0])
+	if third.VersionInfo == second.VersionInfo || crt2.SerialNumber.Cmp(crt1.SerialNumber) == 0 {
+		t.Error("StreamSecrets() certificate has not been renewed")
+	}
+
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	if len(auth.renewed) != 2 || auth.renewed[1].SerialNumber.Cmp(crt1.SerialNumber) != 0 {
+		t.Error("StreamSecrets() did not renew the last certificate")
+	}
+}
+
+func TestServer_SetAuthority(t *testing.T) {
+	ca, err := minica.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(&mockAuthority{ca: ca, err: errors.New("forbidden")})
+	auth := &mockAuthority{ca: ca, lifetime: time.Hour}
+	s.SetAuthority(auth)
+	if s.getAuthority() != auth {
+		t.Error("Server.SetAuthority() did not replace the authority")
+	}
+}
+
+func TestMessages(t *testing.T) {
+	req := &DiscoveryRequest{
+		VersionInfo:   "1",
+		Node:          &Node{ID: "id", Cluster: "cluster"},
+		ResourceNames: []string{"a", "b"},
+		TypeURL:       SecretTypeURL,
+		ResponseNonce: "2",
+		ErrorDetail:   &Status{Code: 13, Message: "error"},
+	}
+	got := new(DiscoveryRequest)
+	if err := got.Unmarshal(req.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, req) {
+		t.Errorf("DiscoveryRequest = %+v, want %+v", got, req)
+	}
+
+	resp := &DiscoveryResponse{
+		VersionInfo: "1",
+		Resources: []*Secret{
+			{Name: "a", TLSCertificate: &TLSCertificate{CertificateChain: []byte("chain"), PrivateKey: []byte("key")}},
+			{Name: "b", ValidationContext: &ValidationContext{TrustedCA: []byte("roots")}},
+		},
+		TypeURL: SecretTypeURL,
+		Nonce:   "2",
+	}
+	gotResp := new(DiscoveryResponse)
+	if err := gotResp.Unmarshal(resp.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotResp, resp) {
+		t.Errorf("DiscoveryResponse = %+v, want %+v", gotResp, resp)
+	}
+
+	if err := new(DiscoveryRequest).Unmarshal([]byte{0x0a, 0x05, 'a'}); err == nil {
+		t.Error("DiscoveryRequest.Unmarshal() error = nil, want error")
+	}
+}
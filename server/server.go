@@ -102,6 +102,20 @@ func (srv *Server) Shutdown() error {
 	return srv.Server.Shutdown(ctx)
 }
 
+// ShutdownContext stops accepting new connections and waits for the active
+// ones until the given context is done. The connections that are still
+// active at that point are closed.
+func (srv *Server) ShutdownContext(ctx context.Context) error {
+	defer close(srv.shutdownCh) // close shutdown channel
+	if err := srv.Server.Shutdown(ctx); err != nil {
+		if cerr := srv.Server.Close(); cerr != nil {
+			log.Printf("error closing server connections: %v", cerr)
+		}
+		return errors.Wrap(err, "error draining server connections")
+	}
+	return nil
+}
+
 func (srv *Server) reloadShutdown() error {
 	ctx, cancel := context.WithTimeout(context.Background(), ServerShutdownTimeout)
 	defer cancel() // release resources if Shutdown ends before the timeout
@@ -0,0 +1,255 @@
+// Package smime implements the issuance of S/MIME certificates without ACME.
+//
+// The ownership of the email address is proven with a one-time code sent to
+// it, or with an ID token of a trusted OpenID Connect provider. The
+// certificates are created with a template that sets the S/MIME key usages
+// and the SMIMECapabilities extension defined in RFC 4262.
+package smime
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.step.sm/crypto/x509util"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/cache"
+)
+
+const (
+	// DefaultCodeLifetime is the default time a one-time code can be used.
+	DefaultCodeLifetime = 10 * time.Minute
+	// DefaultValidity is the default validity of the certificates issued with
+	// a one-time code.
+	DefaultValidity = 365 * 24 * time.Hour
+	// MaxAttempts is the maximum number of failed verifications of the codes
+	// of an email address during the lifetime of a code.
+	MaxAttempts = 5
+	// MaxCodes is the maximum number of codes sent to an email address during
+	// the lifetime of a code.
+	MaxCodes = 3
+)
+
+var (
+	// ErrInvalidCode is the error returned if the code is not valid or it has
+	// expired.
+	ErrInvalidCode = errors.New("invalid or expired code")
+	// ErrTooManyRequests is the error returned if too many codes have been
+	// requested or verified for the same email address.
+	ErrTooManyRequests = errors.New("too many requests for the email address")
+)
+
+var oidSMIMECapabilities = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 15}
+
+// smimeCapabilities are the symmetric algorithms announced in the
+// SMIMECapabilities extension, in order of preference.
+var smimeCapabilities = []asn1.ObjectIdentifier{
+	{2, 16, 840, 1, 101, 3, 4, 1, 46}, // aes256-GCM
+	{2, 16, 840, 1, 101, 3, 4, 1, 6},  // aes128-GCM
+	{2, 16, 840, 1, 101, 3, 4, 1, 42}, // aes256-CBC
+	{2, 16, 840, 1, 101, 3, 4, 1, 2},  // aes128-CBC
+}
+
+// DefaultTemplate is the template used to create S/MIME certificates. RSA keys
+// can be used for signing and encryption, EC keys for signing and key
+// agreement, and Ed25519 keys only for signing.
+var DefaultTemplate = fmt.Sprintf(`{
+	"subject": {{ toJson .Subject }},
+	"sans": {{ toJson .SANs }},
+{{- if typeIs "*rsa.PublicKey" .Insecure.CR.PublicKey }}
+	"keyUsage": ["digitalSignature", "keyEncipherment"],
+{{- else if typeIs "*ecdsa.PublicKey" .Insecure.CR.PublicKey }}
+	"keyUsage": ["digitalSignature", "keyAgreement"],
+{{- else }}
+	"keyUsage": ["digitalSignature"],
+{{- end }}
+	"extKeyUsage": ["emailProtection"],
+	"extensions": [{"id": %q, "value": %q}]
+}`, oidSMIMECapabilities.String(), mustMarshalCapabilities())
+
+func mustMarshalCapabilities() string {
+	type capability struct {
+		ID asn1.ObjectIdentifier
+	}
+	caps := make([]capability, len(smimeCapabilities))
+	for i, oid := range smimeCapabilities {
+		caps[i] = capability{ID: oid}
+	}
+	b, err := asn1.Marshal(caps)
+	if err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// ValidateEmail checks that the given value is a bare email address.
+func ValidateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return errors.Errorf("invalid email address %q", email)
+	}
+	return nil
+}
+
+// TemplateOptions returns the certificate options that render the default
+// template for the given email address.
+func TemplateOptions(email string) (provisioner.CertificateOptions, error) {
+	data := x509util.CreateTemplateData(email, []string{email})
+	return provisioner.CustomTemplateOptions(nil, data, DefaultTemplate)
+}
+
+// SignOptions returns the sign options used to issue an S/MIME certificate for
+// the given email address after verifying its one-time code.
+func SignOptions(email string, validity time.Duration) ([]provisioner.SignOption, error) {
+	templateOptions, err := TemplateOptions(email)
+	if err != nil {
+		return nil, err
+	}
+	if validity <= 0 {
+		validity = DefaultValidity
+	}
+	return []provisioner.SignOption{
+		templateOptions,
+		provisioner.CertificateModifierFunc(func(cert *x509.Certificate, so provisioner.SignOptions) error {
+			now := time.Now()
+			cert.NotBefore = now.Add(-so.Backdate)
+			cert.NotAfter = now.Add(validity)
+			return nil
+		}),
+		publicKeyValidator{},
+	}, nil
+}
+
+// publicKeyValidator validates the key of the certificate request.
+type publicKeyValidator struct{}
+
+func (publicKeyValidator) Valid(csr *x509.CertificateRequest) error {
+	switch k := csr.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if k.Size() < 256 {
+			return errors.New("rsa key in CSR must be at least 2048 bits (256 bytes)")
+		}
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+	default:
+		return errors.Errorf("unrecognized public key of type '%T' in CSR", k)
+	}
+	return nil
+}
+
+// Mailer is the interface used to send the one-time codes.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// Verifier sends and verifies the one-time codes used to prove the ownership
+// of an email address. The codes are kept in the ephemeral state store, only
+// a hash of the email address and the code is stored.
+type Verifier struct {
+	store    cache.Store
+	mailer   Mailer
+	lifetime time.Duration
+}
+
+// NewVerifier creates a new Verifier that keeps the codes in the given store
+// and sends them with the given mailer.
+func NewVerifier(store cache.Store, mailer Mailer, lifetime time.Duration) *Verifier {
+	if lifetime <= 0 {
+		lifetime = DefaultCodeLifetime
+	}
+	return &Verifier{
+		store:    store,
+		mailer:   mailer,
+		lifetime: lifetime,
+	}
+}
+
+// SendCode generates a new one-time code and sends it to the given email
+// address.
+func (v *Verifier) SendCode(ctx context.Context, email string) error {
+	if err := ValidateEmail(email); err != nil {
+		return err
+	}
+	n, err := v.store.Incr(ctx, key("sent", email), v.lifetime)
+	if err != nil {
+		return errors.Wrap(err, "error storing code")
+	}
+	if n > MaxCodes {
+		return ErrTooManyRequests
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return err
+	}
+	if ok, err := v.store.SetNX(ctx, key("code", email, code), []byte{1}, v.lifetime); err != nil {
+		return errors.Wrap(err, "error storing code")
+	} else if !ok {
+		return errors.New("error storing code: code already exists")
+	}
+
+	body := fmt.Sprintf("Your verification code is %s.\r\n\r\nThe code expires in %s. If you did not request a certificate for %s, you can ignore this message.\r\n",
+		code, v.lifetime, email)
+	if err := v.mailer.Send(email, "Your certificate verification code", body); err != nil {
+		return errors.Wrap(err, "error sending code")
+	}
+	return nil
+}
+
+// VerifyCode checks the one-time code of the given email address. A code can
+// only be used once.
+func (v *Verifier) VerifyCode(ctx context.Context, email, code string) error {
+	if err := ValidateEmail(email); err != nil {
+		return err
+	}
+	n, err := v.store.Incr(ctx, key("attempts", email), v.lifetime)
+	if err != nil {
+		return errors.Wrap(err, "error verifying code")
+	}
+	if n > MaxAttempts {
+		return ErrTooManyRequests
+	}
+	ok, err := v.store.Delete(ctx, key("code", email, strings.TrimSpace(code)))
+	if err != nil {
+		return errors.Wrap(err, "error verifying code")
+	}
+	if !ok {
+		return ErrInvalidCode
+	}
+	// Ignore errors, the attempts will expire anyway.
+	_, _ = v.store.Delete(ctx, key("attempts", email))
+	return nil
+}
+
+func generateCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(100000000))
+	if err != nil {
+		return "", errors.Wrap(err, "error generating code")
+	}
+	return fmt.Sprintf("%08d", n), nil
+}
+
+// key returns the key in the store for the given email and values. The email
+// address is hashed so it's not stored in plain text.
+func key(kind, email string, values ...string) string {
+	h := sha256.New()
+	h.Write([]byte(strings.ToLower(email)))
+	for _, v := range values {
+		h.Write([]byte{0})
+		h.Write([]byte(v))
+	}
+	return "smime:" + kind + ":" + hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,173 @@
+package smime
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/x509util"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/cache"
+)
+
+type mockMailer struct {
+	to, subject, body string
+	err               error
+}
+
+func (m *mockMailer) Send(to, subject, body string) error {
+	m.to, m.subject, m.body = to, subject, body
+	return m.err
+}
+
+var codeRegexp = regexp.MustCompile(`code is (\d{8})\.`)
+
+func (m *mockMailer) code(t *testing.T) string {
+	t.Helper()
+	matches := codeRegexp.FindStringSubmatch(m.body)
+	require.Len(t, matches, 2)
+	return matches[1]
+}
+
+func TestValidateEmail(t *testing.T) {
+	assert.NoError(t, ValidateEmail("jane@smallstep.com"))
+	assert.Error(t, ValidateEmail(""))
+	assert.Error(t, ValidateEmail("jane"))
+	assert.Error(t, ValidateEmail("Jane <jane@smallstep.com>"))
+	assert.Error(t, ValidateEmail("jane@smallstep.com\r\nBcc: joe@smallstep.com"))
+}
+
+func TestVerifier(t *testing.T) {
+	ctx := context.Background()
+	email := "jane@smallstep.com"
+
+	t.Run("ok", func(t *testing.T) {
+		mailer := &mockMailer{}
+		v := NewVerifier(cache.NewMemory(), mailer, 0)
+		require.NoError(t, v.SendCode(ctx, email))
+		assert.Equal(t, email, mailer.to)
+		code := mailer.code(t)
+
+		assert.ErrorIs(t, v.VerifyCode(ctx, "joe@smallstep.com", code), ErrInvalidCode)
+		assert.ErrorIs(t, v.VerifyCode(ctx, email, "00000000"), ErrInvalidCode)
+		assert.NoError(t, v.VerifyCode(ctx, strings.ToUpper(email), code))
+		// Codes can only be used once
+		assert.ErrorIs(t, v.VerifyCode(ctx, email, code), ErrInvalidCode)
+	})
+
+	t.Run("ok/expired", func(t *testing.T) {
+		mailer := &mockMailer{}
+		v := NewVerifier(cache.NewMemory(), mailer, 100*time.Millisecond)
+		require.NoError(t, v.SendCode(ctx, email))
+		time.Sleep(200 * time.Millisecond)
+		assert.ErrorIs(t, v.VerifyCode(ctx, email, mailer.code(t)), ErrInvalidCode)
+	})
+
+	t.Run("fail/attempts", func(t *testing.T) {
+		mailer := &mockMailer{}
+		v := NewVerifier(cache.NewMemory(), mailer, time.Minute)
+		require.NoError(t, v.SendCode(ctx, email))
+		for i := 0; i < MaxAttempts; i++ {
+			assert.ErrorIs(t, v.VerifyCode(ctx, email, "bad"), ErrInvalidCode)
+		}
+		assert.ErrorIs(t, v.VerifyCode(ctx, email, mailer.code(t)), ErrTooManyRequests)
+	})
+
+	t.Run("fail/codes", func(t *testing.T) {
+		v := NewVerifier(cache.NewMemory(), &mockMailer{}, time.Minute)
+		for i := 0; i < MaxCodes; i++ {
+			require.NoError(t, v.SendCode(ctx, email))
+		}
+		assert.ErrorIs(t, v.SendCode(ctx, email), ErrTooManyRequests)
+	})
+
+	t.Run("fail/email", func(t *testing.T) {
+		v := NewVerifier(cache.NewMemory(), &mockMailer{}, time.Minute)
+		assert.EqualError(t, v.SendCode(ctx, "jane"), `invalid email address "jane"`)
+		assert.EqualError(t, v.VerifyCode(ctx, "jane", "12345678"), `invalid email address "jane"`)
+	})
+
+	t.Run("fail/mailer", func(t *testing.T) {
+		v := NewVerifier(cache.NewMemory(), &mockMailer{err: errors.New("smtp error")}, time.Minute)
+		assert.EqualError(t, v.SendCode(ctx, email), "error sending code: smtp error")
+	})
+}
+
+func TestSignOptions(t *testing.T) {
+	email := "jane@smallstep.com"
+	opts, err := SignOptions(email, 0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		kty, crv string
+		size     int
+		keyUsage x509.KeyUsage
+	}{
+		{"rsa", "RSA", "", 2048, x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment},
+		{"ec", "EC", "P-256", 0, x509.KeyUsageDigitalSignature | x509.KeyUsageKeyAgreement},
+		{"ed25519", "OKP", "Ed25519", 0, x509.KeyUsageDigitalSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := keyutil.GenerateSigner(tt.kty, tt.crv, tt.size)
+			require.NoError(t, err)
+			der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"www.smallstep.com"}}, signer)
+			require.NoError(t, err)
+			csr, err := x509.ParseCertificateRequest(der)
+			require.NoError(t, err)
+
+			var certOptions []x509util.Option
+			so := provisioner.SignOptions{Backdate: time.Minute}
+			now := time.Now()
+			cert := &x509.Certificate{}
+			for _, op := range opts {
+				switch o := op.(type) {
+				case provisioner.CertificateOptions:
+					certOptions = append(certOptions, o.Options(so)...)
+				case provisioner.CertificateRequestValidator:
+					require.NoError(t, o.Valid(csr))
+				case provisioner.CertificateModifier:
+					require.NoError(t, o.Modify(cert, so))
+				}
+			}
+			c, err := x509util.NewCertificate(csr, certOptions...)
+			require.NoError(t, err)
+			leaf := c.GetCertificate()
+
+			assert.Equal(t, email, leaf.Subject.CommonName)
+			assert.Equal(t, []string{email}, leaf.EmailAddresses)
+			assert.Empty(t, leaf.DNSNames)
+			assert.Equal(t, tt.keyUsage, leaf.KeyUsage)
+			assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection}, leaf.ExtKeyUsage)
+			if assert.Len(t, leaf.ExtraExtensions, 1) {
+				assert.Equal(t, oidSMIMECapabilities, leaf.ExtraExtensions[0].Id)
+				var caps []struct{ ID asn1.ObjectIdentifier }
+				_, err := asn1.Unmarshal(leaf.ExtraExtensions[0].Value, &caps)
+				require.NoError(t, err)
+				assert.Len(t, caps, len(smimeCapabilities))
+			}
+			assert.WithinDuration(t, now.Add(-time.Minute), cert.NotBefore, time.Second)
+			assert.WithinDuration(t, now.Add(DefaultValidity), cert.NotAfter, time.Second)
+		})
+	}
+
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, weak)
+	require.NoError(t, err)
+	csr, err := x509.ParseCertificateRequest(der)
+	require.NoError(t, err)
+	assert.Error(t, publicKeyValidator{}.Valid(csr))
+}
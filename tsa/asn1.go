@@ -0,0 +1,157 @@
+package tsa
+
+import (
+	"crypto"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"time"
+)
+
+var (
+	oidSignedData                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidAttributeContentType      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttributeSigningCertV2    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidExtensionExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidSignatureSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSignatureECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+// hashes are the hash algorithms accepted in the message imprint.
+var hashes = map[string]crypto.Hash{
+	oidSHA256.String(): crypto.SHA256,
+	oidSHA384.String(): crypto.SHA384,
+	oidSHA512.String(): crypto.SHA512,
+}
+
+// PKIStatus values defined in RFC 3161, section 2.4.2.
+const (
+	StatusGranted                = 0
+	StatusGrantedWithMods        = 1
+	StatusRejection              = 2
+	StatusWaiting                = 3
+	StatusRevocationWarning      = 4
+	StatusRevocationNotification = 5
+)
+
+// FailureInfo values defined in RFC 3161, section 2.4.2. They are the position
+// of the bit in the PKIFailureInfo bit string.
+const (
+	FailureBadAlg              = 0
+	FailureBadRequest          = 2
+	FailureBadDataFormat       = 5
+	FailureTimeNotAvailable    = 14
+	FailureUnacceptedPolicy    = 15
+	FailureUnacceptedExtension = 16
+	FailureAddInfoNotAvailable = 17
+	FailureSystemFailure       = 25
+)
+
+// messageImprint is the MessageImprint structure.
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// timeStampReq is the TimeStampReq structure.
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+	Extensions     []pkix.Extension      `asn1:"optional,tag:0"`
+}
+
+// pkiStatusInfo is the PKIStatusInfo structure.
+type pkiStatusInfo struct {
+	Status       int
+	StatusString asn1.RawValue  `asn1:"optional"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+// timeStampResp is the TimeStampResp structure.
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// accuracy is the Accuracy structure.
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+// tstInfo is the TSTInfo structure.
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Accuracy       accuracy  `asn1:"optional"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
+
+// contentInfo is the CMS ContentInfo structure. The content is the explicitly
+// tagged SignedData.
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+// encapsulatedContentInfo is the CMS EncapsulatedContentInfo structure.
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,tag:0"`
+}
+
+// signedData is the CMS SignedData structure.
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+// signerInfo is the CMS SignerInfo structure.
+type signerInfo struct {
+	Version            int
+	Sid                issuerAndSerialNumber
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+// issuerAndSerialNumber is the CMS IssuerAndSerialNumber structure.
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// attribute is the CMS Attribute structure.
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// signingCertificateV2 is the ESS SigningCertificateV2 structure defined in
+// RFC 5035. The hash algorithm is the default SHA-256.
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+// essCertIDv2 is the ESS ESSCertIDv2 structure.
+type essCertIDv2 struct {
+	CertHash []byte
+}
@@ -0,0 +1,304 @@
+// Package tsa implements a time-stamp authority (TSA) as defined in RFC 3161.
+//
+// The time-stamp tokens are CMS SignedData structures with a TSTInfo content,
+// signed with a dedicated key and certificate. The certificate must only have
+// the critical time stamping extended key usage.
+package tsa
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxSerialNumber is the upper bound of the serial numbers of the tokens.
+var maxSerialNumber = new(big.Int).Lsh(big.NewInt(1), 128)
+
+// Options are the options used to create the time-stamp tokens.
+type Options struct {
+	// Policies are the TSA policies that can be requested. The first one is
+	// the default policy.
+	Policies []asn1.ObjectIdentifier
+	// Accuracy is the accuracy of the time in the tokens. If it is 0, the
+	// accuracy is not added to the tokens.
+	Accuracy time.Duration
+}
+
+// TSA is a time-stamp authority.
+type TSA struct {
+	signer   crypto.Signer
+	chain    []*x509.Certificate
+	policies []asn1.ObjectIdentifier
+	accuracy time.Duration
+	now      func() time.Time
+}
+
+// New creates a new TSA with the given signer and certificate chain. The
+// first certificate of the chain must be the TSA certificate.
+func New(signer crypto.Signer, chain []*x509.Certificate, opts Options) (*TSA, error) {
+	switch {
+	case signer == nil:
+		return nil, errors.New("tsa signer cannot be nil")
+	case len(chain) == 0:
+		return nil, errors.New("tsa certificate cannot be empty")
+	case len(opts.Policies) == 0:
+		return nil, errors.New("tsa policies cannot be empty")
+	case opts.Accuracy < 0:
+		return nil, errors.New("tsa accuracy cannot be negative")
+	}
+	if err := ValidateCertificate(chain[0], signer.Public()); err != nil {
+		return nil, err
+	}
+	if _, err := signatureAlgorithm(signer.Public()); err != nil {
+		return nil, err
+	}
+	return &TSA{
+		signer:   signer,
+		chain:    chain,
+		policies: opts.Policies,
+		accuracy: opts.Accuracy,
+		now:      time.Now,
+	}, nil
+}
+
+// ValidateCertificate checks that the given certificate can be used by a TSA
+// with the given key. As required by RFC 3161, the certificate must have a
+// critical extended key usage extension with only the time stamping usage.
+func ValidateCertificate(cert *x509.Certificate, pub crypto.PublicKey) error {
+	if k, ok := pub.(interface{ Equal(crypto.PublicKey) bool }); !ok || !k.Equal(cert.PublicKey) {
+		return errors.New("tsa key does not match the certificate")
+	}
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageTimeStamping || len(cert.UnknownExtKeyUsage) != 0 {
+		return errors.New("tsa certificate must only have the timeStamping extended key usage")
+	}
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidExtensionExtendedKeyUsage) && !ext.Critical {
+			return errors.New("tsa certificate extended key usage must be critical")
+		}
+	}
+	return nil
+}
+
+// Certificate returns the TSA certificate.
+func (t *TSA) Certificate() *x509.Certificate {
+	return t.chain[0]
+}
+
+// Respond parses the DER encoded TimeStampReq and returns the DER encoded
+// TimeStampResp. Invalid requests are rejected with the appropriate failure
+// info in the response, an error is only returned if the response cannot be
+// created.
+func (t *TSA) Respond(der []byte) ([]byte, error) {
+	var req timeStampReq
+	if rest, err := asn1.Unmarshal(der, &req); err != nil || len(rest) > 0 {
+		return rejection(FailureBadDataFormat, "invalid time-stamp request")
+	}
+	if req.Version != 1 {
+		return rejection(FailureBadRequest, "unsupported time-stamp request version")
+	}
+	h, ok := hashes[req.MessageImprint.HashAlgorithm.Algorithm.String()]
+	if !ok {
+		return rejection(FailureBadAlg, "unsupported hash algorithm")
+	}
+	if len(req.MessageImprint.HashedMessage) != h.Size() {
+		return rejection(FailureBadDataFormat, "invalid hashed message length")
+	}
+	if len(req.Extensions) > 0 {
+		return rejection(FailureUnacceptedExtension, "extensions are not supported")
+	}
+
+	policy := t.policies[0]
+	if len(req.ReqPolicy) > 0 {
+		var found bool
+		for _, p := range t.policies {
+			if p.Equal(req.ReqPolicy) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return rejection(FailureUnacceptedPolicy, "unaccepted policy")
+		}
+		policy = req.ReqPolicy
+	}
+
+	token, err := t.sign(&req, policy)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(timeStampResp{
+		Status:         pkiStatusInfo{Status: StatusGranted},
+		TimeStampToken: asn1.RawValue{FullBytes: token},
+	})
+}
+
+// sign creates the time-stamp token for the given request.
+func (t *TSA) sign(req *timeStampReq, policy asn1.ObjectIdentifier) ([]byte, error) {
+	serial, err := rand.Int(rand.Reader, maxSerialNumber)
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating serial number")
+	}
+
+	info := tstInfo{
+		Version:        1,
+		Policy:         policy,
+		MessageImprint: req.MessageImprint,
+		SerialNumber:   serial,
+		GenTime:        t.now().UTC().Truncate(time.Second),
+		Nonce:          req.Nonce,
+	}
+	if t.accuracy > 0 {
+		info.Accuracy = accuracy{
+			Seconds: int(t.accuracy / time.Second),
+			Millis:  int(t.accuracy % time.Second / time.Millisecond),
+			Micros:  int(t.accuracy % time.Millisecond / time.Microsecond),
+		}
+	}
+	content, err := asn1.Marshal(info)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling tstInfo")
+	}
+
+	// Signed attributes
+	cert := t.chain[0]
+	contentDigest := sha256.Sum256(content)
+	certDigest := sha256.Sum256(cert.Raw)
+	attrs, err := marshalAttributes(
+		attributeValue{oidAttributeContentType, oidTSTInfo},
+		attributeValue{oidAttributeMessageDigest, contentDigest[:]},
+		attributeValue{oidAttributeSigningCertV2, signingCertificateV2{
+			Certs: []essCertIDv2{{CertHash: certDigest[:]}},
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature is calculated over the DER encoding of the SET OF
+	// attributes, but they are added to the SignerInfo with an implicit tag.
+	sigAlg, _ := signatureAlgorithm(t.signer.Public())
+	signature, err := t.signAttributes(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	sd := signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: encapsulatedContentInfo{
+			EContentType: oidTSTInfo,
+			EContent:     content,
+		},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			Sid: issuerAndSerialNumber{
+				Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+				SerialNumber: cert.SerialNumber,
+			},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrs},
+			SignatureAlgorithm: sigAlg,
+			Signature:          signature,
+		}},
+	}
+	if req.CertReq {
+		var certs []byte
+		for _, crt := range t.chain {
+			certs = append(certs, crt.Raw...)
+		}
+		sd.Certificates = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs}
+	}
+	b, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling signedData")
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: b},
+	})
+}
+
+func (t *TSA) signAttributes(attrs []byte) ([]byte, error) {
+	set, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: attrs})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling attributes")
+	}
+	sum := sha256.Sum256(set)
+	signature, err := t.signer.Sign(rand.Reader, sum[:], crypto.SHA256)
+	if err != nil {
+		return nil, errors.Wrap(err, "error signing time-stamp token")
+	}
+	return signature, nil
+}
+
+type attributeValue struct {
+	Type  asn1.ObjectIdentifier
+	Value any
+}
+
+// marshalAttributes returns the DER encoding of the given attributes, sorted
+// as required by the DER encoding of a SET OF, without the SET tag.
+func marshalAttributes(values ...attributeValue) ([]byte, error) {
+	encoded := make([][]byte, len(values))
+	for i, v := range values {
+		b, err := asn1.Marshal(v.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error marshaling attribute %s", v.Type)
+		}
+		if encoded[i], err = asn1.Marshal(attribute{
+			Type:   v.Type,
+			Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: b},
+		}); err != nil {
+			return nil, errors.Wrapf(err, "error marshaling attribute %s", v.Type)
+		}
+	}
+	// Sort the elements of the SET OF
+	for i := 1; i < len(encoded); i++ {
+		for j := i; j > 0 && bytes.Compare(encoded[j], encoded[j-1]) < 0; j-- {
+			encoded[j], encoded[j-1] = encoded[j-1], encoded[j]
+		}
+	}
+	return bytes.Join(encoded, nil), nil
+}
+
+func signatureAlgorithm(pub crypto.PublicKey) (pkix.AlgorithmIdentifier, error) {
+	switch pub.(type) {
+	case *ecdsa.PublicKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSAWithSHA256}, nil
+	case *rsa.PublicKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidSignatureSHA256WithRSA, Parameters: asn1.NullRawValue}, nil
+	default:
+		return pkix.AlgorithmIdentifier{}, errors.Errorf("unsupported tsa key type %T", pub)
+	}
+}
+
+// rejection returns a TimeStampResp with the rejection status and the given
+// failure info.
+func rejection(failure int, msg string) ([]byte, error) {
+	info := asn1.BitString{
+		Bytes:     make([]byte, failure/8+1),
+		BitLength: failure + 1,
+	}
+	info.Bytes[failure/8] |= 0x80 >> (failure % 8)
+	text, err := asn1.MarshalWithParams(msg, "utf8")
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling status")
+	}
+	return asn1.Marshal(timeStampResp{
+		Status: pkiStatusInfo{
+			Status:       StatusRejection,
+			StatusString: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: text},
+			FailInfo:     info,
+		},
+	})
+}
@@ -0,0 +1,192 @@
+package tsa
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mozilla.org/pkcs7"
+	"go.step.sm/crypto/keyutil"
+	"go.step.sm/crypto/minica"
+)
+
+var testPolicy = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37476, 9000, 64, 1}
+
+func mustTSACertificate(t *testing.T, ca *minica.CA, critical bool) (crypto.Signer, *x509.Certificate) {
+	t.Helper()
+	signer, err := keyutil.GenerateDefaultSigner()
+	require.NoError(t, err)
+	eku, err := asn1.Marshal([]asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 8}})
+	require.NoError(t, err)
+	crt, err := ca.Sign(&x509.Certificate{
+		Subject:   pkix.Name{CommonName: "Test TSA"},
+		PublicKey: signer.Public(),
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidExtensionExtendedKeyUsage, Critical: critical, Value: eku},
+		},
+	})
+	require.NoError(t, err)
+	return signer, crt
+}
+
+func mustRequest(t *testing.T, req timeStampReq) []byte {
+	t.Helper()
+	b, err := asn1.Marshal(req)
+	require.NoError(t, err)
+	return b
+}
+
+func parseResponse(t *testing.T, b []byte) timeStampResp {
+	t.Helper()
+	var resp timeStampResp
+	rest, err := asn1.Unmarshal(b, &resp)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+	return resp
+}
+
+func TestNew(t *testing.T) {
+	ca, err := minica.New()
+	require.NoError(t, err)
+	signer, crt := mustTSACertificate(t, ca, true)
+	nonCriticalSigner, nonCritical := mustTSACertificate(t, ca, false)
+	opts := Options{Policies: []asn1.ObjectIdentifier{testPolicy}}
+
+	_, err = New(signer, []*x509.Certificate{crt, ca.Intermediate}, opts)
+	assert.NoError(t, err)
+
+	_, err = New(nil, []*x509.Certificate{crt}, opts)
+	assert.EqualError(t, err, "tsa signer cannot be nil")
+	_, err = New(signer, nil, opts)
+	assert.EqualError(t, err, "tsa certificate cannot be empty")
+	_, err = New(signer, []*x509.Certificate{crt}, Options{})
+	assert.EqualError(t, err, "tsa policies cannot be empty")
+	_, err = New(signer, []*x509.Certificate{crt}, Options{Policies: opts.Policies, Accuracy: -1})
+	assert.EqualError(t, err, "tsa accuracy cannot be negative")
+	_, err = New(signer, []*x509.Certificate{ca.Intermediate}, opts)
+	assert.EqualError(t, err, "tsa key does not match the certificate")
+	_, err = New(ca.Signer, []*x509.Certificate{ca.Intermediate}, opts)
+	assert.EqualError(t, err, "tsa certificate must only have the timeStamping extended key usage")
+	_, err = New(nonCriticalSigner, []*x509.Certificate{nonCritical}, opts)
+	assert.EqualError(t, err, "tsa certificate extended key usage must be critical")
+}
+
+func TestTSA_Respond(t *testing.T) {
+	ca, err := minica.New()
+	require.NoError(t, err)
+	signer, crt := mustTSACertificate(t, ca, true)
+	otherPolicy := asn1.ObjectIdentifier{1, 2, 3, 4}
+
+	tsa, err := New(signer, []*x509.Certificate{crt, ca.Intermediate}, Options{
+		Policies: []asn1.ObjectIdentifier{testPolicy, otherPolicy},
+		Accuracy: 1500 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	tsa.now = func() time.Time { return now }
+
+	sum := sha256.Sum256([]byte("the data"))
+	imprint := messageImprint{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+		HashedMessage: sum[:],
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Root)
+
+	t.Run("ok", func(t *testing.T) {
+		b, err := tsa.Respond(mustRequest(t, timeStampReq{
+			Version:        1,
+			MessageImprint: imprint,
+			Nonce:          big.NewInt(1234),
+			CertReq:        true,
+		}))
+		require.NoError(t, err)
+		resp := parseResponse(t, b)
+		assert.Equal(t, StatusGranted, resp.Status.Status)
+
+		p7, err := pkcs7.Parse(resp.TimeStampToken.FullBytes)
+		require.NoError(t, err)
+		require.NoError(t, p7.VerifyWithChain(roots))
+		assert.Equal(t, []*x509.Certificate{crt, ca.Intermediate}, p7.Certificates)
+
+		var info tstInfo
+		_, err = asn1.Unmarshal(p7.Content, &info)
+		require.NoError(t, err)
+		assert.Equal(t, 1, info.Version)
+		assert.Equal(t, testPolicy, info.Policy)
+		assert.Equal(t, imprint, info.MessageImprint)
+		assert.Equal(t, big.NewInt(1234), info.Nonce)
+		assert.True(t, now.Equal(info.GenTime))
+		assert.Equal(t, accuracy{Seconds: 1, Millis: 500}, info.Accuracy)
+	})
+
+	t.Run("ok/policy", func(t *testing.T) {
+		b, err := tsa.Respond(mustRequest(t, timeStampReq{
+			Version:        1,
+			MessageImprint: imprint,
+			ReqPolicy:      otherPolicy,
+		}))
+		require.NoError(t, err)
+		resp := parseResponse(t, b)
+		assert.Equal(t, StatusGranted, resp.Status.Status)
+
+		// Without certReq the certificates must be provided by the client.
+		p7, err := pkcs7.Parse(resp.TimeStampToken.FullBytes)
+		require.NoError(t, err)
+		assert.Empty(t, p7.Certificates)
+		p7.Certificates = []*x509.Certificate{crt, ca.Intermediate}
+		require.NoError(t, p7.VerifyWithChain(roots))
+
+		var info tstInfo
+		_, err = asn1.Unmarshal(p7.Content, &info)
+		require.NoError(t, err)
+		assert.Equal(t, otherPolicy, info.Policy)
+		assert.Nil(t, info.Nonce)
+	})
+
+	failures := []struct {
+		name    string
+		req     []byte
+		failure int
+	}{
+		{"fail/format", []byte("not a request"), FailureBadDataFormat},
+		{"fail/version", mustRequest(t, timeStampReq{Version: 2, MessageImprint: imprint}), FailureBadRequest},
+		{"fail/alg", mustRequest(t, timeStampReq{Version: 1, MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}},
+			HashedMessage: sum[:20],
+		}}), FailureBadAlg},
+		{"fail/length", mustRequest(t, timeStampReq{Version: 1, MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			HashedMessage: sum[:20],
+		}}), FailureBadDataFormat},
+		{"fail/policy", mustRequest(t, timeStampReq{Version: 1, MessageImprint: imprint, ReqPolicy: asn1.ObjectIdentifier{1, 2, 3}}), FailureUnacceptedPolicy},
+		{"fail/extensions", mustRequest(t, timeStampReq{Version: 1, MessageImprint: imprint, Extensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 2, 3}, Value: []byte{0x05, 0x00}},
+		}}), FailureUnacceptedExtension},
+	}
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tsa.Respond(tt.req)
+			require.NoError(t, err)
+			resp := parseResponse(t, b)
+			assert.Equal(t, StatusRejection, resp.Status.Status)
+			assert.Equal(t, 1, resp.Status.FailInfo.At(tt.failure))
+			assert.Equal(t, tt.failure+1, resp.Status.FailInfo.BitLength)
+			assert.Empty(t, resp.TimeStampToken.FullBytes)
+
+			var text []string
+			_, err = asn1.Unmarshal(resp.Status.StatusString.FullBytes, &text)
+			require.NoError(t, err)
+			assert.Len(t, text, 1)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	// SignatureHeader is the header with the hex encoded HMAC-SHA256 of the
+	// body of a webhook request or response.
+	SignatureHeader = "X-Smallstep-Signature"
+	// IDHeader is the header with the id of the webhook.
+	IDHeader = "X-Smallstep-Webhook-ID"
+)
+
+// DefaultMaxSkew is the default maximum difference between the timestamp of
+// a request and the current time accepted by VerifyTimestamp.
+const DefaultMaxSkew = 5 * time.Minute
+
+// Sign returns the hex encoded HMAC-SHA256 of the body, as sent in the
+// X-Smallstep-Signature header of the webhook requests. The request body
+// includes a timestamp and a nonce, so the signature covers both of them.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks that the hex encoded signature is the HMAC-SHA256 of the
+// body.
+func Verify(secret, body []byte, signature string) error {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) == 0 {
+		return errors.New("webhook signature is not valid")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return errors.New("webhook signature does not match")
+	}
+	return nil
+}
+
+// SignResponse returns the signature a webhook server must send in the
+// X-Smallstep-Signature header of a response if the CA is configured to
+// verify it. It is the hex encoded HMAC-SHA256 of the nonce of the request,
+// a dot, and the response body, so a response cannot be replayed for a
+// different request.
+func SignResponse(secret []byte, nonce string, body []byte) string {
+	return Sign(secret, responsePayload(nonce, body))
+}
+
+// VerifyResponse checks the signature of a webhook response for the request
+// with the given nonce.
+func VerifyResponse(secret []byte, nonce string, body []byte, signature string) error {
+	return Verify(secret, responsePayload(nonce, body), signature)
+}
+
+// VerifyTimestamp checks that the timestamp of a request is within maxSkew
+// of the current time. Receivers can combine it with a cache of the nonces
+// seen in that window to reject replayed requests.
+func VerifyTimestamp(t time.Time, maxSkew time.Duration) error {
+	if maxSkew <= 0 {
+		maxSkew = DefaultMaxSkew
+	}
+	if d := time.Since(t); d > maxSkew || d < -maxSkew {
+		return errors.New("webhook request timestamp is outside the allowed window")
+	}
+	return nil
+}
+
+// NewNonce returns a new random nonce for a webhook request.
+func NewNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func responsePayload(nonce string, body []byte) []byte {
+	b := make([]byte, 0, len(nonce)+1+len(body))
+	b = append(b, nonce...)
+	b = append(b, '.')
+	return append(b, body...)
+}
@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"timestamp":"2026-01-02T03:04:05Z","nonce":"abc"}`)
+
+	sig := Sign(secret, body)
+	assert.Equal(t, "9d179f9d698b8b7178f676a0988fb4377a997dfc2ca21c561e819e91cf82ee33", sig)
+	assert.NoError(t, Verify(secret, body, sig))
+	assert.EqualError(t, Verify([]byte("other"), body, sig), "webhook signature does not match")
+	assert.EqualError(t, Verify(secret, []byte("{}"), sig), "webhook signature does not match")
+	assert.EqualError(t, Verify(secret, body, "not-hex"), "webhook signature is not valid")
+	assert.EqualError(t, Verify(secret, body, ""), "webhook signature is not valid")
+}
+
+func TestSignResponse(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"allow":true}`)
+
+	sig := SignResponse(secret, "nonce", body)
+	assert.NotEqual(t, Sign(secret, body), sig)
+	assert.NoError(t, VerifyResponse(secret, "nonce", body, sig))
+	assert.EqualError(t, VerifyResponse(secret, "other", body, sig), "webhook signature does not match")
+}
+
+func TestVerifyTimestamp(t *testing.T) {
+	now := time.Now()
+	assert.NoError(t, VerifyTimestamp(now, 0))
+	assert.NoError(t, VerifyTimestamp(now.Add(-time.Minute), time.Minute+time.Second))
+	assert.Error(t, VerifyTimestamp(now.Add(-10*time.Minute), 0))
+	assert.Error(t, VerifyTimestamp(now.Add(10*time.Minute), 0))
+
+	n1, err := NewNonce()
+	assert.NoError(t, err)
+	n2, err := NewNonce()
+	assert.NoError(t, err)
+	assert.Len(t, n1, 32)
+	assert.NotEqual(t, n1, n2)
+}
@@ -70,6 +70,9 @@ type X5CCertificate struct {
 // RequestBody is the body sent to webhook servers.
 type RequestBody struct {
 	Timestamp time.Time `json:"timestamp"`
+	// Nonce is a random value unique to each request, receivers can use it
+	// with the timestamp to reject replayed requests
+	Nonce string `json:"nonce,omitempty"`
 	// Only set after successfully completing acme device-attest-01 challenge
 	AttestationData *AttestationData `json:"attestationData,omitempty"`
 	// Set for most provisioners, but not acme or scep